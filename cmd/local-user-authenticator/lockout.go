@@ -0,0 +1,104 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// loginLimiter counts the consecutive failed logins of each user, and locks a user out for a while after too many of
+// them, so that the passwords of a webhook which is reachable from the internet cannot be guessed quickly. Only the
+// users which exist are tracked, since tracking every username which anyone tries would let them grow the map forever.
+//
+// The failures are keyed only by username. The requests come from the Concierge or the API server rather than from the
+// users, so their source addresses are unknown here, and anyone who can attempt logins can keep a user locked out.
+type loginLimiter struct {
+	maxFailures     int
+	lockoutDuration time.Duration
+	clock           func() time.Time
+
+	lock  sync.Mutex
+	users map[string]*loginFailures
+}
+
+type loginFailures struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// newLoginLimiter returns a limiter which locks a user out for the lockoutDuration after maxFailures consecutive
+// failed logins, or nil when maxFailures is not positive. A nil limiter is valid and never locks anyone out.
+func newLoginLimiter(maxFailures int, lockoutDuration time.Duration) *loginLimiter {
+	if maxFailures <= 0 {
+		return nil
+	}
+	return &loginLimiter{
+		maxFailures:     maxFailures,
+		lockoutDuration: lockoutDuration,
+		clock:           time.Now,
+		users:           map[string]*loginFailures{},
+	}
+}
+
+// lockedOut returns whether the user is locked out, and until when.
+func (l *loginLimiter) lockedOut(username string) (time.Time, bool) {
+	if l == nil {
+		return time.Time{}, false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	failures, ok := l.users[username]
+	if !ok || !l.clock().Before(failures.lockedUntil) {
+		return time.Time{}, false
+	}
+	return failures.lockedUntil, true
+}
+
+// failed records a failed login of the user. It returns the number of consecutive failures, and the time until which
+// the user is locked out when this failure locked them out. The count starts over after a lockout, and after a quiet
+// period of the lockoutDuration without any failures.
+func (l *loginLimiter) failed(username string) (int, time.Time) {
+	if l == nil {
+		return 0, time.Time{}
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.clock()
+	l.forgetStale(now)
+	failures, ok := l.users[username]
+	if !ok {
+		failures = &loginFailures{}
+		l.users[username] = failures
+	}
+	failures.count++
+	failures.lastFailure = now
+	if failures.count < l.maxFailures {
+		return failures.count, time.Time{}
+	}
+	count := failures.count
+	failures.count = 0
+	failures.lockedUntil = now.Add(l.lockoutDuration)
+	return count, failures.lockedUntil
+}
+
+// succeeded forgets the failed logins of the user.
+func (l *loginLimiter) succeeded(username string) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.users, username)
+}
+
+// forgetStale removes the users who are not locked out and whose last failure was long enough ago to not count anymore.
+func (l *loginLimiter) forgetStale(now time.Time) {
+	for username, failures := range l.users {
+		if !now.Before(failures.lockedUntil) && !now.Before(failures.lastFailure.Add(l.lockoutDuration)) {
+			delete(l.users, username)
+		}
+	}
+}
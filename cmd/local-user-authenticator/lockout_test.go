@@ -0,0 +1,114 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	authenticationv1beta1 "k8s.io/api/authentication/v1beta1"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	now := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	l := newLoginLimiter(3, time.Minute)
+	l.clock = func() time.Time { return now }
+
+	requireFailed := func(username string, wantCount int, wantLockedUntil time.Time) {
+		t.Helper()
+		count, lockedUntil := l.failed(username)
+		require.Equal(t, wantCount, count)
+		require.Equal(t, wantLockedUntil, lockedUntil)
+	}
+	requireLockedOut := func(username string, wantLockedUntil time.Time) {
+		t.Helper()
+		lockedUntil, locked := l.lockedOut(username)
+		require.Equal(t, !wantLockedUntil.IsZero(), locked)
+		require.Equal(t, wantLockedUntil, lockedUntil)
+	}
+
+	// A success forgets the failures before it.
+	requireFailed("some-user", 1, time.Time{})
+	requireFailed("some-user", 2, time.Time{})
+	l.succeeded("some-user")
+	requireFailed("some-user", 1, time.Time{})
+	requireFailed("some-user", 2, time.Time{})
+	requireLockedOut("some-user", time.Time{})
+
+	// The users are counted separately.
+	requireFailed("other-user", 1, time.Time{})
+
+	// Too many consecutive failures lock the user out.
+	lockedUntil := now.Add(time.Minute)
+	requireFailed("some-user", 3, lockedUntil)
+	requireLockedOut("some-user", lockedUntil)
+	requireLockedOut("other-user", time.Time{})
+
+	// The lockout ends after the lockout duration, and then the count starts over.
+	now = lockedUntil.Add(-time.Nanosecond)
+	requireLockedOut("some-user", lockedUntil)
+	now = lockedUntil
+	requireLockedOut("some-user", time.Time{})
+	requireFailed("some-user", 1, time.Time{})
+
+	// The failures are forgotten after a quiet period.
+	now = now.Add(time.Minute)
+	requireFailed("some-user", 1, time.Time{})
+	require.Len(t, l.users, 1)
+}
+
+func TestLoginLimiterDisabled(t *testing.T) {
+	l := newLoginLimiter(0, time.Minute)
+	require.Nil(t, l)
+
+	// A nil limiter never locks anyone out.
+	for i := 0; i < 10; i++ {
+		count, lockedUntil := l.failed("some-user")
+		require.Zero(t, count)
+		require.True(t, lockedUntil.IsZero())
+	}
+	_, locked := l.lockedOut("some-user")
+	require.False(t, locked)
+	l.succeeded("some-user")
+}
+
+func TestWebhookLockout(t *testing.T) {
+	kubeClient := kubernetesfake.NewSimpleClientset()
+	addSecretToFakeClientTracker(t, kubeClient, "some-user", "some-uid", "some-password", "")
+	secretInformer := createSecretInformer(t, kubeClient)
+
+	w := newWebhook(nil, secretInformer, nil, newLoginLimiter(2, time.Minute))
+
+	authenticate := func(token string) bool {
+		t.Helper()
+		body, err := newTokenReviewBody(token)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/authenticate", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		rsp := httptest.NewRecorder()
+		w.ServeHTTP(rsp, req)
+		require.Equal(t, http.StatusOK, rsp.Code)
+		var tr authenticationv1beta1.TokenReview
+		require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &tr))
+		return tr.Status.Authenticated
+	}
+
+	require.False(t, authenticate("some-user:wrong-password"))
+	require.True(t, authenticate("some-user:some-password"))
+	require.False(t, authenticate("some-user:wrong-password"))
+	require.False(t, authenticate("some-user:wrong-password"))
+
+	// The correct password does not help a user who is locked out.
+	require.False(t, authenticate("some-user:some-password"))
+
+	// Unknown users are not tracked.
+	require.False(t, authenticate("unknown-user:some-password"))
+	require.NotContains(t, w.limiter.users, "unknown-user")
+}
@@ -15,12 +15,14 @@ import (
 	"crypto/tls"
 	"encoding/csv"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"mime"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"time"
 
@@ -39,6 +41,7 @@ import (
 	"go.pinniped.dev/internal/dynamiccert"
 	"go.pinniped.dev/internal/kubeclient"
 	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/versioninfo"
 )
 
 const (
@@ -51,20 +54,32 @@ const (
 	defaultResyncInterval = 3 * time.Minute
 
 	invalidRequest = constable.Error("invalid request")
+
+	// The reasons why the account of a user cannot be used.
+	reasonAccountDisabled = "user account is disabled"
+	reasonAccountExpired  = "user account has expired"
 )
 
 type webhook struct {
 	certProvider   dynamiccert.Provider
 	secretInformer corev1informers.SecretInformer
+	passwords      *passwordCache
+	limiter        *loginLimiter
+	clock          func() time.Time
 }
 
 func newWebhook(
 	certProvider dynamiccert.Provider,
 	secretInformer corev1informers.SecretInformer,
+	passwords *passwordCache,
+	limiter *loginLimiter,
 ) *webhook {
 	return &webhook{
 		certProvider:   certProvider,
 		secretInformer: secretInformer,
+		passwords:      passwords,
+		limiter:        limiter,
+		clock:          time.Now,
 	}
 }
 
@@ -106,8 +121,13 @@ func (w *webhook) start(ctx context.Context, l net.Listener) error {
 }
 
 func (w *webhook) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	result := resultError
+	defer func() { recordAuthentication(result, time.Since(start)) }()
+
 	username, password, err := getUsernameAndPasswordFromRequest(rsp, req)
 	if err != nil {
+		result = resultInvalidRequest
 		return
 	}
 	defer func() { _ = req.Body.Close() }()
@@ -122,34 +142,65 @@ func (w *webhook) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
 
 	if notFound {
 		plog.Debug("user not found")
-		respondWithUnauthenticated(rsp)
+		result = resultUnknownUser
+		respondWithUnauthenticated(rsp, "")
 		return
 	}
 
-	passwordMatches := bcrypt.CompareHashAndPassword(
-		secret.Data["passwordHash"],
-		[]byte(password),
-	) == nil
-	if !passwordMatches {
-		plog.Debug("authentication failed: wrong password")
-		respondWithUnauthenticated(rsp)
+	if lockedUntil, locked := w.limiter.lockedOut(username); locked {
+		plog.Info("authentication rejected: user is locked out", "username", username, "lockedUntil", lockedUntil.UTC().Format(time.RFC3339))
+		result = resultLockedOut
+		respondWithUnauthenticated(rsp, "")
 		return
 	}
 
-	groups := []string{}
-	groupsBuf := bytes.NewBuffer(secret.Data["groups"])
-	if groupsBuf.Len() > 0 {
-		groupsCSVReader := csv.NewReader(groupsBuf)
-		groups, err = groupsCSVReader.Read()
-		if err != nil {
-			plog.Debug("could not read groups", "err", err)
-			rsp.WriteHeader(http.StatusInternalServerError)
+	passwordHash := secret.Data["passwordHash"]
+	if w.passwords.verified(username, passwordHash, password) {
+		plog.Debug("password was verified recently")
+	} else {
+		passwordMatches := bcrypt.CompareHashAndPassword(
+			passwordHash,
+			[]byte(password),
+		) == nil
+		if !passwordMatches {
+			failures, lockedUntil := w.limiter.failed(username)
+			plog.Info("authentication failed: wrong password", "username", username, "failedAttempts", failures)
+			if !lockedUntil.IsZero() {
+				plog.Warning("user is locked out after too many failed attempts", "username", username, "failedAttempts", failures, "lockedUntil", lockedUntil.UTC().Format(time.RFC3339))
+			}
+			result = resultWrongPassword
+			respondWithUnauthenticated(rsp, "")
 			return
 		}
-		trimLeadingAndTrailingWhitespace(groups)
+		w.passwords.add(username, passwordHash, password)
 	}
+	w.limiter.succeeded(username)
 
-	plog.Debug("successful authentication")
+	reason, err := checkAccount(secret.Data, w.clock())
+	if err != nil {
+		plog.Debug("could not read account status", "err", err)
+		rsp.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if reason != "" {
+		plog.Info("authentication rejected: "+reason, "username", username)
+		result = resultDisabled
+		if reason == reasonAccountExpired {
+			result = resultExpired
+		}
+		respondWithUnauthenticated(rsp, reason)
+		return
+	}
+
+	groups, err := parseGroups(secret.Data["groups"])
+	if err != nil {
+		plog.Debug("could not read groups", "err", err)
+		rsp.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	plog.Info("successful authentication", "username", username)
+	result = resultSuccess
 	respondWithAuthenticated(rsp, secret.ObjectMeta.Name, string(secret.UID), groups)
 }
 
@@ -229,13 +280,52 @@ func headerContains(req *http.Request, headerName, s string) bool {
 	return false
 }
 
-func trimLeadingAndTrailingWhitespace(ss []string) {
-	for i := range ss {
-		ss[i] = strings.TrimSpace(ss[i])
+// parseGroups reads the comma-separated group names from the "groups" key of a user Secret. Whitespace around the
+// names is ignored, and so are empty names, e.g. after a trailing comma, since nobody should be in the group "".
+func parseGroups(groupsCSV []byte) ([]string, error) {
+	groups := []string{}
+	if len(groupsCSV) == 0 {
+		return groups, nil
+	}
+	names, err := csv.NewReader(bytes.NewReader(groupsCSV)).Read()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			groups = append(groups, name)
+		}
+	}
+	return groups, nil
+}
+
+// checkAccount returns why the account of a user Secret cannot be used, or "" when it can. The optional "disabled"
+// key is a boolean, and the optional "expiresAt" key is an RFC 3339 timestamp after which the account expires.
+func checkAccount(data map[string][]byte, now time.Time) (string, error) {
+	if disabled, ok := data["disabled"]; ok {
+		isDisabled, err := strconv.ParseBool(strings.TrimSpace(string(disabled)))
+		if err != nil {
+			return "", fmt.Errorf("invalid disabled value %q: %w", disabled, err)
+		}
+		if isDisabled {
+			return reasonAccountDisabled, nil
+		}
 	}
+	if expiresAt, ok := data["expiresAt"]; ok {
+		expiry, err := time.Parse(time.RFC3339, strings.TrimSpace(string(expiresAt)))
+		if err != nil {
+			return "", fmt.Errorf("invalid expiresAt value %q: %w", expiresAt, err)
+		}
+		if !now.Before(expiry) {
+			return reasonAccountExpired, nil
+		}
+	}
+	return "", nil
 }
 
-func respondWithUnauthenticated(rsp http.ResponseWriter) {
+// respondWithUnauthenticated responds that the token is not valid. The reason, when it is not empty, is returned as
+// the error of the TokenReview, which is logged by the Concierge.
+func respondWithUnauthenticated(rsp http.ResponseWriter, reason string) {
 	rsp.Header().Add("Content-Type", "application/json")
 
 	body := authenticationv1beta1.TokenReview{
@@ -245,6 +335,7 @@ func respondWithUnauthenticated(rsp http.ResponseWriter) {
 		},
 		Status: authenticationv1beta1.TokenReviewStatus{
 			Authenticated: false,
+			Error:         reason,
 		},
 	}
 	if err := json.NewEncoder(rsp).Encode(body); err != nil {
@@ -284,14 +375,43 @@ func startControllers(
 	dynamicCertProvider dynamiccert.Provider,
 	kubeClient kubernetes.Interface,
 	kubeInformers kubeinformers.SharedInformerFactory,
+	tlsSecretName string,
 ) {
+	controllerManager := controllerlib.NewManager()
+	if tlsSecretName != "" {
+		// Serve the certificate from the Secret of the user, and follow its rotations.
+		controllerManager = controllerManager.WithController(
+			apicerts.NewTLSSecretObserverController(
+				namespace,
+				tlsSecretName,
+				dynamicCertProvider,
+				kubeInformers.Core().V1().Secrets(),
+				controllerlib.WithInformer,
+			),
+			singletonWorker,
+		)
+	} else {
+		controllerManager = withSelfSignedCertControllers(controllerManager, dynamicCertProvider, kubeClient, kubeInformers)
+	}
+
+	kubeInformers.Start(ctx.Done())
+
+	go controllerManager.Start(ctx)
+}
+
+// withSelfSignedCertControllers adds the controllers which generate a self-signed serving certificate into a Secret,
+// and which serve it.
+func withSelfSignedCertControllers(
+	controllerManager controllerlib.Manager,
+	dynamicCertProvider dynamiccert.Provider,
+	kubeClient kubernetes.Interface,
+	kubeInformers kubeinformers.SharedInformerFactory,
+) controllerlib.Manager {
 	aVeryLongTime := time.Hour * 24 * 365 * 100
 
 	const certsSecretResourceName = "local-user-authenticator-tls-serving-certificate"
 
-	// Create controller manager.
-	controllerManager := controllerlib.
-		NewManager().
+	return controllerManager.
 		WithController(
 			apicerts.NewCertsManagerController(
 				namespace,
@@ -304,6 +424,8 @@ func startControllers(
 				controllerlib.WithInformer,
 				controllerlib.WithInitialEvent,
 				aVeryLongTime,
+				0, // renewBefore, not needed because the certs are never rotated
+				0, // rotationOverlap, not needed because the certs are never rotated
 				"local-user-authenticator CA",
 				serviceName,
 			),
@@ -319,10 +441,6 @@ func startControllers(
 			),
 			singletonWorker,
 		)
-
-	kubeInformers.Start(ctx.Done())
-
-	go controllerManager.Start(ctx)
 }
 
 func startWebhook(
@@ -330,8 +448,10 @@ func startWebhook(
 	l net.Listener,
 	dynamicCertProvider dynamiccert.Provider,
 	secretInformer corev1informers.SecretInformer,
+	passwords *passwordCache,
+	limiter *loginLimiter,
 ) error {
-	return newWebhook(dynamicCertProvider, secretInformer).start(ctx, l)
+	return newWebhook(dynamicCertProvider, secretInformer, passwords, limiter).start(ctx, l)
 }
 
 func waitForSignal() os.Signal {
@@ -340,7 +460,59 @@ func waitForSignal() os.Signal {
 	return <-signalCh
 }
 
-func run() error {
+// options are the command-line flags of the webhook.
+type options struct {
+	// passwordCacheTTL is how long a verified password is remembered. Zero disables the cache.
+	passwordCacheTTL time.Duration
+	// maxFailedLogins is how many consecutive failed logins lock a user out. Zero disables the lockout.
+	maxFailedLogins int
+	// lockoutDuration is how long a user is locked out.
+	lockoutDuration time.Duration
+	// tlsSecretName is the name of a Secret of type kubernetes.io/tls with the serving certificate. When it is empty,
+	// a self-signed certificate is generated.
+	tlsSecretName string
+	// monitoringAddress is the address of the plain HTTP server for /healthz and /metrics. Empty disables it.
+	monitoringAddress string
+	// logFormat is the format of the logs, either text or json.
+	logFormat plog.LogFormat
+}
+
+func parseOptions(args []string) (*options, error) {
+	flags := flag.NewFlagSet("local-user-authenticator", flag.ContinueOnError)
+	var opts options
+	flags.DurationVar(&opts.passwordCacheTTL, "password-cache-ttl", time.Minute,
+		"how long to remember a password which was verified against the bcrypt hash of a user (0 disables the cache)")
+	flags.IntVar(&opts.maxFailedLogins, "max-failed-logins", 5,
+		"how many consecutive failed logins of a user lock them out (0 disables the lockout)")
+	flags.DurationVar(&opts.lockoutDuration, "lockout-duration", 5*time.Minute,
+		"how long a user is locked out after too many failed logins")
+	flags.StringVar(&opts.tlsSecretName, "tls-secret-name", "",
+		"name of a Secret of type kubernetes.io/tls in the namespace with the serving certificate, instead of a generated self-signed certificate")
+	flags.StringVar(&opts.monitoringAddress, "monitoring-address", ":8080",
+		"address of the plain HTTP server for the /healthz and /metrics endpoints (\"\" disables it)")
+	flags.StringVar((*string)(&opts.logFormat), "log-format", string(plog.FormatText), "format of the logs, either text or json")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() != 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+	if opts.passwordCacheTTL < 0 {
+		return nil, fmt.Errorf("--password-cache-ttl must not be negative: %s", opts.passwordCacheTTL)
+	}
+	if err := plog.ValidateLogFormat(opts.logFormat); err != nil {
+		return nil, fmt.Errorf("--log-format: %w", err)
+	}
+	if opts.maxFailedLogins < 0 {
+		return nil, fmt.Errorf("--max-failed-logins must not be negative: %d", opts.maxFailedLogins)
+	}
+	if opts.maxFailedLogins > 0 && opts.lockoutDuration <= 0 {
+		return nil, fmt.Errorf("--lockout-duration must be positive: %s", opts.lockoutDuration)
+	}
+	return &opts, nil
+}
+
+func run(opts *options) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -357,7 +529,12 @@ func run() error {
 
 	dynamicCertProvider := dynamiccert.New()
 
-	startControllers(ctx, dynamicCertProvider, client.Kubernetes, kubeInformers)
+	passwords, err := newPasswordCache(opts.passwordCacheTTL)
+	if err != nil {
+		return err
+	}
+
+	startControllers(ctx, dynamicCertProvider, client.Kubernetes, kubeInformers, opts.tlsSecretName)
 	plog.Debug("controllers are ready")
 
 	//nolint: gosec // Intentionally binding to all network interfaces.
@@ -367,11 +544,30 @@ func run() error {
 	}
 	defer func() { _ = l.Close() }()
 
-	err = startWebhook(ctx, l, dynamicCertProvider, kubeInformers.Core().V1().Secrets())
+	limiter := newLoginLimiter(opts.maxFailedLogins, opts.lockoutDuration)
+
+	if opts.monitoringAddress != "" {
+		//nolint: gosec // Intentionally binding to all network interfaces by default.
+		monitoringListener, err := net.Listen("tcp", opts.monitoringAddress)
+		if err != nil {
+			return fmt.Errorf("cannot create monitoring listener: %w", err)
+		}
+		defer func() { _ = monitoringListener.Close() }()
+		startMonitoring(ctx, monitoringListener, dynamicCertProvider)
+		plog.Debug("monitoring endpoints are ready", "address", monitoringListener.Addr().String())
+	}
+
+	err = startWebhook(ctx, l, dynamicCertProvider, kubeInformers.Core().V1().Secrets(), passwords, limiter)
 	if err != nil {
 		return fmt.Errorf("cannot start webhook: %w", err)
 	}
-	plog.Debug("webhook is ready", "address", l.Addr().String())
+	plog.Debug("webhook is ready",
+		"address", l.Addr().String(),
+		"passwordCacheTTL", opts.passwordCacheTTL.String(),
+		"maxFailedLogins", opts.maxFailedLogins,
+		"lockoutDuration", opts.lockoutDuration.String(),
+		"tlsSecretName", opts.tlsSecretName,
+	)
 
 	gotSignal := waitForSignal()
 	plog.Debug("webhook exiting", "signal", gotSignal)
@@ -380,12 +576,29 @@ func run() error {
 }
 
 func main() {
+	if handled, err := versioninfo.HandleArgs(os.Args[1:], os.Stdout); handled {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	opts, err := parseOptions(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	// Hardcode the logging level to debug, since this is a test app and it is very helpful to have
 	// verbose logs to debug test failures.
 	if err := plog.ValidateAndSetLogLevelGlobally(plog.LevelDebug); err != nil {
 		klog.Fatal(err)
 	}
-	if err := run(); err != nil {
+	if err := plog.ValidateAndSetLogFormatGlobally(opts.logFormat, "local-user-authenticator"); err != nil {
+		klog.Fatal(err)
+	}
+	if err := run(opts); err != nil {
 		klog.Fatal(err)
 	}
 }
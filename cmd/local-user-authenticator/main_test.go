@@ -15,6 +15,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
@@ -34,6 +35,7 @@ import (
 
 	"go.pinniped.dev/internal/certauthority"
 	"go.pinniped.dev/internal/dynamiccert"
+	"go.pinniped.dev/internal/plog"
 )
 
 func TestWebhook(t *testing.T) {
@@ -102,7 +104,12 @@ func TestWebhook(t *testing.T) {
 	secretInformer := createSecretInformer(t, kubeClient)
 
 	certProvider, caBundle, serverName := newCertProvider(t)
-	w := newWebhook(certProvider, secretInformer)
+	// Remember the verified passwords, so that the requests which repeat a login are answered from the cache.
+	passwords, err := newPasswordCache(time.Minute)
+	require.NoError(t, err)
+
+	// The limit of failed logins is higher than the number of failing requests of any user below.
+	w := newWebhook(certProvider, secretInformer, passwords, newLoginLimiter(100, time.Minute))
 
 	l, err := net.Listen("tcp", "127.0.0.1:0")
 	require.NoError(t, err)
@@ -541,6 +548,165 @@ func unauthenticatedResponseJSON() *authenticationv1beta1.TokenReview {
 	}
 }
 
+func TestParseGroups(t *testing.T) {
+	tests := []struct {
+		name       string
+		groupsCSV  string
+		wantGroups []string
+		wantErr    string
+	}{
+		{name: "no groups", groupsCSV: "", wantGroups: []string{}},
+		{name: "one group", groupsCSV: "some-group", wantGroups: []string{"some-group"}},
+		{name: "whitespace", groupsCSV: " some-group-0 ,some-group-1\t", wantGroups: []string{"some-group-0", "some-group-1"}},
+		{name: "empty names", groupsCSV: ",some-group-0,, ,some-group-1,", wantGroups: []string{"some-group-0", "some-group-1"}},
+		{name: "quoted name", groupsCSV: `"some, group",other-group`, wantGroups: []string{"some, group", "other-group"}},
+		{name: "only empty names", groupsCSV: " , ", wantGroups: []string{}},
+		{name: "invalid", groupsCSV: `"some-group`, wantErr: `parse error on line 1, column 12: extraneous or missing " in quoted-field`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := parseGroups([]byte(tt.groupsCSV))
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantGroups, groups)
+		})
+	}
+}
+
+func TestCheckAccount(t *testing.T) {
+	now := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		data       map[string][]byte
+		wantReason string
+		wantErr    string
+	}{
+		{name: "no status keys", data: map[string][]byte{"passwordHash": []byte("some-hash")}},
+		{name: "not disabled", data: map[string][]byte{"disabled": []byte("false")}},
+		{name: "disabled", data: map[string][]byte{"disabled": []byte("true")}, wantReason: reasonAccountDisabled},
+		{name: "disabled with whitespace", data: map[string][]byte{"disabled": []byte(" 1\n")}, wantReason: reasonAccountDisabled},
+		{name: "invalid disabled", data: map[string][]byte{"disabled": []byte("yes")}, wantErr: `invalid disabled value "yes": strconv.ParseBool: parsing "yes": invalid syntax`},
+		{name: "not expired yet", data: map[string][]byte{"expiresAt": []byte("2021-04-01T12:00:01Z")}},
+		{name: "expired", data: map[string][]byte{"expiresAt": []byte("2021-04-01T12:00:00Z")}, wantReason: reasonAccountExpired},
+		{name: "expired in another time zone", data: map[string][]byte{"expiresAt": []byte("2021-04-01T07:59:59-04:00")}, wantReason: reasonAccountExpired},
+		{name: "invalid expiresAt", data: map[string][]byte{"expiresAt": []byte("tomorrow")}, wantErr: `invalid expiresAt value "tomorrow": parsing time "tomorrow" as "2006-01-02T15:04:05Z07:00": cannot parse "tomorrow" as "2006"`},
+		{name: "disabled and expired", data: map[string][]byte{"disabled": []byte("true"), "expiresAt": []byte("2020-01-01T00:00:00Z")}, wantReason: reasonAccountDisabled},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			reason, err := checkAccount(tt.data, now)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+func TestWebhookAccountStatus(t *testing.T) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("some-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	kubeClient := kubernetesfake.NewSimpleClientset()
+	for username, data := range map[string]map[string][]byte{
+		"disabled-user": {"disabled": []byte("true")},
+		"expired-user":  {"expiresAt": []byte("2021-04-01T11:00:00Z")},
+		"valid-user":    {"expiresAt": []byte("2021-04-01T13:00:00Z"), "disabled": []byte("false")},
+		"invalid-user":  {"expiresAt": []byte("never")},
+	} {
+		data["passwordHash"] = passwordHash
+		require.NoError(t, kubeClient.Tracker().Add(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: username, Namespace: "local-user-authenticator", UID: types.UID(username + "-uid")},
+			Data:       data,
+		}))
+	}
+	w := newWebhook(nil, createSecretInformer(t, kubeClient), nil, nil)
+	w.clock = func() time.Time { return time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		token      string
+		wantStatus int
+		wantBody   *authenticationv1beta1.TokenReview
+	}{
+		{token: "disabled-user:some-password", wantStatus: http.StatusOK, wantBody: unauthenticatedResponseJSONWithError("user account is disabled")},
+		{token: "expired-user:some-password", wantStatus: http.StatusOK, wantBody: unauthenticatedResponseJSONWithError("user account has expired")},
+		// The status of the account is only revealed to those who know its password.
+		{token: "disabled-user:wrong-password", wantStatus: http.StatusOK, wantBody: unauthenticatedResponseJSON()},
+		{token: "valid-user:some-password", wantStatus: http.StatusOK, wantBody: authenticatedResponseJSON("valid-user", "valid-user-uid", nil)},
+		{token: "invalid-user:some-password", wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.token, func(t *testing.T) {
+			body, err := newTokenReviewBody(tt.token)
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/authenticate", body)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json")
+			rsp := httptest.NewRecorder()
+			w.ServeHTTP(rsp, req)
+
+			require.Equal(t, tt.wantStatus, rsp.Code)
+			if tt.wantBody == nil {
+				require.Empty(t, rsp.Body.String())
+				return
+			}
+			var tr authenticationv1beta1.TokenReview
+			require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &tr))
+			require.Equal(t, tt.wantBody, &tr)
+		})
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    *options
+		wantErr string
+	}{
+		{name: "defaults", want: &options{passwordCacheTTL: time.Minute, maxFailedLogins: 5, lockoutDuration: 5 * time.Minute, monitoringAddress: ":8080", logFormat: plog.FormatText}},
+		{name: "password cache TTL", args: []string{"--password-cache-ttl", "5m"}, want: &options{passwordCacheTTL: 5 * time.Minute, maxFailedLogins: 5, lockoutDuration: 5 * time.Minute, monitoringAddress: ":8080", logFormat: plog.FormatText}},
+		{name: "password cache disabled", args: []string{"--password-cache-ttl=0"}, want: &options{passwordCacheTTL: 0, maxFailedLogins: 5, lockoutDuration: 5 * time.Minute, monitoringAddress: ":8080", logFormat: plog.FormatText}},
+		{name: "lockout", args: []string{"--max-failed-logins=3", "--lockout-duration=30s"}, want: &options{passwordCacheTTL: time.Minute, maxFailedLogins: 3, lockoutDuration: 30 * time.Second, monitoringAddress: ":8080", logFormat: plog.FormatText}},
+		{name: "lockout disabled", args: []string{"--max-failed-logins=0", "--lockout-duration=0"}, want: &options{passwordCacheTTL: time.Minute, maxFailedLogins: 0, lockoutDuration: 0, monitoringAddress: ":8080", logFormat: plog.FormatText}},
+		{name: "TLS secret", args: []string{"--tls-secret-name=some-tls-secret"}, want: &options{passwordCacheTTL: time.Minute, maxFailedLogins: 5, lockoutDuration: 5 * time.Minute, tlsSecretName: "some-tls-secret", monitoringAddress: ":8080", logFormat: plog.FormatText}},
+		{name: "monitoring address", args: []string{"--monitoring-address=127.0.0.1:9090"}, want: &options{passwordCacheTTL: time.Minute, maxFailedLogins: 5, lockoutDuration: 5 * time.Minute, monitoringAddress: "127.0.0.1:9090", logFormat: plog.FormatText}},
+		{name: "monitoring disabled", args: []string{"--monitoring-address="}, want: &options{passwordCacheTTL: time.Minute, maxFailedLogins: 5, lockoutDuration: 5 * time.Minute, logFormat: plog.FormatText}},
+		{name: "JSON logs", args: []string{"--log-format=json"}, want: &options{passwordCacheTTL: time.Minute, maxFailedLogins: 5, lockoutDuration: 5 * time.Minute, monitoringAddress: ":8080", logFormat: plog.FormatJSON}},
+		{name: "invalid log format", args: []string{"--log-format=logfmt"}, wantErr: "--log-format: invalid log format, valid choices are the empty string, text and json"},
+		{name: "negative max failed logins", args: []string{"--max-failed-logins=-1"}, wantErr: "--max-failed-logins must not be negative: -1"},
+		{name: "no lockout duration", args: []string{"--lockout-duration=0"}, wantErr: "--lockout-duration must be positive: 0s"},
+		{name: "negative password cache TTL", args: []string{"--password-cache-ttl=-1s"}, wantErr: "--password-cache-ttl must not be negative: -1s"},
+		{name: "unexpected argument", args: []string{"extra"}, wantErr: "unexpected arguments: [extra]"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOptions(tt.args)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func unauthenticatedResponseJSONWithError(reason string) *authenticationv1beta1.TokenReview {
+	tr := unauthenticatedResponseJSON()
+	tr.Status.Error = reason
+	return tr
+}
+
 func authenticatedResponseJSON(user, uid string, groups []string) *authenticationv1beta1.TokenReview {
 	return &authenticationv1beta1.TokenReview{
 		TypeMeta: metav1.TypeMeta{
@@ -0,0 +1,86 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"go.pinniped.dev/internal/dynamiccert"
+	"go.pinniped.dev/internal/plog"
+)
+
+// The results of authentication requests, used as the value of the "result" label.
+const (
+	resultSuccess        = "success"
+	resultWrongPassword  = "wrong_password"
+	resultUnknownUser    = "unknown_user"
+	resultLockedOut      = "locked_out"
+	resultDisabled       = "disabled"
+	resultExpired        = "expired"
+	resultInvalidRequest = "invalid_request"
+	resultError          = "error"
+)
+
+//nolint: gochecknoglobals
+var (
+	authenticationsTotal = metrics.NewCounterVec(&metrics.CounterOpts{
+		Name:           "pinniped_local_user_authenticator_authentications_total",
+		Help:           "Number of authentication requests by result.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"result"})
+
+	authenticationDuration = metrics.NewHistogramVec(&metrics.HistogramOpts{
+		Name:           "pinniped_local_user_authenticator_authentication_duration_seconds",
+		Help:           "Latency of authentication requests by result, including the time taken to verify the password hash.",
+		Buckets:        []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"result"})
+)
+
+//nolint: gochecknoinits
+func init() {
+	legacyregistry.MustRegister(authenticationsTotal, authenticationDuration)
+}
+
+func recordAuthentication(result string, duration time.Duration) {
+	authenticationsTotal.WithLabelValues(result).Inc()
+	authenticationDuration.WithLabelValues(result).Observe(duration.Seconds())
+}
+
+// newMonitoringHandler returns the handler of the plain HTTP endpoints which are meant for kubelet and Prometheus:
+// /healthz, which fails until there is a serving certificate, and /metrics.
+func newMonitoringHandler(certProvider dynamiccert.Provider) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(rsp http.ResponseWriter, _ *http.Request) {
+		if cert, key := certProvider.CurrentCertKeyContent(); len(cert) == 0 || len(key) == 0 {
+			http.Error(rsp, "no serving certificate", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = rsp.Write([]byte("ok"))
+	})
+	mux.Handle("/metrics", legacyregistry.Handler())
+	return mux
+}
+
+// startMonitoring serves the monitoring endpoints on the listener until the context is cancelled.
+func startMonitoring(ctx context.Context, l net.Listener, certProvider dynamiccert.Provider) {
+	server := http.Server{Handler: newMonitoringHandler(certProvider), ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		plog.Debug("monitoring server exited", "err", server.Serve(l))
+	}()
+
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			plog.Debug("monitoring server shutdown failed", "err", err)
+		}
+	}()
+}
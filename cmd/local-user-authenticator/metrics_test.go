@@ -0,0 +1,97 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	metricstestutil "k8s.io/component-base/metrics/testutil"
+
+	"go.pinniped.dev/internal/dynamiccert"
+)
+
+func TestMonitoringHandler(t *testing.T) {
+	certProvider := dynamiccert.New()
+	handler := newMonitoringHandler(certProvider)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		t.Helper()
+		rsp := httptest.NewRecorder()
+		handler.ServeHTTP(rsp, httptest.NewRequest(http.MethodGet, path, nil))
+		return rsp
+	}
+
+	rsp := get("/healthz")
+	require.Equal(t, http.StatusServiceUnavailable, rsp.Code)
+	require.Equal(t, "no serving certificate\n", rsp.Body.String())
+
+	certProvider.Set([]byte("some cert"), []byte("some key"))
+	rsp = get("/healthz")
+	require.Equal(t, http.StatusOK, rsp.Code)
+	require.Equal(t, "ok", rsp.Body.String())
+
+	recordAuthentication(resultSuccess, time.Millisecond)
+	rsp = get("/metrics")
+	require.Equal(t, http.StatusOK, rsp.Code)
+	require.Contains(t, rsp.Body.String(), `pinniped_local_user_authenticator_authentications_total{result="success"}`)
+	require.Contains(t, rsp.Body.String(), `pinniped_local_user_authenticator_authentication_duration_seconds_bucket{result="success",le="0.001"}`)
+
+	require.Equal(t, http.StatusNotFound, get("/authenticate").Code)
+}
+
+func TestWebhookMetrics(t *testing.T) {
+	kubeClient := kubernetesfake.NewSimpleClientset()
+	addSecretToFakeClientTracker(t, kubeClient, "some-metrics-user", "some-uid", "some-password", "")
+	w := newWebhook(nil, createSecretInformer(t, kubeClient), nil, newLoginLimiter(2, time.Minute))
+
+	counts := func() map[string]float64 {
+		t.Helper()
+		got := map[string]float64{}
+		for _, result := range []string{resultSuccess, resultWrongPassword, resultUnknownUser, resultLockedOut, resultInvalidRequest, resultError} {
+			count, err := metricstestutil.GetCounterMetricValue(authenticationsTotal.WithLabelValues(result))
+			require.NoError(t, err)
+			got[result] = count
+		}
+		return got
+	}
+	authenticate := func(path, token string) {
+		t.Helper()
+		body, err := newTokenReviewBody(token)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, path, body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		w.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	before := counts()
+	authenticate("/authenticate", "some-metrics-user:some-password")
+	authenticate("/authenticate", "some-metrics-user:wrong-password")
+	authenticate("/authenticate", "some-metrics-user:wrong-password")
+	authenticate("/authenticate", "some-metrics-user:some-password")
+	authenticate("/authenticate", "unknown-user:some-password")
+	authenticate("/wrong-path", "some-metrics-user:some-password")
+	after := counts()
+
+	require.Equal(t, map[string]float64{
+		resultSuccess:        1,
+		resultWrongPassword:  2,
+		resultLockedOut:      1,
+		resultUnknownUser:    1,
+		resultInvalidRequest: 1,
+		resultError:          0,
+	}, map[string]float64{
+		resultSuccess:        after[resultSuccess] - before[resultSuccess],
+		resultWrongPassword:  after[resultWrongPassword] - before[resultWrongPassword],
+		resultLockedOut:      after[resultLockedOut] - before[resultLockedOut],
+		resultUnknownUser:    after[resultUnknownUser] - before[resultUnknownUser],
+		resultInvalidRequest: after[resultInvalidRequest] - before[resultInvalidRequest],
+		resultError:          after[resultError] - before[resultError],
+	})
+}
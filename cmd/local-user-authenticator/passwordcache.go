@@ -0,0 +1,89 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// passwordCache remembers the passwords which were verified recently, so that a user who logs in many times in a row,
+// like the integration tests do, does not pay for a bcrypt comparison every time. It never stores the passwords: it
+// stores an HMAC of each password and the hash which it was verified against, keyed with random bytes which only
+// exist in the memory of this process. Changing the hash of a user, e.g. to change their password, misses the cache.
+type passwordCache struct {
+	ttl   time.Duration
+	key   []byte
+	clock func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]passwordCacheEntry // keyed by username
+}
+
+type passwordCacheEntry struct {
+	mac       []byte
+	expiresAt time.Time
+}
+
+// newPasswordCache returns a cache which remembers verified passwords for the ttl, or nil when the ttl is not
+// positive. A nil cache is valid and never remembers anything.
+func newPasswordCache(ttl time.Duration) (*passwordCache, error) {
+	if ttl <= 0 {
+		return nil, nil
+	}
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("could not generate password cache key: %w", err)
+	}
+	return &passwordCache{
+		ttl:     ttl,
+		key:     key,
+		clock:   time.Now,
+		entries: map[string]passwordCacheEntry{},
+	}, nil
+}
+
+// verified returns whether the password was recently verified against the hash for the user.
+func (c *passwordCache) verified(username string, passwordHash []byte, password string) bool {
+	if c == nil {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[username]
+	if !ok || !c.clock().Before(entry.expiresAt) {
+		return false
+	}
+	return hmac.Equal(entry.mac, c.mac(passwordHash, password))
+}
+
+// add remembers that the password was verified against the hash for the user, replacing any previous entry of the
+// user. It also forgets the expired entries of other users, so that the cache does not grow without bound.
+func (c *passwordCache) add(username string, passwordHash []byte, password string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.clock()
+	for name, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, name)
+		}
+	}
+	c.entries[username] = passwordCacheEntry{mac: c.mac(passwordHash, password), expiresAt: now.Add(c.ttl)}
+}
+
+func (c *passwordCache) mac(passwordHash []byte, password string) []byte {
+	h := hmac.New(sha256.New, c.key)
+	// The length prefix keeps the boundary between the hash and the password unambiguous.
+	_, _ = fmt.Fprintf(h, "%d:", len(passwordHash))
+	_, _ = h.Write(passwordHash)
+	_, _ = h.Write([]byte(password))
+	return h.Sum(nil)
+}
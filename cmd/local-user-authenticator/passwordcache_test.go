@@ -0,0 +1,62 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordCache(t *testing.T) {
+	now := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	hash, otherHash := []byte("$2y$10$some-hash"), []byte("$2y$10$some-other-hash")
+
+	c, err := newPasswordCache(time.Minute)
+	require.NoError(t, err)
+	c.clock = func() time.Time { return now }
+
+	require.False(t, c.verified("some-user", hash, "some-password"))
+	c.add("some-user", hash, "some-password")
+	require.True(t, c.verified("some-user", hash, "some-password"))
+
+	// Only the same password of the same user with the same hash is remembered.
+	require.False(t, c.verified("some-user", hash, "wrong-password"))
+	require.False(t, c.verified("some-user", otherHash, "some-password"))
+	require.False(t, c.verified("other-user", hash, "some-password"))
+	// The boundary between the hash and the password is part of the HMAC.
+	require.False(t, c.verified("some-user", hash[:len(hash)-1], "h"+"some-password"))
+
+	// The password itself is never stored.
+	for _, entry := range c.entries {
+		require.NotContains(t, string(entry.mac), "some-password")
+	}
+
+	// The entries expire after the TTL.
+	now = now.Add(time.Minute - time.Nanosecond)
+	require.True(t, c.verified("some-user", hash, "some-password"))
+	now = now.Add(time.Nanosecond)
+	require.False(t, c.verified("some-user", hash, "some-password"))
+
+	// Adding an entry forgets the expired entries.
+	c.add("other-user", otherHash, "other-password")
+	require.Len(t, c.entries, 1)
+	require.True(t, c.verified("other-user", otherHash, "other-password"))
+
+	// Two caches have different keys.
+	other, err := newPasswordCache(time.Minute)
+	require.NoError(t, err)
+	require.NotEqual(t, c.mac(hash, "some-password"), other.mac(hash, "some-password"))
+}
+
+func TestPasswordCacheDisabled(t *testing.T) {
+	c, err := newPasswordCache(0)
+	require.NoError(t, err)
+	require.Nil(t, c)
+
+	// A nil cache never remembers anything.
+	c.add("some-user", []byte("some-hash"), "some-password")
+	require.False(t, c.verified("some-user", []byte("some-hash"), "some-password"))
+}
@@ -1,9 +1,11 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"time"
 
@@ -14,9 +16,30 @@ import (
 	"k8s.io/klog/v2"
 
 	"go.pinniped.dev/internal/concierge/server"
+	"go.pinniped.dev/internal/healthcheck"
+	"go.pinniped.dev/internal/versioninfo"
 )
 
+// healthCheckURL is the health endpoint of the aggregated API server, which the healthcheck subcommand requests
+// unless it is given another --url.
+const healthCheckURL = "https://127.0.0.1:8443/healthz"
+
 func main() {
+	if handled, err := versioninfo.HandleArgs(os.Args[1:], os.Stdout); handled {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if handled, err := healthcheck.HandleArgs(context.Background(), os.Args[1:], os.Stdout, healthCheckURL); handled {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	logs.InitLogs()
 	defer logs.FlushLogs()
 
@@ -7,7 +7,10 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509/pkix"
+	"flag"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
@@ -17,9 +20,12 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/dynamic"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/pkg/version"
 	"k8s.io/client-go/rest"
 	"k8s.io/component-base/logs"
@@ -29,28 +35,84 @@ import (
 	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
 	pinnipedclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
 	pinnipedinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions"
+	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/config/reload"
 	"go.pinniped.dev/internal/config/supervisor"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
 	"go.pinniped.dev/internal/controller/supervisorconfig"
 	"go.pinniped.dev/internal/controller/supervisorconfig/generator"
 	"go.pinniped.dev/internal/controller/supervisorconfig/upstreamwatcher"
 	"go.pinniped.dev/internal/controller/supervisorstorage"
 	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/debughandler"
 	"go.pinniped.dev/internal/deploymentref"
+	"go.pinniped.dev/internal/devauthenticator"
 	"go.pinniped.dev/internal/downward"
 	"go.pinniped.dev/internal/groupsuffix"
+	"go.pinniped.dev/internal/healthcheck"
 	"go.pinniped.dev/internal/kubeclient"
+	"go.pinniped.dev/internal/oidc/audit"
+	"go.pinniped.dev/internal/oidc/groupgrant"
 	"go.pinniped.dev/internal/oidc/jwks"
+	"go.pinniped.dev/internal/oidc/loginapproval"
 	"go.pinniped.dev/internal/oidc/provider"
 	"go.pinniped.dev/internal/oidc/provider/manager"
+	"go.pinniped.dev/internal/oidc/staticadmin"
 	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/revocationlist"
 	"go.pinniped.dev/internal/secret"
+	"go.pinniped.dev/internal/supervisoradmin"
+	"go.pinniped.dev/internal/supervisormetrics"
+	"go.pinniped.dev/internal/versioninfo"
 )
 
 const (
-	singletonWorker       = 1
-	defaultResyncInterval = 3 * time.Minute
+	singletonWorker = 1
+
+	// devTLSCertTTL is the lifetime of the self-signed certificate which is generated in --dev mode.
+	devTLSCertTTL = 365 * 24 * time.Hour
+
+	// healthCheckURL is the health endpoint on the default address of the http endpoint, which the healthcheck
+	// subcommand requests unless it is given another --url.
+	healthCheckURL = "http://127.0.0.1:8080/healthz"
 )
 
+// devTLSCert generates a self-signed CA and a serving certificate for localhost and the loopback addresses,
+// returning the certificate and the PEM-encoded CA bundle which clients should trust.
+func devTLSCert() (*tls.Certificate, []byte, error) {
+	ca, err := certauthority.New(pkix.Name{CommonName: "Pinniped Supervisor Dev CA"}, devTLSCertTTL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create dev CA: %w", err)
+	}
+	cert, err := ca.Issue(
+		pkix.Name{CommonName: "localhost"},
+		[]string{"localhost"},
+		[]net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		devTLSCertTTL,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not issue dev TLS cert: %w", err)
+	}
+	return cert, ca.Bundle(), nil
+}
+
+func listen(e *supervisor.Endpoint) (net.Listener, error) {
+	if e.Network == supervisor.NetworkUnix {
+		// A socket file left behind by a previous process (e.g. one that was killed) would cause the listen to fail,
+		// so remove it first. Refuse to remove anything other than a socket.
+		if info, err := os.Lstat(e.Address); err == nil {
+			if info.Mode()&os.ModeSocket == 0 {
+				return nil, fmt.Errorf("%q already exists and is not a socket", e.Address)
+			}
+			if err := os.Remove(e.Address); err != nil {
+				return nil, fmt.Errorf("could not remove stale socket: %w", err)
+			}
+		}
+	}
+	//nolint: gosec // Intentionally binding to all network interfaces.
+	return net.Listen(e.Network, e.Address)
+}
+
 func start(ctx context.Context, l net.Listener, handler http.Handler) {
 	server := http.Server{Handler: handler}
 
@@ -72,6 +134,59 @@ func start(ctx context.Context, l net.Listener, handler http.Handler) {
 	}()
 }
 
+// servingEndpoint serves requests on one of the configured endpoints. Its listener is replaced when the endpoint
+// is changed in the config file.
+type servingEndpoint struct {
+	name       string
+	handler    http.Handler
+	newHandler func(*supervisor.Endpoint) http.Handler               // optional, used instead of handler for each new endpoint
+	wrap       func(net.Listener, *supervisor.Endpoint) net.Listener // optional
+	prepare    func(*supervisor.Endpoint) error                      // optional, called before listening on a new endpoint
+
+	started  bool
+	endpoint supervisor.Endpoint
+	stop     context.CancelFunc // nil while the endpoint is disabled
+}
+
+// update starts serving on the endpoint, and then stops serving on the previous endpoint. When the new listener
+// cannot be created, the previous one keeps serving. It does nothing when the endpoint has not changed.
+func (s *servingEndpoint) update(ctx context.Context, e supervisor.Endpoint) error {
+	if s.started && s.endpoint == e {
+		return nil
+	}
+
+	var stop context.CancelFunc
+	if e.Network != supervisor.NetworkDisabled {
+		if s.prepare != nil {
+			if err := s.prepare(&e); err != nil {
+				return fmt.Errorf("cannot prepare %s listener: %w", s.name, err)
+			}
+		}
+		l, err := listen(&e)
+		if err != nil {
+			return fmt.Errorf("cannot create %s listener with network %q and address %q: %w", s.name, e.Network, e.Address, err)
+		}
+		if s.wrap != nil {
+			l = s.wrap(l, &e)
+		}
+		handler := s.handler
+		if s.newHandler != nil {
+			handler = s.newHandler(&e)
+		}
+		var listenerCtx context.Context
+		listenerCtx, stop = context.WithCancel(ctx)
+		start(listenerCtx, l, handler)
+		plog.Debug("supervisor "+s.name+" listener started", "address", l.Addr().String())
+	}
+
+	if s.stop != nil {
+		s.stop()
+		plog.Debug("supervisor "+s.name+" listener stopped", "network", s.endpoint.Network, "address", s.endpoint.Address)
+	}
+	s.started, s.endpoint, s.stop = true, e, stop
+	return nil
+}
+
 func waitForSignal() os.Signal {
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, os.Interrupt)
@@ -88,23 +203,44 @@ func startControllers(
 	dynamicUpstreamIDPProvider provider.DynamicUpstreamIDPProvider,
 	secretCache *secret.Cache,
 	supervisorDeployment *appsv1.Deployment,
+	elector *controllerlib.LeaderElector,
 	kubeClient kubernetes.Interface,
 	pinnipedClient pinnipedclientset.Interface,
+	dynamicClient dynamic.Interface,
 	kubeInformers kubeinformers.SharedInformerFactory,
 	pinnipedInformers pinnipedinformers.SharedInformerFactory,
-) {
+	dev bool,
+) (<-chan struct{}, error) {
+	// The rate limiters are built into the queues of the controllers when they are created below.
+	rateLimiterOverrides := make(map[string]controllerlib.RateLimiterConfig, len(cfg.Controllers.RateLimiterOverrides))
+	for name, override := range cfg.Controllers.RateLimiterOverrides {
+		rateLimiterOverrides[name] = rateLimiterConfig(override)
+	}
+	rateLimiters := controllerlib.NewRateLimiters(rateLimiterConfig(cfg.Controllers.RateLimiter), rateLimiterOverrides)
+
 	federationDomainInformer := pinnipedInformers.Config().V1alpha1().FederationDomains()
 	secretInformer := kubeInformers.Core().V1().Secrets()
 
-	// Create controller manager.
+	// Some controllers record Events about the FederationDomains and OIDCIdentityProviders, e.g. when they become unready.
+	recorder, startRecorder := pinnipedcontroller.NewEventRecorder(kubeClient, "pinniped-supervisor")
+	startRecorder(ctx)
+
+	// Create controller manager. Only the leader runs the garbage collector, the JWKS writer, and the controllers which
+	// write Certificates and group grant statuses. The FederationDomain watcher and the Secret generators also write
+	// shared statuses and Secrets, but they run on every replica because they also load the issuers and keys into this
+	// replica's memory. Running them everywhere is safe: the Secret names are derived from the owner, so a concurrent
+	// create fails with AlreadyExists and is retried against the winner's Secret, updates re-read the live object and
+	// keep it when it is already valid, and status updates are skipped when nothing changed.
 	controllerManager := controllerlib.
 		NewManager().
-		WithController(
+		WithLeaderElection(elector).
+		WithLeaderElectedController(
 			supervisorstorage.GarbageCollectorController(
 				clock.RealClock{},
 				kubeClient,
 				secretInformer,
 				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
@@ -114,18 +250,23 @@ func startControllers(
 				clock.RealClock{},
 				pinnipedClient,
 				federationDomainInformer,
+				dev,
+				recorder,
 				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
-		WithController(
+		WithLeaderElectedController(
 			supervisorconfig.NewJWKSWriterController(
 				cfg.Labels,
 				kubeClient,
 				pinnipedClient,
 				secretInformer,
 				federationDomainInformer,
+				recorder,
 				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
@@ -135,6 +276,19 @@ func startControllers(
 				secretInformer,
 				federationDomainInformer,
 				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
+			),
+			singletonWorker,
+		).
+		WithLeaderElectedController(
+			supervisorconfig.NewCertManagerCertificateController(
+				cfg.Labels,
+				clock.RealClock{},
+				pinnipedClient,
+				dynamicClient,
+				federationDomainInformer,
+				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
@@ -142,9 +296,12 @@ func startControllers(
 			supervisorconfig.NewTLSCertObserverController(
 				dynamicTLSCertProvider,
 				cfg.NamesConfig.DefaultTLSCertificateSecret,
+				pinnipedClient,
+				clock.RealClock{},
 				secretInformer,
 				federationDomainInformer,
 				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
@@ -160,6 +317,7 @@ func startControllers(
 				},
 				controllerlib.WithInformer,
 				controllerlib.WithInitialEvent,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
@@ -183,6 +341,7 @@ func startControllers(
 				secretInformer,
 				federationDomainInformer,
 				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
@@ -206,6 +365,7 @@ func startControllers(
 				secretInformer,
 				federationDomainInformer,
 				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
@@ -229,6 +389,17 @@ func startControllers(
 				secretInformer,
 				federationDomainInformer,
 				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
+			),
+			singletonWorker,
+		).
+		WithLeaderElectedController(
+			supervisorconfig.NewGroupGrantStatusController(
+				clock.RealClock{},
+				pinnipedClient,
+				pinnipedInformers.Config().V1alpha1().GroupGrants(),
+				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
@@ -238,11 +409,17 @@ func startControllers(
 				pinnipedClient,
 				pinnipedInformers.IDP().V1alpha1().OIDCIdentityProviders(),
 				secretInformer,
+				recorder,
 				klogr.New(),
 				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker)
 
+	if err := rateLimiters.Validate(); err != nil {
+		return nil, err
+	}
+
 	kubeInformers.Start(ctx.Done())
 	pinnipedInformers.Start(ctx.Done())
 
@@ -250,10 +427,41 @@ func startControllers(
 	kubeInformers.WaitForCacheSync(ctx.Done())
 	pinnipedInformers.WaitForCacheSync(ctx.Done())
 
-	go controllerManager.Start(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		controllerManager.Start(ctx)
+	}()
+	return stopped, nil
+}
+
+// newAuditWebhook returns the audit webhook with the authorization and the CA bundle from its Secret, which is only
+// read once at startup.
+func newAuditWebhook(ctx context.Context, secrets corev1client.SecretInterface, spec *supervisor.AuditWebhookSpec) (*audit.Webhook, error) {
+	var authorization string
+	var caBundle []byte
+	if spec.SecretName != "" {
+		secret, err := secrets.Get(ctx, spec.SecretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("cannot get audit webhook secret: %w", err)
+		}
+		authorization = strings.TrimSpace(string(secret.Data["authorization"]))
+		caBundle = secret.Data[corev1.ServiceAccountRootCAKey]
+	}
+	return audit.NewWebhook(spec.URL, authorization, caBundle, *spec.BufferSize)
+}
+
+func rateLimiterConfig(spec supervisor.RateLimiterSpec) controllerlib.RateLimiterConfig {
+	return controllerlib.RateLimiterConfig{
+		BaseDelay:  time.Duration(spec.BaseDelayMilliseconds) * time.Millisecond,
+		MaxDelay:   time.Duration(spec.MaxDelaySeconds) * time.Second,
+		QPS:        spec.QPS,
+		BucketSize: spec.BucketSize,
+	}
 }
 
-func run(podInfo *downward.PodInfo, cfg *supervisor.Config) error {
+//nolint:funlen
+func run(podInfo *downward.PodInfo, cfg *supervisor.Config, configPath string, dev bool) error {
 	serverInstallationNamespace := podInfo.Namespace
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -272,15 +480,26 @@ func run(podInfo *downward.PodInfo, cfg *supervisor.Config) error {
 		return fmt.Errorf("cannot create k8s client: %w", err)
 	}
 
+	// Used for cert-manager Certificates, which are not part of any of the typed clients.
+	dynamicClient, err := dynamic.NewForConfig(client.JSONConfig)
+	if err != nil {
+		return fmt.Errorf("cannot create dynamic k8s client: %w", err)
+	}
+
+	// The Supervisor only watches Secrets with the kube informers, so the optional label selector only applies to them.
+	resyncPeriod := time.Duration(*cfg.Informers.ResyncPeriodSeconds) * time.Second
 	kubeInformers := kubeinformers.NewSharedInformerFactoryWithOptions(
 		client.Kubernetes,
-		defaultResyncInterval,
+		resyncPeriod,
 		kubeinformers.WithNamespace(serverInstallationNamespace),
+		kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = cfg.Informers.SecretLabelSelector
+		}),
 	)
 
 	pinnipedInformers := pinnipedinformers.NewSharedInformerFactoryWithOptions(
 		client.PinnipedSupervisor,
-		defaultResyncInterval,
+		resyncPeriod,
 		pinnipedinformers.WithNamespace(serverInstallationNamespace),
 	)
 
@@ -295,16 +514,113 @@ func run(podInfo *downward.PodInfo, cfg *supervisor.Config) error {
 	dynamicUpstreamIDPProvider := provider.NewDynamicUpstreamIDPProvider()
 	secretCache := secret.Cache{}
 
+	// Sessions are stored in Secrets, except in dev mode where they are only kept in memory.
+	secretsClient := client.Kubernetes.CoreV1().Secrets(serverInstallationNamespace)
+	revocations := revocationlist.NewLister(kubeInformers.Core().V1().Secrets().Lister().Secrets(serverInstallationNamespace))
+	var devCert *tls.Certificate
+	if dev {
+		secretsClient = nil
+		revocations = nil
+
+		var devCABundle []byte
+		devCert, devCABundle, err = devTLSCert()
+		if err != nil {
+			return err
+		}
+		plog.Warning("supervisor is running in dev mode, which is not secure and must not be used in production: "+
+			"sessions are only stored in memory, a self-signed TLS certificate is served by default, "+
+			"http issuers are allowed for loopback hosts, and demo users can log in unless the static admin identity provider is configured",
+			"devCABundle", string(devCABundle),
+		)
+	}
+
+	var staticAdminIDP *staticadmin.IdentityProvider
+	if cfg.StaticAdminIdentityProvider != nil {
+		staticAdminIDP = staticadmin.New(
+			cfg.StaticAdminIdentityProvider.SecretName,
+			kubeInformers.Core().V1().Secrets().Lister().Secrets(serverInstallationNamespace),
+		)
+		plog.Warning("the static admin identity provider is enabled: anyone who knows the admin password can log in, "+
+			"so it should only be used to bootstrap the Supervisor and disabled as soon as a real identity provider has been configured",
+			"secretName", cfg.StaticAdminIdentityProvider.SecretName,
+		)
+	}
+	if dev && staticAdminIDP == nil {
+		// The demo users log in like the static admin user, so that logins can be tried out without any Secret.
+		demoUsers := devauthenticator.DemoUsers()
+		staticAdminIDP = staticadmin.NewDemo(demoUsers)
+		for _, u := range demoUsers {
+			plog.Debug("added demo user", "username", u.Username, "groups", u.Groups, "password", u.Token)
+		}
+	}
+
+	if cfg.AuditLog != nil {
+		// The webhook never fails to write, so it comes first and gets every event, even when the file cannot be
+		// written.
+		var outputs []io.Writer
+		var webhookURL string
+		if cfg.AuditLog.Webhook != nil {
+			webhook, err := newAuditWebhook(ctx, client.Kubernetes.CoreV1().Secrets(serverInstallationNamespace), cfg.AuditLog.Webhook)
+			if err != nil {
+				return err
+			}
+			go webhook.Run(ctx)
+			outputs = append(outputs, webhook)
+			webhookURL = cfg.AuditLog.Webhook.URL
+		}
+		switch cfg.AuditLog.Output {
+		case "":
+		case supervisor.AuditLogOutputStdout:
+			outputs = append(outputs, os.Stdout)
+		default:
+			auditLogFile, err := os.OpenFile(cfg.AuditLog.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				return fmt.Errorf("cannot open audit log: %w", err)
+			}
+			defer func() { _ = auditLogFile.Close() }()
+			outputs = append(outputs, auditLogFile)
+		}
+		audit.SetOutput(io.MultiWriter(outputs...))
+		plog.Info("writing audit log", "output", cfg.AuditLog.Output, "webhook", webhookURL)
+	}
+
 	// OIDC endpoints will be served by the oidProvidersManager, and any non-OIDC paths will fallback to the healthMux.
 	oidProvidersManager := manager.NewManager(
 		healthMux,
 		dynamicJWKSProvider,
 		dynamicUpstreamIDPProvider,
+		staticAdminIDP,
+		groupgrant.New(
+			pinnipedInformers.Config().V1alpha1().GroupGrants().Lister().GroupGrants(serverInstallationNamespace),
+			clock.RealClock{},
+		),
+		loginapproval.NewStore(
+			client.PinnipedSupervisor.ConfigV1alpha1().UserApprovals(serverInstallationNamespace),
+			pinnipedInformers.Config().V1alpha1().UserApprovals().Lister().UserApprovals(serverInstallationNamespace),
+			cfg.Labels,
+		),
 		&secretCache,
-		client.Kubernetes.CoreV1().Secrets(serverInstallationNamespace),
+		generator.NewKeyLoader(supervisorDeployment, cfg.Labels, client.Kubernetes, client.PinnipedSupervisor, &secretCache).Load,
+		secretsClient,
+		revocations,
 	)
 
-	startControllers(
+	// Serve the /readyz endpoint, which reports whether this replica can serve logins, so that traffic which is
+	// routed to the replicas of the same zone only reaches those which are ready.
+	healthMux.Handle("/readyz", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if err := oidProvidersManager.Ready(); err != nil {
+			http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = writer.Write([]byte("ok"))
+	}))
+
+	elector, err := controllerlib.NewLeaderElector(client.Kubernetes, serverInstallationNamespace, supervisorDeployment.Name, podInfo.Name)
+	if err != nil {
+		return fmt.Errorf("cannot create leader elector: %w", err)
+	}
+
+	controllersStopped, err := startControllers(
 		ctx,
 		cfg,
 		oidProvidersManager,
@@ -313,75 +629,225 @@ func run(podInfo *downward.PodInfo, cfg *supervisor.Config) error {
 		dynamicUpstreamIDPProvider,
 		&secretCache,
 		supervisorDeployment,
+		elector,
 		client.Kubernetes,
 		client.PinnipedSupervisor,
+		dynamicClient,
 		kubeInformers,
 		pinnipedInformers,
+		dev,
 	)
-
-	//nolint: gosec // Intentionally binding to all network interfaces.
-	httpListener, err := net.Listen("tcp", ":8080")
 	if err != nil {
-		return fmt.Errorf("cannot create listener: %w", err)
+		return fmt.Errorf("cannot start controllers: %w", err)
 	}
-	defer func() { _ = httpListener.Close() }()
-	start(ctx, httpListener, oidProvidersManager)
 
-	//nolint: gosec // Intentionally binding to all network interfaces.
-	httpsListener, err := tls.Listen("tcp", ":8443", &tls.Config{
-		MinVersion: tls.VersionTLS12, // Allow v1.2 because clients like the default `curl` on MacOS don't support 1.3 yet.
-		GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
-			cert := dynamicTLSCertProvider.GetTLSCert(strings.ToLower(info.ServerName))
-			defaultCert := dynamicTLSCertProvider.GetDefaultTLSCert()
-			plog.Debug("GetCertificate called for port 8443",
-				"info.ServerName", info.ServerName,
-				"foundSNICert", cert != nil,
-				"foundDefaultCert", defaultCert != nil,
-			)
-			if cert == nil {
-				cert = defaultCert
+	httpEndpoint := &servingEndpoint{name: "http", handler: oidProvidersManager}
+	if err := httpEndpoint.update(ctx, *cfg.Endpoints.HTTP); err != nil {
+		return err
+	}
+
+	httpsEndpoint := &servingEndpoint{
+		name:    "https",
+		handler: oidProvidersManager,
+		wrap: func(l net.Listener, e *supervisor.Endpoint) net.Listener {
+			clientAuth := tls.NoClientCert
+			if e.RequestClientCertificates {
+				// Certificates are not verified, because they only serve to bind tokens, see oidc.BindToClientCertificate.
+				clientAuth = tls.RequestClientCert
 			}
-			return cert, nil
+			return tls.NewListener(l, &tls.Config{
+				ClientAuth: clientAuth,
+				MinVersion: tls.VersionTLS12, // Allow v1.2 because clients like the default `curl` on MacOS don't support 1.3 yet.
+				GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+					cert := dynamicTLSCertProvider.GetTLSCertForServerName(strings.ToLower(info.ServerName))
+					plog.Debug("GetCertificate called for https listener",
+						"info.ServerName", info.ServerName,
+						"foundCert", cert != nil,
+					)
+					if cert == nil {
+						cert = devCert // nil unless in dev mode
+					}
+					return cert, nil
+				},
+			})
 		},
-	})
+	}
+	if err := httpsEndpoint.update(ctx, *cfg.Endpoints.HTTPS); err != nil {
+		return err
+	}
+
+	// The admin endpoint only listens on a unix socket, and its clients must also read the token from the file next
+	// to the socket, so only processes in the pod which can read that file can use it.
+	adminToken, err := supervisoradmin.GenerateToken()
 	if err != nil {
-		return fmt.Errorf("cannot create listener: %w", err)
+		return err
+	}
+	adminEndpoint := &servingEndpoint{
+		name: "admin",
+		handler: supervisoradmin.NewHandler(supervisoradmin.Config{
+			Token:                  adminToken,
+			Secrets:                client.Kubernetes.CoreV1().Secrets(serverInstallationNamespace),
+			FederationDomainLister: pinnipedInformers.Config().V1alpha1().FederationDomains().Lister().FederationDomains(serverInstallationNamespace),
+			UpstreamIDPs:           dynamicUpstreamIDPProvider,
+			SessionsInMemory:       dev,
+			Clock:                  time.Now,
+		}),
+		prepare: func(e *supervisor.Endpoint) error {
+			return supervisoradmin.WriteToken(e.Address, adminToken)
+		},
+	}
+	if err := adminEndpoint.update(ctx, *cfg.Endpoints.Admin); err != nil {
+		return err
 	}
-	defer func() { _ = httpsListener.Close() }()
-	start(ctx, httpsListener, oidProvidersManager)
 
-	plog.Debug("supervisor is ready",
-		"httpAddress", httpListener.Addr().String(),
-		"httpsAddress", httpsListener.Addr().String(),
-	)
+	// The metrics endpoint has its own listener, so that the metrics are not served to the users of the OIDC endpoints.
+	metricsEndpoint := &servingEndpoint{
+		name: "metrics",
+		newHandler: func(e *supervisor.Endpoint) http.Handler {
+			if !e.RequireAuthorization {
+				return supervisormetrics.NewHandler(nil)
+			}
+			return supervisormetrics.NewHandler(&supervisormetrics.Authorizer{
+				TokenReviews:         client.Kubernetes.AuthenticationV1().TokenReviews(),
+				SubjectAccessReviews: client.Kubernetes.AuthorizationV1().SubjectAccessReviews(),
+			})
+		},
+		wrap: func(l net.Listener, e *supervisor.Endpoint) net.Listener {
+			if e.TLSSecretName == "" {
+				return l
+			}
+			return tls.NewListener(l, supervisormetrics.TLSConfig(
+				kubeInformers.Core().V1().Secrets().Lister().Secrets(serverInstallationNamespace),
+				e.TLSSecretName,
+			))
+		},
+	}
+	if err := metricsEndpoint.update(ctx, *cfg.Endpoints.Metrics); err != nil {
+		return err
+	}
+
+	// The debug endpoint serves the runtime profiles. The config only allows it on a loopback address, on a unix
+	// socket, or with the same authorization as the metrics endpoint.
+	debugEndpoint := &servingEndpoint{
+		name: "debug",
+		newHandler: func(e *supervisor.Endpoint) http.Handler {
+			if !e.RequireAuthorization {
+				return debughandler.New()
+			}
+			return supervisormetrics.WithAuthorization(&supervisormetrics.Authorizer{
+				TokenReviews:         client.Kubernetes.AuthenticationV1().TokenReviews(),
+				SubjectAccessReviews: client.Kubernetes.AuthorizationV1().SubjectAccessReviews(),
+			}, debughandler.New())
+		},
+		wrap: metricsEndpoint.wrap,
+	}
+	if err := debugEndpoint.update(ctx, *cfg.Endpoints.Debug); err != nil {
+		return err
+	}
+
+	// Apply changes to the log level and format and to the endpoints without a restart. Only the watcher's goroutine
+	// uses appliedCfg, which is the config that was last applied.
+	appliedCfg := cfg
+	reload.New("supervisor", configPath, func() error {
+		newCfg, err := supervisor.Load(configPath)
+		if err != nil {
+			return err
+		}
+		if changes := reload.PendingRestart(
+			supervisor.RestartRequiredChanges(cfg, newCfg),
+			supervisor.RestartRequiredChanges(appliedCfg, newCfg),
+		); len(changes) > 0 {
+			plog.Warning("some changed settings in the config file will only take effect after the supervisor is restarted",
+				"settings", changes,
+			)
+		}
+		if err := plog.ValidateAndSetLogLevelGlobally(newCfg.LogLevel); err != nil {
+			return fmt.Errorf("validate log level: %w", err)
+		}
+		if err := plog.ValidateAndSetLogFormatGlobally(newCfg.LogFormat, "supervisor"); err != nil {
+			return fmt.Errorf("validate log format: %w", err)
+		}
+		if err := httpEndpoint.update(ctx, *newCfg.Endpoints.HTTP); err != nil {
+			return err
+		}
+		if err := httpsEndpoint.update(ctx, *newCfg.Endpoints.HTTPS); err != nil {
+			return err
+		}
+		if err := adminEndpoint.update(ctx, *newCfg.Endpoints.Admin); err != nil {
+			return err
+		}
+		if err := metricsEndpoint.update(ctx, *newCfg.Endpoints.Metrics); err != nil {
+			return err
+		}
+		if err := debugEndpoint.update(ctx, *newCfg.Endpoints.Debug); err != nil {
+			return err
+		}
+		appliedCfg = newCfg
+		return nil
+	}).Start(ctx, reload.DefaultInterval)
+
+	plog.Debug("supervisor is ready")
 
 	gotSignal := waitForSignal()
 	plog.Debug("supervisor exiting", "signal", gotSignal)
 
+	// Release the Lease once the in-flight syncs have finished, so that another replica can take over right away.
+	cancel()
+	<-controllersStopped
+
 	return nil
 }
 
 func main() {
+	if handled, err := versioninfo.HandleArgs(os.Args[1:], os.Stdout); handled {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if handled, err := healthcheck.HandleArgs(context.Background(), os.Args[1:], os.Stdout, healthCheckURL); handled {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if handled, err := supervisoradmin.HandleArgs(context.Background(), os.Args[1:], os.Stdout); handled {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	logs.InitLogs()
 	defer logs.FlushLogs()
 	plog.RemoveKlogGlobalFlags() // move this whenever the below code gets refactored to use cobra
 
 	klog.Infof("Running %s at %#v", rest.DefaultKubernetesUserAgent(), version.Get())
-	klog.Infof("Command-line arguments were: %s %s %s", os.Args[0], os.Args[1], os.Args[2])
+	klog.Infof("Command-line arguments were: %s", strings.Join(os.Args, " "))
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	dev := flags.Bool("dev", false, "run in an insecure mode for local development and demos")
+	_ = flags.Parse(os.Args[1:]) // exits on error
+	if flags.NArg() != 2 {
+		klog.Fatalf("usage: %s [--dev] <podinfo-path> <config-path>", os.Args[0])
+	}
 
 	// Discover in which namespace we are installed.
-	podInfo, err := downward.Load(os.Args[1])
+	podInfo, err := downward.Load(flags.Arg(0))
 	if err != nil {
 		klog.Fatal(fmt.Errorf("could not read pod metadata: %w", err))
 	}
 
 	// Read the server config file.
-	cfg, err := supervisor.FromPath(os.Args[2])
+	cfg, err := supervisor.FromPath(flags.Arg(1))
 	if err != nil {
 		klog.Fatal(fmt.Errorf("could not load config: %w", err))
 	}
 
-	if err := run(podInfo, cfg); err != nil {
+	if err := run(podInfo, cfg, flags.Arg(1), *dev); err != nil {
 		klog.Fatal(err)
 	}
 }
@@ -0,0 +1,162 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthenticationv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	conciergev1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"go.pinniped.dev/internal/bootstrapcredential"
+	"go.pinniped.dev/internal/groupsuffix"
+	"go.pinniped.dev/internal/timeformat"
+)
+
+type bootstrapKubeconfigDeps struct {
+	kubeconfigDeps
+	generateToken func() (token string, tokenHash string, err error)
+	now           func() time.Time
+}
+
+func bootstrapKubeconfigRealDeps() bootstrapKubeconfigDeps {
+	return bootstrapKubeconfigDeps{
+		kubeconfigDeps: kubeconfigRealDeps(),
+		generateToken:  func() (string, string, error) { return bootstrapcredential.GenerateToken(rand.Reader) },
+		now:            time.Now,
+	}
+}
+
+//nolint: gochecknoinits
+func init() {
+	getCmd.AddCommand(bootstrapKubeconfigCommand(bootstrapKubeconfigRealDeps()))
+}
+
+type getBootstrapKubeconfigParams struct {
+	kubeconfigPath            string
+	kubeconfigContextOverride string
+	apiGroupSuffix            string
+	username                  string
+	groups                    []string
+	ttl                       time.Duration
+	reason                    string
+}
+
+func bootstrapKubeconfigCommand(deps bootstrapKubeconfigDeps) *cobra.Command {
+	var (
+		cmd = &cobra.Command{
+			Args:  cobra.NoArgs,
+			Use:   "bootstrap-kubeconfig",
+			Short: "Generate a kubeconfig containing a single-use, expiring credential for another user",
+			Long: "Generate a kubeconfig containing a single-use, expiring credential for another user.\n\n" +
+				"A BootstrapCredential resource is created in the cluster, so this command requires permission to create " +
+				"BootstrapCredentials. The credential can be exchanged with the Pinniped concierge exactly once before it expires, " +
+				"for a client certificate which is valid until the same time and which is cached and reused by the kubeconfig.",
+			SilenceUsage: true,
+		}
+		flags getBootstrapKubeconfigParams
+	)
+
+	f := cmd.Flags()
+	f.StringVar(&flags.username, "username", "", "Username of the user who will receive the kubeconfig")
+	f.StringSliceVar(&flags.groups, "groups", nil, "Groups of the user who will receive the kubeconfig (optional, can be repeated)")
+	f.DurationVar(&flags.ttl, "ttl", 1*time.Hour, "How long the credential and its client certificate are valid for (at most 24h)")
+	f.StringVar(&flags.reason, "reason", "", "Why the credential is being issued, recorded for auditing purposes")
+	f.StringVar(&flags.apiGroupSuffix, "concierge-api-group-suffix", "pinniped.dev", "Concierge API group suffix")
+	f.StringVar(&flags.kubeconfigPath, "kubeconfig", os.Getenv("KUBECONFIG"), "Path to kubeconfig file")
+	f.StringVar(&flags.kubeconfigContextOverride, "kubeconfig-context", "", "Kubeconfig context name (default: current active context)")
+
+	mustMarkRequired(cmd, "username")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runGetBootstrapKubeconfig(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), deps, flags)
+	}
+	return cmd
+}
+
+func runGetBootstrapKubeconfig(ctx context.Context, out, errOut io.Writer, deps bootstrapKubeconfigDeps, flags getBootstrapKubeconfigParams) error {
+	if err := groupsuffix.Validate(flags.apiGroupSuffix); err != nil {
+		return fmt.Errorf("invalid api group suffix: %w", err)
+	}
+	if flags.ttl <= 0 || flags.ttl > bootstrapcredential.MaxTTL {
+		return fmt.Errorf("--ttl must be positive and at most %s", bootstrapcredential.MaxTTL)
+	}
+
+	pathToSelf, err := deps.getPathToSelf()
+	if err != nil {
+		return fmt.Errorf("could not determine the Pinniped executable path: %w", err)
+	}
+
+	clientConfig := newClientConfig(flags.kubeconfigPath, flags.kubeconfigContextOverride)
+	currentKubeConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return fmt.Errorf("could not load --kubeconfig: %w", err)
+	}
+	cluster, err := copyCurrentClusterFromExistingKubeConfig(currentKubeConfig, flags.kubeconfigContextOverride)
+	if err != nil {
+		return fmt.Errorf("could not load --kubeconfig/--kubeconfig-context: %w", err)
+	}
+	clientset, err := deps.getClientset(clientConfig, flags.apiGroupSuffix)
+	if err != nil {
+		return fmt.Errorf("could not configure Kubernetes client: %w", err)
+	}
+
+	token, tokenHash, err := deps.generateToken()
+	if err != nil {
+		return err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	now := deps.now()
+	expiresAt := now.Add(flags.ttl)
+
+	cred, err := clientset.AuthenticationV1alpha1().BootstrapCredentials().Create(ctx, &conciergev1alpha1.BootstrapCredential{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "bootstrap-"},
+		Spec: conciergev1alpha1.BootstrapCredentialSpec{
+			TokenHash: tokenHash,
+			Username:  flags.username,
+			Groups:    flags.groups,
+			ExpiresAt: metav1.NewTime(expiresAt),
+			Reason:    flags.reason,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("could not create BootstrapCredential: %w", err)
+	}
+	fmt.Fprintf(errOut, "Created BootstrapCredential %q for user %q, which can be used once and expires %s\n",
+		cred.Name, flags.username, timeformat.Expiry(expiresAt, now))
+
+	execConfig := clientcmdapi.ExecConfig{
+		APIVersion: clientauthenticationv1beta1.SchemeGroupVersion.String(),
+		Command:    pathToSelf,
+		Args: []string{
+			"login",
+			"static",
+			"--enable-concierge",
+			"--concierge-api-group-suffix=" + flags.apiGroupSuffix,
+			"--concierge-authenticator-name=" + cred.Name,
+			"--concierge-authenticator-type=bootstrap",
+			"--concierge-endpoint=" + cluster.Server,
+			"--concierge-ca-bundle-data=" + base64.StdEncoding.EncodeToString(cluster.CertificateAuthorityData),
+			"--token=" + token,
+		},
+		Env:                []clientcmdapi.ExecEnvVar{},
+		ProvideClusterInfo: true,
+	}
+	return writeConfigAsYAML(out, newExecKubeconfig(cluster, &execConfig))
+}
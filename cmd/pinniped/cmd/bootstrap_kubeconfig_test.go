@@ -0,0 +1,264 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubetesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+
+	conciergev1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	conciergeclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
+	fakeconciergeclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	"go.pinniped.dev/internal/here"
+)
+
+func TestGetBootstrapKubeconfig(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	tests := []struct {
+		name               string
+		args               []string
+		getPathToSelfErr   error
+		getClientsetErr    error
+		generateTokenErr   error
+		conciergeReactions []kubetesting.Reactor
+		wantError          bool
+		wantStdout         string
+		wantStderr         string
+		wantCreated        *conciergev1alpha1.BootstrapCredential
+	}{
+		{
+			name: "help flag passed",
+			args: []string{"--help"},
+			wantStdout: here.Doc(`
+				Generate a kubeconfig containing a single-use, expiring credential for another user.
+
+				A BootstrapCredential resource is created in the cluster, so this command requires permission to create BootstrapCredentials. The credential can be exchanged with the Pinniped concierge exactly once before it expires, for a client certificate which is valid until the same time and which is cached and reused by the kubeconfig.
+
+				Usage:
+				  bootstrap-kubeconfig [flags]
+
+				Flags:
+				      --concierge-api-group-suffix string   Concierge API group suffix (default "pinniped.dev")
+				      --groups strings                      Groups of the user who will receive the kubeconfig (optional, can be repeated)
+				  -h, --help                                help for bootstrap-kubeconfig
+				      --kubeconfig string                   Path to kubeconfig file
+				      --kubeconfig-context string           Kubeconfig context name (default: current active context)
+				      --reason string                       Why the credential is being issued, recorded for auditing purposes
+				      --ttl duration                        How long the credential and its client certificate are valid for (at most 24h) (default 1h0m0s)
+				      --username string                     Username of the user who will receive the kubeconfig
+			`),
+		},
+		{
+			name:      "missing username",
+			args:      []string{},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: required flag(s) "username" not set
+			`),
+		},
+		{
+			name:      "invalid api group suffix",
+			args:      []string{"--username", "alice", "--concierge-api-group-suffix", ".starts.with.dot"},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: invalid api group suffix: a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')
+			`),
+		},
+		{
+			name:      "ttl too long",
+			args:      []string{"--username", "alice", "--ttl", "25h"},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: --ttl must be positive and at most 24h0m0s
+			`),
+		},
+		{
+			name:      "ttl not positive",
+			args:      []string{"--username", "alice", "--ttl", "0s"},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: --ttl must be positive and at most 24h0m0s
+			`),
+		},
+		{
+			name:             "fail to get self-path",
+			args:             []string{"--username", "alice"},
+			getPathToSelfErr: fmt.Errorf("some OS error"),
+			wantError:        true,
+			wantStderr: here.Doc(`
+				Error: could not determine the Pinniped executable path: some OS error
+			`),
+		},
+		{
+			name:      "invalid kubeconfig context",
+			args:      []string{"--username", "alice", "--kubeconfig", "./testdata/kubeconfig.yaml", "--kubeconfig-context", "invalid"},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: could not load --kubeconfig/--kubeconfig-context: no such context "invalid"
+			`),
+		},
+		{
+			name:            "clientset creation failure",
+			args:            []string{"--username", "alice", "--kubeconfig", "./testdata/kubeconfig.yaml"},
+			getClientsetErr: fmt.Errorf("some kube error"),
+			wantError:       true,
+			wantStderr: here.Doc(`
+				Error: could not configure Kubernetes client: some kube error
+			`),
+		},
+		{
+			name:             "token generation failure",
+			args:             []string{"--username", "alice", "--kubeconfig", "./testdata/kubeconfig.yaml"},
+			generateTokenErr: fmt.Errorf("some entropy error"),
+			wantError:        true,
+			wantStderr: here.Doc(`
+				Error: some entropy error
+			`),
+		},
+		{
+			name: "create failure",
+			args: []string{"--username", "alice", "--kubeconfig", "./testdata/kubeconfig.yaml"},
+			conciergeReactions: []kubetesting.Reactor{
+				&kubetesting.SimpleReactor{
+					Verb:     "*",
+					Resource: "*",
+					Reaction: func(kubetesting.Action) (bool, runtime.Object, error) {
+						return true, nil, fmt.Errorf("some create error")
+					},
+				},
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: could not create BootstrapCredential: some create error
+			`),
+		},
+		{
+			name: "success",
+			args: []string{
+				"--username", "alice",
+				"--groups", "group-a,group-b",
+				"--ttl", "30m",
+				"--reason", "onboarding",
+				"--kubeconfig", "./testdata/kubeconfig.yaml",
+			},
+			wantCreated: &conciergev1alpha1.BootstrapCredential{
+				ObjectMeta: metav1.ObjectMeta{Name: "bootstrap-abcde", GenerateName: "bootstrap-"},
+				Spec: conciergev1alpha1.BootstrapCredentialSpec{
+					TokenHash: "test-token-hash",
+					Username:  "alice",
+					Groups:    []string{"group-a", "group-b"},
+					ExpiresAt: metav1.NewTime(now.Add(30 * time.Minute)),
+					Reason:    "onboarding",
+				},
+			},
+			wantStdout: here.Doc(`
+				apiVersion: v1
+				clusters:
+				- cluster:
+				    certificate-authority-data: ZmFrZS1jZXJ0aWZpY2F0ZS1hdXRob3JpdHktZGF0YS12YWx1ZQ==
+				    server: https://fake-server-url-value
+				  name: pinniped
+				contexts:
+				- context:
+				    cluster: pinniped
+				    user: pinniped
+				  name: pinniped
+				current-context: pinniped
+				kind: Config
+				preferences: {}
+				users:
+				- name: pinniped
+				  user:
+				    exec:
+				      apiVersion: client.authentication.k8s.io/v1beta1
+				      args:
+				      - login
+				      - static
+				      - --enable-concierge
+				      - --concierge-api-group-suffix=pinniped.dev
+				      - --concierge-authenticator-name=bootstrap-abcde
+				      - --concierge-authenticator-type=bootstrap
+				      - --concierge-endpoint=https://fake-server-url-value
+				      - --concierge-ca-bundle-data=ZmFrZS1jZXJ0aWZpY2F0ZS1hdXRob3JpdHktZGF0YS12YWx1ZQ==
+				      - --token=test-token
+				      command: '.../path/to/pinniped'
+				      env: []
+				      provideClusterInfo: true
+			`),
+			wantStderr: here.Doc(`
+				Created BootstrapCredential "bootstrap-abcde" for user "alice", which can be used once and expires Thu, 04 Mar 2021 05:36:07 UTC (in 30m0s, 2021-03-04T05:36:07Z)
+			`),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var fake *fakeconciergeclientset.Clientset
+			cmd := bootstrapKubeconfigCommand(bootstrapKubeconfigDeps{
+				kubeconfigDeps: kubeconfigDeps{
+					getPathToSelf: func() (string, error) {
+						if tt.getPathToSelfErr != nil {
+							return "", tt.getPathToSelfErr
+						}
+						return ".../path/to/pinniped", nil
+					},
+					getClientset: func(clientConfig clientcmd.ClientConfig, apiGroupSuffix string) (conciergeclientset.Interface, error) {
+						require.Equal(t, "pinniped.dev", apiGroupSuffix)
+						if tt.getClientsetErr != nil {
+							return nil, tt.getClientsetErr
+						}
+						fake = fakeconciergeclientset.NewSimpleClientset()
+						// The fake clientset does not implement generateName, so fill in a name ourselves.
+						fake.PrependReactor("create", "bootstrapcredentials", func(action kubetesting.Action) (bool, runtime.Object, error) {
+							cred := action.(kubetesting.CreateAction).GetObject().(*conciergev1alpha1.BootstrapCredential)
+							cred.Name = cred.GenerateName + "abcde"
+							return false, nil, nil
+						})
+						if len(tt.conciergeReactions) > 0 {
+							fake.ReactionChain = tt.conciergeReactions
+						}
+						return fake, nil
+					},
+				},
+				generateToken: func() (string, string, error) {
+					if tt.generateTokenErr != nil {
+						return "", "", tt.generateTokenErr
+					}
+					return "test-token", "test-token-hash", nil
+				},
+				now: func() time.Time { return now },
+			})
+			require.NotNil(t, cmd)
+
+			var stdout, stderr bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			cmd.SetArgs(tt.args)
+			err := cmd.Execute()
+			if tt.wantError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantStdout, stdout.String(), "unexpected stdout")
+			require.Equal(t, tt.wantStderr, stderr.String(), "unexpected stderr")
+
+			if tt.wantCreated != nil {
+				got, err := fake.AuthenticationV1alpha1().BootstrapCredentials().Get(context.Background(), tt.wantCreated.Name, metav1.GetOptions{})
+				require.NoError(t, err)
+				require.Equal(t, tt.wantCreated, got)
+			}
+		})
+	}
+}
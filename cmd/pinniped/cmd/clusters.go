@@ -0,0 +1,270 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/spf13/cobra"
+	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+
+	supervisoroidc "go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/kubeconfig"
+	"go.pinniped.dev/pkg/oidcclient"
+	"go.pinniped.dev/pkg/oidcclient/filesession"
+	"go.pinniped.dev/pkg/oidcclient/oidctypes"
+)
+
+//nolint: gochecknoglobals
+var clustersCmd = &cobra.Command{Use: "clusters", Short: "clusters"}
+
+//nolint: gochecknoinits
+func init() {
+	rootCmd.AddCommand(clustersCmd)
+	clustersCmd.AddCommand(clustersListCommand(clustersListCommandRealDeps()))
+}
+
+type clustersListCommandDeps struct {
+	login     func(string, string, ...oidcclient.Option) (*oidctypes.Token, error)
+	lookupEnv func(string) (string, bool)
+}
+
+func clustersListCommandRealDeps() clustersListCommandDeps {
+	return clustersListCommandDeps{
+		login:     oidcclient.Login,
+		lookupEnv: os.LookupEnv,
+	}
+}
+
+type clustersListFlags struct {
+	issuer                 string
+	clientID               string
+	skipBrowser            bool
+	sessionCachePath       string
+	sessionCacheKeychain   bool
+	sessionCacheEncryption string
+	caBundlePaths          []string
+	caBundleData           []string
+	proxy                  proxyFlags
+	clusterNames           []string
+	outputFormat           string
+}
+
+func clustersListCommand(deps clustersListCommandDeps) *cobra.Command {
+	var (
+		cmd = &cobra.Command{
+			Args:  cobra.NoArgs,
+			Use:   "list --issuer ISSUER",
+			Short: "List the clusters which you can access through a Pinniped Supervisor",
+			Long: "List the clusters of a Pinniped Supervisor FederationDomain which you are allowed to access, logging in\n" +
+				"to the Supervisor first if needed.\n\n" +
+				"With --output=kubeconfig, a kubeconfig with one context per cluster is printed instead.",
+			SilenceUsage: true,
+		}
+		flags clustersListFlags
+	)
+	cmd.Flags().StringVar(&flags.issuer, "issuer", "", "Pinniped Supervisor issuer URL")
+	cmd.Flags().StringVar(&flags.clientID, "client-id", "pinniped-cli", "OpenID Connect client ID")
+	cmd.Flags().BoolVar(&flags.skipBrowser, "skip-browser", false, "Skip opening the browser (just print the URL)")
+	cmd.Flags().StringVar(&flags.sessionCachePath, "session-cache", filepath.Join(mustGetConfigDir(), "sessions.yaml"), "Path to session cache file")
+	cmd.Flags().BoolVar(&flags.sessionCacheKeychain, "session-cache-keychain", true, "Store refresh tokens in the OS keychain instead of the session cache file, when a keychain is available")
+	cmd.Flags().StringVar(&flags.sessionCacheEncryption, "session-cache-encryption", "none", sessionCacheEncryptionUsage)
+	cmd.Flags().StringSliceVar(&flags.caBundlePaths, "ca-bundle", nil, "Path to TLS certificate authority bundle (PEM format, optional, can be repeated)")
+	cmd.Flags().StringSliceVar(&flags.caBundleData, "ca-bundle-data", nil, "Base64 endcoded TLS certificate authority bundle (base64 encoded PEM format, optional, can be repeated)")
+	addProxyFlags(cmd.Flags(), &flags.proxy)
+	cmd.Flags().StringSliceVar(&flags.clusterNames, "cluster", nil, "Only include this cluster (optional, can be repeated)")
+	cmd.Flags().StringVarP(&flags.outputFormat, "output", "o", "text", "Output format (e.g., 'text', 'json', 'yaml', 'kubeconfig')")
+	mustMarkRequired(cmd, "issuer")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error { return runClustersList(cmd, deps, flags) }
+	return cmd
+}
+
+func runClustersList(cmd *cobra.Command, deps clustersListCommandDeps, flags clustersListFlags) error {
+	switch flags.outputFormat {
+	case "text", "json", "yaml", "kubeconfig":
+	default:
+		return fmt.Errorf("unknown output format: %q", flags.outputFormat)
+	}
+
+	proxy, err := loadProxyConfig(flags.proxy, deps.lookupEnv)
+	if err != nil {
+		return err
+	}
+	httpClient, err := makeClient(flags.caBundlePaths, flags.caBundleData, proxy)
+	if err != nil {
+		return err
+	}
+
+	// Log in with the same scopes as "pinniped login oidc", so that the session of the kubeconfigs is reused.
+	encryptionOptions, err := encryptionSessionOptions(flags.sessionCacheEncryption, deps.lookupEnv)
+	if err != nil {
+		return err
+	}
+	sessionOptions := append(keychainSessionOptions(flags.sessionCacheKeychain), encryptionOptions...)
+	opts := []oidcclient.Option{
+		oidcclient.WithContext(cmd.Context()),
+		oidcclient.WithScopes([]string{oidc.ScopeOfflineAccess, oidc.ScopeOpenID, "pinniped:request-audience"}),
+		oidcclient.WithSessionCache(filesession.New(flags.sessionCachePath, sessionOptions...)),
+		oidcclient.WithClient(httpClient),
+	}
+	if flags.skipBrowser {
+		opts = append(opts, oidcclient.WithBrowserOpen(func(url string) error {
+			cmd.PrintErr("Please log in: ", url, "\n")
+			return nil
+		}))
+	}
+	token, err := deps.login(flags.issuer, flags.clientID, opts...)
+	if err != nil {
+		return fmt.Errorf("could not complete Pinniped login: %w", err)
+	}
+	if token.AccessToken == nil || token.AccessToken.Token == "" {
+		return errors.New("could not complete Pinniped login: no access token was issued")
+	}
+
+	var list kubeconfig.ClusterList
+	if err := getWithAccessToken(cmd.Context(), httpClient, strings.TrimSuffix(flags.issuer, "/")+supervisoroidc.ClustersEndpointPath, token.AccessToken.Token, func(body []byte) error {
+		return json.Unmarshal(body, &list)
+	}); err != nil {
+		return fmt.Errorf("could not list clusters: %w", err)
+	}
+	list.Clusters, err = selectClusters(list.Clusters, flags.clusterNames)
+	if err != nil {
+		return err
+	}
+
+	switch flags.outputFormat {
+	case "json":
+		data, err := json.MarshalIndent(&list, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = cmd.OutOrStdout().Write(append(data, '\n'))
+		return err
+	case "yaml":
+		data, err := yaml.Marshal(&list)
+		if err != nil {
+			return err
+		}
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
+	case "kubeconfig":
+		return writeClustersKubeconfig(cmd.Context(), cmd.OutOrStdout(), httpClient, token.AccessToken.Token, list.Clusters)
+	default:
+		return writeClustersText(cmd.OutOrStdout(), list.Clusters)
+	}
+}
+
+// selectClusters returns the clusters with the given names, or all clusters when no names are given.
+func selectClusters(clusters []kubeconfig.ClusterListItem, names []string) ([]kubeconfig.ClusterListItem, error) {
+	if len(names) == 0 {
+		return clusters, nil
+	}
+	selected := make([]kubeconfig.ClusterListItem, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, cluster := range clusters {
+			if cluster.Name == name {
+				selected = append(selected, cluster)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("cluster %q does not exist or you are not allowed to access it", name)
+		}
+	}
+	return selected, nil
+}
+
+func writeClustersText(output io.Writer, clusters []kubeconfig.ClusterListItem) error {
+	if len(clusters) == 0 {
+		_, err := fmt.Fprintln(output, "No clusters found.")
+		return err
+	}
+	w := tabwriter.NewWriter(output, 0, 4, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tSERVER\tAUDIENCE")
+	for _, cluster := range clusters {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", cluster.Name, cluster.Server, cluster.Audience)
+	}
+	return w.Flush()
+}
+
+// writeClustersKubeconfig downloads the kubeconfig of each cluster and merges them into one kubeconfig, whose current
+// context is the first cluster.
+func writeClustersKubeconfig(ctx context.Context, output io.Writer, httpClient *http.Client, accessToken string, clusters []kubeconfig.ClusterListItem) error {
+	merged := clientcmdv1.Config{
+		Kind:       "Config",
+		APIVersion: clientcmdv1.SchemeGroupVersion.Version,
+		Clusters:   []clientcmdv1.NamedCluster{},
+		AuthInfos:  []clientcmdv1.NamedAuthInfo{},
+		Contexts:   []clientcmdv1.NamedContext{},
+	}
+	for _, cluster := range clusters {
+		kubeconfigURL, err := url.Parse(cluster.KubeconfigURL)
+		if err != nil {
+			return fmt.Errorf("invalid kubeconfig URL of cluster %q: %w", cluster.Name, err)
+		}
+		// The Supervisor would guess the platform from our User-Agent, which does not have one.
+		query := kubeconfigURL.Query()
+		query.Set("os", runtime.GOOS)
+		kubeconfigURL.RawQuery = query.Encode()
+
+		var config clientcmdv1.Config
+		if err := getWithAccessToken(ctx, httpClient, kubeconfigURL.String(), accessToken, func(body []byte) error {
+			return yaml.Unmarshal(body, &config)
+		}); err != nil {
+			return fmt.Errorf("could not get kubeconfig of cluster %q: %w", cluster.Name, err)
+		}
+		merged.Clusters = append(merged.Clusters, config.Clusters...)
+		merged.AuthInfos = append(merged.AuthInfos, config.AuthInfos...)
+		merged.Contexts = append(merged.Contexts, config.Contexts...)
+		if merged.CurrentContext == "" {
+			merged.CurrentContext = config.CurrentContext
+		}
+	}
+
+	data, err := yaml.Marshal(&merged)
+	if err != nil {
+		return err
+	}
+	_, err = output.Write(data)
+	return err
+}
+
+// getWithAccessToken gets an endpoint of the Supervisor which is authenticated with an access token, and decodes the
+// response body when it succeeds.
+func getWithAccessToken(ctx context.Context, httpClient *http.Client, endpoint string, accessToken string, decode func([]byte) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	rsp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", rsp.Status, strings.TrimSpace(string(body)))
+	}
+	return decode(body)
+}
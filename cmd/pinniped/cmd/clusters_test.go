@@ -0,0 +1,256 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/pkg/oidcclient"
+	"go.pinniped.dev/pkg/oidcclient/oidctypes"
+)
+
+func TestClustersListCommand(t *testing.T) {
+	var issuer string
+	supervisor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-access-token" {
+			http.Error(w, "Unauthorized: invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/clusters":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"clusters":[`+
+				`{"name":"dev","audience":"dev-audience","server":"https://dev.example.com","kubeconfigURL":"%[1]s/kubeconfig?cluster=dev"},`+
+				`{"name":"prod","audience":"prod-audience","server":"https://prod.example.com","kubeconfigURL":"%[1]s/kubeconfig?cluster=prod"}`+
+				`]}`, issuer)
+		case "/kubeconfig":
+			require.Equal(t, runtime.GOOS, r.URL.Query().Get("os"))
+			name := r.URL.Query().Get("cluster")
+			_, _ = fmt.Fprint(w, here.Docf(`
+				apiVersion: v1
+				kind: Config
+				clusters:
+				- cluster:
+				    server: https://%[1]s.example.com
+				  name: %[1]s
+				contexts:
+				- context:
+				    cluster: %[1]s
+				    user: %[1]s
+				  name: %[1]s
+				current-context: %[1]s
+				users:
+				- name: %[1]s
+				  user:
+				    exec:
+				      apiVersion: client.authentication.k8s.io/v1beta1
+				      command: pinniped
+				      args: [login, oidc, --request-audience=%[1]s-audience]
+			`, name))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(supervisor.Close)
+	issuer = supervisor.URL
+
+	tests := []struct {
+		name        string
+		args        []string
+		accessToken string
+		loginErr    error
+		wantError   bool
+		wantStdout  string
+		wantStderr  string
+	}{
+		{
+			name: "help flag passed",
+			args: []string{"--help"},
+			wantStdout: here.Doc(`
+				List the clusters of a Pinniped Supervisor FederationDomain which you are allowed to access, logging in
+				to the Supervisor first if needed.
+
+				With --output=kubeconfig, a kubeconfig with one context per cluster is printed instead.
+
+				Usage:
+				  list --issuer ISSUER [flags]
+
+				Flags:
+				      --ca-bundle strings                 Path to TLS certificate authority bundle (PEM format, optional, can be repeated)
+				      --ca-bundle-data strings            Base64 endcoded TLS certificate authority bundle (base64 encoded PEM format, optional, can be repeated)
+				      --client-id string                  OpenID Connect client ID (default "pinniped-cli")
+				      --cluster strings                   Only include this cluster (optional, can be repeated)
+				  -h, --help                              help for list
+				      --issuer string                     Pinniped Supervisor issuer URL
+				  -o, --output string                     Output format (e.g., 'text', 'json', 'yaml', 'kubeconfig') (default "text")
+				      --proxy-ca-bundle string            Path to the TLS certificate authority bundle of the proxy (PEM format, optional) (default: $PINNIPED_PROXY_CA_BUNDLE)
+				      --proxy-url string                  URL of the HTTP(S) proxy for requests to the OpenID Connect provider and the concierge, which may include credentials (default: $PINNIPED_PROXY_URL, or the standard proxy environment variables)
+				      --session-cache string              Path to session cache file (default "` + filepath.Join(mustGetConfigDir(), "sessions.yaml") + `")
+				      --session-cache-encryption string   Encrypt the session cache file with a key derived from $PINNIPED_SESSION_CACHE_PASSPHRASE ('passphrase'), or do not encrypt it ('none') (default "none")
+				      --session-cache-keychain            Store refresh tokens in the OS keychain instead of the session cache file, when a keychain is available (default true)
+				      --skip-browser                      Skip opening the browser (just print the URL)
+			`),
+		},
+		{
+			name:      "missing issuer",
+			args:      []string{},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: required flag(s) "issuer" not set
+			`),
+		},
+		{
+			name:      "unknown output format",
+			args:      []string{"--issuer", issuer, "--output", "xml"},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: unknown output format: "xml"
+			`),
+		},
+		{
+			name:      "login fails",
+			args:      []string{"--issuer", issuer},
+			loginErr:  fmt.Errorf("some login error"),
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: could not complete Pinniped login: some login error
+			`),
+		},
+		{
+			name:        "listing fails",
+			args:        []string{"--issuer", issuer},
+			accessToken: "wrong-access-token",
+			wantError:   true,
+			wantStderr: here.Doc(`
+				Error: could not list clusters: 401 Unauthorized: Unauthorized: invalid bearer token
+			`),
+		},
+		{
+			name: "text output",
+			args: []string{"--issuer", issuer},
+			wantStdout: here.Doc(`
+				NAME   SERVER                     AUDIENCE
+				dev    https://dev.example.com    dev-audience
+				prod   https://prod.example.com   prod-audience
+			`),
+		},
+		{
+			name: "json output of a selected cluster",
+			args: []string{"--issuer", issuer, "--cluster", "prod", "-o", "json"},
+			wantStdout: here.Docf(`
+				{
+				  "clusters": [
+				    {
+				      "name": "prod",
+				      "audience": "prod-audience",
+				      "server": "https://prod.example.com",
+				      "kubeconfigURL": "%s/kubeconfig?cluster=prod"
+				    }
+				  ]
+				}
+			`, issuer),
+		},
+		{
+			name:      "selected cluster is not listed",
+			args:      []string{"--issuer", issuer, "--cluster", "staging"},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: cluster "staging" does not exist or you are not allowed to access it
+			`),
+		},
+		{
+			name: "kubeconfig output",
+			args: []string{"--issuer", issuer, "--output", "kubeconfig"},
+			wantStdout: here.Doc(`
+				apiVersion: v1
+				clusters:
+				- cluster:
+				    server: https://dev.example.com
+				  name: dev
+				- cluster:
+				    server: https://prod.example.com
+				  name: prod
+				contexts:
+				- context:
+				    cluster: dev
+				    user: dev
+				  name: dev
+				- context:
+				    cluster: prod
+				    user: prod
+				  name: prod
+				current-context: dev
+				kind: Config
+				preferences: {}
+				users:
+				- name: dev
+				  user:
+				    exec:
+				      apiVersion: client.authentication.k8s.io/v1beta1
+				      args:
+				      - login
+				      - oidc
+				      - --request-audience=dev-audience
+				      command: pinniped
+				      env: null
+				      provideClusterInfo: false
+				- name: prod
+				  user:
+				    exec:
+				      apiVersion: client.authentication.k8s.io/v1beta1
+				      args:
+				      - login
+				      - oidc
+				      - --request-audience=prod-audience
+				      command: pinniped
+				      env: null
+				      provideClusterInfo: false
+			`),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := clustersListCommand(clustersListCommandDeps{
+				login: func(gotIssuer string, clientID string, _ ...oidcclient.Option) (*oidctypes.Token, error) {
+					require.Equal(t, issuer, gotIssuer)
+					require.Equal(t, "pinniped-cli", clientID)
+					if tt.loginErr != nil {
+						return nil, tt.loginErr
+					}
+					accessToken := "test-access-token"
+					if tt.accessToken != "" {
+						accessToken = tt.accessToken
+					}
+					return &oidctypes.Token{AccessToken: &oidctypes.AccessToken{Token: accessToken}}, nil
+				},
+				lookupEnv: func(string) (string, bool) { return "", false },
+			})
+			require.NotNil(t, cmd)
+
+			var stdout, stderr bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			cmd.SetArgs(append([]string{"--session-cache", testutil.TempDir(t) + "/sessions.yaml", "--session-cache-keychain=false"}, tt.args...))
+			err := cmd.Execute()
+			if tt.wantError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantStdout, stdout.String(), "unexpected stdout")
+			require.Equal(t, tt.wantStderr, stderr.String(), "unexpected stderr")
+		})
+	}
+}
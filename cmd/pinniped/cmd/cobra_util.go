@@ -23,6 +23,13 @@ func mustMarkHidden(cmd *cobra.Command, flags ...string) {
 	}
 }
 
+// mustRegisterFlagCompletion registers the dynamic completion of the given flag. If the name is wrong, it panics.
+func mustRegisterFlagCompletion(cmd *cobra.Command, flag string, f func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective)) {
+	if err := cmd.RegisterFlagCompletionFunc(flag, f); err != nil {
+		panic(err)
+	}
+}
+
 func mustMarkDeprecated(cmd *cobra.Command, flag, usageMessage string) {
 	if err := cmd.Flags().MarkDeprecated(flag, usageMessage); err != nil {
 		panic(err)
@@ -0,0 +1,133 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// completionTimeout is how long the dynamic completions may query the cluster, since the user is waiting at the shell.
+const completionTimeout = 5 * time.Second
+
+//nolint: gochecknoinits
+func init() {
+	rootCmd.AddCommand(newCompletionCommand())
+}
+
+func newCompletionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Generate the autocompletion script for the specified shell",
+		Long: "Generate the autocompletion script of the Pinniped CLI for the specified shell.\n\n" +
+			"For example, to load the completions in every new bash session, add this line to ~/.bashrc:\n\n" +
+			"    source <(pinniped completion bash)\n\n" +
+			"Except in PowerShell, the names of authenticators and namespaces are completed by querying the cluster of the current kubeconfig.",
+		Args:                  cobra.ExactValidArgs(1),
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			switch args[0] {
+			case "bash":
+				return cmd.Root().GenBashCompletion(out)
+			case "zsh":
+				return cmd.Root().GenZshCompletion(out)
+			case "fish":
+				return cmd.Root().GenFishCompletion(out, true)
+			default:
+				return cmd.Root().GenPowerShellCompletion(out)
+			}
+		},
+	}
+}
+
+// registerKubeconfigCompletions completes the flags of "pinniped get kubeconfig" whose values are names of objects in
+// the cluster of --kubeconfig and --kubeconfig-context. The flags are read when the completion runs, so the completions
+// respect the flags which the user has already typed.
+func registerKubeconfigCompletions(cmd *cobra.Command, deps kubeconfigDeps, flags *getKubeconfigParams) {
+	mustRegisterFlagCompletion(cmd, "concierge-authenticator-type", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return []string{"webhook", "jwt", "cloudidentity", "statictoken", "clientcertificate", "serviceaccount"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	mustRegisterFlagCompletion(cmd, "concierge-authenticator-name", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		names, err := completeAuthenticatorNames(deps, flags)
+		if err != nil {
+			cobra.CompDebugln(err.Error(), false)
+			return nil, cobra.ShellCompDirectiveError
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+	completeNamespaces := func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		names, err := completeNamespaceNames(deps, flags)
+		if err != nil {
+			cobra.CompDebugln(err.Error(), false)
+			return nil, cobra.ShellCompDirectiveError
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+	mustRegisterFlagCompletion(cmd, "namespace", completeNamespaces)
+	mustRegisterFlagCompletion(cmd, "fleet-secret-namespace", completeNamespaces)
+}
+
+// completeAuthenticatorNames returns the names of the authenticators of the type of --concierge-authenticator-type,
+// or of every type when it is not set.
+func completeAuthenticatorNames(deps kubeconfigDeps, flags *getKubeconfigParams) ([]string, error) {
+	clientConfig := newClientConfig(flags.kubeconfigPath, flags.kubeconfigContextOverride)
+	apiGroupSuffix := flags.concierge.apiGroupSuffix
+	if apiGroupSuffix == "" {
+		discoveryClient, err := deps.getDiscovery(clientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("could not configure Kubernetes client: %w", err)
+		}
+		if apiGroupSuffix, err = discoverAPIGroupSuffix(discoveryClient); err != nil {
+			return nil, err
+		}
+	}
+	clientset, err := deps.getClientset(clientConfig, apiGroupSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("could not configure Kubernetes client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	authenticators, err := listAuthenticators(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, authenticator := range authenticators {
+		if flags.concierge.authenticatorType == "" || strings.EqualFold(flags.concierge.authenticatorType, authenticatorType(authenticator)) {
+			names = append(names, authenticator.GetName())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// completeNamespaceNames returns the names of the namespaces of the cluster.
+func completeNamespaceNames(deps kubeconfigDeps, flags *getKubeconfigParams) ([]string, error) {
+	kubeClient, err := deps.getKubeClient(newClientConfig(flags.kubeconfigPath, flags.kubeconfigContextOverride))
+	if err != nil {
+		return nil, fmt.Errorf("could not configure Kubernetes client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	namespaces, err := kubeClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not list namespaces: %w", err)
+	}
+	names := make([]string, 0, len(namespaces.Items))
+	for _, namespace := range namespaces.Items {
+		names = append(names, namespace.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	kubetesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+
+	conciergev1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	conciergeclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
+	fakeconciergeclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+)
+
+func TestCompletionCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		wantError    string
+		wantContains string
+	}{
+		{
+			name:         "bash",
+			args:         []string{"completion", "bash"},
+			wantContains: "# bash completion for test-cli",
+		},
+		{
+			name:         "zsh",
+			args:         []string{"completion", "zsh"},
+			wantContains: "#compdef _test-cli test-cli",
+		},
+		{
+			name:         "fish",
+			args:         []string{"completion", "fish"},
+			wantContains: "# fish completion for test-cli",
+		},
+		{
+			name:         "powershell",
+			args:         []string{"completion", "powershell"},
+			wantContains: "Register-ArgumentCompleter -Native -CommandName 'test-cli'",
+		},
+		{
+			name:      "unknown shell",
+			args:      []string{"completion", "tcsh"},
+			wantError: `invalid argument "tcsh" for "test-cli completion"`,
+		},
+		{
+			name:      "no shell",
+			args:      []string{"completion"},
+			wantError: "accepts 1 arg(s), received 0",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			root := &cobra.Command{Use: "test-cli", SilenceUsage: true, SilenceErrors: true}
+			root.AddCommand(newCompletionCommand())
+
+			var stdout bytes.Buffer
+			root.SetOut(&stdout)
+			root.SetArgs(tt.args)
+			err := root.Execute()
+			if tt.wantError != "" {
+				require.EqualError(t, err, tt.wantError)
+				return
+			}
+			require.NoError(t, err)
+			require.Contains(t, stdout.String(), tt.wantContains)
+		})
+	}
+}
+
+func TestKubeconfigCompletions(t *testing.T) {
+	conciergeObjects := []runtime.Object{
+		&conciergev1alpha1.JWTAuthenticator{ObjectMeta: metav1.ObjectMeta{Name: "test-jwt-authenticator-2"}},
+		&conciergev1alpha1.JWTAuthenticator{ObjectMeta: metav1.ObjectMeta{Name: "test-jwt-authenticator-1"}},
+		&conciergev1alpha1.WebhookAuthenticator{ObjectMeta: metav1.ObjectMeta{Name: "test-webhook-authenticator"}},
+	}
+	kubeObjects := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-namespace-b"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-namespace-a"}},
+	}
+
+	tests := []struct {
+		name             string
+		args             []string
+		getClientsetErr  error
+		getKubeClientErr error
+		wantSuffix       string
+		wantStdout       string
+	}{
+		{
+			name:       "authenticator types",
+			args:       []string{"--concierge-authenticator-type", ""},
+			wantStdout: "webhook\njwt\ncloudidentity\nstatictoken\nclientcertificate\nserviceaccount\n:4\n",
+		},
+		{
+			name:       "authenticator names of every type",
+			args:       []string{"--concierge-authenticator-name", ""},
+			wantSuffix: "pinniped.dev",
+			wantStdout: "test-jwt-authenticator-1\ntest-jwt-authenticator-2\ntest-webhook-authenticator\n:4\n",
+		},
+		{
+			name:       "authenticator names of one type",
+			args:       []string{"--concierge-authenticator-type", "JWT", "--concierge-api-group-suffix", "tuna.io", "--concierge-authenticator-name", ""},
+			wantSuffix: "tuna.io",
+			wantStdout: "test-jwt-authenticator-1\ntest-jwt-authenticator-2\n:4\n",
+		},
+		{
+			name:            "authenticator names when the cluster cannot be reached",
+			args:            []string{"--concierge-authenticator-name", ""},
+			getClientsetErr: fmt.Errorf("some kube error"),
+			wantSuffix:      "pinniped.dev",
+			wantStdout:      ":1\n",
+		},
+		{
+			name:       "namespaces",
+			args:       []string{"--namespace", ""},
+			wantStdout: "test-namespace-a\ntest-namespace-b\n:4\n",
+		},
+		{
+			name:       "fleet Secret namespaces",
+			args:       []string{"--fleet-secret-namespace", ""},
+			wantStdout: "test-namespace-a\ntest-namespace-b\n:4\n",
+		},
+		{
+			name:             "namespaces when the cluster cannot be reached",
+			args:             []string{"--namespace", ""},
+			getKubeClientErr: fmt.Errorf("some kube error"),
+			wantStdout:       ":1\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := kubeconfigCommand(kubeconfigDeps{
+				getDiscovery: func(clientConfig clientcmd.ClientConfig) (discovery.DiscoveryInterface, error) {
+					fake := &fakediscovery.FakeDiscovery{Fake: &kubetesting.Fake{}}
+					fake.Resources = append(fake.Resources, &metav1.APIResourceList{GroupVersion: "login.concierge.pinniped.dev/v1alpha1"})
+					return fake, nil
+				},
+				getKubeClient: func(clientConfig clientcmd.ClientConfig) (kubernetes.Interface, error) {
+					if tt.getKubeClientErr != nil {
+						return nil, tt.getKubeClientErr
+					}
+					return kubernetesfake.NewSimpleClientset(kubeObjects...), nil
+				},
+				getClientset: func(clientConfig clientcmd.ClientConfig, apiGroupSuffix string) (conciergeclientset.Interface, error) {
+					require.Equal(t, tt.wantSuffix, apiGroupSuffix)
+					if tt.getClientsetErr != nil {
+						return nil, tt.getClientsetErr
+					}
+					return fakeconciergeclientset.NewSimpleClientset(conciergeObjects...), nil
+				},
+			})
+
+			var stdout, stderr bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			cmd.SetArgs(append([]string{cobra.ShellCompNoDescRequestCmd}, tt.args...))
+			require.NoError(t, cmd.Execute())
+			require.Equal(t, tt.wantStdout, stdout.String())
+		})
+	}
+}
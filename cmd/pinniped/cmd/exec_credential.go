@@ -0,0 +1,85 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	clientauthv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+
+	"go.pinniped.dev/internal/execcredcache"
+)
+
+const (
+	// execInfoEnvVarName is set by kubectl to the ExecCredential which it passes to the login command.
+	execInfoEnvVarName = "KUBERNETES_EXEC_INFO"
+
+	// execCredentialV1 is the GA version of the ExecCredential API, which kubectl 1.22 and newer support. Its status
+	// has the same fields as the v1beta1 status, so the v1beta1 types are used to write both versions.
+	execCredentialV1      = "client.authentication.k8s.io/v1"
+	execCredentialV1beta1 = "client.authentication.k8s.io/v1beta1"
+
+	// ifAvailableExecInteractiveMode lets kubectl give the login command a terminal when it has one, so that it can
+	// prompt for a password. Exec configs of the v1 API must have an interactiveMode.
+	ifAvailableExecInteractiveMode = "IfAvailable"
+
+	// credentialCacheUsage is the usage of the --credential-cache flags.
+	credentialCacheUsage = "Path to the cache of the short-lived cluster credentials issued by the concierge (\"\" disables the cache)"
+)
+
+// execCredentialAPIVersion returns the version of the ExecCredential API which kubectl expects the login command to
+// write. Older versions of kubectl do not always set KUBERNETES_EXEC_INFO, and they all expect v1beta1.
+func execCredentialAPIVersion(lookupEnv func(string) (string, bool)) (string, error) {
+	execInfo, ok := lookupEnv(execInfoEnvVarName)
+	if !ok || execInfo == "" {
+		return execCredentialV1beta1, nil
+	}
+	var cred clientauthv1beta1.ExecCredential
+	if err := json.Unmarshal([]byte(execInfo), &cred); err != nil {
+		return "", fmt.Errorf("could not parse %s: %w", execInfoEnvVarName, err)
+	}
+	switch cred.APIVersion {
+	case execCredentialV1, execCredentialV1beta1:
+		return cred.APIVersion, nil
+	default:
+		return "", fmt.Errorf("unsupported ExecCredential API version %q in %s (use %s or %s)", cred.APIVersion, execInfoEnvVarName, execCredentialV1, execCredentialV1beta1)
+	}
+}
+
+// writeExecCredential writes the credential for kubectl in the requested version of the ExecCredential API.
+func writeExecCredential(out io.Writer, cred *clientauthv1beta1.ExecCredential, apiVersion string) error {
+	cred.Kind = "ExecCredential"
+	cred.APIVersion = apiVersion
+	return json.NewEncoder(out).Encode(cred)
+}
+
+// credentialCacheKey identifies a cluster credential in the --credential-cache. A cached credential is only reused
+// with the same Concierge authenticator and the same identity. The cache stores only a hash of the key, so the
+// identity may contain secrets, e.g. a static token.
+type credentialCacheKey struct {
+	ConciergeEndpoint          string   `json:"conciergeEndpoint"`
+	ConciergeAPIGroupSuffix    string   `json:"conciergeAPIGroupSuffix"`
+	ConciergeAuthenticatorType string   `json:"conciergeAuthenticatorType"`
+	ConciergeAuthenticatorName string   `json:"conciergeAuthenticatorName"`
+	ConciergeUseProxy          bool     `json:"conciergeUseProxy"`
+	Identity                   []string `json:"identity"`
+}
+
+// newCredentialCache returns the cache of the credentials issued by the Concierge, or nil when it is disabled. The
+// cache file is encrypted with the encryptionSecret, unless it is nil.
+func newCredentialCache(cmd *cobra.Command, path string, encryptionSecret []byte) *execcredcache.Cache {
+	if path == "" {
+		return nil
+	}
+	options := []execcredcache.Option{execcredcache.WithErrorReporter(func(err error) {
+		cmd.PrintErrf("Warning: %v\n", err)
+	})}
+	if encryptionSecret != nil {
+		options = append(options, execcredcache.WithEncryption(encryptionSecret))
+	}
+	return execcredcache.New(path, options...)
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -18,6 +19,9 @@ import (
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
 	clientauthenticationv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
@@ -25,6 +29,8 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth" // Adds handlers for various dynamic auth plugins in client-go
 
 	conciergev1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	loginv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/login/v1alpha1"
 	conciergeclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
 	"go.pinniped.dev/internal/groupsuffix"
 	"go.pinniped.dev/internal/kubeclient"
@@ -33,6 +39,8 @@ import (
 type kubeconfigDeps struct {
 	getPathToSelf func() (string, error)
 	getClientset  func(clientConfig clientcmd.ClientConfig, apiGroupSuffix string) (conciergeclientset.Interface, error)
+	getDiscovery  func(clientConfig clientcmd.ClientConfig) (discovery.DiscoveryInterface, error)
+	getKubeClient func(clientConfig clientcmd.ClientConfig) (kubernetes.Interface, error)
 }
 
 func kubeconfigRealDeps() kubeconfigDeps {
@@ -52,10 +60,28 @@ func kubeconfigRealDeps() kubeconfigDeps {
 			}
 			return client.PinnipedConcierge, nil
 		},
+		getDiscovery: func(clientConfig clientcmd.ClientConfig) (discovery.DiscoveryInterface, error) {
+			restConfig, err := clientConfig.ClientConfig()
+			if err != nil {
+				return nil, err
+			}
+			return discovery.NewDiscoveryClientForConfig(restConfig)
+		},
+		getKubeClient: func(clientConfig clientcmd.ClientConfig) (kubernetes.Interface, error) {
+			restConfig, err := clientConfig.ClientConfig()
+			if err != nil {
+				return nil, err
+			}
+			client, err := kubeclient.New(kubeclient.WithConfig(restConfig))
+			if err != nil {
+				return nil, err
+			}
+			return client.Kubernetes, nil
+		},
 	}
 }
 
-//nolint: gochecknoinits
+// nolint: gochecknoinits
 func init() {
 	getCmd.AddCommand(kubeconfigCommand(kubeconfigRealDeps()))
 }
@@ -64,12 +90,17 @@ type getKubeconfigOIDCParams struct {
 	issuer            string
 	clientID          string
 	listenPort        uint16
+	listenPortRange   string
+	listenAddress     string
+	loginTimeout      time.Duration
 	scopes            []string
 	skipBrowser       bool
 	sessionCachePath  string
+	sessionEncryption string
 	debugSessionCache bool
 	caBundlePaths     []string
 	requestAudience   string
+	upstreamIDP       upstreamIdentityProviderFlags
 }
 
 type getKubeconfigConciergeParams struct {
@@ -82,10 +113,20 @@ type getKubeconfigConciergeParams struct {
 type getKubeconfigParams struct {
 	kubeconfigPath            string
 	kubeconfigContextOverride string
+	contextName               string
+	userName                  string
+	namespaces                []string
+	outputFormat              string
+	mergeInto                 string
+	mergeOverwrite            bool
+	execAPIVersion            string
+	proxy                     proxyFlags
 	staticToken               string
 	staticTokenEnvName        string
+	staticTokenFile           string
 	oidc                      getKubeconfigOIDCParams
 	concierge                 getKubeconfigConciergeParams
+	fleet                     getKubeconfigFleetParams
 }
 
 func kubeconfigCommand(deps kubeconfigDeps) *cobra.Command {
@@ -103,26 +144,46 @@ func kubeconfigCommand(deps kubeconfigDeps) *cobra.Command {
 	f := cmd.Flags()
 	f.StringVar(&flags.staticToken, "static-token", "", "Instead of doing an OIDC-based login, specify a static token")
 	f.StringVar(&flags.staticTokenEnvName, "static-token-env", "", "Instead of doing an OIDC-based login, read a static token from the environment")
+	f.StringVar(&flags.staticTokenFile, "static-token-file", "", "Instead of doing an OIDC-based login, read a token from a file on every login (e.g., a projected ServiceAccount token)")
 
 	f.BoolVar(&flags.concierge.disabled, "no-concierge", false, "Generate a configuration which does not use the concierge, but sends the credential to the cluster directly")
 	f.StringVar(&namespace, "concierge-namespace", "pinniped-concierge", "Namespace in which the concierge was installed")
 	f.StringVar(&flags.concierge.authenticatorType, "concierge-authenticator-type", "", "Concierge authenticator type (e.g., 'webhook', 'jwt') (default: autodiscover)")
 	f.StringVar(&flags.concierge.authenticatorName, "concierge-authenticator-name", "", "Concierge authenticator name (default: autodiscover)")
-	f.StringVar(&flags.concierge.apiGroupSuffix, "concierge-api-group-suffix", "pinniped.dev", "Concierge API group suffix")
+	f.StringVar(&flags.concierge.apiGroupSuffix, "concierge-api-group-suffix", "", "Concierge API group suffix (default: autodiscover)")
 
 	f.StringVar(&flags.oidc.issuer, "oidc-issuer", "", "OpenID Connect issuer URL (default: autodiscover)")
 	f.StringVar(&flags.oidc.clientID, "oidc-client-id", "pinniped-cli", "OpenID Connect client ID (default: autodiscover)")
 	f.Uint16Var(&flags.oidc.listenPort, "oidc-listen-port", 0, "TCP port for localhost listener (authorization code flow only)")
+	f.StringVar(&flags.oidc.listenPortRange, "oidc-listen-port-range", "", "Range of TCP ports to try for localhost listener, e.g. '49152-49162' (authorization code flow only)")
+	f.StringVar(&flags.oidc.listenAddress, "oidc-listen-address", "", "Loopback address for localhost listener, e.g. '127.0.0.1' or '::1' (authorization code flow only, default: localhost)")
+	f.DurationVar(&flags.oidc.loginTimeout, "oidc-login-timeout", 0, "Overall time allowed for an OpenID Connect login, including any interaction with the user (default: 90m)")
 	f.StringSliceVar(&flags.oidc.scopes, "oidc-scopes", []string{oidc.ScopeOfflineAccess, oidc.ScopeOpenID, "pinniped:request-audience"}, "OpenID Connect scopes to request during login")
 	f.BoolVar(&flags.oidc.skipBrowser, "oidc-skip-browser", false, "During OpenID Connect login, skip opening the browser (just print the URL)")
 	f.StringVar(&flags.oidc.sessionCachePath, "oidc-session-cache", "", "Path to OpenID Connect session cache file")
+	f.StringVar(&flags.oidc.sessionEncryption, "oidc-session-cache-encryption", "", "Encryption of the OpenID Connect session cache file: 'none' or 'passphrase' (see 'pinniped login oidc --help')")
 	f.StringSliceVar(&flags.oidc.caBundlePaths, "oidc-ca-bundle", nil, "Path to TLS certificate authority bundle (PEM format, optional, can be repeated)")
 	f.BoolVar(&flags.oidc.debugSessionCache, "oidc-debug-session-cache", false, "Print debug logs related to the OpenID Connect session cache")
 	f.StringVar(&flags.oidc.requestAudience, "oidc-request-audience", "", "Request a token with an alternate audience using RFC8693 token exchange")
+	f.StringVar(&flags.oidc.upstreamIDP.name, "upstream-identity-provider-name", "", "The name of the upstream identity provider used during login with a Supervisor")
+	f.StringVar(&flags.oidc.upstreamIDP.idpType, "upstream-identity-provider-type", "", "The type of the upstream identity provider used during login with a Supervisor (e.g. 'oidc')")
 	f.StringVar(&flags.kubeconfigPath, "kubeconfig", os.Getenv("KUBECONFIG"), "Path to kubeconfig file")
 	f.StringVar(&flags.kubeconfigContextOverride, "kubeconfig-context", "", "Kubeconfig context name (default: current active context)")
+	f.StringVar(&flags.contextName, "context-name", "pinniped", "Name of the generated context and cluster, and of the generated user unless --user-name is specified")
+	f.StringVar(&flags.userName, "user-name", "", "Name of the generated user (default: --context-name)")
+	f.StringSliceVar(&flags.namespaces, "namespace", nil, "Default namespace of the generated context (optional, can be repeated to generate one context per namespace)")
+	f.StringVar(&flags.fleet.file, "fleet-file", "", "Path to a file which lists the kubeconfigs of many clusters, to generate one context per cluster")
+	f.StringVar(&flags.fleet.secretNamespace, "fleet-secret-namespace", "", "Namespace of the cluster of --kubeconfig which contains Secrets with the kubeconfigs of many clusters, to generate one context per cluster")
+	f.StringVar(&flags.fleet.secretSelector, "fleet-secret-selector", "", "Label selector of the Secrets in --fleet-secret-namespace (optional)")
+	f.StringVarP(&flags.outputFormat, "output", "o", "yaml", "Output format (e.g., 'yaml', 'json')")
+	f.StringVar(&flags.mergeInto, "merge-into", "", "Path to an existing kubeconfig file to add the generated entries to, instead of printing them")
+	f.BoolVar(&flags.mergeOverwrite, "merge-overwrite", false, "With --merge-into, replace existing entries which have the same names as generated entries")
+	f.StringVar(&flags.proxy.url, "proxy-url", "", "URL of the HTTP(S) proxy for the requests of the login command, which may include credentials (optional)")
+	f.StringVar(&flags.proxy.caBundlePath, "proxy-ca-bundle", "", "Path to the TLS certificate authority bundle of the proxy of the login command (PEM format, optional)")
+	f.StringVar(&flags.execAPIVersion, "exec-api-version", "v1beta1", "Version of the ExecCredential API used by the login command: 'v1beta1', or 'v1' (kubectl 1.22 and newer)")
 
 	mustMarkHidden(cmd, "oidc-debug-session-cache")
+	registerKubeconfigCompletions(cmd, deps, &flags)
 
 	mustMarkDeprecated(cmd, "concierge-namespace", "not needed anymore")
 	mustMarkHidden(cmd, "concierge-namespace")
@@ -131,25 +192,39 @@ func kubeconfigCommand(deps kubeconfigDeps) *cobra.Command {
 	return cmd
 }
 
-//nolint:funlen
 func runGetKubeconfig(out io.Writer, deps kubeconfigDeps, flags getKubeconfigParams) error {
 	// Validate api group suffix and immediately return an error if it is invalid.
-	if err := groupsuffix.Validate(flags.concierge.apiGroupSuffix); err != nil {
-		return fmt.Errorf("invalid api group suffix: %w", err)
+	if flags.concierge.apiGroupSuffix != "" {
+		if err := groupsuffix.Validate(flags.concierge.apiGroupSuffix); err != nil {
+			return fmt.Errorf("invalid api group suffix: %w", err)
+		}
 	}
-
-	execConfig := clientcmdapi.ExecConfig{
-		APIVersion: clientauthenticationv1beta1.SchemeGroupVersion.String(),
-		Args:       []string{},
-		Env:        []clientcmdapi.ExecEnvVar{},
+	if err := validateContextFlags(flags.contextName, flags.namespaces); err != nil {
+		return err
+	}
+	if err := validateOutputFlags(flags); err != nil {
+		return err
+	}
+	if err := flags.oidc.upstreamIDP.validate(); err != nil {
+		return err
+	}
+	if flags.oidc.listenPortRange != "" {
+		if flags.oidc.listenPort != 0 {
+			return fmt.Errorf("--oidc-listen-port and --oidc-listen-port-range cannot be used together")
+		}
+		if _, _, err := parsePortRange("oidc-listen-port-range", flags.oidc.listenPortRange); err != nil {
+			return err
+		}
+	}
+	// The environment variables are read by the login command itself, so only the flags are validated here.
+	if _, err := loadProxyConfig(flags.proxy, func(string) (string, bool) { return "", false }); err != nil {
+		return err
 	}
 
-	var err error
-	execConfig.Command, err = deps.getPathToSelf()
+	execPath, err := deps.getPathToSelf()
 	if err != nil {
 		return fmt.Errorf("could not determine the Pinniped executable path: %w", err)
 	}
-	execConfig.ProvideClusterInfo = true
 
 	oidcCABundle, err := loadCABundlePaths(flags.oidc.caBundlePaths)
 	if err != nil {
@@ -157,17 +232,66 @@ func runGetKubeconfig(out io.Writer, deps kubeconfigDeps, flags getKubeconfigPar
 	}
 
 	clientConfig := newClientConfig(flags.kubeconfigPath, flags.kubeconfigContextOverride)
+	if flags.fleet.file != "" || flags.fleet.secretNamespace != "" || flags.fleet.secretSelector != "" {
+		if flags.userName != "" {
+			return fmt.Errorf("--user-name cannot be used with a fleet, since each cluster gets its own user")
+		}
+		return runGetFleetKubeconfig(out, deps, flags, clientConfig, execPath, oidcCABundle)
+	}
+
+	cluster, execConfig, err := generateExecConfig(deps, flags, clientConfig, flags.kubeconfigContextOverride, execPath, oidcCABundle)
+	if err != nil {
+		return err
+	}
+	return writeKubeconfig(out, flags, newNamespacedExecKubeconfig(cluster, execConfig, flags.contextName, flags.userName, flags.namespaces))
+}
+
+// generateExecConfig returns the cluster of the given kubeconfig and the exec credential plugin config which logs in
+// to it. The flags are passed by value because the autodiscovered settings of one cluster must not leak into the
+// kubeconfig of the next cluster in fleet mode.
+//nolint:funlen
+func generateExecConfig(
+	deps kubeconfigDeps,
+	flags getKubeconfigParams,
+	clientConfig clientcmd.ClientConfig,
+	kubeconfigContextOverride string,
+	execPath string,
+	oidcCABundle string,
+) (*clientcmdapi.Cluster, *clientcmdapi.ExecConfig, error) {
+	execConfig := clientcmdapi.ExecConfig{
+		APIVersion:         clientauthenticationv1beta1.SchemeGroupVersion.Group + "/" + flags.execAPIVersion,
+		Command:            execPath,
+		Args:               []string{},
+		Env:                []clientcmdapi.ExecEnvVar{},
+		ProvideClusterInfo: true,
+	}
+
 	currentKubeConfig, err := clientConfig.RawConfig()
 	if err != nil {
-		return fmt.Errorf("could not load --kubeconfig: %w", err)
+		return nil, nil, fmt.Errorf("could not load --kubeconfig: %w", err)
 	}
-	cluster, err := copyCurrentClusterFromExistingKubeConfig(currentKubeConfig, flags.kubeconfigContextOverride)
+	cluster, err := copyCurrentClusterFromExistingKubeConfig(currentKubeConfig, kubeconfigContextOverride)
 	if err != nil {
-		return fmt.Errorf("could not load --kubeconfig/--kubeconfig-context: %w", err)
+		return nil, nil, fmt.Errorf("could not load --kubeconfig/--kubeconfig-context: %w", err)
+	}
+
+	if flags.concierge.apiGroupSuffix == "" {
+		if flags.concierge.disabled {
+			// The Concierge API is not used, so the suffix does not matter.
+			flags.concierge.apiGroupSuffix = "pinniped.dev"
+		} else {
+			discoveryClient, err := deps.getDiscovery(clientConfig)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not configure Kubernetes client: %w", err)
+			}
+			if flags.concierge.apiGroupSuffix, err = discoverAPIGroupSuffix(discoveryClient); err != nil {
+				return nil, nil, err
+			}
+		}
 	}
 	clientset, err := deps.getClientset(clientConfig, flags.concierge.apiGroupSuffix)
 	if err != nil {
-		return fmt.Errorf("could not configure Kubernetes client: %w", err)
+		return nil, nil, fmt.Errorf("could not configure Kubernetes client: %w", err)
 	}
 
 	if !flags.concierge.disabled {
@@ -177,17 +301,42 @@ func runGetKubeconfig(out io.Writer, deps kubeconfigDeps, flags getKubeconfigPar
 			flags.concierge.authenticatorName,
 		)
 		if err != nil {
-			return err
+			return nil, nil, err
+		}
+		proxyInfo, err := checkConciergeStrategies(clientset)
+		if err != nil {
+			return nil, nil, err
+		}
+		// When the impersonation proxy is the only working strategy, the kubeconfig must talk to the cluster
+		// through the proxy instead of talking to the API server directly.
+		if proxyInfo != nil {
+			caData, err := base64.StdEncoding.DecodeString(proxyInfo.CertificateAuthorityData)
+			if err != nil {
+				return nil, nil, fmt.Errorf("the impersonation proxy of the concierge has invalid certificateAuthorityData: %w", err)
+			}
+			cluster.Server = proxyInfo.Endpoint
+			cluster.CertificateAuthorityData = caData
 		}
 		if err := configureConcierge(authenticator, &flags, cluster, &oidcCABundle, &execConfig); err != nil {
-			return err
+			return nil, nil, err
 		}
+		if proxyInfo != nil {
+			execConfig.Args = append(execConfig.Args, "--concierge-use-impersonation-proxy")
+		}
+	}
+
+	// The proxy is used by both login commands, for the requests to the concierge as well as to the OIDC provider.
+	if flags.proxy.url != "" {
+		execConfig.Args = append(execConfig.Args, "--proxy-url="+flags.proxy.url)
+	}
+	if flags.proxy.caBundlePath != "" {
+		execConfig.Args = append(execConfig.Args, "--proxy-ca-bundle="+flags.proxy.caBundlePath)
 	}
 
 	// If one of the --static-* flags was passed, output a config that runs `pinniped login static`.
-	if flags.staticToken != "" || flags.staticTokenEnvName != "" {
-		if flags.staticToken != "" && flags.staticTokenEnvName != "" {
-			return fmt.Errorf("only one of --static-token and --static-token-env can be specified")
+	if flags.staticToken != "" || flags.staticTokenEnvName != "" || flags.staticTokenFile != "" {
+		if countNonEmpty(flags.staticToken, flags.staticTokenEnvName, flags.staticTokenFile) > 1 {
+			return nil, nil, fmt.Errorf("only one of --static-token, --static-token-env, and --static-token-file can be specified")
 		}
 		execConfig.Args = append([]string{"login", "static"}, execConfig.Args...)
 		if flags.staticToken != "" {
@@ -196,13 +345,16 @@ func runGetKubeconfig(out io.Writer, deps kubeconfigDeps, flags getKubeconfigPar
 		if flags.staticTokenEnvName != "" {
 			execConfig.Args = append(execConfig.Args, "--token-env="+flags.staticTokenEnvName)
 		}
-		return writeConfigAsYAML(out, newExecKubeconfig(cluster, &execConfig))
+		if flags.staticTokenFile != "" {
+			execConfig.Args = append(execConfig.Args, "--token-file="+flags.staticTokenFile)
+		}
+		return cluster, &execConfig, nil
 	}
 
 	// Otherwise continue to parse the OIDC-related flags and output a config that runs `pinniped login oidc`.
 	execConfig.Args = append([]string{"login", "oidc"}, execConfig.Args...)
 	if flags.oidc.issuer == "" {
-		return fmt.Errorf("could not autodiscover --oidc-issuer, and none was provided")
+		return nil, nil, fmt.Errorf("could not autodiscover --oidc-issuer, and none was provided")
 	}
 	execConfig.Args = append(execConfig.Args,
 		"--issuer="+flags.oidc.issuer,
@@ -215,38 +367,48 @@ func runGetKubeconfig(out io.Writer, deps kubeconfigDeps, flags getKubeconfigPar
 	if flags.oidc.listenPort != 0 {
 		execConfig.Args = append(execConfig.Args, "--listen-port="+strconv.Itoa(int(flags.oidc.listenPort)))
 	}
+	if flags.oidc.listenPortRange != "" {
+		execConfig.Args = append(execConfig.Args, "--listen-port-range="+flags.oidc.listenPortRange)
+	}
+	if flags.oidc.listenAddress != "" {
+		execConfig.Args = append(execConfig.Args, "--listen-address="+flags.oidc.listenAddress)
+	}
+	if flags.oidc.loginTimeout != 0 {
+		execConfig.Args = append(execConfig.Args, "--login-timeout="+flags.oidc.loginTimeout.String())
+	}
 	if oidcCABundle != "" {
 		execConfig.Args = append(execConfig.Args, "--ca-bundle-data="+base64.StdEncoding.EncodeToString([]byte(oidcCABundle)))
 	}
 	if flags.oidc.sessionCachePath != "" {
 		execConfig.Args = append(execConfig.Args, "--session-cache="+flags.oidc.sessionCachePath)
 	}
+	if flags.oidc.sessionEncryption != "" {
+		execConfig.Args = append(execConfig.Args, "--session-cache-encryption="+flags.oidc.sessionEncryption)
+	}
 	if flags.oidc.debugSessionCache {
 		execConfig.Args = append(execConfig.Args, "--debug-session-cache")
 	}
 	if flags.oidc.requestAudience != "" {
 		execConfig.Args = append(execConfig.Args, "--request-audience="+flags.oidc.requestAudience)
 	}
-	return writeConfigAsYAML(out, newExecKubeconfig(cluster, &execConfig))
+	if flags.oidc.upstreamIDP.name != "" {
+		execConfig.Args = append(execConfig.Args, "--upstream-identity-provider-name="+flags.oidc.upstreamIDP.name)
+	}
+	if flags.oidc.upstreamIDP.idpType != "" {
+		execConfig.Args = append(execConfig.Args, "--upstream-identity-provider-type="+flags.oidc.upstreamIDP.idpType)
+	}
+	return cluster, &execConfig, nil
 }
 
 func configureConcierge(authenticator metav1.Object, flags *getKubeconfigParams, v1Cluster *clientcmdapi.Cluster, oidcCABundle *string, execConfig *clientcmdapi.ExecConfig) error {
-	switch auth := authenticator.(type) {
-	case *conciergev1alpha1.WebhookAuthenticator:
-		// If the --concierge-authenticator-type/--concierge-authenticator-name flags were not set explicitly, set
-		// them to point at the discovered WebhookAuthenticator.
-		if flags.concierge.authenticatorType == "" && flags.concierge.authenticatorName == "" {
-			flags.concierge.authenticatorType = "webhook"
-			flags.concierge.authenticatorName = auth.Name
-		}
-	case *conciergev1alpha1.JWTAuthenticator:
-		// If the --concierge-authenticator-type/--concierge-authenticator-name flags were not set explicitly, set
-		// them to point at the discovered JWTAuthenticator.
-		if flags.concierge.authenticatorType == "" && flags.concierge.authenticatorName == "" {
-			flags.concierge.authenticatorType = "jwt"
-			flags.concierge.authenticatorName = auth.Name
-		}
+	// If the --concierge-authenticator-type/--concierge-authenticator-name flags were not set explicitly, set
+	// them to point at the discovered authenticator.
+	if flags.concierge.authenticatorType == "" && flags.concierge.authenticatorName == "" {
+		flags.concierge.authenticatorType = authenticatorType(authenticator)
+		flags.concierge.authenticatorName = authenticator.GetName()
+	}
 
+	if auth, ok := authenticator.(*conciergev1alpha1.JWTAuthenticator); ok {
 		// If the --oidc-issuer flag was not set explicitly, default it to the spec.issuer field of the JWTAuthenticator.
 		if flags.oidc.issuer == "" {
 			flags.oidc.issuer = auth.Spec.Issuer
@@ -280,6 +442,16 @@ func configureConcierge(authenticator metav1.Object, flags *getKubeconfigParams,
 	return nil
 }
 
+func countNonEmpty(values ...string) int {
+	count := 0
+	for _, v := range values {
+		if v != "" {
+			count++
+		}
+	}
+	return count
+}
+
 func loadCABundlePaths(paths []string) (string, error) {
 	if len(paths) == 0 {
 		return "", nil
@@ -296,15 +468,58 @@ func loadCABundlePaths(paths []string) (string, error) {
 }
 
 func newExecKubeconfig(cluster *clientcmdapi.Cluster, execConfig *clientcmdapi.ExecConfig) clientcmdapi.Config {
-	const name = "pinniped"
-	return clientcmdapi.Config{
-		Kind:           "Config",
-		APIVersion:     clientcmdapi.SchemeGroupVersion.Version,
-		Clusters:       map[string]*clientcmdapi.Cluster{name: cluster},
-		AuthInfos:      map[string]*clientcmdapi.AuthInfo{name: {Exec: execConfig}},
-		Contexts:       map[string]*clientcmdapi.Context{name: {Cluster: name, AuthInfo: name}},
-		CurrentContext: name,
+	return newNamespacedExecKubeconfig(cluster, execConfig, "pinniped", "", nil)
+}
+
+// newNamespacedExecKubeconfig returns a kubeconfig with a context for each of the namespaces, which all share the same
+// cluster and user. A single namespace (or none) uses the name as the context name, while multiple namespaces get
+// contexts named "<name>-<namespace>". The first context is the current context. The cluster is also named after
+// the name, as is the user unless userName is not empty.
+func newNamespacedExecKubeconfig(cluster *clientcmdapi.Cluster, execConfig *clientcmdapi.ExecConfig, name string, userName string, namespaces []string) clientcmdapi.Config {
+	if userName == "" {
+		userName = name
+	}
+	config := clientcmdapi.Config{
+		Kind:       "Config",
+		APIVersion: clientcmdapi.SchemeGroupVersion.Version,
+		Clusters:   map[string]*clientcmdapi.Cluster{name: cluster},
+		AuthInfos:  map[string]*clientcmdapi.AuthInfo{userName: {Exec: execConfig}},
+		Contexts:   map[string]*clientcmdapi.Context{},
+	}
+	if len(namespaces) <= 1 {
+		kubeContext := &clientcmdapi.Context{Cluster: name, AuthInfo: userName}
+		if len(namespaces) == 1 {
+			kubeContext.Namespace = namespaces[0]
+		}
+		config.Contexts[name] = kubeContext
+		config.CurrentContext = name
+		return config
+	}
+	for _, namespace := range namespaces {
+		contextName := name + "-" + namespace
+		config.Contexts[contextName] = &clientcmdapi.Context{Cluster: name, AuthInfo: userName, Namespace: namespace}
+		if config.CurrentContext == "" {
+			config.CurrentContext = contextName
+		}
 	}
+	return config
+}
+
+func validateContextFlags(contextName string, namespaces []string) error {
+	if contextName == "" {
+		return fmt.Errorf("--context-name must not be empty")
+	}
+	seen := make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid --namespace %q: %s", namespace, strings.Join(errs, ", "))
+		}
+		if seen[namespace] {
+			return fmt.Errorf("--namespace %q was specified more than once", namespace)
+		}
+		seen[namespace] = true
+	}
+	return nil
 }
 
 func lookupAuthenticator(clientset conciergeclientset.Interface, authType, authName string) (metav1.Object, error) {
@@ -318,36 +533,168 @@ func lookupAuthenticator(clientset conciergeclientset.Interface, authType, authN
 			return clientset.AuthenticationV1alpha1().WebhookAuthenticators().Get(ctx, authName, metav1.GetOptions{})
 		case "jwt":
 			return clientset.AuthenticationV1alpha1().JWTAuthenticators().Get(ctx, authName, metav1.GetOptions{})
+		case "cloudidentity":
+			return clientset.AuthenticationV1alpha1().CloudIdentityAuthenticators().Get(ctx, authName, metav1.GetOptions{})
+		case "statictoken":
+			return clientset.AuthenticationV1alpha1().StaticTokenAuthenticators().Get(ctx, authName, metav1.GetOptions{})
+		case "clientcertificate":
+			return clientset.AuthenticationV1alpha1().ClientCertificateAuthenticators().Get(ctx, authName, metav1.GetOptions{})
+		case "serviceaccount":
+			return clientset.AuthenticationV1alpha1().ServiceAccountAuthenticators().Get(ctx, authName, metav1.GetOptions{})
 		default:
-			return nil, fmt.Errorf(`invalid authenticator type %q, supported values are "webhook" and "jwt"`, authType)
+			return nil, fmt.Errorf(`invalid authenticator type %q, supported values are "webhook", "jwt", "cloudidentity", "statictoken", "clientcertificate", and "serviceaccount"`, authType)
 		}
 	}
 
 	// Otherwise list all the available authenticators and hope there's just a single one.
-
-	jwtAuths, err := clientset.AuthenticationV1alpha1().JWTAuthenticators().List(ctx, metav1.ListOptions{})
+	results, err := listAuthenticators(ctx, clientset)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list JWTAuthenticator objects for autodiscovery: %w", err)
+		return nil, err
 	}
-	webhooks, err := clientset.AuthenticationV1alpha1().WebhookAuthenticators().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list WebhookAuthenticator objects for autodiscovery: %w", err)
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no authenticators were found")
 	}
+	if len(results) > 1 {
+		return nil, fmt.Errorf("multiple authenticators were found, so the --concierge-authenticator-type/--concierge-authenticator-name flags must be specified")
+	}
+	return results[0], nil
+}
 
-	results := make([]metav1.Object, 0, len(jwtAuths.Items)+len(webhooks.Items))
+// listAuthenticators returns the authenticators of every type.
+func listAuthenticators(ctx context.Context, clientset conciergeclientset.Interface) ([]metav1.Object, error) {
+	client := clientset.AuthenticationV1alpha1()
+	var results []metav1.Object
+
+	jwtAuths, err := client.JWTAuthenticators().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list JWTAuthenticator objects for autodiscovery: %w", err)
+	}
 	for i := range jwtAuths.Items {
 		results = append(results, &jwtAuths.Items[i])
 	}
+	webhooks, err := client.WebhookAuthenticators().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WebhookAuthenticator objects for autodiscovery: %w", err)
+	}
 	for i := range webhooks.Items {
 		results = append(results, &webhooks.Items[i])
 	}
-	if len(results) == 0 {
-		return nil, fmt.Errorf("no authenticators were found")
+	cloudIdentities, err := client.CloudIdentityAuthenticators().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CloudIdentityAuthenticator objects for autodiscovery: %w", err)
 	}
-	if len(results) > 1 {
-		return nil, fmt.Errorf("multiple authenticators were found, so the --concierge-authenticator-type/--concierge-authenticator-name flags must be specified")
+	for i := range cloudIdentities.Items {
+		results = append(results, &cloudIdentities.Items[i])
 	}
-	return results[0], nil
+	staticTokens, err := client.StaticTokenAuthenticators().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list StaticTokenAuthenticator objects for autodiscovery: %w", err)
+	}
+	for i := range staticTokens.Items {
+		results = append(results, &staticTokens.Items[i])
+	}
+	clientCerts, err := client.ClientCertificateAuthenticators().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ClientCertificateAuthenticator objects for autodiscovery: %w", err)
+	}
+	for i := range clientCerts.Items {
+		results = append(results, &clientCerts.Items[i])
+	}
+	serviceAccounts, err := client.ServiceAccountAuthenticators().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ServiceAccountAuthenticator objects for autodiscovery: %w", err)
+	}
+	for i := range serviceAccounts.Items {
+		results = append(results, &serviceAccounts.Items[i])
+	}
+	return results, nil
+}
+
+// authenticatorType returns the value of the --concierge-authenticator-type flag for an authenticator.
+func authenticatorType(authenticator metav1.Object) string {
+	switch authenticator.(type) {
+	case *conciergev1alpha1.WebhookAuthenticator:
+		return "webhook"
+	case *conciergev1alpha1.JWTAuthenticator:
+		return "jwt"
+	case *conciergev1alpha1.CloudIdentityAuthenticator:
+		return "cloudidentity"
+	case *conciergev1alpha1.StaticTokenAuthenticator:
+		return "statictoken"
+	case *conciergev1alpha1.ClientCertificateAuthenticator:
+		return "clientcertificate"
+	case *conciergev1alpha1.ServiceAccountAuthenticator:
+		return "serviceaccount"
+	default:
+		return ""
+	}
+}
+
+// discoverAPIGroupSuffix returns the API group suffix of the Concierge which is installed on the cluster, by looking
+// for its login API group among the API groups which the cluster serves.
+func discoverAPIGroupSuffix(discoveryClient discovery.DiscoveryInterface) (string, error) {
+	groups, err := discoveryClient.ServerGroups()
+	if err != nil {
+		return "", fmt.Errorf("failed to list API groups to autodiscover --concierge-api-group-suffix: %w", err)
+	}
+
+	loginGroupPrefix := strings.TrimSuffix(loginv1alpha1.GroupName, "pinniped.dev")
+	var suffixes []string
+	for _, group := range groups.Groups {
+		if strings.HasPrefix(group.Name, loginGroupPrefix) {
+			suffixes = append(suffixes, strings.TrimPrefix(group.Name, loginGroupPrefix))
+		}
+	}
+	switch len(suffixes) {
+	case 0:
+		return "", fmt.Errorf("could not find the concierge API on the cluster to autodiscover --concierge-api-group-suffix (use --no-concierge if the concierge is not installed)")
+	case 1:
+		return suffixes[0], nil
+	default:
+		sort.Strings(suffixes)
+		return "", fmt.Errorf("multiple concierge installations were found (API group suffixes %s), so the --concierge-api-group-suffix flag must be specified", strings.Join(suffixes, ", "))
+	}
+}
+
+// checkConciergeStrategies returns an error when the CredentialIssuer reports that none of the strategies of the
+// Concierge are working, since a kubeconfig which uses the Concierge would fail to log in on every attempt. When the
+// impersonation proxy is the only working strategy, it returns the information needed to connect to the proxy.
+func checkConciergeStrategies(clientset conciergeclientset.Interface) (*configv1alpha1.ImpersonationProxyInfo, error) {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancelFunc()
+
+	credentialIssuers, err := clientset.ConfigV1alpha1().CredentialIssuers().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CredentialIssuer objects to check the status of the concierge: %w", err)
+	}
+
+	var problems []string
+	var proxyInfo *configv1alpha1.ImpersonationProxyInfo
+	for _, credentialIssuer := range credentialIssuers.Items {
+		for _, strategy := range credentialIssuer.Status.Strategies {
+			if strategy.Status != configv1alpha1.SuccessStrategyStatus {
+				problems = append(problems, fmt.Sprintf("%s: %s", strategy.Type, strategy.Message))
+				continue
+			}
+			if strategy.Type != configv1alpha1.ImpersonationProxyStrategyType {
+				return nil, nil
+			}
+			if proxyInfo == nil && strategy.ImpersonationProxyInfo != nil {
+				proxyInfo = strategy.ImpersonationProxyInfo
+			}
+		}
+	}
+	if proxyInfo != nil {
+		return proxyInfo, nil
+	}
+	// The strategies may not have been reported yet, e.g. right after installation, so don't refuse in that case.
+	if len(problems) == 0 {
+		return nil, nil
+	}
+	return nil, fmt.Errorf(
+		"the concierge has no working strategy for issuing cluster credentials, so the kubeconfig would not work until a cluster administrator fixes it (%s)",
+		strings.Join(problems, "; "),
+	)
 }
 
 func newClientConfig(kubeconfigPathOverride string, currentContextName string) clientcmd.ClientConfig {
@@ -360,7 +707,7 @@ func newClientConfig(kubeconfigPathOverride string, currentContextName string) c
 }
 
 func writeConfigAsYAML(out io.Writer, config clientcmdapi.Config) error {
-	output, err := clientcmd.Write(config)
+	output, err := marshalKubeconfigYAML(config, interactiveModes(config))
 	if err != nil {
 		return err
 	}
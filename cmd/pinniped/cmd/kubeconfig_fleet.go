@@ -0,0 +1,200 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/yaml"
+)
+
+type getKubeconfigFleetParams struct {
+	file            string
+	secretNamespace string
+	secretSelector  string
+}
+
+// fleetFile is the format of the --fleet-file flag.
+type fleetFile struct {
+	Clusters []struct {
+		// Name is the name of the context, cluster, and user of the cluster in the generated kubeconfig.
+		Name string `json:"name"`
+		// Kubeconfig is the path to an admin kubeconfig of the cluster, relative to the fleet file.
+		Kubeconfig string `json:"kubeconfig"`
+		// Context is the context of the kubeconfig to use (default: its current context).
+		Context string `json:"context,omitempty"`
+	} `json:"clusters"`
+}
+
+// fleetSecretKubeconfigKeys are the keys of the kubeconfig in the Secrets of --fleet-secret-namespace. The first one
+// is used by Cluster API, so its Secrets can be used as they are.
+//nolint: gochecknoglobals
+var fleetSecretKubeconfigKeys = []string{"value", "kubeconfig"}
+
+type fleetMember struct {
+	name                      string
+	clientConfig              clientcmd.ClientConfig
+	kubeconfigContextOverride string
+}
+
+// runGetFleetKubeconfig writes one kubeconfig with a context for each cluster of a fleet. The logins of all contexts
+// use the same session cache, so a single login to the Supervisor is enough to use all of the clusters.
+func runGetFleetKubeconfig(
+	out io.Writer,
+	deps kubeconfigDeps,
+	flags getKubeconfigParams,
+	managementClientConfig clientcmd.ClientConfig,
+	execPath string,
+	oidcCABundle string,
+) error {
+	var members []fleetMember
+	var err error
+	switch {
+	case flags.fleet.file != "" && flags.fleet.secretNamespace != "":
+		return fmt.Errorf("only one of --fleet-file and --fleet-secret-namespace can be specified")
+	case flags.fleet.secretSelector != "" && flags.fleet.secretNamespace == "":
+		return fmt.Errorf("--fleet-secret-selector requires --fleet-secret-namespace")
+	case flags.fleet.file != "":
+		members, err = loadFleetFile(flags.fleet.file)
+	default:
+		members, err = loadFleetSecrets(deps, managementClientConfig, flags.fleet.secretNamespace, flags.fleet.secretSelector)
+	}
+	if err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("the fleet does not contain any clusters")
+	}
+
+	configs := make([]clientcmdapi.Config, 0, len(members))
+	for _, member := range members {
+		cluster, execConfig, err := generateExecConfig(deps, flags, member.clientConfig, member.kubeconfigContextOverride, execPath, oidcCABundle)
+		if err != nil {
+			return fmt.Errorf("cluster %q: %w", member.name, err)
+		}
+		configs = append(configs, newNamespacedExecKubeconfig(cluster, execConfig, member.name, "", flags.namespaces))
+	}
+	return writeKubeconfig(out, flags, mergeKubeconfigs(configs))
+}
+
+func loadFleetFile(path string) ([]fleetMember, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read --fleet-file: %w", err)
+	}
+	var fleet fleetFile
+	if err := yaml.UnmarshalStrict(data, &fleet); err != nil {
+		return nil, fmt.Errorf("could not parse --fleet-file: %w", err)
+	}
+
+	members := make([]fleetMember, 0, len(fleet.Clusters))
+	for i, cluster := range fleet.Clusters {
+		if cluster.Kubeconfig == "" {
+			return nil, fmt.Errorf("invalid --fleet-file: cluster %d has no kubeconfig", i)
+		}
+		kubeconfigPath := cluster.Kubeconfig
+		if !filepath.IsAbs(kubeconfigPath) {
+			kubeconfigPath = filepath.Join(filepath.Dir(path), kubeconfigPath)
+		}
+		members = append(members, fleetMember{
+			name:                      cluster.Name,
+			clientConfig:              newClientConfig(kubeconfigPath, cluster.Context),
+			kubeconfigContextOverride: cluster.Context,
+		})
+	}
+	if err := validateFleetNames(members); err != nil {
+		return nil, fmt.Errorf("invalid --fleet-file: %w", err)
+	}
+	return members, nil
+}
+
+func loadFleetSecrets(deps kubeconfigDeps, managementClientConfig clientcmd.ClientConfig, namespace, selector string) ([]fleetMember, error) {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancelFunc()
+
+	kubeClient, err := deps.getKubeClient(managementClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not configure Kubernetes client: %w", err)
+	}
+	secrets, err := kubeClient.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the Secrets of the fleet: %w", err)
+	}
+
+	members := make([]fleetMember, 0, len(secrets.Items))
+	for _, secret := range secrets.Items {
+		var data []byte
+		for _, key := range fleetSecretKubeconfigKeys {
+			if data = secret.Data[key]; len(data) > 0 {
+				break
+			}
+		}
+		if len(data) == 0 {
+			// Secrets without a kubeconfig may match a broad selector, so skip them instead of failing.
+			continue
+		}
+		clientConfig, err := clientcmd.NewClientConfigFromBytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("the Secret %s/%s does not contain a valid kubeconfig: %w", namespace, secret.Name, err)
+		}
+		members = append(members, fleetMember{
+			name:         strings.TrimSuffix(secret.Name, "-kubeconfig"),
+			clientConfig: clientConfig,
+		})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].name < members[j].name })
+	if err := validateFleetNames(members); err != nil {
+		return nil, fmt.Errorf("invalid Secrets in --fleet-secret-namespace: %w", err)
+	}
+	return members, nil
+}
+
+func validateFleetNames(members []fleetMember) error {
+	seen := make(map[string]bool, len(members))
+	for _, member := range members {
+		if errs := validation.IsDNS1123Subdomain(member.name); len(errs) > 0 {
+			return fmt.Errorf("invalid cluster name %q: %s", member.name, strings.Join(errs, ", "))
+		}
+		if seen[member.name] {
+			return fmt.Errorf("cluster name %q is used more than once", member.name)
+		}
+		seen[member.name] = true
+	}
+	return nil
+}
+
+// mergeKubeconfigs merges kubeconfigs whose names do not overlap. The current context is the one of the first.
+func mergeKubeconfigs(configs []clientcmdapi.Config) clientcmdapi.Config {
+	merged := clientcmdapi.Config{
+		Clusters:  map[string]*clientcmdapi.Cluster{},
+		AuthInfos: map[string]*clientcmdapi.AuthInfo{},
+		Contexts:  map[string]*clientcmdapi.Context{},
+	}
+	for _, config := range configs {
+		for name, cluster := range config.Clusters {
+			merged.Clusters[name] = cluster
+		}
+		for name, authInfo := range config.AuthInfos {
+			merged.AuthInfos[name] = authInfo
+		}
+		for name, kubeContext := range config.Contexts {
+			merged.Contexts[name] = kubeContext
+		}
+		if merged.CurrentContext == "" {
+			merged.CurrentContext = config.CurrentContext
+		}
+	}
+	return merged
+}
@@ -0,0 +1,201 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	"go.pinniped.dev/internal/testutil"
+)
+
+func TestLoadFleetFile(t *testing.T) {
+	members, err := loadFleetFile("./testdata/fleet.yaml")
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+
+	require.Equal(t, "kind", members[0].name)
+	require.Equal(t, "https://fake-server-url-value", fleetMemberServer(t, members[0]))
+	require.Equal(t, "some-other-cluster", members[1].name)
+	require.Equal(t, "some-other-context", members[1].kubeconfigContextOverride)
+	require.Equal(t, "https://some-other-fake-server-url-value", fleetMemberServer(t, members[1]))
+
+	tmpdir := testutil.TempDir(t)
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "invalid YAML",
+			yaml:    "clusters: [",
+			wantErr: "could not parse --fleet-file: error converting YAML to JSON: yaml: line 1: did not find expected node content",
+		},
+		{
+			name:    "unknown field",
+			yaml:    "clusters: [{name: a, kubeconfig: a.yaml, server: https://example.com}]",
+			wantErr: `could not parse --fleet-file: error unmarshaling JSON: while decoding JSON: json: unknown field "server"`,
+		},
+		{
+			name:    "missing kubeconfig",
+			yaml:    "clusters: [{name: a}]",
+			wantErr: "invalid --fleet-file: cluster 0 has no kubeconfig",
+		},
+		{
+			name:    "invalid name",
+			yaml:    "clusters: [{name: Not_Valid, kubeconfig: a.yaml}]",
+			wantErr: `invalid --fleet-file: invalid cluster name "Not_Valid": a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`,
+		},
+		{
+			name:    "duplicate name",
+			yaml:    "clusters: [{name: a, kubeconfig: a.yaml}, {name: a, kubeconfig: b.yaml}]",
+			wantErr: `invalid --fleet-file: cluster name "a" is used more than once`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpdir, "fleet.yaml")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tt.yaml), 0600))
+			_, err := loadFleetFile(path)
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLoadFleetSecrets(t *testing.T) {
+	kubeconfig, err := ioutil.ReadFile("./testdata/kubeconfig.yaml")
+	require.NoError(t, err)
+
+	fleetSecret := func(name string, key string, labels map[string]string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "fleet", Labels: labels},
+			Data:       map[string][]byte{key: kubeconfig},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		selector      string
+		kubeObjects   []runtime.Object
+		kubeClientErr error
+		wantNames     []string
+		wantErr       string
+	}{
+		{
+			name: "Cluster API and plain kubeconfig Secrets",
+			kubeObjects: []runtime.Object{
+				fleetSecret("prod-kubeconfig", "value", nil),
+				fleetSecret("dev", "kubeconfig", nil),
+				fleetSecret("not-a-kubeconfig", "password", nil),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "other-namespace-kubeconfig", Namespace: "other"},
+					Data:       map[string][]byte{"value": kubeconfig},
+				},
+			},
+			wantNames: []string{"dev", "prod"},
+		},
+		{
+			name:     "label selector",
+			selector: "fleet=blue",
+			kubeObjects: []runtime.Object{
+				fleetSecret("prod-kubeconfig", "value", map[string]string{"fleet": "blue"}),
+				fleetSecret("dev-kubeconfig", "value", map[string]string{"fleet": "green"}),
+			},
+			wantNames: []string{"prod"},
+		},
+		{
+			name: "invalid kubeconfig",
+			kubeObjects: []runtime.Object{
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "prod-kubeconfig", Namespace: "fleet"},
+					Data:       map[string][]byte{"value": []byte("not a kubeconfig")},
+				},
+			},
+			wantErr: "the Secret fleet/prod-kubeconfig does not contain a valid kubeconfig: " +
+				"couldn't get version/kind; json parse error: json: cannot unmarshal string into Go value of type struct { APIVersion string \"json:\\\"apiVersion,omitempty\\\"\"; Kind string \"json:\\\"kind,omitempty\\\"\" }",
+		},
+		{
+			name: "duplicate names",
+			kubeObjects: []runtime.Object{
+				fleetSecret("prod-kubeconfig", "value", nil),
+				fleetSecret("prod", "kubeconfig", nil),
+			},
+			wantErr: `invalid Secrets in --fleet-secret-namespace: cluster name "prod" is used more than once`,
+		},
+		{
+			name:          "client creation failure",
+			kubeClientErr: fmt.Errorf("some kube error"),
+			wantErr:       "could not configure Kubernetes client: some kube error",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			deps := kubeconfigDeps{
+				getKubeClient: func(clientConfig clientcmd.ClientConfig) (kubernetes.Interface, error) {
+					if tt.kubeClientErr != nil {
+						return nil, tt.kubeClientErr
+					}
+					return kubernetesfake.NewSimpleClientset(tt.kubeObjects...), nil
+				},
+			}
+			members, err := loadFleetSecrets(deps, nil, "fleet", tt.selector)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			names := make([]string, 0, len(members))
+			for _, member := range members {
+				names = append(names, member.name)
+				require.Equal(t, "https://fake-server-url-value", fleetMemberServer(t, member))
+			}
+			require.Equal(t, tt.wantNames, names)
+		})
+	}
+}
+
+func TestMergeKubeconfigs(t *testing.T) {
+	execConfig := &clientcmdapi.ExecConfig{Command: "/path/to/pinniped"}
+	merged := mergeKubeconfigs([]clientcmdapi.Config{
+		newNamespacedExecKubeconfig(&clientcmdapi.Cluster{Server: "https://prod.example.com"}, execConfig, "prod", "", nil),
+		newNamespacedExecKubeconfig(&clientcmdapi.Cluster{Server: "https://dev.example.com"}, execConfig, "dev", "", []string{"a", "b"}),
+	})
+
+	require.Equal(t, "prod", merged.CurrentContext)
+	require.Equal(t, map[string]*clientcmdapi.Cluster{
+		"prod": {Server: "https://prod.example.com"},
+		"dev":  {Server: "https://dev.example.com"},
+	}, merged.Clusters)
+	require.Equal(t, map[string]*clientcmdapi.AuthInfo{
+		"prod": {Exec: execConfig},
+		"dev":  {Exec: execConfig},
+	}, merged.AuthInfos)
+	require.Equal(t, map[string]*clientcmdapi.Context{
+		"prod":  {Cluster: "prod", AuthInfo: "prod"},
+		"dev-a": {Cluster: "dev", AuthInfo: "dev", Namespace: "a"},
+		"dev-b": {Cluster: "dev", AuthInfo: "dev", Namespace: "b"},
+	}, merged.Contexts)
+}
+
+func fleetMemberServer(t *testing.T, member fleetMember) string {
+	t.Helper()
+	rawConfig, err := member.clientConfig.RawConfig()
+	require.NoError(t, err)
+	cluster, err := copyCurrentClusterFromExistingKubeConfig(rawConfig, member.kubeconfigContextOverride)
+	require.NoError(t, err)
+	return cluster.Server
+}
@@ -0,0 +1,262 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
+	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func validateOutputFlags(flags getKubeconfigParams) error {
+	switch flags.outputFormat {
+	case "yaml", "json":
+	default:
+		return fmt.Errorf("unknown output format: %q", flags.outputFormat)
+	}
+	if flags.mergeInto != "" && flags.outputFormat != "yaml" {
+		return fmt.Errorf("--output cannot be used with --merge-into, since the kubeconfig file is always written as YAML")
+	}
+	if flags.mergeOverwrite && flags.mergeInto == "" {
+		return fmt.Errorf("--merge-overwrite requires --merge-into")
+	}
+	switch flags.execAPIVersion {
+	case "v1beta1", "v1":
+	default:
+		return fmt.Errorf("invalid --exec-api-version %q (use v1beta1 or v1)", flags.execAPIVersion)
+	}
+	return nil
+}
+
+// writeKubeconfig prints the generated kubeconfig in the requested format, or merges it into the --merge-into file.
+func writeKubeconfig(out io.Writer, flags getKubeconfigParams, config clientcmdapi.Config) error {
+	switch {
+	case flags.mergeInto != "":
+		return mergeIntoKubeconfigFile(out, flags.mergeInto, config, flags.mergeOverwrite)
+	case flags.outputFormat == "json":
+		return writeConfigAsJSON(out, config)
+	default:
+		return writeConfigAsYAML(out, config)
+	}
+}
+
+func writeConfigAsJSON(out io.Writer, config clientcmdapi.Config) error {
+	output, err := marshalKubeconfigJSON(config, interactiveModes(config))
+	if err != nil {
+		return err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, output, "", "  "); err != nil {
+		return err
+	}
+	indented.WriteByte('\n')
+	if _, err := indented.WriteTo(out); err != nil {
+		return fmt.Errorf("could not write output: %w", err)
+	}
+	return nil
+}
+
+// interactiveModes returns the interactiveMode of the exec config of each user which needs one. The v1 ExecCredential
+// API requires it, but the clientcmd types of this version of client-go do not have the field yet, so it is added
+// to the serialized kubeconfig instead.
+func interactiveModes(config clientcmdapi.Config) map[string]string {
+	modes := map[string]string{}
+	for name, authInfo := range config.AuthInfos {
+		if authInfo.Exec != nil && authInfo.Exec.APIVersion == execCredentialV1 {
+			modes[name] = ifAvailableExecInteractiveMode
+		}
+	}
+	return modes
+}
+
+// marshalKubeconfigJSON serializes the kubeconfig like clientcmd does, and then sets the interactiveMode of the
+// exec config of each user in modes.
+func marshalKubeconfigJSON(config clientcmdapi.Config, modes map[string]string) ([]byte, error) {
+	converted, err := clientcmdlatest.Scheme.ConvertToVersion(&config, clientcmdv1.SchemeGroupVersion)
+	if err != nil {
+		return nil, err
+	}
+	output, err := json.Marshal(converted)
+	if err != nil || len(modes) == 0 {
+		return output, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, err
+	}
+	users, _ := raw["users"].([]interface{})
+	for _, namedUser := range users {
+		namedUser, _ := namedUser.(map[string]interface{})
+		name, _ := namedUser["name"].(string)
+		authInfo, _ := namedUser["user"].(map[string]interface{})
+		exec, _ := authInfo["exec"].(map[string]interface{})
+		if mode, ok := modes[name]; ok && exec != nil {
+			exec["interactiveMode"] = mode
+		}
+	}
+	return json.Marshal(raw)
+}
+
+func marshalKubeconfigYAML(config clientcmdapi.Config, modes map[string]string) ([]byte, error) {
+	if len(modes) == 0 {
+		return clientcmd.Write(config)
+	}
+	output, err := marshalKubeconfigJSON(config, modes)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.JSONToYAML(output)
+}
+
+// readInteractiveModes returns the interactiveMode of the exec config of each user in the kubeconfig file at path,
+// since clientcmd drops them when it loads the file.
+func readInteractiveModes(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var kubeconfig struct {
+		Users []struct {
+			Name string `json:"name"`
+			User struct {
+				Exec *struct {
+					InteractiveMode string `json:"interactiveMode"`
+				} `json:"exec"`
+			} `json:"user"`
+		} `json:"users"`
+	}
+	if err := yaml.Unmarshal(data, &kubeconfig); err != nil {
+		return nil, err
+	}
+	modes := map[string]string{}
+	for _, user := range kubeconfig.Users {
+		if user.User.Exec != nil && user.User.Exec.InteractiveMode != "" {
+			modes[user.Name] = user.User.Exec.InteractiveMode
+		}
+	}
+	return modes, nil
+}
+
+// mergeIntoKubeconfigFile adds the entries of the generated kubeconfig to the kubeconfig file at path, which is
+// created when it does not exist yet. The current context of the file is only changed when it has none.
+func mergeIntoKubeconfigFile(out io.Writer, path string, generated clientcmdapi.Config, overwrite bool) error {
+	existing, err := clientcmd.LoadFromFile(path)
+	modes := map[string]string{}
+	if err == nil {
+		modes, err = readInteractiveModes(path)
+	}
+	if os.IsNotExist(err) {
+		existing, err = clientcmdapi.NewConfig(), nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not load --merge-into: %w", err)
+	}
+	if err := mergeKubeconfigInto(existing, generated, overwrite); err != nil {
+		return fmt.Errorf("could not merge into %s: %w", path, err)
+	}
+
+	// The generated users replace any existing users of the same names, along with their interactive modes.
+	for name := range generated.AuthInfos {
+		delete(modes, name)
+	}
+	for name, mode := range interactiveModes(generated) {
+		modes[name] = mode
+	}
+	output, err := marshalKubeconfigYAML(*existing, modes)
+	if err != nil {
+		return fmt.Errorf("could not write --merge-into: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not write --merge-into: %w", err)
+	}
+	if err := ioutil.WriteFile(path, output, 0600); err != nil {
+		return fmt.Errorf("could not write --merge-into: %w", err)
+	}
+
+	contextNames := make([]string, 0, len(generated.Contexts))
+	for name := range generated.Contexts {
+		contextNames = append(contextNames, name)
+	}
+	sort.Strings(contextNames)
+	_, err = fmt.Fprintf(out, "Merged context(s) %s into %s\n", strings.Join(contextNames, ", "), path)
+	return err
+}
+
+// mergeKubeconfigInto adds the clusters, users, and contexts of generated to existing. It fails without changing
+// existing when any of them has the name of a different existing entry, unless overwrite is true. Entries which are
+// identical to existing entries are not conflicts, so that merging the same kubeconfig again succeeds.
+func mergeKubeconfigInto(existing *clientcmdapi.Config, generated clientcmdapi.Config, overwrite bool) error {
+	var conflicts []string
+	for name, cluster := range generated.Clusters {
+		if current, ok := existing.Clusters[name]; ok && !sameEntry(current, cluster) {
+			conflicts = append(conflicts, fmt.Sprintf("cluster %q", name))
+		}
+	}
+	for name, authInfo := range generated.AuthInfos {
+		if current, ok := existing.AuthInfos[name]; ok && !sameEntry(current, authInfo) {
+			conflicts = append(conflicts, fmt.Sprintf("user %q", name))
+		}
+	}
+	for name, kubeContext := range generated.Contexts {
+		if current, ok := existing.Contexts[name]; ok && !sameEntry(current, kubeContext) {
+			conflicts = append(conflicts, fmt.Sprintf("context %q", name))
+		}
+	}
+	if len(conflicts) > 0 && !overwrite {
+		sort.Strings(conflicts)
+		return fmt.Errorf("the kubeconfig already has a different %s (use --merge-overwrite to replace them, or --context-name and --user-name to choose other names)", strings.Join(conflicts, ", "))
+	}
+
+	for name, cluster := range generated.Clusters {
+		existing.Clusters[name] = cluster
+	}
+	for name, authInfo := range generated.AuthInfos {
+		existing.AuthInfos[name] = authInfo
+	}
+	for name, kubeContext := range generated.Contexts {
+		existing.Contexts[name] = kubeContext
+	}
+	if existing.CurrentContext == "" {
+		existing.CurrentContext = generated.CurrentContext
+	}
+	return nil
+}
+
+// sameEntry compares two entries of a kubeconfig, ignoring which file they were loaded from.
+func sameEntry(existing, generated interface{}) bool {
+	return apiequality.Semantic.DeepEqual(withoutOrigin(existing), withoutOrigin(generated))
+}
+
+func withoutOrigin(entry interface{}) interface{} {
+	switch entry := entry.(type) {
+	case *clientcmdapi.Cluster:
+		copied := *entry
+		copied.LocationOfOrigin = ""
+		return &copied
+	case *clientcmdapi.AuthInfo:
+		copied := *entry
+		copied.LocationOfOrigin = ""
+		return &copied
+	case *clientcmdapi.Context:
+		copied := *entry
+		copied.LocationOfOrigin = ""
+		return &copied
+	default:
+		return entry
+	}
+}
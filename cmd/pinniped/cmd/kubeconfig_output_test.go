@@ -0,0 +1,219 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	"go.pinniped.dev/internal/here"
+)
+
+func TestMergeKubeconfigInto(t *testing.T) {
+	execConfig := &clientcmdapi.ExecConfig{Command: "/path/to/pinniped", APIVersion: "client.authentication.k8s.io/v1beta1"}
+	generated := newNamespacedExecKubeconfig(&clientcmdapi.Cluster{Server: "https://fake-server-url-value"}, execConfig, "pinniped", "", nil)
+
+	tests := []struct {
+		name               string
+		contextName        string
+		overwrite          bool
+		wantErr            string
+		wantCurrentContext string
+	}{
+		{
+			name:               "new names",
+			contextName:        "pinniped",
+			wantCurrentContext: "kind-kind",
+		},
+		{
+			name:        "conflicting names",
+			contextName: "kind-kind",
+			wantErr:     `the kubeconfig already has a different cluster "kind-kind", user "kind-kind" (use --merge-overwrite to replace them, or --context-name and --user-name to choose other names)`,
+		},
+		{
+			name:               "conflicting names with overwrite",
+			contextName:        "kind-kind",
+			overwrite:          true,
+			wantCurrentContext: "kind-kind",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			existing, err := clientcmd.LoadFromFile("./testdata/kubeconfig.yaml")
+			require.NoError(t, err)
+			generated := newNamespacedExecKubeconfig(&clientcmdapi.Cluster{Server: "https://pinniped.example.com"}, execConfig, tt.contextName, "", nil)
+
+			err = mergeKubeconfigInto(existing, generated, tt.overwrite)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Equal(t, "https://fake-server-url-value", existing.Clusters["kind-kind"].Server)
+				require.Nil(t, existing.AuthInfos["kind-kind"].Exec)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantCurrentContext, existing.CurrentContext)
+			require.Equal(t, "https://pinniped.example.com", existing.Clusters[tt.contextName].Server)
+			require.Equal(t, execConfig, existing.AuthInfos[tt.contextName].Exec)
+			require.Equal(t, &clientcmdapi.Context{Cluster: tt.contextName, AuthInfo: tt.contextName}, existing.Contexts[tt.contextName])
+			require.Contains(t, existing.Contexts, "some-other-context")
+		})
+	}
+
+	t.Run("identical entries", func(t *testing.T) {
+		existing := clientcmdapi.NewConfig()
+		require.NoError(t, mergeKubeconfigInto(existing, generated, false))
+		require.Equal(t, "pinniped", existing.CurrentContext)
+
+		// Merging the same entries again is not a conflict, even when they were loaded from a file.
+		existing.Clusters["pinniped"].LocationOfOrigin = "/some/kubeconfig"
+		require.NoError(t, mergeKubeconfigInto(existing, generated, false))
+	})
+}
+
+func TestWriteConfigAsJSON(t *testing.T) {
+	execConfig := &clientcmdapi.ExecConfig{
+		Command:    "/path/to/pinniped",
+		Args:       []string{"login", "static", "--token=test-token"},
+		APIVersion: "client.authentication.k8s.io/v1beta1",
+	}
+	config := newNamespacedExecKubeconfig(&clientcmdapi.Cluster{Server: "https://pinniped.example.com"}, execConfig, "pinniped", "some-user", nil)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeConfigAsJSON(&buf, config))
+	require.Equal(t, here.Doc(`
+		{
+		  "kind": "Config",
+		  "apiVersion": "v1",
+		  "preferences": {},
+		  "clusters": [
+		    {
+		      "name": "pinniped",
+		      "cluster": {
+		        "server": "https://pinniped.example.com"
+		      }
+		    }
+		  ],
+		  "users": [
+		    {
+		      "name": "some-user",
+		      "user": {
+		        "exec": {
+		          "command": "/path/to/pinniped",
+		          "args": [
+		            "login",
+		            "static",
+		            "--token=test-token"
+		          ],
+		          "env": null,
+		          "apiVersion": "client.authentication.k8s.io/v1beta1",
+		          "provideClusterInfo": false
+		        }
+		      }
+		    }
+		  ],
+		  "contexts": [
+		    {
+		      "name": "pinniped",
+		      "context": {
+		        "cluster": "pinniped",
+		        "user": "some-user"
+		      }
+		    }
+		  ],
+		  "current-context": "pinniped"
+		}
+	`), buf.String())
+}
+
+func TestInteractiveModes(t *testing.T) {
+	execConfig := &clientcmdapi.ExecConfig{
+		Command:    "/path/to/pinniped",
+		Args:       []string{"login", "static", "--token=test-token"},
+		APIVersion: "client.authentication.k8s.io/v1",
+	}
+	config := newNamespacedExecKubeconfig(&clientcmdapi.Cluster{Server: "https://pinniped.example.com"}, execConfig, "pinniped", "", nil)
+
+	t.Run("yaml", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeConfigAsYAML(&buf, config))
+		require.Equal(t, here.Doc(`
+			apiVersion: v1
+			clusters:
+			- cluster:
+			    server: https://pinniped.example.com
+			  name: pinniped
+			contexts:
+			- context:
+			    cluster: pinniped
+			    user: pinniped
+			  name: pinniped
+			current-context: pinniped
+			kind: Config
+			preferences: {}
+			users:
+			- name: pinniped
+			  user:
+			    exec:
+			      apiVersion: client.authentication.k8s.io/v1
+			      args:
+			      - login
+			      - static
+			      - --token=test-token
+			      command: /path/to/pinniped
+			      env: null
+			      interactiveMode: IfAvailable
+			      provideClusterInfo: false
+		`), buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeConfigAsJSON(&buf, config))
+		require.Contains(t, buf.String(), `"interactiveMode": "IfAvailable"`)
+	})
+
+	t.Run("merge keeps the interactive modes of existing users", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "kubeconfig.yaml")
+		require.NoError(t, ioutil.WriteFile(path, []byte(here.Doc(`
+			apiVersion: v1
+			kind: Config
+			clusters:
+			- name: other
+			  cluster:
+			    server: https://other.example.com
+			contexts:
+			- name: other
+			  context:
+			    cluster: other
+			    user: other
+			current-context: other
+			users:
+			- name: other
+			  user:
+			    exec:
+			      apiVersion: client.authentication.k8s.io/v1
+			      command: /path/to/other
+			      interactiveMode: Never
+		`)), 0600))
+
+		var buf bytes.Buffer
+		require.NoError(t, mergeIntoKubeconfigFile(&buf, path, config, false))
+		require.Equal(t, "Merged context(s) pinniped into "+path+"\n", buf.String())
+
+		modes, err := readInteractiveModes(path)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"other": "Never", "pinniped": "IfAvailable"}, modes)
+		merged, err := clientcmd.LoadFromFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "other", merged.CurrentContext)
+		require.Equal(t, execConfig.Args, merged.AuthInfos["pinniped"].Exec.Args)
+	})
+}
@@ -14,6 +14,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
@@ -22,6 +24,11 @@ import (
 	clientauthv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
 	"k8s.io/klog/v2/klogr"
 
+	"go.pinniped.dev/internal/browser"
+	"go.pinniped.dev/internal/execcredcache"
+	"go.pinniped.dev/internal/keychain"
+	supervisoroidc "go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/timeformat"
 	"go.pinniped.dev/pkg/conciergeclient"
 	"go.pinniped.dev/pkg/oidcclient"
 	"go.pinniped.dev/pkg/oidcclient/filesession"
@@ -36,6 +43,8 @@ func init() {
 type oidcLoginCommandDeps struct {
 	login         func(string, string, ...oidcclient.Option) (*oidctypes.Token, error)
 	exchangeToken func(context.Context, *conciergeclient.Client, string) (*clientauthv1beta1.ExecCredential, error)
+	lookupEnv     func(string) (string, bool)
+	now           func() time.Time
 }
 
 func oidcLoginCommandRealDeps() oidcLoginCommandDeps {
@@ -44,18 +53,54 @@ func oidcLoginCommandRealDeps() oidcLoginCommandDeps {
 		exchangeToken: func(ctx context.Context, client *conciergeclient.Client, token string) (*clientauthv1beta1.ExecCredential, error) {
 			return client.ExchangeToken(ctx, token)
 		},
+		lookupEnv: os.LookupEnv,
+		now:       time.Now,
 	}
 }
 
+// reauthRequiredHint is printed to stderr when the session cannot be refreshed. The RFC3339 time comes first and the
+// rest of the format is stable, so it can be matched by scripts. The parentheses hold the same time for people.
+const reauthRequiredHint = "Pinniped: interactive login will be required after %s (%s, %s) because the session cannot be refreshed\n"
+
+// staticAdminPasswordEnvVarName is the environment variable from which the static admin password is read, so that
+// it never needs to appear on the command line or in a kubeconfig.
+const staticAdminPasswordEnvVarName = "PINNIPED_STATIC_ADMIN_PASSWORD"
+
+// usernameEnvVarName and passwordEnvVarName are the environment variables from which the credentials of a
+// non-interactive login are read, e.g. in CI pipelines where nobody can log in with a browser. The kubeconfig decides
+// which issuer the exec plugin talks to, so the credentials are only sent to the issuer in passwordIssuerEnvVarName.
+const (
+	usernameEnvVarName       = "PINNIPED_USERNAME"
+	passwordEnvVarName       = "PINNIPED_PASSWORD"
+	passwordIssuerEnvVarName = "PINNIPED_PASSWORD_ISSUER"
+)
+
+// sessionCachePassphraseEnvVarName is the environment variable from which the passphrase of an encrypted session
+// cache is read, for the same reason.
+const sessionCachePassphraseEnvVarName = "PINNIPED_SESSION_CACHE_PASSPHRASE"
+
+// sessionCacheEncryptionUsage is the usage of the --session-cache-encryption flags.
+const sessionCacheEncryptionUsage = "Encrypt the session cache file with a key derived from $" + sessionCachePassphraseEnvVarName +
+	" ('passphrase'), or do not encrypt it ('none')"
+
 type oidcLoginFlags struct {
 	issuer                     string
 	clientID                   string
 	listenPort                 uint16
+	listenPortRange            string
+	listenAddress              string
+	loginTimeout               time.Duration
 	scopes                     []string
 	skipBrowser                bool
+	browserCommand             string
+	qrCode                     bool
 	sessionCachePath           string
+	sessionCacheKeychain       bool
+	sessionCacheEncryption     string
+	credentialCachePath        string
 	caBundlePaths              []string
 	caBundleData               []string
+	proxy                      proxyFlags
 	debugSessionCache          bool
 	requestAudience            string
 	conciergeEnabled           bool
@@ -64,6 +109,25 @@ type oidcLoginFlags struct {
 	conciergeEndpoint          string
 	conciergeCABundle          string
 	conciergeAPIGroupSuffix    string
+	conciergeUseProxy          bool
+	staticAdminUsername        string
+	grantType                  string
+	upstreamIdentityProvider   upstreamIdentityProviderFlags
+}
+
+// upstreamIdentityProviderFlags select one of the upstream identity providers of a Supervisor.
+type upstreamIdentityProviderFlags struct {
+	name    string
+	idpType string
+}
+
+func (f upstreamIdentityProviderFlags) validate() error {
+	switch f.idpType {
+	case "", supervisoroidc.UpstreamIDPTypeOIDC:
+		return nil
+	default:
+		return fmt.Errorf("invalid upstream identity provider type %q (only %q is supported)", f.idpType, supervisoroidc.UpstreamIDPTypeOIDC)
+	}
 }
 
 func oidcLoginCommand(deps oidcLoginCommandDeps) *cobra.Command {
@@ -80,11 +144,20 @@ func oidcLoginCommand(deps oidcLoginCommandDeps) *cobra.Command {
 	cmd.Flags().StringVar(&flags.issuer, "issuer", "", "OpenID Connect issuer URL")
 	cmd.Flags().StringVar(&flags.clientID, "client-id", "pinniped-cli", "OpenID Connect client ID")
 	cmd.Flags().Uint16Var(&flags.listenPort, "listen-port", 0, "TCP port for localhost listener (authorization code flow only)")
+	cmd.Flags().StringVar(&flags.listenPortRange, "listen-port-range", "", "Range of TCP ports to try for localhost listener, e.g. '49152-49162' (authorization code flow only)")
+	cmd.Flags().StringVar(&flags.listenAddress, "listen-address", "", "Loopback address for localhost listener, e.g. '127.0.0.1' or '::1' (authorization code flow only, default: localhost)")
+	cmd.Flags().DurationVar(&flags.loginTimeout, "login-timeout", 0, "Overall time allowed for a login, including any interaction with the user (default: 90m)")
 	cmd.Flags().StringSliceVar(&flags.scopes, "scopes", []string{oidc.ScopeOfflineAccess, oidc.ScopeOpenID, "pinniped:request-audience"}, "OIDC scopes to request during login")
 	cmd.Flags().BoolVar(&flags.skipBrowser, "skip-browser", false, "Skip opening the browser (just print the URL)")
+	cmd.Flags().StringVar(&flags.browserCommand, "browser-command", "", "Command to open the login URL with, which is added as its last argument or replaces '%s' in its arguments (default: the default browser, also in WSL)")
+	cmd.Flags().BoolVar(&flags.qrCode, "qr-code", false, "Also draw the login URL as a QR code, to log in with a phone (--grant-type device or manual only)")
 	cmd.Flags().StringVar(&flags.sessionCachePath, "session-cache", filepath.Join(mustGetConfigDir(), "sessions.yaml"), "Path to session cache file")
+	cmd.Flags().BoolVar(&flags.sessionCacheKeychain, "session-cache-keychain", true, "Store refresh tokens in the OS keychain instead of the session cache file, when a keychain is available")
+	cmd.Flags().StringVar(&flags.sessionCacheEncryption, "session-cache-encryption", "none", sessionCacheEncryptionUsage)
+	cmd.Flags().StringVar(&flags.credentialCachePath, "credential-cache", filepath.Join(mustGetConfigDir(), "credentials.yaml"), credentialCacheUsage)
 	cmd.Flags().StringSliceVar(&flags.caBundlePaths, "ca-bundle", nil, "Path to TLS certificate authority bundle (PEM format, optional, can be repeated)")
 	cmd.Flags().StringSliceVar(&flags.caBundleData, "ca-bundle-data", nil, "Base64 endcoded TLS certificate authority bundle (base64 encoded PEM format, optional, can be repeated)")
+	addProxyFlags(cmd.Flags(), &flags.proxy)
 	cmd.Flags().BoolVar(&flags.debugSessionCache, "debug-session-cache", false, "Print debug logs related to the session cache")
 	cmd.Flags().StringVar(&flags.requestAudience, "request-audience", "", "Request a token with an alternate audience using RFC8693 token exchange")
 	cmd.Flags().BoolVar(&flags.conciergeEnabled, "enable-concierge", false, "Exchange the OIDC ID token with the Pinniped concierge during login")
@@ -94,6 +167,11 @@ func oidcLoginCommand(deps oidcLoginCommandDeps) *cobra.Command {
 	cmd.Flags().StringVar(&flags.conciergeEndpoint, "concierge-endpoint", "", "API base for the Pinniped concierge endpoint")
 	cmd.Flags().StringVar(&flags.conciergeCABundle, "concierge-ca-bundle-data", "", "CA bundle to use when connecting to the concierge")
 	cmd.Flags().StringVar(&flags.conciergeAPIGroupSuffix, "concierge-api-group-suffix", "pinniped.dev", "Concierge API group suffix")
+	cmd.Flags().BoolVar(&flags.conciergeUseProxy, "concierge-use-impersonation-proxy", false, "Whether the concierge cluster uses an impersonation proxy")
+	cmd.Flags().StringVar(&flags.staticAdminUsername, "static-admin-username", "", "Log in as this Supervisor static admin user, with the password from $"+staticAdminPasswordEnvVarName+" (bootstrapping only)")
+	cmd.Flags().StringVar(&flags.upstreamIdentityProvider.name, "upstream-identity-provider-name", "", "The name of the upstream identity provider used during login with a Supervisor")
+	cmd.Flags().StringVar(&flags.upstreamIdentityProvider.idpType, "upstream-identity-provider-type", "", "The type of the upstream identity provider used during login with a Supervisor (e.g. 'oidc')")
+	cmd.Flags().StringVar(&flags.grantType, "grant-type", "authcode", "OAuth grant type to log in with: 'authcode' opens a browser on this machine, 'device' prints a code to enter on any other device, 'manual' prints a URL to open on any other device and reads the code which it shows")
 
 	mustMarkHidden(cmd, "debug-session-cache")
 	mustMarkRequired(cmd, "issuer")
@@ -106,6 +184,15 @@ func oidcLoginCommand(deps oidcLoginCommandDeps) *cobra.Command {
 }
 
 func runOIDCLogin(cmd *cobra.Command, deps oidcLoginCommandDeps, flags oidcLoginFlags) error {
+	apiVersion, err := execCredentialAPIVersion(deps.lookupEnv)
+	if err != nil {
+		return err
+	}
+	proxy, err := loadProxyConfig(flags.proxy, deps.lookupEnv)
+	if err != nil {
+		return err
+	}
+
 	// Initialize the session cache.
 	var sessionOptions []filesession.Option
 
@@ -116,6 +203,14 @@ func runOIDCLogin(cmd *cobra.Command, deps oidcLoginCommandDeps, flags oidcLogin
 			logger.Error(err, "error during session cache operation")
 		}))
 	}
+	sessionOptions = append(sessionOptions, keychainSessionOptions(flags.sessionCacheKeychain)...)
+	encryptionSecret, err := sessionCacheSecret(flags.sessionCacheEncryption, deps.lookupEnv)
+	if err != nil {
+		return err
+	}
+	if encryptionSecret != nil {
+		sessionOptions = append(sessionOptions, filesession.WithEncryption(encryptionSecret))
+	}
 	sessionCache := filesession.New(flags.sessionCachePath, sessionOptions...)
 
 	// Initialize the login handler.
@@ -125,38 +220,112 @@ func runOIDCLogin(cmd *cobra.Command, deps oidcLoginCommandDeps, flags oidcLogin
 		oidcclient.WithSessionCache(sessionCache),
 	}
 
+	if flags.listenPort != 0 && flags.listenPortRange != "" {
+		return fmt.Errorf("--listen-port and --listen-port-range cannot be used together")
+	}
 	if flags.listenPort != 0 {
 		opts = append(opts, oidcclient.WithListenPort(flags.listenPort))
 	}
+	if flags.listenPortRange != "" {
+		first, last, err := parsePortRange("listen-port-range", flags.listenPortRange)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, oidcclient.WithListenPortRange(first, last))
+	}
+	if flags.listenAddress != "" {
+		opts = append(opts, oidcclient.WithListenAddress(flags.listenAddress))
+	}
+	if flags.loginTimeout != 0 {
+		opts = append(opts, oidcclient.WithLoginTimeout(flags.loginTimeout))
+	}
 
 	if flags.requestAudience != "" {
 		opts = append(opts, oidcclient.WithRequestAudience(flags.requestAudience))
 	}
 
+	if err := flags.upstreamIdentityProvider.validate(); err != nil {
+		return err
+	}
+	if flags.upstreamIdentityProvider.name != "" || flags.upstreamIdentityProvider.idpType != "" {
+		opts = append(opts, oidcclient.WithUpstreamIdentityProvider(flags.upstreamIdentityProvider.name, flags.upstreamIdentityProvider.idpType))
+	}
+
+	switch flags.grantType {
+	case "authcode":
+	case "device":
+		// The verification URL goes to stderr, since stdout is read by kubectl.
+		opts = append(opts, oidcclient.WithDeviceFlow(cmd.ErrOrStderr()))
+	case "manual":
+		opts = append(opts, oidcclient.WithManualCodeEntry(cmd.InOrStdin(), cmd.ErrOrStderr()))
+	default:
+		return fmt.Errorf("invalid --grant-type %q (use authcode, device, or manual)", flags.grantType)
+	}
+	if flags.qrCode {
+		// The authorization code flow redirects to a listener on this machine, which a phone cannot reach.
+		if flags.grantType == "authcode" {
+			return fmt.Errorf("--qr-code requires --grant-type device or manual")
+		}
+		opts = append(opts, oidcclient.WithQRCode())
+	}
+
+	if flags.staticAdminUsername != "" {
+		password, ok := deps.lookupEnv(staticAdminPasswordEnvVarName)
+		if !ok || password == "" {
+			return fmt.Errorf("--static-admin-username requires the %s environment variable to be set", staticAdminPasswordEnvVarName)
+		}
+		opts = append(opts, oidcclient.WithStaticAdminCredentials(flags.staticAdminUsername, password))
+	}
+
+	passwordLogin, err := passwordLoginOption(deps.lookupEnv, flags.issuer)
+	if err != nil {
+		return err
+	}
+	if passwordLogin != nil && flags.staticAdminUsername == "" {
+		opts = append(opts, passwordLogin)
+	}
+
 	var concierge *conciergeclient.Client
 	if flags.conciergeEnabled {
-		var err error
-		concierge, err = conciergeclient.New(
+		conciergeOpts := []conciergeclient.Option{
 			conciergeclient.WithEndpoint(flags.conciergeEndpoint),
 			conciergeclient.WithBase64CABundle(flags.conciergeCABundle),
 			conciergeclient.WithAuthenticator(flags.conciergeAuthenticatorType, flags.conciergeAuthenticatorName),
 			conciergeclient.WithAPIGroupSuffix(flags.conciergeAPIGroupSuffix),
-		)
+		}
+		if flags.conciergeUseProxy {
+			conciergeOpts = append(conciergeOpts, conciergeclient.WithImpersonationProxy())
+		}
+		if proxy.configured() {
+			conciergeOpts = append(conciergeOpts, conciergeclient.WithProxy(proxy.url, string(proxy.caBundle)))
+		}
+		var err error
+		concierge, err = conciergeclient.New(conciergeOpts...)
 		if err != nil {
 			return fmt.Errorf("invalid concierge parameters: %w", err)
 		}
 	}
 
 	// --skip-browser replaces the default "browser open" function with one that prints to stderr.
+	if flags.skipBrowser && flags.browserCommand != "" {
+		return fmt.Errorf("--skip-browser and --browser-command cannot be used together")
+	}
 	if flags.skipBrowser {
 		opts = append(opts, oidcclient.WithBrowserOpen(func(url string) error {
 			cmd.PrintErr("Please log in: ", url, "\n")
 			return nil
 		}))
 	}
+	if flags.browserCommand != "" {
+		openURL, err := browser.Command(flags.browserCommand)
+		if err != nil {
+			return fmt.Errorf("invalid --browser-command: %w", err)
+		}
+		opts = append(opts, oidcclient.WithBrowserOpen(openURL))
+	}
 
-	if len(flags.caBundlePaths) > 0 || len(flags.caBundleData) > 0 {
-		client, err := makeClient(flags.caBundlePaths, flags.caBundleData)
+	if len(flags.caBundlePaths) > 0 || len(flags.caBundleData) > 0 || proxy.configured() {
+		client, err := makeClient(flags.caBundlePaths, flags.caBundleData, proxy)
 		if err != nil {
 			return err
 		}
@@ -175,15 +344,145 @@ func runOIDCLogin(cmd *cobra.Command, deps oidcLoginCommandDeps, flags oidcLogin
 	defer cancel()
 
 	if concierge != nil {
-		cred, err = deps.exchangeToken(ctx, concierge, token.IDToken.Token)
-		if err != nil {
-			return fmt.Errorf("could not complete concierge credential exchange: %w", err)
+		// A credential from an earlier exchange is reused for the same user until it is about to expire. The ID token
+		// usually comes from the session cache, so this needs no requests at all.
+		identity := idTokenIdentity(token)
+		var credentialCache *execcredcache.Cache
+		if identity != nil {
+			credentialCache = newCredentialCache(cmd, flags.credentialCachePath, encryptionSecret)
+		}
+		cacheKey := credentialCacheKey{
+			ConciergeEndpoint:          flags.conciergeEndpoint,
+			ConciergeAPIGroupSuffix:    flags.conciergeAPIGroupSuffix,
+			ConciergeAuthenticatorType: flags.conciergeAuthenticatorType,
+			ConciergeAuthenticatorName: flags.conciergeAuthenticatorName,
+			ConciergeUseProxy:          flags.conciergeUseProxy,
+			Identity:                   identity,
+		}
+		var cachedCred *clientauthv1beta1.ExecCredential
+		if credentialCache != nil {
+			cachedCred = credentialCache.Get(cacheKey)
+		}
+		if cachedCred != nil {
+			cred = cachedCred
+		} else {
+			cred, err = deps.exchangeToken(ctx, concierge, token.IDToken.Token)
+			if err != nil {
+				return fmt.Errorf("could not complete concierge credential exchange: %w", err)
+			}
+			if credentialCache != nil {
+				credentialCache.Put(cacheKey, cred)
+			}
 		}
 	}
-	return json.NewEncoder(cmd.OutOrStdout()).Encode(cred)
+
+	// Without a refresh token, the next login after the ID token expires opens a browser again. Tell wrappers of
+	// kubectl when that will happen, so they can trigger the login ahead of time. Static admin logins never need
+	// any interaction, and neither do logins with a username and password from the environment.
+	if flags.staticAdminUsername == "" && passwordLogin == nil && (token.RefreshToken == nil || token.RefreshToken.Token == "") && !token.IDToken.Expiry.IsZero() {
+		now := deps.now()
+		expiry := token.IDToken.Expiry.Time
+		cmd.PrintErrf(reauthRequiredHint, timeformat.RFC3339(expiry), timeformat.Human(expiry, now.Location()), timeformat.Relative(expiry, now))
+	}
+	return writeExecCredential(cmd.OutOrStdout(), cred, apiVersion)
+}
+
+// idTokenIdentity returns the issuer, subject and audience of the ID token, which identify the user and the cluster
+// that a cluster credential was issued for, or nil when the token does not have them and its credential cannot be
+// cached. Unlike the flags of the login, they also tell apart the users who log in with the same kubeconfig.
+func idTokenIdentity(token *oidctypes.Token) []string {
+	if token.IDToken == nil {
+		return nil
+	}
+	issuer, _ := token.IDToken.Claims["iss"].(string)
+	subject, _ := token.IDToken.Claims["sub"].(string)
+	audience, err := json.Marshal(token.IDToken.Claims["aud"])
+	if issuer == "" || subject == "" || err != nil {
+		return nil
+	}
+	return []string{issuer, subject, string(audience)}
 }
-func makeClient(caBundlePaths []string, caBundleData []string) (*http.Client, error) {
+
+// keychainSessionOptions returns the options for a session cache which stores refresh tokens in the OS keychain, when
+// enabled and available. Otherwise, refresh tokens are stored in the session cache file.
+func keychainSessionOptions(enabled bool) []filesession.Option {
+	if !enabled {
+		return nil
+	}
+	osKeychain, err := keychain.New()
+	if err != nil {
+		return nil
+	}
+	return []filesession.Option{filesession.WithKeychain(osKeychain)}
+}
+
+// passwordLoginOption returns the option for a non-interactive login with the username and password from the
+// environment, or nil when neither is set. A kubeconfig from an untrusted source could name any issuer, so the
+// password is only sent to the issuer which the environment allows explicitly.
+func passwordLoginOption(lookupEnv func(string) (string, bool), issuer string) (oidcclient.Option, error) {
+	username, _ := lookupEnv(usernameEnvVarName)
+	password, _ := lookupEnv(passwordEnvVarName)
+	switch {
+	case username == "" && password == "":
+		return nil, nil
+	case username == "" || password == "":
+		return nil, fmt.Errorf("the %s and %s environment variables must be set together", usernameEnvVarName, passwordEnvVarName)
+	}
+	if allowedIssuer, _ := lookupEnv(passwordIssuerEnvVarName); allowedIssuer != issuer {
+		return nil, fmt.Errorf("the %s and %s environment variables are only sent to the issuer in the %s environment variable, which is not %q",
+			usernameEnvVarName, passwordEnvVarName, passwordIssuerEnvVarName, issuer)
+	}
+	return oidcclient.WithUsernamePassword(username, password), nil
+}
+
+// encryptionSessionOptions returns the options for a session cache file which is encrypted according to the
+// --session-cache-encryption flag.
+func encryptionSessionOptions(mode string, lookupEnv func(string) (string, bool)) ([]filesession.Option, error) {
+	secret, err := sessionCacheSecret(mode, lookupEnv)
+	if err != nil || secret == nil {
+		return nil, err
+	}
+	return []filesession.Option{filesession.WithEncryption(secret)}, nil
+}
+
+// sessionCacheSecret returns the secret from which the key of the encrypted cache files is derived according to the
+// --session-cache-encryption flag, or nil when they are not encrypted.
+func sessionCacheSecret(mode string, lookupEnv func(string) (string, bool)) ([]byte, error) {
+	switch mode {
+	case "none":
+		return nil, nil
+	case "passphrase":
+		passphrase, ok := lookupEnv(sessionCachePassphraseEnvVarName)
+		if !ok || passphrase == "" {
+			return nil, fmt.Errorf("--session-cache-encryption=passphrase requires the %s environment variable to be set", sessionCachePassphraseEnvVarName)
+		}
+		return []byte(passphrase), nil
+	default:
+		return nil, fmt.Errorf("invalid --session-cache-encryption %q (use none or passphrase)", mode)
+	}
+}
+
+// parsePortRange parses the value of a port range flag, e.g. "49152-49162".
+func parsePortRange(flagName string, portRange string) (uint16, uint16, error) {
+	parts := strings.SplitN(portRange, "-", 2)
+	if len(parts) == 2 {
+		first, firstErr := strconv.ParseUint(parts[0], 10, 16)
+		last, lastErr := strconv.ParseUint(parts[1], 10, 16)
+		if firstErr == nil && lastErr == nil && first != 0 && first <= last {
+			return uint16(first), uint16(last), nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid --%s %q (use FIRST-LAST, e.g. 49152-49162)", flagName, portRange)
+}
+
+func makeClient(caBundlePaths []string, caBundleData []string, proxy *proxyConfig) (*http.Client, error) {
 	pool := x509.NewCertPool()
+	if len(caBundlePaths) == 0 && len(caBundleData) == 0 && proxy.configured() {
+		// The client only exists because of the proxy, so it trusts the system roots as well as the proxy CA bundle.
+		if systemPool, err := x509.SystemCertPool(); err == nil {
+			pool = systemPool
+		}
+	}
 	for _, p := range caBundlePaths {
 		pem, err := ioutil.ReadFile(p)
 		if err != nil {
@@ -198,9 +497,12 @@ func makeClient(caBundlePaths []string, caBundleData []string) (*http.Client, er
 		}
 		pool.AppendCertsFromPEM(pem)
 	}
+	if proxy != nil {
+		pool.AppendCertsFromPEM(proxy.caBundle)
+	}
 	return &http.Client{
 		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
+			Proxy: proxy.proxyFunc(),
 			TLSClientConfig: &tls.Config{
 				RootCAs:    pool,
 				MinVersion: tls.VersionTLS12,
@@ -213,7 +515,7 @@ func tokenCredential(token *oidctypes.Token) *clientauthv1beta1.ExecCredential {
 	cred := clientauthv1beta1.ExecCredential{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ExecCredential",
-			APIVersion: "client.authentication.k8s.io/v1beta1",
+			APIVersion: execCredentialV1beta1,
 		},
 		Status: &clientauthv1beta1.ExecCredentialStatus{
 			Token: token.IDToken.Token,
@@ -43,8 +43,10 @@ func TestLoginOIDCCommand(t *testing.T) {
 		loginErr         error
 		conciergeErr     error
 		wantError        bool
+		noRefreshToken   bool
 		wantStdout       string
 		wantStderr       string
+		env              map[string]string
 		wantOptionsCount int
 	}{
 		{
@@ -57,22 +59,37 @@ func TestLoginOIDCCommand(t *testing.T) {
 				  oidc --issuer ISSUER [flags]
 
 				Flags:
-				      --ca-bundle strings                     Path to TLS certificate authority bundle (PEM format, optional, can be repeated)
-				      --ca-bundle-data strings                Base64 endcoded TLS certificate authority bundle (base64 encoded PEM format, optional, can be repeated)
-				      --client-id string                      OpenID Connect client ID (default "pinniped-cli")
-				      --concierge-api-group-suffix string     Concierge API group suffix (default "pinniped.dev")
-				      --concierge-authenticator-name string   Concierge authenticator name
-				      --concierge-authenticator-type string   Concierge authenticator type (e.g., 'webhook', 'jwt')
-				      --concierge-ca-bundle-data string       CA bundle to use when connecting to the concierge
-				      --concierge-endpoint string             API base for the Pinniped concierge endpoint
-				      --enable-concierge                      Exchange the OIDC ID token with the Pinniped concierge during login
-				  -h, --help                                  help for oidc
-				      --issuer string                         OpenID Connect issuer URL
-				      --listen-port uint16                    TCP port for localhost listener (authorization code flow only)
-				      --request-audience string               Request a token with an alternate audience using RFC8693 token exchange
-				      --scopes strings                        OIDC scopes to request during login (default [offline_access,openid,pinniped:request-audience])
-				      --session-cache string                  Path to session cache file (default "` + cfgDir + `/sessions.yaml")
-				      --skip-browser                          Skip opening the browser (just print the URL)
+				      --browser-command string                   Command to open the login URL with, which is added as its last argument or replaces '%s' in its arguments (default: the default browser, also in WSL)
+				      --ca-bundle strings                        Path to TLS certificate authority bundle (PEM format, optional, can be repeated)
+				      --ca-bundle-data strings                   Base64 endcoded TLS certificate authority bundle (base64 encoded PEM format, optional, can be repeated)
+				      --client-id string                         OpenID Connect client ID (default "pinniped-cli")
+				      --concierge-api-group-suffix string        Concierge API group suffix (default "pinniped.dev")
+				      --concierge-authenticator-name string      Concierge authenticator name
+				      --concierge-authenticator-type string      Concierge authenticator type (e.g., 'webhook', 'jwt')
+				      --concierge-ca-bundle-data string          CA bundle to use when connecting to the concierge
+				      --concierge-endpoint string                API base for the Pinniped concierge endpoint
+				      --concierge-use-impersonation-proxy        Whether the concierge cluster uses an impersonation proxy
+				      --credential-cache string                  Path to the cache of the short-lived cluster credentials issued by the concierge ("" disables the cache) (default "` + cfgDir + `/credentials.yaml")
+				      --enable-concierge                         Exchange the OIDC ID token with the Pinniped concierge during login
+				      --grant-type string                        OAuth grant type to log in with: 'authcode' opens a browser on this machine, 'device' prints a code to enter on any other device, 'manual' prints a URL to open on any other device and reads the code which it shows (default "authcode")
+				  -h, --help                                     help for oidc
+				      --issuer string                            OpenID Connect issuer URL
+				      --listen-address string                    Loopback address for localhost listener, e.g. '127.0.0.1' or '::1' (authorization code flow only, default: localhost)
+				      --listen-port uint16                       TCP port for localhost listener (authorization code flow only)
+				      --listen-port-range string                 Range of TCP ports to try for localhost listener, e.g. '49152-49162' (authorization code flow only)
+				      --login-timeout duration                   Overall time allowed for a login, including any interaction with the user (default: 90m)
+				      --proxy-ca-bundle string                   Path to the TLS certificate authority bundle of the proxy (PEM format, optional) (default: $PINNIPED_PROXY_CA_BUNDLE)
+				      --proxy-url string                         URL of the HTTP(S) proxy for requests to the OpenID Connect provider and the concierge, which may include credentials (default: $PINNIPED_PROXY_URL, or the standard proxy environment variables)
+				      --qr-code                                  Also draw the login URL as a QR code, to log in with a phone (--grant-type device or manual only)
+				      --request-audience string                  Request a token with an alternate audience using RFC8693 token exchange
+				      --scopes strings                           OIDC scopes to request during login (default [offline_access,openid,pinniped:request-audience])
+				      --session-cache string                     Path to session cache file (default "` + cfgDir + `/sessions.yaml")
+				      --session-cache-encryption string          Encrypt the session cache file with a key derived from $PINNIPED_SESSION_CACHE_PASSPHRASE ('passphrase'), or do not encrypt it ('none') (default "none")
+				      --session-cache-keychain                   Store refresh tokens in the OS keychain instead of the session cache file, when a keychain is available (default true)
+				      --skip-browser                             Skip opening the browser (just print the URL)
+				      --static-admin-username string             Log in as this Supervisor static admin user, with the password from $PINNIPED_STATIC_ADMIN_PASSWORD (bootstrapping only)
+				      --upstream-identity-provider-name string   The name of the upstream identity provider used during login with a Supervisor
+				      --upstream-identity-provider-type string   The type of the upstream identity provider used during login with a Supervisor (e.g. 'oidc')
 			`),
 		},
 		{
@@ -134,6 +151,113 @@ func TestLoginOIDCCommand(t *testing.T) {
 				Error: invalid concierge parameters: invalid api group suffix: a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')
 			`),
 		},
+		{
+			name: "invalid session cache encryption",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--session-cache-encryption", "rot13",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: invalid --session-cache-encryption "rot13" (use none or passphrase)
+			`),
+		},
+		{
+			name: "username without a password from the environment",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+			},
+			env:       map[string]string{"PINNIPED_USERNAME": "ci-user"},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: the PINNIPED_USERNAME and PINNIPED_PASSWORD environment variables must be set together
+			`),
+		},
+		{
+			name: "username/password from the environment without an allowed issuer",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+			},
+			env:       map[string]string{"PINNIPED_USERNAME": "ci-user", "PINNIPED_PASSWORD": "some-password"},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: the PINNIPED_USERNAME and PINNIPED_PASSWORD environment variables are only sent to the issuer in the PINNIPED_PASSWORD_ISSUER environment variable, which is not "test-issuer"
+			`),
+		},
+		{
+			name: "username/password from the environment for another issuer",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+			},
+			env:       map[string]string{"PINNIPED_USERNAME": "ci-user", "PINNIPED_PASSWORD": "some-password", "PINNIPED_PASSWORD_ISSUER": "https://ci-issuer.example.com"},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: the PINNIPED_USERNAME and PINNIPED_PASSWORD environment variables are only sent to the issuer in the PINNIPED_PASSWORD_ISSUER environment variable, which is not "test-issuer"
+			`),
+		},
+		{
+			name: "invalid grant type",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--grant-type", "implicit",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: invalid --grant-type "implicit" (use authcode, device, or manual)
+			`),
+		},
+		{
+			name: "invalid upstream identity provider type",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--upstream-identity-provider-type", "ldap",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: invalid upstream identity provider type "ldap" (only "oidc" is supported)
+			`),
+		},
+		{
+			name: "session cache encryption without passphrase",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--session-cache-encryption", "passphrase",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: --session-cache-encryption=passphrase requires the PINNIPED_SESSION_CACHE_PASSPHRASE environment variable to be set
+			`),
+		},
+		{
+			name: "static admin username without password",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--static-admin-username", "admin",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: --static-admin-username requires the PINNIPED_STATIC_ADMIN_PASSWORD environment variable to be set
+			`),
+		},
+		{
+			name: "success with static admin credentials",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--static-admin-username", "admin",
+			},
+			env:              map[string]string{"PINNIPED_STATIC_ADMIN_PASSWORD": "some-password"},
+			wantOptionsCount: 4,
+			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
+		},
 		{
 			name: "login error",
 			args: []string{
@@ -173,6 +297,212 @@ func TestLoginOIDCCommand(t *testing.T) {
 			wantOptionsCount: 3,
 			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
 		},
+		{
+			name: "success with v1 ExecCredential API",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+			},
+			env:              map[string]string{"KUBERNETES_EXEC_INFO": `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1","spec":{"interactive":true}}`},
+			wantOptionsCount: 3,
+			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
+		},
+		{
+			name: "success with a proxy from the environment",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+			},
+			env:              map[string]string{"PINNIPED_PROXY_URL": "http://proxy.example.com:3128"},
+			wantOptionsCount: 4,
+			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
+		},
+		{
+			name: "invalid proxy URL",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--proxy-url", "proxy.example.com:3128",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: invalid --proxy-url scheme "proxy.example.com" (must be "http", "https", or "socks5")
+			`),
+		},
+		{
+			name: "listen port and listen port range",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--listen-port", "1234",
+				"--listen-port-range", "49152-49162",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: --listen-port and --listen-port-range cannot be used together
+			`),
+		},
+		{
+			name: "invalid listen port range",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--listen-port-range", "49162-49152",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: invalid --listen-port-range "49162-49152" (use FIRST-LAST, e.g. 49152-49162)
+			`),
+		},
+		{
+			name: "success with a custom localhost listener and login timeout",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--listen-address", "127.0.0.1",
+				"--listen-port-range", "49152-49162",
+				"--login-timeout", "3h",
+			},
+			wantOptionsCount: 6,
+			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
+		},
+		{
+			name: "unsupported ExecCredential API",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+			},
+			env:       map[string]string{"KUBERNETES_EXEC_INFO": `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1alpha1"}`},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: unsupported ExecCredential API version "client.authentication.k8s.io/v1alpha1" in KUBERNETES_EXEC_INFO (use client.authentication.k8s.io/v1 or client.authentication.k8s.io/v1beta1)
+			`),
+		},
+		{
+			name: "success without a refresh token",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+			},
+			noRefreshToken:   true,
+			wantOptionsCount: 3,
+			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
+			wantStderr: here.Doc(`
+				Pinniped: interactive login will be required after 3020-10-12T13:14:15Z (Thu, 12 Oct 3020 13:14:15 UTC, in 1h0m0s) because the session cannot be refreshed
+			`),
+		},
+		{
+			name: "static admin login without a refresh token does not need interaction",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--static-admin-username", "admin",
+			},
+			env:              map[string]string{"PINNIPED_STATIC_ADMIN_PASSWORD": "some-password"},
+			noRefreshToken:   true,
+			wantOptionsCount: 4,
+			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
+		},
+		{
+			name: "username/password login from the environment without a refresh token does not need interaction",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+			},
+			env:              map[string]string{"PINNIPED_USERNAME": "ci-user", "PINNIPED_PASSWORD": "some-password", "PINNIPED_PASSWORD_ISSUER": "test-issuer"},
+			noRefreshToken:   true,
+			wantOptionsCount: 4,
+			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
+		},
+		{
+			name: "success with the device grant",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--grant-type", "device",
+			},
+			wantOptionsCount: 4,
+			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
+		},
+		{
+			name: "success with the manual grant",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--grant-type", "manual",
+			},
+			wantOptionsCount: 4,
+			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
+		},
+		{
+			name: "success with the manual grant and a QR code",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--grant-type", "manual",
+				"--qr-code",
+			},
+			wantOptionsCount: 5,
+			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
+		},
+		{
+			name: "QR code with the authcode grant",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--qr-code",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: --qr-code requires --grant-type device or manual
+			`),
+		},
+		{
+			name: "success with a browser command",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--browser-command", "firefox --private-window",
+			},
+			wantOptionsCount: 4,
+			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
+		},
+		{
+			name: "browser command with skip browser",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--browser-command", "firefox",
+				"--skip-browser",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: --skip-browser and --browser-command cannot be used together
+			`),
+		},
+		{
+			name: "empty browser command",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--browser-command", " ",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: invalid --browser-command: browser command is empty
+			`),
+		},
+		{
+			name: "success with an upstream identity provider",
+			args: []string{
+				"--client-id", "test-client-id",
+				"--issuer", "test-issuer",
+				"--upstream-identity-provider-name", "some-upstream",
+				"--upstream-identity-provider-type", "oidc",
+			},
+			wantOptionsCount: 4,
+			wantStdout:       `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"expirationTimestamp":"3020-10-12T13:14:15Z","token":"test-id-token"}}` + "\n",
+		},
 		{
 			name: "success with all options",
 			args: []string{
@@ -202,6 +532,7 @@ func TestLoginOIDCCommand(t *testing.T) {
 				gotOptions []oidcclient.Option
 			)
 			cmd := oidcLoginCommand(oidcLoginCommandDeps{
+				now: func() time.Time { return time1.Add(-time.Hour) },
 				login: func(issuer string, clientID string, opts ...oidcclient.Option) (*oidctypes.Token, error) {
 					require.Equal(t, "test-issuer", issuer)
 					require.Equal(t, "test-client-id", clientID)
@@ -209,12 +540,17 @@ func TestLoginOIDCCommand(t *testing.T) {
 					if tt.loginErr != nil {
 						return nil, tt.loginErr
 					}
-					return &oidctypes.Token{
+					token := &oidctypes.Token{
+						RefreshToken: &oidctypes.RefreshToken{Token: "test-refresh-token"},
 						IDToken: &oidctypes.IDToken{
 							Token:  "test-id-token",
 							Expiry: metav1.NewTime(time1),
 						},
-					}, nil
+					}
+					if tt.noRefreshToken {
+						token.RefreshToken = nil
+					}
+					return token, nil
 				},
 				exchangeToken: func(ctx context.Context, client *conciergeclient.Client, token string) (*clientauthv1beta1.ExecCredential, error) {
 					require.Equal(t, token, "test-id-token")
@@ -231,6 +567,10 @@ func TestLoginOIDCCommand(t *testing.T) {
 						},
 					}, nil
 				},
+				lookupEnv: func(name string) (string, bool) {
+					value, ok := tt.env[name]
+					return value, ok
+				},
 			})
 			require.NotNil(t, cmd)
 
@@ -250,3 +590,79 @@ func TestLoginOIDCCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestLoginOIDCCommandCredentialCache(t *testing.T) {
+	credentialCachePath := filepath.Join(testutil.TempDir(t), "credentials.yaml")
+	expiry := metav1.NewTime(time.Now().Add(time.Hour).Truncate(time.Second).UTC())
+	var exchanges int
+	subject := "test-subject"
+	deps := oidcLoginCommandDeps{
+		now: time.Now,
+		login: func(issuer string, clientID string, opts ...oidcclient.Option) (*oidctypes.Token, error) {
+			return &oidctypes.Token{
+				RefreshToken: &oidctypes.RefreshToken{Token: "test-refresh-token"},
+				IDToken: &oidctypes.IDToken{Token: "test-id-token", Expiry: expiry, Claims: map[string]interface{}{
+					"iss": issuer, "sub": subject, "aud": []interface{}{clientID},
+				}},
+			}, nil
+		},
+		exchangeToken: func(ctx context.Context, client *conciergeclient.Client, token string) (*clientauthv1beta1.ExecCredential, error) {
+			exchanges++
+			return &clientauthv1beta1.ExecCredential{
+				Status: &clientauthv1beta1.ExecCredentialStatus{
+					ClientCertificateData: fmt.Sprintf("test-certificate-%d", exchanges),
+					ClientKeyData:         "test-key",
+					ExpirationTimestamp:   &expiry,
+				},
+			}, nil
+		},
+		lookupEnv: func(name string) (string, bool) {
+			if name == "PINNIPED_SESSION_CACHE_PASSPHRASE" {
+				return "test-passphrase", true
+			}
+			return "", false
+		},
+	}
+	login := func(issuer string) string {
+		cmd := oidcLoginCommand(deps)
+		var stdout, stderr bytes.Buffer
+		cmd.SetOut(&stdout)
+		cmd.SetErr(&stderr)
+		cmd.SetArgs([]string{
+			"--issuer", issuer,
+			"--session-cache", filepath.Join(filepath.Dir(credentialCachePath), "sessions.yaml"),
+			"--session-cache-encryption", "passphrase",
+			"--credential-cache", credentialCachePath,
+			"--enable-concierge",
+			"--concierge-authenticator-type", "jwt",
+			"--concierge-authenticator-name", "test-authenticator",
+			"--concierge-endpoint", "https://127.0.0.1:1234/",
+		})
+		require.NoError(t, cmd.Execute())
+		require.Empty(t, stderr.String())
+		return stdout.String()
+	}
+
+	first := login("test-issuer")
+	require.Contains(t, first, `"clientCertificateData":"test-certificate-1"`)
+	require.Equal(t, 1, exchanges)
+
+	// The second login of the same user reuses the credential.
+	require.Equal(t, first, login("test-issuer"))
+	require.Equal(t, 1, exchanges)
+
+	// The credential of another issuer is not reused.
+	require.Contains(t, login("other-issuer"), `"clientCertificateData":"test-certificate-2"`)
+	require.Equal(t, 2, exchanges)
+
+	// Neither is the credential of another user who logs in with the same flags, e.g. in another browser profile.
+	subject = "other-subject"
+	require.Contains(t, login("test-issuer"), `"clientCertificateData":"test-certificate-3"`)
+	require.Equal(t, 3, exchanges)
+
+	// The cache file is encrypted like the session cache, since the credentials contain private keys.
+	contents, err := ioutil.ReadFile(credentialCachePath)
+	require.NoError(t, err)
+	require.Contains(t, string(contents), "kind: EncryptedCredentialCache")
+	require.NotContains(t, string(contents), "test-key")
+}
@@ -5,15 +5,20 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	clientauthv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
 
+	"go.pinniped.dev/internal/clientcert"
 	"go.pinniped.dev/pkg/conciergeclient"
 	"go.pinniped.dev/pkg/oidcclient/oidctypes"
 )
@@ -40,12 +45,19 @@ func staticLoginRealDeps() staticLoginDeps {
 type staticLoginParams struct {
 	staticToken                string
 	staticTokenEnvName         string
+	staticTokenFile            string
+	clientCertificatePath      string
+	clientKeyPath              string
+	clientCertificateAudience  string
 	conciergeEnabled           bool
 	conciergeAuthenticatorType string
 	conciergeAuthenticatorName string
 	conciergeEndpoint          string
 	conciergeCABundle          string
 	conciergeAPIGroupSuffix    string
+	conciergeUseProxy          bool
+	credentialCachePath        string
+	proxy                      proxyFlags
 }
 
 func staticLoginCommand(deps staticLoginDeps) *cobra.Command {
@@ -61,6 +73,10 @@ func staticLoginCommand(deps staticLoginDeps) *cobra.Command {
 	)
 	cmd.Flags().StringVar(&flags.staticToken, "token", "", "Static token to present during login")
 	cmd.Flags().StringVar(&flags.staticTokenEnvName, "token-env", "", "Environment variable containing a static token")
+	cmd.Flags().StringVar(&flags.staticTokenFile, "token-file", "", "File containing a token, which is read on every login (e.g., a projected ServiceAccount token)")
+	cmd.Flags().StringVar(&flags.clientCertificatePath, "client-certificate", "", "Path to a PEM-encoded client certificate (and intermediates) to prove possession of, instead of a token")
+	cmd.Flags().StringVar(&flags.clientKeyPath, "client-key", "", "Path to the PEM-encoded private key of the --client-certificate")
+	cmd.Flags().StringVar(&flags.clientCertificateAudience, "client-certificate-audience", "", "Audience of the concierge ClientCertificateAuthenticator which accepts the --client-certificate")
 	cmd.Flags().BoolVar(&flags.conciergeEnabled, "enable-concierge", false, "Exchange the token with the Pinniped concierge during login")
 	cmd.Flags().StringVar(&conciergeNamespace, "concierge-namespace", "pinniped-concierge", "Namespace in which the concierge was installed")
 	cmd.Flags().StringVar(&flags.conciergeAuthenticatorType, "concierge-authenticator-type", "", "Concierge authenticator type (e.g., 'webhook', 'jwt')")
@@ -68,7 +84,10 @@ func staticLoginCommand(deps staticLoginDeps) *cobra.Command {
 	cmd.Flags().StringVar(&flags.conciergeEndpoint, "concierge-endpoint", "", "API base for the Pinniped concierge endpoint")
 	cmd.Flags().StringVar(&flags.conciergeCABundle, "concierge-ca-bundle-data", "", "CA bundle to use when connecting to the concierge")
 	cmd.Flags().StringVar(&flags.conciergeAPIGroupSuffix, "concierge-api-group-suffix", "pinniped.dev", "Concierge API group suffix")
-	cmd.RunE = func(cmd *cobra.Command, args []string) error { return runStaticLogin(cmd.OutOrStdout(), deps, flags) }
+	cmd.Flags().BoolVar(&flags.conciergeUseProxy, "concierge-use-impersonation-proxy", false, "Whether the concierge cluster uses an impersonation proxy")
+	cmd.Flags().StringVar(&flags.credentialCachePath, "credential-cache", filepath.Join(mustGetConfigDir(), "credentials.yaml"), credentialCacheUsage)
+	addProxyFlags(cmd.Flags(), &flags.proxy)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error { return runStaticLogin(cmd, deps, flags) }
 
 	mustMarkDeprecated(cmd, "concierge-namespace", "not needed anymore")
 	mustMarkHidden(cmd, "concierge-namespace")
@@ -76,20 +95,43 @@ func staticLoginCommand(deps staticLoginDeps) *cobra.Command {
 	return cmd
 }
 
-func runStaticLogin(out io.Writer, deps staticLoginDeps, flags staticLoginParams) error {
-	if flags.staticToken == "" && flags.staticTokenEnvName == "" {
-		return fmt.Errorf("one of --token or --token-env must be set")
+func runStaticLogin(cmd *cobra.Command, deps staticLoginDeps, flags staticLoginParams) error {
+	if flags.staticToken == "" && flags.staticTokenEnvName == "" && flags.staticTokenFile == "" && flags.clientCertificatePath == "" {
+		return fmt.Errorf("one of --token, --token-env, --token-file, or --client-certificate must be set")
+	}
+	if flags.clientCertificatePath != "" || flags.clientKeyPath != "" {
+		if flags.staticToken != "" || flags.staticTokenEnvName != "" || flags.staticTokenFile != "" {
+			return fmt.Errorf("--token, --token-env, and --token-file cannot be used with --client-certificate")
+		}
+		if flags.clientCertificatePath == "" || flags.clientKeyPath == "" || flags.clientCertificateAudience == "" {
+			return fmt.Errorf("--client-certificate, --client-key, and --client-certificate-audience must be set together")
+		}
+	}
+	apiVersion, err := execCredentialAPIVersion(deps.lookupEnv)
+	if err != nil {
+		return err
+	}
+	proxy, err := loadProxyConfig(flags.proxy, deps.lookupEnv)
+	if err != nil {
+		return err
 	}
 
 	var concierge *conciergeclient.Client
 	if flags.conciergeEnabled {
-		var err error
-		concierge, err = conciergeclient.New(
+		conciergeOpts := []conciergeclient.Option{
 			conciergeclient.WithEndpoint(flags.conciergeEndpoint),
 			conciergeclient.WithBase64CABundle(flags.conciergeCABundle),
 			conciergeclient.WithAuthenticator(flags.conciergeAuthenticatorType, flags.conciergeAuthenticatorName),
 			conciergeclient.WithAPIGroupSuffix(flags.conciergeAPIGroupSuffix),
-		)
+		}
+		if flags.conciergeUseProxy {
+			conciergeOpts = append(conciergeOpts, conciergeclient.WithImpersonationProxy())
+		}
+		if proxy.configured() {
+			conciergeOpts = append(conciergeOpts, conciergeclient.WithProxy(proxy.url, string(proxy.caBundle)))
+		}
+		var err error
+		concierge, err = conciergeclient.New(conciergeOpts...)
 		if err != nil {
 			return fmt.Errorf("invalid concierge parameters: %w", err)
 		}
@@ -99,6 +141,13 @@ func runStaticLogin(out io.Writer, deps staticLoginDeps, flags staticLoginParams
 	if flags.staticToken != "" {
 		token = flags.staticToken
 	}
+	if flags.clientCertificatePath != "" {
+		var err error
+		token, err = clientCertificateProof(flags.clientCertificatePath, flags.clientKeyPath, flags.clientCertificateAudience)
+		if err != nil {
+			return err
+		}
+	}
 	if flags.staticTokenEnvName != "" {
 		var ok bool
 		token, ok = deps.lookupEnv(flags.staticTokenEnvName)
@@ -109,10 +158,40 @@ func runStaticLogin(out io.Writer, deps staticLoginDeps, flags staticLoginParams
 			return fmt.Errorf("--token-env variable %q is empty", flags.staticTokenEnvName)
 		}
 	}
+	if flags.staticTokenFile != "" {
+		// Projected ServiceAccount tokens are rotated in place, so the file is read again on every login.
+		tokenBytes, err := ioutil.ReadFile(flags.staticTokenFile)
+		if err != nil {
+			return fmt.Errorf("could not read --token-file: %w", err)
+		}
+		token = strings.TrimSpace(string(tokenBytes))
+		if token == "" {
+			return fmt.Errorf("--token-file %q is empty", flags.staticTokenFile)
+		}
+	}
 	cred := tokenCredential(&oidctypes.Token{IDToken: &oidctypes.IDToken{Token: token}})
 
-	// Exchange that token with the concierge, if configured.
+	// Exchange that token with the concierge, if configured, unless a credential for the same token is still cached.
 	if concierge != nil {
+		credentialCache := newCredentialCache(cmd, flags.credentialCachePath, nil)
+		cacheKey := credentialCacheKey{
+			ConciergeEndpoint:          flags.conciergeEndpoint,
+			ConciergeAPIGroupSuffix:    flags.conciergeAPIGroupSuffix,
+			ConciergeAuthenticatorType: flags.conciergeAuthenticatorType,
+			ConciergeAuthenticatorName: flags.conciergeAuthenticatorName,
+			ConciergeUseProxy:          flags.conciergeUseProxy,
+			Identity:                   []string{token},
+		}
+		if flags.clientCertificatePath != "" {
+			// Every proof of possession is different, so the certificate identifies the user instead.
+			cacheKey.Identity = []string{flags.clientCertificatePath, flags.clientCertificateAudience}
+		}
+		if credentialCache != nil {
+			if cached := credentialCache.Get(cacheKey); cached != nil {
+				return writeExecCredential(cmd.OutOrStdout(), cached, apiVersion)
+			}
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
@@ -121,6 +200,35 @@ func runStaticLogin(out io.Writer, deps staticLoginDeps, flags staticLoginParams
 		if err != nil {
 			return fmt.Errorf("could not complete concierge credential exchange: %w", err)
 		}
+		if credentialCache != nil {
+			credentialCache.Put(cacheKey, cred)
+		}
+	}
+	return writeExecCredential(cmd.OutOrStdout(), cred, apiVersion)
+}
+
+// clientCertificateProof returns a short-lived token which proves possession of the client certificate to a
+// concierge ClientCertificateAuthenticator, since the certificate itself cannot be presented through the concierge.
+func clientCertificateProof(certPath, keyPath, audience string) (string, error) {
+	keyPair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return "", fmt.Errorf("could not load --client-certificate and --client-key: %w", err)
+	}
+	signer, ok := keyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return "", fmt.Errorf("--client-key has unsupported type %T", keyPair.PrivateKey)
+	}
+	chain := make([]*x509.Certificate, 0, len(keyPair.Certificate))
+	for _, der := range keyPair.Certificate {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return "", fmt.Errorf("could not parse --client-certificate: %w", err)
+		}
+		chain = append(chain, cert)
+	}
+	token, err := clientcert.NewProof(chain, signer, audience, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("could not create client certificate proof: %w", err)
 	}
-	return json.NewEncoder(out).Encode(cred)
+	return token, nil
 }
@@ -7,6 +7,8 @@ import (
 	"bytes"
 	"context"
 	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -15,20 +17,35 @@ import (
 
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
 	clientauthv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
 
+	authv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
 	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/clientcert"
 	"go.pinniped.dev/internal/here"
 	"go.pinniped.dev/internal/testutil"
 	"go.pinniped.dev/pkg/conciergeclient"
 )
 
 func TestLoginStaticCommand(t *testing.T) {
+	cfgDir := mustGetConfigDir()
+
 	testCA, err := certauthority.New(pkix.Name{CommonName: "Test CA"}, 1*time.Hour)
 	require.NoError(t, err)
 	tmpdir := testutil.TempDir(t)
 	testCABundlePath := filepath.Join(tmpdir, "testca.pem")
 	require.NoError(t, ioutil.WriteFile(testCABundlePath, testCA.Bundle(), 0600))
+	testClientCert, testClientKey, err := testCA.IssuePEM(pkix.Name{CommonName: "test-user"}, nil, 1*time.Hour)
+	require.NoError(t, err)
+	testClientCertPath := filepath.Join(tmpdir, "client.crt")
+	require.NoError(t, ioutil.WriteFile(testClientCertPath, testClientCert, 0600))
+	testClientKeyPath := filepath.Join(tmpdir, "client.key")
+	require.NoError(t, ioutil.WriteFile(testClientKeyPath, testClientKey, 0600))
+	testTokenPath := filepath.Join(tmpdir, "token")
+	require.NoError(t, ioutil.WriteFile(testTokenPath, []byte("test-token\n"), 0600))
+	testEmptyTokenPath := filepath.Join(tmpdir, "empty-token")
+	require.NoError(t, ioutil.WriteFile(testEmptyTokenPath, nil, 0600))
 
 	tests := []struct {
 		name             string
@@ -38,6 +55,7 @@ func TestLoginStaticCommand(t *testing.T) {
 		conciergeErr     error
 		wantError        bool
 		wantStdout       string
+		wantTokenUser    string
 		wantStderr       string
 		wantOptionsCount int
 	}{
@@ -51,15 +69,23 @@ func TestLoginStaticCommand(t *testing.T) {
 				  static [--token TOKEN] [--token-env TOKEN_NAME] [flags]
 
 				Flags:
+				      --client-certificate string             Path to a PEM-encoded client certificate (and intermediates) to prove possession of, instead of a token
+				      --client-certificate-audience string    Audience of the concierge ClientCertificateAuthenticator which accepts the --client-certificate
+				      --client-key string                     Path to the PEM-encoded private key of the --client-certificate
 				      --concierge-api-group-suffix string     Concierge API group suffix (default "pinniped.dev")
 				      --concierge-authenticator-name string   Concierge authenticator name
 				      --concierge-authenticator-type string   Concierge authenticator type (e.g., 'webhook', 'jwt')
 				      --concierge-ca-bundle-data string       CA bundle to use when connecting to the concierge
 				      --concierge-endpoint string             API base for the Pinniped concierge endpoint
+				      --concierge-use-impersonation-proxy     Whether the concierge cluster uses an impersonation proxy
+				      --credential-cache string               Path to the cache of the short-lived cluster credentials issued by the concierge ("" disables the cache) (default "` + cfgDir + `/credentials.yaml")
 				      --enable-concierge                      Exchange the token with the Pinniped concierge during login
 				  -h, --help                                  help for static
+				      --proxy-ca-bundle string                Path to the TLS certificate authority bundle of the proxy (PEM format, optional) (default: $PINNIPED_PROXY_CA_BUNDLE)
+				      --proxy-url string                      URL of the HTTP(S) proxy for requests to the OpenID Connect provider and the concierge, which may include credentials (default: $PINNIPED_PROXY_URL, or the standard proxy environment variables)
 				      --token string                          Static token to present during login
 				      --token-env string                      Environment variable containing a static token
+				      --token-file string                     File containing a token, which is read on every login (e.g., a projected ServiceAccount token)
 			`),
 		},
 		{
@@ -67,7 +93,7 @@ func TestLoginStaticCommand(t *testing.T) {
 			args:      []string{},
 			wantError: true,
 			wantStderr: here.Doc(`
-				Error: one of --token or --token-env must be set
+				Error: one of --token, --token-env, --token-file, or --client-certificate must be set
 			`),
 		},
 		{
@@ -151,6 +177,110 @@ func TestLoginStaticCommand(t *testing.T) {
 			},
 			wantStdout: `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"token":"test-token"}}` + "\n",
 		},
+		{
+			name: "static token success with v1 ExecCredential API",
+			args: []string{
+				"--token", "test-token",
+			},
+			env: map[string]string{
+				"KUBERNETES_EXEC_INFO": `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1","spec":{"interactive":false}}`,
+			},
+			wantStdout: `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1","spec":{},"status":{"token":"test-token"}}` + "\n",
+		},
+		{
+			name: "invalid KUBERNETES_EXEC_INFO",
+			args: []string{
+				"--token", "test-token",
+			},
+			env: map[string]string{
+				"KUBERNETES_EXEC_INFO": `not json`,
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: could not parse KUBERNETES_EXEC_INFO: invalid character 'o' in literal null (expecting 'u')
+			`),
+		},
+		{
+			name: "token file success",
+			args: []string{
+				"--token-file", testTokenPath,
+			},
+			wantStdout: `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"token":"test-token"}}` + "\n",
+		},
+		{
+			name: "token file success with concierge",
+			args: []string{
+				"--token-file", testTokenPath,
+				"--enable-concierge",
+				"--concierge-authenticator-type", "serviceaccount",
+				"--concierge-authenticator-name", "test-authenticator",
+				"--concierge-endpoint", "https://127.0.0.1/",
+			},
+			wantStdout: `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{},"status":{"token":"exchanged-token"}}` + "\n",
+		},
+		{
+			name: "missing token file",
+			args: []string{
+				"--token-file", filepath.Join(tmpdir, "does-not-exist"),
+			},
+			wantError: true,
+			wantStderr: here.Docf(`
+				Error: could not read --token-file: open %s: no such file or directory
+			`, filepath.Join(tmpdir, "does-not-exist")),
+		},
+		{
+			name: "empty token file",
+			args: []string{
+				"--token-file", testEmptyTokenPath,
+			},
+			wantError: true,
+			wantStderr: here.Docf(`
+				Error: --token-file %q is empty
+			`, testEmptyTokenPath),
+		},
+		{
+			name: "client certificate with token",
+			args: []string{
+				"--token", "test-token",
+				"--client-certificate", testClientCertPath,
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: --token, --token-env, and --token-file cannot be used with --client-certificate
+			`),
+		},
+		{
+			name: "client certificate without key",
+			args: []string{
+				"--client-certificate", testClientCertPath,
+				"--client-certificate-audience", "test-audience",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: --client-certificate, --client-key, and --client-certificate-audience must be set together
+			`),
+		},
+		{
+			name: "client certificate with invalid key",
+			args: []string{
+				"--client-certificate", testClientCertPath,
+				"--client-key", testCABundlePath,
+				"--client-certificate-audience", "test-audience",
+			},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: could not load --client-certificate and --client-key: tls: found a certificate rather than a key in the PEM for the private key
+			`),
+		},
+		{
+			name: "client certificate success",
+			args: []string{
+				"--client-certificate", testClientCertPath,
+				"--client-key", testClientKeyPath,
+				"--client-certificate-audience", "test-audience",
+			},
+			wantTokenUser: "test-user",
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -188,8 +318,76 @@ func TestLoginStaticCommand(t *testing.T) {
 			} else {
 				require.NoError(t, err)
 			}
-			require.Equal(t, tt.wantStdout, stdout.String(), "unexpected stdout")
 			require.Equal(t, tt.wantStderr, stderr.String(), "unexpected stderr")
+			if tt.wantTokenUser == "" {
+				require.Equal(t, tt.wantStdout, stdout.String(), "unexpected stdout")
+				return
+			}
+
+			// The token is a freshly signed proof, so check that it would be accepted rather than comparing it.
+			var cred clientauthv1beta1.ExecCredential
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), &cred))
+			authenticator, err := clientcert.New(&authv1alpha1.ClientCertificateAuthenticatorSpec{
+				CertificateAuthorityData: base64.StdEncoding.EncodeToString(testCA.Bundle()),
+				Audience:                 "test-audience",
+			}, clock.RealClock{})
+			require.NoError(t, err)
+			response, authenticated, err := authenticator.AuthenticateToken(context.Background(), cred.Status.Token)
+			require.NoError(t, err)
+			require.True(t, authenticated)
+			require.Equal(t, tt.wantTokenUser, response.User.GetName())
 		})
 	}
 }
+
+func TestLoginStaticCommandCredentialCache(t *testing.T) {
+	credentialCachePath := filepath.Join(testutil.TempDir(t), "credentials.yaml")
+	expiry := metav1.NewTime(time.Now().Add(time.Hour).Truncate(time.Second).UTC())
+	var exchanged []string
+	deps := staticLoginDeps{
+		lookupEnv: func(string) (string, bool) { return "", false },
+		exchangeToken: func(ctx context.Context, client *conciergeclient.Client, token string) (*clientauthv1beta1.ExecCredential, error) {
+			exchanged = append(exchanged, token)
+			return &clientauthv1beta1.ExecCredential{
+				Status: &clientauthv1beta1.ExecCredentialStatus{
+					ClientCertificateData: fmt.Sprintf("test-certificate-%d", len(exchanged)),
+					ClientKeyData:         "test-key",
+					ExpirationTimestamp:   &expiry,
+				},
+			}, nil
+		},
+	}
+	login := func(args ...string) string {
+		cmd := staticLoginCommand(deps)
+		var stdout, stderr bytes.Buffer
+		cmd.SetOut(&stdout)
+		cmd.SetErr(&stderr)
+		cmd.SetArgs(append([]string{
+			"--enable-concierge",
+			"--concierge-authenticator-type", "webhook",
+			"--concierge-authenticator-name", "test-authenticator",
+			"--concierge-endpoint", "https://127.0.0.1:1234/",
+		}, args...))
+		require.NoError(t, cmd.Execute())
+		require.Empty(t, stderr.String())
+		return stdout.String()
+	}
+
+	first := login("--token", "test-token", "--credential-cache", credentialCachePath)
+	require.Contains(t, first, `"clientCertificateData":"test-certificate-1"`)
+	require.Equal(t, first, login("--token", "test-token", "--credential-cache", credentialCachePath))
+	require.Equal(t, []string{"test-token"}, exchanged)
+
+	// Another token is another identity.
+	require.Contains(t, login("--token", "other-token", "--credential-cache", credentialCachePath), `"clientCertificateData":"test-certificate-2"`)
+	require.Equal(t, []string{"test-token", "other-token"}, exchanged)
+
+	// The token itself is not stored in the cache.
+	contents, err := ioutil.ReadFile(credentialCachePath)
+	require.NoError(t, err)
+	require.NotContains(t, string(contents), "test-token")
+
+	// An empty path disables the cache.
+	login("--token", "test-token", "--credential-cache", "")
+	require.Equal(t, []string{"test-token", "other-token", "test-token"}, exchanged)
+}
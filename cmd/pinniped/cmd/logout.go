@@ -0,0 +1,143 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/spf13/cobra"
+
+	"go.pinniped.dev/pkg/oidcclient"
+	"go.pinniped.dev/pkg/oidcclient/filesession"
+	"go.pinniped.dev/pkg/oidcclient/oidctypes"
+)
+
+//nolint: gochecknoinits
+func init() {
+	rootCmd.AddCommand(logoutCommand(logoutCommandRealDeps()))
+}
+
+type logoutCommandDeps struct {
+	revoke func(context.Context, string, string, *oidctypes.Token, *http.Client) error
+}
+
+func logoutCommandRealDeps() logoutCommandDeps {
+	return logoutCommandDeps{revoke: oidcclient.Revoke}
+}
+
+type logoutFlags struct {
+	issuer           string
+	sessionCachePath string
+	sessionKeychain  bool
+	sessionEncrypt   string
+	credentialCache  string
+	caBundlePaths    []string
+	caBundleData     []string
+	proxy            proxyFlags
+	skipRevocation   bool
+}
+
+func logoutCommand(deps logoutCommandDeps) *cobra.Command {
+	var (
+		cmd = &cobra.Command{
+			Args:  cobra.NoArgs,
+			Use:   "logout",
+			Short: "Remove cached sessions and revoke them at the issuer",
+			Long: "Remove cached sessions from the session cache, so that the next login requires interaction.\n\n" +
+				"The sessions are also revoked at their issuer when it supports token revocation, so they stop working\n" +
+				"even where their tokens were copied. A session whose revocation fails is kept, so that logout can be\n" +
+				"retried. Without --issuer, the sessions of all issuers are removed.\n\n" +
+				"The cached cluster credentials which were issued by the concierge are always all removed.",
+			SilenceUsage: true,
+		}
+		flags logoutFlags
+	)
+	cmd.Flags().StringVar(&flags.issuer, "issuer", "", "Only remove the sessions of this OpenID Connect issuer URL")
+	cmd.Flags().StringVar(&flags.sessionCachePath, "session-cache", filepath.Join(mustGetConfigDir(), "sessions.yaml"), "Path to session cache file")
+	cmd.Flags().BoolVar(&flags.sessionKeychain, "session-cache-keychain", true, "Remove refresh tokens from the OS keychain, when a keychain is available")
+	cmd.Flags().StringVar(&flags.sessionEncrypt, "session-cache-encryption", "none", sessionCacheEncryptionUsage)
+	cmd.Flags().StringVar(&flags.credentialCache, "credential-cache", filepath.Join(mustGetConfigDir(), "credentials.yaml"), credentialCacheUsage)
+	cmd.Flags().StringSliceVar(&flags.caBundlePaths, "ca-bundle", nil, "Path to TLS certificate authority bundle (PEM format, optional, can be repeated)")
+	cmd.Flags().StringSliceVar(&flags.caBundleData, "ca-bundle-data", nil, "Base64 endcoded TLS certificate authority bundle (base64 encoded PEM format, optional, can be repeated)")
+	addProxyFlags(cmd.Flags(), &flags.proxy)
+	cmd.Flags().BoolVar(&flags.skipRevocation, "skip-revocation", false, "Only remove the sessions from the session cache, without revoking them")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error { return runLogout(cmd, deps, flags) }
+	return cmd
+}
+
+func runLogout(cmd *cobra.Command, deps logoutCommandDeps, flags logoutFlags) error {
+	proxy, err := loadProxyConfig(flags.proxy, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	httpClient, err := makeClient(flags.caBundlePaths, flags.caBundleData, proxy)
+	if err != nil {
+		return err
+	}
+
+	encryptionSecret, err := sessionCacheSecret(flags.sessionEncrypt, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	sessionOptions := append(keychainSessionOptions(flags.sessionKeychain), filesession.WithErrorReporter(func(err error) {
+		cmd.PrintErrf("Warning: %v\n", err)
+	}))
+	if encryptionSecret != nil {
+		sessionOptions = append(sessionOptions, filesession.WithEncryption(encryptionSecret))
+	}
+	sessionCache := filesession.New(flags.sessionCachePath, sessionOptions...)
+
+	// The cache does not know which session a credential was issued for, so all of them are removed.
+	if credentialCache := newCredentialCache(cmd, flags.credentialCache, encryptionSecret); credentialCache != nil {
+		credentialCache.Clear()
+	}
+
+	sessions := sessionCache.ListTokens(func(key oidcclient.SessionCacheKey) bool {
+		return flags.issuer == "" || key.Issuer == flags.issuer
+	})
+	if len(sessions) == 0 {
+		cmd.Println("No cached sessions found.")
+		return nil
+	}
+
+	// Sessions are only removed after they were revoked, so that a session whose revocation failed can still be
+	// revoked by running logout again.
+	var removable []oidcclient.SessionCacheKey
+	for i := range sessions {
+		session := &sessions[i]
+		if !flags.skipRevocation {
+			err := deps.revoke(cmd.Context(), session.Key.Issuer, session.Key.ClientID, &session.Tokens, httpClient)
+			switch {
+			case errors.Is(err, oidcclient.ErrRevocationNotSupported):
+				cmd.PrintErrf("Warning: %s does not support token revocation, so the session remains valid until it expires\n", session.Key.Issuer)
+			case err != nil:
+				cmd.PrintErrf("Warning: could not revoke the session of %s, so it was kept: %v\n", session.Key.Issuer, err)
+				continue
+			}
+		}
+		removable = append(removable, session.Key)
+	}
+
+	removed := sessionCache.DeleteTokens(func(key oidcclient.SessionCacheKey) bool {
+		for i := range removable {
+			if reflect.DeepEqual(removable[i], key) {
+				return true
+			}
+		}
+		return false
+	})
+	for i := range removed {
+		cmd.Printf("Removed session of %s\n", removed[i].Key.Issuer)
+	}
+
+	if len(removed) < len(sessions) {
+		return errors.New("some sessions could not be revoked, try again or remove them with --skip-revocation")
+	}
+	return nil
+}
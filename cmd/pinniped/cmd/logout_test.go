@@ -0,0 +1,198 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+
+	"go.pinniped.dev/internal/execcredcache"
+	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/pkg/oidcclient"
+	"go.pinniped.dev/pkg/oidcclient/filesession"
+	"go.pinniped.dev/pkg/oidcclient/oidctypes"
+)
+
+func TestLogoutCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		noSessions   bool
+		noRun        bool
+		revokeErr    error
+		wantError    bool
+		wantStdout   string
+		wantStderr   string
+		wantRevoked  []string
+		wantRemained []string
+	}{
+		{
+			name:       "help flag passed",
+			args:       []string{"--help"},
+			noSessions: true,
+			noRun:      true,
+			wantStdout: here.Doc(`
+				Remove cached sessions from the session cache, so that the next login requires interaction.
+
+				The sessions are also revoked at their issuer when it supports token revocation, so they stop working
+				even where their tokens were copied. A session whose revocation fails is kept, so that logout can be
+				retried. Without --issuer, the sessions of all issuers are removed.
+
+				The cached cluster credentials which were issued by the concierge are always all removed.
+
+				Usage:
+				  logout [flags]
+
+				Flags:
+				      --ca-bundle strings                 Path to TLS certificate authority bundle (PEM format, optional, can be repeated)
+				      --ca-bundle-data strings            Base64 endcoded TLS certificate authority bundle (base64 encoded PEM format, optional, can be repeated)
+				      --credential-cache string           Path to the cache of the short-lived cluster credentials issued by the concierge ("" disables the cache) (default "` + filepath.Join(mustGetConfigDir(), "credentials.yaml") + `")
+				  -h, --help                              help for logout
+				      --issuer string                     Only remove the sessions of this OpenID Connect issuer URL
+				      --proxy-ca-bundle string            Path to the TLS certificate authority bundle of the proxy (PEM format, optional) (default: $PINNIPED_PROXY_CA_BUNDLE)
+				      --proxy-url string                  URL of the HTTP(S) proxy for requests to the OpenID Connect provider and the concierge, which may include credentials (default: $PINNIPED_PROXY_URL, or the standard proxy environment variables)
+				      --session-cache string              Path to session cache file (default "` + filepath.Join(mustGetConfigDir(), "sessions.yaml") + `")
+				      --session-cache-encryption string   Encrypt the session cache file with a key derived from $PINNIPED_SESSION_CACHE_PASSPHRASE ('passphrase'), or do not encrypt it ('none') (default "none")
+				      --session-cache-keychain            Remove refresh tokens from the OS keychain, when a keychain is available (default true)
+				      --skip-revocation                   Only remove the sessions from the session cache, without revoking them
+			`),
+		},
+		{
+			name:       "invalid CA bundle data",
+			args:       []string{"--ca-bundle-data", "invalid-base64"},
+			wantError:  true,
+			wantStderr: "Error: could not read --ca-bundle-data: illegal base64 data at input byte 7\n",
+			wantRemained: []string{
+				"https://issuer-1.example.com", "https://issuer-2.example.com",
+			},
+		},
+		{
+			name:       "no cached sessions",
+			noSessions: true,
+			wantStdout: "No cached sessions found.\n",
+		},
+		{
+			name:        "all sessions are removed and revoked",
+			wantRevoked: []string{"https://issuer-1.example.com", "https://issuer-2.example.com"},
+			wantStdout: here.Doc(`
+				Removed session of https://issuer-1.example.com
+				Removed session of https://issuer-2.example.com
+			`),
+		},
+		{
+			name:         "sessions of one issuer are removed and revoked",
+			args:         []string{"--issuer", "https://issuer-2.example.com"},
+			wantRevoked:  []string{"https://issuer-2.example.com"},
+			wantRemained: []string{"https://issuer-1.example.com"},
+			wantStdout:   "Removed session of https://issuer-2.example.com\n",
+		},
+		{
+			name: "sessions are removed without revocation",
+			args: []string{"--skip-revocation"},
+			wantStdout: here.Doc(`
+				Removed session of https://issuer-1.example.com
+				Removed session of https://issuer-2.example.com
+			`),
+		},
+		{
+			name:        "issuer does not support revocation",
+			args:        []string{"--issuer", "https://issuer-1.example.com"},
+			revokeErr:   oidcclient.ErrRevocationNotSupported,
+			wantRevoked: []string{"https://issuer-1.example.com"},
+			wantRemained: []string{
+				"https://issuer-2.example.com",
+			},
+			wantStdout: "Removed session of https://issuer-1.example.com\n",
+			wantStderr: "Warning: https://issuer-1.example.com does not support token revocation, so the session remains valid until it expires\n",
+		},
+		{
+			name:        "revocation fails",
+			args:        []string{"--issuer", "https://issuer-1.example.com"},
+			revokeErr:   fmt.Errorf("some revocation error"),
+			wantError:   true,
+			wantRevoked: []string{"https://issuer-1.example.com"},
+			wantRemained: []string{
+				"https://issuer-1.example.com", "https://issuer-2.example.com",
+			},
+			wantStderr: here.Doc(`
+				Warning: could not revoke the session of https://issuer-1.example.com, so it was kept: some revocation error
+				Error: some sessions could not be revoked, try again or remove them with --skip-revocation
+			`),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := testutil.TempDir(t)
+			sessionCachePath := tempDir + "/sessions.yaml"
+			credentialCachePath := tempDir + "/credentials.yaml"
+			credentialCacheKey := credentialCacheKey{ConciergeEndpoint: "https://127.0.0.1:1234/"}
+			execcredcache.New(credentialCachePath).Put(credentialCacheKey, &clientauthv1beta1.ExecCredential{
+				Status: &clientauthv1beta1.ExecCredentialStatus{
+					ClientCertificateData: "some-certificate",
+					ClientKeyData:         "some-key",
+					ExpirationTimestamp:   &metav1.Time{Time: time.Now().Add(time.Hour)},
+				},
+			})
+			if !tt.noSessions {
+				cache := filesession.New(sessionCachePath)
+				for _, issuer := range []string{"https://issuer-1.example.com", "https://issuer-2.example.com"} {
+					cache.PutToken(
+						oidcclient.SessionCacheKey{Issuer: issuer, ClientID: "pinniped-cli", Scopes: []string{"openid"}},
+						&oidctypes.Token{RefreshToken: &oidctypes.RefreshToken{Token: "refresh-token-for-" + issuer}},
+					)
+				}
+			}
+
+			var gotRevoked []string
+			cmd := logoutCommand(logoutCommandDeps{
+				revoke: func(_ context.Context, issuer string, clientID string, token *oidctypes.Token, _ *http.Client) error {
+					require.Equal(t, "pinniped-cli", clientID)
+					require.Equal(t, "refresh-token-for-"+issuer, token.RefreshToken.Token)
+					gotRevoked = append(gotRevoked, issuer)
+					return tt.revokeErr
+				},
+			})
+			require.NotNil(t, cmd)
+
+			var stdout, stderr bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			cmd.SetArgs(append([]string{"--session-cache", sessionCachePath, "--credential-cache", credentialCachePath}, tt.args...))
+			err := cmd.Execute()
+			if tt.wantError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			// The cluster credentials are removed before the sessions are revoked.
+			if (tt.wantError && tt.revokeErr == nil) || tt.noRun {
+				require.NotNil(t, execcredcache.New(credentialCachePath).Get(credentialCacheKey))
+			} else {
+				require.Nil(t, execcredcache.New(credentialCachePath).Get(credentialCacheKey))
+			}
+			require.Equal(t, tt.wantStdout, stdout.String(), "unexpected stdout")
+			require.Equal(t, tt.wantStderr, stderr.String(), "unexpected stderr")
+			require.Equal(t, tt.wantRevoked, gotRevoked)
+
+			var remained []string
+			if !tt.noSessions {
+				for _, session := range filesession.New(sessionCachePath).DeleteTokens(func(oidcclient.SessionCacheKey) bool { return true }) {
+					remained = append(remained, session.Key.Issuer)
+				}
+			}
+			require.Equal(t, tt.wantRemained, remained)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	proxyURLEnvVarName      = "PINNIPED_PROXY_URL"
+	proxyCABundleEnvVarName = "PINNIPED_PROXY_CA_BUNDLE"
+)
+
+type proxyFlags struct {
+	url          string
+	caBundlePath string
+}
+
+func addProxyFlags(f *pflag.FlagSet, flags *proxyFlags) {
+	f.StringVar(&flags.url, "proxy-url", "", "URL of the HTTP(S) proxy for requests to the OpenID Connect provider and the concierge, which may include credentials (default: $"+proxyURLEnvVarName+", or the standard proxy environment variables)")
+	f.StringVar(&flags.caBundlePath, "proxy-ca-bundle", "", "Path to the TLS certificate authority bundle of the proxy (PEM format, optional) (default: $"+proxyCABundleEnvVarName+")")
+}
+
+// proxyConfig is the proxy which the CLI uses for all of its own HTTP requests. Unlike the standard HTTPS_PROXY and
+// NO_PROXY environment variables, it does not change how kubectl and other programs reach the cluster.
+type proxyConfig struct {
+	// url is nil when no proxy was configured, in which case the standard proxy environment variables are used.
+	url      *url.URL
+	caBundle []byte
+}
+
+// loadProxyConfig returns the proxy of the flags, falling back to the environment variables for each unset flag.
+func loadProxyConfig(flags proxyFlags, lookupEnv func(string) (string, bool)) (*proxyConfig, error) {
+	if flags.url == "" {
+		flags.url, _ = lookupEnv(proxyURLEnvVarName)
+	}
+	if flags.caBundlePath == "" {
+		flags.caBundlePath, _ = lookupEnv(proxyCABundleEnvVarName)
+	}
+
+	var config proxyConfig
+	if flags.url != "" {
+		proxyURL, err := url.Parse(flags.url)
+		if err != nil {
+			// Do not print the URL, since it may contain the credentials of the proxy.
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return nil, fmt.Errorf("invalid --proxy-url: %w", err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf(`invalid --proxy-url scheme %q (must be "http", "https", or "socks5")`, proxyURL.Scheme)
+		}
+		if proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid --proxy-url: missing host")
+		}
+		config.url = proxyURL
+	}
+	if flags.caBundlePath != "" {
+		caBundle, err := ioutil.ReadFile(flags.caBundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("could not read --proxy-ca-bundle: %w", err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("invalid --proxy-ca-bundle %q: no certificates found", flags.caBundlePath)
+		}
+		config.caBundle = caBundle
+	}
+	return &config, nil
+}
+
+func (p *proxyConfig) proxyFunc() func(*http.Request) (*url.URL, error) {
+	if p == nil || p.url == nil {
+		return http.ProxyFromEnvironment
+	}
+	return http.ProxyURL(p.url)
+}
+
+// configured returns whether the CLI needs its own HTTP client for the proxy.
+func (p *proxyConfig) configured() bool {
+	return p != nil && (p.url != nil || len(p.caBundle) > 0)
+}
@@ -4,10 +4,12 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 
+	"go.pinniped.dev/internal/perror"
 	"go.pinniped.dev/internal/plog"
 )
 
@@ -29,6 +31,10 @@ func init() {
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		// Cobra has already printed the error, so only add what the user can do about it, if we know.
+		if hint := perror.Hint(err); hint != "" {
+			fmt.Fprintln(os.Stderr, hint)
+		}
 		os.Exit(1)
 	}
 }
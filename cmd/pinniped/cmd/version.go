@@ -1,13 +1,32 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	apimachineryversion "k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/component-base/version"
+	aggregatorclient "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset"
+	"sigs.k8s.io/yaml"
+
+	loginv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/login/v1alpha1"
+	"go.pinniped.dev/internal/groupsuffix"
+	supervisoroidc "go.pinniped.dev/internal/oidc"
 )
 
 //nolint: gochecknoinits
@@ -15,14 +34,229 @@ func init() {
 	rootCmd.AddCommand(newVersionCommand())
 }
 
-func newVersionCommand() *cobra.Command {
-	return &cobra.Command{
-		RunE: func(cmd *cobra.Command, _ []string) error {
-			fmt.Fprintf(cmd.OutOrStdout(), "%#v\n", version.Get())
-			return nil
+type versionDeps struct {
+	getDiscovery        func(clientConfig clientcmd.ClientConfig) (discovery.DiscoveryInterface, error)
+	getKubeClient       func(clientConfig clientcmd.ClientConfig) (kubernetes.Interface, error)
+	getAggregatorClient func(clientConfig clientcmd.ClientConfig) (aggregatorclient.Interface, error)
+}
+
+func versionRealDeps() versionDeps {
+	kubeconfigDeps := kubeconfigRealDeps()
+	return versionDeps{
+		getDiscovery:  kubeconfigDeps.getDiscovery,
+		getKubeClient: kubeconfigDeps.getKubeClient,
+		getAggregatorClient: func(clientConfig clientcmd.ClientConfig) (aggregatorclient.Interface, error) {
+			restConfig, err := clientConfig.ClientConfig()
+			if err != nil {
+				return nil, err
+			}
+			return aggregatorclient.NewForConfig(restConfig)
 		},
-		Args:  cobra.NoArgs, // do not accept positional arguments for this command
-		Use:   "version",
-		Short: "Print the version of this Pinniped CLI",
+	}
+}
+
+type versionFlags struct {
+	outputFormat              string
+	concierge                 bool
+	conciergeAPIGroupSuffix   string
+	kubeconfigPath            string
+	kubeconfigContextOverride string
+	supervisorIssuer          string
+	supervisorCABundlePaths   []string
+}
+
+// versionOutput is printed by "pinniped version --output json|yaml".
+type versionOutput struct {
+	ClientVersion     *apimachineryversion.Info `json:"clientVersion"`
+	ConciergeVersion  *apimachineryversion.Info `json:"conciergeVersion,omitempty"`
+	SupervisorVersion *apimachineryversion.Info `json:"supervisorVersion,omitempty"`
+}
+
+func newVersionCommand() *cobra.Command {
+	return versionCommand(versionRealDeps())
+}
+
+func versionCommand(deps versionDeps) *cobra.Command {
+	var (
+		cmd = &cobra.Command{
+			Args:  cobra.NoArgs, // do not accept positional arguments for this command
+			Use:   "version",
+			Short: "Print the version of this Pinniped CLI",
+			Long: "Print the version of this Pinniped CLI, and optionally of the Pinniped Concierge and Supervisor.\n\n" +
+				"A warning is printed when the minor version of a server differs from the minor version of this CLI.",
+		}
+		flags versionFlags
+	)
+	f := cmd.Flags()
+	f.StringVarP(&flags.outputFormat, "output", "o", "", "Output format (e.g., 'json', 'yaml') (default: Go syntax)")
+	f.BoolVar(&flags.concierge, "concierge", false, "Also print the version of the Concierge of the cluster of --kubeconfig")
+	f.StringVar(&flags.conciergeAPIGroupSuffix, "concierge-api-group-suffix", "", "Concierge API group suffix (default: autodiscover)")
+	f.StringVar(&flags.kubeconfigPath, "kubeconfig", os.Getenv("KUBECONFIG"), "Path to kubeconfig file")
+	f.StringVar(&flags.kubeconfigContextOverride, "kubeconfig-context", "", "Kubeconfig context name (default: current active context)")
+	f.StringVar(&flags.supervisorIssuer, "supervisor-issuer", "", "Also print the version of the Supervisor which serves this issuer URL")
+	f.StringSliceVar(&flags.supervisorCABundlePaths, "supervisor-ca-bundle", nil, "Path to TLS certificate authority bundle of the Supervisor (PEM format, optional, can be repeated)")
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error { return runVersion(cmd, deps, flags) }
+	return cmd
+}
+
+func runVersion(cmd *cobra.Command, deps versionDeps, flags versionFlags) error {
+	switch flags.outputFormat {
+	case "", "json", "yaml":
+	default:
+		return fmt.Errorf("invalid --output %q (use json or yaml)", flags.outputFormat)
+	}
+
+	clientVersion := version.Get()
+	output := versionOutput{ClientVersion: &clientVersion}
+	if flags.concierge {
+		conciergeVersion, err := getConciergeVersion(cmd.Context(), deps, flags)
+		if err != nil {
+			return fmt.Errorf("could not get the version of the Concierge: %w", err)
+		}
+		output.ConciergeVersion = conciergeVersion
+		warnAboutVersionSkew(cmd, "Concierge", &clientVersion, conciergeVersion)
+	}
+	if flags.supervisorIssuer != "" {
+		supervisorVersion, err := getSupervisorVersion(cmd.Context(), flags)
+		if err != nil {
+			return fmt.Errorf("could not get the version of the Supervisor: %w", err)
+		}
+		output.SupervisorVersion = supervisorVersion
+		warnAboutVersionSkew(cmd, "Supervisor", &clientVersion, supervisorVersion)
+	}
+
+	out := cmd.OutOrStdout()
+	switch flags.outputFormat {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output)
+	case "yaml":
+		yamlOutput, err := yaml.Marshal(output)
+		if err != nil {
+			return fmt.Errorf("could not encode version info: %w", err)
+		}
+		_, err = out.Write(yamlOutput)
+		return err
+	}
+
+	// Without servers, keep printing only the version of the CLI, since scripts may parse it.
+	if output.ConciergeVersion == nil && output.SupervisorVersion == nil {
+		fmt.Fprintf(out, "%#v\n", clientVersion)
+		return nil
+	}
+	fmt.Fprintf(out, "Client: %#v\n", clientVersion)
+	if output.ConciergeVersion != nil {
+		fmt.Fprintf(out, "Concierge: %#v\n", *output.ConciergeVersion)
+	}
+	if output.SupervisorVersion != nil {
+		fmt.Fprintf(out, "Supervisor: %#v\n", *output.SupervisorVersion)
+	}
+	return nil
+}
+
+// getConciergeVersion asks the aggregated API server of the Concierge for its version. The request is proxied by the
+// Kubernetes API server to the Service of the login API, since the /version path of the cluster belongs to Kubernetes.
+func getConciergeVersion(ctx context.Context, deps versionDeps, flags versionFlags) (*apimachineryversion.Info, error) {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	clientConfig := newClientConfig(flags.kubeconfigPath, flags.kubeconfigContextOverride)
+	apiGroupSuffix := flags.conciergeAPIGroupSuffix
+	if apiGroupSuffix == "" {
+		discoveryClient, err := deps.getDiscovery(clientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("could not configure Kubernetes client: %w", err)
+		}
+		if apiGroupSuffix, err = discoverAPIGroupSuffix(discoveryClient); err != nil {
+			return nil, err
+		}
+	} else if err := groupsuffix.Validate(apiGroupSuffix); err != nil {
+		return nil, fmt.Errorf("invalid api group suffix: %w", err)
+	}
+
+	aggregatorClient, err := deps.getAggregatorClient(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not configure Kubernetes client: %w", err)
+	}
+	loginGroup, _ := groupsuffix.Replace(loginv1alpha1.GroupName, apiGroupSuffix)
+	apiServiceName := loginv1alpha1.SchemeGroupVersion.Version + "." + loginGroup
+	apiService, err := aggregatorClient.ApiregistrationV1().APIServices().Get(ctx, apiServiceName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not get APIService %q: %w", apiServiceName, err)
+	}
+	service := apiService.Spec.Service
+	if service == nil {
+		return nil, fmt.Errorf("APIService %q does not refer to a Service", apiServiceName)
+	}
+	port := "443"
+	if service.Port != nil {
+		port = fmt.Sprint(*service.Port)
+	}
+
+	kubeClient, err := deps.getKubeClient(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not configure Kubernetes client: %w", err)
+	}
+	body, err := kubeClient.CoreV1().Services(service.Namespace).ProxyGet("https", service.Name, port, "/version", nil).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get /version through Service %s/%s: %w", service.Namespace, service.Name, err)
+	}
+	var info apimachineryversion.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("could not decode version info: %w", err)
+	}
+	return &info, nil
+}
+
+// getSupervisorVersion asks the Supervisor which serves the issuer for its version.
+func getSupervisorVersion(ctx context.Context, flags versionFlags) (*apimachineryversion.Info, error) {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	httpClient := &http.Client{}
+	if len(flags.supervisorCABundlePaths) > 0 {
+		var err error
+		if httpClient, err = makeClient(flags.supervisorCABundlePaths, nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(flags.supervisorIssuer, "/")+supervisoroidc.VersionEndpointPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", rsp.Status, strings.TrimSpace(string(body)))
+	}
+	var info apimachineryversion.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("could not decode version info: %w", err)
+	}
+	return &info, nil
+}
+
+// warnAboutVersionSkew prints a warning when the major or minor version of a server differs from the CLI. Development
+// builds which do not have a semantic version are never reported.
+func warnAboutVersionSkew(cmd *cobra.Command, server string, clientVersion, serverVersion *apimachineryversion.Info) {
+	parsedClient, err := utilversion.ParseSemantic(clientVersion.GitVersion)
+	if err != nil {
+		return
+	}
+	parsedServer, err := utilversion.ParseSemantic(serverVersion.GitVersion)
+	if err != nil {
+		return
+	}
+	if parsedClient.Major() != parsedServer.Major() || parsedClient.Minor() != parsedServer.Minor() {
+		fmt.Fprintf(cmd.ErrOrStderr(), "WARNING: the %s has version %s, which differs from the version %s of this CLI\n",
+			server, serverVersion.GitVersion, clientVersion.GitVersion)
 	}
 }
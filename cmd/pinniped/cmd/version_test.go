@@ -1,16 +1,40 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package cmd
 
 import (
 	"bytes"
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apimachineryversion "k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	kubetesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	aggregatorclient "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset"
+	aggregatorfake "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/fake"
 
 	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/testutil"
 )
 
 var (
@@ -19,35 +43,60 @@ var (
 		  version \[flags\]
 
 		Flags:
-		  -h, --help   help for version
+		      --concierge                           Also print the version of the Concierge of the cluster of --kubeconfig
+		      --concierge-api-group-suffix string   Concierge API group suffix \(default: autodiscover\)
+		  -h, --help                                help for version
+		      --kubeconfig string                   Path to kubeconfig file
+		      --kubeconfig-context string           Kubeconfig context name \(default: current active context\)
+		  -o, --output string                       Output format \(e.g., 'json', 'yaml'\) \(default: Go syntax\)
+		      --supervisor-ca-bundle strings        Path to TLS certificate authority bundle of the Supervisor \(PEM format, optional, can be repeated\)
+		      --supervisor-issuer string            Also print the version of the Supervisor which serves this issuer URL
 
 		`)
 
 	knownGoodHelpRegexpForVersion = here.Doc(`
-		Print the version of this Pinniped CLI
+		Print the version of this Pinniped CLI, and optionally of the Pinniped Concierge and Supervisor.
 
-		Usage:
-		  version \[flags\]
+		A warning is printed when the minor version of a server differs from the minor version of this CLI.
 
-		Flags:
-		  -h, --help   help for version
-		`)
+		`) + strings.TrimSuffix(knownGoodUsageRegexpForVersion, "\n")
 
 	emptyVersionRegexp = `version.Info{Major:"", Minor:"", GitVersion:".*", GitCommit:".*", GitTreeState:"", BuildDate:".*", GoVersion:".*", Compiler:".*", Platform:".*/.*"}`
 )
 
 func TestNewVersionCmd(t *testing.T) {
+	supervisor := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/issuer/version":
+			_, _ = w.Write([]byte(`{"gitVersion":"v0.99.0"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(supervisor.Close)
+	supervisorCABundlePath := filepath.Join(testutil.TempDir(t), "supervisor-ca.pem")
+	require.NoError(t, ioutil.WriteFile(supervisorCABundlePath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: supervisor.Certificate().Raw}), 0600))
+
+	conciergeAPIService := &apiregistrationv1.APIService{
+		ObjectMeta: metav1.ObjectMeta{Name: "v1alpha1.login.concierge.pinniped.dev"},
+		Spec: apiregistrationv1.APIServiceSpec{
+			Service: &apiregistrationv1.ServiceReference{Namespace: "concierge", Name: "concierge-api"},
+		},
+	}
+
 	tests := []struct {
 		name             string
 		args             []string
+		apiServices      []runtime.Object
 		wantError        bool
+		wantProxyGet     string
 		wantStdoutRegexp string
 		wantStderrRegexp string
 	}{
 		{
 			name:             "no flags",
 			args:             []string{},
-			wantStdoutRegexp: emptyVersionRegexp + "\n",
+			wantStdoutRegexp: "^" + emptyVersionRegexp + "\n$",
 		},
 		{
 			name:             "help flag passed",
@@ -61,11 +110,84 @@ func TestNewVersionCmd(t *testing.T) {
 			wantStderrRegexp: `Error: unknown command "tuna" for "version"`,
 			wantStdoutRegexp: knownGoodUsageRegexpForVersion,
 		},
+		{
+			name:             "json output",
+			args:             []string{"--output", "json"},
+			wantStdoutRegexp: `(?s)^{\n  "clientVersion": {\n    "major": "",.*"platform": ".*/.*"\n  }\n}\n$`,
+		},
+		{
+			name:             "yaml output",
+			args:             []string{"-o", "yaml"},
+			wantStdoutRegexp: `(?s)^clientVersion:\n  buildDate: .*platform: .*/.*\n$`,
+		},
+		{
+			name:             "invalid output",
+			args:             []string{"-o", "xml"},
+			wantError:        true,
+			wantStderrRegexp: `Error: invalid --output "xml" \(use json or yaml\)`,
+		},
+		{
+			name:             "concierge version",
+			args:             []string{"--concierge"},
+			apiServices:      []runtime.Object{conciergeAPIService},
+			wantProxyGet:     "concierge/https:concierge-api:443/version",
+			wantStdoutRegexp: `^Client: ` + emptyVersionRegexp + `\nConcierge: version.Info{Major:"0", Minor:"99", GitVersion:"v0.99.0", .*}\n$`,
+		},
+		{
+			name:             "concierge version with json output",
+			args:             []string{"--concierge", "-o", "json"},
+			apiServices:      []runtime.Object{conciergeAPIService},
+			wantProxyGet:     "concierge/https:concierge-api:443/version",
+			wantStdoutRegexp: `(?s)"conciergeVersion": {\n    "major": "0",\n    "minor": "99",\n    "gitVersion": "v0.99.0",`,
+		},
+		{
+			name:             "concierge which is not installed",
+			args:             []string{"--concierge", "--concierge-api-group-suffix", "tuna.io"},
+			apiServices:      []runtime.Object{conciergeAPIService},
+			wantError:        true,
+			wantStderrRegexp: `Error: could not get the version of the Concierge: could not get APIService "v1alpha1.login.concierge.tuna.io": apiservices.apiregistration.k8s.io "v1alpha1.login.concierge.tuna.io" not found`,
+		},
+		{
+			name:             "supervisor version",
+			args:             []string{"--supervisor-issuer", supervisor.URL + "/issuer/", "--supervisor-ca-bundle", supervisorCABundlePath},
+			wantStdoutRegexp: `^Client: ` + emptyVersionRegexp + `\nSupervisor: version.Info{Major:"", Minor:"", GitVersion:"v0.99.0", .*}\n$`,
+		},
+		{
+			name:             "supervisor which is not found",
+			args:             []string{"--supervisor-issuer", supervisor.URL + "/other-issuer", "--supervisor-ca-bundle", supervisorCABundlePath},
+			wantError:        true,
+			wantStderrRegexp: `Error: could not get the version of the Supervisor: 404 Not Found: 404 page not found`,
+		},
+		{
+			name:             "supervisor with an untrusted certificate",
+			args:             []string{"--supervisor-issuer", supervisor.URL + "/issuer"},
+			wantError:        true,
+			wantStderrRegexp: `Error: could not get the version of the Supervisor: Get ".*/issuer/version": .*x509: certificate signed by unknown authority`,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			cmd := newVersionCommand()
+			cmd := versionCommand(versionDeps{
+				getDiscovery: func(clientConfig clientcmd.ClientConfig) (discovery.DiscoveryInterface, error) {
+					fake := &fakediscovery.FakeDiscovery{Fake: &kubetesting.Fake{}}
+					fake.Resources = append(fake.Resources, &metav1.APIResourceList{GroupVersion: "login.concierge.pinniped.dev/v1alpha1"})
+					return fake, nil
+				},
+				getAggregatorClient: func(clientConfig clientcmd.ClientConfig) (aggregatorclient.Interface, error) {
+					return aggregatorfake.NewSimpleClientset(tt.apiServices...), nil
+				},
+				getKubeClient: func(clientConfig clientcmd.ClientConfig) (kubernetes.Interface, error) {
+					fake := kubernetesfake.NewSimpleClientset()
+					fake.AddProxyReactor("services", func(action kubetesting.Action) (bool, rest.ResponseWrapper, error) {
+						proxyGet := action.(kubetesting.ProxyGetAction)
+						require.Equal(t, tt.wantProxyGet, fmt.Sprintf("%s/%s:%s:%s%s",
+							proxyGet.GetNamespace(), proxyGet.GetScheme(), proxyGet.GetName(), proxyGet.GetPort(), proxyGet.GetPath()))
+						return true, fakeResponseWrapper(`{"major":"0","minor":"99","gitVersion":"v0.99.0"}`), nil
+					})
+					return fake, nil
+				},
+			})
 			require.NotNil(t, cmd)
 
 			var stdout, stderr bytes.Buffer
@@ -83,3 +205,66 @@ func TestNewVersionCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestWarnAboutVersionSkew(t *testing.T) {
+	tests := []struct {
+		name          string
+		clientVersion string
+		serverVersion string
+		wantWarning   string
+	}{
+		{
+			name:          "same version",
+			clientVersion: "v0.10.0",
+			serverVersion: "v0.10.0",
+		},
+		{
+			name:          "other patch version",
+			clientVersion: "v0.10.0",
+			serverVersion: "v0.10.3",
+		},
+		{
+			name:          "other minor version",
+			clientVersion: "v0.10.0",
+			serverVersion: "v0.9.1",
+			wantWarning:   "WARNING: the Supervisor has version v0.9.1, which differs from the version v0.10.0 of this CLI\n",
+		},
+		{
+			name:          "other major version",
+			clientVersion: "v1.0.0",
+			serverVersion: "v0.10.0",
+			wantWarning:   "WARNING: the Supervisor has version v0.10.0, which differs from the version v1.0.0 of this CLI\n",
+		},
+		{
+			name:          "development build of the client",
+			clientVersion: "v0.0.0-master+$Format:%h$",
+			serverVersion: "v0.10.0",
+		},
+		{
+			name:          "development build of the server",
+			clientVersion: "v0.10.0",
+			serverVersion: "",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			cmd := &cobra.Command{}
+			cmd.SetErr(&stderr)
+			warnAboutVersionSkew(cmd, "Supervisor",
+				&apimachineryversion.Info{GitVersion: tt.clientVersion},
+				&apimachineryversion.Info{GitVersion: tt.serverVersion},
+			)
+			require.Equal(t, tt.wantWarning, stderr.String())
+		})
+	}
+}
+
+type fakeResponseWrapper string
+
+func (f fakeResponseWrapper) DoRaw(context.Context) ([]byte, error) { return []byte(f), nil }
+
+func (f fakeResponseWrapper) Stream(context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader([]byte(f))), nil
+}
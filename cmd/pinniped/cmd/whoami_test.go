@@ -0,0 +1,252 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubetesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+
+	identityv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/identity/v1alpha1"
+	conciergeclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
+	fakeconciergeclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	"go.pinniped.dev/internal/here"
+)
+
+func TestWhoami(t *testing.T) {
+	tests := []struct {
+		name                string
+		args                []string
+		groupsOverride      []string
+		gettingClientsetErr error
+		callingAPIErr       error
+		wantError           bool
+		wantStdout          string
+		wantStderr          string
+		wantAPIGroupSuffix  string
+	}{
+		{
+			name: "help flag",
+			args: []string{"--help"},
+			wantStdout: here.Doc(`
+				Print information about the current user
+
+				Usage:
+				  whoami [flags]
+
+				Flags:
+				      --api-group-suffix string     Concierge API group suffix (default "pinniped.dev")
+				  -h, --help                        help for whoami
+				      --kubeconfig string           Path to kubeconfig file
+				      --kubeconfig-context string   Kubeconfig context name (default: current active context)
+				  -o, --output string               Output format (e.g., 'yaml', 'json', 'text') (default "text")
+				      --timeout duration            Timeout for the WhoAmI API request (default 10s)
+			`),
+		},
+		{
+			name: "text output",
+			args: []string{"--kubeconfig", "testdata/kubeconfig.yaml"},
+			wantStdout: here.Doc(`
+				Current cluster info:
+
+				Name: kind-kind
+				URL: https://fake-server-url-value
+
+				Current user info:
+
+				Username: some-username
+				Groups: some-group-0, some-group-1
+			`),
+		},
+		{
+			name:           "text output without groups",
+			args:           []string{"--kubeconfig", "testdata/kubeconfig.yaml"},
+			groupsOverride: []string{},
+			wantStdout: here.Doc(`
+				Current cluster info:
+
+				Name: kind-kind
+				URL: https://fake-server-url-value
+
+				Current user info:
+
+				Username: some-username
+				Groups: <none>
+			`),
+		},
+		{
+			name: "text output with kubeconfig context and api group suffix",
+			args: []string{"--kubeconfig", "testdata/kubeconfig.yaml", "--kubeconfig-context", "some-other-context", "--api-group-suffix", "tuna.io"},
+			wantStdout: here.Doc(`
+				Current cluster info:
+
+				Name: some-other-cluster
+				URL: https://some-other-fake-server-url-value
+
+				Current user info:
+
+				Username: some-username
+				Groups: some-group-0, some-group-1
+			`),
+			wantAPIGroupSuffix: "tuna.io",
+		},
+		{
+			name: "text output with unknown kubeconfig context",
+			args: []string{"--kubeconfig", "testdata/kubeconfig.yaml", "--kubeconfig-context", "this-context-does-not-exist"},
+			wantStdout: here.Doc(`
+				Current cluster info:
+
+				Name: ???
+				URL: ???
+
+				Current user info:
+
+				Username: some-username
+				Groups: some-group-0, some-group-1
+			`),
+		},
+		{
+			name: "json output",
+			args: []string{"--kubeconfig", "testdata/kubeconfig.yaml", "--output", "json"},
+			wantStdout: here.Doc(`
+				{
+				  "kind": "WhoAmIRequest",
+				  "apiVersion": "identity.concierge.pinniped.dev/v1alpha1",
+				  "metadata": {
+				    "creationTimestamp": null
+				  },
+				  "spec": {},
+				  "status": {
+				    "kubernetesUserInfo": {
+				      "user": {
+				        "username": "some-username",
+				        "groups": [
+				          "some-group-0",
+				          "some-group-1"
+				        ]
+				      }
+				    }
+				  }
+				}
+			`),
+		},
+		{
+			name: "yaml output",
+			args: []string{"--kubeconfig", "testdata/kubeconfig.yaml", "-o", "yaml"},
+			wantStdout: here.Doc(`
+				apiVersion: identity.concierge.pinniped.dev/v1alpha1
+				kind: WhoAmIRequest
+				metadata:
+				  creationTimestamp: null
+				spec: {}
+				status:
+				  kubernetesUserInfo:
+				    user:
+				      groups:
+				      - some-group-0
+				      - some-group-1
+				      username: some-username
+			`),
+		},
+		{
+			name:      "unknown output format",
+			args:      []string{"--kubeconfig", "testdata/kubeconfig.yaml", "--output", "xml"},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: could not write output: unknown output format: "xml"
+			`),
+		},
+		{
+			name:      "invalid kubeconfig",
+			args:      []string{"--kubeconfig", "this-file-does-not-exist"},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: could not get current cluster info: stat this-file-does-not-exist: no such file or directory
+			`),
+		},
+		{
+			name:                "getting clientset fails",
+			args:                []string{"--kubeconfig", "testdata/kubeconfig.yaml"},
+			gettingClientsetErr: fmt.Errorf("some get clientset error"),
+			wantError:           true,
+			wantStderr: here.Doc(`
+				Error: could not configure Kubernetes client: some get clientset error
+			`),
+		},
+		{
+			name:          "calling API fails",
+			args:          []string{"--kubeconfig", "testdata/kubeconfig.yaml"},
+			callingAPIErr: fmt.Errorf("some API error"),
+			wantError:     true,
+			wantStderr: here.Doc(`
+				Error: could not complete WhoAmIRequest: some API error
+			`),
+		},
+		{
+			name:          "calling API fails because WhoAmI API is not installed",
+			args:          []string{"--kubeconfig", "testdata/kubeconfig.yaml"},
+			callingAPIErr: fmt.Errorf("the server could not find the requested resource (post whoamirequests.identity.concierge.pinniped.dev)"),
+			wantError:     true,
+			wantStderr: here.Doc(`
+				Error: could not complete WhoAmIRequest (is the Pinniped WhoAmI API running and healthy?): the server could not find the requested resource (post whoamirequests.identity.concierge.pinniped.dev)
+			`),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			getClientset := func(clientConfig clientcmd.ClientConfig, apiGroupSuffix string) (conciergeclientset.Interface, error) {
+				if test.wantAPIGroupSuffix == "" {
+					require.Equal(t, "pinniped.dev", apiGroupSuffix)
+				} else {
+					require.Equal(t, test.wantAPIGroupSuffix, apiGroupSuffix)
+				}
+				if test.gettingClientsetErr != nil {
+					return nil, test.gettingClientsetErr
+				}
+				clientset := fakeconciergeclientset.NewSimpleClientset()
+				clientset.PrependReactor("create", "whoamirequests", func(_ kubetesting.Action) (bool, runtime.Object, error) {
+					if test.callingAPIErr != nil {
+						return true, nil, test.callingAPIErr
+					}
+					groups := []string{"some-group-0", "some-group-1"}
+					if test.groupsOverride != nil {
+						groups = test.groupsOverride
+					}
+					return true, &identityv1alpha1.WhoAmIRequest{
+						Status: identityv1alpha1.WhoAmIRequestStatus{
+							KubernetesUserInfo: identityv1alpha1.KubernetesUserInfo{
+								User: identityv1alpha1.UserInfo{
+									Username: "some-username",
+									Groups:   groups,
+								},
+							},
+						},
+					}, nil
+				})
+				return clientset, nil
+			}
+			cmd := newWhoamiCommand(getClientset)
+
+			stdout, stderr := bytes.NewBuffer([]byte{}), bytes.NewBuffer([]byte{})
+			cmd.SetOut(stdout)
+			cmd.SetErr(stderr)
+			cmd.SetArgs(test.args)
+
+			err := cmd.Execute()
+			if test.wantError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, test.wantStdout, stdout.String())
+			require.Equal(t, test.wantStderr, stderr.String())
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// Status of a bootstrap credential.
+type BootstrapCredentialStatus struct {
+	// Represents the observations of the credential's current state.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// RedeemedAt is the time at which the credential was exchanged for a cluster credential. A credential which has
+	// been redeemed can never be used again.
+	// +optional
+	RedeemedAt *metav1.Time `json:"redeemedAt,omitempty"`
+}
+
+// Spec for a bootstrap credential.
+type BootstrapCredentialSpec struct {
+	// TokenHash is the lowercase hex-encoded SHA-256 hash of the bearer token. The token itself is never stored.
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{64}$`
+	TokenHash string `json:"tokenHash"`
+
+	// Username is the username that will be asserted when the credential is redeemed.
+	// +kubebuilder:validation:MinLength=1
+	Username string `json:"username"`
+
+	// Groups is the list of groups that will be asserted when the credential is redeemed.
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+
+	// ExpiresAt is the time after which the credential can no longer be redeemed. It must be at most 24 hours after
+	// the creation of the BootstrapCredential, otherwise the credential cannot be redeemed at all.
+	ExpiresAt metav1.Time `json:"expiresAt"`
+
+	// Reason is a free-form description of why the credential was issued, recorded for auditing purposes.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// BootstrapCredential describes a single-use, expiring credential which can be exchanged once for cluster credentials
+// using a TokenCredentialRequest. Bootstrap credentials are intended for onboarding new users and for short-lived
+// break-glass access, so that administrators do not need to share their own kubeconfigs.
+//
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories=pinniped;pinniped-authenticator;pinniped-authenticators,scope=Cluster
+// +kubebuilder:printcolumn:name="Username",type=string,JSONPath=`.spec.username`
+// +kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.spec.expiresAt`
+// +kubebuilder:printcolumn:name="Redeemed",type=date,JSONPath=`.status.redeemedAt`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
+type BootstrapCredential struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec of the credential.
+	Spec BootstrapCredentialSpec `json:"spec"`
+
+	// Status of the credential.
+	Status BootstrapCredentialStatus `json:"status,omitempty"`
+}
+
+// List of BootstrapCredential objects.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type BootstrapCredentialList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []BootstrapCredential `json:"items"`
+}
@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -18,6 +18,43 @@ const (
 	ConditionUnknown ConditionStatus = "Unknown"
 )
 
+// These are the types of the conditions which the Concierge sets on authenticators. They are a stable part of the
+// API, so tooling can rely on them. Each authenticator only has the conditions which apply to its kind.
+const (
+	// ConditionTypeReady is set on all authenticators which have conditions. It is true when all other conditions
+	// are true, so "kubectl wait --for=condition=Ready" can be used to wait until an authenticator is usable.
+	ConditionTypeReady = "Ready"
+
+	// ConditionTypeTLSConfigurationValid is set on JWTAuthenticators and WebhookAuthenticators. It is false when
+	// the spec.tls settings cannot be used, e.g. because the CA bundle is invalid.
+	ConditionTypeTLSConfigurationValid = "TLSConfigurationValid"
+
+	// ConditionTypeIssuerReachable is set on JWTAuthenticators. It is false when the OIDC discovery document or the
+	// signing keys of the issuer cannot be fetched.
+	ConditionTypeIssuerReachable = "IssuerReachable"
+
+	// ConditionTypeAudienceValidated is set on JWTAuthenticators. It is false when the authenticator does not handle
+	// a self-test token for its audience as expected, and unknown until the signing keys of the issuer are fetched.
+	ConditionTypeAudienceValidated = "AudienceValidated"
+
+	// ConditionTypeWebhookReachable is set on WebhookAuthenticators. It is false while requests to the webhook are
+	// failing. Each replica of the Concierge reports its own requests, so it reflects the replica which most recently
+	// made requests to the webhook.
+	ConditionTypeWebhookReachable = "WebhookReachable"
+
+	// ConditionTypeSecretValid is set on StaticTokenAuthenticators. It is false when the Secret with the tokens
+	// cannot be found or parsed.
+	ConditionTypeSecretValid = "SecretValid"
+
+	// ConditionTypeAuthenticatorReady is set on SupervisorConnections. It mirrors the Ready condition of the
+	// JWTAuthenticator which is maintained for the connection.
+	ConditionTypeAuthenticatorReady = "AuthenticatorReady"
+
+	// ConditionTypeBootstrapBindingReady is set on SupervisorConnections. It is false when the ClusterRoleBinding for
+	// the bootstrap group cannot be created or updated.
+	ConditionTypeBootstrapBindingReady = "BootstrapBindingReady"
+)
+
 // Condition status of a resource (mirrored from the metav1.Condition type added in Kubernetes 1.19). In a future API
 // version we can switch to using the upstream type.
 // See https://github.com/kubernetes/apimachinery/blob/v0.19.0/pkg/apis/meta/v1/types.go#L1353-L1413.
@@ -0,0 +1,64 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// Status of a service account authenticator.
+type ServiceAccountAuthenticatorStatus struct {
+	// Represents the observations of the authenticator's current state.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// Spec for configuring a service account authenticator.
+type ServiceAccountAuthenticatorSpec struct {
+	// Audiences is the list of audiences for which the token must have been issued. Tokens are validated with a
+	// TokenReview for these audiences, so they should be specific to the Concierge, e.g. the audience of a projected
+	// ServiceAccount token volume, and must not include the default audience of the Kubernetes API server.
+	// +kubebuilder:validation:MinItems=1
+	Audiences []string `json:"audiences"`
+
+	// Namespaces is the list of namespaces whose ServiceAccounts are allowed to authenticate. ServiceAccounts of all
+	// namespaces are allowed when this is omitted.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// ServiceAccountAuthenticator describes the configuration of an authenticator for bound ServiceAccount tokens, such
+// as projected ServiceAccount token volumes or the tokens returned by the TokenRequest API. It allows workloads running
+// in the cluster, such as CI runners, to get cluster credentials through the same TokenCredentialRequest API as human
+// users. The username and groups are the ones of the ServiceAccount, e.g. "system:serviceaccount:ns:name". Legacy
+// ServiceAccount tokens, which are stored in Secrets and never expire, are not accepted.
+//
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories=pinniped;pinniped-authenticator;pinniped-authenticators,scope=Cluster
+// +kubebuilder:printcolumn:name="Audiences",type=string,JSONPath=`.spec.audiences`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
+type ServiceAccountAuthenticator struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec for configuring the authenticator.
+	Spec ServiceAccountAuthenticatorSpec `json:"spec"`
+
+	// Status of the authenticator.
+	Status ServiceAccountAuthenticatorStatus `json:"status,omitempty"`
+}
+
+// List of ServiceAccountAuthenticator objects.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type ServiceAccountAuthenticatorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ServiceAccountAuthenticator `json:"items"`
+}
@@ -1,27 +1,37 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
 
 import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-// +kubebuilder:validation:Enum=KubeClusterSigningCertificate
+// +kubebuilder:validation:Enum=KubeClusterSigningCertificate;ImpersonationProxy
 type StrategyType string
 
 // +kubebuilder:validation:Enum=Success;Error
 type StrategyStatus string
 
-// +kubebuilder:validation:Enum=FetchedKey;CouldNotFetchKey
+// +kubebuilder:validation:Enum=FetchedKey;CouldNotFetchKey;SignedCertificateSigningRequest;CouldNotSignCertificateSigningRequest;Listening;Disabled;ErrorDuringSetup
 type StrategyReason string
 
 const (
 	KubeClusterSigningCertificateStrategyType = StrategyType("KubeClusterSigningCertificate")
+	ImpersonationProxyStrategyType            = StrategyType("ImpersonationProxy")
 
 	SuccessStrategyStatus = StrategyStatus("Success")
 	ErrorStrategyStatus   = StrategyStatus("Error")
 
 	CouldNotFetchKeyStrategyReason = StrategyReason("CouldNotFetchKey")
 	FetchedKeyStrategyReason       = StrategyReason("FetchedKey")
+
+	// These reasons are used by the KubeClusterSigningCertificate strategy when the Concierge is configured to have
+	// client certificates signed by the CertificateSigningRequest API instead of fetching the cluster signing key.
+	SignedCertificateSigningRequestStrategyReason       = StrategyReason("SignedCertificateSigningRequest")
+	CouldNotSignCertificateSigningRequestStrategyReason = StrategyReason("CouldNotSignCertificateSigningRequest")
+
+	ListeningStrategyReason        = StrategyReason("Listening")
+	DisabledStrategyReason         = StrategyReason("Disabled")
+	ErrorDuringSetupStrategyReason = StrategyReason("ErrorDuringSetup")
 )
 
 // Status of a credential issuer.
@@ -32,6 +42,14 @@ type CredentialIssuerStatus struct {
 	// Information needed to form a valid Pinniped-based kubeconfig using this credential issuer.
 	// +optional
 	KubeConfigInfo *CredentialIssuerKubeConfigInfo `json:"kubeConfigInfo,omitempty"`
+
+	// Conditions summarize the strategies for tooling which understands conditions. Each strategy has a condition of
+	// the same type, and the Ready condition is true while any strategy is working.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 // Information needed to form a valid Pinniped-based kubeconfig using this credential issuer.
@@ -63,6 +81,24 @@ type CredentialIssuerStrategy struct {
 
 	// When the status was last checked.
 	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+
+	// Information needed by clients to use the impersonation proxy. Only set for the ImpersonationProxy strategy
+	// while the proxy is listening.
+	// +optional
+	ImpersonationProxyInfo *ImpersonationProxyInfo `json:"impersonationProxyInfo,omitempty"`
+}
+
+// Information needed by clients to use the impersonation proxy of the Concierge, which forwards requests to the
+// Kubernetes API server on behalf of users who authenticated using a Pinniped credential.
+type ImpersonationProxyInfo struct {
+	// The HTTPS URL of the impersonation proxy.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^https://`
+	Endpoint string `json:"endpoint"`
+
+	// The base64-encoded PEM CA bundle which verifies the serving certificate of the impersonation proxy.
+	// +kubebuilder:validation:MinLength=1
+	CertificateAuthorityData string `json:"certificateAuthorityData"`
 }
 
 // Describes the configuration status of a Pinniped credential issuer.
@@ -70,6 +106,8 @@ type CredentialIssuerStrategy struct {
 // +genclient:nonNamespaced
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:resource:categories=pinniped,scope=Cluster
+// +kubebuilder:printcolumn:name="Strategy",type=string,JSONPath=`.status.strategies[?(@.status=="Success")].type`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // +kubebuilder:subresource:status
 type CredentialIssuer struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -153,6 +153,7 @@ func (in *OIDCIdentityProviderSpec) DeepCopyInto(out *OIDCIdentityProviderSpec)
 	in.AuthorizationConfig.DeepCopyInto(&out.AuthorizationConfig)
 	out.Claims = in.Claims
 	out.Client = in.Client
+	out.TokenValidation = in.TokenValidation
 	return
 }
 
@@ -189,6 +190,22 @@ func (in *OIDCIdentityProviderStatus) DeepCopy() *OIDCIdentityProviderStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCTokenValidation) DeepCopyInto(out *OIDCTokenValidation) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCTokenValidation.
+func (in *OIDCTokenValidation) DeepCopy() *OIDCTokenValidation {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCTokenValidation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLSSpec) DeepCopyInto(out *TLSSpec) {
 	*out = *in
@@ -0,0 +1,167 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"time"
+
+	v1alpha1 "go.pinniped.dev/generated/1.17/apis/concierge/authentication/v1alpha1"
+	scheme "go.pinniped.dev/generated/1.17/client/concierge/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// ClientCertificateAuthenticatorsGetter has a method to return a ClientCertificateAuthenticatorInterface.
+// A group's client should implement this interface.
+type ClientCertificateAuthenticatorsGetter interface {
+	ClientCertificateAuthenticators() ClientCertificateAuthenticatorInterface
+}
+
+// ClientCertificateAuthenticatorInterface has methods to work with ClientCertificateAuthenticator resources.
+type ClientCertificateAuthenticatorInterface interface {
+	Create(*v1alpha1.ClientCertificateAuthenticator) (*v1alpha1.ClientCertificateAuthenticator, error)
+	Update(*v1alpha1.ClientCertificateAuthenticator) (*v1alpha1.ClientCertificateAuthenticator, error)
+	UpdateStatus(*v1alpha1.ClientCertificateAuthenticator) (*v1alpha1.ClientCertificateAuthenticator, error)
+	Delete(name string, options *v1.DeleteOptions) error
+	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
+	Get(name string, options v1.GetOptions) (*v1alpha1.ClientCertificateAuthenticator, error)
+	List(opts v1.ListOptions) (*v1alpha1.ClientCertificateAuthenticatorList, error)
+	Watch(opts v1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.ClientCertificateAuthenticator, err error)
+	ClientCertificateAuthenticatorExpansion
+}
+
+// clientCertificateAuthenticators implements ClientCertificateAuthenticatorInterface
+type clientCertificateAuthenticators struct {
+	client rest.Interface
+}
+
+// newClientCertificateAuthenticators returns a ClientCertificateAuthenticators
+func newClientCertificateAuthenticators(c *AuthenticationV1alpha1Client) *clientCertificateAuthenticators {
+	return &clientCertificateAuthenticators{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the clientCertificateAuthenticator, and returns the corresponding clientCertificateAuthenticator object, and an error if there is any.
+func (c *clientCertificateAuthenticators) Get(name string, options v1.GetOptions) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	result = &v1alpha1.ClientCertificateAuthenticator{}
+	err = c.client.Get().
+		Resource("clientcertificateauthenticators").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of ClientCertificateAuthenticators that match those selectors.
+func (c *clientCertificateAuthenticators) List(opts v1.ListOptions) (result *v1alpha1.ClientCertificateAuthenticatorList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.ClientCertificateAuthenticatorList{}
+	err = c.client.Get().
+		Resource("clientcertificateauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested clientCertificateAuthenticators.
+func (c *clientCertificateAuthenticators) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("clientcertificateauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch()
+}
+
+// Create takes the representation of a clientCertificateAuthenticator and creates it.  Returns the server's representation of the clientCertificateAuthenticator, and an error, if there is any.
+func (c *clientCertificateAuthenticators) Create(clientCertificateAuthenticator *v1alpha1.ClientCertificateAuthenticator) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	result = &v1alpha1.ClientCertificateAuthenticator{}
+	err = c.client.Post().
+		Resource("clientcertificateauthenticators").
+		Body(clientCertificateAuthenticator).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a clientCertificateAuthenticator and updates it. Returns the server's representation of the clientCertificateAuthenticator, and an error, if there is any.
+func (c *clientCertificateAuthenticators) Update(clientCertificateAuthenticator *v1alpha1.ClientCertificateAuthenticator) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	result = &v1alpha1.ClientCertificateAuthenticator{}
+	err = c.client.Put().
+		Resource("clientcertificateauthenticators").
+		Name(clientCertificateAuthenticator.Name).
+		Body(clientCertificateAuthenticator).
+		Do().
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *clientCertificateAuthenticators) UpdateStatus(clientCertificateAuthenticator *v1alpha1.ClientCertificateAuthenticator) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	result = &v1alpha1.ClientCertificateAuthenticator{}
+	err = c.client.Put().
+		Resource("clientcertificateauthenticators").
+		Name(clientCertificateAuthenticator.Name).
+		SubResource("status").
+		Body(clientCertificateAuthenticator).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the clientCertificateAuthenticator and deletes it. Returns an error if one occurs.
+func (c *clientCertificateAuthenticators) Delete(name string, options *v1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("clientcertificateauthenticators").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *clientCertificateAuthenticators) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("clientcertificateauthenticators").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched clientCertificateAuthenticator.
+func (c *clientCertificateAuthenticators) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	result = &v1alpha1.ClientCertificateAuthenticator{}
+	err = c.client.Patch(pt).
+		Resource("clientcertificateauthenticators").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
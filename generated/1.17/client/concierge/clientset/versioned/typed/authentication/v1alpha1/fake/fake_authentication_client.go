@@ -15,10 +15,34 @@ type FakeAuthenticationV1alpha1 struct {
 	*testing.Fake
 }
 
+func (c *FakeAuthenticationV1alpha1) BootstrapCredentials() v1alpha1.BootstrapCredentialInterface {
+	return &FakeBootstrapCredentials{c}
+}
+
+func (c *FakeAuthenticationV1alpha1) ClientCertificateAuthenticators() v1alpha1.ClientCertificateAuthenticatorInterface {
+	return &FakeClientCertificateAuthenticators{c}
+}
+
+func (c *FakeAuthenticationV1alpha1) CloudIdentityAuthenticators() v1alpha1.CloudIdentityAuthenticatorInterface {
+	return &FakeCloudIdentityAuthenticators{c}
+}
+
 func (c *FakeAuthenticationV1alpha1) JWTAuthenticators() v1alpha1.JWTAuthenticatorInterface {
 	return &FakeJWTAuthenticators{c}
 }
 
+func (c *FakeAuthenticationV1alpha1) ServiceAccountAuthenticators() v1alpha1.ServiceAccountAuthenticatorInterface {
+	return &FakeServiceAccountAuthenticators{c}
+}
+
+func (c *FakeAuthenticationV1alpha1) StaticTokenAuthenticators() v1alpha1.StaticTokenAuthenticatorInterface {
+	return &FakeStaticTokenAuthenticators{c}
+}
+
+func (c *FakeAuthenticationV1alpha1) SupervisorConnections() v1alpha1.SupervisorConnectionInterface {
+	return &FakeSupervisorConnections{c}
+}
+
 func (c *FakeAuthenticationV1alpha1) WebhookAuthenticators() v1alpha1.WebhookAuthenticatorInterface {
 	return &FakeWebhookAuthenticators{c}
 }
@@ -0,0 +1,167 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"time"
+
+	v1alpha1 "go.pinniped.dev/generated/1.17/apis/concierge/authentication/v1alpha1"
+	scheme "go.pinniped.dev/generated/1.17/client/concierge/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// StaticTokenAuthenticatorsGetter has a method to return a StaticTokenAuthenticatorInterface.
+// A group's client should implement this interface.
+type StaticTokenAuthenticatorsGetter interface {
+	StaticTokenAuthenticators() StaticTokenAuthenticatorInterface
+}
+
+// StaticTokenAuthenticatorInterface has methods to work with StaticTokenAuthenticator resources.
+type StaticTokenAuthenticatorInterface interface {
+	Create(*v1alpha1.StaticTokenAuthenticator) (*v1alpha1.StaticTokenAuthenticator, error)
+	Update(*v1alpha1.StaticTokenAuthenticator) (*v1alpha1.StaticTokenAuthenticator, error)
+	UpdateStatus(*v1alpha1.StaticTokenAuthenticator) (*v1alpha1.StaticTokenAuthenticator, error)
+	Delete(name string, options *v1.DeleteOptions) error
+	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
+	Get(name string, options v1.GetOptions) (*v1alpha1.StaticTokenAuthenticator, error)
+	List(opts v1.ListOptions) (*v1alpha1.StaticTokenAuthenticatorList, error)
+	Watch(opts v1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.StaticTokenAuthenticator, err error)
+	StaticTokenAuthenticatorExpansion
+}
+
+// staticTokenAuthenticators implements StaticTokenAuthenticatorInterface
+type staticTokenAuthenticators struct {
+	client rest.Interface
+}
+
+// newStaticTokenAuthenticators returns a StaticTokenAuthenticators
+func newStaticTokenAuthenticators(c *AuthenticationV1alpha1Client) *staticTokenAuthenticators {
+	return &staticTokenAuthenticators{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the staticTokenAuthenticator, and returns the corresponding staticTokenAuthenticator object, and an error if there is any.
+func (c *staticTokenAuthenticators) Get(name string, options v1.GetOptions) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	result = &v1alpha1.StaticTokenAuthenticator{}
+	err = c.client.Get().
+		Resource("statictokenauthenticators").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of StaticTokenAuthenticators that match those selectors.
+func (c *staticTokenAuthenticators) List(opts v1.ListOptions) (result *v1alpha1.StaticTokenAuthenticatorList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.StaticTokenAuthenticatorList{}
+	err = c.client.Get().
+		Resource("statictokenauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested staticTokenAuthenticators.
+func (c *staticTokenAuthenticators) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("statictokenauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch()
+}
+
+// Create takes the representation of a staticTokenAuthenticator and creates it.  Returns the server's representation of the staticTokenAuthenticator, and an error, if there is any.
+func (c *staticTokenAuthenticators) Create(staticTokenAuthenticator *v1alpha1.StaticTokenAuthenticator) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	result = &v1alpha1.StaticTokenAuthenticator{}
+	err = c.client.Post().
+		Resource("statictokenauthenticators").
+		Body(staticTokenAuthenticator).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a staticTokenAuthenticator and updates it. Returns the server's representation of the staticTokenAuthenticator, and an error, if there is any.
+func (c *staticTokenAuthenticators) Update(staticTokenAuthenticator *v1alpha1.StaticTokenAuthenticator) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	result = &v1alpha1.StaticTokenAuthenticator{}
+	err = c.client.Put().
+		Resource("statictokenauthenticators").
+		Name(staticTokenAuthenticator.Name).
+		Body(staticTokenAuthenticator).
+		Do().
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *staticTokenAuthenticators) UpdateStatus(staticTokenAuthenticator *v1alpha1.StaticTokenAuthenticator) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	result = &v1alpha1.StaticTokenAuthenticator{}
+	err = c.client.Put().
+		Resource("statictokenauthenticators").
+		Name(staticTokenAuthenticator.Name).
+		SubResource("status").
+		Body(staticTokenAuthenticator).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the staticTokenAuthenticator and deletes it. Returns an error if one occurs.
+func (c *staticTokenAuthenticators) Delete(name string, options *v1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("statictokenauthenticators").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *staticTokenAuthenticators) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("statictokenauthenticators").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched staticTokenAuthenticator.
+func (c *staticTokenAuthenticators) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	result = &v1alpha1.StaticTokenAuthenticator{}
+	err = c.client.Patch(pt).
+		Resource("statictokenauthenticators").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
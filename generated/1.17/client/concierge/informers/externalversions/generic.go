@@ -42,8 +42,20 @@ func (f *genericInformer) Lister() cache.GenericLister {
 func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
 	switch resource {
 	// Group=authentication.concierge.pinniped.dev, Version=v1alpha1
+	case v1alpha1.SchemeGroupVersion.WithResource("bootstrapcredentials"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Authentication().V1alpha1().BootstrapCredentials().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("clientcertificateauthenticators"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Authentication().V1alpha1().ClientCertificateAuthenticators().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("cloudidentityauthenticators"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Authentication().V1alpha1().CloudIdentityAuthenticators().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("jwtauthenticators"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Authentication().V1alpha1().JWTAuthenticators().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("serviceaccountauthenticators"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Authentication().V1alpha1().ServiceAccountAuthenticators().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("statictokenauthenticators"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Authentication().V1alpha1().StaticTokenAuthenticators().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("supervisorconnections"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Authentication().V1alpha1().SupervisorConnections().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("webhookauthenticators"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Authentication().V1alpha1().WebhookAuthenticators().Informer()}, nil
 
@@ -0,0 +1,52 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "go.pinniped.dev/generated/1.17/apis/concierge/authentication/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ClientCertificateAuthenticatorLister helps list ClientCertificateAuthenticators.
+type ClientCertificateAuthenticatorLister interface {
+	// List lists all ClientCertificateAuthenticators in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha1.ClientCertificateAuthenticator, err error)
+	// Get retrieves the ClientCertificateAuthenticator from the index for a given name.
+	Get(name string) (*v1alpha1.ClientCertificateAuthenticator, error)
+	ClientCertificateAuthenticatorListerExpansion
+}
+
+// clientCertificateAuthenticatorLister implements the ClientCertificateAuthenticatorLister interface.
+type clientCertificateAuthenticatorLister struct {
+	indexer cache.Indexer
+}
+
+// NewClientCertificateAuthenticatorLister returns a new ClientCertificateAuthenticatorLister.
+func NewClientCertificateAuthenticatorLister(indexer cache.Indexer) ClientCertificateAuthenticatorLister {
+	return &clientCertificateAuthenticatorLister{indexer: indexer}
+}
+
+// List lists all ClientCertificateAuthenticators in the indexer.
+func (s *clientCertificateAuthenticatorLister) List(selector labels.Selector) (ret []*v1alpha1.ClientCertificateAuthenticator, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.ClientCertificateAuthenticator))
+	})
+	return ret, err
+}
+
+// Get retrieves the ClientCertificateAuthenticator from the index for a given name.
+func (s *clientCertificateAuthenticatorLister) Get(name string) (*v1alpha1.ClientCertificateAuthenticator, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("clientcertificateauthenticator"), name)
+	}
+	return obj.(*v1alpha1.ClientCertificateAuthenticator), nil
+}
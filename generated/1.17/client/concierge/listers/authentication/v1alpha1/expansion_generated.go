@@ -5,10 +5,34 @@
 
 package v1alpha1
 
+// BootstrapCredentialListerExpansion allows custom methods to be added to
+// BootstrapCredentialLister.
+type BootstrapCredentialListerExpansion interface{}
+
+// ClientCertificateAuthenticatorListerExpansion allows custom methods to be added to
+// ClientCertificateAuthenticatorLister.
+type ClientCertificateAuthenticatorListerExpansion interface{}
+
+// CloudIdentityAuthenticatorListerExpansion allows custom methods to be added to
+// CloudIdentityAuthenticatorLister.
+type CloudIdentityAuthenticatorListerExpansion interface{}
+
 // JWTAuthenticatorListerExpansion allows custom methods to be added to
 // JWTAuthenticatorLister.
 type JWTAuthenticatorListerExpansion interface{}
 
+// ServiceAccountAuthenticatorListerExpansion allows custom methods to be added to
+// ServiceAccountAuthenticatorLister.
+type ServiceAccountAuthenticatorListerExpansion interface{}
+
+// StaticTokenAuthenticatorListerExpansion allows custom methods to be added to
+// StaticTokenAuthenticatorLister.
+type StaticTokenAuthenticatorListerExpansion interface{}
+
+// SupervisorConnectionListerExpansion allows custom methods to be added to
+// SupervisorConnectionLister.
+type SupervisorConnectionListerExpansion interface{}
+
 // WebhookAuthenticatorListerExpansion allows custom methods to be added to
 // WebhookAuthenticatorLister.
 type WebhookAuthenticatorListerExpansion interface{}
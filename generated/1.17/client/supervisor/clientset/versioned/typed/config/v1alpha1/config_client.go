@@ -14,6 +14,8 @@ import (
 type ConfigV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	FederationDomainsGetter
+	GroupGrantsGetter
+	UserApprovalsGetter
 }
 
 // ConfigV1alpha1Client is used to interact with features provided by the config.supervisor.pinniped.dev group.
@@ -25,6 +27,14 @@ func (c *ConfigV1alpha1Client) FederationDomains(namespace string) FederationDom
 	return newFederationDomains(c, namespace)
 }
 
+func (c *ConfigV1alpha1Client) GroupGrants(namespace string) GroupGrantInterface {
+	return newGroupGrants(c, namespace)
+}
+
+func (c *ConfigV1alpha1Client) UserApprovals(namespace string) UserApprovalInterface {
+	return newUserApprovals(c, namespace)
+}
+
 // NewForConfig creates a new ConfigV1alpha1Client for the given config.
 func NewForConfig(c *rest.Config) (*ConfigV1alpha1Client, error) {
 	config := *c
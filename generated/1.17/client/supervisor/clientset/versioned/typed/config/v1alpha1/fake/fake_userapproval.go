@@ -0,0 +1,115 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "go.pinniped.dev/generated/1.17/apis/supervisor/config/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeUserApprovals implements UserApprovalInterface
+type FakeUserApprovals struct {
+	Fake *FakeConfigV1alpha1
+	ns   string
+}
+
+var userapprovalsResource = schema.GroupVersionResource{Group: "config.supervisor.pinniped.dev", Version: "v1alpha1", Resource: "userapprovals"}
+
+var userapprovalsKind = schema.GroupVersionKind{Group: "config.supervisor.pinniped.dev", Version: "v1alpha1", Kind: "UserApproval"}
+
+// Get takes name of the userApproval, and returns the corresponding userApproval object, and an error if there is any.
+func (c *FakeUserApprovals) Get(name string, options v1.GetOptions) (result *v1alpha1.UserApproval, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(userapprovalsResource, c.ns, name), &v1alpha1.UserApproval{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.UserApproval), err
+}
+
+// List takes label and field selectors, and returns the list of UserApprovals that match those selectors.
+func (c *FakeUserApprovals) List(opts v1.ListOptions) (result *v1alpha1.UserApprovalList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(userapprovalsResource, userapprovalsKind, c.ns, opts), &v1alpha1.UserApprovalList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.UserApprovalList{ListMeta: obj.(*v1alpha1.UserApprovalList).ListMeta}
+	for _, item := range obj.(*v1alpha1.UserApprovalList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested userApprovals.
+func (c *FakeUserApprovals) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(userapprovalsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a userApproval and creates it.  Returns the server's representation of the userApproval, and an error, if there is any.
+func (c *FakeUserApprovals) Create(userApproval *v1alpha1.UserApproval) (result *v1alpha1.UserApproval, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(userapprovalsResource, c.ns, userApproval), &v1alpha1.UserApproval{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.UserApproval), err
+}
+
+// Update takes the representation of a userApproval and updates it. Returns the server's representation of the userApproval, and an error, if there is any.
+func (c *FakeUserApprovals) Update(userApproval *v1alpha1.UserApproval) (result *v1alpha1.UserApproval, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(userapprovalsResource, c.ns, userApproval), &v1alpha1.UserApproval{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.UserApproval), err
+}
+
+// Delete takes name of the userApproval and deletes it. Returns an error if one occurs.
+func (c *FakeUserApprovals) Delete(name string, options *v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(userapprovalsResource, c.ns, name), &v1alpha1.UserApproval{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeUserApprovals) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(userapprovalsResource, c.ns, listOptions)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.UserApprovalList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched userApproval.
+func (c *FakeUserApprovals) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.UserApproval, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(userapprovalsResource, c.ns, name, pt, data, subresources...), &v1alpha1.UserApproval{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.UserApproval), err
+}
@@ -0,0 +1,161 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"time"
+
+	v1alpha1 "go.pinniped.dev/generated/1.17/apis/supervisor/config/v1alpha1"
+	scheme "go.pinniped.dev/generated/1.17/client/supervisor/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// UserApprovalsGetter has a method to return a UserApprovalInterface.
+// A group's client should implement this interface.
+type UserApprovalsGetter interface {
+	UserApprovals(namespace string) UserApprovalInterface
+}
+
+// UserApprovalInterface has methods to work with UserApproval resources.
+type UserApprovalInterface interface {
+	Create(*v1alpha1.UserApproval) (*v1alpha1.UserApproval, error)
+	Update(*v1alpha1.UserApproval) (*v1alpha1.UserApproval, error)
+	Delete(name string, options *v1.DeleteOptions) error
+	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
+	Get(name string, options v1.GetOptions) (*v1alpha1.UserApproval, error)
+	List(opts v1.ListOptions) (*v1alpha1.UserApprovalList, error)
+	Watch(opts v1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.UserApproval, err error)
+	UserApprovalExpansion
+}
+
+// userApprovals implements UserApprovalInterface
+type userApprovals struct {
+	client rest.Interface
+	ns     string
+}
+
+// newUserApprovals returns a UserApprovals
+func newUserApprovals(c *ConfigV1alpha1Client, namespace string) *userApprovals {
+	return &userApprovals{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the userApproval, and returns the corresponding userApproval object, and an error if there is any.
+func (c *userApprovals) Get(name string, options v1.GetOptions) (result *v1alpha1.UserApproval, err error) {
+	result = &v1alpha1.UserApproval{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of UserApprovals that match those selectors.
+func (c *userApprovals) List(opts v1.ListOptions) (result *v1alpha1.UserApprovalList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.UserApprovalList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested userApprovals.
+func (c *userApprovals) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch()
+}
+
+// Create takes the representation of a userApproval and creates it.  Returns the server's representation of the userApproval, and an error, if there is any.
+func (c *userApprovals) Create(userApproval *v1alpha1.UserApproval) (result *v1alpha1.UserApproval, err error) {
+	result = &v1alpha1.UserApproval{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		Body(userApproval).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a userApproval and updates it. Returns the server's representation of the userApproval, and an error, if there is any.
+func (c *userApprovals) Update(userApproval *v1alpha1.UserApproval) (result *v1alpha1.UserApproval, err error) {
+	result = &v1alpha1.UserApproval{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		Name(userApproval.Name).
+		Body(userApproval).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the userApproval and deletes it. Returns an error if one occurs.
+func (c *userApprovals) Delete(name string, options *v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *userApprovals) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched userApproval.
+func (c *userApprovals) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.UserApproval, err error) {
+	result = &v1alpha1.UserApproval{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("userapprovals").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
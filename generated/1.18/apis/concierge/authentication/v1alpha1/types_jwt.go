@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -27,14 +27,47 @@ type JWTAuthenticatorSpec struct {
 	// +kubebuilder:validation:MinLength=1
 	Audience string `json:"audience"`
 
+	// Audiences are additional values of the "aud" JWT claim which are accepted. A token is valid when its "aud"
+	// claim contains Audience or any of these values. This allows one authenticator to validate tokens which were
+	// minted for several related audiences, e.g. while clients migrate from one audience to another.
+	// +optional
+	Audiences []string `json:"audiences,omitempty"`
+
 	// Claims allows customization of the claims that will be mapped to user identity
 	// for Kubernetes access.
 	// +optional
 	Claims JWTTokenClaims `json:"claims"`
 
+	// ClockSkewToleranceSeconds is how many seconds a token is still accepted after its "exp" time, or before its
+	// "nbf" time, to tolerate clocks which disagree with the clock of the OIDC provider. Distributed claims are not
+	// supported for tokens which are only valid because of this tolerance. Defaults to 0.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	ClockSkewToleranceSeconds int32 `json:"clockSkewToleranceSeconds,omitempty"`
+
+	// RevocationList configures a list of revoked users which is polled from the OIDC provider, such as the one which
+	// a Pinniped Supervisor serves for each FederationDomain. Tokens which were issued to a user before the user was
+	// revoked are rejected.
+	// +optional
+	RevocationList *JWTRevocationListSpec `json:"revocationList,omitempty"`
+
 	// TLS configuration for communicating with the OIDC provider.
 	// +optional
-	TLS *TLSSpec `json:"tls,omitempty"`
+	TLS *JWTTLSSpec `json:"tls,omitempty"`
+}
+
+// JWTTLSSpec configures TLS for communicating with the OIDC provider of a JWTAuthenticator.
+type JWTTLSSpec struct {
+	// X.509 Certificate Authority (base64-encoded PEM bundle). If omitted, a default set of system roots will be trusted.
+	// +optional
+	CertificateAuthorityData string `json:"certificateAuthorityData,omitempty"`
+
+	// Name of a Secret in the namespace of the Concierge whose "ca.crt" key contains an X.509 Certificate Authority
+	// (PEM bundle). The authenticator is rebuilt whenever the Secret changes, so the CA can be rotated without editing
+	// the authenticator. Cannot be used together with certificateAuthorityData.
+	// +optional
+	CertificateAuthoritySecretName string `json:"certificateAuthoritySecretName,omitempty"`
 }
 
 // JWTTokenClaims allows customization of the claims that will be mapped to user identity
@@ -49,6 +82,26 @@ type JWTTokenClaims struct {
 	// username from the JWT token. When not specified, it will default to "username".
 	// +optional
 	Username string `json:"username"`
+
+	// UID is the name of the claim which should be read to extract the user's stable
+	// unique identifier from the JWT token. When not specified, it will default to "uid".
+	// If the claim is not present in a token, the user will not have a UID.
+	// +optional
+	UID string `json:"uid,omitempty"`
+}
+
+// JWTRevocationListSpec configures the polling of a list of revoked users.
+type JWTRevocationListSpec struct {
+	// URL of the revocation list, e.g. the issuer of a Pinniped Supervisor FederationDomain followed by
+	// "/revoked-users". The TLS configuration of the authenticator is also used to fetch the list.
+	// +kubebuilder:validation:Pattern=`^https://`
+	URL string `json:"url"`
+
+	// RefreshIntervalSeconds is how often the list is fetched. Defaults to 60.
+	// +optional
+	// +kubebuilder:validation:Minimum=10
+	// +kubebuilder:validation:Maximum=3600
+	RefreshIntervalSeconds int32 `json:"refreshIntervalSeconds,omitempty"`
 }
 
 // JWTAuthenticator describes the configuration of a JWT authenticator.
@@ -61,6 +114,9 @@ type JWTTokenClaims struct {
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:resource:categories=pinniped;pinniped-authenticator;pinniped-authenticators,scope=Cluster
 // +kubebuilder:printcolumn:name="Issuer",type=string,JSONPath=`.spec.issuer`
+// +kubebuilder:printcolumn:name="Audience",type=string,JSONPath=`.spec.audience`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // +kubebuilder:subresource:status
 type JWTAuthenticator struct {
 	metav1.TypeMeta   `json:",inline"`
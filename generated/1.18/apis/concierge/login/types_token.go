@@ -4,6 +4,7 @@
 package login
 
 import (
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -24,6 +25,11 @@ type TokenCredentialRequestStatus struct {
 	// An error message will be returned for an unsuccessful credential request.
 	// +optional
 	Message *string
+
+	// The identity of the authenticated user will be returned for a successful credential request, including the
+	// UID and extra attributes which cannot be carried by a client certificate.
+	// +optional
+	User *authenticationv1.UserInfo
 }
 
 // TokenCredentialRequest submits an IDP-specific credential to Pinniped in exchange for a cluster-specific credential.
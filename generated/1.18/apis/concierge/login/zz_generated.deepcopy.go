@@ -8,6 +8,7 @@
 package login
 
 import (
+	v1 "k8s.io/api/authentication/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -119,6 +120,11 @@ func (in *TokenCredentialRequestStatus) DeepCopyInto(out *TokenCredentialRequest
 		*out = new(string)
 		**out = **in
 	}
+	if in.User != nil {
+		in, out := &in.User, &out.User
+		*out = new(v1.UserInfo)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -0,0 +1,63 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:validation:Enum=Approved;Denied
+type UserApprovalDecision string
+
+const (
+	ApprovedUserApprovalDecision = UserApprovalDecision("Approved")
+	DeniedUserApprovalDecision   = UserApprovalDecision("Denied")
+)
+
+// UserApprovalSpec describes a user who has tried to log in to a FederationDomain which requires approval.
+type UserApprovalSpec struct {
+	// FederationDomainName is the name of the FederationDomain, in the same namespace, to which the user tried to log in.
+	// +kubebuilder:validation:MinLength=1
+	FederationDomainName string `json:"federationDomainName"`
+
+	// Subject is the downstream subject (i.e. the value of the sub claim in the tokens issued by the Supervisor)
+	// of the user, which uniquely identifies the user.
+	// +kubebuilder:validation:MinLength=1
+	Subject string `json:"subject"`
+
+	// Username is the downstream username of the user at the time of their first login, for display purposes.
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// Decision is set by an administrator to approve or deny the user. While it is empty, the user's logins
+	// are held for approval.
+	// +optional
+	Decision UserApprovalDecision `json:"decision,omitempty"`
+}
+
+// UserApproval records whether a user may log in to a FederationDomain which requires approval for new users.
+// The Supervisor creates one for each new user, and an administrator approves or denies it.
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories=pinniped
+// +kubebuilder:printcolumn:name="FederationDomain",type=string,JSONPath=`.spec.federationDomainName`
+// +kubebuilder:printcolumn:name="Username",type=string,JSONPath=`.spec.username`
+// +kubebuilder:printcolumn:name="Decision",type=string,JSONPath=`.spec.decision`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type UserApproval struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec of the user approval.
+	Spec UserApprovalSpec `json:"spec"`
+}
+
+// List of UserApproval objects.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type UserApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []UserApproval `json:"items"`
+}
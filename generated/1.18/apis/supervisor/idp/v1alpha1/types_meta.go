@@ -18,6 +18,23 @@ const (
 	ConditionUnknown ConditionStatus = "Unknown"
 )
 
+// These are the types of the conditions which the Supervisor sets on OIDCIdentityProviders. They are a stable part
+// of the API, so tooling can rely on them.
+const (
+	// ConditionTypeReady is true when all other conditions are true, i.e. when the provider can be used for logins.
+	ConditionTypeReady = "Ready"
+
+	// ConditionTypeClaimsValid is false when the spec.claims settings are invalid, e.g. an invalid username template.
+	ConditionTypeClaimsValid = "ClaimsValid"
+
+	// ConditionTypeClientCredentialsValid is false when the Secret with the client credentials cannot be found or
+	// does not have the expected type and keys.
+	ConditionTypeClientCredentialsValid = "ClientCredentialsValid"
+
+	// ConditionTypeOIDCDiscoverySucceeded is false when the OIDC discovery document of the issuer cannot be fetched.
+	ConditionTypeOIDCDiscoverySucceeded = "OIDCDiscoverySucceeded"
+)
+
 // Condition status of a resource (mirrored from the metav1.Condition type added in Kubernetes 1.19). In a future API
 // version we can switch to using the upstream type.
 // See https://github.com/kubernetes/apimachinery/blob/v0.19.0/pkg/apis/meta/v1/types.go#L1353-L1413.
@@ -0,0 +1,120 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "go.pinniped.dev/generated/1.18/apis/concierge/authentication/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeBootstrapCredentials implements BootstrapCredentialInterface
+type FakeBootstrapCredentials struct {
+	Fake *FakeAuthenticationV1alpha1
+}
+
+var bootstrapcredentialsResource = schema.GroupVersionResource{Group: "authentication.concierge.pinniped.dev", Version: "v1alpha1", Resource: "bootstrapcredentials"}
+
+var bootstrapcredentialsKind = schema.GroupVersionKind{Group: "authentication.concierge.pinniped.dev", Version: "v1alpha1", Kind: "BootstrapCredential"}
+
+// Get takes name of the bootstrapCredential, and returns the corresponding bootstrapCredential object, and an error if there is any.
+func (c *FakeBootstrapCredentials) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.BootstrapCredential, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(bootstrapcredentialsResource, name), &v1alpha1.BootstrapCredential{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.BootstrapCredential), err
+}
+
+// List takes label and field selectors, and returns the list of BootstrapCredentials that match those selectors.
+func (c *FakeBootstrapCredentials) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.BootstrapCredentialList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(bootstrapcredentialsResource, bootstrapcredentialsKind, opts), &v1alpha1.BootstrapCredentialList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.BootstrapCredentialList{ListMeta: obj.(*v1alpha1.BootstrapCredentialList).ListMeta}
+	for _, item := range obj.(*v1alpha1.BootstrapCredentialList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested bootstrapCredentials.
+func (c *FakeBootstrapCredentials) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(bootstrapcredentialsResource, opts))
+}
+
+// Create takes the representation of a bootstrapCredential and creates it.  Returns the server's representation of the bootstrapCredential, and an error, if there is any.
+func (c *FakeBootstrapCredentials) Create(ctx context.Context, bootstrapCredential *v1alpha1.BootstrapCredential, opts v1.CreateOptions) (result *v1alpha1.BootstrapCredential, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(bootstrapcredentialsResource, bootstrapCredential), &v1alpha1.BootstrapCredential{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.BootstrapCredential), err
+}
+
+// Update takes the representation of a bootstrapCredential and updates it. Returns the server's representation of the bootstrapCredential, and an error, if there is any.
+func (c *FakeBootstrapCredentials) Update(ctx context.Context, bootstrapCredential *v1alpha1.BootstrapCredential, opts v1.UpdateOptions) (result *v1alpha1.BootstrapCredential, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(bootstrapcredentialsResource, bootstrapCredential), &v1alpha1.BootstrapCredential{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.BootstrapCredential), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeBootstrapCredentials) UpdateStatus(ctx context.Context, bootstrapCredential *v1alpha1.BootstrapCredential, opts v1.UpdateOptions) (*v1alpha1.BootstrapCredential, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateSubresourceAction(bootstrapcredentialsResource, "status", bootstrapCredential), &v1alpha1.BootstrapCredential{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.BootstrapCredential), err
+}
+
+// Delete takes name of the bootstrapCredential and deletes it. Returns an error if one occurs.
+func (c *FakeBootstrapCredentials) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(bootstrapcredentialsResource, name), &v1alpha1.BootstrapCredential{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeBootstrapCredentials) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(bootstrapcredentialsResource, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.BootstrapCredentialList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched bootstrapCredential.
+func (c *FakeBootstrapCredentials) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.BootstrapCredential, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(bootstrapcredentialsResource, name, pt, data, subresources...), &v1alpha1.BootstrapCredential{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.BootstrapCredential), err
+}
@@ -0,0 +1,120 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "go.pinniped.dev/generated/1.18/apis/concierge/authentication/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeCloudIdentityAuthenticators implements CloudIdentityAuthenticatorInterface
+type FakeCloudIdentityAuthenticators struct {
+	Fake *FakeAuthenticationV1alpha1
+}
+
+var cloudidentityauthenticatorsResource = schema.GroupVersionResource{Group: "authentication.concierge.pinniped.dev", Version: "v1alpha1", Resource: "cloudidentityauthenticators"}
+
+var cloudidentityauthenticatorsKind = schema.GroupVersionKind{Group: "authentication.concierge.pinniped.dev", Version: "v1alpha1", Kind: "CloudIdentityAuthenticator"}
+
+// Get takes name of the cloudIdentityAuthenticator, and returns the corresponding cloudIdentityAuthenticator object, and an error if there is any.
+func (c *FakeCloudIdentityAuthenticators) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.CloudIdentityAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(cloudidentityauthenticatorsResource, name), &v1alpha1.CloudIdentityAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.CloudIdentityAuthenticator), err
+}
+
+// List takes label and field selectors, and returns the list of CloudIdentityAuthenticators that match those selectors.
+func (c *FakeCloudIdentityAuthenticators) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.CloudIdentityAuthenticatorList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(cloudidentityauthenticatorsResource, cloudidentityauthenticatorsKind, opts), &v1alpha1.CloudIdentityAuthenticatorList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.CloudIdentityAuthenticatorList{ListMeta: obj.(*v1alpha1.CloudIdentityAuthenticatorList).ListMeta}
+	for _, item := range obj.(*v1alpha1.CloudIdentityAuthenticatorList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested cloudIdentityAuthenticators.
+func (c *FakeCloudIdentityAuthenticators) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(cloudidentityauthenticatorsResource, opts))
+}
+
+// Create takes the representation of a cloudIdentityAuthenticator and creates it.  Returns the server's representation of the cloudIdentityAuthenticator, and an error, if there is any.
+func (c *FakeCloudIdentityAuthenticators) Create(ctx context.Context, cloudIdentityAuthenticator *v1alpha1.CloudIdentityAuthenticator, opts v1.CreateOptions) (result *v1alpha1.CloudIdentityAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(cloudidentityauthenticatorsResource, cloudIdentityAuthenticator), &v1alpha1.CloudIdentityAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.CloudIdentityAuthenticator), err
+}
+
+// Update takes the representation of a cloudIdentityAuthenticator and updates it. Returns the server's representation of the cloudIdentityAuthenticator, and an error, if there is any.
+func (c *FakeCloudIdentityAuthenticators) Update(ctx context.Context, cloudIdentityAuthenticator *v1alpha1.CloudIdentityAuthenticator, opts v1.UpdateOptions) (result *v1alpha1.CloudIdentityAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(cloudidentityauthenticatorsResource, cloudIdentityAuthenticator), &v1alpha1.CloudIdentityAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.CloudIdentityAuthenticator), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeCloudIdentityAuthenticators) UpdateStatus(ctx context.Context, cloudIdentityAuthenticator *v1alpha1.CloudIdentityAuthenticator, opts v1.UpdateOptions) (*v1alpha1.CloudIdentityAuthenticator, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateSubresourceAction(cloudidentityauthenticatorsResource, "status", cloudIdentityAuthenticator), &v1alpha1.CloudIdentityAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.CloudIdentityAuthenticator), err
+}
+
+// Delete takes name of the cloudIdentityAuthenticator and deletes it. Returns an error if one occurs.
+func (c *FakeCloudIdentityAuthenticators) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(cloudidentityauthenticatorsResource, name), &v1alpha1.CloudIdentityAuthenticator{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeCloudIdentityAuthenticators) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(cloudidentityauthenticatorsResource, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.CloudIdentityAuthenticatorList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched cloudIdentityAuthenticator.
+func (c *FakeCloudIdentityAuthenticators) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.CloudIdentityAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(cloudidentityauthenticatorsResource, name, pt, data, subresources...), &v1alpha1.CloudIdentityAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.CloudIdentityAuthenticator), err
+}
@@ -0,0 +1,120 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "go.pinniped.dev/generated/1.18/apis/concierge/authentication/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeStaticTokenAuthenticators implements StaticTokenAuthenticatorInterface
+type FakeStaticTokenAuthenticators struct {
+	Fake *FakeAuthenticationV1alpha1
+}
+
+var statictokenauthenticatorsResource = schema.GroupVersionResource{Group: "authentication.concierge.pinniped.dev", Version: "v1alpha1", Resource: "statictokenauthenticators"}
+
+var statictokenauthenticatorsKind = schema.GroupVersionKind{Group: "authentication.concierge.pinniped.dev", Version: "v1alpha1", Kind: "StaticTokenAuthenticator"}
+
+// Get takes name of the staticTokenAuthenticator, and returns the corresponding staticTokenAuthenticator object, and an error if there is any.
+func (c *FakeStaticTokenAuthenticators) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(statictokenauthenticatorsResource, name), &v1alpha1.StaticTokenAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.StaticTokenAuthenticator), err
+}
+
+// List takes label and field selectors, and returns the list of StaticTokenAuthenticators that match those selectors.
+func (c *FakeStaticTokenAuthenticators) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.StaticTokenAuthenticatorList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(statictokenauthenticatorsResource, statictokenauthenticatorsKind, opts), &v1alpha1.StaticTokenAuthenticatorList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.StaticTokenAuthenticatorList{ListMeta: obj.(*v1alpha1.StaticTokenAuthenticatorList).ListMeta}
+	for _, item := range obj.(*v1alpha1.StaticTokenAuthenticatorList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested staticTokenAuthenticators.
+func (c *FakeStaticTokenAuthenticators) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(statictokenauthenticatorsResource, opts))
+}
+
+// Create takes the representation of a staticTokenAuthenticator and creates it.  Returns the server's representation of the staticTokenAuthenticator, and an error, if there is any.
+func (c *FakeStaticTokenAuthenticators) Create(ctx context.Context, staticTokenAuthenticator *v1alpha1.StaticTokenAuthenticator, opts v1.CreateOptions) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(statictokenauthenticatorsResource, staticTokenAuthenticator), &v1alpha1.StaticTokenAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.StaticTokenAuthenticator), err
+}
+
+// Update takes the representation of a staticTokenAuthenticator and updates it. Returns the server's representation of the staticTokenAuthenticator, and an error, if there is any.
+func (c *FakeStaticTokenAuthenticators) Update(ctx context.Context, staticTokenAuthenticator *v1alpha1.StaticTokenAuthenticator, opts v1.UpdateOptions) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(statictokenauthenticatorsResource, staticTokenAuthenticator), &v1alpha1.StaticTokenAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.StaticTokenAuthenticator), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeStaticTokenAuthenticators) UpdateStatus(ctx context.Context, staticTokenAuthenticator *v1alpha1.StaticTokenAuthenticator, opts v1.UpdateOptions) (*v1alpha1.StaticTokenAuthenticator, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateSubresourceAction(statictokenauthenticatorsResource, "status", staticTokenAuthenticator), &v1alpha1.StaticTokenAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.StaticTokenAuthenticator), err
+}
+
+// Delete takes name of the staticTokenAuthenticator and deletes it. Returns an error if one occurs.
+func (c *FakeStaticTokenAuthenticators) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(statictokenauthenticatorsResource, name), &v1alpha1.StaticTokenAuthenticator{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeStaticTokenAuthenticators) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(statictokenauthenticatorsResource, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.StaticTokenAuthenticatorList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched staticTokenAuthenticator.
+func (c *FakeStaticTokenAuthenticators) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(statictokenauthenticatorsResource, name, pt, data, subresources...), &v1alpha1.StaticTokenAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.StaticTokenAuthenticator), err
+}
@@ -0,0 +1,171 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "go.pinniped.dev/generated/1.18/apis/concierge/authentication/v1alpha1"
+	scheme "go.pinniped.dev/generated/1.18/client/concierge/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// ServiceAccountAuthenticatorsGetter has a method to return a ServiceAccountAuthenticatorInterface.
+// A group's client should implement this interface.
+type ServiceAccountAuthenticatorsGetter interface {
+	ServiceAccountAuthenticators() ServiceAccountAuthenticatorInterface
+}
+
+// ServiceAccountAuthenticatorInterface has methods to work with ServiceAccountAuthenticator resources.
+type ServiceAccountAuthenticatorInterface interface {
+	Create(ctx context.Context, serviceAccountAuthenticator *v1alpha1.ServiceAccountAuthenticator, opts v1.CreateOptions) (*v1alpha1.ServiceAccountAuthenticator, error)
+	Update(ctx context.Context, serviceAccountAuthenticator *v1alpha1.ServiceAccountAuthenticator, opts v1.UpdateOptions) (*v1alpha1.ServiceAccountAuthenticator, error)
+	UpdateStatus(ctx context.Context, serviceAccountAuthenticator *v1alpha1.ServiceAccountAuthenticator, opts v1.UpdateOptions) (*v1alpha1.ServiceAccountAuthenticator, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.ServiceAccountAuthenticator, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.ServiceAccountAuthenticatorList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.ServiceAccountAuthenticator, err error)
+	ServiceAccountAuthenticatorExpansion
+}
+
+// serviceAccountAuthenticators implements ServiceAccountAuthenticatorInterface
+type serviceAccountAuthenticators struct {
+	client rest.Interface
+}
+
+// newServiceAccountAuthenticators returns a ServiceAccountAuthenticators
+func newServiceAccountAuthenticators(c *AuthenticationV1alpha1Client) *serviceAccountAuthenticators {
+	return &serviceAccountAuthenticators{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the serviceAccountAuthenticator, and returns the corresponding serviceAccountAuthenticator object, and an error if there is any.
+func (c *serviceAccountAuthenticators) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.ServiceAccountAuthenticator, err error) {
+	result = &v1alpha1.ServiceAccountAuthenticator{}
+	err = c.client.Get().
+		Resource("serviceaccountauthenticators").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of ServiceAccountAuthenticators that match those selectors.
+func (c *serviceAccountAuthenticators) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.ServiceAccountAuthenticatorList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.ServiceAccountAuthenticatorList{}
+	err = c.client.Get().
+		Resource("serviceaccountauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested serviceAccountAuthenticators.
+func (c *serviceAccountAuthenticators) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("serviceaccountauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a serviceAccountAuthenticator and creates it.  Returns the server's representation of the serviceAccountAuthenticator, and an error, if there is any.
+func (c *serviceAccountAuthenticators) Create(ctx context.Context, serviceAccountAuthenticator *v1alpha1.ServiceAccountAuthenticator, opts v1.CreateOptions) (result *v1alpha1.ServiceAccountAuthenticator, err error) {
+	result = &v1alpha1.ServiceAccountAuthenticator{}
+	err = c.client.Post().
+		Resource("serviceaccountauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(serviceAccountAuthenticator).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a serviceAccountAuthenticator and updates it. Returns the server's representation of the serviceAccountAuthenticator, and an error, if there is any.
+func (c *serviceAccountAuthenticators) Update(ctx context.Context, serviceAccountAuthenticator *v1alpha1.ServiceAccountAuthenticator, opts v1.UpdateOptions) (result *v1alpha1.ServiceAccountAuthenticator, err error) {
+	result = &v1alpha1.ServiceAccountAuthenticator{}
+	err = c.client.Put().
+		Resource("serviceaccountauthenticators").
+		Name(serviceAccountAuthenticator.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(serviceAccountAuthenticator).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *serviceAccountAuthenticators) UpdateStatus(ctx context.Context, serviceAccountAuthenticator *v1alpha1.ServiceAccountAuthenticator, opts v1.UpdateOptions) (result *v1alpha1.ServiceAccountAuthenticator, err error) {
+	result = &v1alpha1.ServiceAccountAuthenticator{}
+	err = c.client.Put().
+		Resource("serviceaccountauthenticators").
+		Name(serviceAccountAuthenticator.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(serviceAccountAuthenticator).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the serviceAccountAuthenticator and deletes it. Returns an error if one occurs.
+func (c *serviceAccountAuthenticators) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("serviceaccountauthenticators").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *serviceAccountAuthenticators) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("serviceaccountauthenticators").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched serviceAccountAuthenticator.
+func (c *serviceAccountAuthenticators) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.ServiceAccountAuthenticator, err error) {
+	result = &v1alpha1.ServiceAccountAuthenticator{}
+	err = c.client.Patch(pt).
+		Resource("serviceaccountauthenticators").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
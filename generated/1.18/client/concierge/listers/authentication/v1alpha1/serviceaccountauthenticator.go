@@ -0,0 +1,52 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "go.pinniped.dev/generated/1.18/apis/concierge/authentication/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ServiceAccountAuthenticatorLister helps list ServiceAccountAuthenticators.
+type ServiceAccountAuthenticatorLister interface {
+	// List lists all ServiceAccountAuthenticators in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha1.ServiceAccountAuthenticator, err error)
+	// Get retrieves the ServiceAccountAuthenticator from the index for a given name.
+	Get(name string) (*v1alpha1.ServiceAccountAuthenticator, error)
+	ServiceAccountAuthenticatorListerExpansion
+}
+
+// serviceAccountAuthenticatorLister implements the ServiceAccountAuthenticatorLister interface.
+type serviceAccountAuthenticatorLister struct {
+	indexer cache.Indexer
+}
+
+// NewServiceAccountAuthenticatorLister returns a new ServiceAccountAuthenticatorLister.
+func NewServiceAccountAuthenticatorLister(indexer cache.Indexer) ServiceAccountAuthenticatorLister {
+	return &serviceAccountAuthenticatorLister{indexer: indexer}
+}
+
+// List lists all ServiceAccountAuthenticators in the indexer.
+func (s *serviceAccountAuthenticatorLister) List(selector labels.Selector) (ret []*v1alpha1.ServiceAccountAuthenticator, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.ServiceAccountAuthenticator))
+	})
+	return ret, err
+}
+
+// Get retrieves the ServiceAccountAuthenticator from the index for a given name.
+func (s *serviceAccountAuthenticatorLister) Get(name string) (*v1alpha1.ServiceAccountAuthenticator, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("serviceaccountauthenticator"), name)
+	}
+	return obj.(*v1alpha1.ServiceAccountAuthenticator), nil
+}
@@ -19,6 +19,14 @@ func (c *FakeConfigV1alpha1) FederationDomains(namespace string) v1alpha1.Federa
 	return &FakeFederationDomains{c, namespace}
 }
 
+func (c *FakeConfigV1alpha1) GroupGrants(namespace string) v1alpha1.GroupGrantInterface {
+	return &FakeGroupGrants{c, namespace}
+}
+
+func (c *FakeConfigV1alpha1) UserApprovals(namespace string) v1alpha1.UserApprovalInterface {
+	return &FakeUserApprovals{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeConfigV1alpha1) RESTClient() rest.Interface {
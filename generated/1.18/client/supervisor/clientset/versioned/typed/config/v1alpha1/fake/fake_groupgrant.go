@@ -0,0 +1,129 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "go.pinniped.dev/generated/1.18/apis/supervisor/config/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeGroupGrants implements GroupGrantInterface
+type FakeGroupGrants struct {
+	Fake *FakeConfigV1alpha1
+	ns   string
+}
+
+var groupgrantsResource = schema.GroupVersionResource{Group: "config.supervisor.pinniped.dev", Version: "v1alpha1", Resource: "groupgrants"}
+
+var groupgrantsKind = schema.GroupVersionKind{Group: "config.supervisor.pinniped.dev", Version: "v1alpha1", Kind: "GroupGrant"}
+
+// Get takes name of the groupGrant, and returns the corresponding groupGrant object, and an error if there is any.
+func (c *FakeGroupGrants) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.GroupGrant, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(groupgrantsResource, c.ns, name), &v1alpha1.GroupGrant{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.GroupGrant), err
+}
+
+// List takes label and field selectors, and returns the list of GroupGrants that match those selectors.
+func (c *FakeGroupGrants) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.GroupGrantList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(groupgrantsResource, groupgrantsKind, c.ns, opts), &v1alpha1.GroupGrantList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.GroupGrantList{ListMeta: obj.(*v1alpha1.GroupGrantList).ListMeta}
+	for _, item := range obj.(*v1alpha1.GroupGrantList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested groupGrants.
+func (c *FakeGroupGrants) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(groupgrantsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a groupGrant and creates it.  Returns the server's representation of the groupGrant, and an error, if there is any.
+func (c *FakeGroupGrants) Create(ctx context.Context, groupGrant *v1alpha1.GroupGrant, opts v1.CreateOptions) (result *v1alpha1.GroupGrant, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(groupgrantsResource, c.ns, groupGrant), &v1alpha1.GroupGrant{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.GroupGrant), err
+}
+
+// Update takes the representation of a groupGrant and updates it. Returns the server's representation of the groupGrant, and an error, if there is any.
+func (c *FakeGroupGrants) Update(ctx context.Context, groupGrant *v1alpha1.GroupGrant, opts v1.UpdateOptions) (result *v1alpha1.GroupGrant, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(groupgrantsResource, c.ns, groupGrant), &v1alpha1.GroupGrant{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.GroupGrant), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeGroupGrants) UpdateStatus(ctx context.Context, groupGrant *v1alpha1.GroupGrant, opts v1.UpdateOptions) (*v1alpha1.GroupGrant, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(groupgrantsResource, "status", c.ns, groupGrant), &v1alpha1.GroupGrant{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.GroupGrant), err
+}
+
+// Delete takes name of the groupGrant and deletes it. Returns an error if one occurs.
+func (c *FakeGroupGrants) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(groupgrantsResource, c.ns, name), &v1alpha1.GroupGrant{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeGroupGrants) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(groupgrantsResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.GroupGrantList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched groupGrant.
+func (c *FakeGroupGrants) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.GroupGrant, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(groupgrantsResource, c.ns, name, pt, data, subresources...), &v1alpha1.GroupGrant{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.GroupGrant), err
+}
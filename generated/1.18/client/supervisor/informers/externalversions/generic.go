@@ -43,6 +43,10 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	// Group=config.supervisor.pinniped.dev, Version=v1alpha1
 	case v1alpha1.SchemeGroupVersion.WithResource("federationdomains"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Config().V1alpha1().FederationDomains().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("groupgrants"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Config().V1alpha1().GroupGrants().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("userapprovals"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Config().V1alpha1().UserApprovals().Informer()}, nil
 
 		// Group=idp.supervisor.pinniped.dev, Version=v1alpha1
 	case idpv1alpha1.SchemeGroupVersion.WithResource("oidcidentityproviders"):
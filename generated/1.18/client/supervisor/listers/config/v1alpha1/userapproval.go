@@ -0,0 +1,81 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "go.pinniped.dev/generated/1.18/apis/supervisor/config/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// UserApprovalLister helps list UserApprovals.
+type UserApprovalLister interface {
+	// List lists all UserApprovals in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha1.UserApproval, err error)
+	// UserApprovals returns an object that can list and get UserApprovals.
+	UserApprovals(namespace string) UserApprovalNamespaceLister
+	UserApprovalListerExpansion
+}
+
+// userApprovalLister implements the UserApprovalLister interface.
+type userApprovalLister struct {
+	indexer cache.Indexer
+}
+
+// NewUserApprovalLister returns a new UserApprovalLister.
+func NewUserApprovalLister(indexer cache.Indexer) UserApprovalLister {
+	return &userApprovalLister{indexer: indexer}
+}
+
+// List lists all UserApprovals in the indexer.
+func (s *userApprovalLister) List(selector labels.Selector) (ret []*v1alpha1.UserApproval, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.UserApproval))
+	})
+	return ret, err
+}
+
+// UserApprovals returns an object that can list and get UserApprovals.
+func (s *userApprovalLister) UserApprovals(namespace string) UserApprovalNamespaceLister {
+	return userApprovalNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// UserApprovalNamespaceLister helps list and get UserApprovals.
+type UserApprovalNamespaceLister interface {
+	// List lists all UserApprovals in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v1alpha1.UserApproval, err error)
+	// Get retrieves the UserApproval from the indexer for a given namespace and name.
+	Get(name string) (*v1alpha1.UserApproval, error)
+	UserApprovalNamespaceListerExpansion
+}
+
+// userApprovalNamespaceLister implements the UserApprovalNamespaceLister
+// interface.
+type userApprovalNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all UserApprovals in the indexer for a given namespace.
+func (s userApprovalNamespaceLister) List(selector labels.Selector) (ret []*v1alpha1.UserApproval, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.UserApproval))
+	})
+	return ret, err
+}
+
+// Get retrieves the UserApproval from the indexer for a given namespace and name.
+func (s userApprovalNamespaceLister) Get(name string) (*v1alpha1.UserApproval, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("userapproval"), name)
+	}
+	return obj.(*v1alpha1.UserApproval), nil
+}
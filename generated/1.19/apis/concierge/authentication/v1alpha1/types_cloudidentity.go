@@ -0,0 +1,108 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// Status of a cloud identity authenticator.
+type CloudIdentityAuthenticatorStatus struct {
+	// Represents the observations of the authenticator's current state.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// Spec for configuring a cloud identity authenticator. Exactly one of AWS or GCP must be specified.
+type CloudIdentityAuthenticatorSpec struct {
+	// AWS configures the authenticator to accept presigned AWS STS GetCallerIdentity requests.
+	// +optional
+	AWS *AWSIdentitySpec `json:"aws,omitempty"`
+
+	// GCP configures the authenticator to accept Google-signed identity tokens, such as those returned by
+	// the GCE metadata server.
+	// +optional
+	GCP *GCPIdentitySpec `json:"gcp,omitempty"`
+
+	// Mappings allows particular cloud principals to be given a different username and/or additional groups.
+	// Principals which do not match any mapping are authenticated using their canonical principal name and no groups.
+	// +optional
+	Mappings []CloudIdentityMapping `json:"mappings,omitempty"`
+}
+
+// AWSIdentitySpec configures validation of presigned AWS STS GetCallerIdentity requests.
+//
+// The token is expected to be in the format used by aws-iam-authenticator and `aws eks get-token`, i.e.
+// "k8s-aws-v1." followed by the unpadded base64url encoding of the presigned URL.
+type AWSIdentitySpec struct {
+	// ClusterID is the required value of the signed "x-k8s-aws-id" header of the presigned request. Binding tokens to a
+	// cluster ID prevents a token minted for one cluster from being replayed against another.
+	// +kubebuilder:validation:MinLength=1
+	ClusterID string `json:"clusterID"`
+
+	// AccountIDs is the list of AWS account IDs whose principals are allowed to authenticate.
+	// +kubebuilder:validation:MinItems=1
+	AccountIDs []string `json:"accountIDs"`
+}
+
+// GCPIdentitySpec configures validation of Google-signed identity tokens.
+type GCPIdentitySpec struct {
+	// Audience is the required value of the "aud" claim of the identity token.
+	// +kubebuilder:validation:MinLength=1
+	Audience string `json:"audience"`
+
+	// ProjectIDs is the list of GCP project IDs whose service accounts are allowed to authenticate.
+	// +kubebuilder:validation:MinItems=1
+	ProjectIDs []string `json:"projectIDs"`
+}
+
+// CloudIdentityMapping maps a single cloud principal to a Kubernetes username and groups.
+type CloudIdentityMapping struct {
+	// Principal is the canonical name of the cloud principal. For AWS, this is an IAM user or role ARN (assumed role
+	// sessions are canonicalized to their role ARN, e.g., "arn:aws:iam::123456789012:role/ci-runner"). For GCP, this is
+	// the service account email address.
+	// +kubebuilder:validation:MinLength=1
+	Principal string `json:"principal"`
+
+	// Username overrides the username of the principal. When not specified, the principal name is used.
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// Groups is the list of groups that the principal will be a member of.
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+}
+
+// CloudIdentityAuthenticator describes the configuration of an authenticator for cloud workload identities.
+//
+// A CloudIdentityAuthenticator allows non-interactive callers running outside of the cluster (e.g., on EC2 or GCE) to
+// exchange a credential proving their cloud identity for cluster credentials.
+//
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories=pinniped;pinniped-authenticator;pinniped-authenticators,scope=Cluster
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
+type CloudIdentityAuthenticator struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec for configuring the authenticator.
+	Spec CloudIdentityAuthenticatorSpec `json:"spec"`
+
+	// Status of the authenticator.
+	Status CloudIdentityAuthenticatorStatus `json:"status,omitempty"`
+}
+
+// List of CloudIdentityAuthenticator objects.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type CloudIdentityAuthenticatorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []CloudIdentityAuthenticator `json:"items"`
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// Status of a Supervisor connection.
+type SupervisorConnectionStatus struct {
+	// Represents the observations of the connection's current state.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// JWTAuthenticatorName is the name of the JWTAuthenticator which is maintained for this connection. Clients use it
+	// in their kubeconfig, e.g. with "pinniped get kubeconfig --concierge-authenticator-name".
+	// +optional
+	JWTAuthenticatorName string `json:"jwtAuthenticatorName,omitempty"`
+}
+
+// Spec for configuring a Supervisor connection.
+type SupervisorConnectionSpec struct {
+	// Issuer is the issuer URL of the FederationDomain of the Supervisor which users log in with.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^https://`
+	Issuer string `json:"issuer"`
+
+	// Audience is the audience of the cluster-scoped tokens which the Supervisor issues for this cluster. It must be
+	// unique to this cluster, so tokens for one cluster cannot be used with another one.
+	// +kubebuilder:validation:MinLength=1
+	Audience string `json:"audience"`
+
+	// TLS configuration for communicating with the Supervisor.
+	// +optional
+	TLS *TLSSpec `json:"tls,omitempty"`
+
+	// Bootstrap grants initial access to a group of users, so the cluster is usable as soon as it is connected.
+	// When omitted, no access is granted and all RBAC bindings must be created separately.
+	// +optional
+	Bootstrap *SupervisorConnectionBootstrapSpec `json:"bootstrap,omitempty"`
+}
+
+// SupervisorConnectionBootstrapSpec describes the access which is granted to a group as soon as the cluster is
+// connected to the Supervisor.
+type SupervisorConnectionBootstrapSpec struct {
+	// Group is the name of the group, as asserted by the Supervisor, which is granted access.
+	// +kubebuilder:validation:MinLength=1
+	Group string `json:"group"`
+
+	// ClusterRole is the name of the ClusterRole which is bound to the group with a ClusterRoleBinding. When not
+	// specified, it will default to "view". The Concierge must be allowed to bind this ClusterRole.
+	// +optional
+	ClusterRole string `json:"clusterRole,omitempty"`
+}
+
+// SupervisorConnection joins the cluster to a central Pinniped Supervisor.
+//
+// The Concierge maintains a JWTAuthenticator which trusts the tokens issued by the Supervisor for this cluster,
+// optionally binds a ClusterRole to a bootstrap group, and reports whether the connection is ready in its status.
+// The JWTAuthenticator and the ClusterRoleBinding are owned by the SupervisorConnection, so they are deleted along
+// with it. This is only done when the Concierge was installed with the Supervisor connection controller enabled.
+//
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories=pinniped,scope=Cluster
+// +kubebuilder:printcolumn:name="Issuer",type=string,JSONPath=`.spec.issuer`
+// +kubebuilder:printcolumn:name="Audience",type=string,JSONPath=`.spec.audience`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
+type SupervisorConnection struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec for configuring the connection.
+	Spec SupervisorConnectionSpec `json:"spec"`
+
+	// Status of the connection.
+	Status SupervisorConnectionStatus `json:"status,omitempty"`
+}
+
+// List of SupervisorConnection objects.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type SupervisorConnectionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []SupervisorConnection `json:"items"`
+}
@@ -11,6 +11,7 @@ import (
 	unsafe "unsafe"
 
 	login "go.pinniped.dev/generated/1.19/apis/concierge/login"
+	v1 "k8s.io/api/authentication/v1"
 	conversion "k8s.io/apimachinery/pkg/conversion"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
@@ -180,6 +181,7 @@ func Convert_login_TokenCredentialRequestSpec_To_v1alpha1_TokenCredentialRequest
 func autoConvert_v1alpha1_TokenCredentialRequestStatus_To_login_TokenCredentialRequestStatus(in *TokenCredentialRequestStatus, out *login.TokenCredentialRequestStatus, s conversion.Scope) error {
 	out.Credential = (*login.ClusterCredential)(unsafe.Pointer(in.Credential))
 	out.Message = (*string)(unsafe.Pointer(in.Message))
+	out.User = (*v1.UserInfo)(unsafe.Pointer(in.User))
 	return nil
 }
 
@@ -191,6 +193,7 @@ func Convert_v1alpha1_TokenCredentialRequestStatus_To_login_TokenCredentialReque
 func autoConvert_login_TokenCredentialRequestStatus_To_v1alpha1_TokenCredentialRequestStatus(in *login.TokenCredentialRequestStatus, out *TokenCredentialRequestStatus, s conversion.Scope) error {
 	out.Credential = (*ClusterCredential)(unsafe.Pointer(in.Credential))
 	out.Message = (*string)(unsafe.Pointer(in.Message))
+	out.User = (*v1.UserInfo)(unsafe.Pointer(in.User))
 	return nil
 }
 
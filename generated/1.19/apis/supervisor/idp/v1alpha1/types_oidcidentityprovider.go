@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -42,6 +42,14 @@ type OIDCAuthorizationConfig struct {
 	// request flow with an OIDC identity provider. By default only the "openid" scope will be requested.
 	// +optional
 	AdditionalScopes []string `json:"additionalScopes,omitempty"`
+
+	// AllowPasswordGrant lets non-interactive clients, e.g. the Pinniped CLI in CI jobs, log in with a username and
+	// password, which the Supervisor sends to this OIDC identity provider using the resource owner password
+	// credentials grant. The OIDC identity provider must support that grant for the client. This also makes it
+	// possible to log in with the accounts of LDAP and Active Directory servers through an OIDC identity provider which
+	// connects to them. By default only interactive logins in a browser are allowed.
+	// +optional
+	AllowPasswordGrant bool `json:"allowPasswordGrant,omitempty"`
 }
 
 // OIDCClaims provides a mapping from upstream claims into identities.
@@ -55,6 +63,47 @@ type OIDCClaims struct {
 	// username.
 	// +optional
 	Username string `json:"username"`
+
+	// UsernameTemplate is a Go text/template which computes the username from the claims of the ID token, for
+	// identity providers where no single claim is a suitable username, e.g. "{{.preferred_username}}@{{.tenant}}".
+	// Logins fail when the ID token does not have a claim which is referenced by the template. It may not be
+	// specified together with Username.
+	// +optional
+	UsernameTemplate string `json:"usernameTemplate,omitempty"`
+
+	// UID provides the name of the token claim that will be used to derive a stable, opaque identifier
+	// for an identity, which is emitted as the "uid" claim of downstream ID tokens. Unlike the username,
+	// the UID is expected to never change for the lifetime of an upstream account, so it can be used by
+	// audit systems to track users across username changes. When not specified, it will default to "sub".
+	// +optional
+	UID string `json:"uid,omitempty"`
+}
+
+// OIDCTokenValidation configures additional checks of the responses from an OIDC identity provider. The nonce of
+// each ID token is always required, and the "at_hash" and "c_hash" claims of ID tokens and the "iss" parameter of
+// authorization responses are always validated when they are present. These settings reject responses which omit
+// them, so they should only be enabled for identity providers which are known to always include them.
+type OIDCTokenValidation struct {
+	// ClockSkewToleranceSeconds is how many seconds an ID token is still accepted after its "exp" time, or before its
+	// "nbf" time, to tolerate clocks which disagree with the clock of the OIDC identity provider. Defaults to 0.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	ClockSkewToleranceSeconds int32 `json:"clockSkewToleranceSeconds,omitempty"`
+
+	// RequireAccessTokenHash rejects ID tokens which do not have an "at_hash" claim.
+	// +optional
+	RequireAccessTokenHash bool `json:"requireAccessTokenHash,omitempty"`
+
+	// RequireAuthorizationCodeHash rejects ID tokens returned by the authorization code exchange which do not have a
+	// "c_hash" claim.
+	// +optional
+	RequireAuthorizationCodeHash bool `json:"requireAuthorizationCodeHash,omitempty"`
+
+	// RequireIssuerParameter rejects authorization responses which do not have an "iss" parameter, as defined by
+	// OAuth 2.0 Authorization Server Issuer Identification.
+	// +optional
+	RequireIssuerParameter bool `json:"requireIssuerParameter,omitempty"`
 }
 
 // OIDCClient contains information about an OIDC client (e.g., client ID and client
@@ -65,6 +114,13 @@ type OIDCClient struct {
 	// struct, then it is expected that the Secret is of type "secrets.pinniped.dev/oidc-client" with keys
 	// "clientID" and "clientSecret".
 	SecretName string `json:"secretName"`
+
+	// DecryptionKeySecretName contains the name of a namespace-local Secret object of type
+	// "secrets.pinniped.dev/oidc-client-decryption-keys" whose "keys" key holds one or more PEM-encoded RSA or ECDSA
+	// private keys. When it is specified, ID tokens which the OIDC identity provider encrypts (as JWEs) for any of
+	// the corresponding public keys are decrypted before they are validated. Unencrypted ID tokens are still accepted.
+	// +optional
+	DecryptionKeySecretName string `json:"decryptionKeySecretName,omitempty"`
 }
 
 // Spec for configuring an OIDC identity provider.
@@ -92,6 +148,10 @@ type OIDCIdentityProviderSpec struct {
 	// OIDCClient contains OIDC client information to be used used with this OIDC identity
 	// provider.
 	Client OIDCClient `json:"client"`
+
+	// TokenValidation configures additional checks of the responses from this OIDC identity provider.
+	// +optional
+	TokenValidation OIDCTokenValidation `json:"tokenValidation,omitempty"`
 }
 
 // OIDCIdentityProvider describes the configuration of an upstream OpenID Connect identity provider.
@@ -13,7 +13,13 @@ import (
 
 type AuthenticationV1alpha1Interface interface {
 	RESTClient() rest.Interface
+	BootstrapCredentialsGetter
+	ClientCertificateAuthenticatorsGetter
+	CloudIdentityAuthenticatorsGetter
 	JWTAuthenticatorsGetter
+	ServiceAccountAuthenticatorsGetter
+	StaticTokenAuthenticatorsGetter
+	SupervisorConnectionsGetter
 	WebhookAuthenticatorsGetter
 }
 
@@ -22,10 +28,34 @@ type AuthenticationV1alpha1Client struct {
 	restClient rest.Interface
 }
 
+func (c *AuthenticationV1alpha1Client) BootstrapCredentials() BootstrapCredentialInterface {
+	return newBootstrapCredentials(c)
+}
+
+func (c *AuthenticationV1alpha1Client) ClientCertificateAuthenticators() ClientCertificateAuthenticatorInterface {
+	return newClientCertificateAuthenticators(c)
+}
+
+func (c *AuthenticationV1alpha1Client) CloudIdentityAuthenticators() CloudIdentityAuthenticatorInterface {
+	return newCloudIdentityAuthenticators(c)
+}
+
 func (c *AuthenticationV1alpha1Client) JWTAuthenticators() JWTAuthenticatorInterface {
 	return newJWTAuthenticators(c)
 }
 
+func (c *AuthenticationV1alpha1Client) ServiceAccountAuthenticators() ServiceAccountAuthenticatorInterface {
+	return newServiceAccountAuthenticators(c)
+}
+
+func (c *AuthenticationV1alpha1Client) StaticTokenAuthenticators() StaticTokenAuthenticatorInterface {
+	return newStaticTokenAuthenticators(c)
+}
+
+func (c *AuthenticationV1alpha1Client) SupervisorConnections() SupervisorConnectionInterface {
+	return newSupervisorConnections(c)
+}
+
 func (c *AuthenticationV1alpha1Client) WebhookAuthenticators() WebhookAuthenticatorInterface {
 	return newWebhookAuthenticators(c)
 }
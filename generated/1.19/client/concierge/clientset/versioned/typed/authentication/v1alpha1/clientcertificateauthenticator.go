@@ -0,0 +1,171 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "go.pinniped.dev/generated/1.19/apis/concierge/authentication/v1alpha1"
+	scheme "go.pinniped.dev/generated/1.19/client/concierge/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// ClientCertificateAuthenticatorsGetter has a method to return a ClientCertificateAuthenticatorInterface.
+// A group's client should implement this interface.
+type ClientCertificateAuthenticatorsGetter interface {
+	ClientCertificateAuthenticators() ClientCertificateAuthenticatorInterface
+}
+
+// ClientCertificateAuthenticatorInterface has methods to work with ClientCertificateAuthenticator resources.
+type ClientCertificateAuthenticatorInterface interface {
+	Create(ctx context.Context, clientCertificateAuthenticator *v1alpha1.ClientCertificateAuthenticator, opts v1.CreateOptions) (*v1alpha1.ClientCertificateAuthenticator, error)
+	Update(ctx context.Context, clientCertificateAuthenticator *v1alpha1.ClientCertificateAuthenticator, opts v1.UpdateOptions) (*v1alpha1.ClientCertificateAuthenticator, error)
+	UpdateStatus(ctx context.Context, clientCertificateAuthenticator *v1alpha1.ClientCertificateAuthenticator, opts v1.UpdateOptions) (*v1alpha1.ClientCertificateAuthenticator, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.ClientCertificateAuthenticator, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.ClientCertificateAuthenticatorList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.ClientCertificateAuthenticator, err error)
+	ClientCertificateAuthenticatorExpansion
+}
+
+// clientCertificateAuthenticators implements ClientCertificateAuthenticatorInterface
+type clientCertificateAuthenticators struct {
+	client rest.Interface
+}
+
+// newClientCertificateAuthenticators returns a ClientCertificateAuthenticators
+func newClientCertificateAuthenticators(c *AuthenticationV1alpha1Client) *clientCertificateAuthenticators {
+	return &clientCertificateAuthenticators{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the clientCertificateAuthenticator, and returns the corresponding clientCertificateAuthenticator object, and an error if there is any.
+func (c *clientCertificateAuthenticators) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	result = &v1alpha1.ClientCertificateAuthenticator{}
+	err = c.client.Get().
+		Resource("clientcertificateauthenticators").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of ClientCertificateAuthenticators that match those selectors.
+func (c *clientCertificateAuthenticators) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.ClientCertificateAuthenticatorList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.ClientCertificateAuthenticatorList{}
+	err = c.client.Get().
+		Resource("clientcertificateauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested clientCertificateAuthenticators.
+func (c *clientCertificateAuthenticators) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("clientcertificateauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a clientCertificateAuthenticator and creates it.  Returns the server's representation of the clientCertificateAuthenticator, and an error, if there is any.
+func (c *clientCertificateAuthenticators) Create(ctx context.Context, clientCertificateAuthenticator *v1alpha1.ClientCertificateAuthenticator, opts v1.CreateOptions) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	result = &v1alpha1.ClientCertificateAuthenticator{}
+	err = c.client.Post().
+		Resource("clientcertificateauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(clientCertificateAuthenticator).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a clientCertificateAuthenticator and updates it. Returns the server's representation of the clientCertificateAuthenticator, and an error, if there is any.
+func (c *clientCertificateAuthenticators) Update(ctx context.Context, clientCertificateAuthenticator *v1alpha1.ClientCertificateAuthenticator, opts v1.UpdateOptions) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	result = &v1alpha1.ClientCertificateAuthenticator{}
+	err = c.client.Put().
+		Resource("clientcertificateauthenticators").
+		Name(clientCertificateAuthenticator.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(clientCertificateAuthenticator).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *clientCertificateAuthenticators) UpdateStatus(ctx context.Context, clientCertificateAuthenticator *v1alpha1.ClientCertificateAuthenticator, opts v1.UpdateOptions) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	result = &v1alpha1.ClientCertificateAuthenticator{}
+	err = c.client.Put().
+		Resource("clientcertificateauthenticators").
+		Name(clientCertificateAuthenticator.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(clientCertificateAuthenticator).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the clientCertificateAuthenticator and deletes it. Returns an error if one occurs.
+func (c *clientCertificateAuthenticators) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("clientcertificateauthenticators").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *clientCertificateAuthenticators) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("clientcertificateauthenticators").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched clientCertificateAuthenticator.
+func (c *clientCertificateAuthenticators) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	result = &v1alpha1.ClientCertificateAuthenticator{}
+	err = c.client.Patch(pt).
+		Resource("clientcertificateauthenticators").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
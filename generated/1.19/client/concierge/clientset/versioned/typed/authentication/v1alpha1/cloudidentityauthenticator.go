@@ -0,0 +1,171 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "go.pinniped.dev/generated/1.19/apis/concierge/authentication/v1alpha1"
+	scheme "go.pinniped.dev/generated/1.19/client/concierge/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// CloudIdentityAuthenticatorsGetter has a method to return a CloudIdentityAuthenticatorInterface.
+// A group's client should implement this interface.
+type CloudIdentityAuthenticatorsGetter interface {
+	CloudIdentityAuthenticators() CloudIdentityAuthenticatorInterface
+}
+
+// CloudIdentityAuthenticatorInterface has methods to work with CloudIdentityAuthenticator resources.
+type CloudIdentityAuthenticatorInterface interface {
+	Create(ctx context.Context, cloudIdentityAuthenticator *v1alpha1.CloudIdentityAuthenticator, opts v1.CreateOptions) (*v1alpha1.CloudIdentityAuthenticator, error)
+	Update(ctx context.Context, cloudIdentityAuthenticator *v1alpha1.CloudIdentityAuthenticator, opts v1.UpdateOptions) (*v1alpha1.CloudIdentityAuthenticator, error)
+	UpdateStatus(ctx context.Context, cloudIdentityAuthenticator *v1alpha1.CloudIdentityAuthenticator, opts v1.UpdateOptions) (*v1alpha1.CloudIdentityAuthenticator, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.CloudIdentityAuthenticator, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.CloudIdentityAuthenticatorList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.CloudIdentityAuthenticator, err error)
+	CloudIdentityAuthenticatorExpansion
+}
+
+// cloudIdentityAuthenticators implements CloudIdentityAuthenticatorInterface
+type cloudIdentityAuthenticators struct {
+	client rest.Interface
+}
+
+// newCloudIdentityAuthenticators returns a CloudIdentityAuthenticators
+func newCloudIdentityAuthenticators(c *AuthenticationV1alpha1Client) *cloudIdentityAuthenticators {
+	return &cloudIdentityAuthenticators{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the cloudIdentityAuthenticator, and returns the corresponding cloudIdentityAuthenticator object, and an error if there is any.
+func (c *cloudIdentityAuthenticators) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.CloudIdentityAuthenticator, err error) {
+	result = &v1alpha1.CloudIdentityAuthenticator{}
+	err = c.client.Get().
+		Resource("cloudidentityauthenticators").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of CloudIdentityAuthenticators that match those selectors.
+func (c *cloudIdentityAuthenticators) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.CloudIdentityAuthenticatorList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.CloudIdentityAuthenticatorList{}
+	err = c.client.Get().
+		Resource("cloudidentityauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested cloudIdentityAuthenticators.
+func (c *cloudIdentityAuthenticators) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("cloudidentityauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a cloudIdentityAuthenticator and creates it.  Returns the server's representation of the cloudIdentityAuthenticator, and an error, if there is any.
+func (c *cloudIdentityAuthenticators) Create(ctx context.Context, cloudIdentityAuthenticator *v1alpha1.CloudIdentityAuthenticator, opts v1.CreateOptions) (result *v1alpha1.CloudIdentityAuthenticator, err error) {
+	result = &v1alpha1.CloudIdentityAuthenticator{}
+	err = c.client.Post().
+		Resource("cloudidentityauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(cloudIdentityAuthenticator).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a cloudIdentityAuthenticator and updates it. Returns the server's representation of the cloudIdentityAuthenticator, and an error, if there is any.
+func (c *cloudIdentityAuthenticators) Update(ctx context.Context, cloudIdentityAuthenticator *v1alpha1.CloudIdentityAuthenticator, opts v1.UpdateOptions) (result *v1alpha1.CloudIdentityAuthenticator, err error) {
+	result = &v1alpha1.CloudIdentityAuthenticator{}
+	err = c.client.Put().
+		Resource("cloudidentityauthenticators").
+		Name(cloudIdentityAuthenticator.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(cloudIdentityAuthenticator).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *cloudIdentityAuthenticators) UpdateStatus(ctx context.Context, cloudIdentityAuthenticator *v1alpha1.CloudIdentityAuthenticator, opts v1.UpdateOptions) (result *v1alpha1.CloudIdentityAuthenticator, err error) {
+	result = &v1alpha1.CloudIdentityAuthenticator{}
+	err = c.client.Put().
+		Resource("cloudidentityauthenticators").
+		Name(cloudIdentityAuthenticator.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(cloudIdentityAuthenticator).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the cloudIdentityAuthenticator and deletes it. Returns an error if one occurs.
+func (c *cloudIdentityAuthenticators) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("cloudidentityauthenticators").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *cloudIdentityAuthenticators) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("cloudidentityauthenticators").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched cloudIdentityAuthenticator.
+func (c *cloudIdentityAuthenticators) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.CloudIdentityAuthenticator, err error) {
+	result = &v1alpha1.CloudIdentityAuthenticator{}
+	err = c.client.Patch(pt).
+		Resource("cloudidentityauthenticators").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
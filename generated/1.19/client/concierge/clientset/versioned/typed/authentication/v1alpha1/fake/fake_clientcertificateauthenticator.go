@@ -0,0 +1,120 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "go.pinniped.dev/generated/1.19/apis/concierge/authentication/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeClientCertificateAuthenticators implements ClientCertificateAuthenticatorInterface
+type FakeClientCertificateAuthenticators struct {
+	Fake *FakeAuthenticationV1alpha1
+}
+
+var clientcertificateauthenticatorsResource = schema.GroupVersionResource{Group: "authentication.concierge.pinniped.dev", Version: "v1alpha1", Resource: "clientcertificateauthenticators"}
+
+var clientcertificateauthenticatorsKind = schema.GroupVersionKind{Group: "authentication.concierge.pinniped.dev", Version: "v1alpha1", Kind: "ClientCertificateAuthenticator"}
+
+// Get takes name of the clientCertificateAuthenticator, and returns the corresponding clientCertificateAuthenticator object, and an error if there is any.
+func (c *FakeClientCertificateAuthenticators) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(clientcertificateauthenticatorsResource, name), &v1alpha1.ClientCertificateAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ClientCertificateAuthenticator), err
+}
+
+// List takes label and field selectors, and returns the list of ClientCertificateAuthenticators that match those selectors.
+func (c *FakeClientCertificateAuthenticators) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.ClientCertificateAuthenticatorList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(clientcertificateauthenticatorsResource, clientcertificateauthenticatorsKind, opts), &v1alpha1.ClientCertificateAuthenticatorList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.ClientCertificateAuthenticatorList{ListMeta: obj.(*v1alpha1.ClientCertificateAuthenticatorList).ListMeta}
+	for _, item := range obj.(*v1alpha1.ClientCertificateAuthenticatorList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested clientCertificateAuthenticators.
+func (c *FakeClientCertificateAuthenticators) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(clientcertificateauthenticatorsResource, opts))
+}
+
+// Create takes the representation of a clientCertificateAuthenticator and creates it.  Returns the server's representation of the clientCertificateAuthenticator, and an error, if there is any.
+func (c *FakeClientCertificateAuthenticators) Create(ctx context.Context, clientCertificateAuthenticator *v1alpha1.ClientCertificateAuthenticator, opts v1.CreateOptions) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(clientcertificateauthenticatorsResource, clientCertificateAuthenticator), &v1alpha1.ClientCertificateAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ClientCertificateAuthenticator), err
+}
+
+// Update takes the representation of a clientCertificateAuthenticator and updates it. Returns the server's representation of the clientCertificateAuthenticator, and an error, if there is any.
+func (c *FakeClientCertificateAuthenticators) Update(ctx context.Context, clientCertificateAuthenticator *v1alpha1.ClientCertificateAuthenticator, opts v1.UpdateOptions) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(clientcertificateauthenticatorsResource, clientCertificateAuthenticator), &v1alpha1.ClientCertificateAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ClientCertificateAuthenticator), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeClientCertificateAuthenticators) UpdateStatus(ctx context.Context, clientCertificateAuthenticator *v1alpha1.ClientCertificateAuthenticator, opts v1.UpdateOptions) (*v1alpha1.ClientCertificateAuthenticator, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateSubresourceAction(clientcertificateauthenticatorsResource, "status", clientCertificateAuthenticator), &v1alpha1.ClientCertificateAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ClientCertificateAuthenticator), err
+}
+
+// Delete takes name of the clientCertificateAuthenticator and deletes it. Returns an error if one occurs.
+func (c *FakeClientCertificateAuthenticators) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(clientcertificateauthenticatorsResource, name), &v1alpha1.ClientCertificateAuthenticator{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeClientCertificateAuthenticators) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(clientcertificateauthenticatorsResource, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.ClientCertificateAuthenticatorList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched clientCertificateAuthenticator.
+func (c *FakeClientCertificateAuthenticators) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.ClientCertificateAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(clientcertificateauthenticatorsResource, name, pt, data, subresources...), &v1alpha1.ClientCertificateAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ClientCertificateAuthenticator), err
+}
@@ -0,0 +1,120 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "go.pinniped.dev/generated/1.19/apis/concierge/authentication/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeServiceAccountAuthenticators implements ServiceAccountAuthenticatorInterface
+type FakeServiceAccountAuthenticators struct {
+	Fake *FakeAuthenticationV1alpha1
+}
+
+var serviceaccountauthenticatorsResource = schema.GroupVersionResource{Group: "authentication.concierge.pinniped.dev", Version: "v1alpha1", Resource: "serviceaccountauthenticators"}
+
+var serviceaccountauthenticatorsKind = schema.GroupVersionKind{Group: "authentication.concierge.pinniped.dev", Version: "v1alpha1", Kind: "ServiceAccountAuthenticator"}
+
+// Get takes name of the serviceAccountAuthenticator, and returns the corresponding serviceAccountAuthenticator object, and an error if there is any.
+func (c *FakeServiceAccountAuthenticators) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.ServiceAccountAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(serviceaccountauthenticatorsResource, name), &v1alpha1.ServiceAccountAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ServiceAccountAuthenticator), err
+}
+
+// List takes label and field selectors, and returns the list of ServiceAccountAuthenticators that match those selectors.
+func (c *FakeServiceAccountAuthenticators) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.ServiceAccountAuthenticatorList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(serviceaccountauthenticatorsResource, serviceaccountauthenticatorsKind, opts), &v1alpha1.ServiceAccountAuthenticatorList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.ServiceAccountAuthenticatorList{ListMeta: obj.(*v1alpha1.ServiceAccountAuthenticatorList).ListMeta}
+	for _, item := range obj.(*v1alpha1.ServiceAccountAuthenticatorList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested serviceAccountAuthenticators.
+func (c *FakeServiceAccountAuthenticators) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(serviceaccountauthenticatorsResource, opts))
+}
+
+// Create takes the representation of a serviceAccountAuthenticator and creates it.  Returns the server's representation of the serviceAccountAuthenticator, and an error, if there is any.
+func (c *FakeServiceAccountAuthenticators) Create(ctx context.Context, serviceAccountAuthenticator *v1alpha1.ServiceAccountAuthenticator, opts v1.CreateOptions) (result *v1alpha1.ServiceAccountAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(serviceaccountauthenticatorsResource, serviceAccountAuthenticator), &v1alpha1.ServiceAccountAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ServiceAccountAuthenticator), err
+}
+
+// Update takes the representation of a serviceAccountAuthenticator and updates it. Returns the server's representation of the serviceAccountAuthenticator, and an error, if there is any.
+func (c *FakeServiceAccountAuthenticators) Update(ctx context.Context, serviceAccountAuthenticator *v1alpha1.ServiceAccountAuthenticator, opts v1.UpdateOptions) (result *v1alpha1.ServiceAccountAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(serviceaccountauthenticatorsResource, serviceAccountAuthenticator), &v1alpha1.ServiceAccountAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ServiceAccountAuthenticator), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeServiceAccountAuthenticators) UpdateStatus(ctx context.Context, serviceAccountAuthenticator *v1alpha1.ServiceAccountAuthenticator, opts v1.UpdateOptions) (*v1alpha1.ServiceAccountAuthenticator, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateSubresourceAction(serviceaccountauthenticatorsResource, "status", serviceAccountAuthenticator), &v1alpha1.ServiceAccountAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ServiceAccountAuthenticator), err
+}
+
+// Delete takes name of the serviceAccountAuthenticator and deletes it. Returns an error if one occurs.
+func (c *FakeServiceAccountAuthenticators) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(serviceaccountauthenticatorsResource, name), &v1alpha1.ServiceAccountAuthenticator{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeServiceAccountAuthenticators) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(serviceaccountauthenticatorsResource, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.ServiceAccountAuthenticatorList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched serviceAccountAuthenticator.
+func (c *FakeServiceAccountAuthenticators) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.ServiceAccountAuthenticator, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(serviceaccountauthenticatorsResource, name, pt, data, subresources...), &v1alpha1.ServiceAccountAuthenticator{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ServiceAccountAuthenticator), err
+}
@@ -0,0 +1,171 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "go.pinniped.dev/generated/1.19/apis/concierge/authentication/v1alpha1"
+	scheme "go.pinniped.dev/generated/1.19/client/concierge/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// StaticTokenAuthenticatorsGetter has a method to return a StaticTokenAuthenticatorInterface.
+// A group's client should implement this interface.
+type StaticTokenAuthenticatorsGetter interface {
+	StaticTokenAuthenticators() StaticTokenAuthenticatorInterface
+}
+
+// StaticTokenAuthenticatorInterface has methods to work with StaticTokenAuthenticator resources.
+type StaticTokenAuthenticatorInterface interface {
+	Create(ctx context.Context, staticTokenAuthenticator *v1alpha1.StaticTokenAuthenticator, opts v1.CreateOptions) (*v1alpha1.StaticTokenAuthenticator, error)
+	Update(ctx context.Context, staticTokenAuthenticator *v1alpha1.StaticTokenAuthenticator, opts v1.UpdateOptions) (*v1alpha1.StaticTokenAuthenticator, error)
+	UpdateStatus(ctx context.Context, staticTokenAuthenticator *v1alpha1.StaticTokenAuthenticator, opts v1.UpdateOptions) (*v1alpha1.StaticTokenAuthenticator, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.StaticTokenAuthenticator, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.StaticTokenAuthenticatorList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.StaticTokenAuthenticator, err error)
+	StaticTokenAuthenticatorExpansion
+}
+
+// staticTokenAuthenticators implements StaticTokenAuthenticatorInterface
+type staticTokenAuthenticators struct {
+	client rest.Interface
+}
+
+// newStaticTokenAuthenticators returns a StaticTokenAuthenticators
+func newStaticTokenAuthenticators(c *AuthenticationV1alpha1Client) *staticTokenAuthenticators {
+	return &staticTokenAuthenticators{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the staticTokenAuthenticator, and returns the corresponding staticTokenAuthenticator object, and an error if there is any.
+func (c *staticTokenAuthenticators) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	result = &v1alpha1.StaticTokenAuthenticator{}
+	err = c.client.Get().
+		Resource("statictokenauthenticators").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of StaticTokenAuthenticators that match those selectors.
+func (c *staticTokenAuthenticators) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.StaticTokenAuthenticatorList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.StaticTokenAuthenticatorList{}
+	err = c.client.Get().
+		Resource("statictokenauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested staticTokenAuthenticators.
+func (c *staticTokenAuthenticators) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("statictokenauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a staticTokenAuthenticator and creates it.  Returns the server's representation of the staticTokenAuthenticator, and an error, if there is any.
+func (c *staticTokenAuthenticators) Create(ctx context.Context, staticTokenAuthenticator *v1alpha1.StaticTokenAuthenticator, opts v1.CreateOptions) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	result = &v1alpha1.StaticTokenAuthenticator{}
+	err = c.client.Post().
+		Resource("statictokenauthenticators").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(staticTokenAuthenticator).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a staticTokenAuthenticator and updates it. Returns the server's representation of the staticTokenAuthenticator, and an error, if there is any.
+func (c *staticTokenAuthenticators) Update(ctx context.Context, staticTokenAuthenticator *v1alpha1.StaticTokenAuthenticator, opts v1.UpdateOptions) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	result = &v1alpha1.StaticTokenAuthenticator{}
+	err = c.client.Put().
+		Resource("statictokenauthenticators").
+		Name(staticTokenAuthenticator.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(staticTokenAuthenticator).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *staticTokenAuthenticators) UpdateStatus(ctx context.Context, staticTokenAuthenticator *v1alpha1.StaticTokenAuthenticator, opts v1.UpdateOptions) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	result = &v1alpha1.StaticTokenAuthenticator{}
+	err = c.client.Put().
+		Resource("statictokenauthenticators").
+		Name(staticTokenAuthenticator.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(staticTokenAuthenticator).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the staticTokenAuthenticator and deletes it. Returns an error if one occurs.
+func (c *staticTokenAuthenticators) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("statictokenauthenticators").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *staticTokenAuthenticators) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("statictokenauthenticators").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched staticTokenAuthenticator.
+func (c *staticTokenAuthenticators) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.StaticTokenAuthenticator, err error) {
+	result = &v1alpha1.StaticTokenAuthenticator{}
+	err = c.client.Patch(pt).
+		Resource("statictokenauthenticators").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
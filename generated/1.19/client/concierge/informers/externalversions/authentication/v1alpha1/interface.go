@@ -11,8 +11,20 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// BootstrapCredentials returns a BootstrapCredentialInformer.
+	BootstrapCredentials() BootstrapCredentialInformer
+	// ClientCertificateAuthenticators returns a ClientCertificateAuthenticatorInformer.
+	ClientCertificateAuthenticators() ClientCertificateAuthenticatorInformer
+	// CloudIdentityAuthenticators returns a CloudIdentityAuthenticatorInformer.
+	CloudIdentityAuthenticators() CloudIdentityAuthenticatorInformer
 	// JWTAuthenticators returns a JWTAuthenticatorInformer.
 	JWTAuthenticators() JWTAuthenticatorInformer
+	// ServiceAccountAuthenticators returns a ServiceAccountAuthenticatorInformer.
+	ServiceAccountAuthenticators() ServiceAccountAuthenticatorInformer
+	// StaticTokenAuthenticators returns a StaticTokenAuthenticatorInformer.
+	StaticTokenAuthenticators() StaticTokenAuthenticatorInformer
+	// SupervisorConnections returns a SupervisorConnectionInformer.
+	SupervisorConnections() SupervisorConnectionInformer
 	// WebhookAuthenticators returns a WebhookAuthenticatorInformer.
 	WebhookAuthenticators() WebhookAuthenticatorInformer
 }
@@ -28,11 +40,41 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// BootstrapCredentials returns a BootstrapCredentialInformer.
+func (v *version) BootstrapCredentials() BootstrapCredentialInformer {
+	return &bootstrapCredentialInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// ClientCertificateAuthenticators returns a ClientCertificateAuthenticatorInformer.
+func (v *version) ClientCertificateAuthenticators() ClientCertificateAuthenticatorInformer {
+	return &clientCertificateAuthenticatorInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// CloudIdentityAuthenticators returns a CloudIdentityAuthenticatorInformer.
+func (v *version) CloudIdentityAuthenticators() CloudIdentityAuthenticatorInformer {
+	return &cloudIdentityAuthenticatorInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
 // JWTAuthenticators returns a JWTAuthenticatorInformer.
 func (v *version) JWTAuthenticators() JWTAuthenticatorInformer {
 	return &jWTAuthenticatorInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
 }
 
+// ServiceAccountAuthenticators returns a ServiceAccountAuthenticatorInformer.
+func (v *version) ServiceAccountAuthenticators() ServiceAccountAuthenticatorInformer {
+	return &serviceAccountAuthenticatorInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// StaticTokenAuthenticators returns a StaticTokenAuthenticatorInformer.
+func (v *version) StaticTokenAuthenticators() StaticTokenAuthenticatorInformer {
+	return &staticTokenAuthenticatorInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// SupervisorConnections returns a SupervisorConnectionInformer.
+func (v *version) SupervisorConnections() SupervisorConnectionInformer {
+	return &supervisorConnectionInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
 // WebhookAuthenticators returns a WebhookAuthenticatorInformer.
 func (v *version) WebhookAuthenticators() WebhookAuthenticatorInformer {
 	return &webhookAuthenticatorInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
@@ -0,0 +1,55 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "go.pinniped.dev/generated/1.19/apis/concierge/authentication/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// StaticTokenAuthenticatorLister helps list StaticTokenAuthenticators.
+// All objects returned here must be treated as read-only.
+type StaticTokenAuthenticatorLister interface {
+	// List lists all StaticTokenAuthenticators in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1alpha1.StaticTokenAuthenticator, err error)
+	// Get retrieves the StaticTokenAuthenticator from the index for a given name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1alpha1.StaticTokenAuthenticator, error)
+	StaticTokenAuthenticatorListerExpansion
+}
+
+// staticTokenAuthenticatorLister implements the StaticTokenAuthenticatorLister interface.
+type staticTokenAuthenticatorLister struct {
+	indexer cache.Indexer
+}
+
+// NewStaticTokenAuthenticatorLister returns a new StaticTokenAuthenticatorLister.
+func NewStaticTokenAuthenticatorLister(indexer cache.Indexer) StaticTokenAuthenticatorLister {
+	return &staticTokenAuthenticatorLister{indexer: indexer}
+}
+
+// List lists all StaticTokenAuthenticators in the indexer.
+func (s *staticTokenAuthenticatorLister) List(selector labels.Selector) (ret []*v1alpha1.StaticTokenAuthenticator, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.StaticTokenAuthenticator))
+	})
+	return ret, err
+}
+
+// Get retrieves the StaticTokenAuthenticator from the index for a given name.
+func (s *staticTokenAuthenticatorLister) Get(name string) (*v1alpha1.StaticTokenAuthenticator, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("statictokenauthenticator"), name)
+	}
+	return obj.(*v1alpha1.StaticTokenAuthenticator), nil
+}
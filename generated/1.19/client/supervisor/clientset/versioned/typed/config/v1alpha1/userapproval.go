@@ -0,0 +1,165 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "go.pinniped.dev/generated/1.19/apis/supervisor/config/v1alpha1"
+	scheme "go.pinniped.dev/generated/1.19/client/supervisor/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// UserApprovalsGetter has a method to return a UserApprovalInterface.
+// A group's client should implement this interface.
+type UserApprovalsGetter interface {
+	UserApprovals(namespace string) UserApprovalInterface
+}
+
+// UserApprovalInterface has methods to work with UserApproval resources.
+type UserApprovalInterface interface {
+	Create(ctx context.Context, userApproval *v1alpha1.UserApproval, opts v1.CreateOptions) (*v1alpha1.UserApproval, error)
+	Update(ctx context.Context, userApproval *v1alpha1.UserApproval, opts v1.UpdateOptions) (*v1alpha1.UserApproval, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.UserApproval, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.UserApprovalList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.UserApproval, err error)
+	UserApprovalExpansion
+}
+
+// userApprovals implements UserApprovalInterface
+type userApprovals struct {
+	client rest.Interface
+	ns     string
+}
+
+// newUserApprovals returns a UserApprovals
+func newUserApprovals(c *ConfigV1alpha1Client, namespace string) *userApprovals {
+	return &userApprovals{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the userApproval, and returns the corresponding userApproval object, and an error if there is any.
+func (c *userApprovals) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.UserApproval, err error) {
+	result = &v1alpha1.UserApproval{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of UserApprovals that match those selectors.
+func (c *userApprovals) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.UserApprovalList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.UserApprovalList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested userApprovals.
+func (c *userApprovals) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a userApproval and creates it.  Returns the server's representation of the userApproval, and an error, if there is any.
+func (c *userApprovals) Create(ctx context.Context, userApproval *v1alpha1.UserApproval, opts v1.CreateOptions) (result *v1alpha1.UserApproval, err error) {
+	result = &v1alpha1.UserApproval{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(userApproval).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a userApproval and updates it. Returns the server's representation of the userApproval, and an error, if there is any.
+func (c *userApprovals) Update(ctx context.Context, userApproval *v1alpha1.UserApproval, opts v1.UpdateOptions) (result *v1alpha1.UserApproval, err error) {
+	result = &v1alpha1.UserApproval{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		Name(userApproval.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(userApproval).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the userApproval and deletes it. Returns an error if one occurs.
+func (c *userApprovals) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *userApprovals) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("userapprovals").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched userApproval.
+func (c *userApprovals) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.UserApproval, err error) {
+	result = &v1alpha1.UserApproval{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("userapprovals").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// Status of a client certificate authenticator.
+type ClientCertificateAuthenticatorStatus struct {
+	// Represents the observations of the authenticator's current state.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// Spec for configuring a client certificate authenticator.
+//
+// The token is expected to be a JWS in compact serialization whose "x5c" header holds the client certificate and any
+// intermediate certificates, and which is signed by the private key of the client certificate. Its payload must be a
+// set of JWT claims with an "aud" claim of the configured audience and "iat" and "exp" claims at most five minutes
+// apart, which proves that the client currently holds the private key without ever sending the key to the cluster.
+type ClientCertificateAuthenticatorSpec struct {
+	// CertificateAuthorityData is the base64 encoded PEM bundle of the CAs which issue the accepted client
+	// certificates. Client certificates must be valid for client authentication (or have no extended key usages).
+	// +kubebuilder:validation:MinLength=1
+	CertificateAuthorityData string `json:"certificateAuthorityData"`
+
+	// Audience is the required "aud" claim of the token. It should be unique to this cluster, so that tokens made
+	// for another cluster which trusts the same CA cannot be replayed here.
+	// +kubebuilder:validation:MinLength=1
+	Audience string `json:"audience"`
+}
+
+// ClientCertificateAuthenticator describes the configuration of an authenticator for x509 client certificates which
+// were issued outside of the cluster, such as by an existing enterprise PKI. The common name of the certificate's
+// subject is used as the username and its organizations are used as the groups, following the same convention as
+// the Kubernetes API server.
+//
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories=pinniped;pinniped-authenticator;pinniped-authenticators,scope=Cluster
+// +kubebuilder:printcolumn:name="Audience",type=string,JSONPath=`.spec.audience`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
+type ClientCertificateAuthenticator struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec for configuring the authenticator.
+	Spec ClientCertificateAuthenticatorSpec `json:"spec"`
+
+	// Status of the authenticator.
+	Status ClientCertificateAuthenticatorStatus `json:"status,omitempty"`
+}
+
+// List of ClientCertificateAuthenticator objects.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type ClientCertificateAuthenticatorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ClientCertificateAuthenticator `json:"items"`
+}
@@ -1,13 +1,20 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
 
 import (
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// NoWorkingStrategyCause is the type of the cause in the details of the error which is returned when a
+// TokenCredentialRequest is authenticated but a cluster credential cannot be issued, because none of the strategies
+// of the Concierge's CredentialIssuer are working. This is a problem with the cluster rather than with the credentials
+// of the user, so clients should tell the user to contact a cluster administrator.
+const NoWorkingStrategyCause metav1.CauseType = "NoWorkingStrategy"
+
 // TokenCredentialRequestSpec is the specification of a TokenCredentialRequest, expected on requests to the Pinniped API.
 type TokenCredentialRequestSpec struct {
 	// Bearer token supplied with the credential request.
@@ -26,6 +33,11 @@ type TokenCredentialRequestStatus struct {
 	// An error message will be returned for an unsuccessful credential request.
 	// +optional
 	Message *string `json:"message,omitempty"`
+
+	// The identity of the authenticated user will be returned for a successful credential request, including the
+	// UID and extra attributes which cannot be carried by a client certificate.
+	// +optional
+	User *authenticationv1.UserInfo `json:"user,omitempty"`
 }
 
 // TokenCredentialRequest submits an IDP-specific credential to Pinniped in exchange for a cluster-specific credential.
@@ -6,3 +6,7 @@
 package v1alpha1
 
 type FederationDomainExpansion interface{}
+
+type GroupGrantExpansion interface{}
+
+type UserApprovalExpansion interface{}
@@ -13,6 +13,10 @@ import (
 type Interface interface {
 	// FederationDomains returns a FederationDomainInformer.
 	FederationDomains() FederationDomainInformer
+	// GroupGrants returns a GroupGrantInformer.
+	GroupGrants() GroupGrantInformer
+	// UserApprovals returns a UserApprovalInformer.
+	UserApprovals() UserApprovalInformer
 }
 
 type version struct {
@@ -30,3 +34,13 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 func (v *version) FederationDomains() FederationDomainInformer {
 	return &federationDomainInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// GroupGrants returns a GroupGrantInformer.
+func (v *version) GroupGrants() GroupGrantInformer {
+	return &groupGrantInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// UserApprovals returns a UserApprovalInformer.
+func (v *version) UserApprovals() UserApprovalInformer {
+	return &userApprovalInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// Status of a static token authenticator.
+type StaticTokenAuthenticatorStatus struct {
+	// Represents the observations of the authenticator's current state.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// Spec for configuring a static token authenticator.
+type StaticTokenAuthenticatorSpec struct {
+	// SecretName is the name of a Secret in the namespace of the Concierge which holds the hashes of the tokens. For
+	// each token, the Secret must have a key with the name of the token whose value is the lowercase hex-encoded
+	// SHA-256 hash of the token, e.g. the output of `printf %s "$TOKEN" | sha256sum`. The tokens themselves are never
+	// stored in the cluster, so they should be randomly generated with at least 32 bytes of entropy.
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+
+	// Tokens maps each token to the user which it authenticates as.
+	// +kubebuilder:validation:MinItems=1
+	Tokens []StaticToken `json:"tokens"`
+}
+
+// StaticToken maps a single token to a fixed Kubernetes username and groups.
+type StaticToken struct {
+	// Name of the token, which is the key of its hash in the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Username of the user which is authenticated by the token.
+	// +kubebuilder:validation:MinLength=1
+	Username string `json:"username"`
+
+	// Groups is the list of groups that the user will be a member of.
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+}
+
+// StaticTokenAuthenticator describes the configuration of an authenticator for a fixed set of tokens.
+//
+// A StaticTokenAuthenticator gives operators an emergency ("break-glass") way to access the cluster when the usual
+// identity provider is unavailable. Each token authenticates as a fixed user, so the tokens should be stored as
+// securely as any other administrator credential, and revoked by removing them from the Secret once they are used.
+//
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories=pinniped;pinniped-authenticator;pinniped-authenticators,scope=Cluster
+// +kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.spec.secretName`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
+type StaticTokenAuthenticator struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec for configuring the authenticator.
+	Spec StaticTokenAuthenticatorSpec `json:"spec"`
+
+	// Status of the authenticator.
+	Status StaticTokenAuthenticatorStatus `json:"status,omitempty"`
+}
+
+// List of StaticTokenAuthenticator objects.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type StaticTokenAuthenticatorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []StaticTokenAuthenticator `json:"items"`
+}
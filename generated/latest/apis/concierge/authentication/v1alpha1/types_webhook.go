@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -25,6 +25,49 @@ type WebhookAuthenticatorSpec struct {
 	// TLS configuration.
 	// +optional
 	TLS *TLSSpec `json:"tls,omitempty"`
+
+	// Request configures the timeout and retries of the requests to the webhook.
+	// +optional
+	Request *WebhookRequestSpec `json:"request,omitempty"`
+
+	// Cache configures caching of the webhook's responses, so that repeated requests to authenticate the same token
+	// do not each call the webhook. Responses are not cached when this is omitted.
+	// +optional
+	Cache *WebhookCacheSpec `json:"cache,omitempty"`
+}
+
+// WebhookRequestSpec configures how the webhook is called.
+type WebhookRequestSpec struct {
+	// Timeout is the longest time to wait for the webhook to authenticate a token, including all retries, e.g. "10s".
+	// Each individual request is also limited to 30 seconds. There is no additional limit when this is omitted.
+	// +optional
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+
+	// MaxRetries is how many times a request which failed with a retryable error, e.g. a connection reset or an
+	// HTTP 500, 504, or 429 response, is retried. Defaults to 4.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=20
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+
+	// InitialBackoff is how long to wait before the first retry, e.g. "500ms". Each following wait is 1.5 times
+	// longer than the previous one, with some random jitter. Defaults to 500ms.
+	// +optional
+	InitialBackoff metav1.Duration `json:"initialBackoff,omitempty"`
+}
+
+// WebhookCacheSpec configures how long the responses of a webhook authenticator are cached. Errors while calling
+// the webhook are never cached.
+type WebhookCacheSpec struct {
+	// SuccessTTL is how long a response which authenticated the token is cached, e.g. "2m". Such responses are not
+	// cached when this is omitted or zero.
+	// +optional
+	SuccessTTL metav1.Duration `json:"successTTL,omitempty"`
+
+	// FailureTTL is how long a response which did not authenticate the token is cached, e.g. "30s". Such responses
+	// are not cached when this is omitted or zero.
+	// +optional
+	FailureTTL metav1.Duration `json:"failureTTL,omitempty"`
 }
 
 // WebhookAuthenticator describes the configuration of a webhook authenticator.
@@ -33,6 +76,8 @@ type WebhookAuthenticatorSpec struct {
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:resource:categories=pinniped;pinniped-authenticator;pinniped-authenticators,scope=Cluster
 // +kubebuilder:printcolumn:name="Endpoint",type=string,JSONPath=`.spec.endpoint`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // +kubebuilder:subresource:status
 type WebhookAuthenticator struct {
 	metav1.TypeMeta   `json:",inline"`
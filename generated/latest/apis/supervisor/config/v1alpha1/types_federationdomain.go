@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -18,6 +18,32 @@ const (
 	InvalidFederationDomainStatusCondition                         = FederationDomainStatusCondition("Invalid")
 )
 
+// +kubebuilder:validation:Enum=Ready;Pending;Failed
+type FederationDomainTLSStatusCondition string
+
+const (
+	ReadyFederationDomainTLSStatusCondition   = FederationDomainTLSStatusCondition("Ready")
+	PendingFederationDomainTLSStatusCondition = FederationDomainTLSStatusCondition("Pending")
+	FailedFederationDomainTLSStatusCondition  = FederationDomainTLSStatusCondition("Failed")
+)
+
+// FederationDomainTLSIssuerRef refers to a cert-manager issuer.
+type FederationDomainTLSIssuerRef struct {
+	// Name of the issuer.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Kind of the issuer, e.g. Issuer (which must be in the same namespace) or ClusterIssuer.
+	// +kubebuilder:default=Issuer
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Group of the issuer. It only needs to be changed when using an external issuer.
+	// +kubebuilder:default=cert-manager.io
+	// +optional
+	Group string `json:"group,omitempty"`
+}
+
 // FederationDomainTLSSpec is a struct that describes the TLS configuration for an OIDC Provider.
 type FederationDomainTLSSpec struct {
 	// SecretName is an optional name of a Secret in the same namespace, of type `kubernetes.io/tls`, which contains
@@ -39,6 +65,99 @@ type FederationDomainTLSSpec struct {
 	//
 	// +optional
 	SecretName string `json:"secretName,omitempty"`
+
+	// IssuerRef is an optional reference to a cert-manager (https://cert-manager.io) Issuer or ClusterIssuer. When
+	// provided, the Supervisor creates and manages a cert-manager Certificate for the hostname of the Issuer URL, which
+	// cert-manager will keep up to date in the Secret named by SecretName. SecretName is required when IssuerRef is
+	// provided. The progress of the certificate is reported in status.tls.
+	//
+	// +optional
+	IssuerRef *FederationDomainTLSIssuerRef `json:"issuerRef,omitempty"`
+}
+
+// FederationDomainLoginApprovalSpec configures whether users must be approved before they can log in.
+type FederationDomainLoginApprovalSpec struct {
+	// Required causes the first login of each user to this FederationDomain to be held for approval. A UserApproval
+	// is created in the same namespace for each new user, and the user will not be able to log in until an
+	// administrator sets its spec.decision to Approved. Once approved, later logins by the same user proceed
+	// automatically.
+	Required bool `json:"required"`
+}
+
+// +kubebuilder:validation:Enum=Array;SpaceDelimited
+type FederationDomainGroupsClaimFormat string
+
+const (
+	// ArrayGroupsClaimFormat formats the groups as a JSON array of strings.
+	ArrayGroupsClaimFormat = FederationDomainGroupsClaimFormat("Array")
+
+	// SpaceDelimitedGroupsClaimFormat formats the groups as a single string in which the groups are separated by
+	// spaces, like the "scope" claim of OAuth 2.0.
+	SpaceDelimitedGroupsClaimFormat = FederationDomainGroupsClaimFormat("SpaceDelimited")
+)
+
+// FederationDomainGroupsClaimSpec configures an additional claim in the ID tokens issued by a FederationDomain which
+// contains the user's groups.
+type FederationDomainGroupsClaimSpec struct {
+	// Name of the claim, e.g. "roles". It may not be the name of a claim which is already used by the Supervisor,
+	// such as "groups", "username", or any of the claims defined by OpenID Connect.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Format of the claim. Array is a JSON array of strings, and SpaceDelimited is a string in which the groups are
+	// separated by spaces.
+	// +kubebuilder:default=Array
+	// +optional
+	Format FederationDomainGroupsClaimFormat `json:"format,omitempty"`
+}
+
+// FederationDomainClusterConciergeSpec describes how the Pinniped Concierge of a cluster exchanges the tokens of the
+// FederationDomain for cluster credentials.
+type FederationDomainClusterConciergeSpec struct {
+	// AuthenticatorName is the name of the JWTAuthenticator of the Concierge which trusts the tokens of the
+	// FederationDomain for the cluster's audience.
+	// +kubebuilder:validation:MinLength=1
+	AuthenticatorName string `json:"authenticatorName"`
+
+	// APIGroupSuffix is the API group suffix with which the Concierge was installed.
+	// +kubebuilder:default=pinniped.dev
+	// +optional
+	APIGroupSuffix string `json:"apiGroupSuffix,omitempty"`
+}
+
+// FederationDomainCluster describes a Kubernetes cluster which accepts the tokens of a FederationDomain. Users who are
+// allowed to access the cluster can download a kubeconfig for it from the FederationDomain.
+type FederationDomainCluster struct {
+	// Name of the cluster. It is also used as the name of the cluster, user and context in downloaded kubeconfigs.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Audience of the tokens for this cluster, which the Pinniped CLI requests from the FederationDomain with a token
+	// exchange. It must be the audience which the cluster expects, e.g. the spec.audience of its JWTAuthenticator.
+	// +kubebuilder:validation:MinLength=1
+	Audience string `json:"audience"`
+
+	// Server is the URL of the Kubernetes API server of the cluster.
+	// +kubebuilder:validation:Pattern=`^https://`
+	Server string `json:"server"`
+
+	// CertificateAuthorityData is the base64-encoded PEM bundle of certificate authorities which are trusted to
+	// serve the Kubernetes API of the cluster. When omitted, the system's trusted certificate authorities are used.
+	// +optional
+	CertificateAuthorityData string `json:"certificateAuthorityData,omitempty"`
+
+	// Concierge configures the kubeconfig to exchange the token for cluster credentials with the Pinniped Concierge.
+	// When omitted, the token is sent to the Kubernetes API server directly, which must be configured to trust it.
+	// +optional
+	Concierge *FederationDomainClusterConciergeSpec `json:"concierge,omitempty"`
+
+	// AllowedGroups restricts the cluster to the users who belong to at least one of these downstream groups. When
+	// empty, all users of the FederationDomain are allowed. This only controls which clusters are offered to users;
+	// access to the cluster is still controlled by the cluster's own RBAC policy.
+	// +optional
+	AllowedGroups []string `json:"allowedGroups,omitempty"`
 }
 
 // FederationDomainSpec is a struct that describes an OIDC Provider.
@@ -57,6 +176,25 @@ type FederationDomainSpec struct {
 	// TLS configures how this FederationDomain is served over Transport Layer Security (TLS).
 	// +optional
 	TLS *FederationDomainTLSSpec `json:"tls,omitempty"`
+
+	// LoginApproval configures whether new users must be approved by an administrator before they can log in.
+	// +optional
+	LoginApproval *FederationDomainLoginApprovalSpec `json:"loginApproval,omitempty"`
+
+	// GroupsClaim configures an additional claim in the ID tokens issued by this FederationDomain which contains the
+	// user's groups, for clients which expect to find the groups in a claim other than "groups". The "groups" claim,
+	// which is used by the Pinniped Concierge, is always included.
+	// +optional
+	GroupsClaim *FederationDomainGroupsClaimSpec `json:"groupsClaim,omitempty"`
+
+	// Clusters lists the Kubernetes clusters which accept the tokens of this FederationDomain. Users can list the
+	// clusters which they are allowed to access at the issuer URL followed by "/clusters", and download a ready-to-use
+	// kubeconfig for each of them at the issuer URL followed by "/kubeconfig". Users can also log in with their browser
+	// and download the kubeconfigs at the issuer URL followed by "/downloads".
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Clusters []FederationDomainCluster `json:"clusters,omitempty"`
 }
 
 // FederationDomainSecrets holds information about this OIDC Provider's secrets.
@@ -83,6 +221,25 @@ type FederationDomainSecrets struct {
 	StateEncryptionKey corev1.LocalObjectReference `json:"stateEncryptionKey,omitempty"`
 }
 
+// FederationDomainTLSStatus describes the state of the cert-manager Certificate for an OIDC Provider.
+type FederationDomainTLSStatus struct {
+	// CertificateName is the name of the cert-manager Certificate which is managed for this OIDC Provider.
+	// +optional
+	CertificateName string `json:"certificateName,omitempty"`
+
+	// Status holds an enum that describes whether the serving certificate has been issued.
+	// +optional
+	Status FederationDomainTLSStatusCondition `json:"status,omitempty"`
+
+	// Message provides human-readable details about the Status.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastUpdateTime holds the time at which the Status was last updated.
+	// +optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
 // FederationDomainStatus is a struct that describes the actual state of an OIDC Provider.
 type FederationDomainStatus struct {
 	// Status holds an enum that describes the state of this OIDC Provider. Note that this Status can
@@ -103,12 +260,29 @@ type FederationDomainStatus struct {
 	// Secrets contains information about this OIDC Provider's secrets.
 	// +optional
 	Secrets FederationDomainSecrets `json:"secrets,omitempty"`
+
+	// TLS contains information about the cert-manager Certificate for this OIDC Provider. It is only set when
+	// spec.tls.issuerRef is set.
+	// +optional
+	TLS *FederationDomainTLSStatus `json:"tls,omitempty"`
+
+	// Conditions represent the observations of this OIDC Provider's current state. The IssuerHostServable condition
+	// reports whether the TLS certificate which will be served for the issuer's hostname is valid for that hostname.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 // FederationDomain describes the configuration of an OIDC provider.
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:resource:categories=pinniped
+// +kubebuilder:printcolumn:name="Issuer",type=string,JSONPath=`.spec.issuer`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // +kubebuilder:subresource:status
 type FederationDomain struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -0,0 +1,94 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:validation:Enum=Pending;Active;Expired
+type GroupGrantPhase string
+
+const (
+	PendingGroupGrantPhase = GroupGrantPhase("Pending")
+	ActiveGroupGrantPhase  = GroupGrantPhase("Active")
+	ExpiredGroupGrantPhase = GroupGrantPhase("Expired")
+)
+
+// GroupGrantSpec describes additional groups which are granted to a user for a bounded time window.
+type GroupGrantSpec struct {
+	// FederationDomainName is the name of the FederationDomain, in the same namespace, to whose tokens the groups are
+	// added. The same user may be granted different groups by each FederationDomain.
+	// +kubebuilder:validation:MinLength=1
+	FederationDomainName string `json:"federationDomainName"`
+
+	// Subject is the downstream subject (i.e. the value of the sub claim in the tokens issued by the Supervisor)
+	// of the user who is granted the additional groups. It identifies both the upstream identity provider and the
+	// user within it, unlike the downstream username, which may be claimed by users of several identity providers.
+	// +kubebuilder:validation:MinLength=1
+	Subject string `json:"subject"`
+
+	// Username is the downstream username of the user, for display purposes. It is not used to match the user.
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// Groups are added to the groups claim of the tokens issued to the user while this grant is active.
+	// +kubebuilder:validation:MinItems=1
+	Groups []string `json:"groups"`
+
+	// NotBefore is the time at which this grant becomes active. When it is not set, the grant is active immediately.
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+
+	// ExpiresAt is the time at which this grant stops being active. Groups are only added when tokens are issued or
+	// refreshed, so a user may keep the granted groups until their current tokens expire, which is a few minutes
+	// for the tokens issued by the Supervisor.
+	ExpiresAt metav1.Time `json:"expiresAt"`
+}
+
+// GroupGrantStatus describes whether a GroupGrant is currently active.
+type GroupGrantStatus struct {
+	// Phase summarizes whether this grant is waiting for its NotBefore time, active, or expired.
+	// +optional
+	Phase GroupGrantPhase `json:"phase,omitempty"`
+
+	// Message provides human-readable details about the Phase.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastUpdateTime holds the time at which the Phase was last updated.
+	// +optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// GroupGrant temporarily grants additional downstream groups to a user, e.g. for just-in-time elevation of privileges.
+// The grant is evaluated whenever the Supervisor issues or refreshes tokens for the user.
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories=pinniped
+// +kubebuilder:printcolumn:name="FederationDomain",type=string,JSONPath=`.spec.federationDomainName`
+// +kubebuilder:printcolumn:name="Username",type=string,JSONPath=`.spec.username`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.spec.expiresAt`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
+type GroupGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec of the group grant.
+	Spec GroupGrantSpec `json:"spec"`
+
+	// Status of the group grant.
+	Status GroupGrantStatus `json:"status,omitempty"`
+}
+
+// List of GroupGrant objects.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type GroupGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []GroupGrant `json:"items"`
+}
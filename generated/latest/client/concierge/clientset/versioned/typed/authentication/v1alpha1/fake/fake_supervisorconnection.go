@@ -0,0 +1,120 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeSupervisorConnections implements SupervisorConnectionInterface
+type FakeSupervisorConnections struct {
+	Fake *FakeAuthenticationV1alpha1
+}
+
+var supervisorconnectionsResource = schema.GroupVersionResource{Group: "authentication.concierge.pinniped.dev", Version: "v1alpha1", Resource: "supervisorconnections"}
+
+var supervisorconnectionsKind = schema.GroupVersionKind{Group: "authentication.concierge.pinniped.dev", Version: "v1alpha1", Kind: "SupervisorConnection"}
+
+// Get takes name of the supervisorConnection, and returns the corresponding supervisorConnection object, and an error if there is any.
+func (c *FakeSupervisorConnections) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.SupervisorConnection, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(supervisorconnectionsResource, name), &v1alpha1.SupervisorConnection{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.SupervisorConnection), err
+}
+
+// List takes label and field selectors, and returns the list of SupervisorConnections that match those selectors.
+func (c *FakeSupervisorConnections) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.SupervisorConnectionList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(supervisorconnectionsResource, supervisorconnectionsKind, opts), &v1alpha1.SupervisorConnectionList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.SupervisorConnectionList{ListMeta: obj.(*v1alpha1.SupervisorConnectionList).ListMeta}
+	for _, item := range obj.(*v1alpha1.SupervisorConnectionList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested supervisorConnections.
+func (c *FakeSupervisorConnections) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(supervisorconnectionsResource, opts))
+}
+
+// Create takes the representation of a supervisorConnection and creates it.  Returns the server's representation of the supervisorConnection, and an error, if there is any.
+func (c *FakeSupervisorConnections) Create(ctx context.Context, supervisorConnection *v1alpha1.SupervisorConnection, opts v1.CreateOptions) (result *v1alpha1.SupervisorConnection, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(supervisorconnectionsResource, supervisorConnection), &v1alpha1.SupervisorConnection{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.SupervisorConnection), err
+}
+
+// Update takes the representation of a supervisorConnection and updates it. Returns the server's representation of the supervisorConnection, and an error, if there is any.
+func (c *FakeSupervisorConnections) Update(ctx context.Context, supervisorConnection *v1alpha1.SupervisorConnection, opts v1.UpdateOptions) (result *v1alpha1.SupervisorConnection, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(supervisorconnectionsResource, supervisorConnection), &v1alpha1.SupervisorConnection{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.SupervisorConnection), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeSupervisorConnections) UpdateStatus(ctx context.Context, supervisorConnection *v1alpha1.SupervisorConnection, opts v1.UpdateOptions) (*v1alpha1.SupervisorConnection, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateSubresourceAction(supervisorconnectionsResource, "status", supervisorConnection), &v1alpha1.SupervisorConnection{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.SupervisorConnection), err
+}
+
+// Delete takes name of the supervisorConnection and deletes it. Returns an error if one occurs.
+func (c *FakeSupervisorConnections) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(supervisorconnectionsResource, name), &v1alpha1.SupervisorConnection{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeSupervisorConnections) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(supervisorconnectionsResource, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.SupervisorConnectionList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched supervisorConnection.
+func (c *FakeSupervisorConnections) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.SupervisorConnection, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(supervisorconnectionsResource, name, pt, data, subresources...), &v1alpha1.SupervisorConnection{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.SupervisorConnection), err
+}
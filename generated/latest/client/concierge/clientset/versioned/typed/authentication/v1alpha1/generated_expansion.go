@@ -5,6 +5,18 @@
 
 package v1alpha1
 
+type BootstrapCredentialExpansion interface{}
+
+type ClientCertificateAuthenticatorExpansion interface{}
+
+type CloudIdentityAuthenticatorExpansion interface{}
+
 type JWTAuthenticatorExpansion interface{}
 
+type ServiceAccountAuthenticatorExpansion interface{}
+
+type StaticTokenAuthenticatorExpansion interface{}
+
+type SupervisorConnectionExpansion interface{}
+
 type WebhookAuthenticatorExpansion interface{}
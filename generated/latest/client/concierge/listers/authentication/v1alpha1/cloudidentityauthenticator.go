@@ -0,0 +1,55 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CloudIdentityAuthenticatorLister helps list CloudIdentityAuthenticators.
+// All objects returned here must be treated as read-only.
+type CloudIdentityAuthenticatorLister interface {
+	// List lists all CloudIdentityAuthenticators in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1alpha1.CloudIdentityAuthenticator, err error)
+	// Get retrieves the CloudIdentityAuthenticator from the index for a given name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1alpha1.CloudIdentityAuthenticator, error)
+	CloudIdentityAuthenticatorListerExpansion
+}
+
+// cloudIdentityAuthenticatorLister implements the CloudIdentityAuthenticatorLister interface.
+type cloudIdentityAuthenticatorLister struct {
+	indexer cache.Indexer
+}
+
+// NewCloudIdentityAuthenticatorLister returns a new CloudIdentityAuthenticatorLister.
+func NewCloudIdentityAuthenticatorLister(indexer cache.Indexer) CloudIdentityAuthenticatorLister {
+	return &cloudIdentityAuthenticatorLister{indexer: indexer}
+}
+
+// List lists all CloudIdentityAuthenticators in the indexer.
+func (s *cloudIdentityAuthenticatorLister) List(selector labels.Selector) (ret []*v1alpha1.CloudIdentityAuthenticator, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.CloudIdentityAuthenticator))
+	})
+	return ret, err
+}
+
+// Get retrieves the CloudIdentityAuthenticator from the index for a given name.
+func (s *cloudIdentityAuthenticatorLister) Get(name string) (*v1alpha1.CloudIdentityAuthenticator, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("cloudidentityauthenticator"), name)
+	}
+	return obj.(*v1alpha1.CloudIdentityAuthenticator), nil
+}
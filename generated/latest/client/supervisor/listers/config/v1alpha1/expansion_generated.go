@@ -12,3 +12,19 @@ type FederationDomainListerExpansion interface{}
 // FederationDomainNamespaceListerExpansion allows custom methods to be added to
 // FederationDomainNamespaceLister.
 type FederationDomainNamespaceListerExpansion interface{}
+
+// GroupGrantListerExpansion allows custom methods to be added to
+// GroupGrantLister.
+type GroupGrantListerExpansion interface{}
+
+// GroupGrantNamespaceListerExpansion allows custom methods to be added to
+// GroupGrantNamespaceLister.
+type GroupGrantNamespaceListerExpansion interface{}
+
+// UserApprovalListerExpansion allows custom methods to be added to
+// UserApprovalLister.
+type UserApprovalListerExpansion interface{}
+
+// UserApprovalNamespaceListerExpansion allows custom methods to be added to
+// UserApprovalNamespaceLister.
+type UserApprovalNamespaceListerExpansion interface{}
@@ -51,7 +51,9 @@ function check_dependency() {
 help=no
 skip_build=no
 clean_kind=no
+browserless=no
 api_group_suffix="pinniped.dev" # same default as in the values.yaml ytt file
+bcrypt_cost=10
 
 while (("$#")); do
   case "$1" in
@@ -67,6 +69,10 @@ while (("$#")); do
     clean_kind=yes
     shift
     ;;
+  -b | --browserless)
+    browserless=yes
+    shift
+    ;;
   -g | --api-group-suffix)
     shift
     # If there are no more command line arguments, or there is another command line argument but it starts with a dash, then error
@@ -77,6 +83,16 @@ while (("$#")); do
     api_group_suffix=$1
     shift
     ;;
+  --bcrypt-cost)
+    shift
+    # The cost must be a number which htpasswd accepts.
+    if [[ "$#" == "0" || ! "$1" =~ ^[0-9]+$ || "$1" -lt 4 || "$1" -gt 17 ]]; then
+      log_error "--bcrypt-cost requires a number from 4 to 17 to be specified"
+      exit 1
+    fi
+    bcrypt_cost=$1
+    shift
+    ;;
   -*)
     log_error "Unsupported flag $1" >&2
     exit 1
@@ -95,6 +111,8 @@ if [[ "$help" == "yes" ]]; then
   log_note
   log_note "Flags:"
   log_note "   -h, --help:              print this usage"
+  log_note "   -b, --browserless:       run the browser-based tests without Chrome, by logging in to Dex programmatically"
+  log_note "       --bcrypt-cost:       the bcrypt cost of the password hash of the test user (default 10, lower is faster)"
   log_note "   -c, --clean:             destroy the current kind cluster and make a new one"
   log_note "   -g, --api-group-suffix:  deploy Pinniped with an alternate API group suffix"
   log_note "   -s, --skip-build:        reuse the most recently built image of the app instead of building"
@@ -113,7 +131,9 @@ check_dependency ytt "Please install ytt. e.g. 'brew tap k14s/tap && brew instal
 check_dependency kapp "Please install kapp. e.g. 'brew tap k14s/tap && brew install kapp' for MacOS"
 check_dependency kubectl "Please install kubectl. e.g. 'brew install kubectl' for MacOS"
 check_dependency htpasswd "Please install htpasswd. Should be pre-installed on MacOS. Usually found in 'apache2-utils' package for linux."
-check_dependency chromedriver "Please install chromedriver. e.g. 'brew install chromedriver' for MacOS"
+if [[ "$browserless" == "no" ]]; then
+  check_dependency chromedriver "Please install chromedriver. e.g. 'brew install chromedriver' for MacOS"
+fi
 
 # Require kubectl >= 1.18.x
 if [ "$(kubectl version --client=true --short | cut -d '.' -f 2)" -lt 18 ]; then
@@ -220,7 +240,7 @@ log_note "Creating test user '$test_username'..."
 kubectl create secret generic "$test_username" \
   --namespace local-user-authenticator \
   --from-literal=groups="$test_groups" \
-  --from-literal=passwordHash="$(htpasswd -nbBC 10 x "$test_password" | sed -e "s/^x://")" \
+  --from-literal=passwordHash="$(htpasswd -nbBC "$bcrypt_cost" x "$test_password" | sed -e "s/^x://")" \
   --dry-run=client \
   --output yaml |
   kubectl apply -f -
@@ -330,6 +350,7 @@ export PINNIPED_TEST_SUPERVISOR_UPSTREAM_OIDC_USERNAME=pinny@example.com
 export PINNIPED_TEST_SUPERVISOR_UPSTREAM_OIDC_PASSWORD=password
 export PINNIPED_TEST_SUPERVISOR_UPSTREAM_OIDC_EXPECTED_GROUPS= # Dex's local user store does not let us configure groups.
 export PINNIPED_TEST_API_GROUP_SUFFIX='${api_group_suffix}'
+export PINNIPED_TEST_BROWSERLESS=${browserless}
 
 read -r -d '' PINNIPED_TEST_CLUSTER_CAPABILITY_YAML << PINNIPED_TEST_CLUSTER_CAPABILITY_YAML_EOF || true
 ${pinniped_cluster_capability_file_content}
@@ -0,0 +1,43 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package atomicfile writes files which are read by other processes concurrently, like the caches of the CLI.
+package atomicfile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes the data to a temporary file next to the path with mode 0600, and then renames it to the path, so
+// that other processes never read a partially written file, not even when this process is killed while writing.
+func WriteFile(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }() // does nothing once the file was renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		// Report the path of the file instead of the random name of the temporary file.
+		var linkErr *os.LinkError
+		if errors.As(err, &linkErr) {
+			return &os.PathError{Op: "rename", Path: path, Err: linkErr.Err}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/testutil"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := testutil.TempDir(t)
+	path := filepath.Join(dir, "some-file.yaml")
+
+	require.NoError(t, WriteFile(path, []byte("first")))
+	require.NoError(t, WriteFile(path, []byte("second")))
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "second", string(contents))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// No temporary files are left behind, not even when the rename fails.
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "some-dir"), 0700))
+	err = WriteFile(filepath.Join(dir, "some-dir"), []byte("third"))
+	require.Error(t, err)
+	require.Regexp(t, "^rename "+filepath.Join(dir, "some-dir")+": ", err.Error())
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	require.Error(t, WriteFile(filepath.Join(dir, "missing-dir", "some-file.yaml"), []byte("fourth")))
+}
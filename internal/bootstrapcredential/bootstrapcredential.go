@@ -0,0 +1,154 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package bootstrapcredential implements single-use, expiring bearer tokens which are backed by BootstrapCredential
+// resources.
+package bootstrapcredential
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog/v2"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	authv1alpha1 "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/typed/authentication/v1alpha1"
+	"go.pinniped.dev/internal/plog"
+)
+
+// tokenBytes is the number of random bytes in a generated token.
+const tokenBytes = 32
+
+// MaxTTL is the longest lifetime of a BootstrapCredential, and of any credential issued for it, since they are meant
+// for short-lived onboarding and break-glass access. The spec.expiresAt of a BootstrapCredential is not validated by
+// its CRD, so the Authenticator rejects those which would be valid for longer.
+const MaxTTL = 24 * time.Hour
+
+// GenerateToken returns a new random bearer token and the value to use as the spec.tokenHash of its
+// BootstrapCredential.
+func GenerateToken(rand io.Reader) (token string, tokenHash string, err error) {
+	b := make([]byte, tokenBytes)
+	if _, err := io.ReadFull(rand, b); err != nil {
+		return "", "", fmt.Errorf("could not generate token: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the lowercase hex-encoded SHA-256 hash of the token.
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Authenticator is an authenticator.Token which authenticates the token of a single BootstrapCredential, at most once.
+type Authenticator struct {
+	name   string
+	client authv1alpha1.BootstrapCredentialInterface
+	clock  clock.PassiveClock
+}
+
+// Assert that *Authenticator implements authenticator.Token.
+var _ authenticator.Token = (*Authenticator)(nil)
+
+// New returns an Authenticator for the BootstrapCredential with the provided name.
+func New(name string, client authv1alpha1.BootstrapCredentialInterface, clock clock.PassiveClock) *Authenticator {
+	return &Authenticator{name: name, client: client, clock: clock}
+}
+
+// User is the user.Info of an authenticated BootstrapCredential. The BootstrapCredential is not redeemed by
+// authenticating its token, so that a failure to issue a credential does not use it up. Callers must Redeem it after
+// they issued the credential, and must not hand out that credential when Redeem fails.
+type User struct {
+	user.DefaultInfo
+
+	// ExpiresAt is when the BootstrapCredential expires, which is also the latest expiry of any credential issued for it.
+	ExpiresAt time.Time
+
+	cred   *auth1alpha1.BootstrapCredential
+	client authv1alpha1.BootstrapCredentialInterface
+	clock  clock.PassiveClock
+}
+
+// Redeem marks the BootstrapCredential as redeemed. It uses an update which is optimistically locked on the version of
+// the BootstrapCredential which was authenticated, so that at most one of several concurrent requests can succeed.
+func (u *User) Redeem(ctx context.Context) error {
+	cred := u.cred.DeepCopy()
+	redeemedAt := metav1.NewTime(u.clock.Now())
+	cred.Status.RedeemedAt = &redeemedAt
+	if _, err := u.client.UpdateStatus(ctx, cred, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("could not mark BootstrapCredential as redeemed: %w", err)
+	}
+
+	plog.Info("redeemed bootstrap credential",
+		"bootstrapCredential", klog.KObj(cred),
+		"username", cred.Spec.Username,
+		"groups", cred.Spec.Groups,
+		"reason", cred.Spec.Reason,
+	)
+	return nil
+}
+
+// AuthenticateToken implements authenticator.Token. The user.Info of a successful response is a *User, which must be
+// redeemed by the caller.
+//
+// The BootstrapCredential is always read directly from the API server rather than from an informer cache.
+func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	cred, err := a.client.Get(ctx, a.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, false, fmt.Errorf("could not get BootstrapCredential: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(cred.Spec.TokenHash)) != 1 {
+		plog.Info("rejected bootstrap credential with incorrect token", "bootstrapCredential", klog.KObj(cred))
+		return nil, false, nil
+	}
+	if cred.Status.RedeemedAt != nil {
+		plog.Info("rejected bootstrap credential which was already redeemed",
+			"bootstrapCredential", klog.KObj(cred),
+			"redeemedAt", cred.Status.RedeemedAt.Time,
+		)
+		return nil, false, nil
+	}
+	now := a.clock.Now()
+	if !now.Before(cred.Spec.ExpiresAt.Time) {
+		plog.Info("rejected expired bootstrap credential",
+			"bootstrapCredential", klog.KObj(cred),
+			"expiresAt", cred.Spec.ExpiresAt.Time,
+		)
+		return nil, false, nil
+	}
+	if cred.Spec.ExpiresAt.Sub(now) > MaxTTL ||
+		(!cred.CreationTimestamp.IsZero() && cred.Spec.ExpiresAt.Sub(cred.CreationTimestamp.Time) > MaxTTL) {
+		plog.Info("rejected bootstrap credential which expires too late",
+			"bootstrapCredential", klog.KObj(cred),
+			"creationTimestamp", cred.CreationTimestamp.Time,
+			"expiresAt", cred.Spec.ExpiresAt.Time,
+			"maxTTL", MaxTTL,
+		)
+		return nil, false, nil
+	}
+
+	return &authenticator.Response{
+		User: &User{
+			DefaultInfo: user.DefaultInfo{
+				Name:   cred.Spec.Username,
+				Groups: append([]string{}, cred.Spec.Groups...),
+			},
+			ExpiresAt: cred.Spec.ExpiresAt.Time,
+			cred:      cred,
+			client:    a.client,
+			clock:     a.clock,
+		},
+	}, true, nil
+}
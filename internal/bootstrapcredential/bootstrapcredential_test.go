@@ -0,0 +1,208 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bootstrapcredential
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apiserver/pkg/authentication/user"
+	kubetesting "k8s.io/client-go/testing"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+)
+
+func TestGenerateToken(t *testing.T) {
+	token, tokenHash, err := GenerateToken(bytes.NewReader(bytes.Repeat([]byte{0x42}, tokenBytes)))
+	require.NoError(t, err)
+	require.Equal(t, "QkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkI", token)
+	require.Equal(t, HashToken(token), tokenHash)
+	require.Len(t, tokenHash, 64)
+
+	_, _, err = GenerateToken(strings.NewReader("too short"))
+	require.EqualError(t, err, "could not generate token: unexpected EOF")
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	redeemedAt := metav1.NewTime(now.Add(-time.Minute))
+
+	newCredential := func(mutate func(*auth1alpha1.BootstrapCredential)) *auth1alpha1.BootstrapCredential {
+		cred := &auth1alpha1.BootstrapCredential{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+			Spec: auth1alpha1.BootstrapCredentialSpec{
+				TokenHash: HashToken("test-token"),
+				Username:  "test-username",
+				Groups:    []string{"test-group-1", "test-group-2"},
+				ExpiresAt: metav1.NewTime(now.Add(time.Hour)),
+				Reason:    "test-reason",
+			},
+		}
+		if mutate != nil {
+			mutate(cred)
+		}
+		return cred
+	}
+
+	tests := []struct {
+		name           string
+		token          string
+		credentials    []runtime.Object
+		wantErr        string
+		wantOK         bool
+		wantUser       *user.DefaultInfo
+		wantRedeemedAt *metav1.Time
+	}{
+		{
+			name:    "not found",
+			token:   "test-token",
+			wantErr: `could not get BootstrapCredential: bootstrapcredentials.authentication.concierge.pinniped.dev "test-name" not found`,
+		},
+		{
+			name:        "wrong token",
+			token:       "wrong-token",
+			credentials: []runtime.Object{newCredential(nil)},
+		},
+		{
+			name:  "already redeemed",
+			token: "test-token",
+			credentials: []runtime.Object{newCredential(func(cred *auth1alpha1.BootstrapCredential) {
+				cred.Status.RedeemedAt = &redeemedAt
+			})},
+			wantRedeemedAt: &redeemedAt,
+		},
+		{
+			name:  "expired",
+			token: "test-token",
+			credentials: []runtime.Object{newCredential(func(cred *auth1alpha1.BootstrapCredential) {
+				cred.Spec.ExpiresAt = metav1.NewTime(now)
+			})},
+		},
+		{
+			name:  "expires more than the max TTL from now",
+			token: "test-token",
+			credentials: []runtime.Object{newCredential(func(cred *auth1alpha1.BootstrapCredential) {
+				cred.Spec.ExpiresAt = metav1.NewTime(now.Add(MaxTTL + time.Second))
+			})},
+		},
+		{
+			name:  "expires more than the max TTL after it was created",
+			token: "test-token",
+			credentials: []runtime.Object{newCredential(func(cred *auth1alpha1.BootstrapCredential) {
+				cred.CreationTimestamp = metav1.NewTime(now.Add(-MaxTTL))
+			})},
+		},
+		{
+			name:  "success at the max TTL",
+			token: "test-token",
+			credentials: []runtime.Object{newCredential(func(cred *auth1alpha1.BootstrapCredential) {
+				cred.CreationTimestamp = metav1.NewTime(now.Add(time.Hour - MaxTTL))
+			})},
+			wantOK: true,
+			wantUser: &user.DefaultInfo{
+				Name:   "test-username",
+				Groups: []string{"test-group-1", "test-group-2"},
+			},
+		},
+		{
+			name:        "success",
+			token:       "test-token",
+			credentials: []runtime.Object{newCredential(nil)},
+			wantOK:      true,
+			wantUser: &user.DefaultInfo{
+				Name:   "test-username",
+				Groups: []string{"test-group-1", "test-group-2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := pinnipedfake.NewSimpleClientset(tt.credentials...).AuthenticationV1alpha1().BootstrapCredentials()
+
+			a := New("test-name", client, clock.NewFakeClock(now))
+			resp, ok, err := a.AuthenticateToken(context.Background(), tt.token)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantOK, ok)
+			if tt.wantUser == nil {
+				require.Nil(t, resp)
+			} else {
+				require.Equal(t, tt.wantUser, &resp.User.(*User).DefaultInfo)
+				require.Equal(t, now.Add(time.Hour), resp.User.(*User).ExpiresAt)
+			}
+
+			// Authenticating the token never redeems the BootstrapCredential.
+			if len(tt.credentials) > 0 {
+				cred, err := client.Get(context.Background(), "test-name", metav1.GetOptions{})
+				require.NoError(t, err)
+				require.Equal(t, tt.wantRedeemedAt, cred.Status.RedeemedAt)
+			}
+		})
+	}
+}
+
+func TestRedeem(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	token, tokenHash, err := GenerateToken(strings.NewReader(strings.Repeat("x", tokenBytes)))
+	require.NoError(t, err)
+
+	fakeClient := pinnipedfake.NewSimpleClientset(&auth1alpha1.BootstrapCredential{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-name", ResourceVersion: "1"},
+		Spec: auth1alpha1.BootstrapCredentialSpec{
+			TokenHash: tokenHash,
+			Username:  "test-username",
+			ExpiresAt: metav1.NewTime(now.Add(time.Hour)),
+		},
+	})
+	// The fake clientset does not implement optimistic locking, so emulate it.
+	fakeClient.PrependReactor("update", "bootstrapcredentials", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		updated := action.(kubetesting.UpdateAction).GetObject().(*auth1alpha1.BootstrapCredential)
+		current, err := fakeClient.Tracker().Get(action.GetResource(), "", updated.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		if current.(*auth1alpha1.BootstrapCredential).ResourceVersion != updated.ResourceVersion {
+			return true, nil, apierrors.NewConflict(action.GetResource().GroupResource(), updated.Name, fmt.Errorf("stale"))
+		}
+		updated.ResourceVersion += "1"
+		return false, nil, nil
+	})
+	client := fakeClient.AuthenticationV1alpha1().BootstrapCredentials()
+	a := New("test-name", client, clock.NewFakeClock(now))
+
+	// Two concurrent requests can both authenticate, but only the first one can redeem the BootstrapCredential.
+	first, ok, err := a.AuthenticateToken(context.Background(), token)
+	require.NoError(t, err)
+	require.True(t, ok)
+	second, ok, err := a.AuthenticateToken(context.Background(), token)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, first.User.(*User).Redeem(context.Background()))
+	cred, err := client.Get(context.Background(), "test-name", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, metav1.NewTime(now), *cred.Status.RedeemedAt)
+
+	err = second.User.(*User).Redeem(context.Background())
+	require.EqualError(t, err, `could not mark BootstrapCredential as redeemed: Operation cannot be fulfilled on bootstrapcredentials.authentication.concierge.pinniped.dev "test-name": stale`)
+
+	resp, ok, err := a.AuthenticateToken(context.Background(), token)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Nil(t, resp)
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package browser opens login URLs in a browser. Unlike https://github.com/pkg/browser, it knows about the Windows
+// Subsystem for Linux, where the browser of the user runs on Windows, and it can use a command chosen by the user.
+package browser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/browser"
+
+	"go.pinniped.dev/internal/constable"
+)
+
+// ErrEmptyCommand is returned by Command for a command without any words.
+const ErrEmptyCommand = constable.Error("browser command is empty")
+
+// opener opens URLs. Its fields are replaced in tests.
+type opener struct {
+	goos     string
+	getenv   func(string) string
+	readFile func(string) ([]byte, error)
+	lookPath func(string) (string, error)
+	run      func(name string, args ...string) error
+	fallback func(url string) error
+}
+
+func newOpener() *opener {
+	return &opener{
+		goos:     runtime.GOOS,
+		getenv:   os.Getenv,
+		readFile: ioutil.ReadFile,
+		lookPath: exec.LookPath,
+		run:      runCommand,
+		fallback: browser.OpenURL,
+	}
+}
+
+// Open opens the URL in the default browser of the user. In WSL, it opens the URL in the default browser of Windows,
+// with wslview when it is installed and otherwise with PowerShell.
+func Open(url string) error {
+	return newOpener().open(url)
+}
+
+// Command returns a function which opens URLs with the command, e.g. "firefox --private-window". The command is
+// split into words at spaces, without any shell quoting. The URL replaces every "%s" in the words, or it is added as
+// the last argument when there is no "%s".
+func Command(command string) (func(url string) error, error) {
+	words := strings.Fields(command)
+	if len(words) == 0 {
+		return nil, ErrEmptyCommand
+	}
+	o := newOpener()
+	return func(url string) error {
+		return o.runWithURL(words, url)
+	}, nil
+}
+
+func (o *opener) open(url string) error {
+	if !o.isWSL() {
+		return o.fallback(url)
+	}
+	if _, err := o.lookPath("wslview"); err == nil {
+		return o.run("wslview", url)
+	}
+	if _, err := o.lookPath("powershell.exe"); err == nil {
+		// Start-Process opens URLs with their default handler. The URL is quoted as a PowerShell string literal,
+		// in which only single quotes are special.
+		return o.run("powershell.exe", "-NoProfile", "-NonInteractive", "-Command",
+			"Start-Process '"+strings.ReplaceAll(url, "'", "''")+"'")
+	}
+	return o.fallback(url)
+}
+
+// isWSL returns whether this is a Linux process in WSL. WSL sets $WSL_DISTRO_NAME in shells, and the release of its
+// kernel says "microsoft" (or "Microsoft" for WSL 1), which also works when the environment has been cleared.
+func (o *opener) isWSL() bool {
+	if o.goos != "linux" {
+		return false
+	}
+	if o.getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	release, err := o.readFile("/proc/sys/kernel/osrelease")
+	return err == nil && strings.Contains(strings.ToLower(string(release)), "microsoft")
+}
+
+func (o *opener) runWithURL(words []string, url string) error {
+	args := make([]string, 0, len(words))
+	replaced := false
+	for _, word := range words[1:] {
+		if strings.Contains(word, "%s") {
+			word = strings.ReplaceAll(word, "%s", url)
+			replaced = true
+		}
+		args = append(args, word)
+	}
+	if !replaced {
+		args = append(args, url)
+	}
+	return o.run(words[0], args...)
+}
+
+// runCommand starts the command without waiting for it, since a browser which was not running yet keeps running until
+// the user closes it. Its output is discarded, because the stdout of a login command is read by kubectl.
+func runCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...) //nolint:gosec // the command is chosen by the user
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start %s: %w", name, err)
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
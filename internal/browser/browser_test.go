@@ -0,0 +1,140 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package browser
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	const url = "https://issuer.example.com/authorize?client_id=pinniped-cli&state=it's"
+
+	tests := []struct {
+		name         string
+		goos         string
+		env          map[string]string
+		osRelease    string
+		commands     []string
+		wantRun      string
+		wantFallback bool
+	}{
+		{
+			name:         "macOS",
+			goos:         "darwin",
+			env:          map[string]string{"WSL_DISTRO_NAME": "Ubuntu"},
+			commands:     []string{"wslview"},
+			wantFallback: true,
+		},
+		{
+			name:         "Linux",
+			goos:         "linux",
+			osRelease:    "5.10.0-8-amd64\n",
+			commands:     []string{"wslview", "powershell.exe"},
+			wantFallback: true,
+		},
+		{
+			name:      "WSL with wslview",
+			goos:      "linux",
+			env:       map[string]string{"WSL_DISTRO_NAME": "Ubuntu"},
+			commands:  []string{"wslview", "powershell.exe"},
+			wantRun:   "wslview " + url,
+			osRelease: "5.10.16.3-microsoft-standard-WSL2\n",
+		},
+		{
+			name:      "WSL 1 without environment",
+			goos:      "linux",
+			osRelease: "4.4.0-19041-Microsoft\n",
+			commands:  []string{"wslview"},
+			wantRun:   "wslview " + url,
+		},
+		{
+			name:      "WSL with PowerShell",
+			goos:      "linux",
+			osRelease: "5.10.16.3-microsoft-standard-WSL2\n",
+			commands:  []string{"powershell.exe"},
+			wantRun:   "powershell.exe -NoProfile -NonInteractive -Command Start-Process 'https://issuer.example.com/authorize?client_id=pinniped-cli&state=it''s'",
+		},
+		{
+			name:         "WSL without any command",
+			goos:         "linux",
+			osRelease:    "5.10.16.3-microsoft-standard-WSL2\n",
+			wantFallback: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRun string
+			gotFallback := false
+			o := &opener{
+				goos:   tt.goos,
+				getenv: func(name string) string { return tt.env[name] },
+				readFile: func(path string) ([]byte, error) {
+					require.Equal(t, "/proc/sys/kernel/osrelease", path)
+					if tt.osRelease == "" {
+						return nil, os.ErrNotExist
+					}
+					return []byte(tt.osRelease), nil
+				},
+				lookPath: func(name string) (string, error) {
+					for _, command := range tt.commands {
+						if command == name {
+							return "/usr/bin/" + name, nil
+						}
+					}
+					return "", fmt.Errorf("%s not found", name)
+				},
+				run: func(name string, args ...string) error {
+					gotRun = strings.Join(append([]string{name}, args...), " ")
+					return nil
+				},
+				fallback: func(gotURL string) error {
+					require.Equal(t, url, gotURL)
+					gotFallback = true
+					return nil
+				},
+			}
+			require.NoError(t, o.open(url))
+			require.Equal(t, tt.wantRun, gotRun)
+			require.Equal(t, tt.wantFallback, gotFallback)
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	_, err := Command("  ")
+	require.Equal(t, ErrEmptyCommand, err)
+
+	tests := []struct {
+		name    string
+		command string
+		wantRun []string
+	}{
+		{name: "URL as the last argument", command: "firefox  --private-window", wantRun: []string{"firefox", "--private-window", "https://example.com"}},
+		{name: "URL in an argument", command: "chrome --app=%s --new-window", wantRun: []string{"chrome", "--app=https://example.com", "--new-window"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRun []string
+			o := &opener{run: func(name string, args ...string) error {
+				gotRun = append([]string{name}, args...)
+				return nil
+			}}
+			require.NoError(t, o.runWithURL(strings.Fields(tt.command), "https://example.com"))
+			require.Equal(t, tt.wantRun, gotRun)
+		})
+	}
+
+	open, err := Command("pinniped-browser-command-which-does-not-exist")
+	require.NoError(t, err)
+	err = open("https://example.com")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "could not start pinniped-browser-command-which-does-not-exist: ")
+}
@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package certauthority implements a simple x509 certificate authority suitable for use in an aggregated API service.
@@ -18,6 +18,7 @@ import (
 	"io"
 	"math/big"
 	"net"
+	"net/url"
 	"time"
 )
 
@@ -136,6 +137,16 @@ func (c *CA) Bundle() []byte {
 	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.caCertBytes})
 }
 
+// PrivateKeyToPEM returns the private key of the CA in PEM format, so that the CA can be stored and later restored
+// using Load.
+func (c *CA) PrivateKeyToPEM() ([]byte, error) {
+	privateKeyPKCS8, err := x509.MarshalPKCS8PrivateKey(c.signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key into PKCS8: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyPKCS8}), nil
+}
+
 // Pool returns the current CA signing bundle as a *x509.CertPool.
 func (c *CA) Pool() *x509.CertPool {
 	pool := x509.NewCertPool()
@@ -145,6 +156,10 @@ func (c *CA) Pool() *x509.CertPool {
 
 // Issue a new server certificate for the given identity and duration.
 func (c *CA) Issue(subject pkix.Name, dnsNames []string, ips []net.IP, ttl time.Duration) (*tls.Certificate, error) {
+	return c.issue(subject, dnsNames, ips, nil, ttl)
+}
+
+func (c *CA) issue(subject pkix.Name, dnsNames []string, ips []net.IP, uris []*url.URL, ttl time.Duration) (*tls.Certificate, error) {
 	// Choose a random 128 bit serial number.
 	serialNumber, err := randomSerial(c.env.serialRNG)
 	if err != nil {
@@ -180,6 +195,7 @@ func (c *CA) Issue(subject pkix.Name, dnsNames []string, ips []net.IP, ttl time.
 		IsCA:                  false,
 		DNSNames:              dnsNames,
 		IPAddresses:           ips,
+		URIs:                  uris,
 	}
 	certBytes, err := x509.CreateCertificate(rand.Reader, &template, caCert, &privateKey.PublicKey, c.signer)
 	if err != nil {
@@ -206,6 +222,12 @@ func (c *CA) IssuePEM(subject pkix.Name, dnsNames []string, ttl time.Duration) (
 	return toPEM(c.Issue(subject, dnsNames, nil, ttl))
 }
 
+// IssueClientCertPEM issues a new client certificate for the given identity and duration, with the given URIs as
+// subject alternative names, returning it as a pair of PEM-formatted byte slices for the certificate and private key.
+func (c *CA) IssueClientCertPEM(subject pkix.Name, uris []*url.URL, ttl time.Duration) ([]byte, []byte, error) {
+	return toPEM(c.issue(subject, nil, nil, uris, ttl))
+}
+
 func toPEM(cert *tls.Certificate, err error) ([]byte, []byte, error) {
 	// If the wrapped Issue() returned an error, pass it back.
 	if err != nil {
@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package certauthority
@@ -8,10 +8,12 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -192,6 +194,26 @@ func TestPool(t *testing.T) {
 	})
 }
 
+func TestPrivateKeyToPEM(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		ca, err := New(pkix.Name{CommonName: "test"}, 1*time.Hour)
+		require.NoError(t, err)
+
+		keyPEM, err := ca.PrivateKeyToPEM()
+		require.NoError(t, err)
+		loaded, err := Load(string(ca.Bundle()), string(keyPEM))
+		require.NoError(t, err)
+		require.Equal(t, ca.Bundle(), loaded.Bundle())
+		require.Equal(t, ca.signer, loaded.signer)
+	})
+
+	t.Run("unsupported key", func(t *testing.T) {
+		ca := CA{signer: &errSigner{}}
+		_, err := ca.PrivateKeyToPEM()
+		require.EqualError(t, err, "failed to marshal private key into PKCS8: x509: unknown key type while marshaling PKCS#8: *certauthority.errSigner")
+	})
+}
+
 type errSigner struct {
 	pubkey crypto.PublicKey
 	err    error
@@ -315,6 +337,26 @@ func TestIssuePEM(t *testing.T) {
 	require.NotEmpty(t, keyPEM)
 }
 
+func TestIssueClientCertPEM(t *testing.T) {
+	realCA, err := loadFromFiles(t, "./testdata/test.crt", "./testdata/test.key")
+	require.NoError(t, err)
+
+	uri, err := url.Parse("spiffe://cluster.example.com/user/test-user")
+	require.NoError(t, err)
+	certPEM, keyPEM, err := realCA.IssueClientCertPEM(pkix.Name{CommonName: "test-user"}, []*url.URL{uri}, 10*time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, keyPEM)
+
+	block, _ := pem.Decode(certPEM)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	require.Equal(t, "test-user", cert.Subject.CommonName)
+	require.Equal(t, []*url.URL{uri}, cert.URIs)
+	require.Empty(t, cert.DNSNames)
+	require.Empty(t, cert.IPAddresses)
+}
+
 func TestToPEM(t *testing.T) {
 	realCert, err := tls.LoadX509KeyPair("./testdata/test.crt", "./testdata/test.key")
 	require.NoError(t, err)
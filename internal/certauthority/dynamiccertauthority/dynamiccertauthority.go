@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package dynamiccertauthority implements a x509 certificate authority capable of issuing
@@ -7,12 +7,18 @@ package dynamiccertauthority
 
 import (
 	"crypto/x509/pkix"
+	"net/url"
 	"time"
 
 	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/constable"
 	"go.pinniped.dev/internal/dynamiccert"
 )
 
+// ErrNoSigningKey is returned by IssuePEM when the provider has never been given a keypair, which means that none
+// of the Concierge's strategies for obtaining a signing key have worked yet.
+const ErrNoSigningKey = constable.Error("no signing key is available")
+
 // CA is a type capable of issuing certificates.
 type CA struct {
 	provider dynamiccert.Provider
@@ -29,11 +35,29 @@ func New(provider dynamiccert.Provider) *CA {
 // IssuePEM issues a new server certificate for the given identity and duration, returning it as a
 // pair of PEM-formatted byte slices for the certificate and private key.
 func (c *CA) IssuePEM(subject pkix.Name, dnsNames []string, ttl time.Duration) ([]byte, []byte, error) {
-	caCrtPEM, caKeyPEM := c.provider.CurrentCertKeyContent()
-	ca, err := certauthority.Load(string(caCrtPEM), string(caKeyPEM))
+	ca, err := c.currentCA()
 	if err != nil {
 		return nil, nil, err
 	}
 
 	return ca.IssuePEM(subject, dnsNames, ttl)
 }
+
+// IssueClientCertPEM issues a new client certificate for the given identity and duration, with the given URIs as
+// subject alternative names, returning it as a pair of PEM-formatted byte slices for the certificate and private key.
+func (c *CA) IssueClientCertPEM(subject pkix.Name, uris []*url.URL, ttl time.Duration) ([]byte, []byte, error) {
+	ca, err := c.currentCA()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ca.IssueClientCertPEM(subject, uris, ttl)
+}
+
+func (c *CA) currentCA() (*certauthority.CA, error) {
+	caCrtPEM, caKeyPEM := c.provider.CurrentCertKeyContent()
+	if len(caCrtPEM) == 0 && len(caKeyPEM) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	return certauthority.Load(string(caCrtPEM), string(caKeyPEM))
+}
@@ -1,10 +1,11 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package dynamiccertauthority
 
 import (
 	"crypto/x509/pkix"
+	"net/url"
 	"testing"
 	"time"
 
@@ -39,7 +40,7 @@ func TestCAIssuePEM(t *testing.T) {
 	}{
 		{
 			name:      "no cert+key",
-			wantError: "could not load CA: tls: failed to find any PEM data in certificate input",
+			wantError: "no signing key is available",
 		},
 		{
 			name:      "only cert",
@@ -126,3 +127,28 @@ func TestCAIssuePEM(t *testing.T) {
 		})
 	}
 }
+
+func TestCAIssueClientCertPEM(t *testing.T) {
+	t.Parallel()
+
+	provider := dynamiccert.New()
+	ca := New(provider)
+	uri, err := url.Parse("spiffe://cluster.example.com/user/some-username")
+	require.NoError(t, err)
+
+	crtPEM, keyPEM, err := ca.IssueClientCertPEM(pkix.Name{CommonName: "some-username"}, []*url.URL{uri}, time.Hour)
+	require.EqualError(t, err, "no signing key is available")
+	require.Empty(t, crtPEM)
+	require.Empty(t, keyPEM)
+
+	caCrtPEM, caKeyPEM, err := testutil.CreateCertificate(time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	provider.Set(caCrtPEM, caKeyPEM)
+
+	crtPEM, keyPEM, err = ca.IssueClientCertPEM(pkix.Name{CommonName: "some-username"}, []*url.URL{uri}, time.Hour)
+	require.NoError(t, err)
+	crtAssertions := testutil.ValidateCertificate(t, string(caCrtPEM), string(crtPEM))
+	crtAssertions.RequireCommonName("some-username")
+	crtAssertions.RequireURI("spiffe://cluster.example.com/user/some-username")
+	crtAssertions.RequireMatchesPrivateKey(string(keyPEM))
+}
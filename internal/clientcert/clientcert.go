@@ -0,0 +1,171 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package clientcert implements a token authenticator for x509 client certificates which were issued outside of the
+// cluster. Since the Concierge only ever sees a token, the client proves that it holds the private key of its
+// certificate by signing a short-lived JWT with it and including the certificate chain in the JWT's "x5c" header.
+package clientcert
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"go.pinniped.dev/internal/constable"
+)
+
+const (
+	// ErrNoCommonName is returned when an otherwise valid client certificate has no subject common name to use
+	// as the username.
+	ErrNoCommonName = constable.Error("client certificate does not have a subject common name")
+
+	// MaxProofLifetime is the longest that a proof may be valid for, which limits how long a leaked proof could be
+	// replayed.
+	MaxProofLifetime = 5 * time.Minute
+
+	// clockSkewLeeway is how far the clocks of the client and the Concierge may disagree.
+	clockSkewLeeway = time.Minute
+)
+
+// Authenticator is an authenticator.Token which authenticates proofs of possession of client certificates.
+type Authenticator struct {
+	roots    *x509.CertPool
+	audience string
+	clock    clock.PassiveClock
+}
+
+// Assert that *Authenticator implements authenticator.Token.
+var _ authenticator.Token = (*Authenticator)(nil)
+
+// New creates an Authenticator from the provided spec.
+func New(spec *auth1alpha1.ClientCertificateAuthenticatorSpec, clock clock.PassiveClock) (*Authenticator, error) {
+	if spec.Audience == "" {
+		return nil, fmt.Errorf("audience must be specified")
+	}
+	caBundle, err := base64.StdEncoding.DecodeString(spec.CertificateAuthorityData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificateAuthorityData: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("invalid certificateAuthorityData: CA bundle does not contain any PEM-encoded certificates")
+	}
+	return &Authenticator{roots: roots, audience: spec.Audience, clock: clock}, nil
+}
+
+// AuthenticateToken implements authenticator.Token.
+func (a *Authenticator) AuthenticateToken(_ context.Context, token string) (*authenticator.Response, bool, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not parse client certificate proof: %w", err)
+	}
+	if len(parsed.Headers) != 1 {
+		return nil, false, fmt.Errorf("client certificate proof must have exactly one signature")
+	}
+
+	now := a.clock.Now()
+	chains, err := parsed.Headers[0].Certificates(x509.VerifyOptions{
+		Roots:       a.roots,
+		CurrentTime: now,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("could not verify client certificate: %w", err)
+	}
+	leaf := chains[0][0]
+
+	var claims jwt.Claims
+	if err := parsed.Claims(leaf.PublicKey, &claims); err != nil {
+		return nil, false, fmt.Errorf("could not verify signature of client certificate proof: %w", err)
+	}
+	if claims.IssuedAt == nil || claims.Expiry == nil {
+		return nil, false, fmt.Errorf("client certificate proof must have iat and exp claims")
+	}
+	if lifetime := claims.Expiry.Time().Sub(claims.IssuedAt.Time()); lifetime > MaxProofLifetime {
+		return nil, false, fmt.Errorf("client certificate proof must not be valid for more than %s, but it was valid for %s", MaxProofLifetime, lifetime)
+	}
+	// Check these explicitly rather than relying on the validation below, so that a proof issued in the future
+	// cannot be replayed for longer than MaxProofLifetime.
+	if issuedIn := claims.IssuedAt.Time().Sub(now); issuedIn > clockSkewLeeway {
+		return nil, false, fmt.Errorf("client certificate proof must not be issued in the future, but it was issued %s from now", issuedIn)
+	}
+	if remaining := claims.Expiry.Time().Sub(now); remaining > MaxProofLifetime+clockSkewLeeway {
+		return nil, false, fmt.Errorf("client certificate proof must not be valid for more than %s from now, but it was valid for %s", MaxProofLifetime, remaining)
+	}
+	if err := claims.ValidateWithLeeway(jwt.Expected{Audience: jwt.Audience{a.audience}, Time: now}, clockSkewLeeway); err != nil {
+		return nil, false, fmt.Errorf("invalid client certificate proof: %w", err)
+	}
+
+	if leaf.Subject.CommonName == "" {
+		return nil, false, ErrNoCommonName
+	}
+	return &authenticator.Response{
+		User: &user.DefaultInfo{
+			Name:   leaf.Subject.CommonName,
+			Groups: append([]string{}, leaf.Subject.Organization...),
+		},
+	}, true, nil
+}
+
+// NewProof returns a token which proves to an Authenticator with the given audience that the caller holds the private
+// key of the first certificate of chain. The rest of the chain should be any intermediate CAs.
+func NewProof(chain []*x509.Certificate, key crypto.Signer, audience string, now time.Time) (string, error) {
+	if len(chain) == 0 {
+		return "", fmt.Errorf("certificate chain must not be empty")
+	}
+	algorithm, err := signatureAlgorithm(key)
+	if err != nil {
+		return "", err
+	}
+
+	x5c := make([]string, 0, len(chain))
+	for _, cert := range chain {
+		x5c = append(x5c, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: algorithm, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("x5c", x5c),
+	)
+	if err != nil {
+		return "", fmt.Errorf("could not create signer: %w", err)
+	}
+
+	return jwt.Signed(signer).Claims(jwt.Claims{
+		Audience: jwt.Audience{audience},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(MaxProofLifetime)),
+	}).CompactSerialize()
+}
+
+func signatureAlgorithm(key crypto.Signer) (jose.SignatureAlgorithm, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		return jose.RS256, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		}
+	case ed25519.PublicKey:
+		return jose.EdDSA, nil
+	}
+	return "", fmt.Errorf("unsupported private key type %T", key.Public())
+}
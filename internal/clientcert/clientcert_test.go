@@ -0,0 +1,270 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package clientcert
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"go.pinniped.dev/internal/certauthority"
+)
+
+func TestNew(t *testing.T) {
+	ca, err := certauthority.New(pkix.Name{CommonName: "test-ca"}, time.Hour)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		spec    auth1alpha1.ClientCertificateAuthenticatorSpec
+		wantErr string
+	}{
+		{
+			name: "valid",
+			spec: auth1alpha1.ClientCertificateAuthenticatorSpec{
+				CertificateAuthorityData: base64.StdEncoding.EncodeToString(ca.Bundle()),
+				Audience:                 "some-audience",
+			},
+		},
+		{
+			name: "missing audience",
+			spec: auth1alpha1.ClientCertificateAuthenticatorSpec{
+				CertificateAuthorityData: base64.StdEncoding.EncodeToString(ca.Bundle()),
+			},
+			wantErr: "audience must be specified",
+		},
+		{
+			name: "invalid base64",
+			spec: auth1alpha1.ClientCertificateAuthenticatorSpec{
+				CertificateAuthorityData: "not base64!",
+				Audience:                 "some-audience",
+			},
+			wantErr: "invalid certificateAuthorityData: illegal base64 data at input byte 3",
+		},
+		{
+			name: "no certificates",
+			spec: auth1alpha1.ClientCertificateAuthenticatorSpec{
+				CertificateAuthorityData: base64.StdEncoding.EncodeToString([]byte("not a certificate")),
+				Audience:                 "some-audience",
+			},
+			wantErr: "invalid certificateAuthorityData: CA bundle does not contain any PEM-encoded certificates",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(&tt.spec, clock.RealClock{})
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, a)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, a)
+		})
+	}
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	ca, err := certauthority.New(pkix.Name{CommonName: "test-ca"}, time.Hour)
+	require.NoError(t, err)
+	otherCA, err := certauthority.New(pkix.Name{CommonName: "other-test-ca"}, time.Hour)
+	require.NoError(t, err)
+
+	issue := func(t *testing.T, ca *certauthority.CA, subject pkix.Name) ([]*x509.Certificate, crypto.Signer) {
+		t.Helper()
+		cert, err := ca.Issue(subject, nil, nil, time.Hour)
+		require.NoError(t, err)
+		return []*x509.Certificate{cert.Leaf}, cert.PrivateKey.(crypto.Signer)
+	}
+	signClaims := func(t *testing.T, chain []*x509.Certificate, key crypto.Signer, claims jwt.Claims) string {
+		t.Helper()
+		x5c := []string{}
+		for _, cert := range chain {
+			x5c = append(x5c, base64.StdEncoding.EncodeToString(cert.Raw))
+		}
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, (&jose.SignerOptions{}).WithHeader("x5c", x5c))
+		require.NoError(t, err)
+		token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	validChain, validKey := issue(t, ca, pkix.Name{CommonName: "some-user", Organization: []string{"group-a", "group-b"}})
+
+	tests := []struct {
+		name         string
+		token        func(t *testing.T) string
+		wantResponse *authenticator.Response
+		wantErr      string
+	}{
+		{
+			name: "valid",
+			token: func(t *testing.T) string {
+				token, err := NewProof(validChain, validKey, "some-audience", now)
+				require.NoError(t, err)
+				return token
+			},
+			wantResponse: &authenticator.Response{
+				User: &user.DefaultInfo{Name: "some-user", Groups: []string{"group-a", "group-b"}},
+			},
+		},
+		{
+			name: "valid with clock skew",
+			token: func(t *testing.T) string {
+				token, err := NewProof(validChain, validKey, "some-audience", now.Add(30*time.Second))
+				require.NoError(t, err)
+				return token
+			},
+			wantResponse: &authenticator.Response{
+				User: &user.DefaultInfo{Name: "some-user", Groups: []string{"group-a", "group-b"}},
+			},
+		},
+		{
+			name:    "not a JWT",
+			token:   func(t *testing.T) string { return "some-token" },
+			wantErr: "could not parse client certificate proof: square/go-jose: compact JWS format must have three parts",
+		},
+		{
+			name: "no x5c header",
+			token: func(t *testing.T) string {
+				return signClaims(t, nil, validKey, jwt.Claims{})
+			},
+			wantErr: "could not verify client certificate: square/go-jose: no x5c header present in message",
+		},
+		{
+			name: "certificate from another CA",
+			token: func(t *testing.T) string {
+				chain, key := issue(t, otherCA, pkix.Name{CommonName: "some-user"})
+				token, err := NewProof(chain, key, "some-audience", now)
+				require.NoError(t, err)
+				return token
+			},
+			wantErr: `could not verify client certificate: x509: certificate signed by unknown authority`,
+		},
+		{
+			name: "signed by a different key",
+			token: func(t *testing.T) string {
+				otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				require.NoError(t, err)
+				token, err := NewProof(validChain, otherKey, "some-audience", now)
+				require.NoError(t, err)
+				return token
+			},
+			wantErr: "could not verify signature of client certificate proof: square/go-jose: error in cryptographic primitive",
+		},
+		{
+			name: "wrong audience",
+			token: func(t *testing.T) string {
+				token, err := NewProof(validChain, validKey, "some-other-audience", now)
+				require.NoError(t, err)
+				return token
+			},
+			wantErr: "invalid client certificate proof: square/go-jose/jwt: validation failed, invalid audience claim (aud)",
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T) string {
+				token, err := NewProof(validChain, validKey, "some-audience", now.Add(-10*time.Minute))
+				require.NoError(t, err)
+				return token
+			},
+			wantErr: "invalid client certificate proof: square/go-jose/jwt: validation failed, token is expired (exp)",
+		},
+		{
+			name: "missing iat",
+			token: func(t *testing.T) string {
+				return signClaims(t, validChain, validKey, jwt.Claims{
+					Audience: jwt.Audience{"some-audience"},
+					Expiry:   jwt.NewNumericDate(now.Add(time.Minute)),
+				})
+			},
+			wantErr: "client certificate proof must have iat and exp claims",
+		},
+		{
+			name: "valid for too long",
+			token: func(t *testing.T) string {
+				return signClaims(t, validChain, validKey, jwt.Claims{
+					Audience: jwt.Audience{"some-audience"},
+					IssuedAt: jwt.NewNumericDate(now),
+					Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+				})
+			},
+			wantErr: "client certificate proof must not be valid for more than 5m0s, but it was valid for 1h0m0s",
+		},
+		{
+			name: "issued in the future",
+			token: func(t *testing.T) string {
+				token, err := NewProof(validChain, validKey, "some-audience", now.Add(2*time.Minute))
+				require.NoError(t, err)
+				return token
+			},
+			wantErr: "client certificate proof must not be issued in the future, but it was issued 2m0s from now",
+		},
+		{
+			name: "issued just within the clock skew leeway",
+			token: func(t *testing.T) string {
+				token, err := NewProof(validChain, validKey, "some-audience", now.Add(time.Minute))
+				require.NoError(t, err)
+				return token
+			},
+			wantResponse: &authenticator.Response{
+				User: &user.DefaultInfo{Name: "some-user", Groups: []string{"group-a", "group-b"}},
+			},
+		},
+		{
+			name: "no common name",
+			token: func(t *testing.T) string {
+				chain, key := issue(t, ca, pkix.Name{Organization: []string{"group-a"}})
+				token, err := NewProof(chain, key, "some-audience", now)
+				require.NoError(t, err)
+				return token
+			},
+			wantErr: "client certificate does not have a subject common name",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(&auth1alpha1.ClientCertificateAuthenticatorSpec{
+				CertificateAuthorityData: base64.StdEncoding.EncodeToString(ca.Bundle()),
+				Audience:                 "some-audience",
+			}, clock.NewFakeClock(now))
+			require.NoError(t, err)
+
+			response, authenticated, err := a.AuthenticateToken(context.Background(), tt.token(t))
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.False(t, authenticated)
+				require.Nil(t, response)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, authenticated)
+			require.Equal(t, tt.wantResponse, response)
+		})
+	}
+}
+
+func TestNewProof(t *testing.T) {
+	_, err := NewProof(nil, nil, "some-audience", time.Now())
+	require.EqualError(t, err, "certificate chain must not be empty")
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package clockskew validates the "exp" and "nbf" claims of ID tokens while tolerating an issuer whose clock
+// disagrees with ours, e.g. on hosts with unreliable NTP.
+package clockskew
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+)
+
+// notBeforeLeeway is the leeway which go-oidc always allows for the "nbf" claim.
+const notBeforeLeeway = time.Minute
+
+// Config returns config for verifying ID tokens whose time claims are checked by Check instead. When tolerance is
+// zero, the verifier keeps checking the time claims itself and Check does nothing.
+func Config(config coreosoidc.Config, tolerance time.Duration) *coreosoidc.Config {
+	if tolerance > 0 {
+		config.SkipExpiryCheck = true
+	}
+	return &config
+}
+
+// Check validates the "exp" and "nbf" claims of an ID token which was verified with a config from Config, allowing
+// now to be up to tolerance after its expiry or before its "nbf" time.
+func Check(token *coreosoidc.IDToken, tolerance time.Duration, now time.Time) error {
+	if tolerance <= 0 {
+		return nil
+	}
+	if token.Expiry.Add(tolerance).Before(now) {
+		return fmt.Errorf("token is expired (Token Expiry: %v, clock skew tolerance: %s)", token.Expiry, tolerance)
+	}
+
+	var claims struct {
+		NotBefore *json.Number `json:"nbf"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return fmt.Errorf("could not parse nbf claim: %w", err)
+	}
+	if claims.NotBefore == nil {
+		return nil
+	}
+	seconds, err := claims.NotBefore.Float64()
+	if err != nil {
+		return fmt.Errorf("could not parse nbf claim: %w", err)
+	}
+	notBefore := time.Unix(int64(seconds), 0)
+	if now.Add(notBeforeLeeway + tolerance).Before(notBefore) {
+		return fmt.Errorf("current time %v before the nbf (not before) time: %v (clock skew tolerance: %s)", now, notBefore, tolerance)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package clockskew
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+type keySet struct{ key *ecdsa.PublicKey }
+
+func (k keySet) VerifySignature(_ context.Context, token string) ([]byte, error) {
+	jws, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+	return jws.Verify(k.key)
+}
+
+func TestCheck(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+	require.NoError(t, err)
+
+	now := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		expiry    time.Time
+		notBefore time.Time
+		tolerance time.Duration
+		wantErr   string
+	}{
+		{
+			name:   "valid without tolerance",
+			expiry: now.Add(time.Minute),
+		},
+		{
+			name:    "expired without tolerance",
+			expiry:  now.Add(-time.Minute),
+			wantErr: "oidc: token is expired (Token Expiry: 2021-04-01 11:59:00 +0000 UTC)",
+		},
+		{
+			name:      "expired within tolerance",
+			expiry:    now.Add(-time.Minute),
+			tolerance: 2 * time.Minute,
+		},
+		{
+			name:      "expired beyond tolerance",
+			expiry:    now.Add(-3 * time.Minute),
+			tolerance: 2 * time.Minute,
+			wantErr:   "token is expired (Token Expiry: 2021-04-01 11:57:00 +0000 UTC, clock skew tolerance: 2m0s)",
+		},
+		{
+			name:      "not yet valid without tolerance",
+			expiry:    now.Add(time.Hour),
+			notBefore: now.Add(2 * time.Minute),
+			wantErr:   "oidc: current time 2021-04-01 12:00:00 +0000 UTC before the nbf (not before) time: 2021-04-01 12:02:00 +0000 UTC",
+		},
+		{
+			name:      "not yet valid within tolerance",
+			expiry:    now.Add(time.Hour),
+			notBefore: now.Add(2 * time.Minute),
+			tolerance: 2 * time.Minute,
+		},
+		{
+			name:      "not yet valid beyond tolerance",
+			expiry:    now.Add(time.Hour),
+			notBefore: now.Add(4 * time.Minute),
+			tolerance: 2 * time.Minute,
+			wantErr:   "current time 2021-04-01 12:00:00 +0000 UTC before the nbf (not before) time: 2021-04-01 12:04:00 +0000 UTC (clock skew tolerance: 2m0s)",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.Claims{Issuer: "https://issuer.example.com", Audience: jwt.Audience{"test-audience"}, Expiry: jwt.NewNumericDate(tt.expiry)}
+			if !tt.notBefore.IsZero() {
+				claims.NotBefore = jwt.NewNumericDate(tt.notBefore)
+			}
+			token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+			require.NoError(t, err)
+
+			config := Config(coreosoidc.Config{
+				ClientID:             "test-audience",
+				SupportedSigningAlgs: []string{coreosoidc.ES256},
+				Now:                  func() time.Time { return now },
+			}, tt.tolerance)
+			verifier := coreosoidc.NewVerifier("https://issuer.example.com", keySet{key: &key.PublicKey}, config)
+
+			validated, err := verifier.Verify(context.Background(), token)
+			if err == nil {
+				err = Check(validated, tt.tolerance, now)
+			}
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
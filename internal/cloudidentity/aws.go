@@ -0,0 +1,187 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudidentity
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"go.pinniped.dev/internal/constable"
+)
+
+const (
+	// awsTokenPrefix is the prefix used by aws-iam-authenticator and `aws eks get-token` for presigned URL tokens.
+	awsTokenPrefix = "k8s-aws-v1."
+
+	// awsClusterIDHeader is the header which binds a presigned request to a particular cluster.
+	awsClusterIDHeader = "x-k8s-aws-id"
+
+	// awsMaxPresignExpiry is the longest presigned URL lifetime that we are willing to accept.
+	awsMaxPresignExpiry = 15 * time.Minute
+
+	// awsMaxResponseBytes limits how much of the STS response body we are willing to read.
+	awsMaxResponseBytes = 64 * 1024
+
+	awsSTSTimeout = 10 * time.Second
+
+	errAWSMalformedToken = constable.Error("malformed AWS token")
+)
+
+// awsSTSHostPattern matches the global and regional STS endpoints in all AWS partitions.
+var awsSTSHostPattern = regexp.MustCompile(`^sts(\.[a-z0-9-]+)?\.amazonaws\.com(\.cn)?$`) //nolint:gochecknoglobals
+
+// awsAllowedQueryParams are the only query parameters that may appear in a presigned GetCallerIdentity URL.
+var awsAllowedQueryParams = sets.NewString( //nolint:gochecknoglobals
+	"Action",
+	"Version",
+	"X-Amz-Algorithm",
+	"X-Amz-Credential",
+	"X-Amz-Date",
+	"X-Amz-Expires",
+	"X-Amz-Security-Token",
+	"X-Amz-Signature",
+	"X-Amz-SignedHeaders",
+)
+
+// awsAssumedRolePattern matches the ARN of an assumed role session, e.g.
+// "arn:aws:sts::123456789012:assumed-role/my-role/my-session".
+var awsAssumedRolePattern = regexp.MustCompile(`^arn:(aws[a-z-]*):sts::(\d{12}):assumed-role/([^/]+)/[^/]+$`) //nolint:gochecknoglobals
+
+type awsVerifier struct {
+	clusterID        string
+	accountIDs       sets.String
+	client           *http.Client
+	isAllowedSTSHost func(host string) bool
+}
+
+func newAWSVerifier(spec *auth1alpha1.AWSIdentitySpec) *awsVerifier {
+	return &awsVerifier{
+		clusterID:  spec.ClusterID,
+		accountIDs: sets.NewString(spec.AccountIDs...),
+		client: &http.Client{
+			Timeout: awsSTSTimeout,
+			// Never follow redirects, since that could allow the presigned URL to send us somewhere other than STS.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		isAllowedSTSHost: awsSTSHostPattern.MatchString,
+	}
+}
+
+type awsGetCallerIdentityResponse struct {
+	GetCallerIdentityResponse struct {
+		GetCallerIdentityResult struct {
+			Account string `json:"Account"`
+			Arn     string `json:"Arn"`
+			UserID  string `json:"UserId"`
+		} `json:"GetCallerIdentityResult"`
+	} `json:"GetCallerIdentityResponse"`
+}
+
+func (v *awsVerifier) verifyPrincipal(ctx context.Context, token string) (string, error) {
+	presignedURL, err := v.parseToken(token)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, presignedURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("could not build STS request: %w", err)
+	}
+	req.Header.Set(awsClusterIDHeader, v.clusterID)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not call STS: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, awsMaxResponseBytes))
+	if err != nil {
+		return "", fmt.Errorf("could not read STS response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("STS rejected the presigned request with status %d", resp.StatusCode)
+	}
+
+	var identity awsGetCallerIdentityResponse
+	if err := json.Unmarshal(body, &identity); err != nil {
+		return "", fmt.Errorf("could not decode STS response: %w", err)
+	}
+	result := identity.GetCallerIdentityResponse.GetCallerIdentityResult
+
+	if !v.accountIDs.Has(result.Account) {
+		return "", fmt.Errorf("AWS account %q is not allowed", result.Account)
+	}
+	return canonicalizeAWSARN(result.Arn, result.Account)
+}
+
+// parseToken decodes the presigned URL from the token and validates that it can only be a GetCallerIdentity call to
+// STS which is bound to our cluster ID.
+func (v *awsVerifier) parseToken(token string) (*url.URL, error) {
+	if !strings.HasPrefix(token, awsTokenPrefix) {
+		return nil, fmt.Errorf("%w: missing %q prefix", errAWSMalformedToken, awsTokenPrefix)
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(token, awsTokenPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errAWSMalformedToken, err)
+	}
+	presignedURL, err := url.Parse(string(decoded))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errAWSMalformedToken, err)
+	}
+
+	if presignedURL.Scheme != "https" {
+		return nil, fmt.Errorf("%w: scheme must be https", errAWSMalformedToken)
+	}
+	if !v.isAllowedSTSHost(presignedURL.Host) {
+		return nil, fmt.Errorf("%w: unexpected STS host %q", errAWSMalformedToken, presignedURL.Host)
+	}
+	if presignedURL.Path != "/" {
+		return nil, fmt.Errorf("%w: unexpected path %q", errAWSMalformedToken, presignedURL.Path)
+	}
+
+	query := presignedURL.Query()
+	for key := range query {
+		if !awsAllowedQueryParams.Has(key) {
+			return nil, fmt.Errorf("%w: unexpected query parameter %q", errAWSMalformedToken, key)
+		}
+	}
+	if query.Get("Action") != "GetCallerIdentity" {
+		return nil, fmt.Errorf("%w: action must be GetCallerIdentity", errAWSMalformedToken)
+	}
+	signedHeaders := strings.Split(strings.ToLower(query.Get("X-Amz-SignedHeaders")), ";")
+	if !sets.NewString(signedHeaders...).Has(awsClusterIDHeader) {
+		return nil, fmt.Errorf("%w: %s header must be signed", errAWSMalformedToken, awsClusterIDHeader)
+	}
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expires <= 0 || time.Duration(expires)*time.Second > awsMaxPresignExpiry {
+		return nil, fmt.Errorf("%w: X-Amz-Expires must be between 1 and %d seconds", errAWSMalformedToken, int(awsMaxPresignExpiry.Seconds()))
+	}
+	return presignedURL, nil
+}
+
+// canonicalizeAWSARN maps assumed role session ARNs to their role ARN so that mappings are stable across sessions.
+func canonicalizeAWSARN(arn, account string) (string, error) {
+	if match := awsAssumedRolePattern.FindStringSubmatch(arn); match != nil {
+		return fmt.Sprintf("arn:%s:iam::%s:role/%s", match[1], match[2], match[3]), nil
+	}
+	if strings.HasPrefix(arn, "arn:") && strings.Contains(arn, ":iam::"+account+":") {
+		return arn, nil
+	}
+	return "", fmt.Errorf("unsupported AWS principal ARN %q", arn)
+}
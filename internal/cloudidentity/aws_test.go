@@ -0,0 +1,211 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudidentity
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+)
+
+func TestAWSVerifier(t *testing.T) {
+	t.Parallel()
+
+	const (
+		goodClusterID = "test-cluster-id"
+		goodAccount   = "123456789012"
+	)
+
+	var stsResponseStatus int
+	var stsResponseBody string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, goodClusterID, r.Header.Get("x-k8s-aws-id"))
+		require.Equal(t, "application/json", r.Header.Get("Accept"))
+		require.Equal(t, "GetCallerIdentity", r.URL.Query().Get("Action"))
+		w.WriteHeader(stsResponseStatus)
+		_, _ = w.Write([]byte(stsResponseBody))
+	}))
+	t.Cleanup(server.Close)
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	goodQuery := func() url.Values {
+		return url.Values{
+			"Action":              {"GetCallerIdentity"},
+			"Version":             {"2011-06-15"},
+			"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+			"X-Amz-Credential":    {"AKIAEXAMPLE/20210101/us-east-1/sts/aws4_request"},
+			"X-Amz-Date":          {"20210101T000000Z"},
+			"X-Amz-Expires":       {"60"},
+			"X-Amz-SignedHeaders": {"host;x-k8s-aws-id"},
+			"X-Amz-Signature":     {"some-signature"},
+		}
+	}
+	tokenFor := func(u url.URL, query url.Values) string {
+		u.RawQuery = query.Encode()
+		return "k8s-aws-v1." + base64.RawURLEncoding.EncodeToString([]byte(u.String()))
+	}
+	goodURL := url.URL{Scheme: "https", Host: serverURL.Host, Path: "/"}
+	callerIdentity := func(arn string) string {
+		return `{"GetCallerIdentityResponse":{"GetCallerIdentityResult":{"Account":"` + goodAccount + `","Arn":"` + arn + `","UserId":"AROAEXAMPLE:session"}}}`
+	}
+
+	tests := []struct {
+		name          string
+		token         string
+		status        int
+		body          string
+		wantPrincipal string
+		wantErr       string
+	}{
+		{
+			name:          "assumed role is canonicalized to its role",
+			token:         tokenFor(goodURL, goodQuery()),
+			status:        http.StatusOK,
+			body:          callerIdentity("arn:aws:sts::123456789012:assumed-role/ci-runner/i-0123456789"),
+			wantPrincipal: "arn:aws:iam::123456789012:role/ci-runner",
+		},
+		{
+			name:          "iam user",
+			token:         tokenFor(goodURL, goodQuery()),
+			status:        http.StatusOK,
+			body:          callerIdentity("arn:aws:iam::123456789012:user/alice"),
+			wantPrincipal: "arn:aws:iam::123456789012:user/alice",
+		},
+		{
+			name:    "missing prefix",
+			token:   "some-other-token",
+			wantErr: `malformed AWS token: missing "k8s-aws-v1." prefix`,
+		},
+		{
+			name:    "invalid base64",
+			token:   "k8s-aws-v1.!!!",
+			wantErr: "malformed AWS token: illegal base64 data at input byte 0",
+		},
+		{
+			name:    "non-https scheme",
+			token:   tokenFor(url.URL{Scheme: "http", Host: serverURL.Host, Path: "/"}, goodQuery()),
+			wantErr: "malformed AWS token: scheme must be https",
+		},
+		{
+			name:    "disallowed host",
+			token:   tokenFor(url.URL{Scheme: "https", Host: "evil.example.com", Path: "/"}, goodQuery()),
+			wantErr: `malformed AWS token: unexpected STS host "evil.example.com"`,
+		},
+		{
+			name:    "unexpected path",
+			token:   tokenFor(url.URL{Scheme: "https", Host: serverURL.Host, Path: "/other"}, goodQuery()),
+			wantErr: `malformed AWS token: unexpected path "/other"`,
+		},
+		{
+			name: "unexpected query parameter",
+			token: tokenFor(goodURL, func() url.Values {
+				q := goodQuery()
+				q.Set("RoleArn", "arn:aws:iam::123456789012:role/admin")
+				return q
+			}()),
+			wantErr: `malformed AWS token: unexpected query parameter "RoleArn"`,
+		},
+		{
+			name: "wrong action",
+			token: tokenFor(goodURL, func() url.Values {
+				q := goodQuery()
+				q.Set("Action", "AssumeRole")
+				return q
+			}()),
+			wantErr: "malformed AWS token: action must be GetCallerIdentity",
+		},
+		{
+			name: "cluster ID header not signed",
+			token: tokenFor(goodURL, func() url.Values {
+				q := goodQuery()
+				q.Set("X-Amz-SignedHeaders", "host")
+				return q
+			}()),
+			wantErr: "malformed AWS token: x-k8s-aws-id header must be signed",
+		},
+		{
+			name: "expiry too long",
+			token: tokenFor(goodURL, func() url.Values {
+				q := goodQuery()
+				q.Set("X-Amz-Expires", "3600")
+				return q
+			}()),
+			wantErr: "malformed AWS token: X-Amz-Expires must be between 1 and 900 seconds",
+		},
+		{
+			name:    "STS rejects the request",
+			token:   tokenFor(goodURL, goodQuery()),
+			status:  http.StatusForbidden,
+			body:    "denied",
+			wantErr: "STS rejected the presigned request with status 403",
+		},
+		{
+			name:    "STS returns invalid JSON",
+			token:   tokenFor(goodURL, goodQuery()),
+			status:  http.StatusOK,
+			body:    "<xml/>",
+			wantErr: "could not decode STS response: invalid character '<' looking for beginning of value",
+		},
+		{
+			name:    "account not allowed",
+			token:   tokenFor(goodURL, goodQuery()),
+			status:  http.StatusOK,
+			body:    `{"GetCallerIdentityResponse":{"GetCallerIdentityResult":{"Account":"999999999999","Arn":"arn:aws:iam::999999999999:user/mallory"}}}`,
+			wantErr: `AWS account "999999999999" is not allowed`,
+		},
+		{
+			name:    "unsupported ARN",
+			token:   tokenFor(goodURL, goodQuery()),
+			status:  http.StatusOK,
+			body:    callerIdentity("arn:aws:sts::123456789012:federated-user/bob"),
+			wantErr: `unsupported AWS principal ARN "arn:aws:sts::123456789012:federated-user/bob"`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Not parallel, since the subtests share the fake STS server's response.
+			stsResponseStatus = tt.status
+			stsResponseBody = tt.body
+
+			v := newAWSVerifier(&auth1alpha1.AWSIdentitySpec{ClusterID: goodClusterID, AccountIDs: []string{goodAccount}})
+			v.client = server.Client()
+			v.isAllowedSTSHost = func(host string) bool { return host == serverURL.Host }
+
+			principal, err := v.verifyPrincipal(context.Background(), tt.token)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Empty(t, principal)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantPrincipal, principal)
+		})
+	}
+}
+
+func TestAWSSTSHostPattern(t *testing.T) {
+	t.Parallel()
+	for host, want := range map[string]bool{
+		"sts.amazonaws.com":                    true,
+		"sts.us-west-2.amazonaws.com":          true,
+		"sts.cn-north-1.amazonaws.com.cn":      true,
+		"sts.amazonaws.com.evil.example.com":   false,
+		"evil.example.com":                     false,
+		"sts.us-west-2.amazonaws.com:8443":     false,
+		"iam.amazonaws.com":                    false,
+		"sts.us-west-2.amazonaws.com.attacker": false,
+	} {
+		require.Equal(t, want, awsSTSHostPattern.MatchString(host), host)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cloudidentity implements token authenticators which validate proofs of cloud workload identity, such as
+// presigned AWS STS GetCallerIdentity requests and Google-signed identity tokens.
+package cloudidentity
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"go.pinniped.dev/internal/constable"
+)
+
+// ErrInvalidSpec is returned by New() when the provided spec does not configure exactly one cloud provider.
+const ErrInvalidSpec = constable.Error("exactly one of spec.aws or spec.gcp must be specified")
+
+// principalVerifier validates a token and returns the canonical name of the cloud principal which it proves.
+type principalVerifier interface {
+	verifyPrincipal(ctx context.Context, token string) (string, error)
+}
+
+// Authenticator is an authenticator.Token which authenticates cloud principals and maps them to Kubernetes users.
+type Authenticator struct {
+	verifier principalVerifier
+	mappings map[string]auth1alpha1.CloudIdentityMapping
+	cancel   context.CancelFunc
+}
+
+// Assert that *Authenticator implements authenticator.Token.
+var _ authenticator.Token = (*Authenticator)(nil)
+
+// New creates an Authenticator from the provided spec.
+func New(spec *auth1alpha1.CloudIdentityAuthenticatorSpec) (*Authenticator, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var verifier principalVerifier
+	switch {
+	case spec.AWS != nil && spec.GCP == nil:
+		verifier = newAWSVerifier(spec.AWS)
+	case spec.GCP != nil && spec.AWS == nil:
+		verifier = newGCPVerifier(ctx, spec.GCP)
+	default:
+		cancel()
+		return nil, ErrInvalidSpec
+	}
+
+	mappings := make(map[string]auth1alpha1.CloudIdentityMapping, len(spec.Mappings))
+	for _, m := range spec.Mappings {
+		if _, exists := mappings[m.Principal]; exists {
+			cancel()
+			return nil, fmt.Errorf("duplicate mapping for principal %q", m.Principal)
+		}
+		mappings[m.Principal] = m
+	}
+
+	return &Authenticator{verifier: verifier, mappings: mappings, cancel: cancel}, nil
+}
+
+// AuthenticateToken implements authenticator.Token.
+func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	principal, err := a.verifier.verifyPrincipal(ctx, token)
+	if err != nil {
+		return nil, false, err
+	}
+
+	userInfo := &user.DefaultInfo{Name: principal}
+	if mapping, ok := a.mappings[principal]; ok {
+		if mapping.Username != "" {
+			userInfo.Name = mapping.Username
+		}
+		userInfo.Groups = append([]string{}, mapping.Groups...)
+	}
+	return &authenticator.Response{User: userInfo}, true, nil
+}
+
+// Close stops any background work (such as key refreshes) performed by this Authenticator. It is idempotent.
+func (a *Authenticator) Close() {
+	a.cancel()
+}
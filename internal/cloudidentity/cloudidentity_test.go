@@ -0,0 +1,145 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudidentity
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+)
+
+type fakeVerifier struct {
+	principal string
+	err       error
+}
+
+func (f *fakeVerifier) verifyPrincipal(context.Context, string) (string, error) {
+	return f.principal, f.err
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	aws := &auth1alpha1.AWSIdentitySpec{ClusterID: "some-cluster", AccountIDs: []string{"123456789012"}}
+	gcp := &auth1alpha1.GCPIdentitySpec{Audience: "some-audience", ProjectIDs: []string{"some-project"}}
+
+	tests := []struct {
+		name    string
+		spec    auth1alpha1.CloudIdentityAuthenticatorSpec
+		wantErr string
+	}{
+		{
+			name: "aws",
+			spec: auth1alpha1.CloudIdentityAuthenticatorSpec{AWS: aws},
+		},
+		{
+			name: "gcp",
+			spec: auth1alpha1.CloudIdentityAuthenticatorSpec{GCP: gcp},
+		},
+		{
+			name:    "neither",
+			spec:    auth1alpha1.CloudIdentityAuthenticatorSpec{},
+			wantErr: "exactly one of spec.aws or spec.gcp must be specified",
+		},
+		{
+			name:    "both",
+			spec:    auth1alpha1.CloudIdentityAuthenticatorSpec{AWS: aws, GCP: gcp},
+			wantErr: "exactly one of spec.aws or spec.gcp must be specified",
+		},
+		{
+			name: "duplicate mappings",
+			spec: auth1alpha1.CloudIdentityAuthenticatorSpec{
+				AWS: aws,
+				Mappings: []auth1alpha1.CloudIdentityMapping{
+					{Principal: "arn:aws:iam::123456789012:role/ci"},
+					{Principal: "arn:aws:iam::123456789012:role/ci"},
+				},
+			},
+			wantErr: `duplicate mapping for principal "arn:aws:iam::123456789012:role/ci"`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a, err := New(&tt.spec)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, a)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, a)
+			a.Close()
+			a.Close() // should be idempotent
+		})
+	}
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	t.Parallel()
+
+	mappings := map[string]auth1alpha1.CloudIdentityMapping{
+		"arn:aws:iam::123456789012:role/ci": {
+			Principal: "arn:aws:iam::123456789012:role/ci",
+			Username:  "ci-bot",
+			Groups:    []string{"ci", "deployers"},
+		},
+		"arn:aws:iam::123456789012:role/viewer": {
+			Principal: "arn:aws:iam::123456789012:role/viewer",
+			Groups:    []string{"viewers"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		verifier principalVerifier
+		wantResp *authenticator.Response
+		wantErr  string
+	}{
+		{
+			name:     "unmapped principal",
+			verifier: &fakeVerifier{principal: "arn:aws:iam::123456789012:user/alice"},
+			wantResp: &authenticator.Response{User: &user.DefaultInfo{Name: "arn:aws:iam::123456789012:user/alice"}},
+		},
+		{
+			name:     "mapped principal with username override",
+			verifier: &fakeVerifier{principal: "arn:aws:iam::123456789012:role/ci"},
+			wantResp: &authenticator.Response{User: &user.DefaultInfo{Name: "ci-bot", Groups: []string{"ci", "deployers"}}},
+		},
+		{
+			name:     "mapped principal with only groups",
+			verifier: &fakeVerifier{principal: "arn:aws:iam::123456789012:role/viewer"},
+			wantResp: &authenticator.Response{User: &user.DefaultInfo{Name: "arn:aws:iam::123456789012:role/viewer", Groups: []string{"viewers"}}},
+		},
+		{
+			name:     "verification error",
+			verifier: &fakeVerifier{err: errors.New("some verification error")},
+			wantErr:  "some verification error",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a := &Authenticator{verifier: tt.verifier, mappings: mappings, cancel: func() {}}
+			resp, ok, err := a.AuthenticateToken(context.Background(), "some-token")
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.False(t, ok)
+				require.Nil(t, resp)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
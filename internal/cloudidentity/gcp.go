@@ -0,0 +1,69 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudidentity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"go.pinniped.dev/internal/constable"
+)
+
+const (
+	gcpIssuer  = "https://accounts.google.com"
+	gcpJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+	// gcpServiceAccountDomainSuffix is the email domain suffix of all user-managed GCP service accounts.
+	gcpServiceAccountDomainSuffix = ".iam.gserviceaccount.com"
+
+	errGCPUnverifiedEmail = constable.Error("GCP identity token must contain a verified email claim")
+)
+
+type gcpVerifier struct {
+	verifier   *oidc.IDTokenVerifier
+	projectIDs sets.String
+}
+
+func newGCPVerifier(ctx context.Context, spec *auth1alpha1.GCPIdentitySpec) *gcpVerifier {
+	return newGCPVerifierWithKeySet(oidc.NewRemoteKeySet(ctx, gcpJWKSURL), spec)
+}
+
+func newGCPVerifierWithKeySet(keySet oidc.KeySet, spec *auth1alpha1.GCPIdentitySpec) *gcpVerifier {
+	return &gcpVerifier{
+		verifier:   oidc.NewVerifier(gcpIssuer, keySet, &oidc.Config{ClientID: spec.Audience}),
+		projectIDs: sets.NewString(spec.ProjectIDs...),
+	}
+}
+
+func (v *gcpVerifier) verifyPrincipal(ctx context.Context, token string) (string, error) {
+	idToken, err := v.verifier.Verify(ctx, token)
+	if err != nil {
+		return "", fmt.Errorf("invalid GCP identity token: %w", err)
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return "", fmt.Errorf("could not decode GCP identity token claims: %w", err)
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return "", errGCPUnverifiedEmail
+	}
+
+	at := strings.LastIndex(claims.Email, "@")
+	if at < 0 || !strings.HasSuffix(claims.Email[at+1:], gcpServiceAccountDomainSuffix) {
+		return "", fmt.Errorf("%q is not a GCP service account", claims.Email)
+	}
+	if project := strings.TrimSuffix(claims.Email[at+1:], gcpServiceAccountDomainSuffix); !v.projectIDs.Has(project) {
+		return "", fmt.Errorf("GCP project %q is not allowed", project)
+	}
+	return claims.Email, nil
+}
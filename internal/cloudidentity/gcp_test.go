@@ -0,0 +1,138 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudidentity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"go.pinniped.dev/internal/mocks/mockkeyset"
+)
+
+func TestGCPVerifier(t *testing.T) {
+	t.Parallel()
+
+	const goodAudience = "https://pinniped.example.com"
+
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: signingKey}, nil)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+	keySet := mockkeyset.NewMockKeySet(ctrl)
+	keySet.EXPECT().VerifySignature(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, rawJWT string) ([]byte, error) {
+			jws, err := jose.ParseSigned(rawJWT)
+			if err != nil {
+				return nil, err
+			}
+			return jws.Verify(&signingKey.PublicKey)
+		})
+
+	sign := func(t *testing.T, claims map[string]interface{}) string {
+		t.Helper()
+		token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+	goodClaims := func(email string, verified bool) map[string]interface{} {
+		return map[string]interface{}{
+			"iss":            "https://accounts.google.com",
+			"aud":            goodAudience,
+			"sub":            "1234567890",
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"email":          email,
+			"email_verified": verified,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		token         func(t *testing.T) string
+		wantPrincipal string
+		wantErr       string
+	}{
+		{
+			name: "service account in an allowed project",
+			token: func(t *testing.T) string {
+				return sign(t, goodClaims("builder@project-one.iam.gserviceaccount.com", true))
+			},
+			wantPrincipal: "builder@project-one.iam.gserviceaccount.com",
+		},
+		{
+			name: "service account in a disallowed project",
+			token: func(t *testing.T) string {
+				return sign(t, goodClaims("builder@project-three.iam.gserviceaccount.com", true))
+			},
+			wantErr: `GCP project "project-three" is not allowed`,
+		},
+		{
+			name: "not a service account",
+			token: func(t *testing.T) string {
+				return sign(t, goodClaims("alice@example.com", true))
+			},
+			wantErr: `"alice@example.com" is not a GCP service account`,
+		},
+		{
+			name: "unverified email",
+			token: func(t *testing.T) string {
+				return sign(t, goodClaims("builder@project-one.iam.gserviceaccount.com", false))
+			},
+			wantErr: "GCP identity token must contain a verified email claim",
+		},
+		{
+			name: "wrong audience",
+			token: func(t *testing.T) string {
+				claims := goodClaims("builder@project-one.iam.gserviceaccount.com", true)
+				claims["aud"] = "some-other-audience"
+				return sign(t, claims)
+			},
+			wantErr: `invalid GCP identity token: oidc: expected audience "https://pinniped.example.com" got ["some-other-audience"]`,
+		},
+		{
+			name: "wrong issuer",
+			token: func(t *testing.T) string {
+				claims := goodClaims("builder@project-one.iam.gserviceaccount.com", true)
+				claims["iss"] = "https://evil.example.com"
+				return sign(t, claims)
+			},
+			wantErr: `invalid GCP identity token: oidc: id token issued by a different provider, expected "https://accounts.google.com" got "https://evil.example.com"`,
+		},
+		{
+			name:    "malformed token",
+			token:   func(t *testing.T) string { return "not-a-jwt" },
+			wantErr: "invalid GCP identity token: oidc: malformed jwt: square/go-jose: compact JWS format must have three parts",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			v := newGCPVerifierWithKeySet(keySet, &auth1alpha1.GCPIdentitySpec{
+				Audience:   goodAudience,
+				ProjectIDs: []string{"project-one", "project-two"},
+			})
+			principal, err := v.verifyPrincipal(context.Background(), tt.token(t))
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Empty(t, principal)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantPrincipal, principal)
+		})
+	}
+}
@@ -10,12 +10,14 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apiserver/pkg/registry/rest"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	"k8s.io/client-go/pkg/version"
 
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/internal/registry/credentialrequest"
+	"go.pinniped.dev/internal/registry/whoamirequest"
 )
 
 type Config struct {
@@ -26,10 +28,15 @@ type Config struct {
 type ExtraConfig struct {
 	Authenticator                 credentialrequest.TokenCredentialRequestAuthenticator
 	Issuer                        credentialrequest.CertIssuer
-	StartControllersPostStartHook func(ctx context.Context)
+	IssuanceLimiter               *credentialrequest.IssuanceLimiter
+	Throttler                     *credentialrequest.Throttler
+	URISANTemplate                *credentialrequest.URISANTemplate
+	CallerPolicy                  *credentialrequest.CallerPolicy
+	StartControllersPostStartHook func(ctx context.Context) (stopped <-chan struct{})
 	Scheme                        *runtime.Scheme
 	NegotiatedSerializer          runtime.NegotiatedSerializer
-	GroupVersion                  schema.GroupVersion
+	LoginGroupVersion             schema.GroupVersion
+	IdentityGroupVersion          schema.GroupVersion
 }
 
 type PinnipedServer struct {
@@ -70,19 +77,34 @@ func (c completedConfig) New() (*PinnipedServer, error) {
 		GenericAPIServer: genericServer,
 	}
 
-	gvr := c.ExtraConfig.GroupVersion.WithResource("tokencredentialrequests")
-	storage := credentialrequest.NewREST(c.ExtraConfig.Authenticator, c.ExtraConfig.Issuer, gvr.GroupResource())
-	if err := s.GenericAPIServer.InstallAPIGroup(&genericapiserver.APIGroupInfo{
-		PrioritizedVersions:          []schema.GroupVersion{gvr.GroupVersion()},
-		VersionedResourcesStorageMap: map[string]map[string]rest.Storage{gvr.Version: {gvr.Resource: storage}},
-		OptionsExternalVersion:       &schema.GroupVersion{Version: "v1"},
-		Scheme:                       c.ExtraConfig.Scheme,
-		ParameterCodec:               metav1.ParameterCodec,
-		NegotiatedSerializer:         c.ExtraConfig.NegotiatedSerializer,
-	}); err != nil {
-		return nil, fmt.Errorf("could not install API group %s: %w", gvr.String(), err)
+	for _, f := range []func() (schema.GroupVersionResource, rest.Storage){
+		func() (schema.GroupVersionResource, rest.Storage) {
+			tokenCredReqGVR := c.ExtraConfig.LoginGroupVersion.WithResource("tokencredentialrequests")
+			tokenCredStorage := credentialrequest.NewREST(c.ExtraConfig.Authenticator, c.ExtraConfig.Issuer, c.ExtraConfig.IssuanceLimiter, c.ExtraConfig.Throttler, c.ExtraConfig.URISANTemplate, c.ExtraConfig.CallerPolicy, clock.RealClock{}, tokenCredReqGVR.GroupResource())
+			return tokenCredReqGVR, tokenCredStorage
+		},
+		func() (schema.GroupVersionResource, rest.Storage) {
+			whoAmIReqGVR := c.ExtraConfig.IdentityGroupVersion.WithResource("whoamirequests")
+			whoAmIStorage := whoamirequest.NewREST(whoAmIReqGVR.GroupResource())
+			return whoAmIReqGVR, whoAmIStorage
+		},
+	} {
+		gvr, storage := f()
+		if err := s.GenericAPIServer.InstallAPIGroup(&genericapiserver.APIGroupInfo{
+			PrioritizedVersions:          []schema.GroupVersion{gvr.GroupVersion()},
+			VersionedResourcesStorageMap: map[string]map[string]rest.Storage{gvr.Version: {gvr.Resource: storage}},
+			OptionsExternalVersion:       &schema.GroupVersion{Version: "v1"},
+			Scheme:                       c.ExtraConfig.Scheme,
+			ParameterCodec:               metav1.ParameterCodec,
+			NegotiatedSerializer:         c.ExtraConfig.NegotiatedSerializer,
+		}); err != nil {
+			return nil, fmt.Errorf("could not install API group %s: %w", gvr.String(), err)
+		}
 	}
 
+	// The controllers release their leader election Lease when they stop, so the server waits for them before it
+	// exits, and another replica can take over immediately.
+	controllersStopped := make(chan (<-chan struct{}), 1)
 	s.GenericAPIServer.AddPostStartHookOrDie("start-controllers",
 		func(postStartContext genericapiserver.PostStartHookContext) error {
 			plog.Debug("start-controllers post start hook starting")
@@ -92,11 +114,21 @@ func (c completedConfig) New() (*PinnipedServer, error) {
 				<-postStartContext.StopCh
 				cancel()
 			}()
-			c.ExtraConfig.StartControllersPostStartHook(ctx)
+			controllersStopped <- c.ExtraConfig.StartControllersPostStartHook(ctx)
 
 			return nil
 		},
 	)
+	s.GenericAPIServer.AddPreShutdownHookOrDie("stop-controllers",
+		func() error {
+			select {
+			case stopped := <-controllersStopped:
+				<-stopped
+			default: // the controllers were never started
+			}
+			return nil
+		},
+	)
 
 	return s, nil
 }
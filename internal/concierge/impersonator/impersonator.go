@@ -0,0 +1,263 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package impersonator implements the impersonation proxy of the Concierge, which authenticates requests using
+// Pinniped credentials and forwards them to the Kubernetes API server using impersonation headers. It allows
+// Pinniped to be used on clusters where the Concierge cannot issue client certificates, e.g. because the cluster
+// signing key is not available on managed clusters.
+package impersonator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+
+	loginapi "go.pinniped.dev/generated/latest/apis/concierge/login"
+	loginv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/login/v1alpha1"
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/groupsuffix"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/registry/credentialrequest"
+)
+
+const (
+	// ErrMissingToken is returned when a request to the proxy does not have a bearer token.
+	ErrMissingToken = constable.Error("missing bearer token")
+
+	// ErrInvalidToken is returned when the bearer token of a request is not an encoded TokenCredentialRequest.
+	ErrInvalidToken = constable.Error("bearer token is not an encoded TokenCredentialRequest")
+
+	// ErrImpersonationNotAllowed is returned when a request to the proxy already has impersonation headers, since
+	// the proxy would otherwise allow any user to impersonate anyone with the privileges of the Concierge.
+	ErrImpersonationNotAllowed = constable.Error("impersonation headers are not allowed in requests to the impersonation proxy")
+
+	// UIDExtraKey is the key of the user extra which carries the UID of the authenticated user to the API server.
+	// Kubernetes does not support impersonating a UID, so audit logs and admission webhooks find it among the extras.
+	UIDExtraKey = "authentication.concierge.pinniped.dev/uid"
+)
+
+// TokenCredentialRequestAuthenticator authenticates the credentials which are sent to the proxy, and returns how long
+// the proxy may trust the authenticated user without authenticating the credential again. It is implemented by the
+// TokenCredentialRequest API, so that the proxy is subject to the same policies, see
+// credentialrequest.REST.AuthenticateForImpersonation.
+type TokenCredentialRequestAuthenticator interface {
+	AuthenticateForImpersonation(ctx context.Context, req *loginapi.TokenCredentialRequest) (user.Info, time.Duration, error)
+}
+
+type proxy struct {
+	authenticator  TokenCredentialRequestAuthenticator
+	trustedProxies *credentialrequest.TrustedProxies
+	apiGroupSuffix string
+	serverURL      *url.URL
+	transport      http.RoundTripper
+	clock          clock.PassiveClock
+
+	sessionsLock sync.Mutex
+	sessions     map[[sha256.Size]byte]session
+}
+
+// session is a user which was authenticated by a credential, until the credential must be authenticated again.
+type session struct {
+	user      user.Info
+	expiresAt time.Time
+}
+
+// New returns a handler which forwards the requests of authenticated users to the API server described by the
+// restConfig, impersonating the user. The credentials of the restConfig must be allowed to impersonate users, groups,
+// and user extras. The UID of the user is sent as the UIDExtraKey extra.
+//
+// Clients authenticate by sending a TokenCredentialRequest as the bearer token, encoded as base64 JSON. This is the
+// same request that they would otherwise send to the TokenCredentialRequest API, so that all authenticators work.
+// Each credential is only authenticated once, like a TokenCredentialRequest, and the proxy remembers the user for as
+// long as a client certificate issued for it would be valid. Failed authentications are throttled per client address,
+// which is taken from the X-Forwarded-For header only when the request comes through one of the trustedProxies, e.g.
+// a load balancer in front of the proxy.
+func New(authenticator TokenCredentialRequestAuthenticator, trustedProxies *credentialrequest.TrustedProxies, apiGroupSuffix string, restConfig *rest.Config, clock clock.PassiveClock) (http.Handler, error) {
+	serverURL, err := url.Parse(restConfig.Host)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse host URL from in-cluster config: %w", err)
+	}
+	rt, err := rest.TransportFor(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not get in-cluster transport: %w", err)
+	}
+	return &proxy{
+		authenticator:  authenticator,
+		trustedProxies: trustedProxies,
+		apiGroupSuffix: apiGroupSuffix,
+		serverURL:      serverURL,
+		transport:      rt,
+		clock:          clock,
+		sessions:       map[[sha256.Size]byte]session{},
+	}, nil
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for header := range r.Header {
+		if strings.HasPrefix(http.CanonicalHeaderKey(header), "Impersonate-") {
+			plog.Debug("impersonation proxy refused request with impersonation headers", "url", r.URL.String())
+			writeStatus(w, http.StatusBadRequest, metav1.StatusReasonBadRequest, ErrImpersonationNotAllowed.Error())
+			return
+		}
+	}
+
+	userInfo, err := p.authenticate(r)
+	if err != nil {
+		plog.Debug("impersonation proxy received a request which could not be authenticated", "url", r.URL.String(), "error", err.Error())
+		var status apierrors.APIStatus
+		if errors.As(err, &status) {
+			writeAPIStatus(w, status.Status())
+			return
+		}
+		writeStatus(w, http.StatusUnauthorized, metav1.StatusReasonUnauthorized, "Unauthorized")
+		return
+	}
+
+	plog.Trace("impersonation proxy forwarding request", "url", r.URL.String(), "username", userInfo.GetName())
+
+	// Don't forward the Pinniped credential, so that the transport adds the credentials of the Concierge instead.
+	r.Header.Del("Authorization")
+
+	reverseProxy := httputil.NewSingleHostReverseProxy(p.serverURL)
+	reverseProxy.Transport = transport.NewImpersonatingRoundTripper(
+		transport.ImpersonationConfig{
+			UserName: userInfo.GetName(),
+			Groups:   userInfo.GetGroups(),
+			Extra:    extraWithUID(userInfo),
+		},
+		p.transport,
+	)
+	// Flush immediately so that watches and logs are streamed to the client.
+	reverseProxy.FlushInterval = -1
+	reverseProxy.ServeHTTP(w, r)
+}
+
+// extraWithUID returns a copy of the extras of the user, plus the UID of the user, if it has one.
+func extraWithUID(userInfo user.Info) map[string][]string {
+	extra := make(map[string][]string, len(userInfo.GetExtra())+1)
+	for k, v := range userInfo.GetExtra() {
+		extra[k] = v
+	}
+	if uid := userInfo.GetUID(); uid != "" {
+		extra[UIDExtraKey] = []string{uid}
+	}
+	return extra
+}
+
+func (p *proxy) authenticate(r *http.Request) (user.Info, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	key := sha256.Sum256([]byte(token))
+	now := p.clock.Now()
+
+	p.sessionsLock.Lock()
+	s, ok := p.sessions[key]
+	p.sessionsLock.Unlock()
+	if ok && now.Before(s.expiresAt) {
+		return s.user, nil
+	}
+
+	req, err := p.decodeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	ctx := credentialrequest.WithSourceIP(r.Context(), p.trustedProxies.SourceIP(r))
+	userInfo, ttl, err := p.authenticator.AuthenticateForImpersonation(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if userInfo == nil || userInfo.GetName() == "" {
+		return nil, constable.Error("not authenticated")
+	}
+
+	p.sessionsLock.Lock()
+	defer p.sessionsLock.Unlock()
+	for k, s := range p.sessions {
+		if !now.Before(s.expiresAt) {
+			delete(p.sessions, k)
+		}
+	}
+	p.sessions[key] = session{user: userInfo, expiresAt: now.Add(ttl)}
+	return userInfo, nil
+}
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+func (p *proxy) decodeToken(token string) (*loginapi.TokenCredentialRequest, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var external loginv1alpha1.TokenCredentialRequest
+	if err := json.Unmarshal(data, &external); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if external.Spec.Token == "" || external.Spec.Authenticator.APIGroup == nil {
+		return nil, ErrInvalidToken
+	}
+
+	// Like the TokenCredentialRequest API, restore the standard API group of the authenticator, which is how the
+	// authenticators are keyed in the cache.
+	apiGroup, ok := groupsuffix.Unreplace(*external.Spec.Authenticator.APIGroup, p.apiGroupSuffix)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return &loginapi.TokenCredentialRequest{
+		Spec: loginapi.TokenCredentialRequestSpec{
+			Token: external.Spec.Token,
+			Authenticator: corev1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     external.Spec.Authenticator.Kind,
+				Name:     external.Spec.Authenticator.Name,
+			},
+		},
+	}, nil
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason metav1.StatusReason, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(&metav1.Status{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+		Status:   metav1.StatusFailure,
+		Message:  message,
+		Reason:   reason,
+		Code:     int32(code),
+	})
+}
+
+func writeAPIStatus(w http.ResponseWriter, status metav1.Status) {
+	if status.Details != nil && status.Details.RetryAfterSeconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(status.Details.RetryAfterSeconds)))
+	}
+	status.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Status"}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(status.Code))
+	_ = json.NewEncoder(w).Encode(&status)
+}
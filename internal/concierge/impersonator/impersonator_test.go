@@ -0,0 +1,245 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package impersonator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/rest"
+
+	loginapi "go.pinniped.dev/generated/latest/apis/concierge/login"
+	loginv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/login/v1alpha1"
+	"go.pinniped.dev/internal/perror"
+)
+
+type fakeAuthenticator struct {
+	gotReq *loginapi.TokenCredentialRequest
+	calls  int
+	user   user.Info
+	ttl    time.Duration
+	err    error
+}
+
+func (f *fakeAuthenticator) AuthenticateForImpersonation(_ context.Context, req *loginapi.TokenCredentialRequest) (user.Info, time.Duration, error) {
+	f.gotReq = req
+	f.calls++
+	ttl := f.ttl
+	if ttl == 0 {
+		ttl = 5 * time.Minute
+	}
+	return f.user, ttl, f.err
+}
+
+func encodeToken(t *testing.T, apiGroup string, token string) string {
+	t.Helper()
+	data, err := json.Marshal(&loginv1alpha1.TokenCredentialRequest{
+		Spec: loginv1alpha1.TokenCredentialRequestSpec{
+			Token: token,
+			Authenticator: corev1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     "WebhookAuthenticator",
+				Name:     "test-authenticator",
+			},
+		},
+	})
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestImpersonator(t *testing.T) {
+	var upstreamRequest *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamRequest = r
+		_, _ = fmt.Fprint(w, "some upstream response")
+	}))
+	t.Cleanup(upstream.Close)
+
+	tests := []struct {
+		name          string
+		headers       map[string]string
+		authenticator *fakeAuthenticator
+		wantStatus    int
+		wantBody      string
+		wantUpstream  map[string][]string
+		wantGroup     string
+	}{
+		{
+			name:          "no token",
+			authenticator: &fakeAuthenticator{},
+			wantStatus:    http.StatusUnauthorized,
+			wantBody:      `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"Unauthorized","reason":"Unauthorized","code":401}` + "\n",
+		},
+		{
+			name:          "token is not an encoded TokenCredentialRequest",
+			headers:       map[string]string{"Authorization": "Bearer some-plain-token"},
+			authenticator: &fakeAuthenticator{},
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "authenticator API group does not have the API group suffix",
+			headers:       map[string]string{"Authorization": "Bearer " + encodeToken(t, "authentication.concierge.other.dev", "some-token")},
+			authenticator: &fakeAuthenticator{},
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "authentication error",
+			headers:       map[string]string{"Authorization": "Bearer " + encodeToken(t, "authentication.concierge.pinniped.dev", "some-token")},
+			authenticator: &fakeAuthenticator{err: fmt.Errorf("some authentication error")},
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "authentication refused by a policy",
+			headers:       map[string]string{"Authorization": "Bearer " + encodeToken(t, "authentication.concierge.pinniped.dev", "some-token")},
+			authenticator: &fakeAuthenticator{err: perror.New(perror.CodeRateLimited, "too many failed authentication attempts, please try again later").WithRetryAfter(30 * time.Second).APIStatus()},
+			wantStatus:    http.StatusTooManyRequests,
+			wantBody:      `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"too many failed authentication attempts, please try again later","reason":"TooManyRequests","details":{"causes":[{"reason":"PinnipedErrorCode","message":"rate_limited"}],"retryAfterSeconds":30},"code":429}` + "\n",
+		},
+		{
+			name:          "not authenticated",
+			headers:       map[string]string{"Authorization": "Bearer " + encodeToken(t, "authentication.concierge.pinniped.dev", "some-token")},
+			authenticator: &fakeAuthenticator{},
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name: "request with impersonation headers",
+			headers: map[string]string{
+				"Authorization":    "Bearer " + encodeToken(t, "authentication.concierge.pinniped.dev", "some-token"),
+				"Impersonate-User": "system:admin",
+			},
+			authenticator: &fakeAuthenticator{user: &user.DefaultInfo{Name: "test-user"}},
+			wantStatus:    http.StatusBadRequest,
+			wantBody:      `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"impersonation headers are not allowed in requests to the impersonation proxy","reason":"BadRequest","code":400}` + "\n",
+		},
+		{
+			name:    "success",
+			headers: map[string]string{"Authorization": "Bearer " + encodeToken(t, "authentication.concierge.pinniped.dev", "some-token")},
+			authenticator: &fakeAuthenticator{user: &user.DefaultInfo{
+				Name:   "test-user",
+				Groups: []string{"test-group-1", "test-group-2"},
+				Extra:  map[string][]string{"some-key": {"some-value"}},
+			}},
+			wantStatus: http.StatusOK,
+			wantBody:   "some upstream response",
+			wantUpstream: map[string][]string{
+				"Authorization":              {"Bearer some-concierge-token"},
+				"Impersonate-User":           {"test-user"},
+				"Impersonate-Group":          {"test-group-1", "test-group-2"},
+				"Impersonate-Extra-Some-Key": {"some-value"},
+			},
+			wantGroup: "authentication.concierge.pinniped.dev",
+		},
+		{
+			name:    "success with a UID",
+			headers: map[string]string{"Authorization": "Bearer " + encodeToken(t, "authentication.concierge.pinniped.dev", "some-token")},
+			authenticator: &fakeAuthenticator{user: &user.DefaultInfo{
+				Name:   "test-user",
+				UID:    "test-uid",
+				Groups: []string{"test-group"},
+				Extra:  map[string][]string{"some-key": {"some-value-1", "some-value-2"}},
+			}},
+			wantStatus: http.StatusOK,
+			wantBody:   "some upstream response",
+			wantUpstream: map[string][]string{
+				"Authorization":              {"Bearer some-concierge-token"},
+				"Impersonate-User":           {"test-user"},
+				"Impersonate-Group":          {"test-group"},
+				"Impersonate-Extra-Some-Key": {"some-value-1", "some-value-2"},
+				"Impersonate-Extra-Authentication.concierge.pinniped.dev%2fuid": {"test-uid"},
+			},
+			wantGroup: "authentication.concierge.pinniped.dev",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamRequest = nil
+			handler, err := New(tt.authenticator, nil, "pinniped.dev", &rest.Config{Host: upstream.URL, BearerToken: "some-concierge-token"}, clock.NewFakeClock(time.Now()))
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces?limit=10", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code)
+			body, err := ioutil.ReadAll(rec.Body)
+			require.NoError(t, err)
+			if tt.wantBody != "" {
+				require.Equal(t, tt.wantBody, string(body))
+			}
+
+			if tt.wantUpstream == nil {
+				require.Nil(t, upstreamRequest)
+				return
+			}
+			require.NotNil(t, upstreamRequest)
+			require.Equal(t, "/api/v1/namespaces", upstreamRequest.URL.Path)
+			require.Equal(t, "limit=10", upstreamRequest.URL.RawQuery)
+			for k, v := range tt.wantUpstream {
+				require.Equal(t, v, upstreamRequest.Header.Values(k), k)
+			}
+			require.Equal(t, "some-token", tt.authenticator.gotReq.Spec.Token)
+			require.Equal(t, tt.wantGroup, *tt.authenticator.gotReq.Spec.Authenticator.APIGroup)
+		})
+	}
+}
+
+func TestImpersonatorSessions(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.Header.Get("Impersonate-User"))
+	}))
+	t.Cleanup(upstream.Close)
+
+	authenticator := &fakeAuthenticator{user: &user.DefaultInfo{Name: "test-user"}, ttl: time.Minute}
+	fakeClock := clock.NewFakeClock(time.Now())
+	handler, err := New(authenticator, nil, "pinniped.dev", &rest.Config{Host: upstream.URL, BearerToken: "some-concierge-token"}, fakeClock)
+	require.NoError(t, err)
+
+	get := func(token string) (int, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.Header.Set("Authorization", "Bearer "+encodeToken(t, "authentication.concierge.pinniped.dev", token))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := get("some-token")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "test-user", body)
+	require.Equal(t, 1, authenticator.calls)
+
+	// The credential is not authenticated again while the session is valid, even when it would be refused now.
+	authenticator.err = fmt.Errorf("some authentication error")
+	fakeClock.Step(59 * time.Second)
+	code, body = get("some-token")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "test-user", body)
+	require.Equal(t, 1, authenticator.calls)
+
+	// Other credentials have their own sessions.
+	code, _ = get("some-other-token")
+	require.Equal(t, http.StatusUnauthorized, code)
+	require.Equal(t, 2, authenticator.calls)
+
+	// Once the session expires, the credential is authenticated again.
+	fakeClock.Step(time.Second)
+	code, _ = get("some-token")
+	require.Equal(t, http.StatusUnauthorized, code)
+	require.Equal(t, 3, authenticator.calls)
+}
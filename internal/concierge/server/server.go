@@ -8,6 +8,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -15,21 +17,29 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/clock"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	genericoptions "k8s.io/apiserver/pkg/server/options"
 
+	identityapi "go.pinniped.dev/generated/latest/apis/concierge/identity"
+	identityv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/identity/v1alpha1"
 	loginapi "go.pinniped.dev/generated/latest/apis/concierge/login"
 	loginv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/login/v1alpha1"
 	"go.pinniped.dev/internal/certauthority/dynamiccertauthority"
 	"go.pinniped.dev/internal/concierge/apiserver"
 	"go.pinniped.dev/internal/config/concierge"
+	"go.pinniped.dev/internal/config/reload"
 	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controller/kubecertagent"
 	"go.pinniped.dev/internal/controllermanager"
+	"go.pinniped.dev/internal/debughandler"
+	"go.pinniped.dev/internal/devauthenticator"
 	"go.pinniped.dev/internal/downward"
 	"go.pinniped.dev/internal/dynamiccert"
 	"go.pinniped.dev/internal/groupsuffix"
 	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/kubeclient"
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/internal/registry/credentialrequest"
 )
@@ -41,6 +51,7 @@ type App struct {
 	// CLI flags
 	configPath      string
 	downwardAPIPath string
+	dev             bool
 }
 
 // New constructs a new App with command line args, stdout and stderr.
@@ -92,6 +103,13 @@ func addCommandlineFlagsToCommand(cmd *cobra.Command, app *App) {
 		"path to Downward API volume mount",
 	)
 
+	cmd.Flags().BoolVar(
+		&app.dev,
+		"dev",
+		false,
+		"run in an insecure mode for local development and demos, which accepts well-known tokens for demo users",
+	)
+
 	plog.RemoveKlogGlobalFlags()
 }
 
@@ -103,6 +121,61 @@ func (a *App) runServer(ctx context.Context) error {
 		return fmt.Errorf("could not load config: %w", err)
 	}
 
+	// Count the client certificates issued to each user, and limit them when configured to do so.
+	issuanceLimiter := credentialrequest.NewIssuanceLimiter(cfg.CertificateIssuance.MaxCertificatesPerUserPerHour, clock.RealClock{})
+	uriSANTemplate := credentialrequest.NewURISANTemplate(cfg.CertificateIssuance.URISANTemplate)
+
+	// Restrict who can call the TokenCredentialRequest API, when configured to do so.
+	callerPolicy := credentialrequest.NewCallerPolicy(cfg.TokenCredentialRequest.RequireAuthenticatedCallers, cfg.TokenCredentialRequest.AnonymousAllowedAudiences)
+
+	// Slow down clients which keep presenting invalid tokens. Only the proxies which are explicitly trusted may tell
+	// the throttler the address of the client which they forward.
+	throttler := credentialrequest.NewThrottler(clock.RealClock{})
+	trustedProxies, err := credentialrequest.NewTrustedProxies(cfg.TokenCredentialRequest.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("could not configure trusted proxies: %w", err)
+	}
+
+	// Apply changes to the log level and format, the certificate issuance settings, the caller policy, and the trusted
+	// proxies without a restart.
+	// Only the watcher's goroutine uses appliedCfg, which is the config that was last applied.
+	appliedCfg := cfg
+	reload.New("concierge", a.configPath, func() error {
+		newCfg, err := concierge.Load(a.configPath)
+		if err != nil {
+			return err
+		}
+		if changes := reload.PendingRestart(
+			concierge.RestartRequiredChanges(cfg, newCfg),
+			concierge.RestartRequiredChanges(appliedCfg, newCfg),
+		); len(changes) > 0 {
+			plog.Warning("some changed settings in the config file will only take effect after the concierge is restarted",
+				"settings", changes,
+			)
+		}
+		if err := plog.ValidateAndSetLogLevelGlobally(newCfg.LogLevel); err != nil {
+			return fmt.Errorf("validate log level: %w", err)
+		}
+		if err := plog.ValidateAndSetLogFormatGlobally(newCfg.LogFormat, "concierge"); err != nil {
+			return fmt.Errorf("validate log format: %w", err)
+		}
+		issuanceLimiter.SetMaxPerHour(newCfg.CertificateIssuance.MaxCertificatesPerUserPerHour)
+		uriSANTemplate.Set(newCfg.CertificateIssuance.URISANTemplate)
+		callerPolicy.Set(newCfg.TokenCredentialRequest.RequireAuthenticatedCallers, newCfg.TokenCredentialRequest.AnonymousAllowedAudiences)
+		if err := trustedProxies.Set(newCfg.TokenCredentialRequest.TrustedProxies); err != nil {
+			return err
+		}
+		appliedCfg = newCfg
+		return nil
+	}).Start(ctx, reload.DefaultInterval)
+
+	// Serve the runtime profiles on a loopback address, when configured to do so.
+	if cfg.Debug.ListenAddress != "" {
+		if err := startDebugListener(ctx, cfg.Debug.ListenAddress); err != nil {
+			return fmt.Errorf("could not start debug listener: %w", err)
+		}
+	}
+
 	// Discover in which namespace we are installed.
 	podInfo, err := downward.Load(a.downwardAPIPath)
 	if err != nil {
@@ -111,6 +184,9 @@ func (a *App) runServer(ctx context.Context) error {
 
 	// Initialize the cache of active authenticators.
 	authenticators := authncache.New()
+	if a.dev {
+		seedDevAuthenticator(authenticators)
+	}
 
 	// This cert provider will provide certs to the API server and will
 	// be mutated by a controller to keep the certs up to date with what
@@ -123,21 +199,52 @@ func (a *App) runServer(ctx context.Context) error {
 	// cert issuer used to issue certs to Pinniped clients wishing to login.
 	dynamicSigningCertProvider := dynamiccert.New()
 
+	// Either have the cluster sign client certificates through the CSR API, or sign them with the signing key which
+	// the kube cert agent controllers fetch from the cluster.
+	var issuer credentialrequest.CertIssuer = dynamiccertauthority.New(dynamicSigningCertProvider)
+	var csrIssuer *kubecertagent.CSRIssuer
+	if cfg.KubeCertAgentConfig.Mode == concierge.KubeCertAgentModeCSR {
+		client, err := kubeclient.New()
+		if err != nil {
+			return fmt.Errorf("could not create client for the CertificateSigningRequest API: %w", err)
+		}
+		csrIssuer = kubecertagent.NewCSRIssuer(client.Kubernetes)
+		issuer = csrIssuer
+	}
+
+	// The impersonation proxy authenticates its clients like the TokenCredentialRequest API does, so that the caller
+	// policy, the throttler and the issuance limit apply to both.
+	loginGroup, ok := groupsuffix.Replace(loginv1alpha1.GroupName, *cfg.APIGroupSuffix)
+	if !ok {
+		return fmt.Errorf("cannot make api group from %s/%s", loginv1alpha1.GroupName, *cfg.APIGroupSuffix)
+	}
+	impersonationAuthenticator := credentialrequest.NewREST(authenticators, issuer, issuanceLimiter, throttler, uriSANTemplate, callerPolicy, clock.RealClock{},
+		schema.GroupResource{Group: loginGroup, Resource: "tokencredentialrequests"})
+
 	// Prepare to start the controllers, but defer actually starting them until the
 	// post start hook of the aggregated API server.
 	startControllersFunc, err := controllermanager.PrepareControllers(
 		&controllermanager.Config{
-			ServerInstallationInfo:     podInfo,
-			APIGroupSuffix:             *cfg.APIGroupSuffix,
-			NamesConfig:                &cfg.NamesConfig,
-			Labels:                     cfg.Labels,
-			KubeCertAgentConfig:        &cfg.KubeCertAgentConfig,
-			DiscoveryURLOverride:       cfg.DiscoveryInfo.URL,
-			DynamicServingCertProvider: dynamicServingCertProvider,
-			DynamicSigningCertProvider: dynamicSigningCertProvider,
-			ServingCertDuration:        time.Duration(*cfg.APIConfig.ServingCertificateConfig.DurationSeconds) * time.Second,
-			ServingCertRenewBefore:     time.Duration(*cfg.APIConfig.ServingCertificateConfig.RenewBeforeSeconds) * time.Second,
-			AuthenticatorCache:         authenticators,
+			ServerInstallationInfo:      podInfo,
+			APIGroupSuffix:              *cfg.APIGroupSuffix,
+			NamesConfig:                 &cfg.NamesConfig,
+			Labels:                      cfg.Labels,
+			KubeCertAgentConfig:         &cfg.KubeCertAgentConfig,
+			ImpersonationProxyConfig:    &cfg.ImpersonationProxy,
+			SupervisorConnectionConfig:  &cfg.SupervisorConnection,
+			ControllersConfig:           &cfg.Controllers,
+			DiscoveryURLOverride:        cfg.DiscoveryInfo.URL,
+			DynamicServingCertProvider:  dynamicServingCertProvider,
+			DynamicSigningCertProvider:  dynamicSigningCertProvider,
+			ServingCertDuration:         time.Duration(*cfg.APIConfig.ServingCertificateConfig.DurationSeconds) * time.Second,
+			ServingCertRenewBefore:      time.Duration(*cfg.APIConfig.ServingCertificateConfig.RenewBeforeSeconds) * time.Second,
+			ServingCertRotationOverlap:  time.Duration(*cfg.APIConfig.ServingCertificateConfig.RotationOverlapSeconds) * time.Second,
+			InformerResyncPeriod:        time.Duration(*cfg.Informers.ResyncPeriodSeconds) * time.Second,
+			InformerSecretLabelSelector: cfg.Informers.SecretLabelSelector,
+			AuthenticatorCache:          authenticators,
+			ImpersonationAuthenticator:  impersonationAuthenticator,
+			TrustedProxies:              trustedProxies,
+			CSRIssuer:                   csrIssuer,
 		},
 	)
 	if err != nil {
@@ -148,7 +255,12 @@ func (a *App) runServer(ctx context.Context) error {
 	aggregatedAPIServerConfig, err := getAggregatedAPIServerConfig(
 		dynamicServingCertProvider,
 		authenticators,
-		dynamiccertauthority.New(dynamicSigningCertProvider),
+		issuer,
+		issuanceLimiter,
+		throttler,
+		trustedProxies,
+		uriSANTemplate,
+		callerPolicy,
 		startControllersFunc,
 		*cfg.APIGroupSuffix,
 	)
@@ -166,31 +278,82 @@ func (a *App) runServer(ctx context.Context) error {
 	return server.GenericAPIServer.PrepareRun().Run(ctx.Done())
 }
 
+// startDebugListener serves the runtime profiles on the address until the context is done. The config
+// only allows loopback addresses, because the listener has no authentication.
+func startDebugListener(ctx context.Context, address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: debughandler.New()}
+	go func() {
+		if err := server.Serve(l); err != http.ErrServerClosed {
+			plog.Warning("debug listener exited", "err", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	plog.Info("debug listener started", "address", l.Addr().String())
+	return nil
+}
+
+// seedDevAuthenticator adds an authenticator for the demo users to the cache. It does not correspond to any custom
+// resource, so the cache cleaner controller will leave it alone.
+func seedDevAuthenticator(authenticators *authncache.Cache) {
+	users := devauthenticator.DemoUsers()
+	authenticators.Store(devauthenticator.CacheKey, devauthenticator.New(users))
+	plog.Warning("concierge is running in dev mode, which is not secure and must not be used in production")
+	for _, u := range users {
+		plog.Debug("added demo user",
+			"authenticatorKind", devauthenticator.Kind,
+			"authenticatorName", devauthenticator.Name,
+			"username", u.Username,
+			"groups", u.Groups,
+			"token", u.Token,
+		)
+	}
+}
+
 // Create a configuration for the aggregated API server.
 func getAggregatedAPIServerConfig(
 	dynamicCertProvider dynamiccert.Provider,
 	authenticator credentialrequest.TokenCredentialRequestAuthenticator,
 	issuer credentialrequest.CertIssuer,
-	startControllersPostStartHook func(context.Context),
+	issuanceLimiter *credentialrequest.IssuanceLimiter,
+	throttler *credentialrequest.Throttler,
+	trustedProxies *credentialrequest.TrustedProxies,
+	uriSANTemplate *credentialrequest.URISANTemplate,
+	callerPolicy *credentialrequest.CallerPolicy,
+	startControllersPostStartHook func(context.Context) <-chan struct{},
 	apiGroupSuffix string,
 ) (*apiserver.Config, error) {
 	loginConciergeAPIGroup, ok := groupsuffix.Replace(loginv1alpha1.GroupName, apiGroupSuffix)
 	if !ok {
 		return nil, fmt.Errorf("cannot make api group from %s/%s", loginv1alpha1.GroupName, apiGroupSuffix)
 	}
+	identityConciergeAPIGroup, ok := groupsuffix.Replace(identityv1alpha1.GroupName, apiGroupSuffix)
+	if !ok {
+		return nil, fmt.Errorf("cannot make api group from %s/%s", identityv1alpha1.GroupName, apiGroupSuffix)
+	}
 
-	scheme := getAggregatedAPIServerScheme(loginConciergeAPIGroup, apiGroupSuffix)
+	scheme := getAggregatedAPIServerScheme(loginConciergeAPIGroup, identityConciergeAPIGroup, apiGroupSuffix)
 	codecs := serializer.NewCodecFactory(scheme)
 
 	defaultEtcdPathPrefix := fmt.Sprintf("/registry/%s", loginConciergeAPIGroup)
-	groupVersion := schema.GroupVersion{
+	loginGroupVersion := schema.GroupVersion{
 		Group:   loginConciergeAPIGroup,
 		Version: loginv1alpha1.SchemeGroupVersion.Version,
 	}
+	identityGroupVersion := schema.GroupVersion{
+		Group:   identityConciergeAPIGroup,
+		Version: identityv1alpha1.SchemeGroupVersion.Version,
+	}
 
 	recommendedOptions := genericoptions.NewRecommendedOptions(
 		defaultEtcdPathPrefix,
-		codecs.LegacyCodec(groupVersion),
+		codecs.LegacyCodec(loginGroupVersion, identityGroupVersion),
 	)
 	recommendedOptions.Etcd = nil // turn off etcd storage because we don't need it yet
 	recommendedOptions.SecureServing.ServerCert.GeneratedCert = dynamicCertProvider
@@ -210,31 +373,46 @@ func getAggregatedAPIServerConfig(
 		return nil, err
 	}
 
+	// Let the TokenCredentialRequest API throttle failed authentications per client. Requests for the aggregated API
+	// are proxied by the Kubernetes API server, so they only carry the address of the client when the API server is
+	// configured as a trusted proxy.
+	buildHandlerChain := serverConfig.BuildHandlerChainFunc
+	serverConfig.BuildHandlerChainFunc = func(apiHandler http.Handler, c *genericapiserver.Config) http.Handler {
+		return buildHandlerChain(withSourceIP(apiHandler, trustedProxies), c)
+	}
+
 	apiServerConfig := &apiserver.Config{
 		GenericConfig: serverConfig,
 		ExtraConfig: apiserver.ExtraConfig{
 			Authenticator:                 authenticator,
 			Issuer:                        issuer,
+			IssuanceLimiter:               issuanceLimiter,
+			Throttler:                     throttler,
+			URISANTemplate:                uriSANTemplate,
+			CallerPolicy:                  callerPolicy,
 			StartControllersPostStartHook: startControllersPostStartHook,
 			Scheme:                        scheme,
 			NegotiatedSerializer:          codecs,
-			GroupVersion:                  groupVersion,
+			LoginGroupVersion:             loginGroupVersion,
+			IdentityGroupVersion:          identityGroupVersion,
 		},
 	}
 	return apiServerConfig, nil
 }
 
-func getAggregatedAPIServerScheme(loginConciergeAPIGroup, apiGroupSuffix string) *runtime.Scheme {
+func getAggregatedAPIServerScheme(loginConciergeAPIGroup, identityConciergeAPIGroup, apiGroupSuffix string) *runtime.Scheme {
 	// standard set up of the server side scheme
 	scheme := runtime.NewScheme()
 
 	// add the options to empty v1
 	metav1.AddToGroupVersion(scheme, metav1.Unversioned)
 
-	// nothing fancy is required if using the standard group
-	if loginConciergeAPIGroup == loginv1alpha1.GroupName {
+	// nothing fancy is required if using the standard groups
+	if loginConciergeAPIGroup == loginv1alpha1.GroupName && identityConciergeAPIGroup == identityv1alpha1.GroupName {
 		utilruntime.Must(loginv1alpha1.AddToScheme(scheme))
 		utilruntime.Must(loginapi.AddToScheme(scheme))
+		utilruntime.Must(identityv1alpha1.AddToScheme(scheme))
+		utilruntime.Must(identityapi.AddToScheme(scheme))
 		return scheme
 	}
 
@@ -242,14 +420,22 @@ func getAggregatedAPIServerScheme(loginConciergeAPIGroup, apiGroupSuffix string)
 	tmpScheme := runtime.NewScheme()
 	utilruntime.Must(loginv1alpha1.AddToScheme(tmpScheme))
 	utilruntime.Must(loginapi.AddToScheme(tmpScheme))
+	utilruntime.Must(identityv1alpha1.AddToScheme(tmpScheme))
+	utilruntime.Must(identityapi.AddToScheme(tmpScheme))
 
 	for gvk := range tmpScheme.AllKnownTypes() {
 		if gvk.GroupVersion() == metav1.Unversioned {
 			continue // metav1.AddToGroupVersion registers types outside of our aggregated API group that we need to ignore
 		}
 
-		if gvk.Group != loginv1alpha1.GroupName {
-			panic("tmp scheme has types not in the aggregated API group: " + gvk.Group) // programmer error
+		var newGroup string
+		switch gvk.Group {
+		case loginv1alpha1.GroupName:
+			newGroup = loginConciergeAPIGroup
+		case identityv1alpha1.GroupName:
+			newGroup = identityConciergeAPIGroup
+		default:
+			panic("tmp scheme has types not in the aggregated API groups: " + gvk.Group) // programmer error
 		}
 
 		obj, err := tmpScheme.New(gvk)
@@ -257,7 +443,7 @@ func getAggregatedAPIServerScheme(loginConciergeAPIGroup, apiGroupSuffix string)
 			panic(err) // programmer error, scheme internal code is broken
 		}
 		newGVK := schema.GroupVersionKind{
-			Group:   loginConciergeAPIGroup,
+			Group:   newGroup,
 			Version: gvk.Version,
 			Kind:    gvk.Kind,
 		}
@@ -266,9 +452,11 @@ func getAggregatedAPIServerScheme(loginConciergeAPIGroup, apiGroupSuffix string)
 		scheme.AddKnownTypeWithName(newGVK, obj)
 	}
 
-	// manually register conversions and defaulting into the correct scheme since we cannot directly call loginv1alpha1.AddToScheme
+	// manually register conversions and defaulting into the correct scheme since we cannot directly call AddToScheme
 	utilruntime.Must(loginv1alpha1.RegisterConversions(scheme))
 	utilruntime.Must(loginv1alpha1.RegisterDefaults(scheme))
+	utilruntime.Must(identityv1alpha1.RegisterConversions(scheme))
+	utilruntime.Must(identityv1alpha1.RegisterDefaults(scheme))
 
 	// we do not want to return errors from the scheme and instead would prefer to defer
 	// to the REST storage layer for consistency.  The simplest way to do this is to force
@@ -311,3 +499,10 @@ func getAggregatedAPIServerScheme(loginConciergeAPIGroup, apiGroupSuffix string)
 
 	return scheme
 }
+
+// withSourceIP adds the IP address of the client to the context of each request.
+func withSourceIP(handler http.Handler, trustedProxies *credentialrequest.TrustedProxies) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(credentialrequest.WithSourceIP(r.Context(), trustedProxies.SourceIP(r))))
+	})
+}
@@ -18,6 +18,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
+	identityapi "go.pinniped.dev/generated/latest/apis/concierge/identity"
+	identityv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/identity/v1alpha1"
 	loginapi "go.pinniped.dev/generated/latest/apis/concierge/login"
 	loginv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/login/v1alpha1"
 	"go.pinniped.dev/internal/groupsuffix"
@@ -33,6 +35,7 @@ Usage:
 
 Flags:
   -c, --config string              path to configuration file (default "pinniped.yaml")
+      --dev                        run in an insecure mode for local development and demos, which accepts well-known tokens for demo users
       --downward-api-path string   path to Downward API volume mount (default "/etc/podinfo")
   -h, --help                       help for pinniped-concierge
 `
@@ -66,6 +69,10 @@ func TestCommand(t *testing.T) {
 			name: "LongConfigFlagSucceeds",
 			args: []string{"--config", "some/path/to/config.yaml"},
 		},
+		{
+			name: "DevFlagSucceeds",
+			args: []string{"--dev"},
+		},
 		{
 			name: "OneArgWithConfigFlagFails",
 			args: []string{
@@ -109,6 +116,16 @@ func Test_getAggregatedAPIServerScheme(t *testing.T) {
 		Version: runtime.APIVersionInternal,
 	}
 
+	// the standard identity group
+	regularIdentityGV := schema.GroupVersion{
+		Group:   "identity.concierge.pinniped.dev",
+		Version: "v1alpha1",
+	}
+	regularIdentityGVInternal := schema.GroupVersion{
+		Group:   "identity.concierge.pinniped.dev",
+		Version: runtime.APIVersionInternal,
+	}
+
 	// the canonical other group
 	otherGV := schema.GroupVersion{
 		Group:   "login.concierge.walrus.tld",
@@ -119,6 +136,16 @@ func Test_getAggregatedAPIServerScheme(t *testing.T) {
 		Version: runtime.APIVersionInternal,
 	}
 
+	// the canonical other identity group
+	otherIdentityGV := schema.GroupVersion{
+		Group:   "identity.concierge.walrus.tld",
+		Version: "v1alpha1",
+	}
+	otherIdentityGVInternal := schema.GroupVersion{
+		Group:   "identity.concierge.walrus.tld",
+		Version: runtime.APIVersionInternal,
+	}
+
 	// kube's core internal
 	internalGV := schema.GroupVersion{
 		Group:   "",
@@ -153,6 +180,23 @@ func Test_getAggregatedAPIServerScheme(t *testing.T) {
 
 				regularGVInternal.WithKind("WatchEvent"): reflect.TypeOf(&metav1.InternalEvent{}).Elem(),
 
+				regularIdentityGV.WithKind("WhoAmIRequest"):     reflect.TypeOf(&identityv1alpha1.WhoAmIRequest{}).Elem(),
+				regularIdentityGV.WithKind("WhoAmIRequestList"): reflect.TypeOf(&identityv1alpha1.WhoAmIRequestList{}).Elem(),
+
+				regularIdentityGVInternal.WithKind("WhoAmIRequest"):     reflect.TypeOf(&identityapi.WhoAmIRequest{}).Elem(),
+				regularIdentityGVInternal.WithKind("WhoAmIRequestList"): reflect.TypeOf(&identityapi.WhoAmIRequestList{}).Elem(),
+
+				regularIdentityGV.WithKind("CreateOptions"): reflect.TypeOf(&metav1.CreateOptions{}).Elem(),
+				regularIdentityGV.WithKind("DeleteOptions"): reflect.TypeOf(&metav1.DeleteOptions{}).Elem(),
+				regularIdentityGV.WithKind("ExportOptions"): reflect.TypeOf(&metav1.ExportOptions{}).Elem(),
+				regularIdentityGV.WithKind("GetOptions"):    reflect.TypeOf(&metav1.GetOptions{}).Elem(),
+				regularIdentityGV.WithKind("ListOptions"):   reflect.TypeOf(&metav1.ListOptions{}).Elem(),
+				regularIdentityGV.WithKind("PatchOptions"):  reflect.TypeOf(&metav1.PatchOptions{}).Elem(),
+				regularIdentityGV.WithKind("UpdateOptions"): reflect.TypeOf(&metav1.UpdateOptions{}).Elem(),
+				regularIdentityGV.WithKind("WatchEvent"):    reflect.TypeOf(&metav1.WatchEvent{}).Elem(),
+
+				regularIdentityGVInternal.WithKind("WatchEvent"): reflect.TypeOf(&metav1.InternalEvent{}).Elem(),
+
 				// the types below this line do not really matter to us because they are in the core group
 
 				internalGV.WithKind("WatchEvent"): reflect.TypeOf(&metav1.InternalEvent{}).Elem(),
@@ -195,6 +239,23 @@ func Test_getAggregatedAPIServerScheme(t *testing.T) {
 
 				otherGVInternal.WithKind("WatchEvent"): reflect.TypeOf(&metav1.InternalEvent{}).Elem(),
 
+				otherIdentityGV.WithKind("WhoAmIRequest"):     reflect.TypeOf(&identityv1alpha1.WhoAmIRequest{}).Elem(),
+				otherIdentityGV.WithKind("WhoAmIRequestList"): reflect.TypeOf(&identityv1alpha1.WhoAmIRequestList{}).Elem(),
+
+				otherIdentityGVInternal.WithKind("WhoAmIRequest"):     reflect.TypeOf(&identityapi.WhoAmIRequest{}).Elem(),
+				otherIdentityGVInternal.WithKind("WhoAmIRequestList"): reflect.TypeOf(&identityapi.WhoAmIRequestList{}).Elem(),
+
+				otherIdentityGV.WithKind("CreateOptions"): reflect.TypeOf(&metav1.CreateOptions{}).Elem(),
+				otherIdentityGV.WithKind("DeleteOptions"): reflect.TypeOf(&metav1.DeleteOptions{}).Elem(),
+				otherIdentityGV.WithKind("ExportOptions"): reflect.TypeOf(&metav1.ExportOptions{}).Elem(),
+				otherIdentityGV.WithKind("GetOptions"):    reflect.TypeOf(&metav1.GetOptions{}).Elem(),
+				otherIdentityGV.WithKind("ListOptions"):   reflect.TypeOf(&metav1.ListOptions{}).Elem(),
+				otherIdentityGV.WithKind("PatchOptions"):  reflect.TypeOf(&metav1.PatchOptions{}).Elem(),
+				otherIdentityGV.WithKind("UpdateOptions"): reflect.TypeOf(&metav1.UpdateOptions{}).Elem(),
+				otherIdentityGV.WithKind("WatchEvent"):    reflect.TypeOf(&metav1.WatchEvent{}).Elem(),
+
+				otherIdentityGVInternal.WithKind("WatchEvent"): reflect.TypeOf(&metav1.InternalEvent{}).Elem(),
+
 				// the types below this line do not really matter to us because they are in the core group
 
 				internalGV.WithKind("WatchEvent"): reflect.TypeOf(&metav1.InternalEvent{}).Elem(),
@@ -220,8 +281,10 @@ func Test_getAggregatedAPIServerScheme(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			loginConciergeAPIGroup, ok := groupsuffix.Replace("login.concierge.pinniped.dev", tt.apiGroupSuffix)
 			require.True(t, ok)
+			identityConciergeAPIGroup, ok := groupsuffix.Replace("identity.concierge.pinniped.dev", tt.apiGroupSuffix)
+			require.True(t, ok)
 
-			scheme := getAggregatedAPIServerScheme(loginConciergeAPIGroup, tt.apiGroupSuffix)
+			scheme := getAggregatedAPIServerScheme(loginConciergeAPIGroup, identityConciergeAPIGroup, tt.apiGroupSuffix)
 			require.Equal(t, tt.want, scheme.AllKnownTypes())
 
 			// make a credential request like a client would send
@@ -8,10 +8,14 @@ package concierge
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
+	"reflect"
 	"strings"
 
-	"sigs.k8s.io/yaml"
+	"k8s.io/apimachinery/pkg/labels"
 
+	"go.pinniped.dev/internal/config/schema"
 	"go.pinniped.dev/internal/constable"
 	"go.pinniped.dev/internal/groupsuffix"
 	"go.pinniped.dev/internal/plog"
@@ -20,6 +24,12 @@ import (
 const (
 	aboutAYear   = 60 * 60 * 24 * 365
 	about9Months = 60 * 60 * 24 * 30 * 9
+	aboutADay    = 60 * 60 * 24
+	about30Days  = 60 * 60 * 24 * 30
+
+	defaultImpersonationProxyPort = 8444
+
+	defaultInformerResyncPeriodSeconds = 3 * 60
 )
 
 // FromPath loads an Config from a provided local file path, inserts any
@@ -30,19 +40,40 @@ const (
 // This function will decode that base64-encoded data to PEM bytes to be stored
 // in the Config.
 func FromPath(path string) (*Config, error) {
+	config, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := plog.ValidateAndSetLogLevelGlobally(config.LogLevel); err != nil {
+		return nil, fmt.Errorf("validate log level: %w", err)
+	}
+
+	if err := plog.ValidateAndSetLogFormatGlobally(config.LogFormat, "concierge"); err != nil {
+		return nil, fmt.Errorf("validate log format: %w", err)
+	}
+
+	return config, nil
+}
+
+// Load is like FromPath, except that it does not change the global log level. It is used to validate a changed
+// config file before any of its settings are applied.
+func Load(path string) (*Config, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
 
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := schema.Decode(data, schema.TypeMeta{APIVersion: APIVersion, Kind: Kind}, &config); err != nil {
 		return nil, fmt.Errorf("decode yaml: %w", err)
 	}
 
 	maybeSetAPIDefaults(&config.APIConfig)
 	maybeSetAPIGroupSuffixDefault(&config.APIGroupSuffix)
 	maybeSetKubeCertAgentDefaults(&config.KubeCertAgentConfig)
+	maybeSetImpersonationProxyDefaults(&config.ImpersonationProxy)
+	maybeSetInformersDefaults(&config.Informers)
 
 	if err := validateAPI(&config.APIConfig); err != nil {
 		return nil, fmt.Errorf("validate api: %w", err)
@@ -56,10 +87,42 @@ func FromPath(path string) (*Config, error) {
 		return nil, fmt.Errorf("validate names: %w", err)
 	}
 
-	if err := plog.ValidateAndSetLogLevelGlobally(config.LogLevel); err != nil {
+	if err := plog.ValidateLogLevel(config.LogLevel); err != nil {
 		return nil, fmt.Errorf("validate log level: %w", err)
 	}
 
+	if err := plog.ValidateLogFormat(config.LogFormat); err != nil {
+		return nil, fmt.Errorf("validate log format: %w", err)
+	}
+
+	if err := validateKubeCertAgent(&config.KubeCertAgentConfig); err != nil {
+		return nil, fmt.Errorf("validate kubeCertAgent: %w", err)
+	}
+
+	if err := validateCertificateIssuance(&config.CertificateIssuance); err != nil {
+		return nil, fmt.Errorf("validate certificateIssuance: %w", err)
+	}
+
+	if err := validateTokenCredentialRequest(&config.TokenCredentialRequest); err != nil {
+		return nil, fmt.Errorf("validate tokenCredentialRequest: %w", err)
+	}
+
+	if err := validateImpersonationProxy(&config.ImpersonationProxy, &config.NamesConfig); err != nil {
+		return nil, fmt.Errorf("validate impersonationProxy: %w", err)
+	}
+
+	if err := validateInformers(&config.Informers); err != nil {
+		return nil, fmt.Errorf("validate informers: %w", err)
+	}
+
+	if err := validateControllers(&config.Controllers); err != nil {
+		return nil, fmt.Errorf("validate controllers: %w", err)
+	}
+
+	if err := validateDebug(&config.Debug); err != nil {
+		return nil, fmt.Errorf("validate debug: %w", err)
+	}
+
 	if config.Labels == nil {
 		config.Labels = make(map[string]string)
 	}
@@ -67,6 +130,44 @@ func FromPath(path string) (*Config, error) {
 	return &config, nil
 }
 
+// RestartRequiredChanges returns the names of the top-level settings which differ between the two configs and which
+// only take effect when the Concierge is restarted. Only the log level and format, the certificate issuance settings,
+// and the restrictions of the TokenCredentialRequest API can be changed without a restart.
+func RestartRequiredChanges(oldConfig, newConfig *Config) []string {
+	var changes []string
+	if !reflect.DeepEqual(oldConfig.DiscoveryInfo, newConfig.DiscoveryInfo) {
+		changes = append(changes, "discovery")
+	}
+	if !reflect.DeepEqual(oldConfig.APIConfig, newConfig.APIConfig) {
+		changes = append(changes, "api")
+	}
+	if !reflect.DeepEqual(oldConfig.APIGroupSuffix, newConfig.APIGroupSuffix) {
+		changes = append(changes, "apiGroupSuffix")
+	}
+	if !reflect.DeepEqual(oldConfig.NamesConfig, newConfig.NamesConfig) {
+		changes = append(changes, "names")
+	}
+	if !reflect.DeepEqual(oldConfig.KubeCertAgentConfig, newConfig.KubeCertAgentConfig) {
+		changes = append(changes, "kubeCertAgent")
+	}
+	if !reflect.DeepEqual(oldConfig.Labels, newConfig.Labels) {
+		changes = append(changes, "labels")
+	}
+	if !reflect.DeepEqual(oldConfig.ImpersonationProxy, newConfig.ImpersonationProxy) {
+		changes = append(changes, "impersonationProxy")
+	}
+	if !reflect.DeepEqual(oldConfig.Informers, newConfig.Informers) {
+		changes = append(changes, "informers")
+	}
+	if !reflect.DeepEqual(oldConfig.Controllers, newConfig.Controllers) {
+		changes = append(changes, "controllers")
+	}
+	if !reflect.DeepEqual(oldConfig.Debug, newConfig.Debug) {
+		changes = append(changes, "debug")
+	}
+	return changes
+}
+
 func maybeSetAPIDefaults(apiConfig *APIConfigSpec) {
 	if apiConfig.ServingCertificateConfig.DurationSeconds == nil {
 		apiConfig.ServingCertificateConfig.DurationSeconds = int64Ptr(aboutAYear)
@@ -75,6 +176,10 @@ func maybeSetAPIDefaults(apiConfig *APIConfigSpec) {
 	if apiConfig.ServingCertificateConfig.RenewBeforeSeconds == nil {
 		apiConfig.ServingCertificateConfig.RenewBeforeSeconds = int64Ptr(about9Months)
 	}
+
+	if apiConfig.ServingCertificateConfig.RotationOverlapSeconds == nil {
+		apiConfig.ServingCertificateConfig.RotationOverlapSeconds = int64Ptr(aboutADay)
+	}
 }
 
 func maybeSetAPIGroupSuffixDefault(apiGroupSuffix **string) {
@@ -84,6 +189,10 @@ func maybeSetAPIGroupSuffixDefault(apiGroupSuffix **string) {
 }
 
 func maybeSetKubeCertAgentDefaults(cfg *KubeCertAgentSpec) {
+	if cfg.Mode == "" {
+		cfg.Mode = KubeCertAgentModePod
+	}
+
 	if cfg.NamePrefix == nil {
 		cfg.NamePrefix = stringPtr("pinniped-kube-cert-agent-")
 	}
@@ -93,6 +202,34 @@ func maybeSetKubeCertAgentDefaults(cfg *KubeCertAgentSpec) {
 	}
 }
 
+func maybeSetImpersonationProxyDefaults(cfg *ImpersonationProxySpec) {
+	if cfg.Mode == "" {
+		cfg.Mode = ImpersonationProxyModeDisabled
+	}
+
+	if cfg.Port == 0 {
+		cfg.Port = defaultImpersonationProxyPort
+	}
+
+	if cfg.CertificateAuthority.DurationSeconds == nil {
+		cfg.CertificateAuthority.DurationSeconds = int64Ptr(aboutAYear)
+	}
+
+	if cfg.CertificateAuthority.ReplaceBeforeSeconds == nil {
+		cfg.CertificateAuthority.ReplaceBeforeSeconds = int64Ptr(about30Days)
+	}
+
+	if cfg.CertificateAuthority.RotationOverlapSeconds == nil {
+		cfg.CertificateAuthority.RotationOverlapSeconds = int64Ptr(2 * about30Days)
+	}
+}
+
+func maybeSetInformersDefaults(cfg *InformersSpec) {
+	if cfg.ResyncPeriodSeconds == nil {
+		cfg.ResyncPeriodSeconds = int64Ptr(defaultInformerResyncPeriodSeconds)
+	}
+}
+
 func validateNames(names *NamesConfigSpec) error {
 	missingNames := []string{}
 	if names == nil {
@@ -114,6 +251,143 @@ func validateNames(names *NamesConfigSpec) error {
 	return nil
 }
 
+func validateKubeCertAgent(kubeCertAgent *KubeCertAgentSpec) error {
+	switch kubeCertAgent.Mode {
+	case KubeCertAgentModePod, KubeCertAgentModeCSR:
+		return nil
+	default:
+		return fmt.Errorf("invalid mode %q, supported values are %q and %q", kubeCertAgent.Mode,
+			KubeCertAgentModePod, KubeCertAgentModeCSR)
+	}
+}
+
+func validateCertificateIssuance(certificateIssuance *CertificateIssuanceSpec) error {
+	if certificateIssuance.MaxCertificatesPerUserPerHour < 0 {
+		return constable.Error("maxCertificatesPerUserPerHour must not be negative")
+	}
+	if certificateIssuance.URISANTemplate != "" {
+		if err := validateURISANTemplate(certificateIssuance.URISANTemplate); err != nil {
+			return fmt.Errorf("invalid uriSANTemplate: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateTokenCredentialRequest(tokenCredentialRequest *TokenCredentialRequestSpec) error {
+	if tokenCredentialRequest.RequireAuthenticatedCallers && len(tokenCredentialRequest.AnonymousAllowedAudiences) > 0 {
+		return constable.Error("anonymousAllowedAudiences cannot be used with requireAuthenticatedCallers, which refuses all anonymous callers")
+	}
+	for _, audience := range tokenCredentialRequest.AnonymousAllowedAudiences {
+		if audience == "" {
+			return constable.Error("anonymousAllowedAudiences must not contain empty audiences")
+		}
+	}
+	for _, cidr := range tokenCredentialRequest.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("trustedProxies: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateURISANTemplate(template string) error {
+	example := strings.NewReplacer("{username}", "example-username", "{uid}", "example-uid").Replace(template)
+	if strings.ContainsAny(example, "{}") {
+		return constable.Error(`only the "{username}" and "{uid}" placeholders are supported`)
+	}
+	uri, err := url.Parse(example)
+	if err != nil {
+		return err
+	}
+	if uri.Scheme == "" || uri.Host == "" {
+		return constable.Error("must be an absolute URI with a scheme and a host, e.g. spiffe://cluster.example.com/user/{username}")
+	}
+	return nil
+}
+
+func validateImpersonationProxy(impersonationProxy *ImpersonationProxySpec, names *NamesConfigSpec) error {
+	switch impersonationProxy.Mode {
+	case ImpersonationProxyModeDisabled:
+		return nil
+	case ImpersonationProxyModeAuto, ImpersonationProxyModeEnabled:
+	default:
+		return fmt.Errorf("invalid mode %q, supported values are %q, %q, and %q", impersonationProxy.Mode,
+			ImpersonationProxyModeDisabled, ImpersonationProxyModeAuto, ImpersonationProxyModeEnabled)
+	}
+	if impersonationProxy.Port < 1 || impersonationProxy.Port > 65535 {
+		return constable.Error("port must be between 1 and 65535")
+	}
+	if names.ImpersonationProxyTLSSecret == "" {
+		return constable.Error("names.impersonationProxyTLSSecret is required unless the mode is disabled")
+	}
+	ca := impersonationProxy.CertificateAuthority
+	if *ca.ReplaceBeforeSeconds <= 0 {
+		return constable.Error("certificateAuthority.replaceBeforeSeconds must be positive")
+	}
+	if *ca.RotationOverlapSeconds < 0 {
+		return constable.Error("certificateAuthority.rotationOverlapSeconds cannot be negative")
+	}
+	if *ca.DurationSeconds <= *ca.ReplaceBeforeSeconds+*ca.RotationOverlapSeconds {
+		return constable.Error("certificateAuthority.durationSeconds must be greater than replaceBeforeSeconds plus rotationOverlapSeconds")
+	}
+	return nil
+}
+
+func validateInformers(informers *InformersSpec) error {
+	// Some controllers, like the one which renews the serving certificate, only run again on the next resync.
+	if *informers.ResyncPeriodSeconds <= 0 {
+		return constable.Error("resyncPeriodSeconds must be positive")
+	}
+	if _, err := labels.Parse(informers.SecretLabelSelector); err != nil {
+		return fmt.Errorf("invalid secretLabelSelector %q: %w", informers.SecretLabelSelector, err)
+	}
+	return nil
+}
+
+func validateControllers(controllers *ControllersSpec) error {
+	if err := validateRateLimiter(&controllers.RateLimiter); err != nil {
+		return fmt.Errorf("rateLimiter: %w", err)
+	}
+	for name, override := range controllers.RateLimiterOverrides {
+		override := override
+		if err := validateRateLimiter(&override); err != nil {
+			return fmt.Errorf("rateLimiterOverrides[%q]: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateRateLimiter(rateLimiter *RateLimiterSpec) error {
+	switch {
+	case rateLimiter.BaseDelayMilliseconds < 0:
+		return constable.Error("baseDelayMilliseconds must not be negative")
+	case rateLimiter.MaxDelaySeconds < 0:
+		return constable.Error("maxDelaySeconds must not be negative")
+	case rateLimiter.QPS < 0:
+		return constable.Error("qps must not be negative")
+	case rateLimiter.BucketSize < 0:
+		return constable.Error("bucketSize must not be negative")
+	case rateLimiter.BaseDelayMilliseconds > 0 && rateLimiter.MaxDelaySeconds > 0 &&
+		rateLimiter.MaxDelaySeconds*1000 < rateLimiter.BaseDelayMilliseconds:
+		return constable.Error("maxDelaySeconds must not be shorter than baseDelayMilliseconds")
+	}
+	return nil
+}
+
+func validateDebug(debug *DebugSpec) error {
+	if debug.ListenAddress == "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(debug.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("invalid listenAddress %q: %w", debug.ListenAddress, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("listenAddress %q must be a loopback address", debug.ListenAddress)
+	}
+	return nil
+}
+
 func validateAPI(apiConfig *APIConfigSpec) error {
 	if *apiConfig.ServingCertificateConfig.DurationSeconds < *apiConfig.ServingCertificateConfig.RenewBeforeSeconds {
 		return constable.Error("durationSeconds cannot be smaller than renewBeforeSeconds")
@@ -123,6 +397,10 @@ func validateAPI(apiConfig *APIConfigSpec) error {
 		return constable.Error("renewBefore must be positive")
 	}
 
+	if *apiConfig.ServingCertificateConfig.RotationOverlapSeconds < 0 {
+		return constable.Error("rotationOverlapSeconds cannot be negative")
+	}
+
 	return nil
 }
 
@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/plog"
 )
 
 func TestFromPath(t *testing.T) {
@@ -24,25 +25,60 @@ func TestFromPath(t *testing.T) {
 			name: "Happy",
 			yaml: here.Doc(`
 				---
+				apiVersion: concierge.config.pinniped.dev/v1alpha1
+				kind: ConciergeConfig
 				discovery:
 				  url: https://some.discovery/url
 				api:
 				  servingCertificate:
 					durationSeconds: 3600
 					renewBeforeSeconds: 2400
+					rotationOverlapSeconds: 600
 				apiGroupSuffix: some.suffix.com
 				names:
 				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
 				  credentialIssuer: pinniped-config
 				  apiService: pinniped-api
-				  kubeCertAgentPrefix: kube-cert-agent-prefix
+				  impersonationProxyTLSSecret: pinniped-concierge-impersonation-proxy-tls
 				labels:
 				  myLabelKey1: myLabelValue1
 				  myLabelKey2: myLabelValue2
-				KubeCertAgent:
+				logFormat: text
+				kubeCertAgent:
+				  mode: csr
 				  namePrefix: kube-cert-agent-name-prefix-
 				  image: kube-cert-agent-image
 				  imagePullSecrets: [kube-cert-agent-image-pull-secret]
+				certificateIssuance:
+				  maxCertificatesPerUserPerHour: 60
+				  uriSANTemplate: spiffe://cluster.example.com/user/{username}
+				tokenCredentialRequest:
+				  anonymousAllowedAudiences: [some-audience]
+				  trustedProxies: [10.96.0.0/12]
+				impersonationProxy:
+				  mode: auto
+				  port: 9443
+				  externalEndpoint: proxy.example.com
+				  certificateAuthority:
+				    durationSeconds: 864000
+				    replaceBeforeSeconds: 86400
+				    rotationOverlapSeconds: 172800
+				informers:
+				  resyncPeriodSeconds: 600
+				  secretLabelSelector: app.kubernetes.io/part-of!=other
+				controllers:
+				  rateLimiter:
+					baseDelayMilliseconds: 100
+					maxDelaySeconds: 300
+					qps: 2.5
+					bucketSize: 20
+				  rateLimiterOverrides:
+					kube-cert-agent-creater-controller:
+					  maxDelaySeconds: 30
+				supervisorConnection:
+				  enabled: true
+				debug:
+				  listenAddress: 127.0.0.1:8085
 			`),
 			wantConfig: &Config{
 				DiscoveryInfo: DiscoveryInfoSpec{
@@ -50,25 +86,68 @@ func TestFromPath(t *testing.T) {
 				},
 				APIConfig: APIConfigSpec{
 					ServingCertificateConfig: ServingCertificateConfigSpec{
-						DurationSeconds:    int64Ptr(3600),
-						RenewBeforeSeconds: int64Ptr(2400),
+						DurationSeconds:        int64Ptr(3600),
+						RenewBeforeSeconds:     int64Ptr(2400),
+						RotationOverlapSeconds: int64Ptr(600),
 					},
 				},
 				APIGroupSuffix: stringPtr("some.suffix.com"),
 				NamesConfig: NamesConfigSpec{
-					ServingCertificateSecret: "pinniped-concierge-api-tls-serving-certificate",
-					CredentialIssuer:         "pinniped-config",
-					APIService:               "pinniped-api",
+					ServingCertificateSecret:    "pinniped-concierge-api-tls-serving-certificate",
+					CredentialIssuer:            "pinniped-config",
+					APIService:                  "pinniped-api",
+					ImpersonationProxyTLSSecret: "pinniped-concierge-impersonation-proxy-tls",
 				},
 				Labels: map[string]string{
 					"myLabelKey1": "myLabelValue1",
 					"myLabelKey2": "myLabelValue2",
 				},
+				LogFormat: plog.FormatText,
 				KubeCertAgentConfig: KubeCertAgentSpec{
+					Mode:             KubeCertAgentModeCSR,
 					NamePrefix:       stringPtr("kube-cert-agent-name-prefix-"),
 					Image:            stringPtr("kube-cert-agent-image"),
 					ImagePullSecrets: []string{"kube-cert-agent-image-pull-secret"},
 				},
+				CertificateIssuance: CertificateIssuanceSpec{
+					MaxCertificatesPerUserPerHour: 60,
+					URISANTemplate:                "spiffe://cluster.example.com/user/{username}",
+				},
+				TokenCredentialRequest: TokenCredentialRequestSpec{
+					AnonymousAllowedAudiences: []string{"some-audience"},
+					TrustedProxies:            []string{"10.96.0.0/12"},
+				},
+				ImpersonationProxy: ImpersonationProxySpec{
+					Mode:             ImpersonationProxyModeAuto,
+					Port:             9443,
+					ExternalEndpoint: "proxy.example.com",
+					CertificateAuthority: ImpersonationProxyCASpec{
+						DurationSeconds:        int64Ptr(864000),
+						ReplaceBeforeSeconds:   int64Ptr(86400),
+						RotationOverlapSeconds: int64Ptr(172800),
+					},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(600),
+					SecretLabelSelector: "app.kubernetes.io/part-of!=other",
+				},
+				Controllers: ControllersSpec{
+					RateLimiter: RateLimiterSpec{
+						BaseDelayMilliseconds: 100,
+						MaxDelaySeconds:       300,
+						QPS:                   2.5,
+						BucketSize:            20,
+					},
+					RateLimiterOverrides: map[string]RateLimiterSpec{
+						"kube-cert-agent-creater-controller": {MaxDelaySeconds: 30},
+					},
+				},
+				SupervisorConnection: SupervisorConnectionSpec{
+					Enabled: true,
+				},
+				Debug: DebugSpec{
+					ListenAddress: "127.0.0.1:8085",
+				},
 			},
 		},
 		{
@@ -87,8 +166,9 @@ func TestFromPath(t *testing.T) {
 				APIGroupSuffix: stringPtr("pinniped.dev"),
 				APIConfig: APIConfigSpec{
 					ServingCertificateConfig: ServingCertificateConfigSpec{
-						DurationSeconds:    int64Ptr(60 * 60 * 24 * 365),    // about a year
-						RenewBeforeSeconds: int64Ptr(60 * 60 * 24 * 30 * 9), // about 9 months
+						DurationSeconds:        int64Ptr(60 * 60 * 24 * 365),    // about a year
+						RenewBeforeSeconds:     int64Ptr(60 * 60 * 24 * 30 * 9), // about 9 months
+						RotationOverlapSeconds: int64Ptr(60 * 60 * 24),          // about a day
 					},
 				},
 				NamesConfig: NamesConfigSpec{
@@ -98,16 +178,314 @@ func TestFromPath(t *testing.T) {
 				},
 				Labels: map[string]string{},
 				KubeCertAgentConfig: KubeCertAgentSpec{
+					Mode:       KubeCertAgentModePod,
 					NamePrefix: stringPtr("pinniped-kube-cert-agent-"),
 					Image:      stringPtr("debian:latest"),
 				},
+				ImpersonationProxy: ImpersonationProxySpec{
+					Mode: ImpersonationProxyModeDisabled,
+					Port: 8444,
+					CertificateAuthority: ImpersonationProxyCASpec{
+						DurationSeconds:        int64Ptr(60 * 60 * 24 * 365),
+						ReplaceBeforeSeconds:   int64Ptr(60 * 60 * 24 * 30),
+						RotationOverlapSeconds: int64Ptr(60 * 60 * 24 * 60),
+					},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(180),
+				},
 			},
 		},
+		{
+			name: "Negative maxCertificatesPerUserPerHour",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				certificateIssuance:
+				  maxCertificatesPerUserPerHour: -1
+			`),
+			wantError: "validate certificateIssuance: maxCertificatesPerUserPerHour must not be negative",
+		},
+		{
+			name: "uriSANTemplate with unsupported placeholder",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				certificateIssuance:
+				  uriSANTemplate: spiffe://cluster.example.com/group/{group}
+			`),
+			wantError: `validate certificateIssuance: invalid uriSANTemplate: only the "{username}" and "{uid}" placeholders are supported`,
+		},
+		{
+			name: "uriSANTemplate which is not an absolute URI",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				certificateIssuance:
+				  uriSANTemplate: /user/{username}
+			`),
+			wantError: "validate certificateIssuance: invalid uriSANTemplate: must be an absolute URI with a scheme and a host, e.g. spiffe://cluster.example.com/user/{username}",
+		},
+		{
+			name: "anonymousAllowedAudiences with requireAuthenticatedCallers",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				tokenCredentialRequest:
+				  requireAuthenticatedCallers: true
+				  anonymousAllowedAudiences: [some-audience]
+			`),
+			wantError: "validate tokenCredentialRequest: anonymousAllowedAudiences cannot be used with requireAuthenticatedCallers, which refuses all anonymous callers",
+		},
+		{
+			name: "empty anonymousAllowedAudience",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				tokenCredentialRequest:
+				  anonymousAllowedAudiences: [""]
+			`),
+			wantError: "validate tokenCredentialRequest: anonymousAllowedAudiences must not contain empty audiences",
+		},
+		{
+			name: "invalid trustedProxies",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				tokenCredentialRequest:
+				  trustedProxies: [10.96.0.1]
+			`),
+			wantError: "validate tokenCredentialRequest: trustedProxies: invalid CIDR address: 10.96.0.1",
+		},
+		{
+			name: "Invalid kubeCertAgent mode",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				kubeCertAgent:
+				  mode: daemonset
+			`),
+			wantError: `validate kubeCertAgent: invalid mode "daemonset", supported values are "pod" and "csr"`,
+		},
+		{
+			name: "Invalid logFormat",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				logFormat: logfmt
+			`),
+			wantError: "validate log format: invalid log format, valid choices are the empty string, text and json",
+		},
+		{
+			name: "Invalid impersonationProxy mode",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				  impersonationProxyTLSSecret: pinniped-concierge-impersonation-proxy-tls
+				impersonationProxy:
+				  mode: sometimes
+			`),
+			wantError: `validate impersonationProxy: invalid mode "sometimes", supported values are "disabled", "auto", and "enabled"`,
+		},
+		{
+			name: "Invalid impersonationProxy port",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				  impersonationProxyTLSSecret: pinniped-concierge-impersonation-proxy-tls
+				impersonationProxy:
+				  mode: enabled
+				  port: 70000
+			`),
+			wantError: "validate impersonationProxy: port must be between 1 and 65535",
+		},
+		{
+			name: "Missing impersonationProxyTLSSecret name when the impersonation proxy is enabled",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				impersonationProxy:
+				  mode: auto
+			`),
+			wantError: "validate impersonationProxy: names.impersonationProxyTLSSecret is required unless the mode is disabled",
+		},
+		{
+			name: "impersonationProxy CA rotation which does not fit into the CA duration",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				  impersonationProxyTLSSecret: pinniped-concierge-impersonation-proxy-tls-serving-certificate
+				impersonationProxy:
+				  mode: auto
+				  certificateAuthority:
+				    durationSeconds: 864000
+				    replaceBeforeSeconds: 432000
+				    rotationOverlapSeconds: 432000
+			`),
+			wantError: "validate impersonationProxy: certificateAuthority.durationSeconds must be greater than replaceBeforeSeconds plus rotationOverlapSeconds",
+		},
+		{
+			name: "Zero impersonationProxy CA replaceBeforeSeconds",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				  impersonationProxyTLSSecret: pinniped-concierge-impersonation-proxy-tls-serving-certificate
+				impersonationProxy:
+				  mode: auto
+				  certificateAuthority:
+				    replaceBeforeSeconds: 0
+			`),
+			wantError: "validate impersonationProxy: certificateAuthority.replaceBeforeSeconds must be positive",
+		},
+		{
+			name: "Negative informer resyncPeriodSeconds",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				informers:
+				  resyncPeriodSeconds: -1
+			`),
+			wantError: "validate informers: resyncPeriodSeconds must be positive",
+		},
+		{
+			name: "Zero informer resyncPeriodSeconds",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				informers:
+				  resyncPeriodSeconds: 0
+			`),
+			wantError: "validate informers: resyncPeriodSeconds must be positive",
+		},
+		{
+			name: "Negative rate limiter qps",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				controllers:
+				  rateLimiter:
+					qps: -1
+			`),
+			wantError: "validate controllers: rateLimiter: qps must not be negative",
+		},
+		{
+			name: "Rate limiter override with a max delay shorter than the base delay",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				controllers:
+				  rateLimiterOverrides:
+					some-controller:
+					  baseDelayMilliseconds: 2000
+					  maxDelaySeconds: 1
+			`),
+			wantError: `validate controllers: rateLimiterOverrides["some-controller"]: maxDelaySeconds must not be shorter than baseDelayMilliseconds`,
+		},
+		{
+			name: "Debug listener on all interfaces",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				debug:
+				  listenAddress: :8085
+			`),
+			wantError: `validate debug: listenAddress ":8085" must be a loopback address`,
+		},
+		{
+			name: "Debug listener without a port",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				debug:
+				  listenAddress: localhost
+			`),
+			wantError: `validate debug: invalid listenAddress "localhost": address localhost: missing port in address`,
+		},
 		{
 			name:      "Empty",
 			yaml:      here.Doc(``),
 			wantError: "validate names: missing required names: servingCertificateSecret, credentialIssuer, apiService",
 		},
+		{
+			name: "Unsupported kind",
+			yaml: here.Doc(`
+				---
+				apiVersion: concierge.config.pinniped.dev/v1alpha1
+				kind: SupervisorConfig
+			`),
+			wantError: `decode yaml: line 3: unsupported kind "SupervisorConfig" (expected "ConciergeConfig")`,
+		},
+		{
+			name: "Misspelled field",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				impersonationProxy:
+				  mode: auto
+				  externalEndPoint: proxy.example.com
+			`),
+			wantError: `decode yaml: line 8: unknown field "externalEndPoint" in impersonationProxy`,
+		},
 		{
 			name: "Missing apiService name",
 			yaml: here.Doc(`
@@ -183,6 +561,22 @@ func TestFromPath(t *testing.T) {
 			`),
 			wantError: "validate api: renewBefore must be positive",
 		},
+		{
+			name: "NegativeRotationOverlap",
+			yaml: here.Doc(`
+				---
+				api:
+				  servingCertificate:
+					durationSeconds: 3600
+					renewBeforeSeconds: 2400
+					rotationOverlapSeconds: -10
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+			`),
+			wantError: "validate api: rotationOverlapSeconds cannot be negative",
+		},
 		{
 			name: "InvalidAPIGroupSuffix",
 			yaml: here.Doc(`
@@ -227,3 +621,68 @@ func TestFromPath(t *testing.T) {
 		})
 	}
 }
+
+func TestRestartRequiredChanges(t *testing.T) {
+	load := func(yaml string) *Config {
+		t.Helper()
+		f, err := ioutil.TempFile("", "pinniped-test-config-yaml-*")
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, os.Remove(f.Name()))
+		}()
+		_, err = f.WriteString(yaml)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		config, err := Load(f.Name())
+		require.NoError(t, err)
+		return config
+	}
+
+	oldConfig := load(here.Doc(`
+		---
+		names:
+		  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+		  credentialIssuer: pinniped-config
+		  apiService: pinniped-api
+		labels:
+		  myLabelKey: myLabelValue
+	`))
+
+	// Changes to the log level and to the restrictions of the TokenCredentialRequest API can be applied without a restart.
+	require.Empty(t, RestartRequiredChanges(oldConfig, load(here.Doc(`
+		---
+		names:
+		  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+		  credentialIssuer: pinniped-config
+		  apiService: pinniped-api
+		labels:
+		  myLabelKey: myLabelValue
+		logLevel: debug
+		logFormat: json
+		tokenCredentialRequest:
+		  requireAuthenticatedCallers: true
+	`))))
+
+	require.Equal(t, []string{"apiGroupSuffix", "names", "kubeCertAgent", "labels", "impersonationProxy", "controllers", "debug"}, RestartRequiredChanges(oldConfig, load(here.Doc(`
+		---
+		apiGroupSuffix: some.suffix.com
+		names:
+		  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+		  credentialIssuer: pinniped-other-config
+		  apiService: pinniped-api
+		  impersonationProxyTLSSecret: pinniped-concierge-impersonation-proxy-tls
+		kubeCertAgent:
+		  namePrefix: some-other-prefix-
+		labels:
+		  myLabelKey: myOtherLabelValue
+		logLevel: debug
+		impersonationProxy:
+		  mode: enabled
+		controllers:
+		  rateLimiter:
+		    qps: 20
+		debug:
+		  listenAddress: localhost:8085
+	`))))
+}
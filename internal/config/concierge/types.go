@@ -5,6 +5,12 @@ package concierge
 
 import "go.pinniped.dev/internal/plog"
 
+// APIVersion and Kind identify the current version of the Concierge config file. They may be left out of the file.
+const (
+	APIVersion = "concierge.config.pinniped.dev/v1alpha1"
+	Kind       = "ConciergeConfig"
+)
+
 // Config contains knobs to setup an instance of the Pinniped Concierge.
 type Config struct {
 	DiscoveryInfo       DiscoveryInfoSpec `json:"discovery"`
@@ -14,6 +20,166 @@ type Config struct {
 	KubeCertAgentConfig KubeCertAgentSpec `json:"kubeCertAgent"`
 	Labels              map[string]string `json:"labels"`
 	LogLevel            plog.LogLevel     `json:"logLevel"`
+	LogFormat           plog.LogFormat    `json:"logFormat,omitempty"`
+
+	CertificateIssuance    CertificateIssuanceSpec    `json:"certificateIssuance"`
+	TokenCredentialRequest TokenCredentialRequestSpec `json:"tokenCredentialRequest"`
+	ImpersonationProxy     ImpersonationProxySpec     `json:"impersonationProxy"`
+	Informers              InformersSpec              `json:"informers"`
+	Controllers            ControllersSpec            `json:"controllers"`
+	SupervisorConnection   SupervisorConnectionSpec   `json:"supervisorConnection"`
+	Debug                  DebugSpec                  `json:"debug"`
+}
+
+// DebugSpec contains configuration knobs for the debug listener, which serves the runtime profiles of net/http/pprof
+// at /debug/pprof/ and the variables of expvar at /debug/vars over plain HTTP.
+type DebugSpec struct {
+	// ListenAddress is the host:port on which the debug listener is started, e.g. "127.0.0.1:8085". The listener has
+	// no authentication, so the host must be "localhost" or a loopback IP, which can still be reached with
+	// "kubectl port-forward". By default, it is empty and the listener is not started.
+	ListenAddress string `json:"listenAddress,omitempty"`
+}
+
+// SupervisorConnectionSpec contains configuration knobs for the controller which joins the cluster to a central
+// Supervisor as described by SupervisorConnection resources.
+type SupervisorConnectionSpec struct {
+	// Enabled runs the controller, which creates JWTAuthenticators and ClusterRoleBindings for each
+	// SupervisorConnection. It needs permission to bind ClusterRoles, so it is disabled by default.
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// InformersSpec contains configuration knobs for the informers which watch Kubernetes resources.
+type InformersSpec struct {
+	// ResyncPeriodSeconds is how often every informer replays all cached objects to its controllers, even when
+	// nothing has changed. Controllers are triggered by watch events anyway, so on clusters with many objects a
+	// longer period avoids regular CPU spikes. It must be positive. By default, it is 180 seconds.
+	ResyncPeriodSeconds *int64 `json:"resyncPeriodSeconds,omitempty"`
+
+	// SecretLabelSelector limits the Secrets which the Concierge watches in its namespace, e.g. when it shares the
+	// namespace with other applications. The Secrets which the Concierge creates and the Secrets which are named in
+	// its config must match it, so a selector which excludes the unrelated Secrets is usually the easiest. By default,
+	// all Secrets in the namespace are watched.
+	SecretLabelSelector string `json:"secretLabelSelector,omitempty"`
+}
+
+// ControllersSpec configures the controllers of the Concierge. Each controller has a queue of keys to sync, which is rate
+// limited so that a controller which keeps failing does not overload the Kubernetes API server.
+type ControllersSpec struct {
+	// RateLimiter applies to the queues of all controllers.
+	RateLimiter RateLimiterSpec `json:"rateLimiter"`
+
+	// RateLimiterOverrides replaces the settings of RateLimiter which are set in the override for the controllers with
+	// the given names. The names are those in the "controller" field of the logs and the metrics. The server fails to
+	// start when an override names none of its controllers.
+	RateLimiterOverrides map[string]RateLimiterSpec `json:"rateLimiterOverrides,omitempty"`
+}
+
+// RateLimiterSpec configures the rate limiter of a controller queue. Unset fields keep their default values.
+type RateLimiterSpec struct {
+	// BaseDelayMilliseconds is how long a controller waits before it retries a key which failed to sync. The delay
+	// doubles with each consecutive failure. By default, it is 5 milliseconds.
+	BaseDelayMilliseconds int64 `json:"baseDelayMilliseconds,omitempty"`
+
+	// MaxDelaySeconds is the longest delay between the retries of a key. By default, it is 1000 seconds.
+	MaxDelaySeconds int64 `json:"maxDelaySeconds,omitempty"`
+
+	// QPS is how many keys per second a controller syncs at most, whether or not they failed before. Slow clusters
+	// can use a lower rate. By default, it is 10.
+	QPS float64 `json:"qps,omitempty"`
+
+	// BucketSize is how many keys a controller can sync in a burst above QPS, e.g. right after it starts. Very large
+	// clusters can use a larger bucket. By default, it is 100.
+	BucketSize int `json:"bucketSize,omitempty"`
+}
+
+// ImpersonationProxyMode selects when the impersonation proxy runs.
+type ImpersonationProxyMode string
+
+const (
+	// ImpersonationProxyModeDisabled never runs the impersonation proxy.
+	ImpersonationProxyModeDisabled = ImpersonationProxyMode("disabled")
+	// ImpersonationProxyModeAuto runs the impersonation proxy only while the CredentialIssuer reports that the
+	// KubeClusterSigningCertificate strategy is not working, e.g. on managed clusters where the kube cert agent
+	// cannot reach the cluster signing key.
+	ImpersonationProxyModeAuto = ImpersonationProxyMode("auto")
+	// ImpersonationProxyModeEnabled always runs the impersonation proxy.
+	ImpersonationProxyModeEnabled = ImpersonationProxyMode("enabled")
+)
+
+// ImpersonationProxySpec contains configuration knobs for the impersonation proxy, which authenticates requests
+// using Pinniped credentials and forwards them to the Kubernetes API server using impersonation headers.
+type ImpersonationProxySpec struct {
+	// Mode is one of "disabled", "auto", or "enabled". By default, the impersonation proxy is disabled.
+	Mode ImpersonationProxyMode `json:"mode,omitempty"`
+
+	// Port is the port on which the impersonation proxy listens. By default, it listens on port 8444.
+	Port int `json:"port,omitempty"`
+
+	// ExternalEndpoint is the host name or IP address, optionally followed by a port, at which clients can reach the
+	// impersonation proxy, e.g. the address of a LoadBalancer Service in front of it. It is used in the serving
+	// certificate of the proxy and is published in the CredentialIssuer for clients. While it is not set, the proxy
+	// cannot be started and the CredentialIssuer reports an error.
+	ExternalEndpoint string `json:"externalEndpoint,omitempty"`
+
+	// CertificateAuthority configures the rotation of the CA of the impersonation proxy.
+	CertificateAuthority ImpersonationProxyCASpec `json:"certificateAuthority"`
+}
+
+// ImpersonationProxyCASpec contains configuration knobs for the rotation of the CA of the impersonation proxy. The
+// CA bundle is published in the CredentialIssuer and written into the kubeconfigs of users, so the next CA is
+// published alongside the current one for a while before it replaces it. Kubeconfigs which were generated before
+// the next CA was published stop working when the current CA is replaced.
+type ImpersonationProxyCASpec struct {
+	// DurationSeconds is the validity period, in seconds, of each CA. By default, it is 31536000 seconds (1 year).
+	DurationSeconds *int64 `json:"durationSeconds,omitempty"`
+
+	// ReplaceBeforeSeconds is the period of time, in seconds, before the current CA expires at which the next CA
+	// replaces it. By default, it is 2592000 seconds (30 days).
+	ReplaceBeforeSeconds *int64 `json:"replaceBeforeSeconds,omitempty"`
+
+	// RotationOverlapSeconds is the period of time, in seconds, before the current CA is replaced during which the
+	// next CA is published alongside it. By default, it is 5184000 seconds (60 days).
+	RotationOverlapSeconds *int64 `json:"rotationOverlapSeconds,omitempty"`
+}
+
+// CertificateIssuanceSpec contains configuration knobs for the client certificates which are issued by the
+// TokenCredentialRequest API.
+type CertificateIssuanceSpec struct {
+	// MaxCertificatesPerUserPerHour limits how many client certificates can be issued to each username over any
+	// one hour period, to limit the damage done by automation which requests credentials in a tight loop. Requests
+	// beyond the limit fail with a 429 status and are recorded in the audit log. Only the certificates which were
+	// actually issued are counted, and each Concierge pod counts its own, so with several replicas a user may get
+	// up to the limit from each of them. By default, there is no limit.
+	MaxCertificatesPerUserPerHour int `json:"maxCertificatesPerUserPerHour,omitempty"`
+
+	// URISANTemplate is an optional URI which is added to each client certificate as a subject alternative name,
+	// for systems which identify mTLS clients by their URI SAN, e.g. "spiffe://cluster.example.com/user/{username}".
+	// The "{username}" and "{uid}" placeholders are replaced with the path-escaped username and UID of the
+	// authenticated user. When the template uses "{uid}", users without a UID cannot get a certificate.
+	URISANTemplate string `json:"uriSANTemplate,omitempty"`
+}
+
+// TokenCredentialRequestSpec contains configuration knobs which restrict who can call the TokenCredentialRequest API.
+// By default, it can be called without authenticating to the cluster, since the caller does not have a cluster
+// credential yet.
+type TokenCredentialRequestSpec struct {
+	// RequireAuthenticatedCallers refuses TokenCredentialRequests from callers which are not authenticated to the
+	// cluster, e.g. for clusters which forbid unauthenticated access to aggregated APIs. Such callers can still
+	// authenticate to the cluster with a ServiceAccount token or a client certificate of their own.
+	RequireAuthenticatedCallers bool `json:"requireAuthenticatedCallers,omitempty"`
+
+	// AnonymousAllowedAudiences, when not empty, only accepts TokenCredentialRequests from unauthenticated callers when
+	// their token is a JWT with one of these audiences, e.g. the audience of the JWTAuthenticator which trusts the
+	// Supervisor. Other tokens can still be exchanged by authenticated callers. It cannot be combined with
+	// RequireAuthenticatedCallers.
+	AnonymousAllowedAudiences []string `json:"anonymousAllowedAudiences,omitempty"`
+
+	// TrustedProxies are the CIDRs of the proxies which may report the address of their client in the X-Forwarded-For
+	// header, e.g. the Kubernetes API server for the aggregated API or a load balancer in front of the impersonation
+	// proxy. Failed authentications are throttled per client address, which is the address of the peer unless the peer
+	// is one of these proxies. By default, no proxy is trusted, so all requests for the aggregated API share the
+	// address of the Kubernetes API server.
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
 }
 
 // DiscoveryInfoSpec contains configuration knobs specific to
@@ -36,6 +202,10 @@ type NamesConfigSpec struct {
 	ServingCertificateSecret string `json:"servingCertificateSecret"`
 	CredentialIssuer         string `json:"credentialIssuer"`
 	APIService               string `json:"apiService"`
+
+	// ImpersonationProxyTLSSecret is the name of the Secret in which the CA and serving certificate of the
+	// impersonation proxy are stored. It is required unless the impersonation proxy is disabled.
+	ImpersonationProxyTLSSecret string `json:"impersonationProxyTLSSecret,omitempty"`
 }
 
 // ServingCertificateConfigSpec contains the configuration knobs for the API's
@@ -54,9 +224,34 @@ type ServingCertificateConfigSpec struct {
 	// DurationSeconds. By default, Pinniped begins rotation after 23328000
 	// seconds (about 9 months).
 	RenewBeforeSeconds *int64 `json:"renewBeforeSeconds,omitempty"`
+
+	// RotationOverlapSeconds is the period of time, in seconds, before the
+	// rotation of the serving certificate during which the CA bundle of the API
+	// contains both the current and the next CA certificate. This gives the
+	// aggregated API and other clients which cache the CA bundle time to pick
+	// up the next CA before the serving certificate is rotated. Zero disables
+	// the overlap. By default, the overlap is 86400 seconds (1 day).
+	RotationOverlapSeconds *int64 `json:"rotationOverlapSeconds,omitempty"`
 }
 
+// KubeCertAgentMode selects how the Concierge gets client certificates signed by the cluster.
+type KubeCertAgentMode string
+
+const (
+	// KubeCertAgentModePod runs kube cert agent pods next to the kube-controller-manager pods and reads the cluster
+	// signing key through them, so that the Concierge can sign client certificates itself.
+	KubeCertAgentModePod = KubeCertAgentMode("pod")
+	// KubeCertAgentModeCSR has the cluster sign client certificates through the CertificateSigningRequest API with
+	// the kubernetes.io/kube-apiserver-client signer. It needs no agent pods and no access to the signing key, but
+	// the lifetime of the certificates is decided by the cluster signer instead of by the Concierge, so certificates
+	// which outlive the TTL that the Concierge would have chosen are refused.
+	KubeCertAgentModeCSR = KubeCertAgentMode("csr")
+)
+
 type KubeCertAgentSpec struct {
+	// Mode is either "pod" or "csr". By default, it is "pod".
+	Mode KubeCertAgentMode `json:"mode,omitempty"`
+
 	// NamePrefix is the prefix of the name of the kube-cert-agent pods. For example, if this field is
 	// set to "some-prefix-", then the name of the pods will look like "some-prefix-blah". The default
 	// for this value is "pinniped-kube-cert-agent-".
@@ -69,5 +264,5 @@ type KubeCertAgentSpec struct {
 
 	// ImagePullSecrets is a list of names of Kubernetes Secret objects that will be used as
 	// ImagePullSecrets on the kube-cert-agent pods.
-	ImagePullSecrets []string
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"`
 }
@@ -0,0 +1,119 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package reload watches the static config file of a server and applies changes to it while the server is running.
+package reload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io/ioutil"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"go.pinniped.dev/internal/plog"
+)
+
+// DefaultInterval is how often the config file is checked for changes. Kubelet only updates mounted ConfigMaps
+// about once per minute anyway, so there is no point in checking much more often.
+const DefaultInterval = 10 * time.Second
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+//nolint: gochecknoglobals
+var reloadsTotal = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Name:           "pinniped_config_reloads_total",
+		Help:           "Number of times that a changed config file was reloaded, partitioned by component and result.",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"component", "result"},
+)
+
+//nolint: gochecknoinits
+func init() {
+	legacyregistry.MustRegister(reloadsTotal)
+}
+
+// Watcher polls a config file and calls its apply function each time that the contents of the file change.
+type Watcher struct {
+	component string
+	path      string
+	apply     func() error
+
+	lastHash []byte
+}
+
+// New returns a Watcher for the config file at path. The apply function should load and validate the whole file
+// before applying any of it. When it returns an error, the server keeps running with its previous settings, the
+// error is logged, and the failure is counted in the pinniped_config_reloads_total metric.
+func New(component, path string, apply func() error) *Watcher {
+	w := &Watcher{component: component, path: path, apply: apply}
+	// The server has just loaded the file, so only later changes need to be applied.
+	w.lastHash, _ = w.hash()
+	return w
+}
+
+// Start checks the file for changes at the given interval until the context is cancelled. It does not block.
+func (w *Watcher) Start(ctx context.Context, interval time.Duration) {
+	go wait.UntilWithContext(ctx, func(_ context.Context) { w.check() }, interval)
+}
+
+func (w *Watcher) check() {
+	hash, err := w.hash()
+	if err != nil {
+		// The file may be briefly missing while kubelet updates the mounted ConfigMap, so try again next time.
+		plog.Debug("could not read config file to check for changes", "component", w.component, "path", w.path, "error", err.Error())
+		return
+	}
+	if bytes.Equal(hash, w.lastHash) {
+		return
+	}
+	// Remember the new contents even when they are invalid, so that each change is only reported once.
+	w.lastHash = hash
+
+	if err := w.apply(); err != nil {
+		reloadsTotal.WithLabelValues(w.component, resultFailure).Inc()
+		plog.Error("ignoring invalid change to config file, the previous config is still in effect", err,
+			"component", w.component,
+			"path", w.path,
+		)
+		return
+	}
+	reloadsTotal.WithLabelValues(w.component, resultSuccess).Inc()
+	plog.Info("reloaded changed config file", "component", w.component, "path", w.path)
+}
+
+// PendingRestart returns the settings from sinceStartup which are also in sinceLastApplied, i.e. the settings which
+// differ from the ones that the server was started with and which were changed since the config was last applied.
+// The server warns about these, so that a setting which needs a restart is only reported once, rather than each
+// time that any other setting is changed.
+func PendingRestart(sinceStartup, sinceLastApplied []string) []string {
+	changed := make(map[string]bool, len(sinceLastApplied))
+	for _, setting := range sinceLastApplied {
+		changed[setting] = true
+	}
+	var pending []string
+	for _, setting := range sinceStartup {
+		if changed[setting] {
+			pending = append(pending, setting)
+		}
+	}
+	return pending
+}
+
+func (w *Watcher) hash() ([]byte, error) {
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package reload
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-reload-test-*")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "pinniped.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("logLevel: info"), 0600))
+
+	var applyErr error
+	applyCalls := 0
+	w := New("some-component", path, func() error {
+		applyCalls++
+		return applyErr
+	})
+	requireReloads := func(wantSuccesses, wantFailures float64) {
+		t.Helper()
+		successes, err := testutil.GetCounterMetricValue(reloadsTotal.WithLabelValues("some-component", resultSuccess))
+		require.NoError(t, err)
+		require.Equal(t, wantSuccesses, successes)
+		failures, err := testutil.GetCounterMetricValue(reloadsTotal.WithLabelValues("some-component", resultFailure))
+		require.NoError(t, err)
+		require.Equal(t, wantFailures, failures)
+	}
+
+	// The contents which were present when the watcher was created are not applied again.
+	w.check()
+	require.Equal(t, 0, applyCalls)
+	requireReloads(0, 0)
+
+	// A change is applied once.
+	require.NoError(t, ioutil.WriteFile(path, []byte("logLevel: debug"), 0600))
+	w.check()
+	w.check()
+	require.Equal(t, 1, applyCalls)
+	requireReloads(1, 0)
+
+	// An invalid change is reported once.
+	applyErr = errors.New("some invalid config")
+	require.NoError(t, ioutil.WriteFile(path, []byte("logLevel: panda"), 0600))
+	w.check()
+	w.check()
+	require.Equal(t, 2, applyCalls)
+	requireReloads(1, 1)
+
+	// A missing file is ignored until it comes back.
+	require.NoError(t, os.Remove(path))
+	w.check()
+	require.Equal(t, 2, applyCalls)
+
+	// Fixing the file applies it again.
+	applyErr = nil
+	require.NoError(t, ioutil.WriteFile(path, []byte("logLevel: trace"), 0600))
+	w.check()
+	require.Equal(t, 3, applyCalls)
+	requireReloads(2, 1)
+}
+
+func TestPendingRestart(t *testing.T) {
+	require.Empty(t, PendingRestart(nil, []string{"labels"}))
+	require.Empty(t, PendingRestart([]string{"labels"}, nil))
+	require.Equal(t, []string{"labels", "names"}, PendingRestart([]string{"labels", "names", "informers"}, []string{"names", "labels"}))
+}
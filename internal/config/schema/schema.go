@@ -0,0 +1,240 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package schema decodes the versioned config files of the Pinniped components. Unlike a plain YAML decoder, it
+// rejects unknown and duplicate fields and values of the wrong type, and reports the line of the mistake, so that a
+// typo in a config file fails fast instead of silently leaving a setting at its default.
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// TypeMeta is the header of a config file. Files without a header are decoded as the current version, so that
+// config files which were written before the header existed keep working.
+type TypeMeta struct {
+	APIVersion string
+	Kind       string
+}
+
+// Decode strictly validates the config file in data against the type of into, and then decodes it into into, which
+// must be a pointer to a struct with json field tags. The apiVersion and kind of the file, when present, must
+// match want.
+func Decode(data []byte, want TypeMeta, into interface{}) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	if len(doc.Content) > 0 {
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode && !isNull(root) {
+			return fmt.Errorf("line %d: the config must be a mapping", root.Line)
+		}
+		if err := checkTypeMeta(root, want); err != nil {
+			return err
+		}
+		if err := check(root, reflect.TypeOf(into).Elem(), "", true); err != nil {
+			return err
+		}
+	}
+
+	// The document is known to be valid now, so decode it the same way as the Kubernetes API machinery does.
+	return sigsyaml.Unmarshal(data, into)
+}
+
+func checkTypeMeta(root *yaml.Node, want TypeMeta) error {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "apiVersion":
+			if value.Value != want.APIVersion {
+				return fmt.Errorf("line %d: unsupported apiVersion %q (expected %q)", value.Line, value.Value, want.APIVersion)
+			}
+		case "kind":
+			if value.Value != want.Kind {
+				return fmt.Errorf("line %d: unsupported kind %q (expected %q)", value.Line, value.Value, want.Kind)
+			}
+		}
+	}
+	return nil
+}
+
+//nolint: gochecknoglobals
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// check returns an error for the first field of node which does not fit into a value of type t. The path is the
+// dotted path of node in the document, which is used in the error messages.
+func check(node *yaml.Node, t reflect.Type, path string, isRoot bool) error {
+	if node.Kind == yaml.AliasNode {
+		return check(node.Alias, t, path, isRoot)
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if isNull(node) {
+		return nil
+	}
+	// Types which decode themselves can not be checked here.
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return typeError(node, path, "a mapping")
+		}
+		fields := jsonFields(t)
+		seen := map[string]bool{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if seen[key.Value] {
+				return fmt.Errorf("line %d: duplicate field %q%s", key.Line, key.Value, inPath(path))
+			}
+			seen[key.Value] = true
+
+			if isRoot && (key.Value == "apiVersion" || key.Value == "kind") {
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				return fmt.Errorf("line %d: unknown field %q%s", key.Line, key.Value, inPath(path))
+			}
+			if err := check(value, field, join(path, key.Value), false); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return typeError(node, path, "a mapping")
+		}
+		seen := map[string]bool{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if seen[key.Value] {
+				return fmt.Errorf("line %d: duplicate key %q%s", key.Line, key.Value, inPath(path))
+			}
+			seen[key.Value] = true
+			if err := check(value, t.Elem(), join(path, key.Value), false); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return typeError(node, path, "a list")
+		}
+		for i, item := range node.Content {
+			if err := check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), false); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.String:
+		// Like the Kubernetes API machinery, accept any scalar for a string, e.g. a port number.
+		if node.Kind != yaml.ScalarNode {
+			return typeError(node, path, "a string")
+		}
+		return nil
+
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			return typeError(node, path, "true or false")
+		}
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			return typeError(node, path, "an integer")
+		}
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		if node.Kind != yaml.ScalarNode || (node.Tag != "!!int" && node.Tag != "!!float") {
+			return typeError(node, path, "a number")
+		}
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// jsonFields returns the types of the fields of the struct type t by their JSON names, including the fields of
+// embedded structs, in the same way as encoding/json does. Unlike encoding/json, names are case-sensitive.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for embeddedName, embeddedType := range jsonFields(embedded) {
+					fields[embeddedName] = embeddedType
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
+
+func typeError(node *yaml.Node, path string, want string) error {
+	got := "a mapping"
+	switch node.Kind {
+	case yaml.SequenceNode:
+		got = "a list"
+	case yaml.ScalarNode:
+		got = fmt.Sprintf("%q", node.Value)
+	}
+	return fmt.Errorf("line %d: %s must be %s, not %s", node.Line, path, want, got)
+}
+
+func inPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return " in " + path
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
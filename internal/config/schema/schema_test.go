@@ -0,0 +1,198 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/here"
+)
+
+type testConfig struct {
+	Name     string            `json:"name"`
+	Enabled  bool              `json:"enabled,omitempty"`
+	Port     *int64            `json:"port,omitempty"`
+	Labels   map[string]string `json:"labels"`
+	Items    []testItem        `json:"items,omitempty"`
+	Ignored  string            `json:"-"`
+	Untagged []string
+	testEmbedded
+}
+
+type testEmbedded struct {
+	Extra string `json:"extra,omitempty"`
+}
+
+type testItem struct {
+	Address string `json:"address"`
+}
+
+func TestDecode(t *testing.T) {
+	want := TypeMeta{APIVersion: "test.config.pinniped.dev/v1alpha1", Kind: "TestConfig"}
+	port := int64(8443)
+
+	tests := []struct {
+		name       string
+		yaml       string
+		wantConfig *testConfig
+		wantError  string
+	}{
+		{
+			name: "all fields with a header",
+			yaml: here.Doc(`
+				apiVersion: test.config.pinniped.dev/v1alpha1
+				kind: TestConfig
+				name: some-name
+				enabled: true
+				port: 8443
+				labels:
+				  some-key: 1234
+				items:
+				  - address: 127.0.0.1
+				Untagged: [a]
+				extra: some-extra
+			`),
+			wantConfig: &testConfig{
+				Name:         "some-name",
+				Enabled:      true,
+				Port:         &port,
+				Labels:       map[string]string{"some-key": "1234"},
+				Items:        []testItem{{Address: "127.0.0.1"}},
+				Untagged:     []string{"a"},
+				testEmbedded: testEmbedded{Extra: "some-extra"},
+			},
+		},
+		{
+			name: "without a header",
+			yaml: here.Doc(`
+				name: some-name
+				port: null
+			`),
+			wantConfig: &testConfig{Name: "some-name"},
+		},
+		{
+			name:       "empty",
+			yaml:       "",
+			wantConfig: &testConfig{},
+		},
+		{
+			name: "wrong apiVersion",
+			yaml: here.Doc(`
+				apiVersion: test.config.pinniped.dev/v2
+				kind: TestConfig
+			`),
+			wantError: `line 1: unsupported apiVersion "test.config.pinniped.dev/v2" (expected "test.config.pinniped.dev/v1alpha1")`,
+		},
+		{
+			name: "wrong kind",
+			yaml: here.Doc(`
+				apiVersion: test.config.pinniped.dev/v1alpha1
+				kind: OtherConfig
+			`),
+			wantError: `line 2: unsupported kind "OtherConfig" (expected "TestConfig")`,
+		},
+		{
+			name: "unknown top level field",
+			yaml: here.Doc(`
+				name: some-name
+				nmae: some-name
+			`),
+			wantError: `line 2: unknown field "nmae"`,
+		},
+		{
+			name: "fields are case-sensitive",
+			yaml: here.Doc(`
+				Name: some-name
+			`),
+			wantError: `line 1: unknown field "Name"`,
+		},
+		{
+			name: "ignored fields are unknown",
+			yaml: here.Doc(`
+				Ignored: some-value
+			`),
+			wantError: `line 1: unknown field "Ignored"`,
+		},
+		{
+			name: "unknown nested field",
+			yaml: here.Doc(`
+				items:
+				  - address: 127.0.0.1
+				  - adress: 127.0.0.1
+			`),
+			wantError: `line 3: unknown field "adress" in items[1]`,
+		},
+		{
+			name: "duplicate field",
+			yaml: here.Doc(`
+				name: some-name
+				enabled: true
+				name: other-name
+			`),
+			wantError: `line 3: duplicate field "name"`,
+		},
+		{
+			name: "wrong type of bool",
+			yaml: here.Doc(`
+				enabled: "yes"
+			`),
+			wantError: `line 1: enabled must be true or false, not "yes"`,
+		},
+		{
+			name: "wrong type of integer",
+			yaml: here.Doc(`
+				port: 84.43
+			`),
+			wantError: `line 1: port must be an integer, not "84.43"`,
+		},
+		{
+			name: "wrong type of struct",
+			yaml: here.Doc(`
+				items: [some-item]
+			`),
+			wantError: `line 1: items[0] must be a mapping, not "some-item"`,
+		},
+		{
+			name: "wrong type of list",
+			yaml: here.Doc(`
+				items:
+				  address: 127.0.0.1
+			`),
+			wantError: `line 2: items must be a list, not a mapping`,
+		},
+		{
+			name: "wrong type of string",
+			yaml: here.Doc(`
+				labels:
+				  some-key: [a, b]
+			`),
+			wantError: `line 2: labels.some-key must be a string, not a list`,
+		},
+		{
+			name:      "not a mapping",
+			yaml:      "- name: some-name\n",
+			wantError: `line 1: the config must be a mapping`,
+		},
+		{
+			name:      "invalid yaml",
+			yaml:      "name: [some-name\n",
+			wantError: `yaml: line 1: did not find expected ',' or ']'`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var config testConfig
+			err := Decode([]byte(tt.yaml), want, &config)
+			if tt.wantError != "" {
+				require.EqualError(t, err, tt.wantError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantConfig, &config)
+		})
+	}
+}
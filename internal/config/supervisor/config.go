@@ -8,26 +8,65 @@ package supervisor
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
+	"path/filepath"
+	"reflect"
 	"strings"
 
-	"sigs.k8s.io/yaml"
+	"k8s.io/apimachinery/pkg/labels"
 
+	"go.pinniped.dev/internal/config/schema"
 	"go.pinniped.dev/internal/constable"
 	"go.pinniped.dev/internal/groupsuffix"
 	"go.pinniped.dev/internal/plog"
 )
 
+// These are the supported values of Endpoint.Network.
+const (
+	NetworkDisabled = "disabled"
+	NetworkTCP      = "tcp"
+	NetworkUnix     = "unix"
+)
+
+// AuditLogOutputStdout is the value of AuditLogSpec.Output which writes the audit log to stdout.
+const AuditLogOutputStdout = "stdout"
+
+const (
+	defaultInformerResyncPeriodSeconds = 3 * 60
+	defaultAuditWebhookBufferSize      = 10000
+)
+
 // FromPath loads an Config from a provided local file path, inserts any
 // defaults (from the Config documentation), and verifies that the config is
 // valid (Config documentation).
 func FromPath(path string) (*Config, error) {
+	config, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := plog.ValidateAndSetLogLevelGlobally(config.LogLevel); err != nil {
+		return nil, fmt.Errorf("validate log level: %w", err)
+	}
+
+	if err := plog.ValidateAndSetLogFormatGlobally(config.LogFormat, "supervisor"); err != nil {
+		return nil, fmt.Errorf("validate log format: %w", err)
+	}
+
+	return config, nil
+}
+
+// Load is like FromPath, except that it does not change the global log level. It is used to validate a changed
+// config file before any of its settings are applied.
+func Load(path string) (*Config, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
 
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := schema.Decode(data, schema.TypeMeta{APIVersion: APIVersion, Kind: Kind}, &config); err != nil {
 		return nil, fmt.Errorf("decode yaml: %w", err)
 	}
 
@@ -36,6 +75,8 @@ func FromPath(path string) (*Config, error) {
 	}
 
 	maybeSetAPIGroupSuffixDefault(&config.APIGroupSuffix)
+	maybeSetInformersDefaults(&config.Informers)
+	maybeSetAuditLogDefaults(config.AuditLog)
 
 	if err := validateAPIGroupSuffix(*config.APIGroupSuffix); err != nil {
 		return nil, fmt.Errorf("validate apiGroupSuffix: %w", err)
@@ -45,19 +86,106 @@ func FromPath(path string) (*Config, error) {
 		return nil, fmt.Errorf("validate names: %w", err)
 	}
 
-	if err := plog.ValidateAndSetLogLevelGlobally(config.LogLevel); err != nil {
+	if err := plog.ValidateLogLevel(config.LogLevel); err != nil {
 		return nil, fmt.Errorf("validate log level: %w", err)
 	}
 
+	if err := plog.ValidateLogFormat(config.LogFormat); err != nil {
+		return nil, fmt.Errorf("validate log format: %w", err)
+	}
+
+	maybeSetEndpointsDefaults(&config.Endpoints)
+
+	if err := validateEndpoints(config.Endpoints); err != nil {
+		return nil, fmt.Errorf("validate endpoints: %w", err)
+	}
+
+	if err := validateStaticAdminIdentityProvider(config.StaticAdminIdentityProvider); err != nil {
+		return nil, fmt.Errorf("validate staticAdminIdentityProvider: %w", err)
+	}
+
+	if err := validateInformers(&config.Informers); err != nil {
+		return nil, fmt.Errorf("validate informers: %w", err)
+	}
+
+	if err := validateControllers(&config.Controllers); err != nil {
+		return nil, fmt.Errorf("validate controllers: %w", err)
+	}
+
+	if err := validateAuditLog(config.AuditLog); err != nil {
+		return nil, fmt.Errorf("validate auditLog: %w", err)
+	}
+
 	return &config, nil
 }
 
+// RestartRequiredChanges returns the names of the top-level settings which differ between the two configs and which
+// only take effect when the Supervisor is restarted. Only the log level and format and the endpoints can be changed without a restart.
+func RestartRequiredChanges(oldConfig, newConfig *Config) []string {
+	var changes []string
+	if !reflect.DeepEqual(oldConfig.APIGroupSuffix, newConfig.APIGroupSuffix) {
+		changes = append(changes, "apiGroupSuffix")
+	}
+	if !reflect.DeepEqual(oldConfig.Labels, newConfig.Labels) {
+		changes = append(changes, "labels")
+	}
+	if !reflect.DeepEqual(oldConfig.NamesConfig, newConfig.NamesConfig) {
+		changes = append(changes, "names")
+	}
+	if !reflect.DeepEqual(oldConfig.StaticAdminIdentityProvider, newConfig.StaticAdminIdentityProvider) {
+		changes = append(changes, "staticAdminIdentityProvider")
+	}
+	if !reflect.DeepEqual(oldConfig.Informers, newConfig.Informers) {
+		changes = append(changes, "informers")
+	}
+	if !reflect.DeepEqual(oldConfig.Controllers, newConfig.Controllers) {
+		changes = append(changes, "controllers")
+	}
+	if !reflect.DeepEqual(oldConfig.AuditLog, newConfig.AuditLog) {
+		changes = append(changes, "auditLog")
+	}
+	return changes
+}
+
 func maybeSetAPIGroupSuffixDefault(apiGroupSuffix **string) {
 	if *apiGroupSuffix == nil {
 		*apiGroupSuffix = stringPtr("pinniped.dev")
 	}
 }
 
+func maybeSetInformersDefaults(informers *InformersSpec) {
+	if informers.ResyncPeriodSeconds == nil {
+		informers.ResyncPeriodSeconds = int64Ptr(defaultInformerResyncPeriodSeconds)
+	}
+}
+
+func maybeSetAuditLogDefaults(auditLog *AuditLogSpec) {
+	if auditLog != nil && auditLog.Webhook != nil && auditLog.Webhook.BufferSize == nil {
+		auditLog.Webhook.BufferSize = intPtr(defaultAuditWebhookBufferSize)
+	}
+}
+
+func maybeSetEndpointsDefaults(endpoints **Endpoints) {
+	if *endpoints == nil {
+		*endpoints = &Endpoints{}
+	}
+	if (*endpoints).HTTPS == nil {
+		(*endpoints).HTTPS = &Endpoint{Network: NetworkTCP, Address: ":8443"}
+	}
+	if (*endpoints).HTTP == nil {
+		(*endpoints).HTTP = &Endpoint{Network: NetworkTCP, Address: ":8080"}
+	}
+	if (*endpoints).Admin == nil {
+		(*endpoints).Admin = &Endpoint{Network: NetworkDisabled}
+	}
+	if (*endpoints).Metrics == nil {
+		(*endpoints).Metrics = &Endpoint{Network: NetworkDisabled}
+	}
+	if (*endpoints).Debug == nil {
+		(*endpoints).Debug = &Endpoint{Network: NetworkDisabled}
+	}
+}
+
 func validateAPIGroupSuffix(apiGroupSuffix string) error {
 	return groupsuffix.Validate(apiGroupSuffix)
 }
@@ -73,6 +201,202 @@ func validateNames(names *NamesConfigSpec) error {
 	return nil
 }
 
+func validateEndpoints(endpoints *Endpoints) error {
+	if err := validateEndpoint(endpoints.HTTPS); err != nil {
+		return fmt.Errorf("https: %w", err)
+	}
+	if err := validateMetricsOnlySettings(endpoints.HTTPS); err != nil {
+		return fmt.Errorf("https: %w", err)
+	}
+	if err := validateEndpoint(endpoints.HTTP); err != nil {
+		return fmt.Errorf("http: %w", err)
+	}
+	if endpoints.HTTP.RequestClientCertificates {
+		return constable.Error("http: requestClientCertificates is only supported by the https endpoint")
+	}
+	if err := validateMetricsOnlySettings(endpoints.HTTP); err != nil {
+		return fmt.Errorf("http: %w", err)
+	}
+	if err := validateEndpoint(endpoints.Admin); err != nil {
+		return fmt.Errorf("admin: %w", err)
+	}
+	if endpoints.Admin.Network == NetworkTCP {
+		return constable.Error(`admin: only the "unix" and "disabled" networks are supported`)
+	}
+	if endpoints.Admin.RequestClientCertificates {
+		return constable.Error("admin: requestClientCertificates is only supported by the https endpoint")
+	}
+	if err := validateMetricsOnlySettings(endpoints.Admin); err != nil {
+		return fmt.Errorf("admin: %w", err)
+	}
+	if err := validateEndpoint(endpoints.Metrics); err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	if endpoints.Metrics.RequestClientCertificates {
+		return constable.Error("metrics: requestClientCertificates is only supported by the https endpoint")
+	}
+	if endpoints.Metrics.RequireAuthorization && endpoints.Metrics.Network == NetworkTCP && endpoints.Metrics.TLSSecretName == "" {
+		return constable.Error(`metrics: requireAuthorization needs tlsSecretName with "tcp" network`)
+	}
+	if err := validateEndpoint(endpoints.Debug); err != nil {
+		return fmt.Errorf("debug: %w", err)
+	}
+	if endpoints.Debug.RequestClientCertificates {
+		return constable.Error("debug: requestClientCertificates is only supported by the https endpoint")
+	}
+	if endpoints.Debug.Network == NetworkTCP {
+		if endpoints.Debug.RequireAuthorization && endpoints.Debug.TLSSecretName == "" {
+			return constable.Error(`debug: requireAuthorization needs tlsSecretName with "tcp" network`)
+		}
+		if !endpoints.Debug.RequireAuthorization && !isLoopback(endpoints.Debug.Address) {
+			return fmt.Errorf("debug: address %q must be a loopback address unless requireAuthorization is set", endpoints.Debug.Address)
+		}
+	}
+	if endpoints.HTTPS.Network == NetworkDisabled && endpoints.HTTP.Network == NetworkDisabled {
+		return constable.Error("all endpoints are disabled")
+	}
+	return nil
+}
+
+func validateMetricsOnlySettings(endpoint *Endpoint) error {
+	if endpoint.TLSSecretName != "" {
+		return constable.Error("tlsSecretName is only supported by the metrics and debug endpoints")
+	}
+	if endpoint.RequireAuthorization {
+		return constable.Error("requireAuthorization is only supported by the metrics and debug endpoints")
+	}
+	return nil
+}
+
+func validateEndpoint(endpoint *Endpoint) error {
+	switch endpoint.Network {
+	case NetworkTCP:
+		if endpoint.Address == "" {
+			return fmt.Errorf("address must be set with %q network", endpoint.Network)
+		}
+		if _, _, err := net.SplitHostPort(endpoint.Address); err != nil {
+			return fmt.Errorf("invalid address %q: %w", endpoint.Address, err)
+		}
+		return nil
+	case NetworkUnix:
+		if endpoint.Address == "" {
+			return fmt.Errorf("address must be set with %q network", endpoint.Network)
+		}
+		if !filepath.IsAbs(endpoint.Address) {
+			return fmt.Errorf("address %q must be an absolute path with %q network", endpoint.Address, endpoint.Network)
+		}
+		return nil
+	case NetworkDisabled:
+		if endpoint.Address != "" {
+			return fmt.Errorf("address set to %q when disabled, should be empty", endpoint.Address)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown network %q", endpoint.Network)
+	}
+}
+
+// isLoopback returns whether the host of the host:port address is "localhost" or a loopback IP.
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func validateInformers(informers *InformersSpec) error {
+	// Some controllers, like the one which renews the serving certificate, only run again on the next resync.
+	if *informers.ResyncPeriodSeconds <= 0 {
+		return constable.Error("resyncPeriodSeconds must be positive")
+	}
+	if _, err := labels.Parse(informers.SecretLabelSelector); err != nil {
+		return fmt.Errorf("invalid secretLabelSelector %q: %w", informers.SecretLabelSelector, err)
+	}
+	return nil
+}
+
+func validateControllers(controllers *ControllersSpec) error {
+	if err := validateRateLimiter(&controllers.RateLimiter); err != nil {
+		return fmt.Errorf("rateLimiter: %w", err)
+	}
+	for name, override := range controllers.RateLimiterOverrides {
+		override := override
+		if err := validateRateLimiter(&override); err != nil {
+			return fmt.Errorf("rateLimiterOverrides[%q]: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateRateLimiter(rateLimiter *RateLimiterSpec) error {
+	switch {
+	case rateLimiter.BaseDelayMilliseconds < 0:
+		return constable.Error("baseDelayMilliseconds must not be negative")
+	case rateLimiter.MaxDelaySeconds < 0:
+		return constable.Error("maxDelaySeconds must not be negative")
+	case rateLimiter.QPS < 0:
+		return constable.Error("qps must not be negative")
+	case rateLimiter.BucketSize < 0:
+		return constable.Error("bucketSize must not be negative")
+	case rateLimiter.BaseDelayMilliseconds > 0 && rateLimiter.MaxDelaySeconds > 0 &&
+		rateLimiter.MaxDelaySeconds*1000 < rateLimiter.BaseDelayMilliseconds:
+		return constable.Error("maxDelaySeconds must not be shorter than baseDelayMilliseconds")
+	}
+	return nil
+}
+
+func validateStaticAdminIdentityProvider(spec *StaticAdminIdentityProviderSpec) error {
+	if spec != nil && spec.SecretName == "" {
+		return constable.Error("secretName must be set")
+	}
+	return nil
+}
+
+func validateAuditLog(spec *AuditLogSpec) error {
+	if spec == nil {
+		return nil
+	}
+	if spec.Output == "" && spec.Webhook == nil {
+		return constable.Error("output or webhook must be set")
+	}
+	if spec.Output != "" && spec.Output != AuditLogOutputStdout && !filepath.IsAbs(spec.Output) {
+		return fmt.Errorf("output %q must be %q or an absolute path", spec.Output, AuditLogOutputStdout)
+	}
+	if spec.Webhook != nil {
+		if err := validateAuditWebhook(spec.Webhook); err != nil {
+			return fmt.Errorf("webhook: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateAuditWebhook(spec *AuditWebhookSpec) error {
+	u, err := url.Parse(spec.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("url %q must be an https URL", spec.URL)
+	}
+	if spec.BufferSize != nil && *spec.BufferSize < 1 {
+		return constable.Error("bufferSize must be positive")
+	}
+	return nil
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/plog"
 )
 
 func TestFromPath(t *testing.T) {
@@ -30,6 +31,24 @@ func TestFromPath(t *testing.T) {
 				  myLabelKey2: myLabelValue2
 				names:
 				  defaultTLSCertificateSecret: my-secret-name
+				logFormat: text
+				endpoints:
+				  https:
+				    network: tcp
+				    address: 127.0.0.1:1234
+				    requestClientCertificates: true
+				  http:
+				    network: disabled
+				informers:
+				  resyncPeriodSeconds: 600
+				  secretLabelSelector: app.kubernetes.io/part-of!=other
+				controllers:
+				  rateLimiter:
+				    qps: 5
+				  rateLimiterOverrides:
+				    garbage-collector-controller:
+				      baseDelayMilliseconds: 1000
+				      bucketSize: 10
 			`),
 			wantConfig: &Config{
 				APIGroupSuffix: stringPtr("some.suffix.com"),
@@ -40,6 +59,24 @@ func TestFromPath(t *testing.T) {
 				NamesConfig: NamesConfigSpec{
 					DefaultTLSCertificateSecret: "my-secret-name",
 				},
+				LogFormat: plog.FormatText,
+				Endpoints: &Endpoints{
+					HTTPS:   &Endpoint{Network: "tcp", Address: "127.0.0.1:1234", RequestClientCertificates: true},
+					HTTP:    &Endpoint{Network: "disabled"},
+					Admin:   &Endpoint{Network: "disabled"},
+					Metrics: &Endpoint{Network: "disabled"},
+					Debug:   &Endpoint{Network: "disabled"},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(600),
+					SecretLabelSelector: "app.kubernetes.io/part-of!=other",
+				},
+				Controllers: ControllersSpec{
+					RateLimiter: RateLimiterSpec{QPS: 5},
+					RateLimiterOverrides: map[string]RateLimiterSpec{
+						"garbage-collector-controller": {BaseDelayMilliseconds: 1000, BucketSize: 10},
+					},
+				},
 			},
 		},
 		{
@@ -55,8 +92,643 @@ func TestFromPath(t *testing.T) {
 				NamesConfig: NamesConfigSpec{
 					DefaultTLSCertificateSecret: "my-secret-name",
 				},
+				Endpoints: &Endpoints{
+					HTTPS:   &Endpoint{Network: "tcp", Address: ":8443"},
+					HTTP:    &Endpoint{Network: "tcp", Address: ":8080"},
+					Admin:   &Endpoint{Network: "disabled"},
+					Metrics: &Endpoint{Network: "disabled"},
+					Debug:   &Endpoint{Network: "disabled"},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(180),
+				},
+			},
+		},
+		{
+			name: "With the apiVersion and kind of the config file",
+			yaml: here.Doc(`
+				---
+				apiVersion: supervisor.config.pinniped.dev/v1alpha1
+				kind: SupervisorConfig
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+			`),
+			wantConfig: &Config{
+				APIGroupSuffix: stringPtr("pinniped.dev"),
+				Labels:         map[string]string{},
+				NamesConfig: NamesConfigSpec{
+					DefaultTLSCertificateSecret: "my-secret-name",
+				},
+				Endpoints: &Endpoints{
+					HTTPS:   &Endpoint{Network: "tcp", Address: ":8443"},
+					HTTP:    &Endpoint{Network: "tcp", Address: ":8080"},
+					Admin:   &Endpoint{Network: "disabled"},
+					Metrics: &Endpoint{Network: "disabled"},
+					Debug:   &Endpoint{Network: "disabled"},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(180),
+				},
+			},
+		},
+		{
+			name: "Unsupported apiVersion",
+			yaml: here.Doc(`
+				---
+				apiVersion: supervisor.config.pinniped.dev/v1
+				kind: SupervisorConfig
+			`),
+			wantError: `decode yaml: line 2: unsupported apiVersion "supervisor.config.pinniped.dev/v1" (expected "supervisor.config.pinniped.dev/v1alpha1")`,
+		},
+		{
+			name: "Misspelled field",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  https:
+				    network: tcp
+				    adress: :1234
+			`),
+			wantError: `decode yaml: line 7: unknown field "adress" in endpoints.https`,
+		},
+		{
+			name: "Field with the wrong type",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				informers:
+				  resyncPeriodSeconds: 10m
+			`),
+			wantError: `decode yaml: line 5: informers.resyncPeriodSeconds must be an integer, not "10m"`,
+		},
+		{
+			name: "Only one endpoint is specified, causes the other to be defaulted",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  http:
+				    network: tcp
+				    address: :1234
+			`),
+			wantConfig: &Config{
+				APIGroupSuffix: stringPtr("pinniped.dev"),
+				Labels:         map[string]string{},
+				NamesConfig: NamesConfigSpec{
+					DefaultTLSCertificateSecret: "my-secret-name",
+				},
+				Endpoints: &Endpoints{
+					HTTPS:   &Endpoint{Network: "tcp", Address: ":8443"},
+					HTTP:    &Endpoint{Network: "tcp", Address: ":1234"},
+					Admin:   &Endpoint{Network: "disabled"},
+					Metrics: &Endpoint{Network: "disabled"},
+					Debug:   &Endpoint{Network: "disabled"},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(180),
+				},
+			},
+		},
+		{
+			name: "Negative informer resyncPeriodSeconds",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				informers:
+				  resyncPeriodSeconds: -1
+			`),
+			wantError: "validate informers: resyncPeriodSeconds must be positive",
+		},
+		{
+			name: "Zero informer resyncPeriodSeconds",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				informers:
+				  resyncPeriodSeconds: 0
+			`),
+			wantError: "validate informers: resyncPeriodSeconds must be positive",
+		},
+		{
+			name: "Invalid informer secretLabelSelector",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				informers:
+				  secretLabelSelector: "app in (a"
+			`),
+			wantError: `validate informers: invalid secretLabelSelector "app in (a": unable to parse requirement: found '', expected: ',' or ')'`,
+		},
+		{
+			name: "Negative rate limiter bucketSize",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				controllers:
+				  rateLimiterOverrides:
+				    some-controller:
+				      bucketSize: -1
+			`),
+			wantError: `validate controllers: rateLimiterOverrides["some-controller"]: bucketSize must not be negative`,
+		},
+		{
+			name: "Invalid logFormat",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				logFormat: logfmt
+			`),
+			wantError: "validate log format: invalid log format, valid choices are the empty string, text and json",
+		},
+		{
+			name: "All endpoints disabled",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  https:
+				    network: disabled
+				  http:
+				    network: disabled
+			`),
+			wantError: "validate endpoints: all endpoints are disabled",
+		},
+		{
+			name: "Unknown network",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  https:
+				    network: udp
+				    address: :8443
+			`),
+			wantError: `validate endpoints: https: unknown network "udp"`,
+		},
+		{
+			name: "TCP endpoint without address",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  http:
+				    network: tcp
+			`),
+			wantError: `validate endpoints: http: address must be set with "tcp" network`,
+		},
+		{
+			name: "TCP endpoint with invalid address",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  https:
+				    network: tcp
+				    address: "8443"
+			`),
+			wantError: `validate endpoints: https: invalid address "8443": address 8443: missing port in address`,
+		},
+		{
+			name: "Unix socket endpoint",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  http:
+				    network: unix
+				    address: /var/run/pinniped/http.sock
+				  admin:
+				    network: unix
+				    address: /var/run/pinniped/admin.sock
+			`),
+			wantConfig: &Config{
+				APIGroupSuffix: stringPtr("pinniped.dev"),
+				Labels:         map[string]string{},
+				NamesConfig: NamesConfigSpec{
+					DefaultTLSCertificateSecret: "my-secret-name",
+				},
+				Endpoints: &Endpoints{
+					HTTPS:   &Endpoint{Network: "tcp", Address: ":8443"},
+					HTTP:    &Endpoint{Network: "unix", Address: "/var/run/pinniped/http.sock"},
+					Admin:   &Endpoint{Network: "unix", Address: "/var/run/pinniped/admin.sock"},
+					Metrics: &Endpoint{Network: "disabled"},
+					Debug:   &Endpoint{Network: "disabled"},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(180),
+				},
+			},
+		},
+		{
+			name: "Unix socket endpoint without address",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  http:
+				    network: unix
+			`),
+			wantError: `validate endpoints: http: address must be set with "unix" network`,
+		},
+		{
+			name: "Unix socket endpoint with relative path",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  http:
+				    network: unix
+				    address: http.sock
+			`),
+			wantError: `validate endpoints: http: address "http.sock" must be an absolute path with "unix" network`,
+		},
+		{
+			name: "Disabled endpoint with address",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  http:
+				    network: disabled
+				    address: :8080
+			`),
+			wantError: `validate endpoints: http: address set to ":8080" when disabled, should be empty`,
+		},
+		{
+			name: "Client certificates requested on the http endpoint",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  http:
+				    network: tcp
+				    address: :8080
+				    requestClientCertificates: true
+			`),
+			wantError: "validate endpoints: http: requestClientCertificates is only supported by the https endpoint",
+		},
+		{
+			name: "Admin endpoint on a tcp network",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  admin:
+				    network: tcp
+				    address: :8081
+			`),
+			wantError: `validate endpoints: admin: only the "unix" and "disabled" networks are supported`,
+		},
+		{
+			name: "Admin endpoint without address",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  admin:
+				    network: unix
+			`),
+			wantError: `validate endpoints: admin: address must be set with "unix" network`,
+		},
+		{
+			name: "Metrics endpoint with TLS and authorization",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  metrics:
+				    network: tcp
+				    address: :8444
+				    tlsSecretName: my-metrics-tls-secret
+				    requireAuthorization: true
+			`),
+			wantConfig: &Config{
+				APIGroupSuffix: stringPtr("pinniped.dev"),
+				Labels:         map[string]string{},
+				NamesConfig: NamesConfigSpec{
+					DefaultTLSCertificateSecret: "my-secret-name",
+				},
+				Endpoints: &Endpoints{
+					HTTPS:   &Endpoint{Network: "tcp", Address: ":8443"},
+					HTTP:    &Endpoint{Network: "tcp", Address: ":8080"},
+					Admin:   &Endpoint{Network: "disabled"},
+					Metrics: &Endpoint{Network: "tcp", Address: ":8444", TLSSecretName: "my-metrics-tls-secret", RequireAuthorization: true},
+					Debug:   &Endpoint{Network: "disabled"},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(180),
+				},
+			},
+		},
+		{
+			name: "Metrics endpoint requiring authorization over plain HTTP",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  metrics:
+				    network: tcp
+				    address: :8444
+				    requireAuthorization: true
+			`),
+			wantError: `validate endpoints: metrics: requireAuthorization needs tlsSecretName with "tcp" network`,
+		},
+		{
+			name: "Metrics endpoint with invalid address",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  metrics:
+				    network: tcp
+			`),
+			wantError: `validate endpoints: metrics: address must be set with "tcp" network`,
+		},
+		{
+			name: "Debug endpoint on a loopback address",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  debug:
+				    network: tcp
+				    address: 127.0.0.1:8085
+			`),
+			wantConfig: &Config{
+				APIGroupSuffix: stringPtr("pinniped.dev"),
+				Labels:         map[string]string{},
+				NamesConfig: NamesConfigSpec{
+					DefaultTLSCertificateSecret: "my-secret-name",
+				},
+				Endpoints: &Endpoints{
+					HTTPS:   &Endpoint{Network: "tcp", Address: ":8443"},
+					HTTP:    &Endpoint{Network: "tcp", Address: ":8080"},
+					Admin:   &Endpoint{Network: "disabled"},
+					Metrics: &Endpoint{Network: "disabled"},
+					Debug:   &Endpoint{Network: "tcp", Address: "127.0.0.1:8085"},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(180),
+				},
+			},
+		},
+		{
+			name: "Debug endpoint on all interfaces with TLS and authorization",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  debug:
+				    network: tcp
+				    address: :8085
+				    tlsSecretName: my-debug-tls-secret
+				    requireAuthorization: true
+			`),
+			wantConfig: &Config{
+				APIGroupSuffix: stringPtr("pinniped.dev"),
+				Labels:         map[string]string{},
+				NamesConfig: NamesConfigSpec{
+					DefaultTLSCertificateSecret: "my-secret-name",
+				},
+				Endpoints: &Endpoints{
+					HTTPS:   &Endpoint{Network: "tcp", Address: ":8443"},
+					HTTP:    &Endpoint{Network: "tcp", Address: ":8080"},
+					Admin:   &Endpoint{Network: "disabled"},
+					Metrics: &Endpoint{Network: "disabled"},
+					Debug:   &Endpoint{Network: "tcp", Address: ":8085", TLSSecretName: "my-debug-tls-secret", RequireAuthorization: true},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(180),
+				},
 			},
 		},
+		{
+			name: "Debug endpoint on all interfaces without authorization",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  debug:
+				    network: tcp
+				    address: :8085
+			`),
+			wantError: `validate endpoints: debug: address ":8085" must be a loopback address unless requireAuthorization is set`,
+		},
+		{
+			name: "Debug endpoint requiring authorization over plain HTTP",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  debug:
+				    network: tcp
+				    address: localhost:8085
+				    requireAuthorization: true
+			`),
+			wantError: `validate endpoints: debug: requireAuthorization needs tlsSecretName with "tcp" network`,
+		},
+		{
+			name: "TLS secret name on the https endpoint",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  https:
+				    network: tcp
+				    address: :8443
+				    tlsSecretName: my-metrics-tls-secret
+			`),
+			wantError: "validate endpoints: https: tlsSecretName is only supported by the metrics and debug endpoints",
+		},
+		{
+			name: "Authorization required on the http endpoint",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  http:
+				    network: tcp
+				    address: :8080
+				    requireAuthorization: true
+			`),
+			wantError: "validate endpoints: http: requireAuthorization is only supported by the metrics and debug endpoints",
+		},
+		{
+			name: "Static admin identity provider",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				staticAdminIdentityProvider:
+				  secretName: my-admin-secret
+			`),
+			wantConfig: &Config{
+				APIGroupSuffix: stringPtr("pinniped.dev"),
+				Labels:         map[string]string{},
+				NamesConfig: NamesConfigSpec{
+					DefaultTLSCertificateSecret: "my-secret-name",
+				},
+				Endpoints: &Endpoints{
+					HTTPS:   &Endpoint{Network: "tcp", Address: ":8443"},
+					HTTP:    &Endpoint{Network: "tcp", Address: ":8080"},
+					Admin:   &Endpoint{Network: "disabled"},
+					Metrics: &Endpoint{Network: "disabled"},
+					Debug:   &Endpoint{Network: "disabled"},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(180),
+				},
+				StaticAdminIdentityProvider: &StaticAdminIdentityProviderSpec{SecretName: "my-admin-secret"},
+			},
+		},
+		{
+			name: "Audit log to a file",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				auditLog:
+				  output: /var/log/pinniped/audit.log
+			`),
+			wantConfig: &Config{
+				APIGroupSuffix: stringPtr("pinniped.dev"),
+				Labels:         map[string]string{},
+				NamesConfig: NamesConfigSpec{
+					DefaultTLSCertificateSecret: "my-secret-name",
+				},
+				Endpoints: &Endpoints{
+					HTTPS:   &Endpoint{Network: "tcp", Address: ":8443"},
+					HTTP:    &Endpoint{Network: "tcp", Address: ":8080"},
+					Admin:   &Endpoint{Network: "disabled"},
+					Metrics: &Endpoint{Network: "disabled"},
+					Debug:   &Endpoint{Network: "disabled"},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(180),
+				},
+				AuditLog: &AuditLogSpec{Output: "/var/log/pinniped/audit.log"},
+			},
+		},
+		{
+			name: "Audit log to a relative path",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				auditLog:
+				  output: audit.log
+			`),
+			wantError: `validate auditLog: output "audit.log" must be "stdout" or an absolute path`,
+		},
+		{
+			name: "Audit log to a webhook",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				auditLog:
+				  webhook:
+				    url: https://siem.example.com/services/collector/raw
+				    secretName: my-audit-webhook-secret
+			`),
+			wantConfig: &Config{
+				APIGroupSuffix: stringPtr("pinniped.dev"),
+				Labels:         map[string]string{},
+				NamesConfig: NamesConfigSpec{
+					DefaultTLSCertificateSecret: "my-secret-name",
+				},
+				Endpoints: &Endpoints{
+					HTTPS:   &Endpoint{Network: "tcp", Address: ":8443"},
+					HTTP:    &Endpoint{Network: "tcp", Address: ":8080"},
+					Admin:   &Endpoint{Network: "disabled"},
+					Metrics: &Endpoint{Network: "disabled"},
+					Debug:   &Endpoint{Network: "disabled"},
+				},
+				Informers: InformersSpec{
+					ResyncPeriodSeconds: int64Ptr(180),
+				},
+				AuditLog: &AuditLogSpec{Webhook: &AuditWebhookSpec{
+					URL:        "https://siem.example.com/services/collector/raw",
+					SecretName: "my-audit-webhook-secret",
+					BufferSize: intPtr(10000),
+				}},
+			},
+		},
+		{
+			name: "Audit log without output or webhook",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				auditLog: {}
+			`),
+			wantError: "validate auditLog: output or webhook must be set",
+		},
+		{
+			name: "Audit webhook without TLS",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				auditLog:
+				  output: stdout
+				  webhook:
+				    url: http://siem.example.com/events
+			`),
+			wantError: `validate auditLog: webhook: url "http://siem.example.com/events" must be an https URL`,
+		},
+		{
+			name: "Audit webhook with an empty buffer",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				auditLog:
+				  webhook:
+				    url: https://siem.example.com/events
+				    bufferSize: 0
+			`),
+			wantError: "validate auditLog: webhook: bufferSize must be positive",
+		},
+		{
+			name: "Static admin identity provider without secretName",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				staticAdminIdentityProvider: {}
+			`),
+			wantError: "validate staticAdminIdentityProvider: secretName must be set",
+		},
 		{
 			name: "Missing defaultTLSCertificateSecret name",
 			yaml: here.Doc(`
@@ -102,3 +774,40 @@ func TestFromPath(t *testing.T) {
 		})
 	}
 }
+
+func TestRestartRequiredChanges(t *testing.T) {
+	oldConfig := &Config{
+		APIGroupSuffix: stringPtr("pinniped.dev"),
+		Labels:         map[string]string{"myLabelKey": "myLabelValue"},
+		NamesConfig:    NamesConfigSpec{DefaultTLSCertificateSecret: "my-secret-name"},
+		Endpoints: &Endpoints{
+			HTTPS: &Endpoint{Network: "tcp", Address: ":8443"},
+			HTTP:  &Endpoint{Network: "tcp", Address: ":8080"},
+		},
+	}
+
+	// Changes to the log level and the endpoints can be applied without a restart.
+	newConfig := &Config{
+		APIGroupSuffix: stringPtr("pinniped.dev"),
+		Labels:         map[string]string{"myLabelKey": "myLabelValue"},
+		NamesConfig:    NamesConfigSpec{DefaultTLSCertificateSecret: "my-secret-name"},
+		LogLevel:       "debug",
+		LogFormat:      "json",
+		Endpoints: &Endpoints{
+			HTTPS: &Endpoint{Network: "tcp", Address: ":9443"},
+			HTTP:  &Endpoint{Network: "disabled"},
+		},
+	}
+	require.Empty(t, RestartRequiredChanges(oldConfig, newConfig))
+
+	newConfig.APIGroupSuffix = stringPtr("some.suffix.com")
+	newConfig.Labels = map[string]string{"myLabelKey": "myOtherLabelValue"}
+	newConfig.NamesConfig.DefaultTLSCertificateSecret = "my-other-secret-name"
+	newConfig.StaticAdminIdentityProvider = &StaticAdminIdentityProviderSpec{SecretName: "my-admin-secret"}
+	newConfig.Controllers.RateLimiter.MaxDelaySeconds = 60
+	newConfig.AuditLog = &AuditLogSpec{Output: AuditLogOutputStdout}
+	require.Equal(t,
+		[]string{"apiGroupSuffix", "labels", "names", "staticAdminIdentityProvider", "controllers", "auditLog"},
+		RestartRequiredChanges(oldConfig, newConfig),
+	)
+}
@@ -5,15 +5,152 @@ package supervisor
 
 import "go.pinniped.dev/internal/plog"
 
+// APIVersion and Kind identify the current version of the Supervisor config file. They may be left out of the file.
+const (
+	APIVersion = "supervisor.config.pinniped.dev/v1alpha1"
+	Kind       = "SupervisorConfig"
+)
+
 // Config contains knobs to setup an instance of the Pinniped Supervisor.
 type Config struct {
 	APIGroupSuffix *string           `json:"apiGroupSuffix,omitempty"`
 	Labels         map[string]string `json:"labels"`
 	NamesConfig    NamesConfigSpec   `json:"names"`
 	LogLevel       plog.LogLevel     `json:"logLevel"`
+	LogFormat      plog.LogFormat    `json:"logFormat,omitempty"`
+	Endpoints      *Endpoints        `json:"endpoints,omitempty"`
+	Informers      InformersSpec     `json:"informers"`
+	Controllers    ControllersSpec   `json:"controllers"`
+
+	StaticAdminIdentityProvider *StaticAdminIdentityProviderSpec `json:"staticAdminIdentityProvider,omitempty"`
+	AuditLog                    *AuditLogSpec                    `json:"auditLog,omitempty"`
+}
+
+// AuditLogSpec enables the security audit log, which records every authorization request, login, and token grant
+// with the username, upstream identity provider, client ID, and source IP as one JSON object per line. At least one
+// of Output and Webhook must be set.
+type AuditLogSpec struct {
+	// Output is either "stdout", which mixes the audit events into the output of the container next to the logs on
+	// stderr, or the absolute path of a file to which they are appended, e.g. on a volume which is shipped elsewhere.
+	Output string `json:"output,omitempty"`
+
+	// Webhook also sends the audit events to an HTTPS endpoint, e.g. the HTTP event collector of a SIEM system.
+	Webhook *AuditWebhookSpec `json:"webhook,omitempty"`
+}
+
+// AuditWebhookSpec configures an HTTPS endpoint to which the Supervisor POSTs batches of audit events as
+// newline-delimited JSON. Events are buffered in memory and retried while the endpoint is unavailable.
+type AuditWebhookSpec struct {
+	// URL is the https URL of the endpoint.
+	URL string `json:"url"`
+
+	// SecretName is the name of an optional Secret in the Supervisor's namespace. Its "authorization" key is sent as
+	// the Authorization header of each request (e.g. "Splunk <token>"), and its "ca.crt" key is the PEM-encoded CA
+	// bundle which is trusted instead of the system roots. It is only read when the Supervisor starts.
+	SecretName string `json:"secretName,omitempty"`
+
+	// BufferSize is how many events are held in memory while the endpoint is unavailable. Events which arrive while
+	// the buffer is full are dropped. By default, it is 10000.
+	BufferSize *int `json:"bufferSize,omitempty"`
+}
+
+// StaticAdminIdentityProviderSpec enables a built-in identity provider with a single user, which can be used to
+// bootstrap a new installation before a real upstream identity provider has been configured. It should be removed
+// from the config as soon as it is no longer needed.
+type StaticAdminIdentityProviderSpec struct {
+	// SecretName is the name of a Secret in the Supervisor's namespace which contains the "username" and
+	// "passwordHash" (bcrypt) of the user, and optionally their comma-separated "groups".
+	SecretName string `json:"secretName"`
+}
+
+// InformersSpec configures the informers which watch Kubernetes resources, including the session storage Secrets.
+type InformersSpec struct {
+	// ResyncPeriodSeconds is how often every informer replays all cached objects to its controllers. Clusters with
+	// many sessions can use a longer period. It must be positive. By default, it is 180 seconds.
+	ResyncPeriodSeconds *int64 `json:"resyncPeriodSeconds,omitempty"`
+
+	// SecretLabelSelector limits the Secrets which the Supervisor watches in its namespace, e.g. when it shares the
+	// namespace with other applications that have many Secrets. Every Secret which the Supervisor reads must match it:
+	// the session storage Secrets, the Secrets which it generates, and the Secrets which are named in its config and
+	// its custom resources. A selector which excludes the unrelated Secrets, e.g. "app.kubernetes.io/part-of!=other",
+	// is usually easier than labeling all of those. By default, all Secrets in the namespace are watched.
+	SecretLabelSelector string `json:"secretLabelSelector,omitempty"`
+}
+
+// ControllersSpec configures the controllers of the Supervisor. Each controller has a queue of keys to sync, which is rate
+// limited so that a controller which keeps failing does not overload the Kubernetes API server.
+type ControllersSpec struct {
+	// RateLimiter applies to the queues of all controllers.
+	RateLimiter RateLimiterSpec `json:"rateLimiter"`
+
+	// RateLimiterOverrides replaces the settings of RateLimiter which are set in the override for the controllers with
+	// the given names. The names are those in the "controller" field of the logs and the metrics. The server fails to
+	// start when an override names none of its controllers.
+	RateLimiterOverrides map[string]RateLimiterSpec `json:"rateLimiterOverrides,omitempty"`
+}
+
+// RateLimiterSpec configures the rate limiter of a controller queue. Unset fields keep their default values.
+type RateLimiterSpec struct {
+	// BaseDelayMilliseconds is how long a controller waits before it retries a key which failed to sync. The delay
+	// doubles with each consecutive failure. By default, it is 5 milliseconds.
+	BaseDelayMilliseconds int64 `json:"baseDelayMilliseconds,omitempty"`
+
+	// MaxDelaySeconds is the longest delay between the retries of a key. By default, it is 1000 seconds.
+	MaxDelaySeconds int64 `json:"maxDelaySeconds,omitempty"`
+
+	// QPS is how many keys per second a controller syncs at most, whether or not they failed before. Slow clusters
+	// can use a lower rate. By default, it is 10.
+	QPS float64 `json:"qps,omitempty"`
+
+	// BucketSize is how many keys a controller can sync in a burst above QPS, e.g. right after it starts. Very large
+	// clusters can use a larger bucket. By default, it is 100.
+	BucketSize int `json:"bucketSize,omitempty"`
 }
 
 // NamesConfigSpec configures the names of some Kubernetes resources for the Supervisor.
 type NamesConfigSpec struct {
 	DefaultTLSCertificateSecret string `json:"defaultTLSCertificateSecret"`
 }
+
+// Endpoints configures the listeners on which the Supervisor serves its endpoints.
+//
+// Admin serves the API of the "pinniped-supervisor admin" subcommands, which operators run inside of the Supervisor
+// pod. It only supports the "unix" and "disabled" networks, and it is disabled by default.
+//
+// Metrics serves the Prometheus metrics of the Supervisor at /metrics, e.g. the requests to each endpoint of the
+// FederationDomains and the round trips to the upstream identity providers. It is disabled by default.
+//
+// Debug serves the runtime profiles of net/http/pprof at /debug/pprof/ and the variables of expvar at /debug/vars,
+// so that the CPU and memory usage of a running Supervisor can be investigated. It is disabled by default. With the
+// "tcp" network, it must either listen on a loopback address (e.g. "127.0.0.1:8085", which can be reached with
+// "kubectl port-forward") or require authorization.
+type Endpoints struct {
+	HTTPS   *Endpoint `json:"https,omitempty"`
+	HTTP    *Endpoint `json:"http,omitempty"`
+	Admin   *Endpoint `json:"admin,omitempty"`
+	Metrics *Endpoint `json:"metrics,omitempty"`
+	Debug   *Endpoint `json:"debug,omitempty"`
+}
+
+// Endpoint configures a single listener. Network must be one of "tcp", "unix", or "disabled". When the network is
+// "tcp", Address is a host:port pair (e.g. ":8443") as accepted by net.Listen. When the network is "unix", Address
+// is the absolute path of the socket file, which allows a sidecar in the same pod to terminate TLS and forward
+// requests without the Supervisor exposing a plaintext TCP port.
+//
+// RequestClientCertificates is only supported by the https endpoint. When it is true, clients are asked for an
+// optional TLS client certificate, and the tokens of a client which presents one are bound to it as described by
+// RFC 8705. Browsers may then offer their user a choice of certificates during login, so it is off by default.
+//
+// TLSSecretName and RequireAuthorization are only supported by the metrics and debug endpoints. TLSSecretName is the
+// name of a Secret of type kubernetes.io/tls in the Supervisor's namespace, which makes the endpoint serve HTTPS with
+// that certificate instead of plain HTTP. When RequireAuthorization is true, every request must have the bearer token
+// of a Kubernetes user or service account which is allowed to get the requested non-resource URL, as checked with a
+// TokenReview and a SubjectAccessReview. Bearer tokens must not be sent in plain text, so it requires TLSSecretName
+// when the network is "tcp".
+type Endpoint struct {
+	Network                   string `json:"network"`
+	Address                   string `json:"address,omitempty"`
+	RequestClientCertificates bool   `json:"requestClientCertificates,omitempty"`
+	TLSSecretName             string `json:"tlsSecretName,omitempty"`
+	RequireAuthorization      bool   `json:"requireAuthorization,omitempty"`
+}
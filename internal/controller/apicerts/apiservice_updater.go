@@ -30,6 +30,7 @@ func NewAPIServiceUpdaterController(
 	aggregatorClient aggregatorclient.Interface,
 	secretInformer corev1informers.SecretInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -42,11 +43,13 @@ func NewAPIServiceUpdaterController(
 				apiServiceName:          apiServiceName,
 			},
 		},
-		withInformer(
-			secretInformer,
-			pinnipedcontroller.NameAndNamespaceExactMatchFilterFactory(certsSecretResourceName, namespace),
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			withInformer(
+				secretInformer,
+				pinnipedcontroller.NameAndNamespaceExactMatchFilterFactory(certsSecretResourceName, namespace),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
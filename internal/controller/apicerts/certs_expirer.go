@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package apicerts
@@ -32,9 +32,11 @@ type certsExpirerController struct {
 	renewBefore time.Duration
 }
 
-// NewCertsExpirerController returns a controllerlib.Controller that will delete a
-// certificate secret once it gets within some threshold of its expiration time. The
-// deletion forces rotation of the secret with the help of other controllers.
+// NewCertsExpirerController returns a controllerlib.Controller that will rotate a
+// certificate secret once it gets within some threshold of its expiration time. When
+// the certs manager controller has staged the next certificates in the secret, they
+// are promoted to be the current certificates. Otherwise, the secret is deleted, which
+// forces rotation of the secret with the help of other controllers.
 func NewCertsExpirerController(
 	namespace string,
 	certsSecretResourceName string,
@@ -42,6 +44,7 @@ func NewCertsExpirerController(
 	secretInformer corev1informers.SecretInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
 	renewBefore time.Duration,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -54,11 +57,13 @@ func NewCertsExpirerController(
 				renewBefore:             renewBefore,
 			},
 		},
-		withInformer(
-			secretInformer,
-			pinnipedcontroller.NameAndNamespaceExactMatchFilterFactory(certsSecretResourceName, namespace),
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			withInformer(
+				secretInformer,
+				pinnipedcontroller.NameAndNamespaceExactMatchFilterFactory(certsSecretResourceName, namespace),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
@@ -74,7 +79,7 @@ func (c *certsExpirerController) Sync(ctx controllerlib.Context) error {
 		return nil
 	}
 
-	notBefore, notAfter, err := getCertBounds(secret)
+	notBefore, notAfter, err := getCertBounds(secret, tlsCertificateChainSecretKey)
 	if err != nil {
 		// If we can't read the cert, then really all we can do is log something,
 		// since if we returned an error then the controller lib would just call us
@@ -87,6 +92,10 @@ func (c *certsExpirerController) Sync(ctx controllerlib.Context) error {
 	renewDelta := certAge - c.renewBefore
 	klog.Infof("certsExpirerController Sync found a renew delta of %s", renewDelta)
 	if renewDelta >= 0 || time.Now().After(notAfter) {
+		if _, nextNotAfter, err := getCertBounds(secret, nextTLSCertificateChainSecretKey); err == nil && time.Now().Before(nextNotAfter) {
+			return c.promoteNextCerts(ctx, secret)
+		}
+
 		err := c.k8sClient.
 			CoreV1().
 			Secrets(c.namespace).
@@ -101,12 +110,36 @@ func (c *certsExpirerController) Sync(ctx controllerlib.Context) error {
 	return nil
 }
 
+// promoteNextCerts replaces the current CA and serving certificate with the ones
+// which were staged by the certs manager controller. The CA bundle was already
+// extended with the next CA during the overlap period, so the current CA can be
+// dropped from it now.
+func (c *certsExpirerController) promoteNextCerts(ctx controllerlib.Context, secret *corev1.Secret) error {
+	updatedSecret := secret.DeepCopy()
+	updatedSecret.Data[caCertificateSecretKey] = secret.Data[nextCACertificateSecretKey]
+	updatedSecret.Data[tlsPrivateKeySecretKey] = secret.Data[nextTLSPrivateKeySecretKey]
+	updatedSecret.Data[tlsCertificateChainSecretKey] = secret.Data[nextTLSCertificateChainSecretKey]
+	delete(updatedSecret.Data, nextCACertificateSecretKey)
+	delete(updatedSecret.Data, nextTLSPrivateKeySecretKey)
+	delete(updatedSecret.Data, nextTLSCertificateChainSecretKey)
+
+	_, err := c.k8sClient.CoreV1().Secrets(c.namespace).Update(ctx.Context, updatedSecret, metav1.UpdateOptions{})
+	if err != nil {
+		// Do return an error here so that the controller library will reschedule
+		// us to try promoting these certs again.
+		return err
+	}
+
+	klog.Info("certsExpirerController Sync promoted the next certificates")
+	return nil
+}
+
 // getCertBounds returns the NotBefore and NotAfter fields of the TLS
-// certificate in the provided secret, or an error. Not that it expects the
-// provided secret to contain the well-known data keys from this package (see
-// certs_manager.go).
-func getCertBounds(secret *corev1.Secret) (time.Time, time.Time, error) {
-	certPEM := secret.Data[tlsCertificateChainSecretKey]
+// certificate under the given key of the provided secret, or an error. Note
+// that it expects the provided secret to contain the well-known data keys
+// from this package (see certs_manager.go).
+func getCertBounds(secret *corev1.Secret, key string) (time.Time, time.Time, error) {
+	certPEM := secret.Data[key]
 	if certPEM == nil {
 		return time.Time{}, time.Time{}, constable.Error("failed to find certificate")
 	}
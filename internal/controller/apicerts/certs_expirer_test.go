@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package apicerts
@@ -116,12 +116,18 @@ func TestExpirerControllerSync(t *testing.T) {
 
 	const certsSecretResourceName = "some-resource-name"
 
+	nextCertPEM, _, err := testutil.CreateCertificate(time.Now().Add(-1*time.Hour), time.Now().Add(10*time.Hour))
+	require.NoError(t, err)
+	expiredNextCertPEM, _, err := testutil.CreateCertificate(time.Now().Add(-2*time.Hour), time.Now().Add(-1*time.Hour))
+	require.NoError(t, err)
+
 	tests := []struct {
 		name                string
 		renewBefore         time.Duration
 		fillSecretData      func(*testing.T, map[string][]byte)
 		configKubeAPIClient func(*kubernetesfake.Clientset)
 		wantDelete          bool
+		wantUpdateData      map[string][]byte
 		wantError           string
 	}{
 		{
@@ -163,6 +169,67 @@ func TestExpirerControllerSync(t *testing.T) {
 			},
 			wantDelete: true,
 		},
+		{
+			name:        "lifetime above threshold with staged next certs",
+			renewBefore: 3 * time.Hour,
+			fillSecretData: func(t *testing.T, m map[string][]byte) {
+				certPEM, keyPEM, err := testutil.CreateCertificate(
+					time.Now().Add(-5*time.Hour),
+					time.Now().Add(5*time.Hour),
+				)
+				require.NoError(t, err)
+
+				// See certs_manager.go for these constants.
+				m["caCertificate"] = []byte("current-ca-and-next-ca")
+				m["tlsPrivateKey"] = keyPEM
+				m["tlsCertificateChain"] = certPEM
+				m["nextCACertificate"] = []byte("next-ca")
+				m["nextTLSPrivateKey"] = []byte("next-key")
+				m["nextTLSCertificateChain"] = nextCertPEM
+			},
+			wantUpdateData: map[string][]byte{
+				"caCertificate":       []byte("next-ca"),
+				"tlsPrivateKey":       []byte("next-key"),
+				"tlsCertificateChain": nextCertPEM,
+			},
+		},
+		{
+			name:        "lifetime above threshold with expired staged next certs",
+			renewBefore: 3 * time.Hour,
+			fillSecretData: func(t *testing.T, m map[string][]byte) {
+				certPEM, _, err := testutil.CreateCertificate(
+					time.Now().Add(-5*time.Hour),
+					time.Now().Add(5*time.Hour),
+				)
+				require.NoError(t, err)
+
+				// See certs_manager.go for these constants.
+				m["tlsCertificateChain"] = certPEM
+				m["nextTLSCertificateChain"] = expiredNextCertPEM
+			},
+			wantDelete: true,
+		},
+		{
+			name:        "promote failure",
+			renewBefore: 3 * time.Hour,
+			fillSecretData: func(t *testing.T, m map[string][]byte) {
+				certPEM, _, err := testutil.CreateCertificate(
+					time.Now().Add(-5*time.Hour),
+					time.Now().Add(5*time.Hour),
+				)
+				require.NoError(t, err)
+
+				// See certs_manager.go for these constants.
+				m["tlsCertificateChain"] = certPEM
+				m["nextTLSCertificateChain"] = nextCertPEM
+			},
+			configKubeAPIClient: func(c *kubernetesfake.Clientset) {
+				c.PrependReactor("update", "secrets", func(_ kubetesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("update failed: some update error")
+				})
+			},
+			wantError: "update failed: some update error",
+		},
 		{
 			name:        "cert expired",
 			renewBefore: 3 * time.Hour,
@@ -283,6 +350,26 @@ func TestExpirerControllerSync(t *testing.T) {
 					),
 				)
 			}
+			if test.wantUpdateData != nil {
+				exActions = append(
+					exActions,
+					kubetesting.NewUpdateAction(
+						schema.GroupVersionResource{
+							Group:    "",
+							Version:  "v1",
+							Resource: "secrets",
+						},
+						namespace,
+						&corev1.Secret{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      name,
+								Namespace: namespace,
+							},
+							Data: test.wantUpdateData,
+						},
+					),
+				)
+			}
 			acActions := kubeAPIClient.Actions()
 			require.Equal(t, exActions, acActions)
 		})
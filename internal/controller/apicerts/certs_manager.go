@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package apicerts
@@ -24,6 +24,12 @@ const (
 	caCertificateSecretKey       = "caCertificate"
 	tlsPrivateKeySecretKey       = "tlsPrivateKey"
 	tlsCertificateChainSecretKey = "tlsCertificateChain"
+
+	// The next CA and serving certificate are staged under these keys during the rotation overlap period, until
+	// the certs expirer controller promotes them.
+	nextCACertificateSecretKey       = "nextCACertificate"
+	nextTLSPrivateKeySecretKey       = "nextTLSPrivateKey"
+	nextTLSCertificateChainSecretKey = "nextTLSCertificateChain"
 )
 
 type certsManagerController struct {
@@ -37,6 +43,16 @@ type certsManagerController struct {
 	// certificate that this controller will use when issuing the certificates.
 	certDuration time.Duration
 
+	// renewBefore is the amount of time after the cert's issuance where the
+	// certs expirer controller will rotate it.
+	renewBefore time.Duration
+
+	// rotationOverlap is how long before the rotation this controller stages the
+	// next CA and publishes it alongside the current CA, so that clients which
+	// load the CA bundle trust the next serving certificate before it is used.
+	// Zero disables staging, in which case the certificates are simply replaced.
+	rotationOverlap time.Duration
+
 	generatedCACommonName                 string
 	serviceNameForGeneratedCertCommonName string
 }
@@ -50,8 +66,11 @@ func NewCertsManagerController(
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
 	withInitialEvent pinnipedcontroller.WithInitialEventOptionFunc,
 	certDuration time.Duration,
+	renewBefore time.Duration,
+	rotationOverlap time.Duration,
 	generatedCACommonName string,
 	serviceNameForGeneratedCertCommonName string,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -63,59 +82,46 @@ func NewCertsManagerController(
 				k8sClient:                             k8sClient,
 				secretInformer:                        secretInformer,
 				certDuration:                          certDuration,
+				renewBefore:                           renewBefore,
+				rotationOverlap:                       rotationOverlap,
 				generatedCACommonName:                 generatedCACommonName,
 				serviceNameForGeneratedCertCommonName: serviceNameForGeneratedCertCommonName,
 			},
 		},
-		withInformer(
-			secretInformer,
-			pinnipedcontroller.NameAndNamespaceExactMatchFilterFactory(certsSecretResourceName, namespace),
-			controllerlib.InformerOption{},
-		),
-		// Be sure to run once even if the Secret that the informer is watching doesn't exist.
-		withInitialEvent(controllerlib.Key{
-			Namespace: namespace,
-			Name:      certsSecretResourceName,
-		}),
+		append([]controllerlib.Option{
+			withInformer(
+				secretInformer,
+				pinnipedcontroller.NameAndNamespaceExactMatchFilterFactory(certsSecretResourceName, namespace),
+				controllerlib.InformerOption{},
+			),
+			// Be sure to run once even if the Secret that the informer is watching doesn't exist.
+			withInitialEvent(controllerlib.Key{
+				Namespace: namespace,
+				Name:      certsSecretResourceName,
+			}),
+		}, opts...)...,
 	)
 }
 
 func (c *certsManagerController) Sync(ctx controllerlib.Context) error {
 	// Try to get the secret from the informer cache.
-	_, err := c.secretInformer.Lister().Secrets(c.namespace).Get(c.certsSecretResourceName)
+	secret, err := c.secretInformer.Lister().Secrets(c.namespace).Get(c.certsSecretResourceName)
 	notFound := k8serrors.IsNotFound(err)
 	if err != nil && !notFound {
 		return fmt.Errorf("failed to get %s/%s secret: %w", c.namespace, c.certsSecretResourceName, err)
 	}
 	if !notFound {
-		// The secret already exists, so nothing to do.
-		return nil
-	}
-
-	// Create a CA.
-	aggregatedAPIServerCA, err := certauthority.New(pkix.Name{CommonName: c.generatedCACommonName}, c.certDuration)
-	if err != nil {
-		return fmt.Errorf("could not initialize CA: %w", err)
+		// The secret already exists, so the only thing left to do is to stage its rotation when it is due.
+		return c.maybeStageRotation(ctx, secret)
 	}
 
-	// Using the CA from above, create a TLS server cert for the aggregated API server to use.
-	serviceEndpoint := c.serviceNameForGeneratedCertCommonName + "." + c.namespace + ".svc"
-	aggregatedAPIServerTLSCert, err := aggregatedAPIServerCA.Issue(
-		pkix.Name{CommonName: serviceEndpoint},
-		[]string{serviceEndpoint},
-		nil,
-		c.certDuration,
-	)
+	caBundle, tlsCertChainPEM, tlsPrivateKeyPEM, err := c.issue()
 	if err != nil {
-		return fmt.Errorf("could not issue serving certificate: %w", err)
+		return err
 	}
 
 	// Write the CA's public key bundle and the serving certs to a secret.
-	tlsCertChainPEM, tlsPrivateKeyPEM, err := certauthority.ToPEM(aggregatedAPIServerTLSCert)
-	if err != nil {
-		return fmt.Errorf("could not PEM encode serving certificate: %w", err)
-	}
-	secret := corev1.Secret{
+	newSecret := corev1.Secret{
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      c.certsSecretResourceName,
@@ -123,12 +129,12 @@ func (c *certsManagerController) Sync(ctx controllerlib.Context) error {
 			Labels:    c.certsSecretLabels,
 		},
 		StringData: map[string]string{
-			caCertificateSecretKey:       string(aggregatedAPIServerCA.Bundle()),
+			caCertificateSecretKey:       string(caBundle),
 			tlsPrivateKeySecretKey:       string(tlsPrivateKeyPEM),
 			tlsCertificateChainSecretKey: string(tlsCertChainPEM),
 		},
 	}
-	_, err = c.k8sClient.CoreV1().Secrets(c.namespace).Create(ctx.Context, &secret, metav1.CreateOptions{})
+	_, err = c.k8sClient.CoreV1().Secrets(c.namespace).Create(ctx.Context, &newSecret, metav1.CreateOptions{})
 	if err != nil {
 		return fmt.Errorf("could not create secret: %w", err)
 	}
@@ -136,3 +142,64 @@ func (c *certsManagerController) Sync(ctx controllerlib.Context) error {
 	klog.Info("certsManagerController Sync successfully created secret")
 	return nil
 }
+
+// maybeStageRotation issues the next CA and serving certificate once the current serving certificate is within the
+// rotation overlap period of its rotation. The next CA is appended to the published CA bundle right away, while the
+// next serving certificate is only put to use by the certs expirer controller when the rotation is due.
+func (c *certsManagerController) maybeStageRotation(ctx controllerlib.Context, secret *corev1.Secret) error {
+	if c.rotationOverlap <= 0 || len(secret.Data[nextTLSCertificateChainSecretKey]) > 0 {
+		return nil
+	}
+
+	// The certs expirer controller replaces secrets that it cannot read, so leave them alone here.
+	notBefore, notAfter, boundsErr := getCertBounds(secret, tlsCertificateChainSecretKey)
+	if boundsErr != nil || time.Since(notBefore) < c.renewBefore-c.rotationOverlap || time.Now().After(notAfter) {
+		return nil
+	}
+
+	nextCABundle, nextTLSCertChainPEM, nextTLSPrivateKeyPEM, err := c.issue()
+	if err != nil {
+		return err
+	}
+
+	updatedSecret := secret.DeepCopy()
+	updatedSecret.Data[caCertificateSecretKey] = append(append([]byte{}, secret.Data[caCertificateSecretKey]...), nextCABundle...)
+	updatedSecret.Data[nextCACertificateSecretKey] = nextCABundle
+	updatedSecret.Data[nextTLSPrivateKeySecretKey] = nextTLSPrivateKeyPEM
+	updatedSecret.Data[nextTLSCertificateChainSecretKey] = nextTLSCertChainPEM
+	_, err = c.k8sClient.CoreV1().Secrets(c.namespace).Update(ctx.Context, updatedSecret, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("could not update secret: %w", err)
+	}
+
+	klog.Info("certsManagerController Sync successfully staged the rotation of the secret")
+	return nil
+}
+
+// issue creates a new CA and uses it to issue a serving certificate, returning the CA bundle and the
+// PEM-encoded serving certificate chain and private key.
+func (c *certsManagerController) issue() ([]byte, []byte, []byte, error) {
+	// Create a CA.
+	aggregatedAPIServerCA, err := certauthority.New(pkix.Name{CommonName: c.generatedCACommonName}, c.certDuration)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not initialize CA: %w", err)
+	}
+
+	// Using the CA from above, create a TLS server cert for the aggregated API server to use.
+	serviceEndpoint := c.serviceNameForGeneratedCertCommonName + "." + c.namespace + ".svc"
+	aggregatedAPIServerTLSCert, err := aggregatedAPIServerCA.Issue(
+		pkix.Name{CommonName: serviceEndpoint},
+		[]string{serviceEndpoint},
+		nil,
+		c.certDuration,
+	)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not issue serving certificate: %w", err)
+	}
+
+	tlsCertChainPEM, tlsPrivateKeyPEM, err := certauthority.ToPEM(aggregatedAPIServerTLSCert)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not PEM encode serving certificate: %w", err)
+	}
+	return aggregatedAPIServerCA.Bundle(), tlsCertChainPEM, tlsPrivateKeyPEM, nil
+}
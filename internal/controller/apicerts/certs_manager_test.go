@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package apicerts
@@ -48,6 +48,8 @@ func TestManagerControllerOptions(t *testing.T) {
 				observableWithInformerOption.WithInformer,
 				observableWithInitialEventOption.WithInitialEvent,
 				0,
+				0,
+				0,
 				"Pinniped CA",
 				"pinniped-api",
 			)
@@ -128,6 +130,7 @@ func TestManagerControllerSync(t *testing.T) {
 		var timeoutContext context.Context
 		var timeoutContextCancel context.CancelFunc
 		var syncContext *controllerlib.Context
+		var renewBefore, rotationOverlap time.Duration
 
 		// Defer starting the informers until the last possible moment so that the
 		// nested Before's can keep adding things to the informer caches.
@@ -145,6 +148,8 @@ func TestManagerControllerSync(t *testing.T) {
 				controllerlib.WithInformer,
 				controllerlib.WithInitialEvent,
 				certDuration,
+				renewBefore,
+				rotationOverlap,
 				"Pinniped CA",
 				"pinniped-api",
 			)
@@ -172,6 +177,7 @@ func TestManagerControllerSync(t *testing.T) {
 			kubeInformerClient = kubernetesfake.NewSimpleClientset()
 			kubeInformers = kubeinformers.NewSharedInformerFactory(kubeInformerClient, 0)
 			kubeAPIClient = kubernetesfake.NewSimpleClientset()
+			renewBefore, rotationOverlap = 0, 0
 		})
 
 		it.After(func() {
@@ -263,5 +269,121 @@ func TestManagerControllerSync(t *testing.T) {
 				r.Empty(kubeAPIClient.Actions())
 			})
 		})
+
+		when("there is a serving cert Secret and rotation overlap is enabled", func() {
+			var currentCACert []byte
+
+			var addSecret = func(notBefore, notAfter time.Time, data map[string][]byte) {
+				certPEM, keyPEM, err := testutil.CreateCertificate(notBefore, notAfter)
+				r.NoError(err)
+				currentCACert = certPEM
+				secret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      certsSecretResourceName,
+						Namespace: installedInNamespace,
+					},
+					Data: map[string][]byte{
+						"caCertificate":       certPEM,
+						"tlsPrivateKey":       keyPEM,
+						"tlsCertificateChain": certPEM,
+					},
+				}
+				for k, v := range data {
+					secret.Data[k] = v
+				}
+				r.NoError(kubeInformerClient.Tracker().Add(secret))
+				r.NoError(kubeAPIClient.Tracker().Add(secret))
+			}
+
+			it.Before(func() {
+				renewBefore = 10 * time.Hour
+				rotationOverlap = 2 * time.Hour
+			})
+
+			when("the serving cert is not yet within the overlap period", func() {
+				it.Before(func() {
+					addSecret(time.Now().Add(-7*time.Hour), time.Now().Add(5*time.Hour), nil)
+				})
+
+				it("does not need to make any API calls with its API client", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+					r.Empty(kubeAPIClient.Actions())
+				})
+			})
+
+			when("the serving cert is within the overlap period", func() {
+				it.Before(func() {
+					addSecret(time.Now().Add(-9*time.Hour), time.Now().Add(5*time.Hour), nil)
+				})
+
+				it("stages the next certs and publishes both CAs", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					r.Len(kubeAPIClient.Actions(), 1)
+					actualAction := kubeAPIClient.Actions()[0].(coretesting.UpdateActionImpl)
+					actualSecret := actualAction.GetObject().(*corev1.Secret)
+					nextCACert := string(actualSecret.Data["nextCACertificate"])
+					nextPrivateKey := string(actualSecret.Data["nextTLSPrivateKey"])
+					nextCertChain := string(actualSecret.Data["nextTLSCertificateChain"])
+					r.Equal(string(currentCACert)+nextCACert, string(actualSecret.Data["caCertificate"]))
+					r.Equal(currentCACert, actualSecret.Data["tlsCertificateChain"])
+
+					validCert := testutil.ValidateCertificate(t, nextCACert, nextCertChain)
+					validCert.RequireDNSName("pinniped-api." + installedInNamespace + ".svc")
+					validCert.RequireLifetime(time.Now(), time.Now().Add(certDuration), 6*time.Minute)
+					validCert.RequireMatchesPrivateKey(nextPrivateKey)
+				})
+
+				when("updating the Secret fails", func() {
+					it.Before(func() {
+						kubeAPIClient.PrependReactor(
+							"update",
+							"secrets",
+							func(_ coretesting.Action) (bool, runtime.Object, error) {
+								return true, nil, errors.New("update failed")
+							},
+						)
+					})
+
+					it("returns the update error", func() {
+						startInformersAndController()
+						err := controllerlib.TestSync(t, subject, *syncContext)
+						r.EqualError(err, "could not update secret: update failed")
+					})
+				})
+			})
+
+			when("the next certs were already staged", func() {
+				it.Before(func() {
+					addSecret(time.Now().Add(-9*time.Hour), time.Now().Add(5*time.Hour), map[string][]byte{
+						"nextTLSCertificateChain": []byte("some-next-cert"),
+					})
+				})
+
+				it("does not need to make any API calls with its API client", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+					r.Empty(kubeAPIClient.Actions())
+				})
+			})
+
+			when("the serving cert has already expired", func() {
+				it.Before(func() {
+					addSecret(time.Now().Add(-20*time.Hour), time.Now().Add(-1*time.Hour), nil)
+				})
+
+				it("leaves the rotation to the certs expirer controller", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+					r.Empty(kubeAPIClient.Actions())
+				})
+			})
+		})
 	}, spec.Parallel(), spec.Report(report.Terminal{}))
 }
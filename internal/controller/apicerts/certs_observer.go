@@ -28,6 +28,7 @@ func NewCertsObserverController(
 	dynamicCertProvider dynamiccert.Provider,
 	secretInformer corev1informers.SecretInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -39,11 +40,13 @@ func NewCertsObserverController(
 				secretInformer:          secretInformer,
 			},
 		},
-		withInformer(
-			secretInformer,
-			pinnipedcontroller.NameAndNamespaceExactMatchFilterFactory(certsSecretResourceName, namespace),
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			withInformer(
+				secretInformer,
+				pinnipedcontroller.NameAndNamespaceExactMatchFilterFactory(certsSecretResourceName, namespace),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
@@ -0,0 +1,81 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package apicerts
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/dynamiccert"
+	"go.pinniped.dev/internal/plog"
+)
+
+type tlsSecretObserverController struct {
+	namespace           string
+	tlsSecretName       string
+	dynamicCertProvider dynamiccert.Provider
+	secretInformer      corev1informers.SecretInformer
+}
+
+// NewTLSSecretObserverController returns a controller which serves the certificate and private key of a Secret of
+// type kubernetes.io/tls which was created by someone else, e.g. by cert-manager, through the dynamicCertProvider.
+// Unlike NewCertsObserverController, it keeps serving the previous certificate when the Secret is changed to an
+// invalid one, so that a bad rotation does not take down the server.
+func NewTLSSecretObserverController(
+	namespace string,
+	tlsSecretName string,
+	dynamicCertProvider dynamiccert.Provider,
+	secretInformer corev1informers.SecretInformer,
+	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "tls-secret-observer-controller",
+			Syncer: &tlsSecretObserverController{
+				namespace:           namespace,
+				tlsSecretName:       tlsSecretName,
+				dynamicCertProvider: dynamicCertProvider,
+				secretInformer:      secretInformer,
+			},
+		},
+		append([]controllerlib.Option{
+			withInformer(
+				secretInformer,
+				pinnipedcontroller.NameAndNamespaceExactMatchFilterFactory(tlsSecretName, namespace),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
+	)
+}
+
+func (c *tlsSecretObserverController) Sync(_ controllerlib.Context) error {
+	secret, err := c.secretInformer.Lister().Secrets(c.namespace).Get(c.tlsSecretName)
+	notFound := k8serrors.IsNotFound(err)
+	if err != nil && !notFound {
+		return fmt.Errorf("failed to get %s/%s secret: %w", c.namespace, c.tlsSecretName, err)
+	}
+	if notFound {
+		plog.Info("tlsSecretObserverController Sync found that the secret does not exist yet or was deleted",
+			"namespace", c.namespace, "name", c.tlsSecretName)
+		c.dynamicCertProvider.Set(nil, nil)
+		return nil
+	}
+
+	certPEM, keyPEM := secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return fmt.Errorf("invalid certificate or private key in %s/%s secret: %w", c.namespace, c.tlsSecretName, err)
+	}
+
+	c.dynamicCertProvider.Set(certPEM, keyPEM)
+	plog.Info("tlsSecretObserverController Sync updated certs in the dynamic cert provider",
+		"namespace", c.namespace, "name", c.tlsSecretName, "resourceVersion", secret.ResourceVersion)
+	return nil
+}
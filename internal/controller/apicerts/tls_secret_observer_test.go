@@ -0,0 +1,133 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package apicerts
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+
+	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/dynamiccert"
+	"go.pinniped.dev/internal/testutil"
+)
+
+func TestTLSSecretObserverControllerInformerFilters(t *testing.T) {
+	observableWithInformerOption := testutil.NewObservableWithInformerOption()
+	secretsInformer := kubeinformers.NewSharedInformerFactory(nil, 0).Core().V1().Secrets()
+	_ = NewTLSSecretObserverController(
+		"some-namespace",
+		"some-tls-secret",
+		nil,
+		secretsInformer,
+		observableWithInformerOption.WithInformer,
+	)
+	filter := observableWithInformerOption.GetFilterForInformer(secretsInformer)
+
+	target := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "some-tls-secret", Namespace: "some-namespace"}}
+	wrongNamespace := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "some-tls-secret", Namespace: "wrong-namespace"}}
+	wrongName := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "wrong-name", Namespace: "some-namespace"}}
+
+	require.True(t, filter.Add(target))
+	require.True(t, filter.Update(wrongName, target))
+	require.True(t, filter.Delete(target))
+	require.False(t, filter.Add(wrongNamespace))
+	require.False(t, filter.Add(wrongName))
+	require.False(t, filter.Update(wrongName, wrongNamespace))
+}
+
+func TestTLSSecretObserverControllerSync(t *testing.T) {
+	ca, err := certauthority.New(pkix.Name{CommonName: "some-ca"}, time.Hour)
+	require.NoError(t, err)
+	certPEM, keyPEM, err := ca.IssuePEM(pkix.Name{CommonName: "some-server"}, []string{"some-server.example.com"}, time.Hour)
+	require.NoError(t, err)
+
+	tlsSecret := func(certPEM, keyPEM []byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "some-tls-secret", Namespace: "some-namespace"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: certPEM, corev1.TLSPrivateKeyKey: keyPEM},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		secret   *corev1.Secret
+		wantErr  string
+		wantCert []byte
+		wantKey  []byte
+	}{
+		{
+			name: "no secret",
+		},
+		{
+			name:     "valid secret",
+			secret:   tlsSecret(certPEM, keyPEM),
+			wantCert: certPEM,
+			wantKey:  keyPEM,
+		},
+		{
+			name:     "invalid certificate keeps the previous certificate",
+			secret:   tlsSecret([]byte("not a certificate"), keyPEM),
+			wantErr:  "invalid certificate or private key in some-namespace/some-tls-secret secret: tls: failed to find any PEM data in certificate input",
+			wantCert: []byte("previous cert"),
+			wantKey:  []byte("previous key"),
+		},
+		{
+			name:     "missing private key keeps the previous certificate",
+			secret:   tlsSecret(certPEM, nil),
+			wantErr:  "invalid certificate or private key in some-namespace/some-tls-secret secret: tls: failed to find any PEM data in key input",
+			wantCert: []byte("previous cert"),
+			wantKey:  []byte("previous key"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			kubeInformerClient := kubernetesfake.NewSimpleClientset()
+			if tt.secret != nil {
+				require.NoError(t, kubeInformerClient.Tracker().Add(tt.secret))
+			}
+			kubeInformers := kubeinformers.NewSharedInformerFactory(kubeInformerClient, 0)
+			dynamicCertProvider := dynamiccert.New()
+			dynamicCertProvider.Set([]byte("previous cert"), []byte("previous key"))
+
+			subject := NewTLSSecretObserverController(
+				"some-namespace",
+				"some-tls-secret",
+				dynamicCertProvider,
+				kubeInformers.Core().V1().Secrets(),
+				controllerlib.WithInformer,
+			)
+			kubeInformers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, subject)
+
+			err := controllerlib.TestSync(t, subject, controllerlib.Context{
+				Context: ctx,
+				Name:    subject.Name(),
+				Key:     controllerlib.Key{Namespace: "some-namespace", Name: "some-tls-secret"},
+			})
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			gotCert, gotKey := dynamicCertProvider.CurrentCertKeyContent()
+			require.Equal(t, tt.wantCert, gotCert)
+			require.Equal(t, tt.wantKey, gotKey)
+		})
+	}
+}
@@ -5,7 +5,9 @@
 package authenticator
 
 import (
+	"crypto/x509"
 	"encoding/base64"
+	"errors"
 
 	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
 )
@@ -27,3 +29,17 @@ func CABundle(spec *auth1alpha1.TLSSpec) ([]byte, error) {
 	}
 	return base64.StdEncoding.DecodeString(spec.CertificateAuthorityData)
 }
+
+// CertPool returns a pool of the certificates in the provided PEM-encoded CA bundle. If the bundle is empty, a nil
+// pool will be returned, which means that the system's trusted CAs should be used. If the bundle is not empty but
+// does not contain any certificates, an error will be returned.
+func CertPool(caBundle []byte) (*x509.CertPool, error) {
+	if len(caBundle) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBundle) {
+		return nil, errors.New("CA bundle does not contain any PEM-encoded certificates")
+	}
+	return pool, nil
+}
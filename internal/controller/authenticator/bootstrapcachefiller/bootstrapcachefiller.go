@@ -0,0 +1,93 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package bootstrapcachefiller implements a controller for filling an authncache.Cache with each added
+// BootstrapCredential.
+package bootstrapcachefiller
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/klog/v2"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
+	authinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions/authentication/v1alpha1"
+	"go.pinniped.dev/internal/bootstrapcredential"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controllerlib"
+)
+
+// New instantiates a new controllerlib.Controller which will populate the provided authncache.Cache.
+func New(
+	cache *authncache.Cache,
+	client pinnipedclientset.Interface,
+	bootstrapCredentials authinformers.BootstrapCredentialInformer,
+	clock clock.PassiveClock,
+	log logr.Logger,
+	opts ...controllerlib.Option,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "bootstrapcachefiller-controller",
+			Syncer: &controller{
+				cache:                cache,
+				client:               client,
+				bootstrapCredentials: bootstrapCredentials,
+				clock:                clock,
+				log:                  log.WithName("bootstrapcachefiller-controller"),
+			},
+		},
+		append([]controllerlib.Option{
+			controllerlib.WithInformer(
+				bootstrapCredentials,
+				pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
+	)
+}
+
+type controller struct {
+	cache                *authncache.Cache
+	client               pinnipedclientset.Interface
+	bootstrapCredentials authinformers.BootstrapCredentialInformer
+	clock                clock.PassiveClock
+	log                  logr.Logger
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *controller) Sync(ctx controllerlib.Context) error {
+	obj, err := c.bootstrapCredentials.Lister().Get(ctx.Key.Name)
+	if err != nil && errors.IsNotFound(err) {
+		c.log.Info("Sync() found that the BootstrapCredential does not exist yet or was deleted")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get BootstrapCredential %s/%s: %w", ctx.Key.Namespace, ctx.Key.Name, err)
+	}
+
+	cacheKey := authncache.Key{
+		APIGroup: auth1alpha1.GroupName,
+		Kind:     "BootstrapCredential",
+		Name:     ctx.Key.Name,
+	}
+
+	// The authenticator always reads the latest version of the BootstrapCredential when it is used, so there is
+	// never any need to replace an existing authenticator when the object is updated.
+	if c.cache.Get(cacheKey) != nil {
+		return nil
+	}
+
+	c.cache.Store(cacheKey, bootstrapcredential.New(
+		obj.Name,
+		c.client.AuthenticationV1alpha1().BootstrapCredentials(),
+		c.clock,
+	))
+	c.log.WithValues("bootstrapCredential", klog.KObj(obj), "username", obj.Spec.Username).Info("added new bootstrap credential authenticator")
+	return nil
+}
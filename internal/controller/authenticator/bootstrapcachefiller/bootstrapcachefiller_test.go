@@ -0,0 +1,106 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bootstrapcachefiller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	pinnipedinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
+	"go.pinniped.dev/internal/bootstrapcredential"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/testutil/testlogger"
+)
+
+func TestController(t *testing.T) {
+	t.Parallel()
+
+	credential := &auth1alpha1.BootstrapCredential{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+		Spec: auth1alpha1.BootstrapCredentialSpec{
+			TokenHash: "0000000000000000000000000000000000000000000000000000000000000000",
+			Username:  "test-username",
+		},
+	}
+
+	tests := []struct {
+		name             string
+		syncKey          controllerlib.Key
+		credentials      []runtime.Object
+		preexisting      bool
+		wantErr          string
+		wantLogs         []string
+		wantCacheEntries int
+	}{
+		{
+			name:    "not found",
+			syncKey: controllerlib.Key{Name: "test-name"},
+			wantLogs: []string{
+				`bootstrapcachefiller-controller "level"=0 "msg"="Sync() found that the BootstrapCredential does not exist yet or was deleted"`,
+			},
+		},
+		{
+			name:        "valid credential",
+			syncKey:     controllerlib.Key{Name: "test-name"},
+			credentials: []runtime.Object{credential},
+			wantLogs: []string{
+				`bootstrapcachefiller-controller "level"=0 "msg"="added new bootstrap credential authenticator" "bootstrapCredential"={"name":"test-name"} "username"="test-username"`,
+			},
+			wantCacheEntries: 1,
+		},
+		{
+			name:             "credential already in cache",
+			syncKey:          controllerlib.Key{Name: "test-name"},
+			credentials:      []runtime.Object{credential},
+			preexisting:      true,
+			wantCacheEntries: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeClient := pinnipedfake.NewSimpleClientset(tt.credentials...)
+			informers := pinnipedinformers.NewSharedInformerFactory(fakeClient, 0)
+			cache := authncache.New()
+			testLog := testlogger.New(t)
+
+			if tt.preexisting {
+				cache.Store(authncache.Key{
+					APIGroup: auth1alpha1.GroupName,
+					Kind:     "BootstrapCredential",
+					Name:     "test-name",
+				}, bootstrapcredential.New("test-name", nil, nil))
+			}
+
+			controller := New(cache, fakeClient, informers.Authentication().V1alpha1().BootstrapCredentials(), clock.NewFakeClock(time.Now()), testLog)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			informers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			syncCtx := controllerlib.Context{Context: ctx, Key: tt.syncKey}
+
+			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantLogs, testLog.Lines())
+			require.Equal(t, tt.wantCacheEntries, len(cache.Keys()))
+		})
+	}
+}
@@ -24,36 +24,79 @@ func New(
 	cache *authncache.Cache,
 	webhooks authinformers.WebhookAuthenticatorInformer,
 	jwtAuthenticators authinformers.JWTAuthenticatorInformer,
+	cloudIdentityAuthenticators authinformers.CloudIdentityAuthenticatorInformer,
+	bootstrapCredentials authinformers.BootstrapCredentialInformer,
+	staticTokenAuthenticators authinformers.StaticTokenAuthenticatorInformer,
+	clientCertificateAuthenticators authinformers.ClientCertificateAuthenticatorInformer,
+	serviceAccountAuthenticators authinformers.ServiceAccountAuthenticatorInformer,
 	log logr.Logger,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
 			Name: "cachecleaner-controller",
 			Syncer: &controller{
-				cache:             cache,
-				webhooks:          webhooks,
-				jwtAuthenticators: jwtAuthenticators,
-				log:               log.WithName("cachecleaner-controller"),
+				cache:                           cache,
+				webhooks:                        webhooks,
+				jwtAuthenticators:               jwtAuthenticators,
+				cloudIdentityAuthenticators:     cloudIdentityAuthenticators,
+				bootstrapCredentials:            bootstrapCredentials,
+				staticTokenAuthenticators:       staticTokenAuthenticators,
+				clientCertificateAuthenticators: clientCertificateAuthenticators,
+				serviceAccountAuthenticators:    serviceAccountAuthenticators,
+				log:                             log.WithName("cachecleaner-controller"),
 			},
 		},
-		controllerlib.WithInformer(
-			webhooks,
-			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
-			controllerlib.InformerOption{},
-		),
-		controllerlib.WithInformer(
-			jwtAuthenticators,
-			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			controllerlib.WithInformer(
+				webhooks,
+				pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+				controllerlib.InformerOption{},
+			),
+			controllerlib.WithInformer(
+				jwtAuthenticators,
+				pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+				controllerlib.InformerOption{},
+			),
+			controllerlib.WithInformer(
+				cloudIdentityAuthenticators,
+				pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+				controllerlib.InformerOption{},
+			),
+			controllerlib.WithInformer(
+				bootstrapCredentials,
+				pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+				controllerlib.InformerOption{},
+			),
+			controllerlib.WithInformer(
+				staticTokenAuthenticators,
+				pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+				controllerlib.InformerOption{},
+			),
+			controllerlib.WithInformer(
+				clientCertificateAuthenticators,
+				pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+				controllerlib.InformerOption{},
+			),
+			controllerlib.WithInformer(
+				serviceAccountAuthenticators,
+				pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
 type controller struct {
-	cache             *authncache.Cache
-	webhooks          authinformers.WebhookAuthenticatorInformer
-	jwtAuthenticators authinformers.JWTAuthenticatorInformer
-	log               logr.Logger
+	cache                           *authncache.Cache
+	webhooks                        authinformers.WebhookAuthenticatorInformer
+	jwtAuthenticators               authinformers.JWTAuthenticatorInformer
+	cloudIdentityAuthenticators     authinformers.CloudIdentityAuthenticatorInformer
+	bootstrapCredentials            authinformers.BootstrapCredentialInformer
+	staticTokenAuthenticators       authinformers.StaticTokenAuthenticatorInformer
+	clientCertificateAuthenticators authinformers.ClientCertificateAuthenticatorInformer
+	serviceAccountAuthenticators    authinformers.ServiceAccountAuthenticatorInformer
+	log                             logr.Logger
 }
 
 // Sync implements controllerlib.Syncer.
@@ -68,6 +111,31 @@ func (c *controller) Sync(_ controllerlib.Context) error {
 		return fmt.Errorf("failed to list JWTAuthenticators: %w", err)
 	}
 
+	cloudIdentityAuthenticators, err := c.cloudIdentityAuthenticators.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list CloudIdentityAuthenticators: %w", err)
+	}
+
+	bootstrapCredentials, err := c.bootstrapCredentials.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list BootstrapCredentials: %w", err)
+	}
+
+	staticTokenAuthenticators, err := c.staticTokenAuthenticators.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list StaticTokenAuthenticators: %w", err)
+	}
+
+	clientCertificateAuthenticators, err := c.clientCertificateAuthenticators.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list ClientCertificateAuthenticators: %w", err)
+	}
+
+	serviceAccountAuthenticators, err := c.serviceAccountAuthenticators.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list ServiceAccountAuthenticators: %w", err)
+	}
+
 	// Index the current authenticators by cache key.
 	authenticatorSet := map[authncache.Key]bool{}
 	for _, webhook := range webhooks {
@@ -86,10 +154,50 @@ func (c *controller) Sync(_ controllerlib.Context) error {
 		}
 		authenticatorSet[key] = true
 	}
+	for _, cloudIdentityAuthenticator := range cloudIdentityAuthenticators {
+		key := authncache.Key{
+			Name:     cloudIdentityAuthenticator.Name,
+			Kind:     "CloudIdentityAuthenticator",
+			APIGroup: auth1alpha1.SchemeGroupVersion.Group,
+		}
+		authenticatorSet[key] = true
+	}
+	for _, bootstrapCredential := range bootstrapCredentials {
+		key := authncache.Key{
+			Name:     bootstrapCredential.Name,
+			Kind:     "BootstrapCredential",
+			APIGroup: auth1alpha1.SchemeGroupVersion.Group,
+		}
+		authenticatorSet[key] = true
+	}
+	for _, staticTokenAuthenticator := range staticTokenAuthenticators {
+		key := authncache.Key{
+			Name:     staticTokenAuthenticator.Name,
+			Kind:     "StaticTokenAuthenticator",
+			APIGroup: auth1alpha1.SchemeGroupVersion.Group,
+		}
+		authenticatorSet[key] = true
+	}
+	for _, clientCertificateAuthenticator := range clientCertificateAuthenticators {
+		key := authncache.Key{
+			Name:     clientCertificateAuthenticator.Name,
+			Kind:     "ClientCertificateAuthenticator",
+			APIGroup: auth1alpha1.SchemeGroupVersion.Group,
+		}
+		authenticatorSet[key] = true
+	}
+	for _, serviceAccountAuthenticator := range serviceAccountAuthenticators {
+		key := authncache.Key{
+			Name:     serviceAccountAuthenticator.Name,
+			Kind:     "ServiceAccountAuthenticator",
+			APIGroup: auth1alpha1.SchemeGroupVersion.Group,
+		}
+		authenticatorSet[key] = true
+	}
 
 	// Delete any entries from the cache which are no longer in the cluster.
 	for _, key := range c.cache.Keys() {
-		if key.APIGroup != auth1alpha1.SchemeGroupVersion.Group || (key.Kind != "WebhookAuthenticator" && key.Kind != "JWTAuthenticator") {
+		if key.APIGroup != auth1alpha1.SchemeGroupVersion.Group || !isKnownKind(key.Kind) {
 			continue
 		}
 		if _, exists := authenticatorSet[key]; !exists {
@@ -110,3 +218,11 @@ func (c *controller) Sync(_ controllerlib.Context) error {
 	}
 	return nil
 }
+
+func isKnownKind(kind string) bool {
+	switch kind {
+	case "WebhookAuthenticator", "JWTAuthenticator", "CloudIdentityAuthenticator", "BootstrapCredential", "StaticTokenAuthenticator", "ClientCertificateAuthenticator", "ServiceAccountAuthenticator":
+		return true
+	}
+	return false
+}
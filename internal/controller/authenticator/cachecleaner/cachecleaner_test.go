@@ -45,6 +45,56 @@ func TestController(t *testing.T) {
 		Kind:     "JWTAuthenticator",
 		Name:     "test-jwt-authenticator-name-two",
 	}
+	testCloudIdentityAuthenticatorKey1 := authncache.Key{
+		APIGroup: "authentication.concierge.pinniped.dev",
+		Kind:     "CloudIdentityAuthenticator",
+		Name:     "test-cloud-identity-authenticator-name-one",
+	}
+	testCloudIdentityAuthenticatorKey2 := authncache.Key{
+		APIGroup: "authentication.concierge.pinniped.dev",
+		Kind:     "CloudIdentityAuthenticator",
+		Name:     "test-cloud-identity-authenticator-name-two",
+	}
+	testBootstrapCredentialKey1 := authncache.Key{
+		APIGroup: "authentication.concierge.pinniped.dev",
+		Kind:     "BootstrapCredential",
+		Name:     "test-bootstrap-credential-name-one",
+	}
+	testBootstrapCredentialKey2 := authncache.Key{
+		APIGroup: "authentication.concierge.pinniped.dev",
+		Kind:     "BootstrapCredential",
+		Name:     "test-bootstrap-credential-name-two",
+	}
+	testStaticTokenAuthenticatorKey1 := authncache.Key{
+		APIGroup: "authentication.concierge.pinniped.dev",
+		Kind:     "StaticTokenAuthenticator",
+		Name:     "test-static-token-authenticator-name-one",
+	}
+	testStaticTokenAuthenticatorKey2 := authncache.Key{
+		APIGroup: "authentication.concierge.pinniped.dev",
+		Kind:     "StaticTokenAuthenticator",
+		Name:     "test-static-token-authenticator-name-two",
+	}
+	testClientCertificateAuthenticatorKey1 := authncache.Key{
+		APIGroup: "authentication.concierge.pinniped.dev",
+		Kind:     "ClientCertificateAuthenticator",
+		Name:     "test-client-certificate-authenticator-name-one",
+	}
+	testClientCertificateAuthenticatorKey2 := authncache.Key{
+		APIGroup: "authentication.concierge.pinniped.dev",
+		Kind:     "ClientCertificateAuthenticator",
+		Name:     "test-client-certificate-authenticator-name-two",
+	}
+	testServiceAccountAuthenticatorKey1 := authncache.Key{
+		APIGroup: "authentication.concierge.pinniped.dev",
+		Kind:     "ServiceAccountAuthenticator",
+		Name:     "test-service-account-authenticator-name-one",
+	}
+	testServiceAccountAuthenticatorKey2 := authncache.Key{
+		APIGroup: "authentication.concierge.pinniped.dev",
+		Kind:     "ServiceAccountAuthenticator",
+		Name:     "test-service-account-authenticator-name-two",
+	}
 	testKeyUnknownType := authncache.Key{
 		APIGroup: "authentication.concierge.pinniped.dev",
 		Kind:     "SomeOtherAuthenticator",
@@ -112,6 +162,16 @@ func TestController(t *testing.T) {
 				cache.Store(testWebhookKey2, nil)
 				cache.Store(testJWTAuthenticatorKey1, newClosableCacheValue(t, 0))
 				cache.Store(testJWTAuthenticatorKey2, newClosableCacheValue(t, 1))
+				cache.Store(testCloudIdentityAuthenticatorKey1, newClosableCacheValue(t, 0))
+				cache.Store(testCloudIdentityAuthenticatorKey2, newClosableCacheValue(t, 1))
+				cache.Store(testBootstrapCredentialKey1, nil)
+				cache.Store(testBootstrapCredentialKey2, nil)
+				cache.Store(testStaticTokenAuthenticatorKey1, nil)
+				cache.Store(testStaticTokenAuthenticatorKey2, nil)
+				cache.Store(testClientCertificateAuthenticatorKey1, nil)
+				cache.Store(testClientCertificateAuthenticatorKey2, nil)
+				cache.Store(testServiceAccountAuthenticatorKey1, nil)
+				cache.Store(testServiceAccountAuthenticatorKey2, nil)
 				cache.Store(testKeyUnknownType, nil)
 			},
 			objects: []runtime.Object{
@@ -125,12 +185,42 @@ func TestController(t *testing.T) {
 						Name: testJWTAuthenticatorKey1.Name,
 					},
 				},
+				&authv1alpha.CloudIdentityAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: testCloudIdentityAuthenticatorKey1.Name,
+					},
+				},
+				&authv1alpha.BootstrapCredential{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: testBootstrapCredentialKey1.Name,
+					},
+				},
+				&authv1alpha.StaticTokenAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: testStaticTokenAuthenticatorKey1.Name,
+					},
+				},
+				&authv1alpha.ClientCertificateAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: testClientCertificateAuthenticatorKey1.Name,
+					},
+				},
+				&authv1alpha.ServiceAccountAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: testServiceAccountAuthenticatorKey1.Name,
+					},
+				},
 			},
 			wantLogs: []string{
 				`cachecleaner-controller "level"=0 "msg"="deleting authenticator from cache" "authenticator"={"name":"test-jwt-authenticator-name-two"} "kind"="JWTAuthenticator"`,
 				`cachecleaner-controller "level"=0 "msg"="deleting authenticator from cache" "authenticator"={"name":"test-webhook-name-two"} "kind"="WebhookAuthenticator"`,
+				`cachecleaner-controller "level"=0 "msg"="deleting authenticator from cache" "authenticator"={"name":"test-cloud-identity-authenticator-name-two"} "kind"="CloudIdentityAuthenticator"`,
+				`cachecleaner-controller "level"=0 "msg"="deleting authenticator from cache" "authenticator"={"name":"test-bootstrap-credential-name-two"} "kind"="BootstrapCredential"`,
+				`cachecleaner-controller "level"=0 "msg"="deleting authenticator from cache" "authenticator"={"name":"test-static-token-authenticator-name-two"} "kind"="StaticTokenAuthenticator"`,
+				`cachecleaner-controller "level"=0 "msg"="deleting authenticator from cache" "authenticator"={"name":"test-client-certificate-authenticator-name-two"} "kind"="ClientCertificateAuthenticator"`,
+				`cachecleaner-controller "level"=0 "msg"="deleting authenticator from cache" "authenticator"={"name":"test-service-account-authenticator-name-two"} "kind"="ServiceAccountAuthenticator"`,
 			},
-			wantCacheKeys: []authncache.Key{testWebhookKey1, testJWTAuthenticatorKey1, testKeyUnknownType},
+			wantCacheKeys: []authncache.Key{testWebhookKey1, testJWTAuthenticatorKey1, testCloudIdentityAuthenticatorKey1, testBootstrapCredentialKey1, testStaticTokenAuthenticatorKey1, testClientCertificateAuthenticatorKey1, testServiceAccountAuthenticatorKey1, testKeyUnknownType},
 		},
 	}
 	for _, tt := range tests {
@@ -148,7 +238,12 @@ func TestController(t *testing.T) {
 
 			webhooks := informers.Authentication().V1alpha1().WebhookAuthenticators()
 			jwtAuthenticators := informers.Authentication().V1alpha1().JWTAuthenticators()
-			controller := New(cache, webhooks, jwtAuthenticators, testLog)
+			cloudIdentityAuthenticators := informers.Authentication().V1alpha1().CloudIdentityAuthenticators()
+			bootstrapCredentials := informers.Authentication().V1alpha1().BootstrapCredentials()
+			staticTokenAuthenticators := informers.Authentication().V1alpha1().StaticTokenAuthenticators()
+			clientCertificateAuthenticators := informers.Authentication().V1alpha1().ClientCertificateAuthenticators()
+			serviceAccountAuthenticators := informers.Authentication().V1alpha1().ServiceAccountAuthenticators()
+			controller := New(cache, webhooks, jwtAuthenticators, cloudIdentityAuthenticators, bootstrapCredentials, staticTokenAuthenticators, clientCertificateAuthenticators, serviceAccountAuthenticators, testLog)
 
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			defer cancel()
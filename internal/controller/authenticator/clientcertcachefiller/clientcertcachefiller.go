@@ -0,0 +1,101 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package clientcertcachefiller implements a controller for filling an authncache.Cache with each
+// added/updated ClientCertificateAuthenticator.
+package clientcertcachefiller
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/klog/v2"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	authinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions/authentication/v1alpha1"
+	"go.pinniped.dev/internal/clientcert"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controllerlib"
+)
+
+type clientCertificateAuthenticator struct {
+	*clientcert.Authenticator
+	spec *auth1alpha1.ClientCertificateAuthenticatorSpec
+}
+
+// New instantiates a new controllerlib.Controller which will populate the provided authncache.Cache.
+func New(
+	cache *authncache.Cache,
+	clientCertificateAuthenticators authinformers.ClientCertificateAuthenticatorInformer,
+	clock clock.PassiveClock,
+	log logr.Logger,
+	opts ...controllerlib.Option,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "clientcertcachefiller-controller",
+			Syncer: &controller{
+				cache:                           cache,
+				clientCertificateAuthenticators: clientCertificateAuthenticators,
+				clock:                           clock,
+				log:                             log.WithName("clientcertcachefiller-controller"),
+			},
+		},
+		append([]controllerlib.Option{
+			controllerlib.WithInformer(
+				clientCertificateAuthenticators,
+				pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
+	)
+}
+
+type controller struct {
+	cache                           *authncache.Cache
+	clientCertificateAuthenticators authinformers.ClientCertificateAuthenticatorInformer
+	clock                           clock.PassiveClock
+	log                             logr.Logger
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *controller) Sync(ctx controllerlib.Context) error {
+	obj, err := c.clientCertificateAuthenticators.Lister().Get(ctx.Key.Name)
+	if err != nil && errors.IsNotFound(err) {
+		c.log.Info("Sync() found that the ClientCertificateAuthenticator does not exist yet or was deleted")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get ClientCertificateAuthenticator %s/%s: %w", ctx.Key.Namespace, ctx.Key.Name, err)
+	}
+
+	cacheKey := authncache.Key{
+		APIGroup: auth1alpha1.GroupName,
+		Kind:     "ClientCertificateAuthenticator",
+		Name:     ctx.Key.Name,
+	}
+
+	// Only recreate the authenticator if its spec has changed.
+	if existing, ok := c.cache.Get(cacheKey).(*clientCertificateAuthenticator); ok && reflect.DeepEqual(existing.spec, &obj.Spec) {
+		c.log.WithValues("clientCertificateAuthenticator", klog.KObj(obj)).Info("actual client certificate authenticator and desired client certificate authenticator are the same")
+		return nil
+	}
+
+	// Make a deep copy of the spec so we aren't storing pointers to something that the informer cache
+	// may mutate!
+	spec := obj.Spec.DeepCopy()
+	authenticator, err := clientcert.New(spec, c.clock)
+	if err != nil {
+		// Stop trusting the previous CAs, since the spec which configured them has changed.
+		c.cache.Delete(cacheKey)
+		return fmt.Errorf("failed to build client certificate authenticator: %w", err)
+	}
+
+	c.cache.Store(cacheKey, &clientCertificateAuthenticator{Authenticator: authenticator, spec: spec})
+	c.log.WithValues("clientCertificateAuthenticator", klog.KObj(obj), "audience", spec.Audience).Info("added new client certificate authenticator")
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package clientcertcachefiller
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	pinnipedinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
+	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/clientcert"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/testutil/testlogger"
+)
+
+func TestController(t *testing.T) {
+	t.Parallel()
+
+	ca, err := certauthority.New(pkix.Name{CommonName: "test-ca"}, time.Hour)
+	require.NoError(t, err)
+	someSpec := auth1alpha1.ClientCertificateAuthenticatorSpec{
+		CertificateAuthorityData: base64.StdEncoding.EncodeToString(ca.Bundle()),
+		Audience:                 "some-audience",
+	}
+	otherSpec := auth1alpha1.ClientCertificateAuthenticatorSpec{
+		CertificateAuthorityData: base64.StdEncoding.EncodeToString(ca.Bundle()),
+		Audience:                 "some-other-audience",
+	}
+	cacheKey := authncache.Key{
+		APIGroup: auth1alpha1.SchemeGroupVersion.Group,
+		Kind:     "ClientCertificateAuthenticator",
+		Name:     "test-name",
+	}
+	newCacheValue := func(t *testing.T, spec auth1alpha1.ClientCertificateAuthenticatorSpec) *clientCertificateAuthenticator {
+		t.Helper()
+		a, err := clientcert.New(&spec, clock.RealClock{})
+		require.NoError(t, err)
+		return &clientCertificateAuthenticator{Authenticator: a, spec: &spec}
+	}
+
+	tests := []struct {
+		name                 string
+		authenticators       []runtime.Object
+		cache                func(*testing.T, *authncache.Cache) authncache.Value
+		wantErr              string
+		wantLogs             []string
+		wantCacheEntries     int
+		wantCacheValueReused bool
+	}{
+		{
+			name: "not found",
+			wantLogs: []string{
+				`clientcertcachefiller-controller "level"=0 "msg"="Sync() found that the ClientCertificateAuthenticator does not exist yet or was deleted"`,
+			},
+		},
+		{
+			name: "invalid spec",
+			authenticators: []runtime.Object{
+				&auth1alpha1.ClientCertificateAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+				},
+			},
+			wantErr: "failed to build client certificate authenticator: audience must be specified",
+		},
+		{
+			name: "invalid spec removes the previous authenticator",
+			cache: func(t *testing.T, cache *authncache.Cache) authncache.Value {
+				value := newCacheValue(t, someSpec)
+				cache.Store(cacheKey, value)
+				return value
+			},
+			authenticators: []runtime.Object{
+				&auth1alpha1.ClientCertificateAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+					Spec:       auth1alpha1.ClientCertificateAuthenticatorSpec{Audience: "some-audience"},
+				},
+			},
+			wantErr: "failed to build client certificate authenticator: invalid certificateAuthorityData: CA bundle does not contain any PEM-encoded certificates",
+		},
+		{
+			name: "valid authenticator",
+			authenticators: []runtime.Object{
+				&auth1alpha1.ClientCertificateAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+					Spec:       someSpec,
+				},
+			},
+			wantLogs: []string{
+				`clientcertcachefiller-controller "level"=0 "msg"="added new client certificate authenticator" "audience"="some-audience" "clientCertificateAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries: 1,
+		},
+		{
+			name: "unchanged authenticator is not recreated",
+			cache: func(t *testing.T, cache *authncache.Cache) authncache.Value {
+				value := newCacheValue(t, someSpec)
+				cache.Store(cacheKey, value)
+				return value
+			},
+			authenticators: []runtime.Object{
+				&auth1alpha1.ClientCertificateAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+					Spec:       someSpec,
+				},
+			},
+			wantLogs: []string{
+				`clientcertcachefiller-controller "level"=0 "msg"="actual client certificate authenticator and desired client certificate authenticator are the same" "clientCertificateAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries:     1,
+			wantCacheValueReused: true,
+		},
+		{
+			name: "changed authenticator is recreated",
+			cache: func(t *testing.T, cache *authncache.Cache) authncache.Value {
+				value := newCacheValue(t, otherSpec)
+				cache.Store(cacheKey, value)
+				return value
+			},
+			authenticators: []runtime.Object{
+				&auth1alpha1.ClientCertificateAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+					Spec:       someSpec,
+				},
+			},
+			wantLogs: []string{
+				`clientcertcachefiller-controller "level"=0 "msg"="added new client certificate authenticator" "audience"="some-audience" "clientCertificateAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeClient := pinnipedfake.NewSimpleClientset(tt.authenticators...)
+			informers := pinnipedinformers.NewSharedInformerFactory(fakeClient, 0)
+			cache := authncache.New()
+			testLog := testlogger.New(t)
+
+			var initialValue authncache.Value
+			if tt.cache != nil {
+				initialValue = tt.cache(t, cache)
+			}
+
+			controller := New(cache, informers.Authentication().V1alpha1().ClientCertificateAuthenticators(), clock.RealClock{}, testLog)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			informers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{Name: "test-name"}}
+
+			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantLogs, testLog.Lines())
+			require.Equal(t, tt.wantCacheEntries, len(cache.Keys()))
+
+			if initialValue != nil {
+				if tt.wantCacheValueReused {
+					require.Same(t, initialValue, cache.Get(cacheKey))
+				} else {
+					require.NotSame(t, initialValue, cache.Get(cacheKey))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cloudidentitycachefiller implements a controller for filling an authncache.Cache with each
+// added/updated CloudIdentityAuthenticator.
+package cloudidentitycachefiller
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	authinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions/authentication/v1alpha1"
+	"go.pinniped.dev/internal/cloudidentity"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controllerlib"
+)
+
+type cloudIdentityAuthenticator struct {
+	*cloudidentity.Authenticator
+	spec *auth1alpha1.CloudIdentityAuthenticatorSpec
+}
+
+// New instantiates a new controllerlib.Controller which will populate the provided authncache.Cache.
+func New(
+	cache *authncache.Cache,
+	cloudIdentityAuthenticators authinformers.CloudIdentityAuthenticatorInformer,
+	log logr.Logger,
+	opts ...controllerlib.Option,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "cloudidentitycachefiller-controller",
+			Syncer: &controller{
+				cache:                       cache,
+				cloudIdentityAuthenticators: cloudIdentityAuthenticators,
+				log:                         log.WithName("cloudidentitycachefiller-controller"),
+			},
+		},
+		append([]controllerlib.Option{
+			controllerlib.WithInformer(
+				cloudIdentityAuthenticators,
+				pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
+	)
+}
+
+type controller struct {
+	cache                       *authncache.Cache
+	cloudIdentityAuthenticators authinformers.CloudIdentityAuthenticatorInformer
+	log                         logr.Logger
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *controller) Sync(ctx controllerlib.Context) error {
+	obj, err := c.cloudIdentityAuthenticators.Lister().Get(ctx.Key.Name)
+	if err != nil && errors.IsNotFound(err) {
+		c.log.Info("Sync() found that the CloudIdentityAuthenticator does not exist yet or was deleted")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get CloudIdentityAuthenticator %s/%s: %w", ctx.Key.Namespace, ctx.Key.Name, err)
+	}
+
+	cacheKey := authncache.Key{
+		APIGroup: auth1alpha1.GroupName,
+		Kind:     "CloudIdentityAuthenticator",
+		Name:     ctx.Key.Name,
+	}
+
+	// Only recreate the authenticator if its spec has changed, and close the old one when we do so that any
+	// background key refreshes are stopped.
+	if existing, ok := c.cache.Get(cacheKey).(*cloudIdentityAuthenticator); ok {
+		if reflect.DeepEqual(existing.spec, &obj.Spec) {
+			c.log.WithValues("cloudIdentityAuthenticator", klog.KObj(obj)).Info("actual cloud identity authenticator and desired cloud identity authenticator are the same")
+			return nil
+		}
+		existing.Close()
+	}
+
+	// Make a deep copy of the spec so we aren't storing pointers to something that the informer cache
+	// may mutate!
+	spec := obj.Spec.DeepCopy()
+	authenticator, err := cloudidentity.New(spec)
+	if err != nil {
+		return fmt.Errorf("failed to build cloud identity authenticator: %w", err)
+	}
+
+	c.cache.Store(cacheKey, &cloudIdentityAuthenticator{Authenticator: authenticator, spec: spec})
+	c.log.WithValues("cloudIdentityAuthenticator", klog.KObj(obj)).Info("added new cloud identity authenticator")
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudidentitycachefiller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	pinnipedinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
+	"go.pinniped.dev/internal/cloudidentity"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/testutil/testlogger"
+)
+
+func TestController(t *testing.T) {
+	t.Parallel()
+
+	awsSpec := auth1alpha1.CloudIdentityAuthenticatorSpec{
+		AWS: &auth1alpha1.AWSIdentitySpec{ClusterID: "some-cluster", AccountIDs: []string{"123456789012"}},
+	}
+	otherAWSSpec := auth1alpha1.CloudIdentityAuthenticatorSpec{
+		AWS: &auth1alpha1.AWSIdentitySpec{ClusterID: "some-other-cluster", AccountIDs: []string{"123456789012"}},
+	}
+	cacheKey := authncache.Key{
+		APIGroup: auth1alpha1.SchemeGroupVersion.Group,
+		Kind:     "CloudIdentityAuthenticator",
+		Name:     "test-name",
+	}
+	newCacheValue := func(t *testing.T, spec auth1alpha1.CloudIdentityAuthenticatorSpec) *cloudIdentityAuthenticator {
+		t.Helper()
+		a, err := cloudidentity.New(&spec)
+		require.NoError(t, err)
+		return &cloudIdentityAuthenticator{Authenticator: a, spec: &spec}
+	}
+
+	tests := []struct {
+		name                 string
+		authenticators       []runtime.Object
+		cache                func(*testing.T, *authncache.Cache) authncache.Value
+		wantErr              string
+		wantLogs             []string
+		wantCacheEntries     int
+		wantCacheValueReused bool
+	}{
+		{
+			name: "not found",
+			wantLogs: []string{
+				`cloudidentitycachefiller-controller "level"=0 "msg"="Sync() found that the CloudIdentityAuthenticator does not exist yet or was deleted"`,
+			},
+		},
+		{
+			name: "invalid spec",
+			authenticators: []runtime.Object{
+				&auth1alpha1.CloudIdentityAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+				},
+			},
+			wantErr: "failed to build cloud identity authenticator: exactly one of spec.aws or spec.gcp must be specified",
+		},
+		{
+			name: "valid authenticator",
+			authenticators: []runtime.Object{
+				&auth1alpha1.CloudIdentityAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+					Spec:       awsSpec,
+				},
+			},
+			wantLogs: []string{
+				`cloudidentitycachefiller-controller "level"=0 "msg"="added new cloud identity authenticator" "cloudIdentityAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries: 1,
+		},
+		{
+			name: "unchanged authenticator is not recreated",
+			cache: func(t *testing.T, cache *authncache.Cache) authncache.Value {
+				value := newCacheValue(t, awsSpec)
+				cache.Store(cacheKey, value)
+				return value
+			},
+			authenticators: []runtime.Object{
+				&auth1alpha1.CloudIdentityAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+					Spec:       awsSpec,
+				},
+			},
+			wantLogs: []string{
+				`cloudidentitycachefiller-controller "level"=0 "msg"="actual cloud identity authenticator and desired cloud identity authenticator are the same" "cloudIdentityAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries:     1,
+			wantCacheValueReused: true,
+		},
+		{
+			name: "changed authenticator is recreated",
+			cache: func(t *testing.T, cache *authncache.Cache) authncache.Value {
+				value := newCacheValue(t, otherAWSSpec)
+				cache.Store(cacheKey, value)
+				return value
+			},
+			authenticators: []runtime.Object{
+				&auth1alpha1.CloudIdentityAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+					Spec:       awsSpec,
+				},
+			},
+			wantLogs: []string{
+				`cloudidentitycachefiller-controller "level"=0 "msg"="added new cloud identity authenticator" "cloudIdentityAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeClient := pinnipedfake.NewSimpleClientset(tt.authenticators...)
+			informers := pinnipedinformers.NewSharedInformerFactory(fakeClient, 0)
+			cache := authncache.New()
+			testLog := testlogger.New(t)
+
+			var initialValue authncache.Value
+			if tt.cache != nil {
+				initialValue = tt.cache(t, cache)
+			}
+
+			controller := New(cache, informers.Authentication().V1alpha1().CloudIdentityAuthenticators(), testLog)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			informers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{Name: "test-name"}}
+
+			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantLogs, testLog.Lines())
+			require.Equal(t, tt.wantCacheEntries, len(cache.Keys()))
+
+			if initialValue != nil {
+				if tt.wantCacheValueReused {
+					require.Same(t, initialValue, cache.Get(cacheKey))
+				} else {
+					require.NotSame(t, initialValue, cache.Get(cacheKey))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package jwtcachefiller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"go.pinniped.dev/internal/clockskew"
+)
+
+// clockSkewAuthenticator wraps a JWT authenticator to accept tokens which it rejected only because of their "exp" or
+// "nbf" claims when they are within the clock skew tolerance of the JWTAuthenticator. The upstream Kubernetes OIDC
+// authenticator does not support a clock skew tolerance, so those tokens are verified again here, mapping their
+// username and groups claims the same way that it does.
+type clockSkewAuthenticator struct {
+	tokenAuthenticatorCloser
+	issuer        string
+	audiences     sets.String
+	usernameClaim string
+	groupsClaim   string
+	tolerance     time.Duration
+	client        *http.Client
+
+	lock     sync.Mutex
+	verifier *coreosoidc.IDTokenVerifier
+}
+
+func newClockSkewAuthenticator(
+	delegate tokenAuthenticatorCloser,
+	spec *auth1alpha1.JWTAuthenticatorSpec,
+	usernameClaim string,
+	groupsClaim string,
+	pool *x509.CertPool,
+) *clockSkewAuthenticator {
+	return &clockSkewAuthenticator{
+		tokenAuthenticatorCloser: delegate,
+		issuer:                   spec.Issuer,
+		audiences:                sets.NewString(acceptedAudiences(spec)...),
+		usernameClaim:            usernameClaim,
+		groupsClaim:              groupsClaim,
+		tolerance:                time.Duration(spec.ClockSkewToleranceSeconds) * time.Second,
+		client: &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool},
+		}},
+	}
+}
+
+func (a *clockSkewAuthenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	response, authenticated, err := a.tokenAuthenticatorCloser.AuthenticateToken(ctx, token)
+	if err == nil || !isClockSkewError(err) {
+		return response, authenticated, err
+	}
+
+	verifier, err := a.getVerifier(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	idToken, err := verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, false, fmt.Errorf("oidc: verify token: %w", err)
+	}
+	if err := clockskew.Check(idToken, a.tolerance, time.Now()); err != nil {
+		return nil, false, fmt.Errorf("oidc: verify token: %w", err)
+	}
+	if !a.audiences.HasAny(idToken.Audience...) {
+		return nil, false, fmt.Errorf("oidc: verify token: oidc: expected audience in %q got %q", a.audiences.List(), idToken.Audience)
+	}
+
+	info, err := a.userInfo(idToken)
+	if err != nil {
+		return nil, false, err
+	}
+	return &authenticator.Response{User: info}, true, nil
+}
+
+func (a *clockSkewAuthenticator) Close() {
+	a.tokenAuthenticatorCloser.Close()
+	a.client.CloseIdleConnections()
+}
+
+// isClockSkewError returns whether the upstream Kubernetes OIDC authenticator rejected a token because of its "exp"
+// or "nbf" claims. It does not return typed errors, so this has to match the messages of go-oidc.
+func isClockSkewError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "token is expired") || strings.Contains(msg, "before the nbf (not before) time")
+}
+
+// getVerifier performs OIDC discovery the first time that it is needed, since most tokens never need it.
+func (a *clockSkewAuthenticator) getVerifier(ctx context.Context) (*coreosoidc.IDTokenVerifier, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.verifier != nil {
+		return a.verifier, nil
+	}
+
+	discoveryCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+	provider, err := coreosoidc.NewProvider(coreosoidc.ClientContext(discoveryCtx, a.client), a.issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: could not perform OIDC discovery: %w", err)
+	}
+	var discoveryClaims struct {
+		JWKSURL string `json:"jwks_uri"`
+	}
+	if err := provider.Claims(&discoveryClaims); err != nil {
+		return nil, fmt.Errorf("oidc: could not perform OIDC discovery: %w", err)
+	}
+
+	// The key set keeps using its context to fetch keys, so it must not be the context of this request.
+	keySet := coreosoidc.NewRemoteKeySet(coreosoidc.ClientContext(context.Background(), a.client), discoveryClaims.JWKSURL)
+	a.verifier = coreosoidc.NewVerifier(a.issuer, keySet, clockskew.Config(coreosoidc.Config{
+		// The audience is checked by AuthenticateToken, since there may be several accepted audiences.
+		SkipClientIDCheck:    true,
+		SupportedSigningAlgs: defaultSupportedSigningAlgos(),
+	}, a.tolerance))
+	return a.verifier, nil
+}
+
+func (a *clockSkewAuthenticator) userInfo(idToken *coreosoidc.IDToken) (*user.DefaultInfo, error) {
+	var claims map[string]json.RawMessage
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("oidc: parse claims: %w", err)
+	}
+
+	var username string
+	if err := unmarshalClaim(claims, a.usernameClaim, &username); err != nil {
+		return nil, fmt.Errorf("oidc: parse username claims %q: %w", a.usernameClaim, err)
+	}
+	if a.usernameClaim == "email" {
+		if _, ok := claims["email_verified"]; ok {
+			var emailVerified bool
+			if err := unmarshalClaim(claims, "email_verified", &emailVerified); err != nil {
+				return nil, fmt.Errorf("oidc: parse 'email_verified' claim: %w", err)
+			}
+			if !emailVerified {
+				return nil, fmt.Errorf("oidc: email not verified")
+			}
+		}
+	}
+
+	info := &user.DefaultInfo{Name: username}
+	if _, ok := claims[a.groupsClaim]; ok {
+		// Like the upstream Kubernetes OIDC authenticator, allow the groups claim to be a single string.
+		var groups []string
+		if err := unmarshalClaim(claims, a.groupsClaim, &groups); err != nil {
+			var group string
+			if err := unmarshalClaim(claims, a.groupsClaim, &group); err != nil {
+				return nil, fmt.Errorf("oidc: parse groups claim %q: %w", a.groupsClaim, err)
+			}
+			groups = []string{group}
+		}
+		info.Groups = groups
+	}
+	return info, nil
+}
+
+func unmarshalClaim(claims map[string]json.RawMessage, name string, v interface{}) error {
+	value, ok := claims[name]
+	if !ok {
+		return fmt.Errorf("claim not present")
+	}
+	return json.Unmarshal(value, v)
+}
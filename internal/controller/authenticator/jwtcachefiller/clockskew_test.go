@@ -0,0 +1,195 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package jwtcachefiller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedauthenticator "go.pinniped.dev/internal/controller/authenticator"
+	"go.pinniped.dev/internal/mocks/mocktokenauthenticatorcloser"
+)
+
+func TestClockSkewAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	mux.Handle("/.well-known/openid-configuration", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, err := fmt.Fprintf(w, `{"issuer": "%s", "jwks_uri": "%s"}`, server.URL, server.URL+"/jwks.json")
+		require.NoError(t, err)
+	}))
+	mux.Handle("/jwks.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jwk := jose.JSONWebKey{Key: signingKey, KeyID: "some-key-id", Algorithm: string(jose.ES256), Use: "sig"}
+		require.NoError(t, json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}}))
+	}))
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: signingKey},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "some-key-id"),
+	)
+	require.NoError(t, err)
+	expiredErr := errors.New("oidc: verify token: oidc: token is expired (Token Expiry: some time)")
+
+	tests := []struct {
+		name              string
+		expiry            time.Time
+		notBefore         time.Time
+		audience          string
+		extraClaims       map[string]interface{}
+		delegateErr       error
+		wantResponse      *authenticator.Response
+		wantAuthenticated bool
+		wantErr           string
+	}{
+		{
+			name:              "delegate authenticates the token",
+			expiry:            time.Now().Add(time.Hour),
+			wantResponse:      &authenticator.Response{User: &user.DefaultInfo{Name: "from-delegate"}},
+			wantAuthenticated: true,
+		},
+		{
+			name:        "delegate rejects the token for another reason",
+			expiry:      time.Now().Add(time.Hour),
+			delegateErr: errors.New("oidc: verify token: failed to verify signature"),
+			wantErr:     "oidc: verify token: failed to verify signature",
+		},
+		{
+			name:              "expired within tolerance",
+			expiry:            time.Now().Add(-time.Minute),
+			extraClaims:       map[string]interface{}{"groups": []string{"some-group-0", "some-group-1"}},
+			delegateErr:       expiredErr,
+			wantResponse:      &authenticator.Response{User: &user.DefaultInfo{Name: "some-username", Groups: []string{"some-group-0", "some-group-1"}}},
+			wantAuthenticated: true,
+		},
+		{
+			name:              "not yet valid within tolerance with groups as string",
+			expiry:            time.Now().Add(time.Hour),
+			notBefore:         time.Now().Add(3 * time.Minute),
+			extraClaims:       map[string]interface{}{"groups": "some-group-0"},
+			delegateErr:       errors.New("oidc: verify token: oidc: current time now before the nbf (not before) time: later"),
+			wantResponse:      &authenticator.Response{User: &user.DefaultInfo{Name: "some-username", Groups: []string{"some-group-0"}}},
+			wantAuthenticated: true,
+		},
+		{
+			name:              "expired within tolerance for an additional audience",
+			expiry:            time.Now().Add(-time.Minute),
+			audience:          "some-other-audience",
+			delegateErr:       expiredErr,
+			wantResponse:      &authenticator.Response{User: &user.DefaultInfo{Name: "some-username"}},
+			wantAuthenticated: true,
+		},
+		{
+			name:        "expired beyond tolerance",
+			expiry:      time.Now().Add(-time.Hour),
+			delegateErr: expiredErr,
+			wantErr:     `oidc: verify token: token is expired \(Token Expiry: .+, clock skew tolerance: 5m0s\)`,
+		},
+		{
+			name:        "expired within tolerance for the wrong audience",
+			expiry:      time.Now().Add(-time.Minute),
+			audience:    "wrong-audience",
+			delegateErr: expiredErr,
+			wantErr:     `oidc: verify token: oidc: expected audience in \["some-audience" "some-other-audience"\] got \["wrong-audience"\]`,
+		},
+		{
+			name:        "expired within tolerance with invalid username",
+			expiry:      time.Now().Add(-time.Minute),
+			extraClaims: map[string]interface{}{"username": 42},
+			delegateErr: expiredErr,
+			wantErr:     `oidc: parse username claims "username": json: cannot unmarshal number into Go value of type string`,
+		},
+		{
+			name:        "expired within tolerance with invalid groups",
+			expiry:      time.Now().Add(-time.Minute),
+			extraClaims: map[string]interface{}{"groups": map[string]string{"not an array": "or a string"}},
+			delegateErr: expiredErr,
+			wantErr:     `oidc: parse groups claim "groups": json: cannot unmarshal object into Go value of type string`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if tt.audience == "" {
+				tt.audience = "some-audience"
+			}
+			claims := jwt.Claims{
+				Issuer:   server.URL,
+				Subject:  "some-subject",
+				Audience: jwt.Audience{tt.audience},
+				Expiry:   jwt.NewNumericDate(tt.expiry),
+			}
+			if !tt.notBefore.IsZero() {
+				claims.NotBefore = jwt.NewNumericDate(tt.notBefore)
+			}
+			builder := jwt.Signed(signer).Claims(claims).Claims(map[string]interface{}{"username": "some-username"})
+			if tt.extraClaims != nil {
+				builder = builder.Claims(tt.extraClaims)
+			}
+			token, err := builder.CompactSerialize()
+			require.NoError(t, err)
+
+			ctrl := gomock.NewController(t)
+			t.Cleanup(ctrl.Finish)
+			delegate := mocktokenauthenticatorcloser.NewMockTokenAuthenticatorCloser(ctrl)
+			if tt.delegateErr != nil {
+				delegate.EXPECT().AuthenticateToken(gomock.Any(), token).Return(nil, false, tt.delegateErr)
+			} else {
+				delegate.EXPECT().AuthenticateToken(gomock.Any(), token).Return(&authenticator.Response{User: &user.DefaultInfo{Name: "from-delegate"}}, true, nil)
+			}
+			delegate.EXPECT().Close()
+
+			spec := &auth1alpha1.JWTAuthenticatorSpec{
+				Issuer:                    server.URL,
+				Audience:                  "some-audience",
+				Audiences:                 []string{"some-other-audience"},
+				TLS:                       tlsSpecFromTLSConfig(server.TLS),
+				ClockSkewToleranceSeconds: 300,
+			}
+			caBundle, err := inlineCABundle(spec.TLS)
+			require.NoError(t, err)
+			pool, err := pinnipedauthenticator.CertPool(caBundle)
+			require.NoError(t, err)
+
+			a := newClockSkewAuthenticator(delegate, spec, "username", "groups", pool)
+			t.Cleanup(a.Close)
+
+			rsp, authenticated, err := a.AuthenticateToken(context.Background(), token)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Regexp(t, tt.wantErr, err.Error())
+				require.False(t, authenticated)
+				require.Nil(t, rsp)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantAuthenticated, authenticated)
+			require.Equal(t, tt.wantResponse, rsp)
+		})
+	}
+}
@@ -6,19 +6,33 @@
 package jwtcachefiller
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"gopkg.in/square/go-jose.v2"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/clock"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/apiserver/plugin/pkg/authenticator/token/oidc"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/klog/v2"
 
 	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
 	authinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions/authentication/v1alpha1"
 	pinnipedcontroller "go.pinniped.dev/internal/controller"
 	pinnipedauthenticator "go.pinniped.dev/internal/controller/authenticator"
@@ -31,8 +45,13 @@ import (
 const (
 	defaultUsernameClaim = "username"
 	defaultGroupsClaim   = "groups"
+	defaultUIDClaim      = "uid"
 )
 
+// caBundleSecretKey is the key of a Secret referenced by spec.tls.certificateAuthoritySecretName which holds the
+// PEM-encoded CA bundle. This is the same key that is used by Secrets of type kubernetes.io/tls.
+const caBundleSecretKey = "ca.crt"
+
 // defaultSupportedSigningAlgos returns the default signing algos that this JWTAuthenticator
 // supports (i.e., if none are supplied by the user).
 func defaultSupportedSigningAlgos() []string {
@@ -54,84 +73,170 @@ type tokenAuthenticatorCloser interface {
 
 type jwtAuthenticator struct {
 	tokenAuthenticatorCloser
-	spec *auth1alpha1.JWTAuthenticatorSpec
+	spec     *auth1alpha1.JWTAuthenticatorSpec
+	caBundle []byte
+	created  time.Time
 }
 
-// New instantiates a new controllerlib.Controller which will populate the provided authncache.Cache.
+// New instantiates a new controllerlib.Controller which will populate the provided authncache.Cache. The
+// secretInformer and namespace are used to read the CA bundles of JWTAuthenticators which reference a Secret. The
+// controller also reports whether each JWTAuthenticator is working in its status conditions, and records an Event
+// whenever one of them changes its status.
 func New(
 	cache *authncache.Cache,
+	client pinnipedclientset.Interface,
 	jwtAuthenticators authinformers.JWTAuthenticatorInformer,
+	secretInformer corev1informers.SecretInformer,
+	namespace string,
+	clock clock.PassiveClock,
+	recorder events.EventRecorder,
 	log logr.Logger,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
 			Name: "jwtcachefiller-controller",
 			Syncer: &controller{
 				cache:             cache,
+				client:            client,
 				jwtAuthenticators: jwtAuthenticators,
+				secretInformer:    secretInformer,
+				namespace:         namespace,
+				clock:             clock,
+				discovery:         newDiscoveryCache(),
 				log:               log.WithName("jwtcachefiller-controller"),
 			},
 		},
-		controllerlib.WithInformer(
-			jwtAuthenticators,
-			pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			controllerlib.WithRecorder(recorder),
+			controllerlib.WithInformer(
+				jwtAuthenticators,
+				pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+			// JWTAuthenticators are cluster-scoped, so the keys of Secret events can be told apart by their namespace.
+			controllerlib.WithInformer(
+				secretInformer,
+				pinnipedcontroller.MatchAnythingFilter(nil),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
 type controller struct {
 	cache             *authncache.Cache
+	client            pinnipedclientset.Interface
 	jwtAuthenticators authinformers.JWTAuthenticatorInformer
+	secretInformer    corev1informers.SecretInformer
+	namespace         string
+	clock             clock.PassiveClock
+	discovery         *discoveryCache
 	log               logr.Logger
 }
 
 // Sync implements controllerlib.Syncer.
 func (c *controller) Sync(ctx controllerlib.Context) error {
-	obj, err := c.jwtAuthenticators.Lister().Get(ctx.Key.Name)
+	if ctx.Key.Namespace != "" {
+		return c.syncAuthenticatorsUsingSecret(ctx, ctx.Key.Name)
+	}
+	return c.syncAuthenticator(ctx, ctx.Key.Name)
+}
+
+// syncAuthenticatorsUsingSecret syncs each JWTAuthenticator which reads its CA bundle from the named Secret.
+func (c *controller) syncAuthenticatorsUsingSecret(ctx controllerlib.Context, secretName string) error {
+	objs, err := c.jwtAuthenticators.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list JWTAuthenticators: %w", err)
+	}
+	var errs []error
+	for _, obj := range objs {
+		if obj.Spec.TLS == nil || obj.Spec.TLS.CertificateAuthoritySecretName != secretName {
+			continue
+		}
+		if err := c.syncAuthenticator(ctx, obj.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (c *controller) syncAuthenticator(ctx controllerlib.Context, name string) error {
+	obj, err := c.jwtAuthenticators.Lister().Get(name)
 	if err != nil && errors.IsNotFound(err) {
 		c.log.Info("Sync() found that the JWTAuthenticator does not exist yet or was deleted")
 		return nil
 	}
 	if err != nil {
-		return fmt.Errorf("failed to get JWTAuthenticator %s/%s: %w", ctx.Key.Namespace, ctx.Key.Name, err)
+		return fmt.Errorf("failed to get JWTAuthenticator %s: %w", name, err)
 	}
 
 	cacheKey := authncache.Key{
 		APIGroup: auth1alpha1.GroupName,
 		Kind:     "JWTAuthenticator",
-		Name:     ctx.Key.Name,
+		Name:     name,
 	}
 
+	caBundle, caBundleErr := c.caBundle(obj.Spec.TLS)
+	pool, tlsCondition := validateTLS(caBundle, caBundleErr)
+	if tlsCondition.Status != auth1alpha1.ConditionTrue {
+		conditions := append([]*auth1alpha1.Condition{tlsCondition}, unableToValidate(typeIssuerReachable, typeAudienceValidated)...)
+		return utilerrors.NewAggregate([]error{
+			fmt.Errorf("failed to build jwt authenticator: invalid TLS configuration: %s", tlsCondition.Message),
+			c.updateStatus(ctx, obj, conditions),
+		})
+	}
+	issuerCondition := c.discovery.validateIssuer(ctx.Context, c.clock.Now(), obj.Spec.Issuer, caBundle, pool)
+
 	// If this authenticator already exists, then only recreate it if is different from the desired
 	// authenticator. We don't want to be creating a new authenticator for every resync period.
 	//
 	// If we do need to recreate the authenticator, then make sure we close the old one to avoid
 	// goroutine leaks.
-	if value := c.cache.Get(cacheKey); value != nil {
-		jwtAuthenticator := c.extractValueAsJWTAuthenticator(value)
+	jwtAuthenticator := c.extractValueAsJWTAuthenticator(c.cache.Get(cacheKey))
+	if jwtAuthenticator != nil && reflect.DeepEqual(jwtAuthenticator.spec, &obj.Spec) && bytes.Equal(jwtAuthenticator.caBundle, caBundle) {
+		c.log.WithValues("jwtAuthenticator", klog.KObj(obj), "issuer", obj.Spec.Issuer).Info("actual jwt authenticator and desired jwt authenticator are the same")
+	} else {
 		if jwtAuthenticator != nil {
-			if reflect.DeepEqual(jwtAuthenticator.spec, &obj.Spec) {
-				c.log.WithValues("jwtAuthenticator", klog.KObj(obj), "issuer", obj.Spec.Issuer).Info("actual jwt authenticator and desired jwt authenticator are the same")
-				return nil
-			}
 			jwtAuthenticator.Close()
 		}
-	}
 
-	// Make a deep copy of the spec so we aren't storing pointers to something that the informer cache
-	// may mutate!
-	jwtAuthenticator, err := newJWTAuthenticator(obj.Spec.DeepCopy())
-	if err != nil {
-		return fmt.Errorf("failed to build jwt authenticator: %w", err)
+		// Make a deep copy of the spec so we aren't storing pointers to something that the informer cache
+		// may mutate!
+		var err error
+		jwtAuthenticator, err = newJWTAuthenticator(obj.Spec.DeepCopy(), caBundle)
+		if err != nil {
+			return utilerrors.NewAggregate([]error{
+				fmt.Errorf("failed to build jwt authenticator: %w", err),
+				c.updateStatus(ctx, obj, []*auth1alpha1.Condition{tlsCondition, issuerCondition, {
+					Type:    typeAudienceValidated,
+					Status:  auth1alpha1.ConditionFalse,
+					Reason:  reasonInvalidConfiguration,
+					Message: err.Error(),
+				}}),
+			})
+		}
+
+		jwtAuthenticator.created = c.clock.Now()
+		c.cache.Store(cacheKey, jwtAuthenticator)
+		c.log.WithValues("jwtAuthenticator", klog.KObj(obj), "issuer", obj.Spec.Issuer).Info("added new jwt authenticator")
 	}
 
-	c.cache.Store(cacheKey, jwtAuthenticator)
-	c.log.WithValues("jwtAuthenticator", klog.KObj(obj), "issuer", obj.Spec.Issuer).Info("added new jwt authenticator")
-	return nil
+	audienceCondition := selfTest(ctx.Context, c.clock.Now(), &obj.Spec)
+	if age := c.clock.Since(jwtAuthenticator.created); audienceCondition.Status == auth1alpha1.ConditionTrue && age < initializationPeriod {
+		audienceCondition = initializing()
+		if issuerCondition.Status == auth1alpha1.ConditionTrue {
+			// Check again once the authenticator has fetched the signing keys, rather than waiting for the next resync.
+			ctx.Queue.AddAfter(controllerlib.Key{Name: obj.Name}, initializationPeriod-age)
+		}
+	}
+	return c.updateStatus(ctx, obj, []*auth1alpha1.Condition{tlsCondition, issuerCondition, audienceCondition})
 }
 
 func (c *controller) extractValueAsJWTAuthenticator(value authncache.Value) *jwtAuthenticator {
+	if value == nil {
+		return nil
+	}
 	jwtAuthenticator, ok := value.(*jwtAuthenticator)
 	if !ok {
 		actualType := "<nil>"
@@ -144,13 +249,37 @@ func (c *controller) extractValueAsJWTAuthenticator(value authncache.Value) *jwt
 	return jwtAuthenticator
 }
 
-// newJWTAuthenticator creates a jwt authenticator from the provided spec.
-func newJWTAuthenticator(spec *auth1alpha1.JWTAuthenticatorSpec) (*jwtAuthenticator, error) {
-	caBundle, err := pinnipedauthenticator.CABundle(spec.TLS)
+// caBundle returns the PEM-encoded CA bundle of the provided spec, which is either inline or in a Secret.
+func (c *controller) caBundle(spec *auth1alpha1.JWTTLSSpec) ([]byte, error) {
+	if spec == nil || spec.CertificateAuthoritySecretName == "" {
+		return inlineCABundle(spec)
+	}
+	if spec.CertificateAuthorityData != "" {
+		return nil, fmt.Errorf("only one of certificateAuthorityData and certificateAuthoritySecretName may be specified")
+	}
+
+	secret, err := c.secretInformer.Lister().Secrets(c.namespace).Get(spec.CertificateAuthoritySecretName)
 	if err != nil {
-		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+		return nil, fmt.Errorf("failed to get CA bundle Secret %q: %w", spec.CertificateAuthoritySecretName, err)
+	}
+	caBundle := secret.Data[caBundleSecretKey]
+	if len(caBundle) == 0 {
+		return nil, fmt.Errorf("CA bundle Secret %q is missing key %q", spec.CertificateAuthoritySecretName, caBundleSecretKey)
 	}
+	return caBundle, nil
+}
 
+// inlineCABundle returns the PEM-encoded CA bundle from the certificateAuthorityData of the provided spec, which
+// may be nil.
+func inlineCABundle(spec *auth1alpha1.JWTTLSSpec) ([]byte, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	return pinnipedauthenticator.CABundle(&auth1alpha1.TLSSpec{CertificateAuthorityData: spec.CertificateAuthorityData})
+}
+
+// newJWTAuthenticator creates a jwt authenticator from the provided spec and PEM-encoded CA bundle.
+func newJWTAuthenticator(spec *auth1alpha1.JWTAuthenticatorSpec, caBundle []byte) (*jwtAuthenticator, error) {
 	var caFile string
 	if caBundle != nil {
 		temp, err := ioutil.TempFile("", "pinniped-jwkauthenticator-cafile-*")
@@ -176,21 +305,156 @@ func newJWTAuthenticator(spec *auth1alpha1.JWTAuthenticatorSpec) (*jwtAuthentica
 	if groupsClaim == "" {
 		groupsClaim = defaultGroupsClaim
 	}
+	uidClaim := spec.Claims.UID
+	if uidClaim == "" {
+		uidClaim = defaultUIDClaim
+	}
 
-	authenticator, err := oidc.New(oidc.Options{
-		IssuerURL:            spec.Issuer,
-		ClientID:             spec.Audience,
-		UsernameClaim:        usernameClaim,
-		GroupsClaim:          groupsClaim,
-		SupportedSigningAlgs: defaultSupportedSigningAlgos(),
-		CAFile:               caFile,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("could not initialize authenticator: %w", err)
+	// The upstream Kubernetes OIDC authenticator only accepts a single audience, so make one for each accepted audience.
+	var authenticators multiAudienceAuthenticator
+	for _, audience := range acceptedAudiences(spec) {
+		authenticator, err := oidc.New(oidc.Options{
+			IssuerURL:            spec.Issuer,
+			ClientID:             audience,
+			UsernameClaim:        usernameClaim,
+			GroupsClaim:          groupsClaim,
+			SupportedSigningAlgs: defaultSupportedSigningAlgos(),
+			CAFile:               caFile,
+		})
+		if err != nil {
+			authenticators.Close()
+			return nil, fmt.Errorf("could not initialize authenticator: %w", err)
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+
+	var authenticator tokenAuthenticatorCloser = authenticators
+	if len(authenticators) == 1 {
+		authenticator = authenticators[0]
+	}
+	if spec.ClockSkewToleranceSeconds > 0 || spec.RevocationList != nil {
+		pool, err := pinnipedauthenticator.CertPool(caBundle)
+		if err != nil {
+			authenticator.Close()
+			return nil, fmt.Errorf("could not initialize authenticator: %w", err)
+		}
+		if spec.ClockSkewToleranceSeconds > 0 {
+			authenticator = newClockSkewAuthenticator(authenticator, spec, usernameClaim, groupsClaim, pool)
+		}
+		if spec.RevocationList != nil {
+			authenticator = newRevocationListAuthenticator(authenticator, spec.RevocationList, pool)
+		}
 	}
 
 	return &jwtAuthenticator{
-		tokenAuthenticatorCloser: authenticator,
+		tokenAuthenticatorCloser: &uidClaimAuthenticator{tokenAuthenticatorCloser: authenticator, uidClaim: uidClaim},
 		spec:                     spec,
+		caBundle:                 caBundle,
 	}, nil
 }
+
+// acceptedAudiences returns spec.Audience followed by the distinct additional spec.Audiences.
+func acceptedAudiences(spec *auth1alpha1.JWTAuthenticatorSpec) []string {
+	audiences := []string{spec.Audience}
+	seen := sets.NewString(spec.Audience)
+	for _, audience := range spec.Audiences {
+		if audience == "" || seen.Has(audience) {
+			continue
+		}
+		seen.Insert(audience)
+		audiences = append(audiences, audience)
+	}
+	return audiences
+}
+
+// multiAudienceAuthenticator authenticates a JWT when any of its authenticators, each of which accepts a different
+// audience, authenticates it.
+type multiAudienceAuthenticator []tokenAuthenticatorCloser
+
+func (m multiAudienceAuthenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	var errs []error
+	for _, a := range m {
+		response, authenticated, err := a.AuthenticateToken(ctx, token)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if authenticated {
+			return response, true, nil
+		}
+	}
+	return nil, false, utilerrors.NewAggregate(errs)
+}
+
+func (m multiAudienceAuthenticator) Close() {
+	for _, a := range m {
+		a.Close()
+	}
+}
+
+// uidClaimAuthenticator wraps a JWT authenticator to set the UID of the authenticated user from a claim of the JWT,
+// since the upstream Kubernetes OIDC authenticator does not support mapping a UID claim.
+type uidClaimAuthenticator struct {
+	tokenAuthenticatorCloser
+	uidClaim string
+}
+
+func (a *uidClaimAuthenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	response, authenticated, err := a.tokenAuthenticatorCloser.AuthenticateToken(ctx, token)
+	if err != nil || !authenticated {
+		return response, authenticated, err
+	}
+
+	// The wrapped authenticator has already verified the signature of the JWT, so it is safe to read its claims here.
+	uid, err := uidFromJWT(token, a.uidClaim)
+	if err != nil {
+		return nil, false, err
+	}
+	if uid == "" {
+		return response, authenticated, nil
+	}
+
+	return &authenticator.Response{
+		Audiences: response.Audiences,
+		User: &user.DefaultInfo{
+			Name:   response.User.GetName(),
+			UID:    uid,
+			Groups: response.User.GetGroups(),
+			Extra:  response.User.GetExtra(),
+		},
+	}, true, nil
+}
+
+func uidFromJWT(token string, uidClaim string) (string, error) {
+	claims, err := unverifiedClaimsFromJWT(token)
+	if err != nil {
+		return "", err
+	}
+
+	uidAsInterface, ok := claims[uidClaim]
+	if !ok {
+		return "", nil
+	}
+	uid, ok := uidAsInterface.(string)
+	if !ok {
+		return "", fmt.Errorf("uid claim %q is not a string", uidClaim)
+	}
+	return uid, nil
+}
+
+// unverifiedClaimsFromJWT returns the claims of the token without verifying its signature.
+func unverifiedClaimsFromJWT(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed jwt, expected 3 parts got %d", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed jwt payload: %w", err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed jwt claims: %w", err)
+	}
+	return claims, nil
+}
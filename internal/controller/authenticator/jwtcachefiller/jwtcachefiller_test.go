@@ -13,6 +13,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -23,10 +24,15 @@ import (
 	"github.com/stretchr/testify/require"
 	"gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/jwt"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
 	"k8s.io/apiserver/pkg/authentication/user"
+	kubeinformers "k8s.io/client-go/informers"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/events"
 
 	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
 	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
@@ -105,10 +111,27 @@ func TestController(t *testing.T) {
 			Groups: "my-custom-groups-claim",
 		},
 	}
+	someJWTAuthenticatorSpecWithAdditionalAudiences := &auth1alpha1.JWTAuthenticatorSpec{
+		Issuer:    goodIssuer,
+		Audience:  goodAudience,
+		Audiences: []string{goodAudience, "some-other-audience"},
+		TLS:       tlsSpecFromTLSConfig(server.TLS),
+	}
+	goodCABundle, err := base64.StdEncoding.DecodeString(tlsSpecFromTLSConfig(server.TLS).CertificateAuthorityData)
+	require.NoError(t, err)
+	someJWTAuthenticatorSpecWithCASecret := &auth1alpha1.JWTAuthenticatorSpec{
+		Issuer:   goodIssuer,
+		Audience: goodAudience,
+		TLS:      &auth1alpha1.JWTTLSSpec{CertificateAuthoritySecretName: "some-ca-secret"},
+	}
+	someCASecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "concierge", Name: "some-ca-secret"},
+		Data:       map[string][]byte{"ca.crt": goodCABundle},
+	}
 	otherJWTAuthenticatorSpec := &auth1alpha1.JWTAuthenticatorSpec{
 		Issuer:   "https://some-other-issuer.com",
 		Audience: goodAudience,
-		TLS:      &auth1alpha1.TLSSpec{CertificateAuthorityData: "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSURVVENDQWptZ0F3SUJBZ0lWQUpzNStTbVRtaTJXeUI0bGJJRXBXaUs5a1RkUE1BMEdDU3FHU0liM0RRRUIKQ3dVQU1COHhDekFKQmdOVkJBWVRBbFZUTVJBd0RnWURWUVFLREFkUWFYWnZkR0ZzTUI0WERUSXdNRFV3TkRFMgpNamMxT0ZvWERUSTBNRFV3TlRFMk1qYzFPRm93SHpFTE1Ba0dBMVVFQmhNQ1ZWTXhFREFPQmdOVkJBb01CMUJwCmRtOTBZV3d3Z2dFaU1BMEdDU3FHU0liM0RRRUJBUVVBQTRJQkR3QXdnZ0VLQW9JQkFRRERZWmZvWGR4Z2NXTEMKZEJtbHB5a0tBaG9JMlBuUWtsVFNXMno1cGcwaXJjOGFRL1E3MXZzMTRZYStmdWtFTGlvOTRZYWw4R01DdVFrbApMZ3AvUEE5N1VYelhQNDBpK25iNXcwRGpwWWd2dU9KQXJXMno2MFRnWE5NSFh3VHk4ME1SZEhpUFVWZ0VZd0JpCmtkNThzdEFVS1Y1MnBQTU1reTJjNy9BcFhJNmRXR2xjalUvaFBsNmtpRzZ5dEw2REtGYjJQRWV3MmdJM3pHZ2IKOFVVbnA1V05DZDd2WjNVY0ZHNXlsZEd3aGc3cnZ4U1ZLWi9WOEhCMGJmbjlxamlrSVcxWFM4dzdpUUNlQmdQMApYZWhKZmVITlZJaTJtZlczNlVQbWpMdnVKaGpqNDIrdFBQWndvdDkzdWtlcEgvbWpHcFJEVm9wamJyWGlpTUYrCkYxdnlPNGMxQWdNQkFBR2pnWU13Z1lBd0hRWURWUjBPQkJZRUZNTWJpSXFhdVkwajRVWWphWDl0bDJzby9LQ1IKTUI4R0ExVWRJd1FZTUJhQUZNTWJpSXFhdVkwajRVWWphWDl0bDJzby9LQ1JNQjBHQTFVZEpRUVdNQlFHQ0NzRwpBUVVGQndNQ0JnZ3JCZ0VGQlFjREFUQVBCZ05WSFJNQkFmOEVCVEFEQVFIL01BNEdBMVVkRHdFQi93UUVBd0lCCkJqQU5CZ2txaGtpRzl3MEJBUXNGQUFPQ0FRRUFYbEh4M2tIMDZwY2NDTDlEVE5qTnBCYnlVSytGd2R6T2IwWFYKcmpNaGtxdHVmdEpUUnR5T3hKZ0ZKNXhUR3pCdEtKamcrVU1pczBOV0t0VDBNWThVMU45U2c5SDl0RFpHRHBjVQpxMlVRU0Y4dXRQMVR3dnJIUzIrdzB2MUoxdHgrTEFiU0lmWmJCV0xXQ21EODUzRlVoWlFZekkvYXpFM28vd0p1CmlPUklMdUpNUk5vNlBXY3VLZmRFVkhaS1RTWnk3a25FcHNidGtsN3EwRE91eUFWdG9HVnlkb3VUR0FOdFhXK2YKczNUSTJjKzErZXg3L2RZOEJGQTFzNWFUOG5vZnU3T1RTTzdiS1kzSkRBUHZOeFQzKzVZUXJwNGR1Nmh0YUFMbAppOHNaRkhidmxpd2EzdlhxL3p1Y2JEaHEzQzBhZnAzV2ZwRGxwSlpvLy9QUUFKaTZLQT09Ci0tLS0tRU5EIENFUlRJRklDQVRFLS0tLS0K"},
+		TLS:      &auth1alpha1.JWTTLSSpec{CertificateAuthorityData: "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSURVVENDQWptZ0F3SUJBZ0lWQUpzNStTbVRtaTJXeUI0bGJJRXBXaUs5a1RkUE1BMEdDU3FHU0liM0RRRUIKQ3dVQU1COHhDekFKQmdOVkJBWVRBbFZUTVJBd0RnWURWUVFLREFkUWFYWnZkR0ZzTUI0WERUSXdNRFV3TkRFMgpNamMxT0ZvWERUSTBNRFV3TlRFMk1qYzFPRm93SHpFTE1Ba0dBMVVFQmhNQ1ZWTXhFREFPQmdOVkJBb01CMUJwCmRtOTBZV3d3Z2dFaU1BMEdDU3FHU0liM0RRRUJBUVVBQTRJQkR3QXdnZ0VLQW9JQkFRRERZWmZvWGR4Z2NXTEMKZEJtbHB5a0tBaG9JMlBuUWtsVFNXMno1cGcwaXJjOGFRL1E3MXZzMTRZYStmdWtFTGlvOTRZYWw4R01DdVFrbApMZ3AvUEE5N1VYelhQNDBpK25iNXcwRGpwWWd2dU9KQXJXMno2MFRnWE5NSFh3VHk4ME1SZEhpUFVWZ0VZd0JpCmtkNThzdEFVS1Y1MnBQTU1reTJjNy9BcFhJNmRXR2xjalUvaFBsNmtpRzZ5dEw2REtGYjJQRWV3MmdJM3pHZ2IKOFVVbnA1V05DZDd2WjNVY0ZHNXlsZEd3aGc3cnZ4U1ZLWi9WOEhCMGJmbjlxamlrSVcxWFM4dzdpUUNlQmdQMApYZWhKZmVITlZJaTJtZlczNlVQbWpMdnVKaGpqNDIrdFBQWndvdDkzdWtlcEgvbWpHcFJEVm9wamJyWGlpTUYrCkYxdnlPNGMxQWdNQkFBR2pnWU13Z1lBd0hRWURWUjBPQkJZRUZNTWJpSXFhdVkwajRVWWphWDl0bDJzby9LQ1IKTUI4R0ExVWRJd1FZTUJhQUZNTWJpSXFhdVkwajRVWWphWDl0bDJzby9LQ1JNQjBHQTFVZEpRUVdNQlFHQ0NzRwpBUVVGQndNQ0JnZ3JCZ0VGQlFjREFUQVBCZ05WSFJNQkFmOEVCVEFEQVFIL01BNEdBMVVkRHdFQi93UUVBd0lCCkJqQU5CZ2txaGtpRzl3MEJBUXNGQUFPQ0FRRUFYbEh4M2tIMDZwY2NDTDlEVE5qTnBCYnlVSytGd2R6T2IwWFYKcmpNaGtxdHVmdEpUUnR5T3hKZ0ZKNXhUR3pCdEtKamcrVU1pczBOV0t0VDBNWThVMU45U2c5SDl0RFpHRHBjVQpxMlVRU0Y4dXRQMVR3dnJIUzIrdzB2MUoxdHgrTEFiU0lmWmJCV0xXQ21EODUzRlVoWlFZekkvYXpFM28vd0p1CmlPUklMdUpNUk5vNlBXY3VLZmRFVkhaS1RTWnk3a25FcHNidGtsN3EwRE91eUFWdG9HVnlkb3VUR0FOdFhXK2YKczNUSTJjKzErZXg3L2RZOEJGQTFzNWFUOG5vZnU3T1RTTzdiS1kzSkRBUHZOeFQzKzVZUXJwNGR1Nmh0YUFMbAppOHNaRkhidmxpd2EzdlhxL3p1Y2JEaHEzQzBhZnAzV2ZwRGxwSlpvLy9QUUFKaTZLQT09Ci0tLS0tRU5EIENFUlRJRklDQVRFLS0tLS0K"},
 	}
 	missingTLSJWTAuthenticatorSpec := &auth1alpha1.JWTAuthenticatorSpec{
 		Issuer:   goodIssuer,
@@ -117,7 +140,7 @@ func TestController(t *testing.T) {
 	invalidTLSJWTAuthenticatorSpec := &auth1alpha1.JWTAuthenticatorSpec{
 		Issuer:   "https://some-other-issuer.com",
 		Audience: goodAudience,
-		TLS:      &auth1alpha1.TLSSpec{CertificateAuthorityData: "invalid base64-encoded data"},
+		TLS:      &auth1alpha1.JWTTLSSpec{CertificateAuthorityData: "invalid base64-encoded data"},
 	}
 
 	tests := []struct {
@@ -125,12 +148,14 @@ func TestController(t *testing.T) {
 		cache                            func(*testing.T, *authncache.Cache, bool)
 		syncKey                          controllerlib.Key
 		jwtAuthenticators                []runtime.Object
+		secrets                          []runtime.Object
 		wantClose                        bool
 		wantErr                          string
 		wantLogs                         []string
 		wantCacheEntries                 int
 		wantUsernameClaim                string
 		wantGroupsClaim                  string
+		tokenAudience                    string
 		runTestsOnResultingAuthenticator bool
 	}{
 		{
@@ -193,6 +218,128 @@ func TestController(t *testing.T) {
 			wantGroupsClaim:                  someJWTAuthenticatorSpecWithGroupsClaim.Claims.Groups,
 			runTestsOnResultingAuthenticator: true,
 		},
+		{
+			name:    "valid jwt authenticator with additional audiences",
+			syncKey: controllerlib.Key{Name: "test-name"},
+			jwtAuthenticators: []runtime.Object{
+				&auth1alpha1.JWTAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-name",
+					},
+					Spec: *someJWTAuthenticatorSpecWithAdditionalAudiences,
+				},
+			},
+			wantLogs: []string{
+				`jwtcachefiller-controller "level"=0 "msg"="added new jwt authenticator" "issuer"="` + goodIssuer + `" "jwtAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries:                 1,
+			tokenAudience:                    "some-other-audience",
+			runTestsOnResultingAuthenticator: true,
+		},
+		{
+			name:    "valid jwt authenticator with CA from Secret",
+			syncKey: controllerlib.Key{Name: "test-name"},
+			jwtAuthenticators: []runtime.Object{
+				&auth1alpha1.JWTAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-name",
+					},
+					Spec: *someJWTAuthenticatorSpecWithCASecret,
+				},
+			},
+			secrets: []runtime.Object{someCASecret},
+			wantLogs: []string{
+				`jwtcachefiller-controller "level"=0 "msg"="added new jwt authenticator" "issuer"="` + goodIssuer + `" "jwtAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries:                 1,
+			runTestsOnResultingAuthenticator: true,
+		},
+		{
+			name: "changing the CA Secret rebuilds the jwt authenticators which use it",
+			cache: func(t *testing.T, cache *authncache.Cache, wantClose bool) {
+				cache.Store(
+					authncache.Key{
+						Name:     "test-name",
+						Kind:     "JWTAuthenticator",
+						APIGroup: auth1alpha1.SchemeGroupVersion.Group,
+					},
+					newCacheValue(t, *someJWTAuthenticatorSpecWithCASecret, wantClose),
+				)
+			},
+			wantClose: true,
+			syncKey:   controllerlib.Key{Namespace: "concierge", Name: "some-ca-secret"},
+			jwtAuthenticators: []runtime.Object{
+				&auth1alpha1.JWTAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-name",
+					},
+					Spec: *someJWTAuthenticatorSpecWithCASecret,
+				},
+				&auth1alpha1.JWTAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "other-name",
+					},
+					Spec: *someJWTAuthenticatorSpec,
+				},
+			},
+			secrets: []runtime.Object{someCASecret},
+			wantLogs: []string{
+				`jwtcachefiller-controller "level"=0 "msg"="added new jwt authenticator" "issuer"="` + goodIssuer + `" "jwtAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries:                 1,
+			runTestsOnResultingAuthenticator: true,
+		},
+		{
+			name:    "CA Secret is missing",
+			syncKey: controllerlib.Key{Name: "test-name"},
+			jwtAuthenticators: []runtime.Object{
+				&auth1alpha1.JWTAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-name",
+					},
+					Spec: *someJWTAuthenticatorSpecWithCASecret,
+				},
+			},
+			wantErr: `failed to build jwt authenticator: invalid TLS configuration: failed to get CA bundle Secret "some-ca-secret": secret "some-ca-secret" not found`,
+		},
+		{
+			name:    "CA Secret is missing its key",
+			syncKey: controllerlib.Key{Name: "test-name"},
+			jwtAuthenticators: []runtime.Object{
+				&auth1alpha1.JWTAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-name",
+					},
+					Spec: *someJWTAuthenticatorSpecWithCASecret,
+				},
+			},
+			secrets: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "concierge", Name: "some-ca-secret"},
+				Data:       map[string][]byte{"tls.crt": goodCABundle},
+			}},
+			wantErr: `failed to build jwt authenticator: invalid TLS configuration: CA bundle Secret "some-ca-secret" is missing key "ca.crt"`,
+		},
+		{
+			name:    "both inline CA and CA Secret",
+			syncKey: controllerlib.Key{Name: "test-name"},
+			jwtAuthenticators: []runtime.Object{
+				&auth1alpha1.JWTAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-name",
+					},
+					Spec: auth1alpha1.JWTAuthenticatorSpec{
+						Issuer:   goodIssuer,
+						Audience: goodAudience,
+						TLS: &auth1alpha1.JWTTLSSpec{
+							CertificateAuthorityData:       someJWTAuthenticatorSpec.TLS.CertificateAuthorityData,
+							CertificateAuthoritySecretName: "some-ca-secret",
+						},
+					},
+				},
+			},
+			secrets: []runtime.Object{someCASecret},
+			wantErr: "failed to build jwt authenticator: invalid TLS configuration: only one of certificateAuthorityData and certificateAuthoritySecretName may be specified",
+		},
 		{
 			name: "updating jwt authenticator with new fields closes previous instance",
 			cache: func(t *testing.T, cache *authncache.Cache, wantClose bool) {
@@ -316,6 +463,8 @@ func TestController(t *testing.T) {
 
 			fakeClient := pinnipedfake.NewSimpleClientset(tt.jwtAuthenticators...)
 			informers := pinnipedinformers.NewSharedInformerFactory(fakeClient, 0)
+			fakeKubeClient := kubernetesfake.NewSimpleClientset(tt.secrets...)
+			kubeInformers := kubeinformers.NewSharedInformerFactoryWithOptions(fakeKubeClient, 0, kubeinformers.WithNamespace("concierge"))
 			cache := authncache.New()
 			testLog := testlogger.New(t)
 
@@ -323,15 +472,16 @@ func TestController(t *testing.T) {
 				tt.cache(t, cache, tt.wantClose)
 			}
 
-			controller := New(cache, informers.Authentication().V1alpha1().JWTAuthenticators(), testLog)
+			controller := New(cache, fakeClient, informers.Authentication().V1alpha1().JWTAuthenticators(), kubeInformers.Core().V1().Secrets(), "concierge", clock.RealClock{}, &events.FakeRecorder{}, testLog)
 
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			defer cancel()
 
 			informers.Start(ctx.Done())
+			kubeInformers.Start(ctx.Done())
 			controllerlib.TestRunSynchronously(t, controller)
 
-			syncCtx := controllerlib.Context{Context: ctx, Key: tt.syncKey}
+			syncCtx := controllerlib.Context{Context: ctx, Key: tt.syncKey, Queue: &recordingQueue{addedAfter: map[controllerlib.Key]time.Duration{}}}
 
 			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
 				require.EqualError(t, err, tt.wantErr)
@@ -358,7 +508,7 @@ func TestController(t *testing.T) {
 			expectedCacheKey := authncache.Key{
 				APIGroup: auth1alpha1.GroupName,
 				Kind:     "JWTAuthenticator",
-				Name:     syncCtx.Key.Name,
+				Name:     "test-name",
 			}
 			cachedAuthenticator := cache.Get(expectedCacheKey)
 			require.NotNil(t, cachedAuthenticator)
@@ -381,6 +531,10 @@ func TestController(t *testing.T) {
 				tt.wantGroupsClaim = "groups"
 			}
 
+			if tt.tokenAudience == "" {
+				tt.tokenAudience = goodAudience
+			}
+
 			for _, test := range testTableForAuthenticateTokenTests(
 				t,
 				goodRSASigningKey,
@@ -399,7 +553,7 @@ func TestController(t *testing.T) {
 					wellKnownClaims := jwt.Claims{
 						Issuer:    goodIssuer,
 						Subject:   goodSubject,
-						Audience:  []string{goodAudience},
+						Audience:  []string{tt.tokenAudience},
 						Expiry:    jwt.NewNumericDate(time.Now().Add(time.Hour)),
 						NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
 						IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Hour)),
@@ -612,7 +766,7 @@ func testTableForAuthenticateTokenTests(
 	return tests
 }
 
-func tlsSpecFromTLSConfig(tls *tls.Config) *auth1alpha1.TLSSpec {
+func tlsSpecFromTLSConfig(tls *tls.Config) *auth1alpha1.JWTTLSSpec {
 	pemData := make([]byte, 0)
 	for _, certificate := range tls.Certificates {
 		for _, reallyCertificate := range certificate.Certificate {
@@ -622,7 +776,7 @@ func tlsSpecFromTLSConfig(tls *tls.Config) *auth1alpha1.TLSSpec {
 			})...)
 		}
 	}
-	return &auth1alpha1.TLSSpec{
+	return &auth1alpha1.JWTTLSSpec{
 		CertificateAuthorityData: base64.StdEncoding.EncodeToString(pemData),
 	}
 }
@@ -669,9 +823,119 @@ func newCacheValue(t *testing.T, spec auth1alpha1.JWTAuthenticatorSpec, wantClos
 		wantCloses++
 	}
 	tokenAuthenticatorCloser.EXPECT().Close().Times(wantCloses)
+	tokenAuthenticatorCloser.EXPECT().AuthenticateToken(gomock.Any(), gomock.Any()).AnyTimes().
+		Return(nil, false, errors.New("oidc: verify token: failed to verify signature: failed to verify id token signature"))
+
+	caBundle, err := inlineCABundle(spec.TLS)
+	require.NoError(t, err)
 
 	return &jwtAuthenticator{
 		tokenAuthenticatorCloser: tokenAuthenticatorCloser,
 		spec:                     &spec,
+		caBundle:                 caBundle,
+	}
+}
+
+func TestUIDClaimAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	makeToken := func(claims string) string {
+		return "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2lnbmF0dXJl"
+	}
+	goodResponse := &authenticator.Response{
+		Audiences: authenticator.Audiences{"some-audience"},
+		User: &user.DefaultInfo{
+			Name:   "some-username",
+			Groups: []string{"some-group"},
+			Extra:  map[string][]string{"some-key": {"some-value"}},
+		},
+	}
+
+	tests := []struct {
+		name              string
+		token             string
+		delegateResponse  *authenticator.Response
+		delegateAuthed    bool
+		delegateErr       error
+		wantResponse      *authenticator.Response
+		wantAuthenticated bool
+		wantErr           string
+	}{
+		{
+			name:        "delegate returns error",
+			token:       makeToken(`{"uid":"some-uid"}`),
+			delegateErr: fmt.Errorf("some delegate error"),
+			wantErr:     "some delegate error",
+		},
+		{
+			name:  "delegate does not authenticate",
+			token: makeToken(`{"uid":"some-uid"}`),
+		},
+		{
+			name:              "token has uid claim",
+			token:             makeToken(`{"uid":"some-uid"}`),
+			delegateResponse:  goodResponse,
+			delegateAuthed:    true,
+			wantAuthenticated: true,
+			wantResponse: &authenticator.Response{
+				Audiences: authenticator.Audiences{"some-audience"},
+				User: &user.DefaultInfo{
+					Name:   "some-username",
+					UID:    "some-uid",
+					Groups: []string{"some-group"},
+					Extra:  map[string][]string{"some-key": {"some-value"}},
+				},
+			},
+		},
+		{
+			name:              "token does not have uid claim",
+			token:             makeToken(`{"other":"some-uid"}`),
+			delegateResponse:  goodResponse,
+			delegateAuthed:    true,
+			wantAuthenticated: true,
+			wantResponse:      goodResponse,
+		},
+		{
+			name:             "token has uid claim with wrong type",
+			token:            makeToken(`{"uid":42}`),
+			delegateResponse: goodResponse,
+			delegateAuthed:   true,
+			wantErr:          `uid claim "uid" is not a string`,
+		},
+		{
+			name:             "token has wrong number of parts",
+			token:            "e30.e30",
+			delegateResponse: goodResponse,
+			delegateAuthed:   true,
+			wantErr:          "malformed jwt, expected 3 parts got 2",
+		},
+		{
+			name:             "token has invalid claims",
+			token:            makeToken(`not json`),
+			delegateResponse: goodResponse,
+			delegateAuthed:   true,
+			wantErr:          "malformed jwt claims: invalid character 'o' in literal null (expecting 'u')",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			t.Cleanup(ctrl.Finish)
+			delegate := mocktokenauthenticatorcloser.NewMockTokenAuthenticatorCloser(ctrl)
+			delegate.EXPECT().AuthenticateToken(gomock.Any(), tt.token).Return(tt.delegateResponse, tt.delegateAuthed, tt.delegateErr)
+
+			a := &uidClaimAuthenticator{tokenAuthenticatorCloser: delegate, uidClaim: "uid"}
+			rsp, authenticated, err := a.AuthenticateToken(context.Background(), tt.token)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantAuthenticated, authenticated)
+			require.Equal(t, tt.wantResponse, rsp)
+		})
 	}
 }
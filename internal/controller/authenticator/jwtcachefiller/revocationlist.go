@@ -0,0 +1,171 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package jwtcachefiller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/revocationlist"
+)
+
+const (
+	// defaultRevocationListRefreshInterval is how often a revocation list is fetched when its spec does not say.
+	defaultRevocationListRefreshInterval = time.Minute
+
+	// revocationListTimeout is how long to wait for a revocation list.
+	revocationListTimeout = 30 * time.Second
+)
+
+// revocationListAuthenticator wraps a JWT authenticator to reject the tokens which were issued to a user before the
+// user was revoked. Users are matched by the issuer and subject of the token, rather than by the username which the
+// authenticator maps from its claims. It fetches the revocation list in the background. When the list cannot be fetched, it keeps using
+// the last list that it fetched, so an unavailable OIDC provider does not lock out every user.
+type revocationListAuthenticator struct {
+	tokenAuthenticatorCloser
+	url    string
+	client *http.Client
+	stop   chan struct{}
+
+	lock sync.RWMutex
+	list *revocationlist.List // nil until the list has been fetched for the first time
+}
+
+func newRevocationListAuthenticator(
+	delegate tokenAuthenticatorCloser,
+	spec *auth1alpha1.JWTRevocationListSpec,
+	pool *x509.CertPool,
+) *revocationListAuthenticator {
+	a := &revocationListAuthenticator{
+		tokenAuthenticatorCloser: delegate,
+		url:                      spec.URL,
+		client: &http.Client{
+			Timeout: revocationListTimeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool},
+			},
+		},
+		stop: make(chan struct{}),
+	}
+	interval := defaultRevocationListRefreshInterval
+	if spec.RefreshIntervalSeconds > 0 {
+		interval = time.Duration(spec.RefreshIntervalSeconds) * time.Second
+	}
+	go wait.Until(a.refresh, interval, a.stop)
+	return a
+}
+
+func (a *revocationListAuthenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	response, authenticated, err := a.tokenAuthenticatorCloser.AuthenticateToken(ctx, token)
+	if err != nil || !authenticated {
+		return response, authenticated, err
+	}
+
+	a.lock.RLock()
+	list := a.list
+	a.lock.RUnlock()
+	if list == nil {
+		return response, authenticated, nil
+	}
+
+	// The wrapped authenticator has already verified the signature of the JWT, so it is safe to read its claims here.
+	claims, err := revocationClaimsFromJWT(token)
+	if err != nil {
+		return nil, false, err
+	}
+	revokedAt, revoked := list.RevokedAt(claims.issuer, claims.subject)
+	if !revoked {
+		return response, authenticated, nil
+	}
+	// The revocation list has a resolution of one second, so a token which was issued in the same second is rejected.
+	if claims.issuedAt == nil || !claims.issuedAt.After(revokedAt) {
+		return nil, false, fmt.Errorf("oidc: verify token: the user was revoked at %s", revokedAt.UTC().Format(time.RFC3339))
+	}
+	return response, authenticated, nil
+}
+
+func (a *revocationListAuthenticator) Close() {
+	a.tokenAuthenticatorCloser.Close()
+	close(a.stop)
+	a.client.CloseIdleConnections()
+}
+
+func (a *revocationListAuthenticator) refresh() {
+	list, err := a.fetch()
+	if err != nil {
+		plog.WarningErr("could not fetch the revocation list of a JWTAuthenticator", err, "url", a.url)
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.list = list
+}
+
+func (a *revocationListAuthenticator) fetch() (*revocationlist.List, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), revocationListTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not build request: %w", err)
+	}
+	rsp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rsp.Body.Close() }()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP response status %d", rsp.StatusCode)
+	}
+	var list revocationlist.List
+	if err := json.NewDecoder(rsp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("could not decode revocation list: %w", err)
+	}
+	return &list, nil
+}
+
+// revocationClaims are the claims of a token which are checked against the revocation list.
+type revocationClaims struct {
+	issuer   string
+	subject  string
+	issuedAt *time.Time // nil when the token does not have an "iat" claim
+}
+
+// revocationClaimsFromJWT returns the "iss", "sub", and "iat" claims of the token.
+func revocationClaimsFromJWT(token string) (*revocationClaims, error) {
+	claims, err := unverifiedClaimsFromJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	var result revocationClaims
+	var ok bool
+	if result.issuer, ok = claims["iss"].(string); !ok {
+		return nil, fmt.Errorf("iss claim is not a string")
+	}
+	if result.subject, ok = claims["sub"].(string); !ok {
+		return nil, fmt.Errorf("sub claim is not a string")
+	}
+	iatAsInterface, ok := claims["iat"]
+	if !ok {
+		return &result, nil
+	}
+	iat, ok := iatAsInterface.(float64)
+	if !ok {
+		return nil, fmt.Errorf("iat claim is not a number")
+	}
+	issuedAt := time.Unix(int64(iat), 0)
+	result.issuedAt = &issuedAt
+	return &result, nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package jwtcachefiller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedauthenticator "go.pinniped.dev/internal/controller/authenticator"
+	"go.pinniped.dev/internal/mocks/mocktokenauthenticatorcloser"
+	"go.pinniped.dev/internal/revocationlist"
+)
+
+func TestRevocationListAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	revokedAt := time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	mux.Handle("/revoked-users", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// The revocation is only for the issuer of the test server, which is unknown until it is started.
+		require.NoError(t, json.NewEncoder(w).Encode(revocationlist.List{Revocations: []revocationlist.Revocation{{
+			Key:          base64.RawURLEncoding.EncodeToString([]byte("some-key")),
+			IdentityHMAC: revocationlist.HashIdentity([]byte("some-key"), "https://"+r.Host, "revoked-subject"),
+			RevokedAt:    revokedAt,
+		}}}))
+	}))
+	mux.Handle("/broken", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "some error", http.StatusInternalServerError)
+	}))
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: signingKey}, (&jose.SignerOptions{}).WithType("JWT"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name              string
+		path              string
+		issuer            string // defaults to the issuer of the test server
+		subject           string
+		issuedAt          *time.Time
+		delegateErr       error
+		wantAuthenticated bool
+		wantErr           string
+	}{
+		{
+			name:              "other user with the username of the revoked user is not revoked",
+			path:              "/revoked-users",
+			subject:           "some-subject",
+			issuedAt:          timePtr(revokedAt.Add(-time.Hour)),
+			wantAuthenticated: true,
+		},
+		{
+			name:              "user of the revoked subject at another issuer is not revoked",
+			path:              "/revoked-users",
+			issuer:            "https://other-issuer.example.com",
+			subject:           "revoked-subject",
+			issuedAt:          timePtr(revokedAt.Add(-time.Hour)),
+			wantAuthenticated: true,
+		},
+		{
+			name:        "delegate rejects the token",
+			path:        "/revoked-users",
+			subject:     "revoked-subject",
+			issuedAt:    timePtr(revokedAt.Add(-time.Hour)),
+			delegateErr: errors.New("oidc: verify token: failed to verify signature"),
+			wantErr:     "oidc: verify token: failed to verify signature",
+		},
+		{
+			name:     "token was issued before the user was revoked",
+			path:     "/revoked-users",
+			subject:  "revoked-subject",
+			issuedAt: timePtr(revokedAt.Add(-time.Hour)),
+			wantErr:  "oidc: verify token: the user was revoked at 2021-06-01T12:00:00Z",
+		},
+		{
+			name:     "token was issued in the same second as the revocation",
+			path:     "/revoked-users",
+			subject:  "revoked-subject",
+			issuedAt: timePtr(revokedAt),
+			wantErr:  "oidc: verify token: the user was revoked at 2021-06-01T12:00:00Z",
+		},
+		{
+			name:    "token without iat claim",
+			path:    "/revoked-users",
+			subject: "revoked-subject",
+			wantErr: "oidc: verify token: the user was revoked at 2021-06-01T12:00:00Z",
+		},
+		{
+			name:              "token was issued after the user was revoked",
+			path:              "/revoked-users",
+			subject:           "revoked-subject",
+			issuedAt:          timePtr(revokedAt.Add(time.Second)),
+			wantAuthenticated: true,
+		},
+		{
+			name:              "revocation list cannot be fetched",
+			path:              "/broken",
+			subject:           "revoked-subject",
+			issuedAt:          timePtr(revokedAt.Add(-time.Hour)),
+			wantAuthenticated: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			issuer := tt.issuer
+			if issuer == "" {
+				issuer = server.URL
+			}
+			claims := jwt.Claims{Issuer: issuer, Subject: tt.subject, Audience: jwt.Audience{"some-audience"}}
+			if tt.issuedAt != nil {
+				claims.IssuedAt = jwt.NewNumericDate(*tt.issuedAt)
+			}
+			token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+			require.NoError(t, err)
+
+			ctrl := gomock.NewController(t)
+			t.Cleanup(ctrl.Finish)
+			delegate := mocktokenauthenticatorcloser.NewMockTokenAuthenticatorCloser(ctrl)
+			delegateResponse := &authenticator.Response{User: &user.DefaultInfo{Name: "revoked-user"}}
+			if tt.delegateErr != nil {
+				delegate.EXPECT().AuthenticateToken(gomock.Any(), token).Return(nil, false, tt.delegateErr)
+			} else {
+				delegate.EXPECT().AuthenticateToken(gomock.Any(), token).Return(delegateResponse, true, nil)
+			}
+			delegate.EXPECT().Close()
+
+			caBundle, err := inlineCABundle(tlsSpecFromTLSConfig(server.TLS))
+			require.NoError(t, err)
+			pool, err := pinnipedauthenticator.CertPool(caBundle)
+			require.NoError(t, err)
+
+			a := newRevocationListAuthenticator(delegate, &auth1alpha1.JWTRevocationListSpec{URL: server.URL + tt.path}, pool)
+			t.Cleanup(a.Close)
+			if tt.path == "/revoked-users" {
+				require.Eventually(t, func() bool {
+					a.lock.RLock()
+					defer a.lock.RUnlock()
+					return a.list != nil
+				}, 10*time.Second, 10*time.Millisecond)
+			}
+
+			rsp, authenticated, err := a.AuthenticateToken(context.Background(), token)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.False(t, authenticated)
+				require.Nil(t, rsp)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantAuthenticated, authenticated)
+			require.Equal(t, delegateResponse, rsp)
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,293 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package jwtcachefiller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedauthenticator "go.pinniped.dev/internal/controller/authenticator"
+	"go.pinniped.dev/internal/controller/conditionsutil"
+	"go.pinniped.dev/internal/controllerlib"
+)
+
+const (
+	typeTLSConfigurationValid = auth1alpha1.ConditionTypeTLSConfigurationValid
+	typeIssuerReachable       = auth1alpha1.ConditionTypeIssuerReachable
+	typeAudienceValidated     = auth1alpha1.ConditionTypeAudienceValidated
+
+	reasonSuccess              = "Success"
+	reasonInvalidTLSConfig     = "InvalidTLSConfig"
+	reasonUnreachable          = "Unreachable"
+	reasonInvalidConfiguration = "InvalidConfiguration"
+	reasonInitializing         = "Initializing"
+	reasonSelfTestFailed       = "SelfTestFailed"
+	reasonUnableToValidate     = "UnableToValidate"
+
+	messageUnableToValidate = "unable to validate; see other conditions for details"
+
+	// discoveryTimeout is how long to wait for the issuer's OIDC discovery document.
+	discoveryTimeout = 30 * time.Second
+
+	// discoveryCacheDuration is how long a successful OIDC discovery against an issuer is remembered, so that
+	// every resync does not hit the issuer again.
+	discoveryCacheDuration = 5 * time.Minute
+
+	// initializationPeriod is how long a new authenticator is reported as initializing. The Kubernetes OIDC
+	// authenticator only fetches the issuer's signing keys ten seconds after it is created.
+	initializationPeriod = 15 * time.Second
+
+	// selfTestSubject is the subject of the tokens used to test authenticators.
+	selfTestSubject = "pinniped-self-test"
+)
+
+// validateTLS returns the TLSConfigurationValid condition and, when it is true, the pool of the trusted CAs. A nil
+// pool means that the system's trusted CAs are used.
+func validateTLS(caBundle []byte, caBundleErr error) (*x509.CertPool, *auth1alpha1.Condition) {
+	pool, err := pinnipedauthenticator.CertPool(caBundle)
+	if caBundleErr != nil {
+		err = caBundleErr
+	}
+	if err != nil {
+		return nil, &auth1alpha1.Condition{
+			Type:    typeTLSConfigurationValid,
+			Status:  auth1alpha1.ConditionFalse,
+			Reason:  reasonInvalidTLSConfig,
+			Message: err.Error(),
+		}
+	}
+	message := "successfully parsed specified CA bundle"
+	if pool == nil {
+		message = "no CA bundle specified, so the system's trusted CAs are used"
+	}
+	return pool, &auth1alpha1.Condition{
+		Type:    typeTLSConfigurationValid,
+		Status:  auth1alpha1.ConditionTrue,
+		Reason:  reasonSuccess,
+		Message: message,
+	}
+}
+
+// discoveryCache remembers the issuers which were recently discovered successfully, keyed by issuer and CA bundle.
+type discoveryCache struct {
+	lock    sync.Mutex
+	entries map[discoveryCacheKey]time.Time
+}
+
+type discoveryCacheKey struct {
+	issuer   string
+	caBundle string
+}
+
+func newDiscoveryCache() *discoveryCache {
+	return &discoveryCache{entries: map[discoveryCacheKey]time.Time{}}
+}
+
+// validateIssuer returns the IssuerReachable condition. Unless the issuer was discovered successfully within the
+// last discoveryCacheDuration, it performs OIDC discovery against the issuer and fetches its signing keys.
+func (d *discoveryCache) validateIssuer(ctx context.Context, now time.Time, issuer string, caBundle []byte, pool *x509.CertPool) *auth1alpha1.Condition {
+	key := discoveryCacheKey{issuer: issuer, caBundle: string(caBundle)}
+	d.lock.Lock()
+	discovered, ok := d.entries[key]
+	d.lock.Unlock()
+
+	if !ok || now.Sub(discovered) >= discoveryCacheDuration {
+		if err := discover(ctx, issuer, pool); err != nil {
+			d.lock.Lock()
+			delete(d.entries, key)
+			d.lock.Unlock()
+			return &auth1alpha1.Condition{
+				Type:    typeIssuerReachable,
+				Status:  auth1alpha1.ConditionFalse,
+				Reason:  reasonUnreachable,
+				Message: err.Error(),
+			}
+		}
+		d.lock.Lock()
+		for k, t := range d.entries {
+			if now.Sub(t) >= discoveryCacheDuration {
+				delete(d.entries, k)
+			}
+		}
+		d.entries[key] = now
+		d.lock.Unlock()
+	}
+	return &auth1alpha1.Condition{
+		Type:    typeIssuerReachable,
+		Status:  auth1alpha1.ConditionTrue,
+		Reason:  reasonSuccess,
+		Message: fmt.Sprintf("successfully performed OIDC discovery against %q", issuer),
+	}
+}
+
+// discover performs OIDC discovery against the issuer and checks that it publishes at least one signing key.
+func discover(ctx context.Context, issuer string, pool *x509.CertPool) error {
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool},
+	}}
+	defer client.CloseIdleConnections()
+
+	discoveryCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+	provider, err := coreosoidc.NewProvider(coreosoidc.ClientContext(discoveryCtx, client), issuer)
+	if err != nil {
+		return fmt.Errorf("failed to perform OIDC discovery against %q: %w", issuer, err)
+	}
+
+	var claims struct {
+		JWKSURL string `json:"jwks_uri"`
+	}
+	if err := provider.Claims(&claims); err != nil {
+		return fmt.Errorf("failed to read OIDC discovery document of %q: %w", issuer, err)
+	}
+	req, err := http.NewRequestWithContext(discoveryCtx, http.MethodGet, claims.JWKSURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the signing keys of %q: %w", issuer, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the signing keys of %q: %w", issuer, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch the signing keys of %q: unexpected status %q", issuer, resp.Status)
+	}
+	var keys jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return fmt.Errorf("failed to decode the signing keys of %q: %w", issuer, err)
+	}
+	if len(keys.Keys) == 0 {
+		return fmt.Errorf("issuer %q does not publish any signing keys", issuer)
+	}
+	return nil
+}
+
+// initializing returns the AudienceValidated condition of an authenticator which was created too recently to have
+// fetched the signing keys of the issuer.
+func initializing() *auth1alpha1.Condition {
+	return &auth1alpha1.Condition{
+		Type:    typeAudienceValidated,
+		Status:  auth1alpha1.ConditionUnknown,
+		Reason:  reasonInitializing,
+		Message: "the authenticator has not fetched the signing keys of the issuer yet",
+	}
+}
+
+// selfTest returns the AudienceValidated condition of a spec by verifying a token for each of the accepted
+// audiences with the same issuer, audience and signing algorithm checks that the authenticator uses. The token is
+// signed by a key which only the self-test trusts, so any error means that the authenticator would reject valid
+// tokens from the issuer.
+func selfTest(ctx context.Context, now time.Time, spec *auth1alpha1.JWTAuthenticatorSpec) *auth1alpha1.Condition {
+	audiences := acceptedAudiences(spec)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err == nil {
+		var token string
+		token, err = selfTestToken(key, spec.Issuer, audiences, now)
+		for _, audience := range audiences {
+			if err != nil {
+				break
+			}
+			verifier := coreosoidc.NewVerifier(spec.Issuer, &selfTestKeySet{key: &key.PublicKey}, &coreosoidc.Config{
+				ClientID:             audience,
+				SupportedSigningAlgs: defaultSupportedSigningAlgos(),
+				Now:                  func() time.Time { return now },
+			})
+			_, err = verifier.Verify(ctx, token)
+		}
+	}
+	if err != nil {
+		return &auth1alpha1.Condition{
+			Type:    typeAudienceValidated,
+			Status:  auth1alpha1.ConditionFalse,
+			Reason:  reasonSelfTestFailed,
+			Message: fmt.Sprintf("self-test token was rejected: %v", err),
+		}
+	}
+	return &auth1alpha1.Condition{
+		Type:    typeAudienceValidated,
+		Status:  auth1alpha1.ConditionTrue,
+		Reason:  reasonSuccess,
+		Message: fmt.Sprintf("the authenticator is ready to verify tokens for audiences %q", audiences),
+	}
+}
+
+// selfTestKeySet is a coreosoidc.KeySet which only trusts the key that signed a self-test token.
+type selfTestKeySet struct {
+	key *ecdsa.PublicKey
+}
+
+func (k *selfTestKeySet) VerifySignature(_ context.Context, token string) ([]byte, error) {
+	jws, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+	return jws.Verify(k.key)
+}
+
+func selfTestToken(key *ecdsa.PrivateKey, issuer string, audiences []string, now time.Time) (string, error) {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", selfTestSubject),
+	)
+	if err != nil {
+		return "", err
+	}
+	return jwt.Signed(signer).Claims(jwt.Claims{
+		Issuer:    issuer,
+		Subject:   selfTestSubject,
+		Audience:  audiences,
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Expiry:    jwt.NewNumericDate(now.Add(5 * time.Minute)),
+	}).CompactSerialize()
+}
+
+// unableToValidate returns conditions of the given types which say that they could not be validated.
+func unableToValidate(types ...string) []*auth1alpha1.Condition {
+	conditions := make([]*auth1alpha1.Condition, 0, len(types))
+	for _, t := range types {
+		conditions = append(conditions, &auth1alpha1.Condition{
+			Type:    t,
+			Status:  auth1alpha1.ConditionUnknown,
+			Reason:  reasonUnableToValidate,
+			Message: messageUnableToValidate,
+		})
+	}
+	return conditions
+}
+
+func (c *controller) updateStatus(ctx controllerlib.Context, obj *auth1alpha1.JWTAuthenticator, conditions []*auth1alpha1.Condition) error {
+	newConditions := make([]auth1alpha1.Condition, 0, len(conditions))
+	for _, cond := range conditions {
+		newConditions = append(newConditions, *cond)
+	}
+	merged := conditionsutil.FromAuthenticationV1alpha1(obj.Status.Conditions)
+	if !conditionsutil.MergeWithReady(&merged, obj.Generation, metav1.NewTime(c.clock.Now()), conditionsutil.FromAuthenticationV1alpha1(newConditions)...) {
+		return nil
+	}
+	updated := obj.DeepCopy()
+	updated.Status.Conditions = conditionsutil.ToAuthenticationV1alpha1(merged)
+
+	if _, err := c.client.AuthenticationV1alpha1().JWTAuthenticators().UpdateStatus(ctx.Context, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update status of JWTAuthenticator %s: %w", obj.Name, err)
+	}
+	conditionsutil.RecordTransitions(ctx.Recorder, obj, "ValidateJWTAuthenticator", conditionsutil.FromAuthenticationV1alpha1(obj.Status.Conditions), merged)
+	return nil
+}
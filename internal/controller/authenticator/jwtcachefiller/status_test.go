@@ -0,0 +1,355 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package jwtcachefiller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	kubeinformers "k8s.io/client-go/informers"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/events"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	pinnipedinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/testutil/testlogger"
+)
+
+func TestControllerStatus(t *testing.T) {
+	t.Parallel()
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"issuer": "%s", "jwks_uri": "%s"}`, server.URL, server.URL+"/jwks.json")
+	})
+	mux.HandleFunc("/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		jwk := jose.JSONWebKey{Key: signingKey, KeyID: "some-key-id", Algorithm: string(jose.ES256), Use: "sig"}
+		require.NoError(t, json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}}))
+	})
+
+	start := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	condition := func(conditionType string, status auth1alpha1.ConditionStatus, transitioned time.Time, reason, message string) auth1alpha1.Condition {
+		return auth1alpha1.Condition{
+			Type:               conditionType,
+			Status:             status,
+			ObservedGeneration: 3,
+			LastTransitionTime: metav1.NewTime(transitioned),
+			Reason:             reason,
+			Message:            message,
+		}
+	}
+
+	tests := []struct {
+		name           string
+		spec           auth1alpha1.JWTAuthenticatorSpec
+		wantErr        string
+		wantRetry      bool
+		wantConditions []auth1alpha1.Condition
+		wantEvents     []string
+		wantFinal      []auth1alpha1.Condition
+		wantFinalEvent string
+	}{
+		{
+			name: "working authenticator",
+			spec: auth1alpha1.JWTAuthenticatorSpec{
+				Issuer:   server.URL,
+				Audience: "some-audience",
+				TLS:      tlsSpecFromTLSConfig(server.TLS),
+			},
+			wantRetry: true,
+			wantConditions: []auth1alpha1.Condition{
+				condition("AudienceValidated", "Unknown", start, "Initializing", "the authenticator has not fetched the signing keys of the issuer yet"),
+				condition("IssuerReachable", "True", start, "Success", fmt.Sprintf("successfully performed OIDC discovery against %q", server.URL)),
+				condition("Ready", "Unknown", start, "Initializing", "AudienceValidated is Unknown: the authenticator has not fetched the signing keys of the issuer yet"),
+				condition("TLSConfigurationValid", "True", start, "Success", "successfully parsed specified CA bundle"),
+			},
+			wantFinal: []auth1alpha1.Condition{
+				condition("AudienceValidated", "True", start.Add(time.Minute), "Success", `the authenticator is ready to verify tokens for audiences ["some-audience"]`),
+				condition("IssuerReachable", "True", start, "Success", fmt.Sprintf("successfully performed OIDC discovery against %q", server.URL)),
+				condition("Ready", "True", start.Add(time.Minute), "Success", "all conditions are true"),
+				condition("TLSConfigurationValid", "True", start, "Success", "successfully parsed specified CA bundle"),
+			},
+			wantFinalEvent: "Normal Success all conditions are true",
+		},
+		{
+			name: "invalid CA bundle",
+			spec: auth1alpha1.JWTAuthenticatorSpec{
+				Issuer:   server.URL,
+				Audience: "some-audience",
+				TLS:      &auth1alpha1.JWTTLSSpec{CertificateAuthorityData: "bm90IGEgY2VydGlmaWNhdGU="},
+			},
+			wantErr: "failed to build jwt authenticator: invalid TLS configuration: CA bundle does not contain any PEM-encoded certificates",
+			wantConditions: []auth1alpha1.Condition{
+				condition("AudienceValidated", "Unknown", start, "UnableToValidate", "unable to validate; see other conditions for details"),
+				condition("IssuerReachable", "Unknown", start, "UnableToValidate", "unable to validate; see other conditions for details"),
+				condition("Ready", "False", start, "InvalidTLSConfig", "TLSConfigurationValid is False: CA bundle does not contain any PEM-encoded certificates"),
+				condition("TLSConfigurationValid", "False", start, "InvalidTLSConfig", "CA bundle does not contain any PEM-encoded certificates"),
+			},
+			wantEvents: []string{"Warning InvalidTLSConfig TLSConfigurationValid is False: CA bundle does not contain any PEM-encoded certificates"},
+		},
+		{
+			name: "unreachable issuer",
+			spec: auth1alpha1.JWTAuthenticatorSpec{
+				Issuer:   server.URL + "/missing",
+				Audience: "some-audience",
+				TLS:      tlsSpecFromTLSConfig(server.TLS),
+			},
+			wantConditions: []auth1alpha1.Condition{
+				condition("AudienceValidated", "Unknown", start, "Initializing", "the authenticator has not fetched the signing keys of the issuer yet"),
+				condition("IssuerReachable", "False", start, "Unreachable", fmt.Sprintf("failed to perform OIDC discovery against %q: 404 Not Found: 404 page not found\n", server.URL+"/missing")),
+				condition("Ready", "False", start, "Unreachable", fmt.Sprintf("IssuerReachable is False: failed to perform OIDC discovery against %q: 404 Not Found: 404 page not found\n", server.URL+"/missing")),
+				condition("TLSConfigurationValid", "True", start, "Success", "successfully parsed specified CA bundle"),
+			},
+			wantEvents: []string{fmt.Sprintf("Warning Unreachable IssuerReachable is False: failed to perform OIDC discovery against %q: 404 Not Found: 404 page not found\n", server.URL+"/missing")},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeClient := pinnipedfake.NewSimpleClientset(&auth1alpha1.JWTAuthenticator{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-name", Generation: 3},
+				Spec:       tt.spec,
+			})
+			informers := pinnipedinformers.NewSharedInformerFactory(fakeClient, 0)
+			kubeInformers := kubeinformers.NewSharedInformerFactoryWithOptions(kubernetesfake.NewSimpleClientset(), 0, kubeinformers.WithNamespace("concierge"))
+			cache := authncache.New()
+			fakeClock := clock.NewFakeClock(start)
+			recorder := events.NewFakeRecorder(10)
+			controller := New(cache, fakeClient, informers.Authentication().V1alpha1().JWTAuthenticators(), kubeInformers.Core().V1().Secrets(), "concierge", fakeClock, recorder, testlogger.New(t))
+			t.Cleanup(func() {
+				if value, ok := cache.Get(authncache.Key{APIGroup: auth1alpha1.GroupName, Kind: "JWTAuthenticator", Name: "test-name"}).(*jwtAuthenticator); ok {
+					value.Close()
+				}
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			informers.Start(ctx.Done())
+			kubeInformers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			sync := func() *recordingQueue {
+				t.Helper()
+				queue := &recordingQueue{addedAfter: map[controllerlib.Key]time.Duration{}}
+				err := controllerlib.TestSync(t, controller, controllerlib.Context{Context: ctx, Key: controllerlib.Key{Name: "test-name"}, Queue: queue})
+				if tt.wantErr != "" {
+					require.EqualError(t, err, tt.wantErr)
+				} else {
+					require.NoError(t, err)
+				}
+				return queue
+			}
+			requireConditions := func(want []auth1alpha1.Condition) {
+				t.Helper()
+				obj, err := fakeClient.AuthenticationV1alpha1().JWTAuthenticators().Get(ctx, "test-name", metav1.GetOptions{})
+				require.NoError(t, err)
+				require.Equal(t, want, obj.Status.Conditions)
+			}
+
+			requireEvents := func(want []string) {
+				t.Helper()
+				var got []string
+				for len(recorder.Events) > 0 {
+					got = append(got, <-recorder.Events)
+				}
+				require.Equal(t, want, got)
+			}
+
+			queue := sync()
+			requireConditions(tt.wantConditions)
+			requireEvents(tt.wantEvents)
+			if tt.wantRetry {
+				require.Equal(t, map[controllerlib.Key]time.Duration{{Name: "test-name"}: 15 * time.Second}, queue.addedAfter)
+			} else {
+				require.Empty(t, queue.addedAfter)
+			}
+			if tt.wantFinal == nil {
+				return
+			}
+
+			require.NoError(t, informers.Authentication().V1alpha1().JWTAuthenticators().Informer().GetStore().Update(mustGet(t, fakeClient)))
+			fakeClock.Step(time.Minute)
+			queue = sync()
+			requireConditions(tt.wantFinal)
+			requireEvents([]string{tt.wantFinalEvent})
+			require.Empty(t, queue.addedAfter)
+		})
+	}
+}
+
+func mustGet(t *testing.T, fakeClient *pinnipedfake.Clientset) *auth1alpha1.JWTAuthenticator {
+	t.Helper()
+	obj, err := fakeClient.AuthenticationV1alpha1().JWTAuthenticators().Get(context.Background(), "test-name", metav1.GetOptions{})
+	require.NoError(t, err)
+	return obj
+}
+
+func TestSelfTest(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	tests := []struct {
+		name        string
+		spec        *auth1alpha1.JWTAuthenticatorSpec
+		wantStatus  auth1alpha1.ConditionStatus
+		wantReason  string
+		wantMessage string
+	}{
+		{
+			name: "valid audiences",
+			spec: &auth1alpha1.JWTAuthenticatorSpec{
+				Issuer:    "https://issuer.example.com",
+				Audience:  "some-audience",
+				Audiences: []string{"other-audience"},
+			},
+			wantStatus:  auth1alpha1.ConditionTrue,
+			wantReason:  "Success",
+			wantMessage: `the authenticator is ready to verify tokens for audiences ["some-audience" "other-audience"]`,
+		},
+		{
+			name: "empty audience",
+			spec: &auth1alpha1.JWTAuthenticatorSpec{
+				Issuer: "https://issuer.example.com",
+			},
+			wantStatus:  auth1alpha1.ConditionFalse,
+			wantReason:  "SelfTestFailed",
+			wantMessage: "self-test token was rejected: oidc: invalid configuration, clientID must be provided or SkipClientIDCheck must be set",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cond := selfTest(context.Background(), now, tt.spec)
+			require.Equal(t, "AudienceValidated", cond.Type)
+			require.Equal(t, tt.wantStatus, cond.Status)
+			require.Equal(t, tt.wantReason, cond.Reason)
+			require.Equal(t, tt.wantMessage, cond.Message)
+		})
+	}
+}
+
+func TestSelfTestToken(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	now := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	token, err := selfTestToken(key, "https://issuer.example.com", []string{"aud-1", "aud-2"}, now)
+	require.NoError(t, err)
+	require.Len(t, strings.Split(token, "."), 3)
+
+	parsed, err := jwt.ParseSigned(token)
+	require.NoError(t, err)
+	require.Equal(t, "pinniped-self-test", parsed.Headers[0].KeyID)
+	var claims jwt.Claims
+	require.NoError(t, parsed.Claims(&key.PublicKey, &claims))
+	require.Equal(t, "https://issuer.example.com", claims.Issuer)
+	require.Equal(t, "pinniped-self-test", claims.Subject)
+	require.Equal(t, jwt.Audience{"aud-1", "aud-2"}, claims.Audience)
+	require.Equal(t, jwt.NewNumericDate(now), claims.IssuedAt)
+
+	payload, err := (&selfTestKeySet{key: &key.PublicKey}).VerifySignature(context.Background(), token)
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = (&selfTestKeySet{key: &otherKey.PublicKey}).VerifySignature(context.Background(), token)
+	require.Error(t, err)
+}
+
+func TestDiscoveryCache(t *testing.T) {
+	t.Parallel()
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	var discoveries, keyFetches int32
+	var publishKeys atomic.Value
+	publishKeys.Store(true)
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&discoveries, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"issuer": "%s", "jwks_uri": "%s"}`, server.URL, server.URL+"/jwks.json")
+	})
+	mux.HandleFunc("/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&keyFetches, 1)
+		keys := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{}}
+		if publishKeys.Load().(bool) {
+			jwk := jose.JSONWebKey{Key: signingKey, KeyID: "some-key-id", Algorithm: string(jose.ES256), Use: "sig"}
+			keys.Keys = append(keys.Keys, jwk.Public())
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(keys))
+	})
+	caBundle, err := inlineCABundle(tlsSpecFromTLSConfig(server.TLS))
+	require.NoError(t, err)
+	pool, _ := validateTLS(caBundle, nil)
+
+	cache := newDiscoveryCache()
+	now := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	validate := func() *auth1alpha1.Condition {
+		return cache.validateIssuer(context.Background(), now, server.URL, caBundle, pool)
+	}
+
+	require.Equal(t, auth1alpha1.ConditionTrue, validate().Status)
+	require.Equal(t, int32(1), atomic.LoadInt32(&discoveries))
+	require.Equal(t, int32(1), atomic.LoadInt32(&keyFetches))
+
+	// A recent successful discovery is reused.
+	now = now.Add(discoveryCacheDuration - time.Second)
+	require.Equal(t, auth1alpha1.ConditionTrue, validate().Status)
+	require.Equal(t, int32(1), atomic.LoadInt32(&discoveries))
+
+	// An expired one is performed again, and failures are not cached.
+	publishKeys.Store(false)
+	now = now.Add(time.Second)
+	cond := validate()
+	require.Equal(t, auth1alpha1.ConditionFalse, cond.Status)
+	require.Equal(t, "Unreachable", cond.Reason)
+	require.Equal(t, fmt.Sprintf("issuer %q does not publish any signing keys", server.URL), cond.Message)
+	require.Equal(t, int32(2), atomic.LoadInt32(&discoveries))
+
+	publishKeys.Store(true)
+	require.Equal(t, auth1alpha1.ConditionTrue, validate().Status)
+	require.Equal(t, int32(3), atomic.LoadInt32(&discoveries))
+	require.Equal(t, int32(3), atomic.LoadInt32(&keyFetches))
+}
+
+type recordingQueue struct {
+	addedAfter map[controllerlib.Key]time.Duration
+}
+
+func (q *recordingQueue) Add(controllerlib.Key)            {}
+func (q *recordingQueue) AddRateLimited(controllerlib.Key) {}
+func (q *recordingQueue) AddAfter(key controllerlib.Key, duration time.Duration) {
+	q.addedAfter[key] = duration
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package serviceaccountcachefiller implements a controller for filling an authncache.Cache with each
+// added/updated ServiceAccountAuthenticator.
+package serviceaccountcachefiller
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	authenticationv1client "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	"k8s.io/klog/v2"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	authinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions/authentication/v1alpha1"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/serviceaccounttoken"
+)
+
+type serviceAccountAuthenticator struct {
+	*serviceaccounttoken.Authenticator
+	spec *auth1alpha1.ServiceAccountAuthenticatorSpec
+}
+
+// New instantiates a new controllerlib.Controller which will populate the provided authncache.Cache. The
+// authenticators review tokens with the provided TokenReview client.
+func New(
+	cache *authncache.Cache,
+	serviceAccountAuthenticators authinformers.ServiceAccountAuthenticatorInformer,
+	tokenReviews authenticationv1client.TokenReviewInterface,
+	log logr.Logger,
+	opts ...controllerlib.Option,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "serviceaccountcachefiller-controller",
+			Syncer: &controller{
+				cache:                        cache,
+				serviceAccountAuthenticators: serviceAccountAuthenticators,
+				tokenReviews:                 tokenReviews,
+				log:                          log.WithName("serviceaccountcachefiller-controller"),
+			},
+		},
+		append([]controllerlib.Option{
+			controllerlib.WithInformer(
+				serviceAccountAuthenticators,
+				pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
+	)
+}
+
+type controller struct {
+	cache                        *authncache.Cache
+	serviceAccountAuthenticators authinformers.ServiceAccountAuthenticatorInformer
+	tokenReviews                 authenticationv1client.TokenReviewInterface
+	log                          logr.Logger
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *controller) Sync(ctx controllerlib.Context) error {
+	obj, err := c.serviceAccountAuthenticators.Lister().Get(ctx.Key.Name)
+	if err != nil && errors.IsNotFound(err) {
+		c.log.Info("Sync() found that the ServiceAccountAuthenticator does not exist yet or was deleted")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get ServiceAccountAuthenticator %s/%s: %w", ctx.Key.Namespace, ctx.Key.Name, err)
+	}
+
+	cacheKey := authncache.Key{
+		APIGroup: auth1alpha1.GroupName,
+		Kind:     "ServiceAccountAuthenticator",
+		Name:     ctx.Key.Name,
+	}
+
+	// Only recreate the authenticator if its spec has changed.
+	if existing, ok := c.cache.Get(cacheKey).(*serviceAccountAuthenticator); ok && reflect.DeepEqual(existing.spec, &obj.Spec) {
+		c.log.WithValues("serviceAccountAuthenticator", klog.KObj(obj)).Info("actual service account authenticator and desired service account authenticator are the same")
+		return nil
+	}
+
+	// Make a deep copy of the spec so we aren't storing pointers to something that the informer cache
+	// may mutate!
+	spec := obj.Spec.DeepCopy()
+	authenticator, err := serviceaccounttoken.New(spec, c.tokenReviews)
+	if err != nil {
+		// Stop accepting the previous audiences, since the spec which configured them has changed.
+		c.cache.Delete(cacheKey)
+		return fmt.Errorf("failed to build service account authenticator: %w", err)
+	}
+
+	c.cache.Store(cacheKey, &serviceAccountAuthenticator{Authenticator: authenticator, spec: spec})
+	c.log.WithValues("serviceAccountAuthenticator", klog.KObj(obj), "audiences", spec.Audiences).Info("added new service account authenticator")
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package serviceaccountcachefiller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	pinnipedinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/serviceaccounttoken"
+	"go.pinniped.dev/internal/testutil/testlogger"
+)
+
+func TestController(t *testing.T) {
+	t.Parallel()
+
+	tokenReviews := kubernetesfake.NewSimpleClientset().AuthenticationV1().TokenReviews()
+	someSpec := auth1alpha1.ServiceAccountAuthenticatorSpec{
+		Audiences: []string{"some-audience"},
+	}
+	otherSpec := auth1alpha1.ServiceAccountAuthenticatorSpec{
+		Audiences:  []string{"some-audience"},
+		Namespaces: []string{"some-namespace"},
+	}
+	cacheKey := authncache.Key{
+		APIGroup: auth1alpha1.SchemeGroupVersion.Group,
+		Kind:     "ServiceAccountAuthenticator",
+		Name:     "test-name",
+	}
+	newCacheValue := func(t *testing.T, spec auth1alpha1.ServiceAccountAuthenticatorSpec) *serviceAccountAuthenticator {
+		t.Helper()
+		a, err := serviceaccounttoken.New(&spec, tokenReviews)
+		require.NoError(t, err)
+		return &serviceAccountAuthenticator{Authenticator: a, spec: &spec}
+	}
+
+	tests := []struct {
+		name                 string
+		authenticators       []runtime.Object
+		cache                func(*testing.T, *authncache.Cache) authncache.Value
+		wantErr              string
+		wantLogs             []string
+		wantCacheEntries     int
+		wantCacheValueReused bool
+	}{
+		{
+			name: "not found",
+			wantLogs: []string{
+				`serviceaccountcachefiller-controller "level"=0 "msg"="Sync() found that the ServiceAccountAuthenticator does not exist yet or was deleted"`,
+			},
+		},
+		{
+			name: "invalid spec",
+			authenticators: []runtime.Object{
+				&auth1alpha1.ServiceAccountAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+				},
+			},
+			wantErr: "failed to build service account authenticator: at least one audience must be specified",
+		},
+		{
+			name: "invalid spec removes the previous authenticator",
+			cache: func(t *testing.T, cache *authncache.Cache) authncache.Value {
+				value := newCacheValue(t, someSpec)
+				cache.Store(cacheKey, value)
+				return value
+			},
+			authenticators: []runtime.Object{
+				&auth1alpha1.ServiceAccountAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+					Spec:       auth1alpha1.ServiceAccountAuthenticatorSpec{Namespaces: []string{"some-namespace"}},
+				},
+			},
+			wantErr: "failed to build service account authenticator: at least one audience must be specified",
+		},
+		{
+			name: "valid authenticator",
+			authenticators: []runtime.Object{
+				&auth1alpha1.ServiceAccountAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+					Spec:       someSpec,
+				},
+			},
+			wantLogs: []string{
+				`serviceaccountcachefiller-controller "level"=0 "msg"="added new service account authenticator" "audiences"=["some-audience"] "serviceAccountAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries: 1,
+		},
+		{
+			name: "unchanged authenticator is not recreated",
+			cache: func(t *testing.T, cache *authncache.Cache) authncache.Value {
+				value := newCacheValue(t, someSpec)
+				cache.Store(cacheKey, value)
+				return value
+			},
+			authenticators: []runtime.Object{
+				&auth1alpha1.ServiceAccountAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+					Spec:       someSpec,
+				},
+			},
+			wantLogs: []string{
+				`serviceaccountcachefiller-controller "level"=0 "msg"="actual service account authenticator and desired service account authenticator are the same" "serviceAccountAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries:     1,
+			wantCacheValueReused: true,
+		},
+		{
+			name: "changed authenticator is recreated",
+			cache: func(t *testing.T, cache *authncache.Cache) authncache.Value {
+				value := newCacheValue(t, otherSpec)
+				cache.Store(cacheKey, value)
+				return value
+			},
+			authenticators: []runtime.Object{
+				&auth1alpha1.ServiceAccountAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+					Spec:       someSpec,
+				},
+			},
+			wantLogs: []string{
+				`serviceaccountcachefiller-controller "level"=0 "msg"="added new service account authenticator" "audiences"=["some-audience"] "serviceAccountAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeClient := pinnipedfake.NewSimpleClientset(tt.authenticators...)
+			informers := pinnipedinformers.NewSharedInformerFactory(fakeClient, 0)
+			cache := authncache.New()
+			testLog := testlogger.New(t)
+
+			var initialValue authncache.Value
+			if tt.cache != nil {
+				initialValue = tt.cache(t, cache)
+			}
+
+			controller := New(cache, informers.Authentication().V1alpha1().ServiceAccountAuthenticators(), tokenReviews, testLog)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			informers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{Name: "test-name"}}
+
+			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantLogs, testLog.Lines())
+			require.Equal(t, tt.wantCacheEntries, len(cache.Keys()))
+
+			if initialValue != nil {
+				if tt.wantCacheValueReused {
+					require.Same(t, initialValue, cache.Get(cacheKey))
+				} else {
+					require.NotSame(t, initialValue, cache.Get(cacheKey))
+				}
+			}
+		})
+	}
+}
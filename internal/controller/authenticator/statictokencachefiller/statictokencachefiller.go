@@ -0,0 +1,211 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package statictokencachefiller implements a controller for filling an authncache.Cache with each
+// added/updated StaticTokenAuthenticator.
+package statictokencachefiller
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/clock"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/klog/v2"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
+	authinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions/authentication/v1alpha1"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controller/conditionsutil"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/statictoken"
+)
+
+const (
+	typeSecretValid = auth1alpha1.ConditionTypeSecretValid
+
+	reasonSuccess       = "Success"
+	reasonInvalidSecret = "InvalidSecret"
+)
+
+type staticTokenAuthenticator struct {
+	*statictoken.Authenticator
+	spec   *auth1alpha1.StaticTokenAuthenticatorSpec
+	hashes map[string][]byte
+}
+
+// New instantiates a new controllerlib.Controller which will populate the provided authncache.Cache. The
+// secretInformer and namespace are used to read the token hashes of each StaticTokenAuthenticator. An Event is
+// recorded whenever the status conditions of a StaticTokenAuthenticator change.
+func New(
+	cache *authncache.Cache,
+	client pinnipedclientset.Interface,
+	staticTokenAuthenticators authinformers.StaticTokenAuthenticatorInformer,
+	secretInformer corev1informers.SecretInformer,
+	namespace string,
+	clock clock.PassiveClock,
+	recorder events.EventRecorder,
+	log logr.Logger,
+	opts ...controllerlib.Option,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "statictokencachefiller-controller",
+			Syncer: &controller{
+				cache:                     cache,
+				client:                    client,
+				staticTokenAuthenticators: staticTokenAuthenticators,
+				secretInformer:            secretInformer,
+				namespace:                 namespace,
+				clock:                     clock,
+				log:                       log.WithName("statictokencachefiller-controller"),
+			},
+		},
+		append([]controllerlib.Option{
+			controllerlib.WithRecorder(recorder),
+			controllerlib.WithInformer(
+				staticTokenAuthenticators,
+				pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+			// StaticTokenAuthenticators are cluster-scoped, so the keys of Secret events can be told apart by their namespace.
+			controllerlib.WithInformer(
+				secretInformer,
+				pinnipedcontroller.MatchAnythingFilter(nil),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
+	)
+}
+
+type controller struct {
+	cache                     *authncache.Cache
+	client                    pinnipedclientset.Interface
+	staticTokenAuthenticators authinformers.StaticTokenAuthenticatorInformer
+	secretInformer            corev1informers.SecretInformer
+	namespace                 string
+	clock                     clock.PassiveClock
+	log                       logr.Logger
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *controller) Sync(ctx controllerlib.Context) error {
+	if ctx.Key.Namespace != "" {
+		return c.syncAuthenticatorsUsingSecret(ctx, ctx.Key.Name)
+	}
+	return c.syncAuthenticator(ctx, ctx.Key.Name)
+}
+
+// syncAuthenticatorsUsingSecret syncs each StaticTokenAuthenticator which reads its token hashes from the named Secret.
+func (c *controller) syncAuthenticatorsUsingSecret(ctx controllerlib.Context, secretName string) error {
+	objs, err := c.staticTokenAuthenticators.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list StaticTokenAuthenticators: %w", err)
+	}
+	var errs []error
+	for _, obj := range objs {
+		if obj.Spec.SecretName != secretName {
+			continue
+		}
+		if err := c.syncAuthenticator(ctx, obj.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (c *controller) syncAuthenticator(ctx controllerlib.Context, name string) error {
+	obj, err := c.staticTokenAuthenticators.Lister().Get(name)
+	if err != nil && errors.IsNotFound(err) {
+		c.log.Info("Sync() found that the StaticTokenAuthenticator does not exist yet or was deleted")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get StaticTokenAuthenticator %s: %w", name, err)
+	}
+
+	cacheKey := authncache.Key{
+		APIGroup: auth1alpha1.GroupName,
+		Kind:     "StaticTokenAuthenticator",
+		Name:     name,
+	}
+
+	authenticator, err := c.newAuthenticator(obj)
+	if err != nil {
+		// Deleting the Secret (or a key of it) is how the tokens are revoked, so stop accepting the old tokens
+		// rather than leaving the previous authenticator in the cache.
+		c.cache.Delete(cacheKey)
+		return utilerrors.NewAggregate([]error{
+			fmt.Errorf("failed to build static token authenticator: %w", err),
+			c.updateStatus(ctx, obj, &auth1alpha1.Condition{
+				Type:    typeSecretValid,
+				Status:  auth1alpha1.ConditionFalse,
+				Reason:  reasonInvalidSecret,
+				Message: err.Error(),
+			}),
+		})
+	}
+
+	if existing, ok := c.cache.Get(cacheKey).(*staticTokenAuthenticator); ok &&
+		reflect.DeepEqual(existing.spec, authenticator.spec) && reflect.DeepEqual(existing.hashes, authenticator.hashes) {
+		c.log.WithValues("staticTokenAuthenticator", klog.KObj(obj)).Info("actual static token authenticator and desired static token authenticator are the same")
+	} else {
+		c.cache.Store(cacheKey, authenticator)
+		c.log.WithValues("staticTokenAuthenticator", klog.KObj(obj), "tokens", len(obj.Spec.Tokens)).Info("added new static token authenticator")
+	}
+
+	return c.updateStatus(ctx, obj, &auth1alpha1.Condition{
+		Type:    typeSecretValid,
+		Status:  auth1alpha1.ConditionTrue,
+		Reason:  reasonSuccess,
+		Message: fmt.Sprintf("loaded the hashes of %d tokens", len(obj.Spec.Tokens)),
+	})
+}
+
+// newAuthenticator reads the token hashes of the StaticTokenAuthenticator from its Secret and builds its authenticator.
+func (c *controller) newAuthenticator(obj *auth1alpha1.StaticTokenAuthenticator) (*staticTokenAuthenticator, error) {
+	secret, err := c.secretInformer.Lister().Secrets(c.namespace).Get(obj.Spec.SecretName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token Secret %q: %w", obj.Spec.SecretName, err)
+	}
+
+	// Make deep copies so we aren't storing pointers to something that the informer cache may mutate!
+	spec := obj.Spec.DeepCopy()
+	hashes := make(map[string][]byte, len(spec.Tokens))
+	for _, t := range spec.Tokens {
+		if value, ok := secret.Data[t.Name]; ok {
+			hashes[t.Name] = append([]byte{}, value...)
+		}
+	}
+
+	authenticator, err := statictoken.New(spec, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return &staticTokenAuthenticator{Authenticator: authenticator, spec: spec, hashes: hashes}, nil
+}
+
+func (c *controller) updateStatus(ctx controllerlib.Context, obj *auth1alpha1.StaticTokenAuthenticator, condition *auth1alpha1.Condition) error {
+	merged := conditionsutil.FromAuthenticationV1alpha1(obj.Status.Conditions)
+	newConditions := conditionsutil.FromAuthenticationV1alpha1([]auth1alpha1.Condition{*condition})
+	if !conditionsutil.MergeWithReady(&merged, obj.Generation, metav1.NewTime(c.clock.Now()), newConditions...) {
+		return nil
+	}
+	updated := obj.DeepCopy()
+	updated.Status.Conditions = conditionsutil.ToAuthenticationV1alpha1(merged)
+
+	if _, err := c.client.AuthenticationV1alpha1().StaticTokenAuthenticators().UpdateStatus(ctx.Context, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update status of StaticTokenAuthenticator %s: %w", obj.Name, err)
+	}
+	conditionsutil.RecordTransitions(ctx.Recorder, obj, "ValidateStaticTokenAuthenticator", conditionsutil.FromAuthenticationV1alpha1(obj.Status.Conditions), merged)
+	return nil
+}
+
@@ -0,0 +1,276 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package statictokencachefiller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	kubeinformers "k8s.io/client-go/informers"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	coretesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/events"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	pinnipedinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/statictoken"
+	"go.pinniped.dev/internal/testutil/testlogger"
+)
+
+func hashOf(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return []byte(hex.EncodeToString(hash[:]))
+}
+
+func TestController(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, time.April, 1, 2, 3, 4, 0, time.UTC)
+	someSpec := auth1alpha1.StaticTokenAuthenticatorSpec{
+		SecretName: "some-secret",
+		Tokens:     []auth1alpha1.StaticToken{{Name: "break-glass", Username: "emergency-admin", Groups: []string{"admins"}}},
+	}
+	someSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "some-secret", Namespace: "concierge"},
+		Data:       map[string][]byte{"break-glass": hashOf("some-token")},
+	}
+	someAuthenticator := &auth1alpha1.StaticTokenAuthenticator{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-name", Generation: 2},
+		Spec:       someSpec,
+	}
+	cacheKey := authncache.Key{
+		APIGroup: auth1alpha1.GroupName,
+		Kind:     "StaticTokenAuthenticator",
+		Name:     "test-name",
+	}
+	validCondition := auth1alpha1.Condition{
+		Type:               "SecretValid",
+		Status:             auth1alpha1.ConditionTrue,
+		ObservedGeneration: 2,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "Success",
+		Message:            "loaded the hashes of 1 tokens",
+	}
+	invalidCondition := func(message string) auth1alpha1.Condition {
+		return auth1alpha1.Condition{
+			Type:               "SecretValid",
+			Status:             auth1alpha1.ConditionFalse,
+			ObservedGeneration: 2,
+			LastTransitionTime: metav1.NewTime(now),
+			Reason:             "InvalidSecret",
+			Message:            message,
+		}
+	}
+	readyCondition := auth1alpha1.Condition{
+		Type:               "Ready",
+		Status:             auth1alpha1.ConditionTrue,
+		ObservedGeneration: 2,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "Success",
+		Message:            "all conditions are true",
+	}
+	notReadyCondition := func(message string) auth1alpha1.Condition {
+		return auth1alpha1.Condition{
+			Type:               "Ready",
+			Status:             auth1alpha1.ConditionFalse,
+			ObservedGeneration: 2,
+			LastTransitionTime: metav1.NewTime(now),
+			Reason:             "InvalidSecret",
+			Message:            "SecretValid is False: " + message,
+		}
+	}
+	withConditions := func(conditions ...auth1alpha1.Condition) *auth1alpha1.StaticTokenAuthenticator {
+		obj := someAuthenticator.DeepCopy()
+		obj.Status.Conditions = conditions
+		return obj
+	}
+	newCacheValue := func(t *testing.T, hash []byte) *staticTokenAuthenticator {
+		t.Helper()
+		spec := someSpec.DeepCopy()
+		hashes := map[string][]byte{"break-glass": hash}
+		a, err := statictoken.New(spec, hashes)
+		require.NoError(t, err)
+		return &staticTokenAuthenticator{Authenticator: a, spec: spec, hashes: hashes}
+	}
+
+	tests := []struct {
+		name                 string
+		syncKey              controllerlib.Key
+		authenticators       []runtime.Object
+		secrets              []runtime.Object
+		cache                func(*testing.T, *authncache.Cache) authncache.Value
+		wantErr              string
+		wantLogs             []string
+		wantStatusUpdate     *auth1alpha1.StaticTokenAuthenticator
+		wantCacheEntries     int
+		wantCacheValueReused bool
+	}{
+		{
+			name:    "not found",
+			syncKey: controllerlib.Key{Name: "test-name"},
+			wantLogs: []string{
+				`statictokencachefiller-controller "level"=0 "msg"="Sync() found that the StaticTokenAuthenticator does not exist yet or was deleted"`,
+			},
+		},
+		{
+			name:           "valid authenticator",
+			syncKey:        controllerlib.Key{Name: "test-name"},
+			authenticators: []runtime.Object{someAuthenticator},
+			secrets:        []runtime.Object{someSecret},
+			wantLogs: []string{
+				`statictokencachefiller-controller "level"=0 "msg"="added new static token authenticator" "staticTokenAuthenticator"={"name":"test-name"} "tokens"=1`,
+			},
+			wantStatusUpdate: withConditions(readyCondition, validCondition),
+			wantCacheEntries: 1,
+		},
+		{
+			name:           "unchanged authenticator is not recreated and its status is not updated again",
+			syncKey:        controllerlib.Key{Name: "test-name"},
+			authenticators: []runtime.Object{withConditions(readyCondition, validCondition)},
+			secrets:        []runtime.Object{someSecret},
+			cache: func(t *testing.T, cache *authncache.Cache) authncache.Value {
+				value := newCacheValue(t, hashOf("some-token"))
+				cache.Store(cacheKey, value)
+				return value
+			},
+			wantLogs: []string{
+				`statictokencachefiller-controller "level"=0 "msg"="actual static token authenticator and desired static token authenticator are the same" "staticTokenAuthenticator"={"name":"test-name"}`,
+			},
+			wantCacheEntries:     1,
+			wantCacheValueReused: true,
+		},
+		{
+			name:           "changing the Secret rebuilds the authenticators which use it",
+			syncKey:        controllerlib.Key{Namespace: "concierge", Name: "some-secret"},
+			authenticators: []runtime.Object{withConditions(readyCondition, validCondition)},
+			secrets:        []runtime.Object{someSecret},
+			cache: func(t *testing.T, cache *authncache.Cache) authncache.Value {
+				value := newCacheValue(t, hashOf("some-old-token"))
+				cache.Store(cacheKey, value)
+				return value
+			},
+			wantLogs: []string{
+				`statictokencachefiller-controller "level"=0 "msg"="added new static token authenticator" "staticTokenAuthenticator"={"name":"test-name"} "tokens"=1`,
+			},
+			wantCacheEntries: 1,
+		},
+		{
+			name:           "changing an unrelated Secret does nothing",
+			syncKey:        controllerlib.Key{Namespace: "concierge", Name: "some-other-secret"},
+			authenticators: []runtime.Object{someAuthenticator},
+			secrets:        []runtime.Object{someSecret},
+		},
+		{
+			name:           "deleting the Secret revokes the tokens",
+			syncKey:        controllerlib.Key{Namespace: "concierge", Name: "some-secret"},
+			authenticators: []runtime.Object{withConditions(readyCondition, validCondition)},
+			cache: func(t *testing.T, cache *authncache.Cache) authncache.Value {
+				value := newCacheValue(t, hashOf("some-token"))
+				cache.Store(cacheKey, value)
+				return value
+			},
+			wantErr: `failed to build static token authenticator: failed to get token Secret "some-secret": secret "some-secret" not found`,
+			wantStatusUpdate: withConditions(
+				notReadyCondition(`failed to get token Secret "some-secret": secret "some-secret" not found`),
+				invalidCondition(`failed to get token Secret "some-secret": secret "some-secret" not found`),
+			),
+		},
+		{
+			name:           "Secret is missing the hash of a token",
+			syncKey:        controllerlib.Key{Name: "test-name"},
+			authenticators: []runtime.Object{someAuthenticator},
+			secrets: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "some-secret", Namespace: "concierge"},
+				Data:       map[string][]byte{"some-other-token": hashOf("some-token")},
+			}},
+			wantErr: `failed to build static token authenticator: secret "some-secret" is missing key "break-glass"`,
+			wantStatusUpdate: withConditions(
+				notReadyCondition(`secret "some-secret" is missing key "break-glass"`),
+				invalidCondition(`secret "some-secret" is missing key "break-glass"`),
+			),
+		},
+		{
+			name:           "Secret in another namespace is ignored",
+			syncKey:        controllerlib.Key{Name: "test-name"},
+			authenticators: []runtime.Object{someAuthenticator},
+			secrets: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "some-secret", Namespace: "other-namespace"},
+				Data:       map[string][]byte{"break-glass": hashOf("some-token")},
+			}},
+			wantErr: `failed to build static token authenticator: failed to get token Secret "some-secret": secret "some-secret" not found`,
+			wantStatusUpdate: withConditions(
+				notReadyCondition(`failed to get token Secret "some-secret": secret "some-secret" not found`),
+				invalidCondition(`failed to get token Secret "some-secret": secret "some-secret" not found`),
+			),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeClient := pinnipedfake.NewSimpleClientset(tt.authenticators...)
+			informers := pinnipedinformers.NewSharedInformerFactory(fakeClient, 0)
+			fakeKubeClient := kubernetesfake.NewSimpleClientset(tt.secrets...)
+			kubeInformers := kubeinformers.NewSharedInformerFactoryWithOptions(fakeKubeClient, 0, kubeinformers.WithNamespace("concierge"))
+			cache := authncache.New()
+			testLog := testlogger.New(t)
+
+			var initialValue authncache.Value
+			if tt.cache != nil {
+				initialValue = tt.cache(t, cache)
+			}
+
+			controller := New(cache, fakeClient, informers.Authentication().V1alpha1().StaticTokenAuthenticators(), kubeInformers.Core().V1().Secrets(), "concierge", clock.NewFakeClock(now), &events.FakeRecorder{}, testLog)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			informers.Start(ctx.Done())
+			kubeInformers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			syncCtx := controllerlib.Context{Context: ctx, Key: tt.syncKey}
+
+			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantLogs, testLog.Lines())
+			require.Equal(t, tt.wantCacheEntries, len(cache.Keys()))
+
+			var statusUpdates []runtime.Object
+			for _, action := range fakeClient.Actions() {
+				if update, ok := action.(coretesting.UpdateAction); ok && update.GetSubresource() == "status" {
+					statusUpdates = append(statusUpdates, update.GetObject())
+				}
+			}
+			if tt.wantStatusUpdate != nil {
+				require.Equal(t, []runtime.Object{tt.wantStatusUpdate}, statusUpdates)
+			} else {
+				require.Empty(t, statusUpdates)
+			}
+
+			if initialValue != nil && tt.wantCacheEntries > 0 {
+				if tt.wantCacheValueReused {
+					require.Same(t, initialValue, cache.Get(cacheKey))
+				} else {
+					require.NotSame(t, initialValue, cache.Get(cacheKey))
+				}
+			}
+		})
+	}
+}
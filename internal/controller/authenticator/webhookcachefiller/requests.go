@@ -0,0 +1,157 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package webhookcachefiller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	webhookutil "k8s.io/apiserver/pkg/util/webhook"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+)
+
+const (
+	defaultMaxRetries     = 4
+	defaultInitialBackoff = 500 * time.Millisecond
+
+	// persistentFailureThreshold is how many consecutive requests to a webhook must fail before the failures are
+	// reported in the status of the WebhookAuthenticator.
+	persistentFailureThreshold = 3
+
+	// statusRefreshInterval is how often the status of each WebhookAuthenticator is updated with the outcome of
+	// the recent requests to its webhook, independently of the resync period of the informers.
+	statusRefreshInterval = 30 * time.Second
+
+	typeWebhookReachable      = auth1alpha1.ConditionTypeWebhookReachable
+	typeTLSConfigurationValid = auth1alpha1.ConditionTypeTLSConfigurationValid
+	reasonSuccess             = "Success"
+	reasonRequestsFailing     = "RequestsFailing"
+	reasonInvalidTLSConfig    = "InvalidTLSConfig"
+	messageWebhookHealthy     = "the recent requests to the webhook succeeded"
+	messageWebhookFailures    = "the last %d requests to the webhook failed, the last error was: %v"
+)
+
+// retryBackoff returns the backoff for retrying failed requests to the webhook. Its number of steps is the maximum
+// number of attempts.
+func retryBackoff(spec *auth1alpha1.WebhookRequestSpec) wait.Backoff {
+	initialBackoff := defaultInitialBackoff
+	maxRetries := int32(defaultMaxRetries)
+	if spec != nil {
+		if spec.InitialBackoff.Duration > 0 {
+			initialBackoff = spec.InitialBackoff.Duration
+		}
+		if spec.MaxRetries != nil {
+			maxRetries = *spec.MaxRetries
+		}
+	}
+	backoff := webhookutil.DefaultRetryBackoffWithInitialDelay(initialBackoff)
+	backoff.Steps = int(maxRetries) + 1
+	return backoff
+}
+
+// requestTracker wraps a webhook authenticator to limit how long each authentication may take, and to remember
+// whether the recent requests to the webhook failed. It is safe for concurrent use.
+type requestTracker struct {
+	delegate authenticator.Token
+	timeout  time.Duration
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	lastFailure         error
+	requestsSinceReport int
+	reported            bool
+}
+
+func newRequestTracker(delegate authenticator.Token, spec *auth1alpha1.WebhookRequestSpec) *requestTracker {
+	t := &requestTracker{delegate: delegate}
+	if spec != nil {
+		t.timeout = spec.Timeout.Duration
+	}
+	return t
+}
+
+func (t *requestTracker) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	response, authenticated, err := t.delegate.AuthenticateToken(ctx, token)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requestsSinceReport++
+	if isWebhookFailure(err) {
+		t.consecutiveFailures++
+		t.lastFailure = err
+	} else {
+		t.consecutiveFailures = 0
+		t.lastFailure = nil
+	}
+	return response, authenticated, err
+}
+
+// report returns the WebhookReachable condition for the requests since the previous report, or nil when this
+// replica of the Concierge has not made any requests to the webhook since then. Each replica only knows about its
+// own requests, so a replica which has nothing new to report must leave the condition which was written by a
+// replica that does, rather than reset it to the outcome of the requests which it made a while ago.
+func (t *requestTracker) report() *auth1alpha1.Condition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reported && t.requestsSinceReport == 0 {
+		return nil
+	}
+	t.reported = true
+	t.requestsSinceReport = 0
+	return t.conditionLocked()
+}
+
+// condition returns the WebhookReachable condition for the recent requests to the webhook.
+func (t *requestTracker) condition() *auth1alpha1.Condition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.conditionLocked()
+}
+
+func (t *requestTracker) conditionLocked() *auth1alpha1.Condition {
+	if t.consecutiveFailures < persistentFailureThreshold {
+		return &auth1alpha1.Condition{
+			Type:    typeWebhookReachable,
+			Status:  auth1alpha1.ConditionTrue,
+			Reason:  reasonSuccess,
+			Message: messageWebhookHealthy,
+		}
+	}
+	return &auth1alpha1.Condition{
+		Type:    typeWebhookReachable,
+		Status:  auth1alpha1.ConditionFalse,
+		Reason:  reasonRequestsFailing,
+		Message: fmt.Sprintf(messageWebhookFailures, t.consecutiveFailures, t.lastFailure),
+	}
+}
+
+// isWebhookFailure returns true when the error means that the webhook could not be called or did not respond
+// successfully. A webhook which responds that a token is not valid, with or without an error message, is working.
+func isWebhookFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiStatus apierrors.APIStatus
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &apiStatus) ||
+		errors.As(err, &urlErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package webhookcachefiller
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	t.Parallel()
+
+	zero := int32(0)
+	seven := int32(7)
+
+	tests := []struct {
+		name             string
+		spec             *auth1alpha1.WebhookRequestSpec
+		wantSteps        int
+		wantInitialDelay time.Duration
+	}{
+		{
+			name:             "defaults",
+			wantSteps:        5,
+			wantInitialDelay: 500 * time.Millisecond,
+		},
+		{
+			name:             "empty spec",
+			spec:             &auth1alpha1.WebhookRequestSpec{},
+			wantSteps:        5,
+			wantInitialDelay: 500 * time.Millisecond,
+		},
+		{
+			name:             "retries disabled",
+			spec:             &auth1alpha1.WebhookRequestSpec{MaxRetries: &zero},
+			wantSteps:        1,
+			wantInitialDelay: 500 * time.Millisecond,
+		},
+		{
+			name: "overrides",
+			spec: &auth1alpha1.WebhookRequestSpec{
+				MaxRetries:     &seven,
+				InitialBackoff: metav1.Duration{Duration: 2 * time.Second},
+			},
+			wantSteps:        8,
+			wantInitialDelay: 2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			backoff := retryBackoff(tt.spec)
+			require.Equal(t, tt.wantSteps, backoff.Steps)
+			require.Equal(t, tt.wantInitialDelay, backoff.Duration)
+			require.Equal(t, 1.5, backoff.Factor)
+		})
+	}
+}
+
+// fakeWebhook returns the configured error, or waits for the context to be done when block is true.
+type fakeWebhook struct {
+	err   error
+	block bool
+}
+
+func (w *fakeWebhook) AuthenticateToken(ctx context.Context, _ string) (*authenticator.Response, bool, error) {
+	if w.block {
+		<-ctx.Done()
+		return nil, false, ctx.Err()
+	}
+	return nil, false, w.err
+}
+
+func TestRequestTracker(t *testing.T) {
+	t.Parallel()
+
+	webhook := &fakeWebhook{}
+	tracker := newRequestTracker(webhook, nil)
+	authenticate := func() {
+		t.Helper()
+		_, _, _ = tracker.AuthenticateToken(context.Background(), "some-token")
+	}
+	requireReachable := func() {
+		t.Helper()
+		require.Equal(t, &auth1alpha1.Condition{
+			Type:    "WebhookReachable",
+			Status:  auth1alpha1.ConditionTrue,
+			Reason:  "Success",
+			Message: "the recent requests to the webhook succeeded",
+		}, tracker.condition())
+	}
+
+	authenticate()
+	requireReachable()
+
+	// A few failures are not reported, since the webhook may just be restarting.
+	webhook.err = &url.Error{Op: "Post", URL: "https://example.com", Err: errors.New("connection refused")}
+	authenticate()
+	authenticate()
+	requireReachable()
+
+	// A webhook which rejects the token is working.
+	webhook.err = errors.New("some rejection from the webhook")
+	authenticate()
+	requireReachable()
+
+	webhook.err = apierrors.NewInternalError(errors.New("some server error"))
+	authenticate()
+	authenticate()
+	requireReachable()
+	authenticate()
+	require.Equal(t, &auth1alpha1.Condition{
+		Type:    "WebhookReachable",
+		Status:  auth1alpha1.ConditionFalse,
+		Reason:  "RequestsFailing",
+		Message: "the last 3 requests to the webhook failed, the last error was: Internal error occurred: some server error",
+	}, tracker.condition())
+
+	// A single success resets the count.
+	webhook.err = nil
+	authenticate()
+	requireReachable()
+}
+
+func TestRequestTrackerTimeout(t *testing.T) {
+	t.Parallel()
+
+	tracker := newRequestTracker(&fakeWebhook{block: true}, &auth1alpha1.WebhookRequestSpec{
+		Timeout: metav1.Duration{Duration: 10 * time.Millisecond},
+	})
+	for i := 0; i < persistentFailureThreshold; i++ {
+		_, _, err := tracker.AuthenticateToken(context.Background(), "some-token")
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+	}
+	require.Equal(t, auth1alpha1.ConditionFalse, tracker.condition().Status)
+	require.Equal(t, "the last 3 requests to the webhook failed, the last error was: context deadline exceeded", tracker.condition().Message)
+}
+
+func TestRequestTrackerReport(t *testing.T) {
+	t.Parallel()
+
+	webhook := &fakeWebhook{err: &url.Error{Op: "Post", URL: "https://example.com", Err: errors.New("connection refused")}}
+	tracker := newRequestTracker(webhook, nil)
+
+	// The first report is made even without any requests, so that a new authenticator has the condition.
+	require.Equal(t, auth1alpha1.ConditionTrue, tracker.report().Status)
+
+	// Without any new requests, there is nothing to report.
+	require.Nil(t, tracker.report())
+
+	for i := 0; i < persistentFailureThreshold; i++ {
+		_, _, _ = tracker.AuthenticateToken(context.Background(), "some-token")
+	}
+	require.Equal(t, auth1alpha1.ConditionFalse, tracker.report().Status)
+	require.Nil(t, tracker.report())
+	require.Equal(t, auth1alpha1.ConditionFalse, tracker.condition().Status)
+}
@@ -8,47 +8,72 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
 
 	"github.com/go-logr/logr"
 	k8sauthv1beta1 "k8s.io/api/authentication/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
+	tokencache "k8s.io/apiserver/pkg/authentication/token/cache"
 	"k8s.io/apiserver/plugin/pkg/authenticator/token/webhook"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/klog/v2"
 
 	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
 	authinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions/authentication/v1alpha1"
 	pinnipedcontroller "go.pinniped.dev/internal/controller"
 	pinnipedauthenticator "go.pinniped.dev/internal/controller/authenticator"
 	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controller/conditionsutil"
 	"go.pinniped.dev/internal/controllerlib"
 )
 
-// New instantiates a new controllerlib.Controller which will populate the provided authncache.Cache.
-func New(cache *authncache.Cache, webhooks authinformers.WebhookAuthenticatorInformer, log logr.Logger) controllerlib.Controller {
+// New instantiates a new controllerlib.Controller which will populate the provided authncache.Cache. It also
+// reports the validity of the TLS configuration and persistent failures of each webhook in the status of its
+// WebhookAuthenticator, which is refreshed every statusRefreshInterval, with an Event for each change.
+func New(
+	cache *authncache.Cache,
+	client pinnipedclientset.Interface,
+	webhooks authinformers.WebhookAuthenticatorInformer,
+	clock clock.PassiveClock,
+	recorder events.EventRecorder,
+	log logr.Logger,
+	opts ...controllerlib.Option,
+) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
 			Name: "webhookcachefiller-controller",
 			Syncer: &controller{
 				cache:    cache,
+				client:   client,
 				webhooks: webhooks,
+				clock:    clock,
 				log:      log.WithName("webhookcachefiller-controller"),
 			},
 		},
-		controllerlib.WithInformer(
-			webhooks,
-			pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			controllerlib.WithRecorder(recorder),
+			controllerlib.WithInformer(
+				webhooks,
+				pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
 type controller struct {
 	cache    *authncache.Cache
+	client   pinnipedclientset.Interface
 	webhooks authinformers.WebhookAuthenticatorInformer
+	clock    clock.PassiveClock
 	log      logr.Logger
 }
 
@@ -63,20 +88,131 @@ func (c *controller) Sync(ctx controllerlib.Context) error {
 		return fmt.Errorf("failed to get WebhookAuthenticator %s/%s: %w", ctx.Key.Namespace, ctx.Key.Name, err)
 	}
 
-	webhookAuthenticator, err := newWebhookAuthenticator(&obj.Spec, ioutil.TempFile, clientcmd.WriteToFile)
-	if err != nil {
-		return fmt.Errorf("failed to build webhook config: %w", err)
-	}
-
-	c.cache.Store(authncache.Key{
+	cacheKey := authncache.Key{
 		APIGroup: auth1alpha1.GroupName,
 		Kind:     "WebhookAuthenticator",
 		Name:     ctx.Key.Name,
-	}, webhookAuthenticator)
+	}
+
+	tlsCondition := validateTLS(obj.Spec.TLS)
+
+	// Only rebuild the authenticator when its spec has changed, because rebuilding it also empties its cache of
+	// webhook responses.
+	if existing, ok := c.cache.Get(cacheKey).(*webhookAuthenticator); ok && reflect.DeepEqual(existing.spec, &obj.Spec) {
+		c.log.WithValues("webhook", klog.KObj(obj), "endpoint", obj.Spec.Endpoint).Info("actual webhook authenticator and desired webhook authenticator are the same")
+		return c.refreshStatus(ctx, obj, tlsCondition, existing.requests)
+	}
+
+	tokenAuthenticator, err := newWebhookAuthenticator(&obj.Spec, ioutil.TempFile, clientcmd.WriteToFile)
+	if err != nil {
+		return utilerrors.NewAggregate([]error{
+			fmt.Errorf("failed to build webhook config: %w", err),
+			c.updateStatus(ctx, obj, []*auth1alpha1.Condition{tlsCondition}),
+		})
+	}
+
+	// The response cache wraps the request tracker, so that only the requests which reach the webhook are tracked.
+	requests := newRequestTracker(tokenAuthenticator, obj.Spec.Request)
+	c.cache.Store(cacheKey, &webhookAuthenticator{
+		Token:    withResponseCache(requests, obj.Spec.Cache),
+		requests: requests,
+		// Make a deep copy of the spec so we aren't storing pointers to something that the informer cache may mutate!
+		spec: obj.Spec.DeepCopy(),
+	})
 	c.log.WithValues("webhook", klog.KObj(obj), "endpoint", obj.Spec.Endpoint).Info("added new webhook authenticator")
+	return c.refreshStatus(ctx, obj, tlsCondition, requests)
+}
+
+// refreshStatus updates the status with the outcome of the recent requests to the webhook, and schedules the next
+// update, since the requests do not cause any events which would trigger a sync.
+func (c *controller) refreshStatus(
+	ctx controllerlib.Context,
+	obj *auth1alpha1.WebhookAuthenticator,
+	tlsCondition *auth1alpha1.Condition,
+	requests *requestTracker,
+) error {
+	conditions := []*auth1alpha1.Condition{tlsCondition}
+	if requestsCondition := requests.report(); requestsCondition != nil {
+		conditions = append(conditions, requestsCondition)
+	}
+	if err := c.updateStatus(ctx, obj, conditions); err != nil {
+		return err
+	}
+	ctx.Queue.AddAfter(ctx.Key, statusRefreshInterval)
+	return nil
+}
+
+// validateTLS returns the TLSConfigurationValid condition of the TLS spec.
+func validateTLS(spec *auth1alpha1.TLSSpec) *auth1alpha1.Condition {
+	caBundle, err := pinnipedauthenticator.CABundle(spec)
+	if err == nil {
+		_, err = pinnipedauthenticator.CertPool(caBundle)
+	}
+	switch {
+	case err != nil:
+		return &auth1alpha1.Condition{
+			Type:    typeTLSConfigurationValid,
+			Status:  auth1alpha1.ConditionFalse,
+			Reason:  reasonInvalidTLSConfig,
+			Message: err.Error(),
+		}
+	case len(caBundle) == 0:
+		return &auth1alpha1.Condition{
+			Type:    typeTLSConfigurationValid,
+			Status:  auth1alpha1.ConditionTrue,
+			Reason:  reasonSuccess,
+			Message: "no CA bundle specified, so the system's trusted CAs are used",
+		}
+	default:
+		return &auth1alpha1.Condition{
+			Type:    typeTLSConfigurationValid,
+			Status:  auth1alpha1.ConditionTrue,
+			Reason:  reasonSuccess,
+			Message: "successfully parsed specified CA bundle",
+		}
+	}
+}
+
+func (c *controller) updateStatus(ctx controllerlib.Context, obj *auth1alpha1.WebhookAuthenticator, conditions []*auth1alpha1.Condition) error {
+	newConditions := make([]auth1alpha1.Condition, 0, len(conditions))
+	for _, cond := range conditions {
+		newConditions = append(newConditions, *cond)
+	}
+	merged := conditionsutil.FromAuthenticationV1alpha1(obj.Status.Conditions)
+	if !conditionsutil.MergeWithReady(&merged, obj.Generation, metav1.NewTime(c.clock.Now()), conditionsutil.FromAuthenticationV1alpha1(newConditions)...) {
+		return nil
+	}
+	updated := obj.DeepCopy()
+	updated.Status.Conditions = conditionsutil.ToAuthenticationV1alpha1(merged)
+
+	if _, err := c.client.AuthenticationV1alpha1().WebhookAuthenticators().UpdateStatus(ctx.Context, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update status of WebhookAuthenticator %s: %w", obj.Name, err)
+	}
+	conditionsutil.RecordTransitions(ctx.Recorder, obj, "ValidateWebhookAuthenticator", conditionsutil.FromAuthenticationV1alpha1(obj.Status.Conditions), merged)
+	for _, cond := range conditions {
+		if cond.Type == typeWebhookReachable && cond.Status == auth1alpha1.ConditionFalse {
+			c.log.WithValues("webhook", klog.KObj(obj), "message", cond.Message).Info("webhook requests are failing")
+		}
+	}
 	return nil
 }
 
+// webhookAuthenticator remembers the spec from which the authenticator was built, and tracks its requests.
+type webhookAuthenticator struct {
+	authenticator.Token
+	requests *requestTracker
+	spec     *auth1alpha1.WebhookAuthenticatorSpec
+}
+
+// withResponseCache wraps the authenticator with the same cache of token authentication results which the
+// kube-apiserver uses for its webhook token authenticator, when the spec asks for caching.
+func withResponseCache(tokenAuthenticator authenticator.Token, spec *auth1alpha1.WebhookCacheSpec) authenticator.Token {
+	if spec == nil || (spec.SuccessTTL.Duration <= 0 && spec.FailureTTL.Duration <= 0) {
+		return tokenAuthenticator
+	}
+	return tokencache.New(tokenAuthenticator, false, spec.SuccessTTL.Duration, spec.FailureTTL.Duration)
+}
+
 // newWebhookAuthenticator creates a webhook from the provided API server url and caBundle
 // used to validate TLS connections.
 func newWebhookAuthenticator(
@@ -92,6 +228,9 @@ func newWebhookAuthenticator(
 
 	cluster := &clientcmdapi.Cluster{Server: spec.Endpoint}
 	cluster.CertificateAuthorityData, err = pinnipedauthenticator.CABundle(spec.TLS)
+	if err == nil {
+		_, err = pinnipedauthenticator.CertPool(cluster.CertificateAuthorityData)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
 	}
@@ -118,5 +257,5 @@ func newWebhookAuthenticator(
 	// custom proxy stuff used by the API server.
 	var customDial net.DialFunc
 
-	return webhook.New(temp.Name(), version, implicitAuds, *webhook.DefaultRetryBackoff(), customDial)
+	return webhook.New(temp.Name(), version, implicitAuds, retryBackoff(spec.Request), customDial)
 }
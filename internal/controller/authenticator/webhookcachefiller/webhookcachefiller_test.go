@@ -16,8 +16,12 @@ import (
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/events"
 
 	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
 	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
@@ -28,6 +32,8 @@ import (
 	"go.pinniped.dev/internal/testutil/testlogger"
 )
 
+var testNow = time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+
 func TestController(t *testing.T) {
 	t.Parallel()
 
@@ -38,6 +44,7 @@ func TestController(t *testing.T) {
 		wantErr          string
 		wantLogs         []string
 		wantCacheEntries int
+		wantConditions   []auth1alpha1.Condition
 	}{
 		{
 			name:    "not found",
@@ -67,7 +74,8 @@ func TestController(t *testing.T) {
 			webhooks: []runtime.Object{
 				&auth1alpha1.WebhookAuthenticator{
 					ObjectMeta: metav1.ObjectMeta{
-						Name: "test-name",
+						Name:       "test-name",
+						Generation: 2,
 					},
 					Spec: auth1alpha1.WebhookAuthenticatorSpec{
 						Endpoint: "https://example.com",
@@ -79,6 +87,67 @@ func TestController(t *testing.T) {
 				`webhookcachefiller-controller "level"=0 "msg"="added new webhook authenticator" "endpoint"="https://example.com" "webhook"={"name":"test-name"}`,
 			},
 			wantCacheEntries: 1,
+			wantConditions: []auth1alpha1.Condition{
+				{
+					Type:               "Ready",
+					Status:             auth1alpha1.ConditionTrue,
+					ObservedGeneration: 2,
+					LastTransitionTime: metav1.NewTime(testNow),
+					Reason:             "Success",
+					Message:            "all conditions are true",
+				},
+				{
+					Type:               "TLSConfigurationValid",
+					Status:             auth1alpha1.ConditionTrue,
+					ObservedGeneration: 2,
+					LastTransitionTime: metav1.NewTime(testNow),
+					Reason:             "Success",
+					Message:            "no CA bundle specified, so the system's trusted CAs are used",
+				},
+				{
+					Type:               "WebhookReachable",
+					Status:             auth1alpha1.ConditionTrue,
+					ObservedGeneration: 2,
+					LastTransitionTime: metav1.NewTime(testNow),
+					Reason:             "Success",
+					Message:            "the recent requests to the webhook succeeded",
+				},
+			},
+		},
+		{
+			name:    "invalid CA bundle",
+			syncKey: controllerlib.Key{Name: "test-name"},
+			webhooks: []runtime.Object{
+				&auth1alpha1.WebhookAuthenticator{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:       "test-name",
+						Generation: 2,
+					},
+					Spec: auth1alpha1.WebhookAuthenticatorSpec{
+						Endpoint: "https://example.com",
+						TLS:      &auth1alpha1.TLSSpec{CertificateAuthorityData: "bm90IGEgY2VydGlmaWNhdGU="},
+					},
+				},
+			},
+			wantErr: "failed to build webhook config: invalid TLS configuration: CA bundle does not contain any PEM-encoded certificates",
+			wantConditions: []auth1alpha1.Condition{
+				{
+					Type:               "Ready",
+					Status:             auth1alpha1.ConditionFalse,
+					ObservedGeneration: 2,
+					LastTransitionTime: metav1.NewTime(testNow),
+					Reason:             "InvalidTLSConfig",
+					Message:            "TLSConfigurationValid is False: CA bundle does not contain any PEM-encoded certificates",
+				},
+				{
+					Type:               "TLSConfigurationValid",
+					Status:             auth1alpha1.ConditionFalse,
+					ObservedGeneration: 2,
+					LastTransitionTime: metav1.NewTime(testNow),
+					Reason:             "InvalidTLSConfig",
+					Message:            "CA bundle does not contain any PEM-encoded certificates",
+				},
+			},
 		},
 	}
 	for _, tt := range tests {
@@ -91,7 +160,7 @@ func TestController(t *testing.T) {
 			cache := authncache.New()
 			testLog := testlogger.New(t)
 
-			controller := New(cache, informers.Authentication().V1alpha1().WebhookAuthenticators(), testLog)
+			controller := New(cache, fakeClient, informers.Authentication().V1alpha1().WebhookAuthenticators(), clock.NewFakeClock(testNow), &events.FakeRecorder{}, testLog)
 
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			defer cancel()
@@ -99,7 +168,8 @@ func TestController(t *testing.T) {
 			informers.Start(ctx.Done())
 			controllerlib.TestRunSynchronously(t, controller)
 
-			syncCtx := controllerlib.Context{Context: ctx, Key: tt.syncKey}
+			queue := &recordingQueue{addedAfter: map[controllerlib.Key]time.Duration{}}
+			syncCtx := controllerlib.Context{Context: ctx, Key: tt.syncKey, Queue: queue}
 
 			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
 				require.EqualError(t, err, tt.wantErr)
@@ -108,6 +178,146 @@ func TestController(t *testing.T) {
 			}
 			require.Equal(t, tt.wantLogs, testLog.Lines())
 			require.Equal(t, tt.wantCacheEntries, len(cache.Keys()))
+			if tt.wantCacheEntries > 0 {
+				// The status is refreshed on a timer, since requests to the webhook do not trigger a sync.
+				require.Equal(t, map[controllerlib.Key]time.Duration{tt.syncKey: statusRefreshInterval}, queue.addedAfter)
+			} else {
+				require.Empty(t, queue.addedAfter)
+			}
+
+			if tt.wantConditions != nil {
+				updated, err := fakeClient.AuthenticationV1alpha1().WebhookAuthenticators().Get(ctx, tt.syncKey.Name, metav1.GetOptions{})
+				require.NoError(t, err)
+				require.Equal(t, tt.wantConditions, updated.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestControllerDoesNotRebuildUnchangedAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	fakeClient := pinnipedfake.NewSimpleClientset(&auth1alpha1.WebhookAuthenticator{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+		Spec: auth1alpha1.WebhookAuthenticatorSpec{
+			Endpoint: "https://example.com",
+			Cache:    &auth1alpha1.WebhookCacheSpec{SuccessTTL: metav1.Duration{Duration: time.Minute}},
+		},
+	})
+	informers := pinnipedinformers.NewSharedInformerFactory(fakeClient, 0)
+	cache := authncache.New()
+	testLog := testlogger.New(t)
+
+	controller := New(cache, fakeClient, informers.Authentication().V1alpha1().WebhookAuthenticators(), clock.NewFakeClock(testNow), &events.FakeRecorder{}, testLog)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	informers.Start(ctx.Done())
+	controllerlib.TestRunSynchronously(t, controller)
+
+	cacheKey := authncache.Key{APIGroup: auth1alpha1.GroupName, Kind: "WebhookAuthenticator", Name: "test-name"}
+	queue := &recordingQueue{addedAfter: map[controllerlib.Key]time.Duration{}}
+	syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{Name: "test-name"}, Queue: queue}
+	require.NoError(t, controllerlib.TestSync(t, controller, syncCtx))
+	first := cache.Get(cacheKey)
+	require.NotNil(t, first)
+
+	// Resyncing keeps the same authenticator, so that its cache of webhook responses is not lost.
+	require.NoError(t, controllerlib.TestSync(t, controller, syncCtx))
+	require.Same(t, first, cache.Get(cacheKey))
+	require.Equal(t, []string{
+		`webhookcachefiller-controller "level"=0 "msg"="added new webhook authenticator" "endpoint"="https://example.com" "webhook"={"name":"test-name"}`,
+		`webhookcachefiller-controller "level"=0 "msg"="actual webhook authenticator and desired webhook authenticator are the same" "endpoint"="https://example.com" "webhook"={"name":"test-name"}`,
+	}, testLog.Lines())
+}
+
+// recordingQueue is a controllerlib.Queue which only records the keys that were added to it with a delay.
+type recordingQueue struct {
+	addedAfter map[controllerlib.Key]time.Duration
+}
+
+func (q *recordingQueue) Add(controllerlib.Key)            {}
+func (q *recordingQueue) AddRateLimited(controllerlib.Key) {}
+func (q *recordingQueue) AddAfter(key controllerlib.Key, duration time.Duration) {
+	q.addedAfter[key] = duration
+}
+
+// countingAuthenticator authenticates the token "good-token" and counts its calls.
+type countingAuthenticator struct{ calls int }
+
+func (a *countingAuthenticator) AuthenticateToken(_ context.Context, token string) (*authenticator.Response, bool, error) {
+	a.calls++
+	if token != "good-token" {
+		return nil, false, nil
+	}
+	return &authenticator.Response{User: &user.DefaultInfo{Name: "some-user"}}, true, nil
+}
+
+func TestWithResponseCache(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		spec          *auth1alpha1.WebhookCacheSpec
+		wantGoodCalls int
+		wantBadCalls  int
+	}{
+		{
+			name:          "no cache",
+			wantGoodCalls: 2,
+			wantBadCalls:  2,
+		},
+		{
+			name:          "zero TTLs",
+			spec:          &auth1alpha1.WebhookCacheSpec{},
+			wantGoodCalls: 2,
+			wantBadCalls:  2,
+		},
+		{
+			name:          "only successes are cached",
+			spec:          &auth1alpha1.WebhookCacheSpec{SuccessTTL: metav1.Duration{Duration: time.Minute}},
+			wantGoodCalls: 1,
+			wantBadCalls:  2,
+		},
+		{
+			name:          "only failures are cached",
+			spec:          &auth1alpha1.WebhookCacheSpec{FailureTTL: metav1.Duration{Duration: time.Minute}},
+			wantGoodCalls: 2,
+			wantBadCalls:  1,
+		},
+		{
+			name: "successes and failures are cached",
+			spec: &auth1alpha1.WebhookCacheSpec{
+				SuccessTTL: metav1.Duration{Duration: time.Minute},
+				FailureTTL: metav1.Duration{Duration: time.Minute},
+			},
+			wantGoodCalls: 1,
+			wantBadCalls:  1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, token := range []string{"good-token", "bad-token"} {
+				counter := &countingAuthenticator{}
+				cached := withResponseCache(counter, tt.spec)
+				for i := 0; i < 2; i++ {
+					resp, authenticated, err := cached.AuthenticateToken(context.Background(), token)
+					require.NoError(t, err)
+					require.Equal(t, token == "good-token", authenticated)
+					if authenticated {
+						require.Equal(t, "some-user", resp.User.GetName())
+					}
+				}
+				if token == "good-token" {
+					require.Equal(t, tt.wantGoodCalls, counter.calls)
+				} else {
+					require.Equal(t, tt.wantBadCalls, counter.calls)
+				}
+			}
 		})
 	}
 }
@@ -136,6 +346,15 @@ func TestNewWebhookAuthenticator(t *testing.T) {
 		require.EqualError(t, err, "invalid TLS configuration: illegal base64 data at input byte 7")
 	})
 
+	t.Run("no certificates in CA bundle", func(t *testing.T) {
+		res, err := newWebhookAuthenticator(&auth1alpha1.WebhookAuthenticatorSpec{
+			Endpoint: "https://example.com",
+			TLS:      &auth1alpha1.TLSSpec{CertificateAuthorityData: base64.StdEncoding.EncodeToString([]byte("not a certificate"))},
+		}, ioutil.TempFile, clientcmd.WriteToFile)
+		require.Nil(t, res)
+		require.EqualError(t, err, "invalid TLS configuration: CA bundle does not contain any PEM-encoded certificates")
+	})
+
 	t.Run("valid config with no TLS spec", func(t *testing.T) {
 		res, err := newWebhookAuthenticator(&auth1alpha1.WebhookAuthenticatorSpec{
 			Endpoint: "https://example.com",
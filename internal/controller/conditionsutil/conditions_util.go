@@ -0,0 +1,159 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package conditionsutil contains the logic which is shared by all controllers that set status conditions.
+//
+// The helpers work on metav1.Condition, which is the type that every controller uses for conditions in memory. The
+// v1alpha1 API groups of Pinniped have their own Condition types, which mirror metav1.Condition field for field
+// because it does not exist in the Kubernetes 1.17 and 1.18 client libraries of the generated API modules. The
+// controllers convert from and to those types only when they read or write a status, see convert.go. This covers
+// the authenticators, the CredentialIssuer, FederationDomains and upstream identity providers.
+package conditionsutil
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+)
+
+// Merge merges the new conditions into existing, replacing any existing conditions of the same types. The new
+// conditions get the observedGeneration, and they keep the LastTransitionTime of the condition which they replace
+// when their status has not changed, or get now otherwise. The conditions are sorted by type so that their order
+// is stable. It returns true when existing was changed.
+func Merge(existing *[]metav1.Condition, observedGeneration int64, now metav1.Time, conditions ...metav1.Condition) bool {
+	changed := false
+	for i := range conditions {
+		condition := conditions[i]
+		condition.ObservedGeneration = observedGeneration
+		condition.LastTransitionTime = now
+
+		old := Find(*existing, condition.Type)
+		if old == nil {
+			*existing = append(*existing, condition)
+			changed = true
+			continue
+		}
+		if old.Status == condition.Status {
+			condition.LastTransitionTime = old.LastTransitionTime
+		}
+		if !equality.Semantic.DeepEqual(*old, condition) {
+			*old = condition
+			changed = true
+		}
+	}
+
+	sort.SliceStable(*existing, func(i, j int) bool {
+		return (*existing)[i].Type < (*existing)[j].Type
+	})
+	return changed
+}
+
+// TypeReady is the type of the condition which summarizes all other conditions of a resource, so that automation
+// can wait for it with "kubectl wait --for=condition=Ready". Each API group publishes it as ConditionTypeReady.
+const TypeReady = "Ready"
+
+// MergeWithReady is like Merge, and additionally maintains the Ready condition. Ready is false when any other
+// condition is false, unknown when any other condition is unknown, and true otherwise. It is derived from all
+// conditions of the resource, including the ones which were set by other controllers.
+func MergeWithReady(existing *[]metav1.Condition, observedGeneration int64, now metav1.Time, conditions ...metav1.Condition) bool {
+	changed := Merge(existing, observedGeneration, now, conditions...)
+	if Merge(existing, observedGeneration, now, readyCondition(*existing)) {
+		changed = true
+	}
+	return changed
+}
+
+func readyCondition(conditions []metav1.Condition) metav1.Condition {
+	var unknown *metav1.Condition
+	for i := range conditions {
+		condition := &conditions[i]
+		if condition.Type == TypeReady {
+			continue
+		}
+		switch condition.Status {
+		case metav1.ConditionTrue:
+		case metav1.ConditionFalse:
+			return notReadyCondition(metav1.ConditionFalse, condition)
+		default:
+			if unknown == nil {
+				unknown = condition
+			}
+		}
+	}
+	if unknown != nil {
+		return notReadyCondition(metav1.ConditionUnknown, unknown)
+	}
+	return metav1.Condition{
+		Type:    TypeReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Success",
+		Message: "all conditions are true",
+	}
+}
+
+func notReadyCondition(status metav1.ConditionStatus, cause *metav1.Condition) metav1.Condition {
+	return metav1.Condition{
+		Type:    TypeReady,
+		Status:  status,
+		Reason:  cause.Reason,
+		Message: fmt.Sprintf("%s is %s: %s", cause.Type, cause.Status, cause.Message),
+	}
+}
+
+// Prune removes all conditions whose type is not one of the given types, e.g. the conditions of an older version of
+// a controller. It returns true when existing was changed.
+func Prune(existing *[]metav1.Condition, types ...string) bool {
+	keep := make(map[string]bool, len(types))
+	for _, t := range types {
+		keep[t] = true
+	}
+
+	pruned := (*existing)[:0]
+	for _, condition := range *existing {
+		if keep[condition.Type] {
+			pruned = append(pruned, condition)
+		}
+	}
+	if len(pruned) == len(*existing) {
+		return false
+	}
+	*existing = pruned
+	return true
+}
+
+// RecordTransitions records an Event about obj for the conditions which changed their status from existing to
+// updated, so that "kubectl describe" shows why a resource became unready without looking at the logs of the pods.
+// Every condition other than Ready which becomes false gets a Warning event, and the Ready condition gets a Normal
+// event when it becomes true. Unknown conditions, e.g. of a resource which is still initializing, and the Ready
+// condition becoming false, which only repeats its cause, do not get any events.
+// The action is what the controller was doing, e.g. "ValidateUpstream".
+func RecordTransitions(recorder events.EventRecorder, obj runtime.Object, action string, existing, updated []metav1.Condition) {
+	for _, condition := range updated {
+		if old := Find(existing, condition.Type); old != nil && old.Status == condition.Status {
+			continue
+		}
+		switch {
+		case condition.Type == TypeReady && condition.Status == metav1.ConditionTrue:
+			recorder.Eventf(obj, nil, corev1.EventTypeNormal, condition.Reason, action, "%s", condition.Message)
+		case condition.Type != TypeReady && condition.Status == metav1.ConditionFalse:
+			recorder.Eventf(obj, nil, corev1.EventTypeWarning, condition.Reason, action,
+				"%s is %s: %s", condition.Type, condition.Status, condition.Message)
+		}
+	}
+}
+
+// Find returns the condition of the given type, or nil when there is none. The returned pointer refers to the
+// element of conditions, so it can be used to change it.
+func Find(conditions []metav1.Condition, conditionType string) *metav1.Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package conditionsutil
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	conciergeconfigv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+)
+
+func TestMerge(t *testing.T) {
+	earlier := metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	now := metav1.NewTime(earlier.Add(time.Hour))
+
+	existing := []metav1.Condition{
+		{Type: "TypeB", Status: metav1.ConditionTrue, ObservedGeneration: 1, LastTransitionTime: earlier, Reason: "Success", Message: "b is fine"},
+		{Type: "TypeA", Status: metav1.ConditionTrue, ObservedGeneration: 1, LastTransitionTime: earlier, Reason: "Success", Message: "a is fine"},
+	}
+
+	// Merging the same conditions with the same generation does not change anything.
+	require.False(t, Merge(&existing, 1, now,
+		metav1.Condition{Type: "TypeA", Status: metav1.ConditionTrue, Reason: "Success", Message: "a is fine"},
+	))
+
+	// A new generation with the same status keeps the transition time, a changed status gets the new time, and
+	// new types are added, all sorted by type.
+	require.True(t, Merge(&existing, 2, now,
+		metav1.Condition{Type: "TypeA", Status: metav1.ConditionTrue, Reason: "Success", Message: "a is still fine"},
+		metav1.Condition{Type: "TypeB", Status: metav1.ConditionFalse, Reason: "Broken", Message: "b is broken"},
+		metav1.Condition{Type: "TypeC", Status: metav1.ConditionUnknown, Reason: "Pending", Message: "c is unknown"},
+	))
+	require.Equal(t, []metav1.Condition{
+		{Type: "TypeA", Status: metav1.ConditionTrue, ObservedGeneration: 2, LastTransitionTime: earlier, Reason: "Success", Message: "a is still fine"},
+		{Type: "TypeB", Status: metav1.ConditionFalse, ObservedGeneration: 2, LastTransitionTime: now, Reason: "Broken", Message: "b is broken"},
+		{Type: "TypeC", Status: metav1.ConditionUnknown, ObservedGeneration: 2, LastTransitionTime: now, Reason: "Pending", Message: "c is unknown"},
+	}, existing)
+}
+
+func TestMergeWithReady(t *testing.T) {
+	now := metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	var existing []metav1.Condition
+
+	require.True(t, MergeWithReady(&existing, 1, now,
+		metav1.Condition{Type: "TypeA", Status: metav1.ConditionTrue, Reason: "Success", Message: "a is fine"},
+		metav1.Condition{Type: "TypeB", Status: metav1.ConditionUnknown, Reason: "Pending", Message: "b is pending"},
+	))
+	require.Equal(t, metav1.Condition{
+		Type: TypeReady, Status: metav1.ConditionUnknown, ObservedGeneration: 1, LastTransitionTime: now,
+		Reason: "Pending", Message: "TypeB is Unknown: b is pending",
+	}, *Find(existing, TypeReady))
+
+	// Conditions set by other controllers are taken into account, and false wins over unknown.
+	require.True(t, MergeWithReady(&existing, 1, now,
+		metav1.Condition{Type: "TypeC", Status: metav1.ConditionFalse, Reason: "Broken", Message: "c is broken"},
+	))
+	require.Equal(t, metav1.ConditionFalse, Find(existing, TypeReady).Status)
+	require.Equal(t, "TypeC is False: c is broken", Find(existing, TypeReady).Message)
+
+	require.True(t, MergeWithReady(&existing, 1, now,
+		metav1.Condition{Type: "TypeB", Status: metav1.ConditionTrue, Reason: "Success", Message: "b is fine"},
+		metav1.Condition{Type: "TypeC", Status: metav1.ConditionTrue, Reason: "Success", Message: "c is fine"},
+	))
+	require.Equal(t, metav1.Condition{
+		Type: TypeReady, Status: metav1.ConditionTrue, ObservedGeneration: 1, LastTransitionTime: now,
+		Reason: "Success", Message: "all conditions are true",
+	}, *Find(existing, TypeReady))
+	require.Len(t, existing, 4)
+
+	require.False(t, MergeWithReady(&existing, 1, now,
+		metav1.Condition{Type: "TypeA", Status: metav1.ConditionTrue, Reason: "Success", Message: "a is fine"},
+	))
+}
+
+func TestPrune(t *testing.T) {
+	existing := []metav1.Condition{{Type: "TypeA"}, {Type: "TypeB"}, {Type: "TypeC"}}
+	require.False(t, Prune(&existing, "TypeA", "TypeB", "TypeC", "TypeD"))
+	require.Len(t, existing, 3)
+
+	require.True(t, Prune(&existing, "TypeA", "TypeC"))
+	require.Equal(t, []metav1.Condition{{Type: "TypeA"}, {Type: "TypeC"}}, existing)
+}
+
+func TestFind(t *testing.T) {
+	conditions := []metav1.Condition{{Type: "TypeA"}, {Type: "TypeB"}}
+	require.Nil(t, Find(conditions, "TypeC"))
+
+	found := Find(conditions, "TypeB")
+	require.NotNil(t, found)
+	found.Reason = "Changed"
+	require.Equal(t, "Changed", conditions[1].Reason)
+}
+
+func TestConvert(t *testing.T) {
+	require.Nil(t, FromAuthenticationV1alpha1(nil))
+	require.Nil(t, ToAuthenticationV1alpha1(nil))
+
+	original := []auth1alpha1.Condition{{
+		Type:               auth1alpha1.ConditionTypeSecretValid,
+		Status:             auth1alpha1.ConditionFalse,
+		ObservedGeneration: 3,
+		LastTransitionTime: metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)),
+		Reason:             "SecretNotFound",
+		Message:            "secret not found",
+	}}
+	converted := FromAuthenticationV1alpha1(original)
+	require.Equal(t, metav1.ConditionFalse, converted[0].Status)
+	require.Equal(t, original, ToAuthenticationV1alpha1(converted))
+	require.Equal(t, converted, FromConfigV1alpha1(ToConfigV1alpha1(converted)))
+	require.Equal(t, converted, FromIDPV1alpha1(ToIDPV1alpha1(converted)))
+	require.Equal(t, converted, FromConciergeConfigV1alpha1(ToConciergeConfigV1alpha1(converted)))
+}
+
+// The conversions copy each field by name, so a field which is added to metav1.Condition must be added to the
+// Condition type of every API group, and to the conversions.
+func TestAPIConditionsMirrorMetav1Condition(t *testing.T) {
+	want := reflect.TypeOf(metav1.Condition{})
+	for _, apiCondition := range []interface{}{
+		auth1alpha1.Condition{},
+		conciergeconfigv1alpha1.Condition{},
+		configv1alpha1.Condition{},
+		idpv1alpha1.Condition{},
+	} {
+		got := reflect.TypeOf(apiCondition)
+		require.Equal(t, want.NumField(), got.NumField(), got.PkgPath())
+		for i := 0; i < want.NumField(); i++ {
+			wantField, gotField := want.Field(i), got.Field(i)
+			require.Equal(t, wantField.Name, gotField.Name, got.PkgPath())
+			require.Equal(t, wantField.Type.Kind(), gotField.Type.Kind(), "%s.%s", got.PkgPath(), gotField.Name)
+			require.Equal(t, wantField.Tag.Get("json"), gotField.Tag.Get("json"), "%s.%s", got.PkgPath(), gotField.Name)
+		}
+	}
+}
+
+func TestRecordTransitions(t *testing.T) {
+	obj := &auth1alpha1.JWTAuthenticator{ObjectMeta: metav1.ObjectMeta{Name: "some-authenticator"}}
+	existing := []metav1.Condition{
+		{Type: "TypeA", Status: metav1.ConditionTrue, Reason: "Success", Message: "a is fine"},
+		{Type: "TypeB", Status: metav1.ConditionTrue, Reason: "Success", Message: "b is fine"},
+		{Type: TypeReady, Status: metav1.ConditionTrue, Reason: "Success", Message: "all conditions are true"},
+	}
+	broken := []metav1.Condition{
+		{Type: "TypeA", Status: metav1.ConditionTrue, Reason: "Success", Message: "a is still fine"},
+		{Type: "TypeB", Status: metav1.ConditionFalse, Reason: "Broken", Message: "b is broken"},
+		{Type: "TypeC", Status: metav1.ConditionUnknown, Reason: "Pending", Message: "c is unknown"},
+		{Type: TypeReady, Status: metav1.ConditionFalse, Reason: "Broken", Message: "TypeB is False: b is broken"},
+	}
+
+	recorder := events.NewFakeRecorder(10)
+	RecordTransitions(recorder, obj, "Validate", existing, existing)
+	RecordTransitions(recorder, obj, "Validate", existing, broken)
+	RecordTransitions(recorder, obj, "Validate", broken, existing)
+	RecordTransitions(recorder, obj, "Validate", nil, existing)
+	close(recorder.Events)
+
+	var got []string
+	for event := range recorder.Events {
+		got = append(got, event)
+	}
+	require.Equal(t, []string{
+		"Warning Broken TypeB is False: b is broken",
+		"Normal Success all conditions are true",
+		"Normal Success all conditions are true",
+	}, got)
+}
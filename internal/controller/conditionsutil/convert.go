@@ -0,0 +1,165 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package conditionsutil
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	conciergeconfigv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+)
+
+// FromAuthenticationV1alpha1 converts the conditions of a Concierge authenticator.
+func FromAuthenticationV1alpha1(conditions []auth1alpha1.Condition) []metav1.Condition {
+	if conditions == nil {
+		return nil
+	}
+	result := make([]metav1.Condition, 0, len(conditions))
+	for _, c := range conditions {
+		result = append(result, metav1.Condition{
+			Type:               c.Type,
+			Status:             metav1.ConditionStatus(c.Status),
+			ObservedGeneration: c.ObservedGeneration,
+			LastTransitionTime: c.LastTransitionTime,
+			Reason:             c.Reason,
+			Message:            c.Message,
+		})
+	}
+	return result
+}
+
+// ToAuthenticationV1alpha1 converts conditions for a Concierge authenticator.
+func ToAuthenticationV1alpha1(conditions []metav1.Condition) []auth1alpha1.Condition {
+	if conditions == nil {
+		return nil
+	}
+	result := make([]auth1alpha1.Condition, 0, len(conditions))
+	for _, c := range conditions {
+		result = append(result, auth1alpha1.Condition{
+			Type:               c.Type,
+			Status:             auth1alpha1.ConditionStatus(c.Status),
+			ObservedGeneration: c.ObservedGeneration,
+			LastTransitionTime: c.LastTransitionTime,
+			Reason:             c.Reason,
+			Message:            c.Message,
+		})
+	}
+	return result
+}
+
+// FromConfigV1alpha1 converts the conditions of a FederationDomain.
+func FromConfigV1alpha1(conditions []configv1alpha1.Condition) []metav1.Condition {
+	if conditions == nil {
+		return nil
+	}
+	result := make([]metav1.Condition, 0, len(conditions))
+	for _, c := range conditions {
+		result = append(result, metav1.Condition{
+			Type:               c.Type,
+			Status:             metav1.ConditionStatus(c.Status),
+			ObservedGeneration: c.ObservedGeneration,
+			LastTransitionTime: c.LastTransitionTime,
+			Reason:             c.Reason,
+			Message:            c.Message,
+		})
+	}
+	return result
+}
+
+// ToConfigV1alpha1 converts conditions for a FederationDomain.
+func ToConfigV1alpha1(conditions []metav1.Condition) []configv1alpha1.Condition {
+	if conditions == nil {
+		return nil
+	}
+	result := make([]configv1alpha1.Condition, 0, len(conditions))
+	for _, c := range conditions {
+		result = append(result, configv1alpha1.Condition{
+			Type:               c.Type,
+			Status:             configv1alpha1.ConditionStatus(c.Status),
+			ObservedGeneration: c.ObservedGeneration,
+			LastTransitionTime: c.LastTransitionTime,
+			Reason:             c.Reason,
+			Message:            c.Message,
+		})
+	}
+	return result
+}
+
+// FromIDPV1alpha1 converts the conditions of an upstream identity provider.
+func FromIDPV1alpha1(conditions []idpv1alpha1.Condition) []metav1.Condition {
+	if conditions == nil {
+		return nil
+	}
+	result := make([]metav1.Condition, 0, len(conditions))
+	for _, c := range conditions {
+		result = append(result, metav1.Condition{
+			Type:               c.Type,
+			Status:             metav1.ConditionStatus(c.Status),
+			ObservedGeneration: c.ObservedGeneration,
+			LastTransitionTime: c.LastTransitionTime,
+			Reason:             c.Reason,
+			Message:            c.Message,
+		})
+	}
+	return result
+}
+
+// ToIDPV1alpha1 converts conditions for an upstream identity provider.
+func ToIDPV1alpha1(conditions []metav1.Condition) []idpv1alpha1.Condition {
+	if conditions == nil {
+		return nil
+	}
+	result := make([]idpv1alpha1.Condition, 0, len(conditions))
+	for _, c := range conditions {
+		result = append(result, idpv1alpha1.Condition{
+			Type:               c.Type,
+			Status:             idpv1alpha1.ConditionStatus(c.Status),
+			ObservedGeneration: c.ObservedGeneration,
+			LastTransitionTime: c.LastTransitionTime,
+			Reason:             c.Reason,
+			Message:            c.Message,
+		})
+	}
+	return result
+}
+
+// FromConciergeConfigV1alpha1 converts the conditions of a CredentialIssuer.
+func FromConciergeConfigV1alpha1(conditions []conciergeconfigv1alpha1.Condition) []metav1.Condition {
+	if conditions == nil {
+		return nil
+	}
+	result := make([]metav1.Condition, 0, len(conditions))
+	for _, c := range conditions {
+		result = append(result, metav1.Condition{
+			Type:               c.Type,
+			Status:             metav1.ConditionStatus(c.Status),
+			ObservedGeneration: c.ObservedGeneration,
+			LastTransitionTime: c.LastTransitionTime,
+			Reason:             c.Reason,
+			Message:            c.Message,
+		})
+	}
+	return result
+}
+
+// ToConciergeConfigV1alpha1 converts conditions for a CredentialIssuer.
+func ToConciergeConfigV1alpha1(conditions []metav1.Condition) []conciergeconfigv1alpha1.Condition {
+	if conditions == nil {
+		return nil
+	}
+	result := make([]conciergeconfigv1alpha1.Condition, 0, len(conditions))
+	for _, c := range conditions {
+		result = append(result, conciergeconfigv1alpha1.Condition{
+			Type:               c.Type,
+			Status:             conciergeconfigv1alpha1.ConditionStatus(c.Status),
+			ObservedGeneration: c.ObservedGeneration,
+			LastTransitionTime: c.LastTransitionTime,
+			Reason:             c.Reason,
+			Message:            c.Message,
+		})
+	}
+	return result
+}
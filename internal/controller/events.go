@@ -0,0 +1,43 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/client-go/tools/record"
+
+	conciergescheme "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/scheme"
+	supervisorscheme "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/scheme"
+)
+
+// NewEventRecorder returns an EventRecorder which creates Events about the Kubernetes and Pinniped resources on behalf
+// of the given component, once the returned start function has been called and until its context is done. It creates
+// core/v1 Events, because the events.k8s.io API group does not exist in all supported Kubernetes versions, and
+// "kubectl describe" shows both.
+func NewEventRecorder(client kubernetes.Interface, component string) (events.EventRecorder, func(ctx context.Context)) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(kubescheme.AddToScheme(scheme))
+	utilruntime.Must(conciergescheme.AddToScheme(scheme))
+	utilruntime.Must(supervisorscheme.AddToScheme(scheme))
+
+	broadcaster := record.NewBroadcaster()
+	recorder := record.NewEventRecorderAdapter(broadcaster.NewRecorder(scheme, corev1.EventSource{Component: component}))
+	start := func(ctx context.Context) {
+		broadcaster.StartStructuredLogging(4)
+		broadcaster.StartRecordingToSink(&corev1client.EventSinkImpl{Interface: client.CoreV1().Events("")})
+		go func() {
+			<-ctx.Done()
+			broadcaster.Shutdown()
+		}()
+	}
+	return recorder, start
+}
@@ -0,0 +1,470 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package impersonatorconfig contains a controller which starts and stops the impersonation proxy of the Concierge
+// and reports its status as a strategy of the CredentialIssuer.
+package impersonatorconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	pinnipedclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
+	configinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions/config/v1alpha1"
+	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/config/concierge"
+	"go.pinniped.dev/internal/constable"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/issuerconfig"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	caCertificateSecretKey = "ca.crt"
+	caPrivateKeySecretKey  = "ca.key"
+
+	// The next CA is staged under these keys while both it and the current CA are published.
+	nextCACertificateSecretKey = "next-ca.crt"
+	nextCAPrivateKeySecretKey  = "next-ca.key"
+
+	// The serving certificate is reissued servingCertRenewBefore it expires.
+	servingCertLifetime    = 30 * 24 * time.Hour
+	servingCertRenewBefore = 7 * 24 * time.Hour
+
+	// certificateCheckInterval is how often the certificates of a running proxy are checked for rotation.
+	certificateCheckInterval = time.Hour
+
+	// ErrNoExternalEndpoint is reported when the impersonation proxy should run, but it is not known how clients
+	// can reach it.
+	ErrNoExternalEndpoint = constable.Error("the impersonation proxy cannot be started because impersonationProxy.externalEndpoint is not configured")
+)
+
+// ListenFunc is the signature of net.Listen, which is injected for testing.
+type ListenFunc func(network, address string) (net.Listener, error)
+
+type impersonatorConfigController struct {
+	namespace                    string
+	credentialIssuerResourceName string
+	tlsSecretName                string
+	labels                       map[string]string
+	spec                         concierge.ImpersonationProxySpec
+	k8sClient                    kubernetes.Interface
+	pinnipedClient               pinnipedclientset.Interface
+	credentialIssuerInformer     configinformers.CredentialIssuerInformer
+	secretInformer               corev1informers.SecretInformer
+	handler                      http.Handler
+	listen                       ListenFunc
+	clock                        clock.Clock
+
+	server *http.Server
+
+	certMutex sync.RWMutex
+	cert      *tls.Certificate
+}
+
+// NewImpersonatorConfigController returns a controller which runs the impersonation proxy whenever it is enabled,
+// or in "auto" mode whenever the CredentialIssuer reports that the KubeClusterSigningCertificate strategy is not
+// working. It stores the CA and serving certificate of the proxy in the Secret named tlsSecretName and publishes
+// the endpoint and CA of the proxy in the ImpersonationProxy strategy of the CredentialIssuer.
+func NewImpersonatorConfigController(
+	namespace string,
+	credentialIssuerResourceName string,
+	tlsSecretName string,
+	labels map[string]string,
+	spec concierge.ImpersonationProxySpec,
+	k8sClient kubernetes.Interface,
+	pinnipedClient pinnipedclientset.Interface,
+	credentialIssuerInformer configinformers.CredentialIssuerInformer,
+	secretInformer corev1informers.SecretInformer,
+	handler http.Handler,
+	listen ListenFunc,
+	clock clock.Clock,
+	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	withInitialEvent pinnipedcontroller.WithInitialEventOptionFunc,
+	opts ...controllerlib.Option,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "impersonator-config-controller",
+			Syncer: &impersonatorConfigController{
+				namespace:                    namespace,
+				credentialIssuerResourceName: credentialIssuerResourceName,
+				tlsSecretName:                tlsSecretName,
+				labels:                       labels,
+				spec:                         spec,
+				k8sClient:                    k8sClient,
+				pinnipedClient:               pinnipedClient,
+				credentialIssuerInformer:     credentialIssuerInformer,
+				secretInformer:               secretInformer,
+				handler:                      handler,
+				listen:                       listen,
+				clock:                        clock,
+			},
+		},
+		append([]controllerlib.Option{
+			withInformer(
+				credentialIssuerInformer,
+				pinnipedcontroller.SimpleFilterWithSingletonQueue(func(obj metav1.Object) bool {
+					return obj.GetName() == credentialIssuerResourceName
+				}),
+				controllerlib.InformerOption{},
+			),
+			withInformer(
+				secretInformer,
+				pinnipedcontroller.SimpleFilterWithSingletonQueue(func(obj metav1.Object) bool {
+					return obj.GetName() == tlsSecretName && obj.GetNamespace() == namespace
+				}),
+				controllerlib.InformerOption{},
+			),
+			// Be sure to run once even if neither the CredentialIssuer nor the Secret exist yet.
+			withInitialEvent(controllerlib.Key{}),
+		}, opts...)...,
+	)
+}
+
+func (c *impersonatorConfigController) Sync(ctx controllerlib.Context) error {
+	shouldRun, reason, err := c.shouldRun()
+	if err != nil {
+		return err
+	}
+
+	if !shouldRun {
+		if err := c.stop(); err != nil {
+			return err
+		}
+		return c.updateStrategy(ctx, configv1alpha1.CredentialIssuerStrategy{
+			Type:    configv1alpha1.ImpersonationProxyStrategyType,
+			Status:  configv1alpha1.ErrorStrategyStatus,
+			Reason:  configv1alpha1.DisabledStrategyReason,
+			Message: reason,
+		})
+	}
+
+	info, err := c.ensureRunning(ctx)
+	if err != nil {
+		strategyErr := c.updateStrategy(ctx, configv1alpha1.CredentialIssuerStrategy{
+			Type:    configv1alpha1.ImpersonationProxyStrategyType,
+			Status:  configv1alpha1.ErrorStrategyStatus,
+			Reason:  configv1alpha1.ErrorDuringSetupStrategyReason,
+			Message: err.Error(),
+		})
+		if errors.Is(err, ErrNoExternalEndpoint) {
+			// There is nothing to retry until the config is changed, which requires a restart.
+			return strategyErr
+		}
+		if strategyErr != nil {
+			plog.Error("could not update CredentialIssuer status", strategyErr)
+		}
+		return err
+	}
+
+	// Neither the CredentialIssuer nor the Secret change when a certificate is due for rotation.
+	ctx.Queue.AddAfter(ctx.Key, certificateCheckInterval)
+	return c.updateStrategy(ctx, configv1alpha1.CredentialIssuerStrategy{
+		Type:                   configv1alpha1.ImpersonationProxyStrategyType,
+		Status:                 configv1alpha1.SuccessStrategyStatus,
+		Reason:                 configv1alpha1.ListeningStrategyReason,
+		Message:                "impersonation proxy is ready to accept client connections",
+		ImpersonationProxyInfo: info,
+	})
+}
+
+// shouldRun decides whether the impersonation proxy should be running, and explains why not when it should not.
+func (c *impersonatorConfigController) shouldRun() (bool, string, error) {
+	switch c.spec.Mode {
+	case concierge.ImpersonationProxyModeEnabled:
+		return true, "", nil
+	case concierge.ImpersonationProxyModeAuto:
+	default:
+		return false, "the impersonation proxy was disabled by configuration", nil
+	}
+
+	credentialIssuer, err := c.credentialIssuerInformer.Lister().Get(c.credentialIssuerResourceName)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return false, "", fmt.Errorf("failed to get CredentialIssuer %s: %w", c.credentialIssuerResourceName, err)
+	}
+	if credentialIssuer == nil {
+		return false, "the impersonation proxy is waiting for the status of the KubeClusterSigningCertificate strategy", nil
+	}
+
+	kubeCertAgentStrategy := issuerconfig.FindStrategy(&credentialIssuer.Status, configv1alpha1.KubeClusterSigningCertificateStrategyType)
+	switch {
+	case kubeCertAgentStrategy == nil:
+		return false, "the impersonation proxy is waiting for the status of the KubeClusterSigningCertificate strategy", nil
+	case kubeCertAgentStrategy.Status == configv1alpha1.SuccessStrategyStatus:
+		return false, "the impersonation proxy is not needed because the KubeClusterSigningCertificate strategy is working", nil
+	default:
+		return true, "", nil
+	}
+}
+
+func (c *impersonatorConfigController) ensureRunning(ctx controllerlib.Context) (*configv1alpha1.ImpersonationProxyInfo, error) {
+	if c.spec.ExternalEndpoint == "" {
+		return nil, ErrNoExternalEndpoint
+	}
+	host := c.spec.ExternalEndpoint
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	caBundle, err := c.ensureCertificate(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.server == nil {
+		listener, err := c.listen("tcp", fmt.Sprintf(":%d", c.spec.Port))
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on port %d: %w", c.spec.Port, err)
+		}
+		c.server = &http.Server{Handler: c.handler}
+		go func(server *http.Server, listener net.Listener) {
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				plog.Error("impersonation proxy stopped unexpectedly", err)
+			}
+		}(c.server, tls.NewListener(listener, &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: c.getCertificate,
+		}))
+		plog.Info("started impersonation proxy", "port", c.spec.Port, "externalEndpoint", c.spec.ExternalEndpoint)
+	}
+
+	return &configv1alpha1.ImpersonationProxyInfo{
+		Endpoint:                 "https://" + c.spec.ExternalEndpoint,
+		CertificateAuthorityData: base64.StdEncoding.EncodeToString(caBundle),
+	}, nil
+}
+
+func (c *impersonatorConfigController) stop() error {
+	if c.server == nil {
+		return nil
+	}
+	err := c.server.Close()
+	c.server = nil
+	if err != nil {
+		return fmt.Errorf("failed to stop impersonation proxy: %w", err)
+	}
+	plog.Info("stopped impersonation proxy")
+	return nil
+}
+
+func (c *impersonatorConfigController) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.certMutex.RLock()
+	defer c.certMutex.RUnlock()
+	if c.cert == nil {
+		return nil, constable.Error("the impersonation proxy does not have a serving certificate yet")
+	}
+	return c.cert, nil
+}
+
+// ensureCertificate loads the serving certificate from the Secret, or issues a new one when it does not exist, is not
+// valid for the host, or is about to expire. It also rotates the CA as configured by spec.CertificateAuthority: the
+// next CA is staged rotationOverlapSeconds before the current one is replaced, which happens replaceBeforeSeconds
+// before it expires. It returns the CA bundle to publish, which contains the staged CA, if any.
+func (c *impersonatorConfigController) ensureCertificate(ctx controllerlib.Context, host string) ([]byte, error) {
+	caSpec := c.spec.CertificateAuthority
+	caLifetime := time.Duration(*caSpec.DurationSeconds) * time.Second
+	caReplaceBefore := time.Duration(*caSpec.ReplaceBeforeSeconds) * time.Second
+	caStageBefore := caReplaceBefore + time.Duration(*caSpec.RotationOverlapSeconds)*time.Second
+
+	secret, err := c.secretInformer.Lister().Secrets(c.namespace).Get(c.tlsSecretName)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get Secret %s: %w", c.tlsSecretName, err)
+	}
+	now := c.clock.Now()
+
+	var ca, nextCA *certauthority.CA
+	var caNotAfter, nextCANotAfter time.Time
+	if secret != nil {
+		ca, caNotAfter = loadCA(secret, caCertificateSecretKey, caPrivateKeySecretKey)
+		nextCA, nextCANotAfter = loadCA(secret, nextCACertificateSecretKey, nextCAPrivateKeySecretKey)
+	}
+	caChanged := false
+	if ca == nil || !now.Before(caNotAfter.Add(-caReplaceBefore)) {
+		ca, caNotAfter, nextCA = nextCA, nextCANotAfter, nil
+		if ca == nil || !now.Before(caNotAfter.Add(-caReplaceBefore)) {
+			if ca, caNotAfter, err = newCA(now, caLifetime); err != nil {
+				return nil, err
+			}
+		}
+		caChanged = true
+	}
+	if nextCA == nil && !now.Before(caNotAfter.Add(-caStageBefore)) {
+		if nextCA, _, err = newCA(now, caLifetime); err != nil {
+			return nil, err
+		}
+		caChanged = true
+	}
+	caBundle := ca.Bundle()
+	if nextCA != nil {
+		caBundle = append(caBundle, nextCA.Bundle()...)
+	}
+
+	if secret != nil && !caChanged {
+		if cert, err := validCertificate(secret, host, now.Add(servingCertRenewBefore)); err == nil {
+			c.setCertificate(cert)
+			return caBundle, nil
+		}
+	}
+
+	caKeyPEM, err := ca.PrivateKeyToPEM()
+	if err != nil {
+		return nil, err
+	}
+
+	var dnsNames []string
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		dnsNames = []string{host}
+	}
+	lifetime := servingCertLifetime
+	if remaining := caNotAfter.Sub(now); remaining < lifetime {
+		lifetime = remaining
+	}
+	cert, err := ca.Issue(pkix.Name{}, dnsNames, ips, lifetime)
+	if err != nil {
+		return nil, fmt.Errorf("could not issue impersonation proxy serving certificate: %w", err)
+	}
+	certPEM, keyPEM, err := certauthority.ToPEM(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	newSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.tlsSecretName,
+			Namespace: c.namespace,
+			Labels:    c.labels,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			caCertificateSecretKey:  ca.Bundle(),
+			caPrivateKeySecretKey:   caKeyPEM,
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		},
+	}
+	if nextCA != nil {
+		nextCAKeyPEM, err := nextCA.PrivateKeyToPEM()
+		if err != nil {
+			return nil, err
+		}
+		newSecret.Data[nextCACertificateSecretKey] = nextCA.Bundle()
+		newSecret.Data[nextCAPrivateKeySecretKey] = nextCAKeyPEM
+	}
+	if secret == nil {
+		_, err = c.k8sClient.CoreV1().Secrets(c.namespace).Create(ctx.Context, newSecret, metav1.CreateOptions{})
+	} else {
+		newSecret.ResourceVersion = secret.ResourceVersion
+		_, err = c.k8sClient.CoreV1().Secrets(c.namespace).Update(ctx.Context, newSecret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write Secret %s: %w", c.tlsSecretName, err)
+	}
+	plog.Info("issued impersonation proxy serving certificate",
+		"secret", klog.KRef(c.namespace, c.tlsSecretName),
+		"host", host,
+		"caRotated", caChanged,
+	)
+
+	c.setCertificate(cert)
+	return caBundle, nil
+}
+
+// newCA creates a CA which is valid for caLifetime from now.
+func newCA(now time.Time, caLifetime time.Duration) (*certauthority.CA, time.Time, error) {
+	ca, err := certauthority.New(pkix.Name{CommonName: "Pinniped Impersonation Proxy CA"}, caLifetime)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("could not create impersonation proxy CA: %w", err)
+	}
+	return ca, now.Add(caLifetime), nil
+}
+
+// loadCA loads the CA stored under the given keys of the Secret and returns when it expires, or nil when the Secret
+// does not contain a valid CA.
+func loadCA(secret *corev1.Secret, certKey, keyKey string) (*certauthority.CA, time.Time) {
+	ca, err := certauthority.Load(string(secret.Data[certKey]), string(secret.Data[keyKey]))
+	if err != nil {
+		return nil, time.Time{}
+	}
+	block, _ := pem.Decode(secret.Data[certKey])
+	if block == nil {
+		return nil, time.Time{}
+	}
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, time.Time{}
+	}
+	return ca, caCert.NotAfter
+}
+
+func (c *impersonatorConfigController) setCertificate(cert *tls.Certificate) {
+	c.certMutex.Lock()
+	defer c.certMutex.Unlock()
+	c.cert = cert
+}
+
+// validCertificate returns the serving certificate from the Secret when it was issued by the CA from the Secret and
+// is valid for the host at the given time.
+func validCertificate(secret *corev1.Secret, host string, at time.Time) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(secret.Data[caCertificateSecretKey]) {
+		return nil, constable.Error("invalid CA certificate")
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots, CurrentTime: at}); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// updateStrategy sets the ImpersonationProxy strategy of the CredentialIssuer. The time of the last update is only
+// changed when something else changed, because each update of the CredentialIssuer causes another sync.
+func (c *impersonatorConfigController) updateStrategy(ctx controllerlib.Context, strategy configv1alpha1.CredentialIssuerStrategy) error {
+	return issuerconfig.CreateOrUpdateCredentialIssuerStatus(
+		ctx.Context,
+		c.credentialIssuerResourceName,
+		c.labels,
+		c.pinnipedClient,
+		func(configToUpdate *configv1alpha1.CredentialIssuerStatus) {
+			strategy.LastUpdateTime = metav1.NewTime(c.clock.Now())
+			if existing := issuerconfig.FindStrategy(configToUpdate, strategy.Type); existing != nil {
+				unchanged := strategy.DeepCopy()
+				unchanged.LastUpdateTime = existing.LastUpdateTime
+				if equality.Semantic.DeepEqual(existing, unchanged) {
+					return
+				}
+			}
+			issuerconfig.SetStrategy(configToUpdate, strategy)
+		},
+	)
+}
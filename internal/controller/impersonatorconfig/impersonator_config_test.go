@@ -0,0 +1,453 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package impersonatorconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	kubeinformers "k8s.io/client-go/informers"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	pinnipedinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
+	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/config/concierge"
+	"go.pinniped.dev/internal/controllerlib"
+)
+
+const (
+	testNamespace     = "concierge"
+	testCredIssuer    = "pinniped-config"
+	testTLSSecretName = "impersonation-proxy-tls"
+)
+
+type testEnv struct {
+	controller     controllerlib.Controller
+	pinnipedClient *pinnipedfake.Clientset
+	kubeClient     *kubernetesfake.Clientset
+	secretInformer corev1informers.SecretInformer
+	listenAddr     *string
+	ctx            context.Context
+	queue          *recordingQueue
+}
+
+// defaultCASpec rotates the CA of the proxy like the default configuration does.
+func defaultCASpec() concierge.ImpersonationProxyCASpec {
+	return caSpec(365*24*time.Hour, 30*24*time.Hour, 60*24*time.Hour)
+}
+
+func caSpec(duration, replaceBefore, rotationOverlap time.Duration) concierge.ImpersonationProxyCASpec {
+	seconds := func(d time.Duration) *int64 {
+		s := int64(d / time.Second)
+		return &s
+	}
+	return concierge.ImpersonationProxyCASpec{
+		DurationSeconds:        seconds(duration),
+		ReplaceBeforeSeconds:   seconds(replaceBefore),
+		RotationOverlapSeconds: seconds(rotationOverlap),
+	}
+}
+
+func newTestEnv(t *testing.T, spec concierge.ImpersonationProxySpec, kubeCertAgentStatus configv1alpha1.StrategyStatus, secrets ...runtime.Object) *testEnv {
+	t.Helper()
+	// The certificates are issued using the real time, so the fake clock must be close to it.
+	now := time.Now()
+
+	var pinnipedObjects []runtime.Object
+	if kubeCertAgentStatus != "" {
+		pinnipedObjects = append(pinnipedObjects, &configv1alpha1.CredentialIssuer{
+			ObjectMeta: metav1.ObjectMeta{Name: testCredIssuer},
+			Status: configv1alpha1.CredentialIssuerStatus{
+				Strategies: []configv1alpha1.CredentialIssuerStrategy{{
+					Type:    configv1alpha1.KubeClusterSigningCertificateStrategyType,
+					Status:  kubeCertAgentStatus,
+					Reason:  configv1alpha1.CouldNotFetchKeyStrategyReason,
+					Message: "some kube cert agent message",
+				}},
+			},
+		})
+	}
+	pinnipedClient := pinnipedfake.NewSimpleClientset(pinnipedObjects...)
+	pinnipedInformers := pinnipedinformers.NewSharedInformerFactory(pinnipedClient, 0)
+	kubeClient := kubernetesfake.NewSimpleClientset(secrets...)
+	kubeInformers := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 0, kubeinformers.WithNamespace(testNamespace))
+
+	var listenAddr string
+	listen := func(network, address string) (net.Listener, error) {
+		require.Equal(t, "tcp", network)
+		require.Equal(t, fmt.Sprintf(":%d", spec.Port), address)
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err == nil {
+			listenAddr = l.Addr().String()
+			t.Cleanup(func() { _ = l.Close() })
+		}
+		return l, err
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "hello from the proxy")
+	})
+
+	controller := NewImpersonatorConfigController(
+		testNamespace,
+		testCredIssuer,
+		testTLSSecretName,
+		map[string]string{"app": "concierge"},
+		spec,
+		kubeClient,
+		pinnipedClient,
+		pinnipedInformers.Config().V1alpha1().CredentialIssuers(),
+		kubeInformers.Core().V1().Secrets(),
+		handler,
+		listen,
+		clock.NewFakeClock(now),
+		controllerlib.WithInformer,
+		controllerlib.WithInitialEvent,
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	pinnipedInformers.Start(ctx.Done())
+	kubeInformers.Start(ctx.Done())
+	controllerlib.TestRunSynchronously(t, controller)
+
+	return &testEnv{
+		controller:     controller,
+		pinnipedClient: pinnipedClient,
+		kubeClient:     kubeClient,
+		secretInformer: kubeInformers.Core().V1().Secrets(),
+		listenAddr:     &listenAddr,
+		ctx:            ctx,
+	}
+}
+
+func (e *testEnv) sync(t *testing.T) error {
+	t.Helper()
+	e.queue = &recordingQueue{}
+	return controllerlib.TestSync(t, e.controller, controllerlib.Context{Context: e.ctx, Queue: e.queue})
+}
+
+type recordingQueue struct {
+	addedAfter []time.Duration
+}
+
+func (q *recordingQueue) Add(controllerlib.Key)            {}
+func (q *recordingQueue) AddRateLimited(controllerlib.Key) {}
+func (q *recordingQueue) AddAfter(_ controllerlib.Key, duration time.Duration) {
+	q.addedAfter = append(q.addedAfter, duration)
+}
+
+func (e *testEnv) strategy(t *testing.T) configv1alpha1.CredentialIssuerStrategy {
+	t.Helper()
+	credentialIssuer, err := e.pinnipedClient.ConfigV1alpha1().CredentialIssuers().Get(e.ctx, testCredIssuer, metav1.GetOptions{})
+	require.NoError(t, err)
+	for _, s := range credentialIssuer.Status.Strategies {
+		if s.Type == configv1alpha1.ImpersonationProxyStrategyType {
+			s.LastUpdateTime = metav1.Time{}
+			return s
+		}
+	}
+	require.FailNow(t, "no ImpersonationProxy strategy")
+	return configv1alpha1.CredentialIssuerStrategy{}
+}
+
+func (e *testEnv) secret(t *testing.T) *corev1.Secret {
+	t.Helper()
+	secret, err := e.kubeClient.CoreV1().Secrets(testNamespace).Get(e.ctx, testTLSSecretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	return secret
+}
+
+func getThroughProxy(t *testing.T, addr, serverName string, caBundle []byte) string {
+	t.Helper()
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(caBundle))
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    roots,
+		ServerName: serverName,
+	}}}
+	resp, err := client.Get("https://" + addr + "/api")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestImpersonatorConfigControllerDisabled(t *testing.T) {
+	tests := []struct {
+		name                string
+		mode                concierge.ImpersonationProxyMode
+		kubeCertAgentStatus configv1alpha1.StrategyStatus
+		wantMessage         string
+	}{
+		{
+			name:                "disabled by configuration",
+			mode:                concierge.ImpersonationProxyModeDisabled,
+			kubeCertAgentStatus: configv1alpha1.ErrorStrategyStatus,
+			wantMessage:         "the impersonation proxy was disabled by configuration",
+		},
+		{
+			name:                "auto mode when the kube cert agent is working",
+			mode:                concierge.ImpersonationProxyModeAuto,
+			kubeCertAgentStatus: configv1alpha1.SuccessStrategyStatus,
+			wantMessage:         "the impersonation proxy is not needed because the KubeClusterSigningCertificate strategy is working",
+		},
+		{
+			name:        "auto mode when the kube cert agent has not reported its status yet",
+			mode:        concierge.ImpersonationProxyModeAuto,
+			wantMessage: "the impersonation proxy is waiting for the status of the KubeClusterSigningCertificate strategy",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, concierge.ImpersonationProxySpec{
+				Mode:                 tt.mode,
+				Port:                 8444,
+				ExternalEndpoint:     "proxy.example.com",
+				CertificateAuthority: defaultCASpec(),
+			}, tt.kubeCertAgentStatus)
+
+			require.NoError(t, env.sync(t))
+			require.Empty(t, *env.listenAddr)
+			require.Equal(t, configv1alpha1.CredentialIssuerStrategy{
+				Type:    configv1alpha1.ImpersonationProxyStrategyType,
+				Status:  configv1alpha1.ErrorStrategyStatus,
+				Reason:  configv1alpha1.DisabledStrategyReason,
+				Message: tt.wantMessage,
+			}, env.strategy(t))
+		})
+	}
+}
+
+func TestImpersonatorConfigControllerWithoutExternalEndpoint(t *testing.T) {
+	env := newTestEnv(t, concierge.ImpersonationProxySpec{
+		Mode:                 concierge.ImpersonationProxyModeEnabled,
+		Port:                 8444,
+		CertificateAuthority: defaultCASpec(),
+	}, configv1alpha1.SuccessStrategyStatus)
+
+	require.NoError(t, env.sync(t))
+	require.Empty(t, *env.listenAddr)
+	require.Equal(t, configv1alpha1.CredentialIssuerStrategy{
+		Type:    configv1alpha1.ImpersonationProxyStrategyType,
+		Status:  configv1alpha1.ErrorStrategyStatus,
+		Reason:  configv1alpha1.ErrorDuringSetupStrategyReason,
+		Message: "the impersonation proxy cannot be started because impersonationProxy.externalEndpoint is not configured",
+	}, env.strategy(t))
+}
+
+func TestImpersonatorConfigControllerLifecycle(t *testing.T) {
+	env := newTestEnv(t, concierge.ImpersonationProxySpec{
+		Mode:                 concierge.ImpersonationProxyModeAuto,
+		Port:                 8444,
+		ExternalEndpoint:     "proxy.example.com:443",
+		CertificateAuthority: defaultCASpec(),
+	}, configv1alpha1.ErrorStrategyStatus)
+
+	// The proxy is started when the kube cert agent is not working.
+	require.NoError(t, env.sync(t))
+	require.NotEmpty(t, *env.listenAddr)
+	secret := env.secret(t)
+	require.Equal(t, corev1.SecretTypeTLS, secret.Type)
+	require.Equal(t, configv1alpha1.CredentialIssuerStrategy{
+		Type:    configv1alpha1.ImpersonationProxyStrategyType,
+		Status:  configv1alpha1.SuccessStrategyStatus,
+		Reason:  configv1alpha1.ListeningStrategyReason,
+		Message: "impersonation proxy is ready to accept client connections",
+		ImpersonationProxyInfo: &configv1alpha1.ImpersonationProxyInfo{
+			Endpoint:                 "https://proxy.example.com:443",
+			CertificateAuthorityData: base64.StdEncoding.EncodeToString(secret.Data["ca.crt"]),
+		},
+	}, env.strategy(t))
+	require.Equal(t, "hello from the proxy", getThroughProxy(t, *env.listenAddr, "proxy.example.com", secret.Data["ca.crt"]))
+	require.Equal(t, []time.Duration{time.Hour}, env.queue.addedAfter)
+	servingCert := parseCertificate(t, secret.Data["tls.crt"])
+	require.WithinDuration(t, time.Now().Add(30*24*time.Hour), servingCert.NotAfter, time.Minute)
+	caCert := parseCertificate(t, secret.Data["ca.crt"])
+	require.WithinDuration(t, time.Now().Add(365*24*time.Hour), caCert.NotAfter, time.Minute)
+
+	// Syncing again reuses the running proxy and the stored certificate.
+	require.Eventually(t, func() bool {
+		_, err := env.secretInformer.Lister().Secrets(testNamespace).Get(testTLSSecretName)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	actionCount := len(env.kubeClient.Actions())
+	listenAddr := *env.listenAddr
+	require.NoError(t, env.sync(t))
+	require.Len(t, env.kubeClient.Actions(), actionCount)
+	require.Equal(t, listenAddr, *env.listenAddr)
+	require.Equal(t, secret, env.secret(t))
+
+	// The proxy is stopped when the kube cert agent starts working.
+	credentialIssuer, err := env.pinnipedClient.ConfigV1alpha1().CredentialIssuers().Get(env.ctx, testCredIssuer, metav1.GetOptions{})
+	require.NoError(t, err)
+	credentialIssuer.Status.Strategies[0].Status = configv1alpha1.SuccessStrategyStatus
+	_, err = env.pinnipedClient.ConfigV1alpha1().CredentialIssuers().UpdateStatus(env.ctx, credentialIssuer, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return env.sync(t) == nil && env.strategy(t).Reason == configv1alpha1.DisabledStrategyReason
+	}, 5*time.Second, 10*time.Millisecond)
+	_, err = net.Dial("tcp", *env.listenAddr)
+	require.Error(t, err)
+}
+
+func TestImpersonatorConfigControllerReissuesCertificateForNewHost(t *testing.T) {
+	ca, err := certauthority.New(pkix.Name{CommonName: "existing CA"}, 365*24*time.Hour)
+	require.NoError(t, err)
+	caKeyPEM, err := ca.PrivateKeyToPEM()
+	require.NoError(t, err)
+	cert, err := ca.Issue(pkix.Name{}, []string{"old.example.com"}, nil, time.Hour)
+	require.NoError(t, err)
+	certPEM, keyPEM, err := certauthority.ToPEM(cert)
+	require.NoError(t, err)
+
+	env := newTestEnv(t, concierge.ImpersonationProxySpec{
+		Mode:                 concierge.ImpersonationProxyModeEnabled,
+		Port:                 8444,
+		ExternalEndpoint:     "10.1.2.3",
+		CertificateAuthority: defaultCASpec(),
+	}, "", &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: testTLSSecretName, Namespace: testNamespace, ResourceVersion: "1"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			"ca.crt":  ca.Bundle(),
+			"ca.key":  caKeyPEM,
+			"tls.crt": certPEM,
+			"tls.key": keyPEM,
+		},
+	})
+
+	require.NoError(t, env.sync(t))
+	secret := env.secret(t)
+	require.Equal(t, ca.Bundle(), secret.Data["ca.crt"], "the CA should be kept")
+	require.NotEqual(t, certPEM, secret.Data["tls.crt"], "the serving certificate should be reissued")
+	require.Equal(t, "hello from the proxy", getThroughProxy(t, *env.listenAddr, "10.1.2.3", ca.Bundle()))
+}
+
+func parseCertificate(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode(certPEM)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestImpersonatorConfigControllerRotatesCA(t *testing.T) {
+	newCASecret := func(t *testing.T, caLifetime time.Duration, next *certauthority.CA) (*corev1.Secret, *certauthority.CA) {
+		t.Helper()
+		ca, err := certauthority.New(pkix.Name{CommonName: "existing CA"}, caLifetime)
+		require.NoError(t, err)
+		caKeyPEM, err := ca.PrivateKeyToPEM()
+		require.NoError(t, err)
+		cert, err := ca.Issue(pkix.Name{}, []string{"proxy.example.com"}, nil, caLifetime)
+		require.NoError(t, err)
+		certPEM, keyPEM, err := certauthority.ToPEM(cert)
+		require.NoError(t, err)
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: testTLSSecretName, Namespace: testNamespace, ResourceVersion: "1"},
+			Type:       corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				"ca.crt":  ca.Bundle(),
+				"ca.key":  caKeyPEM,
+				"tls.crt": certPEM,
+				"tls.key": keyPEM,
+			},
+		}
+		if next != nil {
+			nextKeyPEM, err := next.PrivateKeyToPEM()
+			require.NoError(t, err)
+			secret.Data["next-ca.crt"] = next.Bundle()
+			secret.Data["next-ca.key"] = nextKeyPEM
+		}
+		return secret, ca
+	}
+	spec := concierge.ImpersonationProxySpec{
+		Mode:                 concierge.ImpersonationProxyModeEnabled,
+		Port:                 8444,
+		ExternalEndpoint:     "proxy.example.com",
+		CertificateAuthority: defaultCASpec(),
+	}
+
+	t.Run("stages the next CA when the current one is within the overlap period", func(t *testing.T) {
+		secret, ca := newCASecret(t, 60*24*time.Hour, nil)
+		env := newTestEnv(t, spec, "", secret)
+
+		require.NoError(t, env.sync(t))
+		updated := env.secret(t)
+		require.Equal(t, ca.Bundle(), updated.Data["ca.crt"], "the current CA should be kept")
+		require.NotEmpty(t, updated.Data["next-ca.crt"])
+		require.NotEmpty(t, updated.Data["next-ca.key"])
+
+		// Both CAs are published, and the proxy keeps serving with the current one.
+		published, err := base64.StdEncoding.DecodeString(env.strategy(t).ImpersonationProxyInfo.CertificateAuthorityData)
+		require.NoError(t, err)
+		require.Equal(t, append(append([]byte{}, ca.Bundle()...), updated.Data["next-ca.crt"]...), published)
+		require.Equal(t, "hello from the proxy", getThroughProxy(t, *env.listenAddr, "proxy.example.com", ca.Bundle()))
+	})
+
+	t.Run("replaces the current CA with the next one when it is about to expire", func(t *testing.T) {
+		next, err := certauthority.New(pkix.Name{CommonName: "next CA"}, 365*24*time.Hour)
+		require.NoError(t, err)
+		secret, _ := newCASecret(t, 20*24*time.Hour, next)
+		env := newTestEnv(t, spec, "", secret)
+
+		require.NoError(t, env.sync(t))
+		updated := env.secret(t)
+		require.Equal(t, next.Bundle(), updated.Data["ca.crt"], "the next CA should be promoted")
+		require.NotContains(t, updated.Data, "next-ca.crt")
+		require.Equal(t, base64.StdEncoding.EncodeToString(next.Bundle()), env.strategy(t).ImpersonationProxyInfo.CertificateAuthorityData)
+		require.Equal(t, "hello from the proxy", getThroughProxy(t, *env.listenAddr, "proxy.example.com", next.Bundle()))
+	})
+
+	t.Run("creates a new CA when the current one is about to expire and none was staged", func(t *testing.T) {
+		secret, ca := newCASecret(t, 20*24*time.Hour, nil)
+		env := newTestEnv(t, spec, "", secret)
+
+		require.NoError(t, env.sync(t))
+		updated := env.secret(t)
+		require.NotEqual(t, ca.Bundle(), updated.Data["ca.crt"])
+		require.WithinDuration(t, time.Now().Add(365*24*time.Hour), parseCertificate(t, updated.Data["ca.crt"]).NotAfter, time.Minute)
+		require.Equal(t, "hello from the proxy", getThroughProxy(t, *env.listenAddr, "proxy.example.com", updated.Data["ca.crt"]))
+	})
+
+	t.Run("rotates on the configured schedule", func(t *testing.T) {
+		customSpec := spec
+		customSpec.CertificateAuthority = caSpec(10*24*time.Hour, 24*time.Hour, 2*24*time.Hour)
+
+		// Outside of the overlap period, nothing changes.
+		secret, ca := newCASecret(t, 4*24*time.Hour, nil)
+		env := newTestEnv(t, customSpec, "", secret)
+		require.NoError(t, env.sync(t))
+		require.Equal(t, base64.StdEncoding.EncodeToString(ca.Bundle()), env.strategy(t).ImpersonationProxyInfo.CertificateAuthorityData)
+
+		// Within the overlap period, the next CA is staged with the configured lifetime.
+		secret, ca = newCASecret(t, 2*24*time.Hour, nil)
+		env = newTestEnv(t, customSpec, "", secret)
+		require.NoError(t, env.sync(t))
+		updated := env.secret(t)
+		require.Equal(t, ca.Bundle(), updated.Data["ca.crt"])
+		require.WithinDuration(t, time.Now().Add(10*24*time.Hour), parseCertificate(t, updated.Data["next-ca.crt"]).NotAfter, time.Minute)
+	})
+}
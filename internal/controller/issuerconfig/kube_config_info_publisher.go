@@ -43,6 +43,7 @@ func NewKubeConfigInfoPublisherController(
 	pinnipedClient pinnipedclientset.Interface,
 	configMapInformer corev1informers.ConfigMapInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -55,11 +56,13 @@ func NewKubeConfigInfoPublisherController(
 				configMapInformer:            configMapInformer,
 			},
 		},
-		withInformer(
-			configMapInformer,
-			pinnipedcontroller.NameAndNamespaceExactMatchFilterFactory(clusterInfoName, ClusterInfoNamespace),
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			withInformer(
+				configMapInformer,
+				pinnipedcontroller.NameAndNamespaceExactMatchFilterFactory(clusterInfoName, ClusterInfoNamespace),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
@@ -0,0 +1,82 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package issuerconfig
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	"go.pinniped.dev/internal/controller/conditionsutil"
+)
+
+// noWorkingStrategyReason is the reason of the Ready condition while none of the strategies work.
+const noWorkingStrategyReason = "NoWorkingStrategy"
+
+// SetStrategy replaces the strategy of the same type in the status, or appends it when there is none yet. Each
+// strategy is reported by a different controller, so none of them may overwrite the whole list. It also updates
+// the conditions which summarize the strategies.
+func SetStrategy(status *configv1alpha1.CredentialIssuerStatus, strategy configv1alpha1.CredentialIssuerStrategy) {
+	if existing := FindStrategy(status, strategy.Type); existing != nil {
+		*existing = strategy
+	} else {
+		status.Strategies = append(status.Strategies, strategy)
+	}
+	setConditions(status)
+}
+
+// FindStrategy returns the strategy of the given type in the status, or nil when there is none.
+func FindStrategy(status *configv1alpha1.CredentialIssuerStatus, strategyType configv1alpha1.StrategyType) *configv1alpha1.CredentialIssuerStrategy {
+	for i := range status.Strategies {
+		if status.Strategies[i].Type == strategyType {
+			return &status.Strategies[i]
+		}
+	}
+	return nil
+}
+
+// setConditions derives a condition from each strategy, and the Ready condition from all of them. The time at which
+// a strategy was last checked is the transition time of its condition when its status changed, so that the status
+// does not change when nothing else did.
+func setConditions(status *configv1alpha1.CredentialIssuerStatus) {
+	conditions := conditionsutil.FromConciergeConfigV1alpha1(status.Conditions)
+
+	var lastUpdate metav1.Time
+	var working, broken []string
+	for _, strategy := range status.Strategies {
+		condition := metav1.Condition{
+			Type:    string(strategy.Type),
+			Status:  metav1.ConditionFalse,
+			Reason:  string(strategy.Reason),
+			Message: strategy.Message,
+		}
+		if strategy.Status == configv1alpha1.SuccessStrategyStatus {
+			condition.Status = metav1.ConditionTrue
+			working = append(working, string(strategy.Type))
+		} else {
+			broken = append(broken, string(strategy.Type))
+		}
+		conditionsutil.Merge(&conditions, 0, strategy.LastUpdateTime, condition)
+		if lastUpdate.Before(&strategy.LastUpdateTime) {
+			lastUpdate = strategy.LastUpdateTime
+		}
+	}
+
+	ready := metav1.Condition{
+		Type:    configv1alpha1.ConditionTypeReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Success",
+		Message: fmt.Sprintf("working strategies: %s", strings.Join(working, ", ")),
+	}
+	if len(working) == 0 {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = noWorkingStrategyReason
+		ready.Message = fmt.Sprintf("none of the strategies are working: %s", strings.Join(broken, ", "))
+	}
+	conditionsutil.Merge(&conditions, 0, lastUpdate, ready)
+
+	status.Conditions = conditionsutil.ToConciergeConfigV1alpha1(conditions)
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package issuerconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+)
+
+func TestSetStrategy(t *testing.T) {
+	status := configv1alpha1.CredentialIssuerStatus{}
+	require.Nil(t, FindStrategy(&status, configv1alpha1.KubeClusterSigningCertificateStrategyType))
+
+	SetStrategy(&status, configv1alpha1.CredentialIssuerStrategy{
+		Type:   configv1alpha1.KubeClusterSigningCertificateStrategyType,
+		Status: configv1alpha1.ErrorStrategyStatus,
+	})
+	SetStrategy(&status, configv1alpha1.CredentialIssuerStrategy{
+		Type:   configv1alpha1.ImpersonationProxyStrategyType,
+		Status: configv1alpha1.SuccessStrategyStatus,
+	})
+	SetStrategy(&status, configv1alpha1.CredentialIssuerStrategy{
+		Type:   configv1alpha1.KubeClusterSigningCertificateStrategyType,
+		Status: configv1alpha1.SuccessStrategyStatus,
+	})
+
+	require.Equal(t, []configv1alpha1.CredentialIssuerStrategy{
+		{Type: configv1alpha1.KubeClusterSigningCertificateStrategyType, Status: configv1alpha1.SuccessStrategyStatus},
+		{Type: configv1alpha1.ImpersonationProxyStrategyType, Status: configv1alpha1.SuccessStrategyStatus},
+	}, status.Strategies)
+	require.Equal(t, &status.Strategies[1], FindStrategy(&status, configv1alpha1.ImpersonationProxyStrategyType))
+}
+
+func TestSetStrategyConditions(t *testing.T) {
+	earlier := metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	later := metav1.NewTime(earlier.Add(time.Minute))
+
+	status := configv1alpha1.CredentialIssuerStatus{}
+	SetStrategy(&status, configv1alpha1.CredentialIssuerStrategy{
+		Type:           configv1alpha1.KubeClusterSigningCertificateStrategyType,
+		Status:         configv1alpha1.ErrorStrategyStatus,
+		Reason:         configv1alpha1.CouldNotFetchKeyStrategyReason,
+		Message:        "some error",
+		LastUpdateTime: earlier,
+	})
+	SetStrategy(&status, configv1alpha1.CredentialIssuerStrategy{
+		Type:           configv1alpha1.ImpersonationProxyStrategyType,
+		Status:         configv1alpha1.ErrorStrategyStatus,
+		Reason:         configv1alpha1.DisabledStrategyReason,
+		Message:        "the impersonation proxy is disabled",
+		LastUpdateTime: earlier,
+	})
+	require.Equal(t, []configv1alpha1.Condition{
+		{Type: "ImpersonationProxy", Status: configv1alpha1.ConditionFalse, LastTransitionTime: earlier, Reason: "Disabled", Message: "the impersonation proxy is disabled"},
+		{Type: "KubeClusterSigningCertificate", Status: configv1alpha1.ConditionFalse, LastTransitionTime: earlier, Reason: "CouldNotFetchKey", Message: "some error"},
+		{Type: "Ready", Status: configv1alpha1.ConditionFalse, LastTransitionTime: earlier, Reason: "NoWorkingStrategy", Message: "none of the strategies are working: KubeClusterSigningCertificate, ImpersonationProxy"},
+	}, status.Conditions)
+
+	// A strategy which starts working makes the CredentialIssuer ready. The conditions which did not change their
+	// status keep their transition time, even though their strategy was checked again.
+	SetStrategy(&status, configv1alpha1.CredentialIssuerStrategy{
+		Type:           configv1alpha1.KubeClusterSigningCertificateStrategyType,
+		Status:         configv1alpha1.SuccessStrategyStatus,
+		Reason:         configv1alpha1.FetchedKeyStrategyReason,
+		Message:        "key was fetched successfully",
+		LastUpdateTime: later,
+	})
+	SetStrategy(&status, configv1alpha1.CredentialIssuerStrategy{
+		Type:           configv1alpha1.ImpersonationProxyStrategyType,
+		Status:         configv1alpha1.ErrorStrategyStatus,
+		Reason:         configv1alpha1.DisabledStrategyReason,
+		Message:        "the impersonation proxy is disabled",
+		LastUpdateTime: later,
+	})
+	require.Equal(t, []configv1alpha1.Condition{
+		{Type: "ImpersonationProxy", Status: configv1alpha1.ConditionFalse, LastTransitionTime: earlier, Reason: "Disabled", Message: "the impersonation proxy is disabled"},
+		{Type: "KubeClusterSigningCertificate", Status: configv1alpha1.ConditionTrue, LastTransitionTime: later, Reason: "FetchedKey", Message: "key was fetched successfully"},
+		{Type: "Ready", Status: configv1alpha1.ConditionTrue, LastTransitionTime: later, Reason: "Success", Message: "working strategies: KubeClusterSigningCertificate"},
+	}, status.Conditions)
+}
@@ -56,6 +56,7 @@ func NewAnnotaterController(
 	kubeSystemPodInformer corev1informers.PodInformer,
 	agentPodInformer corev1informers.PodInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -70,16 +71,18 @@ func NewAnnotaterController(
 				agentPodInformer:               agentPodInformer,
 			},
 		},
-		withInformer(
-			kubeSystemPodInformer,
-			pinnipedcontroller.SimpleFilterWithSingletonQueue(isControllerManagerPod),
-			controllerlib.InformerOption{},
-		),
-		withInformer(
-			agentPodInformer,
-			pinnipedcontroller.SimpleFilterWithSingletonQueue(isAgentPod),
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			withInformer(
+				kubeSystemPodInformer,
+				pinnipedcontroller.SimpleFilterWithSingletonQueue(isControllerManagerPod),
+				controllerlib.InformerOption{},
+			),
+			withInformer(
+				agentPodInformer,
+				pinnipedcontroller.SimpleFilterWithSingletonQueue(isAgentPod),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
@@ -261,6 +261,7 @@ func TestAnnotaterControllerSync(t *testing.T) {
 									LastUpdateTime: metav1.NewTime(frozenNow),
 								},
 							}
+							expectedCredentialIssuer.Status.Conditions = signingCertificateConditions(expectedCredentialIssuer.Status.Strategies[0])
 							expectedGetAction := coretesting.NewRootGetAction(
 								credentialIssuerGVR,
 								credentialIssuerResourceName,
@@ -329,6 +330,7 @@ func TestAnnotaterControllerSync(t *testing.T) {
 									},
 								},
 							}
+							expectedCredentialIssuer.Status.Conditions = signingCertificateConditions(expectedCredentialIssuer.Status.Strategies[0])
 							expectedGetAction := coretesting.NewRootGetAction(
 								credentialIssuerGVR,
 								credentialIssuerResourceName,
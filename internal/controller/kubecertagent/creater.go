@@ -48,6 +48,7 @@ func NewCreaterController(
 	agentPodInformer corev1informers.PodInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
 	withInitialEvent pinnipedcontroller.WithInitialEventOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -64,26 +65,28 @@ func NewCreaterController(
 				agentPodInformer:               agentPodInformer,
 			},
 		},
-		withInformer(
-			kubeSystemPodInformer,
-			pinnipedcontroller.SimpleFilterWithSingletonQueue(isControllerManagerPod),
-			controllerlib.InformerOption{},
-		),
-		withInformer(
-			agentPodInformer,
-			pinnipedcontroller.SimpleFilterWithSingletonQueue(isAgentPod),
-			controllerlib.InformerOption{},
-		),
-		// Be sure to run once even to make sure the CI is updated if there are no controller manager
-		// pods. We should be able to pass an empty key since we don't use the key in the sync (we sync
-		// the world).
-		withInitialEvent(controllerlib.Key{}),
+		append([]controllerlib.Option{
+			withInformer(
+				kubeSystemPodInformer,
+				pinnipedcontroller.SimpleFilterWithSingletonQueue(isControllerManagerPod),
+				controllerlib.InformerOption{},
+			),
+			withInformer(
+				agentPodInformer,
+				pinnipedcontroller.SimpleFilterWithSingletonQueue(isAgentPod),
+				controllerlib.InformerOption{},
+			),
+			// Be sure to run once even to make sure the CI is updated if there are no controller manager
+			// pods. We should be able to pass an empty key since we don't use the key in the sync (we sync
+			// the world).
+			withInitialEvent(controllerlib.Key{}),
+		}, opts...)...,
 	)
 }
 
 // Sync implements controllerlib.Syncer.
 func (c *createrController) Sync(ctx controllerlib.Context) error {
-	controllerManagerSelector, err := labels.Parse("component=kube-controller-manager")
+	controllerManagerSelector, err := labels.Parse(ControllerManagerLabelSelector)
 	if err != nil {
 		return fmt.Errorf("cannot create controller manager selector: %w", err)
 	}
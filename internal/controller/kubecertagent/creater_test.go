@@ -332,6 +332,7 @@ func TestCreaterControllerSync(t *testing.T) {
 									LastUpdateTime: metav1.NewTime(frozenNow),
 								},
 							}
+							expectedCredentialIssuer.Status.Conditions = signingCertificateConditions(expectedCredentialIssuer.Status.Strategies[0])
 							expectedGetAction := coretesting.NewRootGetAction(
 								credentialIssuerGVR,
 								credentialIssuerResourceName,
@@ -408,6 +409,7 @@ func TestCreaterControllerSync(t *testing.T) {
 									},
 								},
 							}
+							expectedCredentialIssuer.Status.Conditions = signingCertificateConditions(expectedCredentialIssuer.Status.Strategies[0])
 							expectedGetAction := coretesting.NewRootGetAction(
 								credentialIssuerGVR,
 								credentialIssuerResourceName,
@@ -472,6 +474,7 @@ func TestCreaterControllerSync(t *testing.T) {
 							LastUpdateTime: metav1.NewTime(frozenNow),
 						},
 					}
+					expectedCredentialIssuer.Status.Conditions = signingCertificateConditions(expectedCredentialIssuer.Status.Strategies[0])
 					expectedGetAction := coretesting.NewRootGetAction(
 						credentialIssuerGVR,
 						credentialIssuerResourceName,
@@ -565,6 +568,7 @@ func TestCreaterControllerSync(t *testing.T) {
 							},
 						},
 					}
+					expectedCredentialIssuer.Status.Conditions = signingCertificateConditions(expectedCredentialIssuer.Status.Strategies[0])
 					expectedGetAction := coretesting.NewRootGetAction(
 						credentialIssuerGVR,
 						credentialIssuerResourceName,
@@ -0,0 +1,247 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubecertagent
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	pinnipedclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
+	"go.pinniped.dev/internal/certauthority/dynamiccertauthority"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/issuerconfig"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// csrGenerateName is the name prefix of the CertificateSigningRequests created by the CSRIssuer.
+	csrGenerateName = "pinniped-client-"
+
+	// csrPollInterval and csrTimeout bound how long the CSRIssuer waits for the cluster signer, which holds up the
+	// TokenCredentialRequest of the user.
+	csrPollInterval = 100 * time.Millisecond
+	csrTimeout      = 10 * time.Second
+
+	// csrLifetimeTolerance is how much longer than requested a signed certificate may be valid for, to allow for the
+	// time spent waiting for the signer and for clock skew between the Concierge and the signer.
+	csrLifetimeTolerance = time.Minute
+
+	// csrProbeInterval is how often the prober controller checks that the cluster still signs certificates.
+	csrProbeInterval = 10 * time.Minute
+	// csrProbeCommonName is the subject of the certificates issued by the prober controller, which are never used.
+	csrProbeCommonName = "pinniped-concierge-csr-probe"
+	// csrProbeTTL is the TTL of the client certificates issued for TokenCredentialRequests, so that the prober notices
+	// when the cluster signer issues longer-lived certificates.
+	csrProbeTTL = 5 * time.Minute
+)
+
+// CSRIssuer issues client certificates through the CertificateSigningRequest API of the cluster, using the
+// kubernetes.io/kube-apiserver-client signer. It creates a CSR for a new private key, approves it, waits for the
+// cluster to sign it, and deletes it again. Unlike the kube cert agent pods, it does not need access to the signing
+// key of the cluster, but the Concierge needs permission to approve CSRs for that signer, and the cluster needs to
+// run the signer, e.g. kube-controller-manager with its cluster signing flags.
+type CSRIssuer struct {
+	client       kubernetes.Interface
+	pollInterval time.Duration
+	timeout      time.Duration
+}
+
+// NewCSRIssuer returns a CSRIssuer which uses the given client.
+func NewCSRIssuer(client kubernetes.Interface) *CSRIssuer {
+	return &CSRIssuer{client: client, pollInterval: csrPollInterval, timeout: csrTimeout}
+}
+
+// IssueClientCertPEM issues a client certificate for the given identity, with the given URIs as subject alternative
+// names, returning it as a pair of PEM-formatted byte slices for the certificate and private key.
+//
+// The certificates.k8s.io/v1 API does not let the requester choose the validity period, so the certificate is valid
+// for as long as the cluster signer decides, e.g. the --cluster-signing-duration of kube-controller-manager. Signed
+// certificates which are valid for more than a minute longer than the ttl are discarded, so operators who use this
+// mode must configure a duration for that signer which is no longer than the TTL of Pinniped's client certificates.
+//
+// When the cluster does not sign the request in time, or signs it for too long, the returned error wraps
+// dynamiccertauthority.ErrNoSigningKey, because that means that this strategy is not working.
+func (c *CSRIssuer) IssueClientCertPEM(subject pkix.Name, uris []*url.URL, ttl time.Duration) ([]byte, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not generate private key: %w", err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: subject, URIs: uris}, privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create certificate request: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not marshal private key: %w", err)
+	}
+
+	csrs := c.client.CertificatesV1().CertificateSigningRequests()
+	csr, err := csrs.Create(ctx, &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: csrGenerateName},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}),
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageClientAuth,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create CertificateSigningRequest: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so that the CSR is cleaned up even when waiting for it timed out.
+		if err := csrs.Delete(context.Background(), csr.Name, metav1.DeleteOptions{}); err != nil {
+			plog.Warning("could not delete CertificateSigningRequest", "name", csr.Name, "error", err.Error())
+		}
+	}()
+
+	csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+		Type:           certificatesv1.CertificateApproved,
+		Status:         corev1.ConditionTrue,
+		Reason:         "PinnipedConciergeApproved",
+		Message:        "approved by the Pinniped Concierge for a TokenCredentialRequest",
+		LastUpdateTime: metav1.Now(),
+	})
+	if _, err := csrs.UpdateApproval(ctx, csr.Name, csr, metav1.UpdateOptions{}); err != nil {
+		return nil, nil, fmt.Errorf("could not approve CertificateSigningRequest: %w", err)
+	}
+
+	var certPEM []byte
+	err = wait.PollImmediateUntil(c.pollInterval, func() (bool, error) {
+		current, err := csrs.Get(ctx, csr.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("could not get CertificateSigningRequest: %w", err)
+		}
+		for _, condition := range current.Status.Conditions {
+			if condition.Type == certificatesv1.CertificateDenied || condition.Type == certificatesv1.CertificateFailed {
+				return false, fmt.Errorf("CertificateSigningRequest was not signed: %s: %s", condition.Reason, condition.Message)
+			}
+		}
+		certPEM = current.Status.Certificate
+		return len(certPEM) > 0, nil
+	}, ctx.Done())
+	if err == wait.ErrWaitTimeout {
+		return nil, nil, fmt.Errorf("%w: the cluster did not sign the CertificateSigningRequest within %s", dynamiccertauthority.ErrNoSigningKey, c.timeout)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, nil, fmt.Errorf("could not decode the signed certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse the signed certificate: %w", err)
+	}
+	if maxNotAfter := time.Now().Add(ttl + csrLifetimeTolerance); cert.NotAfter.After(maxNotAfter) {
+		return nil, nil, fmt.Errorf("%w: the cluster signed a certificate which is valid until %s, which is longer than the requested %s",
+			dynamiccertauthority.ErrNoSigningKey, cert.NotAfter.UTC().Format(time.RFC3339), ttl)
+	}
+
+	return certPEM, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+type csrProberController struct {
+	credentialIssuerLocationConfig *CredentialIssuerLocationConfig
+	credentialIssuerLabels         map[string]string
+	issuer                         *CSRIssuer
+	clock                          clock.Clock
+	pinnipedAPIClient              pinnipedclientset.Interface
+}
+
+// NewCSRProberController returns a controllerlib.Controller that regularly has a certificate signed by the issuer,
+// and reports whether that worked as the KubeClusterSigningCertificate strategy of the CredentialIssuer. It is used
+// instead of the kube cert agent controllers when the Concierge is configured to use the CSR API.
+func NewCSRProberController(
+	credentialIssuerLocationConfig *CredentialIssuerLocationConfig,
+	credentialIssuerLabels map[string]string,
+	issuer *CSRIssuer,
+	clock clock.Clock,
+	pinnipedAPIClient pinnipedclientset.Interface,
+	withInitialEvent pinnipedcontroller.WithInitialEventOptionFunc,
+	opts ...controllerlib.Option,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "kube-cert-agent-csr-prober-controller",
+			Syncer: &csrProberController{
+				credentialIssuerLocationConfig: credentialIssuerLocationConfig,
+				credentialIssuerLabels:         credentialIssuerLabels,
+				issuer:                         issuer,
+				clock:                          clock,
+				pinnipedAPIClient:              pinnipedAPIClient,
+			},
+		},
+		append([]controllerlib.Option{
+			withInitialEvent(controllerlib.Key{Name: credentialIssuerLocationConfig.Name}),
+		}, opts...)...,
+	)
+}
+
+func (c *csrProberController) Sync(ctx controllerlib.Context) error {
+	_, _, probeErr := c.issuer.IssueClientCertPEM(pkix.Name{CommonName: csrProbeCommonName}, nil, csrProbeTTL)
+	if probeErr != nil {
+		plog.Warning("could not have a certificate signed through the CertificateSigningRequest API", "error", probeErr.Error())
+	}
+
+	err := issuerconfig.CreateOrUpdateCredentialIssuerStatus(
+		ctx.Context,
+		c.credentialIssuerLocationConfig.Name,
+		c.credentialIssuerLabels,
+		c.pinnipedAPIClient,
+		func(configToUpdate *configv1alpha1.CredentialIssuerStatus) {
+			issuerconfig.SetStrategy(configToUpdate, csrStrategy(c.clock, probeErr))
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not create or update CredentialIssuer: %w", err)
+	}
+
+	ctx.Queue.AddAfter(ctx.Key, csrProbeInterval)
+	return nil
+}
+
+func csrStrategy(clock clock.Clock, err error) configv1alpha1.CredentialIssuerStrategy {
+	if err != nil {
+		return configv1alpha1.CredentialIssuerStrategy{
+			Type:           configv1alpha1.KubeClusterSigningCertificateStrategyType,
+			Status:         configv1alpha1.ErrorStrategyStatus,
+			Reason:         configv1alpha1.CouldNotSignCertificateSigningRequestStrategyReason,
+			Message:        err.Error(),
+			LastUpdateTime: metav1.NewTime(clock.Now()),
+		}
+	}
+	return configv1alpha1.CredentialIssuerStrategy{
+		Type:           configv1alpha1.KubeClusterSigningCertificateStrategyType,
+		Status:         configv1alpha1.SuccessStrategyStatus,
+		Reason:         configv1alpha1.SignedCertificateSigningRequestStrategyReason,
+		Message:        "The cluster signed a CertificateSigningRequest",
+		LastUpdateTime: metav1.NewTime(clock.Now()),
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubecertagent
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	coretesting "k8s.io/client-go/testing"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	"go.pinniped.dev/internal/certauthority/dynamiccertauthority"
+	"go.pinniped.dev/internal/controllerlib"
+)
+
+// recordingQueue is a controllerlib.Queue which only records the keys that were added to it.
+type recordingQueue struct {
+	addedAfter map[controllerlib.Key]time.Duration
+}
+
+func (q *recordingQueue) Add(controllerlib.Key)            {}
+func (q *recordingQueue) AddRateLimited(controllerlib.Key) {}
+func (q *recordingQueue) AddAfter(key controllerlib.Key, duration time.Duration) {
+	q.addedAfter[key] = duration
+}
+
+// fakeCSRClient returns a fake clientset which names created CSRs, and which calls sign when a CSR is approved,
+// like kube-controller-manager would.
+func fakeCSRClient(t *testing.T, sign func(csr *certificatesv1.CertificateSigningRequest)) *kubernetesfake.Clientset {
+	t.Helper()
+	client := kubernetesfake.NewSimpleClientset()
+	client.PrependReactor("create", "certificatesigningrequests", func(action coretesting.Action) (bool, runtime.Object, error) {
+		csr := action.(coretesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+		csr.Name = csr.GenerateName + "abc123"
+		return false, nil, nil
+	})
+	client.PrependReactor("update", "certificatesigningrequests", func(action coretesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() == "approval" {
+			sign(action.(coretesting.UpdateAction).GetObject().(*certificatesv1.CertificateSigningRequest))
+		}
+		return false, nil, nil
+	})
+	return client
+}
+
+// testSigner returns a CA and a func which signs CSRs with it for the given duration, like the cluster signer would.
+func testSigner(t *testing.T, duration time.Duration) (*x509.Certificate, func(csr *certificatesv1.CertificateSigningRequest)) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-cluster-ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	return ca, func(csr *certificatesv1.CertificateSigningRequest) {
+		block, _ := pem.Decode(csr.Spec.Request)
+		require.NotNil(t, block)
+		request, err := x509.ParseCertificateRequest(block.Bytes)
+		require.NoError(t, err)
+		require.Equal(t, certificatesv1.KubeAPIServerClientSignerName, csr.Spec.SignerName)
+		require.Equal(t, certificatesv1.CertificateApproved, csr.Status.Conditions[0].Type)
+
+		certDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      request.Subject,
+			URIs:         request.URIs,
+			NotBefore:    time.Now().Add(-time.Minute),
+			NotAfter:     time.Now().Add(duration),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, ca, request.PublicKey, caKey)
+		require.NoError(t, err)
+		csr.Status.Certificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	}
+}
+
+func requireCSRDeleted(t *testing.T, client *kubernetesfake.Clientset) {
+	t.Helper()
+	csrs, err := client.CertificatesV1().CertificateSigningRequests().List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, csrs.Items)
+}
+
+func TestCSRIssuer(t *testing.T) {
+	t.Run("signed", func(t *testing.T) {
+		ca, sign := testSigner(t, 5*time.Minute)
+		client := fakeCSRClient(t, sign)
+		issuer := NewCSRIssuer(client)
+
+		uri, err := url.Parse("spiffe://cluster.example.com/user/some-user")
+		require.NoError(t, err)
+		certPEM, keyPEM, err := issuer.IssueClientCertPEM(
+			pkix.Name{CommonName: "some-user", Organization: []string{"group-a", "group-b"}},
+			[]*url.URL{uri},
+			5*time.Minute,
+		)
+		require.NoError(t, err)
+
+		// The private key matches the signed certificate.
+		keyPair, err := tls.X509KeyPair(certPEM, keyPEM)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+		require.NoError(t, err)
+		require.NoError(t, cert.CheckSignatureFrom(ca))
+		require.Equal(t, "some-user", cert.Subject.CommonName)
+		require.Equal(t, []string{"group-a", "group-b"}, cert.Subject.Organization)
+		require.Equal(t, []*url.URL{uri}, cert.URIs)
+
+		requireCSRDeleted(t, client)
+	})
+
+	t.Run("signed for longer than the ttl", func(t *testing.T) {
+		_, sign := testSigner(t, time.Hour)
+		client := fakeCSRClient(t, sign)
+		issuer := NewCSRIssuer(client)
+
+		_, _, err := issuer.IssueClientCertPEM(pkix.Name{CommonName: "some-user"}, nil, 5*time.Minute)
+		require.Error(t, err)
+		require.Regexp(t, `^no signing key is available: the cluster signed a certificate which is valid until .+, which is longer than the requested 5m0s$`, err.Error())
+		require.True(t, errors.Is(err, dynamiccertauthority.ErrNoSigningKey))
+		requireCSRDeleted(t, client)
+	})
+
+	t.Run("signed for less than the ttl", func(t *testing.T) {
+		_, sign := testSigner(t, time.Minute)
+		issuer := NewCSRIssuer(fakeCSRClient(t, sign))
+
+		_, _, err := issuer.IssueClientCertPEM(pkix.Name{CommonName: "some-user"}, nil, 5*time.Minute)
+		require.NoError(t, err)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		client := fakeCSRClient(t, func(csr *certificatesv1.CertificateSigningRequest) {
+			csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+				Type:    certificatesv1.CertificateDenied,
+				Status:  corev1.ConditionTrue,
+				Reason:  "SomeReason",
+				Message: "some message",
+			})
+		})
+		issuer := NewCSRIssuer(client)
+
+		_, _, err := issuer.IssueClientCertPEM(pkix.Name{CommonName: "some-user"}, nil, 5*time.Minute)
+		require.EqualError(t, err, "CertificateSigningRequest was not signed: SomeReason: some message")
+		require.False(t, errors.Is(err, dynamiccertauthority.ErrNoSigningKey))
+		requireCSRDeleted(t, client)
+	})
+
+	t.Run("not signed in time", func(t *testing.T) {
+		client := fakeCSRClient(t, func(*certificatesv1.CertificateSigningRequest) {})
+		issuer := &CSRIssuer{client: client, pollInterval: 10 * time.Millisecond, timeout: 50 * time.Millisecond}
+
+		_, _, err := issuer.IssueClientCertPEM(pkix.Name{CommonName: "some-user"}, nil, 5*time.Minute)
+		require.EqualError(t, err, "no signing key is available: the cluster did not sign the CertificateSigningRequest within 50ms")
+		require.True(t, errors.Is(err, dynamiccertauthority.ErrNoSigningKey))
+		requireCSRDeleted(t, client)
+	})
+}
+
+func TestCSRProberControllerSync(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		sign         func(t *testing.T) func(csr *certificatesv1.CertificateSigningRequest)
+		wantStrategy configv1alpha1.CredentialIssuerStrategy
+		// wantMessage matches the message of the strategy when it contains a timestamp.
+		wantMessage string
+	}{
+		{
+			name: "the cluster signs the probe",
+			sign: func(t *testing.T) func(csr *certificatesv1.CertificateSigningRequest) {
+				_, sign := testSigner(t, csrProbeTTL)
+				return sign
+			},
+			wantStrategy: configv1alpha1.CredentialIssuerStrategy{
+				Type:           configv1alpha1.KubeClusterSigningCertificateStrategyType,
+				Status:         configv1alpha1.SuccessStrategyStatus,
+				Reason:         configv1alpha1.SignedCertificateSigningRequestStrategyReason,
+				Message:        "The cluster signed a CertificateSigningRequest",
+				LastUpdateTime: metav1.NewTime(now),
+			},
+		},
+		{
+			name: "the cluster signs the probe for too long",
+			sign: func(t *testing.T) func(csr *certificatesv1.CertificateSigningRequest) {
+				_, sign := testSigner(t, 365*24*time.Hour)
+				return sign
+			},
+			wantStrategy: configv1alpha1.CredentialIssuerStrategy{
+				Type:           configv1alpha1.KubeClusterSigningCertificateStrategyType,
+				Status:         configv1alpha1.ErrorStrategyStatus,
+				Reason:         configv1alpha1.CouldNotSignCertificateSigningRequestStrategyReason,
+				LastUpdateTime: metav1.NewTime(now),
+			},
+			wantMessage: `^no signing key is available: the cluster signed a certificate which is valid until .+, which is longer than the requested 5m0s$`,
+		},
+		{
+			name: "the cluster denies the probe",
+			sign: func(t *testing.T) func(csr *certificatesv1.CertificateSigningRequest) {
+				return func(csr *certificatesv1.CertificateSigningRequest) {
+					csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+						Type:    certificatesv1.CertificateFailed,
+						Status:  corev1.ConditionTrue,
+						Reason:  "SignerValidationFailure",
+						Message: "no signer for kubernetes.io/kube-apiserver-client",
+					})
+				}
+			},
+			wantStrategy: configv1alpha1.CredentialIssuerStrategy{
+				Type:           configv1alpha1.KubeClusterSigningCertificateStrategyType,
+				Status:         configv1alpha1.ErrorStrategyStatus,
+				Reason:         configv1alpha1.CouldNotSignCertificateSigningRequestStrategyReason,
+				Message:        "CertificateSigningRequest was not signed: SignerValidationFailure: no signer for kubernetes.io/kube-apiserver-client",
+				LastUpdateTime: metav1.NewTime(now),
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			pinnipedAPIClient := pinnipedfake.NewSimpleClientset()
+			controller := NewCSRProberController(
+				&CredentialIssuerLocationConfig{Name: "some-credential-issuer"},
+				map[string]string{"some-label": "some-value"},
+				NewCSRIssuer(fakeCSRClient(t, tt.sign(t))),
+				clock.NewFakeClock(now),
+				pinnipedAPIClient,
+				controllerlib.WithInitialEvent,
+			)
+
+			queue := &recordingQueue{addedAfter: map[controllerlib.Key]time.Duration{}}
+			key := controllerlib.Key{Name: "some-credential-issuer"}
+			require.NoError(t, controllerlib.TestSync(t, controller, controllerlib.Context{
+				Context: context.Background(),
+				Key:     key,
+				Queue:   queue,
+			}))
+			require.Equal(t, map[controllerlib.Key]time.Duration{key: csrProbeInterval}, queue.addedAfter)
+
+			credentialIssuer, err := pinnipedAPIClient.ConfigV1alpha1().CredentialIssuers().Get(context.Background(), "some-credential-issuer", metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, map[string]string{"some-label": "some-value"}, credentialIssuer.Labels)
+			if tt.wantMessage != "" {
+				require.Len(t, credentialIssuer.Status.Strategies, 1)
+				require.Regexp(t, tt.wantMessage, credentialIssuer.Status.Strategies[0].Message)
+				credentialIssuer.Status.Strategies[0].Message = ""
+			}
+			require.Equal(t, []configv1alpha1.CredentialIssuerStrategy{tt.wantStrategy}, credentialIssuer.Status.Strategies)
+		})
+	}
+}
@@ -31,6 +31,7 @@ func NewDeleterController(
 	kubeSystemPodInformer corev1informers.PodInformer,
 	agentPodInformer corev1informers.PodInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -42,16 +43,18 @@ func NewDeleterController(
 				agentPodInformer:      agentPodInformer,
 			},
 		},
-		withInformer(
-			kubeSystemPodInformer,
-			pinnipedcontroller.SimpleFilterWithSingletonQueue(isControllerManagerPod),
-			controllerlib.InformerOption{},
-		),
-		withInformer(
-			agentPodInformer,
-			pinnipedcontroller.SimpleFilterWithSingletonQueue(isAgentPod),
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			withInformer(
+				kubeSystemPodInformer,
+				pinnipedcontroller.SimpleFilterWithSingletonQueue(isControllerManagerPod),
+				controllerlib.InformerOption{},
+			),
+			withInformer(
+				agentPodInformer,
+				pinnipedcontroller.SimpleFilterWithSingletonQueue(isAgentPod),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
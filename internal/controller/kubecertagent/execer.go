@@ -41,6 +41,7 @@ func NewExecerController(
 	clock clock.Clock,
 	agentPodInformer corev1informers.PodInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -54,11 +55,13 @@ func NewExecerController(
 				agentPodInformer:               agentPodInformer,
 			},
 		},
-		withInformer(
-			agentPodInformer,
-			pinnipedcontroller.SimpleFilter(isAgentPod, nil), // nil parent func is fine because each event is distinct
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			withInformer(
+				agentPodInformer,
+				pinnipedcontroller.SimpleFilter(isAgentPod, nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
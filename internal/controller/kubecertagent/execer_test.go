@@ -357,6 +357,7 @@ func TestManagerControllerSync(t *testing.T) {
 								LastUpdateTime: metav1.NewTime(frozenNow),
 							},
 						}
+						expectedCredentialIssuer.Status.Conditions = signingCertificateConditions(expectedCredentialIssuer.Status.Strategies[0])
 						expectedGetAction := coretesting.NewRootGetAction(credentialIssuerGVR, credentialIssuerResourceName)
 						expectedCreateAction := coretesting.NewRootUpdateSubresourceAction(credentialIssuerGVR, "status", expectedCredentialIssuer)
 						r.Equal([]coretesting.Action{expectedGetAction, expectedCreateAction}, pinnipedAPIClient.Actions())
@@ -413,6 +414,7 @@ func TestManagerControllerSync(t *testing.T) {
 								},
 							},
 						}
+						expectedCredentialIssuer.Status.Conditions = signingCertificateConditions(expectedCredentialIssuer.Status.Strategies[0])
 						expectedGetAction := coretesting.NewRootGetAction(credentialIssuerGVR, credentialIssuerResourceName)
 						expectedCreateAction := coretesting.NewRootCreateAction(credentialIssuerGVR, expectedCreateCredentialIssuer)
 						expectedUpdateAction := coretesting.NewRootUpdateSubresourceAction(credentialIssuerGVR, "status", expectedCredentialIssuer)
@@ -463,6 +465,7 @@ func TestManagerControllerSync(t *testing.T) {
 							},
 						},
 					}
+					expectedCredentialIssuer.Status.Conditions = signingCertificateConditions(expectedCredentialIssuer.Status.Strategies[0])
 					expectedGetAction := coretesting.NewRootGetAction(credentialIssuerGVR, credentialIssuerResourceName)
 					expectedCreateAction := coretesting.NewRootCreateAction(credentialIssuerGVR, expectedCreateCredentialIssuer)
 					expectedUpdateAction := coretesting.NewRootUpdateSubresourceAction(credentialIssuerGVR, "status", expectedCredentialIssuer)
@@ -512,6 +515,7 @@ func TestManagerControllerSync(t *testing.T) {
 							},
 						},
 					}
+					expectedCredentialIssuer.Status.Conditions = signingCertificateConditions(expectedCredentialIssuer.Status.Strategies[0])
 					expectedGetAction := coretesting.NewRootGetAction(credentialIssuerGVR, credentialIssuerResourceName)
 					expectedCreateAction := coretesting.NewRootCreateAction(credentialIssuerGVR, expectedCreateCredentialIssuer)
 					expectedUpdateAction := coretesting.NewRootUpdateSubresourceAction(credentialIssuerGVR, "status", expectedCredentialIssuer)
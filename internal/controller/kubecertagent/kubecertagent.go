@@ -34,6 +34,9 @@ const (
 	// ControllerManagerNamespace is the assumed namespace of the kube-controller-manager pod(s).
 	ControllerManagerNamespace = "kube-system"
 
+	// ControllerManagerLabelSelector selects the kube-controller-manager pod(s) in ControllerManagerNamespace.
+	ControllerManagerLabelSelector = "component=kube-controller-manager"
+
 	// controllerManagerNameAnnotationKey is used to store an agent pod's parent's name, i.e., the
 	// name of the controller manager pod with which it is supposed to be in sync.
 	controllerManagerNameAnnotationKey = "kube-cert-agent.pinniped.dev/controller-manager-name"
@@ -299,9 +302,7 @@ func createOrUpdateCredentialIssuer(ctx context.Context,
 			} else {
 				strategyResult = strategyError(clock, err)
 			}
-			configToUpdate.Strategies = []configv1alpha1.CredentialIssuerStrategy{
-				strategyResult,
-			}
+			issuerconfig.SetStrategy(configToUpdate, strategyResult)
 		},
 	)
 }
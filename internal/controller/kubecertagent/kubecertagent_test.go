@@ -15,6 +15,7 @@ import (
 	kubeinformers "k8s.io/client-go/informers"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
 	"go.pinniped.dev/internal/controllerlib"
 	"go.pinniped.dev/internal/testutil"
 )
@@ -247,3 +248,32 @@ func defineSharedKubecertagentFilterSpecs(
 		})
 	})
 }
+
+// signingCertificateConditions returns the conditions of a CredentialIssuer whose only strategy is the given
+// KubeClusterSigningCertificate strategy.
+func signingCertificateConditions(strategy configv1alpha1.CredentialIssuerStrategy) []configv1alpha1.Condition {
+	ready := configv1alpha1.Condition{
+		Type:               configv1alpha1.ConditionTypeReady,
+		Status:             configv1alpha1.ConditionFalse,
+		LastTransitionTime: strategy.LastUpdateTime,
+		Reason:             "NoWorkingStrategy",
+		Message:            "none of the strategies are working: KubeClusterSigningCertificate",
+	}
+	status := configv1alpha1.ConditionFalse
+	if strategy.Status == configv1alpha1.SuccessStrategyStatus {
+		status = configv1alpha1.ConditionTrue
+		ready.Status = configv1alpha1.ConditionTrue
+		ready.Reason = "Success"
+		ready.Message = "working strategies: KubeClusterSigningCertificate"
+	}
+	return []configv1alpha1.Condition{
+		{
+			Type:               configv1alpha1.ConditionTypeKubeClusterSigningCertificate,
+			Status:             status,
+			LastTransitionTime: strategy.LastUpdateTime,
+			Reason:             string(strategy.Reason),
+			Message:            strategy.Message,
+		},
+		ready,
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package supervisorconfig
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	pinnipedclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
+	configinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions/config/v1alpha1"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	defaultCertManagerIssuerKind  = "Issuer"
+	defaultCertManagerIssuerGroup = "cert-manager.io"
+)
+
+// certManagerCertificateGVR is the cert-manager Certificate resource. Certificates are accessed with a dynamic
+// client so that the Supervisor does not depend on cert-manager's Go packages, and so that it keeps working on
+// clusters where cert-manager is not installed.
+//nolint:gochecknoglobals
+var certManagerCertificateGVR = schema.GroupVersionResource{
+	Group:    "cert-manager.io",
+	Version:  "v1",
+	Resource: "certificates",
+}
+
+type certManagerCertificateController struct {
+	certificateLabels        map[string]string
+	clock                    clock.Clock
+	pinnipedClient           pinnipedclientset.Interface
+	dynamicClient            dynamic.Interface
+	federationDomainInformer configinformers.FederationDomainInformer
+}
+
+// NewCertManagerCertificateController returns a controllerlib.Controller that creates and updates a cert-manager
+// Certificate for each FederationDomain which has a spec.tls.issuerRef, and which reports the progress of that
+// Certificate in the FederationDomain's status.tls.
+func NewCertManagerCertificateController(
+	certificateLabels map[string]string,
+	clock clock.Clock,
+	pinnipedClient pinnipedclientset.Interface,
+	dynamicClient dynamic.Interface,
+	federationDomainInformer configinformers.FederationDomainInformer,
+	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "CertManagerCertificateController",
+			Syncer: &certManagerCertificateController{
+				certificateLabels:        certificateLabels,
+				clock:                    clock,
+				pinnipedClient:           pinnipedClient,
+				dynamicClient:            dynamicClient,
+				federationDomainInformer: federationDomainInformer,
+			},
+		},
+		append([]controllerlib.Option{
+			withInformer(
+				federationDomainInformer,
+				pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
+	)
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *certManagerCertificateController) Sync(ctx controllerlib.Context) error {
+	federationDomain, err := c.federationDomainInformer.Lister().FederationDomains(ctx.Key.Namespace).Get(ctx.Key.Name)
+	notFound := k8serrors.IsNotFound(err)
+	if err != nil && !notFound {
+		return fmt.Errorf(
+			"failed to get %s/%s FederationDomain: %w",
+			ctx.Key.Namespace,
+			ctx.Key.Name,
+			err,
+		)
+	}
+
+	if notFound {
+		// The corresponding Certificate should be garbage collected since it has this FederationDomain as its owner.
+		return nil
+	}
+
+	if federationDomain.Spec.TLS == nil || federationDomain.Spec.TLS.IssuerRef == nil {
+		if federationDomain.Status.TLS == nil {
+			return nil
+		}
+		// The issuerRef was removed, so stop managing the Certificate.
+		if err := c.deleteCertificate(ctx.Context, federationDomain, federationDomain.Status.TLS.CertificateName); err != nil {
+			return err
+		}
+		return c.updateStatus(ctx.Context, federationDomain, nil)
+	}
+
+	tlsStatus, syncErr := c.syncCertificate(ctx.Context, federationDomain)
+	if err := c.updateStatus(ctx.Context, federationDomain, tlsStatus); err != nil {
+		return err
+	}
+	if syncErr != nil {
+		return syncErr
+	}
+	if tlsStatus.Status == configv1alpha1.PendingFederationDomainTLSStatusCondition {
+		// We are not watching Certificates, so poll until cert-manager has finished.
+		return controllerlib.ErrSyntheticRequeue
+	}
+	return nil
+}
+
+// syncCertificate creates or updates the Certificate for the FederationDomain and returns the resulting status.
+// The returned error is only non-nil for errors which are worth retrying.
+func (c *certManagerCertificateController) syncCertificate(
+	ctx context.Context,
+	federationDomain *configv1alpha1.FederationDomain,
+) (*configv1alpha1.FederationDomainTLSStatus, error) {
+	certificateName := federationDomain.Name + "-tls"
+	status := func(condition configv1alpha1.FederationDomainTLSStatusCondition, message string) *configv1alpha1.FederationDomainTLSStatus {
+		return &configv1alpha1.FederationDomainTLSStatus{
+			CertificateName: certificateName,
+			Status:          condition,
+			Message:         message,
+		}
+	}
+
+	desired, err := c.desiredCertificate(federationDomain, certificateName)
+	if err != nil {
+		return status(configv1alpha1.FailedFederationDomainTLSStatusCondition, err.Error()), nil
+	}
+
+	certificates := c.dynamicClient.Resource(certManagerCertificateGVR).Namespace(federationDomain.Namespace)
+	var actual *unstructured.Unstructured
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := certificates.Get(ctx, certificateName, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			actual, err = certificates.Create(ctx, desired, metav1.CreateOptions{})
+			if err != nil {
+				return fmt.Errorf("could not create Certificate: %w", err)
+			}
+			plog.Debug("created Certificate", "certificate", klog.KObj(actual))
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not get Certificate: %w", err)
+		}
+
+		if !metav1.IsControlledBy(existing, federationDomain) {
+			return fmt.Errorf("a Certificate named %q already exists and is not managed by this FederationDomain", certificateName)
+		}
+
+		if equality.Semantic.DeepEqual(existing.Object["spec"], desired.Object["spec"]) {
+			actual = existing
+			return nil
+		}
+		existing.Object["spec"] = desired.Object["spec"]
+		actual, err = certificates.Update(ctx, existing, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("could not update Certificate: %w", err)
+		}
+		plog.Debug("updated Certificate", "certificate", klog.KObj(actual))
+		return nil
+	})
+	if err != nil {
+		return status(configv1alpha1.FailedFederationDomainTLSStatusCondition, err.Error()), err
+	}
+
+	condition, message := certificateReadiness(actual)
+	return status(condition, message), nil
+}
+
+func (c *certManagerCertificateController) desiredCertificate(
+	federationDomain *configv1alpha1.FederationDomain,
+	certificateName string,
+) (*unstructured.Unstructured, error) {
+	tls := federationDomain.Spec.TLS
+	if tls.SecretName == "" {
+		return nil, fmt.Errorf("spec.tls.secretName must be set when spec.tls.issuerRef is set")
+	}
+
+	issuerURL, err := url.Parse(federationDomain.Spec.Issuer)
+	if err != nil || issuerURL.Hostname() == "" {
+		return nil, fmt.Errorf("could not determine hostname of issuer %q", federationDomain.Spec.Issuer)
+	}
+
+	issuerRef := map[string]interface{}{
+		"name":  tls.IssuerRef.Name,
+		"kind":  defaultCertManagerIssuerKind,
+		"group": defaultCertManagerIssuerGroup,
+	}
+	if tls.IssuerRef.Kind != "" {
+		issuerRef["kind"] = tls.IssuerRef.Kind
+	}
+	if tls.IssuerRef.Group != "" {
+		issuerRef["group"] = tls.IssuerRef.Group
+	}
+
+	spec := map[string]interface{}{
+		"secretName": tls.SecretName,
+		"issuerRef":  issuerRef,
+	}
+	// Issuers with IP address hosts cannot use SNI, but a Certificate for them might still be useful as the
+	// default TLS certificate.
+	if net.ParseIP(issuerURL.Hostname()) != nil {
+		spec["ipAddresses"] = []interface{}{issuerURL.Hostname()}
+	} else {
+		spec["dnsNames"] = []interface{}{issuerURL.Hostname()}
+	}
+
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	certificate.SetAPIVersion(certManagerCertificateGVR.GroupVersion().String())
+	certificate.SetKind("Certificate")
+	certificate.SetName(certificateName)
+	certificate.SetNamespace(federationDomain.Namespace)
+	certificate.SetLabels(c.certificateLabels)
+	certificate.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(federationDomain, schema.GroupVersionKind{
+			Group:   configv1alpha1.SchemeGroupVersion.Group,
+			Version: configv1alpha1.SchemeGroupVersion.Version,
+			Kind:    federationDomainKind,
+		}),
+	})
+	return certificate, nil
+}
+
+// certificateReadiness interprets the status conditions which cert-manager sets on a Certificate.
+func certificateReadiness(certificate *unstructured.Unstructured) (configv1alpha1.FederationDomainTLSStatusCondition, string) {
+	conditions, _, _ := unstructured.NestedSlice(certificate.Object, "status", "conditions")
+
+	readyMessage := ""
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditionType, _ := condition["type"].(string)
+		conditionStatus, _ := condition["status"].(string)
+		reason, _ := condition["reason"].(string)
+		message, _ := condition["message"].(string)
+
+		switch {
+		case conditionType == "Ready" && conditionStatus == "True":
+			return configv1alpha1.ReadyFederationDomainTLSStatusCondition, "Certificate is ready"
+		case conditionType == "Ready":
+			readyMessage = message
+		case conditionType == "Issuing" && conditionStatus == "False" && reason == "Failed":
+			return configv1alpha1.FailedFederationDomainTLSStatusCondition, "Certificate issuance failed: " + message
+		}
+	}
+
+	if readyMessage == "" {
+		return configv1alpha1.PendingFederationDomainTLSStatusCondition, "waiting for cert-manager to issue the Certificate"
+	}
+	return configv1alpha1.PendingFederationDomainTLSStatusCondition, "waiting for cert-manager to issue the Certificate: " + readyMessage
+}
+
+func (c *certManagerCertificateController) deleteCertificate(
+	ctx context.Context,
+	federationDomain *configv1alpha1.FederationDomain,
+	certificateName string,
+) error {
+	if certificateName == "" {
+		return nil
+	}
+	certificates := c.dynamicClient.Resource(certManagerCertificateGVR).Namespace(federationDomain.Namespace)
+	certificate, err := certificates.Get(ctx, certificateName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not get Certificate: %w", err)
+	}
+	if !metav1.IsControlledBy(certificate, federationDomain) {
+		return nil
+	}
+	uid := certificate.GetUID()
+	err = certificates.Delete(ctx, certificateName, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &uid},
+	})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("could not delete Certificate: %w", err)
+	}
+	plog.Debug("deleted Certificate", "certificate", klog.KObj(certificate))
+	return nil
+}
+
+func (c *certManagerCertificateController) updateStatus(
+	ctx context.Context,
+	federationDomain *configv1alpha1.FederationDomain,
+	tlsStatus *configv1alpha1.FederationDomainTLSStatus,
+) error {
+	federationDomainClient := c.pinnipedClient.ConfigV1alpha1().FederationDomains(federationDomain.Namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		oldFederationDomain, err := federationDomainClient.Get(ctx, federationDomain.Name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("cannot get FederationDomain: %w", err)
+		}
+
+		oldTLSStatus := oldFederationDomain.Status.TLS
+		if tlsStatus == nil && oldTLSStatus == nil {
+			return nil
+		}
+		if tlsStatus != nil && oldTLSStatus != nil &&
+			tlsStatus.CertificateName == oldTLSStatus.CertificateName &&
+			tlsStatus.Status == oldTLSStatus.Status &&
+			tlsStatus.Message == oldTLSStatus.Message {
+			return nil
+		}
+
+		var newTLSStatus *configv1alpha1.FederationDomainTLSStatus
+		if tlsStatus != nil {
+			newTLSStatus = tlsStatus.DeepCopy()
+			newTLSStatus.LastUpdateTime = timePtr(metav1.NewTime(c.clock.Now()))
+		}
+		plog.Debug("attempting TLS status update", "federationdomain", klog.KObj(federationDomain), "tls", newTLSStatus)
+		oldFederationDomain.Status.TLS = newTLSStatus
+		_, err = federationDomainClient.UpdateStatus(ctx, oldFederationDomain, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -0,0 +1,282 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package supervisorconfig
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	pinnipedinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions"
+	"go.pinniped.dev/internal/controllerlib"
+)
+
+func TestCertManagerCertificateControllerSync(t *testing.T) {
+	t.Parallel()
+
+	const namespace = "some-namespace"
+
+	frozenNow := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	frozenMetaNow := metav1.NewTime(frozenNow)
+
+	newFederationDomain := func(issuer string, tls *configv1alpha1.FederationDomainTLSSpec) *configv1alpha1.FederationDomain {
+		return &configv1alpha1.FederationDomain{
+			ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: namespace, UID: "some-uid"},
+			Spec:       configv1alpha1.FederationDomainSpec{Issuer: issuer, TLS: tls},
+		}
+	}
+	issuerTLS := &configv1alpha1.FederationDomainTLSSpec{
+		SecretName: "some-secret",
+		IssuerRef:  &configv1alpha1.FederationDomainTLSIssuerRef{Name: "some-issuer"},
+	}
+	clusterIssuerTLS := &configv1alpha1.FederationDomainTLSSpec{
+		SecretName: "some-secret",
+		IssuerRef: &configv1alpha1.FederationDomainTLSIssuerRef{
+			Name:  "some-cluster-issuer",
+			Kind:  "ClusterIssuer",
+			Group: "example.com",
+		},
+	}
+
+	ownerRefs := []interface{}{
+		map[string]interface{}{
+			"apiVersion":         "config.supervisor.pinniped.dev/v1alpha1",
+			"kind":               "FederationDomain",
+			"name":               "some-name",
+			"uid":                "some-uid",
+			"controller":         true,
+			"blockOwnerDeletion": true,
+		},
+	}
+	newCertificate := func(owned bool, spec map[string]interface{}, conditions ...interface{}) *unstructured.Unstructured {
+		metadata := map[string]interface{}{
+			"name":      "some-name-tls",
+			"namespace": namespace,
+			"labels":    map[string]interface{}{"some-label": "some-value"},
+		}
+		if owned {
+			metadata["ownerReferences"] = ownerRefs
+		}
+		obj := map[string]interface{}{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Certificate",
+			"metadata":   metadata,
+			"spec":       spec,
+		}
+		if len(conditions) > 0 {
+			obj["status"] = map[string]interface{}{"conditions": conditions}
+		}
+		return &unstructured.Unstructured{Object: obj}
+	}
+	issuerSpec := map[string]interface{}{
+		"secretName": "some-secret",
+		"dnsNames":   []interface{}{"issuer.example.com"},
+		"issuerRef": map[string]interface{}{
+			"name":  "some-issuer",
+			"kind":  "Issuer",
+			"group": "cert-manager.io",
+		},
+	}
+	condition := func(conditionType, status, reason, message string) interface{} {
+		return map[string]interface{}{"type": conditionType, "status": status, "reason": reason, "message": message}
+	}
+	tlsStatus := func(condition configv1alpha1.FederationDomainTLSStatusCondition, message string) *configv1alpha1.FederationDomainTLSStatus {
+		return &configv1alpha1.FederationDomainTLSStatus{
+			CertificateName: "some-name-tls",
+			Status:          condition,
+			Message:         message,
+			LastUpdateTime:  &frozenMetaNow,
+		}
+	}
+
+	tests := []struct {
+		name              string
+		federationDomain  *configv1alpha1.FederationDomain
+		certificate       *unstructured.Unstructured
+		wantErr           string
+		wantTLSStatus     *configv1alpha1.FederationDomainTLSStatus
+		wantCertificate   *unstructured.Unstructured
+		wantNoCertificate bool
+	}{
+		{
+			name:              "federation domain does not exist",
+			wantNoCertificate: true,
+		},
+		{
+			name:              "federation domain without issuerRef",
+			federationDomain:  newFederationDomain("https://issuer.example.com", &configv1alpha1.FederationDomainTLSSpec{SecretName: "some-secret"}),
+			wantNoCertificate: true,
+		},
+		{
+			name:              "issuerRef without secretName",
+			federationDomain:  newFederationDomain("https://issuer.example.com", &configv1alpha1.FederationDomainTLSSpec{IssuerRef: issuerTLS.IssuerRef}),
+			wantTLSStatus:     tlsStatus(configv1alpha1.FailedFederationDomainTLSStatusCondition, "spec.tls.secretName must be set when spec.tls.issuerRef is set"),
+			wantNoCertificate: true,
+		},
+		{
+			name:              "issuer without hostname",
+			federationDomain:  newFederationDomain("https://", issuerTLS),
+			wantTLSStatus:     tlsStatus(configv1alpha1.FailedFederationDomainTLSStatusCondition, `could not determine hostname of issuer "https://"`),
+			wantNoCertificate: true,
+		},
+		{
+			name:             "creates a Certificate for an Issuer",
+			federationDomain: newFederationDomain("https://issuer.example.com:1234/some/path", issuerTLS),
+			wantErr:          "synthetic requeue request",
+			wantTLSStatus:    tlsStatus(configv1alpha1.PendingFederationDomainTLSStatusCondition, "waiting for cert-manager to issue the Certificate"),
+			wantCertificate:  newCertificate(true, issuerSpec),
+		},
+		{
+			name:             "creates a Certificate for an external ClusterIssuer and an IP address",
+			federationDomain: newFederationDomain("https://1.2.3.4", clusterIssuerTLS),
+			wantErr:          "synthetic requeue request",
+			wantTLSStatus:    tlsStatus(configv1alpha1.PendingFederationDomainTLSStatusCondition, "waiting for cert-manager to issue the Certificate"),
+			wantCertificate: newCertificate(true, map[string]interface{}{
+				"secretName":  "some-secret",
+				"ipAddresses": []interface{}{"1.2.3.4"},
+				"issuerRef": map[string]interface{}{
+					"name":  "some-cluster-issuer",
+					"kind":  "ClusterIssuer",
+					"group": "example.com",
+				},
+			}),
+		},
+		{
+			name:             "updates an outdated Certificate",
+			federationDomain: newFederationDomain("https://issuer.example.com", issuerTLS),
+			certificate: newCertificate(true, map[string]interface{}{"secretName": "some-old-secret"},
+				condition("Ready", "False", "Issuing", "some-message"),
+			),
+			wantErr:       "synthetic requeue request",
+			wantTLSStatus: tlsStatus(configv1alpha1.PendingFederationDomainTLSStatusCondition, "waiting for cert-manager to issue the Certificate: some-message"),
+			wantCertificate: newCertificate(true, issuerSpec,
+				condition("Ready", "False", "Issuing", "some-message"),
+			),
+		},
+		{
+			name:             "Certificate is ready",
+			federationDomain: newFederationDomain("https://issuer.example.com", issuerTLS),
+			certificate: newCertificate(true, issuerSpec,
+				condition("Issuing", "False", "Issued", ""),
+				condition("Ready", "True", "Ready", "Certificate is up to date and has not expired"),
+			),
+			wantTLSStatus: tlsStatus(configv1alpha1.ReadyFederationDomainTLSStatusCondition, "Certificate is ready"),
+			wantCertificate: newCertificate(true, issuerSpec,
+				condition("Issuing", "False", "Issued", ""),
+				condition("Ready", "True", "Ready", "Certificate is up to date and has not expired"),
+			),
+		},
+		{
+			name:             "Certificate issuance failed",
+			federationDomain: newFederationDomain("https://issuer.example.com", issuerTLS),
+			certificate: newCertificate(true, issuerSpec,
+				condition("Ready", "False", "DoesNotExist", "Issuing certificate as Secret does not exist"),
+				condition("Issuing", "False", "Failed", "some issuer error"),
+			),
+			wantTLSStatus: tlsStatus(configv1alpha1.FailedFederationDomainTLSStatusCondition, "Certificate issuance failed: some issuer error"),
+			wantCertificate: newCertificate(true, issuerSpec,
+				condition("Ready", "False", "DoesNotExist", "Issuing certificate as Secret does not exist"),
+				condition("Issuing", "False", "Failed", "some issuer error"),
+			),
+		},
+		{
+			name:             "Certificate exists but is not owned by the federation domain",
+			federationDomain: newFederationDomain("https://issuer.example.com", issuerTLS),
+			certificate:      newCertificate(false, map[string]interface{}{"secretName": "some-other-secret"}),
+			wantErr:          `a Certificate named "some-name-tls" already exists and is not managed by this FederationDomain`,
+			wantTLSStatus: tlsStatus(configv1alpha1.FailedFederationDomainTLSStatusCondition,
+				`a Certificate named "some-name-tls" already exists and is not managed by this FederationDomain`),
+			wantCertificate: newCertificate(false, map[string]interface{}{"secretName": "some-other-secret"}),
+		},
+		{
+			name: "issuerRef was removed",
+			federationDomain: func() *configv1alpha1.FederationDomain {
+				fd := newFederationDomain("https://issuer.example.com", nil)
+				fd.Status.TLS = tlsStatus(configv1alpha1.ReadyFederationDomainTLSStatusCondition, "Certificate is ready")
+				return fd
+			}(),
+			certificate:       newCertificate(true, issuerSpec),
+			wantNoCertificate: true,
+		},
+		{
+			name: "issuerRef was removed but the Certificate is not owned by the federation domain",
+			federationDomain: func() *configv1alpha1.FederationDomain {
+				fd := newFederationDomain("https://issuer.example.com", nil)
+				fd.Status.TLS = tlsStatus(configv1alpha1.ReadyFederationDomainTLSStatusCondition, "Certificate is ready")
+				return fd
+			}(),
+			certificate:     newCertificate(false, issuerSpec),
+			wantCertificate: newCertificate(false, issuerSpec),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			pinnipedAPIClient := pinnipedfake.NewSimpleClientset()
+			pinnipedInformerClient := pinnipedfake.NewSimpleClientset()
+			if tt.federationDomain != nil {
+				require.NoError(t, pinnipedAPIClient.Tracker().Add(tt.federationDomain))
+				require.NoError(t, pinnipedInformerClient.Tracker().Add(tt.federationDomain))
+			}
+			var dynamicObjects []runtime.Object
+			if tt.certificate != nil {
+				dynamicObjects = append(dynamicObjects, tt.certificate)
+			}
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), dynamicObjects...)
+
+			pinnipedInformers := pinnipedinformers.NewSharedInformerFactory(pinnipedInformerClient, 0)
+			c := NewCertManagerCertificateController(
+				map[string]string{"some-label": "some-value"},
+				clock.NewFakeClock(frozenNow),
+				pinnipedAPIClient,
+				dynamicClient,
+				pinnipedInformers.Config().V1alpha1().FederationDomains(),
+				controllerlib.WithInformer,
+			)
+
+			// Must start informers before calling TestRunSynchronously().
+			pinnipedInformers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, c)
+
+			err := controllerlib.TestSync(t, c, controllerlib.Context{
+				Context: ctx,
+				Key:     controllerlib.Key{Namespace: namespace, Name: "some-name"},
+			})
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			if tt.federationDomain != nil {
+				actualFederationDomain, err := pinnipedAPIClient.ConfigV1alpha1().FederationDomains(namespace).Get(ctx, "some-name", metav1.GetOptions{})
+				require.NoError(t, err)
+				require.Equal(t, tt.wantTLSStatus, actualFederationDomain.Status.TLS)
+			}
+
+			actualCertificate, err := dynamicClient.Resource(certManagerCertificateGVR).Namespace(namespace).Get(ctx, "some-name-tls", metav1.GetOptions{})
+			if tt.wantNoCertificate {
+				require.True(t, k8serrors.IsNotFound(err), "expected not found error, got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantCertificate, actualCertificate)
+		})
+	}
+}
@@ -4,7 +4,7 @@
 package supervisorconfig
 
 import (
-	"context"
+	"encoding/base64"
 	"fmt"
 	"net/url"
 	"strings"
@@ -13,6 +13,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 
@@ -20,7 +21,9 @@ import (
 	pinnipedclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
 	configinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions/config/v1alpha1"
 	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/conditionsutil"
 	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/oidc"
 	"go.pinniped.dev/internal/oidc/provider"
 	"go.pinniped.dev/internal/plog"
 )
@@ -37,16 +40,23 @@ type federationDomainWatcherController struct {
 	clock                    clock.Clock
 	client                   pinnipedclientset.Interface
 	federationDomainInformer configinformers.FederationDomainInformer
+	allowLoopbackHTTPIssuers bool
 }
 
 // NewFederationDomainWatcherController creates a controllerlib.Controller that watches
 // FederationDomain objects and notifies a callback object of the collection of provider configs.
+// When allowLoopbackHTTPIssuers is true, issuers with an "http" scheme are also accepted for loopback
+// hosts, which should only be enabled for local development. It records an Event about each FederationDomain whose
+// conditions change their status.
 func NewFederationDomainWatcherController(
 	providerSetter ProvidersSetter,
 	clock clock.Clock,
 	client pinnipedclientset.Interface,
 	federationDomainInformer configinformers.FederationDomainInformer,
+	allowLoopbackHTTPIssuers bool,
+	recorder events.EventRecorder,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -56,13 +66,17 @@ func NewFederationDomainWatcherController(
 				clock:                    clock,
 				client:                   client,
 				federationDomainInformer: federationDomainInformer,
+				allowLoopbackHTTPIssuers: allowLoopbackHTTPIssuers,
 			},
 		},
-		withInformer(
-			federationDomainInformer,
-			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			controllerlib.WithRecorder(recorder),
+			withInformer(
+				federationDomainInformer,
+				pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
@@ -117,7 +131,7 @@ func (c *federationDomainWatcherController) Sync(ctx controllerlib.Context) erro
 		if urlParseErr == nil {
 			if issuerCount := issuerCounts[issuerURLToIssuerKey(issuerURL)]; issuerCount > 1 {
 				if err := c.updateStatus(
-					ctx.Context,
+					ctx,
 					federationDomain.Namespace,
 					federationDomain.Name,
 					configv1alpha1.DuplicateFederationDomainStatusCondition,
@@ -132,7 +146,7 @@ func (c *federationDomainWatcherController) Sync(ctx controllerlib.Context) erro
 		// Skip url parse errors because they will be validated below.
 		if urlParseErr == nil && len(uniqueSecretNamesPerIssuerAddress[issuerURLToHostnameKey(issuerURL)]) > 1 {
 			if err := c.updateStatus(
-				ctx.Context,
+				ctx,
 				federationDomain.Namespace,
 				federationDomain.Name,
 				configv1alpha1.SameIssuerHostMustUseSameSecretFederationDomainStatusCondition,
@@ -143,10 +157,47 @@ func (c *federationDomainWatcherController) Sync(ctx controllerlib.Context) erro
 			continue
 		}
 
-		federationDomainIssuer, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer) // This validates the Issuer URL.
+		newFederationDomainIssuer := provider.NewFederationDomainIssuer
+		if c.allowLoopbackHTTPIssuers {
+			newFederationDomainIssuer = provider.NewDevFederationDomainIssuer
+		}
+		federationDomainIssuer, err := newFederationDomainIssuer(federationDomain.Spec.Issuer) // This validates the Issuer URL.
+		if err != nil {
+			if err := c.updateStatus(
+				ctx,
+				federationDomain.Namespace,
+				federationDomain.Name,
+				configv1alpha1.InvalidFederationDomainStatusCondition,
+				"Invalid: "+err.Error(),
+			); err != nil {
+				errs = append(errs, fmt.Errorf("could not update status: %w", err))
+			}
+			continue
+		}
+		federationDomainIssuer.SetName(federationDomain.Name)
+		federationDomainIssuer.SetLoginApprovalRequired(federationDomain.Spec.LoginApproval != nil && federationDomain.Spec.LoginApproval.Required)
+		if groupsClaim := federationDomain.Spec.GroupsClaim; groupsClaim != nil {
+			if err := oidc.ValidateGroupsClaimName(groupsClaim.Name); err != nil {
+				if err := c.updateStatus(
+					ctx,
+					federationDomain.Namespace,
+					federationDomain.Name,
+					configv1alpha1.InvalidFederationDomainStatusCondition,
+					"Invalid: "+err.Error(),
+				); err != nil {
+					errs = append(errs, fmt.Errorf("could not update status: %w", err))
+				}
+				continue
+			}
+			federationDomainIssuer.SetGroupsClaim(provider.DownstreamGroupsClaim{
+				Name:           groupsClaim.Name,
+				SpaceDelimited: groupsClaim.Format == configv1alpha1.SpaceDelimitedGroupsClaimFormat,
+			})
+		}
+		clusters, err := clustersFromSpec(federationDomain.Spec.Clusters)
 		if err != nil {
 			if err := c.updateStatus(
-				ctx.Context,
+				ctx,
 				federationDomain.Namespace,
 				federationDomain.Name,
 				configv1alpha1.InvalidFederationDomainStatusCondition,
@@ -156,9 +207,10 @@ func (c *federationDomainWatcherController) Sync(ctx controllerlib.Context) erro
 			}
 			continue
 		}
+		federationDomainIssuer.SetClusters(clusters)
 
 		if err := c.updateStatus(
-			ctx.Context,
+			ctx,
 			federationDomain.Namespace,
 			federationDomain.Name,
 			configv1alpha1.SuccessFederationDomainStatusCondition,
@@ -177,18 +229,23 @@ func (c *federationDomainWatcherController) Sync(ctx controllerlib.Context) erro
 }
 
 func (c *federationDomainWatcherController) updateStatus(
-	ctx context.Context,
+	ctx controllerlib.Context,
 	namespace, name string,
 	status configv1alpha1.FederationDomainStatusCondition,
 	message string,
 ) error {
 	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		federationDomain, err := c.client.ConfigV1alpha1().FederationDomains(namespace).Get(ctx, name, metav1.GetOptions{})
+		federationDomain, err := c.client.ConfigV1alpha1().FederationDomains(namespace).Get(ctx.Context, name, metav1.GetOptions{})
 		if err != nil {
 			return fmt.Errorf("get failed: %w", err)
 		}
 
-		if federationDomain.Status.Status == status && federationDomain.Status.Message == message {
+		existingConditions := conditionsutil.FromConfigV1alpha1(federationDomain.Status.Conditions)
+		conditions := conditionsutil.FromConfigV1alpha1(federationDomain.Status.Conditions)
+		conditionsChanged := conditionsutil.MergeWithReady(&conditions, federationDomain.Generation, metav1.NewTime(c.clock.Now()),
+			issuerValidCondition(status, message),
+		)
+		if federationDomain.Status.Status == status && federationDomain.Status.Message == message && !conditionsChanged {
 			return nil
 		}
 
@@ -204,9 +261,53 @@ func (c *federationDomainWatcherController) updateStatus(
 		federationDomain.Status.Status = status
 		federationDomain.Status.Message = message
 		federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(c.clock.Now()))
-		_, err = c.client.ConfigV1alpha1().FederationDomains(namespace).UpdateStatus(ctx, federationDomain, metav1.UpdateOptions{})
-		return err
+		federationDomain.Status.Conditions = conditionsutil.ToConfigV1alpha1(conditions)
+		if _, err := c.client.ConfigV1alpha1().FederationDomains(namespace).UpdateStatus(ctx.Context, federationDomain, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		conditionsutil.RecordTransitions(ctx.Recorder, federationDomain, "ValidateFederationDomain", existingConditions, conditions)
+		return nil
 	})
 }
 
+func clustersFromSpec(specs []configv1alpha1.FederationDomainCluster) ([]provider.Cluster, error) {
+	var clusters []provider.Cluster
+	for _, spec := range specs {
+		caData, err := base64.StdEncoding.DecodeString(spec.CertificateAuthorityData)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %q certificateAuthorityData is not valid base64: %w", spec.Name, err)
+		}
+		cluster := provider.Cluster{
+			Name:                     spec.Name,
+			Audience:                 spec.Audience,
+			Server:                   spec.Server,
+			CertificateAuthorityData: caData,
+			AllowedGroups:            spec.AllowedGroups,
+		}
+		if spec.Concierge != nil {
+			cluster.ConciergeAuthenticatorName = spec.Concierge.AuthenticatorName
+			cluster.ConciergeAPIGroupSuffix = spec.Concierge.APIGroupSuffix
+			if cluster.ConciergeAPIGroupSuffix == "" {
+				cluster.ConciergeAPIGroupSuffix = "pinniped.dev"
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// issuerValidCondition mirrors status.status as a condition, so that it is reflected by the Ready condition.
+func issuerValidCondition(status configv1alpha1.FederationDomainStatusCondition, message string) metav1.Condition {
+	condition := metav1.Condition{
+		Type:    configv1alpha1.ConditionTypeIssuerValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  string(status),
+		Message: message,
+	}
+	if status != configv1alpha1.SuccessFederationDomainStatusCondition {
+		condition.Status = metav1.ConditionFalse
+	}
+	return condition
+}
+
 func timePtr(t metav1.Time) *metav1.Time { return &t }
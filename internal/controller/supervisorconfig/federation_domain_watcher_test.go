@@ -22,6 +22,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/clock"
 	coretesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/events"
 
 	"go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
 	pinnipedfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
@@ -47,6 +48,8 @@ func TestInformerFilters(t *testing.T) {
 				nil,
 				nil,
 				federationDomainInformer,
+				false,
+				nil,
 				observableWithInformerOption.WithInformer, // make it possible to observe the behavior of the Filters
 			)
 			configMapInformerFilter = observableWithInformerOption.GetFilterForInformer(federationDomainInformer)
@@ -93,6 +96,20 @@ func (f *fakeProvidersSetter) SetProviders(federationDomains ...*provider.Federa
 	f.FederationDomainsReceived = federationDomains
 }
 
+// federationDomainIssuerFor returns the FederationDomainIssuer which the controller is expected to create for federationDomain.
+func federationDomainIssuerFor(
+	newIssuer func(issuer string) (*provider.FederationDomainIssuer, error),
+	federationDomain *v1alpha1.FederationDomain,
+) (*provider.FederationDomainIssuer, error) {
+	federationDomainIssuer, err := newIssuer(federationDomain.Spec.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	federationDomainIssuer.SetName(federationDomain.Name)
+	federationDomainIssuer.SetLoginApprovalRequired(federationDomain.Spec.LoginApproval != nil && federationDomain.Spec.LoginApproval.Required)
+	return federationDomainIssuer, nil
+}
+
 func TestSync(t *testing.T) {
 	spec.Run(t, "Sync", func(t *testing.T, when spec.G, it spec.S) {
 		const namespace = "some-namespace"
@@ -109,6 +126,8 @@ func TestSync(t *testing.T) {
 		var frozenNow time.Time
 		var providersSetter *fakeProvidersSetter
 		var federationDomainGVR schema.GroupVersionResource
+		var allowLoopbackHTTPIssuers bool
+		var recorder *events.FakeRecorder
 
 		// Defer starting the informers until the last possible moment so that the
 		// nested Before's can keep adding things to the informer caches.
@@ -119,6 +138,8 @@ func TestSync(t *testing.T) {
 				clock.NewFakeClock(frozenNow),
 				pinnipedAPIClient,
 				federationDomainInformers.Config().V1alpha1().FederationDomains(),
+				allowLoopbackHTTPIssuers,
+				recorder,
 				controllerlib.WithInformer,
 			)
 
@@ -141,6 +162,8 @@ func TestSync(t *testing.T) {
 			r = require.New(t)
 
 			providersSetter = &fakeProvidersSetter{}
+			allowLoopbackHTTPIssuers = false
+			recorder = events.NewFakeRecorder(10)
 			frozenNow = time.Date(2020, time.September, 23, 7, 42, 0, 0, time.Local)
 
 			timeoutContext, timeoutContextCancel = context.WithTimeout(context.Background(), time.Second*3)
@@ -187,10 +210,10 @@ func TestSync(t *testing.T) {
 				err := controllerlib.TestSync(t, subject, *syncContext)
 				r.NoError(err)
 
-				provider1, err := provider.NewFederationDomainIssuer(federationDomain1.Spec.Issuer)
+				provider1, err := federationDomainIssuerFor(provider.NewFederationDomainIssuer, federationDomain1)
 				r.NoError(err)
 
-				provider2, err := provider.NewFederationDomainIssuer(federationDomain2.Spec.Issuer)
+				provider2, err := federationDomainIssuerFor(provider.NewFederationDomainIssuer, federationDomain2)
 				r.NoError(err)
 
 				r.True(providersSetter.SetProvidersWasCalled)
@@ -211,10 +234,12 @@ func TestSync(t *testing.T) {
 				federationDomain1.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 				federationDomain1.Status.Message = "Provider successfully created"
 				federationDomain1.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+				federationDomain1.Status.Conditions = federationDomainConditions(federationDomain1.Status, frozenNow)
 
 				federationDomain2.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 				federationDomain2.Status.Message = "Provider successfully created"
 				federationDomain2.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+				federationDomain2.Status.Conditions = federationDomainConditions(federationDomain2.Status, frozenNow)
 
 				expectedActions := []coretesting.Action{
 					coretesting.NewGetAction(
@@ -241,6 +266,10 @@ func TestSync(t *testing.T) {
 					),
 				}
 				r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				r.Equal([]string{
+					"Normal Success all conditions are true",
+					"Normal Success all conditions are true",
+				}, recordedEvents(recorder))
 			})
 
 			when("one FederationDomain is already up to date", func() {
@@ -248,6 +277,7 @@ func TestSync(t *testing.T) {
 					federationDomain1.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 					federationDomain1.Status.Message = "Provider successfully created"
 					federationDomain1.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+					federationDomain1.Status.Conditions = federationDomainConditions(federationDomain1.Status, frozenNow)
 
 					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain1, federationDomain1.Namespace))
 					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain1, federationDomain1.Namespace))
@@ -261,6 +291,7 @@ func TestSync(t *testing.T) {
 					federationDomain2.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 					federationDomain2.Status.Message = "Provider successfully created"
 					federationDomain2.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+					federationDomain2.Status.Conditions = federationDomainConditions(federationDomain2.Status, frozenNow)
 
 					expectedActions := []coretesting.Action{
 						coretesting.NewGetAction(
@@ -281,6 +312,7 @@ func TestSync(t *testing.T) {
 						),
 					}
 					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+					r.Equal([]string{"Normal Success all conditions are true"}, recordedEvents(recorder))
 				})
 
 				it("calls the ProvidersSetter with both FederationDomain's", func() {
@@ -288,10 +320,10 @@ func TestSync(t *testing.T) {
 					err := controllerlib.TestSync(t, subject, *syncContext)
 					r.NoError(err)
 
-					provider1, err := provider.NewFederationDomainIssuer(federationDomain1.Spec.Issuer)
+					provider1, err := federationDomainIssuerFor(provider.NewFederationDomainIssuer, federationDomain1)
 					r.NoError(err)
 
-					provider2, err := provider.NewFederationDomainIssuer(federationDomain2.Spec.Issuer)
+					provider2, err := federationDomainIssuerFor(provider.NewFederationDomainIssuer, federationDomain2)
 					r.NoError(err)
 
 					r.True(providersSetter.SetProvidersWasCalled)
@@ -326,10 +358,10 @@ func TestSync(t *testing.T) {
 					err := controllerlib.TestSync(t, subject, *syncContext)
 					r.EqualError(err, "could not update status: some update error")
 
-					provider1, err := provider.NewFederationDomainIssuer(federationDomain1.Spec.Issuer)
+					provider1, err := federationDomainIssuerFor(provider.NewFederationDomainIssuer, federationDomain1)
 					r.NoError(err)
 
-					provider2, err := provider.NewFederationDomainIssuer(federationDomain2.Spec.Issuer)
+					provider2, err := federationDomainIssuerFor(provider.NewFederationDomainIssuer, federationDomain2)
 					r.NoError(err)
 
 					r.True(providersSetter.SetProvidersWasCalled)
@@ -348,10 +380,12 @@ func TestSync(t *testing.T) {
 					federationDomain1.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 					federationDomain1.Status.Message = "Provider successfully created"
 					federationDomain1.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+					federationDomain1.Status.Conditions = federationDomainConditions(federationDomain1.Status, frozenNow)
 
 					federationDomain2.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 					federationDomain2.Status.Message = "Provider successfully created"
 					federationDomain2.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+					federationDomain2.Status.Conditions = federationDomainConditions(federationDomain2.Status, frozenNow)
 
 					expectedActions := []coretesting.Action{
 						coretesting.NewGetAction(
@@ -420,6 +454,7 @@ func TestSync(t *testing.T) {
 					federationDomain.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 					federationDomain.Status.Message = "Provider successfully created"
 					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+					federationDomain.Status.Conditions = federationDomainConditions(federationDomain.Status, frozenNow)
 
 					expectedActions := []coretesting.Action{
 						coretesting.NewGetAction(
@@ -468,6 +503,7 @@ func TestSync(t *testing.T) {
 					federationDomain.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 					federationDomain.Status.Message = "Provider successfully created"
 					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+					federationDomain.Status.Conditions = federationDomainConditions(federationDomain.Status, frozenNow)
 
 					expectedActions := []coretesting.Action{
 						coretesting.NewGetAction(
@@ -505,6 +541,7 @@ func TestSync(t *testing.T) {
 					federationDomain.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 					federationDomain.Status.Message = "Provider successfully created"
 					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+					federationDomain.Status.Conditions = federationDomainConditions(federationDomain.Status, frozenNow)
 
 					expectedActions := []coretesting.Action{
 						coretesting.NewGetAction(
@@ -545,7 +582,7 @@ func TestSync(t *testing.T) {
 				err := controllerlib.TestSync(t, subject, *syncContext)
 				r.NoError(err)
 
-				validProvider, err := provider.NewFederationDomainIssuer(validFederationDomain.Spec.Issuer)
+				validProvider, err := federationDomainIssuerFor(provider.NewFederationDomainIssuer, validFederationDomain)
 				r.NoError(err)
 
 				r.True(providersSetter.SetProvidersWasCalled)
@@ -565,10 +602,12 @@ func TestSync(t *testing.T) {
 				validFederationDomain.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 				validFederationDomain.Status.Message = "Provider successfully created"
 				validFederationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+				validFederationDomain.Status.Conditions = federationDomainConditions(validFederationDomain.Status, frozenNow)
 
 				invalidFederationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
 				invalidFederationDomain.Status.Message = "Invalid: issuer must not have query"
 				invalidFederationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+				invalidFederationDomain.Status.Conditions = federationDomainConditions(invalidFederationDomain.Status, frozenNow)
 
 				expectedActions := []coretesting.Action{
 					coretesting.NewGetAction(
@@ -595,6 +634,10 @@ func TestSync(t *testing.T) {
 					),
 				}
 				r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				r.ElementsMatch([]string{
+					"Warning Invalid IssuerValid is False: Invalid: issuer must not have query",
+					"Normal Success all conditions are true",
+				}, recordedEvents(recorder))
 			})
 
 			when("updating only the invalid FederationDomain fails for a reason other than conflict", func() {
@@ -619,7 +662,7 @@ func TestSync(t *testing.T) {
 					err := controllerlib.TestSync(t, subject, *syncContext)
 					r.EqualError(err, "could not update status: some update error")
 
-					validProvider, err := provider.NewFederationDomainIssuer(validFederationDomain.Spec.Issuer)
+					validProvider, err := federationDomainIssuerFor(provider.NewFederationDomainIssuer, validFederationDomain)
 					r.NoError(err)
 
 					r.True(providersSetter.SetProvidersWasCalled)
@@ -639,10 +682,12 @@ func TestSync(t *testing.T) {
 					validFederationDomain.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 					validFederationDomain.Status.Message = "Provider successfully created"
 					validFederationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+					validFederationDomain.Status.Conditions = federationDomainConditions(validFederationDomain.Status, frozenNow)
 
 					invalidFederationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
 					invalidFederationDomain.Status.Message = "Invalid: issuer must not have query"
 					invalidFederationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+					invalidFederationDomain.Status.Conditions = federationDomainConditions(invalidFederationDomain.Status, frozenNow)
 
 					expectedActions := []coretesting.Action{
 						coretesting.NewGetAction(
@@ -673,6 +718,210 @@ func TestSync(t *testing.T) {
 			})
 		})
 
+		when("there is a FederationDomain which requires login approval in the informer", func() {
+			it.Before(func() {
+				federationDomain := &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec: v1alpha1.FederationDomainSpec{
+						Issuer:        "https://issuer.com",
+						LoginApproval: &v1alpha1.FederationDomainLoginApprovalSpec{Required: true},
+					},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+			})
+
+			it("sets a provider which requires login approval", func() {
+				startInformersAndController()
+				err := controllerlib.TestSync(t, subject, *syncContext)
+				r.NoError(err)
+
+				r.True(providersSetter.SetProvidersWasCalled)
+				r.Len(providersSetter.FederationDomainsReceived, 1)
+				r.Equal("config", providersSetter.FederationDomainsReceived[0].Name())
+				r.True(providersSetter.FederationDomainsReceived[0].LoginApprovalRequired())
+			})
+		})
+
+		when("there is a FederationDomain with an additional groups claim in the informer", func() {
+			it.Before(func() {
+				federationDomain := &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec: v1alpha1.FederationDomainSpec{
+						Issuer:      "https://issuer.com",
+						GroupsClaim: &v1alpha1.FederationDomainGroupsClaimSpec{Name: "roles", Format: v1alpha1.SpaceDelimitedGroupsClaimFormat},
+					},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+			})
+
+			it("sets a provider with the additional groups claim", func() {
+				startInformersAndController()
+				err := controllerlib.TestSync(t, subject, *syncContext)
+				r.NoError(err)
+
+				r.True(providersSetter.SetProvidersWasCalled)
+				r.Len(providersSetter.FederationDomainsReceived, 1)
+				r.Equal(provider.DownstreamGroupsClaim{Name: "roles", SpaceDelimited: true}, providersSetter.FederationDomainsReceived[0].GroupsClaim())
+			})
+		})
+
+		when("there is a FederationDomain whose additional groups claim has a reserved name in the informer", func() {
+			it.Before(func() {
+				federationDomain := &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec: v1alpha1.FederationDomainSpec{
+						Issuer:      "https://issuer.com",
+						GroupsClaim: &v1alpha1.FederationDomainGroupsClaimSpec{Name: "username"},
+					},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+			})
+
+			it("sets the status to invalid and does not set a provider", func() {
+				startInformersAndController()
+				err := controllerlib.TestSync(t, subject, *syncContext)
+				r.NoError(err)
+
+				r.True(providersSetter.SetProvidersWasCalled)
+				r.Empty(providersSetter.FederationDomainsReceived)
+
+				actual, err := pinnipedAPIClient.ConfigV1alpha1().FederationDomains(namespace).Get(context.Background(), "config", metav1.GetOptions{})
+				r.NoError(err)
+				r.Equal(v1alpha1.InvalidFederationDomainStatusCondition, actual.Status.Status)
+				r.Equal(`Invalid: groups claim name "username" is reserved`, actual.Status.Message)
+			})
+		})
+
+		when("there is a FederationDomain with clusters in the informer", func() {
+			it.Before(func() {
+				federationDomain := &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec: v1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer.com",
+						Clusters: []v1alpha1.FederationDomainCluster{
+							{
+								Name:                     "prod",
+								Audience:                 "prod-audience",
+								Server:                   "https://prod.example.com",
+								CertificateAuthorityData: "c29tZS1jYQ==",
+								Concierge:                &v1alpha1.FederationDomainClusterConciergeSpec{AuthenticatorName: "supervisor"},
+								AllowedGroups:            []string{"admins"},
+							},
+							{Name: "dev", Audience: "dev-audience", Server: "https://dev.example.com"},
+						},
+					},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+			})
+
+			it("sets a provider with the clusters", func() {
+				startInformersAndController()
+				err := controllerlib.TestSync(t, subject, *syncContext)
+				r.NoError(err)
+
+				r.True(providersSetter.SetProvidersWasCalled)
+				r.Len(providersSetter.FederationDomainsReceived, 1)
+				r.Equal([]provider.Cluster{
+					{
+						Name:                       "prod",
+						Audience:                   "prod-audience",
+						Server:                     "https://prod.example.com",
+						CertificateAuthorityData:   []byte("some-ca"),
+						ConciergeAuthenticatorName: "supervisor",
+						ConciergeAPIGroupSuffix:    "pinniped.dev",
+						AllowedGroups:              []string{"admins"},
+					},
+					{Name: "dev", Audience: "dev-audience", Server: "https://dev.example.com", CertificateAuthorityData: []byte{}},
+				}, providersSetter.FederationDomainsReceived[0].Clusters())
+			})
+		})
+
+		when("there is a FederationDomain with a cluster whose CA data is invalid in the informer", func() {
+			it.Before(func() {
+				federationDomain := &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec: v1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer.com",
+						Clusters: []v1alpha1.FederationDomainCluster{
+							{Name: "prod", Audience: "prod-audience", Server: "https://prod.example.com", CertificateAuthorityData: "not base64"},
+						},
+					},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+			})
+
+			it("sets the status to invalid and does not set a provider", func() {
+				startInformersAndController()
+				err := controllerlib.TestSync(t, subject, *syncContext)
+				r.NoError(err)
+
+				r.True(providersSetter.SetProvidersWasCalled)
+				r.Empty(providersSetter.FederationDomainsReceived)
+
+				actual, err := pinnipedAPIClient.ConfigV1alpha1().FederationDomains(namespace).Get(context.Background(), "config", metav1.GetOptions{})
+				r.NoError(err)
+				r.Equal(v1alpha1.InvalidFederationDomainStatusCondition, actual.Status.Status)
+				r.Equal(`Invalid: cluster "prod" certificateAuthorityData is not valid base64: illegal base64 data at input byte 3`, actual.Status.Message)
+			})
+		})
+
+		when("there is a FederationDomain with a loopback http issuer in the informer", func() {
+			var federationDomain *v1alpha1.FederationDomain
+
+			it.Before(func() {
+				federationDomain = &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec:       v1alpha1.FederationDomainSpec{Issuer: "http://127.0.0.1:8080/issuer"},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+			})
+
+			it("marks it as invalid and does not set the provider", func() {
+				startInformersAndController()
+				err := controllerlib.TestSync(t, subject, *syncContext)
+				r.NoError(err)
+
+				r.True(providersSetter.SetProvidersWasCalled)
+				r.Empty(providersSetter.FederationDomainsReceived)
+
+				federationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
+				federationDomain.Status.Message = `Invalid: issuer must have "https" scheme`
+				federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+				federationDomain.Status.Conditions = federationDomainConditions(federationDomain.Status, frozenNow)
+				r.Equal(federationDomain, pinnipedAPIClient.Actions()[1].(coretesting.UpdateActionImpl).GetObject())
+			})
+
+			when("loopback http issuers are allowed", func() {
+				it.Before(func() {
+					allowLoopbackHTTPIssuers = true
+				})
+
+				it("sets the provider", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					devProvider, err := federationDomainIssuerFor(provider.NewDevFederationDomainIssuer, federationDomain)
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Equal([]*provider.FederationDomainIssuer{devProvider}, providersSetter.FederationDomainsReceived)
+
+					federationDomain.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
+					federationDomain.Status.Message = "Provider successfully created"
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+					federationDomain.Status.Conditions = federationDomainConditions(federationDomain.Status, frozenNow)
+					r.Equal(federationDomain, pinnipedAPIClient.Actions()[1].(coretesting.UpdateActionImpl).GetObject())
+				})
+			})
+		})
+
 		when("there are FederationDomains with duplicate issuer names in the informer", func() {
 			var (
 				federationDomainDuplicate1 *v1alpha1.FederationDomain
@@ -709,7 +958,7 @@ func TestSync(t *testing.T) {
 				err := controllerlib.TestSync(t, subject, *syncContext)
 				r.NoError(err)
 
-				nonDuplicateProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer)
+				nonDuplicateProvider, err := federationDomainIssuerFor(provider.NewFederationDomainIssuer, federationDomain)
 				r.NoError(err)
 
 				r.True(providersSetter.SetProvidersWasCalled)
@@ -729,14 +978,17 @@ func TestSync(t *testing.T) {
 				federationDomain.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 				federationDomain.Status.Message = "Provider successfully created"
 				federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+				federationDomain.Status.Conditions = federationDomainConditions(federationDomain.Status, frozenNow)
 
 				federationDomainDuplicate1.Status.Status = v1alpha1.DuplicateFederationDomainStatusCondition
 				federationDomainDuplicate1.Status.Message = "Duplicate issuer: https://iSSueR-duPlicAte.cOm/a"
 				federationDomainDuplicate1.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+				federationDomainDuplicate1.Status.Conditions = federationDomainConditions(federationDomainDuplicate1.Status, frozenNow)
 
 				federationDomainDuplicate2.Status.Status = v1alpha1.DuplicateFederationDomainStatusCondition
 				federationDomainDuplicate2.Status.Message = "Duplicate issuer: https://issuer-duplicate.com/a"
 				federationDomainDuplicate2.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+				federationDomainDuplicate2.Status.Conditions = federationDomainConditions(federationDomainDuplicate2.Status, frozenNow)
 
 				expectedActions := []coretesting.Action{
 					coretesting.NewGetAction(
@@ -798,6 +1050,7 @@ func TestSync(t *testing.T) {
 					federationDomain.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 					federationDomain.Status.Message = "Provider successfully created"
 					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+					federationDomain.Status.Conditions = federationDomainConditions(federationDomain.Status, frozenNow)
 
 					expectedActions := []coretesting.Action{
 						coretesting.NewGetAction(
@@ -882,7 +1135,7 @@ func TestSync(t *testing.T) {
 				err := controllerlib.TestSync(t, subject, *syncContext)
 				r.NoError(err)
 
-				nonDuplicateProvider, err := provider.NewFederationDomainIssuer(federationDomainDifferentIssuerAddress.Spec.Issuer)
+				nonDuplicateProvider, err := federationDomainIssuerFor(provider.NewFederationDomainIssuer, federationDomainDifferentIssuerAddress)
 				r.NoError(err)
 
 				r.True(providersSetter.SetProvidersWasCalled)
@@ -902,18 +1155,22 @@ func TestSync(t *testing.T) {
 				federationDomainDifferentIssuerAddress.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 				federationDomainDifferentIssuerAddress.Status.Message = "Provider successfully created"
 				federationDomainDifferentIssuerAddress.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+				federationDomainDifferentIssuerAddress.Status.Conditions = federationDomainConditions(federationDomainDifferentIssuerAddress.Status, frozenNow)
 
 				federationDomainSameIssuerAddress1.Status.Status = v1alpha1.SameIssuerHostMustUseSameSecretFederationDomainStatusCondition
 				federationDomainSameIssuerAddress1.Status.Message = "Issuers with the same DNS hostname (address not including port) must use the same secretName: issuer-duplicate-address.com"
 				federationDomainSameIssuerAddress1.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+				federationDomainSameIssuerAddress1.Status.Conditions = federationDomainConditions(federationDomainSameIssuerAddress1.Status, frozenNow)
 
 				federationDomainSameIssuerAddress2.Status.Status = v1alpha1.SameIssuerHostMustUseSameSecretFederationDomainStatusCondition
 				federationDomainSameIssuerAddress2.Status.Message = "Issuers with the same DNS hostname (address not including port) must use the same secretName: issuer-duplicate-address.com"
 				federationDomainSameIssuerAddress2.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+				federationDomainSameIssuerAddress2.Status.Conditions = federationDomainConditions(federationDomainSameIssuerAddress2.Status, frozenNow)
 
 				federationDomainWithInvalidIssuerURL.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
 				federationDomainWithInvalidIssuerURL.Status.Message = `Invalid: could not parse issuer as URL: parse ":/host//path": missing protocol scheme`
 				federationDomainWithInvalidIssuerURL.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+				federationDomainWithInvalidIssuerURL.Status.Conditions = federationDomainConditions(federationDomainWithInvalidIssuerURL.Status, frozenNow)
 
 				expectedActions := []coretesting.Action{
 					coretesting.NewGetAction(
@@ -986,6 +1243,7 @@ func TestSync(t *testing.T) {
 					federationDomainDifferentIssuerAddress.Status.Status = v1alpha1.SuccessFederationDomainStatusCondition
 					federationDomainDifferentIssuerAddress.Status.Message = "Provider successfully created"
 					federationDomainDifferentIssuerAddress.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+					federationDomainDifferentIssuerAddress.Status.Conditions = federationDomainConditions(federationDomainDifferentIssuerAddress.Status, frozenNow)
 
 					expectedActions := []coretesting.Action{
 						coretesting.NewGetAction(
@@ -1026,3 +1284,40 @@ func TestSync(t *testing.T) {
 		})
 	}, spec.Parallel(), spec.Report(report.Terminal{}))
 }
+
+// federationDomainConditions returns the conditions which the controller sets along with the status.
+func federationDomainConditions(status v1alpha1.FederationDomainStatus, now time.Time) []v1alpha1.Condition {
+	issuerValid := v1alpha1.Condition{
+		Type:               "IssuerValid",
+		Status:             v1alpha1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             string(status.Status),
+		Message:            status.Message,
+	}
+	ready := v1alpha1.Condition{
+		Type:               "Ready",
+		Status:             v1alpha1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "Success",
+		Message:            "all conditions are true",
+	}
+	if status.Status != v1alpha1.SuccessFederationDomainStatusCondition {
+		issuerValid.Status = v1alpha1.ConditionFalse
+		ready.Status = v1alpha1.ConditionFalse
+		ready.Reason = string(status.Status)
+		ready.Message = "IssuerValid is False: " + status.Message
+	}
+	return []v1alpha1.Condition{issuerValid, ready}
+}
+
+func recordedEvents(recorder *events.FakeRecorder) []string {
+	var got []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			got = append(got, event)
+		default:
+			return got
+		}
+	}
+}
@@ -44,6 +44,7 @@ func NewFederationDomainSecretsController(
 	secretInformer corev1informers.SecretInformer,
 	federationDomainInformer configinformers.FederationDomainInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -57,19 +58,21 @@ func NewFederationDomainSecretsController(
 				federationDomainInformer: federationDomainInformer,
 			},
 		},
-		// We want to be notified when a FederationDomain's secret gets updated or deleted. When this happens, we
-		// should get notified via the corresponding FederationDomain key.
-		withInformer(
-			secretInformer,
-			pinnipedcontroller.SimpleFilter(secretHelper.Handles, pinnipedcontroller.SecretIsControlledByParentFunc(secretHelper.Handles)),
-			controllerlib.InformerOption{},
-		),
-		// We want to be notified when anything happens to an FederationDomain.
-		withInformer(
-			federationDomainInformer,
-			pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			// We want to be notified when a FederationDomain's secret gets updated or deleted. When this happens, we
+			// should get notified via the corresponding FederationDomain key.
+			withInformer(
+				secretInformer,
+				pinnipedcontroller.SimpleFilter(secretHelper.Handles, pinnipedcontroller.SecretIsControlledByParentFunc(secretHelper.Handles)),
+				controllerlib.InformerOption{},
+			),
+			// We want to be notified when anything happens to an FederationDomain.
+			withInformer(
+				federationDomainInformer,
+				pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
@@ -0,0 +1,109 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package generator
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	pinnipedclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/secret"
+)
+
+// KeyLoader reads the keys which the controllers of this package generate directly from the API server. The
+// controllers of a replica only put the keys into its cache once its informers have seen them, so a replica which
+// serves a request of a login that another replica started, e.g. in another zone, can load them itself instead of
+// rejecting the request.
+type KeyLoader struct {
+	namespace      string
+	csrfSecretName string
+	labels         map[string]string
+	kubeClient     kubernetes.Interface
+	pinnipedClient pinnipedclientset.Interface
+	cache          *secret.Cache
+}
+
+// NewKeyLoader returns a KeyLoader which puts the keys of the Supervisor owner and its FederationDomains into the
+// cache. The owner and labels must be the same as those of NewSupervisorSecretsController.
+func NewKeyLoader(
+	owner *appsv1.Deployment,
+	labels map[string]string,
+	kubeClient kubernetes.Interface,
+	pinnipedClient pinnipedclientset.Interface,
+	cache *secret.Cache,
+) *KeyLoader {
+	return &KeyLoader{
+		namespace:      owner.Namespace,
+		csrfSecretName: owner.Name + "-key",
+		labels:         labels,
+		kubeClient:     kubeClient,
+		pinnipedClient: pinnipedClient,
+		cache:          cache,
+	}
+}
+
+// Load reads the keys of the FederationDomain with the issuer which are not in the cache yet. Keys which have not been
+// generated yet are left out of the cache without an error, since only the controllers create them.
+func (l *KeyLoader) Load(ctx context.Context, issuer string) error {
+	if l.cache.GetCSRFCookieEncoderHashKey() == nil {
+		csrfSecret, err := l.kubeClient.CoreV1().Secrets(l.namespace).Get(ctx, l.csrfSecretName, metav1.GetOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("could not get the CSRF cookie key: %w", err)
+		}
+		if err == nil && isValid(csrfSecret, l.labels) {
+			l.cache.SetCSRFCookieEncoderHashKey(csrfSecret.Data[symmetricSecretDataKey])
+		}
+	}
+
+	if l.cache.GetTokenHMACKey(issuer) != nil &&
+		l.cache.GetStateEncoderHashKey(issuer) != nil &&
+		l.cache.GetStateEncoderBlockKey(issuer) != nil {
+		return nil
+	}
+	federationDomains, err := l.pinnipedClient.ConfigV1alpha1().FederationDomains(l.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("could not list FederationDomains: %w", err)
+	}
+	for i := range federationDomains.Items {
+		federationDomain := &federationDomains.Items[i]
+		if federationDomain.Spec.Issuer != issuer {
+			continue
+		}
+		keys := []struct {
+			usage SecretUsage
+			ref   corev1.LocalObjectReference
+			set   func(issuer string, key []byte)
+		}{
+			{SecretUsageTokenSigningKey, federationDomain.Status.Secrets.TokenSigningKey, l.cache.SetTokenHMACKey},
+			{SecretUsageStateSigningKey, federationDomain.Status.Secrets.StateSigningKey, l.cache.SetStateEncoderHashKey},
+			{SecretUsageStateEncryptionKey, federationDomain.Status.Secrets.StateEncryptionKey, l.cache.SetStateEncoderBlockKey},
+		}
+		for _, key := range keys {
+			if key.ref.Name == "" {
+				continue
+			}
+			keySecret, err := l.kubeClient.CoreV1().Secrets(l.namespace).Get(ctx, key.ref.Name, metav1.GetOptions{})
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("could not get key of FederationDomain %q: %w", federationDomain.Name, err)
+			}
+			helper := &symmetricSecretHelper{secretUsage: key.usage}
+			if helper.IsValid(federationDomain, keySecret) {
+				key.set(issuer, keySecret.Data[symmetricSecretDataKey])
+			}
+		}
+		plog.Debug("loaded the keys of a FederationDomain from the API", "issuer", issuer)
+		return nil
+	}
+	return nil
+}
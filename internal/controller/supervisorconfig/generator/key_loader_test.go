@@ -0,0 +1,171 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	kubetesting "k8s.io/client-go/testing"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	"go.pinniped.dev/internal/secret"
+)
+
+func TestKeyLoader(t *testing.T) {
+	const issuer = "https://some-issuer.com/some/path"
+
+	federationDomain := &configv1alpha1.FederationDomain{
+		ObjectMeta: metav1.ObjectMeta{Name: "some-federation-domain", Namespace: owner.Namespace, UID: "some-federation-domain-uid"},
+		Spec:       configv1alpha1.FederationDomainSpec{Issuer: issuer},
+		Status: configv1alpha1.FederationDomainStatus{
+			Secrets: configv1alpha1.FederationDomainSecrets{
+				TokenSigningKey:    corev1.LocalObjectReference{Name: "some-token-signing-key"},
+				StateSigningKey:    corev1.LocalObjectReference{Name: "some-state-signing-key"},
+				StateEncryptionKey: corev1.LocalObjectReference{Name: "some-state-encryption-key"},
+			},
+		},
+	}
+	otherFederationDomain := &configv1alpha1.FederationDomain{
+		ObjectMeta: metav1.ObjectMeta{Name: "other-federation-domain", Namespace: owner.Namespace, UID: "other-federation-domain-uid"},
+		Spec:       configv1alpha1.FederationDomainSpec{Issuer: "https://other-issuer.com"},
+	}
+
+	key := func(b byte) []byte { return bytes.Repeat([]byte{b}, symmetricKeySize) }
+	csrfSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: owner.Name + "-key", Namespace: owner.Namespace, Labels: labels},
+		Type:       SupervisorCSRFSigningKeySecretType,
+		Data:       map[string][]byte{symmetricSecretDataKey: key('c')},
+	}
+	federationDomainSecret := func(parent *configv1alpha1.FederationDomain, name string, secretType corev1.SecretType, data []byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: owner.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(parent, schema.GroupVersionKind{
+						Group:   configv1alpha1.SchemeGroupVersion.Group,
+						Version: configv1alpha1.SchemeGroupVersion.Version,
+						Kind:    federationDomainKind,
+					}),
+				},
+			},
+			Type: secretType,
+			Data: map[string][]byte{symmetricSecretDataKey: data},
+		}
+	}
+	tokenSigningKey := federationDomainSecret(federationDomain, "some-token-signing-key", FederationDomainTokenSigningKeyType, key('t'))
+	stateSigningKey := federationDomainSecret(federationDomain, "some-state-signing-key", FederationDomainStateSigningKeyType, key('s'))
+	stateEncryptionKey := federationDomainSecret(federationDomain, "some-state-encryption-key", FederationDomainStateEncryptionKeyType, key('e'))
+
+	tests := []struct {
+		name                 string
+		kubeObjects          []runtime.Object
+		federationDomains    []runtime.Object
+		prepareCache         func(*secret.Cache)
+		addKubeReactions     func(*kubernetesfake.Clientset)
+		wantError            string
+		wantCSRFKey          []byte
+		wantTokenHMACKey     []byte
+		wantStateHashKey     []byte
+		wantStateBlockKey    []byte
+		wantKubeActionsCount int
+	}{
+		{
+			name:                 "loads all keys",
+			kubeObjects:          []runtime.Object{csrfSecret, tokenSigningKey, stateSigningKey, stateEncryptionKey},
+			federationDomains:    []runtime.Object{otherFederationDomain, federationDomain},
+			wantCSRFKey:          key('c'),
+			wantTokenHMACKey:     key('t'),
+			wantStateHashKey:     key('s'),
+			wantStateBlockKey:    key('e'),
+			wantKubeActionsCount: 4,
+		},
+		{
+			name:              "does not read keys which are already cached",
+			kubeObjects:       []runtime.Object{csrfSecret, tokenSigningKey, stateSigningKey, stateEncryptionKey},
+			federationDomains: []runtime.Object{federationDomain},
+			prepareCache: func(cache *secret.Cache) {
+				cache.SetCSRFCookieEncoderHashKey(key('C'))
+				cache.SetTokenHMACKey(issuer, key('T'))
+				cache.SetStateEncoderHashKey(issuer, key('S'))
+				cache.SetStateEncoderBlockKey(issuer, key('E'))
+			},
+			wantCSRFKey:       key('C'),
+			wantTokenHMACKey:  key('T'),
+			wantStateHashKey:  key('S'),
+			wantStateBlockKey: key('E'),
+		},
+		{
+			name: "skips keys which are invalid or not generated yet",
+			kubeObjects: []runtime.Object{
+				federationDomainSecret(otherFederationDomain, "some-token-signing-key", FederationDomainTokenSigningKeyType, key('t')),
+				federationDomainSecret(federationDomain, "some-state-signing-key", FederationDomainStateEncryptionKeyType, key('s')),
+				stateEncryptionKey,
+			},
+			federationDomains:    []runtime.Object{federationDomain},
+			wantStateBlockKey:    key('e'),
+			wantKubeActionsCount: 4,
+		},
+		{
+			name:                 "unknown issuer",
+			kubeObjects:          []runtime.Object{csrfSecret},
+			federationDomains:    []runtime.Object{otherFederationDomain},
+			wantCSRFKey:          key('c'),
+			wantKubeActionsCount: 1,
+		},
+		{
+			name:        "error getting a key",
+			kubeObjects: []runtime.Object{csrfSecret},
+			addKubeReactions: func(client *kubernetesfake.Clientset) {
+				client.PrependReactor("get", "secrets", func(action kubetesting.Action) (bool, runtime.Object, error) {
+					if action.(kubetesting.GetAction).GetName() == "some-token-signing-key" {
+						return true, nil, errors.New("some get error")
+					}
+					return false, nil, nil
+				})
+			},
+			federationDomains:    []runtime.Object{federationDomain},
+			wantError:            `could not get key of FederationDomain "some-federation-domain": some get error`,
+			wantCSRFKey:          key('c'),
+			wantKubeActionsCount: 2,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			kubeClient := kubernetesfake.NewSimpleClientset(test.kubeObjects...)
+			if test.addKubeReactions != nil {
+				test.addKubeReactions(kubeClient)
+			}
+			cache := secret.Cache{}
+			if test.prepareCache != nil {
+				test.prepareCache(&cache)
+			}
+
+			err := NewKeyLoader(owner, labels, kubeClient, pinnipedfake.NewSimpleClientset(test.federationDomains...), &cache).
+				Load(context.Background(), issuer)
+			if test.wantError != "" {
+				require.EqualError(t, err, test.wantError)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, test.wantCSRFKey, cache.GetCSRFCookieEncoderHashKey())
+			require.Equal(t, test.wantTokenHMACKey, cache.GetTokenHMACKey(issuer))
+			require.Equal(t, test.wantStateHashKey, cache.GetStateEncoderHashKey(issuer))
+			require.Equal(t, test.wantStateBlockKey, cache.GetStateEncoderBlockKey(issuer))
+			require.Len(t, kubeClient.Actions(), test.wantKubeActionsCount)
+		})
+	}
+}
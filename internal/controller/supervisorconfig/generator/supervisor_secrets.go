@@ -43,6 +43,7 @@ func NewSupervisorSecretsController(
 	setCacheFunc func(secret []byte),
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
 	initialEventFunc pinnipedcontroller.WithInitialEventOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	c := supervisorSecretsController{
 		labels:         labels,
@@ -52,24 +53,26 @@ func NewSupervisorSecretsController(
 	}
 	return controllerlib.New(
 		controllerlib.Config{Name: owner.Name + "-secret-generator", Syncer: &c},
-		withInformer(
-			secretInformer,
-			pinnipedcontroller.SimpleFilter(func(obj metav1.Object) bool {
-				secret, ok := obj.(*corev1.Secret)
-				if !ok {
-					return false
-				}
-				if secret.Type != SupervisorCSRFSigningKeySecretType {
-					return false
-				}
-				return true
-			}, nil),
-			controllerlib.InformerOption{},
-		),
-		initialEventFunc(controllerlib.Key{
-			Namespace: owner.Namespace,
-			Name:      owner.Name + "-key",
-		}),
+		append([]controllerlib.Option{
+			withInformer(
+				secretInformer,
+				pinnipedcontroller.SimpleFilter(func(obj metav1.Object) bool {
+					secret, ok := obj.(*corev1.Secret)
+					if !ok {
+						return false
+					}
+					if secret.Type != SupervisorCSRFSigningKeySecretType {
+						return false
+					}
+					return true
+				}, nil),
+				controllerlib.InformerOption{},
+			),
+			initialEventFunc(controllerlib.Key{
+				Namespace: owner.Namespace,
+				Name:      owner.Name + "-key",
+			}),
+		}, opts...)...,
 	)
 }
 
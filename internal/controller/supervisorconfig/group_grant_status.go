@@ -0,0 +1,113 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package supervisorconfig
+
+import (
+	"fmt"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/klog/v2"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	pinnipedclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
+	configinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions/config/v1alpha1"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/oidc/groupgrant"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/timeformat"
+)
+
+type groupGrantStatusController struct {
+	clock              clock.Clock
+	client             pinnipedclientset.Interface
+	groupGrantInformer configinformers.GroupGrantInformer
+}
+
+// NewGroupGrantStatusController creates a controllerlib.Controller that keeps the status.phase of each GroupGrant
+// up to date, and logs when each grant becomes active and when it expires.
+func NewGroupGrantStatusController(
+	clock clock.Clock,
+	client pinnipedclientset.Interface,
+	groupGrantInformer configinformers.GroupGrantInformer,
+	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "GroupGrantStatusController",
+			Syncer: &groupGrantStatusController{
+				clock:              clock,
+				client:             client,
+				groupGrantInformer: groupGrantInformer,
+			},
+		},
+		append([]controllerlib.Option{
+			withInformer(
+				groupGrantInformer,
+				pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
+	)
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *groupGrantStatusController) Sync(ctx controllerlib.Context) error {
+	grant, err := c.groupGrantInformer.Lister().GroupGrants(ctx.Key.Namespace).Get(ctx.Key.Name)
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get GroupGrant %s/%s: %w", ctx.Key.Namespace, ctx.Key.Name, err)
+	}
+
+	now := c.clock.Now()
+	phase := groupgrant.Phase(grant, now)
+
+	// Sync again when the phase is next due to change.
+	switch phase {
+	case configv1alpha1.PendingGroupGrantPhase:
+		ctx.Queue.AddAfter(ctx.Key, grant.Spec.NotBefore.Sub(now))
+	case configv1alpha1.ActiveGroupGrantPhase:
+		ctx.Queue.AddAfter(ctx.Key, grant.Spec.ExpiresAt.Sub(now))
+	}
+
+	if grant.Status.Phase == phase {
+		return nil
+	}
+
+	plog.Info("group grant phase changed",
+		"groupGrant", klog.KObj(grant),
+		"federationDomain", grant.Spec.FederationDomainName,
+		"subject", grant.Spec.Subject,
+		"username", grant.Spec.Username,
+		"groups", grant.Spec.Groups,
+		"oldPhase", grant.Status.Phase,
+		"newPhase", phase,
+	)
+
+	updated := grant.DeepCopy()
+	updated.Status.Phase = phase
+	updated.Status.Message = groupGrantPhaseMessage(grant, phase)
+	lastUpdateTime := metav1.NewTime(now)
+	updated.Status.LastUpdateTime = &lastUpdateTime
+	if _, err := c.client.ConfigV1alpha1().GroupGrants(grant.Namespace).UpdateStatus(ctx.Context, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update status of GroupGrant %s/%s: %w", grant.Namespace, grant.Name, err)
+	}
+	return nil
+}
+
+func groupGrantPhaseMessage(grant *configv1alpha1.GroupGrant, phase configv1alpha1.GroupGrantPhase) string {
+	switch phase {
+	case configv1alpha1.PendingGroupGrantPhase:
+		return fmt.Sprintf("the grant will become active at %s", timeformat.RFC3339(grant.Spec.NotBefore.Time))
+	case configv1alpha1.ActiveGroupGrantPhase:
+		return fmt.Sprintf("the grant is active until %s", timeformat.RFC3339(grant.Spec.ExpiresAt.Time))
+	default:
+		return fmt.Sprintf("the grant expired at %s", timeformat.RFC3339(grant.Spec.ExpiresAt.Time))
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package supervisorconfig
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	pinnipedinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions"
+	"go.pinniped.dev/internal/controllerlib"
+)
+
+// recordingQueue is a controllerlib.Queue which only records the keys that were added to it.
+type recordingQueue struct {
+	addedAfter map[controllerlib.Key]time.Duration
+}
+
+func (q *recordingQueue) Add(controllerlib.Key)            {}
+func (q *recordingQueue) AddRateLimited(controllerlib.Key) {}
+func (q *recordingQueue) AddAfter(key controllerlib.Key, duration time.Duration) {
+	q.addedAfter[key] = duration
+}
+
+func TestGroupGrantStatusControllerSync(t *testing.T) {
+	t.Parallel()
+
+	const namespace = "some-namespace"
+
+	frozenNow := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	frozenMetaNow := metav1.NewTime(frozenNow)
+	key := controllerlib.Key{Namespace: namespace, Name: "some-grant"}
+
+	newGroupGrant := func(notBefore *time.Time, expiresAt time.Time, phase configv1alpha1.GroupGrantPhase) *configv1alpha1.GroupGrant {
+		grant := &configv1alpha1.GroupGrant{
+			ObjectMeta: metav1.ObjectMeta{Name: "some-grant", Namespace: namespace},
+			Spec: configv1alpha1.GroupGrantSpec{
+				FederationDomainName: "some-federation-domain",
+				Subject:              "some-subject",
+				Username:             "some-user",
+				Groups:               []string{"some-group"},
+				ExpiresAt:            metav1.NewTime(expiresAt),
+			},
+			Status: configv1alpha1.GroupGrantStatus{Phase: phase},
+		}
+		if notBefore != nil {
+			metaNotBefore := metav1.NewTime(*notBefore)
+			grant.Spec.NotBefore = &metaNotBefore
+		}
+		return grant
+	}
+	inOneHour := frozenNow.Add(time.Hour)
+	oneHourAgo := frozenNow.Add(-time.Hour)
+
+	tests := []struct {
+		name           string
+		groupGrant     *configv1alpha1.GroupGrant
+		wantStatus     configv1alpha1.GroupGrantStatus
+		wantAddedAfter map[controllerlib.Key]time.Duration
+	}{
+		{
+			name:           "grant does not exist",
+			wantAddedAfter: map[controllerlib.Key]time.Duration{},
+		},
+		{
+			name:       "new grant which is not active yet",
+			groupGrant: newGroupGrant(&inOneHour, frozenNow.Add(2*time.Hour), ""),
+			wantStatus: configv1alpha1.GroupGrantStatus{
+				Phase:          configv1alpha1.PendingGroupGrantPhase,
+				Message:        "the grant will become active at 2021-03-04T06:06:07Z",
+				LastUpdateTime: &frozenMetaNow,
+			},
+			wantAddedAfter: map[controllerlib.Key]time.Duration{key: time.Hour},
+		},
+		{
+			name:       "pending grant which has become active",
+			groupGrant: newGroupGrant(&oneHourAgo, inOneHour, configv1alpha1.PendingGroupGrantPhase),
+			wantStatus: configv1alpha1.GroupGrantStatus{
+				Phase:          configv1alpha1.ActiveGroupGrantPhase,
+				Message:        "the grant is active until 2021-03-04T06:06:07Z",
+				LastUpdateTime: &frozenMetaNow,
+			},
+			wantAddedAfter: map[controllerlib.Key]time.Duration{key: time.Hour},
+		},
+		{
+			name:       "active grant without a notBefore time",
+			groupGrant: newGroupGrant(nil, inOneHour, configv1alpha1.ActiveGroupGrantPhase),
+			wantStatus: configv1alpha1.GroupGrantStatus{
+				Phase: configv1alpha1.ActiveGroupGrantPhase,
+			},
+			wantAddedAfter: map[controllerlib.Key]time.Duration{key: time.Hour},
+		},
+		{
+			name:       "active grant which has expired",
+			groupGrant: newGroupGrant(nil, oneHourAgo, configv1alpha1.ActiveGroupGrantPhase),
+			wantStatus: configv1alpha1.GroupGrantStatus{
+				Phase:          configv1alpha1.ExpiredGroupGrantPhase,
+				Message:        "the grant expired at 2021-03-04T04:06:07Z",
+				LastUpdateTime: &frozenMetaNow,
+			},
+			wantAddedAfter: map[controllerlib.Key]time.Duration{},
+		},
+		{
+			name:       "grant expires exactly now",
+			groupGrant: newGroupGrant(nil, frozenNow, configv1alpha1.ActiveGroupGrantPhase),
+			wantStatus: configv1alpha1.GroupGrantStatus{
+				Phase:          configv1alpha1.ExpiredGroupGrantPhase,
+				Message:        "the grant expired at 2021-03-04T05:06:07Z",
+				LastUpdateTime: &frozenMetaNow,
+			},
+			wantAddedAfter: map[controllerlib.Key]time.Duration{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			pinnipedAPIClient := pinnipedfake.NewSimpleClientset()
+			pinnipedInformerClient := pinnipedfake.NewSimpleClientset()
+			if tt.groupGrant != nil {
+				require.NoError(t, pinnipedAPIClient.Tracker().Add(tt.groupGrant))
+				require.NoError(t, pinnipedInformerClient.Tracker().Add(tt.groupGrant))
+			}
+
+			pinnipedInformers := pinnipedinformers.NewSharedInformerFactory(pinnipedInformerClient, 0)
+			c := NewGroupGrantStatusController(
+				clock.NewFakeClock(frozenNow),
+				pinnipedAPIClient,
+				pinnipedInformers.Config().V1alpha1().GroupGrants(),
+				controllerlib.WithInformer,
+			)
+
+			// Must start informers before calling TestRunSynchronously().
+			pinnipedInformers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, c)
+
+			queue := &recordingQueue{addedAfter: map[controllerlib.Key]time.Duration{}}
+			require.NoError(t, controllerlib.TestSync(t, c, controllerlib.Context{
+				Context: ctx,
+				Key:     key,
+				Queue:   queue,
+			}))
+			require.Equal(t, tt.wantAddedAfter, queue.addedAfter)
+
+			if tt.groupGrant != nil {
+				actualGroupGrant, err := pinnipedAPIClient.ConfigV1alpha1().GroupGrants(namespace).Get(ctx, "some-grant", metav1.GetOptions{})
+				require.NoError(t, err)
+				require.Equal(t, tt.wantStatus, actualGroupGrant.Status)
+			}
+		})
+	}
+}
@@ -40,6 +40,7 @@ func NewJWKSObserverController(
 	secretInformer corev1informers.SecretInformer,
 	federationDomainInformer v1alpha1.FederationDomainInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -50,16 +51,18 @@ func NewJWKSObserverController(
 				secretInformer:           secretInformer,
 			},
 		},
-		withInformer(
-			secretInformer,
-			pinnipedcontroller.MatchAnySecretOfTypeFilter(jwksSecretTypeValue, nil),
-			controllerlib.InformerOption{},
-		),
-		withInformer(
-			federationDomainInformer,
-			pinnipedcontroller.MatchAnythingFilter(nil),
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			withInformer(
+				secretInformer,
+				pinnipedcontroller.MatchAnySecretOfTypeFilter(jwksSecretTypeValue, nil),
+				controllerlib.InformerOption{},
+			),
+			withInformer(
+				federationDomainInformer,
+				pinnipedcontroller.MatchAnythingFilter(nil),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
@@ -5,9 +5,11 @@ package supervisorconfig
 
 import (
 	"context"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -19,6 +21,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 
@@ -68,14 +72,17 @@ type jwksWriterController struct {
 }
 
 // NewJWKSWriterController returns a controllerlib.Controller that ensures a FederationDomain has a corresponding
-// Secret that contains a valid active JWK and JWKS.
+// Secret that contains a valid active JWK and JWKS. It records an Event about the FederationDomain whenever it
+// generates a new JWKS, or fails to.
 func NewJWKSWriterController(
 	jwksSecretLabels map[string]string,
 	kubeClient kubernetes.Interface,
 	pinnipedClient pinnipedclientset.Interface,
 	secretInformer corev1informers.SecretInformer,
 	federationDomainInformer configinformers.FederationDomainInformer,
+	recorder events.EventRecorder,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	isSecretToSync := func(obj metav1.Object) bool {
 		return generator.IsFederationDomainSecretOfType(obj, jwksSecretTypeValue)
@@ -92,19 +99,22 @@ func NewJWKSWriterController(
 				federationDomainInformer: federationDomainInformer,
 			},
 		},
-		// We want to be notified when a FederationDomain's secret gets updated or deleted. When this happens, we
-		// should get notified via the corresponding FederationDomain key.
-		withInformer(
-			secretInformer,
-			pinnipedcontroller.SimpleFilter(isSecretToSync, pinnipedcontroller.SecretIsControlledByParentFunc(isSecretToSync)),
-			controllerlib.InformerOption{},
-		),
-		// We want to be notified when anything happens to an FederationDomain.
-		withInformer(
-			federationDomainInformer,
-			pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			controllerlib.WithRecorder(recorder),
+			// We want to be notified when a FederationDomain's secret gets updated or deleted. When this happens, we
+			// should get notified via the corresponding FederationDomain key.
+			withInformer(
+				secretInformer,
+				pinnipedcontroller.SimpleFilter(isSecretToSync, pinnipedcontroller.SecretIsControlledByParentFunc(isSecretToSync)),
+				controllerlib.InformerOption{},
+			),
+			// We want to be notified when anything happens to an FederationDomain.
+			withInformer(
+				federationDomainInformer,
+				pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
@@ -153,10 +163,17 @@ func (c *jwksWriterController) Sync(ctx controllerlib.Context) error {
 		return fmt.Errorf("cannot generate secret: %w", err)
 	}
 
-	if err := c.createOrUpdateSecret(ctx.Context, secret); err != nil {
+	changed, err := c.createOrUpdateSecret(ctx.Context, secret)
+	if err != nil {
+		ctx.Recorder.Eventf(federationDomain, nil, corev1.EventTypeWarning, "JWKSGenerationFailed", "GenerateJWKS",
+			"could not create or update Secret %q: %v", secret.Name, err)
 		return fmt.Errorf("cannot create or update secret: %w", err)
 	}
 	plog.Debug("created/updated secret", "secret", klog.KObj(secret))
+	if changed {
+		ctx.Recorder.Eventf(federationDomain, nil, corev1.EventTypeNormal, "JWKSGenerated", "GenerateJWKS",
+			"generated a new signing key in Secret %q", secret.Name)
+	}
 
 	// Ensure that the FederationDomain points to the secret.
 	newFederationDomain := federationDomain.DeepCopy()
@@ -248,12 +265,14 @@ func (c *jwksWriterController) generateSecret(federationDomain *configv1alpha1.F
 	return &s, nil
 }
 
+// createOrUpdateSecret returns true when it created the Secret or replaced its invalid data.
 func (c *jwksWriterController) createOrUpdateSecret(
 	ctx context.Context,
 	newSecret *corev1.Secret,
-) error {
+) (bool, error) {
 	secretClient := c.kubeClient.CoreV1().Secrets(newSecret.Namespace)
-	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+	changed := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		oldSecret, err := secretClient.Get(ctx, newSecret.Name, metav1.GetOptions{})
 		notFound := k8serrors.IsNotFound(err)
 		if err != nil && !notFound {
@@ -266,6 +285,7 @@ func (c *jwksWriterController) createOrUpdateSecret(
 			if err != nil {
 				return fmt.Errorf("cannot create secret: %w", err)
 			}
+			changed = true
 			return nil
 		}
 
@@ -278,9 +298,13 @@ func (c *jwksWriterController) createOrUpdateSecret(
 
 		oldSecret.Data = newSecret.Data
 		oldSecret.Type = jwksSecretTypeValue
-		_, err = secretClient.Update(ctx, oldSecret, metav1.UpdateOptions{})
-		return err
+		if _, err := secretClient.Update(ctx, oldSecret, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		changed = true
+		return nil
 	})
+	return changed, err
 }
 
 func (c *jwksWriterController) updateFederationDomainStatus(
@@ -305,6 +329,62 @@ func (c *jwksWriterController) updateFederationDomainStatus(
 	})
 }
 
+// RotateJWKS replaces the active JWK in the named JWKS Secret of a FederationDomain with a newly generated key, and
+// returns the key ID of the new key. The JWKS keeps the public key of the previously active JWK, so that the tokens
+// which it signed can still be verified until they expire, but any older keys are dropped. The JWKSObserverController
+// starts using the new key as soon as it sees the updated Secret.
+func RotateJWKS(ctx context.Context, secrets corev1client.SecretInterface, secretName string) (string, error) {
+	var newKeyID string
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := secrets.Get(ctx, secretName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("cannot get secret: %w", err)
+		}
+		if !isValid(secret) {
+			return fmt.Errorf("secret %s does not contain a valid JWKS", secretName)
+		}
+
+		var previousJWK jose.JSONWebKey
+		if err := json.Unmarshal(secret.Data[activeJWKKey], &previousJWK); err != nil {
+			return fmt.Errorf("cannot unmarshal active jwk: %w", err)
+		}
+
+		key, err := generateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("cannot generate key: %w", err)
+		}
+		jwk := jose.JSONWebKey{Key: key, Algorithm: "ES256", Use: "sig"}
+		thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return fmt.Errorf("cannot compute jwk thumbprint: %w", err)
+		}
+		jwk.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+
+		jwkData, err := json.Marshal(jwk)
+		if err != nil {
+			return fmt.Errorf("cannot marshal jwk: %w", err)
+		}
+		jwksData, err := json.Marshal(jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{jwk.Public(), previousJWK.Public()},
+		})
+		if err != nil {
+			return fmt.Errorf("cannot marshal jwks: %w", err)
+		}
+
+		secret.Data[activeJWKKey] = jwkData
+		secret.Data[jwksKey] = jwksData
+		if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		newKeyID = jwk.KeyID
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return newKeyID, nil
+}
+
 // isValid returns whether the provided secret contains a valid active JWK and verification JWKS.
 func isValid(secret *corev1.Secret) bool {
 	if secret.Type != jwksSecretTypeValue {
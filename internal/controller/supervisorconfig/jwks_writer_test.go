@@ -6,7 +6,11 @@ package supervisorconfig
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"io"
@@ -15,6 +19,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -22,6 +27,7 @@ import (
 	kubeinformers "k8s.io/client-go/informers"
 	kubernetesfake "k8s.io/client-go/kubernetes/fake"
 	kubetesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/events"
 
 	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
 	pinnipedfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
@@ -187,6 +193,7 @@ func TestJWKSWriterControllerFilterSecret(t *testing.T) {
 				nil, // pinnipedClient, not needed
 				secretInformer,
 				federationDomainInformer,
+				nil,
 				withInformer.WithInformer,
 			)
 
@@ -241,6 +248,7 @@ func TestJWKSWriterControllerFilterFederationDomain(t *testing.T) {
 				nil, // pinnipedClient, not needed
 				secretInformer,
 				federationDomainInformer,
+				nil,
 				withInformer.WithInformer,
 			)
 
@@ -329,6 +337,8 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 	secretWithWrongType := newSecret("testdata/good-jwk.json", "testdata/good-jwks.json")
 	secretWithWrongType.Type = "not-the-right-type"
 
+	wantGeneratedEvents := []string{`Normal JWKSGenerated generated a new signing key in Secret "good-federationDomain-jwks"`}
+
 	tests := []struct {
 		name                        string
 		key                         controllerlib.Key
@@ -340,6 +350,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 		wantGenerateKeyCount        int
 		wantSecretActions           []kubetesting.Action
 		wantFederationDomainActions []kubetesting.Action
+		wantEvents                  []string
 		wantError                   string
 	}{
 		{
@@ -357,6 +368,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
 				kubetesting.NewUpdateSubresourceAction(federationDomainGVR, "status", namespace, goodFederationDomainWithStatus),
 			},
+			wantEvents: wantGeneratedEvents,
 		},
 		{
 			name: "federationDomain without status with existing secret",
@@ -390,6 +402,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 			wantFederationDomainActions: []kubetesting.Action{
 				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
 			},
+			wantEvents: wantGeneratedEvents,
 		},
 		{
 			name: "existing federationDomain with existing secret",
@@ -423,6 +436,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 			wantFederationDomainActions: []kubetesting.Action{
 				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
 			},
+			wantEvents: wantGeneratedEvents,
 		},
 		{
 			name: "missing jwks in secret",
@@ -441,6 +455,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 			wantFederationDomainActions: []kubetesting.Action{
 				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
 			},
+			wantEvents: wantGeneratedEvents,
 		},
 		{
 			name: "wrong type in secret",
@@ -459,6 +474,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 			wantFederationDomainActions: []kubetesting.Action{
 				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
 			},
+			wantEvents: wantGeneratedEvents,
 		},
 		{
 			name: "invalid jwk JSON in secret",
@@ -477,6 +493,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 			wantFederationDomainActions: []kubetesting.Action{
 				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
 			},
+			wantEvents: wantGeneratedEvents,
 		},
 		{
 			name: "invalid jwks JSON in secret",
@@ -495,6 +512,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 			wantFederationDomainActions: []kubetesting.Action{
 				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
 			},
+			wantEvents: wantGeneratedEvents,
 		},
 		{
 			name: "public jwk in secret",
@@ -513,6 +531,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 			wantFederationDomainActions: []kubetesting.Action{
 				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
 			},
+			wantEvents: wantGeneratedEvents,
 		},
 		{
 			name: "private jwks in secret",
@@ -531,6 +550,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 			wantFederationDomainActions: []kubetesting.Action{
 				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
 			},
+			wantEvents: wantGeneratedEvents,
 		},
 		{
 			name: "invalid jwk key in secret",
@@ -549,6 +569,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 			wantFederationDomainActions: []kubetesting.Action{
 				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
 			},
+			wantEvents: wantGeneratedEvents,
 		},
 		{
 			name: "invalid jwks key in secret",
@@ -567,6 +588,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 			wantFederationDomainActions: []kubetesting.Action{
 				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
 			},
+			wantEvents: wantGeneratedEvents,
 		},
 		{
 			name: "missing active jwks in secret",
@@ -585,6 +607,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 			wantFederationDomainActions: []kubetesting.Action{
 				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
 			},
+			wantEvents: wantGeneratedEvents,
 		},
 		{
 			name: "generate key fails",
@@ -606,7 +629,8 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 					return true, nil, errors.New("some get error")
 				})
 			},
-			wantError: "cannot create or update secret: cannot get secret: some get error",
+			wantEvents: []string{`Warning JWKSGenerationFailed could not create or update Secret "good-federationDomain-jwks": cannot get secret: some get error`},
+			wantError:  "cannot create or update secret: cannot get secret: some get error",
 		},
 		{
 			name: "create secret fails",
@@ -619,7 +643,8 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 					return true, nil, errors.New("some create error")
 				})
 			},
-			wantError: "cannot create or update secret: cannot create secret: some create error",
+			wantEvents: []string{`Warning JWKSGenerationFailed could not create or update Secret "good-federationDomain-jwks": cannot create secret: some create error`},
+			wantError:  "cannot create or update secret: cannot create secret: some create error",
 		},
 		{
 			name: "update secret fails",
@@ -635,7 +660,8 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 					return true, nil, errors.New("some update error")
 				})
 			},
-			wantError: "cannot create or update secret: some update error",
+			wantEvents: []string{`Warning JWKSGenerationFailed could not create or update Secret "good-federationDomain-jwks": some update error`},
+			wantError:  "cannot create or update secret: some update error",
 		},
 		{
 			name: "get FederationDomain fails",
@@ -648,7 +674,8 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 					return true, nil, errors.New("some get error")
 				})
 			},
-			wantError: "cannot update FederationDomain: cannot get FederationDomain: some get error",
+			wantEvents: wantGeneratedEvents,
+			wantError:  "cannot update FederationDomain: cannot get FederationDomain: some get error",
 		},
 		{
 			name: "update federationDomain fails",
@@ -661,7 +688,8 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 					return true, nil, errors.New("some update error")
 				})
 			},
-			wantError: "cannot update FederationDomain: some update error",
+			wantEvents: wantGeneratedEvents,
+			wantError:  "cannot update FederationDomain: some update error",
 		},
 	}
 	for _, test := range tests {
@@ -677,6 +705,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 			defer cancel()
 
+			recorder := events.NewFakeRecorder(10)
 			kubeAPIClient := kubernetesfake.NewSimpleClientset()
 			kubeInformerClient := kubernetesfake.NewSimpleClientset()
 			for _, secret := range test.secrets {
@@ -715,6 +744,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 				pinnipedAPIClient,
 				kubeInformers.Core().V1().Secrets(),
 				pinnipedInformers.Config().V1alpha1().FederationDomains(),
+				recorder,
 				controllerlib.WithInformer,
 			)
 
@@ -727,6 +757,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 				Context: ctx,
 				Key:     test.key,
 			})
+			require.Equal(t, test.wantEvents, recordedEvents(recorder))
 			if test.wantError != "" {
 				require.EqualError(t, err, test.wantError)
 				return
@@ -745,6 +776,78 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 	}
 }
 
+func TestRotateJWKS(t *testing.T) {
+	// We shouldn't run this test in parallel since it messes with a global function (generateKey).
+
+	const namespace = "tuna-namespace"
+
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	generateKey = func(_ io.Reader) (interface{}, error) {
+		return newKey, nil
+	}
+	t.Cleanup(func() { generateKey = generateECKey })
+
+	newSecret := func(name, activeJWKPath, jwksPath string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Type:       "secrets.pinniped.dev/federation-domain-jwks",
+			Data: map[string][]byte{
+				"activeJWK": readJWKJSON(t, activeJWKPath),
+				"jwks":      readJWKJSON(t, jwksPath),
+			},
+		}
+	}
+
+	kubeClient := kubernetesfake.NewSimpleClientset(
+		newSecret("good-jwks", "testdata/good-jwk.json", "testdata/good-jwks.json"),
+		newSecret("invalid-jwks", "testdata/public-jwk.json", "testdata/good-jwks.json"),
+	)
+	secrets := kubeClient.CoreV1().Secrets(namespace)
+	ctx := context.Background()
+
+	_, err = RotateJWKS(ctx, secrets, "missing-jwks")
+	require.EqualError(t, err, `cannot get secret: secrets "missing-jwks" not found`)
+
+	_, err = RotateJWKS(ctx, secrets, "invalid-jwks")
+	require.EqualError(t, err, "secret invalid-jwks does not contain a valid JWKS")
+
+	firstKeyID, err := RotateJWKS(ctx, secrets, "good-jwks")
+	require.NoError(t, err)
+	require.NotEqual(t, "pinniped-supervisor-key", firstKeyID)
+
+	secret, err := secrets.Get(ctx, "good-jwks", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.True(t, isValid(secret))
+
+	var activeJWK jose.JSONWebKey
+	require.NoError(t, json.Unmarshal(secret.Data["activeJWK"], &activeJWK))
+	require.Equal(t, firstKeyID, activeJWK.KeyID)
+	require.Equal(t, newKey, activeJWK.Key)
+
+	var jwks jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(secret.Data["jwks"], &jwks))
+	require.Len(t, jwks.Keys, 2)
+	require.Equal(t, firstKeyID, jwks.Keys[0].KeyID)
+	require.Equal(t, &newKey.PublicKey, jwks.Keys[0].Key)
+	require.Equal(t, "pinniped-supervisor-key", jwks.Keys[1].KeyID)
+
+	// Only the previously active key is kept in the JWKS.
+	newKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	secondKeyID, err := RotateJWKS(ctx, secrets, "good-jwks")
+	require.NoError(t, err)
+	require.NotEqual(t, firstKeyID, secondKeyID)
+
+	secret, err = secrets.Get(ctx, "good-jwks", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.True(t, isValid(secret))
+	require.NoError(t, json.Unmarshal(secret.Data["jwks"], &jwks))
+	require.Len(t, jwks.Keys, 2)
+	require.Equal(t, secondKeyID, jwks.Keys[0].KeyID)
+	require.Equal(t, firstKeyID, jwks.Keys[1].KeyID)
+}
+
 func readJWKJSON(t *testing.T, path string) []byte {
 	t.Helper()
 
@@ -4,24 +4,48 @@
 package supervisorconfig
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"net/url"
 	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/errors"
 	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/klog/v2"
 
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	pinnipedclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
 	"go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions/config/v1alpha1"
 	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/conditionsutil"
 	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/oidc/provider"
 	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/timeformat"
+)
+
+const (
+	typeIssuerHostServable = configv1alpha1.ConditionTypeIssuerHostServable
+
+	reasonSuccess            = "Success"
+	reasonNoTLSCertificate   = "NoTLSCertificate"
+	reasonHostnameMismatch   = "HostnameMismatch"
+	reasonCertificateExpired = "CertificateExpired"
 )
 
 type tlsCertObserverController struct {
 	issuerTLSCertSetter             IssuerTLSCertSetter
 	defaultTLSCertificateSecretName string
+	client                          pinnipedclientset.Interface
+	clock                           clock.Clock
 	federationDomainInformer        v1alpha1.FederationDomainInformer
 	secretInformer                  corev1informers.SecretInformer
 }
@@ -31,12 +55,19 @@ type IssuerTLSCertSetter interface {
 	SetDefaultTLSCert(certificate *tls.Certificate)
 }
 
+// NewTLSCertObserverController returns a controllerlib.Controller which loads the TLS certificates of the
+// FederationDomains and the default TLS certificate into the issuerTLSCertSetter. It also reports whether the
+// certificate which will be served for each FederationDomain's issuer hostname is valid for it, using the
+// IssuerHostServable condition.
 func NewTLSCertObserverController(
 	issuerTLSCertSetter IssuerTLSCertSetter,
 	defaultTLSCertificateSecretName string,
+	client pinnipedclientset.Interface,
+	clock clock.Clock,
 	secretInformer corev1informers.SecretInformer,
 	federationDomainInformer v1alpha1.FederationDomainInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	return controllerlib.New(
 		controllerlib.Config{
@@ -44,20 +75,24 @@ func NewTLSCertObserverController(
 			Syncer: &tlsCertObserverController{
 				issuerTLSCertSetter:             issuerTLSCertSetter,
 				defaultTLSCertificateSecretName: defaultTLSCertificateSecretName,
+				client:                          client,
+				clock:                           clock,
 				federationDomainInformer:        federationDomainInformer,
 				secretInformer:                  secretInformer,
 			},
 		},
-		withInformer(
-			secretInformer,
-			pinnipedcontroller.MatchAnySecretOfTypeFilter(v1.SecretTypeTLS, nil),
-			controllerlib.InformerOption{},
-		),
-		withInformer(
-			federationDomainInformer,
-			pinnipedcontroller.MatchAnythingFilter(nil),
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			withInformer(
+				secretInformer,
+				pinnipedcontroller.MatchAnySecretOfTypeFilter(v1.SecretTypeTLS, nil),
+				controllerlib.InformerOption{},
+			),
+			withInformer(
+				federationDomainInformer,
+				pinnipedcontroller.MatchAnythingFilter(nil),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
@@ -95,12 +130,98 @@ func (c *tlsCertObserverController) Sync(ctx controllerlib.Context) error {
 
 	defaultCert, err := c.certFromSecret(ns, c.defaultTLSCertificateSecretName)
 	if err != nil {
-		c.issuerTLSCertSetter.SetDefaultTLSCert(nil)
-	} else {
-		c.issuerTLSCertSetter.SetDefaultTLSCert(defaultCert)
+		defaultCert = nil
+	}
+	c.issuerTLSCertSetter.SetDefaultTLSCert(defaultCert)
+
+	// Report which issuer hostnames can actually be served, using the same rules as the https listener.
+	var errs []error
+	for _, federationDomain := range allProviders {
+		issuerURL, err := url.Parse(federationDomain.Spec.Issuer)
+		if err != nil || issuerURL.Hostname() == "" {
+			continue // The FederationDomain watcher controller reports invalid issuers.
+		}
+		condition := issuerHostServableCondition(
+			strings.ToLower(issuerURL.Hostname()),
+			issuerHostToTLSCertMap,
+			defaultCert,
+			c.clock.Now(),
+		)
+		if err := c.updateCondition(ctx.Context, federationDomain, condition); err != nil {
+			errs = append(errs, fmt.Errorf("could not update status of FederationDomain %s: %w", klog.KObj(federationDomain), err))
+		}
+	}
+
+	return errors.NewAggregate(errs)
+}
+
+// issuerHostServableCondition determines whether the certificate that will be served for the lowercase host is valid.
+func issuerHostServableCondition(
+	host string,
+	issuerHostToTLSCertMap map[string]*tls.Certificate,
+	defaultCert *tls.Certificate,
+	now time.Time,
+) *configv1alpha1.Condition {
+	// Clients do not send SNI server names for IP addresses.
+	serverName := host
+	if net.ParseIP(host) != nil {
+		serverName = ""
+	}
+
+	cert := provider.SelectTLSCert(serverName, issuerHostToTLSCertMap, defaultCert, now)
+	switch {
+	case cert == nil:
+		return &configv1alpha1.Condition{
+			Type:   typeIssuerHostServable,
+			Status: configv1alpha1.ConditionFalse,
+			Reason: reasonNoTLSCertificate,
+			Message: fmt.Sprintf("no TLS certificate is available for %q, so it can only be served when TLS is terminated "+
+				"before reaching the Supervisor (e.g. by an Ingress)", host),
+		}
+	case cert.Leaf.VerifyHostname(host) != nil:
+		return &configv1alpha1.Condition{
+			Type:    typeIssuerHostServable,
+			Status:  configv1alpha1.ConditionFalse,
+			Reason:  reasonHostnameMismatch,
+			Message: fmt.Sprintf("the TLS certificate for %q is not valid for that host: %s", host, cert.Leaf.VerifyHostname(host)),
+		}
+	case now.After(cert.Leaf.NotAfter):
+		return &configv1alpha1.Condition{
+			Type:    typeIssuerHostServable,
+			Status:  configv1alpha1.ConditionFalse,
+			Reason:  reasonCertificateExpired,
+			Message: fmt.Sprintf("the TLS certificate for %q expired at %s", host, timeformat.RFC3339(cert.Leaf.NotAfter)),
+		}
+	default:
+		return &configv1alpha1.Condition{
+			Type:    typeIssuerHostServable,
+			Status:  configv1alpha1.ConditionTrue,
+			Reason:  reasonSuccess,
+			Message: fmt.Sprintf("the TLS certificate for %q is valid until %s", host, timeformat.RFC3339(cert.Leaf.NotAfter)),
+		}
 	}
+}
 
-	return nil
+func (c *tlsCertObserverController) updateCondition(
+	ctx context.Context,
+	federationDomain *configv1alpha1.FederationDomain,
+	condition *configv1alpha1.Condition,
+) error {
+	merged := conditionsutil.FromConfigV1alpha1(federationDomain.Status.Conditions)
+	newConditions := conditionsutil.FromConfigV1alpha1([]configv1alpha1.Condition{*condition})
+	if !conditionsutil.MergeWithReady(&merged, federationDomain.Generation, metav1.NewTime(c.clock.Now()), newConditions...) {
+		return nil
+	}
+	updated := federationDomain.DeepCopy()
+	updated.Status.Conditions = conditionsutil.ToConfigV1alpha1(merged)
+	plog.Debug("updating FederationDomain condition",
+		"federationdomain", klog.KObj(federationDomain),
+		"type", condition.Type,
+		"status", condition.Status,
+		"reason", condition.Reason,
+	)
+	_, err := c.client.ConfigV1alpha1().FederationDomains(federationDomain.Namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	return err
 }
 
 func (c *tlsCertObserverController) certFromSecret(ns string, secretName string) (*tls.Certificate, error) {
@@ -114,6 +235,12 @@ func (c *tlsCertObserverController) certFromSecret(ns string, secretName string)
 		plog.Debug("tlsCertObserverController Sync found a TLS secret with Data in an unexpected format", "namespace", ns, "secretName", secretName)
 		return nil, err
 	}
+	// Parse the leaf so that the certificate can be matched against hostnames.
+	certFromSecret.Leaf, err = x509.ParseCertificate(certFromSecret.Certificate[0])
+	if err != nil {
+		plog.Debug("tlsCertObserverController Sync found a TLS secret with an unparsable certificate", "namespace", ns, "secretName", secretName)
+		return nil, err
+	}
 	return &certFromSecret, nil
 }
 
@@ -122,3 +249,4 @@ func lowercaseHostWithoutPort(issuerURL *url.URL) string {
 	colonSegments := strings.Split(lowercaseHost, ":")
 	return colonSegments[0]
 }
+
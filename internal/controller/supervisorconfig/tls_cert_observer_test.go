@@ -6,7 +6,10 @@ package supervisorconfig
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"io/ioutil"
+	"net"
 	"net/url"
 	"testing"
 	"time"
@@ -16,12 +19,15 @@ import (
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
 	kubeinformers "k8s.io/client-go/informers"
 	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	coretesting "k8s.io/client-go/testing"
 
 	"go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
 	pinnipedfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
 	pinnipedinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions"
+	"go.pinniped.dev/internal/certauthority"
 	"go.pinniped.dev/internal/controllerlib"
 	"go.pinniped.dev/internal/testutil"
 )
@@ -43,6 +49,8 @@ func TestTLSCertObserverControllerInformerFilters(t *testing.T) {
 			_ = NewTLSCertObserverController(
 				nil,
 				"", // don't care about the secret name for this test
+				nil,
+				nil,
 				secretsInformer,
 				federationDomainInformer,
 				observableWithInformerOption.WithInformer, // make it possible to observe the behavior of the Filters
@@ -133,6 +141,7 @@ func TestTLSCertObserverControllerSync(t *testing.T) {
 			r                      *require.Assertions
 			subject                controllerlib.Controller
 			pinnipedInformerClient *pinnipedfake.Clientset
+			pinnipedAPIClient      *pinnipedfake.Clientset
 			kubeInformerClient     *kubernetesfake.Clientset
 			pinnipedInformers      pinnipedinformers.SharedInformerFactory
 			kubeInformers          kubeinformers.SharedInformerFactory
@@ -140,6 +149,8 @@ func TestTLSCertObserverControllerSync(t *testing.T) {
 			timeoutContextCancel   context.CancelFunc
 			syncContext            *controllerlib.Context
 			issuerTLSCertSetter    *fakeIssuerTLSCertSetter
+			frozenNow              time.Time
+			frozenClock            *clock.FakeClock
 		)
 
 		// Defer starting the informers until the last possible moment so that the
@@ -149,6 +160,8 @@ func TestTLSCertObserverControllerSync(t *testing.T) {
 			subject = NewTLSCertObserverController(
 				issuerTLSCertSetter,
 				defaultTLSSecretName,
+				pinnipedAPIClient,
+				frozenClock,
 				kubeInformers.Core().V1().Secrets(),
 				pinnipedInformers.Config().V1alpha1().FederationDomains(),
 				controllerlib.WithInformer,
@@ -176,6 +189,35 @@ func TestTLSCertObserverControllerSync(t *testing.T) {
 			return data
 		}
 
+		var loadKeyPair = func(certPEM, keyPEM []byte) tls.Certificate {
+			cert, err := tls.X509KeyPair(certPEM, keyPEM)
+			r.NoError(err)
+			cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+			r.NoError(err)
+			return cert
+		}
+
+		var addFederationDomain = func(federationDomain *v1alpha1.FederationDomain) {
+			r.NoError(pinnipedInformerClient.Tracker().Add(federationDomain))
+			r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+		}
+
+		var getCondition = func(federationDomainName, conditionType string) *v1alpha1.Condition {
+			federationDomain, err := pinnipedAPIClient.ConfigV1alpha1().FederationDomains(installedInNamespace).
+				Get(context.Background(), federationDomainName, metav1.GetOptions{})
+			r.NoError(err)
+			for i := range federationDomain.Status.Conditions {
+				if federationDomain.Status.Conditions[i].Type == conditionType {
+					return &federationDomain.Status.Conditions[i]
+				}
+			}
+			return nil
+		}
+
+		var getIssuerHostServableCondition = func(federationDomainName string) *v1alpha1.Condition {
+			return getCondition(federationDomainName, "IssuerHostServable")
+		}
+
 		it.Before(func() {
 			r = require.New(t)
 
@@ -185,7 +227,10 @@ func TestTLSCertObserverControllerSync(t *testing.T) {
 			kubeInformers = kubeinformers.NewSharedInformerFactory(kubeInformerClient, 0)
 			pinnipedInformerClient = pinnipedfake.NewSimpleClientset()
 			pinnipedInformers = pinnipedinformers.NewSharedInformerFactory(pinnipedInformerClient, 0)
+			pinnipedAPIClient = pinnipedfake.NewSimpleClientset()
 			issuerTLSCertSetter = &fakeIssuerTLSCertSetter{}
+			frozenNow = time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+			frozenClock = clock.NewFakeClock(frozenNow)
 
 			unrelatedSecret := &corev1.Secret{
 				ObjectMeta: metav1.ObjectMeta{
@@ -288,10 +333,8 @@ func TestTLSCertObserverControllerSync(t *testing.T) {
 				r.NotEmpty(testKey1)
 				testKey2 := readTestFile("testdata/test2.key")
 				r.NotEmpty(testKey2)
-				expectedCertificate1, err = tls.X509KeyPair(testCrt1, testKey1)
-				r.NoError(err)
-				expectedCertificate2, err = tls.X509KeyPair(testCrt2, testKey2)
-				r.NoError(err)
+				expectedCertificate1 = loadKeyPair(testCrt1, testKey1)
+				expectedCertificate2 = loadKeyPair(testCrt2, testKey2)
 				goodTLSSecret1 := &corev1.Secret{
 					ObjectMeta: metav1.ObjectMeta{Name: "good-tls-secret-name1", Namespace: installedInNamespace},
 					Data:       map[string][]byte{"tls.crt": testCrt1, "tls.key": testKey1},
@@ -304,12 +347,12 @@ func TestTLSCertObserverControllerSync(t *testing.T) {
 					ObjectMeta: metav1.ObjectMeta{Name: "bad-tls-secret-name", Namespace: installedInNamespace},
 					Data:       map[string][]byte{"junk": nil},
 				}
-				r.NoError(pinnipedInformerClient.Tracker().Add(federationDomainWithoutSecret1))
-				r.NoError(pinnipedInformerClient.Tracker().Add(federationDomainWithoutSecret2))
-				r.NoError(pinnipedInformerClient.Tracker().Add(federationDomainWithBadSecret))
-				r.NoError(pinnipedInformerClient.Tracker().Add(federationDomainWithBadIssuer))
-				r.NoError(pinnipedInformerClient.Tracker().Add(federationDomainWithGoodSecret1))
-				r.NoError(pinnipedInformerClient.Tracker().Add(federationDomainWithGoodSecret2))
+				addFederationDomain(federationDomainWithoutSecret1)
+				addFederationDomain(federationDomainWithoutSecret2)
+				addFederationDomain(federationDomainWithBadSecret)
+				addFederationDomain(federationDomainWithBadIssuer)
+				addFederationDomain(federationDomainWithGoodSecret1)
+				addFederationDomain(federationDomainWithGoodSecret2)
 				r.NoError(kubeInformerClient.Tracker().Add(goodTLSSecret1))
 				r.NoError(kubeInformerClient.Tracker().Add(goodTLSSecret2))
 				r.NoError(kubeInformerClient.Tracker().Add(badTLSSecret))
@@ -337,19 +380,39 @@ func TestTLSCertObserverControllerSync(t *testing.T) {
 				r.Equal(expectedCertificate2, *actualCertificate2)
 			})
 
+			it("reports whether each issuer host can be served", func() {
+				startInformersAndController()
+				r.NoError(controllerlib.TestSync(t, subject, *syncContext))
+
+				for _, name := range []string{"no-secret-federationdomain1", "no-secret-federationdomain2", "bad-secret-federationdomain"} {
+					condition := getIssuerHostServableCondition(name)
+					r.NotNil(condition, name)
+					r.Equal(v1alpha1.ConditionFalse, condition.Status, name)
+					r.Equal("NoTLSCertificate", condition.Reason, name)
+				}
+
+				// The test fixture certs do not have any SANs, so they are not valid for any hostname.
+				condition := getIssuerHostServableCondition("good-secret-federationdomain1")
+				r.NotNil(condition)
+				r.Equal(v1alpha1.ConditionFalse, condition.Status)
+				r.Equal("HostnameMismatch", condition.Reason)
+				r.Equal(metav1.NewTime(frozenNow), condition.LastTransitionTime)
+
+				// Invalid issuers are not given a condition.
+				r.Nil(getIssuerHostServableCondition("bad-issuer-federationdomain"))
+			})
+
 			when("there is also a default TLS cert secret with the configured default TLS cert secret name", func() {
 				var (
 					expectedDefaultCertificate tls.Certificate
 				)
 
 				it.Before(func() {
-					var err error
 					testCrt := readTestFile("testdata/test3.crt")
 					r.NotEmpty(testCrt)
 					testKey := readTestFile("testdata/test3.key")
 					r.NotEmpty(testKey)
-					expectedDefaultCertificate, err = tls.X509KeyPair(testCrt, testKey)
-					r.NoError(err)
+					expectedDefaultCertificate = loadKeyPair(testCrt, testKey)
 					defaultTLSCertSecret := &corev1.Secret{
 						ObjectMeta: metav1.ObjectMeta{Name: defaultTLSSecretName, Namespace: installedInNamespace},
 						Data:       map[string][]byte{"tls.crt": testCrt, "tls.key": testKey},
@@ -371,5 +434,98 @@ func TestTLSCertObserverControllerSync(t *testing.T) {
 				})
 			})
 		})
+
+		when("there are FederationDomains whose hosts are only covered by other certificates", func() {
+			var ca *certauthority.CA
+
+			var addTLSSecret = func(name string, dnsNames []string, ips []net.IP, ttl time.Duration) {
+				cert, err := ca.Issue(pkix.Name{CommonName: name}, dnsNames, ips, ttl)
+				r.NoError(err)
+				certPEM, keyPEM, err := certauthority.ToPEM(cert)
+				r.NoError(err)
+				r.NoError(kubeInformerClient.Tracker().Add(&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: installedInNamespace},
+					Data:       map[string][]byte{"tls.crt": certPEM, "tls.key": keyPEM},
+				}))
+			}
+
+			var addFederationDomainForIssuer = func(name, issuer, secretName string) {
+				federationDomain := &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: installedInNamespace, Generation: 42},
+					Spec:       v1alpha1.FederationDomainSpec{Issuer: issuer},
+				}
+				if secretName != "" {
+					federationDomain.Spec.TLS = &v1alpha1.FederationDomainTLSSpec{SecretName: secretName}
+				}
+				addFederationDomain(federationDomain)
+			}
+
+			it.Before(func() {
+				var err error
+				ca, err = certauthority.New(pkix.Name{CommonName: "test-ca"}, 100*365*24*time.Hour)
+				r.NoError(err)
+
+				addTLSSecret("wildcard-secret", []string{"*.example.com"}, nil, 100*365*24*time.Hour)
+				addTLSSecret("expired-secret", []string{"expired.example.com"}, nil, time.Hour)
+				addTLSSecret(defaultTLSSecretName, []string{"default.example.com"}, []net.IP{net.ParseIP("10.1.2.3")}, 100*365*24*time.Hour)
+
+				addFederationDomainForIssuer("wildcard", "https://a.example.com", "wildcard-secret")
+				addFederationDomainForIssuer("covered-by-wildcard", "https://b.example.com/issuer", "")
+				addFederationDomainForIssuer("expired", "https://expired.example.com", "expired-secret")
+				addFederationDomainForIssuer("ip-covered-by-default", "https://10.1.2.3:8443", "")
+				addFederationDomainForIssuer("not-covered", "https://other.test", "")
+			})
+
+			it("reports which issuer hosts are servable using the same SNI fallback as the https listener", func() {
+				startInformersAndController()
+				r.NoError(controllerlib.TestSync(t, subject, *syncContext))
+
+				for _, name := range []string{"wildcard", "covered-by-wildcard", "ip-covered-by-default"} {
+					condition := getIssuerHostServableCondition(name)
+					r.NotNil(condition, name)
+					r.Equal(v1alpha1.ConditionTrue, condition.Status, name)
+					r.Equal("Success", condition.Reason, name)
+					r.Equal(int64(42), condition.ObservedGeneration, name)
+					r.Equal(v1alpha1.ConditionTrue, getCondition(name, "Ready").Status, name)
+				}
+				r.Contains(getIssuerHostServableCondition("covered-by-wildcard").Message, `"b.example.com" is valid until`)
+
+				condition := getIssuerHostServableCondition("expired")
+				r.Equal(v1alpha1.ConditionFalse, condition.Status)
+				r.Equal("CertificateExpired", condition.Reason)
+
+				condition = getIssuerHostServableCondition("not-covered")
+				r.Equal(v1alpha1.ConditionFalse, condition.Status)
+				r.Equal("HostnameMismatch", condition.Reason)
+				r.Equal(`the TLS certificate for "other.test" is not valid for that host: `+
+					`x509: certificate is valid for default.example.com, not other.test`, condition.Message)
+
+				condition = getCondition("not-covered", "Ready")
+				r.Equal(v1alpha1.ConditionFalse, condition.Status)
+				r.Equal("HostnameMismatch", condition.Reason)
+			})
+
+			it("does not update the FederationDomains when the conditions have not changed", func() {
+				startInformersAndController()
+				r.NoError(controllerlib.TestSync(t, subject, *syncContext))
+				r.Len(pinnipedAPIClient.Actions(), 5)
+
+				// Feed the updated objects back into the informer, as would happen in a real cluster.
+				for _, action := range pinnipedAPIClient.Actions() {
+					updated := action.(coretesting.UpdateAction).GetObject()
+					r.NoError(pinnipedInformerClient.Tracker().Update(v1alpha1.SchemeGroupVersion.WithResource("federationdomains"), updated, installedInNamespace))
+				}
+				r.Eventually(func() bool {
+					federationDomain, err := pinnipedInformers.Config().V1alpha1().FederationDomains().Lister().
+						FederationDomains(installedInNamespace).Get("not-covered")
+					return err == nil && len(federationDomain.Status.Conditions) == 2
+				}, 3*time.Second, 10*time.Millisecond)
+
+				frozenClock.Step(time.Minute)
+				pinnipedAPIClient.ClearActions()
+				r.NoError(controllerlib.TestSync(t, subject, *syncContext))
+				r.Empty(pinnipedAPIClient.Actions())
+			})
+		})
 	}, spec.Parallel(), spec.Report(report.Terminal{}))
 }
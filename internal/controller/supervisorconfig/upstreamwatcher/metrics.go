@@ -0,0 +1,68 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamwatcher
+
+import (
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+)
+
+//nolint: gochecknoglobals
+var (
+	discoveryDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Name:           "pinniped_supervisor_upstream_oidc_discovery_duration_seconds",
+			Help:           "Duration of the OIDC discovery requests to each upstream OIDCIdentityProvider, including failed requests.",
+			Buckets:        metrics.ExponentialBuckets(0.05, 2, 10),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "name"},
+	)
+	discoveryConsecutiveFailures = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name:           "pinniped_supervisor_upstream_oidc_discovery_consecutive_failures",
+			Help:           "Number of consecutive validations of each upstream OIDCIdentityProvider in which OIDC discovery failed.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "name"},
+	)
+	discoveryLastSuccess = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name:           "pinniped_supervisor_upstream_oidc_discovery_last_success_timestamp_seconds",
+			Help:           "Unix time of the last validation of each upstream OIDCIdentityProvider in which OIDC discovery succeeded, including when the discovery response was cached.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "name"},
+	)
+)
+
+//nolint: gochecknoinits
+func init() {
+	legacyregistry.MustRegister(discoveryDuration, discoveryConsecutiveFailures, discoveryLastSuccess)
+}
+
+func observeDiscoveryDuration(upstream *v1alpha1.OIDCIdentityProvider, duration time.Duration) {
+	discoveryDuration.WithLabelValues(upstream.Namespace, upstream.Name).Observe(duration.Seconds())
+}
+
+func recordDiscoveryResult(upstream *v1alpha1.OIDCIdentityProvider, succeeded bool, now time.Time) {
+	if !succeeded {
+		discoveryConsecutiveFailures.WithLabelValues(upstream.Namespace, upstream.Name).Inc()
+		return
+	}
+	discoveryConsecutiveFailures.WithLabelValues(upstream.Namespace, upstream.Name).Set(0)
+	discoveryLastSuccess.WithLabelValues(upstream.Namespace, upstream.Name).Set(float64(now.Unix()))
+}
+
+// forgetUpstreamMetrics deletes the metrics of an upstream which no longer exists.
+func forgetUpstreamMetrics(namespace, name string) {
+	labels := map[string]string{"namespace": namespace, "name": name}
+	discoveryDuration.Delete(labels)
+	discoveryConsecutiveFailures.Delete(labels)
+	discoveryLastSuccess.Delete(labels)
+}
@@ -0,0 +1,36 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamwatcher
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	"go.pinniped.dev/internal/supervisormetrics"
+)
+
+func TestDiscoveryMetricsAreServedByTheMetricsEndpoint(t *testing.T) {
+	upstream := &v1alpha1.OIDCIdentityProvider{ObjectMeta: metav1.ObjectMeta{Namespace: "metrics-test-namespace", Name: "metrics-test-name"}}
+	defer forgetUpstreamMetrics(upstream.Namespace, upstream.Name)
+
+	observeDiscoveryDuration(upstream, time.Second)
+	recordDiscoveryResult(upstream, false, time.Now())
+
+	rr := httptest.NewRecorder()
+	supervisormetrics.NewHandler(nil).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	body, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err)
+
+	labels := `{name="metrics-test-name",namespace="metrics-test-namespace"}`
+	require.Contains(t, string(body), "pinniped_supervisor_upstream_oidc_discovery_duration_seconds_count"+labels+" 1")
+	require.Contains(t, string(body), "pinniped_supervisor_upstream_oidc_discovery_consecutive_failures"+labels+" 1")
+}
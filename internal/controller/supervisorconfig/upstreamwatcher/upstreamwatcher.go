@@ -9,6 +9,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -22,16 +23,21 @@ import (
 	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/cache"
 	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/client-go/util/workqueue"
 
 	"go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
 	pinnipedclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
 	idpinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions/idp/v1alpha1"
 	"go.pinniped.dev/internal/constable"
 	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/conditionsutil"
 	"go.pinniped.dev/internal/controllerlib"
 	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/oidc/usernametemplate"
 	"go.pinniped.dev/internal/upstreamoidc"
 )
 
@@ -45,19 +51,33 @@ const (
 	clientIDDataKey     = "clientID"
 	clientSecretDataKey = "clientSecret"
 
+	// Constants related to the Secret of ID token decryption keys.
+	oidcClientDecryptionKeysSecretType corev1.SecretType = "secrets.pinniped.dev/oidc-client-decryption-keys"
+
+	decryptionKeysDataKey = "keys"
+
 	// Constants related to the OIDC provider discovery cache. These do not affect the cache of JWKS.
 	validatorCacheTTL = 15 * time.Minute
 
+	// Constants related to validating many upstreams. Each upstream is validated independently, so that a slow or
+	// unreachable issuer only delays its own validation.
+	maxConcurrentValidations    = 10
+	discoveryTimeout            = 30 * time.Second
+	failedUpstreamRetryInterval = time.Minute
+
 	// Constants related to conditions.
-	typeClientCredsValid       = "ClientCredentialsValid"
-	typeOIDCDiscoverySucceeded = "OIDCDiscoverySucceeded"
+	typeClaimsValid            = v1alpha1.ConditionTypeClaimsValid
+	typeClientCredsValid       = v1alpha1.ConditionTypeClientCredentialsValid
+	typeOIDCDiscoverySucceeded = v1alpha1.ConditionTypeOIDCDiscoverySucceeded
 	reasonNotFound             = "SecretNotFound"
 	reasonWrongType            = "SecretWrongType"
 	reasonMissingKeys          = "SecretMissingKeys"
+	reasonInvalidKeys          = "SecretInvalidKeys"
 	reasonSuccess              = "Success"
 	reasonUnreachable          = "Unreachable"
 	reasonInvalidTLSConfig     = "InvalidTLSConfig"
 	reasonInvalidResponse      = "InvalidResponse"
+	reasonInvalidClaims        = "InvalidClaimsConfig"
 
 	// Errors that are generated by our reconcile process.
 	errFailureStatus  = constable.Error("OIDCIdentityProvider has a failing condition")
@@ -98,6 +118,19 @@ func (c *lruValidatorCache) cacheKey(spec *v1alpha1.OIDCIdentityProviderSpec) in
 	return key
 }
 
+// retryFailedUpstreamsKey is the queue key of a sync which only revalidates the upstreams that failed validation during
+// the previous sync. All other events use the empty key.
+//nolint: gochecknoglobals
+var retryFailedUpstreamsKey = controllerlib.Key{Name: "retry-failed-upstreams"}
+
+// validationResult is the outcome of validating one generation of an upstream. The valid config is nil when the
+// upstream failed validation.
+type validationResult struct {
+	namespace, name string
+	generation      int64
+	valid           *upstreamoidc.ProviderConfig
+}
+
 type controller struct {
 	cache                        IDPCache
 	log                          logr.Logger
@@ -108,16 +141,23 @@ type controller struct {
 		getProvider(*v1alpha1.OIDCIdentityProviderSpec) (*oidc.Provider, *http.Client)
 		putProvider(*v1alpha1.OIDCIdentityProviderSpec, *oidc.Provider, *http.Client)
 	}
+
+	// lastResults holds the results of the previous sync, by upstream UID. It is only used by the controller's
+	// single worker.
+	lastResults map[types.UID]validationResult
 }
 
-// New instantiates a new controllerlib.Controller which will populate the provided IDPCache.
+// New instantiates a new controllerlib.Controller which will populate the provided IDPCache. It records an Event
+// about each OIDCIdentityProvider whose conditions change their status.
 func New(
 	idpCache IDPCache,
 	client pinnipedclientset.Interface,
 	oidcIdentityProviderInformer idpinformers.OIDCIdentityProviderInformer,
 	secretInformer corev1informers.SecretInformer,
+	recorder events.EventRecorder,
 	log logr.Logger,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	c := controller{
 		cache:                        idpCache,
@@ -129,16 +169,22 @@ func New(
 	}
 	return controllerlib.New(
 		controllerlib.Config{Name: controllerName, Syncer: &c},
-		withInformer(
-			oidcIdentityProviderInformer,
-			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
-			controllerlib.InformerOption{},
-		),
-		withInformer(
-			secretInformer,
-			pinnipedcontroller.MatchAnySecretOfTypeFilter(oidcClientSecretType, pinnipedcontroller.SingletonQueue()),
-			controllerlib.InformerOption{},
-		),
+		append([]controllerlib.Option{
+			controllerlib.WithRecorder(recorder),
+			withInformer(
+				oidcIdentityProviderInformer,
+				pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+				controllerlib.InformerOption{},
+			),
+			withInformer(
+				secretInformer,
+				pinnipedcontroller.MatchAnySecretOfTypesFilter(
+					[]corev1.SecretType{oidcClientSecretType, oidcClientDecryptionKeysSecretType},
+					pinnipedcontroller.SingletonQueue(),
+				),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
@@ -149,19 +195,55 @@ func (c *controller) Sync(ctx controllerlib.Context) error {
 		return fmt.Errorf("failed to list OIDCIdentityProviders: %w", err)
 	}
 
-	requeue := false
+	// When retrying the upstreams which failed validation, the results of the other upstreams are still current,
+	// because any change to them or to their Secrets would have caused a full sync.
+	onlyRetryFailed := ctx.Key == retryFailedUpstreamsKey
+
+	// Validate the upstreams concurrently, since OIDC discovery against each of them may be slow. The results are
+	// kept in the same order as the upstreams.
+	results := make([]*upstreamoidc.ProviderConfig, len(actualUpstreams))
+	workqueue.ParallelizeUntil(ctx.Context, maxConcurrentValidations, len(actualUpstreams), func(i int) {
+		upstream := actualUpstreams[i]
+		if previous, ok := c.lastResults[upstream.UID]; onlyRetryFailed && ok && previous.valid != nil && previous.generation == upstream.Generation {
+			results[i] = previous.valid
+			return
+		}
+		results[i] = c.validateUpstream(ctx, upstream)
+	})
+	if err := ctx.Context.Err(); err != nil {
+		// Some upstreams may not have been validated, so keep the previous list of upstreams until the next sync.
+		return err
+	}
+
+	anyFailed := false
+	lastResults := make(map[types.UID]validationResult, len(actualUpstreams))
 	validatedUpstreams := make([]provider.UpstreamOIDCIdentityProviderI, 0, len(actualUpstreams))
-	for _, upstream := range actualUpstreams {
-		valid := c.validateUpstream(ctx, upstream)
+	for i, valid := range results {
+		upstream := actualUpstreams[i]
+		lastResults[upstream.UID] = validationResult{
+			namespace:  upstream.Namespace,
+			name:       upstream.Name,
+			generation: upstream.Generation,
+			valid:      valid,
+		}
 		if valid == nil {
-			requeue = true
+			anyFailed = true
 		} else {
 			validatedUpstreams = append(validatedUpstreams, provider.UpstreamOIDCIdentityProviderI(valid))
 		}
 	}
+	for uid, previous := range c.lastResults {
+		if _, ok := lastResults[uid]; !ok {
+			forgetUpstreamMetrics(previous.namespace, previous.name)
+		}
+	}
+	c.lastResults = lastResults
 	c.cache.SetIDPList(validatedUpstreams)
-	if requeue {
-		return controllerlib.ErrSyntheticRequeue
+
+	// A failing upstream has already reported the problem in its own status, so rather than failing the whole sync,
+	// which would immediately revalidate every upstream, only the failed upstreams are retried after a while.
+	if anyFailed {
+		ctx.Queue.AddAfter(retryFailedUpstreamsKey, failedUpstreamRetryInterval)
 	}
 	return nil
 }
@@ -170,18 +252,28 @@ func (c *controller) Sync(ctx controllerlib.Context) error {
 // provider.UpstreamOIDCIdentityProvider. As a side effect, it also updates the status of the v1alpha1.OIDCIdentityProvider.
 func (c *controller) validateUpstream(ctx controllerlib.Context, upstream *v1alpha1.OIDCIdentityProvider) *upstreamoidc.ProviderConfig {
 	result := upstreamoidc.ProviderConfig{
-		Name: upstream.Name,
+		Name:   upstream.Name,
+		Issuer: upstream.Spec.Issuer,
 		Config: &oauth2.Config{
 			Scopes: computeScopes(upstream.Spec.AuthorizationConfig.AdditionalScopes),
 		},
-		UsernameClaim: upstream.Spec.Claims.Username,
-		GroupsClaim:   upstream.Spec.Claims.Groups,
-	}
+		UsernameClaim:                upstream.Spec.Claims.Username,
+		GroupsClaim:                  upstream.Spec.Claims.Groups,
+		UIDClaim:                     upstream.Spec.Claims.UID,
+		RequireAccessTokenHash:       upstream.Spec.TokenValidation.RequireAccessTokenHash,
+		RequireAuthorizationCodeHash: upstream.Spec.TokenValidation.RequireAuthorizationCodeHash,
+		RequireIssuerParameter:       upstream.Spec.TokenValidation.RequireIssuerParameter,
+		ClockSkewTolerance:           time.Duration(upstream.Spec.TokenValidation.ClockSkewToleranceSeconds) * time.Second,
+		AllowPasswordGrant:           upstream.Spec.AuthorizationConfig.AllowPasswordGrant,
+	}
+	discoveryCondition := c.validateIssuer(ctx.Context, upstream, &result)
+	recordDiscoveryResult(upstream, discoveryCondition.Status == v1alpha1.ConditionTrue, time.Now())
 	conditions := []*v1alpha1.Condition{
+		validateClaims(upstream, &result),
 		c.validateSecret(upstream, &result),
-		c.validateIssuer(ctx.Context, upstream, &result),
+		discoveryCondition,
 	}
-	c.updateStatus(ctx.Context, upstream, conditions)
+	c.updateStatus(ctx, upstream, conditions)
 
 	valid := true
 	log := c.log.WithValues("namespace", upstream.Namespace, "name", upstream.Name)
@@ -201,6 +293,37 @@ func (c *controller) validateUpstream(ctx controllerlib.Context, upstream *v1alp
 	return nil
 }
 
+// validateClaims validates the .spec.claims field and returns the appropriate ClaimsValid condition.
+func validateClaims(upstream *v1alpha1.OIDCIdentityProvider, result *upstreamoidc.ProviderConfig) *v1alpha1.Condition {
+	claims := upstream.Spec.Claims
+	if claims.UsernameTemplate != "" {
+		if claims.Username != "" {
+			return &v1alpha1.Condition{
+				Type:    typeClaimsValid,
+				Status:  v1alpha1.ConditionFalse,
+				Reason:  reasonInvalidClaims,
+				Message: "claims.username and claims.usernameTemplate cannot both be specified",
+			}
+		}
+		usernameTemplate, err := usernametemplate.Parse(claims.UsernameTemplate)
+		if err != nil {
+			return &v1alpha1.Condition{
+				Type:    typeClaimsValid,
+				Status:  v1alpha1.ConditionFalse,
+				Reason:  reasonInvalidClaims,
+				Message: fmt.Sprintf("invalid claims.usernameTemplate: %s", err.Error()),
+			}
+		}
+		result.UsernameTemplate = usernameTemplate
+	}
+	return &v1alpha1.Condition{
+		Type:    typeClaimsValid,
+		Status:  v1alpha1.ConditionTrue,
+		Reason:  reasonSuccess,
+		Message: "claims configuration is valid",
+	}
+}
+
 // validateSecret validates the .spec.client.secretName field and returns the appropriate ClientCredentialsValid condition.
 func (c *controller) validateSecret(upstream *v1alpha1.OIDCIdentityProvider, result *upstreamoidc.ProviderConfig) *v1alpha1.Condition {
 	secretName := upstream.Spec.Client.SecretName
@@ -238,6 +361,16 @@ func (c *controller) validateSecret(upstream *v1alpha1.OIDCIdentityProvider, res
 		}
 	}
 
+	message := "loaded client credentials"
+	if upstream.Spec.Client.DecryptionKeySecretName != "" {
+		keys, condition := c.loadDecryptionKeys(upstream.Namespace, upstream.Spec.Client.DecryptionKeySecretName)
+		if condition != nil {
+			return condition
+		}
+		result.DecryptionKeys = keys
+		message = "loaded client credentials and ID token decryption keys"
+	}
+
 	// If everything is valid, update the result and set the condition to true.
 	result.Config.ClientID = string(clientID)
 	result.Config.ClientSecret = string(clientSecret)
@@ -245,10 +378,83 @@ func (c *controller) validateSecret(upstream *v1alpha1.OIDCIdentityProvider, res
 		Type:    typeClientCredsValid,
 		Status:  v1alpha1.ConditionTrue,
 		Reason:  reasonSuccess,
-		Message: "loaded client credentials",
+		Message: message,
 	}
 }
 
+// loadDecryptionKeys reads the private keys which decrypt encrypted ID tokens from the named Secret, or returns a
+// false ClientCredentialsValid condition when they cannot be loaded.
+func (c *controller) loadDecryptionKeys(namespace, secretName string) ([]interface{}, *v1alpha1.Condition) {
+	secret, err := c.secretInformer.Lister().Secrets(namespace).Get(secretName)
+	if err != nil {
+		return nil, &v1alpha1.Condition{
+			Type:    typeClientCredsValid,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  reasonNotFound,
+			Message: err.Error(),
+		}
+	}
+	if secret.Type != oidcClientDecryptionKeysSecretType {
+		return nil, &v1alpha1.Condition{
+			Type:    typeClientCredsValid,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  reasonWrongType,
+			Message: fmt.Sprintf("referenced Secret %q has wrong type %q (should be %q)", secretName, secret.Type, oidcClientDecryptionKeysSecretType),
+		}
+	}
+	pemKeys := secret.Data[decryptionKeysDataKey]
+	if len(pemKeys) == 0 {
+		return nil, &v1alpha1.Condition{
+			Type:    typeClientCredsValid,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  reasonMissingKeys,
+			Message: fmt.Sprintf("referenced Secret %q is missing required keys %q", secretName, []string{decryptionKeysDataKey}),
+		}
+	}
+	keys, err := parsePrivateKeys(pemKeys)
+	if err != nil {
+		return nil, &v1alpha1.Condition{
+			Type:    typeClientCredsValid,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  reasonInvalidKeys,
+			Message: fmt.Sprintf("referenced Secret %q has invalid decryption keys: %v", secretName, err),
+		}
+	}
+	return keys, nil
+}
+
+// parsePrivateKeys parses each PEM block of the input as a PKCS #1, PKCS #8, or SEC 1 private key.
+func parsePrivateKeys(pemKeys []byte) ([]interface{}, error) {
+	var keys []interface{}
+	for {
+		var block *pem.Block
+		block, pemKeys = pem.Decode(pemKeys)
+		if block == nil {
+			break
+		}
+		var key interface{}
+		var err error
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		default:
+			err = fmt.Errorf("unsupported PEM block type %q", block.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", len(keys), err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, constable.Error("no PEM-encoded private keys found")
+	}
+	return keys, nil
+}
+
 // validateIssuer validates the .spec.issuer field, performs OIDC discovery, and returns the appropriate OIDCDiscoverySucceeded condition.
 func (c *controller) validateIssuer(ctx context.Context, upstream *v1alpha1.OIDCIdentityProvider, result *upstreamoidc.ProviderConfig) *v1alpha1.Condition {
 	// Get the provider and HTTP Client from cache if possible.
@@ -267,7 +473,11 @@ func (c *controller) validateIssuer(ctx context.Context, upstream *v1alpha1.OIDC
 		}
 		httpClient = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
 
-		discoveredProvider, err = oidc.NewProvider(oidc.ClientContext(ctx, httpClient), upstream.Spec.Issuer)
+		discoveryCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+		defer cancel()
+		start := time.Now()
+		discoveredProvider, err = oidc.NewProvider(oidc.ClientContext(discoveryCtx, httpClient), upstream.Spec.Issuer)
+		observeDiscoveryDuration(upstream, time.Since(start))
 		if err != nil {
 			return &v1alpha1.Condition{
 				Type:    typeOIDCDiscoverySucceeded,
@@ -334,27 +544,27 @@ func getTLSConfig(upstream *v1alpha1.OIDCIdentityProvider) (*tls.Config, error)
 	return &result, nil
 }
 
-func (c *controller) updateStatus(ctx context.Context, upstream *v1alpha1.OIDCIdentityProvider, conditions []*v1alpha1.Condition) {
+func (c *controller) updateStatus(ctx controllerlib.Context, upstream *v1alpha1.OIDCIdentityProvider, conditions []*v1alpha1.Condition) {
 	log := c.log.WithValues("namespace", upstream.Namespace, "name", upstream.Name)
 	updated := upstream.DeepCopy()
 
 	updated.Status.Phase = v1alpha1.PhaseReady
 
-	for i := range conditions {
-		cond := conditions[i].DeepCopy()
-		cond.LastTransitionTime = metav1.Now()
-		cond.ObservedGeneration = upstream.Generation
-		if mergeCondition(&updated.Status.Conditions, cond) {
+	merged := conditionsutil.FromIDPV1alpha1(updated.Status.Conditions)
+	now := metav1.Now()
+	for _, cond := range conditionsutil.FromIDPV1alpha1(conditionValues(conditions)) {
+		if conditionsutil.Merge(&merged, upstream.Generation, now, cond) {
 			log.Info("updated condition", "type", cond.Type, "status", cond.Status, "reason", cond.Reason, "message", cond.Message)
 		}
-		if cond.Status == v1alpha1.ConditionFalse {
+		if cond.Status == metav1.ConditionFalse {
 			updated.Status.Phase = v1alpha1.PhaseError
 		}
 	}
-
-	sort.SliceStable(updated.Status.Conditions, func(i, j int) bool {
-		return updated.Status.Conditions[i].Type < updated.Status.Conditions[j].Type
-	})
+	if conditionsutil.MergeWithReady(&merged, upstream.Generation, now) {
+		ready := conditionsutil.Find(merged, v1alpha1.ConditionTypeReady)
+		log.Info("updated condition", "type", ready.Type, "status", ready.Status, "reason", ready.Reason, "message", ready.Message)
+	}
+	updated.Status.Conditions = conditionsutil.ToIDPV1alpha1(merged)
 
 	if equality.Semantic.DeepEqual(upstream, updated) {
 		return
@@ -363,44 +573,21 @@ func (c *controller) updateStatus(ctx context.Context, upstream *v1alpha1.OIDCId
 	_, err := c.client.
 		IDPV1alpha1().
 		OIDCIdentityProviders(upstream.Namespace).
-		UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+		UpdateStatus(ctx.Context, updated, metav1.UpdateOptions{})
 	if err != nil {
 		log.Error(err, "failed to update status")
+		return
 	}
+	conditionsutil.RecordTransitions(ctx.Recorder, upstream, "ValidateUpstream",
+		conditionsutil.FromIDPV1alpha1(upstream.Status.Conditions), merged)
 }
 
-// mergeCondition merges a new v1alpha1.Condition into a slice of existing conditions. It returns true
-// if the condition has meaningfully changed.
-func mergeCondition(existing *[]v1alpha1.Condition, new *v1alpha1.Condition) bool {
-	// Find any existing condition with a matching type.
-	var old *v1alpha1.Condition
-	for i := range *existing {
-		if (*existing)[i].Type == new.Type {
-			old = &(*existing)[i]
-			continue
-		}
+func conditionValues(conditions []*v1alpha1.Condition) []v1alpha1.Condition {
+	values := make([]v1alpha1.Condition, 0, len(conditions))
+	for _, cond := range conditions {
+		values = append(values, *cond)
 	}
-
-	// If there is no existing condition of this type, append this one and we're done.
-	if old == nil {
-		*existing = append(*existing, *new)
-		return true
-	}
-
-	// Set the LastTransitionTime depending on whether the status has changed.
-	new = new.DeepCopy()
-	if old.Status == new.Status {
-		new.LastTransitionTime = old.LastTransitionTime
-	}
-
-	// If anything has actually changed, update the entry and return true.
-	if !equality.Semantic.DeepEqual(old, new) {
-		*old = *new
-		return true
-	}
-
-	// Otherwise the entry is already up to date.
-	return false
+	return values
 }
 
 func computeScopes(additionalScopes []string) []string {
@@ -4,9 +4,18 @@
 package upstreamwatcher
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
@@ -16,9 +25,14 @@ import (
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/component-base/metrics/legacyregistry"
+	metricstestutil "k8s.io/component-base/metrics/testutil"
 
 	"go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
 	pinnipedfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
@@ -26,6 +40,7 @@ import (
 	"go.pinniped.dev/internal/controllerlib"
 	"go.pinniped.dev/internal/oidc/oidctestutil"
 	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/oidc/usernametemplate"
 	"go.pinniped.dev/internal/testutil"
 	"go.pinniped.dev/internal/testutil/testlogger"
 	"go.pinniped.dev/internal/upstreamoidc"
@@ -51,6 +66,16 @@ func TestControllerFilterSecret(t *testing.T) {
 			wantUpdate: true,
 			wantDelete: true,
 		},
+		{
+			name: "a secret of the decryption keys type",
+			secret: &corev1.Secret{
+				Type:       "secrets.pinniped.dev/oidc-client-decryption-keys",
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+			wantAdd:    true,
+			wantUpdate: true,
+			wantDelete: true,
+		},
 		{
 			name: "a secret of the wrong type",
 			secret: &corev1.Secret{
@@ -87,6 +112,7 @@ func TestControllerFilterSecret(t *testing.T) {
 				nil,
 				pinnipedInformers.IDP().V1alpha1().OIDCIdentityProviders(),
 				secretInformer,
+				nil,
 				testLog,
 				withInformer.WithInformer,
 			)
@@ -123,12 +149,13 @@ func TestController(t *testing.T) {
 		testValidSecretData  = map[string][]byte{"clientID": []byte(testClientID), "clientSecret": []byte(testClientSecret)}
 		testGroupsClaim      = "test-groups-claim"
 		testUsernameClaim    = "test-username-claim"
+		testUIDClaim         = "test-uid-claim"
 	)
 	tests := []struct {
 		name                   string
 		inputUpstreams         []runtime.Object
 		inputSecrets           []runtime.Object
-		wantErr                string
+		wantRetry              bool
 		wantLogs               []string
 		wantResultingCache     []provider.UpstreamOIDCIdentityProviderI
 		wantResultingUpstreams []v1alpha1.OIDCIdentityProvider
@@ -148,10 +175,12 @@ func TestController(t *testing.T) {
 				},
 			}},
 			inputSecrets: []runtime.Object{},
-			wantErr:      controllerlib.ErrSyntheticRequeue.Error(),
+			wantRetry:    true,
 			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="claims configuration is valid" "reason"="Success" "status"="True" "type"="ClaimsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="secret \"test-client-secret\" not found" "reason"="SecretNotFound" "status"="False" "type"="ClientCredentialsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="discovered issuer configuration" "reason"="Success" "status"="True" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="ClientCredentialsValid is False: secret \"test-client-secret\" not found" "reason"="SecretNotFound" "status"="False" "type"="Ready"`,
 				`upstream-observer "error"="OIDCIdentityProvider has a failing condition" "msg"="found failing condition" "message"="secret \"test-client-secret\" not found" "name"="test-name" "namespace"="test-namespace" "reason"="SecretNotFound" "type"="ClientCredentialsValid"`,
 			},
 			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{},
@@ -160,6 +189,13 @@ func TestController(t *testing.T) {
 				Status: v1alpha1.OIDCIdentityProviderStatus{
 					Phase: "Error",
 					Conditions: []v1alpha1.Condition{
+						{
+							Type:               "ClaimsValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "claims configuration is valid",
+						},
 						{
 							Type:               "ClientCredentialsValid",
 							Status:             "False",
@@ -174,6 +210,13 @@ func TestController(t *testing.T) {
 							Reason:             "Success",
 							Message:            "discovered issuer configuration",
 						},
+						{
+							Type:               "Ready",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "SecretNotFound",
+							Message:            `ClientCredentialsValid is False: secret "test-client-secret" not found`,
+						},
 					},
 				},
 			}},
@@ -194,10 +237,12 @@ func TestController(t *testing.T) {
 				Type:       "some-other-type",
 				Data:       testValidSecretData,
 			}},
-			wantErr: controllerlib.ErrSyntheticRequeue.Error(),
+			wantRetry: true,
 			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="claims configuration is valid" "reason"="Success" "status"="True" "type"="ClaimsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="referenced Secret \"test-client-secret\" has wrong type \"some-other-type\" (should be \"secrets.pinniped.dev/oidc-client\")" "reason"="SecretWrongType" "status"="False" "type"="ClientCredentialsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="discovered issuer configuration" "reason"="Success" "status"="True" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="ClientCredentialsValid is False: referenced Secret \"test-client-secret\" has wrong type \"some-other-type\" (should be \"secrets.pinniped.dev/oidc-client\")" "reason"="SecretWrongType" "status"="False" "type"="Ready"`,
 				`upstream-observer "error"="OIDCIdentityProvider has a failing condition" "msg"="found failing condition" "message"="referenced Secret \"test-client-secret\" has wrong type \"some-other-type\" (should be \"secrets.pinniped.dev/oidc-client\")" "name"="test-name" "namespace"="test-namespace" "reason"="SecretWrongType" "type"="ClientCredentialsValid"`,
 			},
 			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{},
@@ -206,6 +251,13 @@ func TestController(t *testing.T) {
 				Status: v1alpha1.OIDCIdentityProviderStatus{
 					Phase: "Error",
 					Conditions: []v1alpha1.Condition{
+						{
+							Type:               "ClaimsValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "claims configuration is valid",
+						},
 						{
 							Type:               "ClientCredentialsValid",
 							Status:             "False",
@@ -220,6 +272,13 @@ func TestController(t *testing.T) {
 							Reason:             "Success",
 							Message:            "discovered issuer configuration",
 						},
+						{
+							Type:               "Ready",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "SecretWrongType",
+							Message:            `ClientCredentialsValid is False: referenced Secret "test-client-secret" has wrong type "some-other-type" (should be "secrets.pinniped.dev/oidc-client")`,
+						},
 					},
 				},
 			}},
@@ -239,10 +298,12 @@ func TestController(t *testing.T) {
 				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testSecretName},
 				Type:       "secrets.pinniped.dev/oidc-client",
 			}},
-			wantErr: controllerlib.ErrSyntheticRequeue.Error(),
+			wantRetry: true,
 			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="claims configuration is valid" "reason"="Success" "status"="True" "type"="ClaimsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="referenced Secret \"test-client-secret\" is missing required keys [\"clientID\" \"clientSecret\"]" "reason"="SecretMissingKeys" "status"="False" "type"="ClientCredentialsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="discovered issuer configuration" "reason"="Success" "status"="True" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="ClientCredentialsValid is False: referenced Secret \"test-client-secret\" is missing required keys [\"clientID\" \"clientSecret\"]" "reason"="SecretMissingKeys" "status"="False" "type"="Ready"`,
 				`upstream-observer "error"="OIDCIdentityProvider has a failing condition" "msg"="found failing condition" "message"="referenced Secret \"test-client-secret\" is missing required keys [\"clientID\" \"clientSecret\"]" "name"="test-name" "namespace"="test-namespace" "reason"="SecretMissingKeys" "type"="ClientCredentialsValid"`,
 			},
 			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{},
@@ -251,6 +312,13 @@ func TestController(t *testing.T) {
 				Status: v1alpha1.OIDCIdentityProviderStatus{
 					Phase: "Error",
 					Conditions: []v1alpha1.Condition{
+						{
+							Type:               "ClaimsValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "claims configuration is valid",
+						},
 						{
 							Type:               "ClientCredentialsValid",
 							Status:             "False",
@@ -265,6 +333,13 @@ func TestController(t *testing.T) {
 							Reason:             "Success",
 							Message:            "discovered issuer configuration",
 						},
+						{
+							Type:               "Ready",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "SecretMissingKeys",
+							Message:            `ClientCredentialsValid is False: referenced Secret "test-client-secret" is missing required keys ["clientID" "clientSecret"]`,
+						},
 					},
 				},
 			}},
@@ -287,10 +362,12 @@ func TestController(t *testing.T) {
 				Type:       "secrets.pinniped.dev/oidc-client",
 				Data:       testValidSecretData,
 			}},
-			wantErr: controllerlib.ErrSyntheticRequeue.Error(),
+			wantRetry: true,
 			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="claims configuration is valid" "reason"="Success" "status"="True" "type"="ClaimsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="loaded client credentials" "reason"="Success" "status"="True" "type"="ClientCredentialsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="spec.certificateAuthorityData is invalid: illegal base64 data at input byte 7" "reason"="InvalidTLSConfig" "status"="False" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="OIDCDiscoverySucceeded is False: spec.certificateAuthorityData is invalid: illegal base64 data at input byte 7" "reason"="InvalidTLSConfig" "status"="False" "type"="Ready"`,
 				`upstream-observer "error"="OIDCIdentityProvider has a failing condition" "msg"="found failing condition" "message"="spec.certificateAuthorityData is invalid: illegal base64 data at input byte 7" "name"="test-name" "namespace"="test-namespace" "reason"="InvalidTLSConfig" "type"="OIDCDiscoverySucceeded"`,
 			},
 			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{},
@@ -299,6 +376,13 @@ func TestController(t *testing.T) {
 				Status: v1alpha1.OIDCIdentityProviderStatus{
 					Phase: "Error",
 					Conditions: []v1alpha1.Condition{
+						{
+							Type:               "ClaimsValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "claims configuration is valid",
+						},
 						{
 							Type:               "ClientCredentialsValid",
 							Status:             "True",
@@ -313,6 +397,13 @@ func TestController(t *testing.T) {
 							Reason:             "InvalidTLSConfig",
 							Message:            `spec.certificateAuthorityData is invalid: illegal base64 data at input byte 7`,
 						},
+						{
+							Type:               "Ready",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "InvalidTLSConfig",
+							Message:            `OIDCDiscoverySucceeded is False: spec.certificateAuthorityData is invalid: illegal base64 data at input byte 7`,
+						},
 					},
 				},
 			}},
@@ -335,10 +426,12 @@ func TestController(t *testing.T) {
 				Type:       "secrets.pinniped.dev/oidc-client",
 				Data:       testValidSecretData,
 			}},
-			wantErr: controllerlib.ErrSyntheticRequeue.Error(),
+			wantRetry: true,
 			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="claims configuration is valid" "reason"="Success" "status"="True" "type"="ClaimsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="loaded client credentials" "reason"="Success" "status"="True" "type"="ClientCredentialsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="spec.certificateAuthorityData is invalid: no certificates found" "reason"="InvalidTLSConfig" "status"="False" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="OIDCDiscoverySucceeded is False: spec.certificateAuthorityData is invalid: no certificates found" "reason"="InvalidTLSConfig" "status"="False" "type"="Ready"`,
 				`upstream-observer "error"="OIDCIdentityProvider has a failing condition" "msg"="found failing condition" "message"="spec.certificateAuthorityData is invalid: no certificates found" "name"="test-name" "namespace"="test-namespace" "reason"="InvalidTLSConfig" "type"="OIDCDiscoverySucceeded"`,
 			},
 			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{},
@@ -347,6 +440,13 @@ func TestController(t *testing.T) {
 				Status: v1alpha1.OIDCIdentityProviderStatus{
 					Phase: "Error",
 					Conditions: []v1alpha1.Condition{
+						{
+							Type:               "ClaimsValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "claims configuration is valid",
+						},
 						{
 							Type:               "ClientCredentialsValid",
 							Status:             "True",
@@ -361,6 +461,13 @@ func TestController(t *testing.T) {
 							Reason:             "InvalidTLSConfig",
 							Message:            `spec.certificateAuthorityData is invalid: no certificates found`,
 						},
+						{
+							Type:               "Ready",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "InvalidTLSConfig",
+							Message:            `OIDCDiscoverySucceeded is False: spec.certificateAuthorityData is invalid: no certificates found`,
+						},
 					},
 				},
 			}},
@@ -380,10 +487,12 @@ func TestController(t *testing.T) {
 				Type:       "secrets.pinniped.dev/oidc-client",
 				Data:       testValidSecretData,
 			}},
-			wantErr: controllerlib.ErrSyntheticRequeue.Error(),
+			wantRetry: true,
 			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="claims configuration is valid" "reason"="Success" "status"="True" "type"="ClaimsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="loaded client credentials" "reason"="Success" "status"="True" "type"="ClientCredentialsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="failed to perform OIDC discovery against \"invalid-url\"" "reason"="Unreachable" "status"="False" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="OIDCDiscoverySucceeded is False: failed to perform OIDC discovery against \"invalid-url\"" "reason"="Unreachable" "status"="False" "type"="Ready"`,
 				`upstream-observer "error"="OIDCIdentityProvider has a failing condition" "msg"="found failing condition" "message"="failed to perform OIDC discovery against \"invalid-url\"" "name"="test-name" "namespace"="test-namespace" "reason"="Unreachable" "type"="OIDCDiscoverySucceeded"`,
 			},
 			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{},
@@ -392,6 +501,13 @@ func TestController(t *testing.T) {
 				Status: v1alpha1.OIDCIdentityProviderStatus{
 					Phase: "Error",
 					Conditions: []v1alpha1.Condition{
+						{
+							Type:               "ClaimsValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "claims configuration is valid",
+						},
 						{
 							Type:               "ClientCredentialsValid",
 							Status:             "True",
@@ -406,6 +522,13 @@ func TestController(t *testing.T) {
 							Reason:             "Unreachable",
 							Message:            `failed to perform OIDC discovery against "invalid-url"`,
 						},
+						{
+							Type:               "Ready",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "Unreachable",
+							Message:            `OIDCDiscoverySucceeded is False: failed to perform OIDC discovery against "invalid-url"`,
+						},
 					},
 				},
 			}},
@@ -426,10 +549,12 @@ func TestController(t *testing.T) {
 				Type:       "secrets.pinniped.dev/oidc-client",
 				Data:       testValidSecretData,
 			}},
-			wantErr: controllerlib.ErrSyntheticRequeue.Error(),
+			wantRetry: true,
 			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="claims configuration is valid" "reason"="Success" "status"="True" "type"="ClaimsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="loaded client credentials" "reason"="Success" "status"="True" "type"="ClientCredentialsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="failed to parse authorization endpoint URL: parse \"%\": invalid URL escape \"%\"" "reason"="InvalidResponse" "status"="False" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="OIDCDiscoverySucceeded is False: failed to parse authorization endpoint URL: parse \"%\": invalid URL escape \"%\"" "reason"="InvalidResponse" "status"="False" "type"="Ready"`,
 				`upstream-observer "error"="OIDCIdentityProvider has a failing condition" "msg"="found failing condition" "message"="failed to parse authorization endpoint URL: parse \"%\": invalid URL escape \"%\"" "name"="test-name" "namespace"="test-namespace" "reason"="InvalidResponse" "type"="OIDCDiscoverySucceeded"`,
 			},
 			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{},
@@ -438,6 +563,13 @@ func TestController(t *testing.T) {
 				Status: v1alpha1.OIDCIdentityProviderStatus{
 					Phase: "Error",
 					Conditions: []v1alpha1.Condition{
+						{
+							Type:               "ClaimsValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "claims configuration is valid",
+						},
 						{
 							Type:               "ClientCredentialsValid",
 							Status:             "True",
@@ -452,6 +584,13 @@ func TestController(t *testing.T) {
 							Reason:             "InvalidResponse",
 							Message:            `failed to parse authorization endpoint URL: parse "%": invalid URL escape "%"`,
 						},
+						{
+							Type:               "Ready",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "InvalidResponse",
+							Message:            `OIDCDiscoverySucceeded is False: failed to parse authorization endpoint URL: parse "%": invalid URL escape "%"`,
+						},
 					},
 				},
 			}},
@@ -472,10 +611,12 @@ func TestController(t *testing.T) {
 				Type:       "secrets.pinniped.dev/oidc-client",
 				Data:       testValidSecretData,
 			}},
-			wantErr: controllerlib.ErrSyntheticRequeue.Error(),
+			wantRetry: true,
 			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="claims configuration is valid" "reason"="Success" "status"="True" "type"="ClaimsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="loaded client credentials" "reason"="Success" "status"="True" "type"="ClientCredentialsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="authorization endpoint URL scheme must be \"https\", not \"http\"" "reason"="InvalidResponse" "status"="False" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="OIDCDiscoverySucceeded is False: authorization endpoint URL scheme must be \"https\", not \"http\"" "reason"="InvalidResponse" "status"="False" "type"="Ready"`,
 				`upstream-observer "error"="OIDCIdentityProvider has a failing condition" "msg"="found failing condition" "message"="authorization endpoint URL scheme must be \"https\", not \"http\"" "name"="test-name" "namespace"="test-namespace" "reason"="InvalidResponse" "type"="OIDCDiscoverySucceeded"`,
 			},
 			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{},
@@ -484,6 +625,13 @@ func TestController(t *testing.T) {
 				Status: v1alpha1.OIDCIdentityProviderStatus{
 					Phase: "Error",
 					Conditions: []v1alpha1.Condition{
+						{
+							Type:               "ClaimsValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "claims configuration is valid",
+						},
 						{
 							Type:               "ClientCredentialsValid",
 							Status:             "True",
@@ -498,6 +646,13 @@ func TestController(t *testing.T) {
 							Reason:             "InvalidResponse",
 							Message:            `authorization endpoint URL scheme must be "https", not "http"`,
 						},
+						{
+							Type:               "Ready",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "InvalidResponse",
+							Message:            `OIDCDiscoverySucceeded is False: authorization endpoint URL scheme must be "https", not "http"`,
+						},
 					},
 				},
 			}},
@@ -511,7 +666,7 @@ func TestController(t *testing.T) {
 					TLS:                 &v1alpha1.TLSSpec{CertificateAuthorityData: testIssuerCABase64},
 					Client:              v1alpha1.OIDCClient{SecretName: testSecretName},
 					AuthorizationConfig: v1alpha1.OIDCAuthorizationConfig{AdditionalScopes: append(testAdditionalScopes, "xyz", "openid")},
-					Claims:              v1alpha1.OIDCClaims{Groups: testGroupsClaim, Username: testUsernameClaim},
+					Claims:              v1alpha1.OIDCClaims{Groups: testGroupsClaim, Username: testUsernameClaim, UID: testUIDClaim},
 				},
 				Status: v1alpha1.OIDCIdentityProviderStatus{
 					Phase: "Error",
@@ -527,8 +682,10 @@ func TestController(t *testing.T) {
 				Data:       testValidSecretData,
 			}},
 			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="claims configuration is valid" "reason"="Success" "status"="True" "type"="ClaimsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="loaded client credentials" "reason"="Success" "status"="True" "type"="ClientCredentialsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="discovered issuer configuration" "reason"="Success" "status"="True" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="all conditions are true" "reason"="Success" "status"="True" "type"="Ready"`,
 			},
 			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{
 				&oidctestutil.TestUpstreamOIDCIdentityProvider{
@@ -538,6 +695,54 @@ func TestController(t *testing.T) {
 					Scopes:           append(testExpectedScopes, "xyz"),
 					UsernameClaim:    testUsernameClaim,
 					GroupsClaim:      testGroupsClaim,
+					UIDClaim:         testUIDClaim,
+				},
+			},
+			wantResultingUpstreams: []v1alpha1.OIDCIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName},
+				Status: v1alpha1.OIDCIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []v1alpha1.Condition{
+						{Type: "ClaimsValid", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "claims configuration is valid"},
+						{Type: "ClientCredentialsValid", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "loaded client credentials"},
+						{Type: "OIDCDiscoverySucceeded", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "discovered issuer configuration"},
+						{Type: "Ready", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "all conditions are true"},
+					},
+				},
+			}},
+		},
+		{
+			name: "upstream with username template and password grant",
+			inputUpstreams: []runtime.Object{&v1alpha1.OIDCIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName},
+				Spec: v1alpha1.OIDCIdentityProviderSpec{
+					Issuer:              testIssuerURL,
+					TLS:                 &v1alpha1.TLSSpec{CertificateAuthorityData: testIssuerCABase64},
+					Client:              v1alpha1.OIDCClient{SecretName: testSecretName},
+					AuthorizationConfig: v1alpha1.OIDCAuthorizationConfig{AdditionalScopes: testAdditionalScopes, AllowPasswordGrant: true},
+					Claims:              v1alpha1.OIDCClaims{Groups: testGroupsClaim, UsernameTemplate: "{{.preferred_username}}@{{.tenant}}"},
+				},
+			}},
+			inputSecrets: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testSecretName},
+				Type:       "secrets.pinniped.dev/oidc-client",
+				Data:       testValidSecretData,
+			}},
+			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="claims configuration is valid" "reason"="Success" "status"="True" "type"="ClaimsValid"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="loaded client credentials" "reason"="Success" "status"="True" "type"="ClientCredentialsValid"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="discovered issuer configuration" "reason"="Success" "status"="True" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="all conditions are true" "reason"="Success" "status"="True" "type"="Ready"`,
+			},
+			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{
+				&oidctestutil.TestUpstreamOIDCIdentityProvider{
+					Name:               testName,
+					ClientID:           testClientID,
+					AuthorizationURL:   *testIssuerAuthorizeURL,
+					Scopes:             testExpectedScopes,
+					UsernameTemplate:   mustParseUsernameTemplate(t, "{{.preferred_username}}@{{.tenant}}"),
+					GroupsClaim:        testGroupsClaim,
+					AllowPasswordGrant: true,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.OIDCIdentityProvider{{
@@ -545,8 +750,88 @@ func TestController(t *testing.T) {
 				Status: v1alpha1.OIDCIdentityProviderStatus{
 					Phase: "Ready",
 					Conditions: []v1alpha1.Condition{
+						{Type: "ClaimsValid", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "claims configuration is valid"},
 						{Type: "ClientCredentialsValid", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "loaded client credentials"},
 						{Type: "OIDCDiscoverySucceeded", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "discovered issuer configuration"},
+						{Type: "Ready", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "all conditions are true"},
+					},
+				},
+			}},
+		},
+		{
+			name: "invalid username template",
+			inputUpstreams: []runtime.Object{&v1alpha1.OIDCIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName},
+				Spec: v1alpha1.OIDCIdentityProviderSpec{
+					Issuer:              testIssuerURL,
+					TLS:                 &v1alpha1.TLSSpec{CertificateAuthorityData: testIssuerCABase64},
+					Client:              v1alpha1.OIDCClient{SecretName: testSecretName},
+					AuthorizationConfig: v1alpha1.OIDCAuthorizationConfig{AdditionalScopes: testAdditionalScopes},
+					Claims:              v1alpha1.OIDCClaims{UsernameTemplate: "{{.preferred_username"},
+				},
+			}},
+			inputSecrets: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testSecretName},
+				Type:       "secrets.pinniped.dev/oidc-client",
+				Data:       testValidSecretData,
+			}},
+			wantRetry: true,
+			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="invalid claims.usernameTemplate: template: usernameTemplate:1: unclosed action" "reason"="InvalidClaimsConfig" "status"="False" "type"="ClaimsValid"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="loaded client credentials" "reason"="Success" "status"="True" "type"="ClientCredentialsValid"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="discovered issuer configuration" "reason"="Success" "status"="True" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="ClaimsValid is False: invalid claims.usernameTemplate: template: usernameTemplate:1: unclosed action" "reason"="InvalidClaimsConfig" "status"="False" "type"="Ready"`,
+				`upstream-observer "error"="OIDCIdentityProvider has a failing condition" "msg"="found failing condition" "message"="invalid claims.usernameTemplate: template: usernameTemplate:1: unclosed action" "name"="test-name" "namespace"="test-namespace" "reason"="InvalidClaimsConfig" "type"="ClaimsValid"`,
+			},
+			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{},
+			wantResultingUpstreams: []v1alpha1.OIDCIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName},
+				Status: v1alpha1.OIDCIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []v1alpha1.Condition{
+						{Type: "ClaimsValid", Status: "False", LastTransitionTime: now, Reason: "InvalidClaimsConfig", Message: "invalid claims.usernameTemplate: template: usernameTemplate:1: unclosed action"},
+						{Type: "ClientCredentialsValid", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "loaded client credentials"},
+						{Type: "OIDCDiscoverySucceeded", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "discovered issuer configuration"},
+						{Type: "Ready", Status: "False", LastTransitionTime: now, Reason: "InvalidClaimsConfig", Message: "ClaimsValid is False: invalid claims.usernameTemplate: template: usernameTemplate:1: unclosed action"},
+					},
+				},
+			}},
+		},
+		{
+			name: "username claim and username template are both specified",
+			inputUpstreams: []runtime.Object{&v1alpha1.OIDCIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName},
+				Spec: v1alpha1.OIDCIdentityProviderSpec{
+					Issuer:              testIssuerURL,
+					TLS:                 &v1alpha1.TLSSpec{CertificateAuthorityData: testIssuerCABase64},
+					Client:              v1alpha1.OIDCClient{SecretName: testSecretName},
+					AuthorizationConfig: v1alpha1.OIDCAuthorizationConfig{AdditionalScopes: testAdditionalScopes},
+					Claims:              v1alpha1.OIDCClaims{Username: testUsernameClaim, UsernameTemplate: "{{.preferred_username}}"},
+				},
+			}},
+			inputSecrets: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testSecretName},
+				Type:       "secrets.pinniped.dev/oidc-client",
+				Data:       testValidSecretData,
+			}},
+			wantRetry: true,
+			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="claims.username and claims.usernameTemplate cannot both be specified" "reason"="InvalidClaimsConfig" "status"="False" "type"="ClaimsValid"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="loaded client credentials" "reason"="Success" "status"="True" "type"="ClientCredentialsValid"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="discovered issuer configuration" "reason"="Success" "status"="True" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="ClaimsValid is False: claims.username and claims.usernameTemplate cannot both be specified" "reason"="InvalidClaimsConfig" "status"="False" "type"="Ready"`,
+				`upstream-observer "error"="OIDCIdentityProvider has a failing condition" "msg"="found failing condition" "message"="claims.username and claims.usernameTemplate cannot both be specified" "name"="test-name" "namespace"="test-namespace" "reason"="InvalidClaimsConfig" "type"="ClaimsValid"`,
+			},
+			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{},
+			wantResultingUpstreams: []v1alpha1.OIDCIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName},
+				Status: v1alpha1.OIDCIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []v1alpha1.Condition{
+						{Type: "ClaimsValid", Status: "False", LastTransitionTime: now, Reason: "InvalidClaimsConfig", Message: "claims.username and claims.usernameTemplate cannot both be specified"},
+						{Type: "ClientCredentialsValid", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "loaded client credentials"},
+						{Type: "OIDCDiscoverySucceeded", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "discovered issuer configuration"},
+						{Type: "Ready", Status: "False", LastTransitionTime: now, Reason: "InvalidClaimsConfig", Message: "ClaimsValid is False: claims.username and claims.usernameTemplate cannot both be specified"},
 					},
 				},
 			}},
@@ -560,11 +845,12 @@ func TestController(t *testing.T) {
 					TLS:                 &v1alpha1.TLSSpec{CertificateAuthorityData: testIssuerCABase64},
 					Client:              v1alpha1.OIDCClient{SecretName: testSecretName},
 					AuthorizationConfig: v1alpha1.OIDCAuthorizationConfig{AdditionalScopes: testAdditionalScopes},
-					Claims:              v1alpha1.OIDCClaims{Groups: testGroupsClaim, Username: testUsernameClaim},
+					Claims:              v1alpha1.OIDCClaims{Groups: testGroupsClaim, Username: testUsernameClaim, UID: testUIDClaim},
 				},
 				Status: v1alpha1.OIDCIdentityProviderStatus{
 					Phase: "Ready",
 					Conditions: []v1alpha1.Condition{
+						{Type: "ClaimsValid", Status: "True", LastTransitionTime: earlier, Reason: "Success", Message: "claims configuration is valid"},
 						{Type: "ClientCredentialsValid", Status: "True", LastTransitionTime: earlier, Reason: "Success", Message: "loaded client credentials"},
 						{Type: "OIDCDiscoverySucceeded", Status: "True", LastTransitionTime: earlier, Reason: "Success", Message: "discovered issuer configuration"},
 					},
@@ -576,8 +862,10 @@ func TestController(t *testing.T) {
 				Data:       testValidSecretData,
 			}},
 			wantLogs: []string{
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="claims configuration is valid" "reason"="Success" "status"="True" "type"="ClaimsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="loaded client credentials" "reason"="Success" "status"="True" "type"="ClientCredentialsValid"`,
 				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="discovered issuer configuration" "reason"="Success" "status"="True" "type"="OIDCDiscoverySucceeded"`,
+				`upstream-observer "level"=0 "msg"="updated condition" "name"="test-name" "namespace"="test-namespace" "message"="all conditions are true" "reason"="Success" "status"="True" "type"="Ready"`,
 			},
 			wantResultingCache: []provider.UpstreamOIDCIdentityProviderI{
 				&oidctestutil.TestUpstreamOIDCIdentityProvider{
@@ -587,6 +875,7 @@ func TestController(t *testing.T) {
 					Scopes:           testExpectedScopes,
 					UsernameClaim:    testUsernameClaim,
 					GroupsClaim:      testGroupsClaim,
+					UIDClaim:         testUIDClaim,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.OIDCIdentityProvider{{
@@ -594,8 +883,10 @@ func TestController(t *testing.T) {
 				Status: v1alpha1.OIDCIdentityProviderStatus{
 					Phase: "Ready",
 					Conditions: []v1alpha1.Condition{
+						{Type: "ClaimsValid", Status: "True", LastTransitionTime: earlier, Reason: "Success", Message: "claims configuration is valid", ObservedGeneration: 1234},
 						{Type: "ClientCredentialsValid", Status: "True", LastTransitionTime: earlier, Reason: "Success", Message: "loaded client credentials", ObservedGeneration: 1234},
 						{Type: "OIDCDiscoverySucceeded", Status: "True", LastTransitionTime: earlier, Reason: "Success", Message: "discovered issuer configuration", ObservedGeneration: 1234},
+						{Type: "Ready", Status: "True", LastTransitionTime: now, Reason: "Success", Message: "all conditions are true", ObservedGeneration: 1234},
 					},
 				},
 			}},
@@ -620,6 +911,7 @@ func TestController(t *testing.T) {
 				fakePinnipedClient,
 				pinnipedInformers.IDP().V1alpha1().OIDCIdentityProviders(),
 				kubeInformers.Core().V1().Secrets(),
+				&events.FakeRecorder{},
 				testLog,
 				controllerlib.WithInformer,
 			)
@@ -631,13 +923,11 @@ func TestController(t *testing.T) {
 			kubeInformers.Start(ctx.Done())
 			controllerlib.TestRunSynchronously(t, controller)
 
-			syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{}}
+			queue := &recordingQueue{addedAfter: map[controllerlib.Key]time.Duration{}}
+			syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{}, Queue: queue}
 
-			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
-				require.EqualError(t, err, tt.wantErr)
-			} else {
-				require.NoError(t, err)
-			}
+			require.NoError(t, controllerlib.TestSync(t, controller, syncCtx))
+			requireRetry(t, tt.wantRetry, queue)
 			require.Equal(t, strings.Join(tt.wantLogs, "\n"), strings.Join(testLog.Lines(), "\n"))
 
 			actualIDPList := cache.GetIDPList()
@@ -649,7 +939,16 @@ func TestController(t *testing.T) {
 				require.Equal(t, tt.wantResultingCache[i].GetAuthorizationURL().String(), actualIDP.GetAuthorizationURL().String())
 				require.Equal(t, tt.wantResultingCache[i].GetUsernameClaim(), actualIDP.GetUsernameClaim())
 				require.Equal(t, tt.wantResultingCache[i].GetGroupsClaim(), actualIDP.GetGroupsClaim())
+				require.Equal(t, tt.wantResultingCache[i].GetUIDClaim(), actualIDP.GetUIDClaim())
+				if wantTemplate := tt.wantResultingCache[i].GetUsernameTemplate(); wantTemplate != nil {
+					require.NotNil(t, actualIDP.GetUsernameTemplate())
+					require.Equal(t, wantTemplate.String(), actualIDP.GetUsernameTemplate().String())
+				} else {
+					require.Nil(t, actualIDP.GetUsernameTemplate())
+				}
 				require.ElementsMatch(t, tt.wantResultingCache[i].GetScopes(), actualIDP.GetScopes())
+				require.Equal(t, tt.wantResultingCache[i].AllowsPasswordGrant(), actualIDP.AllowsPasswordGrant())
+				require.NoError(t, actualIDP.ValidateIssuerParameter(testIssuerURL))
 			}
 
 			actualUpstreams, err := fakePinnipedClient.IDPV1alpha1().OIDCIdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
@@ -658,17 +957,293 @@ func TestController(t *testing.T) {
 			// Preprocess the set of upstreams a bit so that they're easier to assert against.
 			require.ElementsMatch(t, tt.wantResultingUpstreams, normalizeUpstreams(actualUpstreams.Items, now))
 
-			// Running the sync() a second time should be idempotent except for logs, and should return the same error.
+			// Running the sync() a second time should be idempotent except for logs, and should schedule the same retry.
 			// This also helps exercise code paths where the OIDC provider discovery hits cache.
-			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
-				require.EqualError(t, err, tt.wantErr)
-			} else {
-				require.NoError(t, err)
+			queue.addedAfter = map[controllerlib.Key]time.Duration{}
+			require.NoError(t, controllerlib.TestSync(t, controller, syncCtx))
+			requireRetry(t, tt.wantRetry, queue)
+		})
+	}
+}
+
+func TestLoadDecryptionKeys(t *testing.T) {
+	t.Parallel()
+
+	const testNamespace = "test-namespace"
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pkcs8Key, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	require.NoError(t, err)
+	ecKeyDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	validKeys := bytes.Join([][]byte{
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecKeyDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Key}),
+	}, nil)
+
+	tests := []struct {
+		name        string
+		secret      *corev1.Secret
+		wantKeys    []interface{}
+		wantReason  string
+		wantMessage string
+	}{
+		{
+			name: "valid keys",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "test-keys"},
+				Type:       "secrets.pinniped.dev/oidc-client-decryption-keys",
+				Data:       map[string][]byte{"keys": validKeys},
+			},
+			wantKeys: []interface{}{rsaKey, ecKey, ecKey},
+		},
+		{
+			name:        "missing secret",
+			wantReason:  "SecretNotFound",
+			wantMessage: `secret "test-keys" not found`,
+		},
+		{
+			name: "secret has wrong type",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "test-keys"},
+				Type:       "secrets.pinniped.dev/oidc-client",
+				Data:       map[string][]byte{"keys": validKeys},
+			},
+			wantReason:  "SecretWrongType",
+			wantMessage: `referenced Secret "test-keys" has wrong type "secrets.pinniped.dev/oidc-client" (should be "secrets.pinniped.dev/oidc-client-decryption-keys")`,
+		},
+		{
+			name: "secret is missing key",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "test-keys"},
+				Type:       "secrets.pinniped.dev/oidc-client-decryption-keys",
+			},
+			wantReason:  "SecretMissingKeys",
+			wantMessage: `referenced Secret "test-keys" is missing required keys ["keys"]`,
+		},
+		{
+			name: "secret does not contain PEM",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "test-keys"},
+				Type:       "secrets.pinniped.dev/oidc-client-decryption-keys",
+				Data:       map[string][]byte{"keys": []byte("not PEM")},
+			},
+			wantReason:  "SecretInvalidKeys",
+			wantMessage: `referenced Secret "test-keys" has invalid decryption keys: no PEM-encoded private keys found`,
+		},
+		{
+			name: "secret contains a certificate",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "test-keys"},
+				Type:       "secrets.pinniped.dev/oidc-client-decryption-keys",
+				Data: map[string][]byte{"keys": append(append([]byte{}, validKeys...),
+					pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("some certificate")})...)},
+			},
+			wantReason:  "SecretInvalidKeys",
+			wantMessage: `referenced Secret "test-keys" has invalid decryption keys: key 3: unsupported PEM block type "CERTIFICATE"`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			secretInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Secrets()
+			if tt.secret != nil {
+				require.NoError(t, secretInformer.Informer().GetIndexer().Add(tt.secret))
+			}
+			c := controller{secretInformer: secretInformer}
+
+			keys, condition := c.loadDecryptionKeys(testNamespace, "test-keys")
+			if tt.wantReason != "" {
+				require.Nil(t, keys)
+				require.Equal(t, &v1alpha1.Condition{
+					Type:    "ClientCredentialsValid",
+					Status:  "False",
+					Reason:  tt.wantReason,
+					Message: tt.wantMessage,
+				}, condition)
+				return
+			}
+			require.Nil(t, condition)
+			// Parsed keys may have different precomputed values than the generated keys, so compare them with
+			// their Equal methods.
+			require.Len(t, keys, len(tt.wantKeys))
+			for i := range tt.wantKeys {
+				wantKey := tt.wantKeys[i].(interface{ Equal(crypto.PrivateKey) bool })
+				require.True(t, wantKey.Equal(keys[i]), "key %d", i)
 			}
 		})
 	}
 }
 
+func TestControllerWithManyUpstreams(t *testing.T) {
+	t.Parallel()
+
+	testIssuerCA, testIssuerURL := newTestIssuer(t)
+	testIssuerCABase64 := base64.StdEncoding.EncodeToString([]byte(testIssuerCA))
+
+	const testNamespace = "test-namespace"
+	var (
+		inputUpstreams []runtime.Object
+		wantValidNames []string
+	)
+	for i := 0; i < 3*maxConcurrentValidations; i++ {
+		name := fmt.Sprintf("test-name-%02d", i)
+		issuer := testIssuerURL
+		if i%4 == 0 {
+			// Every fourth upstream fails validation, which should not affect any of the others.
+			issuer = testIssuerURL + "/insecure"
+		} else {
+			wantValidNames = append(wantValidNames, name)
+		}
+		inputUpstreams = append(inputUpstreams, &v1alpha1.OIDCIdentityProvider{
+			ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name, UID: types.UID("uid-" + name)},
+			Spec: v1alpha1.OIDCIdentityProviderSpec{
+				Issuer: issuer,
+				TLS:    &v1alpha1.TLSSpec{CertificateAuthorityData: testIssuerCABase64},
+				Client: v1alpha1.OIDCClient{SecretName: "test-client-secret"},
+			},
+		})
+	}
+
+	fakePinnipedClient := pinnipedfake.NewSimpleClientset(inputUpstreams...)
+	pinnipedInformers := pinnipedinformers.NewSharedInformerFactory(fakePinnipedClient, 0)
+	fakeKubeClient := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "test-client-secret"},
+		Type:       "secrets.pinniped.dev/oidc-client",
+		Data:       map[string][]byte{"clientID": []byte("test-client-id"), "clientSecret": []byte("test-client-secret")},
+	})
+	kubeInformers := informers.NewSharedInformerFactory(fakeKubeClient, 0)
+	cache := provider.NewDynamicUpstreamIDPProvider()
+	recorder := events.NewFakeRecorder(2 * len(inputUpstreams))
+
+	controller := New(
+		cache,
+		fakePinnipedClient,
+		pinnipedInformers.IDP().V1alpha1().OIDCIdentityProviders(),
+		kubeInformers.Core().V1().Secrets(),
+		recorder,
+		testlogger.New(t),
+		controllerlib.WithInformer,
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pinnipedInformers.Start(ctx.Done())
+	kubeInformers.Start(ctx.Done())
+	controllerlib.TestRunSynchronously(t, controller)
+
+	queue := &recordingQueue{addedAfter: map[controllerlib.Key]time.Duration{}}
+	syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{}, Queue: queue}
+	require.NoError(t, controllerlib.TestSync(t, controller, syncCtx))
+	requireRetry(t, true, queue)
+
+	validatedByName := map[string]provider.UpstreamOIDCIdentityProviderI{}
+	for _, idp := range cache.GetIDPList() {
+		validatedByName[idp.GetName()] = idp
+	}
+	require.Len(t, validatedByName, len(wantValidNames))
+	for _, name := range wantValidNames {
+		require.Contains(t, validatedByName, name)
+	}
+
+	// Each upstream records that it became ready, or why it did not.
+	wantEvents := map[string]int{
+		"Normal Success all conditions are true": len(wantValidNames),
+		`Warning InvalidResponse OIDCDiscoverySucceeded is False: authorization endpoint URL scheme must be "https", not "http"`: len(inputUpstreams) - len(wantValidNames),
+	}
+	require.Equal(t, wantEvents, countEvents(recorder))
+
+	// Wait for the informer to see the updated statuses, so that the retry compares against the recorded conditions.
+	require.Eventually(t, func() bool {
+		upstreams, err := pinnipedInformers.IDP().V1alpha1().OIDCIdentityProviders().Lister().List(labels.Everything())
+		require.NoError(t, err)
+		for _, upstream := range upstreams {
+			if upstream.Status.Phase == "" {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Retrying only revalidates the failed upstreams, so the valid upstreams keep their previous configs.
+	retryCtx := controllerlib.Context{Context: ctx, Key: retryFailedUpstreamsKey, Queue: queue}
+	require.NoError(t, controllerlib.TestSync(t, controller, retryCtx))
+	requireRetry(t, true, queue)
+	retried := cache.GetIDPList()
+	require.Len(t, retried, len(wantValidNames))
+	for _, idp := range retried {
+		require.Same(t, validatedByName[idp.GetName()], idp)
+	}
+	require.Empty(t, countEvents(recorder), "unchanged conditions should not record events")
+
+	actualUpstreams, err := fakePinnipedClient.IDPV1alpha1().OIDCIdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, actualUpstreams.Items, len(inputUpstreams))
+	for _, upstream := range actualUpstreams.Items {
+		wantPhase := v1alpha1.PhaseReady
+		if upstream.Spec.Issuer != testIssuerURL {
+			wantPhase = v1alpha1.PhaseError
+		}
+		require.Equal(t, wantPhase, upstream.Status.Phase, "upstream %s", upstream.Name)
+
+		// The failed upstreams were validated twice, while the valid upstreams were only validated once.
+		failures, err := metricstestutil.GetGaugeMetricValue(discoveryConsecutiveFailures.WithLabelValues(testNamespace, upstream.Name))
+		require.NoError(t, err)
+		lastSuccess, err := metricstestutil.GetGaugeMetricValue(discoveryLastSuccess.WithLabelValues(testNamespace, upstream.Name))
+		require.NoError(t, err)
+		if wantPhase == v1alpha1.PhaseError {
+			require.Equal(t, float64(2), failures, "upstream %s", upstream.Name)
+			require.Zero(t, lastSuccess, "upstream %s", upstream.Name)
+		} else {
+			require.Zero(t, failures, "upstream %s", upstream.Name)
+			require.InDelta(t, float64(time.Now().Unix()), lastSuccess, 60, "upstream %s", upstream.Name)
+		}
+	}
+
+	// The metrics of deleted upstreams are removed.
+	require.NoError(t, fakePinnipedClient.IDPV1alpha1().OIDCIdentityProviders(testNamespace).Delete(ctx, "test-name-00", metav1.DeleteOptions{}))
+	require.Eventually(t, func() bool {
+		_, err := pinnipedInformers.IDP().V1alpha1().OIDCIdentityProviders().Lister().OIDCIdentityProviders(testNamespace).Get("test-name-00")
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, controllerlib.TestSync(t, controller, syncCtx))
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			require.False(t, metricstestutil.LabelsMatch(metric, map[string]string{"namespace": testNamespace, "name": "test-name-00"}),
+				"found metric %s of deleted upstream", family.GetName())
+		}
+	}
+}
+
+// recordingQueue is a controllerlib.Queue which only records the keys that were added to it.
+type recordingQueue struct {
+	addedAfter map[controllerlib.Key]time.Duration
+}
+
+func (q *recordingQueue) Add(controllerlib.Key)            {}
+func (q *recordingQueue) AddRateLimited(controllerlib.Key) {}
+func (q *recordingQueue) AddAfter(key controllerlib.Key, duration time.Duration) {
+	q.addedAfter[key] = duration
+}
+
+func requireRetry(t *testing.T, wantRetry bool, queue *recordingQueue) {
+	t.Helper()
+	if wantRetry {
+		require.Equal(t, map[controllerlib.Key]time.Duration{retryFailedUpstreamsKey: failedUpstreamRetryInterval}, queue.addedAfter)
+	} else {
+		require.Empty(t, queue.addedAfter)
+	}
+}
+
 func normalizeUpstreams(upstreams []v1alpha1.OIDCIdentityProvider, now metav1.Time) []v1alpha1.OIDCIdentityProvider {
 	result := make([]v1alpha1.OIDCIdentityProvider, 0, len(upstreams))
 	for _, u := range upstreams {
@@ -730,3 +1305,22 @@ func newTestIssuer(t *testing.T) (string, string) {
 
 	return caBundlePEM, testURL
 }
+
+func mustParseUsernameTemplate(t *testing.T, text string) *usernametemplate.Template {
+	t.Helper()
+	tmpl, err := usernametemplate.Parse(text)
+	require.NoError(t, err)
+	return tmpl
+}
+
+func countEvents(recorder *events.FakeRecorder) map[string]int {
+	counts := map[string]int{}
+	for {
+		select {
+		case event := <-recorder.Events:
+			counts[event]++
+		default:
+			return counts
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package supervisorconnection implements a controller which joins the cluster to a central Supervisor as described
+// by each SupervisorConnection: it maintains a JWTAuthenticator for the Supervisor, binds a ClusterRole to the
+// bootstrap group, and reports whether the connection is ready.
+package supervisorconnection
+
+import (
+	"context"
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	rbacinformers "k8s.io/client-go/informers/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
+	authinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions/authentication/v1alpha1"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/conditionsutil"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	typeAuthenticatorReady    = auth1alpha1.ConditionTypeAuthenticatorReady
+	typeBootstrapBindingReady = auth1alpha1.ConditionTypeBootstrapBindingReady
+
+	reasonSuccess        = "Success"
+	reasonPending        = "Pending"
+	reasonNameConflict   = "NameConflict"
+	reasonNotRequested   = "NotRequested"
+	reasonFailedToCreate = "FailedToCreate"
+	reasonFailedToUpdate = "FailedToUpdate"
+
+	// kind is the kind of the owner references which this controller sets on the objects it creates.
+	kind = "SupervisorConnection"
+
+	// bindingNamePrefix is prepended to the name of the SupervisorConnection to get the name of its
+	// ClusterRoleBinding, since ClusterRoleBindings share their namespace with all other bindings of the cluster.
+	bindingNamePrefix = "pinniped-supervisor-connection-"
+
+	// defaultClusterRole is bound to the bootstrap group when the SupervisorConnection does not name a ClusterRole.
+	defaultClusterRole = "view"
+)
+
+type controller struct {
+	client                pinnipedclientset.Interface
+	k8sClient             kubernetes.Interface
+	supervisorConnections authinformers.SupervisorConnectionInformer
+	jwtAuthenticators     authinformers.JWTAuthenticatorInformer
+	clusterRoleBindings   rbacinformers.ClusterRoleBindingInformer
+	labels                map[string]string
+	clock                 clock.PassiveClock
+}
+
+// NewController creates a controllerlib.Controller which maintains the JWTAuthenticator and the bootstrap
+// ClusterRoleBinding of each SupervisorConnection. The labels are added to the objects which it creates.
+func NewController(
+	client pinnipedclientset.Interface,
+	k8sClient kubernetes.Interface,
+	supervisorConnections authinformers.SupervisorConnectionInformer,
+	jwtAuthenticators authinformers.JWTAuthenticatorInformer,
+	clusterRoleBindings rbacinformers.ClusterRoleBindingInformer,
+	labels map[string]string,
+	clock clock.PassiveClock,
+	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "supervisorconnection-controller",
+			Syncer: &controller{
+				client:                client,
+				k8sClient:             k8sClient,
+				supervisorConnections: supervisorConnections,
+				jwtAuthenticators:     jwtAuthenticators,
+				clusterRoleBindings:   clusterRoleBindings,
+				labels:                labels,
+				clock:                 clock,
+			},
+		},
+		append([]controllerlib.Option{
+			withInformer(
+				supervisorConnections,
+				pinnipedcontroller.MatchAnythingFilter(nil), // nil parent func is fine because each event is distinct
+				controllerlib.InformerOption{},
+			),
+			withInformer(
+				jwtAuthenticators,
+				pinnipedcontroller.SimpleFilter(isOwnedBySupervisorConnection, owningSupervisorConnection),
+				controllerlib.InformerOption{},
+			),
+			withInformer(
+				clusterRoleBindings,
+				pinnipedcontroller.SimpleFilter(isOwnedBySupervisorConnection, owningSupervisorConnection),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
+	)
+}
+
+func isOwnedBySupervisorConnection(obj metav1.Object) bool {
+	ref := metav1.GetControllerOf(obj)
+	return ref != nil && ref.Kind == kind && ref.APIVersion == auth1alpha1.SchemeGroupVersion.String()
+}
+
+func owningSupervisorConnection(obj metav1.Object) controllerlib.Key {
+	if !isOwnedBySupervisorConnection(obj) {
+		return controllerlib.Key{}
+	}
+	return controllerlib.Key{Name: metav1.GetControllerOf(obj).Name}
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *controller) Sync(ctx controllerlib.Context) error {
+	conn, err := c.supervisorConnections.Lister().Get(ctx.Key.Name)
+	if k8serrors.IsNotFound(err) {
+		// The objects which were created for the SupervisorConnection are garbage collected along with it.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get SupervisorConnection %s: %w", ctx.Key.Name, err)
+	}
+
+	ownerRef := metav1.NewControllerRef(conn, auth1alpha1.SchemeGroupVersion.WithKind(kind))
+
+	authenticatorCondition, authenticatorErr := c.ensureJWTAuthenticator(ctx.Context, conn, ownerRef)
+	bindingCondition, bindingErr := c.ensureBootstrapBinding(ctx.Context, conn, ownerRef)
+
+	// Don't point clients at a JWTAuthenticator which belongs to someone else.
+	authenticatorName := conn.Name
+	if authenticatorCondition != nil && authenticatorCondition.Reason == reasonNameConflict {
+		authenticatorName = ""
+	}
+
+	return utilerrors.NewAggregate([]error{
+		authenticatorErr,
+		bindingErr,
+		c.updateStatus(ctx.Context, conn, authenticatorName, authenticatorCondition, bindingCondition),
+	})
+}
+
+// ensureJWTAuthenticator creates or updates the JWTAuthenticator of the SupervisorConnection, and returns the
+// AuthenticatorReady condition, which mirrors the Ready condition of the JWTAuthenticator.
+func (c *controller) ensureJWTAuthenticator(
+	ctx context.Context,
+	conn *auth1alpha1.SupervisorConnection,
+	ownerRef *metav1.OwnerReference,
+) (*auth1alpha1.Condition, error) {
+	spec := auth1alpha1.JWTAuthenticatorSpec{
+		Issuer:   conn.Spec.Issuer,
+		Audience: conn.Spec.Audience,
+	}
+	if conn.Spec.TLS != nil {
+		spec.TLS = &auth1alpha1.JWTTLSSpec{CertificateAuthorityData: conn.Spec.TLS.CertificateAuthorityData}
+	}
+
+	existing, err := c.jwtAuthenticators.Lister().Get(conn.Name)
+	switch {
+	case k8serrors.IsNotFound(err):
+		authenticator := &auth1alpha1.JWTAuthenticator{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            conn.Name,
+				Labels:          c.labels,
+				OwnerReferences: []metav1.OwnerReference{*ownerRef},
+			},
+			Spec: spec,
+		}
+		if _, err := c.client.AuthenticationV1alpha1().JWTAuthenticators().Create(ctx, authenticator, metav1.CreateOptions{}); err != nil {
+			err = fmt.Errorf("failed to create JWTAuthenticator %s: %w", conn.Name, err)
+			return falseCondition(typeAuthenticatorReady, reasonFailedToCreate, err.Error()), err
+		}
+		plog.Info("created JWTAuthenticator for SupervisorConnection", "supervisorConnection", klog.KObj(conn), "issuer", spec.Issuer)
+		return pendingAuthenticatorCondition(conn.Name), nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to get JWTAuthenticator %s: %w", conn.Name, err)
+	case !metav1.IsControlledBy(existing, conn):
+		return falseCondition(typeAuthenticatorReady, reasonNameConflict,
+			fmt.Sprintf("JWTAuthenticator %q already exists and is not owned by this SupervisorConnection", conn.Name)), nil
+	case !equality.Semantic.DeepEqual(existing.Spec, spec):
+		updated := existing.DeepCopy()
+		updated.Spec = spec
+		if _, err := c.client.AuthenticationV1alpha1().JWTAuthenticators().Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			err = fmt.Errorf("failed to update JWTAuthenticator %s: %w", conn.Name, err)
+			return falseCondition(typeAuthenticatorReady, reasonFailedToUpdate, err.Error()), err
+		}
+		plog.Info("updated JWTAuthenticator for SupervisorConnection", "supervisorConnection", klog.KObj(conn), "issuer", spec.Issuer)
+		return pendingAuthenticatorCondition(conn.Name), nil
+	}
+
+	// The status of the JWTAuthenticator only describes its current spec once the cache filler has observed it.
+	var ready *auth1alpha1.Condition
+	for i := range existing.Status.Conditions {
+		if condition := &existing.Status.Conditions[i]; condition.Type == auth1alpha1.ConditionTypeReady &&
+			condition.ObservedGeneration == existing.Generation {
+			ready = condition
+		}
+	}
+	switch {
+	case ready == nil:
+		return pendingAuthenticatorCondition(conn.Name), nil
+	case ready.Status == auth1alpha1.ConditionTrue:
+		return &auth1alpha1.Condition{
+			Type:    typeAuthenticatorReady,
+			Status:  auth1alpha1.ConditionTrue,
+			Reason:  reasonSuccess,
+			Message: fmt.Sprintf("JWTAuthenticator %q is ready", conn.Name),
+		}, nil
+	default:
+		return &auth1alpha1.Condition{
+			Type:    typeAuthenticatorReady,
+			Status:  ready.Status,
+			Reason:  ready.Reason,
+			Message: fmt.Sprintf("JWTAuthenticator %q is not ready: %s", conn.Name, ready.Message),
+		}, nil
+	}
+}
+
+// ensureBootstrapBinding creates, updates, or deletes the ClusterRoleBinding for the bootstrap group of the
+// SupervisorConnection, and returns the BootstrapBindingReady condition.
+func (c *controller) ensureBootstrapBinding(
+	ctx context.Context,
+	conn *auth1alpha1.SupervisorConnection,
+	ownerRef *metav1.OwnerReference,
+) (*auth1alpha1.Condition, error) {
+	name := bindingNamePrefix + conn.Name
+
+	existing, err := c.clusterRoleBindings.Lister().Get(name)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get ClusterRoleBinding %s: %w", name, err)
+	}
+	if existing != nil && !metav1.IsControlledBy(existing, conn) {
+		return falseCondition(typeBootstrapBindingReady, reasonNameConflict,
+			fmt.Sprintf("ClusterRoleBinding %q already exists and is not owned by this SupervisorConnection", name)), nil
+	}
+
+	if conn.Spec.Bootstrap == nil {
+		if existing != nil {
+			if err := c.k8sClient.RbacV1().ClusterRoleBindings().Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+				err = fmt.Errorf("failed to delete ClusterRoleBinding %s: %w", name, err)
+				return falseCondition(typeBootstrapBindingReady, reasonFailedToUpdate, err.Error()), err
+			}
+			plog.Info("deleted bootstrap ClusterRoleBinding for SupervisorConnection", "supervisorConnection", klog.KObj(conn))
+		}
+		return &auth1alpha1.Condition{
+			Type:    typeBootstrapBindingReady,
+			Status:  auth1alpha1.ConditionTrue,
+			Reason:  reasonNotRequested,
+			Message: "no bootstrap group is configured",
+		}, nil
+	}
+
+	clusterRole := conn.Spec.Bootstrap.ClusterRole
+	if clusterRole == "" {
+		clusterRole = defaultClusterRole
+	}
+	binding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Labels:          c.labels,
+			OwnerReferences: []metav1.OwnerReference{*ownerRef},
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:     rbacv1.GroupKind,
+			APIGroup: rbacv1.GroupName,
+			Name:     conn.Spec.Bootstrap.Group,
+		}},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     clusterRole,
+		},
+	}
+
+	switch {
+	case existing != nil && equality.Semantic.DeepEqual(existing.RoleRef, binding.RoleRef) &&
+		equality.Semantic.DeepEqual(existing.Subjects, binding.Subjects):
+		// Already up to date.
+	case existing != nil && equality.Semantic.DeepEqual(existing.RoleRef, binding.RoleRef):
+		updated := existing.DeepCopy()
+		updated.Subjects = binding.Subjects
+		if _, err := c.k8sClient.RbacV1().ClusterRoleBindings().Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			err = fmt.Errorf("failed to update ClusterRoleBinding %s: %w", name, err)
+			return falseCondition(typeBootstrapBindingReady, reasonFailedToUpdate, err.Error()), err
+		}
+		plog.Info("updated bootstrap ClusterRoleBinding for SupervisorConnection", "supervisorConnection", klog.KObj(conn), "group", conn.Spec.Bootstrap.Group)
+	default:
+		// The roleRef of a binding cannot be changed, so a binding for another ClusterRole is replaced.
+		if existing != nil {
+			if err := c.k8sClient.RbacV1().ClusterRoleBindings().Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+				err = fmt.Errorf("failed to delete ClusterRoleBinding %s: %w", name, err)
+				return falseCondition(typeBootstrapBindingReady, reasonFailedToUpdate, err.Error()), err
+			}
+		}
+		if _, err := c.k8sClient.RbacV1().ClusterRoleBindings().Create(ctx, binding, metav1.CreateOptions{}); err != nil {
+			err = fmt.Errorf("failed to create ClusterRoleBinding %s: %w", name, err)
+			return falseCondition(typeBootstrapBindingReady, reasonFailedToCreate, err.Error()), err
+		}
+		plog.Info("created bootstrap ClusterRoleBinding for SupervisorConnection", "supervisorConnection", klog.KObj(conn),
+			"group", conn.Spec.Bootstrap.Group, "clusterRole", clusterRole)
+	}
+
+	return &auth1alpha1.Condition{
+		Type:    typeBootstrapBindingReady,
+		Status:  auth1alpha1.ConditionTrue,
+		Reason:  reasonSuccess,
+		Message: fmt.Sprintf("bound ClusterRole %q to group %q", clusterRole, conn.Spec.Bootstrap.Group),
+	}, nil
+}
+
+func (c *controller) updateStatus(
+	ctx context.Context,
+	conn *auth1alpha1.SupervisorConnection,
+	authenticatorName string,
+	conditions ...*auth1alpha1.Condition,
+) error {
+	var newConditions []auth1alpha1.Condition
+	for _, condition := range conditions {
+		if condition != nil {
+			newConditions = append(newConditions, *condition)
+		}
+	}
+
+	merged := conditionsutil.FromAuthenticationV1alpha1(conn.Status.Conditions)
+	changed := conditionsutil.MergeWithReady(&merged, conn.Generation, metav1.NewTime(c.clock.Now()), conditionsutil.FromAuthenticationV1alpha1(newConditions)...)
+	if !changed && conn.Status.JWTAuthenticatorName == authenticatorName {
+		return nil
+	}
+
+	updated := conn.DeepCopy()
+	updated.Status.Conditions = conditionsutil.ToAuthenticationV1alpha1(merged)
+	updated.Status.JWTAuthenticatorName = authenticatorName
+	if _, err := c.client.AuthenticationV1alpha1().SupervisorConnections().UpdateStatus(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update status of SupervisorConnection %s: %w", conn.Name, err)
+	}
+	return nil
+}
+
+func pendingAuthenticatorCondition(name string) *auth1alpha1.Condition {
+	return &auth1alpha1.Condition{
+		Type:    typeAuthenticatorReady,
+		Status:  auth1alpha1.ConditionUnknown,
+		Reason:  reasonPending,
+		Message: fmt.Sprintf("waiting for JWTAuthenticator %q to be validated", name),
+	}
+}
+
+func falseCondition(conditionType, reason, message string) *auth1alpha1.Condition {
+	return &auth1alpha1.Condition{
+		Type:    conditionType,
+		Status:  auth1alpha1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}
+}
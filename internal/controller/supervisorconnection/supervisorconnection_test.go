@@ -0,0 +1,387 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package supervisorconnection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	kubeinformers "k8s.io/client-go/informers"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	coretesting "k8s.io/client-go/testing"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	pinnipedinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
+	"go.pinniped.dev/internal/controllerlib"
+)
+
+func TestController(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, time.April, 1, 2, 3, 4, 0, time.UTC)
+	labels := map[string]string{"app": "concierge"}
+
+	someConnection := &auth1alpha1.SupervisorConnection{
+		ObjectMeta: metav1.ObjectMeta{Name: "central", UID: "some-uid", Generation: 2},
+		Spec: auth1alpha1.SupervisorConnectionSpec{
+			Issuer:   "https://supervisor.example.com/issuer",
+			Audience: "cluster-1",
+			TLS:      &auth1alpha1.TLSSpec{CertificateAuthorityData: "some-ca-data"},
+		},
+	}
+	withBootstrap := func(group, clusterRole string) *auth1alpha1.SupervisorConnection {
+		conn := someConnection.DeepCopy()
+		conn.Spec.Bootstrap = &auth1alpha1.SupervisorConnectionBootstrapSpec{Group: group, ClusterRole: clusterRole}
+		return conn
+	}
+	ownerRefs := []metav1.OwnerReference{{
+		APIVersion:         "authentication.concierge.pinniped.dev/v1alpha1",
+		Kind:               "SupervisorConnection",
+		Name:               "central",
+		UID:                "some-uid",
+		Controller:         boolPtr(true),
+		BlockOwnerDeletion: boolPtr(true),
+	}}
+
+	someAuthenticator := &auth1alpha1.JWTAuthenticator{
+		ObjectMeta: metav1.ObjectMeta{Name: "central", Labels: labels, OwnerReferences: ownerRefs},
+		Spec: auth1alpha1.JWTAuthenticatorSpec{
+			Issuer:   "https://supervisor.example.com/issuer",
+			Audience: "cluster-1",
+			TLS:      &auth1alpha1.JWTTLSSpec{CertificateAuthorityData: "some-ca-data"},
+		},
+	}
+	authenticatorWithReady := func(observedGeneration int64, status auth1alpha1.ConditionStatus, reason, message string) *auth1alpha1.JWTAuthenticator {
+		a := someAuthenticator.DeepCopy()
+		a.Generation = 3
+		a.Status.Conditions = []auth1alpha1.Condition{{
+			Type:               "Ready",
+			Status:             status,
+			ObservedGeneration: observedGeneration,
+			Reason:             reason,
+			Message:            message,
+		}}
+		return a
+	}
+	someBinding := func(group, clusterRole string) *rbacv1.ClusterRoleBinding {
+		return &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "pinniped-supervisor-connection-central",
+				Labels:          labels,
+				OwnerReferences: ownerRefs,
+			},
+			Subjects: []rbacv1.Subject{{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: group}},
+			RoleRef:  rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: clusterRole},
+		}
+	}
+	notOwnedAuthenticator := someAuthenticator.DeepCopy()
+	notOwnedAuthenticator.OwnerReferences = nil
+	notOwnedBinding := someBinding("platform-team", "cluster-admin")
+	notOwnedBinding.OwnerReferences = nil
+
+	condition := func(conditionType string, status auth1alpha1.ConditionStatus, reason, message string) auth1alpha1.Condition {
+		return auth1alpha1.Condition{
+			Type:               conditionType,
+			Status:             status,
+			ObservedGeneration: 2,
+			LastTransitionTime: metav1.NewTime(now),
+			Reason:             reason,
+			Message:            message,
+		}
+	}
+	pendingCondition := condition("AuthenticatorReady", auth1alpha1.ConditionUnknown, "Pending", `waiting for JWTAuthenticator "central" to be validated`)
+	authenticatorReadyCondition := condition("AuthenticatorReady", auth1alpha1.ConditionTrue, "Success", `JWTAuthenticator "central" is ready`)
+	noBootstrapCondition := condition("BootstrapBindingReady", auth1alpha1.ConditionTrue, "NotRequested", "no bootstrap group is configured")
+	boundCondition := func(clusterRole, group string) auth1alpha1.Condition {
+		return condition("BootstrapBindingReady", auth1alpha1.ConditionTrue, "Success", `bound ClusterRole "`+clusterRole+`" to group "`+group+`"`)
+	}
+	readyCondition := condition("Ready", auth1alpha1.ConditionTrue, "Success", "all conditions are true")
+	pendingReadyCondition := condition("Ready", auth1alpha1.ConditionUnknown, "Pending", `AuthenticatorReady is Unknown: waiting for JWTAuthenticator "central" to be validated`)
+	withStatus := func(conn *auth1alpha1.SupervisorConnection, name string, conditions ...auth1alpha1.Condition) *auth1alpha1.SupervisorConnection {
+		conn = conn.DeepCopy()
+		conn.Status.JWTAuthenticatorName = name
+		conn.Status.Conditions = conditions
+		return conn
+	}
+
+	tests := []struct {
+		name                string
+		syncKey             controllerlib.Key
+		connections         []runtime.Object
+		authenticators      []runtime.Object
+		bindings            []runtime.Object
+		addReactors         func(*pinnipedfake.Clientset, *kubernetesfake.Clientset)
+		wantErr             string
+		wantActions         []coretesting.Action
+		wantKubeActions     []coretesting.Action
+		wantStatusUpdate    *auth1alpha1.SupervisorConnection
+		wantNoStatusUpdates bool
+	}{
+		{
+			name:                "not found",
+			syncKey:             controllerlib.Key{Name: "central"},
+			wantNoStatusUpdates: true,
+		},
+		{
+			name:        "new connection without a bootstrap group creates the JWTAuthenticator",
+			syncKey:     controllerlib.Key{Name: "central"},
+			connections: []runtime.Object{someConnection},
+			wantActions: []coretesting.Action{
+				coretesting.NewRootCreateAction(jwtAuthenticatorsGVR, someAuthenticator),
+			},
+			wantStatusUpdate: withStatus(someConnection, "central", pendingCondition, noBootstrapCondition, pendingReadyCondition),
+		},
+		{
+			name:        "new connection with a bootstrap group creates the ClusterRoleBinding for the default ClusterRole",
+			syncKey:     controllerlib.Key{Name: "central"},
+			connections: []runtime.Object{withBootstrap("platform-team", "")},
+			wantActions: []coretesting.Action{
+				coretesting.NewRootCreateAction(jwtAuthenticatorsGVR, someAuthenticator),
+			},
+			wantKubeActions: []coretesting.Action{
+				coretesting.NewRootCreateAction(clusterRoleBindingsGVR, someBinding("platform-team", "view")),
+			},
+			wantStatusUpdate: withStatus(withBootstrap("platform-team", ""), "central",
+				pendingCondition, boundCondition("view", "platform-team"), pendingReadyCondition),
+		},
+		{
+			name:           "ready authenticator and up to date binding make the connection ready",
+			syncKey:        controllerlib.Key{Name: "central"},
+			connections:    []runtime.Object{withBootstrap("platform-team", "edit")},
+			authenticators: []runtime.Object{authenticatorWithReady(3, auth1alpha1.ConditionTrue, "Success", "all conditions are true")},
+			bindings:       []runtime.Object{someBinding("platform-team", "edit")},
+			wantStatusUpdate: withStatus(withBootstrap("platform-team", "edit"), "central",
+				authenticatorReadyCondition, boundCondition("edit", "platform-team"), readyCondition),
+		},
+		{
+			name:    "status is not updated again when nothing changed",
+			syncKey: controllerlib.Key{Name: "central"},
+			connections: []runtime.Object{withStatus(someConnection, "central",
+				authenticatorReadyCondition, noBootstrapCondition, readyCondition)},
+			authenticators:      []runtime.Object{authenticatorWithReady(3, auth1alpha1.ConditionTrue, "Success", "all conditions are true")},
+			wantNoStatusUpdates: true,
+		},
+		{
+			name:           "authenticator which is not ready is reported",
+			syncKey:        controllerlib.Key{Name: "central"},
+			connections:    []runtime.Object{someConnection},
+			authenticators: []runtime.Object{authenticatorWithReady(3, auth1alpha1.ConditionFalse, "Unreachable", "IssuerReachable is False: connection refused")},
+			wantStatusUpdate: withStatus(someConnection, "central",
+				condition("AuthenticatorReady", auth1alpha1.ConditionFalse, "Unreachable",
+					`JWTAuthenticator "central" is not ready: IssuerReachable is False: connection refused`),
+				noBootstrapCondition,
+				condition("Ready", auth1alpha1.ConditionFalse, "Unreachable",
+					`AuthenticatorReady is False: JWTAuthenticator "central" is not ready: IssuerReachable is False: connection refused`),
+			),
+		},
+		{
+			name:             "authenticator status for an older generation is ignored",
+			syncKey:          controllerlib.Key{Name: "central"},
+			connections:      []runtime.Object{someConnection},
+			authenticators:   []runtime.Object{authenticatorWithReady(2, auth1alpha1.ConditionTrue, "Success", "all conditions are true")},
+			wantStatusUpdate: withStatus(someConnection, "central", pendingCondition, noBootstrapCondition, pendingReadyCondition),
+		},
+		{
+			name:    "changed connection updates the JWTAuthenticator",
+			syncKey: controllerlib.Key{Name: "central"},
+			connections: []runtime.Object{func() runtime.Object {
+				conn := someConnection.DeepCopy()
+				conn.Spec.Audience = "cluster-2"
+				conn.Spec.TLS = nil
+				return conn
+			}()},
+			authenticators: []runtime.Object{authenticatorWithReady(3, auth1alpha1.ConditionTrue, "Success", "all conditions are true")},
+			wantActions: []coretesting.Action{
+				coretesting.NewRootUpdateAction(jwtAuthenticatorsGVR, func() runtime.Object {
+					a := authenticatorWithReady(3, auth1alpha1.ConditionTrue, "Success", "all conditions are true")
+					a.Spec.Audience = "cluster-2"
+					a.Spec.TLS = nil
+					return a
+				}()),
+			},
+			wantStatusUpdate: func() *auth1alpha1.SupervisorConnection {
+				conn := withStatus(someConnection, "central", pendingCondition, noBootstrapCondition, pendingReadyCondition)
+				conn.Spec.Audience = "cluster-2"
+				conn.Spec.TLS = nil
+				return conn
+			}(),
+		},
+		{
+			name:           "JWTAuthenticator of the same name which is not owned by the connection is left alone",
+			syncKey:        controllerlib.Key{Name: "central"},
+			connections:    []runtime.Object{someConnection},
+			authenticators: []runtime.Object{notOwnedAuthenticator},
+			wantStatusUpdate: withStatus(someConnection, "",
+				condition("AuthenticatorReady", auth1alpha1.ConditionFalse, "NameConflict",
+					`JWTAuthenticator "central" already exists and is not owned by this SupervisorConnection`),
+				noBootstrapCondition,
+				condition("Ready", auth1alpha1.ConditionFalse, "NameConflict",
+					`AuthenticatorReady is False: JWTAuthenticator "central" already exists and is not owned by this SupervisorConnection`),
+			),
+		},
+		{
+			name:           "binding for another ClusterRole is replaced",
+			syncKey:        controllerlib.Key{Name: "central"},
+			connections:    []runtime.Object{withBootstrap("platform-team", "edit")},
+			authenticators: []runtime.Object{authenticatorWithReady(3, auth1alpha1.ConditionTrue, "Success", "all conditions are true")},
+			bindings:       []runtime.Object{someBinding("platform-team", "view")},
+			wantKubeActions: []coretesting.Action{
+				coretesting.NewRootDeleteAction(clusterRoleBindingsGVR, "pinniped-supervisor-connection-central"),
+				coretesting.NewRootCreateAction(clusterRoleBindingsGVR, someBinding("platform-team", "edit")),
+			},
+			wantStatusUpdate: withStatus(withBootstrap("platform-team", "edit"), "central",
+				authenticatorReadyCondition, boundCondition("edit", "platform-team"), readyCondition),
+		},
+		{
+			name:           "binding for another group is updated",
+			syncKey:        controllerlib.Key{Name: "central"},
+			connections:    []runtime.Object{withBootstrap("new-team", "view")},
+			authenticators: []runtime.Object{authenticatorWithReady(3, auth1alpha1.ConditionTrue, "Success", "all conditions are true")},
+			bindings:       []runtime.Object{someBinding("platform-team", "view")},
+			wantKubeActions: []coretesting.Action{
+				coretesting.NewRootUpdateAction(clusterRoleBindingsGVR, someBinding("new-team", "view")),
+			},
+			wantStatusUpdate: withStatus(withBootstrap("new-team", "view"), "central",
+				authenticatorReadyCondition, boundCondition("view", "new-team"), readyCondition),
+		},
+		{
+			name:           "removing the bootstrap group deletes the binding",
+			syncKey:        controllerlib.Key{Name: "central"},
+			connections:    []runtime.Object{someConnection},
+			authenticators: []runtime.Object{authenticatorWithReady(3, auth1alpha1.ConditionTrue, "Success", "all conditions are true")},
+			bindings:       []runtime.Object{someBinding("platform-team", "view")},
+			wantKubeActions: []coretesting.Action{
+				coretesting.NewRootDeleteAction(clusterRoleBindingsGVR, "pinniped-supervisor-connection-central"),
+			},
+			wantStatusUpdate: withStatus(someConnection, "central", authenticatorReadyCondition, noBootstrapCondition, readyCondition),
+		},
+		{
+			name:           "ClusterRoleBinding of the same name which is not owned by the connection is left alone",
+			syncKey:        controllerlib.Key{Name: "central"},
+			connections:    []runtime.Object{withBootstrap("platform-team", "view")},
+			authenticators: []runtime.Object{authenticatorWithReady(3, auth1alpha1.ConditionTrue, "Success", "all conditions are true")},
+			bindings:       []runtime.Object{notOwnedBinding},
+			wantStatusUpdate: withStatus(withBootstrap("platform-team", "view"), "central",
+				authenticatorReadyCondition,
+				condition("BootstrapBindingReady", auth1alpha1.ConditionFalse, "NameConflict",
+					`ClusterRoleBinding "pinniped-supervisor-connection-central" already exists and is not owned by this SupervisorConnection`),
+				condition("Ready", auth1alpha1.ConditionFalse, "NameConflict",
+					`BootstrapBindingReady is False: ClusterRoleBinding "pinniped-supervisor-connection-central" already exists and is not owned by this SupervisorConnection`),
+			),
+		},
+		{
+			name:        "failure to create the objects is reported in the status and returned",
+			syncKey:     controllerlib.Key{Name: "central"},
+			connections: []runtime.Object{withBootstrap("platform-team", "cluster-admin")},
+			addReactors: func(client *pinnipedfake.Clientset, kubeClient *kubernetesfake.Clientset) {
+				client.PrependReactor("create", "jwtauthenticators", func(coretesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("some create error")
+				})
+				kubeClient.PrependReactor("create", "clusterrolebindings", func(coretesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New(`clusterrolebindings.rbac.authorization.k8s.io is forbidden: user cannot bind clusterrole "cluster-admin"`)
+				})
+			},
+			wantErr: `[failed to create JWTAuthenticator central: some create error, ` +
+				`failed to create ClusterRoleBinding pinniped-supervisor-connection-central: ` +
+				`clusterrolebindings.rbac.authorization.k8s.io is forbidden: user cannot bind clusterrole "cluster-admin"]`,
+			wantActions: []coretesting.Action{
+				coretesting.NewRootCreateAction(jwtAuthenticatorsGVR, someAuthenticator),
+			},
+			wantKubeActions: []coretesting.Action{
+				coretesting.NewRootCreateAction(clusterRoleBindingsGVR, someBinding("platform-team", "cluster-admin")),
+			},
+			wantStatusUpdate: withStatus(withBootstrap("platform-team", "cluster-admin"), "central",
+				condition("AuthenticatorReady", auth1alpha1.ConditionFalse, "FailedToCreate", "failed to create JWTAuthenticator central: some create error"),
+				condition("BootstrapBindingReady", auth1alpha1.ConditionFalse, "FailedToCreate",
+					`failed to create ClusterRoleBinding pinniped-supervisor-connection-central: clusterrolebindings.rbac.authorization.k8s.io is forbidden: user cannot bind clusterrole "cluster-admin"`),
+				condition("Ready", auth1alpha1.ConditionFalse, "FailedToCreate",
+					"AuthenticatorReady is False: failed to create JWTAuthenticator central: some create error"),
+			),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeClient := pinnipedfake.NewSimpleClientset(append(tt.connections, tt.authenticators...)...)
+			informers := pinnipedinformers.NewSharedInformerFactory(fakeClient, 0)
+			fakeKubeClient := kubernetesfake.NewSimpleClientset(tt.bindings...)
+			kubeInformers := kubeinformers.NewSharedInformerFactory(fakeKubeClient, 0)
+			if tt.addReactors != nil {
+				tt.addReactors(fakeClient, fakeKubeClient)
+			}
+
+			controller := NewController(
+				fakeClient,
+				fakeKubeClient,
+				informers.Authentication().V1alpha1().SupervisorConnections(),
+				informers.Authentication().V1alpha1().JWTAuthenticators(),
+				kubeInformers.Rbac().V1().ClusterRoleBindings(),
+				labels,
+				clock.NewFakeClock(now),
+				controllerlib.WithInformer,
+			)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			informers.Start(ctx.Done())
+			kubeInformers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			syncCtx := controllerlib.Context{Context: ctx, Key: tt.syncKey}
+
+			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			var actions []coretesting.Action
+			var statusUpdates []runtime.Object
+			for _, action := range withoutListAndWatch(fakeClient.Actions()) {
+				if update, ok := action.(coretesting.UpdateAction); ok && update.GetSubresource() == "status" {
+					statusUpdates = append(statusUpdates, update.GetObject())
+					continue
+				}
+				actions = append(actions, action)
+			}
+			require.Equal(t, tt.wantActions, actions)
+			require.Equal(t, tt.wantKubeActions, withoutListAndWatch(fakeKubeClient.Actions()))
+
+			if tt.wantNoStatusUpdates {
+				require.Empty(t, statusUpdates)
+			} else {
+				require.Equal(t, []runtime.Object{tt.wantStatusUpdate}, statusUpdates)
+			}
+		})
+	}
+}
+
+//nolint:gochecknoglobals
+var (
+	jwtAuthenticatorsGVR   = auth1alpha1.SchemeGroupVersion.WithResource("jwtauthenticators")
+	clusterRoleBindingsGVR = rbacv1.SchemeGroupVersion.WithResource("clusterrolebindings")
+)
+
+func withoutListAndWatch(actions []coretesting.Action) []coretesting.Action {
+	var result []coretesting.Action
+	for _, action := range actions {
+		if action.GetVerb() != "list" && action.GetVerb() != "watch" {
+			result = append(result, action)
+		}
+	}
+	return result
+}
+
+func boolPtr(b bool) *bool { return &b }
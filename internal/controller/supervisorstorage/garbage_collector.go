@@ -33,6 +33,7 @@ func GarbageCollectorController(
 	kubeClient kubernetes.Interface,
 	secretInformer corev1informers.SecretInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	opts ...controllerlib.Option,
 ) controllerlib.Controller {
 	isSecretWithGCAnnotation := func(obj metav1.Object) bool {
 		secret, ok := obj.(*v1.Secret)
@@ -51,18 +52,20 @@ func GarbageCollectorController(
 				clock:          clock,
 			},
 		},
-		withInformer(
-			secretInformer,
-			controllerlib.FilterFuncs{
-				AddFunc: isSecretWithGCAnnotation,
-				UpdateFunc: func(oldObj, newObj metav1.Object) bool {
-					return isSecretWithGCAnnotation(oldObj) || isSecretWithGCAnnotation(newObj)
+		append([]controllerlib.Option{
+			withInformer(
+				secretInformer,
+				controllerlib.FilterFuncs{
+					AddFunc: isSecretWithGCAnnotation,
+					UpdateFunc: func(oldObj, newObj metav1.Object) bool {
+						return isSecretWithGCAnnotation(oldObj) || isSecretWithGCAnnotation(newObj)
+					},
+					DeleteFunc: func(obj metav1.Object) bool { return false }, // ignore all deletes
+					ParentFunc: nil,
 				},
-				DeleteFunc: func(obj metav1.Object) bool { return false }, // ignore all deletes
-				ParentFunc: nil,
-			},
-			controllerlib.InformerOption{},
-		),
+				controllerlib.InformerOption{},
+			),
+		}, opts...)...,
 	)
 }
 
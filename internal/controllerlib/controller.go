@@ -194,7 +194,9 @@ func (c *controller) processNextWorkItem(ctx context.Context) {
 		Recorder: c.recorder,
 	}
 
+	start := time.Now()
 	err := c.sync(syncCtx)
+	observeSync(c.Name(), start, err)
 	c.handleKey(key, err)
 }
 
@@ -208,17 +210,19 @@ func (c *controller) handleKey(key Key, err error) {
 	shouldRetry := retryForever || c.queue.NumRequeues(key) < c.maxRetries
 
 	if !shouldRetry {
-		utilruntime.HandleError(fmt.Errorf("%s: dropping key %v out of the queue: %w", c.Name(), key, err))
+		plog.Error("dropping key out of the queue", err, "controller", c.Name(), "resource", plog.KRef(key.Namespace, key.Name))
+		droppedKeysTotal.WithLabelValues(c.Name()).Inc()
 		c.queue.Forget(key)
 		return
 	}
 
 	if errors.Is(err, ErrSyntheticRequeue) {
 		// logging this helps detecting wedged controllers with missing pre-requirements
-		klog.V(4).InfoS("requested synthetic requeue", "controller", c.Name(), "key", key)
+		klog.V(4).InfoS("requested synthetic requeue", "controller", c.Name(), "resource", plog.KRef(key.Namespace, key.Name))
 	} else {
-		utilruntime.HandleError(fmt.Errorf("%s: %v failed with: %w", c.Name(), key, err))
+		plog.Error("sync failed", err, "controller", c.Name(), "resource", plog.KRef(key.Namespace, key.Name))
 	}
 
+	requeuesTotal.WithLabelValues(c.Name(), syncResult(err)).Inc()
 	c.queue.AddRateLimited(key)
 }
@@ -0,0 +1,177 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controllerlib
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"go.pinniped.dev/internal/plog"
+)
+
+// The same timing as kube-controller-manager. A leader which is terminated gracefully releases its Lease, so the
+// lease duration only matters when the leader dies without doing so.
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// LeaderElector holds a leader election with a coordination.k8s.io/v1 Lease, so that several replicas of a controller
+// manager can run at the same time while only one of them, the leader, syncs the controllers which were added to the
+// Manager WithLeaderElectedController. The standby replicas keep their informers and queues warm and remember the keys
+// which they did not sync, so that they can take over as soon as the leader is gone.
+type LeaderElector struct {
+	identity string
+	elector  *leaderelection.LeaderElector
+
+	// lock is held for reading while a Syncer runs on the leader, so that stepping down waits for the in-flight syncs.
+	lock    sync.RWMutex
+	leading bool
+	stopped bool
+
+	// leaderCtx is done as soon as the Lease could not be renewed. The contexts of the syncs on the leader are derived
+	// from it, so that their requests are cancelled before another replica can take over.
+	leaderCtx context.Context
+
+	// deferred holds the keys which were not synced on this replica, by the queue which they came from.
+	deferredLock sync.Mutex
+	deferred     map[Queue]map[Key]struct{}
+}
+
+// NewLeaderElector returns a LeaderElector for the Lease with the given namespace and name. The identity must be
+// unique among the replicas, e.g. the name of the pod.
+func NewLeaderElector(client kubernetes.Interface, namespace, name, identity string) (*LeaderElector, error) {
+	return newLeaderElector(client, namespace, name, identity, leaseDuration, renewDeadline, retryPeriod)
+}
+
+func newLeaderElector(client kubernetes.Interface, namespace, name, identity string, leaseDuration, renewDeadline, retryPeriod time.Duration) (*LeaderElector, error) {
+	l := &LeaderElector{identity: identity, deferred: map[Queue]map[Key]struct{}{}}
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Client:     client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: l.startLeading,
+			OnStoppedLeading: l.stopLeading,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create leader election for lease %s/%s: %w", namespace, name, err)
+	}
+	l.elector = elector
+	return l, nil
+}
+
+// Run campaigns for the Lease, and keeps doing so after losing it, until ctx is done. Then it waits for the syncs which
+// are still running, releases the Lease if this replica is the leader, and returns. Callers should wait for Run to
+// return before they exit, so that another replica can take over immediately.
+func (l *LeaderElector) Run(ctx context.Context) {
+	electionCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for electionCtx.Err() == nil {
+			l.elector.Run(electionCtx)
+		}
+	}()
+
+	<-ctx.Done()
+	l.lock.Lock()
+	l.stopped = true
+	l.leading = false
+	l.lock.Unlock()
+
+	cancel()
+	<-done
+}
+
+// IsLeader returns whether this replica currently syncs the controllers which were added WithLeaderElectedController.
+func (l *LeaderElector) IsLeader() bool {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.leading
+}
+
+func (l *LeaderElector) startLeading(leaderCtx context.Context) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	// The callback runs in its own goroutine, so the Lease may already be lost again.
+	if l.stopped || l.leading || leaderCtx.Err() != nil {
+		return
+	}
+	l.leading = true
+	l.leaderCtx = leaderCtx
+	plog.Info("started leading", "identity", l.identity)
+
+	// Nothing can be deferred concurrently, since syncs hold the read lock while they check whether they are leading.
+	for queue, keys := range l.deferred {
+		for key := range keys {
+			queue.Add(key)
+		}
+	}
+	l.deferred = map[Queue]map[Key]struct{}{}
+}
+
+// stopLeading is called after the Lease was lost, once the in-flight syncs, whose contexts are already done, return.
+func (l *LeaderElector) stopLeading() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if !l.leading {
+		return
+	}
+	l.leading = false
+	plog.Info("stopped leading", "identity", l.identity)
+}
+
+func (l *LeaderElector) sync(syncer Syncer, ctx Context) error {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	if l.leading {
+		syncCtx, cancel := context.WithCancel(ctx.Context)
+		defer cancel()
+		go func() {
+			select {
+			case <-l.leaderCtx.Done():
+				cancel()
+			case <-syncCtx.Done():
+			}
+		}()
+		ctx.Context = syncCtx
+		return syncer.Sync(ctx)
+	}
+
+	l.deferredLock.Lock()
+	defer l.deferredLock.Unlock()
+	if l.deferred[ctx.Queue] == nil {
+		l.deferred[ctx.Queue] = map[Key]struct{}{}
+	}
+	l.deferred[ctx.Queue][ctx.Key] = struct{}{}
+	return nil
+}
+
+// leaderElected only runs the Syncer of the controller while the LeaderElector is the leader. On the standby replicas,
+// each key is remembered instead of synced, and it is added to the queue again once they become the leader.
+func leaderElected(controller Controller, elector *LeaderElector) {
+	controller.wrap(func(syncer Syncer) Syncer {
+		return SyncFunc(func(ctx Context) error {
+			return elector.sync(syncer, ctx)
+		})
+	})
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controllerlib
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestLeaderElection(t *testing.T) {
+	kubeClient := kubernetesfake.NewSimpleClientset()
+	newElector := func(identity string) *LeaderElector {
+		t.Helper()
+		elector, err := newLeaderElector(kubeClient, "some-namespace", "some-lease", identity, 2*time.Second, time.Second, 20*time.Millisecond)
+		require.NoError(t, err)
+		return elector
+	}
+	first, second := newElector("first-pod"), newElector("second-pod")
+
+	var synced []Key
+	c := New(Config{
+		Name: "test-leader-election",
+		Syncer: SyncFunc(func(ctx Context) error {
+			synced = append(synced, ctx.Key)
+			return nil
+		}),
+	})
+	leaderElected(c, second)
+	TestRunSynchronously(t, c)
+	queue := &queueWrapper{queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())}
+	t.Cleanup(queue.queue.ShutDown)
+	key := Key{Namespace: "some-namespace", Name: "some-name"}
+
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	firstDone := make(chan struct{})
+	go func() {
+		defer close(firstDone)
+		first.Run(firstCtx)
+	}()
+	t.Cleanup(stopFirst)
+	require.Eventually(t, first.IsLeader, 10*time.Second, 10*time.Millisecond)
+
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	secondDone := make(chan struct{})
+	go func() {
+		defer close(secondDone)
+		second.Run(secondCtx)
+	}()
+	t.Cleanup(stopSecond)
+
+	// The standby replica remembers the key instead of syncing it.
+	require.NoError(t, TestSync(t, c, Context{Context: context.Background(), Key: key, Queue: queue}))
+	require.NoError(t, TestSync(t, c, Context{Context: context.Background(), Key: key, Queue: queue}))
+	require.Empty(t, synced)
+	require.False(t, second.IsLeader())
+	require.Zero(t, queue.queue.Len())
+
+	// The leader releases the lease when it stops, so the standby replica takes over and syncs the key.
+	stopFirst()
+	<-firstDone
+	require.False(t, first.IsLeader())
+	lease, err := kubeClient.CoordinationV1().Leases("some-namespace").Get(context.Background(), "some-lease", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotEqual(t, "first-pod", *lease.Spec.HolderIdentity)
+
+	require.Eventually(t, second.IsLeader, 10*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, queue.queue.Len())
+	queued, _ := queue.queue.Get()
+	require.Equal(t, key, queued)
+	require.NoError(t, TestSync(t, c, Context{Context: context.Background(), Key: key, Queue: queue}))
+	require.Equal(t, []Key{key}, synced)
+
+	stopSecond()
+	<-secondDone
+	require.False(t, second.IsLeader())
+}
+
+func TestLeaderElectionCancelsSyncsWhenTheLeaseIsLost(t *testing.T) {
+	elector, err := NewLeaderElector(kubernetesfake.NewSimpleClientset(), "some-namespace", "some-lease", "some-pod")
+	require.NoError(t, err)
+	leaderCtx, loseLease := context.WithCancel(context.Background())
+	elector.startLeading(leaderCtx)
+	require.True(t, elector.IsLeader())
+
+	started := make(chan struct{})
+	c := New(Config{
+		Name: "test-leader-election",
+		Syncer: SyncFunc(func(ctx Context) error {
+			close(started)
+			<-ctx.Context.Done()
+			return ctx.Context.Err()
+		}),
+	})
+	leaderElected(c, elector)
+	TestRunSynchronously(t, c)
+
+	syncErr := make(chan error)
+	go func() { syncErr <- TestSync(t, c, Context{Context: context.Background()}) }()
+	<-started
+	loseLease()
+	require.EqualError(t, <-syncErr, "context canceled")
+
+	elector.stopLeading()
+	require.False(t, elector.IsLeader())
+
+	// A Lease which is lost before the callback of the election runs does not make the replica the leader.
+	elector.startLeading(leaderCtx)
+	require.False(t, elector.IsLeader())
+}
+
+func TestManagerLeaderElection(t *testing.T) {
+	newController := func(name string, syncs *int) Controller {
+		return New(Config{Name: name, Syncer: SyncFunc(func(Context) error { *syncs++; return nil })})
+	}
+	elector, err := NewLeaderElector(kubernetesfake.NewSimpleClientset(), "some-namespace", "some-lease", "some-pod")
+	require.NoError(t, err)
+	var electedSyncs, unelectedSyncs, everySyncs int
+	elected, unelected, every := newController("elected", &electedSyncs), newController("unelected", &unelectedSyncs), newController("every", &everySyncs)
+
+	// The controllers return immediately with a cancelled context, and so does the election.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	NewManager().WithLeaderElection(elector).WithLeaderElectedController(elected, 1).WithController(every, 1).Start(ctx)
+	NewManager().WithLeaderElectedController(unelected, 1).Start(ctx)
+
+	queue := &queueWrapper{queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())}
+	t.Cleanup(queue.queue.ShutDown)
+	for _, c := range []Controller{elected, unelected, every} {
+		require.NoError(t, TestSync(t, c, Context{Context: context.Background(), Queue: queue}))
+	}
+	require.Zero(t, electedSyncs, "this replica is not the leader")
+	require.Equal(t, 1, unelectedSyncs, "without a LeaderElector, every replica syncs")
+	require.Equal(t, 1, everySyncs)
+}
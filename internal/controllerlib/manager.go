@@ -13,6 +13,16 @@ import (
 type Manager interface {
 	Start(ctx context.Context)
 	WithController(controller Controller, workers int) Manager
+
+	// WithLeaderElectedController is like WithController, except that the controller only syncs on the replica which
+	// is the leader of the LeaderElector of the manager, see WithLeaderElection. Controllers which keep in-memory state
+	// up to date, e.g. the caches of the serving endpoints, must be added WithController instead, since every replica
+	// needs that state.
+	WithLeaderElectedController(controller Controller, workers int) Manager
+
+	// WithLeaderElection sets the LeaderElector which Start runs next to the controllers. Without one, the controllers
+	// which were added WithLeaderElectedController sync on every replica.
+	WithLeaderElection(elector *LeaderElector) Manager
 }
 
 func NewManager() Manager {
@@ -21,12 +31,14 @@ func NewManager() Manager {
 
 // runnableController represents single controller runnable configuration.
 type runnableController struct {
-	controller Controller
-	workers    int
+	controller    Controller
+	workers       int
+	leaderElected bool
 }
 
 type controllerManager struct {
 	controllers []runnableController
+	elector     *LeaderElector
 }
 
 var _ Manager = &controllerManager{}
@@ -39,10 +51,37 @@ func (c *controllerManager) WithController(controller Controller, workers int) M
 	return c
 }
 
+func (c *controllerManager) WithLeaderElectedController(controller Controller, workers int) Manager {
+	c.controllers = append(c.controllers, runnableController{
+		controller:    controller,
+		workers:       workers,
+		leaderElected: true,
+	})
+	return c
+}
+
+func (c *controllerManager) WithLeaderElection(elector *LeaderElector) Manager {
+	c.elector = elector
+	return c
+}
+
 // Start will run all managed controllers and block until all controllers shutdown.
 // When the context passed is cancelled, all controllers are signalled to shutdown.
 func (c *controllerManager) Start(ctx context.Context) {
+	for _, r := range c.controllers {
+		if r.leaderElected && c.elector != nil {
+			leaderElected(r.controller, c.elector)
+		}
+	}
+
 	var wg sync.WaitGroup
+	if c.elector != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.elector.Run(ctx)
+		}()
+	}
 	wg.Add(len(c.controllers))
 	for i := range c.controllers {
 		idx := i
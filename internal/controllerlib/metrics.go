@@ -0,0 +1,61 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controllerlib
+
+import (
+	"errors"
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	_ "k8s.io/component-base/metrics/prometheus/workqueue" // exports the depth, latency, and retries of each queue by controller name
+)
+
+// The results of a sync, used as the value of the "result" label.
+const (
+	syncResultSuccess = "success"
+	syncResultError   = "error"
+	syncResultRequeue = "requeue" // the sync returned ErrSyntheticRequeue
+)
+
+//nolint: gochecknoglobals
+var (
+	syncDuration = metrics.NewHistogramVec(&metrics.HistogramOpts{
+		Name: "pinniped_controller_sync_duration_seconds",
+		Help: "Duration of the syncs of each controller by result, i.e. success, error, or requeue " +
+			"(a sync which asked to be retried without failing).",
+		Buckets:        metrics.ExponentialBuckets(0.001, 2, 15),
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"controller", "result"})
+	requeuesTotal = metrics.NewCounterVec(&metrics.CounterOpts{
+		Name:           "pinniped_controller_requeues_total",
+		Help:           "Number of keys which each controller added back to its queue with backoff after a failed or requeued sync.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"controller", "result"})
+	droppedKeysTotal = metrics.NewCounterVec(&metrics.CounterOpts{
+		Name:           "pinniped_controller_dropped_keys_total",
+		Help:           "Number of keys which each controller stopped retrying after reaching its maximum number of retries.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"controller"})
+)
+
+//nolint: gochecknoinits
+func init() {
+	legacyregistry.MustRegister(syncDuration, requeuesTotal, droppedKeysTotal)
+}
+
+func syncResult(err error) string {
+	switch {
+	case err == nil:
+		return syncResultSuccess
+	case errors.Is(err, ErrSyntheticRequeue):
+		return syncResultRequeue
+	default:
+		return syncResultError
+	}
+}
+
+func observeSync(controller string, start time.Time, err error) {
+	syncDuration.WithLabelValues(controller, syncResult(err)).Observe(time.Since(start).Seconds())
+}
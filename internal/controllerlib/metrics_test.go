@@ -0,0 +1,79 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controllerlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/component-base/metrics/legacyregistry"
+	metricstestutil "k8s.io/component-base/metrics/testutil"
+)
+
+func TestSyncMetrics(t *testing.T) {
+	results := []error{
+		nil,
+		fmt.Errorf("waiting for something: %w", ErrSyntheticRequeue),
+		errors.New("some error"),
+		errors.New("some error"),
+	}
+	var syncs int
+	c := New(Config{
+		Name: "test-sync-metrics",
+		Syncer: SyncFunc(func(ctx Context) error {
+			err := results[syncs]
+			syncs++
+			return err
+		}),
+	},
+		WithMaxRetries(2),
+		// Retry immediately, so that each processNextWorkItem() below finds the key in the queue.
+		WithRateLimiter(workqueue.NewItemExponentialFailureRateLimiter(0, 0)),
+	).(*controller)
+
+	key := Key{Namespace: "some-namespace", Name: "some-name"}
+	c.queue.Add(key)
+	for range results {
+		c.processNextWorkItem(context.Background())
+		if syncs < len(results) {
+			c.queue.Add(key)
+		}
+	}
+	require.Equal(t, len(results), syncs)
+	require.Zero(t, c.queue.Len())
+
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	sampleCounts := map[string]uint64{}
+	var queueAdds float64
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			switch {
+			case family.GetName() == "pinniped_controller_sync_duration_seconds" && labels["controller"] == "test-sync-metrics":
+				sampleCounts[labels["result"]] = metric.GetHistogram().GetSampleCount()
+			case family.GetName() == "workqueue_adds_total" && labels["name"] == "test-sync-metrics":
+				queueAdds = metric.GetCounter().GetValue()
+			}
+		}
+	}
+	require.Equal(t, map[string]uint64{syncResultSuccess: 1, syncResultRequeue: 1, syncResultError: 2}, sampleCounts)
+	require.Equal(t, float64(len(results)), queueAdds)
+
+	for result, want := range map[string]float64{syncResultSuccess: 0, syncResultRequeue: 1, syncResultError: 1} {
+		got, err := metricstestutil.GetCounterMetricValue(requeuesTotal.WithLabelValues("test-sync-metrics", result))
+		require.NoError(t, err)
+		require.Equal(t, want, got, result)
+	}
+	dropped, err := metricstestutil.GetCounterMetricValue(droppedKeysTotal.WithLabelValues("test-sync-metrics"))
+	require.NoError(t, err)
+	require.Equal(t, float64(1), dropped)
+}
@@ -14,6 +14,8 @@ import (
 	"k8s.io/client-go/tools/events"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
+
+	"go.pinniped.dev/internal/plog"
 )
 
 type Option func(*controller)
@@ -32,6 +34,9 @@ func WithInitialEvent(key Key) Option {
 
 func WithRateLimiter(limiter workqueue.RateLimiter) Option {
 	return func(c *controller) {
+		if c.queue != nil {
+			c.queue.ShutDown() // stop the goroutines of the queue which is replaced, e.g. the default one
+		}
 		c.queue = workqueue.NewNamedRateLimitingQueue(limiter, c.Name())
 		c.queueWrapper = &queueWrapper{queue: c.queue}
 	}
@@ -64,8 +69,7 @@ func WithInformer(getter InformerGetter, filter Filter, opt InformerOption) Opti
 				if filter.Add(object) {
 					klog.V(4).InfoS("handling add",
 						"controller", c.Name(),
-						"namespace", object.GetNamespace(),
-						"name", object.GetName(),
+						"resource", plog.KRef(object.GetNamespace(), object.GetName()),
 						"selfLink", object.GetSelfLink(), // TODO: self link is deprecated so we need to extract the GVR in some other way (using a series of schemes?)
 						"kind", fmt.Sprintf("%T", object),
 					)
@@ -78,8 +82,7 @@ func WithInformer(getter InformerGetter, filter Filter, opt InformerOption) Opti
 				if filter.Update(oldObject, newObject) {
 					klog.V(4).InfoS("handling update",
 						"controller", c.Name(),
-						"namespace", newObject.GetNamespace(),
-						"name", newObject.GetName(),
+						"resource", plog.KRef(newObject.GetNamespace(), newObject.GetName()),
 						"selfLink", newObject.GetSelfLink(), // TODO: self link is deprecated so we need to extract the GVR in some other way (using a series of schemes?)
 						"kind", fmt.Sprintf("%T", newObject),
 					)
@@ -103,8 +106,7 @@ func WithInformer(getter InformerGetter, filter Filter, opt InformerOption) Opti
 				if filter.Delete(accessor) {
 					klog.V(4).InfoS("handling delete",
 						"controller", c.Name(),
-						"namespace", accessor.GetNamespace(),
-						"name", accessor.GetName(),
+						"resource", plog.KRef(accessor.GetNamespace(), accessor.GetName()),
 						"selfLink", accessor.GetSelfLink(), // TODO: self link is deprecated so we need to extract the GVR in some other way (using a series of schemes?)
 						"kind", fmt.Sprintf("%T", accessor),
 					)
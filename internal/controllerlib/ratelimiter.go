@@ -0,0 +1,108 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controllerlib
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// RateLimiterConfig configures the rate limiter of the queue of a controller. A key which fails to sync is retried
+// after BaseDelay, and the delay doubles with each failure up to MaxDelay. Independently of failures, the queue hands
+// out at most QPS keys per second with bursts of up to BucketSize keys. Zero fields keep the values of
+// workqueue.DefaultControllerRateLimiter.
+type RateLimiterConfig struct {
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	QPS        float64
+	BucketSize int
+}
+
+// withDefaults returns the config with its zero fields taken from defaults.
+func (r RateLimiterConfig) withDefaults(defaults RateLimiterConfig) RateLimiterConfig {
+	if r.BaseDelay == 0 {
+		r.BaseDelay = defaults.BaseDelay
+	}
+	if r.MaxDelay == 0 {
+		r.MaxDelay = defaults.MaxDelay
+	}
+	if r.QPS == 0 {
+		r.QPS = defaults.QPS
+	}
+	if r.BucketSize == 0 {
+		r.BucketSize = defaults.BucketSize
+	}
+	return r
+}
+
+// RateLimiters builds the rate limiters of the queues of the controllers of a controller manager. The overrides
+// replace the non-zero fields of defaults for the controllers with the given names. Controllers without any configured
+// field get workqueue.DefaultControllerRateLimiter.
+type RateLimiters struct {
+	defaults  RateLimiterConfig
+	overrides map[string]RateLimiterConfig
+	names     sets.String
+}
+
+// NewRateLimiters returns RateLimiters with the given config, see RateLimiterConfig.
+func NewRateLimiters(defaults RateLimiterConfig, overrides map[string]RateLimiterConfig) *RateLimiters {
+	return &RateLimiters{
+		defaults:  defaults,
+		overrides: overrides,
+		names:     sets.NewString(),
+	}
+}
+
+// WithRateLimiter returns an Option which passes the rate limiter of the controller to WithRateLimiter when the
+// controller is created, and which records the name of the controller for Validate.
+func (r *RateLimiters) WithRateLimiter() Option {
+	return func(c *controller) {
+		r.names.Insert(c.Name())
+		WithRateLimiter(r.rateLimiterFor(c.Name()))(c)
+	}
+}
+
+// Validate returns an error when an override names none of the controllers which were created with WithRateLimiter,
+// e.g. because the name is misspelled. It must be called after all the controllers are created.
+func (r *RateLimiters) Validate() error {
+	var unknown []string
+	for name := range r.overrides {
+		if !r.names.Has(name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("rate limiter overrides for unknown controllers %q, known controllers are %q", unknown, r.names.List())
+	}
+	return nil
+}
+
+// rateLimiterFor returns the rate limiter for the queue of the controller with the given name.
+func (r *RateLimiters) rateLimiterFor(controllerName string) workqueue.RateLimiter {
+	config := r.overrides[controllerName].withDefaults(r.defaults)
+	if config == (RateLimiterConfig{}) {
+		return workqueue.DefaultControllerRateLimiter()
+	}
+	return NewRateLimiter(config)
+}
+
+// NewRateLimiter returns a rate limiter which works like workqueue.DefaultControllerRateLimiter, with the given config.
+func NewRateLimiter(config RateLimiterConfig) workqueue.RateLimiter {
+	config = config.withDefaults(RateLimiterConfig{
+		BaseDelay:  5 * time.Millisecond,
+		MaxDelay:   1000 * time.Second,
+		QPS:        10,
+		BucketSize: 100,
+	})
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(config.BaseDelay, config.MaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(config.QPS), config.BucketSize)},
+	)
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controllerlib
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	key := Key{Name: "some-name"}
+
+	limiter := NewRateLimiter(RateLimiterConfig{})
+	require.Equal(t, 5*time.Millisecond, limiter.When(key))
+	require.Equal(t, 10*time.Millisecond, limiter.When(key))
+
+	limiter = NewRateLimiter(RateLimiterConfig{BaseDelay: time.Second, MaxDelay: 3 * time.Second, QPS: 1, BucketSize: 10})
+	require.Equal(t, time.Second, limiter.When(key))
+	require.Equal(t, 2*time.Second, limiter.When(key))
+	require.Equal(t, 3*time.Second, limiter.When(key))
+	limiter.Forget(key)
+	require.Equal(t, time.Second, limiter.When(key))
+}
+
+func TestRateLimiters(t *testing.T) {
+	key := Key{Name: "some-name"}
+	syncer := SyncFunc(func(Context) error { return nil })
+
+	rateLimiters := NewRateLimiters(RateLimiterConfig{}, nil)
+	require.Equal(t, 5*time.Millisecond, rateLimiters.rateLimiterFor("unconfigured").When(key))
+
+	rateLimiters = NewRateLimiters(RateLimiterConfig{BaseDelay: time.Second}, map[string]RateLimiterConfig{
+		"overridden": {BaseDelay: time.Hour, MaxDelay: 2 * time.Hour},
+		"misspelled": {BaseDelay: time.Minute},
+	})
+	require.Equal(t, time.Hour, rateLimiters.rateLimiterFor("overridden").When(key))
+	require.Equal(t, time.Second, rateLimiters.rateLimiterFor("defaulted").When(key))
+
+	// The rate limiter is built into the queue by New, so a key which fails to sync waits for the overridden delay.
+	overridden := New(Config{Name: "overridden", Syncer: syncer}, rateLimiters.WithRateLimiter()).(*controller)
+	overridden.queue.AddRateLimited(key)
+	require.Equal(t, 0, overridden.queue.Len())
+	require.Equal(t, 1, overridden.queue.NumRequeues(key))
+
+	// Controllers which are created without the option are not known to Validate.
+	_ = New(Config{Name: "misspelled", Syncer: syncer})
+	_ = New(Config{Name: "defaulted", Syncer: syncer}, rateLimiters.WithRateLimiter())
+
+	require.EqualError(t, rateLimiters.Validate(),
+		`rate limiter overrides for unknown controllers ["misspelled"], known controllers are ["defaulted" "overridden"]`)
+
+	_ = New(Config{Name: "misspelled", Syncer: syncer}, rateLimiters.WithRateLimiter())
+	require.NoError(t, rateLimiters.Validate())
+
+	// Start does not replace the queue, since the informers may already have added keys to it. The controller returns
+	// immediately with a cancelled context.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	queue := overridden.queue
+	NewManager().WithController(overridden, 1).Start(ctx)
+	require.Same(t, queue, overridden.queue)
+}
@@ -7,9 +7,14 @@ import (
 	"testing"
 )
 
-func TestSync(t *testing.T, controller Controller, ctx Context) error {
+// TestSync calls the Syncer of the controller. When ctx has no Recorder, it gets the recorder of the controller,
+// like in Run(), so that tests can observe the events which were recorded through WithRecorder.
+func TestSync(t *testing.T, c Controller, ctx Context) error {
 	t.Helper() // force testing import to discourage external use
-	return controller.sync(ctx)
+	if impl, ok := c.(*controller); ok && ctx.Recorder == nil {
+		ctx.Recorder = impl.recorder
+	}
+	return c.sync(ctx)
 }
 
 func TestWrap(t *testing.T, controller Controller, wrapper SyncWrapperFunc) {
@@ -8,37 +8,47 @@ package controllermanager
 import (
 	"context"
 	"fmt"
+	"net"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/clock"
 	k8sinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2/klogr"
 
+	identityv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/identity/v1alpha1"
 	loginv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/login/v1alpha1"
 	pinnipedclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
 	pinnipedinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
 	"go.pinniped.dev/internal/apiserviceref"
+	"go.pinniped.dev/internal/concierge/impersonator"
 	"go.pinniped.dev/internal/config/concierge"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
 	"go.pinniped.dev/internal/controller/apicerts"
 	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/controller/authenticator/bootstrapcachefiller"
 	"go.pinniped.dev/internal/controller/authenticator/cachecleaner"
+	"go.pinniped.dev/internal/controller/authenticator/clientcertcachefiller"
+	"go.pinniped.dev/internal/controller/authenticator/cloudidentitycachefiller"
 	"go.pinniped.dev/internal/controller/authenticator/jwtcachefiller"
+	"go.pinniped.dev/internal/controller/authenticator/serviceaccountcachefiller"
+	"go.pinniped.dev/internal/controller/authenticator/statictokencachefiller"
 	"go.pinniped.dev/internal/controller/authenticator/webhookcachefiller"
+	"go.pinniped.dev/internal/controller/impersonatorconfig"
 	"go.pinniped.dev/internal/controller/issuerconfig"
 	"go.pinniped.dev/internal/controller/kubecertagent"
+	"go.pinniped.dev/internal/controller/supervisorconnection"
 	"go.pinniped.dev/internal/controllerlib"
 	"go.pinniped.dev/internal/deploymentref"
 	"go.pinniped.dev/internal/downward"
 	"go.pinniped.dev/internal/dynamiccert"
 	"go.pinniped.dev/internal/groupsuffix"
 	"go.pinniped.dev/internal/kubeclient"
+	"go.pinniped.dev/internal/registry/credentialrequest"
 )
 
-const (
-	singletonWorker       = 1
-	defaultResyncInterval = 3 * time.Minute
-)
+const singletonWorker = 1
 
 // Config holds all the input parameters to the set of controllers run as a part of Pinniped.
 //
@@ -58,6 +68,18 @@ type Config struct {
 	// the kubecertagent package's controllers should manage the agent pods.
 	KubeCertAgentConfig *concierge.KubeCertAgentSpec
 
+	// ImpersonationProxyConfig comes from the Pinniped config API (see api.Config). It configures when
+	// and how the impersonation proxy is served.
+	ImpersonationProxyConfig *concierge.ImpersonationProxySpec
+
+	// SupervisorConnectionConfig comes from the Pinniped config API (see api.Config). It configures whether
+	// SupervisorConnections are reconciled.
+	SupervisorConnectionConfig *concierge.SupervisorConnectionSpec
+
+	// ControllersConfig comes from the Pinniped config API (see api.Config). It configures the rate limiters of
+	// the controller queues.
+	ControllersConfig *concierge.ControllersSpec
+
 	// DiscoveryURLOverride allows a caller to inject a hardcoded discovery URL into Pinniped
 	// discovery document.
 	DiscoveryURLOverride *string
@@ -74,24 +96,51 @@ type Config struct {
 	// rotating the serving certificate. This period of time starts upon issuance of the serving
 	// certificate.
 	ServingCertRenewBefore time.Duration
+	// ServingCertRotationOverlap is the period of time before the rotation of the serving certificate
+	// during which both the current and the next CA certificate are published in the CA bundle.
+	ServingCertRotationOverlap time.Duration
+
+	// InformerResyncPeriod is how often the informers replay their caches to the controllers. It must be
+	// positive, because the certificate controllers rely on the resync to notice when renewal is due.
+	InformerResyncPeriod time.Duration
+
+	// InformerSecretLabelSelector limits the Secrets which are watched in the installation namespace. Empty
+	// watches all of them.
+	InformerSecretLabelSelector string
 
 	// AuthenticatorCache is a cache of authenticators shared amongst various authenticated-related controllers.
 	AuthenticatorCache *authncache.Cache
 
+	// ImpersonationAuthenticator authenticates the clients of the impersonation proxy.
+	ImpersonationAuthenticator impersonator.TokenCredentialRequestAuthenticator
+
+	// TrustedProxies are the proxies which may tell the impersonation proxy the address of its clients.
+	TrustedProxies *credentialrequest.TrustedProxies
+
+	// CSRIssuer is set when the Concierge is configured to have client certificates signed through the
+	// CertificateSigningRequest API, in which case no kube cert agent pods are created.
+	CSRIssuer *kubecertagent.CSRIssuer
+
 	// Labels are labels that should be added to any resources created by the controllers.
 	Labels map[string]string
 }
 
-// Prepare the controllers and their informers and return a function that will start them when called.
+// Prepare the controllers and their informers and return a function that will start them when called. The function
+// returns a channel which is closed once the controllers have stopped after the context is done.
 //nolint:funlen // Eh, fair, it is a really long function...but it is wiring the world...so...
-func PrepareControllers(c *Config) (func(ctx context.Context), error) {
+func PrepareControllers(c *Config) (func(ctx context.Context) <-chan struct{}, error) {
 	groupName, ok := groupsuffix.Replace(loginv1alpha1.GroupName, c.APIGroupSuffix)
 	if !ok {
 		return nil, fmt.Errorf("cannot make api group from %s/%s", loginv1alpha1.GroupName, c.APIGroupSuffix)
 	}
 	apiServiceName := loginv1alpha1.SchemeGroupVersion.Version + "." + groupName
+	identityGroupName, ok := groupsuffix.Replace(identityv1alpha1.GroupName, c.APIGroupSuffix)
+	if !ok {
+		return nil, fmt.Errorf("cannot make api group from %s/%s", identityv1alpha1.GroupName, c.APIGroupSuffix)
+	}
+	identityAPIServiceName := identityv1alpha1.SchemeGroupVersion.Version + "." + identityGroupName
 
-	dref, _, err := deploymentref.New(c.ServerInstallationInfo)
+	dref, deployment, err := deploymentref.New(c.ServerInstallationInfo)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create deployment ref: %w", err)
 	}
@@ -110,8 +159,32 @@ func PrepareControllers(c *Config) (func(ctx context.Context), error) {
 		return nil, fmt.Errorf("could not create clients for the controllers: %w", err)
 	}
 
+	// Only the leader runs the controllers which create and update shared resources, e.g. the serving certificate
+	// Secret and the kube cert agent pods, so that the replicas do not race each other. The controllers which keep the
+	// in-memory state of each replica up to date run on every replica.
+	elector, err := controllerlib.NewLeaderElector(client.Kubernetes, c.ServerInstallationInfo.Namespace, deployment.Name, c.ServerInstallationInfo.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rate limiters are built into the queues of the controllers when they are created below.
+	rateLimiterOverrides := make(map[string]controllerlib.RateLimiterConfig, len(c.ControllersConfig.RateLimiterOverrides))
+	for name, override := range c.ControllersConfig.RateLimiterOverrides {
+		rateLimiterOverrides[name] = rateLimiterConfig(override)
+	}
+	rateLimiters := controllerlib.NewRateLimiters(rateLimiterConfig(c.ControllersConfig.RateLimiter), rateLimiterOverrides)
+
 	// Create informers. Don't forget to make sure they get started in the function returned below.
-	informers := createInformers(c.ServerInstallationInfo.Namespace, client.Kubernetes, client.PinnipedConcierge)
+	informers := createInformers(
+		c.ServerInstallationInfo.Namespace,
+		client.Kubernetes,
+		client.PinnipedConcierge,
+		c.InformerResyncPeriod,
+		c.InformerSecretLabelSelector,
+	)
+
+	// The authenticator controllers record Events about their resources, e.g. when they become unready.
+	recorder, startRecorder := pinnipedcontroller.NewEventRecorder(client.Kubernetes, "pinniped-concierge")
 
 	// Configuration for the kubecertagent controllers created below.
 	agentPodConfig := &kubecertagent.AgentPodConfig{
@@ -125,13 +198,19 @@ func PrepareControllers(c *Config) (func(ctx context.Context), error) {
 		Name: c.NamesConfig.CredentialIssuer,
 	}
 
+	impersonationProxyHandler, err := impersonator.New(c.ImpersonationAuthenticator, c.TrustedProxies, c.APIGroupSuffix, client.JSONConfig, clock.RealClock{})
+	if err != nil {
+		return nil, fmt.Errorf("could not create impersonation proxy: %w", err)
+	}
+
 	// Create controller manager.
 	controllerManager := controllerlib.
 		NewManager().
+		WithLeaderElection(elector).
 
 		// KubeConfig info publishing controller is responsible for writing the KubeConfig information to the
 		// CredentialIssuer resource and keeping that information up to date.
-		WithController(
+		WithLeaderElectedController(
 			issuerconfig.NewKubeConfigInfoPublisherController(
 				c.NamesConfig.CredentialIssuer,
 				c.Labels,
@@ -139,34 +218,51 @@ func PrepareControllers(c *Config) (func(ctx context.Context), error) {
 				client.PinnipedConcierge,
 				informers.kubePublicNamespaceK8s.Core().V1().ConfigMaps(),
 				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
 
 		// API certs controllers are responsible for managing the TLS certificates used to serve Pinniped's API.
-		WithController(
+		WithLeaderElectedController(
 			apicerts.NewCertsManagerController(
 				c.ServerInstallationInfo.Namespace,
 				c.NamesConfig.ServingCertificateSecret,
 				c.Labels,
 				client.Kubernetes,
-				informers.installationNamespaceK8s.Core().V1().Secrets(),
+				informers.installationNamespaceSecretsK8s.Core().V1().Secrets(),
 				controllerlib.WithInformer,
 				controllerlib.WithInitialEvent,
 				c.ServingCertDuration,
+				c.ServingCertRenewBefore,
+				c.ServingCertRotationOverlap,
 				"Pinniped CA",
 				c.NamesConfig.APIService,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
-		WithController(
+		WithLeaderElectedController(
 			apicerts.NewAPIServiceUpdaterController(
 				c.ServerInstallationInfo.Namespace,
 				c.NamesConfig.ServingCertificateSecret,
 				apiServiceName,
 				client.Aggregation,
-				informers.installationNamespaceK8s.Core().V1().Secrets(),
+				informers.installationNamespaceSecretsK8s.Core().V1().Secrets(),
+				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
+			),
+			singletonWorker,
+		).
+		WithLeaderElectedController(
+			apicerts.NewAPIServiceUpdaterController(
+				c.ServerInstallationInfo.Namespace,
+				c.NamesConfig.ServingCertificateSecret,
+				identityAPIServiceName,
+				client.Aggregation,
+				informers.installationNamespaceSecretsK8s.Core().V1().Secrets(),
 				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
@@ -175,91 +271,127 @@ func PrepareControllers(c *Config) (func(ctx context.Context), error) {
 				c.ServerInstallationInfo.Namespace,
 				c.NamesConfig.ServingCertificateSecret,
 				c.DynamicServingCertProvider,
-				informers.installationNamespaceK8s.Core().V1().Secrets(),
+				informers.installationNamespaceSecretsK8s.Core().V1().Secrets(),
 				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
-		WithController(
+		WithLeaderElectedController(
 			apicerts.NewCertsExpirerController(
 				c.ServerInstallationInfo.Namespace,
 				c.NamesConfig.ServingCertificateSecret,
 				client.Kubernetes,
-				informers.installationNamespaceK8s.Core().V1().Secrets(),
+				informers.installationNamespaceSecretsK8s.Core().V1().Secrets(),
 				controllerlib.WithInformer,
 				c.ServingCertRenewBefore,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
 
-		// Kube cert agent controllers are responsible for finding the cluster's signing keys and keeping them
-		// up to date in memory, as well as reporting status on this cluster integration strategy.
+		// The impersonation proxy controller starts and stops the impersonation proxy, which is used instead of
+		// the kube cert agent strategy on clusters where the signing keys cannot be found, and reports its status.
 		WithController(
-			kubecertagent.NewCreaterController(
-				agentPodConfig,
-				credentialIssuerLocationConfig,
+			impersonatorconfig.NewImpersonatorConfigController(
+				c.ServerInstallationInfo.Namespace,
+				c.NamesConfig.CredentialIssuer,
+				c.NamesConfig.ImpersonationProxyTLSSecret,
 				c.Labels,
-				clock.RealClock{},
+				*c.ImpersonationProxyConfig,
 				client.Kubernetes,
 				client.PinnipedConcierge,
-				informers.kubeSystemNamespaceK8s.Core().V1().Pods(),
-				informers.installationNamespaceK8s.Core().V1().Pods(),
+				informers.pinniped.Config().V1alpha1().CredentialIssuers(),
+				informers.installationNamespaceSecretsK8s.Core().V1().Secrets(),
+				impersonationProxyHandler,
+				net.Listen,
+				clock.RealClock{},
 				controllerlib.WithInformer,
 				controllerlib.WithInitialEvent,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
+
+		// The cache filler/cleaner controllers are responsible for keep an in-memory representation of active
+		// authenticators up to date.
 		WithController(
-			kubecertagent.NewAnnotaterController(
-				agentPodConfig,
-				credentialIssuerLocationConfig,
+			webhookcachefiller.New(
+				c.AuthenticatorCache,
+				client.PinnipedConcierge,
+				informers.pinniped.Authentication().V1alpha1().WebhookAuthenticators(),
 				clock.RealClock{},
-				client.Kubernetes,
+				recorder,
+				klogr.New(),
+				rateLimiters.WithRateLimiter(),
+			),
+			singletonWorker,
+		).
+		WithController(
+			jwtcachefiller.New(
+				c.AuthenticatorCache,
 				client.PinnipedConcierge,
-				informers.kubeSystemNamespaceK8s.Core().V1().Pods(),
-				informers.installationNamespaceK8s.Core().V1().Pods(),
-				controllerlib.WithInformer,
+				informers.pinniped.Authentication().V1alpha1().JWTAuthenticators(),
+				informers.installationNamespaceSecretsK8s.Core().V1().Secrets(),
+				c.ServerInstallationInfo.Namespace,
+				clock.RealClock{},
+				recorder,
+				klogr.New(),
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
 		WithController(
-			kubecertagent.NewExecerController(
-				credentialIssuerLocationConfig,
-				c.DynamicSigningCertProvider,
-				kubecertagent.NewPodCommandExecutor(client.JSONConfig, client.Kubernetes),
+			cloudidentitycachefiller.New(
+				c.AuthenticatorCache,
+				informers.pinniped.Authentication().V1alpha1().CloudIdentityAuthenticators(),
+				klogr.New(),
+				rateLimiters.WithRateLimiter(),
+			),
+			singletonWorker,
+		).
+		WithController(
+			bootstrapcachefiller.New(
+				c.AuthenticatorCache,
 				client.PinnipedConcierge,
+				informers.pinniped.Authentication().V1alpha1().BootstrapCredentials(),
 				clock.RealClock{},
-				informers.installationNamespaceK8s.Core().V1().Pods(),
-				controllerlib.WithInformer,
+				klogr.New(),
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
 		WithController(
-			kubecertagent.NewDeleterController(
-				agentPodConfig,
-				client.Kubernetes,
-				informers.kubeSystemNamespaceK8s.Core().V1().Pods(),
-				informers.installationNamespaceK8s.Core().V1().Pods(),
-				controllerlib.WithInformer,
+			statictokencachefiller.New(
+				c.AuthenticatorCache,
+				client.PinnipedConcierge,
+				informers.pinniped.Authentication().V1alpha1().StaticTokenAuthenticators(),
+				informers.installationNamespaceSecretsK8s.Core().V1().Secrets(),
+				c.ServerInstallationInfo.Namespace,
+				clock.RealClock{},
+				recorder,
+				klogr.New(),
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
-
-		// The cache filler/cleaner controllers are responsible for keep an in-memory representation of active
-		// authenticators up to date.
 		WithController(
-			webhookcachefiller.New(
+			clientcertcachefiller.New(
 				c.AuthenticatorCache,
-				informers.pinniped.Authentication().V1alpha1().WebhookAuthenticators(),
+				informers.pinniped.Authentication().V1alpha1().ClientCertificateAuthenticators(),
+				clock.RealClock{},
 				klogr.New(),
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
 		WithController(
-			jwtcachefiller.New(
+			serviceaccountcachefiller.New(
 				c.AuthenticatorCache,
-				informers.pinniped.Authentication().V1alpha1().JWTAuthenticators(),
+				informers.pinniped.Authentication().V1alpha1().ServiceAccountAuthenticators(),
+				client.Kubernetes.AuthenticationV1().TokenReviews(),
 				klogr.New(),
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		).
@@ -268,23 +400,144 @@ func PrepareControllers(c *Config) (func(ctx context.Context), error) {
 				c.AuthenticatorCache,
 				informers.pinniped.Authentication().V1alpha1().WebhookAuthenticators(),
 				informers.pinniped.Authentication().V1alpha1().JWTAuthenticators(),
+				informers.pinniped.Authentication().V1alpha1().CloudIdentityAuthenticators(),
+				informers.pinniped.Authentication().V1alpha1().BootstrapCredentials(),
+				informers.pinniped.Authentication().V1alpha1().StaticTokenAuthenticators(),
+				informers.pinniped.Authentication().V1alpha1().ClientCertificateAuthenticators(),
+				informers.pinniped.Authentication().V1alpha1().ServiceAccountAuthenticators(),
 				klogr.New(),
+				rateLimiters.WithRateLimiter(),
+			),
+			singletonWorker,
+		)
+
+	if c.SupervisorConnectionConfig.Enabled {
+		// The Supervisor connection controller joins the cluster to a central Supervisor as described by each
+		// SupervisorConnection.
+		controllerManager = controllerManager.WithLeaderElectedController(
+			supervisorconnection.NewController(
+				client.PinnipedConcierge,
+				client.Kubernetes,
+				informers.pinniped.Authentication().V1alpha1().SupervisorConnections(),
+				informers.pinniped.Authentication().V1alpha1().JWTAuthenticators(),
+				informers.clusterScopedK8s.Rbac().V1().ClusterRoleBindings(),
+				c.Labels,
+				clock.RealClock{},
+				controllerlib.WithInformer,
+				rateLimiters.WithRateLimiter(),
+			),
+			singletonWorker,
+		)
+	}
+
+	if c.CSRIssuer != nil {
+		// When client certificates are signed through the CSR API, there are no agent pods to manage. The prober
+		// controller reports the status of this cluster integration strategy instead.
+		controllerManager = controllerManager.WithController(
+			kubecertagent.NewCSRProberController(
+				credentialIssuerLocationConfig,
+				c.Labels,
+				c.CSRIssuer,
+				clock.RealClock{},
+				client.PinnipedConcierge,
+				controllerlib.WithInitialEvent,
+				rateLimiters.WithRateLimiter(),
 			),
 			singletonWorker,
 		)
+	} else {
+		// Kube cert agent controllers are responsible for finding the cluster's signing keys and keeping them
+		// up to date in memory, as well as reporting status on this cluster integration strategy.
+		controllerManager = controllerManager.
+			WithLeaderElectedController(
+				kubecertagent.NewCreaterController(
+					agentPodConfig,
+					credentialIssuerLocationConfig,
+					c.Labels,
+					clock.RealClock{},
+					client.Kubernetes,
+					client.PinnipedConcierge,
+					informers.kubeSystemNamespaceK8s.Core().V1().Pods(),
+					informers.installationNamespaceK8s.Core().V1().Pods(),
+					controllerlib.WithInformer,
+					controllerlib.WithInitialEvent,
+					rateLimiters.WithRateLimiter(),
+				),
+				singletonWorker,
+			).
+			WithLeaderElectedController(
+				kubecertagent.NewAnnotaterController(
+					agentPodConfig,
+					credentialIssuerLocationConfig,
+					clock.RealClock{},
+					client.Kubernetes,
+					client.PinnipedConcierge,
+					informers.kubeSystemNamespaceK8s.Core().V1().Pods(),
+					informers.installationNamespaceK8s.Core().V1().Pods(),
+					controllerlib.WithInformer,
+					rateLimiters.WithRateLimiter(),
+				),
+				singletonWorker,
+			).
+			WithController(
+				kubecertagent.NewExecerController(
+					credentialIssuerLocationConfig,
+					c.DynamicSigningCertProvider,
+					kubecertagent.NewPodCommandExecutor(client.JSONConfig, client.Kubernetes),
+					client.PinnipedConcierge,
+					clock.RealClock{},
+					informers.installationNamespaceK8s.Core().V1().Pods(),
+					controllerlib.WithInformer,
+					rateLimiters.WithRateLimiter(),
+				),
+				singletonWorker,
+			).
+			WithLeaderElectedController(
+				kubecertagent.NewDeleterController(
+					agentPodConfig,
+					client.Kubernetes,
+					informers.kubeSystemNamespaceK8s.Core().V1().Pods(),
+					informers.installationNamespaceK8s.Core().V1().Pods(),
+					controllerlib.WithInformer,
+					rateLimiters.WithRateLimiter(),
+				),
+				singletonWorker,
+			)
+	}
+
+	if err := rateLimiters.Validate(); err != nil {
+		return nil, err
+	}
 
 	// Return a function which starts the informers and controllers.
-	return func(ctx context.Context) {
+	return func(ctx context.Context) <-chan struct{} {
+		startRecorder(ctx)
 		informers.startAndWaitForSync(ctx)
-		go controllerManager.Start(ctx)
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			controllerManager.Start(ctx)
+		}()
+		return stopped
 	}, nil
 }
 
+func rateLimiterConfig(spec concierge.RateLimiterSpec) controllerlib.RateLimiterConfig {
+	return controllerlib.RateLimiterConfig{
+		BaseDelay:  time.Duration(spec.BaseDelayMilliseconds) * time.Millisecond,
+		MaxDelay:   time.Duration(spec.MaxDelaySeconds) * time.Second,
+		QPS:        spec.QPS,
+		BucketSize: spec.BucketSize,
+	}
+}
+
 type informers struct {
-	kubePublicNamespaceK8s   k8sinformers.SharedInformerFactory
-	kubeSystemNamespaceK8s   k8sinformers.SharedInformerFactory
-	installationNamespaceK8s k8sinformers.SharedInformerFactory
-	pinniped                 pinnipedinformers.SharedInformerFactory
+	clusterScopedK8s                k8sinformers.SharedInformerFactory
+	kubePublicNamespaceK8s          k8sinformers.SharedInformerFactory
+	kubeSystemNamespaceK8s          k8sinformers.SharedInformerFactory
+	installationNamespaceK8s        k8sinformers.SharedInformerFactory
+	installationNamespaceSecretsK8s k8sinformers.SharedInformerFactory
+	pinniped                        pinnipedinformers.SharedInformerFactory
 }
 
 // Create the informers that will be used by the controllers.
@@ -292,38 +545,62 @@ func createInformers(
 	serverInstallationNamespace string,
 	k8sClient kubernetes.Interface,
 	pinnipedClient pinnipedclientset.Interface,
+	resyncPeriod time.Duration,
+	secretLabelSelector string,
 ) *informers {
+	// The informers of namespaces which the Concierge
+	// shares with other applications only list the objects which it needs, so that its memory use does not grow
+	// with theirs.
 	return &informers{
+		clusterScopedK8s: k8sinformers.NewSharedInformerFactory(
+			k8sClient,
+			resyncPeriod,
+		),
 		kubePublicNamespaceK8s: k8sinformers.NewSharedInformerFactoryWithOptions(
 			k8sClient,
-			defaultResyncInterval,
+			resyncPeriod,
 			k8sinformers.WithNamespace(issuerconfig.ClusterInfoNamespace),
 		),
 		kubeSystemNamespaceK8s: k8sinformers.NewSharedInformerFactoryWithOptions(
 			k8sClient,
-			defaultResyncInterval,
+			resyncPeriod,
 			k8sinformers.WithNamespace(kubecertagent.ControllerManagerNamespace),
+			k8sinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.LabelSelector = kubecertagent.ControllerManagerLabelSelector
+			}),
 		),
 		installationNamespaceK8s: k8sinformers.NewSharedInformerFactoryWithOptions(
 			k8sClient,
-			defaultResyncInterval,
+			resyncPeriod,
+			k8sinformers.WithNamespace(serverInstallationNamespace),
+		),
+		installationNamespaceSecretsK8s: k8sinformers.NewSharedInformerFactoryWithOptions(
+			k8sClient,
+			resyncPeriod,
 			k8sinformers.WithNamespace(serverInstallationNamespace),
+			k8sinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.LabelSelector = secretLabelSelector
+			}),
 		),
 		pinniped: pinnipedinformers.NewSharedInformerFactoryWithOptions(
 			pinnipedClient,
-			defaultResyncInterval,
+			resyncPeriod,
 		),
 	}
 }
 
 func (i *informers) startAndWaitForSync(ctx context.Context) {
+	i.clusterScopedK8s.Start(ctx.Done())
 	i.kubePublicNamespaceK8s.Start(ctx.Done())
 	i.kubeSystemNamespaceK8s.Start(ctx.Done())
 	i.installationNamespaceK8s.Start(ctx.Done())
+	i.installationNamespaceSecretsK8s.Start(ctx.Done())
 	i.pinniped.Start(ctx.Done())
 
+	i.clusterScopedK8s.WaitForCacheSync(ctx.Done())
 	i.kubePublicNamespaceK8s.WaitForCacheSync(ctx.Done())
 	i.kubeSystemNamespaceK8s.WaitForCacheSync(ctx.Done())
 	i.installationNamespaceK8s.WaitForCacheSync(ctx.Done())
+	i.installationNamespaceSecretsK8s.WaitForCacheSync(ctx.Done())
 	i.pinniped.WaitForCacheSync(ctx.Done())
 }
@@ -74,7 +74,9 @@ func (s *secretsStorage) Create(ctx context.Context, signature string, data JSON
 	if err != nil {
 		return "", err
 	}
+	start := time.Now()
 	secret, err = s.secrets.Create(ctx, secret, metav1.CreateOptions{})
+	observeStorageRequest(s.resource, "create", start, err)
 	if err != nil {
 		return "", fmt.Errorf("failed to create %s for signature %s: %w", s.resource, signature, err)
 	}
@@ -82,7 +84,9 @@ func (s *secretsStorage) Create(ctx context.Context, signature string, data JSON
 }
 
 func (s *secretsStorage) Get(ctx context.Context, signature string, data JSON) (string, error) {
+	start := time.Now()
 	secret, err := s.secrets.Get(ctx, s.getName(signature), metav1.GetOptions{})
+	observeStorageRequest(s.resource, "get", start, err)
 	if err != nil {
 		return "", fmt.Errorf("failed to get %s for signature %s: %w", s.resource, signature, err)
 	}
@@ -95,6 +99,23 @@ func (s *secretsStorage) Get(ctx context.Context, signature string, data JSON) (
 	return secret.ResourceVersion, nil
 }
 
+// FromSecret decodes the data of a storage Secret of the given resource, which was e.g. listed from an informer cache
+// instead of being read through a Storage. It fails when the Secret was not written by a Storage of that resource.
+func FromSecret(resource string, secret *corev1.Secret, data JSON) error {
+	s := &secretsStorage{
+		resource:      resource,
+		secretType:    corev1.SecretType(fmt.Sprintf(secretTypeFormat, resource)),
+		secretVersion: []byte(secretVersion),
+	}
+	if err := s.validateSecret(secret); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(secret.Data[secretDataKey], data); err != nil {
+		return fmt.Errorf("failed to decode %s %s: %w", resource, secret.Name, err)
+	}
+	return nil
+}
+
 func (s *secretsStorage) validateSecret(secret *corev1.Secret) error {
 	if secret.Type != s.secretType {
 		return fmt.Errorf("%w: %s must equal %s", ErrSecretTypeMismatch, secret.Type, s.secretType)
@@ -113,7 +134,9 @@ func (s *secretsStorage) Update(ctx context.Context, signature, resourceVersion
 	if err != nil {
 		return "", err
 	}
+	start := time.Now()
 	secret, err = s.secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	observeStorageRequest(s.resource, "update", start, err)
 	if err != nil {
 		return "", fmt.Errorf("failed to update %s for signature %s at resource version %s: %w", s.resource, signature, resourceVersion, err)
 	}
@@ -121,19 +144,24 @@ func (s *secretsStorage) Update(ctx context.Context, signature, resourceVersion
 }
 
 func (s *secretsStorage) Delete(ctx context.Context, signature string) error {
-	if err := s.secrets.Delete(ctx, s.getName(signature), metav1.DeleteOptions{}); err != nil {
+	start := time.Now()
+	err := s.secrets.Delete(ctx, s.getName(signature), metav1.DeleteOptions{})
+	observeStorageRequest(s.resource, "delete", start, err)
+	if err != nil {
 		return fmt.Errorf("failed to delete %s for signature %s: %w", s.resource, signature, err)
 	}
 	return nil
 }
 
 func (s *secretsStorage) DeleteByLabel(ctx context.Context, labelName string, labelValue string) error {
+	start := time.Now()
 	list, err := s.secrets.List(ctx, metav1.ListOptions{
 		LabelSelector: labels.Set{
 			SecretLabelKey: s.resource,
 			labelName:      labelValue,
 		}.String(),
 	})
+	observeStorageRequest(s.resource, "list", start, err)
 	if err != nil {
 		return fmt.Errorf(`failed to list secrets for resource "%s" matching label "%s=%s": %w`, s.resource, labelName, labelValue, err)
 	}
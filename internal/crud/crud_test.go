@@ -21,6 +21,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/kubernetes/fake"
 	coretesting "k8s.io/client-go/testing"
+	"k8s.io/component-base/metrics/legacyregistry"
 )
 
 func TestStorage(t *testing.T) {
@@ -1067,6 +1068,34 @@ func assertValidName(t *testing.T, name string) {
 	require.Empty(t, validateSecretName(name, true)) // I do not think we actually care about this case
 }
 
+func TestFromSecret(t *testing.T) {
+	type testJSON struct {
+		Data string
+	}
+
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	storage := New("access-tokens", client.CoreV1().Secrets("test-ns"), time.Now, time.Minute)
+	_, err := storage.Create(ctx, "some-signature", &testJSON{Data: "snorlax"}, nil)
+	require.NoError(t, err)
+
+	list, err := client.CoreV1().Secrets("test-ns").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	secret := &list.Items[0]
+
+	var data testJSON
+	require.NoError(t, FromSecret("access-tokens", secret, &data))
+	require.Equal(t, "snorlax", data.Data)
+
+	err = FromSecret("refresh-tokens", secret, &data)
+	require.EqualError(t, err, "secret storage data has incorrect type: storage.pinniped.dev/access-tokens must equal storage.pinniped.dev/refresh-tokens")
+
+	secret.Data["pinniped-storage-data"] = []byte("not json")
+	err = FromSecret("access-tokens", secret, &data)
+	require.EqualError(t, err, "failed to decode access-tokens "+secret.Name+": invalid character 'o' in literal null (expecting 'u')")
+}
+
 func getName(t *testing.T, action coretesting.Action) string {
 	t.Helper()
 
@@ -1095,3 +1124,45 @@ func errString(err error) string {
 
 	return err.Error()
 }
+
+func TestStorageRequestMetrics(t *testing.T) {
+	type testJSON struct {
+		Data string
+	}
+
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	// Use a resource which no other test uses, since the metrics are global.
+	storage := New("metrics-test-tokens", client.CoreV1().Secrets("test-ns"), time.Now, time.Minute)
+	_, err := storage.Create(ctx, "some-signature", &testJSON{Data: "snorlax"}, nil)
+	require.NoError(t, err)
+	_, err = storage.Get(ctx, "some-signature", &testJSON{})
+	require.NoError(t, err)
+	_, err = storage.Get(ctx, "other-signature", &testJSON{})
+	require.Error(t, err)
+	require.NoError(t, storage.Delete(ctx, "some-signature"))
+
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	sampleCounts := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "pinniped_supervisor_session_storage_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["resource"] == "metrics-test-tokens" {
+				sampleCounts[labels["operation"]+"/"+labels["result"]] = metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	require.Equal(t, map[string]uint64{
+		"create/success": 1,
+		"get/success":    1,
+		"get/error":      1,
+		"delete/success": 1,
+	}, sampleCounts)
+}
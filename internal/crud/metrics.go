@@ -0,0 +1,36 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package crud
+
+import (
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+//nolint: gochecknoglobals
+var storageRequestDuration = metrics.NewHistogramVec(
+	&metrics.HistogramOpts{
+		Name: "pinniped_supervisor_session_storage_request_duration_seconds",
+		Help: "Duration of the requests for session storage Secrets by resource and operation. Every operation " +
+			"is a request to the API server, so a slow API server or etcd shows up in all of them.",
+		Buckets:        metrics.ExponentialBuckets(0.001, 2, 14),
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"resource", "operation", "result"},
+)
+
+//nolint: gochecknoinits
+func init() {
+	legacyregistry.MustRegister(storageRequestDuration)
+}
+
+func observeStorageRequest(resource, operation string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	storageRequestDuration.WithLabelValues(resource, operation, result).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,26 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package debughandler serves the runtime profiles of net/http/pprof and the variables of expvar, e.g. the memory
+// statistics of the Go runtime, so that the CPU and memory usage of a running server can be investigated. It must
+// only be served on a listener which is either bound to a loopback address or which authorizes its callers.
+package debughandler
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof" //nolint: gosec // The profiles are only served by the handler below, never by http.DefaultServeMux.
+)
+
+// New returns a handler which serves the profiles at /debug/pprof/ and the variables at /debug/vars. All other
+// paths result in 404.
+func New() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package debughandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	handler := New()
+	get := func(path string) *httptest.ResponseRecorder {
+		t.Helper()
+		rsp := httptest.NewRecorder()
+		handler.ServeHTTP(rsp, httptest.NewRequest(http.MethodGet, path, nil))
+		return rsp
+	}
+
+	rsp := get("/debug/pprof/")
+	require.Equal(t, http.StatusOK, rsp.Code)
+	require.Contains(t, rsp.Body.String(), "goroutine")
+
+	rsp = get("/debug/pprof/heap?debug=1")
+	require.Equal(t, http.StatusOK, rsp.Code)
+	require.Contains(t, rsp.Body.String(), "heap profile")
+
+	rsp = get("/debug/pprof/cmdline")
+	require.Equal(t, http.StatusOK, rsp.Code)
+
+	rsp = get("/debug/vars")
+	require.Equal(t, http.StatusOK, rsp.Code)
+	require.Contains(t, rsp.Body.String(), `"memstats"`)
+
+	require.Equal(t, http.StatusNotFound, get("/debug/pprof/no-such-profile").Code)
+	require.Equal(t, http.StatusNotFound, get("/metrics").Code)
+	require.Equal(t, http.StatusNotFound, get("/").Code)
+}
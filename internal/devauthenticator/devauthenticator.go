@@ -0,0 +1,76 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package devauthenticator implements an in-memory authenticator with a fixed set of demo users, which is used by
+// the Concierge's --dev mode so that logins can be tried out without configuring any external identity provider. The
+// Supervisor's --dev mode lets the same users log in, with their tokens as their passwords.
+package devauthenticator
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+)
+
+const (
+	// Kind is the authenticator kind which clients should request in their TokenCredentialRequests.
+	// There is no custom resource of this kind.
+	Kind = "DevAuthenticator"
+
+	// Name is the authenticator name which clients should request in their TokenCredentialRequests.
+	Name = "dev"
+)
+
+// CacheKey is the key under which the Authenticator should be stored in the authncache.
+//nolint:gochecknoglobals
+var CacheKey = authncache.Key{
+	APIGroup: auth1alpha1.SchemeGroupVersion.Group,
+	Kind:     Kind,
+	Name:     Name,
+}
+
+// User is a demo user which can log in by presenting its Token.
+type User struct {
+	Token    string
+	Username string
+	Groups   []string
+}
+
+// DemoUsers returns the users which are seeded in --dev mode. Their tokens are well-known, so they must never be
+// accepted by a production server.
+func DemoUsers() []User {
+	return []User{
+		{Token: "alice-dev-token", Username: "alice", Groups: []string{"developers", "admins"}},
+		{Token: "bob-dev-token", Username: "bob", Groups: []string{"developers"}},
+	}
+}
+
+// Authenticator is an authenticator.Token which accepts the token of any of its users.
+type Authenticator struct {
+	users []User
+}
+
+// Assert that *Authenticator implements authenticator.Token.
+var _ authenticator.Token = (*Authenticator)(nil)
+
+// New returns an Authenticator for the provided users.
+func New(users []User) *Authenticator {
+	return &Authenticator{users: users}
+}
+
+// AuthenticateToken implements authenticator.Token.
+func (a *Authenticator) AuthenticateToken(_ context.Context, token string) (*authenticator.Response, bool, error) {
+	for _, u := range a.users {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(u.Token)) == 1 {
+			return &authenticator.Response{
+				User: &user.DefaultInfo{Name: u.Username, Groups: u.Groups},
+			}, true, nil
+		}
+	}
+	return nil, false, nil
+}
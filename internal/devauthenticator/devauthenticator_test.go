@@ -0,0 +1,55 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package devauthenticator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func TestAuthenticateToken(t *testing.T) {
+	subject := New(DemoUsers())
+
+	tests := []struct {
+		name         string
+		token        string
+		wantResponse *authenticator.Response
+	}{
+		{
+			name:  "alice",
+			token: "alice-dev-token",
+			wantResponse: &authenticator.Response{
+				User: &user.DefaultInfo{Name: "alice", Groups: []string{"developers", "admins"}},
+			},
+		},
+		{
+			name:  "bob",
+			token: "bob-dev-token",
+			wantResponse: &authenticator.Response{
+				User: &user.DefaultInfo{Name: "bob", Groups: []string{"developers"}},
+			},
+		},
+		{
+			name:  "unknown token",
+			token: "some-other-token",
+		},
+		{
+			name:  "empty token",
+			token: "",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			response, authenticated, err := subject.AuthenticateToken(context.Background(), tt.token)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantResponse != nil, authenticated)
+			require.Equal(t, tt.wantResponse, response)
+		})
+	}
+}
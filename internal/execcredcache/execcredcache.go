@@ -0,0 +1,297 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package execcredcache implements a file-based cache of the short-lived cluster credentials which the Concierge
+// issues, so that kubectl commands in quick succession need only one TokenCredentialRequest.
+package execcredcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gofrs/flock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+	"sigs.k8s.io/yaml"
+
+	"go.pinniped.dev/internal/atomicfile"
+	"go.pinniped.dev/internal/fileencryption"
+)
+
+const (
+	// apiVersion is the Kubernetes-style API version of the credential cache file object.
+	apiVersion = "config.supervisor.pinniped.dev/v1alpha1"
+
+	// apiKind is the Kubernetes-style Kind of the credential cache file object.
+	apiKind = "CredentialCache"
+
+	// encryptedAPIKind is the Kubernetes-style Kind of an encrypted credential cache file object.
+	encryptedAPIKind = "EncryptedCredentialCache"
+
+	// minCredentialValidity is how long a cached credential must stay valid to be returned, since a kubectl command
+	// keeps using its credential until it is done.
+	minCredentialValidity = 30 * time.Second
+
+	// fileLockTimeout is how long we will wait trying to acquire the file lock on the cache file before timing out.
+	fileLockTimeout = 10 * time.Second
+
+	// fileLockRetryInterval is how often we will poll while waiting for the file lock to become available.
+	fileLockRetryInterval = 10 * time.Millisecond
+)
+
+type (
+	// credCache is the object which is YAML-serialized to form the contents of the cache file.
+	credCache struct {
+		metav1.TypeMeta
+		Entries []entry `json:"credentials"`
+	}
+
+	// entry is a single credential in the cache. The key is a hash, so that the file does not contain the tokens
+	// which may be part of the key.
+	entry struct {
+		Key               string                                  `json:"key"`
+		CreationTimestamp metav1.Time                             `json:"creationTimestamp"`
+		Credential        *clientauthv1beta1.ExecCredentialStatus `json:"credential"`
+	}
+
+	// encryptedCredCache is the object which is YAML-serialized to form the contents of an encrypted cache file. The
+	// ciphertext is the encryption of a serialized credCache.
+	encryptedCredCache struct {
+		metav1.TypeMeta
+		Salt       []byte `json:"salt"`
+		Nonce      []byte `json:"nonce"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+)
+
+// Option configures a cache in New().
+type Option func(*Cache)
+
+// WithErrorReporter is an Option that specifies a callback which will be invoked for each error reported during
+// cache operations. By default, these errors are silently ignored.
+func WithErrorReporter(reporter func(error)) Option {
+	return func(c *Cache) {
+		c.errReporter = reporter
+	}
+}
+
+// WithEncryption is an Option that encrypts the cache file with a key derived from the secret, e.g. a passphrase.
+// The credentials may contain private keys, so they should be encrypted whenever the session cache is. An existing
+// plaintext file is encrypted when it is next written.
+func WithEncryption(secret []byte) Option {
+	return func(c *Cache) {
+		c.encryption = fileencryption.New(secret)
+	}
+}
+
+// Cache is a credential cache backed by a YAML file, which is shared by the processes of the CLI.
+type Cache struct {
+	path        string
+	encryption  *fileencryption.Encryption
+	errReporter func(error)
+	trylockFunc func() error
+	unlockFunc  func() error
+	clock       func() time.Time
+}
+
+// New returns a Cache backed by the specified file path.
+func New(path string, options ...Option) *Cache {
+	lock := flock.New(path + ".lock")
+	c := Cache{
+		path: path,
+		trylockFunc: func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), fileLockTimeout)
+			defer cancel()
+			_, err := lock.TryLockContext(ctx, fileLockRetryInterval)
+			return err
+		},
+		unlockFunc:  lock.Unlock,
+		errReporter: func(_ error) {},
+		clock:       time.Now,
+	}
+	for _, opt := range options {
+		opt(&c)
+	}
+	return &c
+}
+
+// Get returns the credential which was cached under the key, unless it expires soon. The key may be any value which
+// can be encoded as JSON.
+func (c *Cache) Get(key interface{}) *clientauthv1beta1.ExecCredential {
+	// If the cache file does not exist, exit immediately with no error log.
+	if _, err := os.Stat(c.path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	hash := hashKey(key)
+	var result *clientauthv1beta1.ExecCredential
+	c.withCache(func(cache *credCache) {
+		for _, e := range cache.Entries {
+			if e.Key == hash && c.stillValid(e.Credential) {
+				result = &clientauthv1beta1.ExecCredential{Status: e.Credential.DeepCopy()}
+				return
+			}
+		}
+	})
+	return result
+}
+
+// Put stores the credential under the key. Credentials without an expiration time are not cached, since they might
+// be valid for much longer than the tokens of the session which they were issued for.
+func (c *Cache) Put(key interface{}, cred *clientauthv1beta1.ExecCredential) {
+	if cred.Status == nil || cred.Status.ExpirationTimestamp == nil {
+		return
+	}
+
+	// Create the cache directory if it does not exist.
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil && !errors.Is(err, os.ErrExist) {
+		c.errReporter(fmt.Errorf("could not create credential cache directory: %w", err))
+		return
+	}
+
+	hash := hashKey(key)
+	c.withCache(func(cache *credCache) {
+		entries := make([]entry, 0, len(cache.Entries)+1)
+		for _, e := range cache.Entries {
+			if e.Key != hash {
+				entries = append(entries, e)
+			}
+		}
+		cache.Entries = append(entries, entry{
+			Key:               hash,
+			CreationTimestamp: metav1.NewTime(c.clock()),
+			Credential:        cred.Status.DeepCopy(),
+		})
+	})
+}
+
+// Clear removes every credential from the cache, e.g. when the user logs out.
+func (c *Cache) Clear() {
+	if _, err := os.Stat(c.path); errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	c.withCache(func(cache *credCache) {
+		cache.Entries = nil
+	})
+}
+
+// stillValid returns whether the credential remains valid long enough to be used.
+func (c *Cache) stillValid(cred *clientauthv1beta1.ExecCredentialStatus) bool {
+	return cred != nil && cred.ExpirationTimestamp != nil &&
+		cred.ExpirationTimestamp.Time.After(c.clock().Add(minCredentialValidity))
+}
+
+// withCache locks the cache file, reads it, processes/mutates it with the provided function, and writes it back after
+// removing the credentials which expire soon.
+func (c *Cache) withCache(transact func(*credCache)) {
+	if err := c.trylockFunc(); err != nil {
+		c.errReporter(fmt.Errorf("could not lock credential cache file: %w", err))
+		return
+	}
+	defer func() {
+		if err := c.unlockFunc(); err != nil {
+			c.errReporter(fmt.Errorf("could not unlock credential cache file: %w", err))
+		}
+	}()
+
+	cache, err := readCache(c.path, c.encryption)
+	if err != nil {
+		// If that fails, fall back to resetting to a blank slate.
+		c.errReporter(fmt.Errorf("failed to read credential cache, resetting: %w", err))
+		cache = emptyCache()
+	}
+
+	transact(cache)
+
+	valid := make([]entry, 0, len(cache.Entries))
+	for _, e := range cache.Entries {
+		if c.stillValid(e.Credential) {
+			valid = append(valid, e)
+		}
+	}
+	cache.Entries = valid
+
+	if err := writeCache(c.path, cache, c.encryption); err != nil {
+		c.errReporter(fmt.Errorf("could not write credential cache: %w", err))
+	}
+}
+
+// readCache loads a credCache from a path on disk. If the requested path does not exist, it returns an empty cache.
+// An encrypted file can only be read with the encryption which it was written with, while plaintext files are always
+// read.
+func readCache(path string, e *fileencryption.Encryption) (*credCache, error) {
+	cacheYAML, err := ioutil.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return emptyCache(), nil
+		}
+		return nil, fmt.Errorf("could not read credential cache file: %w", err)
+	}
+
+	var encrypted encryptedCredCache
+	if err := yaml.Unmarshal(cacheYAML, &encrypted); err != nil {
+		return nil, fmt.Errorf("invalid credential cache file: %w", err)
+	}
+	if encrypted.APIVersion == apiVersion && encrypted.Kind == encryptedAPIKind {
+		if e == nil {
+			return nil, fmt.Errorf("credential cache file is encrypted")
+		}
+		cacheYAML, err = e.Open(encrypted.Salt, encrypted.Nonce, encrypted.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("could not decrypt credential cache file (was it encrypted with a different secret?): %w", err)
+		}
+	}
+
+	var cache credCache
+	if err := yaml.Unmarshal(cacheYAML, &cache); err != nil {
+		return nil, fmt.Errorf("invalid credential cache file: %w", err)
+	}
+	if cache.APIVersion != apiVersion || cache.Kind != apiKind {
+		return nil, fmt.Errorf("unsupported credential cache version: %#v", cache.TypeMeta)
+	}
+	return &cache, nil
+}
+
+// writeCache writes the cache to the path on disk, encrypted when e is not nil.
+func writeCache(path string, cache *credCache, e *fileencryption.Encryption) error {
+	cacheYAML, err := yaml.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if e != nil {
+		salt, nonce, ciphertext, err := e.Seal(cacheYAML)
+		if err != nil {
+			return fmt.Errorf("could not encrypt credential cache file: %w", err)
+		}
+		cacheYAML, err = yaml.Marshal(&encryptedCredCache{
+			TypeMeta:   metav1.TypeMeta{APIVersion: apiVersion, Kind: encryptedAPIKind},
+			Salt:       salt,
+			Nonce:      nonce,
+			Ciphertext: ciphertext,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return atomicfile.WriteFile(path, cacheYAML)
+}
+
+// emptyCache returns an empty, initialized credCache.
+func emptyCache() *credCache {
+	return &credCache{TypeMeta: metav1.TypeMeta{APIVersion: apiVersion, Kind: apiKind}}
+}
+
+// hashKey returns the hex-encoded SHA-256 hash of the JSON encoding of the key.
+func hashKey(key interface{}) string {
+	keyJSON, _ := json.Marshal(key)
+	hash := sha256.Sum256(keyJSON)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package execcredcache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+
+	"go.pinniped.dev/internal/testutil"
+)
+
+type testKey struct {
+	Cluster string `json:"cluster"`
+	Token   string `json:"token"`
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+	credential := func(certificate string, expiry time.Time) *clientauthv1beta1.ExecCredential {
+		return &clientauthv1beta1.ExecCredential{Status: &clientauthv1beta1.ExecCredentialStatus{
+			ClientCertificateData: certificate,
+			ClientKeyData:         "test-key",
+			ExpirationTimestamp:   &metav1.Time{Time: expiry},
+		}}
+	}
+	setup := func(t *testing.T) (*Cache, *[]string, string) {
+		path := filepath.Join(testutil.TempDir(t), "subdir", "credentials.yaml")
+		var errs []string
+		c := New(path, WithErrorReporter(func(err error) { errs = append(errs, err.Error()) }))
+		c.clock = func() time.Time { return now }
+		return c, &errs, path
+	}
+
+	t.Run("put and get", func(t *testing.T) {
+		t.Parallel()
+		c, errs, path := setup(t)
+		key1 := testKey{Cluster: "cluster-1", Token: "token-1"}
+		key2 := testKey{Cluster: "cluster-2", Token: "token-1"}
+
+		require.Nil(t, c.Get(key1))
+		c.Put(key1, credential("cert-1", now.Add(5*time.Minute)))
+		c.Put(key2, credential("cert-2", now.Add(5*time.Minute)))
+		c.Put(key1, credential("cert-1-again", now.Add(5*time.Minute)))
+		requireCredential(t, credential("cert-1-again", now.Add(5*time.Minute)), c.Get(key1))
+		requireCredential(t, credential("cert-2", now.Add(5*time.Minute)), c.Get(key2))
+		require.Nil(t, c.Get(testKey{Cluster: "cluster-1", Token: "token-2"}))
+
+		// The file contains neither the keys nor the tokens in them, only their hashes.
+		contents, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.NotContains(t, string(contents), "token-1")
+		require.NotContains(t, string(contents), "cluster-1")
+		require.Contains(t, string(contents), "kind: CredentialCache")
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		// Another process sees the same credentials.
+		other := New(path)
+		other.clock = c.clock
+		requireCredential(t, credential("cert-2", now.Add(5*time.Minute)), other.Get(key2))
+
+		c.Clear()
+		require.Nil(t, c.Get(key1))
+		require.Nil(t, other.Get(key2))
+		require.Empty(t, *errs)
+	})
+
+	t.Run("credentials which expire soon are not returned and are removed", func(t *testing.T) {
+		t.Parallel()
+		c, errs, path := setup(t)
+		c.Put("some-key", credential("cert-1", now.Add(29*time.Second)))
+		c.Put("other-key", credential("cert-2", now.Add(31*time.Second)))
+		require.Nil(t, c.Get("some-key"))
+		require.NotNil(t, c.Get("other-key"))
+
+		cache, err := readCache(path, nil)
+		require.NoError(t, err)
+		require.Len(t, cache.Entries, 1)
+		require.Equal(t, hashKey("other-key"), cache.Entries[0].Key)
+		require.Empty(t, *errs)
+	})
+
+	t.Run("credentials without expiration are not cached", func(t *testing.T) {
+		t.Parallel()
+		c, errs, path := setup(t)
+		c.Put("some-key", &clientauthv1beta1.ExecCredential{Status: &clientauthv1beta1.ExecCredentialStatus{Token: "some-token"}})
+		require.Nil(t, c.Get("some-key"))
+		_, err := os.Stat(path)
+		require.True(t, os.IsNotExist(err))
+		require.Empty(t, *errs)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		t.Parallel()
+		c, errs, path := setup(t)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte("apiVersion: v1\nkind: Other\n"), 0600))
+		require.Nil(t, c.Get("some-key"))
+		require.Equal(t, []string{
+			`failed to read credential cache, resetting: unsupported credential cache version: v1.TypeMeta{Kind:"Other", APIVersion:"v1"}`,
+		}, *errs)
+
+		// The file was reset.
+		c.Put("some-key", credential("cert-1", now.Add(5*time.Minute)))
+		require.NotNil(t, c.Get("some-key"))
+		require.Len(t, *errs, 1)
+	})
+
+	t.Run("encrypted file", func(t *testing.T) {
+		t.Parallel()
+		c, errs, path := setup(t)
+		c.Put("plaintext-key", credential("cert-1", now.Add(5*time.Minute)))
+
+		// An existing plaintext file is read, and encrypted when it is written back.
+		encrypted := New(path, WithEncryption([]byte("some-passphrase")), WithErrorReporter(c.errReporter))
+		encrypted.clock = c.clock
+		requireCredential(t, credential("cert-1", now.Add(5*time.Minute)), encrypted.Get("plaintext-key"))
+		encrypted.Put("some-key", credential("cert-2", now.Add(5*time.Minute)))
+		requireCredential(t, credential("cert-2", now.Add(5*time.Minute)), encrypted.Get("some-key"))
+		contents, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.Contains(t, string(contents), "kind: EncryptedCredentialCache")
+		require.NotContains(t, string(contents), "test-key")
+		require.Empty(t, *errs)
+
+		// Without the same secret, the file cannot be read, and it is reset.
+		wrongSecret := New(path, WithEncryption([]byte("other-passphrase")), WithErrorReporter(c.errReporter))
+		wrongSecret.clock = c.clock
+		require.Nil(t, wrongSecret.Get("some-key"))
+		require.Equal(t, []string{
+			"failed to read credential cache, resetting: could not decrypt credential cache file (was it encrypted with a different secret?): cipher: message authentication failed",
+		}, *errs)
+	})
+
+	t.Run("encrypted file without encryption", func(t *testing.T) {
+		t.Parallel()
+		c, errs, path := setup(t)
+		encrypted := New(path, WithEncryption([]byte("some-passphrase")))
+		encrypted.clock = c.clock
+		encrypted.Put("some-key", credential("cert-1", now.Add(5*time.Minute)))
+
+		require.Nil(t, c.Get("some-key"))
+		require.Equal(t, []string{"failed to read credential cache, resetting: credential cache file is encrypted"}, *errs)
+	})
+
+	t.Run("lock errors", func(t *testing.T) {
+		t.Parallel()
+		c, errs, _ := setup(t)
+		c.trylockFunc = func() error { return fmt.Errorf("some lock error") }
+		c.Put("some-key", credential("cert-1", now.Add(5*time.Minute)))
+		require.Equal(t, []string{"could not lock credential cache file: some lock error"}, *errs)
+
+		c.trylockFunc = func() error { return nil }
+		c.unlockFunc = func() error { return fmt.Errorf("some unlock error") }
+		c.Put("some-key", credential("cert-1", now.Add(5*time.Minute)))
+		require.Equal(t, []string{
+			"could not lock credential cache file: some lock error",
+			"could not unlock credential cache file: some unlock error",
+		}, *errs)
+	})
+}
+
+// requireCredential compares the expiration timestamps by instant, since they are read back in the local time zone.
+func requireCredential(t *testing.T, want, got *clientauthv1beta1.ExecCredential) {
+	t.Helper()
+	require.NotNil(t, got)
+	require.True(t, want.Status.ExpirationTimestamp.Equal(got.Status.ExpirationTimestamp))
+	want, got = want.DeepCopy(), got.DeepCopy()
+	want.Status.ExpirationTimestamp, got.Status.ExpirationTimestamp = nil, nil
+	require.Equal(t, want, got)
+}
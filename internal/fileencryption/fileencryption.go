@@ -0,0 +1,96 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package fileencryption encrypts the cache files of the CLI with a key derived from a secret, e.g. a passphrase.
+package fileencryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// The scrypt parameters recommended for interactive logins in 2017, see https://godoc.org/golang.org/x/crypto/scrypt.
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+
+	saltSize = 16
+	keySize  = 32
+)
+
+// Encryption encrypts and decrypts with AES-GCM, with a key derived from the secret with scrypt. The derived key is
+// kept, since deriving it is deliberately slow.
+type Encryption struct {
+	secret []byte
+	salt   []byte
+	key    []byte
+}
+
+// New returns an Encryption with a key derived from the secret.
+func New(secret []byte) *Encryption {
+	return &Encryption{secret: secret}
+}
+
+// Seal encrypts the plaintext. It keeps the salt of the last ciphertext which was opened, if any, so the key does not
+// need to be derived again.
+func (e *Encryption) Seal(plaintext []byte) (salt, nonce, ciphertext []byte, err error) {
+	salt = e.salt
+	if salt == nil {
+		salt = make([]byte, saltSize)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	gcm, err := e.gcmForSalt(salt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, nil, err
+	}
+	return salt, nonce, gcm.Seal(nil, nonce, plaintext, nil), nil
+}
+
+// Open decrypts a ciphertext which was returned by Seal.
+func (e *Encryption) Open(salt, nonce, ciphertext []byte) ([]byte, error) {
+	gcm, err := e.gcmForSalt(salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce size %d", len(nonce))
+	}
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func (e *Encryption) keyForSalt(salt []byte) ([]byte, error) {
+	if e.key != nil && bytes.Equal(e.salt, salt) {
+		return e.key, nil
+	}
+	key, err := scrypt.Key(e.secret, salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	e.salt, e.key = salt, key
+	return key, nil
+}
+
+func (e *Encryption) gcmForSalt(salt []byte) (cipher.AEAD, error) {
+	key, err := e.keyForSalt(salt)
+	if err != nil {
+		return nil, fmt.Errorf("could not derive the key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,33 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package fileencryption
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryption(t *testing.T) {
+	e := New([]byte("some-passphrase"))
+	salt, nonce, ciphertext, err := e.Seal([]byte("some-plaintext"))
+	require.NoError(t, err)
+	require.Len(t, salt, saltSize)
+	require.NotContains(t, string(ciphertext), "some-plaintext")
+
+	plaintext, err := New([]byte("some-passphrase")).Open(salt, nonce, ciphertext)
+	require.NoError(t, err)
+	require.Equal(t, "some-plaintext", string(plaintext))
+
+	// The salt is kept, so that the key does not need to be derived again, but the nonce is not.
+	otherSalt, otherNonce, _, err := e.Seal([]byte("some-plaintext"))
+	require.NoError(t, err)
+	require.Equal(t, salt, otherSalt)
+	require.NotEqual(t, nonce, otherNonce)
+
+	_, err = New([]byte("other-passphrase")).Open(salt, nonce, ciphertext)
+	require.EqualError(t, err, "cipher: message authentication failed")
+	_, err = e.Open(salt, nonce[1:], ciphertext)
+	require.EqualError(t, err, "invalid nonce size 11")
+}
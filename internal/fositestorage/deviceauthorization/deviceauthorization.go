@@ -0,0 +1,116 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package deviceauthorization stores the pending authorizations of the OAuth 2.0 device authorization grant
+// (RFC 8628). They are keyed by their user code, which is what the user types into the verification page.
+package deviceauthorization
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ory/fosite"
+	"k8s.io/apimachinery/pkg/api/errors"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/crud"
+)
+
+const (
+	TypeLabelValue = "device-authorization"
+
+	ErrInvalidDeviceAuthorizationVersion = constable.Error("device authorization data has wrong version")
+	ErrAlreadyApproved                   = constable.Error("device authorization has already been approved")
+
+	deviceAuthorizationStorageVersion = "1"
+)
+
+// Session is a device authorization. It is pending until the user has logged in through the verification page,
+// which approves it by storing an authorization code that was issued to the device's client. The device then
+// redeems that authorization code (using CodeVerifier for PKCE) when it polls the token endpoint.
+type Session struct {
+	DeviceCodeSignature string    `json:"deviceCodeSignature"`
+	ClientID            string    `json:"clientID"`
+	Scopes              []string  `json:"scopes"`
+	CodeVerifier        string    `json:"codeVerifier"`
+	ExpiresAt           time.Time `json:"expiresAt"`
+	Authcode            string    `json:"authcode,omitempty"`
+	Version             string    `json:"version"`
+
+	// UpstreamIDPName and UpstreamIDPType are the upstream identity provider which the device asked to log in with.
+	UpstreamIDPName string `json:"upstreamIDPName,omitempty"`
+	UpstreamIDPType string `json:"upstreamIDPType,omitempty"`
+}
+
+// Approved returns whether the user has logged in to approve the device.
+func (s *Session) Approved() bool {
+	return s.Authcode != ""
+}
+
+// Storage stores device authorizations.
+type Storage interface {
+	CreateDeviceAuthorization(ctx context.Context, userCode string, session *Session) error
+	GetDeviceAuthorization(ctx context.Context, userCode string) (*Session, error)
+	ApproveDeviceAuthorization(ctx context.Context, userCode string, authcode string) error
+	DeleteDeviceAuthorization(ctx context.Context, userCode string) error
+}
+
+type deviceAuthorizationStorage struct {
+	storage crud.Storage
+}
+
+// New returns a Storage which keeps the device authorizations in Secrets.
+func New(secrets corev1client.SecretInterface, clock func() time.Time, sessionStorageLifetime time.Duration) Storage {
+	return &deviceAuthorizationStorage{storage: crud.New(TypeLabelValue, secrets, clock, sessionStorageLifetime)}
+}
+
+func (d *deviceAuthorizationStorage) CreateDeviceAuthorization(ctx context.Context, userCode string, session *Session) error {
+	session.Version = deviceAuthorizationStorageVersion
+	_, err := d.storage.Create(ctx, userCode, session, nil)
+	return err
+}
+
+func (d *deviceAuthorizationStorage) GetDeviceAuthorization(ctx context.Context, userCode string) (*Session, error) {
+	session, _, err := d.getSession(ctx, userCode)
+	return session, err
+}
+
+func (d *deviceAuthorizationStorage) ApproveDeviceAuthorization(ctx context.Context, userCode string, authcode string) error {
+	session, rv, err := d.getSession(ctx, userCode)
+	if err != nil {
+		return err
+	}
+	if session.Approved() {
+		return ErrAlreadyApproved
+	}
+
+	session.Authcode = authcode
+	_, err = d.storage.Update(ctx, userCode, rv, session)
+	return err
+}
+
+func (d *deviceAuthorizationStorage) DeleteDeviceAuthorization(ctx context.Context, userCode string) error {
+	return d.storage.Delete(ctx, userCode)
+}
+
+func (d *deviceAuthorizationStorage) getSession(ctx context.Context, userCode string) (*Session, string, error) {
+	session := &Session{}
+	rv, err := d.storage.Get(ctx, userCode, session)
+
+	if errors.IsNotFound(err) {
+		return nil, "", fosite.ErrNotFound.WithWrap(err).WithDebug(err.Error())
+	}
+
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get device authorization for %s: %w", userCode, err)
+	}
+
+	if version := session.Version; version != deviceAuthorizationStorageVersion {
+		return nil, "", fmt.Errorf("%w: device authorization for %s has version %s instead of %s",
+			ErrInvalidDeviceAuthorizationVersion, userCode, version, deviceAuthorizationStorageVersion)
+	}
+
+	return session, rv, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package deviceauthorization
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const namespace = "test-ns"
+
+var fakeNow = time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+var lifetime = time.Minute * 10
+var fakeNowPlusLifetimeAsString = metav1.Time{Time: fakeNow.Add(lifetime)}.Format(time.RFC3339)
+
+func TestDeviceAuthorizationStorage(t *testing.T) {
+	ctx, _, secrets, storage := makeTestSubject()
+
+	session := &Session{
+		DeviceCodeSignature: "some-device-code-signature",
+		ClientID:            "pinniped-cli",
+		Scopes:              []string{"openid", "offline_access"},
+		CodeVerifier:        "some-code-verifier",
+		ExpiresAt:           fakeNow.Add(5 * time.Minute),
+	}
+	require.NoError(t, storage.CreateDeviceAuthorization(ctx, "BCDFGHJK", session))
+
+	secret, err := secrets.Get(ctx, "pinniped-storage-device-authorization-aqqmkgdsji", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, corev1.SecretType("storage.pinniped.dev/device-authorization"), secret.Type)
+	require.Equal(t, map[string]string{"storage.pinniped.dev/type": "device-authorization"}, secret.Labels)
+	require.Equal(t, fakeNowPlusLifetimeAsString, secret.Annotations["storage.pinniped.dev/garbage-collect-after"])
+	require.JSONEq(t, `{
+		"deviceCodeSignature": "some-device-code-signature",
+		"clientID": "pinniped-cli",
+		"scopes": ["openid", "offline_access"],
+		"codeVerifier": "some-code-verifier",
+		"expiresAt": "2030-01-01T00:05:00Z",
+		"version": "1"
+	}`, string(secret.Data["pinniped-storage-data"]))
+
+	got, err := storage.GetDeviceAuthorization(ctx, "BCDFGHJK")
+	require.NoError(t, err)
+	require.Equal(t, session, got)
+	require.False(t, got.Approved())
+
+	require.NoError(t, storage.ApproveDeviceAuthorization(ctx, "BCDFGHJK", "some-authcode"))
+	got, err = storage.GetDeviceAuthorization(ctx, "BCDFGHJK")
+	require.NoError(t, err)
+	require.True(t, got.Approved())
+	require.Equal(t, "some-authcode", got.Authcode)
+
+	// A device can only be approved once.
+	require.Equal(t, ErrAlreadyApproved, storage.ApproveDeviceAuthorization(ctx, "BCDFGHJK", "other-authcode"))
+
+	require.NoError(t, storage.DeleteDeviceAuthorization(ctx, "BCDFGHJK"))
+	_, err = storage.GetDeviceAuthorization(ctx, "BCDFGHJK")
+	require.True(t, fosite.ErrNotFound.Is(err))
+}
+
+func TestGetNotFound(t *testing.T) {
+	ctx, _, _, storage := makeTestSubject()
+
+	_, err := storage.GetDeviceAuthorization(ctx, "BCDFGHJK")
+	require.True(t, fosite.ErrNotFound.Is(err))
+
+	err = storage.ApproveDeviceAuthorization(ctx, "BCDFGHJK", "some-authcode")
+	require.True(t, fosite.ErrNotFound.Is(err))
+}
+
+func TestWrongVersion(t *testing.T) {
+	ctx, _, secrets, storage := makeTestSubject()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pinniped-storage-device-authorization-aqqmkgdsji",
+			Labels: map[string]string{
+				"storage.pinniped.dev/type": "device-authorization",
+			},
+		},
+		Data: map[string][]byte{
+			"pinniped-storage-data":    []byte(`{"clientID":"pinniped-cli","version":"not-the-right-version"}`),
+			"pinniped-storage-version": []byte("1"),
+		},
+		Type: "storage.pinniped.dev/device-authorization",
+	}
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, err = storage.GetDeviceAuthorization(ctx, "BCDFGHJK")
+	require.EqualError(t, err, "device authorization data has wrong version: device authorization for BCDFGHJK has version not-the-right-version instead of 1")
+}
+
+func makeTestSubject() (context.Context, *fake.Clientset, corev1client.SecretInterface, Storage) {
+	client := fake.NewSimpleClientset()
+	secrets := client.CoreV1().Secrets(namespace)
+	return context.Background(), client, secrets, New(secrets, clock.NewFakeClock(fakeNow).Now, lifetime)
+}
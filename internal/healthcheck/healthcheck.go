@@ -0,0 +1,88 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package healthcheck implements the "healthcheck" subcommand of the Pinniped server binaries, which requests a health
+// endpoint of the server running in the same container and fails unless it responds with 200 OK. It can be used as an
+// exec probe in container images which have neither a shell nor curl.
+package healthcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxBodySize limits how much of the response is read and printed, since a health endpoint only responds with a
+// short status.
+const maxBodySize = 4096
+
+// Check requests the URL and returns an error unless the response status is 200 OK. When socketPath is set, the
+// request is sent through that unix socket instead of the host of the URL. The response body is written to w.
+func Check(ctx context.Context, url, socketPath string, w io.Writer) error {
+	transport := &http.Transport{
+		// The server runs in the same container, and its serving certificate is not issued for the loopback address
+		// which is probed, so there is nothing to verify.
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint: gosec
+	}
+	if socketPath != "" {
+		dialer := &net.Dialer{}
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+	}
+	client := &http.Client{Transport: transport}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", url, err)
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unhealthy: %w", err)
+	}
+	defer func() { _ = rsp.Body.Close() }()
+
+	body, err := ioutil.ReadAll(io.LimitReader(rsp.Body, maxBodySize))
+	if err != nil {
+		return fmt.Errorf("unhealthy: could not read response: %w", err)
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unhealthy: %s returned %s: %s", url, rsp.Status, strings.TrimSpace(string(body)))
+	}
+	_, err = fmt.Fprintln(w, strings.TrimSpace(string(body)))
+	return err
+}
+
+// HandleArgs runs a health check and returns true when args (without the program name) start with "healthcheck",
+// optionally followed by "--url", "--socket", and "--timeout". The URL defaults to defaultURL, which should be the
+// health endpoint of the binary's own default listener. Otherwise it does nothing and returns false, so the caller
+// can continue with its usual argument handling.
+func HandleArgs(ctx context.Context, args []string, w io.Writer, defaultURL string) (bool, error) {
+	if len(args) == 0 || args[0] != "healthcheck" {
+		return false, nil
+	}
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard) // errors are returned instead
+	var url, socketPath string
+	var timeout time.Duration
+	flags.StringVar(&url, "url", defaultURL, "URL of the health endpoint")
+	flags.StringVar(&socketPath, "socket", "", "path of a unix socket through which the URL is requested")
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "how long to wait for the response")
+	if err := flags.Parse(args[1:]); err != nil {
+		return true, err
+	}
+	if flags.NArg() != 0 {
+		return true, fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return true, Check(ctx, url, socketPath, w)
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleArgs(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no upstream identity providers", http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	httpServer := httptest.NewServer(mux)
+	t.Cleanup(httpServer.Close)
+	httpsServer := httptest.NewTLSServer(mux)
+	t.Cleanup(httpsServer.Close)
+
+	socketDir, err := ioutil.TempDir("", "pinniped-healthcheck-test-*")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, os.RemoveAll(socketDir)) })
+	socketPath := filepath.Join(socketDir, "http.sock")
+	socketListener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	socketServer := &httptest.Server{Listener: socketListener, Config: &http.Server{Handler: mux}}
+	socketServer.Start()
+	t.Cleanup(socketServer.Close)
+
+	tests := []struct {
+		name        string
+		args        []string
+		defaultURL  string
+		wantHandled bool
+		wantOutput  string
+		wantErr     string
+	}{
+		{
+			name: "no args",
+		},
+		{
+			name: "other args",
+			args: []string{"/etc/podinfo", "/etc/config/pinniped.yaml"},
+		},
+		{
+			name:        "default URL",
+			args:        []string{"healthcheck"},
+			defaultURL:  httpServer.URL + "/healthz",
+			wantHandled: true,
+			wantOutput:  "ok\n",
+		},
+		{
+			name:        "HTTPS with a certificate which is not verified",
+			args:        []string{"healthcheck", "--url", httpsServer.URL + "/healthz"},
+			defaultURL:  "http://127.0.0.1:0/healthz",
+			wantHandled: true,
+			wantOutput:  "ok\n",
+		},
+		{
+			name:        "unix socket",
+			args:        []string{"healthcheck", "--socket", socketPath, "--url", "http://localhost/healthz"},
+			wantHandled: true,
+			wantOutput:  "ok\n",
+		},
+		{
+			name:        "unhealthy",
+			args:        []string{"healthcheck", "--url", httpServer.URL + "/readyz"},
+			wantHandled: true,
+			wantErr:     "unhealthy: " + httpServer.URL + "/readyz returned 503 Service Unavailable: no upstream identity providers",
+		},
+		{
+			name:        "timeout",
+			args:        []string{"healthcheck", "--url", httpServer.URL + "/slow", "--timeout", "10ms"},
+			wantHandled: true,
+			wantErr:     `unhealthy: Get "` + httpServer.URL + `/slow": context deadline exceeded`,
+		},
+		{
+			name:        "invalid URL",
+			args:        []string{"healthcheck", "--url", "://no-scheme"},
+			wantHandled: true,
+			wantErr:     `invalid URL "://no-scheme": parse "://no-scheme": missing protocol scheme`,
+		},
+		{
+			name:        "unknown flag",
+			args:        []string{"healthcheck", "--path", "/readyz"},
+			wantHandled: true,
+			wantErr:     "flag provided but not defined: -path",
+		},
+		{
+			name:        "unexpected argument",
+			args:        []string{"healthcheck", "/readyz"},
+			wantHandled: true,
+			wantErr:     "unexpected arguments: [/readyz]",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			var buf bytes.Buffer
+			handled, err := HandleArgs(ctx, tt.args, &buf, tt.defaultURL)
+			require.Equal(t, tt.wantHandled, handled)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Empty(t, buf.String())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantOutput, buf.String())
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package keychain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// runFunc runs a command with the provided stdin, returning its stdout and its exit code when it fails.
+type runFunc func(stdin string, name string, args ...string) (stdout string, exitCode int, err error)
+
+func runCommand(stdin string, name string, args ...string) (string, int, error) {
+	cmd := exec.Command(name, args...) //nolint:gosec // the commands are system binaries
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", exitErr.ExitCode(), fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+		}
+		return "", -1, fmt.Errorf("%s failed: %w", name, err)
+	}
+	return stdout.String(), 0, nil
+}
+
+// The commands are run by their absolute paths in system directories, never through $PATH, so that a directory which
+// was prepended to $PATH cannot substitute a program which captures the secrets.
+const securityPath = "/usr/bin/security"
+
+// secretToolDirs are the directories into which the packages of Linux distributions install secret-tool.
+//nolint: gochecknoglobals
+var secretToolDirs = []string{"/usr/bin", "/bin"}
+
+// findCommand returns the path of the first executable file with the name in the directories.
+func findCommand(dirs []string, name string) (string, bool) {
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0 {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// macOSKeychain uses the "security" command to store generic passwords in the login keychain. Secrets are passed
+// through stdin, so they never appear in the arguments of a process.
+type macOSKeychain struct {
+	run runFunc
+}
+
+// securityItemNotFound is the exit code of "security" when there is no matching item.
+const securityItemNotFound = 44
+
+func (k *macOSKeychain) Get(account string) (string, error) {
+	stdout, exitCode, err := k.run("", securityPath, "find-generic-password", "-s", service, "-a", account, "-w")
+	if exitCode == securityItemNotFound {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(stdout, "\n"), nil
+}
+
+func (k *macOSKeychain) Set(account string, secret string) error {
+	// In interactive mode, "security" reads its commands from stdin.
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		strconv.Quote(service), strconv.Quote(account), strconv.Quote(secret))
+	_, _, err := k.run(command, securityPath, "-i")
+	return err
+}
+
+func (k *macOSKeychain) Delete(account string) error {
+	_, exitCode, err := k.run("", securityPath, "delete-generic-password", "-s", service, "-a", account)
+	if exitCode == securityItemNotFound {
+		return nil
+	}
+	return err
+}
+
+// secretServiceKeychain uses the "secret-tool" command of libsecret to store items with a Secret Service provider.
+type secretServiceKeychain struct {
+	run runFunc
+	// path is the absolute path of secret-tool.
+	path string
+}
+
+func (k *secretServiceKeychain) Get(account string) (string, error) {
+	stdout, exitCode, err := k.run("", k.path, "lookup", "service", service, "account", account)
+	// secret-tool exits with 1 and prints nothing when there is no matching item.
+	if exitCode == 1 && stdout == "" {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if stdout == "" {
+		return "", ErrNotFound
+	}
+	return stdout, nil
+}
+
+func (k *secretServiceKeychain) Set(account string, secret string) error {
+	_, _, err := k.run(secret, k.path, "store", "--label=Pinniped "+account, "service", service, "account", account)
+	return err
+}
+
+func (k *secretServiceKeychain) Delete(account string) error {
+	_, _, err := k.run("", k.path, "clear", "service", service, "account", account)
+	return err
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package keychain
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/testutil"
+)
+
+type fakeCommand struct {
+	stdout   string
+	exitCode int
+	err      error
+
+	gotStdin string
+	gotArgs  string
+}
+
+func (f *fakeCommand) run(stdin string, name string, args ...string) (string, int, error) {
+	f.gotStdin = stdin
+	f.gotArgs = strings.Join(append([]string{name}, args...), " ")
+	return f.stdout, f.exitCode, f.err
+}
+
+func TestMacOSKeychain(t *testing.T) {
+	someErr := fmt.Errorf("some error")
+
+	t.Run("get", func(t *testing.T) {
+		cmd := &fakeCommand{stdout: "some-secret\n"}
+		secret, err := (&macOSKeychain{run: cmd.run}).Get("some-account")
+		require.NoError(t, err)
+		require.Equal(t, "some-secret", secret)
+		require.Equal(t, "/usr/bin/security find-generic-password -s pinniped -a some-account -w", cmd.gotArgs)
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		cmd := &fakeCommand{exitCode: 44, err: someErr}
+		_, err := (&macOSKeychain{run: cmd.run}).Get("some-account")
+		require.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("get error", func(t *testing.T) {
+		cmd := &fakeCommand{exitCode: 1, err: someErr}
+		_, err := (&macOSKeychain{run: cmd.run}).Get("some-account")
+		require.Equal(t, someErr, err)
+	})
+
+	t.Run("set passes the secret through stdin", func(t *testing.T) {
+		cmd := &fakeCommand{}
+		require.NoError(t, (&macOSKeychain{run: cmd.run}).Set("some-account", "some-secret"))
+		require.Equal(t, "/usr/bin/security -i", cmd.gotArgs)
+		require.Equal(t, `add-generic-password -U -s "pinniped" -a "some-account" -w "some-secret"`+"\n", cmd.gotStdin)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		cmd := &fakeCommand{}
+		require.NoError(t, (&macOSKeychain{run: cmd.run}).Delete("some-account"))
+		require.Equal(t, "/usr/bin/security delete-generic-password -s pinniped -a some-account", cmd.gotArgs)
+	})
+
+	t.Run("delete not found", func(t *testing.T) {
+		cmd := &fakeCommand{exitCode: 44, err: someErr}
+		require.NoError(t, (&macOSKeychain{run: cmd.run}).Delete("some-account"))
+	})
+}
+
+func TestSecretServiceKeychain(t *testing.T) {
+	someErr := fmt.Errorf("some error")
+
+	t.Run("get", func(t *testing.T) {
+		cmd := &fakeCommand{stdout: "some-secret"}
+		secret, err := (&secretServiceKeychain{run: cmd.run, path: "/usr/bin/secret-tool"}).Get("some-account")
+		require.NoError(t, err)
+		require.Equal(t, "some-secret", secret)
+		require.Equal(t, "/usr/bin/secret-tool lookup service pinniped account some-account", cmd.gotArgs)
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		cmd := &fakeCommand{exitCode: 1, err: someErr}
+		_, err := (&secretServiceKeychain{run: cmd.run, path: "/usr/bin/secret-tool"}).Get("some-account")
+		require.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("get error", func(t *testing.T) {
+		cmd := &fakeCommand{exitCode: -1, err: someErr}
+		_, err := (&secretServiceKeychain{run: cmd.run, path: "/usr/bin/secret-tool"}).Get("some-account")
+		require.Equal(t, someErr, err)
+	})
+
+	t.Run("set passes the secret through stdin", func(t *testing.T) {
+		cmd := &fakeCommand{}
+		require.NoError(t, (&secretServiceKeychain{run: cmd.run, path: "/usr/bin/secret-tool"}).Set("some-account", "some-secret"))
+		require.Equal(t, "/usr/bin/secret-tool store --label=Pinniped some-account service pinniped account some-account", cmd.gotArgs)
+		require.Equal(t, "some-secret", cmd.gotStdin)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		cmd := &fakeCommand{}
+		require.NoError(t, (&secretServiceKeychain{run: cmd.run, path: "/usr/bin/secret-tool"}).Delete("some-account"))
+		require.Equal(t, "/usr/bin/secret-tool clear service pinniped account some-account", cmd.gotArgs)
+	})
+}
+
+func TestRunCommand(t *testing.T) {
+	stdout, exitCode, err := runCommand("some-input", "cat")
+	require.NoError(t, err)
+	require.Equal(t, 0, exitCode)
+	require.Equal(t, "some-input", stdout)
+
+	_, exitCode, err = runCommand("", "sh", "-c", "echo some-message >&2; exit 3")
+	require.EqualError(t, err, "sh failed: exit status 3: some-message")
+	require.Equal(t, 3, exitCode)
+
+	_, exitCode, err = runCommand("", "/does/not/exist")
+	require.Error(t, err)
+	require.Equal(t, -1, exitCode)
+}
+
+func TestFindCommand(t *testing.T) {
+	dir1, dir2 := testutil.TempDir(t), testutil.TempDir(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir1, "not-executable"), []byte("#!/bin/sh\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir2, "not-executable"), []byte("#!/bin/sh\n"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir1, "some-command"), []byte("#!/bin/sh\n"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir2, "some-command"), []byte("#!/bin/sh\n"), 0700))
+
+	path, ok := findCommand([]string{dir1, dir2}, "some-command")
+	require.True(t, ok)
+	require.Equal(t, filepath.Join(dir1, "some-command"), path)
+
+	path, ok = findCommand([]string{dir1, dir2}, "not-executable")
+	require.True(t, ok)
+	require.Equal(t, filepath.Join(dir2, "not-executable"), path)
+
+	_, ok = findCommand([]string{dir1, dir2}, "does-not-exist")
+	require.False(t, ok)
+
+	_, ok = findCommand([]string{dir1}, ".")
+	require.False(t, ok)
+}
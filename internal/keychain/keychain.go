@@ -0,0 +1,31 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package keychain stores secrets of the Pinniped CLI in the credential store of the operating system: the macOS
+// Keychain, the Windows Credential Manager, or a Secret Service provider such as GNOME Keyring on Linux.
+package keychain
+
+import "go.pinniped.dev/internal/constable"
+
+// service is the name under which all items of the Pinniped CLI are stored.
+const service = "pinniped"
+
+const (
+	// ErrNotFound is returned by Get when there is no item for the account.
+	ErrNotFound = constable.Error("keychain item not found")
+
+	// ErrUnavailable is returned when there is no usable credential store on this system.
+	ErrUnavailable = constable.Error("no OS keychain is available")
+)
+
+// Keychain stores secrets by account name.
+type Keychain interface {
+	Get(account string) (string, error)
+	Set(account string, secret string) error
+	Delete(account string) error
+}
+
+// New returns the credential store of this system, or ErrUnavailable when there is none.
+func New() (Keychain, error) {
+	return newPlatformKeychain()
+}
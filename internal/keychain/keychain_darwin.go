@@ -0,0 +1,13 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package keychain
+
+import "path/filepath"
+
+func newPlatformKeychain() (Keychain, error) {
+	if _, ok := findCommand([]string{filepath.Dir(securityPath)}, filepath.Base(securityPath)); !ok {
+		return nil, ErrUnavailable
+	}
+	return &macOSKeychain{run: runCommand}, nil
+}
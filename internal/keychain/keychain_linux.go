@@ -0,0 +1,18 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package keychain
+
+import "os"
+
+func newPlatformKeychain() (Keychain, error) {
+	// The Secret Service is provided on the session bus, so it is not reachable without one (e.g. over SSH).
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return nil, ErrUnavailable
+	}
+	path, ok := findCommand(secretToolDirs, "secret-tool")
+	if !ok {
+		return nil, ErrUnavailable
+	}
+	return &secretServiceKeychain{run: runCommand, path: path}, nil
+}
@@ -0,0 +1,10 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// +build !darwin,!linux,!windows
+
+package keychain
+
+func newPlatformKeychain() (Keychain, error) {
+	return nil, ErrUnavailable
+}
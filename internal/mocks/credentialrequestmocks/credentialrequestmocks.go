@@ -11,6 +11,7 @@ package credentialrequestmocks
 import (
 	context "context"
 	pkix "crypto/x509/pkix"
+	url "net/url"
 	reflect "reflect"
 	time "time"
 
@@ -43,20 +44,20 @@ func (m *MockCertIssuer) EXPECT() *MockCertIssuerMockRecorder {
 	return m.recorder
 }
 
-// IssuePEM mocks base method
-func (m *MockCertIssuer) IssuePEM(arg0 pkix.Name, arg1 []string, arg2 time.Duration) ([]byte, []byte, error) {
+// IssueClientCertPEM mocks base method
+func (m *MockCertIssuer) IssueClientCertPEM(arg0 pkix.Name, arg1 []*url.URL, arg2 time.Duration) ([]byte, []byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IssuePEM", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "IssueClientCertPEM", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].([]byte)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
 
-// IssuePEM indicates an expected call of IssuePEM
-func (mr *MockCertIssuerMockRecorder) IssuePEM(arg0, arg1, arg2 interface{}) *gomock.Call {
+// IssueClientCertPEM indicates an expected call of IssueClientCertPEM
+func (mr *MockCertIssuerMockRecorder) IssueClientCertPEM(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssuePEM", reflect.TypeOf((*MockCertIssuer)(nil).IssuePEM), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueClientCertPEM", reflect.TypeOf((*MockCertIssuer)(nil).IssueClientCertPEM), arg0, arg1, arg2)
 }
 
 // MockTokenCredentialRequestAuthenticator is a mock of TokenCredentialRequestAuthenticator interface
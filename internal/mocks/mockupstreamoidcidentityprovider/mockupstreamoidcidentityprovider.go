@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 //
 
@@ -11,6 +11,7 @@ package mockupstreamoidcidentityprovider
 import (
 	context "context"
 	gomock "github.com/golang/mock/gomock"
+	usernametemplate "go.pinniped.dev/internal/oidc/usernametemplate"
 	nonce "go.pinniped.dev/pkg/oidcclient/nonce"
 	oidctypes "go.pinniped.dev/pkg/oidcclient/oidctypes"
 	pkce "go.pinniped.dev/pkg/oidcclient/pkce"
@@ -42,6 +43,20 @@ func (m *MockUpstreamOIDCIdentityProviderI) EXPECT() *MockUpstreamOIDCIdentityPr
 	return m.recorder
 }
 
+// AllowsPasswordGrant mocks base method
+func (m *MockUpstreamOIDCIdentityProviderI) AllowsPasswordGrant() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllowsPasswordGrant")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// AllowsPasswordGrant indicates an expected call of AllowsPasswordGrant
+func (mr *MockUpstreamOIDCIdentityProviderIMockRecorder) AllowsPasswordGrant() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllowsPasswordGrant", reflect.TypeOf((*MockUpstreamOIDCIdentityProviderI)(nil).AllowsPasswordGrant))
+}
+
 // ExchangeAuthcodeAndValidateTokens mocks base method
 func (m *MockUpstreamOIDCIdentityProviderI) ExchangeAuthcodeAndValidateTokens(arg0 context.Context, arg1 string, arg2 pkce.Code, arg3 nonce.Nonce, arg4 string) (*oidctypes.Token, error) {
 	m.ctrl.T.Helper()
@@ -127,6 +142,20 @@ func (mr *MockUpstreamOIDCIdentityProviderIMockRecorder) GetScopes() *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScopes", reflect.TypeOf((*MockUpstreamOIDCIdentityProviderI)(nil).GetScopes))
 }
 
+// GetUIDClaim mocks base method
+func (m *MockUpstreamOIDCIdentityProviderI) GetUIDClaim() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUIDClaim")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetUIDClaim indicates an expected call of GetUIDClaim
+func (mr *MockUpstreamOIDCIdentityProviderIMockRecorder) GetUIDClaim() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUIDClaim", reflect.TypeOf((*MockUpstreamOIDCIdentityProviderI)(nil).GetUIDClaim))
+}
+
 // GetUsernameClaim mocks base method
 func (m *MockUpstreamOIDCIdentityProviderI) GetUsernameClaim() string {
 	m.ctrl.T.Helper()
@@ -141,6 +170,49 @@ func (mr *MockUpstreamOIDCIdentityProviderIMockRecorder) GetUsernameClaim() *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsernameClaim", reflect.TypeOf((*MockUpstreamOIDCIdentityProviderI)(nil).GetUsernameClaim))
 }
 
+// GetUsernameTemplate mocks base method
+func (m *MockUpstreamOIDCIdentityProviderI) GetUsernameTemplate() *usernametemplate.Template {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsernameTemplate")
+	ret0, _ := ret[0].(*usernametemplate.Template)
+	return ret0
+}
+
+// GetUsernameTemplate indicates an expected call of GetUsernameTemplate
+func (mr *MockUpstreamOIDCIdentityProviderIMockRecorder) GetUsernameTemplate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsernameTemplate", reflect.TypeOf((*MockUpstreamOIDCIdentityProviderI)(nil).GetUsernameTemplate))
+}
+
+// PasswordCredentialsGrantAndValidateTokens mocks base method
+func (m *MockUpstreamOIDCIdentityProviderI) PasswordCredentialsGrantAndValidateTokens(arg0 context.Context, arg1, arg2 string) (*oidctypes.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PasswordCredentialsGrantAndValidateTokens", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*oidctypes.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PasswordCredentialsGrantAndValidateTokens indicates an expected call of PasswordCredentialsGrantAndValidateTokens
+func (mr *MockUpstreamOIDCIdentityProviderIMockRecorder) PasswordCredentialsGrantAndValidateTokens(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PasswordCredentialsGrantAndValidateTokens", reflect.TypeOf((*MockUpstreamOIDCIdentityProviderI)(nil).PasswordCredentialsGrantAndValidateTokens), arg0, arg1, arg2)
+}
+
+// ValidateIssuerParameter mocks base method
+func (m *MockUpstreamOIDCIdentityProviderI) ValidateIssuerParameter(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateIssuerParameter", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ValidateIssuerParameter indicates an expected call of ValidateIssuerParameter
+func (mr *MockUpstreamOIDCIdentityProviderIMockRecorder) ValidateIssuerParameter(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateIssuerParameter", reflect.TypeOf((*MockUpstreamOIDCIdentityProviderI)(nil).ValidateIssuerParameter), arg0)
+}
+
 // ValidateToken mocks base method
 func (m *MockUpstreamOIDCIdentityProviderI) ValidateToken(arg0 context.Context, arg1 *oauth2.Token, arg2 nonce.Nonce) (*oidctypes.Token, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,125 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package audit writes the security audit log of the Supervisor, which records every authorization attempt, login,
+// and token grant as one JSON object per line. Unlike the logs of plog, audit events are always written in full,
+// regardless of the log level, but only once an output has been set with SetOutput.
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of an audit event.
+type EventType string
+
+const (
+	// EventAuthorize is an authorization request which redirects the user to an upstream identity provider.
+	EventAuthorize EventType = "authorize"
+	// EventLogin is a completed login, i.e. the user was authenticated and an authorization code was issued.
+	EventLogin EventType = "login"
+	// EventTokenIssued is the redemption of an authorization code or a device code at the token endpoint.
+	EventTokenIssued EventType = "token_issued"
+	// EventTokenRefreshed is the use of a refresh token at the token endpoint.
+	EventTokenRefreshed EventType = "token_refreshed"
+	// EventTokenExchanged is an RFC 8693 token exchange for a cluster-scoped ID token at the token endpoint.
+	EventTokenExchanged EventType = "token_exchanged"
+	// EventToken is any other request to the token endpoint, e.g. one with an unsupported grant type.
+	EventToken EventType = "token"
+	// EventAdminListSessions is a request to list the sessions at the admin endpoint of the Supervisor pod.
+	EventAdminListSessions EventType = "admin_list_sessions"
+	// EventAdminRevokeUser is a request to revoke the sessions of a user at the admin endpoint.
+	EventAdminRevokeUser EventType = "admin_revoke_user"
+	// EventAdminRotateJWKS is a request to rotate the signing keys of FederationDomains at the admin endpoint.
+	EventAdminRotateJWKS EventType = "admin_rotate_jwks"
+)
+
+// Event describes what happened. The fields which are unknown at the time of the event are left empty.
+type Event struct {
+	Type        EventType
+	Username    string
+	Subject     string
+	UpstreamIDP string
+	ClientID    string
+	Audience    string // only for token exchanges
+}
+
+type record struct {
+	Time         string    `json:"ts"`
+	Type         EventType `json:"event"`
+	Outcome      string    `json:"outcome"`
+	Error        string    `json:"error,omitempty"`
+	Username     string    `json:"username,omitempty"`
+	Subject      string    `json:"subject,omitempty"`
+	UpstreamIDP  string    `json:"upstreamIDP,omitempty"`
+	ClientID     string    `json:"clientID,omitempty"`
+	Audience     string    `json:"audience,omitempty"`
+	SourceIP     string    `json:"sourceIP,omitempty"`
+	ForwardedFor string    `json:"forwardedFor,omitempty"`
+	UserAgent    string    `json:"userAgent,omitempty"`
+}
+
+//nolint: gochecknoglobals
+var (
+	lock  sync.Mutex
+	out   io.Writer
+	clock = time.Now
+)
+
+// SetOutput sets where the audit events are written, e.g. os.Stdout or a file. A nil writer disables the audit log.
+func SetOutput(w io.Writer) {
+	lock.Lock()
+	defer lock.Unlock()
+	out = w
+}
+
+// Record writes the event which was caused by the request. A nil err means that it succeeded.
+func Record(r *http.Request, event Event, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if out == nil {
+		return
+	}
+
+	rec := record{
+		Time:        clock().UTC().Format(time.RFC3339Nano),
+		Type:        event.Type,
+		Outcome:     "success",
+		Username:    event.Username,
+		Subject:     event.Subject,
+		UpstreamIDP: event.UpstreamIDP,
+		ClientID:    event.ClientID,
+		Audience:    event.Audience,
+		SourceIP:    SourceIP(r),
+		// The header is recorded as it was received, since only the operator knows which proxies can be trusted.
+		ForwardedFor: r.Header.Get("X-Forwarded-For"),
+		UserAgent:    r.UserAgent(),
+	}
+	if err != nil {
+		rec.Outcome, rec.Error = "failure", err.Error()
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(rec); err != nil {
+		return // cannot happen, every field is a string
+	}
+	_, _ = out.Write(buf.Bytes())
+}
+
+// SourceIP returns the IP address of the client of r, which is the peer of the connection rather than any address
+// from a request header, since those can be forged.
+func SourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr // e.g. a unix socket
+	}
+	return host
+}
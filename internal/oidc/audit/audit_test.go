@@ -0,0 +1,56 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	clock = func() time.Time { return time.Date(2021, 6, 1, 12, 0, 0, 123, time.FixedZone("EST", -5*60*60)) }
+	t.Cleanup(func() {
+		clock = time.Now
+		SetOutput(nil)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/some/path", nil)
+	req.RemoteAddr = "10.0.0.1:54321"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
+	req.Header.Set("User-Agent", "some-agent/1.0")
+
+	// Nothing is written until there is an output.
+	Record(req, Event{Type: EventAuthorize}, nil)
+
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	Record(req, Event{Type: EventAuthorize, UpstreamIDP: "some-idp", ClientID: "pinniped-cli"}, nil)
+	Record(req, Event{
+		Type:        EventLogin,
+		Username:    "some-user<admin>",
+		Subject:     "https://issuer?sub=some-subject",
+		UpstreamIDP: "some-idp",
+		ClientID:    "pinniped-cli",
+	}, errors.New("login was denied by an administrator"))
+
+	req.RemoteAddr = "@"
+	req.Header.Del("X-Forwarded-For")
+	req.Header.Del("User-Agent")
+	Record(req, Event{Type: EventTokenExchanged, Username: "some-user", Audience: "some-cluster"}, nil)
+
+	SetOutput(nil)
+	Record(req, Event{Type: EventToken}, nil)
+
+	require.Equal(t, ``+
+		`{"ts":"2021-06-01T17:00:00.000000123Z","event":"authorize","outcome":"success","upstreamIDP":"some-idp","clientID":"pinniped-cli","sourceIP":"10.0.0.1","forwardedFor":"203.0.113.7, 10.0.0.2","userAgent":"some-agent/1.0"}`+"\n"+
+		`{"ts":"2021-06-01T17:00:00.000000123Z","event":"login","outcome":"failure","error":"login was denied by an administrator","username":"some-user<admin>","subject":"https://issuer?sub=some-subject","upstreamIDP":"some-idp","clientID":"pinniped-cli","sourceIP":"10.0.0.1","forwardedFor":"203.0.113.7, 10.0.0.2","userAgent":"some-agent/1.0"}`+"\n"+
+		`{"ts":"2021-06-01T17:00:00.000000123Z","event":"token_exchanged","outcome":"success","username":"some-user","audience":"some-cluster","sourceIP":"@"}`+"\n",
+		buf.String())
+}
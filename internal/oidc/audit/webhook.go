@@ -0,0 +1,169 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// maxWebhookBatchSize limits how many events are sent in a single request.
+	maxWebhookBatchSize = 500
+
+	// The reasons for dropping events, used as the value of the "reason" label.
+	dropReasonBufferFull = "buffer_full"
+	dropReasonRejected   = "rejected"
+)
+
+//nolint: gochecknoglobals
+var webhookDroppedEvents = metrics.NewCounterVec(&metrics.CounterOpts{
+	Name: "pinniped_supervisor_audit_webhook_dropped_events_total",
+	Help: "Number of audit events which were never sent to the audit webhook by reason, i.e. buffer_full when the " +
+		"webhook was unavailable for too long, or rejected when it responded with a client error.",
+	StabilityLevel: metrics.ALPHA,
+}, []string{"reason"})
+
+//nolint: gochecknoinits
+func init() {
+	legacyregistry.MustRegister(webhookDroppedEvents)
+}
+
+// Webhook is an output of the audit log which POSTs the events to an HTTPS endpoint, e.g. the HTTP event collector
+// of a SIEM system. Each call to Write must be a single event, as written by Record. The events are buffered in
+// memory and sent in batches by Run as newline-delimited JSON, and a batch which cannot be sent is retried with
+// exponential backoff until it succeeds. Events which arrive while the buffer is full are dropped, so that logins
+// never wait for the webhook.
+type Webhook struct {
+	url           string
+	authorization string
+	client        *http.Client
+	events        chan []byte
+
+	minRetryDelay time.Duration
+	maxRetryDelay time.Duration
+}
+
+// NewWebhook returns a Webhook which sends the events to the https URL. The optional authorization is sent as the
+// Authorization header of each request, and the optional caBundle is trusted instead of the system roots.
+func NewWebhook(url, authorization string, caBundle []byte, bufferSize int) (*Webhook, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(caBundle) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caBundle) {
+			return nil, constable.Error("invalid CA bundle for the audit webhook: no certificates found")
+		}
+	}
+	return &Webhook{
+		url:           url,
+		authorization: authorization,
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+			Timeout:   30 * time.Second,
+		},
+		events:        make(chan []byte, bufferSize),
+		minRetryDelay: time.Second,
+		maxRetryDelay: time.Minute,
+	}, nil
+}
+
+// Write buffers the event. It never blocks and never fails, even when the event has to be dropped.
+func (w *Webhook) Write(p []byte) (int, error) {
+	event := make([]byte, len(p))
+	copy(event, p)
+	select {
+	case w.events <- event:
+	default:
+		webhookDroppedEvents.WithLabelValues(dropReasonBufferFull).Inc()
+	}
+	return len(p), nil
+}
+
+// Run sends the buffered events until the context is done.
+func (w *Webhook) Run(ctx context.Context) {
+	for {
+		var batch bytes.Buffer
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-w.events:
+			batch.Write(event)
+		}
+		// Add the events which are already waiting, so that a backlog is sent in a few requests.
+	collect:
+		for count := 1; count < maxWebhookBatchSize; count++ {
+			select {
+			case event := <-w.events:
+				batch.Write(event)
+			default:
+				break collect
+			}
+		}
+		w.sendWithRetries(ctx, batch.Bytes())
+	}
+}
+
+func (w *Webhook) sendWithRetries(ctx context.Context, batch []byte) {
+	delay := w.minRetryDelay
+	for {
+		retry, err := w.send(ctx, batch)
+		if err == nil {
+			return
+		}
+		if !retry {
+			plog.Warning("audit webhook rejected events, dropping them", "url", w.url, "err", err)
+			webhookDroppedEvents.WithLabelValues(dropReasonRejected).Add(float64(bytes.Count(batch, []byte("\n"))))
+			return
+		}
+		plog.Warning("could not send events to audit webhook, will retry", "url", w.url, "err", err, "retryAfter", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > w.maxRetryDelay {
+			delay = w.maxRetryDelay
+		}
+	}
+}
+
+// send POSTs the batch, and returns whether it should be retried when it fails.
+func (w *Webhook) send(ctx context.Context, batch []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(batch))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if w.authorization != "" {
+		req.Header.Set("Authorization", w.authorization)
+	}
+	rsp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() { _ = rsp.Body.Close() }()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(rsp.Body, 64*1024)) // allow the connection to be reused
+
+	switch {
+	case rsp.StatusCode >= 200 && rsp.StatusCode < 300:
+		return false, nil
+	case rsp.StatusCode == http.StatusRequestTimeout, rsp.StatusCode == http.StatusTooManyRequests, rsp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected response %s", rsp.Status)
+	default:
+		return false, fmt.Errorf("unexpected response %s", rsp.Status)
+	}
+}
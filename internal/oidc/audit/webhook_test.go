@@ -0,0 +1,147 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metricstestutil "k8s.io/component-base/metrics/testutil"
+)
+
+type webhookServer struct {
+	*httptest.Server
+	lock      sync.Mutex
+	responses []int
+	requests  []*http.Request
+	bodies    []string
+}
+
+func newWebhookServer(t *testing.T, responses ...int) *webhookServer {
+	s := &webhookServer{responses: responses}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, string(body))
+		status := http.StatusOK
+		if len(s.responses) > 0 {
+			status, s.responses = s.responses[0], s.responses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookServer) caBundle() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+}
+
+func (s *webhookServer) received() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func runWebhook(t *testing.T, webhook *Webhook) {
+	webhook.minRetryDelay, webhook.maxRetryDelay = time.Millisecond, 2*time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		webhook.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestWebhook(t *testing.T) {
+	t.Run("batches the buffered events and retries until they are accepted", func(t *testing.T) {
+		server := newWebhookServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		webhook, err := NewWebhook(server.URL+"/events", "Splunk some-token", server.caBundle(), 10)
+		require.NoError(t, err)
+
+		for _, event := range []string{`{"event":"authorize"}` + "\n", `{"event":"login"}` + "\n"} {
+			n, err := webhook.Write([]byte(event))
+			require.NoError(t, err)
+			require.Equal(t, len(event), n)
+		}
+		runWebhook(t, webhook)
+
+		want := `{"event":"authorize"}` + "\n" + `{"event":"login"}` + "\n"
+		require.Eventually(t, func() bool { return len(server.received()) == 3 }, 10*time.Second, 10*time.Millisecond)
+		require.Equal(t, []string{want, want, want}, server.received())
+
+		_, _ = webhook.Write([]byte(`{"event":"token_issued"}` + "\n"))
+		require.Eventually(t, func() bool { return len(server.received()) == 4 }, 10*time.Second, 10*time.Millisecond)
+		require.Equal(t, `{"event":"token_issued"}`+"\n", server.received()[3])
+
+		server.lock.Lock()
+		defer server.lock.Unlock()
+		for _, req := range server.requests {
+			require.Equal(t, http.MethodPost, req.Method)
+			require.Equal(t, "/events", req.URL.Path)
+			require.Equal(t, "application/x-ndjson", req.Header.Get("Content-Type"))
+			require.Equal(t, "Splunk some-token", req.Header.Get("Authorization"))
+		}
+	})
+
+	t.Run("drops events which are rejected or which do not fit into the buffer", func(t *testing.T) {
+		server := newWebhookServer(t, http.StatusBadRequest)
+		webhook, err := NewWebhook(server.URL, "", server.caBundle(), 2)
+		require.NoError(t, err)
+
+		rejectedBefore, err := metricstestutil.GetCounterMetricValue(webhookDroppedEvents.WithLabelValues(dropReasonRejected))
+		require.NoError(t, err)
+		bufferFullBefore, err := metricstestutil.GetCounterMetricValue(webhookDroppedEvents.WithLabelValues(dropReasonBufferFull))
+		require.NoError(t, err)
+
+		for _, event := range []string{"1\n", "2\n", "3\n"} {
+			_, err := webhook.Write([]byte(event))
+			require.NoError(t, err)
+		}
+		runWebhook(t, webhook)
+		require.Eventually(t, func() bool { return len(server.received()) == 1 }, 10*time.Second, 10*time.Millisecond)
+		require.Equal(t, []string{"1\n2\n"}, server.received())
+
+		_, _ = webhook.Write([]byte("4\n"))
+		require.Eventually(t, func() bool { return len(server.received()) == 2 }, 10*time.Second, 10*time.Millisecond)
+		require.Equal(t, "4\n", server.received()[1])
+
+		rejected, err := metricstestutil.GetCounterMetricValue(webhookDroppedEvents.WithLabelValues(dropReasonRejected))
+		require.NoError(t, err)
+		require.Equal(t, float64(2), rejected-rejectedBefore)
+		bufferFull, err := metricstestutil.GetCounterMetricValue(webhookDroppedEvents.WithLabelValues(dropReasonBufferFull))
+		require.NoError(t, err)
+		require.Equal(t, float64(1), bufferFull-bufferFullBefore)
+	})
+
+	t.Run("does not trust the server without its CA bundle", func(t *testing.T) {
+		server := newWebhookServer(t)
+		webhook, err := NewWebhook(server.URL, "", nil, 10)
+		require.NoError(t, err)
+		retry, err := webhook.send(context.Background(), []byte("1\n"))
+		require.True(t, retry)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "certificate signed by unknown authority")
+		require.Empty(t, server.received())
+	})
+
+	t.Run("invalid CA bundle", func(t *testing.T) {
+		_, err := NewWebhook("https://example.com", "", []byte("not a certificate"), 10)
+		require.EqualError(t, err, "invalid CA bundle for the audit webhook: no certificates found")
+	})
+}
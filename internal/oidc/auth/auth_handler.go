@@ -1,12 +1,16 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package auth provides a handler for the OIDC authorization endpoint.
 package auth
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
@@ -18,17 +22,32 @@ import (
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/httputil/securityheader"
 	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/audit"
 	"go.pinniped.dev/internal/oidc/csrftoken"
+	"go.pinniped.dev/internal/oidc/downstreamsession"
+	"go.pinniped.dev/internal/oidc/loginapproval"
 	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/oidc/staticadmin"
+	"go.pinniped.dev/internal/perror"
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/pkg/oidcclient/nonce"
 	"go.pinniped.dev/pkg/oidcclient/pkce"
 )
 
+// NewHandler returns the handler for the authorization endpoint. The oauthHelperWithoutStorage is used to redirect
+// the user to an upstream OIDC provider, since nothing needs to be stored until the callback. When the request carries
+// a username and password instead, the user is authenticated immediately, so the authorization code is issued using
+// oauthHelperWithStorage. The password is checked by the static admin identity provider when the request selects it
+// with the pinniped_idp_type parameter and staticAdminIDP is non-nil, and otherwise by the selected upstream OIDC
+// provider using its password grant, when it allows that. Like at the callback endpoint, those users can only log in
+// once they were approved when loginApprover is non-nil.
 func NewHandler(
 	downstreamIssuer string,
 	idpListGetter oidc.IDPListGetter,
-	oauthHelper fosite.OAuth2Provider,
+	staticAdminIDP *staticadmin.IdentityProvider,
+	loginApprover loginapproval.Approver,
+	oauthHelperWithoutStorage fosite.OAuth2Provider,
+	oauthHelperWithStorage fosite.OAuth2Provider,
 	generateCSRF func() (csrftoken.CSRFToken, error),
 	generatePKCE func() (pkce.Code, error),
 	generateNonce func() (nonce.Nonce, error),
@@ -45,18 +64,52 @@ func NewHandler(
 
 		csrfFromCookie := readCSRFCookie(r, cookieCodec)
 
-		authorizeRequester, err := oauthHelper.NewAuthorizeRequest(r.Context(), r)
+		authorizeRequester, err := oauthHelperWithoutStorage.NewAuthorizeRequest(r.Context(), r)
 		if err != nil {
 			plog.Info("authorize request error", oidc.FositeErrorForLog(err)...)
-			oauthHelper.WriteAuthorizeError(w, authorizeRequester, err)
+			audit.Record(r, audit.Event{Type: audit.EventAuthorize, ClientID: r.Form.Get("client_id")}, err)
+			oauthHelperWithoutStorage.WriteAuthorizeError(w, authorizeRequester, err)
 			return nil
 		}
+		event := audit.Event{Type: audit.EventAuthorize, ClientID: authorizeRequester.GetClient().GetID()}
+
+		idpType := authorizeRequester.GetRequestForm().Get(oidc.UpstreamIDPTypeParamName)
+		if hasPasswordCredentials(r) && idpType == oidc.UpstreamIDPTypeStaticAdmin {
+			if staticAdminIDP == nil {
+				plog.Info("static admin login attempted while the static admin identity provider is disabled")
+				err := fosite.ErrInvalidRequest.WithHint("The static admin identity provider is not enabled.")
+				audit.Record(r, event, err)
+				oauthHelperWithoutStorage.WriteAuthorizeError(w, authorizeRequester, err)
+				return nil
+			}
+			return handleStaticAdminLogin(w, r, oauthHelperWithStorage, authorizeRequester, staticAdminIDP, loginApprover)
+		}
 
-		upstreamIDP, err := chooseUpstreamIDP(idpListGetter)
+		upstreamIDP, err := chooseUpstreamIDP(
+			idpListGetter,
+			authorizeRequester.GetRequestForm().Get(oidc.UpstreamIDPNameParamName),
+			idpType,
+		)
 		if err != nil {
-			plog.WarningErr("authorize upstream config", err)
+			perror.Log("authorize upstream config", err)
+			audit.Record(r, event, err)
 			return err
 		}
+		event.UpstreamIDP = upstreamIDP.GetName()
+
+		if hasPasswordCredentials(r) {
+			// Redirecting a non-interactive client to the login page of an upstream provider would only leave it
+			// waiting for a login which can never happen.
+			if !upstreamIDP.AllowsPasswordGrant() {
+				plog.Info("username/password login attempted with an upstream which does not allow the password grant",
+					"upstreamName", upstreamIDP.GetName())
+				err := fosite.ErrInvalidRequest.WithHint("The identity provider does not support username/password logins.")
+				audit.Record(r, event, err)
+				oauthHelperWithoutStorage.WriteAuthorizeError(w, authorizeRequester, err)
+				return nil
+			}
+			return handleUpstreamPasswordLogin(w, r, oauthHelperWithStorage, authorizeRequester, upstreamIDP, loginApprover)
+		}
 
 		// Grant the openid scope (for now) if they asked for it so that `NewAuthorizeResponse` will perform its OIDC validations.
 		oidc.GrantScopeIfRequested(authorizeRequester, coreosoidc.ScopeOpenID)
@@ -68,7 +121,7 @@ func NewHandler(
 		oidc.GrantScopeIfRequested(authorizeRequester, "pinniped:request-audience")
 
 		now := time.Now()
-		_, err = oauthHelper.NewAuthorizeResponse(r.Context(), authorizeRequester, &openid.DefaultSession{
+		_, err = oauthHelperWithoutStorage.NewAuthorizeResponse(r.Context(), authorizeRequester, &openid.DefaultSession{
 			Claims: &jwt.IDTokenClaims{
 				// Temporary claim values to allow `NewAuthorizeResponse` to perform other OIDC validations.
 				Subject:     "none",
@@ -78,7 +131,8 @@ func NewHandler(
 		})
 		if err != nil {
 			plog.Info("authorize response error", oidc.FositeErrorForLog(err)...)
-			oauthHelper.WriteAuthorizeError(w, authorizeRequester, err)
+			audit.Record(r, event, err)
+			oauthHelperWithoutStorage.WriteAuthorizeError(w, authorizeRequester, err)
 			return nil
 		}
 
@@ -134,6 +188,7 @@ func NewHandler(
 			authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam("prompt", promptParam))
 		}
 
+		audit.Record(r, event, nil)
 		http.Redirect(w, r,
 			upstreamOAuthConfig.AuthCodeURL(
 				encodedStateParamValue,
@@ -146,6 +201,208 @@ func NewHandler(
 	}))
 }
 
+func hasPasswordCredentials(r *http.Request) bool {
+	return r.Header.Get(staticadmin.UsernameHeaderName) != "" || r.Header.Get(staticadmin.PasswordHeaderName) != ""
+}
+
+func handleStaticAdminLogin(
+	w http.ResponseWriter,
+	r *http.Request,
+	oauthHelper fosite.OAuth2Provider,
+	authorizeRequester fosite.AuthorizeRequester,
+	staticAdminIDP *staticadmin.IdentityProvider,
+	loginApprover loginapproval.Approver,
+) error {
+	event := audit.Event{
+		Type:        audit.EventLogin,
+		Username:    r.Header.Get(staticadmin.UsernameHeaderName),
+		UpstreamIDP: staticadmin.Name,
+		ClientID:    authorizeRequester.GetClient().GetID(),
+	}
+	identity, authenticated, err := staticAdminIDP.AuthenticateUser(
+		r.Context(),
+		audit.SourceIP(r),
+		r.Header.Get(staticadmin.UsernameHeaderName),
+		r.Header.Get(staticadmin.PasswordHeaderName),
+	)
+	var throttledErr *staticadmin.ThrottledError
+	if errors.As(err, &throttledErr) {
+		plog.Info("static admin authentication throttled", "retryAfter", throttledErr.RetryAfter.String())
+		err := fosite.ErrTemporarilyUnavailable.WithHint("Too many failed logins, please try again later.")
+		audit.Record(r, event, err)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttledErr.RetryAfter.Seconds()))))
+		oauthHelper.WriteAuthorizeError(w, authorizeRequester, err)
+		return nil
+	}
+	if err != nil {
+		pErr := perror.Wrap(perror.CodeUpstreamFailed, "unexpected error during static admin authentication", err)
+		perror.Log("static admin authentication error", pErr, "secretName", staticAdminIDP.SecretName())
+		audit.Record(r, event, pErr)
+		return pErr
+	}
+	if !authenticated {
+		plog.Info("static admin authentication failed", "secretName", staticAdminIDP.SecretName())
+		err := fosite.ErrAccessDenied.WithHint("Username/password not accepted.")
+		audit.Record(r, event, err)
+		oauthHelper.WriteAuthorizeError(w, authorizeRequester, err)
+		return nil
+	}
+	event.Username, event.Subject = identity.Username, identity.Subject
+
+	if loginApprover != nil {
+		decision, err := loginApprover.Check(r.Context(), identity.Subject, identity.Username)
+		if err != nil {
+			pErr := perror.Wrap(perror.CodeInternal, "error checking login approval", err)
+			perror.Log("static admin login error", pErr, "subject", identity.Subject)
+			audit.Record(r, event, pErr)
+			return pErr
+		}
+		if err := loginApprovalError(decision); err != nil {
+			plog.Info("static admin login was not approved", "decision", decision, "subject", identity.Subject, "username", identity.Username)
+			audit.Record(r, event, err)
+			oauthHelper.WriteAuthorizeError(w, authorizeRequester, err)
+			return nil
+		}
+	}
+
+	plog.Warning("user logged in using the static admin identity provider, "+
+		"which should be disabled as soon as a real identity provider has been configured",
+		"username", identity.Username,
+		"secretName", staticAdminIDP.SecretName(),
+	)
+
+	// Automatically grant the openid, offline_access, and pinniped:request-audience scopes, but only if they were requested.
+	oidc.GrantScopeIfRequested(authorizeRequester, coreosoidc.ScopeOpenID)
+	oidc.GrantScopeIfRequested(authorizeRequester, coreosoidc.ScopeOfflineAccess)
+	oidc.GrantScopeIfRequested(authorizeRequester, "pinniped:request-audience")
+
+	openIDSession := oidc.MakeDownstreamSession(identity.Subject, identity.Username, identity.Groups, identity.UID)
+	authorizeResponder, err := oauthHelper.NewAuthorizeResponse(r.Context(), authorizeRequester, openIDSession)
+	if err != nil {
+		plog.Info("authorize response error", oidc.FositeErrorForLog(err)...)
+		audit.Record(r, event, err)
+		oauthHelper.WriteAuthorizeError(w, authorizeRequester, err)
+		return nil
+	}
+
+	audit.Record(r, event, nil)
+	oauthHelper.WriteAuthorizeResponse(w, authorizeRequester, authorizeResponder)
+	return nil
+}
+
+func handleUpstreamPasswordLogin(
+	w http.ResponseWriter,
+	r *http.Request,
+	oauthHelper fosite.OAuth2Provider,
+	authorizeRequester fosite.AuthorizeRequester,
+	upstreamIDP provider.UpstreamOIDCIdentityProviderI,
+	loginApprover loginapproval.Approver,
+) error {
+	event := audit.Event{
+		Type:        audit.EventLogin,
+		Username:    r.Header.Get(staticadmin.UsernameHeaderName),
+		UpstreamIDP: upstreamIDP.GetName(),
+		ClientID:    authorizeRequester.GetClient().GetID(),
+	}
+	token, err := upstreamIDP.PasswordCredentialsGrantAndValidateTokens(
+		r.Context(),
+		r.Header.Get(staticadmin.UsernameHeaderName),
+		r.Header.Get(staticadmin.PasswordHeaderName),
+	)
+	if err != nil {
+		if isInvalidGrantError(err) {
+			plog.Info("upstream password grant failed", "upstreamName", upstreamIDP.GetName(), "error", err.Error())
+			err := fosite.ErrAccessDenied.WithHint("Username/password not accepted.")
+			audit.Record(r, event, err)
+			oauthHelper.WriteAuthorizeError(w, authorizeRequester, err)
+			return nil
+		}
+		pErr := perror.Wrap(perror.CodeUpstreamFailed, "error performing upstream password grant", err)
+		perror.Log("upstream password grant error", pErr, "upstreamName", upstreamIDP.GetName())
+		audit.Record(r, event, pErr)
+		return pErr
+	}
+
+	subject, username, err := downstreamsession.GetSubjectAndUsernameFromUpstreamIDToken(upstreamIDP, token.IDToken.Claims)
+	if err != nil {
+		audit.Record(r, event, err)
+		return err
+	}
+	event.Subject, event.Username = subject, username
+
+	groups, err := downstreamsession.GetGroupsFromUpstreamIDToken(upstreamIDP, token.IDToken.Claims)
+	if err != nil {
+		audit.Record(r, event, err)
+		return err
+	}
+
+	uid, err := downstreamsession.GetUIDFromUpstreamIDToken(upstreamIDP, token.IDToken.Claims)
+	if err != nil {
+		audit.Record(r, event, err)
+		return err
+	}
+
+	if loginApprover != nil {
+		decision, err := loginApprover.Check(r.Context(), subject, username)
+		if err != nil {
+			pErr := perror.Wrap(perror.CodeInternal, "error checking login approval", err)
+			perror.Log("upstream password login error", pErr, "subject", subject)
+			audit.Record(r, event, pErr)
+			return pErr
+		}
+		if err := loginApprovalError(decision); err != nil {
+			plog.Info("upstream password login was not approved", "decision", decision, "subject", subject, "username", username)
+			audit.Record(r, event, err)
+			oauthHelper.WriteAuthorizeError(w, authorizeRequester, err)
+			return nil
+		}
+	}
+
+	// Automatically grant the openid, offline_access, and pinniped:request-audience scopes, but only if they were requested.
+	oidc.GrantScopeIfRequested(authorizeRequester, coreosoidc.ScopeOpenID)
+	oidc.GrantScopeIfRequested(authorizeRequester, coreosoidc.ScopeOfflineAccess)
+	oidc.GrantScopeIfRequested(authorizeRequester, "pinniped:request-audience")
+
+	openIDSession := oidc.MakeDownstreamSession(subject, username, groups, uid)
+	authorizeResponder, err := oauthHelper.NewAuthorizeResponse(r.Context(), authorizeRequester, openIDSession)
+	if err != nil {
+		plog.Info("authorize response error", oidc.FositeErrorForLog(err)...)
+		audit.Record(r, event, err)
+		oauthHelper.WriteAuthorizeError(w, authorizeRequester, err)
+		return nil
+	}
+
+	audit.Record(r, event, nil)
+	oauthHelper.WriteAuthorizeResponse(w, authorizeRequester, authorizeResponder)
+	return nil
+}
+
+// isInvalidGrantError returns true when the upstream token endpoint rejected the credentials with an invalid_grant
+// error, which it uses for a wrong username or password, see https://datatracker.ietf.org/doc/html/rfc6749#section-5.2.
+// Other errors, e.g. invalid_client or unauthorized_client, mean that the upstream is misconfigured.
+func isInvalidGrantError(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+	var errorResponse struct {
+		Error string `json:"error"`
+	}
+	return json.Unmarshal(retrieveErr.Body, &errorResponse) == nil && errorResponse.Error == "invalid_grant"
+}
+
+// loginApprovalError returns the error for a login which was not approved, or nil when it was.
+func loginApprovalError(decision loginapproval.Decision) error {
+	switch decision {
+	case loginapproval.Approved:
+		return nil
+	case loginapproval.Pending:
+		return fosite.ErrAccessDenied.WithHint("Login is pending approval by an administrator.")
+	default:
+		return fosite.ErrAccessDenied.WithHint("Login was denied by an administrator.")
+	}
+}
+
 func readCSRFCookie(r *http.Request, codec oidc.Decoder) csrftoken.CSRFToken {
 	receivedCSRFCookie, err := r.Cookie(oidc.CSRFCookieName)
 	if err != nil {
@@ -165,13 +422,26 @@ func readCSRFCookie(r *http.Request, codec oidc.Decoder) csrftoken.CSRFToken {
 	return csrfFromCookie
 }
 
-func chooseUpstreamIDP(idpListGetter oidc.IDPListGetter) (provider.UpstreamOIDCIdentityProviderI, error) {
+// chooseUpstreamIDP returns the upstream identity provider which the client asked for by name and type. Clients may
+// leave out the name when there is only one upstream identity provider.
+func chooseUpstreamIDP(idpListGetter oidc.IDPListGetter, name string, idpType string) (provider.UpstreamOIDCIdentityProviderI, error) {
+	if idpType != "" && idpType != oidc.UpstreamIDPTypeOIDC {
+		return nil, perror.New(
+			perror.CodeInvalidRequest,
+			fmt.Sprintf("Unsupported upstream identity provider type %q (only %q is supported)", idpType, oidc.UpstreamIDPTypeOIDC),
+		)
+	}
 	allUpstreamIDPs := idpListGetter.GetIDPList()
+	if name != "" {
+		for _, idp := range allUpstreamIDPs {
+			if idp.GetName() == name {
+				return idp, nil
+			}
+		}
+		return nil, perror.New(perror.CodeNotConfigured, fmt.Sprintf("No upstream provider named %q is configured", name))
+	}
 	if len(allUpstreamIDPs) == 0 {
-		return nil, httperr.New(
-			http.StatusUnprocessableEntity,
-			"No upstream providers are configured",
-		)
+		return nil, perror.New(perror.CodeNotConfigured, "No upstream providers are configured")
 	} else if len(allUpstreamIDPs) > 1 {
 		var upstreamIDPNames []string
 		for _, idp := range allUpstreamIDPs {
@@ -180,9 +450,9 @@ func chooseUpstreamIDP(idpListGetter oidc.IDPListGetter) (provider.UpstreamOIDCI
 
 		plog.Warning("Too many upstream providers are configured (found: %s)", upstreamIDPNames)
 
-		return nil, httperr.New(
-			http.StatusUnprocessableEntity,
-			"Too many upstream providers are configured (support for multiple upstreams is not yet implemented)",
+		return nil, perror.New(
+			perror.CodeNotConfigured,
+			"Too many upstream providers are configured (choose one with the "+oidc.UpstreamIDPNameParamName+" parameter)",
 		)
 	}
 	return allUpstreamIDPs[0], nil
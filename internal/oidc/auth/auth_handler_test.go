@@ -1,9 +1,13 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package auth
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"html"
 	"net/http"
@@ -14,16 +18,27 @@ import (
 	"testing"
 
 	"github.com/gorilla/securecookie"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/storage"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 
 	"go.pinniped.dev/internal/here"
 	"go.pinniped.dev/internal/oidc"
 	"go.pinniped.dev/internal/oidc/csrftoken"
 	"go.pinniped.dev/internal/oidc/jwks"
+	"go.pinniped.dev/internal/oidc/loginapproval"
 	"go.pinniped.dev/internal/oidc/oidctestutil"
 	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/oidc/staticadmin"
 	"go.pinniped.dev/internal/testutil"
 	"go.pinniped.dev/pkg/oidcclient/nonce"
+	"go.pinniped.dev/pkg/oidcclient/oidctypes"
 	"go.pinniped.dev/pkg/oidcclient/pkce"
 )
 
@@ -94,6 +109,24 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			"state":             "short",
 		}
 
+		fositeAccessDeniedErrorQuery = map[string]string{
+			"error":             "access_denied",
+			"error_description": "The resource owner or authorization server denied the request. Username/password not accepted.",
+			"state":             happyState,
+		}
+
+		fositePasswordLoginNotSupportedErrorQuery = map[string]string{
+			"error":             "invalid_request",
+			"error_description": "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. The identity provider does not support username/password logins.",
+			"state":             happyState,
+		}
+
+		fositeStaticAdminNotEnabledErrorQuery = map[string]string{
+			"error":             "invalid_request",
+			"error_description": "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. The static admin identity provider is not enabled.",
+			"state":             happyState,
+		}
+
 		fositeMissingResponseTypeErrorQuery = map[string]string{
 			"error":             "unsupported_response_type",
 			"error_description": "The authorization server does not support obtaining a token using this method. `The request is missing the 'response_type' parameter.",
@@ -110,6 +143,36 @@ func TestAuthorizationEndpoint(t *testing.T) {
 		AuthorizationURL: *upstreamAuthURL,
 		Scopes:           []string{"scope1", "scope2"}, // the scopes to request when starting the upstream authorization flow
 	}
+	otherUpstreamOIDCIdentityProvider := upstreamOIDCIdentityProvider
+	otherUpstreamOIDCIdentityProvider.Name = "some-other-idp"
+
+	upstreamIDTokenClaims := map[string]interface{}{
+		"iss":    "https://some-upstream-issuer",
+		"sub":    "some-upstream-subject",
+		"email":  "some-user@example.com",
+		"groups": []interface{}{"some-group", "other-group"},
+	}
+	passwordGrantUpstream := func(claims map[string]interface{}, err error) *oidctestutil.TestUpstreamOIDCIdentityProvider {
+		upstream := upstreamOIDCIdentityProvider
+		upstream.UsernameClaim = "email"
+		upstream.GroupsClaim = "groups"
+		upstream.AllowPasswordGrant = true
+		upstream.PasswordCredentialsGrantAndValidateTokensFunc = func(_ context.Context, username, password string) (*oidctypes.Token, error) {
+			if err != nil {
+				return nil, err
+			}
+			require.Equal(t, "some-user", username)
+			require.Equal(t, "some-password", password)
+			return &oidctypes.Token{IDToken: &oidctypes.IDToken{Claims: claims}}, nil
+		}
+		return &upstream
+	}
+	upstreamPasswordLoginIdentity := &staticadmin.Identity{
+		Subject:  "https://some-upstream-issuer?sub=some-upstream-subject",
+		Username: "some-user@example.com",
+		Groups:   []string{"some-group", "other-group"},
+		UID:      sha256Hex("https://some-upstream-issuer?sub=some-upstream-subject"),
+	}
 
 	// Configure fosite the same way that the production code would, using NullStorage to turn off storage.
 	oauthStore := oidc.NullStorage{}
@@ -118,6 +181,24 @@ func TestAuthorizationEndpoint(t *testing.T) {
 	jwksProviderIsUnused := jwks.NewDynamicJWKSProvider()
 	oauthHelper := oidc.FositeOauth2Helper(oauthStore, downstreamIssuer, hmacSecretFunc, jwksProviderIsUnused, oidc.DefaultOIDCTimeoutsConfiguration())
 
+	// Logging in as the static admin issues an authcode immediately, so that needs an oauth helper with real storage.
+	memoryStore := oidc.NewMemoryStorage()
+	oauthHelperWithStorage := oidc.FositeOauth2Helper(memoryStore, downstreamIssuer, hmacSecretFunc, jwksProviderIsUnused, oidc.DefaultOIDCTimeoutsConfiguration())
+
+	staticAdminPasswordHash, err := bcrypt.GenerateFromPassword([]byte("some-admin-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	staticAdminSecrets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, staticAdminSecrets.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "admin-secret", Namespace: "some-namespace"},
+		Data: map[string][]byte{
+			"username":     []byte("admin"),
+			"passwordHash": staticAdminPasswordHash,
+			"groups":       []byte("admins"),
+		},
+	}))
+	staticAdminIDP := staticadmin.New("admin-secret", corev1listers.NewSecretLister(staticAdminSecrets).Secrets("some-namespace"))
+	staticAdminIDPWithMissingSecret := staticadmin.New("missing-secret", corev1listers.NewSecretLister(staticAdminSecrets).Secrets("some-namespace"))
+
 	happyCSRF := "test-csrf"
 	happyPKCE := "test-pkce"
 	happyNonce := "test-nonce"
@@ -195,6 +276,8 @@ func TestAuthorizationEndpoint(t *testing.T) {
 		return pathWithQuery("/some/path", modifiedHappyGetRequestQueryMap(queryOverrides))
 	}
 
+	staticAdminGetRequestPath := modifiedHappyGetRequestPath(map[string]string{"pinniped_idp_type": "static-admin"})
+
 	expectedUpstreamStateParam := func(queryOverrides map[string]string, csrfValueOverride, upstreamNameOverride string) string {
 		csrf := happyCSRF
 		if csrfValueOverride != "" {
@@ -256,6 +339,11 @@ func TestAuthorizationEndpoint(t *testing.T) {
 		body          string
 		csrfCookie    string
 
+		staticAdminIDP *staticadmin.IdentityProvider
+		loginUsername  string
+		loginPassword  string
+		loginApprover  loginapproval.Approver
+
 		wantStatus                  int
 		wantContentType             string
 		wantBodyString              string
@@ -265,6 +353,7 @@ func TestAuthorizationEndpoint(t *testing.T) {
 
 		wantUpstreamStateParamInLocationHeader bool
 		wantBodyStringWithLocationInHref       bool
+		wantDownstreamAuthcodeSession          *staticadmin.Identity
 	}
 	tests := []testCase{
 		{
@@ -385,6 +474,28 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			wantUpstreamStateParamInLocationHeader: true,
 			wantBodyStringWithLocationInHref:       true,
 		},
+		{
+			name:          "happy path when downstream redirect uri is the out-of-band redirect uri for manual code entry",
+			issuer:        downstreamIssuer,
+			idpListGetter: oidctestutil.NewIDPListGetter(&upstreamOIDCIdentityProvider),
+			generateCSRF:  happyCSRFGenerator,
+			generatePKCE:  happyPKCEGenerator,
+			generateNonce: happyNonceGenerator,
+			stateEncoder:  happyStateEncoder,
+			cookieEncoder: happyCookieEncoder,
+			method:        http.MethodGet,
+			path: modifiedHappyGetRequestPath(map[string]string{
+				"redirect_uri": oidc.ManualCodeRedirectURI,
+			}),
+			wantStatus:                  http.StatusFound,
+			wantContentType:             "text/html; charset=utf-8",
+			wantCSRFValueInCookieHeader: happyCSRF,
+			wantLocationHeader: expectedRedirectLocation(expectedUpstreamStateParam(map[string]string{
+				"redirect_uri": oidc.ManualCodeRedirectURI,
+			}, "", ""), ""),
+			wantUpstreamStateParamInLocationHeader: true,
+			wantBodyStringWithLocationInHref:       true,
+		},
 		{
 			name:                        "happy path when downstream requested scopes include offline_access",
 			issuer:                      downstreamIssuer,
@@ -712,7 +823,51 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			path:            happyGetRequestPath,
 			wantStatus:      http.StatusUnprocessableEntity,
 			wantContentType: "text/plain; charset=utf-8",
-			wantBodyString:  "Unprocessable Entity: Too many upstream providers are configured (support for multiple upstreams is not yet implemented)\n",
+			wantBodyString:  "Unprocessable Entity: Too many upstream providers are configured (choose one with the pinniped_idp_name parameter)\n",
+		},
+		{
+			name:          "happy path choosing one of many upstream providers by name and type",
+			issuer:        downstreamIssuer,
+			idpListGetter: oidctestutil.NewIDPListGetter(&otherUpstreamOIDCIdentityProvider, &upstreamOIDCIdentityProvider),
+			generateCSRF:  happyCSRFGenerator,
+			generatePKCE:  happyPKCEGenerator,
+			generateNonce: happyNonceGenerator,
+			stateEncoder:  happyStateEncoder,
+			cookieEncoder: happyCookieEncoder,
+			method:        http.MethodGet,
+			path: modifiedHappyGetRequestPath(map[string]string{
+				"pinniped_idp_name": "some-idp",
+				"pinniped_idp_type": "oidc",
+			}),
+			wantStatus:                  http.StatusFound,
+			wantContentType:             "text/html; charset=utf-8",
+			wantCSRFValueInCookieHeader: happyCSRF,
+			wantLocationHeader: expectedRedirectLocation(expectedUpstreamStateParam(map[string]string{
+				"pinniped_idp_name": "some-idp",
+				"pinniped_idp_type": "oidc",
+			}, "", ""), ""),
+			wantUpstreamStateParamInLocationHeader: true,
+			wantBodyStringWithLocationInHref:       true,
+		},
+		{
+			name:            "upstream provider name is not configured",
+			issuer:          downstreamIssuer,
+			idpListGetter:   oidctestutil.NewIDPListGetter(&otherUpstreamOIDCIdentityProvider, &upstreamOIDCIdentityProvider),
+			method:          http.MethodGet,
+			path:            modifiedHappyGetRequestPath(map[string]string{"pinniped_idp_name": "some-missing-idp"}),
+			wantStatus:      http.StatusUnprocessableEntity,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Unprocessable Entity: No upstream provider named \"some-missing-idp\" is configured\n",
+		},
+		{
+			name:            "unsupported upstream provider type",
+			issuer:          downstreamIssuer,
+			idpListGetter:   oidctestutil.NewIDPListGetter(&upstreamOIDCIdentityProvider),
+			method:          http.MethodGet,
+			path:            modifiedHappyGetRequestPath(map[string]string{"pinniped_idp_type": "ldap"}),
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Bad Request: Unsupported upstream identity provider type \"ldap\" (only \"oidc\" is supported)\n",
 		},
 		{
 			name:            "PUT is a bad method",
@@ -744,6 +899,285 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			wantContentType: "text/plain; charset=utf-8",
 			wantBodyString:  "Method Not Allowed: DELETE (try GET or POST)\n",
 		},
+		{
+			name:            "static admin login when the static admin identity provider is enabled",
+			issuer:          downstreamIssuer,
+			idpListGetter:   oidctestutil.NewIDPListGetter(&upstreamOIDCIdentityProvider),
+			stateEncoder:    happyStateEncoder,
+			cookieEncoder:   happyCookieEncoder,
+			staticAdminIDP:  staticAdminIDP,
+			loginUsername:   "admin",
+			loginPassword:   "some-admin-password",
+			method:          http.MethodGet,
+			path:            staticAdminGetRequestPath,
+			wantStatus:      http.StatusFound,
+			wantContentType: "",
+			wantLocationHeader: urlWithQuery(downstreamRedirectURI, map[string]string{
+				"scope": "openid",
+				"state": happyState,
+			}),
+			wantBodyString: "",
+			wantDownstreamAuthcodeSession: &staticadmin.Identity{
+				Subject:  "static-admin?sub=admin",
+				Username: "admin",
+				Groups:   []string{"admins"},
+				UID:      "e143467e1b196b4aa617e55ceb619eaf1a9e23faf89f6fc143c93302eb8c7bae",
+			},
+		},
+		{
+			name:               "static admin login with the wrong password",
+			issuer:             downstreamIssuer,
+			idpListGetter:      oidctestutil.NewIDPListGetter(&upstreamOIDCIdentityProvider),
+			stateEncoder:       happyStateEncoder,
+			cookieEncoder:      happyCookieEncoder,
+			staticAdminIDP:     staticAdminIDP,
+			loginUsername:      "admin",
+			loginPassword:      "wrong-password",
+			method:             http.MethodGet,
+			path:               staticAdminGetRequestPath,
+			wantStatus:         http.StatusFound,
+			wantContentType:    "application/json; charset=utf-8",
+			wantLocationHeader: urlWithQuery(downstreamRedirectURI, fositeAccessDeniedErrorQuery),
+			wantBodyString:     "",
+		},
+		{
+			name:            "static admin login which was approved",
+			issuer:          downstreamIssuer,
+			idpListGetter:   oidctestutil.NewIDPListGetter(&upstreamOIDCIdentityProvider),
+			stateEncoder:    happyStateEncoder,
+			cookieEncoder:   happyCookieEncoder,
+			staticAdminIDP:  staticAdminIDP,
+			loginUsername:   "admin",
+			loginPassword:   "some-admin-password",
+			loginApprover:   &fakeLoginApprover{decision: loginapproval.Approved},
+			method:          http.MethodGet,
+			path:            staticAdminGetRequestPath,
+			wantStatus:      http.StatusFound,
+			wantContentType: "",
+			wantLocationHeader: urlWithQuery(downstreamRedirectURI, map[string]string{
+				"scope": "openid",
+				"state": happyState,
+			}),
+			wantBodyString: "",
+			wantDownstreamAuthcodeSession: &staticadmin.Identity{
+				Subject:  "static-admin?sub=admin",
+				Username: "admin",
+				Groups:   []string{"admins"},
+				UID:      "e143467e1b196b4aa617e55ceb619eaf1a9e23faf89f6fc143c93302eb8c7bae",
+			},
+		},
+		{
+			name:            "static admin login which is pending approval",
+			issuer:          downstreamIssuer,
+			idpListGetter:   oidctestutil.NewIDPListGetter(&upstreamOIDCIdentityProvider),
+			stateEncoder:    happyStateEncoder,
+			cookieEncoder:   happyCookieEncoder,
+			staticAdminIDP:  staticAdminIDP,
+			loginUsername:   "admin",
+			loginPassword:   "some-admin-password",
+			loginApprover:   &fakeLoginApprover{decision: loginapproval.Pending},
+			method:          http.MethodGet,
+			path:            staticAdminGetRequestPath,
+			wantStatus:      http.StatusFound,
+			wantContentType: "application/json; charset=utf-8",
+			wantLocationHeader: urlWithQuery(downstreamRedirectURI, map[string]string{
+				"error":             "access_denied",
+				"error_description": "The resource owner or authorization server denied the request. Login is pending approval by an administrator.",
+				"state":             happyState,
+			}),
+			wantBodyString: "",
+		},
+		{
+			name:            "static admin login which was denied",
+			issuer:          downstreamIssuer,
+			idpListGetter:   oidctestutil.NewIDPListGetter(&upstreamOIDCIdentityProvider),
+			stateEncoder:    happyStateEncoder,
+			cookieEncoder:   happyCookieEncoder,
+			staticAdminIDP:  staticAdminIDP,
+			loginUsername:   "admin",
+			loginPassword:   "some-admin-password",
+			loginApprover:   &fakeLoginApprover{decision: loginapproval.Denied},
+			method:          http.MethodGet,
+			path:            staticAdminGetRequestPath,
+			wantStatus:      http.StatusFound,
+			wantContentType: "application/json; charset=utf-8",
+			wantLocationHeader: urlWithQuery(downstreamRedirectURI, map[string]string{
+				"error":             "access_denied",
+				"error_description": "The resource owner or authorization server denied the request. Login was denied by an administrator.",
+				"state":             happyState,
+			}),
+			wantBodyString: "",
+		},
+		{
+			name:            "static admin login when the login approval cannot be checked",
+			issuer:          downstreamIssuer,
+			idpListGetter:   oidctestutil.NewIDPListGetter(&upstreamOIDCIdentityProvider),
+			stateEncoder:    happyStateEncoder,
+			cookieEncoder:   happyCookieEncoder,
+			staticAdminIDP:  staticAdminIDP,
+			loginUsername:   "admin",
+			loginPassword:   "some-admin-password",
+			loginApprover:   &fakeLoginApprover{err: errors.New("some lister error")},
+			method:          http.MethodGet,
+			path:            staticAdminGetRequestPath,
+			wantStatus:      http.StatusInternalServerError,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Internal Server Error: error checking login approval\n",
+		},
+		{
+			name:            "static admin login when the static admin Secret does not exist",
+			issuer:          downstreamIssuer,
+			idpListGetter:   oidctestutil.NewIDPListGetter(&upstreamOIDCIdentityProvider),
+			stateEncoder:    happyStateEncoder,
+			cookieEncoder:   happyCookieEncoder,
+			staticAdminIDP:  staticAdminIDPWithMissingSecret,
+			loginUsername:   "admin",
+			loginPassword:   "some-admin-password",
+			method:          http.MethodGet,
+			path:            staticAdminGetRequestPath,
+			wantStatus:      http.StatusBadGateway,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Bad Gateway: unexpected error during static admin authentication\n",
+		},
+		{
+			name:               "static admin login when the static admin identity provider is not enabled",
+			issuer:             downstreamIssuer,
+			idpListGetter:      oidctestutil.NewIDPListGetter(&upstreamOIDCIdentityProvider),
+			generateCSRF:       happyCSRFGenerator,
+			generatePKCE:       happyPKCEGenerator,
+			generateNonce:      happyNonceGenerator,
+			stateEncoder:       happyStateEncoder,
+			cookieEncoder:      happyCookieEncoder,
+			loginUsername:      "admin",
+			loginPassword:      "some-admin-password",
+			method:             http.MethodGet,
+			path:               staticAdminGetRequestPath,
+			wantStatus:         http.StatusFound,
+			wantContentType:    "application/json; charset=utf-8",
+			wantLocationHeader: urlWithQuery(downstreamRedirectURI, fositeStaticAdminNotEnabledErrorQuery),
+			wantBodyString:     "",
+		},
+		{
+			name:               "username/password login is not checked against the static admin when it is not selected",
+			issuer:             downstreamIssuer,
+			idpListGetter:      oidctestutil.NewIDPListGetter(&upstreamOIDCIdentityProvider),
+			stateEncoder:       happyStateEncoder,
+			cookieEncoder:      happyCookieEncoder,
+			staticAdminIDP:     staticAdminIDP,
+			loginUsername:      "admin",
+			loginPassword:      "some-admin-password",
+			method:             http.MethodGet,
+			path:               happyGetRequestPath,
+			wantStatus:         http.StatusFound,
+			wantContentType:    "application/json; charset=utf-8",
+			wantLocationHeader: urlWithQuery(downstreamRedirectURI, fositePasswordLoginNotSupportedErrorQuery),
+			wantBodyString:     "",
+		},
+		{
+			name:          "upstream password login",
+			issuer:        downstreamIssuer,
+			idpListGetter: oidctestutil.NewIDPListGetter(passwordGrantUpstream(upstreamIDTokenClaims, nil)),
+			stateEncoder:  happyStateEncoder,
+			cookieEncoder: happyCookieEncoder,
+			loginUsername: "some-user",
+			loginPassword: "some-password",
+			method:        http.MethodGet,
+			path:          happyGetRequestPath,
+			wantStatus:    http.StatusFound,
+			wantLocationHeader: urlWithQuery(downstreamRedirectURI, map[string]string{
+				"scope": "openid",
+				"state": happyState,
+			}),
+			wantDownstreamAuthcodeSession: upstreamPasswordLoginIdentity,
+		},
+		{
+			name:          "upstream password login which was approved",
+			issuer:        downstreamIssuer,
+			idpListGetter: oidctestutil.NewIDPListGetter(passwordGrantUpstream(upstreamIDTokenClaims, nil)),
+			stateEncoder:  happyStateEncoder,
+			cookieEncoder: happyCookieEncoder,
+			loginUsername: "some-user",
+			loginPassword: "some-password",
+			loginApprover: &fakeLoginApprover{decision: loginapproval.Approved},
+			method:        http.MethodGet,
+			path:          happyGetRequestPath,
+			wantStatus:    http.StatusFound,
+			wantLocationHeader: urlWithQuery(downstreamRedirectURI, map[string]string{
+				"scope": "openid",
+				"state": happyState,
+			}),
+			wantDownstreamAuthcodeSession: upstreamPasswordLoginIdentity,
+		},
+		{
+			name:            "upstream password login which is pending approval",
+			issuer:          downstreamIssuer,
+			idpListGetter:   oidctestutil.NewIDPListGetter(passwordGrantUpstream(upstreamIDTokenClaims, nil)),
+			stateEncoder:    happyStateEncoder,
+			cookieEncoder:   happyCookieEncoder,
+			loginUsername:   "some-user",
+			loginPassword:   "some-password",
+			loginApprover:   &fakeLoginApprover{decision: loginapproval.Pending},
+			method:          http.MethodGet,
+			path:            happyGetRequestPath,
+			wantStatus:      http.StatusFound,
+			wantContentType: "application/json; charset=utf-8",
+			wantLocationHeader: urlWithQuery(downstreamRedirectURI, map[string]string{
+				"error":             "access_denied",
+				"error_description": "The resource owner or authorization server denied the request. Login is pending approval by an administrator.",
+				"state":             happyState,
+			}),
+		},
+		{
+			name:   "upstream password login with the wrong password",
+			issuer: downstreamIssuer,
+			idpListGetter: oidctestutil.NewIDPListGetter(passwordGrantUpstream(nil, &oauth2.RetrieveError{
+				Response: &http.Response{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"},
+				Body:     []byte(`{"error":"invalid_grant","error_description":"invalid username or password"}`),
+			})),
+			stateEncoder:       happyStateEncoder,
+			cookieEncoder:      happyCookieEncoder,
+			loginUsername:      "some-user",
+			loginPassword:      "some-password",
+			method:             http.MethodGet,
+			path:               happyGetRequestPath,
+			wantStatus:         http.StatusFound,
+			wantContentType:    "application/json; charset=utf-8",
+			wantLocationHeader: urlWithQuery(downstreamRedirectURI, fositeAccessDeniedErrorQuery),
+		},
+		{
+			name:   "upstream password login when the upstream rejects the client",
+			issuer: downstreamIssuer,
+			idpListGetter: oidctestutil.NewIDPListGetter(passwordGrantUpstream(nil, &oauth2.RetrieveError{
+				Response: &http.Response{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"},
+				Body:     []byte(`{"error":"unauthorized_client"}`),
+			})),
+			stateEncoder:    happyStateEncoder,
+			cookieEncoder:   happyCookieEncoder,
+			loginUsername:   "some-user",
+			loginPassword:   "some-password",
+			method:          http.MethodGet,
+			path:            happyGetRequestPath,
+			wantStatus:      http.StatusBadGateway,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Bad Gateway: error performing upstream password grant\n",
+		},
+		{
+			name:   "upstream password login when the upstream ID token has no subject",
+			issuer: downstreamIssuer,
+			idpListGetter: oidctestutil.NewIDPListGetter(passwordGrantUpstream(map[string]interface{}{
+				"iss":   "https://some-upstream-issuer",
+				"email": "some-user@example.com",
+			}, nil)),
+			stateEncoder:    happyStateEncoder,
+			cookieEncoder:   happyCookieEncoder,
+			loginUsername:   "some-user",
+			loginPassword:   "some-password",
+			method:          http.MethodGet,
+			path:            happyGetRequestPath,
+			wantStatus:      http.StatusUnprocessableEntity,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Unprocessable Entity: no subject claim in upstream ID token\n",
+		},
 	}
 
 	runOneTestCase := func(t *testing.T, test testCase, subject http.Handler) {
@@ -752,6 +1186,12 @@ func TestAuthorizationEndpoint(t *testing.T) {
 		if test.csrfCookie != "" {
 			req.Header.Set("Cookie", test.csrfCookie)
 		}
+		if test.loginUsername != "" {
+			req.Header.Set(staticadmin.UsernameHeaderName, test.loginUsername)
+		}
+		if test.loginPassword != "" {
+			req.Header.Set(staticadmin.PasswordHeaderName, test.loginPassword)
+		}
 		rsp := httptest.NewRecorder()
 		subject.ServeHTTP(rsp, req)
 		t.Logf("response: %#v", rsp)
@@ -766,10 +1206,22 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			if test.wantUpstreamStateParamInLocationHeader {
 				requireEqualDecodedStateParams(t, actualLocation, test.wantLocationHeader, test.stateEncoder)
 			}
+			locationToCompare := actualLocation
+			if test.wantDownstreamAuthcodeSession != nil {
+				// The authcode is random, so check that it was stored with the expected session and then
+				// remove it before comparing the rest of the URL.
+				actualLocationURL, err := url.Parse(actualLocation)
+				require.NoError(t, err)
+				query := actualLocationURL.Query()
+				requireStaticAdminAuthcodeSession(t, memoryStore.AuthorizeCodes, query.Get("code"), test.wantDownstreamAuthcodeSession)
+				query.Del("code")
+				actualLocationURL.RawQuery = query.Encode()
+				locationToCompare = actualLocationURL.String()
+			}
 			// The upstream state param is encoded using a timestamp at the beginning so we don't want to
 			// compare those states since they may be different, but we do want to compare the downstream
 			// state param that should be exactly the same.
-			requireEqualURLs(t, actualLocation, test.wantLocationHeader, test.wantUpstreamStateParamInLocationHeader)
+			requireEqualURLs(t, locationToCompare, test.wantLocationHeader, test.wantUpstreamStateParamInLocationHeader)
 		} else {
 			require.Empty(t, rsp.Header().Values("Location"))
 		}
@@ -803,16 +1255,45 @@ func TestAuthorizationEndpoint(t *testing.T) {
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
-			subject := NewHandler(test.issuer, test.idpListGetter, oauthHelper, test.generateCSRF, test.generatePKCE, test.generateNonce, test.stateEncoder, test.cookieEncoder)
+			subject := NewHandler(test.issuer, test.idpListGetter, test.staticAdminIDP, test.loginApprover, oauthHelper, oauthHelperWithStorage, test.generateCSRF, test.generatePKCE, test.generateNonce, test.stateEncoder, test.cookieEncoder)
 			runOneTestCase(t, test, subject)
 		})
 	}
 
+	t.Run("throttles static admin logins after too many failures", func(t *testing.T) {
+		test := tests[0]
+		throttledIDP := staticadmin.New("admin-secret", corev1listers.NewSecretLister(staticAdminSecrets).Secrets("some-namespace"))
+		subject := NewHandler(test.issuer, test.idpListGetter, throttledIDP, nil, oauthHelper, oauthHelperWithStorage, test.generateCSRF, test.generatePKCE, test.generateNonce, test.stateEncoder, test.cookieEncoder)
+
+		login := func(password string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, staticAdminGetRequestPath, nil)
+			req.Header.Set(staticadmin.UsernameHeaderName, "admin")
+			req.Header.Set(staticadmin.PasswordHeaderName, password)
+			rsp := httptest.NewRecorder()
+			subject.ServeHTTP(rsp, req)
+			return rsp
+		}
+		for i := 0; i < 6; i++ {
+			rsp := login("wrong-password")
+			requireEqualURLs(t, rsp.Header().Get("Location"), urlWithQuery(downstreamRedirectURI, fositeAccessDeniedErrorQuery), false)
+		}
+
+		// The right password is not even checked during the lockout.
+		rsp := login("some-admin-password")
+		require.Equal(t, http.StatusFound, rsp.Code)
+		require.Equal(t, "1", rsp.Header().Get("Retry-After"))
+		requireEqualURLs(t, rsp.Header().Get("Location"), urlWithQuery(downstreamRedirectURI, map[string]string{
+			"error":             "temporarily_unavailable",
+			"error_description": "The authorization server is currently unable to handle the request due to a temporary overloading or maintenance of the server. Too many failed logins, please try again later.",
+			"state":             happyState,
+		}), false)
+	})
+
 	t.Run("allows upstream provider configuration to change between requests", func(t *testing.T) {
 		test := tests[0]
 		require.Equal(t, "happy path using GET without a CSRF cookie", test.name) // re-use the happy path test case
 
-		subject := NewHandler(test.issuer, test.idpListGetter, oauthHelper, test.generateCSRF, test.generatePKCE, test.generateNonce, test.stateEncoder, test.cookieEncoder)
+		subject := NewHandler(test.issuer, test.idpListGetter, test.staticAdminIDP, test.loginApprover, oauthHelper, oauthHelperWithStorage, test.generateCSRF, test.generatePKCE, test.generateNonce, test.stateEncoder, test.cookieEncoder)
 
 		runOneTestCase(t, test, subject)
 
@@ -882,6 +1363,26 @@ func requireEqualDecodedStateParams(t *testing.T, actualURL string, expectedURL
 	require.Equal(t, expectedDecodedStateParam, actualDecodedStateParam)
 }
 
+func requireStaticAdminAuthcodeSession(t *testing.T, storedAuthcodes map[string]storage.StoreAuthorizeCode, authcode string, want *staticadmin.Identity) {
+	t.Helper()
+
+	// The HMAC authcode strategy stores authcodes by their signature, which is the part after the dot.
+	authcodeParts := strings.Split(authcode, ".")
+	require.Len(t, authcodeParts, 2)
+	storedAuthcode, ok := storedAuthcodes[authcodeParts[1]]
+	require.True(t, ok, "authcode was not stored")
+
+	session, ok := storedAuthcode.GetSession().(*openid.DefaultSession)
+	require.True(t, ok)
+	require.Equal(t, want.Subject, session.Claims.Subject)
+	require.Equal(t, map[string]interface{}{
+		oidc.DownstreamUsernameClaim: want.Username,
+		oidc.DownstreamGroupsClaim:   want.Groups,
+		oidc.DownstreamUIDClaim:      want.UID,
+	}, session.Claims.Extra)
+	require.ElementsMatch(t, []string{"openid"}, storedAuthcode.GetGrantedScopes())
+}
+
 func requireEqualURLs(t *testing.T, actualURL string, expectedURL string, ignoreState bool) {
 	t.Helper()
 	actualLocationURL, err := url.Parse(actualURL)
@@ -913,3 +1414,17 @@ func requireEqualURLs(t *testing.T, actualURL string, expectedURL string, ignore
 	}
 	require.Equal(t, expectedLocationQuery, actualLocationQuery)
 }
+
+func sha256Hex(s string) string {
+	hash := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
+
+type fakeLoginApprover struct {
+	decision loginapproval.Decision
+	err      error
+}
+
+func (f *fakeLoginApprover) Check(context.Context, string, string) (loginapproval.Decision, error) {
+	return f.decision, f.err
+}
@@ -6,6 +6,7 @@ package callback
 
 import (
 	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -14,40 +15,58 @@ import (
 	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/handler/openid"
-	"github.com/ory/fosite/token/jwt"
 
+	"go.pinniped.dev/internal/fositestorage/deviceauthorization"
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/httputil/securityheader"
 	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/audit"
 	"go.pinniped.dev/internal/oidc/csrftoken"
+	"go.pinniped.dev/internal/oidc/downstreamsession"
+	"go.pinniped.dev/internal/oidc/loginapproval"
 	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/perror"
 	"go.pinniped.dev/internal/plog"
 )
 
-const (
-	// The name of the email claim from https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
-	emailClaimName = "email"
-
-	// The name of the email_verified claim from https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
-	emailVerifiedClaimName = "email_verified"
-)
-
+// NewHandler returns the handler for the callback endpoint. When loginApprover is non-nil, users can only log in
+// after their first login has been approved by an administrator. When the login was started by the device
+// verification endpoint, the authcode is stored in the device authorization using deviceStorage instead of being
+// returned to the client. When the client asked for the out-of-band redirect_uri, the authcode is shown to the user,
+// who pastes it into the client. When the login was started by the downloads page, no authcode is issued. Instead,
+// the identity of the user is stored in a downloads session cookie using downloadsSessionEncoder, and the user is
+// sent back to the downloads page of downstreamIssuer.
 func NewHandler(
 	idpListGetter oidc.IDPListGetter,
+	loginApprover loginapproval.Approver,
 	oauthHelper fosite.OAuth2Provider,
+	deviceStorage deviceauthorization.Storage,
 	stateDecoder, cookieDecoder oidc.Decoder,
 	redirectURI string,
+	downstreamIssuer string,
+	downloadsSessionEncoder oidc.Encoder,
 ) http.Handler {
-	return securityheader.Wrap(httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+	return securityheader.Wrap(httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) (err error) {
+		// Every response of this handler which does not return an error completes a login.
+		event := audit.Event{Type: audit.EventLogin}
+		defer func() { audit.Record(r, event, err) }()
+
 		state, err := validateRequest(r, stateDecoder, cookieDecoder)
 		if err != nil {
 			return err
 		}
+		event.UpstreamIDP = state.UpstreamName
 
 		upstreamIDPConfig := findUpstreamIDPConfig(state.UpstreamName, idpListGetter)
 		if upstreamIDPConfig == nil {
-			plog.Warning("upstream provider not found")
-			return httperr.New(http.StatusUnprocessableEntity, "upstream provider not found")
+			err := perror.New(perror.CodeNotConfigured, "upstream provider not found")
+			perror.Log("callback error", err, "upstreamName", state.UpstreamName)
+			return err
+		}
+
+		if err := upstreamIDPConfig.ValidateIssuerParameter(r.FormValue("iss")); err != nil {
+			plog.WarningErr("error validating upstream authorization response", err, "upstreamName", upstreamIDPConfig.GetName())
+			return err
 		}
 
 		downstreamAuthParams, err := url.ParseQuery(state.AuthParams)
@@ -63,6 +82,7 @@ func NewHandler(
 			plog.Error("error using state downstream auth params", err)
 			return httperr.New(http.StatusBadRequest, "error using state downstream auth params")
 		}
+		event.ClientID = authorizeRequester.GetClient().GetID()
 
 		// Automatically grant the openid, offline_access, and pinniped:request-audience scopes, but only if they were requested.
 		oidc.GrantScopeIfRequested(authorizeRequester, coreosoidc.ScopeOpenID)
@@ -77,33 +97,167 @@ func NewHandler(
 			redirectURI,
 		)
 		if err != nil {
-			plog.WarningErr("error exchanging and validating upstream tokens", err, "upstreamName", upstreamIDPConfig.GetName())
-			return httperr.New(http.StatusBadGateway, "error exchanging and validating upstream tokens")
+			err := perror.Wrap(perror.CodeUpstreamFailed, "error exchanging and validating upstream tokens", err)
+			perror.Log("callback error", err, "upstreamName", upstreamIDPConfig.GetName())
+			return err
 		}
 
-		subject, username, err := getSubjectAndUsernameFromUpstreamIDToken(upstreamIDPConfig, token.IDToken.Claims)
+		subject, username, err := downstreamsession.GetSubjectAndUsernameFromUpstreamIDToken(upstreamIDPConfig, token.IDToken.Claims)
 		if err != nil {
 			return err
 		}
+		event.Subject, event.Username = subject, username
 
-		groups, err := getGroupsFromUpstreamIDToken(upstreamIDPConfig, token.IDToken.Claims)
+		groups, err := downstreamsession.GetGroupsFromUpstreamIDToken(upstreamIDPConfig, token.IDToken.Claims)
 		if err != nil {
 			return err
 		}
 
-		openIDSession := makeDownstreamSession(subject, username, groups)
+		uid, err := downstreamsession.GetUIDFromUpstreamIDToken(upstreamIDPConfig, token.IDToken.Claims)
+		if err != nil {
+			return err
+		}
+
+		if loginApprover != nil {
+			if err := checkLoginApproval(r, loginApprover, upstreamIDPConfig, subject, username); err != nil {
+				return err
+			}
+		}
+
+		if downstreamAuthParams.Get(oidc.DownloadsSessionParamName) != "" {
+			return startDownloadsSession(w, r, downloadsSessionEncoder, &oidc.DownloadsSession{
+				Issuer:   downstreamIssuer,
+				Subject:  subject,
+				Username: username,
+				Groups:   groups,
+			})
+		}
+
+		openIDSession := oidc.MakeDownstreamSession(subject, username, groups, uid)
+		if userCode := downstreamAuthParams.Get(oidc.DeviceUserCodeParamName); userCode != "" {
+			return approveDevice(w, r, oauthHelper, deviceStorage, authorizeRequester, openIDSession, userCode)
+		}
+
 		authorizeResponder, err := oauthHelper.NewAuthorizeResponse(r.Context(), authorizeRequester, openIDSession)
 		if err != nil {
 			plog.WarningErr("error while generating and saving authcode", err, "upstreamName", upstreamIDPConfig.GetName())
 			return httperr.Wrap(http.StatusInternalServerError, "error while generating and saving authcode", err)
 		}
 
+		if authorizeRequester.GetRedirectURI().String() == oidc.ManualCodeRedirectURI {
+			// Nobody could receive a redirect, so the user copies the code instead. It can only be redeemed with the
+			// PKCE code verifier of the client which started the login. The page shows a credential, so neither the
+			// browser nor a proxy may store it.
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = fmt.Fprintf(w, "you have been logged in, now paste this code into the pinniped CLI to finish:\n\n%s\n", authorizeResponder.GetCode())
+			return nil
+		}
+
 		oauthHelper.WriteAuthorizeResponse(w, authorizeRequester, authorizeResponder)
 
 		return nil
 	}))
 }
 
+func approveDevice(
+	w http.ResponseWriter,
+	r *http.Request,
+	oauthHelper fosite.OAuth2Provider,
+	deviceStorage deviceauthorization.Storage,
+	authorizeRequester fosite.AuthorizeRequester,
+	openIDSession *openid.DefaultSession,
+	userCode string,
+) error {
+	if deviceStorage == nil {
+		return httperr.New(http.StatusUnprocessableEntity, "device authorization is not supported")
+	}
+
+	device, err := deviceStorage.GetDeviceAuthorization(r.Context(), userCode)
+	if errors.Is(err, fosite.ErrNotFound) {
+		plog.Info("device authorization not found", "userCode", userCode)
+		return httperr.New(http.StatusUnprocessableEntity, "device authorization has expired or was already used")
+	}
+	if err != nil {
+		plog.Error("error reading device authorization", err, "userCode", userCode)
+		return httperr.New(http.StatusInternalServerError, "error reading device authorization")
+	}
+	if device.Approved() || time.Now().After(device.ExpiresAt) {
+		return httperr.New(http.StatusUnprocessableEntity, "device authorization has expired or was already used")
+	}
+	// Only the verification endpoint knows the code challenge of the device, so nobody else can start a login for it.
+	if authorizeRequester.GetRequestForm().Get("code_challenge") != oidc.PKCEChallenge(device.CodeVerifier) {
+		plog.Info("device authorization does not match the authorization request", "userCode", userCode)
+		return httperr.New(http.StatusBadRequest, "device authorization does not match the authorization request")
+	}
+
+	authorizeResponder, err := oauthHelper.NewAuthorizeResponse(r.Context(), authorizeRequester, openIDSession)
+	if err != nil {
+		plog.WarningErr("error while generating and saving authcode", err, "userCode", userCode)
+		return httperr.Wrap(http.StatusInternalServerError, "error while generating and saving authcode", err)
+	}
+	if err := deviceStorage.ApproveDeviceAuthorization(r.Context(), userCode, authorizeResponder.GetCode()); err != nil {
+		plog.Error("error approving device authorization", err, "userCode", userCode)
+		return httperr.New(http.StatusInternalServerError, "error approving device authorization")
+	}
+
+	plog.Info("device authorization approved", "userCode", userCode, "subject", openIDSession.Claims.Subject)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("you have been logged in and may now close this tab and return to your device"))
+	return nil
+}
+
+func startDownloadsSession(w http.ResponseWriter, r *http.Request, encoder oidc.Encoder, session *oidc.DownloadsSession) error {
+	if encoder == nil {
+		return httperr.New(http.StatusUnprocessableEntity, "the downloads page is not supported")
+	}
+
+	encoded, err := encoder.Encode(oidc.DownloadsSessionCookieEncodingName, session)
+	if err != nil {
+		plog.Error("error encoding downloads session", err)
+		return httperr.New(http.StatusInternalServerError, "error encoding downloads session")
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidc.DownloadsSessionCookieName,
+		Value:    encoded,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   true,
+		Path:     "/",
+		MaxAge:   int(oidc.DownloadsSessionLifespan.Seconds()),
+	})
+	http.Redirect(w, r, session.Issuer+oidc.DownloadsEndpointPath, http.StatusSeeOther)
+	return nil
+}
+
+func checkLoginApproval(
+	r *http.Request,
+	loginApprover loginapproval.Approver,
+	upstreamIDPConfig provider.UpstreamOIDCIdentityProviderI,
+	subject, username string,
+) error {
+	decision, err := loginApprover.Check(r.Context(), subject, username)
+	if err != nil {
+		err := perror.Wrap(perror.CodeInternal, "error checking login approval", err)
+		perror.Log("callback error", err, "upstreamName", upstreamIDPConfig.GetName(), "subject", subject)
+		return err
+	}
+
+	switch decision {
+	case loginapproval.Approved:
+		return nil
+	case loginapproval.Pending:
+		err := perror.New(perror.CodeApprovalPending, "login is pending approval by an administrator")
+		perror.Log("callback error", err, "upstreamName", upstreamIDPConfig.GetName(), "subject", subject, "username", username)
+		return err
+	default:
+		err := perror.New(perror.CodeAccessDenied, "login was denied by an administrator")
+		perror.Log("callback error", err, "upstreamName", upstreamIDPConfig.GetName(), "subject", subject, "username", username)
+		return err
+	}
+}
+
 func authcode(r *http.Request) string {
 	return r.FormValue("code")
 }
@@ -184,181 +338,3 @@ func readState(r *http.Request, stateDecoder oidc.Decoder) (*oidc.UpstreamStateP
 
 	return &state, nil
 }
-
-func getSubjectAndUsernameFromUpstreamIDToken(
-	upstreamIDPConfig provider.UpstreamOIDCIdentityProviderI,
-	idTokenClaims map[string]interface{},
-) (string, string, error) {
-	// The spec says the "sub" claim is only unique per issuer,
-	// so we will prepend the issuer string to make it globally unique.
-	upstreamIssuer := idTokenClaims[oidc.IDTokenIssuerClaim]
-	if upstreamIssuer == "" {
-		plog.Warning(
-			"issuer claim in upstream ID token missing",
-			"upstreamName", upstreamIDPConfig.GetName(),
-			"issClaim", upstreamIssuer,
-		)
-		return "", "", httperr.New(http.StatusUnprocessableEntity, "issuer claim in upstream ID token missing")
-	}
-	upstreamIssuerAsString, ok := upstreamIssuer.(string)
-	if !ok {
-		plog.Warning(
-			"issuer claim in upstream ID token has invalid format",
-			"upstreamName", upstreamIDPConfig.GetName(),
-			"issClaim", upstreamIssuer,
-		)
-		return "", "", httperr.New(http.StatusUnprocessableEntity, "issuer claim in upstream ID token has invalid format")
-	}
-
-	subjectAsInterface, ok := idTokenClaims[oidc.IDTokenSubjectClaim]
-	if !ok {
-		plog.Warning(
-			"no subject claim in upstream ID token",
-			"upstreamName", upstreamIDPConfig.GetName(),
-		)
-		return "", "", httperr.New(http.StatusUnprocessableEntity, "no subject claim in upstream ID token")
-	}
-
-	upstreamSubject, ok := subjectAsInterface.(string)
-	if !ok {
-		plog.Warning(
-			"subject claim in upstream ID token has invalid format",
-			"upstreamName", upstreamIDPConfig.GetName(),
-		)
-		return "", "", httperr.New(http.StatusUnprocessableEntity, "subject claim in upstream ID token has invalid format")
-	}
-
-	subject := fmt.Sprintf("%s?%s=%s", upstreamIssuerAsString, oidc.IDTokenSubjectClaim, upstreamSubject)
-
-	usernameClaimName := upstreamIDPConfig.GetUsernameClaim()
-	if usernameClaimName == "" {
-		return subject, subject, nil
-	}
-
-	// If the upstream username claim is configured to be the special "email" claim and the upstream "email_verified"
-	// claim is present, then validate that the "email_verified" claim is true.
-	emailVerifiedAsInterface, ok := idTokenClaims[emailVerifiedClaimName]
-	if usernameClaimName == emailClaimName && ok {
-		emailVerified, ok := emailVerifiedAsInterface.(bool)
-		if !ok {
-			plog.Warning(
-				"username claim configured as \"email\" and upstream email_verified claim is not a boolean",
-				"upstreamName", upstreamIDPConfig.GetName(),
-				"configuredUsernameClaim", usernameClaimName,
-				"emailVerifiedClaim", emailVerifiedAsInterface,
-			)
-			return "", "", httperr.New(http.StatusUnprocessableEntity, "email_verified claim in upstream ID token has invalid format")
-		}
-		if !emailVerified {
-			plog.Warning(
-				"username claim configured as \"email\" and upstream email_verified claim has false value",
-				"upstreamName", upstreamIDPConfig.GetName(),
-				"configuredUsernameClaim", usernameClaimName,
-			)
-			return "", "", httperr.New(http.StatusUnprocessableEntity, "email_verified claim in upstream ID token has false value")
-		}
-	}
-
-	usernameAsInterface, ok := idTokenClaims[usernameClaimName]
-	if !ok {
-		plog.Warning(
-			"no username claim in upstream ID token",
-			"upstreamName", upstreamIDPConfig.GetName(),
-			"configuredUsernameClaim", usernameClaimName,
-		)
-		return "", "", httperr.New(http.StatusUnprocessableEntity, "no username claim in upstream ID token")
-	}
-
-	username, ok := usernameAsInterface.(string)
-	if !ok {
-		plog.Warning(
-			"username claim in upstream ID token has invalid format",
-			"upstreamName", upstreamIDPConfig.GetName(),
-			"configuredUsernameClaim", usernameClaimName,
-		)
-		return "", "", httperr.New(http.StatusUnprocessableEntity, "username claim in upstream ID token has invalid format")
-	}
-
-	return subject, username, nil
-}
-
-func getGroupsFromUpstreamIDToken(
-	upstreamIDPConfig provider.UpstreamOIDCIdentityProviderI,
-	idTokenClaims map[string]interface{},
-) ([]string, error) {
-	groupsClaimName := upstreamIDPConfig.GetGroupsClaim()
-	if groupsClaimName == "" {
-		return nil, nil
-	}
-
-	groupsAsInterface, ok := idTokenClaims[groupsClaimName]
-	if !ok {
-		plog.Warning(
-			"no groups claim in upstream ID token",
-			"upstreamName", upstreamIDPConfig.GetName(),
-			"configuredGroupsClaim", groupsClaimName,
-		)
-		return nil, nil // the upstream IDP may have omitted the claim if the user has no groups
-	}
-
-	groupsAsArray, okAsArray := extractGroups(groupsAsInterface)
-	if !okAsArray {
-		plog.Warning(
-			"groups claim in upstream ID token has invalid format",
-			"upstreamName", upstreamIDPConfig.GetName(),
-			"configuredGroupsClaim", groupsClaimName,
-		)
-		return nil, httperr.New(http.StatusUnprocessableEntity, "groups claim in upstream ID token has invalid format")
-	}
-
-	return groupsAsArray, nil
-}
-
-func extractGroups(groupsAsInterface interface{}) ([]string, bool) {
-	groupsAsString, okAsString := groupsAsInterface.(string)
-	if okAsString {
-		return []string{groupsAsString}, true
-	}
-
-	groupsAsStringArray, okAsStringArray := groupsAsInterface.([]string)
-	if okAsStringArray {
-		return groupsAsStringArray, true
-	}
-
-	groupsAsInterfaceArray, okAsArray := groupsAsInterface.([]interface{})
-	if !okAsArray {
-		return nil, false
-	}
-
-	var groupsAsStrings []string
-	for _, groupAsInterface := range groupsAsInterfaceArray {
-		groupAsString, okAsString := groupAsInterface.(string)
-		if !okAsString {
-			return nil, false
-		}
-		if groupAsString != "" {
-			groupsAsStrings = append(groupsAsStrings, groupAsString)
-		}
-	}
-
-	return groupsAsStrings, true
-}
-
-func makeDownstreamSession(subject string, username string, groups []string) *openid.DefaultSession {
-	now := time.Now().UTC()
-	openIDSession := &openid.DefaultSession{
-		Claims: &jwt.IDTokenClaims{
-			Subject:     subject,
-			RequestedAt: now,
-			AuthTime:    now,
-		},
-	}
-	if groups == nil {
-		groups = []string{}
-	}
-	openIDSession.Claims.Extra = map[string]interface{}{
-		oidc.DownstreamUsernameClaim: username,
-		oidc.DownstreamGroupsClaim:   groups,
-	}
-	return openIDSession
-}
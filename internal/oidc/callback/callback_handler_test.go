@@ -23,12 +23,16 @@ import (
 
 	"go.pinniped.dev/internal/crud"
 	"go.pinniped.dev/internal/fositestorage/authorizationcode"
+	"go.pinniped.dev/internal/fositestorage/deviceauthorization"
 	"go.pinniped.dev/internal/fositestorage/openidconnect"
 	"go.pinniped.dev/internal/fositestorage/pkce"
 	"go.pinniped.dev/internal/oidc"
 	"go.pinniped.dev/internal/oidc/jwks"
+	"go.pinniped.dev/internal/oidc/loginapproval"
 	"go.pinniped.dev/internal/oidc/oidctestutil"
+	"go.pinniped.dev/internal/oidc/usernametemplate"
 	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/internal/upstreamoidc"
 	"go.pinniped.dev/pkg/oidcclient/nonce"
 	"go.pinniped.dev/pkg/oidcclient/oidctypes"
 	oidcpkce "go.pinniped.dev/pkg/oidcclient/pkce"
@@ -43,6 +47,10 @@ const (
 
 	upstreamUsernameClaim = "the-user-claim"
 	upstreamGroupsClaim   = "the-groups-claim"
+	upstreamUIDClaim      = "the-uid-claim"
+
+	// The hex-encoded SHA-256 hash of "https://my-upstream-issuer.com?sub=abc123-some-guid".
+	happyDownstreamUID = "37cf75619573bc6ade2e98786f5e7a01266c205c415aeaed866f8b5dbbd1f51f"
 
 	happyUpstreamAuthcode = "upstream-auth-code"
 
@@ -61,6 +69,9 @@ const (
 	downstreamPKCEChallenge       = "some-challenge"
 	downstreamPKCEChallengeMethod = "S256"
 
+	deviceUserCode     = "BCDFGHJK"
+	deviceCodeVerifier = "some-device-code-verifier"
+
 	authCodeExpirationSeconds = 10 * 60 // Current, we set our auth code expiration to 10 minutes
 	timeComparisonFudgeFactor = time.Second * 15
 )
@@ -103,6 +114,8 @@ func TestCallbackEndpoint(t *testing.T) {
 	happyStateCodec.SetSerializer(securecookie.JSONEncoder{})
 	var happyCookieCodec = securecookie.New(cookieEncoderHashKey, cookieEncoderBlockKey)
 	happyCookieCodec.SetSerializer(securecookie.JSONEncoder{})
+	var happyDownloadsCodec = securecookie.New([]byte("fake-hash-secret3"), nil)
+	happyDownloadsCodec.SetSerializer(securecookie.JSONEncoder{})
 
 	happyState := happyUpstreamStateParam().Build(t, happyStateCodec)
 
@@ -120,27 +133,59 @@ func TestCallbackEndpoint(t *testing.T) {
 	// Note that fosite puts the granted scopes as a param in the redirect URI even though the spec doesn't seem to require it
 	happyDownstreamRedirectLocationRegexp := downstreamRedirectURI + `\?code=([^&]+)&scope=openid&state=` + happyDownstreamState
 
+	happyDeviceState := happyUpstreamStateParam().WithAuthorizeRequestParams(shallowCopyAndModifyQuery(happyDownstreamRequestParamsQuery, map[string]string{
+		"code_challenge":             oidc.PKCEChallenge(deviceCodeVerifier),
+		oidc.DeviceUserCodeParamName: deviceUserCode,
+	}).Encode()).Build(t, happyStateCodec)
+	happyManualCodeState := happyUpstreamStateParam().WithAuthorizeRequestParams(shallowCopyAndModifyQuery(happyDownstreamRequestParamsQuery, map[string]string{
+		"redirect_uri": oidc.ManualCodeRedirectURI,
+	}).Encode()).Build(t, happyStateCodec)
+
+	happyDownloadsState := happyUpstreamStateParam().WithAuthorizeRequestParams(shallowCopyAndModifyQuery(happyDownstreamRequestParamsQuery, map[string]string{
+		oidc.DownloadsSessionParamName: "true",
+	}).Encode()).Build(t, happyStateCodec)
+
+	pendingDevice := func() *deviceauthorization.Session {
+		return &deviceauthorization.Session{
+			DeviceCodeSignature: "some-device-code-signature",
+			ClientID:            downstreamClientID,
+			Scopes:              happyDownstreamScopesRequested,
+			CodeVerifier:        deviceCodeVerifier,
+			ExpiresAt:           time.Now().Add(time.Minute),
+		}
+	}
+
 	tests := []struct {
 		name string
 
-		idp        oidctestutil.TestUpstreamOIDCIdentityProvider
-		method     string
-		path       string
-		csrfCookie string
+		idp           oidctestutil.TestUpstreamOIDCIdentityProvider
+		loginApprover *fakeLoginApprover
+		method        string
+		path          string
+		csrfCookie    string
 
 		wantStatus                        int
 		wantBody                          string
+		wantBodyRegexp                    string
+		wantCacheControl                  string
 		wantRedirectLocationRegexp        string
 		wantDownstreamGrantedScopes       []string
 		wantDownstreamIDTokenSubject      string
 		wantDownstreamIDTokenUsername     string
 		wantDownstreamIDTokenGroups       []string
+		wantDownstreamIDTokenUID          string
 		wantDownstreamRequestedScopes     []string
 		wantDownstreamNonce               string
 		wantDownstreamPKCEChallenge       string
 		wantDownstreamPKCEChallengeMethod string
 
 		wantExchangeAndValidateTokensCall *oidctestutil.ExchangeAuthcodeAndValidateTokenArgs
+		wantLoginApprovalChecked          bool
+
+		device             *deviceauthorization.Session
+		wantDeviceApproved bool
+
+		wantDownloadsSession *oidc.DownloadsSession
 	}{
 		{
 			name:                              "GET with good state and cookie and successful upstream token exchange returns 302 to downstream client callback with its state and code",
@@ -154,6 +199,7 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
 			wantDownstreamIDTokenUsername:     upstreamUsername,
 			wantDownstreamIDTokenGroups:       upstreamGroupMembership,
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
 			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
 			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
 			wantDownstreamNonce:               downstreamNonce,
@@ -173,6 +219,7 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
 			wantDownstreamIDTokenUsername:     upstreamIssuer + "?sub=" + upstreamSubject,
 			wantDownstreamIDTokenGroups:       []string{},
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
 			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
 			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
 			wantDownstreamNonce:               downstreamNonce,
@@ -193,6 +240,7 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
 			wantDownstreamIDTokenUsername:     "joe@whitehouse.gov",
 			wantDownstreamIDTokenGroups:       upstreamGroupMembership,
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
 			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
 			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
 			wantDownstreamNonce:               downstreamNonce,
@@ -214,6 +262,7 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
 			wantDownstreamIDTokenUsername:     "joe@whitehouse.gov",
 			wantDownstreamIDTokenGroups:       upstreamGroupMembership,
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
 			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
 			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
 			wantDownstreamNonce:               downstreamNonce,
@@ -236,6 +285,7 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
 			wantDownstreamIDTokenUsername:     "joe",
 			wantDownstreamIDTokenGroups:       upstreamGroupMembership,
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
 			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
 			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
 			wantDownstreamNonce:               downstreamNonce,
@@ -267,6 +317,51 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantBody:                          "Unprocessable Entity: email_verified claim in upstream ID token has false value\n",
 			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
 		},
+		{
+			name: "upstream IDP configures a username template which combines multiple claims",
+			idp: happyUpstream().WithUsernameTemplate("{{.preferred_username}}@{{.tenant}}").
+				WithIDTokenClaim("preferred_username", "joe").
+				WithIDTokenClaim("tenant", "whitehouse.gov").Build(),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusFound,
+			wantRedirectLocationRegexp:        happyDownstreamRedirectLocationRegexp,
+			wantBody:                          "",
+			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
+			wantDownstreamIDTokenUsername:     "joe@whitehouse.gov",
+			wantDownstreamIDTokenGroups:       upstreamGroupMembership,
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+		},
+		{
+			name: "upstream ID token does not contain a claim which is referenced by the username template",
+			idp: happyUpstream().WithUsernameTemplate("{{.preferred_username}}@{{.tenant}}").
+				WithIDTokenClaim("preferred_username", "joe").Build(),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusUnprocessableEntity,
+			wantBody:                          "Unprocessable Entity: could not compute username from upstream ID token: missing claims referenced by the username template: \"tenant\"\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+		},
+		{
+			name: "upstream IDP configures a username template which uses the `email` claim and `email_verified` upstream claim is present with false value",
+			idp: happyUpstream().WithUsernameTemplate("{{.email}}").
+				WithIDTokenClaim("email", "joe@whitehouse.gov").
+				WithIDTokenClaim("email_verified", false).Build(),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusUnprocessableEntity,
+			wantBody:                          "Unprocessable Entity: email_verified claim in upstream ID token is not true\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+		},
 		{
 			name:                              "upstream IDP provides username claim configuration as `sub`, so the downstream token subject should be exactly what they asked for",
 			idp:                               happyUpstream().WithUsernameClaim("sub").Build(),
@@ -279,6 +374,7 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
 			wantDownstreamIDTokenUsername:     upstreamSubject,
 			wantDownstreamIDTokenGroups:       upstreamGroupMembership,
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
 			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
 			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
 			wantDownstreamNonce:               downstreamNonce,
@@ -286,6 +382,56 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
 			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
 		},
+		{
+			name:                              "upstream IDP provides uid claim configuration, so the downstream token uid should be derived from that claim",
+			idp:                               happyUpstream().WithUIDClaim(upstreamUIDClaim).WithIDTokenClaim(upstreamUIDClaim, "some-employee-id").Build(),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusFound,
+			wantRedirectLocationRegexp:        happyDownstreamRedirectLocationRegexp,
+			wantBody:                          "",
+			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
+			wantDownstreamIDTokenUsername:     upstreamUsername,
+			wantDownstreamIDTokenGroups:       upstreamGroupMembership,
+			wantDownstreamIDTokenUID:          "a46aacb907a867e1402ed2ae2144fc584ac8f93b96f223ca00447539fb46ac6c",
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+		},
+		{
+			name:                              "upstream ID token does not contain requested uid claim",
+			idp:                               happyUpstream().WithUIDClaim(upstreamUIDClaim).Build(),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusUnprocessableEntity,
+			wantBody:                          "Unprocessable Entity: no uid claim in upstream ID token\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+		},
+		{
+			name:                              "upstream ID token contains uid claim with weird format",
+			idp:                               happyUpstream().WithUIDClaim(upstreamUIDClaim).WithIDTokenClaim(upstreamUIDClaim, 42).Build(),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusUnprocessableEntity,
+			wantBody:                          "Unprocessable Entity: uid claim in upstream ID token has invalid format\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+		},
+		{
+			name:                              "upstream ID token contains empty uid claim",
+			idp:                               happyUpstream().WithUIDClaim(upstreamUIDClaim).WithIDTokenClaim(upstreamUIDClaim, "").Build(),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusUnprocessableEntity,
+			wantBody:                          "Unprocessable Entity: uid claim in upstream ID token has invalid format\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+		},
 		{
 			name:                              "upstream IDP's configured groups claim in the ID token has a non-array value",
 			idp:                               happyUpstream().WithIDTokenClaim(upstreamGroupsClaim, "notAnArrayGroup1 notAnArrayGroup2").Build(),
@@ -298,6 +444,7 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
 			wantDownstreamIDTokenUsername:     upstreamUsername,
 			wantDownstreamIDTokenGroups:       []string{"notAnArrayGroup1 notAnArrayGroup2"},
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
 			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
 			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
 			wantDownstreamNonce:               downstreamNonce,
@@ -317,6 +464,7 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
 			wantDownstreamIDTokenUsername:     upstreamUsername,
 			wantDownstreamIDTokenGroups:       []string{"group1", "group2"},
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
 			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
 			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
 			wantDownstreamNonce:               downstreamNonce,
@@ -445,6 +593,7 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
 			wantDownstreamRequestedScopes:     []string{"profile", "email"},
 			wantDownstreamIDTokenGroups:       upstreamGroupMembership,
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
@@ -468,6 +617,7 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamRequestedScopes:     []string{"openid", "offline_access"},
 			wantDownstreamGrantedScopes:       []string{"openid", "offline_access"},
 			wantDownstreamIDTokenGroups:       upstreamGroupMembership,
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
@@ -509,6 +659,106 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantBody:   "Forbidden: CSRF value does not match\n",
 		},
 
+		// Login approval
+		{
+			name:                              "login approval is required and the user has been approved",
+			idp:                               happyUpstream().Build(),
+			loginApprover:                     &fakeLoginApprover{decision: loginapproval.Approved},
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusFound,
+			wantRedirectLocationRegexp:        happyDownstreamRedirectLocationRegexp,
+			wantBody:                          "",
+			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
+			wantDownstreamIDTokenUsername:     upstreamUsername,
+			wantDownstreamIDTokenGroups:       upstreamGroupMembership,
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+			wantLoginApprovalChecked:          true,
+		},
+		{
+			name:                              "login approval is required and the user's first login is pending approval",
+			idp:                               happyUpstream().Build(),
+			loginApprover:                     &fakeLoginApprover{decision: loginapproval.Pending},
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusForbidden,
+			wantBody:                          "Forbidden: login is pending approval by an administrator\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+			wantLoginApprovalChecked:          true,
+		},
+		{
+			name:                              "login approval is required and the user has been denied",
+			idp:                               happyUpstream().Build(),
+			loginApprover:                     &fakeLoginApprover{decision: loginapproval.Denied},
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusForbidden,
+			wantBody:                          "Forbidden: login was denied by an administrator\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+			wantLoginApprovalChecked:          true,
+		},
+		{
+			name:                              "login approval is required and checking it fails",
+			idp:                               happyUpstream().Build(),
+			loginApprover:                     &fakeLoginApprover{err: errors.New("some error")},
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusInternalServerError,
+			wantBody:                          "Internal Server Error: error checking login approval\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+			wantLoginApprovalChecked:          true,
+		},
+
+		// Upstream authorization response
+		{
+			name:                              "upstream authorization response has the expected iss parameter",
+			idp:                               happyUpstream().WithRequiredIssuerParameter().Build(),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyState).WithIssuer(upstreamIssuer).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusFound,
+			wantRedirectLocationRegexp:        happyDownstreamRedirectLocationRegexp,
+			wantBody:                          "",
+			wantDownstreamIDTokenSubject:      upstreamIssuer + "?sub=" + upstreamSubject,
+			wantDownstreamIDTokenUsername:     upstreamUsername,
+			wantDownstreamIDTokenGroups:       upstreamGroupMembership,
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+		},
+		{
+			name:       "upstream authorization response has the iss parameter of another issuer",
+			idp:        happyUpstream().Build(),
+			method:     http.MethodGet,
+			path:       newRequestPath().WithState(happyState).WithIssuer("https://other-issuer.com").String(),
+			csrfCookie: happyCSRFCookie,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: authorization response has unexpected iss parameter (expected \"" + upstreamIssuer + "\", got \"https://other-issuer.com\")\n",
+		},
+		{
+			name:       "upstream authorization response is missing the required iss parameter",
+			idp:        happyUpstream().WithRequiredIssuerParameter().Build(),
+			method:     http.MethodGet,
+			path:       newRequestPath().WithState(happyState).String(),
+			csrfCookie: happyCSRFCookie,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: authorization response is missing the iss parameter\n",
+		},
+
 		// Upstream exchange
 		{
 			name:                              "upstream auth code exchange fails",
@@ -544,6 +794,7 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
 			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
 			wantDownstreamIDTokenGroups:       []string{},
+			wantDownstreamIDTokenUID:          happyDownstreamUID,
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
@@ -609,6 +860,117 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantBody:                          "Unprocessable Entity: groups claim in upstream ID token has invalid format\n",
 			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
 		},
+
+		// Manual code entry
+		{
+			name:                              "login with the out-of-band redirect_uri shows the authcode instead of redirecting",
+			idp:                               happyUpstream().Build(),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyManualCodeState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusOK,
+			wantBodyRegexp:                    `^you have been logged in, now paste this code into the pinniped CLI to finish:\n\n[\w-]+\.[\w-]+\n$`,
+			wantCacheControl:                  "no-store",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+		},
+
+		// Downloads page
+		{
+			name:                              "login which was started by the downloads page starts a downloads session instead of issuing an authcode",
+			idp:                               happyUpstream().Build(),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyDownloadsState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusSeeOther,
+			wantBody:                          "<a href=\"" + downstreamIssuer + "/downloads\">See Other</a>.\n\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+			wantDownloadsSession: &oidc.DownloadsSession{
+				Issuer:   downstreamIssuer,
+				Subject:  upstreamIssuer + "?sub=" + upstreamSubject,
+				Username: upstreamUsername,
+				Groups:   upstreamGroupMembership,
+			},
+		},
+		{
+			name:                              "login which was started by the downloads page is still subject to login approval",
+			idp:                               happyUpstream().Build(),
+			loginApprover:                     &fakeLoginApprover{decision: loginapproval.Pending},
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyDownloadsState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusForbidden,
+			wantBodyRegexp:                    `login is pending approval by an administrator`,
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+			wantLoginApprovalChecked:          true,
+		},
+
+		// Device authorization
+		{
+			name:                              "login which was started by the device verification endpoint approves the device instead of redirecting",
+			idp:                               happyUpstream().Build(),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyDeviceState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			device:                            pendingDevice(),
+			wantStatus:                        http.StatusOK,
+			wantBody:                          "you have been logged in and may now close this tab and return to your device",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+			wantDeviceApproved:                true,
+		},
+		{
+			name:                              "device authorization does not exist",
+			idp:                               happyUpstream().Build(),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyDeviceState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusUnprocessableEntity,
+			wantBody:                          "Unprocessable Entity: device authorization has expired or was already used\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+		},
+		{
+			name:       "device authorization has expired",
+			idp:        happyUpstream().Build(),
+			method:     http.MethodGet,
+			path:       newRequestPath().WithState(happyDeviceState).String(),
+			csrfCookie: happyCSRFCookie,
+			device: func() *deviceauthorization.Session {
+				device := pendingDevice()
+				device.ExpiresAt = time.Now().Add(-time.Second)
+				return device
+			}(),
+			wantStatus:                        http.StatusUnprocessableEntity,
+			wantBody:                          "Unprocessable Entity: device authorization has expired or was already used\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+		},
+		{
+			name:       "device authorization was already approved",
+			idp:        happyUpstream().Build(),
+			method:     http.MethodGet,
+			path:       newRequestPath().WithState(happyDeviceState).String(),
+			csrfCookie: happyCSRFCookie,
+			device: func() *deviceauthorization.Session {
+				device := pendingDevice()
+				device.Authcode = "some-other-authcode"
+				return device
+			}(),
+			wantStatus:                        http.StatusUnprocessableEntity,
+			wantBody:                          "Unprocessable Entity: device authorization has expired or was already used\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+			wantDeviceApproved:                true,
+		},
+		{
+			name:   "authorization request for a device has another code challenge",
+			idp:    happyUpstream().Build(),
+			method: http.MethodGet,
+			path: newRequestPath().WithState(happyUpstreamStateParam().WithAuthorizeRequestParams(shallowCopyAndModifyQuery(happyDownstreamRequestParamsQuery, map[string]string{
+				oidc.DeviceUserCodeParamName: deviceUserCode,
+			}).Encode()).Build(t, happyStateCodec)).String(),
+			csrfCookie:                        happyCSRFCookie,
+			device:                            pendingDevice(),
+			wantStatus:                        http.StatusBadRequest,
+			wantBody:                          "Bad Request: device authorization does not match the authorization request\n",
+			wantExchangeAndValidateTokensCall: happyExchangeAndValidateTokensArgs,
+		},
 	}
 	for _, test := range tests {
 		test := test
@@ -627,7 +989,14 @@ func TestCallbackEndpoint(t *testing.T) {
 			oauthHelper := oidc.FositeOauth2Helper(oauthStore, downstreamIssuer, hmacSecretFunc, jwksProviderIsUnused, timeoutsConfiguration)
 
 			idpListGetter := oidctestutil.NewIDPListGetter(&test.idp)
-			subject := NewHandler(idpListGetter, oauthHelper, happyStateCodec, happyCookieCodec, happyUpstreamRedirectURI)
+			var loginApprover loginapproval.Approver
+			if test.loginApprover != nil {
+				loginApprover = test.loginApprover
+			}
+			if test.device != nil {
+				require.NoError(t, oauthStore.CreateDeviceAuthorization(context.Background(), deviceUserCode, test.device))
+			}
+			subject := NewHandler(idpListGetter, loginApprover, oauthHelper, oauthStore, happyStateCodec, happyCookieCodec, happyUpstreamRedirectURI, downstreamIssuer, happyDownloadsCodec)
 			req := httptest.NewRequest(test.method, test.path, nil)
 			if test.csrfCookie != "" {
 				req.Header.Set("Cookie", test.csrfCookie)
@@ -638,6 +1007,9 @@ func TestCallbackEndpoint(t *testing.T) {
 			t.Logf("response body: %q", rsp.Body.String())
 
 			testutil.RequireSecurityHeaders(t, rsp)
+			if test.wantCacheControl != "" {
+				require.Equal(t, test.wantCacheControl, rsp.Header().Get("Cache-Control"))
+			}
 
 			if test.wantExchangeAndValidateTokensCall != nil {
 				require.Equal(t, 1, test.idp.ExchangeAuthcodeAndValidateTokensCallCount())
@@ -647,14 +1019,45 @@ func TestCallbackEndpoint(t *testing.T) {
 				require.Equal(t, 0, test.idp.ExchangeAuthcodeAndValidateTokensCallCount())
 			}
 
+			if test.wantLoginApprovalChecked {
+				require.Equal(t, []string{upstreamIssuer + "?sub=" + upstreamSubject + " " + upstreamUsername}, test.loginApprover.checked)
+			} else if test.loginApprover != nil {
+				require.Empty(t, test.loginApprover.checked)
+			}
+
 			require.Equal(t, test.wantStatus, rsp.Code)
 
-			if test.wantBody != "" {
+			switch {
+			case test.wantBodyRegexp != "":
+				require.Regexp(t, test.wantBodyRegexp, rsp.Body.String())
+			case test.wantBody != "":
 				require.Equal(t, test.wantBody, rsp.Body.String())
-			} else {
+			default:
 				require.Empty(t, rsp.Body.String())
 			}
 
+			if test.wantDownloadsSession != nil {
+				require.Equal(t, downstreamIssuer+"/downloads", rsp.Header().Get("Location"))
+				cookies := rsp.Result().Cookies()
+				require.Len(t, cookies, 1)
+				require.Equal(t, "__Host-pinniped-downloads", cookies[0].Name)
+				require.True(t, cookies[0].HttpOnly)
+				require.True(t, cookies[0].Secure)
+				require.Equal(t, "/", cookies[0].Path)
+				require.Equal(t, 900, cookies[0].MaxAge)
+				var session oidc.DownloadsSession
+				require.NoError(t, happyDownloadsCodec.Decode("downloads", cookies[0].Value, &session))
+				require.Equal(t, test.wantDownloadsSession, &session)
+				// No authcode, PKCE, or ID session was stored.
+				require.Empty(t, client.Actions())
+			}
+
+			if test.device != nil {
+				device, err := oauthStore.GetDeviceAuthorization(context.Background(), deviceUserCode)
+				require.NoError(t, err)
+				require.Equal(t, test.wantDeviceApproved, device.Approved())
+			}
+
 			if test.wantRedirectLocationRegexp != "" { //nolint:nestif // don't mind have several sequential if statements in this test
 				// Assert that Location header matches regular expression.
 				require.Len(t, rsp.Header().Values("Location"), 1)
@@ -686,6 +1089,7 @@ func TestCallbackEndpoint(t *testing.T) {
 					test.wantDownstreamIDTokenSubject,
 					test.wantDownstreamIDTokenUsername,
 					test.wantDownstreamIDTokenGroups,
+					test.wantDownstreamIDTokenUID,
 					test.wantDownstreamRequestedScopes,
 				)
 
@@ -729,8 +1133,19 @@ func includesOpenIDScope(scopes []string) bool {
 	return false
 }
 
+type fakeLoginApprover struct {
+	decision loginapproval.Decision
+	err      error
+	checked  []string
+}
+
+func (f *fakeLoginApprover) Check(_ context.Context, subject, username string) (loginapproval.Decision, error) {
+	f.checked = append(f.checked, subject+" "+username)
+	return f.decision, f.err
+}
+
 type requestPath struct {
-	code, state *string
+	code, state, iss *string
 }
 
 func newRequestPath() *requestPath {
@@ -762,6 +1177,11 @@ func (r *requestPath) WithoutState() *requestPath {
 	return r
 }
 
+func (r *requestPath) WithIssuer(iss string) *requestPath {
+	r.iss = &iss
+	return r
+}
+
 func (r *requestPath) String() string {
 	path := "/downstream-provider-name/callback?"
 	params := url.Values{}
@@ -771,6 +1191,9 @@ func (r *requestPath) String() string {
 	if r.state != nil {
 		params.Add("state", *r.state)
 	}
+	if r.iss != nil {
+		params.Add("iss", *r.iss)
+	}
 	return path + params.Encode()
 }
 
@@ -819,9 +1242,11 @@ func (b *upstreamStateParamBuilder) WithStateVersion(version string) *upstreamSt
 }
 
 type upstreamOIDCIdentityProviderBuilder struct {
-	idToken                    map[string]interface{}
-	usernameClaim, groupsClaim string
-	authcodeExchangeErr        error
+	idToken                              map[string]interface{}
+	usernameClaim, groupsClaim, uidClaim string
+	usernameTemplate                     string
+	authcodeExchangeErr                  error
+	requireIssuerParameter               bool
 }
 
 func happyUpstream() *upstreamOIDCIdentityProviderBuilder {
@@ -848,11 +1273,21 @@ func (u *upstreamOIDCIdentityProviderBuilder) WithoutUsernameClaim() *upstreamOI
 	return u
 }
 
+func (u *upstreamOIDCIdentityProviderBuilder) WithUsernameTemplate(value string) *upstreamOIDCIdentityProviderBuilder {
+	u.usernameTemplate = value
+	return u
+}
+
 func (u *upstreamOIDCIdentityProviderBuilder) WithoutGroupsClaim() *upstreamOIDCIdentityProviderBuilder {
 	u.groupsClaim = ""
 	return u
 }
 
+func (u *upstreamOIDCIdentityProviderBuilder) WithUIDClaim(value string) *upstreamOIDCIdentityProviderBuilder {
+	u.uidClaim = value
+	return u
+}
+
 func (u *upstreamOIDCIdentityProviderBuilder) WithIDTokenClaim(name string, value interface{}) *upstreamOIDCIdentityProviderBuilder {
 	u.idToken[name] = value
 	return u
@@ -868,13 +1303,28 @@ func (u *upstreamOIDCIdentityProviderBuilder) WithoutUpstreamAuthcodeExchangeErr
 	return u
 }
 
+func (u *upstreamOIDCIdentityProviderBuilder) WithRequiredIssuerParameter() *upstreamOIDCIdentityProviderBuilder {
+	u.requireIssuerParameter = true
+	return u
+}
+
 func (u *upstreamOIDCIdentityProviderBuilder) Build() oidctestutil.TestUpstreamOIDCIdentityProvider {
+	var usernameTemplate *usernametemplate.Template
+	if u.usernameTemplate != "" {
+		usernameTemplate, _ = usernametemplate.Parse(u.usernameTemplate)
+	}
 	return oidctestutil.TestUpstreamOIDCIdentityProvider{
-		Name:          happyUpstreamIDPName,
-		ClientID:      "some-client-id",
-		UsernameClaim: u.usernameClaim,
-		GroupsClaim:   u.groupsClaim,
-		Scopes:        []string{"scope1", "scope2"},
+		Name:             happyUpstreamIDPName,
+		ClientID:         "some-client-id",
+		UsernameClaim:    u.usernameClaim,
+		UsernameTemplate: usernameTemplate,
+		GroupsClaim:      u.groupsClaim,
+		UIDClaim:         u.uidClaim,
+		Scopes:           []string{"scope1", "scope2"},
+		ValidateIssuerParameterFunc: func(iss string) error {
+			// Mimic the real validation, which is tested by the upstreamoidc package.
+			return (&upstreamoidc.ProviderConfig{Issuer: upstreamIssuer, RequireIssuerParameter: u.requireIssuerParameter}).ValidateIssuerParameter(iss)
+		},
 		ExchangeAuthcodeAndValidateTokensFunc: func(ctx context.Context, authcode string, pkceCodeVerifier oidcpkce.Code, expectedIDTokenNonce nonce.Nonce) (*oidctypes.Token, error) {
 			if u.authcodeExchangeErr != nil {
 				return nil, u.authcodeExchangeErr
@@ -907,6 +1357,7 @@ func validateAuthcodeStorage(
 	wantDownstreamIDTokenSubject string,
 	wantDownstreamIDTokenUsername string,
 	wantDownstreamIDTokenGroups []string,
+	wantDownstreamIDTokenUID string,
 	wantDownstreamRequestedScopes []string,
 ) (*fosite.Request, *openid.DefaultSession) {
 	t.Helper()
@@ -942,10 +1393,11 @@ func validateAuthcodeStorage(
 	// Now confirm the ID token claims.
 	actualClaims := storedSessionFromAuthcode.Claims
 
-	// Check the user's identity, which are put into the downstream ID token's subject, username and groups claims.
+	// Check the user's identity, which are put into the downstream ID token's subject, username, groups and uid claims.
 	require.Equal(t, wantDownstreamIDTokenSubject, actualClaims.Subject)
 	require.Equal(t, wantDownstreamIDTokenUsername, actualClaims.Extra["username"])
-	require.Len(t, actualClaims.Extra, 2)
+	require.Equal(t, wantDownstreamIDTokenUID, actualClaims.Extra["uid"])
+	require.Len(t, actualClaims.Extra, 3)
 	actualDownstreamIDTokenGroups := actualClaims.Extra["groups"]
 	require.NotNil(t, actualDownstreamIDTokenGroups)
 	require.ElementsMatch(t, wantDownstreamIDTokenGroups, actualDownstreamIDTokenGroups)
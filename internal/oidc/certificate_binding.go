@@ -0,0 +1,78 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+)
+
+const (
+	// ConfirmationClaim is the claim of RFC 7800 which holds the thumbprint of the client certificate to which the
+	// tokens of a session are bound, as described by RFC 8705.
+	ConfirmationClaim = "cnf"
+
+	// confirmationThumbprintMember is the member of the confirmation claim which holds the base64url-encoded SHA-256
+	// hash of the DER encoding of the client certificate.
+	confirmationThumbprintMember = "x5t#S256"
+)
+
+type clientCertificateThumbprintContextKey struct{}
+
+// ClientCertificateThumbprint returns the thumbprint of the TLS client certificate which was presented with the
+// request, or an empty string when there is none. The certificate is not verified, because RFC 8705 binds tokens to
+// self-signed certificates just as well as to certificates from a PKI.
+func ClientCertificateThumbprint(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	hash := sha256.Sum256(r.TLS.PeerCertificates[0].Raw)
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// WithClientCertificateThumbprint returns a copy of ctx which carries the thumbprint, so that the grant handlers
+// of fosite can check it.
+func WithClientCertificateThumbprint(ctx context.Context, thumbprint string) context.Context {
+	return context.WithValue(ctx, clientCertificateThumbprintContextKey{}, thumbprint)
+}
+
+func clientCertificateThumbprintFrom(ctx context.Context) string {
+	thumbprint, _ := ctx.Value(clientCertificateThumbprintContextKey{}).(string)
+	return thumbprint
+}
+
+// BindToClientCertificate binds the tokens of the session to the client certificate with the given thumbprint. It
+// does nothing when the thumbprint is empty, i.e. when the client did not present a certificate. Refreshed tokens
+// inherit the binding, because they are issued from the same session.
+func BindToClientCertificate(session *openid.DefaultSession, thumbprint string) {
+	if thumbprint == "" || session == nil || session.Claims == nil {
+		return
+	}
+	if session.Claims.Extra == nil {
+		session.Claims.Extra = map[string]interface{}{}
+	}
+	session.Claims.Extra[ConfirmationClaim] = map[string]interface{}{confirmationThumbprintMember: thumbprint}
+}
+
+// VerifyClientCertificateBinding returns an error when the tokens of the session are bound to a client certificate
+// other than the one with the given thumbprint.
+func VerifyClientCertificateBinding(session *openid.DefaultSession, thumbprint string) error {
+	if session == nil || session.Claims == nil {
+		return nil
+	}
+	// Claims which were read back from session storage are decoded from JSON as map[string]interface{} as well.
+	confirmation, ok := session.Claims.Extra[ConfirmationClaim].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if bound, _ := confirmation[confirmationThumbprintMember].(string); bound != "" && bound != thumbprint {
+		return fosite.ErrInvalidGrant.WithHint("The token is bound to a client certificate which was not presented with this request.")
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/token/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCertificateThumbprint(t *testing.T) {
+	r := httptest.NewRequest("POST", "/token", nil)
+	require.Empty(t, ClientCertificateThumbprint(r))
+
+	r.TLS = &tls.ConnectionState{}
+	require.Empty(t, ClientCertificateThumbprint(r))
+
+	cert := &x509.Certificate{Raw: []byte("some-der-bytes")}
+	r.TLS.PeerCertificates = []*x509.Certificate{cert}
+	hash := sha256.Sum256(cert.Raw)
+	require.Equal(t, base64.RawURLEncoding.EncodeToString(hash[:]), ClientCertificateThumbprint(r))
+
+	ctx := WithClientCertificateThumbprint(context.Background(), "some-thumbprint")
+	require.Equal(t, "some-thumbprint", clientCertificateThumbprintFrom(ctx))
+	require.Empty(t, clientCertificateThumbprintFrom(context.Background()))
+}
+
+func TestClientCertificateBinding(t *testing.T) {
+	newSession := func() *openid.DefaultSession {
+		return &openid.DefaultSession{Claims: &jwt.IDTokenClaims{Extra: map[string]interface{}{"username": "some-user"}}}
+	}
+
+	// Sessions of clients which did not present a certificate are not bound.
+	unbound := newSession()
+	BindToClientCertificate(unbound, "")
+	require.Equal(t, map[string]interface{}{"username": "some-user"}, unbound.Claims.Extra)
+	require.NoError(t, VerifyClientCertificateBinding(unbound, ""))
+	require.NoError(t, VerifyClientCertificateBinding(unbound, "some-thumbprint"))
+	require.NoError(t, VerifyClientCertificateBinding(nil, ""))
+
+	bound := newSession()
+	BindToClientCertificate(bound, "some-thumbprint")
+	require.Equal(t, map[string]interface{}{"x5t#S256": "some-thumbprint"}, bound.Claims.Extra[ConfirmationClaim])
+	require.NoError(t, VerifyClientCertificateBinding(bound, "some-thumbprint"))
+	for _, thumbprint := range []string{"", "other-thumbprint"} {
+		err := VerifyClientCertificateBinding(bound, thumbprint)
+		require.True(t, errors.Is(err, fosite.ErrInvalidGrant))
+		require.Equal(t, "The token is bound to a client certificate which was not presented with this request.", fosite.ErrorToRFC6749Error(err).HintField)
+	}
+
+	// The binding survives a round trip through session storage.
+	encoded, err := json.Marshal(bound)
+	require.NoError(t, err)
+	decoded := newSession()
+	require.NoError(t, json.Unmarshal(encoded, decoded))
+	require.NoError(t, VerifyClientCertificateBinding(decoded, "some-thumbprint"))
+	require.Error(t, VerifyClientCertificateBinding(decoded, "other-thumbprint"))
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package device provides the handlers for the device authorization grant of RFC 8628, which lets the user log in
+// using a browser on another machine than the one which needs the tokens.
+package device
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"go.pinniped.dev/internal/fositestorage/deviceauthorization"
+	"go.pinniped.dev/internal/httputil/securityheader"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/pkg/oidcclient/pkce"
+)
+
+// AuthorizationResponse is the response of the device authorization endpoint, see RFC 8628 section 3.2.
+type AuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// NewAuthorizationHandler returns the handler for the device authorization endpoint, where a device starts a login
+// and receives the user code which the user enters on the verification page.
+func NewAuthorizationHandler(
+	downstreamIssuer string,
+	storage deviceauthorization.Storage,
+	generatePKCE func() (pkce.Code, error),
+	deviceCodeLifespan time.Duration,
+) http.Handler {
+	return securityheader.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "invalid_request", r.Method+" (try POST)")
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "could not parse the request body")
+			return
+		}
+
+		// The pinniped-cli client is public, so there is nothing to authenticate.
+		client := oidc.PinnipedCLIOIDCClient()
+		if r.PostForm.Get("client_id") != client.GetID() || !client.GetGrantTypes().Has(oidc.DeviceCodeGrantType) {
+			writeError(w, http.StatusUnauthorized, "invalid_client", "unknown client or the client may not use the device authorization grant")
+			return
+		}
+		scopes := strings.Fields(r.PostForm.Get("scope"))
+		if !sets.NewString(client.GetScopes()...).HasAll(scopes...) {
+			writeError(w, http.StatusBadRequest, "invalid_scope", "the client may not request some of these scopes")
+			return
+		}
+
+		userCode, err := oidc.GenerateUserCode()
+		if err != nil {
+			plog.Error("device authorization generate error", err)
+			writeError(w, http.StatusInternalServerError, "server_error", "error generating user code")
+			return
+		}
+		deviceCode, err := oidc.GenerateDeviceCode(userCode)
+		if err != nil {
+			plog.Error("device authorization generate error", err)
+			writeError(w, http.StatusInternalServerError, "server_error", "error generating device code")
+			return
+		}
+		pkceValue, err := generatePKCE()
+		if err != nil {
+			plog.Error("device authorization generate error", err)
+			writeError(w, http.StatusInternalServerError, "server_error", "error generating PKCE param")
+			return
+		}
+
+		if err := storage.CreateDeviceAuthorization(r.Context(), oidc.NormalizeUserCode(userCode), &deviceauthorization.Session{
+			DeviceCodeSignature: oidc.DeviceCodeSignature(deviceCode),
+			ClientID:            client.GetID(),
+			Scopes:              scopes,
+			CodeVerifier:        string(pkceValue),
+			ExpiresAt:           time.Now().Add(deviceCodeLifespan),
+			UpstreamIDPName:     r.PostForm.Get(oidc.UpstreamIDPNameParamName),
+			UpstreamIDPType:     r.PostForm.Get(oidc.UpstreamIDPTypeParamName),
+		}); err != nil {
+			plog.Error("device authorization storage error", err)
+			writeError(w, http.StatusInternalServerError, "server_error", "error storing device authorization")
+			return
+		}
+
+		verificationURI := downstreamIssuer + oidc.DeviceVerificationEndpointPath
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&AuthorizationResponse{
+			DeviceCode:              deviceCode,
+			UserCode:                userCode,
+			VerificationURI:         verificationURI,
+			VerificationURIComplete: verificationURI + "?user_code=" + userCode,
+			ExpiresIn:               int64(deviceCodeLifespan.Seconds()),
+			Interval:                oidc.DeviceCodePollingInterval,
+		})
+	}))
+}
+
+// writeError writes an OAuth 2.0 error response, see RFC 6749 section 5.2.
+func writeError(w http.ResponseWriter, status int, code string, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": description})
+}
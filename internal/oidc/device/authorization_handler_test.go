@@ -0,0 +1,135 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/pkg/oidcclient/pkce"
+)
+
+const downstreamIssuer = "https://my-downstream-issuer.com/path"
+
+func TestAuthorizationHandler(t *testing.T) {
+	happyPKCE := func() (pkce.Code, error) { return "some-code-verifier", nil }
+
+	tests := []struct {
+		name         string
+		method       string
+		form         url.Values
+		generatePKCE func() (pkce.Code, error)
+		wantStatus   int
+		wantError    string
+
+		wantUpstreamIDPName string
+		wantUpstreamIDPType string
+	}{
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantError:  "invalid_request",
+		},
+		{
+			name:       "unknown client",
+			method:     http.MethodPost,
+			form:       url.Values{"client_id": {"some-other-client"}, "scope": {"openid"}},
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "invalid_client",
+		},
+		{
+			name:       "scope which the client may not request",
+			method:     http.MethodPost,
+			form:       url.Values{"client_id": {"pinniped-cli"}, "scope": {"openid some-other-scope"}},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_scope",
+		},
+		{
+			name:         "PKCE generation fails",
+			method:       http.MethodPost,
+			form:         url.Values{"client_id": {"pinniped-cli"}, "scope": {"openid"}},
+			generatePKCE: func() (pkce.Code, error) { return "", errors.New("some error") },
+			wantStatus:   http.StatusInternalServerError,
+			wantError:    "server_error",
+		},
+		{
+			name:       "happy path",
+			method:     http.MethodPost,
+			form:       url.Values{"client_id": {"pinniped-cli"}, "scope": {"openid offline_access pinniped:request-audience"}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "happy path with an upstream provider",
+			method: http.MethodPost,
+			form: url.Values{
+				"client_id":         {"pinniped-cli"},
+				"scope":             {"openid offline_access pinniped:request-audience"},
+				"pinniped_idp_name": {"some-idp"},
+				"pinniped_idp_type": {"oidc"},
+			},
+			wantStatus:          http.StatusOK,
+			wantUpstreamIDPName: "some-idp",
+			wantUpstreamIDPType: "oidc",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			storage := oidc.NewMemoryStorage()
+			generatePKCE := test.generatePKCE
+			if generatePKCE == nil {
+				generatePKCE = happyPKCE
+			}
+			subject := NewAuthorizationHandler(downstreamIssuer, storage, generatePKCE, 15*time.Minute)
+
+			req := httptest.NewRequest(test.method, "/oauth2/device_authorization", strings.NewReader(test.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rsp := httptest.NewRecorder()
+			subject.ServeHTTP(rsp, req)
+			t.Logf("response body: %q", rsp.Body.String())
+
+			testutil.RequireSecurityHeaders(t, rsp)
+			require.Equal(t, test.wantStatus, rsp.Code)
+			testutil.RequireEqualContentType(t, rsp.Header().Get("Content-Type"), "application/json")
+
+			if test.wantError != "" {
+				var parsed map[string]string
+				require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &parsed))
+				require.Equal(t, test.wantError, parsed["error"])
+				return
+			}
+
+			var parsed AuthorizationResponse
+			require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &parsed))
+			require.Regexp(t, `^[BCDFGHJKLMNPQRSTVWXZ]{4}-[BCDFGHJKLMNPQRSTVWXZ]{4}$`, parsed.UserCode)
+			require.Equal(t, downstreamIssuer+"/oauth2/device", parsed.VerificationURI)
+			require.Equal(t, downstreamIssuer+"/oauth2/device?user_code="+parsed.UserCode, parsed.VerificationURIComplete)
+			require.Equal(t, int64(900), parsed.ExpiresIn)
+			require.Equal(t, int64(5), parsed.Interval)
+
+			device, err := storage.GetDeviceAuthorization(context.Background(), oidc.NormalizeUserCode(parsed.UserCode))
+			require.NoError(t, err)
+			require.Equal(t, oidc.DeviceCodeSignature(parsed.DeviceCode), device.DeviceCodeSignature)
+			require.Equal(t, "pinniped-cli", device.ClientID)
+			require.Equal(t, []string{"openid", "offline_access", "pinniped:request-audience"}, device.Scopes)
+			require.Equal(t, "some-code-verifier", device.CodeVerifier)
+			require.WithinDuration(t, time.Now().Add(15*time.Minute), device.ExpiresAt, time.Minute)
+			require.False(t, device.Approved())
+			require.Equal(t, test.wantUpstreamIDPName, device.UpstreamIDPName)
+			require.Equal(t, test.wantUpstreamIDPType, device.UpstreamIDPType)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// userCodeFreeFailures is how many invalid user codes can be entered from one source IP before it is throttled.
+	// Users only ever type a few codes, but several of them may share a source IP, e.g. behind a NAT gateway.
+	userCodeFreeFailures = 10
+
+	// throttleBaseDelay is how long the first lockout lasts. Each further failure doubles it, up to throttleMaxDelay.
+	throttleBaseDelay = time.Second
+	throttleMaxDelay  = 5 * time.Minute
+
+	// throttleForgetAfter is how long a source has to be quiet until its failures are forgotten.
+	throttleForgetAfter = 15 * time.Minute
+)
+
+// userCodeThrottler counts the invalid user codes which were entered on the verification page from each source IP, and
+// once a source has entered too many it refuses further codes from it with an exponentially growing backoff, as
+// RFC 8628 section 5.1 recommends. This makes guessing the user code of someone else's pending device authorization
+// impractical. The counts are kept in the memory of each Supervisor pod and start over when the FederationDomains
+// change, so an attacker who reaches several pods gets a few more guesses.
+type userCodeThrottler struct {
+	clock clock.Clock
+
+	mu        sync.Mutex
+	failures  map[string]*failureRecord
+	lastSweep time.Time
+}
+
+type failureRecord struct {
+	count        int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+func newUserCodeThrottler(clock clock.Clock) *userCodeThrottler {
+	return &userCodeThrottler{clock: clock, failures: map[string]*failureRecord{}, lastSweep: clock.Now()}
+}
+
+// allow returns false when the source is currently locked out. Requests without a source are never throttled.
+func (t *userCodeThrottler) allow(source string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	record := t.failures[source]
+	return source == "" || record == nil || !t.clock.Now().Before(record.blockedUntil)
+}
+
+// recordFailure counts an invalid user code from the source, and locks it out when it has entered too many.
+func (t *userCodeThrottler) recordFailure(source string) {
+	if source == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	t.maybeSweep(now)
+
+	record := t.failures[source]
+	if record == nil || now.Sub(record.lastFailure) >= throttleForgetAfter {
+		record = &failureRecord{}
+		t.failures[source] = record
+	}
+	record.count++
+	record.lastFailure = now
+	if record.count <= userCodeFreeFailures {
+		return
+	}
+
+	delay := throttleMaxDelay
+	if shift := record.count - userCodeFreeFailures - 1; shift < 16 {
+		if d := throttleBaseDelay << shift; d < throttleMaxDelay {
+			delay = d
+		}
+	}
+	record.blockedUntil = now.Add(delay)
+	plog.Warning("locking out device user code entry after too many invalid codes",
+		"source", source,
+		"failures", record.count,
+		"lockout", delay.String(),
+	)
+}
+
+// maybeSweep forgets the sources which have been quiet for a while, so that memory usage is bounded by the rate of
+// invalid user codes.
+func (t *userCodeThrottler) maybeSweep(now time.Time) {
+	if now.Sub(t.lastSweep) < throttleForgetAfter {
+		return
+	}
+	for source, record := range t.failures {
+		if now.Sub(record.lastFailure) >= throttleForgetAfter && !now.Before(record.blockedUntil) {
+			delete(t.failures, source)
+		}
+	}
+	t.lastSweep = now
+}
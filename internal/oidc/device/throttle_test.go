@@ -0,0 +1,102 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"go.pinniped.dev/internal/fositestorage/deviceauthorization"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/csrftoken"
+	"go.pinniped.dev/pkg/oidcclient/state"
+)
+
+func TestThrottledUserCodeEntry(t *testing.T) {
+	cookieCodec := securecookie.New([]byte("fake-hash-secret"), []byte("0123456789ABCDEF"))
+	cookieCodec.SetSerializer(securecookie.JSONEncoder{})
+	encodedCSRF, err := cookieCodec.Encode("csrf", "test-csrf")
+	require.NoError(t, err)
+
+	storage := oidc.NewMemoryStorage()
+	require.NoError(t, storage.CreateDeviceAuthorization(context.Background(), "BCDFGHJK", &deviceauthorization.Session{
+		ClientID:     "pinniped-cli",
+		Scopes:       []string{"openid"},
+		CodeVerifier: "some-code-verifier",
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}))
+	fakeClock := clock.NewFakeClock(time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC))
+	subject := newVerificationHandler(
+		downstreamIssuer,
+		storage,
+		func() (csrftoken.CSRFToken, error) { return "generated-csrf", nil },
+		func() (state.State, error) { return "generated-state-value", nil },
+		cookieCodec,
+		newUserCodeThrottler(fakeClock),
+	)
+
+	enterCode := func(source, userCode string) int {
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/device", strings.NewReader(url.Values{
+			"csrf":      []string{"test-csrf"},
+			"user_code": []string{userCode},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Cookie", "__Host-pinniped-csrf="+encodedCSRF)
+		req.RemoteAddr = source + ":12345"
+		rsp := httptest.NewRecorder()
+		subject.ServeHTTP(rsp, req)
+		if rsp.Code == http.StatusTooManyRequests {
+			require.Contains(t, rsp.Body.String(), "Too many invalid codes were entered.")
+		}
+		return rsp.Code
+	}
+
+	// A source can enter a few invalid codes before it is locked out, even when it enters a valid code.
+	for i := 0; i < userCodeFreeFailures+1; i++ {
+		require.Equal(t, http.StatusBadRequest, enterCode("192.0.2.1", "XXXX-XXXX"))
+	}
+	require.Equal(t, http.StatusTooManyRequests, enterCode("192.0.2.1", "BCDF-GHJK"))
+
+	// Other sources are not affected.
+	require.Equal(t, http.StatusSeeOther, enterCode("198.51.100.1", "BCDF-GHJK"))
+
+	// The lockout doubles with each further invalid code.
+	fakeClock.Step(time.Second)
+	require.Equal(t, http.StatusBadRequest, enterCode("192.0.2.1", "XXXX-XXXX"))
+	fakeClock.Step(time.Second)
+	require.Equal(t, http.StatusTooManyRequests, enterCode("192.0.2.1", "BCDF-GHJK"))
+	fakeClock.Step(time.Second)
+	require.Equal(t, http.StatusSeeOther, enterCode("192.0.2.1", "BCDF-GHJK"))
+
+	// The failures are forgotten after the source has been quiet for a while.
+	fakeClock.Step(throttleForgetAfter)
+	for i := 0; i < userCodeFreeFailures; i++ {
+		require.Equal(t, http.StatusBadRequest, enterCode("192.0.2.1", "XXXX-XXXX"))
+	}
+	require.Equal(t, http.StatusSeeOther, enterCode("192.0.2.1", "BCDF-GHJK"))
+}
+
+func TestUserCodeThrottlerSweep(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC))
+	subject := newUserCodeThrottler(fakeClock)
+
+	subject.recordFailure("192.0.2.1")
+	subject.recordFailure("")
+	require.Len(t, subject.failures, 1)
+	require.True(t, subject.allow(""))
+
+	fakeClock.Step(throttleForgetAfter)
+	subject.recordFailure("198.51.100.1")
+	require.Len(t, subject.failures, 1)
+	require.Contains(t, subject.failures, "198.51.100.1")
+}
@@ -0,0 +1,207 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device
+
+import (
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/ory/fosite"
+	"golang.org/x/oauth2"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"go.pinniped.dev/internal/fositestorage/deviceauthorization"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/httputil/securityheader"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/audit"
+	"go.pinniped.dev/internal/oidc/csrftoken"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/timeformat"
+	"go.pinniped.dev/pkg/oidcclient/state"
+)
+
+const csrfFormFieldName = "csrf"
+
+//nolint: gochecknoglobals
+var verificationPage = template.Must(template.New("verification").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Pinniped device login</title></head>
+<body>
+<h1>Log in on another device</h1>
+{{if .Error}}<p><strong>{{.Error}}</strong></p>
+{{end}}<p>Enter the code which is shown on your device. Only continue if you started this login yourself, for example by running "pinniped login oidc".</p>
+<form method="POST">
+<input type="hidden" name="csrf" value="{{.CSRF}}">
+<input type="hidden" name="tz" value="{{.TimeZone}}">
+<input type="text" name="user_code" value="{{.UserCode}}" autocomplete="off" autofocus required>
+<input type="submit" value="Continue">
+</form>
+</body>
+</html>
+`))
+
+type verificationPageData struct {
+	UserCode string
+	CSRF     string
+	TimeZone string
+	Error    string
+}
+
+// NewVerificationHandler returns the handler for the device verification page. When the user enters the user code
+// of a pending device authorization, they are sent to the authorization endpoint to log in as usual, with a request
+// which makes the callback endpoint approve the device instead of issuing an authcode to a redirect URI. Times are shown
+// in the language of the browser and in the time zone from the optional tz query parameter, see timeformat.ForRequest.
+// Sources which enter too many invalid user codes are throttled, see userCodeThrottler.
+func NewVerificationHandler(
+	downstreamIssuer string,
+	storage deviceauthorization.Storage,
+	generateCSRF func() (csrftoken.CSRFToken, error),
+	generateState func() (state.State, error),
+	cookieCodec oidc.Codec,
+) http.Handler {
+	return newVerificationHandler(downstreamIssuer, storage, generateCSRF, generateState, cookieCodec, newUserCodeThrottler(clock.RealClock{}))
+}
+
+func newVerificationHandler(
+	downstreamIssuer string,
+	storage deviceauthorization.Storage,
+	generateCSRF func() (csrftoken.CSRFToken, error),
+	generateState func() (state.State, error),
+	cookieCodec oidc.Codec,
+	throttler *userCodeThrottler,
+) http.Handler {
+	return securityheader.Wrap(httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		switch r.Method {
+		case http.MethodGet:
+			csrfValue := readCSRFCookie(r, cookieCodec)
+			if csrfValue == "" {
+				var err error
+				if csrfValue, err = generateCSRF(); err != nil {
+					plog.Error("device verification generate error", err)
+					return httperr.Wrap(http.StatusInternalServerError, "error generating CSRF token", err)
+				}
+				if err := addCSRFSetCookieHeader(w, csrfValue, cookieCodec); err != nil {
+					plog.Error("error setting CSRF cookie", err)
+					return err
+				}
+			}
+			return renderVerificationPage(w, http.StatusOK, verificationPageData{
+				UserCode: r.URL.Query().Get("user_code"),
+				CSRF:     string(csrfValue),
+				TimeZone: r.URL.Query().Get(timeformat.TimeZoneParamName),
+			})
+
+		case http.MethodPost:
+			// The CSRF check prevents other sites from making a user approve a device which an attacker controls.
+			csrfValue := readCSRFCookie(r, cookieCodec)
+			if csrfValue == "" || subtle.ConstantTimeCompare([]byte(csrfValue), []byte(r.PostFormValue(csrfFormFieldName))) != 1 {
+				plog.Info("device verification CSRF value does not match")
+				return httperr.New(http.StatusForbidden, "CSRF value does not match")
+			}
+
+			source := audit.SourceIP(r)
+			if !throttler.allow(source) {
+				plog.Info("device user code entry throttled", "source", source)
+				return renderVerificationPage(w, http.StatusTooManyRequests, verificationPageData{
+					UserCode: r.PostFormValue("user_code"),
+					CSRF:     string(csrfValue),
+					TimeZone: r.PostFormValue(timeformat.TimeZoneParamName),
+					Error:    "Too many invalid codes were entered. Please wait a few minutes and try again.",
+				})
+			}
+
+			userCode := oidc.NormalizeUserCode(r.PostFormValue("user_code"))
+			device, err := storage.GetDeviceAuthorization(r.Context(), userCode)
+			if errors.Is(err, fosite.ErrNotFound) || (err == nil && device.Approved()) {
+				throttler.recordFailure(source)
+				return renderVerificationPage(w, http.StatusBadRequest, verificationPageData{
+					UserCode: r.PostFormValue("user_code"),
+					CSRF:     string(csrfValue),
+					TimeZone: r.PostFormValue(timeformat.TimeZoneParamName),
+					Error:    "This code is invalid or has expired. Please check the code, or start the login on your device again.",
+				})
+			}
+			if now := time.Now(); err == nil && now.After(device.ExpiresAt) {
+				return renderVerificationPage(w, http.StatusBadRequest, verificationPageData{
+					UserCode: r.PostFormValue("user_code"),
+					CSRF:     string(csrfValue),
+					TimeZone: r.PostFormValue(timeformat.TimeZoneParamName),
+					Error:    "This code expired " + timeformat.ForRequest(r).Expiry(device.ExpiresAt, now) + ". Please start the login on your device again.",
+				})
+			}
+			if err != nil {
+				plog.Error("error reading device authorization", err)
+				return httperr.New(http.StatusInternalServerError, "error reading device authorization")
+			}
+
+			stateValue, err := generateState()
+			if err != nil {
+				plog.Error("device verification generate error", err)
+				return httperr.Wrap(http.StatusInternalServerError, "error generating state param", err)
+			}
+			authorizeConfig := oauth2.Config{
+				ClientID:    device.ClientID,
+				Endpoint:    oauth2.Endpoint{AuthURL: downstreamIssuer + oidc.AuthorizationEndpointPath},
+				RedirectURL: oidc.DeviceAuthorizationRedirectURI,
+				Scopes:      device.Scopes,
+			}
+			authorizeParams := []oauth2.AuthCodeOption{
+				oauth2.SetAuthURLParam("code_challenge", oidc.PKCEChallenge(device.CodeVerifier)),
+				oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+				oauth2.SetAuthURLParam(oidc.DeviceUserCodeParamName, userCode),
+			}
+			if device.UpstreamIDPName != "" {
+				authorizeParams = append(authorizeParams, oauth2.SetAuthURLParam(oidc.UpstreamIDPNameParamName, device.UpstreamIDPName))
+			}
+			if device.UpstreamIDPType != "" {
+				authorizeParams = append(authorizeParams, oauth2.SetAuthURLParam(oidc.UpstreamIDPTypeParamName, device.UpstreamIDPType))
+			}
+			http.Redirect(w, r, authorizeConfig.AuthCodeURL(stateValue.String(), authorizeParams...), http.StatusSeeOther)
+			return nil
+
+		default:
+			return httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET or POST)", r.Method)
+		}
+	}))
+}
+
+func renderVerificationPage(w http.ResponseWriter, status int, data verificationPageData) error {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	return verificationPage.Execute(w, data)
+}
+
+func readCSRFCookie(r *http.Request, codec oidc.Decoder) csrftoken.CSRFToken {
+	receivedCSRFCookie, err := r.Cookie(oidc.CSRFCookieName)
+	if err != nil {
+		return ""
+	}
+
+	var csrfFromCookie csrftoken.CSRFToken
+	if err := codec.Decode(oidc.CSRFCookieEncodingName, receivedCSRFCookie.Value, &csrfFromCookie); err != nil {
+		return ""
+	}
+	return csrfFromCookie
+}
+
+func addCSRFSetCookieHeader(w http.ResponseWriter, csrfValue csrftoken.CSRFToken, codec oidc.Encoder) error {
+	encodedCSRFValue, err := codec.Encode(oidc.CSRFCookieEncodingName, csrfValue)
+	if err != nil {
+		return httperr.Wrap(http.StatusInternalServerError, "error encoding CSRF cookie", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidc.CSRFCookieName,
+		Value:    encodedCSRFValue,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   true,
+		Path:     "/",
+	})
+	return nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/fositestorage/deviceauthorization"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/csrftoken"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/pkg/oidcclient/state"
+)
+
+func TestVerificationHandler(t *testing.T) {
+	cookieCodec := securecookie.New([]byte("fake-hash-secret"), []byte("0123456789ABCDEF"))
+	cookieCodec.SetSerializer(securecookie.JSONEncoder{})
+	encodedCSRF, err := cookieCodec.Encode("csrf", "test-csrf")
+	require.NoError(t, err)
+	happyCSRFCookie := "__Host-pinniped-csrf=" + encodedCSRF
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		form       url.Values
+		csrfCookie string
+		device     *deviceauthorization.Session
+
+		wantStatus        int
+		wantBodyContains  []string
+		wantNewCSRFCookie bool
+		wantLocation      string
+	}{
+		{
+			name:              "GET without a CSRF cookie shows the form and sets a new cookie",
+			method:            http.MethodGet,
+			path:              "/oauth2/device?user_code=BCDF-GHJK",
+			wantStatus:        http.StatusOK,
+			wantBodyContains:  []string{`name="user_code" value="BCDF-GHJK"`, `name="csrf" value="generated-csrf"`, `name="tz" value=""`},
+			wantNewCSRFCookie: true,
+		},
+		{
+			name:             "GET with a CSRF cookie shows the form using the same CSRF value",
+			method:           http.MethodGet,
+			path:             "/oauth2/device?tz=Asia/Tokyo",
+			csrfCookie:       happyCSRFCookie,
+			wantStatus:       http.StatusOK,
+			wantBodyContains: []string{`name="user_code" value=""`, `name="csrf" value="test-csrf"`, `name="tz" value="Asia/Tokyo"`},
+		},
+		{
+			name:             "POST without a CSRF cookie",
+			method:           http.MethodPost,
+			path:             "/oauth2/device",
+			form:             url.Values{"user_code": {"BCDF-GHJK"}, "csrf": {"test-csrf"}},
+			wantStatus:       http.StatusForbidden,
+			wantBodyContains: []string{"Forbidden: CSRF value does not match"},
+		},
+		{
+			name:             "POST with a CSRF value which does not match the cookie",
+			method:           http.MethodPost,
+			path:             "/oauth2/device",
+			form:             url.Values{"user_code": {"BCDF-GHJK"}, "csrf": {"other-csrf"}},
+			csrfCookie:       happyCSRFCookie,
+			wantStatus:       http.StatusForbidden,
+			wantBodyContains: []string{"Forbidden: CSRF value does not match"},
+		},
+		{
+			name:             "POST with an unknown user code shows the form again",
+			method:           http.MethodPost,
+			path:             "/oauth2/device",
+			form:             url.Values{"user_code": {"BCDF-GHJK"}, "csrf": {"test-csrf"}},
+			csrfCookie:       happyCSRFCookie,
+			wantStatus:       http.StatusBadRequest,
+			wantBodyContains: []string{"This code is invalid or has expired.", `name="user_code" value="BCDF-GHJK"`},
+		},
+		{
+			name:       "POST with the user code of an approved device shows the form again",
+			method:     http.MethodPost,
+			path:       "/oauth2/device",
+			form:       url.Values{"user_code": {"BCDF-GHJK"}, "csrf": {"test-csrf"}},
+			csrfCookie: happyCSRFCookie,
+			device: &deviceauthorization.Session{
+				ClientID:     "pinniped-cli",
+				CodeVerifier: "some-code-verifier",
+				ExpiresAt:    time.Now().Add(time.Minute),
+				Authcode:     "some-authcode",
+			},
+			wantStatus:       http.StatusBadRequest,
+			wantBodyContains: []string{"This code is invalid or has expired."},
+		},
+		{
+			name:       "POST with the user code of an expired device shows when it expired in the time zone and language of the user",
+			method:     http.MethodPost,
+			path:       "/oauth2/device",
+			form:       url.Values{"user_code": {"BCDF-GHJK"}, "csrf": {"test-csrf"}, "tz": {"Europe/Berlin"}},
+			csrfCookie: happyCSRFCookie,
+			device: &deviceauthorization.Session{
+				ClientID:     "pinniped-cli",
+				CodeVerifier: "some-code-verifier",
+				ExpiresAt:    time.Date(2021, 3, 4, 13, 6, 7, 0, time.UTC),
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBodyContains: []string{
+				"This code expired 04.03.2021 14:06:07 CET (",
+				", 2021-03-04T13:06:07Z). Please start the login on your device again.",
+				`name="tz" value="Europe/Berlin"`,
+			},
+		},
+		{
+			name:       "POST with the user code of a pending device starts the login",
+			method:     http.MethodPost,
+			path:       "/oauth2/device",
+			form:       url.Values{"user_code": {"bcdf ghjk"}, "csrf": {"test-csrf"}},
+			csrfCookie: happyCSRFCookie,
+			device: &deviceauthorization.Session{
+				ClientID:     "pinniped-cli",
+				Scopes:       []string{"openid", "offline_access"},
+				CodeVerifier: "some-code-verifier",
+				ExpiresAt:    time.Now().Add(time.Minute),
+			},
+			wantStatus: http.StatusSeeOther,
+			wantLocation: downstreamIssuer + "/oauth2/authorize?" + url.Values{
+				"client_id":                 {"pinniped-cli"},
+				"code_challenge":            {oidc.PKCEChallenge("some-code-verifier")},
+				"code_challenge_method":     {"S256"},
+				"pinniped_device_user_code": {"BCDFGHJK"},
+				"redirect_uri":              {"http://127.0.0.1/callback"},
+				"response_type":             {"code"},
+				"scope":                     {"openid offline_access"},
+				"state":                     {"generated-state-value"},
+			}.Encode(),
+		},
+		{
+			name:       "POST with the user code of a pending device which chose an upstream provider",
+			method:     http.MethodPost,
+			path:       "/oauth2/device",
+			form:       url.Values{"user_code": {"BCDF-GHJK"}, "csrf": {"test-csrf"}},
+			csrfCookie: happyCSRFCookie,
+			device: &deviceauthorization.Session{
+				ClientID:        "pinniped-cli",
+				Scopes:          []string{"openid"},
+				CodeVerifier:    "some-code-verifier",
+				ExpiresAt:       time.Now().Add(time.Minute),
+				UpstreamIDPName: "some-idp",
+				UpstreamIDPType: "oidc",
+			},
+			wantStatus: http.StatusSeeOther,
+			wantLocation: downstreamIssuer + "/oauth2/authorize?" + url.Values{
+				"client_id":                 {"pinniped-cli"},
+				"code_challenge":            {oidc.PKCEChallenge("some-code-verifier")},
+				"code_challenge_method":     {"S256"},
+				"pinniped_device_user_code": {"BCDFGHJK"},
+				"pinniped_idp_name":         {"some-idp"},
+				"pinniped_idp_type":         {"oidc"},
+				"redirect_uri":              {"http://127.0.0.1/callback"},
+				"response_type":             {"code"},
+				"scope":                     {"openid"},
+				"state":                     {"generated-state-value"},
+			}.Encode(),
+		},
+		{
+			name:             "wrong method",
+			method:           http.MethodPut,
+			path:             "/oauth2/device",
+			wantStatus:       http.StatusMethodNotAllowed,
+			wantBodyContains: []string{"Method Not Allowed: PUT (try GET or POST)"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			storage := oidc.NewMemoryStorage()
+			if test.device != nil {
+				require.NoError(t, storage.CreateDeviceAuthorization(context.Background(), "BCDFGHJK", test.device))
+			}
+			subject := NewVerificationHandler(
+				downstreamIssuer,
+				storage,
+				func() (csrftoken.CSRFToken, error) { return "generated-csrf", nil },
+				func() (state.State, error) { return "generated-state-value", nil },
+				cookieCodec,
+			)
+
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Accept-Language", "de-DE,de;q=0.9")
+			if test.csrfCookie != "" {
+				req.Header.Set("Cookie", test.csrfCookie)
+			}
+			rsp := httptest.NewRecorder()
+			subject.ServeHTTP(rsp, req)
+			t.Logf("response body: %q", rsp.Body.String())
+
+			testutil.RequireSecurityHeaders(t, rsp)
+			require.Equal(t, test.wantStatus, rsp.Code)
+			for _, want := range test.wantBodyContains {
+				require.Contains(t, rsp.Body.String(), want)
+			}
+			require.Equal(t, test.wantLocation, rsp.Header().Get("Location"))
+
+			if test.wantNewCSRFCookie {
+				cookies := rsp.Result().Cookies()
+				require.Len(t, cookies, 1)
+				require.Equal(t, "__Host-pinniped-csrf", cookies[0].Name)
+				var csrfFromCookie csrftoken.CSRFToken
+				require.NoError(t, cookieCodec.Decode("csrf", cookies[0].Value, &csrfFromCookie))
+				require.Equal(t, csrftoken.CSRFToken("generated-csrf"), csrfFromCookie)
+			} else {
+				require.Empty(t, rsp.Header().Values("Set-Cookie"))
+			}
+
+			// The verification page never approves a device by itself.
+			if test.device != nil {
+				device, err := storage.GetDeviceAuthorization(context.Background(), "BCDFGHJK")
+				require.NoError(t, err)
+				require.Equal(t, test.device.Approved(), device.Approved())
+			}
+		})
+	}
+}
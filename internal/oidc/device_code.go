@@ -0,0 +1,189 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/pkg/errors"
+
+	"go.pinniped.dev/internal/fositestorage/deviceauthorization"
+)
+
+const (
+	// DeviceCodeGrantType is the grant type of the token requests which redeem a device code, see RFC 8628.
+	DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// DeviceUserCodeParamName is added to the downstream authorization request which the device verification
+	// endpoint starts on behalf of the device, so the callback endpoint approves that device instead of redirecting.
+	DeviceUserCodeParamName = "pinniped_device_user_code"
+
+	// DeviceAuthorizationRedirectURI is the redirect_uri of the authorization codes which are issued to approved
+	// devices. Nothing listens on it, since those codes are redeemed by the token endpoint on behalf of the device.
+	DeviceAuthorizationRedirectURI = "http://127.0.0.1/callback"
+
+	// DeviceCodePollingInterval is the minimum number of seconds which devices should wait between token requests.
+	DeviceCodePollingInterval = 5
+
+	// The user code alphabet has no vowels (to avoid spelling words) and no easily confused letters, as suggested
+	// by RFC 8628 section 6.1. Eight of these letters give about 34 bits of entropy.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+//nolint: gochecknoglobals
+var (
+	errAuthorizationPending = &fosite.RFC6749Error{
+		ErrorField:       "authorization_pending",
+		DescriptionField: "The authorization request is still pending as the end user hasn't yet completed the user-interaction steps.",
+		CodeField:        http.StatusBadRequest,
+	}
+	errExpiredToken = &fosite.RFC6749Error{
+		ErrorField:       "expired_token",
+		DescriptionField: "The device_code has expired, and the device authorization session has concluded.",
+		CodeField:        http.StatusBadRequest,
+	}
+)
+
+// GenerateUserCode returns a new random user code in the format which is shown to the user, e.g. "BCDF-GHJK".
+func GenerateUserCode() (string, error) { return generateUserCode(rand.Reader) }
+
+func generateUserCode(rand io.Reader) (string, error) {
+	var buf [userCodeLength]byte
+	if _, err := io.ReadFull(rand, buf[:]); err != nil {
+		return "", fmt.Errorf("could not generate user code: %w", err)
+	}
+	code := make([]byte, 0, userCodeLength+1)
+	for i, b := range buf {
+		if i == userCodeLength/2 {
+			code = append(code, '-')
+		}
+		// 256 is not a multiple of the alphabet length, but the resulting bias is negligible here.
+		code = append(code, userCodeAlphabet[int(b)%len(userCodeAlphabet)])
+	}
+	return string(code), nil
+}
+
+// NormalizeUserCode returns the storage key of a user code as typed by a user, who might have used lower case
+// or left out the dash.
+func NormalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		if !strings.ContainsRune(userCodeAlphabet, r) {
+			return -1
+		}
+		return r
+	}, userCode)
+}
+
+// GenerateDeviceCode returns a new random device code for the given user code. The device code starts with the
+// normalized user code, so the device authorization can be found again when the device polls the token endpoint.
+func GenerateDeviceCode(userCode string) (string, error) {
+	var buf [32]byte
+	if _, err := io.ReadFull(rand.Reader, buf[:]); err != nil {
+		return "", fmt.Errorf("could not generate device code: %w", err)
+	}
+	return NormalizeUserCode(userCode) + "." + base64.RawURLEncoding.EncodeToString(buf[:]), nil
+}
+
+// DeviceCodeSignature returns the value which is stored instead of the device code itself.
+func DeviceCodeSignature(deviceCode string) string {
+	sum := sha256.Sum256([]byte(deviceCode))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// PKCEChallenge returns the S256 code challenge for the given code verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// DeviceCodeFactory is a compose.Factory for the device code grant of RFC 8628. The storage must implement
+// deviceauthorization.Storage, otherwise the grant is not supported.
+func DeviceCodeFactory(config *compose.Config, storage interface{}, strategy interface{}) interface{} {
+	deviceStorage, _ := storage.(deviceauthorization.Storage)
+	return &DeviceCodeHandler{storage: deviceStorage, now: time.Now}
+}
+
+// DeviceCodeHandler handles the token requests of devices which poll for the result of their device authorization.
+//
+// When the user approved the device, the callback endpoint issued an authorization code to the device's client,
+// so this handler turns the request into the token request which redeems that authorization code. The handlers of
+// the authorization code grant, which must be composed after this one, then validate it and issue the tokens just
+// like they do for any other login.
+type DeviceCodeHandler struct {
+	storage deviceauthorization.Storage
+	now     func() time.Time
+}
+
+func (d *DeviceCodeHandler) HandleTokenEndpointRequest(ctx context.Context, requester fosite.AccessRequester) error {
+	if !requester.GetGrantTypes().ExactOne(DeviceCodeGrantType) {
+		return errors.WithStack(fosite.ErrUnknownRequest)
+	}
+	if d.storage == nil {
+		return errors.WithStack(fosite.ErrUnsupportedGrantType)
+	}
+	if !requester.GetClient().GetGrantTypes().Has(DeviceCodeGrantType) {
+		return errors.WithStack(fosite.ErrUnauthorizedClient.WithHintf("The OAuth 2.0 Client is not allowed to use authorization grant %q.", DeviceCodeGrantType))
+	}
+
+	form := requester.GetRequestForm()
+	deviceCode := form.Get("device_code")
+	userCode := strings.SplitN(deviceCode, ".", 2)[0]
+	if userCode == "" || userCode == deviceCode {
+		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("The device_code is malformed."))
+	}
+
+	session, err := d.storage.GetDeviceAuthorization(ctx, userCode)
+	if errors.Is(err, fosite.ErrNotFound) {
+		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("The device_code is unknown or was already used."))
+	}
+	if err != nil {
+		return errors.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	if subtle.ConstantTimeCompare([]byte(session.DeviceCodeSignature), []byte(DeviceCodeSignature(deviceCode))) != 1 ||
+		session.ClientID != requester.GetClient().GetID() {
+		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("The device_code is unknown or was already used."))
+	}
+	if d.now().After(session.ExpiresAt) {
+		return errors.WithStack(errExpiredToken)
+	}
+	if !session.Approved() {
+		return errors.WithStack(errAuthorizationPending)
+	}
+
+	// The device code can only be redeemed once.
+	if err := d.storage.DeleteDeviceAuthorization(ctx, userCode); err != nil {
+		return errors.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	request, ok := requester.(*fosite.AccessRequest)
+	if !ok {
+		return errors.WithStack(fosite.ErrServerError.WithDebugf("unexpected access request type %T", requester))
+	}
+	request.GrantTypes = fosite.Arguments{"authorization_code"}
+	form.Del("device_code")
+	form.Set("code", session.Authcode)
+	form.Set("code_verifier", session.CodeVerifier)
+	form.Set("redirect_uri", DeviceAuthorizationRedirectURI)
+	return nil
+}
+
+func (d *DeviceCodeHandler) PopulateTokenEndpointResponse(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
+	// By now, the request has become an authorization code request, whose handlers populate the response.
+	return errors.WithStack(fosite.ErrUnknownRequest)
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateUserCode(t *testing.T) {
+	userCode, err := generateUserCode(bytes.NewReader([]byte{0, 1, 2, 19, 20, 21, 255, 39}))
+	require.NoError(t, err)
+	require.Equal(t, "BCDZ-BCTZ", userCode)
+
+	_, err = generateUserCode(bytes.NewReader([]byte{0, 1, 2}))
+	require.EqualError(t, err, "could not generate user code: unexpected EOF")
+}
+
+func TestNormalizeUserCode(t *testing.T) {
+	for _, userCode := range []string{"BCDF-GHJK", "bcdf-ghjk", "BCDFGHJK", " bcdf ghjk\n"} {
+		require.Equal(t, "BCDFGHJK", NormalizeUserCode(userCode), "user code %q", userCode)
+	}
+	require.Equal(t, "", NormalizeUserCode("AEIOU-1234"))
+}
+
+func TestGenerateDeviceCode(t *testing.T) {
+	deviceCode, err := GenerateDeviceCode("BCDF-GHJK")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(deviceCode, "BCDFGHJK."), "device code %q", deviceCode)
+
+	otherDeviceCode, err := GenerateDeviceCode("BCDF-GHJK")
+	require.NoError(t, err)
+	require.NotEqual(t, deviceCode, otherDeviceCode)
+	require.NotEqual(t, DeviceCodeSignature(deviceCode), DeviceCodeSignature(otherDeviceCode))
+}
@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package discovery provides a handler for the OIDC discovery endpoint.
@@ -31,6 +31,9 @@ type Metadata struct {
 
 	// vvv Optional vvv
 
+	RevocationEndpoint          string `json:"revocation_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint,omitempty"`
+
 	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
 	ScopesSupported                   []string `json:"scopes_supported"`
 	ClaimsSupported                   []string `json:"claims_supported"`
@@ -52,6 +55,8 @@ func NewHandler(issuerURL string) http.Handler {
 			Issuer:                            issuerURL,
 			AuthorizationEndpoint:             issuerURL + oidc.AuthorizationEndpointPath,
 			TokenEndpoint:                     issuerURL + oidc.TokenEndpointPath,
+			RevocationEndpoint:                issuerURL + oidc.RevocationEndpointPath,
+			DeviceAuthorizationEndpoint:       issuerURL + oidc.DeviceAuthorizationEndpointPath,
 			JWKSURI:                           issuerURL + oidc.JWKSEndpointPath,
 			ResponseTypesSupported:            []string{"code"},
 			SubjectTypesSupported:             []string{"public"},
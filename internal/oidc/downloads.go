@@ -0,0 +1,37 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import "time"
+
+const (
+	// DownloadsEndpointPath is the browser page from which logged in users download kubeconfigs.
+	DownloadsEndpointPath = "/downloads"
+
+	// DownloadsSessionParamName is added to the downstream authorization request which the downloads page starts, so
+	// the callback endpoint starts a downloads session instead of issuing an authcode.
+	DownloadsSessionParamName = "pinniped_downloads"
+
+	// DownloadsSessionCookieName is the name of the browser cookie which holds the downloads session. The `__Host`
+	// prefix has a special meaning, see CSRFCookieName.
+	DownloadsSessionCookieName = "__Host-pinniped-downloads"
+
+	// DownloadsSessionCookieEncodingName is the `name` passed to the encoder for encoding and decoding the downloads
+	// session cookie contents.
+	DownloadsSessionCookieEncodingName = "downloads"
+
+	// DownloadsSessionLifespan is how long a user may download kubeconfigs after logging in to the downloads page.
+	DownloadsSessionLifespan = 15 * time.Minute
+)
+
+// DownloadsSession is the identity of the user who logged in to the downloads page, as it is stored in the downloads
+// session cookie. The cookie is signed and expires after DownloadsSessionLifespan.
+type DownloadsSession struct {
+	// Issuer is the FederationDomain to which the user logged in, since the cookie is shared by all the
+	// FederationDomains of a host.
+	Issuer   string   `json:"iss"`
+	Subject  string   `json:"sub"`
+	Username string   `json:"username"`
+	Groups   []string `json:"groups"`
+}
@@ -0,0 +1,266 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package downstreamsession computes the identity of a downstream session from the claims of an upstream ID token,
+// which is shared by the logins at the callback endpoint and the password logins at the authorization endpoint.
+package downstreamsession
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/oidc/usernametemplate"
+	"go.pinniped.dev/internal/perror"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// The name of the email claim from https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+	emailClaimName = "email"
+
+	// The name of the email_verified claim from https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+	emailVerifiedClaimName = "email_verified"
+)
+
+// GetSubjectAndUsernameFromUpstreamIDToken returns the downstream subject, which is made globally unique by
+// prepending the upstream issuer to the upstream subject, and the downstream username, which is read from the
+// configured username claim or computed by the configured username template.
+func GetSubjectAndUsernameFromUpstreamIDToken(
+	upstreamIDPConfig provider.UpstreamOIDCIdentityProviderI,
+	idTokenClaims map[string]interface{},
+) (string, string, error) {
+	// The spec says the "sub" claim is only unique per issuer,
+	// so we will prepend the issuer string to make it globally unique.
+	upstreamIssuer := idTokenClaims[oidc.IDTokenIssuerClaim]
+	if upstreamIssuer == "" {
+		plog.Warning(
+			"issuer claim in upstream ID token missing",
+			"upstreamName", upstreamIDPConfig.GetName(),
+			"issClaim", upstreamIssuer,
+		)
+		return "", "", perror.New(perror.CodeUpstreamMisconfigured, "issuer claim in upstream ID token missing")
+	}
+	upstreamIssuerAsString, ok := upstreamIssuer.(string)
+	if !ok {
+		plog.Warning(
+			"issuer claim in upstream ID token has invalid format",
+			"upstreamName", upstreamIDPConfig.GetName(),
+			"issClaim", upstreamIssuer,
+		)
+		return "", "", perror.New(perror.CodeUpstreamMisconfigured, "issuer claim in upstream ID token has invalid format")
+	}
+
+	subjectAsInterface, ok := idTokenClaims[oidc.IDTokenSubjectClaim]
+	if !ok {
+		plog.Warning(
+			"no subject claim in upstream ID token",
+			"upstreamName", upstreamIDPConfig.GetName(),
+		)
+		return "", "", perror.New(perror.CodeUpstreamMisconfigured, "no subject claim in upstream ID token")
+	}
+
+	upstreamSubject, ok := subjectAsInterface.(string)
+	if !ok {
+		plog.Warning(
+			"subject claim in upstream ID token has invalid format",
+			"upstreamName", upstreamIDPConfig.GetName(),
+		)
+		return "", "", perror.New(perror.CodeUpstreamMisconfigured, "subject claim in upstream ID token has invalid format")
+	}
+
+	subject := fmt.Sprintf("%s?%s=%s", upstreamIssuerAsString, oidc.IDTokenSubjectClaim, upstreamSubject)
+
+	if usernameTemplate := upstreamIDPConfig.GetUsernameTemplate(); usernameTemplate != nil {
+		username, err := getUsernameFromTemplate(upstreamIDPConfig, usernameTemplate, idTokenClaims)
+		if err != nil {
+			return "", "", err
+		}
+		return subject, username, nil
+	}
+
+	usernameClaimName := upstreamIDPConfig.GetUsernameClaim()
+	if usernameClaimName == "" {
+		return subject, subject, nil
+	}
+
+	// If the upstream username claim is configured to be the special "email" claim and the upstream "email_verified"
+	// claim is present, then validate that the "email_verified" claim is true.
+	emailVerifiedAsInterface, ok := idTokenClaims[emailVerifiedClaimName]
+	if usernameClaimName == emailClaimName && ok {
+		emailVerified, ok := emailVerifiedAsInterface.(bool)
+		if !ok {
+			plog.Warning(
+				"username claim configured as \"email\" and upstream email_verified claim is not a boolean",
+				"upstreamName", upstreamIDPConfig.GetName(),
+				"configuredUsernameClaim", usernameClaimName,
+				"emailVerifiedClaim", emailVerifiedAsInterface,
+			)
+			return "", "", perror.New(perror.CodeUpstreamMisconfigured, "email_verified claim in upstream ID token has invalid format")
+		}
+		if !emailVerified {
+			plog.Warning(
+				"username claim configured as \"email\" and upstream email_verified claim has false value",
+				"upstreamName", upstreamIDPConfig.GetName(),
+				"configuredUsernameClaim", usernameClaimName,
+			)
+			return "", "", perror.New(perror.CodeUpstreamMisconfigured, "email_verified claim in upstream ID token has false value")
+		}
+	}
+
+	usernameAsInterface, ok := idTokenClaims[usernameClaimName]
+	if !ok {
+		plog.Warning(
+			"no username claim in upstream ID token",
+			"upstreamName", upstreamIDPConfig.GetName(),
+			"configuredUsernameClaim", usernameClaimName,
+		)
+		return "", "", perror.New(perror.CodeUpstreamMisconfigured, "no username claim in upstream ID token")
+	}
+
+	username, ok := usernameAsInterface.(string)
+	if !ok {
+		plog.Warning(
+			"username claim in upstream ID token has invalid format",
+			"upstreamName", upstreamIDPConfig.GetName(),
+			"configuredUsernameClaim", usernameClaimName,
+		)
+		return "", "", perror.New(perror.CodeUpstreamMisconfigured, "username claim in upstream ID token has invalid format")
+	}
+
+	return subject, username, nil
+}
+
+func getUsernameFromTemplate(
+	upstreamIDPConfig provider.UpstreamOIDCIdentityProviderI,
+	usernameTemplate *usernametemplate.Template,
+	idTokenClaims map[string]interface{},
+) (string, error) {
+	// Like a username claim of "email", a template which uses the "email" claim must not use an unverified email.
+	emailVerifiedAsInterface, ok := idTokenClaims[emailVerifiedClaimName]
+	if usernameTemplate.ReferencesClaim(emailClaimName) && ok {
+		if emailVerified, ok := emailVerifiedAsInterface.(bool); !ok || !emailVerified {
+			plog.Warning(
+				"username template uses the email claim and upstream email_verified claim is not true",
+				"upstreamName", upstreamIDPConfig.GetName(),
+				"configuredUsernameTemplate", usernameTemplate.String(),
+				"emailVerifiedClaim", emailVerifiedAsInterface,
+			)
+			return "", perror.New(perror.CodeUpstreamMisconfigured, "email_verified claim in upstream ID token is not true")
+		}
+	}
+
+	username, err := usernameTemplate.Execute(idTokenClaims)
+	if err != nil {
+		plog.Warning(
+			"could not compute username from upstream ID token",
+			"upstreamName", upstreamIDPConfig.GetName(),
+			"configuredUsernameTemplate", usernameTemplate.String(),
+			"error", err.Error(),
+		)
+		return "", perror.New(perror.CodeUpstreamMisconfigured, "could not compute username from upstream ID token: "+err.Error())
+	}
+	return username, nil
+}
+
+// GetGroupsFromUpstreamIDToken returns the groups from the configured groups claim, if any.
+func GetGroupsFromUpstreamIDToken(
+	upstreamIDPConfig provider.UpstreamOIDCIdentityProviderI,
+	idTokenClaims map[string]interface{},
+) ([]string, error) {
+	groupsClaimName := upstreamIDPConfig.GetGroupsClaim()
+	if groupsClaimName == "" {
+		return nil, nil
+	}
+
+	groupsAsInterface, ok := idTokenClaims[groupsClaimName]
+	if !ok {
+		plog.Warning(
+			"no groups claim in upstream ID token",
+			"upstreamName", upstreamIDPConfig.GetName(),
+			"configuredGroupsClaim", groupsClaimName,
+		)
+		return nil, nil // the upstream IDP may have omitted the claim if the user has no groups
+	}
+
+	groupsAsArray, okAsArray := extractGroups(groupsAsInterface)
+	if !okAsArray {
+		plog.Warning(
+			"groups claim in upstream ID token has invalid format",
+			"upstreamName", upstreamIDPConfig.GetName(),
+			"configuredGroupsClaim", groupsClaimName,
+		)
+		return nil, perror.New(perror.CodeUpstreamMisconfigured, "groups claim in upstream ID token has invalid format")
+	}
+
+	return groupsAsArray, nil
+}
+
+// GetUIDFromUpstreamIDToken returns the opaque downstream UID, which is derived from the configured UID claim.
+func GetUIDFromUpstreamIDToken(
+	upstreamIDPConfig provider.UpstreamOIDCIdentityProviderI,
+	idTokenClaims map[string]interface{},
+) (string, error) {
+	uidClaimName := upstreamIDPConfig.GetUIDClaim()
+	if uidClaimName == "" {
+		uidClaimName = oidc.IDTokenSubjectClaim
+	}
+
+	uidAsInterface, ok := idTokenClaims[uidClaimName]
+	if !ok {
+		plog.Warning(
+			"no uid claim in upstream ID token",
+			"upstreamName", upstreamIDPConfig.GetName(),
+			"configuredUIDClaim", uidClaimName,
+		)
+		return "", perror.New(perror.CodeUpstreamMisconfigured, "no uid claim in upstream ID token")
+	}
+
+	upstreamUID, ok := uidAsInterface.(string)
+	if !ok || upstreamUID == "" {
+		plog.Warning(
+			"uid claim in upstream ID token has invalid format",
+			"upstreamName", upstreamIDPConfig.GetName(),
+			"configuredUIDClaim", uidClaimName,
+		)
+		return "", perror.New(perror.CodeUpstreamMisconfigured, "uid claim in upstream ID token has invalid format")
+	}
+
+	// The issuer claim was already validated by GetSubjectAndUsernameFromUpstreamIDToken. Like the downstream
+	// subject, the UID is scoped to the upstream issuer, but it is hashed so that it is opaque and has a fixed length.
+	upstreamIssuer, _ := idTokenClaims[oidc.IDTokenIssuerClaim].(string)
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s?%s=%s", upstreamIssuer, uidClaimName, upstreamUID)))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func extractGroups(groupsAsInterface interface{}) ([]string, bool) {
+	groupsAsString, okAsString := groupsAsInterface.(string)
+	if okAsString {
+		return []string{groupsAsString}, true
+	}
+
+	groupsAsStringArray, okAsStringArray := groupsAsInterface.([]string)
+	if okAsStringArray {
+		return groupsAsStringArray, true
+	}
+
+	groupsAsInterfaceArray, okAsArray := groupsAsInterface.([]interface{})
+	if !okAsArray {
+		return nil, false
+	}
+
+	var groupsAsStrings []string
+	for _, groupAsInterface := range groupsAsInterfaceArray {
+		groupAsString, okAsString := groupAsInterface.(string)
+		if !okAsString {
+			return nil, false
+		}
+		if groupAsString != "" {
+			groupsAsStrings = append(groupsAsStrings, groupAsString)
+		}
+	}
+
+	return groupsAsStrings, true
+}
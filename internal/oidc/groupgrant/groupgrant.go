@@ -0,0 +1,153 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package groupgrant adds the groups from active GroupGrants to the downstream sessions of the Supervisor.
+package groupgrant
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ory/fosite/handler/openid"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	configlisters "go.pinniped.dev/generated/latest/client/supervisor/listers/config/v1alpha1"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/plog"
+)
+
+// Phase returns the phase of the grant at the given time.
+func Phase(grant *configv1alpha1.GroupGrant, now time.Time) configv1alpha1.GroupGrantPhase {
+	switch {
+	case !now.Before(grant.Spec.ExpiresAt.Time):
+		return configv1alpha1.ExpiredGroupGrantPhase
+	case grant.Spec.NotBefore != nil && now.Before(grant.Spec.NotBefore.Time):
+		return configv1alpha1.PendingGroupGrantPhase
+	default:
+		return configv1alpha1.ActiveGroupGrantPhase
+	}
+}
+
+// Applier applies GroupGrants to downstream sessions. It is safe for concurrent use.
+type Applier struct {
+	lister               configlisters.GroupGrantNamespaceLister
+	clock                clock.Clock
+	federationDomainName string
+}
+
+// New returns an Applier which reads the GroupGrants from the given lister. Use ForFederationDomain to get an
+// Applier for the sessions of one FederationDomain.
+func New(lister configlisters.GroupGrantNamespaceLister, clock clock.Clock) *Applier {
+	return &Applier{lister: lister, clock: clock}
+}
+
+// ForFederationDomain returns an Applier which only applies the GroupGrants of the named FederationDomain.
+func (a *Applier) ForFederationDomain(federationDomainName string) *Applier {
+	return &Applier{lister: a.lister, clock: a.clock, federationDomainName: federationDomainName}
+}
+
+// Apply updates the groups claim of the session to include the groups from the GroupGrants of its user which are
+// currently active. Grants are matched by the downstream subject of the session, which identifies the upstream
+// identity provider as well as the user, since several identity providers may map users to the same username.
+// Groups which were added by a previous call, but whose grants are no longer active, are removed.
+// This should be called each time that tokens are issued for the session, including refreshes.
+func (a *Applier) Apply(session *openid.DefaultSession) error {
+	if session == nil || session.Claims == nil || session.Claims.Extra == nil || session.Claims.Subject == "" {
+		return nil
+	}
+	subject := session.Claims.Subject
+	username, _ := session.Claims.Extra[oidc.DownstreamUsernameClaim].(string)
+
+	groupsClaim, ok := stringSlice(session.Claims.Extra[oidc.DownstreamGroupsClaim])
+	if !ok {
+		return nil
+	}
+	previouslyGrantedClaim, _ := stringSlice(session.Claims.Extra[oidc.DownstreamGrantedGroupsClaim])
+
+	grants, err := a.lister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("could not list GroupGrants: %w", err)
+	}
+
+	// Separate the groups that came from the upstream from the ones that were granted by a previous call.
+	previouslyGranted := sets.NewString(previouslyGrantedClaim...)
+	var upstreamGroups []string
+	for _, group := range groupsClaim {
+		if !previouslyGranted.Has(group) {
+			upstreamGroups = append(upstreamGroups, group)
+		}
+	}
+
+	now := a.clock.Now()
+	granted := sets.NewString()
+	var activeGrantNames []string
+	for _, grant := range grants {
+		if grant.Spec.FederationDomainName != a.federationDomainName ||
+			grant.Spec.Subject != subject ||
+			Phase(grant, now) != configv1alpha1.ActiveGroupGrantPhase {
+			continue
+		}
+		granted.Insert(grant.Spec.Groups...)
+		activeGrantNames = append(activeGrantNames, grant.Name)
+	}
+	granted.Delete(upstreamGroups...)
+	if granted.Len() == 0 && previouslyGranted.Len() == 0 {
+		return nil
+	}
+	sort.Strings(activeGrantNames)
+
+	if granted.Len() > 0 {
+		plog.Info("adding groups from group grants to downstream session",
+			"federationDomain", a.federationDomainName,
+			"subject", subject,
+			"username", username,
+			"groupGrants", activeGrantNames,
+			"grantedGroups", granted.List(),
+		)
+	}
+	if removed := previouslyGranted.Difference(granted); removed.Len() > 0 {
+		plog.Info("removing groups from inactive group grants from downstream session",
+			"federationDomain", a.federationDomainName,
+			"subject", subject,
+			"username", username,
+			"removedGroups", removed.List(),
+		)
+	}
+
+	groups := append([]string{}, upstreamGroups...)
+	groups = append(groups, granted.List()...)
+	session.Claims.Extra[oidc.DownstreamGroupsClaim] = groups
+	if granted.Len() > 0 {
+		session.Claims.Extra[oidc.DownstreamGrantedGroupsClaim] = granted.List()
+	} else {
+		delete(session.Claims.Extra, oidc.DownstreamGrantedGroupsClaim)
+	}
+	return nil
+}
+
+// stringSlice converts a claim value to a []string. Claims which were read back from session storage are
+// decoded from JSON as []interface{} rather than []string. A missing claim is treated as an empty list.
+func stringSlice(claim interface{}) ([]string, bool) {
+	switch v := claim.(type) {
+	case nil:
+		return nil, true
+	case []string:
+		return v, true
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			result = append(result, s)
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
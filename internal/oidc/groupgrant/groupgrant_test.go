@@ -0,0 +1,247 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package groupgrant
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/token/jwt"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	configlisters "go.pinniped.dev/generated/latest/client/supervisor/listers/config/v1alpha1"
+	"go.pinniped.dev/internal/oidc"
+)
+
+func TestPhase(t *testing.T) {
+	now := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	before := metav1.NewTime(now.Add(-time.Minute))
+	after := metav1.NewTime(now.Add(time.Minute))
+
+	require.Equal(t, configv1alpha1.ActiveGroupGrantPhase,
+		Phase(&configv1alpha1.GroupGrant{Spec: configv1alpha1.GroupGrantSpec{ExpiresAt: after}}, now))
+	require.Equal(t, configv1alpha1.ActiveGroupGrantPhase,
+		Phase(&configv1alpha1.GroupGrant{Spec: configv1alpha1.GroupGrantSpec{NotBefore: &before, ExpiresAt: after}}, now))
+	require.Equal(t, configv1alpha1.PendingGroupGrantPhase,
+		Phase(&configv1alpha1.GroupGrant{Spec: configv1alpha1.GroupGrantSpec{NotBefore: &after, ExpiresAt: after}}, now.Add(-30*time.Second)))
+	require.Equal(t, configv1alpha1.ExpiredGroupGrantPhase,
+		Phase(&configv1alpha1.GroupGrant{Spec: configv1alpha1.GroupGrantSpec{ExpiresAt: before}}, now))
+	require.Equal(t, configv1alpha1.ExpiredGroupGrantPhase,
+		Phase(&configv1alpha1.GroupGrant{Spec: configv1alpha1.GroupGrantSpec{ExpiresAt: metav1.NewTime(now)}}, now))
+}
+
+func TestApply(t *testing.T) {
+	const (
+		namespace            = "some-namespace"
+		federationDomainName = "some-federation-domain"
+	)
+	now := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	inOneHour := metav1.NewTime(now.Add(time.Hour))
+	oneHourAgo := metav1.NewTime(now.Add(-time.Hour))
+
+	newGroupGrant := func(name, subject string, notBefore *metav1.Time, expiresAt metav1.Time, groups ...string) *configv1alpha1.GroupGrant {
+		return &configv1alpha1.GroupGrant{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: configv1alpha1.GroupGrantSpec{
+				FederationDomainName: federationDomainName,
+				Subject:              subject,
+				Username:             "some-user",
+				Groups:               groups,
+				NotBefore:            notBefore,
+				ExpiresAt:            expiresAt,
+			},
+		}
+	}
+	newSession := func(extra map[string]interface{}) *openid.DefaultSession {
+		return &openid.DefaultSession{Claims: &jwt.IDTokenClaims{Subject: "some-subject", Extra: extra}}
+	}
+
+	tests := []struct {
+		name        string
+		groupGrants []*configv1alpha1.GroupGrant
+		session     *openid.DefaultSession
+		wantExtra   map[string]interface{}
+	}{
+		{
+			name: "no grants",
+			session: newSession(map[string]interface{}{
+				oidc.DownstreamUsernameClaim: "some-user",
+				oidc.DownstreamGroupsClaim:   []string{"upstream-group"},
+			}),
+			wantExtra: map[string]interface{}{
+				oidc.DownstreamUsernameClaim: "some-user",
+				oidc.DownstreamGroupsClaim:   []string{"upstream-group"},
+			},
+		},
+		{
+			name: "active grants for the user are added and other grants are ignored",
+			groupGrants: []*configv1alpha1.GroupGrant{
+				newGroupGrant("grant-1", "some-subject", nil, inOneHour, "granted-group-2", "upstream-group"),
+				newGroupGrant("grant-2", "some-subject", &oneHourAgo, inOneHour, "granted-group-1"),
+				newGroupGrant("pending-grant", "some-subject", &inOneHour, inOneHour, "pending-group"),
+				newGroupGrant("expired-grant", "some-subject", nil, oneHourAgo, "expired-group"),
+				newGroupGrant("other-user-grant", "some-other-subject", nil, inOneHour, "other-user-group"),
+				func() *configv1alpha1.GroupGrant {
+					// Another FederationDomain may grant groups to the same user, which are only added to its own tokens.
+					grant := newGroupGrant("other-federation-domain-grant", "some-subject", nil, inOneHour, "other-federation-domain-group")
+					grant.Spec.FederationDomainName = "some-other-federation-domain"
+					return grant
+				}(),
+			},
+			session: newSession(map[string]interface{}{
+				oidc.DownstreamUsernameClaim: "some-user",
+				oidc.DownstreamGroupsClaim:   []string{"upstream-group"},
+			}),
+			wantExtra: map[string]interface{}{
+				oidc.DownstreamUsernameClaim:      "some-user",
+				oidc.DownstreamGroupsClaim:        []string{"upstream-group", "granted-group-1", "granted-group-2"},
+				oidc.DownstreamGrantedGroupsClaim: []string{"granted-group-1", "granted-group-2"},
+			},
+		},
+		{
+			name: "groups from grants which are no longer active are removed on refresh",
+			groupGrants: []*configv1alpha1.GroupGrant{
+				newGroupGrant("grant-1", "some-subject", nil, inOneHour, "granted-group-1"),
+				newGroupGrant("expired-grant", "some-subject", nil, oneHourAgo, "granted-group-2"),
+			},
+			session: newSession(map[string]interface{}{
+				oidc.DownstreamUsernameClaim:      "some-user",
+				oidc.DownstreamGroupsClaim:        []interface{}{"upstream-group", "granted-group-1", "granted-group-2"},
+				oidc.DownstreamGrantedGroupsClaim: []interface{}{"granted-group-1", "granted-group-2"},
+			}),
+			wantExtra: map[string]interface{}{
+				oidc.DownstreamUsernameClaim:      "some-user",
+				oidc.DownstreamGroupsClaim:        []string{"upstream-group", "granted-group-1"},
+				oidc.DownstreamGrantedGroupsClaim: []string{"granted-group-1"},
+			},
+		},
+		{
+			name: "granted groups claim is removed when no grants are active anymore",
+			groupGrants: []*configv1alpha1.GroupGrant{
+				newGroupGrant("expired-grant", "some-subject", nil, oneHourAgo, "granted-group"),
+			},
+			session: newSession(map[string]interface{}{
+				oidc.DownstreamUsernameClaim:      "some-user",
+				oidc.DownstreamGroupsClaim:        []interface{}{"upstream-group", "granted-group"},
+				oidc.DownstreamGrantedGroupsClaim: []interface{}{"granted-group"},
+			}),
+			wantExtra: map[string]interface{}{
+				oidc.DownstreamUsernameClaim: "some-user",
+				oidc.DownstreamGroupsClaim:   []string{"upstream-group"},
+			},
+		},
+		{
+			name: "session without a groups claim",
+			groupGrants: []*configv1alpha1.GroupGrant{
+				newGroupGrant("grant", "some-subject", nil, inOneHour, "granted-group"),
+			},
+			session: newSession(map[string]interface{}{
+				oidc.DownstreamUsernameClaim: "some-user",
+			}),
+			wantExtra: map[string]interface{}{
+				oidc.DownstreamUsernameClaim:      "some-user",
+				oidc.DownstreamGroupsClaim:        []string{"granted-group"},
+				oidc.DownstreamGrantedGroupsClaim: []string{"granted-group"},
+			},
+		},
+		{
+			name: "session with a groups claim that is not a list is left alone",
+			groupGrants: []*configv1alpha1.GroupGrant{
+				newGroupGrant("grant", "some-subject", nil, inOneHour, "granted-group"),
+			},
+			session: newSession(map[string]interface{}{
+				oidc.DownstreamUsernameClaim: "some-user",
+				oidc.DownstreamGroupsClaim:   "upstream-group",
+			}),
+			wantExtra: map[string]interface{}{
+				oidc.DownstreamUsernameClaim: "some-user",
+				oidc.DownstreamGroupsClaim:   "upstream-group",
+			},
+		},
+		{
+			name: "grants are matched by subject rather than by username",
+			groupGrants: []*configv1alpha1.GroupGrant{
+				// A user from another identity provider may be mapped to the same username.
+				newGroupGrant("grant", "some-other-idp?sub=some-user", nil, inOneHour, "granted-group"),
+			},
+			session: newSession(map[string]interface{}{
+				oidc.DownstreamUsernameClaim: "some-user",
+				oidc.DownstreamGroupsClaim:   []string{"upstream-group"},
+			}),
+			wantExtra: map[string]interface{}{
+				oidc.DownstreamUsernameClaim: "some-user",
+				oidc.DownstreamGroupsClaim:   []string{"upstream-group"},
+			},
+		},
+		{
+			name: "session without a subject is left alone",
+			groupGrants: []*configv1alpha1.GroupGrant{
+				newGroupGrant("grant", "", nil, inOneHour, "granted-group"),
+			},
+			session: &openid.DefaultSession{Claims: &jwt.IDTokenClaims{Extra: map[string]interface{}{
+				oidc.DownstreamGroupsClaim: []string{"upstream-group"},
+			}}},
+			wantExtra: map[string]interface{}{
+				oidc.DownstreamGroupsClaim: []string{"upstream-group"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			for _, grant := range tt.groupGrants {
+				require.NoError(t, indexer.Add(grant))
+			}
+			subject := New(configlisters.NewGroupGrantLister(indexer).GroupGrants(namespace), clock.NewFakeClock(now)).
+				ForFederationDomain(federationDomainName)
+
+			require.NoError(t, subject.Apply(tt.session))
+			require.Equal(t, tt.wantExtra, tt.session.Claims.Extra)
+		})
+	}
+}
+
+func TestApplyAfterSessionStorageRoundTrip(t *testing.T) {
+	const namespace = "some-namespace"
+	now := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	grant := &configv1alpha1.GroupGrant{
+		ObjectMeta: metav1.ObjectMeta{Name: "grant", Namespace: namespace},
+		Spec: configv1alpha1.GroupGrantSpec{
+			FederationDomainName: "some-federation-domain",
+			Subject:              "some-subject",
+			Groups:               []string{"granted-group"},
+			ExpiresAt:            metav1.NewTime(now.Add(time.Hour)),
+		},
+	}
+	require.NoError(t, indexer.Add(grant))
+	fakeClock := clock.NewFakeClock(now)
+	subject := New(configlisters.NewGroupGrantLister(indexer).GroupGrants(namespace), fakeClock).
+		ForFederationDomain("some-federation-domain")
+
+	// The initial token issuance adds the granted group.
+	session := oidc.MakeDownstreamSession("some-subject", "some-user", []string{"upstream-group"}, "some-uid")
+	require.NoError(t, subject.Apply(session))
+	require.Equal(t, []string{"upstream-group", "granted-group"}, session.Claims.Extra[oidc.DownstreamGroupsClaim])
+
+	// Sessions are stored as JSON, so the refreshed session will have []interface{} claims.
+	sessionJSON, err := json.Marshal(session)
+	require.NoError(t, err)
+	var refreshedSession openid.DefaultSession
+	require.NoError(t, json.Unmarshal(sessionJSON, &refreshedSession))
+
+	// Refreshing after the grant has expired removes the granted group but keeps the upstream group.
+	fakeClock.SetTime(now.Add(2 * time.Hour))
+	require.NoError(t, subject.Apply(&refreshedSession))
+	require.Equal(t, []string{"upstream-group"}, refreshedSession.Claims.Extra[oidc.DownstreamGroupsClaim])
+	require.NotContains(t, refreshedSession.Claims.Extra, oidc.DownstreamGrantedGroupsClaim)
+}
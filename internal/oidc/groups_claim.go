@@ -0,0 +1,73 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ory/fosite/handler/openid"
+
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/oidc/provider"
+)
+
+const errEmptyGroupsClaimName = constable.Error("groups claim name must not be empty")
+
+// reservedClaimNames are the claims of downstream ID tokens which cannot be used for the additional groups claim,
+// because they are defined by OpenID Connect or are already set by the Supervisor.
+//nolint: gochecknoglobals
+var reservedClaimNames = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "iat": true, "auth_time": true, "nonce": true,
+	"acr": true, "amr": true, "azp": true, "at_hash": true, IDTokenCodeHashClaim: true, "jti": true, "rat": true,
+	DownstreamUsernameClaim: true, DownstreamGroupsClaim: true, DownstreamUIDClaim: true, DownstreamGrantedGroupsClaim: true, ConfirmationClaim: true,
+}
+
+// ValidateGroupsClaimName returns an error when the name cannot be used for an additional groups claim.
+func ValidateGroupsClaimName(name string) error {
+	if name == "" {
+		return errEmptyGroupsClaimName
+	}
+	if reservedClaimNames[name] {
+		return fmt.Errorf("groups claim name %q is reserved", name)
+	}
+	return nil
+}
+
+// DownstreamGroups returns the groups of the session, which are nil when the session has no groups claim.
+func DownstreamGroups(session *openid.DefaultSession) []string {
+	if session == nil || session.Claims == nil {
+		return nil
+	}
+	var groups []string
+	switch v := session.Claims.Extra[DownstreamGroupsClaim].(type) {
+	case []string:
+		groups = v
+	case []interface{}:
+		// Claims which were read back from session storage are decoded from JSON as []interface{}.
+		for _, group := range v {
+			if s, ok := group.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	return groups
+}
+
+// AddGroupsClaim copies the groups claim of the session into the configured additional groups claim, so that the
+// additional claim includes any changes which were made to the groups since the session was created.
+func AddGroupsClaim(session *openid.DefaultSession, groupsClaim provider.DownstreamGroupsClaim) {
+	if groupsClaim.Name == "" || session == nil || session.Claims == nil || session.Claims.Extra == nil {
+		return
+	}
+	groups := DownstreamGroups(session)
+	if groups == nil {
+		groups = []string{}
+	}
+	if groupsClaim.SpaceDelimited {
+		session.Claims.Extra[groupsClaim.Name] = strings.Join(groups, " ")
+		return
+	}
+	session.Claims.Extra[groupsClaim.Name] = groups
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"testing"
+
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/token/jwt"
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/oidc/provider"
+)
+
+func TestValidateGroupsClaimName(t *testing.T) {
+	require.NoError(t, ValidateGroupsClaimName("roles"))
+	require.NoError(t, ValidateGroupsClaimName("cognito:groups"))
+	require.EqualError(t, ValidateGroupsClaimName(""), "groups claim name must not be empty")
+	require.EqualError(t, ValidateGroupsClaimName("groups"), `groups claim name "groups" is reserved`)
+	require.EqualError(t, ValidateGroupsClaimName("sub"), `groups claim name "sub" is reserved`)
+}
+
+func TestAddGroupsClaim(t *testing.T) {
+	tests := []struct {
+		name        string
+		groups      interface{}
+		groupsClaim provider.DownstreamGroupsClaim
+		wantExtra   map[string]interface{}
+	}{
+		{
+			name:        "no additional claim",
+			groups:      []string{"a", "b"},
+			groupsClaim: provider.DownstreamGroupsClaim{},
+			wantExtra:   map[string]interface{}{"groups": []string{"a", "b"}},
+		},
+		{
+			name:        "array",
+			groups:      []string{"a", "b"},
+			groupsClaim: provider.DownstreamGroupsClaim{Name: "roles"},
+			wantExtra:   map[string]interface{}{"groups": []string{"a", "b"}, "roles": []string{"a", "b"}},
+		},
+		{
+			name:        "space delimited",
+			groups:      []string{"a", "b"},
+			groupsClaim: provider.DownstreamGroupsClaim{Name: "roles", SpaceDelimited: true},
+			wantExtra:   map[string]interface{}{"groups": []string{"a", "b"}, "roles": "a b"},
+		},
+		{
+			name:        "groups from session storage",
+			groups:      []interface{}{"a", "b"},
+			groupsClaim: provider.DownstreamGroupsClaim{Name: "cognito:groups"},
+			wantExtra:   map[string]interface{}{"groups": []interface{}{"a", "b"}, "cognito:groups": []string{"a", "b"}},
+		},
+		{
+			name:        "no groups",
+			groups:      []string{},
+			groupsClaim: provider.DownstreamGroupsClaim{Name: "roles"},
+			wantExtra:   map[string]interface{}{"groups": []string{}, "roles": []string{}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			session := &openid.DefaultSession{Claims: &jwt.IDTokenClaims{Extra: map[string]interface{}{"groups": tt.groups}}}
+			AddGroupsClaim(session, tt.groupsClaim)
+			require.Equal(t, tt.wantExtra, session.Claims.Extra)
+		})
+	}
+
+	// Sessions without claims are ignored.
+	AddGroupsClaim(&openid.DefaultSession{}, provider.DownstreamGroupsClaim{Name: "roles"})
+	AddGroupsClaim(nil, provider.DownstreamGroupsClaim{Name: "roles"})
+}
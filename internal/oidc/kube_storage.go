@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidc
@@ -16,6 +16,7 @@ import (
 	"go.pinniped.dev/internal/constable"
 	"go.pinniped.dev/internal/fositestorage/accesstoken"
 	"go.pinniped.dev/internal/fositestorage/authorizationcode"
+	"go.pinniped.dev/internal/fositestorage/deviceauthorization"
 	"go.pinniped.dev/internal/fositestorage/openidconnect"
 	"go.pinniped.dev/internal/fositestorage/pkce"
 	"go.pinniped.dev/internal/fositestorage/refreshtoken"
@@ -29,6 +30,7 @@ type KubeStorage struct {
 	oidcStorage              openid.OpenIDConnectRequestStorage
 	accessTokenStorage       accesstoken.RevocationStorage
 	refreshTokenStorage      refreshtoken.RevocationStorage
+	deviceStorage            deviceauthorization.Storage
 }
 
 func NewKubeStorage(secrets corev1client.SecretInterface, timeoutsConfiguration TimeoutsConfiguration) *KubeStorage {
@@ -39,6 +41,7 @@ func NewKubeStorage(secrets corev1client.SecretInterface, timeoutsConfiguration
 		oidcStorage:              openidconnect.New(secrets, nowFunc, timeoutsConfiguration.OIDCSessionStorageLifetime),
 		accessTokenStorage:       accesstoken.New(secrets, nowFunc, timeoutsConfiguration.AccessTokenSessionStorageLifetime),
 		refreshTokenStorage:      refreshtoken.New(secrets, nowFunc, timeoutsConfiguration.RefreshTokenSessionStorageLifetime),
+		deviceStorage:            deviceauthorization.New(secrets, nowFunc, timeoutsConfiguration.DeviceAuthorizationSessionStorageLifetime),
 	}
 }
 
@@ -177,6 +180,32 @@ func (k KubeStorage) RevokeRefreshToken(ctx context.Context, requestID string) e
 	return k.refreshTokenStorage.RevokeRefreshToken(ctx, requestID)
 }
 
+//
+// Device authorizations:
+//
+// These are keyed by the user code, without the dash.
+//
+// These are not part of fosite. The device authorization endpoint creates them, the callback endpoint approves them
+// once the user has logged in on the verification page, and the token endpoint deletes them when the device
+// redeems its device code. If the device stops polling, then they will never be deleted.
+//
+
+func (k KubeStorage) CreateDeviceAuthorization(ctx context.Context, userCode string, session *deviceauthorization.Session) error {
+	return k.deviceStorage.CreateDeviceAuthorization(ctx, userCode, session)
+}
+
+func (k KubeStorage) GetDeviceAuthorization(ctx context.Context, userCode string) (*deviceauthorization.Session, error) {
+	return k.deviceStorage.GetDeviceAuthorization(ctx, userCode)
+}
+
+func (k KubeStorage) ApproveDeviceAuthorization(ctx context.Context, userCode string, authcode string) error {
+	return k.deviceStorage.ApproveDeviceAuthorization(ctx, userCode, authcode)
+}
+
+func (k KubeStorage) DeleteDeviceAuthorization(ctx context.Context, userCode string) error {
+	return k.deviceStorage.DeleteDeviceAuthorization(ctx, userCode)
+}
+
 //
 // OAuth client definitions:
 //
@@ -0,0 +1,69 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubeconfig
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/provider"
+)
+
+// ClusterList is the response of the clusters endpoint.
+type ClusterList struct {
+	Clusters []ClusterListItem `json:"clusters"`
+}
+
+// ClusterListItem describes a cluster which the user is allowed to access.
+type ClusterListItem struct {
+	// Name of the cluster, which selects it at the kubeconfig endpoint.
+	Name string `json:"name"`
+
+	// Audience which the user may request tokens for with a token exchange.
+	Audience string `json:"audience"`
+
+	// Server is the URL of the Kubernetes API server of the cluster.
+	Server string `json:"server"`
+
+	// KubeconfigURL is the URL at which a kubeconfig for the cluster can be downloaded.
+	KubeconfigURL string `json:"kubeconfigURL"`
+}
+
+// NewClustersHandler returns the handler for the clusters endpoint, which lists the clusters of the FederationDomain
+// that the user is allowed to access, based on their downstream groups. Like the kubeconfig endpoint, the request must
+// be authenticated with an access token of the FederationDomain as a bearer token.
+func NewClustersHandler(issuer string, clusters []provider.Cluster, introspector TokenIntrospector) http.Handler {
+	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != http.MethodGet {
+			return httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET)", r.Method)
+		}
+
+		session, err := authenticate(r, introspector)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+issuer+`"`)
+			return err
+		}
+
+		groups := oidc.DownstreamGroups(session)
+		list := ClusterList{Clusters: []ClusterListItem{}}
+		for i := range clusters {
+			if !clusters[i].Allows(groups) {
+				continue
+			}
+			list.Clusters = append(list.Clusters, ClusterListItem{
+				Name:          clusters[i].Name,
+				Audience:      clusters[i].Audience,
+				Server:        clusters[i].Server,
+				KubeconfigURL: issuer + oidc.KubeconfigEndpointPath + "?" + url.Values{"cluster": []string{clusters[i].Name}}.Encode(),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		return json.NewEncoder(w).Encode(&list)
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubeconfig
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/oidc/provider"
+)
+
+func TestClustersHandler(t *testing.T) {
+	const issuer = "https://some-issuer.com/some/path"
+
+	clusters := []provider.Cluster{
+		{Name: "dev", Audience: "dev-audience", Server: "https://dev.example.com", AllowedGroups: []string{"developers", "admins"}},
+		{Name: "prod", Audience: "prod-audience", Server: "https://prod.example.com", AllowedGroups: []string{"admins"}},
+		{Name: "sandbox", Audience: "sandbox-audience", Server: "https://sandbox.example.com"},
+	}
+
+	introspector := fakeIntrospector{
+		"developer-token": sessionWithGroups("developers"),
+		"admin-token":     sessionWithGroups("admins"),
+		"other-token":     sessionWithGroups(),
+	}
+
+	tests := []struct {
+		name     string
+		clusters []provider.Cluster
+		method   string
+		token    string
+
+		wantStatus          int
+		wantWWWAuthenticate string
+		wantContentType     string
+		wantBody            string
+	}{
+		{
+			name:       "bad method",
+			method:     http.MethodPost,
+			token:      "developer-token",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   "Method Not Allowed: POST (try GET)\n",
+		},
+		{
+			name:                "missing bearer token",
+			wantStatus:          http.StatusUnauthorized,
+			wantWWWAuthenticate: `Bearer realm="https://some-issuer.com/some/path"`,
+			wantBody:            "Unauthorized: missing bearer token\n",
+		},
+		{
+			name:                "invalid bearer token",
+			token:               "wrong-token",
+			wantStatus:          http.StatusUnauthorized,
+			wantWWWAuthenticate: `Bearer realm="https://some-issuer.com/some/path"`,
+			wantBody:            "Unauthorized: invalid bearer token\n",
+		},
+		{
+			name:            "admin is allowed to access all clusters",
+			clusters:        clusters,
+			token:           "admin-token",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody: `{"clusters":[` +
+				`{"name":"dev","audience":"dev-audience","server":"https://dev.example.com","kubeconfigURL":"https://some-issuer.com/some/path/kubeconfig?cluster=dev"},` +
+				`{"name":"prod","audience":"prod-audience","server":"https://prod.example.com","kubeconfigURL":"https://some-issuer.com/some/path/kubeconfig?cluster=prod"},` +
+				`{"name":"sandbox","audience":"sandbox-audience","server":"https://sandbox.example.com","kubeconfigURL":"https://some-issuer.com/some/path/kubeconfig?cluster=sandbox"}` +
+				"]}\n",
+		},
+		{
+			name:            "developer is allowed to access some clusters",
+			clusters:        clusters,
+			token:           "developer-token",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody: `{"clusters":[` +
+				`{"name":"dev","audience":"dev-audience","server":"https://dev.example.com","kubeconfigURL":"https://some-issuer.com/some/path/kubeconfig?cluster=dev"},` +
+				`{"name":"sandbox","audience":"sandbox-audience","server":"https://sandbox.example.com","kubeconfigURL":"https://some-issuer.com/some/path/kubeconfig?cluster=sandbox"}` +
+				"]}\n",
+		},
+		{
+			name:            "user without groups is only allowed to access unrestricted clusters",
+			clusters:        clusters,
+			token:           "other-token",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody: `{"clusters":[` +
+				`{"name":"sandbox","audience":"sandbox-audience","server":"https://sandbox.example.com","kubeconfigURL":"https://some-issuer.com/some/path/kubeconfig?cluster=sandbox"}` +
+				"]}\n",
+		},
+		{
+			name:            "no clusters",
+			token:           "admin-token",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `{"clusters":[]}` + "\n",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			handler := NewClustersHandler(issuer, test.clusters, introspector)
+
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, issuer+"/clusters", nil)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			rsp := httptest.NewRecorder()
+			handler.ServeHTTP(rsp, req)
+
+			require.Equal(t, test.wantStatus, rsp.Code)
+			require.Equal(t, test.wantBody, rsp.Body.String())
+			require.Equal(t, test.wantWWWAuthenticate, rsp.Header().Get("WWW-Authenticate"))
+			if test.wantStatus == http.StatusOK {
+				require.Equal(t, test.wantContentType, rsp.Header().Get("Content-Type"))
+				require.Equal(t, "no-store", rsp.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
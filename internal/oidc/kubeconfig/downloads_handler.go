@@ -0,0 +1,164 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubeconfig
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/httputil/securityheader"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/groupgrant"
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/pkg/oidcclient/pkce"
+	"go.pinniped.dev/pkg/oidcclient/state"
+)
+
+//nolint: gochecknoglobals
+var downloadsPage = template.Must(template.New("downloads").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Pinniped kubeconfig downloads</title></head>
+<body>
+<h1>Download a kubeconfig</h1>
+<p>You are logged in as {{.Username}}. Each kubeconfig runs the Pinniped CLI to log you in when you use it, so it does not contain any credentials.</p>
+{{if .Clusters}}<table>
+<tr><th>Cluster</th><th>Server</th><th>Downloads</th></tr>
+{{range .Clusters}}<tr>
+<td>{{.Name}}</td>
+<td>{{.Server}}</td>
+<td><a href="{{.DownloadURL "darwin"}}">macOS</a> <a href="{{.DownloadURL "linux"}}">Linux</a> <a href="{{.DownloadURL "windows"}}">Windows</a></td>
+</tr>
+{{end}}</table>
+{{else}}<p>You are not allowed to access any clusters.</p>
+{{end}}</body>
+</html>
+`))
+
+type downloadsPageData struct {
+	Username string
+	Clusters []downloadsPageCluster
+}
+
+type downloadsPageCluster struct {
+	Name   string
+	Server string
+}
+
+// DownloadURL returns the relative URL of the kubeconfig of the cluster for the given platform.
+func (c downloadsPageCluster) DownloadURL(platform string) string {
+	return "?" + url.Values{"cluster": []string{c.Name}, "os": []string{platform}}.Encode()
+}
+
+// NewDownloadsHandler returns the handler for the downloads page, from which users download the kubeconfigs of the
+// clusters of the FederationDomain that they are allowed to access in their browser, e.g. GET /downloads. The
+// kubeconfigs are the same as those of the kubeconfig endpoint, e.g. GET /downloads?cluster=prod&os=darwin.
+//
+// Users who do not have a downloads session yet are sent to the authorization endpoint to log in, with a request
+// which makes the callback endpoint start a downloads session instead of issuing an authcode. The session is kept
+// in a signed cookie for oidc.DownloadsSessionLifespan, and the optional pinniped_idp_name and pinniped_idp_type
+// query parameters of the page select the upstream identity provider of the login. Logins with the static admin
+// identity provider do not go through the callback endpoint, so they cannot use this page.
+func NewDownloadsHandler(
+	downstreamIssuer string,
+	clusters []provider.Cluster,
+	groupGrants *groupgrant.Applier,
+	generateState func() (state.State, error),
+	generatePKCE func() (pkce.Code, error),
+	cookieCodec oidc.Decoder,
+) http.Handler {
+	return securityheader.Wrap(httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != http.MethodGet {
+			return httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET)", r.Method)
+		}
+
+		session := readDownloadsSession(r, downstreamIssuer, cookieCodec)
+		if session == nil {
+			return startDownloadsLogin(w, r, downstreamIssuer, generateState, generatePKCE)
+		}
+
+		groups := session.Groups
+		if groupGrants != nil {
+			// Like the token endpoint, add the groups of the active GroupGrants of the user.
+			openIDSession := oidc.MakeDownstreamSession(session.Subject, session.Username, session.Groups, "")
+			if err := groupGrants.Apply(openIDSession); err != nil {
+				plog.Error("downloads page group grants error", err)
+				return httperr.New(http.StatusInternalServerError, "error applying group grants")
+			}
+			groups = oidc.DownstreamGroups(openIDSession)
+		}
+
+		if r.URL.Query().Get("cluster") != "" {
+			return writeKubeconfig(w, r, downstreamIssuer, clusters, session.Subject, groups)
+		}
+
+		data := downloadsPageData{Username: session.Username}
+		for i := range clusters {
+			if clusters[i].Allows(groups) {
+				data.Clusters = append(data.Clusters, downloadsPageCluster{Name: clusters[i].Name, Server: clusters[i].Server})
+			}
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		return downloadsPage.Execute(w, data)
+	}))
+}
+
+func startDownloadsLogin(
+	w http.ResponseWriter,
+	r *http.Request,
+	downstreamIssuer string,
+	generateState func() (state.State, error),
+	generatePKCE func() (pkce.Code, error),
+) error {
+	stateValue, err := generateState()
+	if err != nil {
+		plog.Error("downloads page generate error", err)
+		return httperr.Wrap(http.StatusInternalServerError, "error generating state param", err)
+	}
+	// The authcode is never issued, but the authorization endpoint requires PKCE from the CLI client.
+	pkceCode, err := generatePKCE()
+	if err != nil {
+		plog.Error("downloads page generate error", err)
+		return httperr.Wrap(http.StatusInternalServerError, "error generating PKCE param", err)
+	}
+
+	authorizeConfig := oauth2.Config{
+		ClientID:    oidc.PinnipedCLIOIDCClient().ID,
+		Endpoint:    oauth2.Endpoint{AuthURL: downstreamIssuer + oidc.AuthorizationEndpointPath},
+		RedirectURL: oidc.DeviceAuthorizationRedirectURI,
+		Scopes:      []string{coreosoidc.ScopeOpenID},
+	}
+	authorizeParams := []oauth2.AuthCodeOption{
+		pkceCode.Challenge(),
+		pkceCode.Method(),
+		oauth2.SetAuthURLParam(oidc.DownloadsSessionParamName, "true"),
+	}
+	for _, param := range []string{oidc.UpstreamIDPNameParamName, oidc.UpstreamIDPTypeParamName} {
+		if value := r.URL.Query().Get(param); value != "" {
+			authorizeParams = append(authorizeParams, oauth2.SetAuthURLParam(param, value))
+		}
+	}
+	http.Redirect(w, r, authorizeConfig.AuthCodeURL(stateValue.String(), authorizeParams...), http.StatusSeeOther)
+	return nil
+}
+
+func readDownloadsSession(r *http.Request, downstreamIssuer string, codec oidc.Decoder) *oidc.DownloadsSession {
+	cookie, err := r.Cookie(oidc.DownloadsSessionCookieName)
+	if err != nil {
+		return nil
+	}
+	var session oidc.DownloadsSession
+	if err := codec.Decode(oidc.DownloadsSessionCookieEncodingName, cookie.Value, &session); err != nil {
+		return nil
+	}
+	if session.Issuer != downstreamIssuer {
+		return nil
+	}
+	return &session
+}
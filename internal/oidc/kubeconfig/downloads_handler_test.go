@@ -0,0 +1,270 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubeconfig
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/pkg/oidcclient/pkce"
+	"go.pinniped.dev/pkg/oidcclient/state"
+)
+
+func TestDownloadsHandler(t *testing.T) {
+	const issuer = "https://some-issuer.com/some/path"
+
+	clusters := []provider.Cluster{
+		{
+			Name:          "dev",
+			Audience:      "dev-audience",
+			Server:        "https://dev.example.com",
+			AllowedGroups: []string{"developers", "admins"},
+		},
+		{
+			Name:          "prod",
+			Audience:      "prod-audience",
+			Server:        "https://prod.example.com",
+			AllowedGroups: []string{"admins"},
+		},
+	}
+
+	codec := securecookie.New([]byte("some-hash-key"), nil)
+	codec.SetSerializer(securecookie.JSONEncoder{})
+	otherCodec := securecookie.New([]byte("some-other-hash-key"), nil)
+	otherCodec.SetSerializer(securecookie.JSONEncoder{})
+
+	sessionCookie := func(t *testing.T, codec *securecookie.SecureCookie, session oidc.DownloadsSession) string {
+		t.Helper()
+		encoded, err := codec.Encode(oidc.DownloadsSessionCookieEncodingName, session)
+		require.NoError(t, err)
+		return oidc.DownloadsSessionCookieName + "=" + encoded
+	}
+	developer := oidc.DownloadsSession{Issuer: issuer, Subject: "some-subject", Username: "some-developer", Groups: []string{"developers"}}
+
+	happyGenerateState := func() (state.State, error) { return "some-state", nil }
+	happyGeneratePKCE := func() (pkce.Code, error) { return "some-pkce-verifier-which-is-long-enough-for-the-spec", nil }
+	happyLoginLocation := issuer + "/oauth2/authorize?" + url.Values{
+		"response_type":         []string{"code"},
+		"client_id":             []string{"pinniped-cli"},
+		"redirect_uri":          []string{"http://127.0.0.1/callback"},
+		"scope":                 []string{"openid"},
+		"state":                 []string{"some-state"},
+		"code_challenge":        []string{oidc.PKCEChallenge("some-pkce-verifier-which-is-long-enough-for-the-spec")},
+		"code_challenge_method": []string{"S256"},
+		"pinniped_downloads":    []string{"true"},
+	}.Encode()
+
+	tests := []struct {
+		name string
+
+		method        string
+		path          string
+		cookie        string
+		generateState func() (state.State, error)
+		generatePKCE  func() (pkce.Code, error)
+
+		wantStatus      int
+		wantLocation    string
+		wantBody        string
+		wantContentType string
+	}{
+		{
+			name:       "bad method",
+			method:     http.MethodPost,
+			path:       "/downloads",
+			cookie:     sessionCookie(t, codec, developer),
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   "Method Not Allowed: POST (try GET)\n",
+		},
+		{
+			name:         "no session starts a login",
+			path:         "/downloads",
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: happyLoginLocation,
+		},
+		{
+			name:       "no session starts a login with the selected upstream identity provider",
+			path:       "/downloads?pinniped_idp_name=some-ldap&pinniped_idp_type=ldap&other=ignored",
+			wantStatus: http.StatusSeeOther,
+			wantLocation: issuer + "/oauth2/authorize?" + url.Values{
+				"response_type":         []string{"code"},
+				"client_id":             []string{"pinniped-cli"},
+				"redirect_uri":          []string{"http://127.0.0.1/callback"},
+				"scope":                 []string{"openid"},
+				"state":                 []string{"some-state"},
+				"code_challenge":        []string{oidc.PKCEChallenge("some-pkce-verifier-which-is-long-enough-for-the-spec")},
+				"code_challenge_method": []string{"S256"},
+				"pinniped_downloads":    []string{"true"},
+				"pinniped_idp_name":     []string{"some-ldap"},
+				"pinniped_idp_type":     []string{"ldap"},
+			}.Encode(),
+		},
+		{
+			name:         "session which was not signed by the supervisor starts a login",
+			path:         "/downloads",
+			cookie:       sessionCookie(t, otherCodec, developer),
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: happyLoginLocation,
+		},
+		{
+			name: "session of another FederationDomain starts a login",
+			path: "/downloads?cluster=dev",
+			cookie: sessionCookie(t, codec, oidc.DownloadsSession{
+				Issuer: "https://some-issuer.com/other/path", Subject: "some-subject", Username: "some-developer", Groups: []string{"developers"},
+			}),
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: happyLoginLocation,
+		},
+		{
+			name:          "error generating state",
+			path:          "/downloads",
+			generateState: func() (state.State, error) { return "", errors.New("some state error") },
+			wantStatus:    http.StatusInternalServerError,
+			wantBody:      "Internal Server Error: error generating state param\n",
+		},
+		{
+			name:         "error generating PKCE",
+			path:         "/downloads",
+			generatePKCE: func() (pkce.Code, error) { return "", errors.New("some PKCE error") },
+			wantStatus:   http.StatusInternalServerError,
+			wantBody:     "Internal Server Error: error generating PKCE param\n",
+		},
+		{
+			name:            "session lists the clusters which the user may access",
+			path:            "/downloads",
+			cookie:          sessionCookie(t, codec, developer),
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBody: here.Doc(`
+				<!DOCTYPE html>
+				<html>
+				<head><title>Pinniped kubeconfig downloads</title></head>
+				<body>
+				<h1>Download a kubeconfig</h1>
+				<p>You are logged in as some-developer. Each kubeconfig runs the Pinniped CLI to log you in when you use it, so it does not contain any credentials.</p>
+				<table>
+				<tr><th>Cluster</th><th>Server</th><th>Downloads</th></tr>
+				<tr>
+				<td>dev</td>
+				<td>https://dev.example.com</td>
+				<td><a href="?cluster=dev&amp;os=darwin">macOS</a> <a href="?cluster=dev&amp;os=linux">Linux</a> <a href="?cluster=dev&amp;os=windows">Windows</a></td>
+				</tr>
+				</table>
+				</body>
+				</html>
+			`),
+		},
+		{
+			name: "session of a user who may not access any cluster",
+			path: "/downloads",
+			cookie: sessionCookie(t, codec, oidc.DownloadsSession{
+				Issuer: issuer, Subject: "some-subject", Username: "<some-guest>", Groups: []string{"guests"},
+			}),
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBody: here.Doc(`
+				<!DOCTYPE html>
+				<html>
+				<head><title>Pinniped kubeconfig downloads</title></head>
+				<body>
+				<h1>Download a kubeconfig</h1>
+				<p>You are logged in as &lt;some-guest&gt;. Each kubeconfig runs the Pinniped CLI to log you in when you use it, so it does not contain any credentials.</p>
+				<p>You are not allowed to access any clusters.</p>
+				</body>
+				</html>
+			`),
+		},
+		{
+			name:       "session downloads the kubeconfig of an allowed cluster",
+			path:       "/downloads?cluster=dev&os=linux",
+			cookie:     sessionCookie(t, codec, developer),
+			wantStatus: http.StatusOK,
+			wantBody: here.Doc(`
+				apiVersion: v1
+				clusters:
+				- cluster:
+				    server: https://dev.example.com
+				  name: dev
+				contexts:
+				- context:
+				    cluster: dev
+				    user: dev
+				  name: dev
+				current-context: dev
+				kind: Config
+				preferences: {}
+				users:
+				- name: dev
+				  user:
+				    exec:
+				      apiVersion: client.authentication.k8s.io/v1beta1
+				      args:
+				      - login
+				      - oidc
+				      - --issuer=https://some-issuer.com/some/path
+				      - --client-id=pinniped-cli
+				      - --scopes=offline_access,openid,pinniped:request-audience
+				      - --request-audience=dev-audience
+				      command: pinniped
+				      env: []
+				      installHint: The Pinniped CLI is required to authenticate to this cluster. Download
+				        pinniped-cli-linux-amd64 from https://github.com/vmware-tanzu/pinniped/releases/latest
+				        and install it as "pinniped" in your PATH.
+				      provideClusterInfo: true
+			`),
+			wantContentType: "application/yaml",
+		},
+		{
+			name:       "session may not download the kubeconfig of another cluster",
+			path:       "/downloads?cluster=prod&os=linux",
+			cookie:     sessionCookie(t, codec, developer),
+			wantStatus: http.StatusForbidden,
+			wantBody:   "Forbidden: not allowed to access cluster \"prod\"\n",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			generateState, generatePKCE := happyGenerateState, happyGeneratePKCE
+			if test.generateState != nil {
+				generateState = test.generateState
+			}
+			if test.generatePKCE != nil {
+				generatePKCE = test.generatePKCE
+			}
+			handler := NewDownloadsHandler(issuer, clusters, nil, generateState, generatePKCE, codec)
+
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "https://some-issuer.com/some/path"+test.path, nil)
+			if test.cookie != "" {
+				req.Header.Set("Cookie", test.cookie)
+			}
+			rsp := httptest.NewRecorder()
+			handler.ServeHTTP(rsp, req)
+
+			testutil.RequireSecurityHeaders(t, rsp)
+			require.Equal(t, test.wantStatus, rsp.Code)
+			require.Equal(t, test.wantLocation, rsp.Header().Get("Location"))
+			if test.wantContentType != "" {
+				require.Equal(t, test.wantContentType, rsp.Header().Get("Content-Type"))
+			}
+			if test.wantBody != "" {
+				require.Equal(t, test.wantBody, rsp.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package kubeconfig provides the handlers for the kubeconfig download and cluster listing endpoints of a
+// FederationDomain, and for the downloads page from which users download kubeconfigs in their browser.
+package kubeconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	clientauthenticationv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/plog"
+)
+
+// TokenIntrospector validates the access tokens which were issued by the FederationDomain. It is implemented by
+// fosite.OAuth2Provider.
+type TokenIntrospector interface {
+	IntrospectToken(ctx context.Context, token string, tokenUse fosite.TokenUse, session fosite.Session, scope ...string) (fosite.TokenUse, fosite.AccessRequester, error)
+}
+
+// The install hints are shown by kubectl when the Pinniped CLI cannot be found.
+//nolint: gochecknoglobals
+var installHints = map[string]string{
+	"darwin":  "The Pinniped CLI is required to authenticate to this cluster. Install it with: brew install vmware-tanzu/pinniped/pinniped-cli",
+	"linux":   "The Pinniped CLI is required to authenticate to this cluster. Download pinniped-cli-linux-amd64 from https://github.com/vmware-tanzu/pinniped/releases/latest and install it as \"pinniped\" in your PATH.",
+	"windows": "The Pinniped CLI is required to authenticate to this cluster. Download pinniped-cli-windows-amd64.exe from https://github.com/vmware-tanzu/pinniped/releases/latest and install it as \"pinniped.exe\" in your PATH.",
+}
+
+// NewHandler returns the handler for the kubeconfig endpoint, which returns a kubeconfig for one of the clusters
+// of the FederationDomain, e.g. GET /kubeconfig?cluster=prod&os=darwin. The request must be authenticated with an
+// access token of the FederationDomain as a bearer token, and the user must be allowed to access the cluster.
+//
+// The kubeconfig does not contain any credentials. It runs "pinniped login oidc", which logs in to the
+// FederationDomain and requests a token for the cluster's audience. The "os" parameter selects the platform of the
+// Pinniped CLI, which is guessed from the User-Agent when it is omitted.
+func NewHandler(issuer string, clusters []provider.Cluster, introspector TokenIntrospector) http.Handler {
+	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != http.MethodGet {
+			return httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET)", r.Method)
+		}
+
+		session, err := authenticate(r, introspector)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+issuer+`"`)
+			return err
+		}
+
+		return writeKubeconfig(w, r, issuer, clusters, session.Subject, oidc.DownstreamGroups(session))
+	})
+}
+
+// writeKubeconfig writes the kubeconfig for the cluster and os from the query of the request, when the user with the
+// given subject and groups is allowed to access that cluster.
+func writeKubeconfig(w http.ResponseWriter, r *http.Request, issuer string, clusters []provider.Cluster, subject string, groups []string) error {
+	clusterName := r.URL.Query().Get("cluster")
+	if clusterName == "" {
+		return httperr.New(http.StatusBadRequest, "missing cluster parameter")
+	}
+	cluster := findCluster(clusters, clusterName)
+	if cluster == nil {
+		return httperr.Newf(http.StatusNotFound, "no such cluster %q", clusterName)
+	}
+	if !cluster.Allows(groups) {
+		plog.Info("kubeconfig download denied", "cluster", cluster.Name, "subject", subject)
+		return httperr.Newf(http.StatusForbidden, "not allowed to access cluster %q", clusterName)
+	}
+
+	platform := r.URL.Query().Get("os")
+	if platform == "" {
+		platform = platformFromUserAgent(r.UserAgent())
+	}
+	if _, ok := installHints[platform]; !ok {
+		return httperr.Newf(http.StatusBadRequest, "unsupported os parameter %q (use darwin, linux, or windows)", platform)
+	}
+
+	kubeconfigYAML, err := yaml.Marshal(newKubeconfig(issuer, cluster, platform))
+	if err != nil {
+		return httperr.Wrap(http.StatusInternalServerError, "could not encode kubeconfig", err)
+	}
+
+	plog.Debug("kubeconfig downloaded", "cluster", cluster.Name, "subject", subject, "os", platform)
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-kubeconfig.yaml"`, cluster.Name))
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(kubeconfigYAML)
+	return nil
+}
+
+func authenticate(r *http.Request, introspector TokenIntrospector) (*openid.DefaultSession, error) {
+	accessToken := fosite.AccessTokenFromRequest(r)
+	if accessToken == "" {
+		return nil, httperr.New(http.StatusUnauthorized, "missing bearer token")
+	}
+
+	_, requester, err := introspector.IntrospectToken(r.Context(), accessToken, fosite.AccessToken, &openid.DefaultSession{})
+	if err != nil {
+		plog.Info("kubeconfig download authentication error", oidc.FositeErrorForLog(err)...)
+		return nil, httperr.New(http.StatusUnauthorized, "invalid bearer token")
+	}
+
+	session, ok := requester.GetSession().(*openid.DefaultSession)
+	if !ok || session.Claims == nil {
+		return nil, httperr.New(http.StatusUnauthorized, "invalid bearer token")
+	}
+	if err := oidc.VerifyClientCertificateBinding(session, oidc.ClientCertificateThumbprint(r)); err != nil {
+		return nil, httperr.New(http.StatusUnauthorized, "bearer token is bound to a different client certificate")
+	}
+	return session, nil
+}
+
+func findCluster(clusters []provider.Cluster, name string) *provider.Cluster {
+	for i := range clusters {
+		if clusters[i].Name == name {
+			return &clusters[i]
+		}
+	}
+	return nil
+}
+
+func platformFromUserAgent(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "Windows"):
+		return "windows"
+	case strings.Contains(userAgent, "Macintosh"), strings.Contains(userAgent, "Mac OS X"):
+		return "darwin"
+	default:
+		return "linux"
+	}
+}
+
+func newKubeconfig(issuer string, cluster *provider.Cluster, platform string) *clientcmdv1.Config {
+	command := "pinniped"
+	if platform == "windows" {
+		command = "pinniped.exe"
+	}
+
+	args := []string{
+		"login", "oidc",
+		"--issuer=" + issuer,
+		"--client-id=" + oidc.PinnipedCLIOIDCClient().ID,
+		"--scopes=" + strings.Join([]string{coreosoidc.ScopeOfflineAccess, coreosoidc.ScopeOpenID, "pinniped:request-audience"}, ","),
+		"--request-audience=" + cluster.Audience,
+	}
+	if cluster.ConciergeAuthenticatorName != "" {
+		args = append(args,
+			"--enable-concierge",
+			"--concierge-api-group-suffix="+cluster.ConciergeAPIGroupSuffix,
+			"--concierge-authenticator-name="+cluster.ConciergeAuthenticatorName,
+			"--concierge-authenticator-type=jwt",
+			"--concierge-endpoint="+cluster.Server,
+			"--concierge-ca-bundle-data="+base64.StdEncoding.EncodeToString(cluster.CertificateAuthorityData),
+		)
+	}
+
+	return &clientcmdv1.Config{
+		Kind:       "Config",
+		APIVersion: clientcmdv1.SchemeGroupVersion.Version,
+		Clusters: []clientcmdv1.NamedCluster{{
+			Name: cluster.Name,
+			Cluster: clientcmdv1.Cluster{
+				Server:                   cluster.Server,
+				CertificateAuthorityData: cluster.CertificateAuthorityData,
+			},
+		}},
+		AuthInfos: []clientcmdv1.NamedAuthInfo{{
+			Name: cluster.Name,
+			AuthInfo: clientcmdv1.AuthInfo{
+				Exec: &clientcmdv1.ExecConfig{
+					APIVersion:         clientauthenticationv1beta1.SchemeGroupVersion.String(),
+					Command:            command,
+					Args:               args,
+					Env:                []clientcmdv1.ExecEnvVar{},
+					InstallHint:        installHints[platform],
+					ProvideClusterInfo: true,
+				},
+			},
+		}},
+		Contexts: []clientcmdv1.NamedContext{{
+			Name:    cluster.Name,
+			Context: clientcmdv1.Context{Cluster: cluster.Name, AuthInfo: cluster.Name},
+		}},
+		CurrentContext: cluster.Name,
+	}
+}
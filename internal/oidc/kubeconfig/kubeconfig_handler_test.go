@@ -0,0 +1,263 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubeconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/token/jwt"
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/provider"
+)
+
+type fakeIntrospector map[string]*openid.DefaultSession
+
+func (f fakeIntrospector) IntrospectToken(_ context.Context, token string, _ fosite.TokenUse, _ fosite.Session, _ ...string) (fosite.TokenUse, fosite.AccessRequester, error) {
+	session, ok := f[token]
+	if !ok {
+		return "", nil, fosite.ErrRequestUnauthorized
+	}
+	return fosite.AccessToken, &fosite.AccessRequest{Request: fosite.Request{Session: session}}, nil
+}
+
+func sessionWithGroups(groups ...string) *openid.DefaultSession {
+	return &openid.DefaultSession{
+		Subject: "some-subject",
+		Claims:  &jwt.IDTokenClaims{Extra: map[string]interface{}{oidc.DownstreamGroupsClaim: groups}},
+	}
+}
+
+func TestKubeconfigHandler(t *testing.T) {
+	const issuer = "https://some-issuer.com/some/path"
+
+	clusters := []provider.Cluster{
+		{
+			Name:          "dev",
+			Audience:      "dev-audience",
+			Server:        "https://dev.example.com",
+			AllowedGroups: []string{"developers", "admins"},
+		},
+		{
+			Name:                       "prod",
+			Audience:                   "prod-audience",
+			Server:                     "https://prod.example.com",
+			CertificateAuthorityData:   []byte("some-ca"),
+			ConciergeAuthenticatorName: "supervisor",
+			ConciergeAPIGroupSuffix:    "pinniped.dev",
+			AllowedGroups:              []string{"admins"},
+		},
+	}
+
+	introspector := fakeIntrospector{
+		"developer-token": sessionWithGroups("developers"),
+		"admin-token":     sessionWithGroups("admins"),
+		"bound-token": {
+			Claims: &jwt.IDTokenClaims{Extra: map[string]interface{}{
+				oidc.ConfirmationClaim: map[string]interface{}{"x5t#S256": "some-thumbprint"},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name string
+
+		method    string
+		path      string
+		token     string
+		userAgent string
+
+		wantStatus          int
+		wantWWWAuthenticate string
+		wantBody            string
+		wantContentType     string
+		wantFilename        string
+	}{
+		{
+			name:       "bad method",
+			method:     http.MethodPost,
+			path:       "/kubeconfig?cluster=dev",
+			token:      "developer-token",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   "Method Not Allowed: POST (try GET)\n",
+		},
+		{
+			name:                "missing bearer token",
+			path:                "/kubeconfig?cluster=dev",
+			wantStatus:          http.StatusUnauthorized,
+			wantWWWAuthenticate: `Bearer realm="https://some-issuer.com/some/path"`,
+			wantBody:            "Unauthorized: missing bearer token\n",
+		},
+		{
+			name:                "invalid bearer token",
+			path:                "/kubeconfig?cluster=dev",
+			token:               "wrong-token",
+			wantStatus:          http.StatusUnauthorized,
+			wantWWWAuthenticate: `Bearer realm="https://some-issuer.com/some/path"`,
+			wantBody:            "Unauthorized: invalid bearer token\n",
+		},
+		{
+			name:                "bearer token bound to a client certificate which was not presented",
+			path:                "/kubeconfig?cluster=dev",
+			token:               "bound-token",
+			wantStatus:          http.StatusUnauthorized,
+			wantWWWAuthenticate: `Bearer realm="https://some-issuer.com/some/path"`,
+			wantBody:            "Unauthorized: bearer token is bound to a different client certificate\n",
+		},
+		{
+			name:       "missing cluster",
+			path:       "/kubeconfig",
+			token:      "developer-token",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: missing cluster parameter\n",
+		},
+		{
+			name:       "unknown cluster",
+			path:       "/kubeconfig?cluster=staging",
+			token:      "developer-token",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "Not Found: no such cluster \"staging\"\n",
+		},
+		{
+			name:       "user is not allowed to access the cluster",
+			path:       "/kubeconfig?cluster=prod",
+			token:      "developer-token",
+			wantStatus: http.StatusForbidden,
+			wantBody:   "Forbidden: not allowed to access cluster \"prod\"\n",
+		},
+		{
+			name:       "unsupported os",
+			path:       "/kubeconfig?cluster=dev&os=plan9",
+			token:      "developer-token",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: unsupported os parameter \"plan9\" (use darwin, linux, or windows)\n",
+		},
+		{
+			name:            "os guessed from the user agent",
+			path:            "/kubeconfig?cluster=dev",
+			token:           "developer-token",
+			userAgent:       "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko)",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/yaml",
+			wantFilename:    `attachment; filename="dev-kubeconfig.yaml"`,
+			wantBody: here.Doc(`
+				apiVersion: v1
+				clusters:
+				- cluster:
+				    server: https://dev.example.com
+				  name: dev
+				contexts:
+				- context:
+				    cluster: dev
+				    user: dev
+				  name: dev
+				current-context: dev
+				kind: Config
+				preferences: {}
+				users:
+				- name: dev
+				  user:
+				    exec:
+				      apiVersion: client.authentication.k8s.io/v1beta1
+				      args:
+				      - login
+				      - oidc
+				      - --issuer=https://some-issuer.com/some/path
+				      - --client-id=pinniped-cli
+				      - --scopes=offline_access,openid,pinniped:request-audience
+				      - --request-audience=dev-audience
+				      command: pinniped
+				      env: []
+				      installHint: 'The Pinniped CLI is required to authenticate to this cluster.
+				        Install it with: brew install vmware-tanzu/pinniped/pinniped-cli'
+				      provideClusterInfo: true
+			`),
+		},
+		{
+			name:            "windows cluster with the concierge",
+			path:            "/kubeconfig?cluster=prod&os=windows",
+			token:           "admin-token",
+			userAgent:       "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7)",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/yaml",
+			wantFilename:    `attachment; filename="prod-kubeconfig.yaml"`,
+			wantBody: here.Doc(`
+				apiVersion: v1
+				clusters:
+				- cluster:
+				    certificate-authority-data: c29tZS1jYQ==
+				    server: https://prod.example.com
+				  name: prod
+				contexts:
+				- context:
+				    cluster: prod
+				    user: prod
+				  name: prod
+				current-context: prod
+				kind: Config
+				preferences: {}
+				users:
+				- name: prod
+				  user:
+				    exec:
+				      apiVersion: client.authentication.k8s.io/v1beta1
+				      args:
+				      - login
+				      - oidc
+				      - --issuer=https://some-issuer.com/some/path
+				      - --client-id=pinniped-cli
+				      - --scopes=offline_access,openid,pinniped:request-audience
+				      - --request-audience=prod-audience
+				      - --enable-concierge
+				      - --concierge-api-group-suffix=pinniped.dev
+				      - --concierge-authenticator-name=supervisor
+				      - --concierge-authenticator-type=jwt
+				      - --concierge-endpoint=https://prod.example.com
+				      - --concierge-ca-bundle-data=c29tZS1jYQ==
+				      command: pinniped.exe
+				      env: []
+				      installHint: The Pinniped CLI is required to authenticate to this cluster. Download
+				        pinniped-cli-windows-amd64.exe from https://github.com/vmware-tanzu/pinniped/releases/latest
+				        and install it as "pinniped.exe" in your PATH.
+				      provideClusterInfo: true
+			`),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			handler := NewHandler(issuer, clusters, introspector)
+
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, issuer+test.path, nil)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			if test.userAgent != "" {
+				req.Header.Set("User-Agent", test.userAgent)
+			}
+			rsp := httptest.NewRecorder()
+			handler.ServeHTTP(rsp, req)
+
+			require.Equal(t, test.wantStatus, rsp.Code)
+			require.Equal(t, test.wantBody, rsp.Body.String())
+			require.Equal(t, test.wantWWWAuthenticate, rsp.Header().Get("WWW-Authenticate"))
+			if test.wantStatus == http.StatusOK {
+				require.Equal(t, test.wantContentType, rsp.Header().Get("Content-Type"))
+				require.Equal(t, test.wantFilename, rsp.Header().Get("Content-Disposition"))
+				require.Equal(t, "no-store", rsp.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package loginapproval holds the logins of new users to a FederationDomain until an administrator approves them.
+package loginapproval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	configv1alpha1client "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/typed/config/v1alpha1"
+	configlisters "go.pinniped.dev/generated/latest/client/supervisor/listers/config/v1alpha1"
+	"go.pinniped.dev/internal/plog"
+)
+
+// FederationDomainNameLabel is added to each UserApproval so that administrators can select the UserApprovals of
+// one FederationDomain.
+const FederationDomainNameLabel = "supervisor.pinniped.dev/federation-domain-name"
+
+// Decision is the outcome of checking whether a user may log in.
+type Decision string
+
+const (
+	Approved Decision = "Approved"
+	Denied   Decision = "Denied"
+	Pending  Decision = "Pending"
+)
+
+// Approver decides whether users may log in to one FederationDomain.
+type Approver interface {
+	Check(ctx context.Context, subject, username string) (Decision, error)
+}
+
+// Store reads and creates the UserApprovals in the Supervisor's namespace.
+type Store struct {
+	client configv1alpha1client.UserApprovalInterface
+	lister configlisters.UserApprovalNamespaceLister
+	labels map[string]string
+}
+
+// NewStore returns a Store. The labels are added to each UserApproval which is created.
+func NewStore(
+	client configv1alpha1client.UserApprovalInterface,
+	lister configlisters.UserApprovalNamespaceLister,
+	labels map[string]string,
+) *Store {
+	return &Store{client: client, lister: lister, labels: labels}
+}
+
+// ForFederationDomain returns an Approver for the named FederationDomain.
+func (s *Store) ForFederationDomain(federationDomainName string) Approver {
+	return &approver{store: s, federationDomainName: federationDomainName}
+}
+
+type approver struct {
+	store                *Store
+	federationDomainName string
+}
+
+// Check returns the decision for the user's login. The first time that a user logs in, a UserApproval without
+// a decision is created for an administrator to approve or deny, and the login is pending until then.
+func (a *approver) Check(ctx context.Context, subject, username string) (Decision, error) {
+	name := userApprovalName(a.federationDomainName, subject)
+
+	userApproval, err := a.store.lister.Get(name)
+	switch {
+	case k8serrors.IsNotFound(err):
+		if err := a.create(ctx, name, subject, username); err != nil {
+			return "", err
+		}
+		return Pending, nil
+	case err != nil:
+		return "", fmt.Errorf("could not get UserApproval %q: %w", name, err)
+	}
+
+	// Guard against hash collisions, however unlikely.
+	if userApproval.Spec.FederationDomainName != a.federationDomainName || userApproval.Spec.Subject != subject {
+		return Denied, fmt.Errorf("UserApproval %q is for a different user", name)
+	}
+
+	switch userApproval.Spec.Decision {
+	case configv1alpha1.ApprovedUserApprovalDecision:
+		return Approved, nil
+	case configv1alpha1.DeniedUserApprovalDecision:
+		return Denied, nil
+	default:
+		return Pending, nil
+	}
+}
+
+func (a *approver) create(ctx context.Context, name, subject, username string) error {
+	labels := map[string]string{}
+	for k, v := range a.store.labels {
+		labels[k] = v
+	}
+	labels[FederationDomainNameLabel] = a.federationDomainName
+
+	_, err := a.store.client.Create(ctx, &configv1alpha1.UserApproval{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: configv1alpha1.UserApprovalSpec{
+			FederationDomainName: a.federationDomainName,
+			Subject:              subject,
+			Username:             username,
+		},
+	}, metav1.CreateOptions{})
+	if k8serrors.IsAlreadyExists(err) {
+		// Another login created it first, or the lister has not seen it yet.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not create UserApproval %q: %w", name, err)
+	}
+
+	plog.Info("created UserApproval for the first login of a new user",
+		"userApproval", name,
+		"federationDomain", a.federationDomainName,
+		"subject", subject,
+		"username", username,
+	)
+	return nil
+}
+
+// userApprovalName derives a valid object name from the FederationDomain name and the subject, which may contain
+// characters that are not allowed in names.
+func userApprovalName(federationDomainName, subject string) string {
+	hash := sha256.Sum256([]byte(federationDomainName + "\x00" + subject))
+	return "user-" + hex.EncodeToString(hash[:16])
+}
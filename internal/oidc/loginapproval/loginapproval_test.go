@@ -0,0 +1,151 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package loginapproval
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubetesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	configlisters "go.pinniped.dev/generated/latest/client/supervisor/listers/config/v1alpha1"
+)
+
+func TestCheck(t *testing.T) {
+	const (
+		namespace            = "some-namespace"
+		federationDomainName = "some-federation-domain"
+		subject              = "https://some-upstream-issuer?sub=some-subject"
+		username             = "some-username"
+	)
+	name := userApprovalName(federationDomainName, subject)
+
+	newUserApproval := func(federationDomainName, subject string, decision configv1alpha1.UserApprovalDecision) *configv1alpha1.UserApproval {
+		return &configv1alpha1.UserApproval{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: configv1alpha1.UserApprovalSpec{
+				FederationDomainName: federationDomainName,
+				Subject:              subject,
+				Username:             username,
+				Decision:             decision,
+			},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		userApproval     *configv1alpha1.UserApproval
+		createError      error
+		wantDecision     Decision
+		wantErr          string
+		wantUserApproval *configv1alpha1.UserApproval
+	}{
+		{
+			name:         "first login creates a UserApproval and is pending",
+			wantDecision: Pending,
+			wantUserApproval: &configv1alpha1.UserApproval{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+					Labels: map[string]string{
+						"some-label":              "some-value",
+						FederationDomainNameLabel: federationDomainName,
+					},
+				},
+				Spec: configv1alpha1.UserApprovalSpec{
+					FederationDomainName: federationDomainName,
+					Subject:              subject,
+					Username:             username,
+				},
+			},
+		},
+		{
+			name:         "UserApproval was already created but the lister has not seen it yet",
+			createError:  k8serrors.NewAlreadyExists(configv1alpha1.Resource("userapprovals"), name),
+			wantDecision: Pending,
+		},
+		{
+			name:        "UserApproval cannot be created",
+			createError: errors.New("some create error"),
+			wantErr:     `could not create UserApproval "` + name + `": some create error`,
+		},
+		{
+			name:         "UserApproval without a decision",
+			userApproval: newUserApproval(federationDomainName, subject, ""),
+			wantDecision: Pending,
+		},
+		{
+			name:         "approved UserApproval",
+			userApproval: newUserApproval(federationDomainName, subject, configv1alpha1.ApprovedUserApprovalDecision),
+			wantDecision: Approved,
+		},
+		{
+			name:         "denied UserApproval",
+			userApproval: newUserApproval(federationDomainName, subject, configv1alpha1.DeniedUserApprovalDecision),
+			wantDecision: Denied,
+		},
+		{
+			name:         "approved UserApproval for a different subject",
+			userApproval: newUserApproval(federationDomainName, "some-other-subject", configv1alpha1.ApprovedUserApprovalDecision),
+			wantDecision: Denied,
+			wantErr:      `UserApproval "` + name + `" is for a different user`,
+		},
+		{
+			name:         "approved UserApproval for a different FederationDomain",
+			userApproval: newUserApproval("some-other-federation-domain", subject, configv1alpha1.ApprovedUserApprovalDecision),
+			wantDecision: Denied,
+			wantErr:      `UserApproval "` + name + `" is for a different user`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := pinnipedfake.NewSimpleClientset()
+			if tt.createError != nil {
+				client.PrependReactor("create", "userapprovals", func(_ kubetesting.Action) (bool, runtime.Object, error) {
+					return true, nil, tt.createError
+				})
+			}
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.userApproval != nil {
+				require.NoError(t, indexer.Add(tt.userApproval))
+			}
+			store := NewStore(
+				client.ConfigV1alpha1().UserApprovals(namespace),
+				configlisters.NewUserApprovalLister(indexer).UserApprovals(namespace),
+				map[string]string{"some-label": "some-value"},
+			)
+
+			decision, err := store.ForFederationDomain(federationDomainName).Check(context.Background(), subject, username)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantDecision, decision)
+
+			if tt.wantUserApproval != nil {
+				actual, err := client.ConfigV1alpha1().UserApprovals(namespace).Get(context.Background(), name, metav1.GetOptions{})
+				require.NoError(t, err)
+				require.Equal(t, tt.wantUserApproval, actual)
+			}
+		})
+	}
+}
+
+func TestUserApprovalName(t *testing.T) {
+	name := userApprovalName("some-federation-domain", "https://some-upstream-issuer?sub=some-subject")
+	require.Len(t, name, len("user-")+32)
+	require.Equal(t, name, userApprovalName("some-federation-domain", "https://some-upstream-issuer?sub=some-subject"))
+	require.NotEqual(t, name, userApprovalName("some-other-federation-domain", "https://some-upstream-issuer?sub=some-subject"))
+	require.NotEqual(t, name, userApprovalName("some-federation-domain", "https://some-upstream-issuer?sub=some-other-subject"))
+}
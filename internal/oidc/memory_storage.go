@@ -0,0 +1,77 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+
+	"go.pinniped.dev/internal/fositestorage/deviceauthorization"
+)
+
+// MemoryStorage is a fosite storage which keeps all sessions in memory instead of in Secrets, so they will be lost
+// when the process exits and will not be shared between replicas. Sessions are never garbage collected.
+type MemoryStorage struct {
+	*storage.MemoryStore
+
+	mu                   sync.Mutex
+	deviceAuthorizations map[string]deviceauthorization.Session
+}
+
+var _ deviceauthorization.Storage = &MemoryStorage{}
+
+// NewMemoryStorage returns a MemoryStorage which knows about the same pre-defined OAuth client as KubeStorage.
+//
+// This is only suitable for local development (see the Supervisor's --dev flag).
+func NewMemoryStorage() *MemoryStorage {
+	store := storage.NewMemoryStore()
+	client := PinnipedCLIOIDCClient()
+	store.Clients[client.ID] = client
+	return &MemoryStorage{
+		MemoryStore:          store,
+		deviceAuthorizations: make(map[string]deviceauthorization.Session),
+	}
+}
+
+func (m *MemoryStorage) CreateDeviceAuthorization(_ context.Context, userCode string, session *deviceauthorization.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deviceAuthorizations[userCode] = *session
+	return nil
+}
+
+func (m *MemoryStorage) GetDeviceAuthorization(_ context.Context, userCode string) (*deviceauthorization.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.deviceAuthorizations[userCode]
+	if !ok {
+		return nil, fosite.ErrNotFound
+	}
+	return &session, nil
+}
+
+func (m *MemoryStorage) ApproveDeviceAuthorization(_ context.Context, userCode string, authcode string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.deviceAuthorizations[userCode]
+	if !ok {
+		return fosite.ErrNotFound
+	}
+	if session.Approved() {
+		return deviceauthorization.ErrAlreadyApproved
+	}
+	session.Authcode = authcode
+	m.deviceAuthorizations[userCode] = session
+	return nil
+}
+
+func (m *MemoryStorage) DeleteDeviceAuthorization(_ context.Context, userCode string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.deviceAuthorizations, userCode)
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ory/fosite"
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/fositestorage/deviceauthorization"
+)
+
+func TestMemoryStorage_GetClient(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	client, err := storage.GetClient(context.Background(), "some-other-client")
+	require.Equal(t, fosite.ErrNotFound, err)
+	require.Zero(t, client)
+
+	client, err = storage.GetClient(context.Background(), "pinniped-cli")
+	require.NoError(t, err)
+	require.Equal(t, PinnipedCLIOIDCClient(), client)
+}
+
+func TestMemoryStorage_DeviceAuthorizations(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	_, err := storage.GetDeviceAuthorization(ctx, "BCDFGHJK")
+	require.Equal(t, fosite.ErrNotFound, err)
+	require.Equal(t, fosite.ErrNotFound, storage.ApproveDeviceAuthorization(ctx, "BCDFGHJK", "some-authcode"))
+
+	require.NoError(t, storage.CreateDeviceAuthorization(ctx, "BCDFGHJK", &deviceauthorization.Session{ClientID: "pinniped-cli"}))
+	session, err := storage.GetDeviceAuthorization(ctx, "BCDFGHJK")
+	require.NoError(t, err)
+	require.False(t, session.Approved())
+
+	require.NoError(t, storage.ApproveDeviceAuthorization(ctx, "BCDFGHJK", "some-authcode"))
+	require.Equal(t, deviceauthorization.ErrAlreadyApproved, storage.ApproveDeviceAuthorization(ctx, "BCDFGHJK", "other-authcode"))
+	session, err = storage.GetDeviceAuthorization(ctx, "BCDFGHJK")
+	require.NoError(t, err)
+	require.Equal(t, "some-authcode", session.Authcode)
+
+	require.NoError(t, storage.DeleteDeviceAuthorization(ctx, "BCDFGHJK"))
+	_, err = storage.GetDeviceAuthorization(ctx, "BCDFGHJK")
+	require.Equal(t, fosite.ErrNotFound, err)
+}
@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidc
@@ -25,9 +25,9 @@ func TestNullStorage_GetClient(t *testing.T) {
 			DefaultClient: &fosite.DefaultClient{
 				ID:            "pinniped-cli",
 				Public:        true,
-				RedirectURIs:  []string{"http://127.0.0.1/callback"},
+				RedirectURIs:  []string{"http://127.0.0.1/callback", "urn:ietf:wg:oauth:2.0:oob"},
 				ResponseTypes: []string{"code"},
-				GrantTypes:    []string{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange"},
+				GrantTypes:    []string{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange", "urn:ietf:params:oauth:grant-type:device_code"},
 				Scopes:        []string{"openid", "offline_access", "profile", "email", "pinniped:request-audience"},
 			},
 			TokenEndpointAuthMethod: "none",
@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package oidc contains common OIDC functionality needed by Pinniped.
@@ -10,6 +10,8 @@ import (
 	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/token/jwt"
 
 	"go.pinniped.dev/internal/oidc/csrftoken"
 	"go.pinniped.dev/internal/oidc/jwks"
@@ -24,6 +26,18 @@ const (
 	TokenEndpointPath         = "/oauth2/token" //nolint:gosec // ignore lint warning that this is a credential
 	CallbackEndpointPath      = "/callback"
 	JWKSEndpointPath          = "/jwks.json"
+	KubeconfigEndpointPath    = "/kubeconfig"
+	ClustersEndpointPath      = "/clusters"
+	RevocationEndpointPath    = "/oauth2/revoke"
+	RevokedUsersEndpointPath  = "/revoked-users"
+	VersionEndpointPath       = "/version"
+
+	DeviceAuthorizationEndpointPath = "/oauth2/device_authorization"
+	DeviceVerificationEndpointPath  = "/oauth2/device"
+
+	// ManualCodeRedirectURI is the out-of-band redirect_uri of the logins in which the user copies the authcode from
+	// the callback page into the CLI, e.g. when the browser runs on another machine than the CLI.
+	ManualCodeRedirectURI = "urn:ietf:wg:oauth:2.0:oob"
 )
 
 const (
@@ -51,6 +65,9 @@ const (
 	// The name of the subject claim specified in the OIDC spec.
 	IDTokenSubjectClaim = "sub"
 
+	// The name of the authorization code hash claim specified in the OIDC spec.
+	IDTokenCodeHashClaim = "c_hash"
+
 	// DownstreamUsernameClaim is a custom claim in the downstream ID token
 	// whose value is mapped from a claim in the upstream token.
 	// By default the value is the same as the downstream subject claim's.
@@ -60,12 +77,39 @@ const (
 	// information.
 	DownstreamGroupsClaim = "groups"
 
+	// DownstreamUIDClaim is a custom claim in the downstream ID token whose value is a stable, opaque
+	// identifier derived from a claim in the upstream token. By default it is derived from the upstream
+	// issuer and subject.
+	DownstreamUIDClaim = "uid"
+
+	// DownstreamGrantedGroupsClaim is a custom claim in the downstream ID token which lists the groups that were
+	// added to the groups claim by a GroupGrant, rather than coming from the upstream identity provider. It is
+	// omitted when there are no such groups.
+	DownstreamGrantedGroupsClaim = "granted_groups"
+
 	// CSRFCookieLifespan is the length of time that the CSRF cookie is valid. After this time, the
 	// Supervisor's authorization endpoint should give the browser a new CSRF cookie. We set it to
 	// a week so that it is unlikely to expire during a login.
 	CSRFCookieLifespan = time.Hour * 24 * 7
 )
 
+const (
+	// UpstreamIDPNameParamName is an optional authorize request parameter which names the upstream identity provider
+	// of the login. It is required when more than one upstream identity provider is configured.
+	UpstreamIDPNameParamName = "pinniped_idp_name"
+
+	// UpstreamIDPTypeParamName is an optional authorize request parameter which names the type of the upstream
+	// identity provider of the login, i.e. UpstreamIDPTypeOIDC or UpstreamIDPTypeStaticAdmin.
+	UpstreamIDPTypeParamName = "pinniped_idp_type"
+
+	// UpstreamIDPTypeOIDC is the type of the upstream OIDCIdentityProviders.
+	UpstreamIDPTypeOIDC = "oidc"
+
+	// UpstreamIDPTypeStaticAdmin is the type of the static admin identity provider. Username/password logins only use
+	// it when a client selects it explicitly, so that the credentials of other users are never checked against it.
+	UpstreamIDPTypeStaticAdmin = "static-admin"
+)
+
 // Encoder is the encoding side of the securecookie.Codec interface.
 type Encoder interface {
 	Encode(name string, value interface{}) (string, error)
@@ -102,9 +146,9 @@ func PinnipedCLIOIDCClient() *fosite.DefaultOpenIDConnectClient {
 		DefaultClient: &fosite.DefaultClient{
 			ID:            "pinniped-cli",
 			Public:        true,
-			RedirectURIs:  []string{"http://127.0.0.1/callback"},
+			RedirectURIs:  []string{"http://127.0.0.1/callback", ManualCodeRedirectURI},
 			ResponseTypes: []string{"code"},
-			GrantTypes:    []string{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange"},
+			GrantTypes:    []string{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange", DeviceCodeGrantType},
 			Scopes:        []string{coreosoidc.ScopeOpenID, coreosoidc.ScopeOfflineAccess, "profile", "email", "pinniped:request-audience"},
 		},
 		TokenEndpointAuthMethod: "none",
@@ -140,6 +184,10 @@ type TimeoutsConfiguration struct {
 	// in their web browser.
 	RefreshTokenLifespan time.Duration
 
+	// How long a device code issued by the device authorization endpoint is valid. This determines how much time
+	// the end user has to visit the verification page and log in, before the device has to start over.
+	DeviceCodeLifespan time.Duration
+
 	// AuthorizationCodeSessionStorageLifetime is the length of time after which an authcode is allowed to be garbage
 	// collected from storage. Authcodes are kept in storage after they are redeemed to allow the system to mark the
 	// authcode as already used, so it can reject any future uses of the same authcode with special case handling which
@@ -182,6 +230,12 @@ type TimeoutsConfiguration struct {
 	// when the token does not exist. If this is desirable, then the RefreshTokenSessionStorageLifetime can be made
 	// to be significantly larger than RefreshTokenLifespan, at the cost of slower cleanup.
 	RefreshTokenSessionStorageLifetime time.Duration
+
+	// DeviceAuthorizationSessionStorageLifetime is the length of time after which a device authorization is allowed
+	// to be garbage collected from storage. They are deleted when the device redeems its device code, and they are
+	// not needed anymore after the device code has expired, so this can be just slightly longer than the
+	// DeviceCodeLifespan.
+	DeviceAuthorizationSessionStorageLifetime time.Duration
 }
 
 // Get the defaults for the Supervisor server.
@@ -189,18 +243,21 @@ func DefaultOIDCTimeoutsConfiguration() TimeoutsConfiguration {
 	accessTokenLifespan := 15 * time.Minute
 	authorizationCodeLifespan := 10 * time.Minute
 	refreshTokenLifespan := 9 * time.Hour
+	deviceCodeLifespan := 15 * time.Minute
 
 	return TimeoutsConfiguration{
-		UpstreamStateParamLifespan:              90 * time.Minute,
-		AuthorizeCodeLifespan:                   authorizationCodeLifespan,
-		AccessTokenLifespan:                     accessTokenLifespan,
-		IDTokenLifespan:                         accessTokenLifespan,
-		RefreshTokenLifespan:                    refreshTokenLifespan,
-		AuthorizationCodeSessionStorageLifetime: authorizationCodeLifespan + refreshTokenLifespan,
-		PKCESessionStorageLifetime:              authorizationCodeLifespan + (1 * time.Minute),
-		OIDCSessionStorageLifetime:              authorizationCodeLifespan + (1 * time.Minute),
-		AccessTokenSessionStorageLifetime:       accessTokenLifespan + (1 * time.Minute),
-		RefreshTokenSessionStorageLifetime:      refreshTokenLifespan + accessTokenLifespan,
+		UpstreamStateParamLifespan:                90 * time.Minute,
+		AuthorizeCodeLifespan:                     authorizationCodeLifespan,
+		AccessTokenLifespan:                       accessTokenLifespan,
+		IDTokenLifespan:                           accessTokenLifespan,
+		RefreshTokenLifespan:                      refreshTokenLifespan,
+		DeviceCodeLifespan:                        deviceCodeLifespan,
+		AuthorizationCodeSessionStorageLifetime:   authorizationCodeLifespan + refreshTokenLifespan,
+		PKCESessionStorageLifetime:                authorizationCodeLifespan + (1 * time.Minute),
+		OIDCSessionStorageLifetime:                authorizationCodeLifespan + (1 * time.Minute),
+		AccessTokenSessionStorageLifetime:         accessTokenLifespan + (1 * time.Minute),
+		RefreshTokenSessionStorageLifetime:        refreshTokenLifespan + accessTokenLifespan,
+		DeviceAuthorizationSessionStorageLifetime: deviceCodeLifespan + (1 * time.Minute),
 	}
 }
 
@@ -243,12 +300,15 @@ func FositeOauth2Helper(
 			CoreStrategy:               newDynamicOauth2HMACStrategy(oauthConfig, hmacSecretOfLengthAtLeast32Func),
 			OpenIDConnectTokenStrategy: newDynamicOpenIDConnectECDSAStrategy(oauthConfig, jwksProvider),
 		},
-		nil, // hasher, defaults to using BCrypt when nil. Used for hashing client secrets.
+		nil,               // hasher, defaults to using BCrypt when nil. Used for hashing client secrets.
+		DeviceCodeFactory, // must come before the authorization code factories, see DeviceCodeHandler
 		compose.OAuth2AuthorizeExplicitFactory,
 		compose.OAuth2RefreshTokenGrantFactory,
 		compose.OpenIDConnectExplicitFactory,
 		compose.OpenIDConnectRefreshFactory,
 		compose.OAuth2PKCEFactory,
+		compose.OAuth2TokenIntrospectionFactory,
+		compose.OAuth2TokenRevocationFactory,
 		TokenExchangeFactory,
 	)
 }
@@ -292,3 +352,25 @@ func ScopeWasRequested(authorizeRequester fosite.AuthorizeRequester, scopeName s
 	}
 	return false
 }
+
+// MakeDownstreamSession creates the session for a downstream user, whose ID tokens will contain the custom
+// username, groups, and uid claims.
+func MakeDownstreamSession(subject string, username string, groups []string, uid string) *openid.DefaultSession {
+	now := time.Now().UTC()
+	openIDSession := &openid.DefaultSession{
+		Claims: &jwt.IDTokenClaims{
+			Subject:     subject,
+			RequestedAt: now,
+			AuthTime:    now,
+		},
+	}
+	if groups == nil {
+		groups = []string{}
+	}
+	openIDSession.Claims.Extra = map[string]interface{}{
+		DownstreamUsernameClaim: username,
+		DownstreamGroupsClaim:   groups,
+		DownstreamUIDClaim:      uid,
+	}
+	return openIDSession
+}
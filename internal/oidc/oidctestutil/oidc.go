@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidctestutil
@@ -17,6 +17,7 @@ import (
 	"gopkg.in/square/go-jose.v2"
 
 	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/oidc/usernametemplate"
 	"go.pinniped.dev/pkg/oidcclient/nonce"
 	"go.pinniped.dev/pkg/oidcclient/oidctypes"
 	"go.pinniped.dev/pkg/oidcclient/pkce"
@@ -39,17 +40,27 @@ type TestUpstreamOIDCIdentityProvider struct {
 	ClientID                              string
 	AuthorizationURL                      url.URL
 	UsernameClaim                         string
+	UsernameTemplate                      *usernametemplate.Template
 	GroupsClaim                           string
+	UIDClaim                              string
 	Scopes                                []string
+	AllowPasswordGrant                    bool
+	ValidateIssuerParameterFunc           func(iss string) error
 	ExchangeAuthcodeAndValidateTokensFunc func(
 		ctx context.Context,
 		authcode string,
 		pkceCodeVerifier pkce.Code,
 		expectedIDTokenNonce nonce.Nonce,
 	) (*oidctypes.Token, error)
+	PasswordCredentialsGrantAndValidateTokensFunc func(
+		ctx context.Context,
+		username string,
+		password string,
+	) (*oidctypes.Token, error)
 
 	exchangeAuthcodeAndValidateTokensCallCount int
 	exchangeAuthcodeAndValidateTokensArgs      []*ExchangeAuthcodeAndValidateTokenArgs
+	passwordCredentialsGrantCallCount          int
 }
 
 func (u *TestUpstreamOIDCIdentityProvider) GetName() string {
@@ -72,10 +83,25 @@ func (u *TestUpstreamOIDCIdentityProvider) GetUsernameClaim() string {
 	return u.UsernameClaim
 }
 
+func (u *TestUpstreamOIDCIdentityProvider) GetUsernameTemplate() *usernametemplate.Template {
+	return u.UsernameTemplate
+}
+
 func (u *TestUpstreamOIDCIdentityProvider) GetGroupsClaim() string {
 	return u.GroupsClaim
 }
 
+func (u *TestUpstreamOIDCIdentityProvider) GetUIDClaim() string {
+	return u.UIDClaim
+}
+
+func (u *TestUpstreamOIDCIdentityProvider) ValidateIssuerParameter(iss string) error {
+	if u.ValidateIssuerParameterFunc == nil {
+		return nil
+	}
+	return u.ValidateIssuerParameterFunc(iss)
+}
+
 func (u *TestUpstreamOIDCIdentityProvider) ExchangeAuthcodeAndValidateTokens(
 	ctx context.Context,
 	authcode string,
@@ -108,6 +134,23 @@ func (u *TestUpstreamOIDCIdentityProvider) ExchangeAuthcodeAndValidateTokensArgs
 	return u.exchangeAuthcodeAndValidateTokensArgs[call]
 }
 
+func (u *TestUpstreamOIDCIdentityProvider) AllowsPasswordGrant() bool {
+	return u.AllowPasswordGrant
+}
+
+func (u *TestUpstreamOIDCIdentityProvider) PasswordCredentialsGrantAndValidateTokens(
+	ctx context.Context,
+	username string,
+	password string,
+) (*oidctypes.Token, error) {
+	u.passwordCredentialsGrantCallCount++
+	return u.PasswordCredentialsGrantAndValidateTokensFunc(ctx, username, password)
+}
+
+func (u *TestUpstreamOIDCIdentityProvider) PasswordCredentialsGrantAndValidateTokensCallCount() int {
+	return u.passwordCredentialsGrantCallCount
+}
+
 func (u *TestUpstreamOIDCIdentityProvider) ValidateToken(_ context.Context, _ *oauth2.Token, _ nonce.Nonce) (*oidctypes.Token, error) {
 	panic("implement me")
 }
@@ -1,11 +1,13 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package provider
 
 import (
 	"crypto/tls"
+	"sort"
 	"sync"
+	"time"
 )
 
 type DynamicTLSCertProvider interface {
@@ -13,6 +15,45 @@ type DynamicTLSCertProvider interface {
 	SetDefaultTLSCert(certificate *tls.Certificate)
 	GetTLSCert(lowercaseIssuerHostName string) *tls.Certificate
 	GetDefaultTLSCert() *tls.Certificate
+	GetTLSCertForServerName(lowercaseServerName string) *tls.Certificate
+}
+
+// SelectTLSCert chooses which certificate to serve for the SNI server name of a TLS handshake, which is empty when
+// the client connected using an IP address. It prefers the certificate configured for an issuer host which exactly
+// matches the server name. Otherwise it will use the certificate of any issuer host which is valid for the server name
+// (e.g. a wildcard certificate, or one which lists several DNS names), and finally the default certificate.
+//
+// Certificates can only be matched by their names when their Leaf has been parsed.
+func SelectTLSCert(
+	lowercaseServerName string,
+	issuerHostToTLSCertMap map[string]*tls.Certificate,
+	defaultCert *tls.Certificate,
+	now time.Time,
+) *tls.Certificate {
+	if lowercaseServerName == "" {
+		return defaultCert
+	}
+	if cert := issuerHostToTLSCertMap[lowercaseServerName]; cert != nil {
+		return cert
+	}
+
+	// Sort to make the choice deterministic when several certificates would be valid.
+	hosts := make([]string, 0, len(issuerHostToTLSCertMap))
+	for host := range issuerHostToTLSCertMap {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		leaf := issuerHostToTLSCertMap[host].Leaf
+		if leaf == nil || now.After(leaf.NotAfter) || now.Before(leaf.NotBefore) {
+			continue
+		}
+		if leaf.VerifyHostname(lowercaseServerName) == nil {
+			return issuerHostToTLSCertMap[host]
+		}
+	}
+
+	return defaultCert
 }
 
 type dynamicTLSCertProvider struct {
@@ -50,3 +91,9 @@ func (p *dynamicTLSCertProvider) GetDefaultTLSCert() *tls.Certificate {
 	defer p.mutex.RUnlock()
 	return p.defaultCert
 }
+
+func (p *dynamicTLSCertProvider) GetTLSCertForServerName(lowercaseServerName string) *tls.Certificate {
+	p.mutex.RLock() // acquire a read lock
+	defer p.mutex.RUnlock()
+	return SelectTLSCert(lowercaseServerName, p.issuerHostToTLSCertMap, p.defaultCert, time.Now())
+}
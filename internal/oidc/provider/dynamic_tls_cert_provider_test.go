@@ -0,0 +1,139 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/certauthority"
+)
+
+func TestSelectTLSCert(t *testing.T) {
+	ca, err := certauthority.New(pkix.Name{CommonName: "test-ca"}, time.Hour)
+	require.NoError(t, err)
+	issue := func(dnsNames ...string) *tls.Certificate {
+		cert, err := ca.Issue(pkix.Name{CommonName: "test-cert"}, dnsNames, nil, time.Hour)
+		require.NoError(t, err)
+		return cert
+	}
+
+	exactCert := issue("exact.example.com")
+	wildcardCert := issue("*.wildcard.example.com")
+	otherWildcardCert := issue("*.wildcard.example.com")
+	unparsedCert := issue("*.unparsed.example.com")
+	unparsedCert.Leaf = nil
+	defaultCert := issue("default.example.com")
+
+	certs := map[string]*tls.Certificate{
+		"exact.example.com":      exactCert,
+		"a.wildcard.example.com": wildcardCert,
+		"b.wildcard.example.com": otherWildcardCert,
+		"a.unparsed.example.com": unparsedCert,
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		serverName  string
+		certs       map[string]*tls.Certificate
+		defaultCert *tls.Certificate
+		now         time.Time
+		want        *tls.Certificate
+	}{
+		{
+			name:        "exact match",
+			serverName:  "exact.example.com",
+			certs:       certs,
+			defaultCert: defaultCert,
+			now:         now,
+			want:        exactCert,
+		},
+		{
+			name:        "exact match is preferred over name match",
+			serverName:  "b.wildcard.example.com",
+			certs:       certs,
+			defaultCert: defaultCert,
+			now:         now,
+			want:        otherWildcardCert,
+		},
+		{
+			name:        "name match uses the first matching issuer host",
+			serverName:  "c.wildcard.example.com",
+			certs:       certs,
+			defaultCert: defaultCert,
+			now:         now,
+			want:        wildcardCert,
+		},
+		{
+			name:        "name match ignores expired certs",
+			serverName:  "c.wildcard.example.com",
+			certs:       certs,
+			defaultCert: defaultCert,
+			now:         now.Add(2 * time.Hour),
+			want:        defaultCert,
+		},
+		{
+			name:        "name match ignores certs without a parsed leaf",
+			serverName:  "b.unparsed.example.com",
+			certs:       certs,
+			defaultCert: defaultCert,
+			now:         now,
+			want:        defaultCert,
+		},
+		{
+			name:        "no match falls back to default",
+			serverName:  "localhost",
+			certs:       certs,
+			defaultCert: defaultCert,
+			now:         now,
+			want:        defaultCert,
+		},
+		{
+			name:        "no server name uses default",
+			serverName:  "",
+			certs:       certs,
+			defaultCert: defaultCert,
+			now:         now,
+			want:        defaultCert,
+		},
+		{
+			name:       "no match and no default",
+			serverName: "localhost",
+			certs:      certs,
+			now:        now,
+			want:       nil,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Same(t, tt.want, SelectTLSCert(tt.serverName, tt.certs, tt.defaultCert, tt.now))
+		})
+	}
+}
+
+func TestDynamicTLSCertProvider(t *testing.T) {
+	ca, err := certauthority.New(pkix.Name{CommonName: "test-ca"}, time.Hour)
+	require.NoError(t, err)
+	cert, err := ca.Issue(pkix.Name{CommonName: "test-cert"}, []string{"*.example.com"}, nil, time.Hour)
+	require.NoError(t, err)
+	defaultCert, err := ca.Issue(pkix.Name{CommonName: "test-default-cert"}, []string{"default.example.com"}, nil, time.Hour)
+	require.NoError(t, err)
+
+	p := NewDynamicTLSCertProvider()
+	require.Nil(t, p.GetTLSCertForServerName("a.example.com"))
+
+	p.SetIssuerHostToTLSCertMap(map[string]*tls.Certificate{"issuer.example.com": cert})
+	p.SetDefaultTLSCert(defaultCert)
+	require.Same(t, cert, p.GetTLSCert("issuer.example.com"))
+	require.Nil(t, p.GetTLSCert("a.example.com"))
+	require.Same(t, cert, p.GetTLSCertForServerName("a.example.com"))
+	require.Same(t, defaultCert, p.GetTLSCertForServerName("localhost"))
+	require.Same(t, defaultCert, p.GetDefaultTLSCert())
+}
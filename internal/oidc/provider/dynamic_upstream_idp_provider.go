@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package provider
@@ -10,6 +10,7 @@ import (
 
 	"golang.org/x/oauth2"
 
+	"go.pinniped.dev/internal/oidc/usernametemplate"
 	"go.pinniped.dev/pkg/oidcclient/nonce"
 	"go.pinniped.dev/pkg/oidcclient/oidctypes"
 	"go.pinniped.dev/pkg/oidcclient/pkce"
@@ -32,9 +33,20 @@ type UpstreamOIDCIdentityProviderI interface {
 	// ID Token username claim name. May return empty string, in which case we will use some reasonable defaults.
 	GetUsernameClaim() string
 
+	// ID Token username template, which is used instead of the username claim when it is not nil.
+	GetUsernameTemplate() *usernametemplate.Template
+
+	// ID Token claim name from which the stable UID will be derived. May return empty string, in which case the
+	// "sub" claim will be used.
+	GetUIDClaim() string
+
 	// ID Token groups claim name. May return empty string, in which case we won't try to read groups from the upstream provider.
 	GetGroupsClaim() string
 
+	// Validates the "iss" parameter of an authorization response from the upstream provider, which may be empty when
+	// the upstream provider does not send it.
+	ValidateIssuerParameter(iss string) error
+
 	// Performs upstream OIDC authorization code exchange and token validation.
 	// Returns the validated raw tokens as well as the parsed claims of the ID token.
 	ExchangeAuthcodeAndValidateTokens(
@@ -46,6 +58,14 @@ type UpstreamOIDCIdentityProviderI interface {
 	) (*oidctypes.Token, error)
 
 	ValidateToken(ctx context.Context, tok *oauth2.Token, expectedIDTokenNonce nonce.Nonce) (*oidctypes.Token, error)
+
+	// Whether non-interactive clients may log in with a username and password using the resource owner password
+	// credentials grant of the upstream provider.
+	AllowsPasswordGrant() bool
+
+	// Performs the upstream resource owner password credentials grant and token validation.
+	// Returns the validated raw tokens as well as the parsed claims of the ID token.
+	PasswordCredentialsGrantAndValidateTokens(ctx context.Context, username, password string) (*oidctypes.Token, error)
 }
 
 type DynamicUpstreamIDPProvider interface {
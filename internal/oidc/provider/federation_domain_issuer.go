@@ -1,10 +1,11 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package provider
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"strings"
 
@@ -17,18 +18,74 @@ type FederationDomainIssuer struct {
 	issuer     string
 	issuerHost string
 	issuerPath string
+
+	// name is the name of the FederationDomain, which may be empty in tests.
+	name                  string
+	loginApprovalRequired bool
+	groupsClaim           DownstreamGroupsClaim
+	clusters              []Cluster
+}
+
+// DownstreamGroupsClaim configures an additional claim of the downstream ID tokens which contains the user's groups,
+// for clients which do not read the "groups" claim. The zero value means that there is no additional claim.
+type DownstreamGroupsClaim struct {
+	Name           string
+	SpaceDelimited bool
+}
+
+// Cluster is a Kubernetes cluster which accepts the tokens of a FederationDomain for its audience.
+type Cluster struct {
+	Name     string
+	Audience string
+	Server   string
+
+	// CertificateAuthorityData is the PEM bundle which the kubeconfig trusts to serve the cluster, if any.
+	CertificateAuthorityData []byte
+
+	// ConciergeAuthenticatorName is the name of the Concierge's JWTAuthenticator for the cluster, or empty when the
+	// cluster does not use the Concierge.
+	ConciergeAuthenticatorName string
+	ConciergeAPIGroupSuffix    string
+
+	// AllowedGroups are the downstream groups whose members may access the cluster. Empty allows everyone.
+	AllowedGroups []string
+}
+
+// Allows returns true when a user who belongs to the groups may access the cluster.
+func (c *Cluster) Allows(groups []string) bool {
+	if len(c.AllowedGroups) == 0 {
+		return true
+	}
+	for _, allowed := range c.AllowedGroups {
+		for _, group := range groups {
+			if group == allowed {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func NewFederationDomainIssuer(issuer string) (*FederationDomainIssuer, error) {
+	return newFederationDomainIssuer(issuer, false)
+}
+
+// NewDevFederationDomainIssuer is like NewFederationDomainIssuer, except that it also accepts issuers with an "http"
+// scheme when their host is localhost or a loopback IP address. It must only be used in the Supervisor's --dev mode.
+func NewDevFederationDomainIssuer(issuer string) (*FederationDomainIssuer, error) {
+	return newFederationDomainIssuer(issuer, true)
+}
+
+func newFederationDomainIssuer(issuer string, allowLoopbackHTTP bool) (*FederationDomainIssuer, error) {
 	p := FederationDomainIssuer{issuer: issuer}
-	err := p.validate()
+	err := p.validate(allowLoopbackHTTP)
 	if err != nil {
 		return nil, err
 	}
 	return &p, nil
 }
 
-func (p *FederationDomainIssuer) validate() error {
+func (p *FederationDomainIssuer) validate(allowLoopbackHTTP bool) error {
 	if p.issuer == "" {
 		return constable.Error("federation domain must have an issuer")
 	}
@@ -38,7 +95,7 @@ func (p *FederationDomainIssuer) validate() error {
 		return fmt.Errorf("could not parse issuer as URL: %w", err)
 	}
 
-	if issuerURL.Scheme != "https" {
+	if issuerURL.Scheme != "https" && !(allowLoopbackHTTP && issuerURL.Scheme == "http" && isLoopbackHost(issuerURL.Hostname())) {
 		return constable.Error(`issuer must have "https" scheme`)
 	}
 
@@ -64,6 +121,14 @@ func (p *FederationDomainIssuer) validate() error {
 	return nil
 }
 
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func (p *FederationDomainIssuer) Issuer() string {
 	return p.issuer
 }
@@ -75,3 +140,38 @@ func (p *FederationDomainIssuer) IssuerHost() string {
 func (p *FederationDomainIssuer) IssuerPath() string {
 	return p.issuerPath
 }
+
+func (p *FederationDomainIssuer) Name() string {
+	return p.name
+}
+
+func (p *FederationDomainIssuer) SetName(name string) {
+	p.name = name
+}
+
+// LoginApprovalRequired returns true when the first login of each user must be approved by an administrator.
+func (p *FederationDomainIssuer) LoginApprovalRequired() bool {
+	return p.loginApprovalRequired
+}
+
+func (p *FederationDomainIssuer) SetLoginApprovalRequired(required bool) {
+	p.loginApprovalRequired = required
+}
+
+// GroupsClaim returns the configuration of the additional groups claim of the ID tokens issued by this issuer.
+func (p *FederationDomainIssuer) GroupsClaim() DownstreamGroupsClaim {
+	return p.groupsClaim
+}
+
+func (p *FederationDomainIssuer) SetGroupsClaim(groupsClaim DownstreamGroupsClaim) {
+	p.groupsClaim = groupsClaim
+}
+
+// Clusters returns the Kubernetes clusters which accept the tokens issued by this issuer.
+func (p *FederationDomainIssuer) Clusters() []Cluster {
+	return p.clusters
+}
+
+func (p *FederationDomainIssuer) SetClusters(clusters []Cluster) {
+	p.clusters = clusters
+}
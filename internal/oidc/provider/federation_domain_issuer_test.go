@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package provider
@@ -81,3 +81,99 @@ func TestFederationDomainIssuerValidations(t *testing.T) {
 		})
 	}
 }
+
+func TestDevFederationDomainIssuerValidations(t *testing.T) {
+	tests := []struct {
+		name      string
+		issuer    string
+		wantError string
+	}{
+		{
+			name:   "https",
+			issuer: "https://tuna.com/fish",
+		},
+		{
+			name:   "http localhost",
+			issuer: "http://localhost:8080/fish",
+		},
+		{
+			name:   "http loopback IPv4 address",
+			issuer: "http://127.0.0.1:8080/fish",
+		},
+		{
+			name:   "http loopback IPv6 address",
+			issuer: "http://[::1]:8080",
+		},
+		{
+			name:      "http non-loopback hostname",
+			issuer:    "http://tuna.com",
+			wantError: `issuer must have "https" scheme`,
+		},
+		{
+			name:      "http non-loopback IP address",
+			issuer:    "http://10.0.0.1",
+			wantError: `issuer must have "https" scheme`,
+		},
+		{
+			name:      "http localhost subdomain",
+			issuer:    "http://localhost.tuna.com",
+			wantError: `issuer must have "https" scheme`,
+		},
+		{
+			name:      "other validations still apply",
+			issuer:    "http://127.0.0.1/",
+			wantError: `issuer must not have trailing slash in path`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDevFederationDomainIssuer(tt.issuer)
+			if tt.wantError != "" {
+				require.EqualError(t, err, tt.wantError)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestClusterAllows(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowedGroups []string
+		groups        []string
+		want          bool
+	}{
+		{
+			name:   "no allowed groups allows everyone",
+			groups: nil,
+			want:   true,
+		},
+		{
+			name:          "member of one of the allowed groups",
+			allowedGroups: []string{"admins", "developers"},
+			groups:        []string{"everyone", "developers"},
+			want:          true,
+		},
+		{
+			name:          "not a member of any allowed group",
+			allowedGroups: []string{"admins", "developers"},
+			groups:        []string{"everyone"},
+			want:          false,
+		},
+		{
+			name:          "no groups",
+			allowedGroups: []string{"admins"},
+			groups:        nil,
+			want:          false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &Cluster{Name: "some-cluster", AllowedGroups: tt.allowedGroups}
+			require.Equal(t, tt.want, cluster.Allows(tt.groups))
+		})
+	}
+}
@@ -1,9 +1,10 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package manager
 
 import (
+	"context"
 	"net/http"
 	"strings"
 	"sync"
@@ -14,52 +15,101 @@ import (
 
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/fositestorage/deviceauthorization"
+	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/oidc"
 	"go.pinniped.dev/internal/oidc/auth"
 	"go.pinniped.dev/internal/oidc/callback"
 	"go.pinniped.dev/internal/oidc/csrftoken"
+	"go.pinniped.dev/internal/oidc/device"
 	"go.pinniped.dev/internal/oidc/discovery"
+	"go.pinniped.dev/internal/oidc/groupgrant"
 	"go.pinniped.dev/internal/oidc/jwks"
+	"go.pinniped.dev/internal/oidc/kubeconfig"
+	"go.pinniped.dev/internal/oidc/loginapproval"
 	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/oidc/revoke"
+	"go.pinniped.dev/internal/oidc/staticadmin"
 	"go.pinniped.dev/internal/oidc/token"
 	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/revocationlist"
+	"go.pinniped.dev/internal/versioninfo"
 	"go.pinniped.dev/pkg/oidcclient/nonce"
 	"go.pinniped.dev/pkg/oidcclient/pkce"
+	"go.pinniped.dev/pkg/oidcclient/state"
 )
 
+// ErrKeysNotLoaded is returned by Manager.Ready while the keys which are shared by all FederationDomains are not loaded.
+const ErrKeysNotLoaded = constable.Error("the CSRF cookie key is not loaded yet")
+
 // Manager can manage multiple active OIDC providers. It acts as a request router for them.
 //
 // It is thread-safe.
 type Manager struct {
 	mu                  sync.RWMutex
 	providers           []*provider.FederationDomainIssuer
-	providerHandlers    map[string]http.Handler  // map of all routes for all providers
-	nextHandler         http.Handler             // the next handler in a chain, called when this manager didn't know how to handle a request
-	dynamicJWKSProvider jwks.DynamicJWKSProvider // in-memory cache of per-issuer JWKS data
-	idpListGetter       oidc.IDPListGetter       // in-memory cache of upstream IDPs
-	secretCache         *secret.Cache            // in-memory cache of cryptographic material
+	providerHandlers    map[string]http.Handler       // map of all routes for all providers
+	nextHandler         http.Handler                  // the next handler in a chain, called when this manager didn't know how to handle a request
+	dynamicJWKSProvider jwks.DynamicJWKSProvider      // in-memory cache of per-issuer JWKS data
+	idpListGetter       oidc.IDPListGetter            // in-memory cache of upstream IDPs
+	staticAdminIDP      *staticadmin.IdentityProvider // nil unless the static admin identity provider is enabled
+	groupGrants         *groupgrant.Applier           // adds groups from active GroupGrants to issued tokens
+	loginApprovals      *loginapproval.Store          // used by the FederationDomains which require login approval
+	secretCache         *secret.Cache                 // in-memory cache of cryptographic material
+	loadKeys            KeyLoaderFunc                 // nil when the keys are only loaded by the controllers
 	secretsClient       corev1client.SecretInterface
+	memoryStorage       *oidc.MemoryStorage    // only used when secretsClient is nil
+	revocations         *revocationlist.Lister // nil when secretsClient is nil
 }
 
+// KeyLoaderFunc puts the keys of the FederationDomain with the issuer which are missing from the secret cache into it,
+// e.g. by reading them from the API server.
+type KeyLoaderFunc func(ctx context.Context, issuer string) error
+
 // NewManager returns an empty Manager.
 // nextHandler will be invoked for any requests that could not be handled by this manager's providers.
 // dynamicJWKSProvider will be used as an in-memory cache for per-issuer JWKS data.
 // idpListGetter will be used as an in-memory cache of currently configured upstream IDPs.
+// staticAdminIDP, when non-nil, allows the built-in admin user to log in without any upstream IDP.
+// groupGrants, when non-nil, will be used to add the groups from active GroupGrants to issued tokens.
+// loginApprovals must be non-nil when any FederationDomain requires login approval.
+// secretCache holds the keys of the FederationDomains, and loadKeys, when non-nil, is used to fill it when a request
+// needs keys which the controllers have not put into it yet.
+// secretsClient will be used to store OAuth sessions. When it is nil, sessions are kept in memory instead,
+// which is only suitable for local development.
+// revocations lists the users whose tokens were revoked, and it may only be nil when secretsClient is nil.
 func NewManager(
 	nextHandler http.Handler,
 	dynamicJWKSProvider jwks.DynamicJWKSProvider,
 	idpListGetter oidc.IDPListGetter,
+	staticAdminIDP *staticadmin.IdentityProvider,
+	groupGrants *groupgrant.Applier,
+	loginApprovals *loginapproval.Store,
 	secretCache *secret.Cache,
+	loadKeys KeyLoaderFunc,
 	secretsClient corev1client.SecretInterface,
+	revocations *revocationlist.Lister,
 ) *Manager {
-	return &Manager{
+	m := &Manager{
 		providerHandlers:    make(map[string]http.Handler),
 		nextHandler:         nextHandler,
 		dynamicJWKSProvider: dynamicJWKSProvider,
 		idpListGetter:       idpListGetter,
+		staticAdminIDP:      staticAdminIDP,
+		groupGrants:         groupGrants,
+		loginApprovals:      loginApprovals,
 		secretCache:         secretCache,
+		loadKeys:            loadKeys,
 		secretsClient:       secretsClient,
+		revocations:         revocations,
+	}
+	if secretsClient == nil {
+		// Share one store across all providers and all calls to SetProviders so that sessions survive
+		// changes to the FederationDomains.
+		m.memoryStorage = oidc.NewMemoryStorage()
 	}
+	return m
 }
 
 // SetProviders adds or updates all the given providerHandlers using each provider's issuer string
@@ -83,6 +133,13 @@ func (m *Manager) SetProviders(federationDomains ...*provider.FederationDomainIs
 		func() []byte { return nil },
 	)
 
+	// The downloads session holds the issuer of the session, so all the FederationDomains of a host can share the cookie.
+	var downloadsSessionCodec = dynamiccodec.New(
+		oidc.DownloadsSessionLifespan,
+		m.secretCache.GetCSRFCookieEncoderHashKey,
+		func() []byte { return nil },
+	)
+
 	for _, incomingProvider := range federationDomains {
 		issuer := incomingProvider.Issuer()
 		issuerHostWithPath := strings.ToLower(incomingProvider.IssuerHost()) + "/" + incomingProvider.IssuerPath()
@@ -96,7 +153,12 @@ func (m *Manager) SetProviders(federationDomains ...*provider.FederationDomainIs
 		oauthHelperWithNullStorage := oidc.FositeOauth2Helper(oidc.NullStorage{}, issuer, tokenHMACKeyGetter, nil, timeoutsConfiguration)
 
 		// For all the other endpoints, make another oauth helper with exactly the same settings except use real storage.
-		oauthHelperWithKubeStorage := oidc.FositeOauth2Helper(oidc.NewKubeStorage(m.secretsClient, timeoutsConfiguration), issuer, tokenHMACKeyGetter, m.dynamicJWKSProvider, timeoutsConfiguration)
+		// The same storage also keeps the device authorizations, so the device code grant handler can find them.
+		var oauthStore deviceauthorization.Storage = oidc.NewKubeStorage(m.secretsClient, timeoutsConfiguration)
+		if m.memoryStorage != nil {
+			oauthStore = m.memoryStorage
+		}
+		oauthHelperWithRealStorage := oidc.FositeOauth2Helper(oauthStore, issuer, tokenHMACKeyGetter, m.dynamicJWKSProvider, timeoutsConfiguration)
 
 		var upstreamStateEncoder = dynamiccodec.New(
 			timeoutsConfiguration.UpstreamStateParamLifespan,
@@ -108,29 +170,94 @@ func (m *Manager) SetProviders(federationDomains ...*provider.FederationDomainIs
 
 		m.providerHandlers[(issuerHostWithPath + oidc.JWKSEndpointPath)] = jwks.NewHandler(issuer, m.dynamicJWKSProvider)
 
-		m.providerHandlers[(issuerHostWithPath + oidc.AuthorizationEndpointPath)] = auth.NewHandler(
+		var loginApprover loginapproval.Approver
+		if incomingProvider.LoginApprovalRequired() {
+			loginApprover = m.loginApprovals.ForFederationDomain(incomingProvider.Name())
+		}
+
+		m.providerHandlers[(issuerHostWithPath + oidc.AuthorizationEndpointPath)] = m.requireKeys(issuer, auth.NewHandler(
 			issuer,
 			m.idpListGetter,
+			m.staticAdminIDP,
+			loginApprover,
 			oauthHelperWithNullStorage,
+			oauthHelperWithRealStorage,
 			csrftoken.Generate,
 			pkce.Generate,
 			nonce.Generate,
 			upstreamStateEncoder,
 			csrfCookieEncoder,
-		)
+		))
 
-		m.providerHandlers[(issuerHostWithPath + oidc.CallbackEndpointPath)] = callback.NewHandler(
+		m.providerHandlers[(issuerHostWithPath + oidc.CallbackEndpointPath)] = m.requireKeys(issuer, callback.NewHandler(
 			m.idpListGetter,
-			oauthHelperWithKubeStorage,
+			loginApprover,
+			oauthHelperWithRealStorage,
+			oauthStore,
 			upstreamStateEncoder,
 			csrfCookieEncoder,
 			issuer+oidc.CallbackEndpointPath,
-		)
+			issuer,
+			downloadsSessionCodec,
+		))
+
+		var groupGrants *groupgrant.Applier
+		if m.groupGrants != nil {
+			groupGrants = m.groupGrants.ForFederationDomain(incomingProvider.Name())
+		}
+
+		m.providerHandlers[(issuerHostWithPath + oidc.TokenEndpointPath)] = m.requireKeys(issuer, token.NewHandler(
+			oauthHelperWithRealStorage,
+			groupGrants,
+			incomingProvider.GroupsClaim(),
+		))
 
-		m.providerHandlers[(issuerHostWithPath + oidc.TokenEndpointPath)] = token.NewHandler(
-			oauthHelperWithKubeStorage,
+		m.providerHandlers[(issuerHostWithPath + oidc.DeviceAuthorizationEndpointPath)] = device.NewAuthorizationHandler(
+			issuer,
+			oauthStore,
+			pkce.Generate,
+			timeoutsConfiguration.DeviceCodeLifespan,
 		)
 
+		m.providerHandlers[(issuerHostWithPath + oidc.DeviceVerificationEndpointPath)] = m.requireKeys(issuer, device.NewVerificationHandler(
+			issuer,
+			oauthStore,
+			csrftoken.Generate,
+			state.Generate,
+			csrfCookieEncoder,
+		))
+
+		m.providerHandlers[(issuerHostWithPath + oidc.RevocationEndpointPath)] = m.requireKeys(issuer, revoke.NewHandler(oauthHelperWithRealStorage))
+
+		m.providerHandlers[(issuerHostWithPath + oidc.RevokedUsersEndpointPath)] = revocationlist.NewHandler(issuer, m.revocations)
+
+		m.providerHandlers[(issuerHostWithPath + oidc.VersionEndpointPath)] = versioninfo.NewHandler()
+
+		m.providerHandlers[(issuerHostWithPath + oidc.ClustersEndpointPath)] = m.requireKeys(issuer, kubeconfig.NewClustersHandler(
+			issuer,
+			incomingProvider.Clusters(),
+			oauthHelperWithRealStorage,
+		))
+
+		m.providerHandlers[(issuerHostWithPath + oidc.KubeconfigEndpointPath)] = m.requireKeys(issuer, kubeconfig.NewHandler(
+			issuer,
+			incomingProvider.Clusters(),
+			oauthHelperWithRealStorage,
+		))
+
+		m.providerHandlers[(issuerHostWithPath + oidc.DownloadsEndpointPath)] = m.requireKeys(issuer, kubeconfig.NewDownloadsHandler(
+			issuer,
+			incomingProvider.Clusters(),
+			groupGrants,
+			state.Generate,
+			pkce.Generate,
+			downloadsSessionCodec,
+		))
+
+		for path, endpoint := range endpointMetricNames {
+			m.providerHandlers[issuerHostWithPath+path] = instrument(endpoint, m.providerHandlers[issuerHostWithPath+path])
+		}
+
 		plog.Debug("oidc provider manager added or updated issuer", "issuer", issuer)
 	}
 }
@@ -160,6 +287,48 @@ func (m *Manager) findHandler(req *http.Request) http.Handler {
 	return m.providerHandlers[strings.ToLower(req.Host)+"/"+req.URL.Path]
 }
 
+// Ready returns an error while this replica cannot serve any login, because it has not loaded the CSRF cookie key
+// which all FederationDomains share. The keys of a single FederationDomain do not make the replica unready: every
+// replica misses them for a moment after the FederationDomain was created, and all of them becoming unready would
+// also interrupt the logins of the other FederationDomains. Requests which need those keys get a 503 response from
+// requireKeys instead.
+func (m *Manager) Ready() error {
+	if m.secretCache.GetCSRFCookieEncoderHashKey() == nil {
+		return ErrKeysNotLoaded
+	}
+	return nil
+}
+
+// requireKeys makes sure that this replica has the keys of the issuer before the handler serves a request. All replicas
+// read the keys from the same Secrets, but the controllers of each one only cache them once its own informer has seen
+// them, so the callback of a login which was started on another replica, e.g. in another zone, may arrive first. Then
+// the keys are loaded from the API server instead. When that is not possible either, e.g. because the keys have not
+// been generated yet, the response is 503 Service Unavailable, which clients and load balancers can retry, while the
+// state or token of the request would otherwise be rejected as invalid.
+func (m *Manager) requireKeys(issuer string, handler http.Handler) http.Handler {
+	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if !m.hasKeys(issuer) && m.loadKeys != nil {
+			if err := m.loadKeys(r.Context(), issuer); err != nil {
+				plog.WarningErr("oidc provider manager could not load the keys of the issuer", err, "issuer", issuer)
+			}
+		}
+		if !m.hasKeys(issuer) {
+			plog.Debug("oidc provider manager has not loaded the keys of the issuer yet", "issuer", issuer, "path", r.URL.Path)
+			w.Header().Set("Retry-After", "1")
+			return httperr.New(http.StatusServiceUnavailable, "the keys of this issuer are not loaded yet, please try again")
+		}
+		handler.ServeHTTP(w, r)
+		return nil
+	})
+}
+
+func (m *Manager) hasKeys(issuer string) bool {
+	return m.secretCache.GetCSRFCookieEncoderHashKey() != nil &&
+		m.secretCache.GetTokenHMACKey(issuer) != nil &&
+		m.secretCache.GetStateEncoderHashKey(issuer) != nil &&
+		m.secretCache.GetStateEncoderBlockKey(issuer) != nil
+}
+
 func wrapGetter(issuer string, getter func(string) []byte) func() []byte {
 	return func() []byte {
 		return getter(issuer)
@@ -6,7 +6,10 @@ package manager
 import (
 	"context"
 	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -154,7 +157,7 @@ func TestManager(t *testing.T) {
 			return actualLocationQueryParams.Get("code")
 		}
 
-		requireTokenRequestToBeHandled := func(requestIssuer, authCode string, jwks *jose.JSONWebKeySet, jwkIssuer string) {
+		requireTokenRequestToBeHandled := func(requestIssuer, authCode string, jwks *jose.JSONWebKeySet, jwkIssuer string) string {
 			recorder := httptest.NewRecorder()
 
 			numberOfKubeActionsBeforeThisRequest := len(kubeClient.Actions())
@@ -191,6 +194,75 @@ func TestManager(t *testing.T) {
 			// Make sure that we wired up the callback endpoint to use kube storage for fosite sessions.
 			r.Equal(len(kubeClient.Actions()), numberOfKubeActionsBeforeThisRequest+8,
 				"did not perform any kube actions during the callback request, but should have")
+
+			accessToken, ok := body["access_token"].(string)
+			r.True(ok, "wanted access_token type to be string, but was %T", body["access_token"])
+			return accessToken
+		}
+
+		requireKubeconfigRequestToBeHandled := func(requestIssuer, accessToken string, wantStatus int, wantIssuerArg string) {
+			recorder := httptest.NewRecorder()
+
+			request := newGetRequest(requestIssuer + oidc.KubeconfigEndpointPath + "?cluster=some-cluster&os=linux")
+			request.Header.Set("Authorization", "Bearer "+accessToken)
+			subject.ServeHTTP(recorder, request)
+
+			r.False(fallbackHandlerWasCalled)
+
+			// Minimal check to ensure that the right endpoint was called and that it validated the access token
+			// with the storage and the HMAC key of the right issuer.
+			r.Equal(wantStatus, recorder.Code, recorder.Body.String())
+			if wantStatus == http.StatusOK {
+				r.Contains(recorder.Body.String(), "--issuer="+wantIssuerArg)
+			}
+		}
+
+		requireClustersRequestToBeHandled := func(requestIssuer, accessToken string, wantStatus int) {
+			recorder := httptest.NewRecorder()
+
+			request := newGetRequest(requestIssuer + oidc.ClustersEndpointPath)
+			request.Header.Set("Authorization", "Bearer "+accessToken)
+			subject.ServeHTTP(recorder, request)
+
+			r.False(fallbackHandlerWasCalled)
+			r.Equal(wantStatus, recorder.Code, recorder.Body.String())
+			if wantStatus == http.StatusOK {
+				r.Contains(recorder.Body.String(), requestIssuer+oidc.KubeconfigEndpointPath+"?cluster=some-cluster")
+			}
+		}
+
+		requireRevocationRequestToBeHandled := func(requestIssuer, accessToken string, wantStatus int) {
+			recorder := httptest.NewRecorder()
+
+			revocationRequestBody := url.Values{
+				"client_id":       []string{downstreamClientID},
+				"token":           []string{accessToken},
+				"token_type_hint": []string{"access_token"},
+			}.Encode()
+			subject.ServeHTTP(recorder, newPostRequest(requestIssuer+oidc.RevocationEndpointPath, revocationRequestBody))
+
+			r.False(fallbackHandlerWasCalled)
+			r.Equal(wantStatus, recorder.Code, recorder.Body.String())
+		}
+
+		requireRevokedUsersRequestToBeHandled := func(requestIssuer string) {
+			recorder := httptest.NewRecorder()
+
+			subject.ServeHTTP(recorder, newGetRequest(requestIssuer+oidc.RevokedUsersEndpointPath))
+
+			r.False(fallbackHandlerWasCalled)
+			r.Equal(http.StatusOK, recorder.Code, recorder.Body.String())
+			r.JSONEq(`{"revocations":[]}`, recorder.Body.String())
+		}
+
+		requireVersionRequestToBeHandled := func(requestIssuer string) {
+			recorder := httptest.NewRecorder()
+
+			subject.ServeHTTP(recorder, newGetRequest(requestIssuer+oidc.VersionEndpointPath))
+
+			r.False(fallbackHandlerWasCalled)
+			r.Equal(http.StatusOK, recorder.Code, recorder.Body.String())
+			r.Contains(recorder.Body.String(), `"goVersion"`)
 		}
 
 		requireJWKSRequestToBeHandled := func(requestIssuer, requestURLSuffix, expectedJWKKeyID string) *jose.JSONWebKeySet {
@@ -254,7 +326,7 @@ func TestManager(t *testing.T) {
 			cache.SetStateEncoderHashKey(issuer2, []byte("some-state-encoder-hash-key-2"))
 			cache.SetStateEncoderBlockKey(issuer2, []byte("16-bytes-STATE02"))
 
-			subject = NewManager(nextHandler, dynamicJWKSProvider, idpListGetter, &cache, secretsClient)
+			subject = NewManager(nextHandler, dynamicJWKSProvider, idpListGetter, nil, nil, nil, &cache, nil, secretsClient, nil)
 		})
 
 		when("given no providers via SetProviders()", func() {
@@ -338,12 +410,31 @@ func TestManager(t *testing.T) {
 			downstreamAuthCode3 := requireCallbackRequestToBeHandled(issuer1DifferentCaseHostname, callbackRequestParams1, csrfCookieValue1)
 			downstreamAuthCode4 := requireCallbackRequestToBeHandled(issuer2DifferentCaseHostname, callbackRequestParams2, csrfCookieValue2)
 
-			requireTokenRequestToBeHandled(issuer1, downstreamAuthCode1, issuer1JWKS, issuer1)
-			requireTokenRequestToBeHandled(issuer2, downstreamAuthCode2, issuer2JWKS, issuer2)
+			accessToken1 := requireTokenRequestToBeHandled(issuer1, downstreamAuthCode1, issuer1JWKS, issuer1)
+			accessToken2 := requireTokenRequestToBeHandled(issuer2, downstreamAuthCode2, issuer2JWKS, issuer2)
 
 			// Hostnames are case-insensitive, so test that we can handle that.
 			requireTokenRequestToBeHandled(issuer1DifferentCaseHostname, downstreamAuthCode3, issuer1JWKS, issuer1)
 			requireTokenRequestToBeHandled(issuer2DifferentCaseHostname, downstreamAuthCode4, issuer2JWKS, issuer2)
+
+			requireKubeconfigRequestToBeHandled(issuer1, accessToken1, http.StatusOK, issuer1)
+			requireKubeconfigRequestToBeHandled(issuer2, accessToken2, http.StatusOK, issuer2)
+			requireKubeconfigRequestToBeHandled(issuer1, accessToken2, http.StatusUnauthorized, "")
+
+			requireClustersRequestToBeHandled(issuer1, accessToken1, http.StatusOK)
+			requireClustersRequestToBeHandled(issuer2, accessToken2, http.StatusOK)
+			requireClustersRequestToBeHandled(issuer2, accessToken1, http.StatusUnauthorized)
+
+			// Revoked tokens are not accepted anymore, while the tokens of the other issuer are unaffected.
+			requireRevocationRequestToBeHandled(issuer1, accessToken1, http.StatusOK)
+			requireKubeconfigRequestToBeHandled(issuer1, accessToken1, http.StatusUnauthorized, "")
+			requireKubeconfigRequestToBeHandled(issuer2, accessToken2, http.StatusOK, issuer2)
+
+			requireRevokedUsersRequestToBeHandled(issuer1)
+			requireRevokedUsersRequestToBeHandled(issuer2)
+
+			requireVersionRequestToBeHandled(issuer1)
+			requireVersionRequestToBeHandled(issuer2)
 		}
 
 		when("given some valid providers via SetProviders()", func() {
@@ -352,6 +443,9 @@ func TestManager(t *testing.T) {
 				r.NoError(err)
 				p2, err := provider.NewFederationDomainIssuer(issuer2)
 				r.NoError(err)
+				for _, p := range []*provider.FederationDomainIssuer{p1, p2} {
+					p.SetClusters([]provider.Cluster{{Name: "some-cluster", Audience: "some-audience", Server: "https://cluster.example.com"}})
+				}
 				subject.SetProviders(p1, p2)
 
 				jwksMap := map[string]*jose.JSONWebKeySet{
@@ -395,6 +489,9 @@ func TestManager(t *testing.T) {
 				r.NoError(err)
 				p2, err := provider.NewFederationDomainIssuer(issuer2)
 				r.NoError(err)
+				for _, p := range []*provider.FederationDomainIssuer{p1, p2} {
+					p.SetClusters([]provider.Cluster{{Name: "some-cluster", Audience: "some-audience", Server: "https://cluster.example.com"}})
+				}
 				subject.SetProviders(p2, p1)
 
 				jwksMap := map[string]*jose.JSONWebKeySet{
@@ -414,3 +511,145 @@ func TestManager(t *testing.T) {
 		})
 	})
 }
+
+// TestManagerAcrossReplicas simulates a login in which each request lands on another replica of the Supervisor, as
+// can happen when the replicas are spread across zones. The replicas only share the Secrets in which the sessions
+// and the keys are stored, and one of them has not loaded the keys yet when the callback arrives.
+func TestManagerAcrossReplicas(t *testing.T) {
+	const (
+		issuer                      = "https://example.com/some/path"
+		upstreamIDPAuthorizationURL = "https://test-upstream.com/auth"
+		downstreamClientID          = "pinniped-cli"
+		downstreamRedirectURL       = "http://127.0.0.1:12345/callback"
+		downstreamPKCECodeVerifier  = "some-pkce-verifier-that-must-be-at-least-43-characters-to-meet-entropy-requirements"
+	)
+
+	parsedUpstreamIDPAuthorizationURL, err := url.Parse(upstreamIDPAuthorizationURL)
+	require.NoError(t, err)
+	idpListGetter := oidctestutil.NewIDPListGetter(&oidctestutil.TestUpstreamOIDCIdentityProvider{
+		Name:             "test-idp",
+		ClientID:         "test-client-id",
+		AuthorizationURL: *parsedUpstreamIDPAuthorizationURL,
+		Scopes:           []string{"test-scope"},
+		ExchangeAuthcodeAndValidateTokensFunc: func(ctx context.Context, authcode string, pkceCodeVerifier pkce.Code, expectedIDTokenNonce nonce.Nonce) (*oidctypes.Token, error) {
+			return &oidctypes.Token{
+				IDToken: &oidctypes.IDToken{
+					Claims: map[string]interface{}{"iss": "https://some-issuer.com", "sub": "some-subject", "username": "test-username"},
+				},
+			}, nil
+		},
+	})
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwk := jose.JSONWebKey{Key: signingKey, KeyID: "some-key", Algorithm: "ES256", Use: "sig"}
+	dynamicJWKSProvider := jwks.NewDynamicJWKSProvider()
+	dynamicJWKSProvider.SetIssuerToJWKSMap(
+		map[string]*jose.JSONWebKeySet{issuer: {Keys: []jose.JSONWebKey{jwk.Public()}}},
+		map[string]*jose.JSONWebKey{issuer: &jwk},
+	)
+
+	loadKeys := func(cache *secret.Cache) {
+		cache.SetCSRFCookieEncoderHashKey([]byte("fake-csrf-hash-secret"))
+		cache.SetTokenHMACKey(issuer, []byte("some secret - must have at least 32 bytes"))
+		cache.SetStateEncoderHashKey(issuer, []byte("some-state-encoder-hash-key"))
+		cache.SetStateEncoderBlockKey(issuer, []byte("16-bytes-STATE01"))
+	}
+
+	secretsClient := fake.NewSimpleClientset().CoreV1().Secrets("some-namespace")
+	nextHandler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { t.Error("unexpected request to the next handler") })
+	federationDomain, err := provider.NewFederationDomainIssuer(issuer)
+	require.NoError(t, err)
+
+	cacheA, cacheB := &secret.Cache{}, &secret.Cache{}
+	loadKeys(cacheA)
+	replicaA := NewManager(nextHandler, dynamicJWKSProvider, idpListGetter, nil, nil, nil, cacheA, nil, secretsClient, nil)
+	replicaA.SetProviders(federationDomain)
+	replicaB := NewManager(nextHandler, dynamicJWKSProvider, idpListGetter, nil, nil, nil, cacheB, nil, secretsClient, nil)
+	replicaB.SetProviders(federationDomain)
+
+	require.NoError(t, replicaA.Ready())
+	require.Equal(t, ErrKeysNotLoaded, replicaB.Ready())
+
+	// The login starts on replica A.
+	authorizeRecorder := httptest.NewRecorder()
+	replicaA.ServeHTTP(authorizeRecorder, httptest.NewRequest(http.MethodGet, issuer+oidc.AuthorizationEndpointPath+"?"+url.Values{
+		"response_type":         []string{"code"},
+		"scope":                 []string{"openid"},
+		"client_id":             []string{downstreamClientID},
+		"state":                 []string{"some-state-value-with-enough-bytes-to-exceed-min-allowed"},
+		"nonce":                 []string{"some-nonce-value-with-enough-bytes-to-exceed-min-allowed"},
+		"code_challenge":        []string{testutil.SHA256(downstreamPKCECodeVerifier)},
+		"code_challenge_method": []string{"S256"},
+		"redirect_uri":          []string{downstreamRedirectURL},
+	}.Encode(), nil))
+	require.Equal(t, http.StatusFound, authorizeRecorder.Code, authorizeRecorder.Body.String())
+	upstreamLocation, err := url.Parse(authorizeRecorder.Header().Get("Location"))
+	require.NoError(t, err)
+	cookies := authorizeRecorder.Result().Cookies() //nolint:bodyclose
+	require.Len(t, cookies, 1)
+
+	newCallbackRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, issuer+oidc.CallbackEndpointPath+"?"+url.Values{
+			"code":  []string{"some-fake-code"},
+			"state": []string{upstreamLocation.Query().Get("state")},
+		}.Encode(), nil)
+		req.AddCookie(cookies[0])
+		return req
+	}
+
+	// The callback lands on replica B, which has not loaded the keys yet, so it asks the client to try again.
+	callbackRecorder := httptest.NewRecorder()
+	replicaB.ServeHTTP(callbackRecorder, newCallbackRequest())
+	require.Equal(t, http.StatusServiceUnavailable, callbackRecorder.Code)
+	require.Equal(t, "1", callbackRecorder.Header().Get("Retry-After"))
+	require.Equal(t, "Service Unavailable: the keys of this issuer are not loaded yet, please try again\n", callbackRecorder.Body.String())
+
+	// Once replica B has loaded the keys, it can finish the login which was started on replica A.
+	loadKeys(cacheB)
+	require.NoError(t, replicaB.Ready())
+	callbackRecorder = httptest.NewRecorder()
+	replicaB.ServeHTTP(callbackRecorder, newCallbackRequest())
+	require.Equal(t, http.StatusFound, callbackRecorder.Code, callbackRecorder.Body.String())
+	downstreamLocation, err := url.Parse(callbackRecorder.Header().Get("Location"))
+	require.NoError(t, err)
+	require.NotEmpty(t, downstreamLocation.Query().Get("code"))
+
+	// The authorization code which was stored by replica B is redeemed on replica A.
+	tokenRecorder := httptest.NewRecorder()
+	tokenRequest := httptest.NewRequest(http.MethodPost, issuer+oidc.TokenEndpointPath, strings.NewReader(url.Values{
+		"code":          []string{downstreamLocation.Query().Get("code")},
+		"client_id":     []string{downstreamClientID},
+		"redirect_uri":  []string{downstreamRedirectURL},
+		"code_verifier": []string{downstreamPKCECodeVerifier},
+		"grant_type":    []string{"authorization_code"},
+	}.Encode()))
+	tokenRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	replicaA.ServeHTTP(tokenRecorder, tokenRequest)
+	require.Equal(t, http.StatusOK, tokenRecorder.Code, tokenRecorder.Body.String())
+	require.Contains(t, tokenRecorder.Body.String(), "id_token")
+
+	// A replica which can load the keys itself, e.g. from the API, does so when a request needs them, instead of
+	// asking the client to try again.
+	cacheC := &secret.Cache{}
+	var loadedIssuers []string
+	replicaC := NewManager(nextHandler, dynamicJWKSProvider, idpListGetter, nil, nil, nil, cacheC, func(ctx context.Context, issuer string) error {
+		loadedIssuers = append(loadedIssuers, issuer)
+		loadKeys(cacheC)
+		return nil
+	}, secretsClient, nil)
+	replicaC.SetProviders(federationDomain)
+	callbackRecorder = httptest.NewRecorder()
+	replicaC.ServeHTTP(callbackRecorder, newCallbackRequest())
+	require.Equal(t, http.StatusFound, callbackRecorder.Code, callbackRecorder.Body.String())
+	require.Equal(t, []string{issuer}, loadedIssuers)
+
+	// When loading the keys fails, the replica still asks the client to try again.
+	replicaD := NewManager(nextHandler, dynamicJWKSProvider, idpListGetter, nil, nil, nil, &secret.Cache{}, func(ctx context.Context, issuer string) error {
+		return errors.New("some load error")
+	}, secretsClient, nil)
+	replicaD.SetProviders(federationDomain)
+	callbackRecorder = httptest.NewRecorder()
+	replicaD.ServeHTTP(callbackRecorder, newCallbackRequest())
+	require.Equal(t, http.StatusServiceUnavailable, callbackRecorder.Code)
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"go.pinniped.dev/internal/oidc"
+)
+
+// endpointMetricNames are the values of the "endpoint" label of the metrics of each endpoint of a FederationDomain.
+//nolint: gochecknoglobals
+var endpointMetricNames = map[string]string{
+	oidc.WellKnownEndpointPath:           "discovery",
+	oidc.JWKSEndpointPath:                "jwks",
+	oidc.AuthorizationEndpointPath:       "authorize",
+	oidc.CallbackEndpointPath:            "callback",
+	oidc.TokenEndpointPath:               "token",
+	oidc.DeviceAuthorizationEndpointPath: "device_authorization",
+	oidc.DeviceVerificationEndpointPath:  "device_verification",
+	oidc.RevocationEndpointPath:          "revoke",
+	oidc.RevokedUsersEndpointPath:        "revoked_users",
+	oidc.VersionEndpointPath:             "version",
+	oidc.ClustersEndpointPath:            "clusters",
+	oidc.KubeconfigEndpointPath:          "kubeconfig",
+	oidc.DownloadsEndpointPath:           "downloads",
+}
+
+// knownOAuthErrors are the error codes of RFC 6749, RFC 7009, RFC 8628, and OpenID Connect Core which are used as the
+// value of the "error" label. Any other error code is counted as "other", so that clients cannot create new series.
+//nolint: gochecknoglobals
+var knownOAuthErrors = map[string]bool{
+	"invalid_request":           true,
+	"invalid_client":            true,
+	"invalid_grant":             true,
+	"invalid_scope":             true,
+	"unauthorized_client":       true,
+	"unsupported_grant_type":    true,
+	"unsupported_response_type": true,
+	"unsupported_token_type":    true,
+	"access_denied":             true,
+	"server_error":              true,
+	"temporarily_unavailable":   true,
+	"authorization_pending":     true,
+	"slow_down":                 true,
+	"expired_token":             true,
+	"interaction_required":      true,
+	"login_required":            true,
+	"consent_required":          true,
+	"request_not_supported":     true,
+}
+
+//nolint: gochecknoglobals
+var (
+	oidcRequestsTotal = metrics.NewCounterVec(&metrics.CounterOpts{
+		Name:           "pinniped_supervisor_oidc_requests_total",
+		Help:           "Number of requests to the endpoints of all FederationDomains by endpoint and HTTP status code.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"endpoint", "code"})
+
+	oidcRequestDuration = metrics.NewHistogramVec(&metrics.HistogramOpts{
+		Name: "pinniped_supervisor_oidc_request_duration_seconds",
+		Help: "Latency of the requests to the endpoints of all FederationDomains by endpoint, including the time " +
+			"taken by the session storage and by the upstream identity provider during the callback.",
+		Buckets:        []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"endpoint"})
+
+	oidcErrorsTotal = metrics.NewCounterVec(&metrics.CounterOpts{
+		Name: "pinniped_supervisor_oidc_errors_total",
+		Help: "Number of failed requests to the endpoints of all FederationDomains by endpoint and class of error. " +
+			"The class is the OAuth error code of the response, or client_error or server_error for the other " +
+			"responses with a 4xx or 5xx status code.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"endpoint", "error"})
+)
+
+//nolint: gochecknoinits
+func init() {
+	legacyregistry.MustRegister(oidcRequestsTotal, oidcRequestDuration, oidcErrorsTotal)
+}
+
+// instrument records the metrics of every request which is served by the handler of an endpoint.
+func instrument(endpoint string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &metricsResponseWriter{ResponseWriter: w}
+		defer func() {
+			if rw.code == 0 {
+				rw.code = http.StatusOK
+			}
+			oidcRequestsTotal.WithLabelValues(endpoint, strconv.Itoa(rw.code)).Inc()
+			oidcRequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+			if errorClass := rw.errorClass(); errorClass != "" {
+				oidcErrorsTotal.WithLabelValues(endpoint, errorClass).Inc()
+			}
+		}()
+		handler.ServeHTTP(rw, r)
+	})
+}
+
+// metricsResponseWriter remembers the status code of the response and the OAuth error code, which is either in the
+// JSON body of an error response or in the query or fragment of the redirect back to the client.
+type metricsResponseWriter struct {
+	http.ResponseWriter
+	code      int
+	oauthErr  string
+	wroteBody bool
+}
+
+func (w *metricsResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+		if code >= 300 && code < 400 {
+			w.oauthErr = redirectError(w.Header().Get("Location"))
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *metricsResponseWriter) Write(data []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	// Error responses are written with a single call, so only the first one needs to be looked at.
+	if !w.wroteBody && w.code >= 400 {
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &body) == nil {
+			w.oauthErr = body.Error
+		}
+	}
+	w.wroteBody = true
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *metricsResponseWriter) errorClass() string {
+	switch {
+	case w.oauthErr != "" && knownOAuthErrors[w.oauthErr]:
+		return w.oauthErr
+	case w.oauthErr != "":
+		return "other"
+	case w.code >= 500:
+		return "server_error"
+	case w.code >= 400:
+		return "client_error"
+	default:
+		return ""
+	}
+}
+
+func redirectError(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+	if e := u.Query().Get("error"); e != "" {
+		return e
+	}
+	// Responses in the fragment response mode put the error in the fragment instead.
+	fragment, err := url.ParseQuery(u.Fragment)
+	if err != nil {
+		return ""
+	}
+	return fragment.Get("error")
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metricstestutil "k8s.io/component-base/metrics/testutil"
+)
+
+func TestInstrument(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		wantCode  string
+		wantError string
+	}{
+		{
+			name:     "success without an explicit status code",
+			handler:  func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("ok")) },
+			wantCode: "200",
+		},
+		{
+			name: "redirect back to the client without an error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "http://127.0.0.1/callback?code=some-code", http.StatusFound)
+			},
+			wantCode: "302",
+		},
+		{
+			name: "redirect back to the client with an error in the query",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "http://127.0.0.1/callback?error=access_denied&state=some-state", http.StatusSeeOther)
+			},
+			wantCode:  "303",
+			wantError: "access_denied",
+		},
+		{
+			name: "redirect back to the client with an error in the fragment",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "http://127.0.0.1/callback#error=login_required", http.StatusFound)
+			},
+			wantCode:  "302",
+			wantError: "login_required",
+		},
+		{
+			name: "json error response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"some description"}`))
+			},
+			wantCode:  "400",
+			wantError: "invalid_grant",
+		},
+		{
+			name: "json error response with an unknown error code",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"some_made_up_error"}`))
+			},
+			wantCode:  "400",
+			wantError: "other",
+		},
+		{
+			name:      "plain text client error",
+			handler:   func(w http.ResponseWriter, r *http.Request) { http.Error(w, "bad", http.StatusUnprocessableEntity) },
+			wantCode:  "422",
+			wantError: "client_error",
+		},
+		{
+			name:      "plain text server error",
+			handler:   func(w http.ResponseWriter, r *http.Request) { http.Error(w, "oops", http.StatusServiceUnavailable) },
+			wantCode:  "503",
+			wantError: "server_error",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := "test_" + tt.name
+
+			rsp := httptest.NewRecorder()
+			instrument(endpoint, tt.handler).ServeHTTP(rsp, httptest.NewRequest(http.MethodGet, "/some/path", nil))
+			require.Equal(t, tt.wantCode, strconv.Itoa(rsp.Code))
+
+			requests, err := metricstestutil.GetCounterMetricValue(oidcRequestsTotal.WithLabelValues(endpoint, tt.wantCode))
+			require.NoError(t, err)
+			require.Equal(t, float64(1), requests)
+
+			for _, errorClass := range []string{"access_denied", "login_required", "invalid_grant", "other", "client_error", "server_error"} {
+				errors, err := metricstestutil.GetCounterMetricValue(oidcErrorsTotal.WithLabelValues(endpoint, errorClass))
+				require.NoError(t, err)
+				if errorClass == tt.wantError {
+					require.Equal(t, float64(1), errors, errorClass)
+				} else {
+					require.Equal(t, float64(0), errors, errorClass)
+				}
+			}
+		})
+	}
+}
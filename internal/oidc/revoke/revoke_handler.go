@@ -0,0 +1,26 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package revoke provides a handler for the OAuth 2.0 token revocation endpoint.
+package revoke
+
+import (
+	"net/http"
+
+	"github.com/ory/fosite"
+
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/plog"
+)
+
+// NewHandler returns the handler for the token revocation endpoint, as described by RFC 7009. Revoking a refresh
+// token also revokes the access tokens which were issued with it, so the session cannot be used anymore.
+func NewHandler(oauthHelper fosite.OAuth2Provider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := oauthHelper.NewRevocationRequest(r.Context(), r)
+		if err != nil {
+			plog.Info("revocation request error", oidc.FositeErrorForLog(err)...)
+		}
+		oauthHelper.WriteRevocationResponse(w, err)
+	})
+}
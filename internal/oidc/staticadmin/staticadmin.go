@@ -0,0 +1,168 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package staticadmin implements the Supervisor's optional built-in identity provider, which has a single user whose
+// bcrypt password hash is stored in a Secret. It is only meant for bootstrapping new installations before a real
+// upstream identity provider has been configured.
+package staticadmin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"k8s.io/apimachinery/pkg/util/clock"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	"go.pinniped.dev/internal/devauthenticator"
+)
+
+const (
+	// UsernameHeaderName is the name of the authorize request header which carries the username of a
+	// username/password login, either with the static admin or with an upstream which allows the password grant.
+	UsernameHeaderName = "Pinniped-Username"
+
+	// PasswordHeaderName is the name of the authorize request header which carries the password of such a login.
+	PasswordHeaderName = "Pinniped-Password"
+
+	// Name identifies this identity provider in logs and in downstream subjects.
+	Name = "static-admin"
+
+	secretUsernameKey     = "username"
+	secretPasswordHashKey = "passwordHash"
+	secretGroupsKey       = "groups"
+)
+
+// Identity is the identity of the static admin user.
+type Identity struct {
+	Subject  string
+	Username string
+	Groups   []string
+	UID      string
+}
+
+// IdentityProvider authenticates the static admin user against a Secret.
+type IdentityProvider struct {
+	secretName string
+	secrets    corev1listers.SecretNamespaceLister
+
+	// demoUsers replace the Secret in --dev mode, see NewDemo.
+	demoUsers []devauthenticator.User
+
+	throttler *throttler
+	// passwordChecks holds a slot for each running bcrypt comparison.
+	passwordChecks chan struct{}
+}
+
+// New returns an IdentityProvider which reads the named Secret from secrets every time it authenticates a user,
+// so that changes to the Secret take effect immediately.
+func New(secretName string, secrets corev1listers.SecretNamespaceLister) *IdentityProvider {
+	return newIdentityProvider(&IdentityProvider{secretName: secretName, secrets: secrets}, clock.RealClock{})
+}
+
+// NewDemo returns an IdentityProvider for the demo users of --dev mode, who log in with their well-known tokens as
+// their passwords. It must never be used by a production server.
+func NewDemo(users []devauthenticator.User) *IdentityProvider {
+	return newIdentityProvider(&IdentityProvider{demoUsers: users}, clock.RealClock{})
+}
+
+func newIdentityProvider(p *IdentityProvider, clock clock.Clock) *IdentityProvider {
+	p.throttler = newThrottler(clock)
+	p.passwordChecks = make(chan struct{}, maxConcurrentPasswordChecks)
+	return p
+}
+
+// SecretName returns the name of the Secret which holds the credentials of the static admin user.
+func (p *IdentityProvider) SecretName() string {
+	return p.secretName
+}
+
+// AuthenticateUser returns the Identity of the static admin user when the username and password are correct.
+// It returns false without an error when they are not.
+//
+// Failed logins are throttled per source IP and per username, and a *ThrottledError is returned without checking the
+// password while either of them is locked out. The source may be empty when it is unknown.
+func (p *IdentityProvider) AuthenticateUser(ctx context.Context, source, username, password string) (*Identity, bool, error) {
+	if err := p.throttler.allow(source, username); err != nil {
+		return nil, false, err
+	}
+
+	identity, authenticated, err := p.authenticate(ctx, username, password)
+	switch {
+	case err != nil:
+	case authenticated:
+		p.throttler.recordSuccess(username)
+	default:
+		p.throttler.recordFailure(source, username)
+	}
+	return identity, authenticated, err
+}
+
+func (p *IdentityProvider) authenticate(ctx context.Context, username, password string) (*Identity, bool, error) {
+	if p.demoUsers != nil {
+		for _, u := range p.demoUsers {
+			if subtle.ConstantTimeCompare([]byte(u.Username), []byte(username)) == 1 &&
+				subtle.ConstantTimeCompare([]byte(u.Token), []byte(password)) == 1 {
+				return newIdentity(username, append([]string{}, u.Groups...)), true, nil
+			}
+		}
+		return nil, false, nil
+	}
+
+	secret, err := p.secrets.Get(p.secretName)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not get Secret %q: %w", p.secretName, err)
+	}
+
+	wantUsername := secret.Data[secretUsernameKey]
+	passwordHash := secret.Data[secretPasswordHashKey]
+	if len(wantUsername) == 0 || len(passwordHash) == 0 {
+		return nil, false, fmt.Errorf("the Secret %q must contain %q and %q", p.secretName, secretUsernameKey, secretPasswordHashKey)
+	}
+
+	select {
+	case p.passwordChecks <- struct{}{}:
+		defer func() { <-p.passwordChecks }()
+	case <-ctx.Done():
+		return nil, false, fmt.Errorf("could not check password: %w", ctx.Err())
+	}
+
+	// Always check the password, so that the response time does not reveal whether the username was correct.
+	passwordMatches := bcrypt.CompareHashAndPassword(passwordHash, []byte(password)) == nil
+	usernameMatches := subtle.ConstantTimeCompare(wantUsername, []byte(username)) == 1
+	if !passwordMatches || !usernameMatches {
+		return nil, false, nil
+	}
+
+	groups := []string{}
+	if groupsCSV := secret.Data[secretGroupsKey]; len(groupsCSV) > 0 {
+		groups, err = csv.NewReader(bytes.NewReader(groupsCSV)).Read()
+		if err != nil {
+			return nil, false, fmt.Errorf("could not read %q from Secret %q: %w", secretGroupsKey, p.secretName, err)
+		}
+		for i := range groups {
+			groups[i] = strings.TrimSpace(groups[i])
+		}
+	}
+
+	return newIdentity(username, groups), true, nil
+}
+
+func newIdentity(username string, groups []string) *Identity {
+	// Like the subjects of users from upstream OIDC providers, the subject is scoped to the identity provider and
+	// the UID is an opaque hash of the subject.
+	subject := fmt.Sprintf("%s?sub=%s", Name, username)
+	hash := sha256.Sum256([]byte(subject))
+	return &Identity{
+		Subject:  subject,
+		Username: username,
+		Groups:   groups,
+		UID:      hex.EncodeToString(hash[:]),
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package staticadmin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"go.pinniped.dev/internal/devauthenticator"
+)
+
+func TestAuthenticateUser(t *testing.T) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("some-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		secretData   map[string][]byte
+		noSecret     bool
+		username     string
+		password     string
+		wantIdentity *Identity
+		wantErr      string
+	}{
+		{
+			name:       "success",
+			secretData: map[string][]byte{"username": []byte("admin"), "passwordHash": passwordHash},
+			username:   "admin",
+			password:   "some-password",
+			wantIdentity: &Identity{
+				Subject:  "static-admin?sub=admin",
+				Username: "admin",
+				Groups:   []string{},
+				UID:      "e143467e1b196b4aa617e55ceb619eaf1a9e23faf89f6fc143c93302eb8c7bae",
+			},
+		},
+		{
+			name: "success with groups",
+			secretData: map[string][]byte{
+				"username":     []byte("admin"),
+				"passwordHash": passwordHash,
+				"groups":       []byte("admins, bootstrappers"),
+			},
+			username: "admin",
+			password: "some-password",
+			wantIdentity: &Identity{
+				Subject:  "static-admin?sub=admin",
+				Username: "admin",
+				Groups:   []string{"admins", "bootstrappers"},
+				UID:      "e143467e1b196b4aa617e55ceb619eaf1a9e23faf89f6fc143c93302eb8c7bae",
+			},
+		},
+		{
+			name:       "wrong password",
+			secretData: map[string][]byte{"username": []byte("admin"), "passwordHash": passwordHash},
+			username:   "admin",
+			password:   "wrong-password",
+		},
+		{
+			name:       "wrong username",
+			secretData: map[string][]byte{"username": []byte("admin"), "passwordHash": passwordHash},
+			username:   "someone-else",
+			password:   "some-password",
+		},
+		{
+			name:       "empty credentials",
+			secretData: map[string][]byte{"username": []byte("admin"), "passwordHash": passwordHash},
+		},
+		{
+			name:     "missing secret",
+			noSecret: true,
+			username: "admin",
+			password: "some-password",
+			wantErr:  `could not get Secret "admin-secret": secret "admin-secret" not found`,
+		},
+		{
+			name:       "secret without password hash",
+			secretData: map[string][]byte{"username": []byte("admin")},
+			username:   "admin",
+			password:   "some-password",
+			wantErr:    `the Secret "admin-secret" must contain "username" and "passwordHash"`,
+		},
+		{
+			name: "invalid groups",
+			secretData: map[string][]byte{
+				"username":     []byte("admin"),
+				"passwordHash": passwordHash,
+				"groups":       []byte(`"unterminated`),
+			},
+			username: "admin",
+			password: "some-password",
+			wantErr:  `could not read "groups" from Secret "admin-secret": parse error on line 1, column 14: extraneous or missing " in quoted-field`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if !tt.noSecret {
+				require.NoError(t, indexer.Add(&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "admin-secret", Namespace: "some-namespace"},
+					Data:       tt.secretData,
+				}))
+			}
+			subject := New("admin-secret", corev1listers.NewSecretLister(indexer).Secrets("some-namespace"))
+			require.Equal(t, "admin-secret", subject.SecretName())
+
+			identity, ok, err := subject.AuthenticateUser(context.Background(), "192.0.2.1", tt.username, tt.password)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.False(t, ok)
+				require.Nil(t, identity)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantIdentity != nil, ok)
+			require.Equal(t, tt.wantIdentity, identity)
+		})
+	}
+}
+
+func TestAuthenticateDemoUser(t *testing.T) {
+	subject := NewDemo(devauthenticator.DemoUsers())
+
+	identity, ok, err := subject.AuthenticateUser(context.Background(), "192.0.2.1", "bob", "bob-dev-token")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, &Identity{
+		Subject:  "static-admin?sub=bob",
+		Username: "bob",
+		Groups:   []string{"developers"},
+		UID:      "096b24eaf45ce2083d4f2befba1f3d1769ce9f837b864e8776d9c20371f67a9d",
+	}, identity)
+
+	for _, credentials := range [][2]string{{"bob", "alice-dev-token"}, {"carol", "bob-dev-token"}, {"bob", ""}} {
+		identity, ok, err = subject.AuthenticateUser(context.Background(), "192.0.2.1", credentials[0], credentials[1])
+		require.NoError(t, err)
+		require.False(t, ok)
+		require.Nil(t, identity)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package staticadmin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// freeFailures is how many failed logins are allowed from one source IP, or for one username, before it is
+	// throttled. It is low because there is only one user, whose password is normally used by a script.
+	freeFailures = 5
+
+	// throttleBaseDelay is how long the first lockout lasts. Each further failure doubles it, up to throttleMaxDelay.
+	throttleBaseDelay = time.Second
+	throttleMaxDelay  = 5 * time.Minute
+
+	// throttleForgetAfter is how long a source or username has to be quiet until its failures are forgotten.
+	throttleForgetAfter = 15 * time.Minute
+
+	// maxConcurrentPasswordChecks bounds the CPU which can be spent on bcrypt, which is slow by design.
+	maxConcurrentPasswordChecks = 2
+)
+
+// ThrottledError is returned by AuthenticateUser when the source or the username failed to log in too often.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed logins, retry after %s", e.RetryAfter)
+}
+
+// throttler counts the failed logins of each source IP and of each username, and once either has failed too often it
+// refuses further logins with an exponentially growing backoff, without checking their passwords. The counts are kept
+// in the memory of each Supervisor pod, so an attacker who reaches several pods gets a few more guesses. Anyone can
+// delay the logins of a username this way, which is preferable to letting them guess its password.
+type throttler struct {
+	clock clock.Clock
+
+	mu        sync.Mutex
+	failures  map[string]*failureRecord
+	lastSweep time.Time
+}
+
+type failureRecord struct {
+	count        int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+func newThrottler(clock clock.Clock) *throttler {
+	return &throttler{clock: clock, failures: map[string]*failureRecord{}, lastSweep: clock.Now()}
+}
+
+// allow returns an error when either the source or the username is currently locked out.
+func (t *throttler) allow(source, username string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	for _, key := range throttleKeys(source, username) {
+		if record := t.failures[key]; record != nil && now.Before(record.blockedUntil) {
+			return &ThrottledError{RetryAfter: record.blockedUntil.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// recordFailure counts a failed login of the username from the source, and locks either of them out when they have
+// failed too often.
+func (t *throttler) recordFailure(source, username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	t.maybeSweep(now)
+
+	for _, key := range throttleKeys(source, username) {
+		record := t.failures[key]
+		if record == nil || now.Sub(record.lastFailure) >= throttleForgetAfter {
+			record = &failureRecord{}
+			t.failures[key] = record
+		}
+		record.count++
+		record.lastFailure = now
+		if record.count <= freeFailures {
+			continue
+		}
+
+		delay := throttleMaxDelay
+		if shift := record.count - freeFailures - 1; shift < 16 {
+			if d := throttleBaseDelay << shift; d < throttleMaxDelay {
+				delay = d
+			}
+		}
+		record.blockedUntil = now.Add(delay)
+		plog.Warning("locking out static admin logins after too many failures",
+			"key", key,
+			"failures", record.count,
+			"lockout", delay.String(),
+		)
+	}
+}
+
+// recordSuccess forgets the failures of the username. The failures of the source are kept, so that someone who knows
+// the password cannot use it to reset the throttling of their guesses for other usernames.
+func (t *throttler) recordSuccess(username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failures, "username="+username)
+}
+
+func throttleKeys(source, username string) []string {
+	keys := []string{"username=" + username}
+	if source != "" {
+		keys = append(keys, "source="+source)
+	}
+	return keys
+}
+
+// maybeSweep forgets the sources and usernames which have been quiet for a while, so that memory usage is bounded by
+// the rate of failed logins.
+func (t *throttler) maybeSweep(now time.Time) {
+	if now.Sub(t.lastSweep) < throttleForgetAfter {
+		return
+	}
+	for key, record := range t.failures {
+		if now.Sub(record.lastFailure) >= throttleForgetAfter && !now.Before(record.blockedUntil) {
+			delete(t.failures, key)
+		}
+	}
+	t.lastSweep = now
+}
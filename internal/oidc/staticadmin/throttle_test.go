@@ -0,0 +1,74 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package staticadmin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestThrottledAuthenticateUser(t *testing.T) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("some-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "admin-secret", Namespace: "some-namespace"},
+		Data:       map[string][]byte{"username": []byte("admin"), "passwordHash": passwordHash},
+	}))
+	fakeClock := clock.NewFakeClock(time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC))
+	subject := newIdentityProvider(
+		&IdentityProvider{secretName: "admin-secret", secrets: corev1listers.NewSecretLister(indexer).Secrets("some-namespace")},
+		fakeClock,
+	)
+
+	authenticate := func(source, username, password string) (bool, error) {
+		_, ok, err := subject.AuthenticateUser(context.Background(), source, username, password)
+		return ok, err
+	}
+
+	// The same username can fail a few times before it is locked out, even when the right password is given.
+	for i := 0; i < freeFailures+1; i++ {
+		ok, err := authenticate("192.0.2.1", "admin", "wrong-password")
+		require.NoError(t, err)
+		require.False(t, ok)
+	}
+	_, err = authenticate("198.51.100.1", "admin", "some-password")
+	require.Equal(t, &ThrottledError{RetryAfter: time.Second}, err)
+
+	// The lockout doubles with each further failure, and a successful login clears the failures of the username.
+	fakeClock.Step(time.Second)
+	_, err = authenticate("198.51.100.1", "admin", "wrong-password")
+	require.NoError(t, err)
+	_, err = authenticate("198.51.100.1", "admin", "some-password")
+	require.EqualError(t, err, "too many failed logins, retry after 2s")
+	fakeClock.Step(2 * time.Second)
+	ok, err := authenticate("198.51.100.1", "admin", "some-password")
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = authenticate("198.51.100.1", "admin", "some-password")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// The failures of the source are not cleared, so guessing another username from it locks it out again.
+	_, err = authenticate("192.0.2.1", "someone", "wrong-password")
+	require.NoError(t, err)
+	_, err = authenticate("192.0.2.1", "admin", "some-password")
+	require.Equal(t, &ThrottledError{RetryAfter: 2 * time.Second}, err)
+
+	// Quiet sources and usernames are forgotten.
+	fakeClock.Step(throttleForgetAfter)
+	_, err = authenticate("203.0.113.1", "someone", "wrong-password")
+	require.NoError(t, err)
+	require.Len(t, subject.throttler.failures, 2)
+	require.Contains(t, subject.throttler.failures, "source=203.0.113.1")
+}
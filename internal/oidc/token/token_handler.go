@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package token provides a handler for the OIDC token endpoint.
@@ -12,30 +12,98 @@ import (
 
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/audit"
+	"go.pinniped.dev/internal/oidc/groupgrant"
+	"go.pinniped.dev/internal/oidc/provider"
 	"go.pinniped.dev/internal/plog"
 )
 
+// NewHandler returns the handler for the token endpoint. When groupGrants is non-nil, the groups from any active
+// GroupGrants are added to the session each time that the authorization code or refresh grant is used. The
+// additional groups claim, when configured, is then updated to match the groups of the session.
+//
+// When the client presents a TLS client certificate with the authorization code, the tokens of the session are
+// bound to that certificate as described by RFC 8705, so they can only be refreshed or exchanged by a client which
+// presents the same certificate.
 func NewHandler(
 	oauthHelper fosite.OAuth2Provider,
+	groupGrants *groupgrant.Applier,
+	groupsClaim provider.DownstreamGroupsClaim,
 ) http.Handler {
 	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		thumbprint := oidc.ClientCertificateThumbprint(r)
+		ctx := oidc.WithClientCertificateThumbprint(r.Context(), thumbprint)
+
 		var session openid.DefaultSession
-		accessRequest, err := oauthHelper.NewAccessRequest(r.Context(), r, &session)
+		accessRequest, err := oauthHelper.NewAccessRequest(ctx, r, &session)
 		if err != nil {
 			plog.Info("token request error", oidc.FositeErrorForLog(err)...)
+			recordTokenEvent(r, accessRequest, err)
 			oauthHelper.WriteAccessError(w, accessRequest, err)
 			return nil
 		}
 
-		accessResponse, err := oauthHelper.NewAccessResponse(r.Context(), accessRequest)
+		// The grant handlers replace the session with the one which was stored when the authcode or refresh token was issued.
+		storedSession, ok := accessRequest.GetSession().(*openid.DefaultSession)
+		if ok && accessRequest.GetGrantTypes().ExactOne("authorization_code") {
+			oidc.BindToClientCertificate(storedSession, thumbprint)
+		}
+		if ok && accessRequest.GetGrantTypes().ExactOne("refresh_token") {
+			if err := oidc.VerifyClientCertificateBinding(storedSession, thumbprint); err != nil {
+				plog.Info("token request error", oidc.FositeErrorForLog(err)...)
+				recordTokenEvent(r, accessRequest, err)
+				oauthHelper.WriteAccessError(w, accessRequest, err)
+				return nil
+			}
+		}
+		if ok && accessRequest.GetGrantTypes().HasOneOf("authorization_code", "refresh_token") {
+			if groupGrants != nil {
+				if err := groupGrants.Apply(storedSession); err != nil {
+					plog.Error("token request group grants error", err)
+					recordTokenEvent(r, accessRequest, err)
+					oauthHelper.WriteAccessError(w, accessRequest, fosite.ErrServerError.WithWrap(err))
+					return nil
+				}
+			}
+			oidc.AddGroupsClaim(storedSession, groupsClaim)
+		}
+
+		accessResponse, err := oauthHelper.NewAccessResponse(ctx, accessRequest)
 		if err != nil {
 			plog.Info("token response error", oidc.FositeErrorForLog(err)...)
+			recordTokenEvent(r, accessRequest, err)
 			oauthHelper.WriteAccessError(w, accessRequest, err)
 			return nil
 		}
 
+		recordTokenEvent(r, accessRequest, nil)
 		oauthHelper.WriteAccessResponse(w, accessRequest, accessResponse)
 
 		return nil
 	})
 }
+
+// recordTokenEvent writes the audit event of a token request. The session of the access request is the one which
+// was stored when the user logged in, except when the request was rejected before it could be found.
+func recordTokenEvent(r *http.Request, accessRequest fosite.AccessRequester, err error) {
+	event := audit.Event{Type: audit.EventToken, ClientID: r.PostForm.Get("client_id")}
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code", oidc.DeviceCodeGrantType:
+		event.Type = audit.EventTokenIssued
+	case "refresh_token":
+		event.Type = audit.EventTokenRefreshed
+	case "urn:ietf:params:oauth:grant-type:token-exchange":
+		event.Type = audit.EventTokenExchanged
+		event.Audience = r.PostForm.Get("audience")
+	}
+	if accessRequest != nil {
+		if client := accessRequest.GetClient(); client != nil {
+			event.ClientID = client.GetID()
+		}
+		if session, ok := accessRequest.GetSession().(*openid.DefaultSession); ok && session.Claims != nil {
+			event.Subject = session.Claims.Subject
+			event.Username, _ = session.Claims.Extra[oidc.DownstreamUsernameClaim].(string)
+		}
+	}
+	audit.Record(r, event, err)
+}
@@ -4,15 +4,20 @@
 package token
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -37,13 +42,16 @@ import (
 	"go.pinniped.dev/internal/crud"
 	"go.pinniped.dev/internal/fositestorage/accesstoken"
 	"go.pinniped.dev/internal/fositestorage/authorizationcode"
+	"go.pinniped.dev/internal/fositestorage/deviceauthorization"
 	"go.pinniped.dev/internal/fositestorage/openidconnect"
 	storagepkce "go.pinniped.dev/internal/fositestorage/pkce"
 	"go.pinniped.dev/internal/fositestorage/refreshtoken"
 	"go.pinniped.dev/internal/here"
 	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/audit"
 	"go.pinniped.dev/internal/oidc/jwks"
 	"go.pinniped.dev/internal/oidc/oidctestutil"
+	"go.pinniped.dev/internal/oidc/provider"
 	"go.pinniped.dev/internal/testutil"
 )
 
@@ -146,6 +154,13 @@ var (
 		}
 	`)
 
+	fositeUnboundClientCertificateErrorBody = here.Doc(`
+		{
+			"error":             "invalid_grant",
+			"error_description": "The provided authorization grant (e.g., authorization code, resource owner credentials) or refresh token is invalid, expired, revoked, does not match the redirection URI used in the authorization request, or was issued to another client. The token is bound to a client certificate which was not presented with this request."
+		}
+	`)
+
 	fositeInvalidRedirectURIErrorBody = here.Doc(`
 		{
 			"error":             "invalid_grant",
@@ -207,6 +222,10 @@ type tokenEndpointResponseExpectedValues struct {
 	wantErrorResponseBody string
 	wantRequestedScopes   []string
 	wantGrantedScopes     []string
+
+	// wantClientCertificateBound is true when the stored access and refresh token sessions should be bound to the
+	// client certificate which was presented with the request.
+	wantClientCertificateBound bool
 }
 
 type authcodeExchangeInputs struct {
@@ -595,6 +614,73 @@ func TestTokenEndpointWhenAuthcodeIsUsedTwice(t *testing.T) {
 	}
 }
 
+func TestDeviceCodeGrant(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	secrets := client.CoreV1().Secrets("some-namespace")
+	oauthStore := oidc.NewKubeStorage(secrets, oidc.DefaultOIDCTimeoutsConfiguration())
+
+	// The device verification endpoint starts an authorization request like this one, without a nonce.
+	authRequest := deepCopyRequestForm(happyAuthRequest)
+	authRequest.Form.Set("scope", "openid offline_access")
+	authRequest.Form.Del("nonce")
+	oauthHelper, authCode, _ := makeHappyOauthHelper(t, authRequest, oauthStore)
+	subject := NewHandler(oauthHelper, nil, provider.DownstreamGroupsClaim{})
+
+	ctx := context.Background()
+	createDevice := func(userCode string, expiresAt time.Time) string {
+		deviceCode, err := oidc.GenerateDeviceCode(userCode)
+		require.NoError(t, err)
+		require.NoError(t, oauthStore.CreateDeviceAuthorization(ctx, oidc.NormalizeUserCode(userCode), &deviceauthorization.Session{
+			DeviceCodeSignature: oidc.DeviceCodeSignature(deviceCode),
+			ClientID:            goodClient,
+			Scopes:              []string{"openid", "offline_access"},
+			CodeVerifier:        goodPKCECodeVerifier,
+			ExpiresAt:           expiresAt,
+		}))
+		return deviceCode
+	}
+	poll := func(deviceCode string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/path/shouldn't/matter", body{
+			"grant_type":  {oidc.DeviceCodeGrantType},
+			"device_code": {deviceCode},
+			"client_id":   {goodClient},
+		}.ReadCloser())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rsp := httptest.NewRecorder()
+		subject.ServeHTTP(rsp, req)
+		t.Logf("response body: %q", rsp.Body.String())
+		return rsp
+	}
+	requireError := func(rsp *httptest.ResponseRecorder, wantError string) {
+		require.Equal(t, http.StatusBadRequest, rsp.Code)
+		var parsed map[string]interface{}
+		require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &parsed))
+		require.Equal(t, wantError, parsed["error"])
+	}
+
+	deviceCode := createDevice("BCDF-GHJK", time.Now().Add(time.Minute))
+	expiredDeviceCode := createDevice("LMNP-QRST", time.Now().Add(-time.Second))
+
+	requireError(poll("BCDFGHJK.wrong-device-code"), "invalid_grant")
+	requireError(poll("not-a-device-code"), "invalid_grant")
+	requireError(poll(expiredDeviceCode), "expired_token")
+	requireError(poll(deviceCode), "authorization_pending")
+
+	// The callback endpoint approves the device once the user has logged in.
+	require.NoError(t, oauthStore.ApproveDeviceAuthorization(ctx, "BCDFGHJK", authCode))
+
+	rsp := poll(deviceCode)
+	require.Equal(t, http.StatusOK, rsp.Code)
+	var parsedResponseBody map[string]interface{}
+	require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &parsedResponseBody))
+	require.ElementsMatch(t, []string{"id_token", "refresh_token", "access_token", "token_type", "expires_in", "scope"}, getMapKeys(parsedResponseBody))
+	requireInvalidAuthCodeStorage(t, authCode, oauthStore)
+	testutil.RequireNumberOfSecretsMatchingLabelSelector(t, secrets, labels.Set{crud.SecretLabelKey: deviceauthorization.TypeLabelValue}, 1) // only the expired one
+
+	// The device code cannot be used again.
+	requireError(poll(deviceCode), "invalid_grant")
+}
+
 func TestTokenExchange(t *testing.T) {
 	successfulAuthCodeExchange := tokenEndpointResponseExpectedValues{
 		wantStatus:            http.StatusOK,
@@ -852,7 +938,54 @@ type refreshRequestInputs struct {
 	want               tokenEndpointResponseExpectedValues
 }
 
+func TestTokenEndpointAuditEvents(t *testing.T) {
+	var auditLog bytes.Buffer
+	audit.SetOutput(&auditLog)
+	t.Cleanup(func() { audit.SetOutput(nil) })
+
+	subject, rsp, _, _, _, _ := exchangeAuthcodeForTokens(t, authcodeExchangeInputs{
+		modifyAuthRequest: func(authRequest *http.Request) {
+			authRequest.Form.Set("scope", "openid pinniped:request-audience")
+		},
+		want: tokenEndpointResponseExpectedValues{
+			wantStatus:            http.StatusOK,
+			wantSuccessBodyFields: []string{"id_token", "access_token", "token_type", "expires_in", "scope"},
+			wantRequestedScopes:   []string{"openid", "pinniped:request-audience"},
+			wantGrantedScopes:     []string{"openid", "pinniped:request-audience"},
+		},
+	})
+	var parsedAuthcodeExchangeResponseBody map[string]interface{}
+	require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &parsedAuthcodeExchangeResponseBody))
+	accessToken := parsedAuthcodeExchangeResponseBody["access_token"].(string)
+
+	exchange := func(audience string) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/path/shouldn't/matter", body(happyTokenExchangeRequest(audience, accessToken).Form).ReadCloser())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		subject.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	exchange("some-workload-cluster")
+	exchange("")
+
+	var events []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(auditLog.String()), "\n") {
+		var event map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		require.NotEmpty(t, event["ts"])
+		delete(event, "ts")
+		events = append(events, event)
+	}
+	require.Equal(t, []map[string]interface{}{
+		{"event": "token_issued", "outcome": "success", "username": goodUsername, "subject": goodSubject, "clientID": goodClient, "sourceIP": "192.0.2.1"},
+		{"event": "token_exchanged", "outcome": "success", "username": goodUsername, "subject": goodSubject, "clientID": goodClient, "audience": "some-workload-cluster", "sourceIP": "192.0.2.1"},
+		{"event": "token_exchanged", "outcome": "failure", "error": "invalid_request", "clientID": goodClient, "sourceIP": "192.0.2.1"},
+	}, events)
+}
+
 func TestRefreshGrant(t *testing.T) {
+	clientCertA := newClientCertificate(t, "client-a")
+	clientCertB := newClientCertificate(t, "client-b")
+
 	tests := []struct {
 		name             string
 		authcodeExchange authcodeExchangeInputs
@@ -1002,6 +1135,76 @@ func TestRefreshGrant(t *testing.T) {
 					wantErrorResponseBody: fositeInvalidAuthCodeErrorBody,
 				}},
 		},
+		{
+			name: "when the tokens are bound to a client certificate and the refresh request presents the same certificate",
+			authcodeExchange: authcodeExchangeInputs{
+				modifyAuthRequest:  func(r *http.Request) { r.Form.Set("scope", "openid offline_access") },
+				modifyTokenRequest: func(r *http.Request, authCode string) { presentClientCertificate(r, clientCertA) },
+				want: tokenEndpointResponseExpectedValues{
+					wantStatus:            http.StatusOK,
+					wantSuccessBodyFields: []string{"id_token", "refresh_token", "access_token", "token_type", "expires_in", "scope"},
+					wantRequestedScopes:   []string{"openid", "offline_access"},
+					wantGrantedScopes:     []string{"openid", "offline_access"},
+
+					wantClientCertificateBound: true,
+				},
+			},
+			refreshRequest: refreshRequestInputs{
+				modifyTokenRequest: func(r *http.Request, refreshToken string, accessToken string) {
+					presentClientCertificate(r, clientCertA)
+				},
+				want: tokenEndpointResponseExpectedValues{
+					wantStatus:            http.StatusOK,
+					wantSuccessBodyFields: []string{"id_token", "refresh_token", "access_token", "token_type", "expires_in", "scope"},
+					wantRequestedScopes:   []string{"openid", "offline_access"},
+					wantGrantedScopes:     []string{"openid", "offline_access"},
+
+					wantClientCertificateBound: true,
+				}},
+		},
+		{
+			name: "when the tokens are bound to a client certificate and the refresh request presents another certificate",
+			authcodeExchange: authcodeExchangeInputs{
+				modifyAuthRequest:  func(r *http.Request) { r.Form.Set("scope", "openid offline_access") },
+				modifyTokenRequest: func(r *http.Request, authCode string) { presentClientCertificate(r, clientCertA) },
+				want: tokenEndpointResponseExpectedValues{
+					wantStatus:            http.StatusOK,
+					wantSuccessBodyFields: []string{"id_token", "refresh_token", "access_token", "token_type", "expires_in", "scope"},
+					wantRequestedScopes:   []string{"openid", "offline_access"},
+					wantGrantedScopes:     []string{"openid", "offline_access"},
+
+					wantClientCertificateBound: true,
+				},
+			},
+			refreshRequest: refreshRequestInputs{
+				modifyTokenRequest: func(r *http.Request, refreshToken string, accessToken string) {
+					presentClientCertificate(r, clientCertB)
+				},
+				want: tokenEndpointResponseExpectedValues{
+					wantStatus:            http.StatusBadRequest,
+					wantErrorResponseBody: fositeUnboundClientCertificateErrorBody,
+				}},
+		},
+		{
+			name: "when the tokens are bound to a client certificate and the refresh request presents none",
+			authcodeExchange: authcodeExchangeInputs{
+				modifyAuthRequest:  func(r *http.Request) { r.Form.Set("scope", "openid offline_access") },
+				modifyTokenRequest: func(r *http.Request, authCode string) { presentClientCertificate(r, clientCertA) },
+				want: tokenEndpointResponseExpectedValues{
+					wantStatus:            http.StatusOK,
+					wantSuccessBodyFields: []string{"id_token", "refresh_token", "access_token", "token_type", "expires_in", "scope"},
+					wantRequestedScopes:   []string{"openid", "offline_access"},
+					wantGrantedScopes:     []string{"openid", "offline_access"},
+
+					wantClientCertificateBound: true,
+				},
+			},
+			refreshRequest: refreshRequestInputs{
+				want: tokenEndpointResponseExpectedValues{
+					wantStatus:            http.StatusBadRequest,
+					wantErrorResponseBody: fositeUnboundClientCertificateErrorBody,
+				}},
+		},
 		{
 			name: "when the wrong client ID is included in the refresh request",
 			authcodeExchange: authcodeExchangeInputs{
@@ -1145,7 +1348,7 @@ func exchangeAuthcodeForTokens(t *testing.T, test authcodeExchangeInputs) (
 	if test.modifyStorage != nil {
 		test.modifyStorage(t, oauthStore, authCode)
 	}
-	subject = NewHandler(oauthHelper)
+	subject = NewHandler(oauthHelper, nil, provider.DownstreamGroupsClaim{})
 
 	authorizeEndpointGrantedOpenIDScope := strings.Contains(authRequest.Form.Get("scope"), "openid")
 	expectedNumberOfIDSessionsStored := 0
@@ -1201,7 +1404,7 @@ func requireTokenEndpointBehavior(
 		wantRefreshToken := contains(test.wantSuccessBodyFields, "refresh_token")
 
 		requireInvalidAuthCodeStorage(t, authCode, oauthStore)
-		requireValidAccessTokenStorage(t, parsedResponseBody, oauthStore, test.wantRequestedScopes, test.wantGrantedScopes)
+		requireValidAccessTokenStorage(t, parsedResponseBody, oauthStore, test.wantRequestedScopes, test.wantGrantedScopes, test.wantClientCertificateBound)
 		requireInvalidPKCEStorage(t, authCode, oauthStore)
 		requireValidOIDCStorage(t, parsedResponseBody, authCode, oauthStore, test.wantRequestedScopes, test.wantGrantedScopes)
 
@@ -1212,10 +1415,13 @@ func requireTokenEndpointBehavior(
 		expectedNumberOfIDSessionsStored := 0
 		if wantIDToken {
 			expectedNumberOfIDSessionsStored = 1
-			requireValidIDToken(t, parsedResponseBody, jwtSigningKey, wantAtHashClaimInIDToken, wantNonceValueInIDToken, parsedResponseBody["access_token"].(string))
+			// The ID token of the initial authcode exchange is made from the session which was stored by the authorize
+			// endpoint, so like at_hash, the confirmation claim only appears in refreshed ID tokens.
+			wantConfirmationClaimInIDToken := test.wantClientCertificateBound && wantAtHashClaimInIDToken
+			requireValidIDToken(t, parsedResponseBody, jwtSigningKey, wantAtHashClaimInIDToken, wantNonceValueInIDToken, wantConfirmationClaimInIDToken, parsedResponseBody["access_token"].(string))
 		}
 		if wantRefreshToken {
-			requireValidRefreshTokenStorage(t, parsedResponseBody, oauthStore, test.wantRequestedScopes, test.wantGrantedScopes)
+			requireValidRefreshTokenStorage(t, parsedResponseBody, oauthStore, test.wantRequestedScopes, test.wantGrantedScopes, test.wantClientCertificateBound)
 		}
 
 		testutil.RequireNumberOfSecretsMatchingLabelSelector(t, secrets, labels.Set{crud.SecretLabelKey: authorizationcode.TypeLabelValue}, 1)
@@ -1450,6 +1656,7 @@ func requireValidRefreshTokenStorage(
 	storage oauth2.CoreStorage,
 	wantRequestedScopes []string,
 	wantGrantedScopes []string,
+	wantClientCertificateBound bool,
 ) {
 	t.Helper()
 
@@ -1470,6 +1677,7 @@ func requireValidRefreshTokenStorage(
 		wantRequestedScopes,
 		wantGrantedScopes,
 		true,
+		wantClientCertificateBound,
 	)
 }
 
@@ -1479,6 +1687,7 @@ func requireValidAccessTokenStorage(
 	storage oauth2.CoreStorage,
 	wantRequestedScopes []string,
 	wantGrantedScopes []string,
+	wantClientCertificateBound bool,
 ) {
 	t.Helper()
 
@@ -1518,6 +1727,7 @@ func requireValidAccessTokenStorage(
 		wantRequestedScopes,
 		wantGrantedScopes,
 		true,
+		wantClientCertificateBound,
 	)
 }
 
@@ -1579,6 +1789,7 @@ func requireValidOIDCStorage(
 			wantRequestedScopes,
 			wantGrantedScopes,
 			false,
+			false,
 		)
 	} else {
 		_, err := storage.GetOpenIDConnectSession(context.Background(), code, nil)
@@ -1593,6 +1804,7 @@ func requireValidStoredRequest(
 	wantRequestedScopes []string,
 	wantGrantedScopes []string,
 	wantAccessTokenExpiresAt bool,
+	wantClientCertificateBound bool,
 ) {
 	t.Helper()
 
@@ -1617,10 +1829,17 @@ func requireValidStoredRequest(
 		require.Equal(t, goodSubject, claims.Subject)
 
 		// Our custom claims from the authorize endpoint should still be set.
-		require.Equal(t, map[string]interface{}{
+		wantExtra := map[string]interface{}{
 			"username": goodUsername,
 			"groups":   goodGroups,
-		}, claims.Extra)
+		}
+		if wantClientCertificateBound {
+			confirmation, ok := claims.Extra[oidc.ConfirmationClaim].(map[string]interface{})
+			require.Truef(t, ok, "wanted the session to be bound to a client certificate, but got %#v", claims.Extra)
+			require.NotEmpty(t, confirmation["x5t#S256"])
+			wantExtra[oidc.ConfirmationClaim] = confirmation
+		}
+		require.Equal(t, wantExtra, claims.Extra)
 
 		// We are in charge of setting these fields. For the purpose of testing, we ensure that the
 		// sentinel test value is set correctly.
@@ -1687,6 +1906,7 @@ func requireValidIDToken(
 	jwtSigningKey *ecdsa.PrivateKey,
 	wantAtHashClaimInIDToken bool,
 	wantNonceValueInIDToken bool,
+	wantConfirmationClaimInIDToken bool,
 	actualAccessToken string,
 ) {
 	t.Helper()
@@ -1721,6 +1941,9 @@ func requireValidIDToken(
 	if wantAtHashClaimInIDToken {
 		idTokenFields = append(idTokenFields, "at_hash")
 	}
+	if wantConfirmationClaimInIDToken {
+		idTokenFields = append(idTokenFields, oidc.ConfirmationClaim)
+	}
 
 	// make sure that these are the only fields in the token
 	var m map[string]interface{}
@@ -1785,3 +2008,26 @@ func contains(haystack []string, needle string) bool {
 	}
 	return false
 }
+
+// newClientCertificate returns a self-signed client certificate.
+func newClientCertificate(t *testing.T, commonName string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// presentClientCertificate makes the request look like it was sent over TLS with the client certificate.
+func presentClientCertificate(r *http.Request, cert *x509.Certificate) {
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+}
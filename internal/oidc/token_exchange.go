@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidc
@@ -65,6 +65,13 @@ func (t *TokenExchangeHandler) PopulateTokenEndpointResponse(ctx context.Context
 		return errors.WithStack(err)
 	}
 
+	// Require that the client presents the certificate to which the incoming access token is bound, if any.
+	if session, ok := originalRequester.GetSession().(*openid.DefaultSession); ok {
+		if err := VerifyClientCertificateBinding(session, clientCertificateThumbprintFrom(ctx)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
 	// Require that the incoming access token has the pinniped:request-audience and OpenID scopes.
 	if !originalRequester.GetGrantedScopes().Has(pinnipedTokenExchangeScope) {
 		return errors.WithStack(fosite.ErrAccessDenied.WithHintf("missing the %q scope", pinnipedTokenExchangeScope))
@@ -79,6 +86,9 @@ func (t *TokenExchangeHandler) PopulateTokenEndpointResponse(ctx context.Context
 		return errors.WithStack(err)
 	}
 
+	// Keep the session of the original authorize request, so that the token endpoint can tell whose token was exchanged.
+	requester.SetSession(originalRequester.GetSession())
+
 	// Format the response parameters according to RFC8693.
 	responder.SetAccessToken(responseToken)
 	responder.SetTokenType("N_A")
@@ -0,0 +1,130 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package usernametemplate computes downstream usernames from the claims of upstream ID tokens.
+package usernametemplate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"go.pinniped.dev/internal/constable"
+)
+
+const (
+	errEmptyTemplate = constable.Error("template must not be empty")
+	errEmptyUsername = constable.Error("template produced an empty username")
+)
+
+// Template is a parsed Go text/template which is evaluated against the claims of an ID token, e.g.
+// "{{.preferred_username}}@{{.tenant}}".
+type Template struct {
+	text   string
+	tmpl   *template.Template
+	claims map[string]bool
+}
+
+// Parse parses and validates a username template.
+func Parse(text string) (*Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, errEmptyTemplate
+	}
+	tmpl, err := template.New("usernameTemplate").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string]bool{}
+	collectClaims(tmpl.Root, claims, true)
+	if len(claims) == 0 {
+		return nil, fmt.Errorf("template %q does not reference any claims", text)
+	}
+	return &Template{text: text, tmpl: tmpl, claims: claims}, nil
+}
+
+// String returns the text of the template.
+func (t *Template) String() string {
+	return t.text
+}
+
+// ReferencesClaim returns true when the template uses the value of the named top-level claim.
+func (t *Template) ReferencesClaim(name string) bool {
+	return t.claims[name]
+}
+
+// Execute evaluates the template against the claims of an ID token. Every claim which is referenced by the
+// template must be present, even when it is only used in a condition.
+func (t *Template) Execute(claims map[string]interface{}) (string, error) {
+	var missing []string
+	for name := range t.claims {
+		if _, ok := claims[name]; !ok {
+			missing = append(missing, strconv.Quote(name))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", fmt.Errorf("missing claims referenced by the username template: %s", strings.Join(missing, ", "))
+	}
+
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, claims); err != nil {
+		return "", err
+	}
+	if b.Len() == 0 {
+		return "", errEmptyUsername
+	}
+	return b.String(), nil
+}
+
+// collectClaims finds the names of the top-level claims which are referenced by the parse tree, e.g. "tenant"
+// for both {{.tenant}} and {{.tenant.name}}. Inside of "with" and "range" blocks, dot refers to something other
+// than the claims, so only fields of $ are claims there.
+func collectClaims(node parse.Node, claims map[string]bool, dotIsClaims bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectClaims(child, claims, dotIsClaims)
+		}
+	case *parse.ActionNode:
+		collectClaims(n.Pipe, claims, dotIsClaims)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectClaims(cmd, claims, dotIsClaims)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectClaims(arg, claims, dotIsClaims)
+		}
+	case *parse.FieldNode:
+		if dotIsClaims {
+			claims[n.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			claims[n.Ident[1]] = true
+		}
+	case *parse.ChainNode:
+		collectClaims(n.Node, claims, dotIsClaims)
+	case *parse.IfNode:
+		collectClaims(n.Pipe, claims, dotIsClaims)
+		collectClaims(n.List, claims, dotIsClaims)
+		collectClaims(n.ElseList, claims, dotIsClaims)
+	case *parse.RangeNode:
+		collectClaims(n.Pipe, claims, dotIsClaims)
+		collectClaims(n.List, claims, false)
+		collectClaims(n.ElseList, claims, dotIsClaims)
+	case *parse.WithNode:
+		collectClaims(n.Pipe, claims, dotIsClaims)
+		collectClaims(n.List, claims, false)
+		collectClaims(n.ElseList, claims, dotIsClaims)
+	}
+}
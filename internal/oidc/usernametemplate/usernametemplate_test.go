@@ -0,0 +1,97 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package usernametemplate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		wantErr    string
+		wantClaims []string
+	}{
+		{
+			name:    "empty",
+			text:    "  ",
+			wantErr: "template must not be empty",
+		},
+		{
+			name:    "invalid syntax",
+			text:    "{{.preferred_username",
+			wantErr: `template: usernameTemplate:1: unclosed action`,
+		},
+		{
+			name:    "no claims",
+			text:    "some-user",
+			wantErr: `template "some-user" does not reference any claims`,
+		},
+		{
+			name:       "single claim",
+			text:       "{{.preferred_username}}",
+			wantClaims: []string{"preferred_username"},
+		},
+		{
+			name:       "multiple claims",
+			text:       "{{.preferred_username}}@{{.tenant.name}}",
+			wantClaims: []string{"preferred_username", "tenant"},
+		},
+		{
+			name:       "with block",
+			text:       `{{with .tenant}}{{.name}}@{{$.domain}}{{end}}`,
+			wantClaims: []string{"tenant", "domain"},
+		},
+		{
+			name:       "conditional",
+			text:       `{{if .upn}}{{.upn}}{{else}}{{.email | printf "%s"}}{{end}}`,
+			wantClaims: []string{"upn", "email"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Parse(tt.text)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, tmpl)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.text, tmpl.String())
+			for _, claim := range tt.wantClaims {
+				require.True(t, tmpl.ReferencesClaim(claim), claim)
+			}
+			require.Len(t, tmpl.claims, len(tt.wantClaims))
+			require.False(t, tmpl.ReferencesClaim("sub"))
+		})
+	}
+}
+
+func TestExecute(t *testing.T) {
+	tmpl, err := Parse("{{.preferred_username}}@{{.tenant}}")
+	require.NoError(t, err)
+
+	username, err := tmpl.Execute(map[string]interface{}{"preferred_username": "pinny", "tenant": "seals.example.com"})
+	require.NoError(t, err)
+	require.Equal(t, "pinny@seals.example.com", username)
+
+	_, err = tmpl.Execute(map[string]interface{}{"preferred_username": "pinny"})
+	require.EqualError(t, err, `missing claims referenced by the username template: "tenant"`)
+	_, err = tmpl.Execute(map[string]interface{}{})
+	require.EqualError(t, err, `missing claims referenced by the username template: "preferred_username", "tenant"`)
+
+	nested, err := Parse("{{.preferred_username}}@{{.tenant.domain}}")
+	require.NoError(t, err)
+	_, err = nested.Execute(map[string]interface{}{"preferred_username": "pinny", "tenant": map[string]interface{}{}})
+	require.EqualError(t, err, `template: usernameTemplate:1:33: executing "usernameTemplate" at <.tenant.domain>: map has no entry for key "domain"`)
+
+	optional, err := Parse("{{with .upn}}{{.}}{{end}}")
+	require.NoError(t, err)
+	_, err = optional.Execute(map[string]interface{}{"upn": ""})
+	require.EqualError(t, err, "template produced an empty username")
+}
@@ -0,0 +1,260 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package perror contains the errors which Pinniped reports to end users. Each error has a message which is safe to
+// show to anyone, a stable code which clients and alerts can match on, and an optional cause with the details which
+// only operators should see. The code also decides who can act on the error and whether retrying may help.
+package perror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"go.pinniped.dev/internal/plog"
+)
+
+// Audience is who can act on an error.
+type Audience string
+
+const (
+	// AudienceUser errors can be fixed by the end user, e.g. by logging in again.
+	AudienceUser = Audience("user")
+	// AudienceAdmin errors need a cluster administrator, e.g. to fix the configuration.
+	AudienceAdmin = Audience("admin")
+)
+
+// Code is the stable identifier of a kind of failure. Codes are part of Pinniped's API and must never be renamed.
+type Code string
+
+const (
+	CodeInvalidRequest        = Code("invalid_request")
+	CodeAuthenticationFailed  = Code("authentication_failed")
+	CodeAccessDenied          = Code("access_denied")
+	CodeApprovalPending       = Code("approval_pending")
+	CodeRateLimited           = Code("rate_limited")
+	CodeNotConfigured         = Code("not_configured")
+	CodeUnavailable           = Code("unavailable")
+	CodeUpstreamFailed        = Code("upstream_failed")
+	CodeUpstreamMisconfigured = Code("upstream_misconfigured")
+	CodeInternal              = Code("internal_error")
+)
+
+type codeInfo struct {
+	audience   Audience
+	retryable  bool
+	httpStatus int
+}
+
+//nolint: gochecknoglobals
+var codes = map[Code]codeInfo{
+	CodeInvalidRequest:        {AudienceUser, false, http.StatusBadRequest},
+	CodeAuthenticationFailed:  {AudienceUser, false, http.StatusUnauthorized},
+	CodeAccessDenied:          {AudienceUser, false, http.StatusForbidden},
+	CodeApprovalPending:       {AudienceUser, true, http.StatusForbidden},
+	CodeRateLimited:           {AudienceUser, true, http.StatusTooManyRequests},
+	CodeNotConfigured:         {AudienceAdmin, false, http.StatusUnprocessableEntity},
+	CodeUnavailable:           {AudienceAdmin, true, http.StatusServiceUnavailable},
+	CodeUpstreamFailed:        {AudienceAdmin, true, http.StatusBadGateway},
+	CodeUpstreamMisconfigured: {AudienceAdmin, false, http.StatusUnprocessableEntity},
+	CodeInternal:              {AudienceAdmin, true, http.StatusInternalServerError},
+}
+
+func (c Code) info() codeInfo {
+	if info, ok := codes[c]; ok {
+		return info
+	}
+	return codes[CodeInternal]
+}
+
+const (
+	// HeaderName is the HTTP response header which carries the code of an error response.
+	HeaderName = "Pinniped-Error-Code"
+
+	// CauseType is the type of the cause which carries the code of a Kubernetes API error.
+	CauseType = metav1.CauseType("PinnipedErrorCode")
+
+	// internalErrorMessage replaces the message of errors which were not meant for end users.
+	internalErrorMessage = "an internal error occurred"
+)
+
+// Error is an error which can be shown to end users.
+type Error struct {
+	code       Code
+	message    string
+	cause      error
+	retryAfter time.Duration
+}
+
+// New returns an Error with a message which is safe to show to end users.
+func New(code Code, message string) *Error {
+	return &Error{code: code, message: message}
+}
+
+// Wrap returns an Error with a message which is safe to show to end users, and a cause which is only logged.
+func Wrap(code Code, message string, cause error) *Error {
+	return &Error{code: code, message: message, cause: cause}
+}
+
+// WithRetryAfter tells clients how long to wait before they retry.
+func (e *Error) WithRetryAfter(retryAfter time.Duration) *Error {
+	e.retryAfter = retryAfter
+	return e
+}
+
+// Error returns the message with the details of the cause, which is meant for operators.
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %v", e.message, e.cause)
+	}
+	return e.message
+}
+
+func (e *Error) Unwrap() error { return e.cause }
+
+func (e *Error) Code() Code { return e.code }
+
+func (e *Error) Audience() Audience { return e.code.info().audience }
+
+func (e *Error) Retryable() bool { return e.code.info().retryable }
+
+func (e *Error) HTTPStatus() int { return e.code.info().httpStatus }
+
+// Message returns the message which is safe to show to end users.
+func (e *Error) Message() string { return e.message }
+
+// Respond writes the error to an HTTP response, without the details of its cause. It implements httperr.Responder.
+func (e *Error) Respond(w http.ResponseWriter) {
+	w.Header().Set(HeaderName, string(e.code))
+	if e.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(e.retryAfter)))
+	}
+	// http.Error is important here because it prevents content sniffing by forcing text/plain.
+	http.Error(w, http.StatusText(e.HTTPStatus())+": "+e.message, e.HTTPStatus())
+}
+
+// APIStatus returns the error as a Kubernetes API error, without the details of its cause.
+func (e *Error) APIStatus() *apierrors.StatusError {
+	status := &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    int32(e.HTTPStatus()),
+		Reason:  apiStatusReasons[e.HTTPStatus()],
+		Message: e.message,
+		Details: &metav1.StatusDetails{
+			Causes: []metav1.StatusCause{{Type: CauseType, Message: string(e.code)}},
+		},
+	}}
+	if e.retryAfter > 0 {
+		status.ErrStatus.Details.RetryAfterSeconds = int32(retryAfterSeconds(e.retryAfter))
+	}
+	return status
+}
+
+// apiStatusReasons are the reasons of the Kubernetes API errors with each HTTP status, which clients check with
+// functions like apierrors.IsTooManyRequests.
+//nolint: gochecknoglobals
+var apiStatusReasons = map[int]metav1.StatusReason{
+	http.StatusBadRequest:          metav1.StatusReasonBadRequest,
+	http.StatusUnauthorized:        metav1.StatusReasonUnauthorized,
+	http.StatusForbidden:           metav1.StatusReasonForbidden,
+	http.StatusUnprocessableEntity: metav1.StatusReasonInvalid,
+	http.StatusTooManyRequests:     metav1.StatusReasonTooManyRequests,
+	http.StatusInternalServerError: metav1.StatusReasonInternalError,
+	http.StatusBadGateway:          metav1.StatusReasonInternalError,
+	http.StatusServiceUnavailable:  metav1.StatusReasonServiceUnavailable,
+}
+
+// As returns the Error in the chain of err. Errors from the responses of Pinniped servers, i.e. Kubernetes API errors
+// and HTTP responses which carry a code, are turned back into an Error.
+func As(err error) (*Error, bool) {
+	var pErr *Error
+	if errors.As(err, &pErr) {
+		return pErr, true
+	}
+
+	var apiStatus apierrors.APIStatus
+	if errors.As(err, &apiStatus) && apiStatus.Status().Details != nil {
+		details := apiStatus.Status().Details
+		for _, cause := range details.Causes {
+			if cause.Type == CauseType {
+				return &Error{
+					code:       Code(cause.Message),
+					message:    apiStatus.Status().Message,
+					retryAfter: time.Duration(details.RetryAfterSeconds) * time.Second,
+				}, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// FromHTTPResponse returns the Error in an HTTP response which was written by Respond.
+func FromHTTPResponse(resp *http.Response, body []byte) (*Error, bool) {
+	code := resp.Header.Get(HeaderName)
+	if code == "" {
+		return nil, false
+	}
+	message := strings.TrimSpace(string(body))
+	message = strings.TrimPrefix(message, http.StatusText(resp.StatusCode)+": ")
+	return &Error{code: Code(code), message: message}, true
+}
+
+// UserMessage returns the message of err which is safe to show to end users. Errors which are not an Error may
+// contain internal details, so they are replaced by a generic message.
+func UserMessage(err error) string {
+	if pErr, ok := As(err); ok {
+		return pErr.message
+	}
+	return internalErrorMessage
+}
+
+// Hint returns a sentence which tells the end user what to do about err, or "" when err is not an Error.
+func Hint(err error) string {
+	pErr, ok := As(err)
+	if !ok {
+		return ""
+	}
+	var hint string
+	switch {
+	case pErr.Audience() == AudienceAdmin && pErr.Retryable():
+		hint = "This may be temporary. If it keeps happening, please ask your cluster administrator for help"
+	case pErr.Audience() == AudienceAdmin:
+		hint = "Please ask your cluster administrator for help"
+	case pErr.retryAfter > 0:
+		hint = fmt.Sprintf("Please try again in %s", pErr.retryAfter.Round(time.Second))
+	case pErr.Retryable():
+		hint = "Please try again later"
+	default:
+		return fmt.Sprintf("(error code %s)", pErr.code)
+	}
+	return fmt.Sprintf("%s (error code %s).", hint, pErr.code)
+}
+
+// Log writes the details of err for operators. Errors which an administrator needs to act on are logged as warnings.
+func Log(msg string, err error, keysAndValues ...interface{}) {
+	pErr, ok := As(err)
+	if !ok {
+		plog.Error(msg, err, keysAndValues...)
+		return
+	}
+	keysAndValues = append(keysAndValues, "code", pErr.code, "audience", pErr.Audience())
+	if pErr.Audience() == AudienceAdmin {
+		plog.WarningErr(msg, err, keysAndValues...)
+		return
+	}
+	plog.InfoErr(msg, err, keysAndValues...)
+}
+
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int(d.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
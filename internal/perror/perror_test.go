@@ -0,0 +1,129 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package perror
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestError(t *testing.T) {
+	cause := errors.New("some secret details")
+	err := Wrap(CodeUpstreamFailed, "could not reach the upstream provider", cause)
+
+	require.EqualError(t, err, "could not reach the upstream provider: some secret details")
+	require.Equal(t, "could not reach the upstream provider", err.Message())
+	require.True(t, errors.Is(err, cause))
+	require.Equal(t, CodeUpstreamFailed, err.Code())
+	require.Equal(t, AudienceAdmin, err.Audience())
+	require.True(t, err.Retryable())
+	require.Equal(t, http.StatusBadGateway, err.HTTPStatus())
+
+	unknown := New(Code("some_unknown_code"), "some message")
+	require.Equal(t, AudienceAdmin, unknown.Audience())
+	require.Equal(t, http.StatusInternalServerError, unknown.HTTPStatus())
+}
+
+func TestRespond(t *testing.T) {
+	w := httptest.NewRecorder()
+	Wrap(CodeRateLimited, "too many requests", errors.New("some secret details")).
+		WithRetryAfter(1500 * time.Millisecond).
+		Respond(w)
+
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "rate_limited", w.Header().Get(HeaderName))
+	require.Equal(t, "2", w.Header().Get("Retry-After"))
+	require.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Equal(t, "Too Many Requests: too many requests\n", w.Body.String())
+
+	resp := w.Result()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	pErr, ok := FromHTTPResponse(resp, body)
+	require.True(t, ok)
+	require.Equal(t, CodeRateLimited, pErr.Code())
+	require.EqualError(t, pErr, "too many requests")
+
+	_, ok = FromHTTPResponse(&http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{}}, body)
+	require.False(t, ok)
+}
+
+func TestAPIStatus(t *testing.T) {
+	status := Wrap(CodeRateLimited, "too many requests", errors.New("some secret details")).
+		WithRetryAfter(time.Minute).
+		APIStatus()
+
+	require.True(t, apierrors.IsTooManyRequests(status))
+	require.Equal(t, "too many requests", status.Error())
+	retryAfterSeconds, ok := apierrors.SuggestsClientDelay(status)
+	require.True(t, ok)
+	require.Equal(t, 60, retryAfterSeconds)
+	require.Equal(t, []metav1.StatusCause{{Type: CauseType, Message: "rate_limited"}}, status.ErrStatus.Details.Causes)
+
+	// Clients get the error back from the Kubernetes API error, even when it is wrapped.
+	pErr, ok := As(fmt.Errorf("could not login: %w", status))
+	require.True(t, ok)
+	require.Equal(t, CodeRateLimited, pErr.Code())
+	require.Equal(t, "too many requests", pErr.Message())
+	require.Equal(t, "Please try again in 1m0s (error code rate_limited).", Hint(status))
+
+	_, ok = As(apierrors.NewBadRequest("some other error"))
+	require.False(t, ok)
+}
+
+func TestUserMessageAndHint(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		wantUserMessage string
+		wantHint        string
+	}{
+		{
+			name:            "admin error which may be temporary",
+			err:             fmt.Errorf("wrapped: %w", Wrap(CodeUnavailable, "the service is unavailable", errors.New("some secret details"))),
+			wantUserMessage: "the service is unavailable",
+			wantHint:        "This may be temporary. If it keeps happening, please ask your cluster administrator for help (error code unavailable).",
+		},
+		{
+			name:            "admin error",
+			err:             New(CodeNotConfigured, "no upstream providers are configured"),
+			wantUserMessage: "no upstream providers are configured",
+			wantHint:        "Please ask your cluster administrator for help (error code not_configured).",
+		},
+		{
+			name:            "retryable user error",
+			err:             New(CodeApprovalPending, "your login is waiting for approval"),
+			wantUserMessage: "your login is waiting for approval",
+			wantHint:        "Please try again later (error code approval_pending).",
+		},
+		{
+			name:            "user error",
+			err:             New(CodeAccessDenied, "your login was denied"),
+			wantUserMessage: "your login was denied",
+			wantHint:        "(error code access_denied)",
+		},
+		{
+			name:            "other error",
+			err:             errors.New("some secret details"),
+			wantUserMessage: "an internal error occurred",
+			wantHint:        "",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.wantUserMessage, UserMessage(tt.err))
+			require.Equal(t, tt.wantHint, Hint(tt.err))
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package plog
+
+import (
+	"os"
+
+	"k8s.io/klog/v2"
+
+	"go.pinniped.dev/internal/constable"
+)
+
+// LogFormat is an enum that controls how logs are encoded.
+type LogFormat string
+
+const (
+	// FormatText (or leaving the format unset) is the standard klog format, which is meant to be read by people.
+	FormatText LogFormat = "text"
+	// FormatJSON writes each log as a JSON object on its own line, which is meant to be ingested by log aggregators.
+	FormatJSON LogFormat = "json"
+
+	errInvalidLogFormat = constable.Error("invalid log format, valid choices are the empty string, text and json")
+)
+
+// ValidateLogFormat returns an error if the format is not one of the valid choices, without changing the global format.
+func ValidateLogFormat(format LogFormat) error {
+	switch format {
+	case "", FormatText, FormatJSON:
+		return nil
+	default:
+		return errInvalidLogFormat
+	}
+}
+
+// ValidateAndSetLogFormatGlobally changes the format of all logs, including those of klog and of the Kubernetes
+// libraries. In the JSON format, every log has a "component" key with the given name of the server.
+func ValidateAndSetLogFormatGlobally(format LogFormat, component string) error {
+	if err := ValidateLogFormat(format); err != nil {
+		return err
+	}
+	if format == FormatJSON {
+		klog.SetLogger(newJSONLogger(os.Stderr, component))
+	} else {
+		klog.SetLogger(nil)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package plog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// jsonLogger is a logr.Logger which writes every log as one JSON object per line, e.g.
+//
+//   {"ts":"2021-04-01T12:00:00.000000Z","level":"info","component":"supervisor","msg":"some message","key":"value"}
+//
+// klog forwards all logs to it once it is installed with klog.SetLogger, and klog has already checked the verbosity
+// of each log by then, so it logs everything which it is given.
+type jsonLogger struct {
+	out       *lockedWriter
+	clock     func() time.Time
+	component string
+	name      string
+	values    []interface{}
+}
+
+type lockedWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+var _ logr.Logger = &jsonLogger{}
+
+func newJSONLogger(w io.Writer, component string) *jsonLogger {
+	return &jsonLogger{out: &lockedWriter{w: w}, clock: time.Now, component: component}
+}
+
+func (l *jsonLogger) Enabled() bool { return true }
+
+func (l *jsonLogger) Info(msg string, keysAndValues ...interface{}) {
+	level := "info"
+	// Warnings are info logs with a "warning" key, because klog does not have structured warnings.
+	if len(keysAndValues) >= 2 && keysAndValues[0] == "warning" && keysAndValues[1] == "true" {
+		level, keysAndValues = "warning", keysAndValues[2:]
+	}
+	l.write(level, msg, nil, keysAndValues)
+}
+
+func (l *jsonLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.write("error", msg, err, keysAndValues)
+}
+
+func (l *jsonLogger) V(_ int) logr.Logger { return l }
+
+func (l *jsonLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	c := *l
+	c.values = append(append([]interface{}{}, l.values...), keysAndValues...)
+	return &c
+}
+
+func (l *jsonLogger) WithName(name string) logr.Logger {
+	c := *l
+	if c.name != "" {
+		name = c.name + "." + name
+	}
+	c.name = name
+	return &c
+}
+
+func (l *jsonLogger) write(level, msg string, err error, keysAndValues []interface{}) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	writeField(&buf, "ts", l.clock().UTC().Format("2006-01-02T15:04:05.000000Z07:00"))
+	writeField(&buf, "level", level)
+	if l.component != "" {
+		writeField(&buf, "component", l.component)
+	}
+	if l.name != "" {
+		writeField(&buf, "logger", l.name)
+	}
+	// Unstructured klog logs end with a newline.
+	writeField(&buf, "msg", strings.TrimSuffix(msg, "\n"))
+	if err != nil {
+		writeField(&buf, "err", err.Error())
+	}
+	for _, kv := range [][]interface{}{l.values, keysAndValues} {
+		for i := 0; i < len(kv); i += 2 {
+			key, ok := kv[i].(string)
+			if !ok {
+				key = fmt.Sprintf("%v", kv[i])
+			}
+			if i+1 == len(kv) {
+				writeField(&buf, key, "(MISSING)")
+				break
+			}
+			writeField(&buf, key, kv[i+1])
+		}
+	}
+	buf.WriteString("}\n")
+
+	l.out.lock.Lock()
+	defer l.out.lock.Unlock()
+	_, _ = l.out.w.Write(buf.Bytes())
+}
+
+func writeField(buf *bytes.Buffer, key string, value interface{}) {
+	if buf.Len() > 1 {
+		buf.WriteByte(',')
+	}
+	keyJSON, _ := marshalJSON(key)
+	buf.Write(keyJSON)
+	buf.WriteByte(':')
+	buf.Write(jsonValue(value))
+}
+
+// jsonValue encodes errors and fmt.Stringers, like klog.ObjectRef, as their strings, which are more useful in logs
+// than their fields.
+func jsonValue(value interface{}) (data []byte) {
+	defer func() {
+		// A nil pointer which implements error or fmt.Stringer can panic.
+		if r := recover(); r != nil {
+			data, _ = marshalJSON(fmt.Sprintf("PANIC=%v", r))
+		}
+	}()
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case fmt.Stringer:
+		value = v.String()
+	}
+	data, err := marshalJSON(value)
+	if err != nil {
+		data, _ = marshalJSON(fmt.Sprintf("%+v", value))
+	}
+	return data
+}
+
+// marshalJSON is json.Marshal without the escaping of HTML characters, which are common in log messages.
+func marshalJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package plog
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2"
+)
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := newJSONLogger(&buf, "supervisor")
+	l.clock = func() time.Time { return time.Date(2021, 4, 1, 12, 0, 0, 123456789, time.FixedZone("EST", -5*60*60)) }
+
+	l.Info("some message", "controller", "some-controller", "resource", klog.KRef("some-namespace", "some-name"), "count", 3)
+	l.Info("some warning", "warning", "true", "reason", "some reason")
+	l.Error(errors.New("some error"), "some failure", "err2", errors.New("another error"))
+	l.WithName("some-logger").WithValues("controller", "other-controller").Info("unstructured klog message\n")
+	l.Info("odd", "key-without-value")
+	l.Info("unencodable", "value", struct{ C chan int }{}, 42, "non-string key")
+
+	require.Equal(t, ``+
+		`{"ts":"2021-04-01T17:00:00.123456Z","level":"info","component":"supervisor","msg":"some message","controller":"some-controller","resource":"some-namespace/some-name","count":3}`+"\n"+
+		`{"ts":"2021-04-01T17:00:00.123456Z","level":"warning","component":"supervisor","msg":"some warning","reason":"some reason"}`+"\n"+
+		`{"ts":"2021-04-01T17:00:00.123456Z","level":"error","component":"supervisor","msg":"some failure","err":"some error","err2":"another error"}`+"\n"+
+		`{"ts":"2021-04-01T17:00:00.123456Z","level":"info","component":"supervisor","logger":"some-logger","msg":"unstructured klog message","controller":"other-controller"}`+"\n"+
+		`{"ts":"2021-04-01T17:00:00.123456Z","level":"info","component":"supervisor","msg":"odd","key-without-value":"(MISSING)"}`+"\n"+
+		`{"ts":"2021-04-01T17:00:00.123456Z","level":"info","component":"supervisor","msg":"unencodable","value":"{C:<nil>}","42":"non-string key"}`+"\n",
+		buf.String())
+}
+
+func TestValidateAndSetLogFormatGlobally(t *testing.T) {
+	require.NoError(t, ValidateLogFormat(""))
+	require.NoError(t, ValidateLogFormat(FormatText))
+	require.NoError(t, ValidateLogFormat(FormatJSON))
+	require.EqualError(t, ValidateLogFormat("logfmt"), "invalid log format, valid choices are the empty string, text and json")
+	require.EqualError(t, ValidateAndSetLogFormatGlobally("logfmt", "concierge"), "invalid log format, valid choices are the empty string, text and json")
+
+	t.Cleanup(func() { klog.SetLogger(nil) })
+	require.NoError(t, ValidateAndSetLogFormatGlobally(FormatJSON, "concierge"))
+	require.NoError(t, ValidateAndSetLogFormatGlobally(FormatText, "concierge"))
+}
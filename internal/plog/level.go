@@ -38,11 +38,19 @@ const (
 	klogLevelAll
 )
 
-func ValidateAndSetLogLevelGlobally(level LogLevel) error {
-	klogLevel := klogLevelForPlogLevel(level)
-	if klogLevel < 0 {
+// ValidateLogLevel returns an error if the level is not one of the valid choices, without changing the global level.
+func ValidateLogLevel(level LogLevel) error {
+	if klogLevelForPlogLevel(level) < 0 {
 		return errInvalidLogLevel
 	}
+	return nil
+}
+
+func ValidateAndSetLogLevelGlobally(level LogLevel) error {
+	if err := ValidateLogLevel(level); err != nil {
+		return err
+	}
+	klogLevel := klogLevelForPlogLevel(level)
 
 	if _, err := logs.GlogSetter(strconv.Itoa(int(klogLevel))); err != nil {
 		panic(err) // programmer error
@@ -0,0 +1,73 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package qrcode encodes text as a QR Code (ISO/IEC 18004) and renders it on a terminal, so that a login URL can be
+// opened on a phone when the CLI runs in an SSH session or a container.
+//
+// The codes are encoded by github.com/skip2/go-qrcode with the lowest error correction level, since they are only
+// scanned from a screen, and long URLs should result in codes which are as small as possible.
+package qrcode
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// quietZone is the width of the border of light modules around a code, in modules.
+const quietZone = 4
+
+// Code is an encoded QR Code.
+type Code struct {
+	modules [][]bool // true for dark modules, indexed by row and then column, without the quiet zone
+}
+
+// Encode returns the smallest QR Code which contains the text.
+func Encode(text string) (*Code, error) {
+	code, err := qrcode.New(text, qrcode.Low)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode text as a QR code (%d bytes): %w", len(text), err)
+	}
+	code.DisableBorder = true
+	return &Code{modules: code.Bitmap()}, nil
+}
+
+// Size returns the number of modules on each side of the code, without the quiet zone.
+func (c *Code) Size() int {
+	return len(c.modules)
+}
+
+// Dark returns whether the module in the column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && x < c.Size() && y >= 0 && y < c.Size() && c.modules[y][x]
+}
+
+// WriteTerminal draws the code with Unicode half blocks, so that every line of text shows two rows of modules. The
+// colors are set explicitly, since a code with light modules on a dark background cannot be scanned by every reader.
+func (c *Code) WriteTerminal(w io.Writer) error {
+	const (
+		black = "\x1b[30;47m" // black foreground on a white background
+		reset = "\x1b[0m"
+	)
+	var b strings.Builder
+	for y := -quietZone; y < c.Size()+quietZone; y += 2 {
+		b.WriteString(black)
+		for x := -quietZone; x < c.Size()+quietZone; x++ {
+			switch top, bottom := c.Dark(x, y), c.Dark(x, y+1); {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(reset + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
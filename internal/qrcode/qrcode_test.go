@@ -0,0 +1,65 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		wantVersion int
+		wantErr     string
+	}{
+		{name: "empty", text: "", wantErr: "could not encode text as a QR code (0 bytes): no data to encode"},
+		{name: "largest version 1", text: strings.Repeat("a", 17), wantVersion: 1},
+		{name: "smallest version 2", text: strings.Repeat("a", 18), wantVersion: 2},
+		{name: "largest version 9", text: strings.Repeat("a", 230), wantVersion: 9},
+		{name: "smallest version 10", text: strings.Repeat("a", 231), wantVersion: 10},
+		{name: "largest version 10", text: strings.Repeat("a", 271), wantVersion: 10},
+		{
+			name:        "login URL",
+			text:        "https://issuer.example.com/some/path/oauth2/authorize?access_type=offline&client_id=pinniped-cli&code_challenge=VVaezYqum7reIhoavCHD1n2d-piN3r_mywoYj7fCR7g&code_challenge_method=S256&nonce=9a0e6b5c5a2f4e1f3d7f5b0f6a8c1c2e&redirect_uri=urn%3Aietf%3Awg%3Aoauth%3A2.0%3Aoob&response_type=code&scope=offline_access+openid+pinniped%3Arequest-audience&state=0f3b9e2a8d6c4b7e1a5d9c3f7b2e6a4d",
+			wantVersion: 13,
+		},
+		{name: "largest version 40", text: strings.Repeat("a", 2953), wantVersion: 40},
+		{name: "too long", text: strings.Repeat("a", 2954), wantErr: "could not encode text as a QR code (2954 bytes): content too long to encode"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Encode(tt.text)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, code)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantVersion*4+17, code.Size())
+		})
+	}
+}
+
+func TestWriteTerminal(t *testing.T) {
+	code, err := Encode("https://example.com")
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, code.WriteTerminal(&out))
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, (code.Size()+2*quietZone+1)/2)
+	for _, line := range lines {
+		require.True(t, strings.HasPrefix(line, "\x1b[30;47m"))
+		require.True(t, strings.HasSuffix(line, "\x1b[0m"))
+	}
+	// The quiet zone, and then the top rows of the top left finder pattern next to the quiet zone of the first line.
+	require.Equal(t, "\x1b[30;47m"+strings.Repeat(" ", code.Size()+2*quietZone)+"\x1b[0m", lines[0])
+	require.True(t, strings.HasPrefix(lines[2], "\x1b[30;47m    █▀▀▀▀▀█ "), lines[2])
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"context"
+	"sync"
+
+	"gopkg.in/square/go-jose.v2/jwt"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"go.pinniped.dev/internal/constable"
+)
+
+const (
+	errAnonymousCaller         = constable.Error("callers must authenticate to the cluster before requesting a credential")
+	errAnonymousCallerAudience = constable.Error("unauthenticated callers may only exchange tokens for one of the allowed audiences")
+)
+
+// CallerPolicy restricts who can call the TokenCredentialRequest API. By default, anyone can, since the callers do not
+// have a cluster credential yet. Clusters which forbid unauthenticated access to aggregated APIs can require callers to
+// be authenticated, or only accept tokens for some audiences from unauthenticated callers. It is safe for concurrent
+// use.
+type CallerPolicy struct {
+	mu                          sync.RWMutex
+	requireAuthenticatedCallers bool
+	anonymousAllowedAudiences   sets.String
+}
+
+// NewCallerPolicy returns a CallerPolicy with the given restrictions. Without any, all callers are allowed.
+func NewCallerPolicy(requireAuthenticatedCallers bool, anonymousAllowedAudiences []string) *CallerPolicy {
+	p := &CallerPolicy{}
+	p.Set(requireAuthenticatedCallers, anonymousAllowedAudiences)
+	return p
+}
+
+// Set changes the restrictions, e.g. when the config file was changed.
+func (p *CallerPolicy) Set(requireAuthenticatedCallers bool, anonymousAllowedAudiences []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requireAuthenticatedCallers = requireAuthenticatedCallers
+	p.anonymousAllowedAudiences = sets.NewString(anonymousAllowedAudiences...)
+}
+
+// Allow returns an error when the caller of the request may not exchange the token.
+func (p *CallerPolicy) Allow(ctx context.Context, token string) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !p.requireAuthenticatedCallers && p.anonymousAllowedAudiences.Len() == 0 {
+		return nil
+	}
+	if !isAnonymous(ctx) {
+		return nil
+	}
+	if p.requireAuthenticatedCallers {
+		return errAnonymousCaller
+	}
+
+	// The token is verified by its authenticator afterwards, so reading its claims without verifying it only decides
+	// whether it may be presented at all.
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return errAnonymousCallerAudience
+	}
+	var claims jwt.Claims
+	if err := parsed.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return errAnonymousCallerAudience
+	}
+	if !p.anonymousAllowedAudiences.HasAny(claims.Audience...) {
+		return errAnonymousCallerAudience
+	}
+	return nil
+}
+
+// isAnonymous returns whether the caller did not authenticate to the aggregated API server. The Kubernetes API server
+// passes them on as system:anonymous, in the system:unauthenticated group.
+func isAnonymous(ctx context.Context) bool {
+	caller, ok := genericapirequest.UserFrom(ctx)
+	if !ok || caller.GetName() == "" || caller.GetName() == user.Anonymous {
+		return true
+	}
+	return sets.NewString(caller.GetGroups()...).Has(user.AllUnauthenticated)
+}
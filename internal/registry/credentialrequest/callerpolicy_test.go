@@ -0,0 +1,111 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	"k8s.io/apiserver/pkg/authentication/user"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestCallerPolicy(t *testing.T) {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("some-signing-key-of-32-bytes-len")}, nil)
+	require.NoError(t, err)
+	tokenFor := func(audiences ...string) string {
+		token, err := jwt.Signed(signer).Claims(jwt.Claims{Subject: "some-subject", Audience: audiences}).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	anonymous := genericapirequest.WithUser(context.Background(), &user.DefaultInfo{
+		Name:   user.Anonymous,
+		Groups: []string{user.AllUnauthenticated},
+	})
+	authenticated := genericapirequest.WithUser(context.Background(), &user.DefaultInfo{
+		Name:   "system:serviceaccount:some-namespace:some-service-account",
+		Groups: []string{user.AllAuthenticated},
+	})
+
+	tests := []struct {
+		name                        string
+		requireAuthenticatedCallers bool
+		anonymousAllowedAudiences   []string
+		ctx                         context.Context
+		token                       string
+		wantErr                     string
+	}{
+		{
+			name:  "no restrictions",
+			ctx:   anonymous,
+			token: "some-opaque-token",
+		},
+		{
+			name:                        "authenticated callers are required and the caller is authenticated",
+			requireAuthenticatedCallers: true,
+			ctx:                         authenticated,
+			token:                       "some-opaque-token",
+		},
+		{
+			name:                        "authenticated callers are required and the caller is anonymous",
+			requireAuthenticatedCallers: true,
+			ctx:                         anonymous,
+			token:                       tokenFor("some-audience"),
+			wantErr:                     "callers must authenticate to the cluster before requesting a credential",
+		},
+		{
+			name:                        "authenticated callers are required and the request has no user",
+			requireAuthenticatedCallers: true,
+			ctx:                         context.Background(),
+			token:                       "some-opaque-token",
+			wantErr:                     "callers must authenticate to the cluster before requesting a credential",
+		},
+		{
+			name:                      "anonymous caller presents a token for an allowed audience",
+			anonymousAllowedAudiences: []string{"some-audience", "other-audience"},
+			ctx:                       anonymous,
+			token:                     tokenFor("unrelated-audience", "other-audience"),
+		},
+		{
+			name:                      "anonymous caller presents a token for another audience",
+			anonymousAllowedAudiences: []string{"some-audience"},
+			ctx:                       anonymous,
+			token:                     tokenFor("unrelated-audience"),
+			wantErr:                   "unauthenticated callers may only exchange tokens for one of the allowed audiences",
+		},
+		{
+			name:                      "anonymous caller presents a token which is not a JWT",
+			anonymousAllowedAudiences: []string{"some-audience"},
+			ctx:                       anonymous,
+			token:                     "some-opaque-token",
+			wantErr:                   "unauthenticated callers may only exchange tokens for one of the allowed audiences",
+		},
+		{
+			name:                      "authenticated caller presents a token which is not a JWT",
+			anonymousAllowedAudiences: []string{"some-audience"},
+			ctx:                       authenticated,
+			token:                     "some-opaque-token",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			policy := NewCallerPolicy(tt.requireAuthenticatedCallers, tt.anonymousAllowedAudiences)
+			err := policy.Allow(tt.ctx, tt.token)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			// Lifting the restrictions allows everyone again.
+			policy.Set(false, nil)
+			require.NoError(t, policy.Allow(tt.ctx, tt.token))
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// issuanceWindow is the sliding window over which the certificates issued to each user are counted.
+const issuanceWindow = time.Hour
+
+//nolint: gochecknoglobals
+var (
+	certificatesLimitedTotal = metrics.NewCounter(&metrics.CounterOpts{
+		Name:           "pinniped_concierge_client_certificates_limited_total",
+		Help:           "Number of TokenCredentialRequests which were refused because the user had reached the limit of certificates per hour.",
+		StabilityLevel: metrics.ALPHA,
+	})
+	certificatesIssuedPerUser = metrics.NewHistogram(&metrics.HistogramOpts{
+		Name:           "pinniped_concierge_client_certificates_per_user_per_hour",
+		Help:           "Number of client certificates which had been issued to the user in the last hour, observed at each issuance.",
+		Buckets:        []float64{1, 2, 5, 10, 20, 50, 100, 200, 500},
+		StabilityLevel: metrics.ALPHA,
+	})
+)
+
+//nolint: gochecknoinits
+func init() {
+	legacyregistry.MustRegister(certificatesLimitedTotal, certificatesIssuedPerUser)
+}
+
+// IssuanceLimiter counts the client certificates issued to each user over the last hour, and optionally limits
+// how many can be issued. It is safe for concurrent use.
+//
+// The counts are kept in the memory of each Concierge pod, so with several replicas a user can get up to the limit
+// from each of them, and the counts start over when a pod restarts. Requests of one user which run concurrently
+// are all allowed when the user is below the limit, since only the certificates which were actually issued are
+// counted. The limit is meant to contain runaway automation rather than to be exact.
+type IssuanceLimiter struct {
+	clock clock.Clock
+
+	mu         sync.Mutex
+	maxPerHour int
+	issued     map[string][]time.Time // oldest first
+	lastSweep  time.Time
+}
+
+// NewIssuanceLimiter returns an IssuanceLimiter which allows at most maxPerHour certificates per user per hour.
+// A maxPerHour of zero means that there is no limit, but issuance is still counted.
+func NewIssuanceLimiter(maxPerHour int, clock clock.Clock) *IssuanceLimiter {
+	return &IssuanceLimiter{
+		clock:      clock,
+		maxPerHour: maxPerHour,
+		issued:     map[string][]time.Time{},
+		lastSweep:  clock.Now(),
+	}
+}
+
+// SetMaxPerHour changes the limit, e.g. when the config file was changed.
+func (l *IssuanceLimiter) SetMaxPerHour(maxPerHour int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxPerHour = maxPerHour
+}
+
+// Allow returns true unless the user has already reached the limit. It also returns how many certificates were
+// issued to the user in the last hour, and when the user will be allowed another certificate when it was not.
+// It does not count anything, so Record must be called once the certificate was actually issued.
+func (l *IssuanceLimiter) Allow(username string) (count int, retryAfter time.Duration, allowed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.maybeSweep(now)
+
+	issued := unexpired(l.issued[username], now)
+	l.issued[username] = issued
+	if l.maxPerHour > 0 && len(issued) >= l.maxPerHour {
+		certificatesLimitedTotal.Inc()
+		return len(issued), issued[len(issued)-l.maxPerHour].Add(issuanceWindow).Sub(now), false
+	}
+	return len(issued), 0, true
+}
+
+// Record counts a certificate which was issued to the user, and returns how many were issued to the user in the
+// last hour, including this one.
+func (l *IssuanceLimiter) Record(username string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	issued := append(unexpired(l.issued[username], now), now)
+	l.issued[username] = issued
+	certificatesIssuedPerUser.Observe(float64(len(issued)))
+	return len(issued)
+}
+
+// maybeSweep forgets the users who have not been issued any certificates in the last hour, so that memory usage
+// is bounded by the number of recently active users.
+func (l *IssuanceLimiter) maybeSweep(now time.Time) {
+	if now.Sub(l.lastSweep) < issuanceWindow {
+		return
+	}
+	for username, issued := range l.issued {
+		if remaining := unexpired(issued, now); len(remaining) > 0 {
+			l.issued[username] = remaining
+		} else {
+			delete(l.issued, username)
+		}
+	}
+	l.lastSweep = now
+}
+
+func unexpired(issued []time.Time, now time.Time) []time.Time {
+	for i, t := range issued {
+		if now.Sub(t) < issuanceWindow {
+			return issued[i:]
+		}
+	}
+	return nil
+}
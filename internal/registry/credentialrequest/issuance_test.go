@@ -0,0 +1,68 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestIssuanceLimiter(t *testing.T) {
+	start := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(start)
+	limiter := NewIssuanceLimiter(3, fakeClock)
+
+	requireAllowed := func(username string, wantCount int) {
+		t.Helper()
+		count, retryAfter, allowed := limiter.Allow(username)
+		require.True(t, allowed)
+		require.Equal(t, wantCount-1, count)
+		require.Zero(t, retryAfter)
+		require.Equal(t, wantCount, limiter.Record(username))
+	}
+	requireLimited := func(username string, wantCount int, wantRetryAfter time.Duration) {
+		t.Helper()
+		count, retryAfter, allowed := limiter.Allow(username)
+		require.False(t, allowed)
+		require.Equal(t, wantCount, count)
+		require.Equal(t, wantRetryAfter, retryAfter)
+	}
+
+	requireAllowed("user-1", 1)
+	fakeClock.Step(10 * time.Minute)
+	requireAllowed("user-1", 2)
+	requireAllowed("user-1", 3)
+	requireLimited("user-1", 3, 50*time.Minute)
+
+	// Requests which are allowed but fail to issue a certificate are not counted.
+	_, _, allowed := limiter.Allow("user-2")
+	require.True(t, allowed)
+	_, _, allowed = limiter.Allow("user-2")
+	require.True(t, allowed)
+
+	// Each user has their own limit.
+	requireAllowed("user-2", 1)
+
+	// Once the first certificate is more than an hour old, the user can get one more.
+	fakeClock.Step(50 * time.Minute)
+	requireAllowed("user-1", 3)
+	requireLimited("user-1", 3, 10*time.Minute)
+
+	// Raising the limit takes effect immediately, and removing it allows any number of certificates.
+	limiter.SetMaxPerHour(4)
+	requireAllowed("user-1", 4)
+	requireLimited("user-1", 4, 10*time.Minute)
+	limiter.SetMaxPerHour(0)
+	requireAllowed("user-1", 5)
+	requireAllowed("user-1", 6)
+
+	// Users who have not been issued any certificates in the last hour are forgotten.
+	fakeClock.Step(2 * time.Hour)
+	requireAllowed("user-3", 1)
+	require.Len(t, limiter.issued, 1)
+	require.Contains(t, limiter.issued, "user-3")
+}
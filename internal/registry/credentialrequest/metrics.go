@@ -0,0 +1,48 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// These metrics are served by the /metrics endpoint of the aggregated API server, next to the standard apiserver
+// metrics. Callers need to be authorized to get the "/metrics" non-resource URL.
+//nolint: gochecknoglobals
+var (
+	tokenCredentialRequests = metrics.NewCounterVec(&metrics.CounterOpts{
+		Name:           "pinniped_concierge_token_credential_requests_total",
+		Help:           "Number of TokenCredentialRequests by authenticator and outcome.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"authenticator_kind", "authenticator_name", "outcome"})
+
+	tokenCredentialRequestDuration = metrics.NewHistogramVec(&metrics.HistogramOpts{
+		Name:           "pinniped_concierge_token_credential_request_duration_seconds",
+		Help:           "Latency of TokenCredentialRequests by authenticator kind and outcome, including the time taken by the authenticator.",
+		Buckets:        []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"authenticator_kind", "outcome"})
+
+	certificatesIssuedTotal = metrics.NewCounter(&metrics.CounterOpts{
+		Name:           "pinniped_concierge_client_certificates_issued_total",
+		Help:           "Number of client certificates issued by the TokenCredentialRequest API.",
+		StabilityLevel: metrics.ALPHA,
+	})
+)
+
+//nolint: gochecknoinits
+func init() {
+	legacyregistry.MustRegister(tokenCredentialRequests, tokenCredentialRequestDuration, certificatesIssuedTotal)
+}
+
+func recordMetrics(authenticatorKind, authenticatorName string, outcome issuanceOutcome, duration time.Duration) {
+	tokenCredentialRequests.WithLabelValues(authenticatorKind, authenticatorName, string(outcome)).Inc()
+	tokenCredentialRequestDuration.WithLabelValues(authenticatorKind, string(outcome)).Observe(duration.Seconds())
+	if outcome == outcomeIssued {
+		certificatesIssuedTotal.Inc()
+	}
+}
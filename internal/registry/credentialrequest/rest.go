@@ -6,46 +6,110 @@ package credentialrequest
 
 import (
 	"context"
+	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"net/url"
 	"time"
 
+	authenticationv1 "k8s.io/api/authentication/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/audit"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/apiserver/pkg/registry/rest"
 	"k8s.io/utils/trace"
 
 	loginapi "go.pinniped.dev/generated/latest/apis/concierge/login"
+	loginv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/login/v1alpha1"
+	"go.pinniped.dev/internal/bootstrapcredential"
+	"go.pinniped.dev/internal/certauthority/dynamiccertauthority"
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/perror"
+	"go.pinniped.dev/internal/plog"
 )
 
 // clientCertificateTTL is the TTL for short-lived client certificates returned by this API.
 const clientCertificateTTL = 5 * time.Minute
 
+// These annotations are added to the audit events of TokenCredentialRequests, so that the audit log of the cluster
+// records who obtained a credential, and with which authenticator.
+const (
+	// authenticatorAuditAnnotation is the kind and name of the authenticator which was requested.
+	authenticatorAuditAnnotation = "concierge.pinniped.dev/authenticator"
+	// usernameAuditAnnotation is the username of the authenticated user, which is not added for unauthenticated requests.
+	usernameAuditAnnotation = "concierge.pinniped.dev/username"
+	// uidAuditAnnotation is the UID of the authenticated user, which is only added when the authenticator returned one.
+	uidAuditAnnotation = "concierge.pinniped.dev/uid"
+	// outcomeAuditAnnotation is one of the issuanceOutcome values.
+	outcomeAuditAnnotation = "concierge.pinniped.dev/client-certificate-issuance"
+	// issuanceLimitAuditAnnotation is added to each request which was refused by the IssuanceLimiter.
+	issuanceLimitAuditAnnotation = "concierge.pinniped.dev/client-certificate-issuance-limit-exceeded"
+)
+
+// issuanceOutcome is the value of the outcomeAuditAnnotation.
+type issuanceOutcome string
+
+const (
+	outcomeIssued            issuanceOutcome = "issued"
+	outcomeUnauthenticated   issuanceOutcome = "unauthenticated"
+	outcomeCallerRefused     issuanceOutcome = "caller-refused"
+	outcomeLimitExceeded     issuanceOutcome = "limit-exceeded"
+	outcomeThrottled         issuanceOutcome = "throttled"
+	outcomeNoWorkingStrategy issuanceOutcome = "no-working-strategy"
+	outcomeFailed            issuanceOutcome = "failed"
+)
+
 type CertIssuer interface {
-	IssuePEM(subject pkix.Name, dnsNames []string, ttl time.Duration) ([]byte, []byte, error)
+	IssueClientCertPEM(subject pkix.Name, uris []*url.URL, ttl time.Duration) ([]byte, []byte, error)
 }
 
 type TokenCredentialRequestAuthenticator interface {
 	AuthenticateTokenCredentialRequest(ctx context.Context, req *loginapi.TokenCredentialRequest) (user.Info, error)
 }
 
-func NewREST(authenticator TokenCredentialRequestAuthenticator, issuer CertIssuer, resource schema.GroupResource) *REST {
+// NewREST returns the storage for the TokenCredentialRequest API. The issuanceLimiter, throttler, uriSANTemplate and
+// callerPolicy are optional.
+func NewREST(
+	authenticator TokenCredentialRequestAuthenticator,
+	issuer CertIssuer,
+	issuanceLimiter *IssuanceLimiter,
+	throttler *Throttler,
+	uriSANTemplate *URISANTemplate,
+	callerPolicy *CallerPolicy,
+	clock clock.PassiveClock,
+	resource schema.GroupResource,
+) *REST {
 	return &REST{
-		authenticator:  authenticator,
-		issuer:         issuer,
-		tableConvertor: rest.NewDefaultTableConvertor(resource),
+		authenticator:   authenticator,
+		issuer:          issuer,
+		issuanceLimiter: issuanceLimiter,
+		throttler:       throttler,
+		uriSANTemplate:  uriSANTemplate,
+		callerPolicy:    callerPolicy,
+		clock:           clock,
+		resource:        resource,
+		tableConvertor:  tableConvertor{resource: resource},
 	}
 }
 
 type REST struct {
-	authenticator  TokenCredentialRequestAuthenticator
-	issuer         CertIssuer
-	tableConvertor rest.TableConvertor
+	authenticator   TokenCredentialRequestAuthenticator
+	issuer          CertIssuer
+	issuanceLimiter *IssuanceLimiter
+	throttler       *Throttler
+	uriSANTemplate  *URISANTemplate
+	callerPolicy    *CallerPolicy
+	clock           clock.PassiveClock
+	resource        schema.GroupResource
+	tableConvertor  rest.TableConvertor
 }
 
 // Assert that our *REST implements all the optional interfaces that we expect it to implement.
@@ -88,6 +152,7 @@ func (*REST) Categories() []string {
 }
 
 func (r *REST) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, options *metav1.CreateOptions) (runtime.Object, error) {
+	start := time.Now()
 	t := trace.FromContext(ctx).Nest("create", trace.Field{
 		Key:   "kind",
 		Value: obj.GetObjectKind().GroupVersionKind().Kind,
@@ -99,42 +164,299 @@ func (r *REST) Create(ctx context.Context, obj runtime.Object, createValidation
 		return nil, err
 	}
 
-	user, err := r.authenticator.AuthenticateTokenCredentialRequest(ctx, credentialRequest)
-	if err != nil {
-		traceFailureWithError(t, "token authentication", err)
+	user, err := r.authenticate(ctx, credentialRequest, start, t)
+	if errors.Is(err, errUnauthenticated) {
 		return failureResponse(), nil
 	}
-	if user == nil || user.GetName() == "" {
-		traceSuccess(t, user, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var uris []*url.URL
+	if r.uriSANTemplate != nil {
+		uris, err = r.uriSANTemplate.URIs(user)
+		if err != nil {
+			traceFailureWithError(t, "URI SAN template", err)
+			recordOutcome(ctx, credentialRequest, start, outcomeFailed)
+			return failureResponse(), nil
+		}
+	}
+
+	ttl, bootstrapUser := r.credentialTTL(user)
+	if ttl <= 0 {
+		traceValidationFailure(t, "bootstrap credential expired")
+		recordOutcome(ctx, credentialRequest, start, outcomeUnauthenticated)
 		return failureResponse(), nil
 	}
 
-	certPEM, keyPEM, err := r.issuer.IssuePEM(
+	// The API server only reads the username and groups from client certificates, so the UID and extras of the
+	// user cannot be carried by the certificate. They are returned in the status instead, and clients of the
+	// impersonation proxy keep them for their requests, see impersonator.New.
+	certPEM, keyPEM, err := r.issuer.IssueClientCertPEM(
 		pkix.Name{
 			CommonName:   user.GetName(),
 			Organization: user.GetGroups(),
 		},
-		[]string{},
-		clientCertificateTTL,
+		uris,
+		ttl,
 	)
 	if err != nil {
 		traceFailureWithError(t, "cert issuer", err)
+		if errors.Is(err, dynamiccertauthority.ErrNoSigningKey) {
+			// The user was authenticated, so don't make them think that their credentials were wrong.
+			recordOutcome(ctx, credentialRequest, start, outcomeNoWorkingStrategy)
+			return nil, r.noWorkingStrategyError()
+		}
+		recordOutcome(ctx, credentialRequest, start, outcomeFailed)
 		return failureResponse(), nil
 	}
 
+	// Report when the certificate really expires, since the issuer may not be able to honor the ttl exactly.
+	expiresAt, err := certificateNotAfter(certPEM)
+	if err != nil {
+		traceFailureWithError(t, "cert issuer", err)
+		recordOutcome(ctx, credentialRequest, start, outcomeFailed)
+		return failureResponse(), nil
+	}
+
+	// Only use up the BootstrapCredential once its certificate was issued. The certificate is discarded when another
+	// request redeemed it in the meantime.
+	if bootstrapUser != nil {
+		if err := bootstrapUser.Redeem(ctx); err != nil {
+			traceFailureWithError(t, "bootstrap credential redemption", err)
+			recordOutcome(ctx, credentialRequest, start, outcomeFailed)
+			return failureResponse(), nil
+		}
+	}
+
+	if r.issuanceLimiter != nil {
+		r.issuanceLimiter.Record(user.GetName())
+	}
+
 	traceSuccess(t, user, true)
+	recordOutcome(ctx, credentialRequest, start, outcomeIssued)
 
 	return &loginapi.TokenCredentialRequest{
 		Status: loginapi.TokenCredentialRequestStatus{
 			Credential: &loginapi.ClusterCredential{
-				ExpirationTimestamp:   metav1.NewTime(time.Now().UTC().Add(clientCertificateTTL)),
+				ExpirationTimestamp:   metav1.NewTime(expiresAt.UTC()),
 				ClientCertificateData: string(certPEM),
 				ClientKeyData:         string(keyPEM),
 			},
+			User: userInfo(user),
 		},
 	}, nil
 }
 
+// certificateNotAfter returns the expiration time of the first certificate in certPEM.
+func certificateNotAfter(certPEM []byte) (time.Time, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return time.Time{}, constable.Error("could not decode the issued certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse the issued certificate: %w", err)
+	}
+	return cert.NotAfter, nil
+}
+
+// userInfo returns the full identity of the authenticated user, as it is returned in the status.
+func userInfo(u user.Info) *authenticationv1.UserInfo {
+	info := &authenticationv1.UserInfo{
+		Username: u.GetName(),
+		UID:      u.GetUID(),
+		Groups:   u.GetGroups(),
+	}
+	if extra := u.GetExtra(); len(extra) > 0 {
+		info.Extra = make(map[string]authenticationv1.ExtraValue, len(extra))
+		for k, v := range extra {
+			info.Extra[k] = v
+		}
+	}
+	return info
+}
+
+// AuthenticateForImpersonation authenticates a TokenCredentialRequest which was sent to the impersonation proxy
+// instead of to this API. It applies the same caller policy, throttling and issuance limit as Create, and counts
+// each successful call as one issued credential, which the proxy may trust for the returned duration. The returned
+// errors are API statuses, so that the proxy can pass them on to the client.
+func (r *REST) AuthenticateForImpersonation(ctx context.Context, credentialRequest *loginapi.TokenCredentialRequest) (user.Info, time.Duration, error) {
+	start := time.Now()
+	t := trace.FromContext(ctx).Nest("impersonate", trace.Field{Key: "kind", Value: "TokenCredentialRequest"})
+	defer t.Log()
+
+	if len(credentialRequest.Spec.Token) == 0 {
+		traceValidationFailure(t, "token must be supplied")
+		return nil, 0, apierrors.NewUnauthorized("token must be supplied")
+	}
+
+	user, err := r.authenticate(ctx, credentialRequest, start, t)
+	if errors.Is(err, errUnauthenticated) {
+		return nil, 0, apierrors.NewUnauthorized("authentication failed")
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ttl, bootstrapUser := r.credentialTTL(user)
+	if ttl <= 0 {
+		traceValidationFailure(t, "bootstrap credential expired")
+		recordOutcome(ctx, credentialRequest, start, outcomeUnauthenticated)
+		return nil, 0, apierrors.NewUnauthorized("authentication failed")
+	}
+	if bootstrapUser != nil {
+		if err := bootstrapUser.Redeem(ctx); err != nil {
+			traceFailureWithError(t, "bootstrap credential redemption", err)
+			recordOutcome(ctx, credentialRequest, start, outcomeFailed)
+			return nil, 0, apierrors.NewUnauthorized("authentication failed")
+		}
+	}
+
+	if r.issuanceLimiter != nil {
+		r.issuanceLimiter.Record(user.GetName())
+	}
+	traceSuccess(t, user, true)
+	recordOutcome(ctx, credentialRequest, start, outcomeIssued)
+	return user, ttl, nil
+}
+
+// errUnauthenticated is returned by authenticate when the token was not accepted. The reason is only traced, so
+// that clients cannot learn why their token was rejected.
+const errUnauthenticated = constable.Error("unauthenticated")
+
+// authenticate applies the caller policy and the throttler, authenticates the token, and checks the issuance limit of
+// the user.
+func (r *REST) authenticate(ctx context.Context, credentialRequest *loginapi.TokenCredentialRequest, start time.Time, t *trace.Trace) (user.Info, error) {
+	authenticatorRef := credentialRequest.Spec.Authenticator
+	audit.AddAuditAnnotation(ctx, authenticatorAuditAnnotation, authenticatorRef.Kind+"/"+authenticatorRef.Name)
+
+	if r.callerPolicy != nil {
+		if err := r.callerPolicy.Allow(ctx, credentialRequest.Spec.Token); err != nil {
+			traceValidationFailure(t, err.Error())
+			recordOutcome(ctx, credentialRequest, start, outcomeCallerRefused)
+			return nil, apierrors.NewForbidden(r.resource, credentialRequest.Name, err)
+		}
+	}
+
+	if r.throttler != nil {
+		if err := r.checkThrottle(ctx, credentialRequest, t); err != nil {
+			recordOutcome(ctx, credentialRequest, start, outcomeThrottled)
+			return nil, err
+		}
+	}
+
+	user, err := r.authenticator.AuthenticateTokenCredentialRequest(ctx, credentialRequest)
+	if err != nil {
+		traceFailureWithError(t, "token authentication", err)
+		r.recordAuthenticationFailure(ctx, credentialRequest)
+		recordOutcome(ctx, credentialRequest, start, outcomeUnauthenticated)
+		return nil, errUnauthenticated
+	}
+	if user == nil || user.GetName() == "" {
+		traceSuccess(t, user, false)
+		r.recordAuthenticationFailure(ctx, credentialRequest)
+		recordOutcome(ctx, credentialRequest, start, outcomeUnauthenticated)
+		return nil, errUnauthenticated
+	}
+	if r.throttler != nil {
+		r.throttler.RecordSuccess(credentialRequest.Spec.Token)
+	}
+	audit.AddAuditAnnotation(ctx, usernameAuditAnnotation, user.GetName())
+	if uid := user.GetUID(); uid != "" {
+		audit.AddAuditAnnotation(ctx, uidAuditAnnotation, uid)
+	}
+
+	if r.issuanceLimiter != nil {
+		if err := r.checkIssuanceLimit(ctx, user, t); err != nil {
+			recordOutcome(ctx, credentialRequest, start, outcomeLimitExceeded)
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// credentialTTL returns how long a credential issued to the user is valid for, and the BootstrapCredential which
+// must be redeemed once it is issued, if any. The TTL is not positive when the BootstrapCredential already expired.
+func (r *REST) credentialTTL(u user.Info) (time.Duration, *bootstrapcredential.User) {
+	bootstrapUser, ok := u.(*bootstrapcredential.User)
+	if !ok {
+		return clientCertificateTTL, nil
+	}
+	// The token of a BootstrapCredential cannot be exchanged again, so its credential is valid until the
+	// BootstrapCredential expires, and the client keeps using it instead of repeating the exchange. Client
+	// certificates cannot be revoked, so the TTL never exceeds that of a BootstrapCredential, whatever its spec says.
+	ttl := bootstrapUser.ExpiresAt.Sub(r.clock.Now())
+	if ttl > bootstrapcredential.MaxTTL {
+		ttl = bootstrapcredential.MaxTTL
+	}
+	return ttl, bootstrapUser
+}
+
+func (r *REST) checkIssuanceLimit(ctx context.Context, userInfo user.Info, t *trace.Trace) error {
+	count, retryAfter, allowed := r.issuanceLimiter.Allow(userInfo.GetName())
+	if allowed {
+		return nil
+	}
+
+	// Record the breach in the audit log of the aggregated API server as well as in our own log.
+	audit.AddAuditAnnotation(ctx, issuanceLimitAuditAnnotation, fmt.Sprintf("%d certificates issued to %q in the last hour", count, userInfo.GetName()))
+	plog.Warning("refusing to issue client certificate because the user reached the limit of certificates per hour",
+		"username", userInfo.GetName(),
+		"userID", userInfo.GetUID(),
+		"issuedInLastHour", count,
+		"retryAfter", retryAfter.String(),
+	)
+	traceValidationFailure(t, "certificate issuance limit exceeded")
+
+	return perror.New(perror.CodeRateLimited, "too many client certificates were issued to this user in the last hour").
+		WithRetryAfter(retryAfter).
+		APIStatus()
+}
+
+func (r *REST) checkThrottle(ctx context.Context, req *loginapi.TokenCredentialRequest, t *trace.Trace) error {
+	retryAfter, allowed := r.throttler.Allow(sourceIPFrom(ctx), req.Spec.Token)
+	if allowed {
+		return nil
+	}
+
+	// The lockout itself was logged when it started, so don't log each refused request of a flood.
+	traceValidationFailure(t, "too many failed authentications from this source")
+
+	return perror.New(perror.CodeRateLimited, "too many failed authentication attempts, please try again later").
+		WithRetryAfter(retryAfter).
+		APIStatus()
+}
+
+func (r *REST) recordAuthenticationFailure(ctx context.Context, req *loginapi.TokenCredentialRequest) {
+	if r.throttler != nil {
+		r.throttler.RecordFailure(sourceIPFrom(ctx), req.Spec.Token)
+	}
+}
+
+// recordOutcome adds the outcome of the request to its audit event and to the metrics.
+func recordOutcome(ctx context.Context, req *loginapi.TokenCredentialRequest, start time.Time, outcome issuanceOutcome) {
+	audit.AddAuditAnnotation(ctx, outcomeAuditAnnotation, string(outcome))
+	recordMetrics(req.Spec.Authenticator.Kind, req.Spec.Authenticator.Name, outcome, time.Since(start))
+}
+
+// noWorkingStrategyError is returned when the user was authenticated but no cluster credential can be issued, because
+// none of the strategies of the CredentialIssuer are working. The cause lets clients recognize this case and tell the
+// user that the cluster is misconfigured rather than that their credentials are invalid.
+func (r *REST) noWorkingStrategyError() error {
+	msg := "the Pinniped Concierge has no working strategy for issuing cluster credentials, " +
+		"please ask a cluster administrator to check the status of the CredentialIssuer"
+	err := perror.New(perror.CodeUnavailable, msg).APIStatus()
+	details := err.ErrStatus.Details
+	details.Group = r.resource.Group
+	details.Kind = "TokenCredentialRequest"
+	details.Causes = append([]metav1.StatusCause{{
+		Type:    loginv1alpha1.NoWorkingStrategyCause,
+		Message: msg,
+	}}, details.Causes...)
+	return err
+}
+
 func validateRequest(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, options *metav1.CreateOptions, t *trace.Trace) (*loginapi.TokenCredentialRequest, error) {
 	credentialRequest, ok := obj.(*loginapi.TokenCredentialRequest)
 	if !ok {
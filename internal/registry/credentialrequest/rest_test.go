@@ -8,28 +8,41 @@ import (
 	"crypto/x509/pkix"
 	"errors"
 	"fmt"
+	"net/url"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/sclevine/spec"
 	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	auditinternal "k8s.io/apiserver/pkg/apis/audit"
 	"k8s.io/apiserver/pkg/authentication/user"
 	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/registry/rest"
+	metricstestutil "k8s.io/component-base/metrics/testutil"
 	"k8s.io/klog/v2"
 
+	authv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
 	loginapi "go.pinniped.dev/generated/latest/apis/concierge/login"
+	loginv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/login/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	"go.pinniped.dev/internal/bootstrapcredential"
+	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/certauthority/dynamiccertauthority"
 	"go.pinniped.dev/internal/mocks/credentialrequestmocks"
+	"go.pinniped.dev/internal/perror"
 	"go.pinniped.dev/internal/testutil"
 )
 
 func TestNew(t *testing.T) {
-	r := NewREST(nil, nil, schema.GroupResource{Group: "bears", Resource: "panda"})
+	r := NewREST(nil, nil, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{Group: "bears", Resource: "panda"})
 	require.NotNil(t, r)
 	require.False(t, r.NamespaceScoped())
 	require.Equal(t, []string{"pinniped"}, r.Categories())
@@ -64,9 +77,11 @@ func TestCreate(t *testing.T) {
 		var r *require.Assertions
 		var ctrl *gomock.Controller
 		var logger *testutil.TranscriptLogger
+		var testCert []byte
 
 		it.Before(func() {
 			r = require.New(t)
+			testCert = testCertPEM(t, clientCertificateTTL)
 			ctrl = gomock.NewController(t)
 			logger = testutil.NewTranscriptLogger(t)
 			klog.SetLogger(logger) // this is unfortunately a global logger, so can't run these tests in parallel :(
@@ -86,18 +101,19 @@ func TestCreate(t *testing.T) {
 					Name:   "test-user",
 					UID:    "test-user-uid",
 					Groups: []string{"test-group-1", "test-group-2"},
+					Extra:  map[string][]string{"test-key": {"test-value-1", "test-value-2"}},
 				}, nil)
 
 			issuer := credentialrequestmocks.NewMockCertIssuer(ctrl)
-			issuer.EXPECT().IssuePEM(
+			issuer.EXPECT().IssueClientCertPEM(
 				pkix.Name{
 					CommonName:   "test-user",
 					Organization: []string{"test-group-1", "test-group-2"}},
-				[]string{},
+				[]*url.URL(nil),
 				5*time.Minute,
-			).Return([]byte("test-cert"), []byte("test-key"), nil)
+			).Return(testCert, []byte("test-key"), nil)
 
-			storage := NewREST(requestAuthenticator, issuer, schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, issuer, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
 
 			response, err := callCreate(context.Background(), storage, req)
 
@@ -113,14 +129,134 @@ func TestCreate(t *testing.T) {
 				Status: loginapi.TokenCredentialRequestStatus{
 					Credential: &loginapi.ClusterCredential{
 						ExpirationTimestamp:   metav1.Time{},
-						ClientCertificateData: "test-cert",
+						ClientCertificateData: string(testCert),
 						ClientKeyData:         "test-key",
 					},
+					User: &authenticationv1.UserInfo{
+						Username: "test-user",
+						UID:      "test-user-uid",
+						Groups:   []string{"test-group-1", "test-group-2"},
+						Extra:    map[string]authenticationv1.ExtraValue{"test-key": {"test-value-1", "test-value-2"}},
+					},
 				},
 			})
 			requireOneLogStatement(r, logger, `"success" userID:test-user-uid,authenticated:true`)
 		})
 
+		it("CreateAddsAuditAnnotations", func() {
+			req := credentialRequest(loginapi.TokenCredentialRequestSpec{
+				Token:         "some token",
+				Authenticator: corev1.TypedLocalObjectReference{Kind: "WebhookAuthenticator", Name: "some-webhook"},
+			})
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(&user.DefaultInfo{Name: "test-user", UID: "test-user-uid"}, nil)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(nil, nil)
+
+			issuer := credentialrequestmocks.NewMockCertIssuer(ctrl)
+			issuer.EXPECT().IssueClientCertPEM(gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(testCert, []byte("test-key"), nil)
+
+			storage := NewREST(requestAuthenticator, issuer, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
+
+			event := &auditinternal.Event{Level: auditinternal.LevelMetadata}
+			_, err := callCreate(genericapirequest.WithAuditEvent(context.Background(), event), storage, req)
+			r.NoError(err)
+			r.Equal(map[string]string{
+				"concierge.pinniped.dev/authenticator":               "WebhookAuthenticator/some-webhook",
+				"concierge.pinniped.dev/username":                    "test-user",
+				"concierge.pinniped.dev/uid":                         "test-user-uid",
+				"concierge.pinniped.dev/client-certificate-issuance": "issued",
+			}, event.Annotations)
+
+			event = &auditinternal.Event{Level: auditinternal.LevelMetadata}
+			_, err = callCreate(genericapirequest.WithAuditEvent(context.Background(), event), storage, req)
+			r.NoError(err)
+			r.Equal(map[string]string{
+				"concierge.pinniped.dev/authenticator":               "WebhookAuthenticator/some-webhook",
+				"concierge.pinniped.dev/client-certificate-issuance": "unauthenticated",
+			}, event.Annotations)
+		})
+
+		it("CreateRecordsMetrics", func() {
+			req := credentialRequest(loginapi.TokenCredentialRequestSpec{
+				Token:         "some token",
+				Authenticator: corev1.TypedLocalObjectReference{Kind: "JWTAuthenticator", Name: "some-jwt-authenticator"},
+			})
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(&user.DefaultInfo{Name: "test-user"}, nil)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(nil, errors.New("some authenticator error")).Times(2)
+
+			issuer := credentialrequestmocks.NewMockCertIssuer(ctrl)
+			issuer.EXPECT().IssueClientCertPEM(gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(testCert, []byte("test-key"), nil)
+
+			storage := NewREST(requestAuthenticator, issuer, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
+			for i := 0; i < 3; i++ {
+				_, err := callCreate(context.Background(), storage, req)
+				r.NoError(err)
+			}
+
+			issued, err := metricstestutil.GetCounterMetricValue(tokenCredentialRequests.WithLabelValues("JWTAuthenticator", "some-jwt-authenticator", "issued"))
+			r.NoError(err)
+			r.Equal(float64(1), issued)
+			unauthenticated, err := metricstestutil.GetCounterMetricValue(tokenCredentialRequests.WithLabelValues("JWTAuthenticator", "some-jwt-authenticator", "unauthenticated"))
+			r.NoError(err)
+			r.Equal(float64(2), unauthenticated)
+			latencies, err := metricstestutil.GetHistogramMetricValue(tokenCredentialRequestDuration.WithLabelValues("JWTAuthenticator", "unauthenticated"))
+			r.NoError(err)
+			r.Greater(latencies, float64(0))
+		})
+
+		it("CreateAddsURISANWhenTemplateIsConfigured", func() {
+			req := validCredentialRequest()
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(&user.DefaultInfo{
+					Name:   "test user@example.com",
+					UID:    "test-user-uid",
+					Groups: []string{"test-group-1"},
+				}, nil)
+
+			wantURI, err := url.Parse("spiffe://cluster.example.com/user/test%20user@example.com/test-user-uid")
+			r.NoError(err)
+			issuer := credentialrequestmocks.NewMockCertIssuer(ctrl)
+			issuer.EXPECT().IssueClientCertPEM(
+				pkix.Name{
+					CommonName:   "test user@example.com",
+					Organization: []string{"test-group-1"}},
+				[]*url.URL{wantURI},
+				5*time.Minute,
+			).Return(testCert, []byte("test-key"), nil)
+
+			storage := NewREST(requestAuthenticator, issuer, nil, nil, NewURISANTemplate("spiffe://cluster.example.com/user/{username}/{uid}"), nil, clock.RealClock{}, schema.GroupResource{})
+
+			response, err := callCreate(context.Background(), storage, req)
+			r.NoError(err)
+			r.Equal(string(testCert), response.(*loginapi.TokenCredentialRequest).Status.Credential.ClientCertificateData)
+		})
+
+		it("CreateFailsWhenURISANTemplateRequiresMissingUID", func() {
+			req := validCredentialRequest()
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(&user.DefaultInfo{Name: "test-user"}, nil)
+
+			storage := NewREST(requestAuthenticator, nil, nil, nil, NewURISANTemplate("spiffe://cluster.example.com/user/{uid}"), nil, clock.RealClock{}, schema.GroupResource{})
+
+			response, err := callCreate(context.Background(), storage, req)
+
+			requireSuccessfulResponseWithAuthenticationFailureMessage(t, err, response)
+			requireOneLogStatement(r, logger, `"failure" failureType:URI SAN template,msg:URI SAN template "spiffe://cluster.example.com/user/{uid}" requires a UID, but user "test-user" does not have one`)
+		})
+
 		it("CreateFailsWithValidTokenWhenCertIssuerFails", func() {
 			req := validCredentialRequest()
 
@@ -133,23 +269,344 @@ func TestCreate(t *testing.T) {
 
 			issuer := credentialrequestmocks.NewMockCertIssuer(ctrl)
 			issuer.EXPECT().
-				IssuePEM(gomock.Any(), gomock.Any(), gomock.Any()).
+				IssueClientCertPEM(gomock.Any(), gomock.Any(), gomock.Any()).
 				Return(nil, nil, fmt.Errorf("some certificate authority error"))
 
-			storage := NewREST(requestAuthenticator, issuer, schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, issuer, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
 
 			response, err := callCreate(context.Background(), storage, req)
 			requireSuccessfulResponseWithAuthenticationFailureMessage(t, err, response)
 			requireOneLogStatement(r, logger, `"failure" failureType:cert issuer,msg:some certificate authority error`)
 		})
 
+		it("CreateReportsTheExpirationOfTheIssuedCertificate", func() {
+			req := validCredentialRequest()
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(&user.DefaultInfo{Name: "test-user"}, nil)
+
+			// Issuers like the cluster signer may not honor the requested TTL exactly.
+			issuer := credentialrequestmocks.NewMockCertIssuer(ctrl)
+			issuer.EXPECT().IssueClientCertPEM(gomock.Any(), gomock.Any(), 5*time.Minute).
+				Return(testCertPEM(t, 2*time.Minute), []byte("test-key"), nil)
+
+			storage := NewREST(requestAuthenticator, issuer, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
+
+			response, err := callCreate(context.Background(), storage, req)
+			r.NoError(err)
+			expires := response.(*loginapi.TokenCredentialRequest).Status.Credential.ExpirationTimestamp
+			r.InDelta(time.Now().Add(2*time.Minute).Unix(), expires.Unix(), 5)
+		})
+
+		it("CreateFailsWithValidTokenWhenTheIssuedCertificateIsInvalid", func() {
+			req := validCredentialRequest()
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(&user.DefaultInfo{Name: "test-user"}, nil)
+
+			issuer := credentialrequestmocks.NewMockCertIssuer(ctrl)
+			issuer.EXPECT().IssueClientCertPEM(gomock.Any(), gomock.Any(), gomock.Any()).
+				Return([]byte("not-a-certificate"), []byte("test-key"), nil)
+
+			storage := NewREST(requestAuthenticator, issuer, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
+
+			response, err := callCreate(context.Background(), storage, req)
+			requireSuccessfulResponseWithAuthenticationFailureMessage(t, err, response)
+			requireOneLogStatement(r, logger, `"failure" failureType:cert issuer,msg:could not decode the issued certificate`)
+		})
+
+		it("CreateRedeemsABootstrapCredentialOnlyAfterIssuingItsCertificate", func() {
+			req := validCredentialRequest()
+			expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+			credentials := pinnipedfake.NewSimpleClientset(&authv1alpha1.BootstrapCredential{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+				Spec: authv1alpha1.BootstrapCredentialSpec{
+					TokenHash: bootstrapcredential.HashToken(req.Spec.Token),
+					Username:  "test-user",
+					ExpiresAt: metav1.NewTime(expiresAt),
+				},
+			}).AuthenticationV1alpha1().BootstrapCredentials()
+			bootstrapAuthenticator := bootstrapcredential.New("test-name", credentials, clock.RealClock{})
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).Times(2).
+				DoAndReturn(func(ctx context.Context, req *loginapi.TokenCredentialRequest) (user.Info, error) {
+					resp, _, err := bootstrapAuthenticator.AuthenticateToken(ctx, req.Spec.Token)
+					return resp.User, err
+				})
+
+			// The certificate is valid until the BootstrapCredential expires.
+			issuer := credentialrequestmocks.NewMockCertIssuer(ctrl)
+			gomock.InOrder(
+				issuer.EXPECT().
+					IssueClientCertPEM(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil, nil, fmt.Errorf("some certificate authority error")),
+				issuer.EXPECT().
+					IssueClientCertPEM(pkix.Name{CommonName: "test-user", Organization: []string{}}, []*url.URL(nil), gomock.Any()).
+					DoAndReturn(func(_ pkix.Name, _ []*url.URL, ttl time.Duration) ([]byte, []byte, error) {
+						r.InDelta(time.Hour, ttl, float64(5*time.Second))
+						return testCertPEM(t, ttl), []byte("test-key"), nil
+					}),
+			)
+
+			storage := NewREST(requestAuthenticator, issuer, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
+
+			// A failure to issue the certificate does not use up the BootstrapCredential.
+			response, err := callCreate(context.Background(), storage, req)
+			requireSuccessfulResponseWithAuthenticationFailureMessage(t, err, response)
+			cred, err := credentials.Get(context.Background(), "test-name", metav1.GetOptions{})
+			r.NoError(err)
+			r.Nil(cred.Status.RedeemedAt)
+
+			response, err = callCreate(context.Background(), storage, req)
+			r.NoError(err)
+			r.NotEmpty(response.(*loginapi.TokenCredentialRequest).Status.Credential.ClientCertificateData)
+			r.InDelta(expiresAt.Unix(), response.(*loginapi.TokenCredentialRequest).Status.Credential.ExpirationTimestamp.Unix(), 5)
+			cred, err = credentials.Get(context.Background(), "test-name", metav1.GetOptions{})
+			r.NoError(err)
+			r.NotNil(cred.Status.RedeemedAt)
+		})
+
+		it("CreateFailsWithAServiceUnavailableErrorWhenThereIsNoWorkingStrategy", func() {
+			req := validCredentialRequest()
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(&user.DefaultInfo{Name: "test-user"}, nil)
+
+			issuer := credentialrequestmocks.NewMockCertIssuer(ctrl)
+			issuer.EXPECT().
+				IssueClientCertPEM(gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(nil, nil, dynamiccertauthority.ErrNoSigningKey)
+
+			storage := NewREST(requestAuthenticator, issuer, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{Group: "login.concierge.pinniped.dev"})
+
+			response, err := callCreate(context.Background(), storage, req)
+			requireAPIError(t, response, err, apierrors.IsServiceUnavailable, "no working strategy for issuing cluster credentials")
+			var status apierrors.APIStatus
+			r.True(errors.As(err, &status))
+			r.Equal("login.concierge.pinniped.dev", status.Status().Details.Group)
+			r.Len(status.Status().Details.Causes, 2)
+			r.Equal(loginv1alpha1.NoWorkingStrategyCause, status.Status().Details.Causes[0].Type)
+			r.Equal(metav1.StatusCause{Type: perror.CauseType, Message: "unavailable"}, status.Status().Details.Causes[1])
+			requireOneLogStatement(r, logger, `"failure" failureType:cert issuer,msg:no signing key is available`)
+		})
+
+		it("CreateFailsWhenTheUserHasReachedTheIssuanceLimit", func() {
+			req := validCredentialRequest()
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(&user.DefaultInfo{Name: "test-user", UID: "test-user-uid"}, nil).Times(2)
+
+			issuer := credentialrequestmocks.NewMockCertIssuer(ctrl)
+			issuer.EXPECT().IssueClientCertPEM(gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(testCert, []byte("test-key"), nil).Times(1)
+
+			fakeClock := clock.NewFakeClock(time.Now())
+			storage := NewREST(requestAuthenticator, issuer, NewIssuanceLimiter(1, fakeClock), nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
+
+			response, err := callCreate(context.Background(), storage, req)
+			r.NoError(err)
+			r.NotNil(response.(*loginapi.TokenCredentialRequest).Status.Credential)
+
+			fakeClock.Step(15 * time.Minute)
+			event := &auditinternal.Event{Level: auditinternal.LevelMetadata}
+			response, err = callCreate(genericapirequest.WithAuditEvent(context.Background(), event), storage, req)
+			requireAPIError(t, response, err, apierrors.IsTooManyRequests, "too many client certificates were issued to this user in the last hour")
+			retryAfterSeconds, ok := apierrors.SuggestsClientDelay(err)
+			r.True(ok)
+			r.Equal(45*60, retryAfterSeconds)
+			pErr, ok := perror.As(err)
+			r.True(ok)
+			r.Equal(perror.CodeRateLimited, pErr.Code())
+			r.Equal("1 certificates issued to \"test-user\" in the last hour", event.Annotations["concierge.pinniped.dev/client-certificate-issuance-limit-exceeded"])
+			r.Equal("limit-exceeded", event.Annotations["concierge.pinniped.dev/client-certificate-issuance"])
+
+			transcript := logger.Transcript()
+			r.Len(transcript, 3)
+			r.Contains(transcript[0].Message, `"success" userID:test-user-uid,authenticated:true`)
+			r.Contains(transcript[1].Message, "refusing to issue client certificate because the user reached the limit of certificates per hour")
+			r.Contains(transcript[2].Message, `"failure" failureType:request validation,msg:certificate issuance limit exceeded`)
+		})
+
+		it("CreateOnlyCountsTheCertificatesWhichWereIssuedTowardsTheIssuanceLimit", func() {
+			req := validCredentialRequest()
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(&user.DefaultInfo{Name: "test-user", UID: "test-user-uid"}, nil).Times(2)
+
+			issuer := credentialrequestmocks.NewMockCertIssuer(ctrl)
+			gomock.InOrder(
+				issuer.EXPECT().IssueClientCertPEM(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil, nil, fmt.Errorf("some certificate authority error")),
+				issuer.EXPECT().IssueClientCertPEM(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(testCert, []byte("test-key"), nil),
+			)
+
+			storage := NewREST(requestAuthenticator, issuer, NewIssuanceLimiter(1, clock.NewFakeClock(time.Now())), nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
+
+			response, err := callCreate(context.Background(), storage, req)
+			r.NoError(err)
+			r.Nil(response.(*loginapi.TokenCredentialRequest).Status.Credential)
+
+			response, err = callCreate(context.Background(), storage, req)
+			r.NoError(err)
+			r.NotNil(response.(*loginapi.TokenCredentialRequest).Status.Credential)
+		})
+
+		it("CreateFailsWithAForbiddenErrorWhenTheCallerIsRefused", func() {
+			req := validCredentialRequest()
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			storage := NewREST(requestAuthenticator, nil, nil, nil, nil, NewCallerPolicy(true, nil), clock.RealClock{}, schema.GroupResource{Group: "login.concierge.pinniped.dev", Resource: "tokencredentialrequests"})
+
+			event := &auditinternal.Event{Level: auditinternal.LevelMetadata}
+			ctx := genericapirequest.WithUser(genericapirequest.WithAuditEvent(context.Background(), event), &user.DefaultInfo{
+				Name:   user.Anonymous,
+				Groups: []string{user.AllUnauthenticated},
+			})
+			response, err := callCreate(ctx, storage, req)
+			requireAPIError(t, response, err, apierrors.IsForbidden, "callers must authenticate to the cluster before requesting a credential")
+			r.Equal("caller-refused", event.Annotations["concierge.pinniped.dev/client-certificate-issuance"])
+			requireOneLogStatement(r, logger, `"failure" failureType:request validation,msg:callers must authenticate to the cluster before requesting a credential`)
+		})
+
+		it("CreateIsThrottledAfterTooManyFailedAuthentications", func() {
+			req := validCredentialRequest()
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(nil, errors.New("invalid token")).Times(sourceFreeFailures + 1)
+
+			storage := NewREST(requestAuthenticator, nil, nil, NewThrottler(clock.NewFakeClock(time.Now())), nil, nil, clock.RealClock{}, schema.GroupResource{})
+			ctx := WithSourceIP(context.Background(), "192.0.2.1")
+
+			for i := 0; i < sourceFreeFailures+1; i++ {
+				response, err := callCreate(ctx, storage, req)
+				requireSuccessfulResponseWithAuthenticationFailureMessage(t, err, response)
+			}
+
+			event := &auditinternal.Event{Level: auditinternal.LevelMetadata}
+			response, err := callCreate(genericapirequest.WithAuditEvent(ctx, event), storage, req)
+			requireAPIError(t, response, err, apierrors.IsTooManyRequests, "too many failed authentication attempts, please try again later")
+			retryAfterSeconds, ok := apierrors.SuggestsClientDelay(err)
+			r.True(ok)
+			r.Equal(1, retryAfterSeconds)
+			r.Equal("throttled", event.Annotations["concierge.pinniped.dev/client-certificate-issuance"])
+
+			throttled, err := metricstestutil.GetCounterMetricValue(tokenCredentialRequestsThrottled.CounterMetric)
+			r.NoError(err)
+			r.GreaterOrEqual(throttled, float64(1))
+
+			transcript := logger.Transcript()
+			r.Contains(transcript[len(transcript)-1].Message, `"failure" failureType:request validation,msg:too many failed authentications from this source`)
+		})
+
+		it("AuthenticateForImpersonationCountsEachAuthenticationTowardsTheIssuanceLimit", func() {
+			req := validCredentialRequest()
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(&user.DefaultInfo{Name: "test-user", UID: "test-user-uid"}, nil).Times(2)
+
+			storage := NewREST(requestAuthenticator, nil, NewIssuanceLimiter(1, clock.NewFakeClock(time.Now())), nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
+
+			userInfo, ttl, err := storage.AuthenticateForImpersonation(context.Background(), req)
+			r.NoError(err)
+			r.Equal("test-user", userInfo.GetName())
+			r.Equal(5*time.Minute, ttl)
+
+			userInfo, _, err = storage.AuthenticateForImpersonation(context.Background(), req)
+			r.Nil(userInfo)
+			r.True(apierrors.IsTooManyRequests(err))
+			r.EqualError(err, "too many client certificates were issued to this user in the last hour")
+		})
+
+		it("AuthenticateForImpersonationAppliesTheCallerPolicy", func() {
+			req := validCredentialRequest()
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			storage := NewREST(requestAuthenticator, nil, nil, nil, nil, NewCallerPolicy(true, nil), clock.RealClock{}, schema.GroupResource{Group: "login.concierge.pinniped.dev", Resource: "tokencredentialrequests"})
+
+			// The clients of the impersonation proxy never authenticate to the cluster.
+			userInfo, _, err := storage.AuthenticateForImpersonation(context.Background(), req)
+			r.Nil(userInfo)
+			r.True(apierrors.IsForbidden(err))
+			r.Contains(err.Error(), "callers must authenticate to the cluster before requesting a credential")
+		})
+
+		it("AuthenticateForImpersonationIsThrottledAfterTooManyFailedAuthentications", func() {
+			req := validCredentialRequest()
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(nil, errors.New("invalid token")).Times(sourceFreeFailures + 1)
+
+			storage := NewREST(requestAuthenticator, nil, nil, NewThrottler(clock.NewFakeClock(time.Now())), nil, nil, clock.RealClock{}, schema.GroupResource{})
+			ctx := WithSourceIP(context.Background(), "192.0.2.1")
+
+			for i := 0; i < sourceFreeFailures+1; i++ {
+				userInfo, _, err := storage.AuthenticateForImpersonation(ctx, req)
+				r.Nil(userInfo)
+				r.True(apierrors.IsUnauthorized(err))
+			}
+
+			_, _, err := storage.AuthenticateForImpersonation(ctx, req)
+			r.True(apierrors.IsTooManyRequests(err))
+			r.EqualError(err, "too many failed authentication attempts, please try again later")
+		})
+
+		it("AuthenticateForImpersonationRedeemsABootstrapCredential", func() {
+			req := validCredentialRequest()
+
+			expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+			credentials := pinnipedfake.NewSimpleClientset(&authv1alpha1.BootstrapCredential{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-name"},
+				Spec: authv1alpha1.BootstrapCredentialSpec{
+					TokenHash: bootstrapcredential.HashToken(req.Spec.Token),
+					Username:  "test-user",
+					ExpiresAt: metav1.NewTime(expiresAt),
+				},
+			}).AuthenticationV1alpha1().BootstrapCredentials()
+			bootstrapAuthenticator := bootstrapcredential.New("test-name", credentials, clock.RealClock{})
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).Times(2).
+				DoAndReturn(func(ctx context.Context, req *loginapi.TokenCredentialRequest) (user.Info, error) {
+					resp, _, err := bootstrapAuthenticator.AuthenticateToken(ctx, req.Spec.Token)
+					if resp == nil {
+						return nil, err
+					}
+					return resp.User, err
+				})
+
+			storage := NewREST(requestAuthenticator, nil, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
+
+			userInfo, ttl, err := storage.AuthenticateForImpersonation(context.Background(), req)
+			r.NoError(err)
+			r.Equal("test-user", userInfo.GetName())
+			r.InDelta(time.Hour, ttl, float64(5*time.Second))
+			cred, err := credentials.Get(context.Background(), "test-name", metav1.GetOptions{})
+			r.NoError(err)
+			r.NotNil(cred.Status.RedeemedAt)
+
+			// The token of the BootstrapCredential can only be used once.
+			userInfo, _, err = storage.AuthenticateForImpersonation(context.Background(), req)
+			r.Nil(userInfo)
+			r.True(apierrors.IsUnauthorized(err))
+		})
+
 		it("CreateSucceedsWithAnUnauthenticatedStatusWhenGivenATokenAndTheWebhookReturnsNilUser", func() {
 			req := validCredentialRequest()
 
 			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
 			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).Return(nil, nil)
 
-			storage := NewREST(requestAuthenticator, nil, schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, nil, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
 
 			response, err := callCreate(context.Background(), storage, req)
 
@@ -164,7 +621,7 @@ func TestCreate(t *testing.T) {
 			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
 				Return(nil, errors.New("some webhook error"))
 
-			storage := NewREST(requestAuthenticator, nil, schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, nil, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
 
 			response, err := callCreate(context.Background(), storage, req)
 
@@ -179,7 +636,7 @@ func TestCreate(t *testing.T) {
 			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
 				Return(&user.DefaultInfo{Name: ""}, nil)
 
-			storage := NewREST(requestAuthenticator, nil, schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, nil, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
 
 			response, err := callCreate(context.Background(), storage, req)
 
@@ -189,7 +646,7 @@ func TestCreate(t *testing.T) {
 
 		it("CreateFailsWhenGivenTheWrongInputType", func() {
 			notACredentialRequest := runtime.Unknown{}
-			response, err := NewREST(nil, nil, schema.GroupResource{}).Create(
+			response, err := NewREST(nil, nil, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{}).Create(
 				genericapirequest.NewContext(),
 				&notACredentialRequest,
 				rest.ValidateAllObjectFunc,
@@ -200,7 +657,7 @@ func TestCreate(t *testing.T) {
 		})
 
 		it("CreateFailsWhenTokenValueIsEmptyInRequest", func() {
-			storage := NewREST(nil, nil, schema.GroupResource{})
+			storage := NewREST(nil, nil, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
 			response, err := callCreate(context.Background(), storage, credentialRequest(loginapi.TokenCredentialRequestSpec{
 				Token: "",
 			}))
@@ -211,7 +668,7 @@ func TestCreate(t *testing.T) {
 		})
 
 		it("CreateFailsWhenValidationFails", func() {
-			storage := NewREST(nil, nil, schema.GroupResource{})
+			storage := NewREST(nil, nil, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
 			response, err := storage.Create(
 				context.Background(),
 				validCredentialRequest(),
@@ -231,7 +688,7 @@ func TestCreate(t *testing.T) {
 			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req.DeepCopy()).
 				Return(&user.DefaultInfo{Name: "test-user"}, nil)
 
-			storage := NewREST(requestAuthenticator, successfulIssuer(ctrl), schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, successfulIssuer(ctrl, testCert), nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
 			response, err := storage.Create(
 				context.Background(),
 				req,
@@ -252,7 +709,7 @@ func TestCreate(t *testing.T) {
 			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req.DeepCopy()).
 				Return(&user.DefaultInfo{Name: "test-user"}, nil)
 
-			storage := NewREST(requestAuthenticator, successfulIssuer(ctrl), schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, successfulIssuer(ctrl, testCert), nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{})
 			validationFunctionWasCalled := false
 			var validationFunctionSawTokenValue string
 			response, err := storage.Create(
@@ -272,7 +729,7 @@ func TestCreate(t *testing.T) {
 		})
 
 		it("CreateFailsWhenRequestOptionsDryRunIsNotEmpty", func() {
-			response, err := NewREST(nil, nil, schema.GroupResource{}).Create(
+			response, err := NewREST(nil, nil, nil, nil, nil, nil, clock.RealClock{}, schema.GroupResource{}).Create(
 				genericapirequest.NewContext(),
 				validCredentialRequest(),
 				rest.ValidateAllObjectFunc,
@@ -287,6 +744,33 @@ func TestCreate(t *testing.T) {
 	}, spec.Sequential())
 }
 
+func TestCredentialTTL(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	storage := NewREST(nil, nil, nil, nil, nil, nil, clock.NewFakeClock(now), schema.GroupResource{})
+
+	ttl, bootstrapUser := storage.credentialTTL(&user.DefaultInfo{Name: "test-user"})
+	require.Equal(t, clientCertificateTTL, ttl)
+	require.Nil(t, bootstrapUser)
+
+	for _, tt := range []struct {
+		name      string
+		expiresAt time.Time
+		wantTTL   time.Duration
+	}{
+		{name: "expired", expiresAt: now.Add(-time.Minute), wantTTL: -time.Minute},
+		{name: "until expiry", expiresAt: now.Add(time.Hour), wantTTL: time.Hour},
+		{name: "capped at the max TTL", expiresAt: now.Add(365 * 24 * time.Hour), wantTTL: bootstrapcredential.MaxTTL},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			u := &bootstrapcredential.User{ExpiresAt: tt.expiresAt}
+			ttl, bootstrapUser := storage.credentialTTL(u)
+			require.Equal(t, tt.wantTTL, ttl)
+			require.Same(t, u, bootstrapUser)
+		})
+	}
+}
+
 func requireOneLogStatement(r *require.Assertions, logger *testutil.TranscriptLogger, messageContains string) {
 	transcript := logger.Transcript()
 	r.Len(transcript, 1)
@@ -342,14 +826,25 @@ func requireSuccessfulResponseWithAuthenticationFailureMessage(t *testing.T, err
 	})
 }
 
-func successfulIssuer(ctrl *gomock.Controller) CertIssuer {
+func successfulIssuer(ctrl *gomock.Controller, testCert []byte) CertIssuer {
 	issuer := credentialrequestmocks.NewMockCertIssuer(ctrl)
 	issuer.EXPECT().
-		IssuePEM(gomock.Any(), gomock.Any(), gomock.Any()).
-		Return([]byte("test-cert"), []byte("test-key"), nil)
+		IssueClientCertPEM(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(testCert, []byte("test-key"), nil)
 	return issuer
 }
 
+// testCertPEM returns a client certificate which is valid for the ttl, since the expiration of the credential is read
+// from the issued certificate.
+func testCertPEM(t *testing.T, ttl time.Duration) []byte {
+	t.Helper()
+	ca, err := certauthority.New(pkix.Name{CommonName: "test-ca"}, time.Hour)
+	require.NoError(t, err)
+	certPEM, _, err := ca.IssueClientCertPEM(pkix.Name{CommonName: "test-user"}, nil, ttl)
+	require.NoError(t, err)
+	return certPEM
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
@@ -0,0 +1,103 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type sourceIPContextKey struct{}
+
+// WithSourceIP returns a copy of ctx which carries the IP address of the client of the request, so that failed
+// authentications can be throttled per source.
+func WithSourceIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, sourceIPContextKey{}, ip)
+}
+
+func sourceIPFrom(ctx context.Context) string {
+	ip, _ := ctx.Value(sourceIPContextKey{}).(string)
+	return ip
+}
+
+// TrustedProxies decides which address of a request is the address of its client. By default, it is the address of
+// the peer of the connection, because the X-Forwarded-For header can be set to anything by the client. Only proxies
+// in the configured networks, e.g. the Kubernetes API server which proxies the aggregated API or the load balancer
+// in front of the impersonation proxy, are trusted to add the address of their own client to that header. It is safe
+// for concurrent use.
+type TrustedProxies struct {
+	mu       sync.RWMutex
+	networks []*net.IPNet
+}
+
+// NewTrustedProxies returns TrustedProxies which trust the given CIDRs. Without any, no proxy is trusted.
+func NewTrustedProxies(cidrs []string) (*TrustedProxies, error) {
+	p := &TrustedProxies{}
+	if err := p.Set(cidrs); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Set changes the trusted CIDRs, e.g. when the config file was changed.
+func (p *TrustedProxies) Set(cidrs []string) error {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy CIDR %q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.networks = networks
+	return nil
+}
+
+// SourceIP returns the IP address of the client of the request, or "" when it cannot be determined. Starting from the
+// peer of the connection, each trusted proxy is replaced by the entry which it appended to X-Forwarded-For, until an
+// address is not a trusted proxy or the header has no more valid entries.
+func (p *TrustedProxies) SourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+
+	var entries []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		entries = append(entries, strings.Split(value, ",")...)
+	}
+	for i := len(entries) - 1; i >= 0 && p.trusts(ip); i-- {
+		forwardedFor := net.ParseIP(strings.TrimSpace(entries[i]))
+		if forwardedFor == nil {
+			break
+		}
+		ip = forwardedFor
+	}
+	return ip.String()
+}
+
+func (p *TrustedProxies) trusts(ip net.IP) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, network := range p.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
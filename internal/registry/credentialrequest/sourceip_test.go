@@ -0,0 +1,78 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceIPFrom(t *testing.T) {
+	require.Empty(t, sourceIPFrom(context.Background()))
+	require.Equal(t, "192.0.2.1", sourceIPFrom(WithSourceIP(context.Background(), "192.0.2.1")))
+}
+
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   []string
+		wantSourceIP   string
+	}{
+		{name: "no forwarded header", remoteAddr: "10.0.0.1:443", wantSourceIP: "10.0.0.1"},
+		{name: "untrusted peer", remoteAddr: "10.0.0.1:443", forwardedFor: []string{"192.0.2.7"}, wantSourceIP: "10.0.0.1"},
+		{name: "trusted peer", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.1:443", forwardedFor: []string{"192.0.2.7"}, wantSourceIP: "192.0.2.7"},
+		{
+			name:           "spoofed earlier entries",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:443",
+			forwardedFor:   []string{"1.2.3.4, 192.0.2.7", "198.51.100.3"},
+			wantSourceIP:   "198.51.100.3",
+		},
+		{
+			name:           "chain of trusted proxies",
+			trustedProxies: []string{"10.0.0.0/8", "2001:db8::/32"},
+			remoteAddr:     "10.0.0.1:443",
+			forwardedFor:   []string{"1.2.3.4, 192.0.2.7, 2001:db8::2", "10.1.2.3"},
+			wantSourceIP:   "192.0.2.7",
+		},
+		{
+			name:           "only trusted proxies",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:443",
+			forwardedFor:   []string{"10.0.0.2"},
+			wantSourceIP:   "10.0.0.2",
+		},
+		{
+			name:           "invalid forwarded entry",
+			trustedProxies: []string{"2001:db8::/32"},
+			remoteAddr:     "[2001:db8::1]:443",
+			forwardedFor:   []string{"not-an-ip"},
+			wantSourceIP:   "2001:db8::1",
+		},
+		{name: "invalid remote address", remoteAddr: "bogus", wantSourceIP: ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			trustedProxies, err := NewTrustedProxies(tt.trustedProxies)
+			require.NoError(t, err)
+			r := &http.Request{RemoteAddr: tt.remoteAddr, Header: http.Header{}}
+			for _, v := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			require.Equal(t, tt.wantSourceIP, trustedProxies.SourceIP(r))
+		})
+	}
+
+	var nilProxies *TrustedProxies
+	require.Equal(t, "10.0.0.1", nilProxies.SourceIP(&http.Request{RemoteAddr: "10.0.0.1:443", Header: http.Header{"X-Forwarded-For": {"192.0.2.7"}}}))
+
+	_, err := NewTrustedProxies([]string{"10.0.0.1"})
+	require.EqualError(t, err, `invalid trusted proxy CIDR "10.0.0.1": invalid CIDR address: 10.0.0.1`)
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	loginapi "go.pinniped.dev/generated/latest/apis/concierge/login"
+)
+
+//nolint: gochecknoglobals
+var tableColumns = []metav1.TableColumnDefinition{
+	{Name: "Name", Type: "string", Format: "name", Description: "Name of the TokenCredentialRequest."},
+	{Name: "Authenticator", Type: "string", Description: "Kind and name of the authenticator which was requested."},
+	{Name: "Expires", Type: "string", Format: "date-time", Description: "Expiration time of the issued credential."},
+	{Name: "Message", Type: "string", Description: "Reason why no credential was issued."},
+}
+
+// tableConvertor shows the authenticator and the outcome of TokenCredentialRequests, e.g. in the output of
+// "kubectl create" without "-o yaml".
+type tableConvertor struct {
+	resource schema.GroupResource
+}
+
+func (c tableConvertor) ConvertToTable(_ context.Context, obj runtime.Object, tableOptions runtime.Object) (*metav1.Table, error) {
+	table := &metav1.Table{}
+	var items []loginapi.TokenCredentialRequest
+	switch o := obj.(type) {
+	case *loginapi.TokenCredentialRequestList:
+		table.ResourceVersion = o.ResourceVersion
+		table.Continue = o.Continue
+		table.RemainingItemCount = o.RemainingItemCount
+		items = o.Items
+	case *loginapi.TokenCredentialRequest:
+		table.ResourceVersion = o.ResourceVersion
+		items = []loginapi.TokenCredentialRequest{*o}
+	default:
+		return nil, fmt.Errorf("the resource %s does not support being converted to a Table", c.resource)
+	}
+
+	if opt, ok := tableOptions.(*metav1.TableOptions); !ok || !opt.NoHeaders {
+		table.ColumnDefinitions = tableColumns
+	}
+	for i := range items {
+		item := &items[i]
+		authenticator := ""
+		if item.Spec.Authenticator.Kind != "" || item.Spec.Authenticator.Name != "" {
+			authenticator = item.Spec.Authenticator.Kind + "/" + item.Spec.Authenticator.Name
+		}
+		expires, message := "", ""
+		if item.Status.Credential != nil {
+			expires = item.Status.Credential.ExpirationTimestamp.UTC().Format(time.RFC3339)
+		}
+		if item.Status.Message != nil {
+			message = *item.Status.Message
+		}
+		table.Rows = append(table.Rows, metav1.TableRow{
+			Cells:  []interface{}{item.Name, authenticator, expires, message},
+			Object: runtime.RawExtension{Object: item},
+		})
+	}
+	return table, nil
+}
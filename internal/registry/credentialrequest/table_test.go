@@ -0,0 +1,61 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	loginapi "go.pinniped.dev/generated/latest/apis/concierge/login"
+)
+
+func TestConvertToTable(t *testing.T) {
+	c := tableConvertor{resource: schema.GroupResource{Group: "bears", Resource: "panda"}}
+	expires := metav1.NewTime(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	apiGroup := "authentication.concierge.pinniped.dev"
+	message := "authentication failed"
+
+	list := &loginapi.TokenCredentialRequestList{
+		ListMeta: metav1.ListMeta{ResourceVersion: "42"},
+		Items: []loginapi.TokenCredentialRequest{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "issued"},
+				Spec: loginapi.TokenCredentialRequestSpec{
+					Authenticator: corev1.TypedLocalObjectReference{APIGroup: &apiGroup, Kind: "JWTAuthenticator", Name: "some-jwt"},
+				},
+				Status: loginapi.TokenCredentialRequestStatus{
+					Credential: &loginapi.ClusterCredential{ExpirationTimestamp: expires},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "failed"},
+				Status:     loginapi.TokenCredentialRequestStatus{Message: &message},
+			},
+		},
+	}
+
+	table, err := c.ConvertToTable(context.Background(), list, nil)
+	require.NoError(t, err)
+	require.Equal(t, "42", table.ResourceVersion)
+	require.Equal(t, tableColumns, table.ColumnDefinitions)
+	require.Len(t, table.Rows, 2)
+	require.Equal(t, []interface{}{"issued", "JWTAuthenticator/some-jwt", "2021-03-04T05:06:07Z", ""}, table.Rows[0].Cells)
+	require.Equal(t, []interface{}{"failed", "", "", message}, table.Rows[1].Cells)
+	require.Equal(t, &list.Items[1], table.Rows[1].Object.Object)
+
+	table, err = c.ConvertToTable(context.Background(), &list.Items[0], &metav1.TableOptions{NoHeaders: true})
+	require.NoError(t, err)
+	require.Nil(t, table.ColumnDefinitions)
+	require.Len(t, table.Rows, 1)
+	require.Equal(t, "issued", table.Rows[0].Cells[0])
+
+	_, err = c.ConvertToTable(context.Background(), &metav1.APIGroup{}, nil)
+	require.EqualError(t, err, "the resource panda.bears does not support being converted to a Table")
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// sourceFreeFailures is how many failed authentications are allowed from one source IP before it is locked out.
+	// It allows for a few users behind a shared address who mistype or present expired tokens.
+	sourceFreeFailures = 20
+
+	// throttleBaseDelay is how long the first lockout lasts. Each further failure doubles it, up to throttleMaxDelay.
+	throttleBaseDelay = time.Second
+	throttleMaxDelay  = 5 * time.Minute
+
+	// throttleForgetAfter is how long a source has to be quiet until its failures are forgotten, and how long a
+	// token which was authenticated successfully is remembered.
+	throttleForgetAfter = 15 * time.Minute
+)
+
+//nolint: gochecknoglobals
+var (
+	tokenCredentialRequestsThrottled = metrics.NewCounter(&metrics.CounterOpts{
+		Name:           "pinniped_concierge_token_credential_requests_throttled_total",
+		Help:           "Number of TokenCredentialRequests which were refused because their source IP had too many failed authentications.",
+		StabilityLevel: metrics.ALPHA,
+	})
+	tokenCredentialRequestLockouts = metrics.NewCounter(&metrics.CounterOpts{
+		Name:           "pinniped_concierge_token_credential_request_lockouts_total",
+		Help:           "Number of times a source IP was locked out after too many failed authentications.",
+		StabilityLevel: metrics.ALPHA,
+	})
+)
+
+//nolint: gochecknoinits
+func init() {
+	legacyregistry.MustRegister(tokenCredentialRequestsThrottled, tokenCredentialRequestLockouts)
+}
+
+// Throttler slows down attackers who guess tokens. It counts the failed authentications of each source IP, as
+// determined by TrustedProxies, and once a source has failed too often it refuses further requests from it with an
+// exponentially growing backoff. Requests without a source IP are never throttled.
+//
+// Many users can share one source IP, e.g. behind a NAT gateway, or when no proxy is trusted and every request seems
+// to come from the Kubernetes API server. So that they do not lock each other out, a lockout does not apply to tokens
+// which were authenticated successfully in the last throttleForgetAfter, which are only kept as SHA-256 hashes. Users
+// with a token which the Throttler has not seen yet have to wait for the lockout to end.
+//
+// Each Concierge pod has its own Throttler and they do not share their counts, so an attacker whose requests are
+// spread over N replicas gets N times sourceFreeFailures guesses before every replica has locked them out.
+//
+// It is safe for concurrent use.
+type Throttler struct {
+	clock clock.Clock
+
+	mu          sync.Mutex
+	failures    map[string]*failureRecord
+	knownTokens map[[sha256.Size]byte]time.Time
+	lastSweep   time.Time
+}
+
+type failureRecord struct {
+	count        int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// NewThrottler returns an empty Throttler.
+func NewThrottler(clock clock.Clock) *Throttler {
+	return &Throttler{
+		clock:       clock,
+		failures:    map[string]*failureRecord{},
+		knownTokens: map[[sha256.Size]byte]time.Time{},
+		lastSweep:   clock.Now(),
+	}
+}
+
+// Allow returns false when the source is currently locked out and the token was not recently authenticated
+// successfully, along with how long until the lockout ends.
+func (t *Throttler) Allow(source, token string) (retryAfter time.Duration, allowed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	record := t.failures[source]
+	if source == "" || record == nil || !now.Before(record.blockedUntil) {
+		return 0, true
+	}
+	if lastSuccess, ok := t.knownTokens[sha256.Sum256([]byte(token))]; ok && now.Sub(lastSuccess) < throttleForgetAfter {
+		return 0, true
+	}
+	tokenCredentialRequestsThrottled.Inc()
+	return record.blockedUntil.Sub(now), false
+}
+
+// RecordFailure counts a failed authentication from the source, and locks it out when it has failed too often. The
+// token is no longer considered valid, e.g. because it expired.
+func (t *Throttler) RecordFailure(source, token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	t.maybeSweep(now)
+	delete(t.knownTokens, sha256.Sum256([]byte(token)))
+
+	if source == "" {
+		return
+	}
+	record := t.failures[source]
+	if record == nil || now.Sub(record.lastFailure) >= throttleForgetAfter {
+		record = &failureRecord{}
+		t.failures[source] = record
+	}
+	record.count++
+	record.lastFailure = now
+	if record.count <= sourceFreeFailures {
+		return
+	}
+
+	delay := throttleMaxDelay
+	if shift := record.count - sourceFreeFailures - 1; shift < 16 {
+		if d := throttleBaseDelay << shift; d < throttleMaxDelay {
+			delay = d
+		}
+	}
+	record.blockedUntil = now.Add(delay)
+	tokenCredentialRequestLockouts.Inc()
+	plog.Warning("locking out TokenCredentialRequests with unknown tokens after too many failed authentications",
+		"sourceIP", source,
+		"failures", record.count,
+		"lockout", delay.String(),
+	)
+}
+
+// RecordSuccess remembers the token, so that it keeps working while its source is locked out. The failures of the
+// source are kept, so that an attacker who holds one valid token cannot use it to reset the throttling of their
+// guesses.
+func (t *Throttler) RecordSuccess(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	t.maybeSweep(now)
+	t.knownTokens[sha256.Sum256([]byte(token))] = now
+}
+
+// maybeSweep forgets the sources which have been quiet for a while and the tokens which have not been used, so that
+// memory usage is bounded by the rate of authentications.
+func (t *Throttler) maybeSweep(now time.Time) {
+	if now.Sub(t.lastSweep) < throttleForgetAfter {
+		return
+	}
+	for source, record := range t.failures {
+		if now.Sub(record.lastFailure) >= throttleForgetAfter && !now.Before(record.blockedUntil) {
+			delete(t.failures, source)
+		}
+	}
+	for hash, lastSuccess := range t.knownTokens {
+		if now.Sub(lastSuccess) >= throttleForgetAfter {
+			delete(t.knownTokens, hash)
+		}
+	}
+	t.lastSweep = now
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestThrottler(t *testing.T) {
+	start := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(start)
+	throttler := NewThrottler(fakeClock)
+
+	requireAllowed := func(source, token string) {
+		t.Helper()
+		retryAfter, allowed := throttler.Allow(source, token)
+		require.True(t, allowed)
+		require.Zero(t, retryAfter)
+	}
+	requireThrottled := func(source, token string, wantRetryAfter time.Duration) {
+		t.Helper()
+		retryAfter, allowed := throttler.Allow(source, token)
+		require.False(t, allowed)
+		require.Equal(t, wantRetryAfter, retryAfter)
+	}
+
+	// A user behind the same source authenticates successfully before the guessing starts.
+	requireAllowed("192.0.2.1", "valid-token")
+	throttler.RecordSuccess("valid-token")
+
+	// A source can fail a few times before it is locked out, and each further failure doubles the lockout.
+	for i := 0; i < sourceFreeFailures; i++ {
+		requireAllowed("192.0.2.1", fmt.Sprintf("guess-%d", i))
+		throttler.RecordFailure("192.0.2.1", fmt.Sprintf("guess-%d", i))
+	}
+	requireAllowed("192.0.2.1", "another-guess")
+	throttler.RecordFailure("192.0.2.1", "another-guess")
+	requireThrottled("192.0.2.1", "another-guess", time.Second)
+	throttler.RecordFailure("192.0.2.1", "another-guess")
+	requireThrottled("192.0.2.1", "another-guess", 2*time.Second)
+
+	// Other sources and requests without a source are not affected.
+	requireAllowed("198.51.100.1", "another-guess")
+	requireAllowed("", "another-guess")
+
+	// The token which was valid keeps working from the locked out source, and using it does not reset the lockout.
+	requireAllowed("192.0.2.1", "valid-token")
+	throttler.RecordSuccess("valid-token")
+	requireThrottled("192.0.2.1", "another-guess", 2*time.Second)
+
+	// Once the token fails, e.g. because it expired, it is locked out along with the guesses.
+	throttler.RecordFailure("192.0.2.1", "valid-token")
+	requireThrottled("192.0.2.1", "valid-token", 4*time.Second)
+
+	// The lockout never exceeds the maximum.
+	for i := 0; i < 100; i++ {
+		throttler.RecordFailure("203.0.113.1", fmt.Sprintf("guess-%d", i))
+	}
+	requireThrottled("203.0.113.1", "another-guess", throttleMaxDelay)
+
+	// Quiet sources and unused tokens are forgotten.
+	throttler.RecordSuccess("other-valid-token")
+	fakeClock.Step(throttleForgetAfter)
+	throttler.RecordSuccess("last-token")
+	throttler.RecordFailure("198.51.100.1", "last-guess")
+	require.Len(t, throttler.failures, 1)
+	require.Contains(t, throttler.failures, "198.51.100.1")
+	require.Len(t, throttler.knownTokens, 1)
+	require.Contains(t, throttler.knownTokens, sha256.Sum256([]byte("last-token")))
+}
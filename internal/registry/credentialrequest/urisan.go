@@ -0,0 +1,62 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const (
+	usernamePlaceholder = "{username}"
+	uidPlaceholder      = "{uid}"
+)
+
+// URISANTemplate renders the URI subject alternative name which is added to each client certificate, e.g.
+// "spiffe://cluster.example.com/user/{username}", so that systems which identify clients by their URI SAN can
+// consume the certificates. It is safe for concurrent use.
+type URISANTemplate struct {
+	mu       sync.RWMutex
+	template string
+}
+
+// NewURISANTemplate returns a URISANTemplate for the template. An empty template means that client certificates
+// do not get a URI SAN.
+func NewURISANTemplate(template string) *URISANTemplate {
+	return &URISANTemplate{template: template}
+}
+
+// Set changes the template, e.g. when the config file was changed.
+func (t *URISANTemplate) Set(template string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.template = template
+}
+
+// URIs returns the URI SANs for the user, which is empty when there is no template.
+func (t *URISANTemplate) URIs(userInfo user.Info) ([]*url.URL, error) {
+	t.mu.RLock()
+	template := t.template
+	t.mu.RUnlock()
+
+	if template == "" {
+		return nil, nil
+	}
+	if strings.Contains(template, uidPlaceholder) && userInfo.GetUID() == "" {
+		return nil, fmt.Errorf("URI SAN template %q requires a UID, but user %q does not have one", template, userInfo.GetName())
+	}
+	rendered := strings.NewReplacer(
+		usernamePlaceholder, url.PathEscape(userInfo.GetName()),
+		uidPlaceholder, url.PathEscape(userInfo.GetUID()),
+	).Replace(template)
+	uri, err := url.Parse(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse URI SAN for user %q: %w", userInfo.GetName(), err)
+	}
+	return []*url.URL{uri}, nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package credentialrequest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func TestURISANTemplate(t *testing.T) {
+	template := NewURISANTemplate("")
+
+	uris, err := template.URIs(&user.DefaultInfo{Name: "some-user"})
+	require.NoError(t, err)
+	require.Empty(t, uris)
+
+	template.Set("spiffe://cluster.example.com/ns/users/{username}")
+	uris, err = template.URIs(&user.DefaultInfo{Name: "system:serviceaccount:ns/sa name"})
+	require.NoError(t, err)
+	require.Len(t, uris, 1)
+	require.Equal(t, "spiffe://cluster.example.com/ns/users/system:serviceaccount:ns%2Fsa%20name", uris[0].String())
+
+	template.Set("spiffe://cluster.example.com/uid/{uid}")
+	uris, err = template.URIs(&user.DefaultInfo{Name: "some-user", UID: "some-uid"})
+	require.NoError(t, err)
+	require.Equal(t, "spiffe://cluster.example.com/uid/some-uid", uris[0].String())
+
+	_, err = template.URIs(&user.DefaultInfo{Name: "some-user"})
+	require.EqualError(t, err, `URI SAN template "spiffe://cluster.example.com/uid/{uid}" requires a UID, but user "some-user" does not have one`)
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package whoamirequest provides REST functionality for the WhoAmIRequest resource.
+package whoamirequest
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	identityapi "go.pinniped.dev/generated/latest/apis/concierge/identity"
+)
+
+// NewREST returns the storage for the WhoAmIRequest API.
+func NewREST(resource schema.GroupResource) *REST {
+	return &REST{
+		tableConvertor: rest.NewDefaultTableConvertor(resource),
+	}
+}
+
+type REST struct {
+	tableConvertor rest.TableConvertor
+}
+
+// Assert that our *REST implements all the optional interfaces that we expect it to implement.
+var _ interface {
+	rest.Creater
+	rest.NamespaceScopedStrategy
+	rest.Scoper
+	rest.Storage
+	rest.CategoriesProvider
+	rest.Lister
+} = (*REST)(nil)
+
+func (*REST) New() runtime.Object {
+	return &identityapi.WhoAmIRequest{}
+}
+
+func (*REST) NewList() runtime.Object {
+	return &identityapi.WhoAmIRequestList{}
+}
+
+func (*REST) List(_ context.Context, _ *metainternalversion.ListOptions) (runtime.Object, error) {
+	return &identityapi.WhoAmIRequestList{
+		ListMeta: metav1.ListMeta{
+			ResourceVersion: "0", // this resource version means "from the API server cache"
+		},
+		Items: []identityapi.WhoAmIRequest{}, // avoid sending nil items list
+	}, nil
+}
+
+func (r *REST) ConvertToTable(ctx context.Context, obj runtime.Object, tableOptions runtime.Object) (*metav1.Table, error) {
+	return r.tableConvertor.ConvertToTable(ctx, obj, tableOptions)
+}
+
+func (*REST) NamespaceScoped() bool {
+	return false
+}
+
+func (*REST) Categories() []string {
+	return []string{"pinniped"}
+}
+
+// Create echoes back the user which the Kubernetes API server authenticated for this request. Nothing is stored.
+func (*REST) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, options *metav1.CreateOptions) (runtime.Object, error) {
+	whoAmIRequest, ok := obj.(*identityapi.WhoAmIRequest)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("not a WhoAmIRequest: %#v", obj))
+	}
+
+	// just a sanity check, there is nothing to store so a dry run is the same as a real request
+	if options != nil && len(options.DryRun) != 0 {
+		errs := field.ErrorList{field.NotSupported(field.NewPath("dryRun"), options.DryRun, nil)}
+		return nil, apierrors.NewInvalid(identityapi.Kind(whoAmIRequest.Kind), whoAmIRequest.Name, errs)
+	}
+
+	if createValidation != nil {
+		if err := createValidation(ctx, obj.DeepCopyObject()); err != nil {
+			return nil, err
+		}
+	}
+
+	userInfo, ok := genericapirequest.UserFrom(ctx)
+	if !ok {
+		return nil, apierrors.NewInternalError(fmt.Errorf("no user info on request"))
+	}
+
+	extra := map[string]identityapi.ExtraValue{}
+	for k, v := range userInfo.GetExtra() {
+		extra[k] = v
+	}
+	if len(extra) == 0 {
+		extra = nil
+	}
+
+	return &identityapi.WhoAmIRequest{
+		Status: identityapi.WhoAmIRequestStatus{
+			KubernetesUserInfo: identityapi.KubernetesUserInfo{
+				User: identityapi.UserInfo{
+					Username: userInfo.GetName(),
+					UID:      userInfo.GetUID(),
+					Groups:   userInfo.GetGroups(),
+					Extra:    extra,
+				},
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package whoamirequest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	identityapi "go.pinniped.dev/generated/latest/apis/concierge/identity"
+	loginapi "go.pinniped.dev/generated/latest/apis/concierge/login"
+)
+
+func TestNew(t *testing.T) {
+	r := NewREST(schema.GroupResource{Group: "bears", Resource: "panda"})
+	require.NotNil(t, r)
+	require.False(t, r.NamespaceScoped())
+	require.Equal(t, []string{"pinniped"}, r.Categories())
+	require.IsType(t, &identityapi.WhoAmIRequest{}, r.New())
+	require.IsType(t, &identityapi.WhoAmIRequestList{}, r.NewList())
+
+	ctx := context.Background()
+
+	// check the simple invariants of our no-op list
+	list, err := r.List(ctx, nil)
+	require.NoError(t, err)
+	require.IsType(t, &identityapi.WhoAmIRequestList{}, list)
+	require.Equal(t, "0", list.(*identityapi.WhoAmIRequestList).ResourceVersion)
+	require.NotNil(t, list.(*identityapi.WhoAmIRequestList).Items)
+	require.Len(t, list.(*identityapi.WhoAmIRequestList).Items, 0)
+
+	// make sure we can turn lists into tables if needed
+	table, err := r.ConvertToTable(ctx, list, nil)
+	require.NoError(t, err)
+	require.NotNil(t, table)
+	require.Equal(t, "0", table.ResourceVersion)
+	require.Nil(t, table.Rows)
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name             string
+		ctx              context.Context
+		obj              runtime.Object
+		createValidation rest.ValidateObjectFunc
+		options          *metav1.CreateOptions
+		want             runtime.Object
+		wantErr          string
+	}{
+		{
+			name:    "wrong type",
+			ctx:     genericapirequest.NewContext(),
+			obj:     &loginapi.TokenCredentialRequest{},
+			wantErr: "not a WhoAmIRequest",
+		},
+		{
+			name:    "dry run",
+			ctx:     genericapirequest.NewContext(),
+			obj:     &identityapi.WhoAmIRequest{},
+			options: &metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}},
+			wantErr: `Unsupported value: []string{"All"}`,
+		},
+		{
+			name: "validation failed",
+			ctx:  genericapirequest.NewContext(),
+			obj:  &identityapi.WhoAmIRequest{},
+			createValidation: func(ctx context.Context, obj runtime.Object) error {
+				return errors.New("some-error-here")
+			},
+			wantErr: "some-error-here",
+		},
+		{
+			name:    "no user info",
+			ctx:     genericapirequest.NewContext(),
+			obj:     &identityapi.WhoAmIRequest{},
+			wantErr: "Internal error occurred: no user info on request",
+		},
+		{
+			name: "with user info",
+			ctx: genericapirequest.WithUser(genericapirequest.NewContext(), &user.DefaultInfo{
+				Name:   "bond",
+				UID:    "007",
+				Groups: []string{"secret", "agents"},
+				Extra: map[string][]string{
+					"fan-of":   {"pinniped", "sed"},
+					"needs-of": {"tacos"},
+				},
+			}),
+			obj: &identityapi.WhoAmIRequest{},
+			createValidation: func(ctx context.Context, obj runtime.Object) error {
+				require.IsType(t, &identityapi.WhoAmIRequest{}, obj)
+				return nil
+			},
+			want: &identityapi.WhoAmIRequest{
+				Status: identityapi.WhoAmIRequestStatus{
+					KubernetesUserInfo: identityapi.KubernetesUserInfo{
+						User: identityapi.UserInfo{
+							Username: "bond",
+							UID:      "007",
+							Groups:   []string{"secret", "agents"},
+							Extra: map[string]identityapi.ExtraValue{
+								"fan-of":   {"pinniped", "sed"},
+								"needs-of": {"tacos"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "anonymous user without extra",
+			ctx: genericapirequest.WithUser(genericapirequest.NewContext(), &user.DefaultInfo{
+				Name:   user.Anonymous,
+				Groups: []string{user.AllUnauthenticated},
+			}),
+			obj: &identityapi.WhoAmIRequest{},
+			want: &identityapi.WhoAmIRequest{
+				Status: identityapi.WhoAmIRequestStatus{
+					KubernetesUserInfo: identityapi.KubernetesUserInfo{
+						User: identityapi.UserInfo{
+							Username: user.Anonymous,
+							Groups:   []string{user.AllUnauthenticated},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewREST(identityapi.Resource("whoamirequests")).Create(tt.ctx, tt.obj, tt.createValidation, tt.options)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				require.Nil(t, got)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
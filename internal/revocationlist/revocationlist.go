@@ -0,0 +1,211 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package revocationlist implements the list of revoked users which the Supervisor serves for each FederationDomain.
+// The Supervisor can delete the sessions of a user, but the cluster-scoped ID tokens which were already issued to the
+// user stay valid until they expire. JWTAuthenticators of the Concierge can poll this list to reject those tokens.
+//
+// Users are identified by the issuer and the subject of their ID tokens, since usernames are mapped from upstream
+// claims and two users of different upstream identity providers can have the same one. The list is served without
+// authentication like the JWKS, so it only contains an HMAC of each identity, keyed with a random key of its
+// revocation. Checking whether a given identity is revoked is easy, but recovering the identities from the list needs
+// a separate guessing attack on every entry.
+package revocationlist
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/crud"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	TypeLabelValue = "revoked-user"
+
+	ErrInvalidRevocationVersion = constable.Error("revocation data has wrong version")
+
+	// Version 1 stored the SHA-256 hashes of usernames, which are skipped.
+	revocationStorageVersion = "2"
+
+	revocationKeyLength = 32
+)
+
+// List is the response of the revocation list endpoint.
+type List struct {
+	Revocations []Revocation `json:"revocations"`
+}
+
+// Revocation says that the tokens which were issued to a user before RevokedAt must not be accepted anymore.
+type Revocation struct {
+	// Key is the base64url-encoded random key of the revocation.
+	Key string `json:"key"`
+	// IdentityHMAC is the hex-encoded HMAC of the issuer and subject of the user, see HashIdentity.
+	IdentityHMAC string    `json:"identityHMAC"`
+	RevokedAt    time.Time `json:"revokedAt"`
+}
+
+// RevokedAt returns when the user with the issuer and subject was last revoked, if they are in the list.
+func (l *List) RevokedAt(issuer, subject string) (time.Time, bool) {
+	var revokedAt time.Time
+	found := false
+	for _, revocation := range l.Revocations {
+		key, err := base64.RawURLEncoding.DecodeString(revocation.Key)
+		if err != nil || HashIdentity(key, issuer, subject) != revocation.IdentityHMAC {
+			continue
+		}
+		if !found || revocation.RevokedAt.After(revokedAt) {
+			revokedAt = revocation.RevokedAt
+			found = true
+		}
+	}
+	return revokedAt, found
+}
+
+// HashIdentity returns the hex-encoded HMAC-SHA256 of the issuer and subject with the key. Issuers are URLs, so they
+// never contain the NUL byte which separates them from the subject.
+func HashIdentity(key []byte, issuer, subject string) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(issuer + "\x00" + subject))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// storedRevocation is the content of a revocation Secret. The subject is only readable by the Supervisor.
+type storedRevocation struct {
+	Subject   string    `json:"subject"`
+	Key       []byte    `json:"key"`
+	RevokedAt time.Time `json:"revokedAt"`
+	Version   string    `json:"version"`
+}
+
+// revocation returns the revocation as it is listed for the FederationDomain with the issuer.
+func (s *storedRevocation) revocation(issuer string) Revocation {
+	return Revocation{
+		Key:          base64.RawURLEncoding.EncodeToString(s.Key),
+		IdentityHMAC: HashIdentity(s.Key, issuer, s.Subject),
+		RevokedAt:    s.RevokedAt,
+	}
+}
+
+// Storage records revocations in Secrets. Each Secret is garbage collected after the lifetime of the Storage, which
+// must be longer than any token that was issued before the revocation could still be accepted.
+type Storage struct {
+	storage crud.Storage
+	clock   func() time.Time
+}
+
+// NewStorage returns a Storage which keeps the revocations in Secrets.
+func NewStorage(secrets corev1client.SecretInterface, clock func() time.Time, lifetime time.Duration) *Storage {
+	return &Storage{storage: crud.New(TypeLabelValue, secrets, clock, lifetime), clock: clock}
+}
+
+// Revoke records that the tokens which were issued until now to the user with the downstream subject must not be
+// accepted anymore by any FederationDomain. Revoking a user again moves the time of their revocation forward, and
+// returns when it happened.
+func (s *Storage) Revoke(ctx context.Context, subject string) (time.Time, error) {
+	key := make([]byte, revocationKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return time.Time{}, fmt.Errorf("could not generate revocation key: %w", err)
+	}
+	stored := storedRevocation{
+		Subject:   subject,
+		Key:       key,
+		RevokedAt: s.clock().UTC().Truncate(time.Second),
+		Version:   revocationStorageVersion,
+	}
+
+	_, err := s.storage.Create(ctx, subject, &stored, nil)
+	if err == nil || !k8serrors.IsAlreadyExists(err) {
+		return stored.RevokedAt, err
+	}
+
+	var existing storedRevocation
+	resourceVersion, err := s.storage.Get(ctx, subject, &existing)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if _, err := s.storage.Update(ctx, subject, resourceVersion, &stored); err != nil {
+		return time.Time{}, err
+	}
+	return stored.RevokedAt, nil
+}
+
+// Lister lists the revocations from an informer cache of the Secrets of a Storage.
+type Lister struct {
+	secrets corev1listers.SecretNamespaceLister
+}
+
+// NewLister returns a Lister of the revocations in the Secrets of the lister.
+func NewLister(secrets corev1listers.SecretNamespaceLister) *Lister {
+	return &Lister{secrets: secrets}
+}
+
+// List returns the revocations as they are listed for the FederationDomain with the issuer, ordered by when they
+// happened.
+func (l *Lister) List(issuer string) (*List, error) {
+	secrets, err := l.secrets.List(labels.SelectorFromSet(labels.Set{crud.SecretLabelKey: TypeLabelValue}))
+	if err != nil {
+		return nil, fmt.Errorf("could not list revocations: %w", err)
+	}
+
+	list := List{Revocations: []Revocation{}}
+	for _, secret := range secrets {
+		var stored storedRevocation
+		if err := crud.FromSecret(TypeLabelValue, secret, &stored); err != nil {
+			plog.Debug("skipping invalid revocation storage secret", "secret", klog.KObj(secret), "err", err)
+			continue
+		}
+		if stored.Version != revocationStorageVersion {
+			plog.Debug("skipping revocation storage secret", "secret", klog.KObj(secret), "err", ErrInvalidRevocationVersion)
+			continue
+		}
+		list.Revocations = append(list.Revocations, stored.revocation(issuer))
+	}
+	sort.Slice(list.Revocations, func(i, j int) bool {
+		if !list.Revocations[i].RevokedAt.Equal(list.Revocations[j].RevokedAt) {
+			return list.Revocations[i].RevokedAt.Before(list.Revocations[j].RevokedAt)
+		}
+		return list.Revocations[i].IdentityHMAC < list.Revocations[j].IdentityHMAC
+	})
+	return &list, nil
+}
+
+// NewHandler returns the handler for the revocation list endpoint of the FederationDomain with the issuer. The lister
+// may be nil when the sessions are only kept in memory, in which case no user can be revoked and the list is always
+// empty.
+func NewHandler(issuer string, lister *Lister) http.Handler {
+	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != http.MethodGet {
+			return httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET)", r.Method)
+		}
+
+		list := &List{Revocations: []Revocation{}}
+		if lister != nil {
+			var err error
+			if list, err = lister.List(issuer); err != nil {
+				return httperr.Wrap(http.StatusInternalServerError, "could not list revocations", err)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		return json.NewEncoder(w).Encode(list)
+	})
+}
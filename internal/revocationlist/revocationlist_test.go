@@ -0,0 +1,187 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package revocationlist
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"go.pinniped.dev/internal/crud"
+)
+
+const (
+	testNamespace = "test-namespace"
+	testIssuer    = "https://issuer.example.com"
+	aliceSubject  = "https://upstream.example.com?sub=alice"
+	bobSubject    = "https://upstream.example.com?sub=bob"
+)
+
+func TestHashIdentity(t *testing.T) {
+	// $ printf 'https://issuer.example.com\0alice' | openssl dgst -sha256 -hmac some-key
+	require.Equal(t, "c5681cce20b01b983efa8d13b68bbad5e4aa8b604e689e3da5c92cc239353d90",
+		HashIdentity([]byte("some-key"), "https://issuer.example.com", "alice"))
+}
+
+func TestStorageAndLister(t *testing.T) {
+	ctx := context.Background()
+	kubeClient := kubernetesfake.NewSimpleClientset()
+	secrets := kubeClient.CoreV1().Secrets(testNamespace)
+	now := time.Date(2021, time.June, 1, 12, 0, 0, 500, time.UTC)
+	storage := NewStorage(secrets, func() time.Time { return now }, time.Hour)
+
+	revokedAt, err := storage.Revoke(ctx, aliceSubject)
+	require.NoError(t, err)
+	require.Equal(t, now.Truncate(time.Second), revokedAt)
+
+	now = now.Add(time.Minute)
+	_, err = storage.Revoke(ctx, bobSubject)
+	require.NoError(t, err)
+
+	// Revoking alice again moves the revocation forward, and also the time at which it is garbage collected.
+	now = now.Add(time.Minute)
+	_, err = storage.Revoke(ctx, aliceSubject)
+	require.NoError(t, err)
+
+	secretList, err := secrets.List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, secretList.Items, 2)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for i := range secretList.Items {
+		secret := &secretList.Items[i]
+		require.Equal(t, TypeLabelValue, secret.Labels[crud.SecretLabelKey])
+		if secret.Name == secretList.Items[0].Name {
+			// Add a Secret of another kind, which must not be listed.
+			other := secret.DeepCopy()
+			other.Name = "some-other-secret"
+			other.Labels[crud.SecretLabelKey] = "access-token"
+			require.NoError(t, indexer.Add(other))
+		}
+		require.NoError(t, indexer.Add(secret))
+	}
+	garbageCollectAfter := map[string]string{}
+	for _, secret := range secretList.Items {
+		var stored storedRevocation
+		require.NoError(t, crud.FromSecret(TypeLabelValue, &secret, &stored))
+		require.Len(t, stored.Key, revocationKeyLength)
+		garbageCollectAfter[stored.Subject] = secret.Annotations[crud.SecretLifetimeAnnotationKey]
+	}
+	require.Equal(t, map[string]string{
+		aliceSubject: "2021-06-01T13:02:00Z",
+		bobSubject:   "2021-06-01T13:01:00Z",
+	}, garbageCollectAfter)
+
+	lister := NewLister(corev1listers.NewSecretLister(indexer).Secrets(testNamespace))
+	list, err := lister.List(testIssuer)
+	require.NoError(t, err)
+	require.Len(t, list.Revocations, 2)
+	require.Equal(t, time.Date(2021, time.June, 1, 12, 1, 0, 0, time.UTC), list.Revocations[0].RevokedAt)
+	require.Equal(t, time.Date(2021, time.June, 1, 12, 2, 0, 0, time.UTC), list.Revocations[1].RevokedAt)
+	// Each revocation has its own key, so the same identity is not recognizable across revocations.
+	require.NotEqual(t, list.Revocations[0].Key, list.Revocations[1].Key)
+	require.NotEqual(t, HashIdentity([]byte("some-key"), testIssuer, aliceSubject), list.Revocations[1].IdentityHMAC)
+
+	revokedAt, revoked := list.RevokedAt(testIssuer, aliceSubject)
+	require.True(t, revoked)
+	require.Equal(t, time.Date(2021, time.June, 1, 12, 2, 0, 0, time.UTC), revokedAt)
+	revokedAt, revoked = list.RevokedAt(testIssuer, bobSubject)
+	require.True(t, revoked)
+	require.Equal(t, time.Date(2021, time.June, 1, 12, 1, 0, 0, time.UTC), revokedAt)
+	_, revoked = list.RevokedAt(testIssuer, "https://upstream.example.com?sub=carol")
+	require.False(t, revoked)
+
+	// The list of another FederationDomain revokes the same subjects, but only for its own issuer.
+	otherList, err := lister.List("https://other-issuer.example.com")
+	require.NoError(t, err)
+	_, revoked = otherList.RevokedAt("https://other-issuer.example.com", aliceSubject)
+	require.True(t, revoked)
+	_, revoked = otherList.RevokedAt(testIssuer, aliceSubject)
+	require.False(t, revoked)
+	_, revoked = list.RevokedAt("https://other-issuer.example.com", aliceSubject)
+	require.False(t, revoked)
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	kubeClient := kubernetesfake.NewSimpleClientset()
+	secrets := kubeClient.CoreV1().Secrets(testNamespace)
+	storage := NewStorage(secrets, func() time.Time { return time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC) }, time.Hour)
+	_, err := storage.Revoke(ctx, aliceSubject)
+	require.NoError(t, err)
+	secretList, err := secrets.List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	require.NoError(t, indexer.Add(&secretList.Items[0]))
+	lister := NewLister(corev1listers.NewSecretLister(indexer).Secrets(testNamespace))
+
+	tests := []struct {
+		name            string
+		lister          *Lister
+		method          string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+		wantRevoked     bool
+	}{
+		{
+			name:            "list",
+			lister:          lister,
+			method:          http.MethodGet,
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantRevoked:     true,
+		},
+		{
+			name:            "sessions in memory",
+			method:          http.MethodGet,
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `{"revocations":[]}` + "\n",
+		},
+		{
+			name:            "wrong method",
+			lister:          lister,
+			method:          http.MethodPost,
+			wantStatus:      http.StatusMethodNotAllowed,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "Method Not Allowed: POST (try GET)\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rsp := httptest.NewRecorder()
+			NewHandler(testIssuer, tt.lister).ServeHTTP(rsp, httptest.NewRequest(tt.method, "/revoked-users", nil))
+			require.Equal(t, tt.wantStatus, rsp.Code)
+			require.Equal(t, tt.wantContentType, rsp.Header().Get("Content-Type"))
+			if !tt.wantRevoked {
+				require.Equal(t, tt.wantBody, rsp.Body.String())
+				return
+			}
+
+			// The subject of the revoked user is not in the response.
+			require.NotContains(t, rsp.Body.String(), "alice")
+			var list List
+			require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &list))
+			require.Len(t, list.Revocations, 1)
+			key, err := base64.RawURLEncoding.DecodeString(list.Revocations[0].Key)
+			require.NoError(t, err)
+			require.Equal(t, Revocation{
+				Key:          list.Revocations[0].Key,
+				IdentityHMAC: HashIdentity(key, testIssuer, aliceSubject),
+				RevokedAt:    time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC),
+			}, list.Revocations[0])
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package serviceaccounttoken implements a token authenticator for bound ServiceAccount tokens, which are validated by
+// the Kubernetes API server through the TokenReview API.
+package serviceaccounttoken
+
+import (
+	"context"
+	"fmt"
+
+	"gopkg.in/square/go-jose.v2/jwt"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
+	authenticationv1client "k8s.io/client-go/kubernetes/typed/authentication/v1"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"go.pinniped.dev/internal/constable"
+)
+
+// ErrLegacyToken is returned for ServiceAccount tokens which do not expire, i.e. the legacy tokens which are stored
+// in Secrets. The API server treats those as valid for any audience, so accepting them would defeat the audiences.
+const ErrLegacyToken = constable.Error("ServiceAccount token does not expire, only bound ServiceAccount tokens are accepted")
+
+// Authenticator is an authenticator.Token which authenticates bound ServiceAccount tokens.
+type Authenticator struct {
+	tokenReviews authenticationv1client.TokenReviewInterface
+	audiences    []string
+	namespaces   sets.String
+}
+
+// Assert that *Authenticator implements authenticator.Token.
+var _ authenticator.Token = (*Authenticator)(nil)
+
+// New creates an Authenticator from the provided spec, which reviews tokens with the provided client.
+func New(spec *auth1alpha1.ServiceAccountAuthenticatorSpec, tokenReviews authenticationv1client.TokenReviewInterface) (*Authenticator, error) {
+	if len(spec.Audiences) == 0 {
+		return nil, fmt.Errorf("at least one audience must be specified")
+	}
+	return &Authenticator{
+		tokenReviews: tokenReviews,
+		audiences:    append([]string{}, spec.Audiences...),
+		namespaces:   sets.NewString(spec.Namespaces...),
+	}, nil
+}
+
+// AuthenticateToken implements authenticator.Token.
+func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not parse ServiceAccount token: %w", err)
+	}
+	// The signature and the claims are verified by the TokenReview below, so the claims may be read without
+	// verification here. Only the presence of the exp claim matters.
+	var claims jwt.Claims
+	if err := parsed.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, false, fmt.Errorf("could not read claims of ServiceAccount token: %w", err)
+	}
+	if claims.Expiry == nil {
+		return nil, false, ErrLegacyToken
+	}
+
+	review, err := a.tokenReviews.Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: a.audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, false, fmt.Errorf("could not review ServiceAccount token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return nil, false, nil
+	}
+	// API servers which do not support audiences ignore the audiences of the review and return none, so the token
+	// must have been validated for at least one of the requested audiences.
+	if !sets.NewString(review.Status.Audiences...).HasAny(a.audiences...) {
+		return nil, false, fmt.Errorf("ServiceAccount token was not validated for any of the audiences %q", a.audiences)
+	}
+
+	namespace, _, err := serviceaccount.SplitUsername(review.Status.User.Username)
+	if err != nil {
+		return nil, false, fmt.Errorf("token does not belong to a ServiceAccount: %w", err)
+	}
+	if a.namespaces.Len() > 0 && !a.namespaces.Has(namespace) {
+		return nil, false, fmt.Errorf("ServiceAccounts of namespace %q are not allowed to authenticate", namespace)
+	}
+
+	return &authenticator.Response{
+		User: &user.DefaultInfo{
+			Name:   review.Status.User.Username,
+			UID:    review.Status.User.UID,
+			Groups: append([]string{}, review.Status.User.Groups...),
+		},
+	}, true, nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package serviceaccounttoken
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	coretesting "k8s.io/client-go/testing"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+)
+
+func TestNew(t *testing.T) {
+	client := kubernetesfake.NewSimpleClientset().AuthenticationV1().TokenReviews()
+
+	_, err := New(&auth1alpha1.ServiceAccountAuthenticatorSpec{Audiences: []string{"some-audience"}}, client)
+	require.NoError(t, err)
+
+	_, err = New(&auth1alpha1.ServiceAccountAuthenticatorSpec{Namespaces: []string{"some-namespace"}}, client)
+	require.EqualError(t, err, "at least one audience must be specified")
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+	require.NoError(t, err)
+	newToken := func(t *testing.T, claims jwt.Claims) string {
+		t.Helper()
+		token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+	boundToken := newToken(t, jwt.Claims{
+		Subject:  "system:serviceaccount:some-namespace:some-name",
+		Audience: jwt.Audience{"some-audience"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	legacyToken := newToken(t, jwt.Claims{Subject: "system:serviceaccount:some-namespace:some-name"})
+
+	authenticatedStatus := authenticationv1.TokenReviewStatus{
+		Authenticated: true,
+		User: authenticationv1.UserInfo{
+			Username: "system:serviceaccount:some-namespace:some-name",
+			UID:      "some-uid",
+			Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:some-namespace", "system:authenticated"},
+		},
+		Audiences: []string{"some-audience"},
+	}
+
+	tests := []struct {
+		name         string
+		spec         auth1alpha1.ServiceAccountAuthenticatorSpec
+		token        string
+		reviewStatus authenticationv1.TokenReviewStatus
+		reviewErr    error
+		wantReviews  int
+		wantResponse *authenticator.Response
+		wantErr      string
+	}{
+		{
+			name:         "bound token",
+			spec:         auth1alpha1.ServiceAccountAuthenticatorSpec{Audiences: []string{"some-audience"}},
+			token:        boundToken,
+			reviewStatus: authenticatedStatus,
+			wantReviews:  1,
+			wantResponse: &authenticator.Response{User: &user.DefaultInfo{
+				Name:   "system:serviceaccount:some-namespace:some-name",
+				UID:    "some-uid",
+				Groups: []string{"system:serviceaccounts", "system:serviceaccounts:some-namespace", "system:authenticated"},
+			}},
+		},
+		{
+			name: "bound token from an allowed namespace",
+			spec: auth1alpha1.ServiceAccountAuthenticatorSpec{
+				Audiences:  []string{"some-audience"},
+				Namespaces: []string{"other-namespace", "some-namespace"},
+			},
+			token:        boundToken,
+			reviewStatus: authenticatedStatus,
+			wantReviews:  1,
+			wantResponse: &authenticator.Response{User: &user.DefaultInfo{
+				Name:   "system:serviceaccount:some-namespace:some-name",
+				UID:    "some-uid",
+				Groups: []string{"system:serviceaccounts", "system:serviceaccounts:some-namespace", "system:authenticated"},
+			}},
+		},
+		{
+			name: "bound token from another namespace",
+			spec: auth1alpha1.ServiceAccountAuthenticatorSpec{
+				Audiences:  []string{"some-audience"},
+				Namespaces: []string{"other-namespace"},
+			},
+			token:        boundToken,
+			reviewStatus: authenticatedStatus,
+			wantReviews:  1,
+			wantErr:      `ServiceAccounts of namespace "some-namespace" are not allowed to authenticate`,
+		},
+		{
+			name:    "legacy token",
+			spec:    auth1alpha1.ServiceAccountAuthenticatorSpec{Audiences: []string{"some-audience"}},
+			token:   legacyToken,
+			wantErr: "ServiceAccount token does not expire, only bound ServiceAccount tokens are accepted",
+		},
+		{
+			name:    "not a JWT",
+			spec:    auth1alpha1.ServiceAccountAuthenticatorSpec{Audiences: []string{"some-audience"}},
+			token:   "not-a-jwt",
+			wantErr: "could not parse ServiceAccount token: square/go-jose: compact JWS format must have three parts",
+		},
+		{
+			name:         "token not authenticated by the API server",
+			spec:         auth1alpha1.ServiceAccountAuthenticatorSpec{Audiences: []string{"some-audience"}},
+			token:        boundToken,
+			reviewStatus: authenticationv1.TokenReviewStatus{Authenticated: false, Error: "token audiences are invalid"},
+			wantReviews:  1,
+		},
+		{
+			name:  "API server which does not return the audiences",
+			spec:  auth1alpha1.ServiceAccountAuthenticatorSpec{Audiences: []string{"some-audience"}},
+			token: boundToken,
+			reviewStatus: authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authenticatedStatus.User,
+			},
+			wantReviews: 1,
+			wantErr:     `ServiceAccount token was not validated for any of the audiences ["some-audience"]`,
+		},
+		{
+			name:  "API server which returns other audiences",
+			spec:  auth1alpha1.ServiceAccountAuthenticatorSpec{Audiences: []string{"some-audience", "other-audience"}},
+			token: boundToken,
+			reviewStatus: authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authenticatedStatus.User,
+				Audiences:     []string{"https://kubernetes.default.svc"},
+			},
+			wantReviews: 1,
+			wantErr:     `ServiceAccount token was not validated for any of the audiences ["some-audience" "other-audience"]`,
+		},
+		{
+			name:         "bound token validated for one of several audiences",
+			spec:         auth1alpha1.ServiceAccountAuthenticatorSpec{Audiences: []string{"other-audience", "some-audience"}},
+			token:        boundToken,
+			reviewStatus: authenticatedStatus,
+			wantReviews:  1,
+			wantResponse: &authenticator.Response{User: &user.DefaultInfo{
+				Name:   "system:serviceaccount:some-namespace:some-name",
+				UID:    "some-uid",
+				Groups: []string{"system:serviceaccounts", "system:serviceaccounts:some-namespace", "system:authenticated"},
+			}},
+		},
+		{
+			name:  "token of a user other than a ServiceAccount",
+			spec:  auth1alpha1.ServiceAccountAuthenticatorSpec{Audiences: []string{"some-audience"}},
+			token: boundToken,
+			reviewStatus: authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authenticationv1.UserInfo{Username: "some-user"},
+				Audiences:     []string{"some-audience"},
+			},
+			wantReviews: 1,
+			wantErr:     `token does not belong to a ServiceAccount: Username must be in the form system:serviceaccount:namespace:name`,
+		},
+		{
+			name:        "TokenReview fails",
+			spec:        auth1alpha1.ServiceAccountAuthenticatorSpec{Audiences: []string{"some-audience"}},
+			token:       boundToken,
+			reviewErr:   errors.New("some error"),
+			wantReviews: 1,
+			wantErr:     "could not review ServiceAccount token: some error",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := kubernetesfake.NewSimpleClientset()
+			var reviews []*authenticationv1.TokenReview
+			client.PrependReactor("create", "tokenreviews", func(action coretesting.Action) (bool, runtime.Object, error) {
+				review := action.(coretesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+				reviews = append(reviews, review)
+				if tt.reviewErr != nil {
+					return true, nil, tt.reviewErr
+				}
+				result := review.DeepCopy()
+				result.Status = tt.reviewStatus
+				return true, result, nil
+			})
+
+			a, err := New(&tt.spec, client.AuthenticationV1().TokenReviews())
+			require.NoError(t, err)
+
+			response, authenticated, err := a.AuthenticateToken(context.Background(), tt.token)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantResponse, response)
+			require.Equal(t, tt.wantResponse != nil, authenticated)
+
+			require.Len(t, reviews, tt.wantReviews)
+			for _, review := range reviews {
+				require.Equal(t, tt.token, review.Spec.Token)
+				require.Equal(t, tt.spec.Audiences, review.Spec.Audiences)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package statictoken implements a token authenticator for a fixed set of tokens, which are configured by their
+// SHA-256 hashes so that the tokens themselves never need to be stored in the cluster.
+package statictoken
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+	"go.pinniped.dev/internal/plog"
+)
+
+type staticToken struct {
+	name string
+	hash []byte
+	user *user.DefaultInfo
+}
+
+// Authenticator is an authenticator.Token which authenticates each of its tokens as a fixed user.
+type Authenticator struct {
+	tokens []staticToken
+}
+
+// Assert that *Authenticator implements authenticator.Token.
+var _ authenticator.Token = (*Authenticator)(nil)
+
+// New creates an Authenticator from the provided spec and the data of the Secret which it references, whose keys are
+// the names of the tokens and whose values are their hex-encoded SHA-256 hashes.
+func New(spec *auth1alpha1.StaticTokenAuthenticatorSpec, hashes map[string][]byte) (*Authenticator, error) {
+	tokens := make([]staticToken, 0, len(spec.Tokens))
+	seen := make(map[string]string, len(spec.Tokens))
+	for _, t := range spec.Tokens {
+		if t.Username == "" {
+			return nil, fmt.Errorf("token %q must have a username", t.Name)
+		}
+		encoded, ok := hashes[t.Name]
+		if !ok {
+			return nil, fmt.Errorf("secret %q is missing key %q", spec.SecretName, t.Name)
+		}
+		hash, err := parseHash(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("secret %q has an invalid hash for token %q: %w", spec.SecretName, t.Name, err)
+		}
+		if other, exists := seen[string(hash)]; exists {
+			return nil, fmt.Errorf("tokens %q and %q have the same hash", other, t.Name)
+		}
+		seen[string(hash)] = t.Name
+		tokens = append(tokens, staticToken{
+			name: t.Name,
+			hash: hash,
+			user: &user.DefaultInfo{Name: t.Username, Groups: append([]string{}, t.Groups...)},
+		})
+	}
+	return &Authenticator{tokens: tokens}, nil
+}
+
+// parseHash decodes a hex-encoded SHA-256 hash. Trailing text after whitespace is ignored, so that the output of
+// sha256sum can be used as is.
+func parseHash(encoded []byte) ([]byte, error) {
+	fields := strings.Fields(string(encoded))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("hash is empty")
+	}
+	hash, err := hex.DecodeString(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("hash is not hex-encoded")
+	}
+	if len(hash) != sha256.Size {
+		return nil, fmt.Errorf("hash must be %d bytes, but it was %d bytes", sha256.Size, len(hash))
+	}
+	return hash, nil
+}
+
+// AuthenticateToken implements authenticator.Token.
+func (a *Authenticator) AuthenticateToken(_ context.Context, token string) (*authenticator.Response, bool, error) {
+	hash := sha256.Sum256([]byte(token))
+
+	// Compare against every token in constant time, so that the response time does not reveal which hashes exist.
+	var match *staticToken
+	for i := range a.tokens {
+		if subtle.ConstantTimeCompare(a.tokens[i].hash, hash[:]) == 1 {
+			match = &a.tokens[i]
+		}
+	}
+	if match == nil {
+		return nil, false, nil
+	}
+
+	// Static tokens are only meant for emergencies, so every use of one is worth noticing.
+	plog.Warning("authenticated using static token", "token", match.name, "username", match.user.Name)
+	return &authenticator.Response{
+		User: &user.DefaultInfo{Name: match.user.Name, Groups: append([]string{}, match.user.Groups...)},
+	}, true, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package statictoken
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	auth1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
+)
+
+func hashOf(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return []byte(hex.EncodeToString(hash[:]))
+}
+
+func TestNew(t *testing.T) {
+	spec := func(tokens ...auth1alpha1.StaticToken) *auth1alpha1.StaticTokenAuthenticatorSpec {
+		return &auth1alpha1.StaticTokenAuthenticatorSpec{SecretName: "some-secret", Tokens: tokens}
+	}
+
+	tests := []struct {
+		name    string
+		spec    *auth1alpha1.StaticTokenAuthenticatorSpec
+		hashes  map[string][]byte
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			spec:   spec(auth1alpha1.StaticToken{Name: "token-1", Username: "user-1"}),
+			hashes: map[string][]byte{"token-1": hashOf("some-token"), "unused": []byte("ignored")},
+		},
+		{
+			name:   "sha256sum output",
+			spec:   spec(auth1alpha1.StaticToken{Name: "token-1", Username: "user-1"}),
+			hashes: map[string][]byte{"token-1": append(hashOf("some-token"), []byte("  -\n")...)},
+		},
+		{
+			name:    "missing username",
+			spec:    spec(auth1alpha1.StaticToken{Name: "token-1"}),
+			hashes:  map[string][]byte{"token-1": hashOf("some-token")},
+			wantErr: `token "token-1" must have a username`,
+		},
+		{
+			name:    "missing key",
+			spec:    spec(auth1alpha1.StaticToken{Name: "token-1", Username: "user-1"}),
+			hashes:  map[string][]byte{},
+			wantErr: `secret "some-secret" is missing key "token-1"`,
+		},
+		{
+			name:    "empty hash",
+			spec:    spec(auth1alpha1.StaticToken{Name: "token-1", Username: "user-1"}),
+			hashes:  map[string][]byte{"token-1": []byte(" \n")},
+			wantErr: `secret "some-secret" has an invalid hash for token "token-1": hash is empty`,
+		},
+		{
+			name:    "not hex",
+			spec:    spec(auth1alpha1.StaticToken{Name: "token-1", Username: "user-1"}),
+			hashes:  map[string][]byte{"token-1": []byte("some-token")},
+			wantErr: `secret "some-secret" has an invalid hash for token "token-1": hash is not hex-encoded`,
+		},
+		{
+			name:    "wrong length",
+			spec:    spec(auth1alpha1.StaticToken{Name: "token-1", Username: "user-1"}),
+			hashes:  map[string][]byte{"token-1": []byte("abcd")},
+			wantErr: `secret "some-secret" has an invalid hash for token "token-1": hash must be 32 bytes, but it was 2 bytes`,
+		},
+		{
+			name: "duplicate hash",
+			spec: spec(
+				auth1alpha1.StaticToken{Name: "token-1", Username: "user-1"},
+				auth1alpha1.StaticToken{Name: "token-2", Username: "user-2"},
+			),
+			hashes:  map[string][]byte{"token-1": hashOf("some-token"), "token-2": hashOf("some-token")},
+			wantErr: `tokens "token-1" and "token-2" have the same hash`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(tt.spec, tt.hashes)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, a)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, a)
+		})
+	}
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	a, err := New(&auth1alpha1.StaticTokenAuthenticatorSpec{
+		SecretName: "some-secret",
+		Tokens: []auth1alpha1.StaticToken{
+			{Name: "token-1", Username: "user-1", Groups: []string{"group-a", "group-b"}},
+			{Name: "token-2", Username: "user-2"},
+		},
+	}, map[string][]byte{
+		"token-1": hashOf("some-token"),
+		"token-2": hashOf("some-other-token"),
+	})
+	require.NoError(t, err)
+
+	response, authenticated, err := a.AuthenticateToken(context.Background(), "some-token")
+	require.NoError(t, err)
+	require.True(t, authenticated)
+	require.Equal(t, &authenticator.Response{
+		User: &user.DefaultInfo{Name: "user-1", Groups: []string{"group-a", "group-b"}},
+	}, response)
+
+	response, authenticated, err = a.AuthenticateToken(context.Background(), "some-other-token")
+	require.NoError(t, err)
+	require.True(t, authenticated)
+	require.Equal(t, &authenticator.Response{
+		User: &user.DefaultInfo{Name: "user-2", Groups: []string{}},
+	}, response)
+
+	response, authenticated, err = a.AuthenticateToken(context.Background(), "wrong-token")
+	require.NoError(t, err)
+	require.False(t, authenticated)
+	require.Nil(t, response)
+
+	// The hash itself is not accepted as a token.
+	_, authenticated, err = a.AuthenticateToken(context.Background(), string(hashOf("some-token")))
+	require.NoError(t, err)
+	require.False(t, authenticated)
+}
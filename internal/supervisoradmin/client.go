@@ -0,0 +1,118 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package supervisoradmin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultSocketPath is where the Supervisor deployment serves the admin endpoint.
+const DefaultSocketPath = "/var/run/pinniped-supervisor-admin/admin.sock"
+
+// Client calls the admin endpoint of the Supervisor which runs in the same pod.
+type Client struct {
+	httpClient *http.Client
+	token      string
+}
+
+// NewClient returns a client of the admin endpoint which listens on the socket at socketPath, using the token which
+// the Supervisor wrote next to the socket.
+func NewClient(socketPath string) (*Client, error) {
+	token, err := ioutil.ReadFile(TokenPath(socketPath))
+	if err != nil {
+		return nil, fmt.Errorf("could not read admin token (is the admin endpoint enabled?): %w", err)
+	}
+	dialer := &net.Dialer{}
+	return &Client{
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+		token: strings.TrimSpace(string(token)),
+	}, nil
+}
+
+// ListSessions returns the sessions of the user, or of all users when username is empty. A non-empty subject only
+// returns the sessions of the user with that downstream subject.
+func (c *Client) ListSessions(ctx context.Context, username, subject string) ([]Session, error) {
+	var sessions []Session
+	if err := c.do(ctx, http.MethodGet, SessionsPath, url.Values{"username": {username}, "subject": {subject}}, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeUser deletes all of the sessions of the user. The subject must be set when the sessions with the username
+// belong to several identities, e.g. of different upstream identity providers.
+func (c *Client) RevokeUser(ctx context.Context, username, subject string) (*RevokedUser, error) {
+	var revoked RevokedUser
+	if err := c.do(ctx, http.MethodPost, RevokeUserPath, url.Values{"username": {username}, "subject": {subject}}, &revoked); err != nil {
+		return nil, err
+	}
+	return &revoked, nil
+}
+
+// RotateJWKS replaces the signing key of the named FederationDomain, or of all FederationDomains when the name is empty.
+func (c *Client) RotateJWKS(ctx context.Context, federationDomain string) ([]RotatedJWKS, error) {
+	var rotated []RotatedJWKS
+	if err := c.do(ctx, http.MethodPost, RotateJWKSPath, url.Values{"federationDomain": {federationDomain}}, &rotated); err != nil {
+		return nil, err
+	}
+	return rotated, nil
+}
+
+// Stats returns a summary of the state of the Supervisor.
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	var stats Stats
+	if err := c.do(ctx, http.MethodGet, StatsPath, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, result interface{}) error {
+	// The host is ignored, since every connection is made to the socket.
+	endpoint := url.URL{Scheme: "http", Host: "supervisor-admin", Path: path}
+	var body *strings.Reader
+	if method == http.MethodGet {
+		endpoint.RawQuery = params.Encode()
+		body = strings.NewReader("")
+	} else {
+		body = strings.NewReader(params.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), body)
+	if err != nil {
+		return fmt.Errorf("could not build admin request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if method != http.MethodGet {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not call the admin endpoint: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("admin endpoint returned %s", strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("could not decode admin response: %w", err)
+	}
+	return nil
+}
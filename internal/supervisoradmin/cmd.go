@@ -0,0 +1,99 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package supervisoradmin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"sigs.k8s.io/yaml"
+)
+
+const usage = "usage: pinniped-supervisor admin list-sessions|revoke-user|rotate-jwks|stats [flags]"
+
+// HandleArgs runs an admin subcommand and writes its result to w when args (without the program name) start with
+// "admin", e.g. "admin revoke-user --username alice". Otherwise it does nothing and returns false, so the caller can
+// continue with its usual argument handling.
+func HandleArgs(ctx context.Context, args []string, w io.Writer) (bool, error) {
+	if len(args) == 0 || args[0] != "admin" {
+		return false, nil
+	}
+	if len(args) < 2 {
+		return true, errors.New(usage)
+	}
+	command := args[1]
+
+	flags := flag.NewFlagSet("admin "+command, flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard) // errors are returned instead
+	var socketPath, format, username, subject, federationDomain string
+	flags.StringVar(&socketPath, "socket", DefaultSocketPath, "path of the admin endpoint's socket")
+	flags.StringVar(&format, "output", "json", "output format, either json or yaml")
+	flags.StringVar(&format, "o", "json", "output format, either json or yaml (shorthand)")
+	switch command {
+	case "list-sessions":
+		flags.StringVar(&username, "username", "", "only list the sessions of this user")
+		flags.StringVar(&subject, "subject", "", "only list the sessions of the user with this downstream subject")
+	case "revoke-user":
+		flags.StringVar(&username, "username", "", "the user whose sessions are revoked")
+		flags.StringVar(&subject, "subject", "", "the downstream subject of the user, required when the username belongs to several identities")
+	case "rotate-jwks":
+		flags.StringVar(&federationDomain, "federation-domain", "", "only rotate the signing key of this FederationDomain")
+	case "stats":
+	default:
+		return true, fmt.Errorf("unknown admin command %q (%s)", command, usage)
+	}
+	if err := flags.Parse(args[2:]); err != nil {
+		return true, err
+	}
+	if flags.NArg() != 0 {
+		return true, fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+	if format != "json" && format != "yaml" {
+		return true, fmt.Errorf(`invalid output format %q (must be "json" or "yaml")`, format)
+	}
+	if command == "revoke-user" && username == "" {
+		return true, errors.New("--username must be set")
+	}
+
+	client, err := NewClient(socketPath)
+	if err != nil {
+		return true, err
+	}
+	var result interface{}
+	switch command {
+	case "list-sessions":
+		result, err = client.ListSessions(ctx, username, subject)
+	case "revoke-user":
+		result, err = client.RevokeUser(ctx, username, subject)
+	case "rotate-jwks":
+		result, err = client.RotateJWKS(ctx, federationDomain)
+	case "stats":
+		result, err = client.Stats(ctx)
+	}
+	if err != nil {
+		return true, err
+	}
+	return true, printResult(w, format, result)
+}
+
+func printResult(w io.Writer, format string, result interface{}) error {
+	var output []byte
+	var err error
+	if format == "yaml" {
+		output, err = yaml.Marshal(result)
+	} else {
+		output, err = json.MarshalIndent(result, "", "  ")
+		output = append(output, '\n')
+	}
+	if err != nil {
+		return fmt.Errorf("could not encode admin response: %w", err)
+	}
+	_, err = w.Write(output)
+	return err
+}
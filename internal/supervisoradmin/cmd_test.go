@@ -0,0 +1,161 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package supervisoradmin
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleArgs(t *testing.T) {
+	// Unix socket paths are limited to about 100 characters, so don't use a directory named after the test.
+	dir, err := ioutil.TempDir("", "admin")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "admin.sock")
+
+	config, _ := newTestConfig(t)
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	server := &http.Server{Handler: NewHandler(config)}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	wrongTokenSocketPath := filepath.Join(dir, "wrong-token.sock")
+	require.NoError(t, WriteToken(socketPath, testToken))
+	require.NoError(t, WriteToken(wrongTokenSocketPath, "wrong-token"))
+	require.NoError(t, os.Symlink(socketPath, wrongTokenSocketPath))
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantHandled bool
+		wantOutput  string
+		wantErr     string
+	}{
+		{
+			name: "no args",
+		},
+		{
+			name: "other args",
+			args: []string{"/etc/podinfo", "/etc/config/pinniped.yaml"},
+		},
+		{
+			name:        "missing command",
+			args:        []string{"admin"},
+			wantHandled: true,
+			wantErr:     "usage: pinniped-supervisor admin list-sessions|revoke-user|rotate-jwks|stats [flags]",
+		},
+		{
+			name:        "unknown command",
+			args:        []string{"admin", "delete-everything"},
+			wantHandled: true,
+			wantErr:     `unknown admin command "delete-everything" (usage: pinniped-supervisor admin list-sessions|revoke-user|rotate-jwks|stats [flags])`,
+		},
+		{
+			name:        "flag of another command",
+			args:        []string{"admin", "stats", "--username", "alice"},
+			wantHandled: true,
+			wantErr:     "flag provided but not defined: -username",
+		},
+		{
+			name:        "unexpected arguments",
+			args:        []string{"admin", "revoke-user", "alice"},
+			wantHandled: true,
+			wantErr:     "unexpected arguments: [alice]",
+		},
+		{
+			name:        "revoke-user without username",
+			args:        []string{"admin", "revoke-user", "--socket", socketPath},
+			wantHandled: true,
+			wantErr:     "--username must be set",
+		},
+		{
+			name:        "invalid output",
+			args:        []string{"admin", "stats", "-o", "xml"},
+			wantHandled: true,
+			wantErr:     `invalid output format "xml" (must be "json" or "yaml")`,
+		},
+		{
+			name:        "missing token",
+			args:        []string{"admin", "stats", "--socket", filepath.Join(dir, "missing.sock")},
+			wantHandled: true,
+			wantErr:     "could not read admin token (is the admin endpoint enabled?): open " + filepath.Join(dir, "missing.sock.token") + ": no such file or directory",
+		},
+		{
+			name:        "wrong token",
+			args:        []string{"admin", "stats", "--socket", wrongTokenSocketPath},
+			wantHandled: true,
+			wantErr:     "admin endpoint returned Unauthorized: invalid admin token",
+		},
+		{
+			name:        "list-sessions",
+			args:        []string{"admin", "list-sessions", "--socket", socketPath, "--username", "bob"},
+			wantHandled: true,
+			wantOutput: `[
+  {
+    "requestID": "bob-request",
+    "username": "bob",
+    "subject": "https://upstream.example.com?sub=bob",
+    "clientID": "pinniped-cli",
+    "requestedAt": "2021-06-01T09:00:00Z",
+    "expiresAt": "2021-06-01T13:00:00Z",
+    "storageTypes": [
+      "access-token"
+    ]
+  }
+]
+`,
+		},
+		{
+			name:        "stats as yaml",
+			args:        []string{"admin", "stats", "--socket", socketPath, "-o", "yaml"},
+			wantHandled: true,
+			wantOutput: `federationDomains: 2
+sessions: 3
+storageSecrets:
+  access-token: 2
+  authcode: 1
+  refresh-token: 1
+upstreamOIDCIdentityProviders: 1
+users: 2
+`,
+		},
+		{
+			name:        "revoke-user",
+			args:        []string{"admin", "revoke-user", "--socket", socketPath, "--username", "bob", "--subject", "https://upstream.example.com?sub=bob", "-o", "yaml"},
+			wantHandled: true,
+			wantOutput:  "revokedAt: \"2021-06-01T12:00:00Z\"\nsessions: 1\nsubject: https://upstream.example.com?sub=bob\nusername: bob\n",
+		},
+		{
+			name:        "rotate-jwks of an unknown FederationDomain",
+			args:        []string{"admin", "rotate-jwks", "--socket", socketPath, "--federation-domain", "unknown"},
+			wantHandled: true,
+			wantErr:     `admin endpoint returned Not Found: FederationDomain "unknown" not found`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			handled, err := HandleArgs(context.Background(), tt.args, &out)
+			require.Equal(t, tt.wantHandled, handled)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Empty(t, out.String())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantOutput, out.String())
+		})
+	}
+}
@@ -0,0 +1,426 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package supervisoradmin implements the admin endpoint of the Supervisor, which serves the day-2 operations of the
+// "pinniped-supervisor admin" subcommands, and the client which those subcommands use to call it. The endpoint only
+// listens on a Unix domain socket inside of the Supervisor pod, and every request must present the bearer token which
+// the Supervisor writes next to the socket when it starts.
+package supervisoradmin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/klog/v2"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	configlisters "go.pinniped.dev/generated/latest/client/supervisor/listers/config/v1alpha1"
+	"go.pinniped.dev/internal/controller/supervisorconfig"
+	"go.pinniped.dev/internal/crud"
+	"go.pinniped.dev/internal/fositestorage/accesstoken"
+	"go.pinniped.dev/internal/fositestorage/authorizationcode"
+	"go.pinniped.dev/internal/fositestorage/openidconnect"
+	"go.pinniped.dev/internal/fositestorage/pkce"
+	"go.pinniped.dev/internal/fositestorage/refreshtoken"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/audit"
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/revocationlist"
+)
+
+// These are the paths of the admin endpoint.
+const (
+	SessionsPath   = "/sessions"
+	RevokeUserPath = "/revoke-user"
+	RotateJWKSPath = "/rotate-jwks"
+	StatsPath      = "/stats"
+)
+
+// revocationLifetime is how long a revocation is kept. It must be longer than the lifetime of the ID tokens which were
+// issued before it, plus the longest clock skew tolerance of a JWTAuthenticator, so that none of them is accepted again.
+//nolint:gochecknoglobals
+var revocationLifetime = oidc.DefaultOIDCTimeoutsConfiguration().IDTokenLifespan + time.Hour + time.Minute
+
+// sessionStorageTypes are the kinds of session storage Secrets which can belong to a login. Device authorizations are
+// not included, because they do not have an identity until they are approved.
+//nolint:gochecknoglobals
+var sessionStorageTypes = []string{
+	authorizationcode.TypeLabelValue,
+	pkce.TypeLabelValue,
+	openidconnect.TypeLabelValue,
+	accesstoken.TypeLabelValue,
+	refreshtoken.TypeLabelValue,
+}
+
+// Session is the stored state of a single login, i.e. of one authorization request and of the tokens which were
+// issued for it.
+type Session struct {
+	RequestID   string    `json:"requestID"`
+	Username    string    `json:"username"`
+	Subject     string    `json:"subject"`
+	ClientID    string    `json:"clientID"`
+	RequestedAt time.Time `json:"requestedAt"`
+	// ExpiresAt is when the last of the Secrets of the session will be garbage collected.
+	ExpiresAt time.Time `json:"expiresAt"`
+	// StorageTypes are the kinds of Secrets which are stored for the session, e.g. "access-token" and "refresh-token".
+	StorageTypes []string `json:"storageTypes"`
+
+	secretNames []string
+}
+
+// RevokedUser is the result of revoking the sessions of a user.
+type RevokedUser struct {
+	Username string `json:"username"`
+	Subject  string `json:"subject"`
+	Sessions int    `json:"sessions"`
+	// RevokedAt is the time before which the cluster-scoped tokens of the user are rejected by the JWTAuthenticators
+	// which poll the revocation list of the Supervisor.
+	RevokedAt time.Time `json:"revokedAt"`
+}
+
+// RotatedJWKS is the new signing key of a FederationDomain.
+type RotatedJWKS struct {
+	FederationDomain string `json:"federationDomain"`
+	Issuer           string `json:"issuer"`
+	KeyID            string `json:"keyID"`
+}
+
+// Stats summarizes the state of the Supervisor.
+type Stats struct {
+	FederationDomains             int `json:"federationDomains"`
+	UpstreamOIDCIdentityProviders int `json:"upstreamOIDCIdentityProviders"`
+	// SessionsInMemory is true in dev mode, in which the sessions are not counted.
+	SessionsInMemory bool `json:"sessionsInMemory,omitempty"`
+	Sessions         int  `json:"sessions"`
+	Users            int  `json:"users"`
+	// StorageSecrets counts the session storage Secrets of each kind.
+	StorageSecrets map[string]int `json:"storageSecrets"`
+}
+
+// Config contains the dependencies of the admin endpoint.
+type Config struct {
+	// Token is the bearer token which every request must present.
+	Token string
+	// Secrets is a client for the Secrets in the Supervisor's namespace, which is used to list and delete sessions and
+	// to rotate signing keys. Sessions are always listed from the API rather than from an informer cache, so that a
+	// revocation also finds the sessions which were created moments ago, possibly by another Supervisor pod.
+	Secrets corev1client.SecretInterface
+	// FederationDomainLister lists the FederationDomains in the Supervisor's namespace from the informer cache.
+	FederationDomainLister configlisters.FederationDomainNamespaceLister
+	UpstreamIDPs           provider.DynamicUpstreamIDPProvider
+	// SessionsInMemory is true in dev mode, in which the sessions are not stored in Secrets and cannot be managed.
+	SessionsInMemory bool
+	Clock            func() time.Time
+}
+
+// NewHandler returns the handler of the admin endpoint.
+func NewHandler(c Config) http.Handler {
+	h := &handler{config: c, revocations: revocationlist.NewStorage(c.Secrets, c.Clock, revocationLifetime)}
+	mux := http.NewServeMux()
+	mux.Handle(SessionsPath, h.endpoint(http.MethodGet, audit.EventAdminListSessions, h.listSessions))
+	mux.Handle(RevokeUserPath, h.endpoint(http.MethodPost, audit.EventAdminRevokeUser, h.revokeUser))
+	mux.Handle(RotateJWKSPath, h.endpoint(http.MethodPost, audit.EventAdminRotateJWKS, h.rotateJWKS))
+	mux.Handle(StatsPath, h.endpoint(http.MethodGet, "", h.stats))
+	return mux
+}
+
+// TokenPath returns the path of the file which holds the bearer token of the admin endpoint which listens on the
+// socket at socketPath.
+func TokenPath(socketPath string) string {
+	return socketPath + ".token"
+}
+
+// GenerateToken returns a new random bearer token for the admin endpoint.
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate admin token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// WriteToken writes the token next to the socket at socketPath, so that the subcommands can read it.
+func WriteToken(socketPath, token string) error {
+	if err := ioutil.WriteFile(TokenPath(socketPath), []byte(token), 0600); err != nil {
+		return fmt.Errorf("could not write admin token: %w", err)
+	}
+	return nil
+}
+
+type handler struct {
+	config      Config
+	revocations *revocationlist.Storage
+}
+
+// endpoint returns the handler of one operation. Every request for an operation with an event type is written to the
+// audit log, including the ones which fail to authenticate. The stats are not audited, since they identify nobody.
+func (h *handler) endpoint(method string, eventType audit.EventType, handle func(r *http.Request) (interface{}, error)) http.Handler {
+	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		result, err := h.handle(r, method, handle)
+		if eventType != "" {
+			audit.Record(r, audit.Event{Type: eventType, Username: r.FormValue("username"), Subject: r.FormValue("subject")}, err)
+		}
+		if err != nil {
+			plog.Debug("admin request failed", "path", r.URL.Path, "err", err)
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(result)
+	})
+}
+
+func (h *handler) handle(r *http.Request, method string, handle func(r *http.Request) (interface{}, error)) (interface{}, error) {
+	if !h.authenticated(r) {
+		return nil, httperr.New(http.StatusUnauthorized, "invalid admin token")
+	}
+	if r.Method != method {
+		return nil, httperr.Newf(http.StatusMethodNotAllowed, "%s (try %s)", r.Method, method)
+	}
+	return handle(r)
+}
+
+func (h *handler) authenticated(r *http.Request) bool {
+	const prefix = "Bearer "
+	authorization := r.Header.Get("Authorization")
+	if h.config.Token == "" || !strings.HasPrefix(authorization, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(authorization, prefix)), []byte(h.config.Token)) == 1
+}
+
+func (h *handler) listSessions(r *http.Request) (interface{}, error) {
+	return h.sessions(r.Context(), r.FormValue("username"), r.FormValue("subject"))
+}
+
+func (h *handler) revokeUser(r *http.Request) (interface{}, error) {
+	username, subject := r.FormValue("username"), r.FormValue("subject")
+	if username == "" {
+		return nil, httperr.New(http.StatusBadRequest, "username must be set")
+	}
+	sessions, err := h.sessions(r.Context(), username, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	// Usernames are mapped from the claims of the upstream identity providers, so two people who log in with different
+	// identity providers can end up with the same username. Refuse to guess which of them is meant.
+	var subjects []string
+	for _, session := range sessions {
+		if !containsString(subjects, session.Subject) {
+			subjects = append(subjects, session.Subject)
+		}
+	}
+	if len(subjects) > 1 {
+		sort.Strings(subjects)
+		return nil, httperr.Newf(http.StatusConflict,
+			"username %q belongs to several identities, choose one of their subjects: %s", username, strings.Join(subjects, ", "))
+	}
+	if subject == "" {
+		// The revocation list identifies users by their subject, which is only known from their sessions.
+		if len(subjects) == 0 {
+			return nil, httperr.Newf(http.StatusNotFound,
+				"username %q has no sessions, set its subject to revoke its tokens anyway", username)
+		}
+		subject = subjects[0]
+	}
+	for _, session := range sessions {
+		for _, name := range session.secretNames {
+			if err := h.config.Secrets.Delete(r.Context(), name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+				return nil, httperr.Newf(http.StatusInternalServerError, "could not delete session %s: %v", session.RequestID, err)
+			}
+		}
+	}
+
+	// Only record the revocation after the sessions are gone, so that no token can be issued after it.
+	revokedAt, err := h.revocations.Revoke(r.Context(), subject)
+	if err != nil {
+		return nil, httperr.Wrap(http.StatusInternalServerError, "could not record revocation", err)
+	}
+	plog.Info("revoked the sessions of a user via the admin endpoint", "username", username, "subject", subject, "sessions", len(sessions))
+	return &RevokedUser{Username: username, Subject: subject, Sessions: len(sessions), RevokedAt: revokedAt}, nil
+}
+
+func (h *handler) rotateJWKS(r *http.Request) (interface{}, error) {
+	federationDomains, err := h.config.FederationDomainLister.List(labels.Everything())
+	if err != nil {
+		return nil, httperr.Newf(http.StatusInternalServerError, "could not list FederationDomains: %v", err)
+	}
+	sort.Slice(federationDomains, func(i, j int) bool { return federationDomains[i].Name < federationDomains[j].Name })
+
+	name := r.FormValue("federationDomain")
+	if name != "" {
+		var found []*configv1alpha1.FederationDomain
+		for _, federationDomain := range federationDomains {
+			if federationDomain.Name == name {
+				found = append(found, federationDomain)
+			}
+		}
+		if len(found) == 0 {
+			return nil, httperr.Newf(http.StatusNotFound, "FederationDomain %q not found", name)
+		}
+		federationDomains = found
+	}
+
+	rotated := []RotatedJWKS{}
+	for _, federationDomain := range federationDomains {
+		secretName := federationDomain.Status.Secrets.JWKS.Name
+		if secretName == "" {
+			return nil, httperr.Newf(http.StatusConflict, "FederationDomain %q does not have a JWKS yet", federationDomain.Name)
+		}
+		keyID, err := supervisorconfig.RotateJWKS(r.Context(), h.config.Secrets, secretName)
+		if err != nil {
+			return nil, httperr.Newf(http.StatusInternalServerError, "could not rotate the JWKS of FederationDomain %q: %v", federationDomain.Name, err)
+		}
+		plog.Info("rotated the JWKS of a FederationDomain via the admin endpoint",
+			"federationdomain", klog.KObj(federationDomain),
+			"keyid", keyID,
+		)
+		rotated = append(rotated, RotatedJWKS{
+			FederationDomain: federationDomain.Name,
+			Issuer:           federationDomain.Spec.Issuer,
+			KeyID:            keyID,
+		})
+	}
+	return rotated, nil
+}
+
+func (h *handler) stats(r *http.Request) (interface{}, error) {
+	federationDomains, err := h.config.FederationDomainLister.List(labels.Everything())
+	if err != nil {
+		return nil, httperr.Newf(http.StatusInternalServerError, "could not list FederationDomains: %v", err)
+	}
+	stats := &Stats{
+		FederationDomains:             len(federationDomains),
+		UpstreamOIDCIdentityProviders: len(h.config.UpstreamIDPs.GetIDPList()),
+		SessionsInMemory:              h.config.SessionsInMemory,
+		StorageSecrets:                map[string]int{},
+	}
+	if h.config.SessionsInMemory {
+		return stats, nil
+	}
+
+	sessions, err := h.sessions(r.Context(), "", "")
+	if err != nil {
+		return nil, err
+	}
+	users := map[string]bool{}
+	for _, session := range sessions {
+		users[session.Subject] = true
+	}
+	stats.Sessions, stats.Users = len(sessions), len(users)
+
+	secrets, err := h.config.Secrets.List(r.Context(), metav1.ListOptions{LabelSelector: crud.SecretLabelKey})
+	if err != nil {
+		return nil, httperr.Newf(http.StatusInternalServerError, "could not list Secrets: %v", err)
+	}
+	for _, secret := range secrets.Items {
+		stats.StorageSecrets[secret.Labels[crud.SecretLabelKey]]++
+	}
+	return stats, nil
+}
+
+// storedSession contains the fields of the fosite requests in the session storage Secrets which identify a login.
+type storedSession struct {
+	Request struct {
+		ID          string    `json:"id"`
+		RequestedAt time.Time `json:"requestedAt"`
+		Client      struct {
+			ID string `json:"id"`
+		} `json:"client"`
+		Session struct {
+			Claims struct {
+				Subject string
+				Extra   map[string]interface{}
+			}
+		} `json:"session"`
+	} `json:"request"`
+}
+
+// sessions returns the sessions of the user, or of all users when username is empty, ordered by when they started.
+// When subject is set, only the sessions of the user with that downstream subject are returned.
+func (h *handler) sessions(ctx context.Context, username, subject string) ([]Session, error) {
+	if h.config.SessionsInMemory {
+		return nil, httperr.New(http.StatusNotImplemented, "sessions are only stored in memory in dev mode")
+	}
+
+	byRequestID := map[string]*Session{}
+	for _, storageType := range sessionStorageTypes {
+		secrets, err := h.config.Secrets.List(ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(labels.Set{crud.SecretLabelKey: storageType}).String(),
+		})
+		if err != nil {
+			return nil, httperr.Newf(http.StatusInternalServerError, "could not list %s Secrets: %v", storageType, err)
+		}
+		for i := range secrets.Items {
+			secret := &secrets.Items[i]
+			var stored storedSession
+			if err := crud.FromSecret(storageType, secret, &stored); err != nil {
+				plog.Debug("skipping invalid session storage secret", "secret", klog.KObj(secret), "err", err)
+				continue
+			}
+			sessionUsername, _ := stored.Request.Session.Claims.Extra[oidc.DownstreamUsernameClaim].(string)
+			if username != "" && sessionUsername != username {
+				continue
+			}
+			if subject != "" && stored.Request.Session.Claims.Subject != subject {
+				continue
+			}
+
+			session, ok := byRequestID[stored.Request.ID]
+			if !ok {
+				session = &Session{
+					RequestID:   stored.Request.ID,
+					Username:    sessionUsername,
+					Subject:     stored.Request.Session.Claims.Subject,
+					ClientID:    stored.Request.Client.ID,
+					RequestedAt: stored.Request.RequestedAt,
+				}
+				byRequestID[stored.Request.ID] = session
+			}
+			if !containsString(session.StorageTypes, storageType) {
+				session.StorageTypes = append(session.StorageTypes, storageType)
+			}
+			session.secretNames = append(session.secretNames, secret.Name)
+			expiresAt, err := time.Parse(crud.SecretLifetimeAnnotationDateFormat, secret.Annotations[crud.SecretLifetimeAnnotationKey])
+			if err == nil && expiresAt.After(session.ExpiresAt) {
+				session.ExpiresAt = expiresAt
+			}
+		}
+	}
+
+	sessions := make([]Session, 0, len(byRequestID))
+	for _, session := range byRequestID {
+		sessions = append(sessions, *session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].RequestedAt.Equal(sessions[j].RequestedAt) {
+			return sessions[i].RequestedAt.Before(sessions[j].RequestedAt)
+		}
+		return sessions[i].RequestID < sessions[j].RequestID
+	})
+	return sessions, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,443 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package supervisoradmin
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	configlisters "go.pinniped.dev/generated/latest/client/supervisor/listers/config/v1alpha1"
+	"go.pinniped.dev/internal/fositestorage/accesstoken"
+	"go.pinniped.dev/internal/fositestorage/authorizationcode"
+	"go.pinniped.dev/internal/fositestorage/refreshtoken"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/audit"
+	"go.pinniped.dev/internal/oidc/oidctestutil"
+	"go.pinniped.dev/internal/oidc/provider"
+)
+
+const (
+	testNamespace = "test-namespace"
+	testToken     = "test-admin-token"
+)
+
+// newTestConfig returns the config of an admin endpoint for a Supervisor which has two FederationDomains, one
+// upstream identity provider, and three sessions of two users.
+func newTestConfig(t *testing.T) (Config, *kubernetesfake.Clientset) {
+	t.Helper()
+	ctx := context.Background()
+
+	kubeClient := kubernetesfake.NewSimpleClientset(
+		newJWKSSecret(t, "federation-domain-1-jwks"),
+		newJWKSSecret(t, "federation-domain-2-jwks"),
+	)
+	secrets := kubeClient.CoreV1().Secrets(testNamespace)
+	clock := func() time.Time { return time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC) }
+
+	aliceRequest := newTestRequest("alice-request", "https://upstream.example.com", "alice", clock().Add(-2*time.Hour))
+	aliceOtherRequest := newTestRequest("alice-other-request", "https://upstream.example.com", "alice", clock().Add(-1*time.Hour))
+	bobRequest := newTestRequest("bob-request", "https://upstream.example.com", "bob", clock().Add(-3*time.Hour))
+
+	accessTokens := accesstoken.New(secrets, clock, time.Hour)
+	refreshTokens := refreshtoken.New(secrets, clock, 9*time.Hour)
+	authCodes := authorizationcode.New(secrets, clock, time.Minute)
+	require.NoError(t, accessTokens.CreateAccessTokenSession(ctx, "alice-access-token", aliceRequest))
+	require.NoError(t, refreshTokens.CreateRefreshTokenSession(ctx, "alice-refresh-token", aliceRequest))
+	require.NoError(t, authCodes.CreateAuthorizeCodeSession(ctx, "alice-auth-code", aliceOtherRequest))
+	require.NoError(t, accessTokens.CreateAccessTokenSession(ctx, "bob-access-token", bobRequest))
+
+	federationDomainIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, name := range []string{"federation-domain-2", "federation-domain-1"} {
+		federationDomain := &configv1alpha1.FederationDomain{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			Spec:       configv1alpha1.FederationDomainSpec{Issuer: "https://issuer.example.com/" + name},
+		}
+		federationDomain.Status.Secrets.JWKS.Name = name + "-jwks"
+		require.NoError(t, federationDomainIndexer.Add(federationDomain))
+	}
+
+	upstreamIDPs := provider.NewDynamicUpstreamIDPProvider()
+	upstreamIDPs.SetIDPList([]provider.UpstreamOIDCIdentityProviderI{&oidctestutil.TestUpstreamOIDCIdentityProvider{Name: "some-idp"}})
+
+	return Config{
+		Token:                  testToken,
+		Secrets:                secrets,
+		FederationDomainLister: configlisters.NewFederationDomainLister(federationDomainIndexer).FederationDomains(testNamespace),
+		UpstreamIDPs:           upstreamIDPs,
+		Clock:                  clock,
+	}, kubeClient
+}
+
+func newTestRequest(id, upstreamIssuer, username string, requestedAt time.Time) *fosite.Request {
+	return &fosite.Request{
+		ID:          id,
+		RequestedAt: requestedAt,
+		Client:      &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: "pinniped-cli"}},
+		Session:     oidc.MakeDownstreamSession(upstreamIssuer+"?sub="+username, username, []string{"some-group"}, "some-uid"),
+	}
+}
+
+func newJWKSSecret(t *testing.T, name string) *corev1.Secret {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwk := jose.JSONWebKey{Key: key, KeyID: "pinniped-supervisor-key", Algorithm: "ES256", Use: "sig"}
+	jwkData, err := json.Marshal(jwk)
+	require.NoError(t, err)
+	jwksData, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}})
+	require.NoError(t, err)
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Type:       "secrets.pinniped.dev/federation-domain-jwks",
+		Data:       map[string][]byte{"activeJWK": jwkData, "jwks": jwksData},
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		params  url.Values
+		token   string
+		devMode bool
+		// otherAlice adds a session of another user named alice, who logged in with another upstream identity provider.
+		otherAlice     bool
+		wantStatus     int
+		wantBody       string
+		wantJSON       string
+		wantSecrets    []string
+		wantRotatedKey []string
+	}{
+		{
+			name:       "missing token",
+			method:     http.MethodGet,
+			path:       StatsPath,
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "Unauthorized: invalid admin token\n",
+		},
+		{
+			name:       "wrong token",
+			method:     http.MethodGet,
+			path:       StatsPath,
+			token:      "wrong-token",
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "Unauthorized: invalid admin token\n",
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			path:       RevokeUserPath,
+			token:      testToken,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   "Method Not Allowed: GET (try POST)\n",
+		},
+		{
+			name:       "unknown path",
+			method:     http.MethodGet,
+			path:       "/unknown",
+			token:      testToken,
+			wantStatus: http.StatusNotFound,
+			wantBody:   "404 page not found\n",
+		},
+		{
+			name:       "list all sessions",
+			method:     http.MethodGet,
+			path:       SessionsPath,
+			token:      testToken,
+			wantStatus: http.StatusOK,
+			wantJSON: `[
+				{"requestID": "bob-request", "username": "bob", "subject": "https://upstream.example.com?sub=bob", "clientID": "pinniped-cli",
+				 "requestedAt": "2021-06-01T09:00:00Z", "expiresAt": "2021-06-01T13:00:00Z", "storageTypes": ["access-token"]},
+				{"requestID": "alice-request", "username": "alice", "subject": "https://upstream.example.com?sub=alice", "clientID": "pinniped-cli",
+				 "requestedAt": "2021-06-01T10:00:00Z", "expiresAt": "2021-06-01T21:00:00Z", "storageTypes": ["access-token", "refresh-token"]},
+				{"requestID": "alice-other-request", "username": "alice", "subject": "https://upstream.example.com?sub=alice", "clientID": "pinniped-cli",
+				 "requestedAt": "2021-06-01T11:00:00Z", "expiresAt": "2021-06-01T12:01:00Z", "storageTypes": ["authcode"]}
+			]`,
+		},
+		{
+			name:       "list the sessions of a user",
+			method:     http.MethodGet,
+			path:       SessionsPath,
+			params:     url.Values{"username": {"bob"}},
+			token:      testToken,
+			wantStatus: http.StatusOK,
+			wantJSON: `[
+				{"requestID": "bob-request", "username": "bob", "subject": "https://upstream.example.com?sub=bob", "clientID": "pinniped-cli",
+				 "requestedAt": "2021-06-01T09:00:00Z", "expiresAt": "2021-06-01T13:00:00Z", "storageTypes": ["access-token"]}
+			]`,
+		},
+		{
+			name:       "list the sessions of a user without sessions",
+			method:     http.MethodGet,
+			path:       SessionsPath,
+			params:     url.Values{"username": {"carol"}},
+			token:      testToken,
+			wantStatus: http.StatusOK,
+			wantJSON:   `[]`,
+		},
+		{
+			name:       "list sessions in dev mode",
+			method:     http.MethodGet,
+			path:       SessionsPath,
+			token:      testToken,
+			devMode:    true,
+			wantStatus: http.StatusNotImplemented,
+			wantBody:   "Not Implemented: sessions are only stored in memory in dev mode\n",
+		},
+		{
+			name:       "revoke a user",
+			method:     http.MethodPost,
+			path:       RevokeUserPath,
+			params:     url.Values{"username": {"alice"}},
+			token:      testToken,
+			wantStatus: http.StatusOK,
+			wantJSON:   `{"username": "alice", "subject": "https://upstream.example.com?sub=alice", "sessions": 2, "revokedAt": "2021-06-01T12:00:00Z"}`,
+			wantSecrets: []string{
+				"federation-domain-1-jwks",
+				"federation-domain-2-jwks",
+				"pinniped-storage-access-token-",
+				"pinniped-storage-revoked-user-",
+			},
+		},
+		{
+			name:       "revoke a username which belongs to several identities",
+			method:     http.MethodPost,
+			path:       RevokeUserPath,
+			params:     url.Values{"username": {"alice"}},
+			token:      testToken,
+			otherAlice: true,
+			wantStatus: http.StatusConflict,
+			wantBody: "Conflict: username \"alice\" belongs to several identities, choose one of their subjects: " +
+				"https://other-upstream.example.com?sub=alice, https://upstream.example.com?sub=alice\n",
+			wantSecrets: []string{
+				"federation-domain-1-jwks",
+				"federation-domain-2-jwks",
+				"pinniped-storage-access-token-",
+				"pinniped-storage-access-token-",
+				"pinniped-storage-access-token-",
+				"pinniped-storage-authcode-",
+				"pinniped-storage-refresh-token-",
+			},
+		},
+		{
+			name:       "revoke one of the identities of a username",
+			method:     http.MethodPost,
+			path:       RevokeUserPath,
+			params:     url.Values{"username": {"alice"}, "subject": {"https://upstream.example.com?sub=alice"}},
+			token:      testToken,
+			otherAlice: true,
+			wantStatus: http.StatusOK,
+			wantJSON:   `{"username": "alice", "subject": "https://upstream.example.com?sub=alice", "sessions": 2, "revokedAt": "2021-06-01T12:00:00Z"}`,
+			wantSecrets: []string{
+				"federation-domain-1-jwks",
+				"federation-domain-2-jwks",
+				"pinniped-storage-access-token-",
+				"pinniped-storage-access-token-",
+				"pinniped-storage-revoked-user-",
+			},
+		},
+		{
+			name:       "revoke a user without sessions",
+			method:     http.MethodPost,
+			path:       RevokeUserPath,
+			params:     url.Values{"username": {"carol"}},
+			token:      testToken,
+			wantStatus: http.StatusNotFound,
+			wantBody:   "Not Found: username \"carol\" has no sessions, set its subject to revoke its tokens anyway\n",
+		},
+		{
+			name:       "revoke the subject of a user without sessions",
+			method:     http.MethodPost,
+			path:       RevokeUserPath,
+			params:     url.Values{"username": {"carol"}, "subject": {"https://upstream.example.com?sub=carol"}},
+			token:      testToken,
+			wantStatus: http.StatusOK,
+			wantJSON:   `{"username": "carol", "subject": "https://upstream.example.com?sub=carol", "sessions": 0, "revokedAt": "2021-06-01T12:00:00Z"}`,
+		},
+		{
+			name:       "revoke a user without username",
+			method:     http.MethodPost,
+			path:       RevokeUserPath,
+			token:      testToken,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: username must be set\n",
+		},
+		{
+			name:           "rotate all JWKS",
+			method:         http.MethodPost,
+			path:           RotateJWKSPath,
+			token:          testToken,
+			wantStatus:     http.StatusOK,
+			wantRotatedKey: []string{"federation-domain-1", "federation-domain-2"},
+		},
+		{
+			name:           "rotate the JWKS of one FederationDomain",
+			method:         http.MethodPost,
+			path:           RotateJWKSPath,
+			params:         url.Values{"federationDomain": {"federation-domain-2"}},
+			token:          testToken,
+			wantStatus:     http.StatusOK,
+			wantRotatedKey: []string{"federation-domain-2"},
+		},
+		{
+			name:       "rotate the JWKS of an unknown FederationDomain",
+			method:     http.MethodPost,
+			path:       RotateJWKSPath,
+			params:     url.Values{"federationDomain": {"unknown"}},
+			token:      testToken,
+			wantStatus: http.StatusNotFound,
+			wantBody:   "Not Found: FederationDomain \"unknown\" not found\n",
+		},
+		{
+			name:       "stats",
+			method:     http.MethodGet,
+			path:       StatsPath,
+			token:      testToken,
+			wantStatus: http.StatusOK,
+			wantJSON: `{
+				"federationDomains": 2, "upstreamOIDCIdentityProviders": 1, "sessions": 3, "users": 2,
+				"storageSecrets": {"access-token": 2, "authcode": 1, "refresh-token": 1}
+			}`,
+		},
+		{
+			name:       "stats in dev mode",
+			method:     http.MethodGet,
+			path:       StatsPath,
+			token:      testToken,
+			devMode:    true,
+			wantStatus: http.StatusOK,
+			wantJSON: `{
+				"federationDomains": 2, "upstreamOIDCIdentityProviders": 1, "sessionsInMemory": true, "sessions": 0, "users": 0,
+				"storageSecrets": {}
+			}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			config, kubeClient := newTestConfig(t)
+			config.SessionsInMemory = tt.devMode
+			if tt.otherAlice {
+				// The session is created after the handler's dependencies, like a login on another Supervisor pod.
+				require.NoError(t, accesstoken.New(config.Secrets, config.Clock, time.Hour).CreateAccessTokenSession(context.Background(),
+					"other-alice-access-token", newTestRequest("other-alice-request", "https://other-upstream.example.com", "alice", config.Clock())))
+			}
+
+			var body *strings.Reader
+			target := tt.path
+			if tt.method == http.MethodGet {
+				target += "?" + tt.params.Encode()
+				body = strings.NewReader("")
+			} else {
+				body = strings.NewReader(tt.params.Encode())
+			}
+			req := httptest.NewRequest(tt.method, target, body)
+			if tt.method == http.MethodPost {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rsp := httptest.NewRecorder()
+			NewHandler(config).ServeHTTP(rsp, req)
+
+			require.Equal(t, tt.wantStatus, rsp.Code, rsp.Body.String())
+			if tt.wantBody != "" {
+				require.Equal(t, tt.wantBody, rsp.Body.String())
+			}
+			if tt.wantJSON != "" {
+				require.Equal(t, "application/json", rsp.Header().Get("Content-Type"))
+				require.JSONEq(t, tt.wantJSON, rsp.Body.String())
+			}
+
+			if tt.wantSecrets != nil {
+				secretList, err := kubeClient.CoreV1().Secrets(testNamespace).List(context.Background(), metav1.ListOptions{})
+				require.NoError(t, err)
+				var names []string
+				for _, secret := range secretList.Items {
+					names = append(names, secret.Name)
+				}
+				sort.Strings(names)
+				require.Len(t, names, len(tt.wantSecrets))
+				for i := range names {
+					require.True(t, strings.HasPrefix(names[i], tt.wantSecrets[i]), "unexpected secret %s", names[i])
+				}
+			}
+
+			if tt.wantRotatedKey != nil {
+				var rotated []RotatedJWKS
+				require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &rotated))
+				require.Len(t, rotated, len(tt.wantRotatedKey))
+				for i, name := range tt.wantRotatedKey {
+					require.Equal(t, name, rotated[i].FederationDomain)
+					require.Equal(t, "https://issuer.example.com/"+name, rotated[i].Issuer)
+
+					secret, err := kubeClient.CoreV1().Secrets(testNamespace).Get(context.Background(), name+"-jwks", metav1.GetOptions{})
+					require.NoError(t, err)
+					var jwks jose.JSONWebKeySet
+					require.NoError(t, json.Unmarshal(secret.Data["jwks"], &jwks))
+					require.Len(t, jwks.Keys, 2)
+					require.Equal(t, rotated[i].KeyID, jwks.Keys[0].KeyID)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerAuditEvents(t *testing.T) {
+	var auditLog bytes.Buffer
+	audit.SetOutput(&auditLog)
+	t.Cleanup(func() { audit.SetOutput(nil) })
+
+	config, _ := newTestConfig(t)
+	handler := NewHandler(config)
+	request := func(method, path, token string, params url.Values) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer "+token)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	request(http.MethodPost, RevokeUserPath, "wrong-token", url.Values{"username": {"alice"}})
+	request(http.MethodGet, SessionsPath+"?username=bob", testToken, nil)
+	request(http.MethodPost, RevokeUserPath, testToken, url.Values{"username": {"bob"}})
+	request(http.MethodPost, RotateJWKSPath, testToken, url.Values{"federationDomain": {"unknown"}})
+	request(http.MethodGet, StatsPath, testToken, nil)
+
+	var events []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(auditLog.String()), "\n") {
+		var event map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		require.NotEmpty(t, event["ts"])
+		delete(event, "ts")
+		events = append(events, event)
+	}
+	require.Equal(t, []map[string]interface{}{
+		{"event": "admin_revoke_user", "outcome": "failure", "error": "invalid admin token", "username": "alice", "sourceIP": "192.0.2.1"},
+		{"event": "admin_list_sessions", "outcome": "success", "username": "bob", "sourceIP": "192.0.2.1"},
+		{"event": "admin_revoke_user", "outcome": "success", "username": "bob", "sourceIP": "192.0.2.1"},
+		{"event": "admin_rotate_jwks", "outcome": "failure", "error": "FederationDomain \"unknown\" not found", "sourceIP": "192.0.2.1"},
+	}, events)
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package supervisormetrics implements the metrics endpoint of the Supervisor, which serves the Prometheus metrics
+// of all of its components on a separate listener, so that they are never exposed next to the OIDC endpoints.
+// The endpoint can optionally serve TLS with a certificate from a Secret, and require that its callers are
+// authorized by Kubernetes to get the "/metrics" non-resource URL. The debug endpoint of the Supervisor uses the same
+// authorization and TLS settings.
+package supervisormetrics
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authenticationv1client "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/plog"
+)
+
+// Authorizer checks the bearer token of each request with a TokenReview, and then checks whether its user may get
+// the path of the request with a SubjectAccessReview, like the /metrics endpoint of the Kubernetes API server.
+type Authorizer struct {
+	TokenReviews         authenticationv1client.TokenReviewInterface
+	SubjectAccessReviews authorizationv1client.SubjectAccessReviewInterface
+}
+
+// NewHandler returns the handler of the metrics endpoint. When authorizer is nil, anyone who can reach the endpoint
+// can read the metrics.
+func NewHandler(authorizer *Authorizer) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", legacyregistry.Handler())
+	return WithAuthorization(authorizer, mux)
+}
+
+// WithAuthorization wraps the handler so that it only serves the requests which the authorizer allows. When
+// authorizer is nil, it returns the handler unchanged.
+func WithAuthorization(authorizer *Authorizer, handler http.Handler) http.Handler {
+	if authorizer == nil {
+		return handler
+	}
+	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if err := authorizer.authorize(r); err != nil {
+			return err
+		}
+		handler.ServeHTTP(w, r)
+		return nil
+	})
+}
+
+func (a *Authorizer) authorize(r *http.Request) error {
+	ctx := r.Context()
+
+	authorization := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	if token == "" || token == authorization {
+		return httperr.New(http.StatusUnauthorized, "bearer token required")
+	}
+
+	user, err := a.authenticate(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	review, err := a.SubjectAccessReviews.Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{Path: r.URL.Path, Verb: "get"},
+			User:                  user.Username,
+			Groups:                user.Groups,
+			UID:                   user.UID,
+			Extra:                 extra(user.Extra),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return httperr.Wrap(http.StatusInternalServerError, "could not authorize request", err)
+	}
+	if !review.Status.Allowed {
+		plog.Debug("request forbidden", "user", user.Username, "path", r.URL.Path, "reason", review.Status.Reason)
+		return httperr.Newf(http.StatusForbidden, "user %q cannot get path %q", user.Username, r.URL.Path)
+	}
+	return nil
+}
+
+func (a *Authorizer) authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	review, err := a.TokenReviews.Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, httperr.Wrap(http.StatusInternalServerError, "could not authenticate request", err)
+	}
+	if !review.Status.Authenticated {
+		return nil, httperr.New(http.StatusUnauthorized, "invalid bearer token")
+	}
+	return &review.Status.User, nil
+}
+
+func extra(in map[string]authenticationv1.ExtraValue) map[string]authorizationv1.ExtraValue {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]authorizationv1.ExtraValue, len(in))
+	for k, v := range in {
+		out[k] = authorizationv1.ExtraValue(v)
+	}
+	return out
+}
+
+// TLSConfig returns the TLS config of the metrics or debug endpoint, which serves the certificate in the kubernetes.io/tls
+// Secret with the given name. The Secret is read from the informer cache for each handshake, so a renewed
+// certificate is served as soon as the informer has seen it.
+func TLSConfig(secrets corev1listers.SecretNamespaceLister, secretName string) *tls.Config {
+	var (
+		lock            sync.Mutex
+		resourceVersion string
+		cert            *tls.Certificate
+	)
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			secret, err := secrets.Get(secretName)
+			if err != nil {
+				return nil, fmt.Errorf("could not get TLS secret %q: %w", secretName, err)
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			if cert != nil && secret.ResourceVersion == resourceVersion {
+				return cert, nil
+			}
+			parsed, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate or private key in TLS secret %q: %w", secretName, err)
+			}
+			resourceVersion, cert = secret.ResourceVersion, &parsed
+			return cert, nil
+		},
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package supervisormetrics
+
+import (
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	kubetesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	"go.pinniped.dev/internal/certauthority"
+)
+
+func TestHandler(t *testing.T) {
+	get := func(handler http.Handler, path, authorization string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rsp := httptest.NewRecorder()
+		handler.ServeHTTP(rsp, req)
+		return rsp
+	}
+
+	t.Run("without authorization", func(t *testing.T) {
+		handler := NewHandler(nil)
+		rsp := get(handler, "/metrics", "")
+		require.Equal(t, http.StatusOK, rsp.Code)
+		require.Contains(t, rsp.Body.String(), "# TYPE")
+		require.Equal(t, http.StatusNotFound, get(handler, "/healthz", "").Code)
+	})
+
+	t.Run("with authorization", func(t *testing.T) {
+		kubeClient := kubernetesfake.NewSimpleClientset()
+		var gotSAR *authorizationv1.SubjectAccessReview
+		kubeClient.PrependReactor("create", "tokenreviews", func(action kubetesting.Action) (bool, runtime.Object, error) {
+			review := action.(kubetesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+			switch review.Spec.Token {
+			case "allowed-token":
+				review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{
+					Username: "system:serviceaccount:monitoring:prometheus",
+					UID:      "some-uid",
+					Groups:   []string{"system:serviceaccounts"},
+					Extra:    map[string]authenticationv1.ExtraValue{"some-key": {"some-value"}},
+				}}
+			case "forbidden-token":
+				review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "some-user"}}
+			case "broken-token":
+				return true, nil, errors.New("some api error")
+			}
+			return true, review, nil
+		})
+		kubeClient.PrependReactor("create", "subjectaccessreviews", func(action kubetesting.Action) (bool, runtime.Object, error) {
+			gotSAR = action.(kubetesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+			gotSAR.Status.Allowed = gotSAR.Spec.User == "system:serviceaccount:monitoring:prometheus"
+			return true, gotSAR, nil
+		})
+		handler := NewHandler(&Authorizer{
+			TokenReviews:         kubeClient.AuthenticationV1().TokenReviews(),
+			SubjectAccessReviews: kubeClient.AuthorizationV1().SubjectAccessReviews(),
+		})
+
+		rsp := get(handler, "/metrics", "")
+		require.Equal(t, http.StatusUnauthorized, rsp.Code)
+		require.Equal(t, "Unauthorized: bearer token required\n", rsp.Body.String())
+
+		rsp = get(handler, "/metrics", "Basic dXNlcjpwYXNz")
+		require.Equal(t, http.StatusUnauthorized, rsp.Code)
+		require.Equal(t, "Unauthorized: bearer token required\n", rsp.Body.String())
+
+		rsp = get(handler, "/metrics", "Bearer invalid-token")
+		require.Equal(t, http.StatusUnauthorized, rsp.Code)
+		require.Equal(t, "Unauthorized: invalid bearer token\n", rsp.Body.String())
+
+		rsp = get(handler, "/metrics", "Bearer broken-token")
+		require.Equal(t, http.StatusInternalServerError, rsp.Code)
+
+		rsp = get(handler, "/metrics", "Bearer forbidden-token")
+		require.Equal(t, http.StatusForbidden, rsp.Code)
+		require.Equal(t, "Forbidden: user \"some-user\" cannot get path \"/metrics\"\n", rsp.Body.String())
+
+		rsp = get(handler, "/metrics", "Bearer allowed-token")
+		require.Equal(t, http.StatusOK, rsp.Code)
+		require.Contains(t, rsp.Body.String(), "# TYPE")
+		require.Equal(t, authorizationv1.SubjectAccessReviewSpec{
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{Path: "/metrics", Verb: "get"},
+			User:                  "system:serviceaccount:monitoring:prometheus",
+			Groups:                []string{"system:serviceaccounts"},
+			UID:                   "some-uid",
+			Extra:                 map[string]authorizationv1.ExtraValue{"some-key": {"some-value"}},
+		}, gotSAR.Spec)
+	})
+}
+
+func TestTLSConfig(t *testing.T) {
+	ca, err := certauthority.New(pkix.Name{CommonName: "some-ca"}, time.Hour)
+	require.NoError(t, err)
+	certPEM, keyPEM, err := ca.IssuePEM(pkix.Name{CommonName: "some-server"}, []string{"some-server.example.com"}, time.Hour)
+	require.NoError(t, err)
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	secrets := corev1listers.NewSecretLister(indexer).Secrets("some-namespace")
+	config := TLSConfig(secrets, "some-tls-secret")
+
+	_, err = config.GetCertificate(&tls.ClientHelloInfo{})
+	require.EqualError(t, err, `could not get TLS secret "some-tls-secret": secret "some-tls-secret" not found`)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "some-tls-secret", Namespace: "some-namespace", ResourceVersion: "1"},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: certPEM, corev1.TLSPrivateKeyKey: keyPEM},
+	}
+	require.NoError(t, indexer.Add(secret))
+	cert, err := config.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	require.NotNil(t, cert)
+	cachedCert, err := config.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	require.Same(t, cert, cachedCert)
+
+	invalid := secret.DeepCopy()
+	invalid.ResourceVersion = "2"
+	invalid.Data[corev1.TLSCertKey] = []byte("not a certificate")
+	require.NoError(t, indexer.Update(invalid))
+	_, err = config.GetCertificate(&tls.ClientHelloInfo{})
+	require.EqualError(t, err, `invalid certificate or private key in TLS secret "some-tls-secret": tls: failed to find any PEM data in certificate input`)
+}
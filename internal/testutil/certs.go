@@ -61,6 +61,16 @@ func (v *ValidCert) RequireDNSName(expectDNSName string) {
 	require.Contains(v.t, v.parsed.DNSNames, expectDNSName, "expected an explicit DNS SAN, not just Common Name")
 }
 
+// RequireURI asserts that the certificate contains the provided URI SAN.
+func (v *ValidCert) RequireURI(expectURI string) {
+	v.t.Helper()
+	uris := make([]string, 0, len(v.parsed.URIs))
+	for _, uri := range v.parsed.URIs {
+		uris = append(uris, uri.String())
+	}
+	require.Contains(v.t, uris, expectURI)
+}
+
 // RequireLifetime asserts that the lifetime of the certificate matches the expected timestamps.
 func (v *ValidCert) RequireLifetime(expectNotBefore time.Time, expectNotAfter time.Time, delta time.Duration) {
 	v.t.Helper()
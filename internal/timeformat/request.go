@@ -0,0 +1,78 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package timeformat
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// TimeZoneParamName is the query or form parameter in which pages accept the IANA time zone of the user, e.g.
+// "Europe/Berlin". Browsers do not send their time zone, so pages which show times must pass it along themselves.
+const TimeZoneParamName = "tz"
+
+// locales are the languages which have their own layout, the first of which is the default. Go only knows the English
+// names of days and months, so the other languages use numeric dates in their usual order.
+//nolint: gochecknoglobals
+var locales = []struct {
+	tag    language.Tag
+	layout string
+}{
+	{tag: language.English, layout: humanLayout},
+	{tag: language.AmericanEnglish, layout: "Mon, Jan 2, 2006 3:04:05 PM MST"},
+	{tag: language.German, layout: "02.01.2006 15:04:05 MST"},
+	{tag: language.Spanish, layout: "02/01/2006 15:04:05 MST"},
+	{tag: language.French, layout: "02/01/2006 15:04:05 MST"},
+	{tag: language.Japanese, layout: "2006/01/02 15:04:05 MST"},
+	{tag: language.Chinese, layout: "2006-01-02 15:04:05 MST"},
+}
+
+//nolint: gochecknoglobals
+var localeMatcher = func() language.Matcher {
+	tags := make([]language.Tag, 0, len(locales))
+	for _, locale := range locales {
+		tags = append(tags, locale.tag)
+	}
+	return language.NewMatcher(tags)
+}()
+
+// Formatter formats times for one person, in their language and time zone.
+type Formatter struct {
+	Location *time.Location
+	layout   string
+}
+
+// ForRequest returns the Formatter for the user who sent r. The language is negotiated using the Accept-Language
+// header, and the time zone is read from the TimeZoneParamName parameter. Unknown time zones fall back to UTC rather
+// than to the time zone of the server, which would mean nothing to the user.
+func ForRequest(r *http.Request) Formatter {
+	f := Formatter{Location: time.UTC, layout: humanLayout}
+
+	if tz := r.FormValue(TimeZoneParamName); tz != "" && tz != "Local" {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			f.Location = loc
+		}
+	}
+
+	if tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language")); err == nil && len(tags) > 0 {
+		_, index, confidence := localeMatcher.Match(tags...)
+		if confidence != language.No {
+			f.layout = locales[index].layout
+		}
+	}
+	return f
+}
+
+// Human formats t like the package level Human, in the language and time zone of the Formatter.
+func (f Formatter) Human(t time.Time) string {
+	return t.In(f.Location).Format(f.layout)
+}
+
+// Expiry formats an expiration time like the package level Expiry, in the language and time zone of the Formatter.
+func (f Formatter) Expiry(expiresAt, now time.Time) string {
+	return fmt.Sprintf("%s (%s, %s)", f.Human(expiresAt), Relative(expiresAt, now), RFC3339(expiresAt))
+}
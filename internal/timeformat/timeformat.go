@@ -0,0 +1,45 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package timeformat formats timestamps which are shown to people, so that all of Pinniped's user-facing output
+// presents times in the same way.
+package timeformat
+
+import (
+	"fmt"
+	"time"
+)
+
+// humanLayout includes the day of week and the zone abbreviation, which people tend to find easier to read than
+// numeric offsets.
+const humanLayout = "Mon, 02 Jan 2006 15:04:05 MST"
+
+// Human formats t for a person in the provided location, e.g. "Thu, 04 Mar 2021 05:36:07 PST". A nil location
+// means the local time zone.
+func Human(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		loc = time.Local
+	}
+	return t.In(loc).Format(humanLayout)
+}
+
+// RFC3339 formats t in UTC as RFC3339, e.g. "2021-03-04T13:36:07Z", for output which might be copied into other tools.
+func RFC3339(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Relative describes t relative to now, rounded to the second, e.g. "in 30m0s" or "5s ago".
+func Relative(t, now time.Time) string {
+	d := t.Sub(now).Round(time.Second)
+	if d < 0 {
+		return fmt.Sprintf("%s ago", -d)
+	}
+	return fmt.Sprintf("in %s", d)
+}
+
+// Expiry describes an expiration time using both the human and RFC3339 formats, along with how far away it is,
+// e.g. "Thu, 04 Mar 2021 05:36:07 PST (in 30m0s, 2021-03-04T13:36:07Z)". The human format uses the location of now,
+// so callers can control the displayed time zone by passing time.Now().In(loc).
+func Expiry(expiresAt, now time.Time) string {
+	return fmt.Sprintf("%s (%s, %s)", Human(expiresAt, now.Location()), Relative(expiresAt, now), RFC3339(expiresAt))
+}
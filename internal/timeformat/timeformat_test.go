@@ -0,0 +1,126 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package timeformat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	pacific, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+	now := time.Date(2021, 3, 4, 13, 6, 7, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		t            time.Time
+		loc          *time.Location
+		wantHuman    string
+		wantRFC3339  string
+		wantRelative string
+		wantExpiry   string
+	}{
+		{
+			name:         "future in UTC",
+			t:            now.Add(30 * time.Minute),
+			loc:          time.UTC,
+			wantHuman:    "Thu, 04 Mar 2021 13:36:07 UTC",
+			wantRFC3339:  "2021-03-04T13:36:07Z",
+			wantRelative: "in 30m0s",
+			wantExpiry:   "Thu, 04 Mar 2021 13:36:07 UTC (in 30m0s, 2021-03-04T13:36:07Z)",
+		},
+		{
+			name:         "future in another time zone",
+			t:            now.Add(30 * time.Minute),
+			loc:          pacific,
+			wantHuman:    "Thu, 04 Mar 2021 05:36:07 PST",
+			wantRFC3339:  "2021-03-04T13:36:07Z",
+			wantRelative: "in 30m0s",
+			wantExpiry:   "Thu, 04 Mar 2021 05:36:07 PST (in 30m0s, 2021-03-04T13:36:07Z)",
+		},
+		{
+			name:         "past with sub-second precision",
+			t:            now.Add(-5*time.Second - 400*time.Millisecond),
+			loc:          time.UTC,
+			wantHuman:    "Thu, 04 Mar 2021 13:06:01 UTC",
+			wantRFC3339:  "2021-03-04T13:06:01Z",
+			wantRelative: "5s ago",
+			wantExpiry:   "Thu, 04 Mar 2021 13:06:01 UTC (5s ago, 2021-03-04T13:06:01Z)",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.wantHuman, Human(tt.t, tt.loc))
+			require.Equal(t, tt.wantRFC3339, RFC3339(tt.t.In(tt.loc)))
+			require.Equal(t, tt.wantRelative, Relative(tt.t, now))
+			require.Equal(t, tt.wantExpiry, Expiry(tt.t, now.In(tt.loc)))
+		})
+	}
+
+	t.Run("nil location means local", func(t *testing.T) {
+		require.Equal(t, Human(now, time.Local), Human(now, nil))
+	})
+}
+
+func TestForRequest(t *testing.T) {
+	now := time.Date(2021, 3, 4, 13, 6, 7, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		target         string
+		acceptLanguage string
+		wantHuman      string
+	}{
+		{
+			name:      "defaults to English in UTC",
+			target:    "/",
+			wantHuman: "Thu, 04 Mar 2021 13:06:07 UTC",
+		},
+		{
+			name:           "time zone and exactly matching language",
+			target:         "/?tz=America/Los_Angeles",
+			acceptLanguage: "en-US,en;q=0.9",
+			wantHuman:      "Thu, Mar 4, 2021 5:06:07 AM PST",
+		},
+		{
+			name:           "regional variant of a language",
+			target:         "/?tz=Europe/Berlin",
+			acceptLanguage: "de-CH",
+			wantHuman:      "04.03.2021 14:06:07 CET",
+		},
+		{
+			name:           "language preference order",
+			target:         "/",
+			acceptLanguage: "tlh, ja;q=0.8, fr;q=0.5",
+			wantHuman:      "2021/03/04 13:06:07 UTC",
+		},
+		{
+			name:           "unknown language and time zone",
+			target:         "/?tz=Mars/Olympus_Mons",
+			acceptLanguage: "tlh",
+			wantHuman:      "Thu, 04 Mar 2021 13:06:07 UTC",
+		},
+		{
+			name:      "the time zone of the server is never used",
+			target:    "/?tz=Local",
+			wantHuman: "Thu, 04 Mar 2021 13:06:07 UTC",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+			f := ForRequest(r)
+			require.Equal(t, tt.wantHuman, f.Human(now))
+			require.Equal(t, tt.wantHuman+" (in 1m0s, 2021-03-04T13:06:07Z)", f.Expiry(now, now.Add(-time.Minute)))
+		})
+	}
+}
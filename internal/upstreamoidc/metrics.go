@@ -0,0 +1,43 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// The requests to the upstream provider which are made during a login, used as the value of the "operation" label.
+const (
+	operationTokenExchange = "token_exchange"
+	operationPasswordGrant = "password_grant"
+	operationUserInfo      = "userinfo"
+)
+
+//nolint: gochecknoglobals
+var upstreamRequestDuration = metrics.NewHistogramVec(
+	&metrics.HistogramOpts{
+		Name: "pinniped_supervisor_upstream_oidc_request_duration_seconds",
+		Help: "Duration of the requests to each upstream OIDCIdentityProvider during logins by operation and result, " +
+			"i.e. the round trips which the callback and authorization endpoints wait for.",
+		Buckets:        metrics.ExponentialBuckets(0.01, 2, 12),
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"name", "operation", "result"},
+)
+
+//nolint: gochecknoinits
+func init() {
+	legacyregistry.MustRegister(upstreamRequestDuration)
+}
+
+func observeUpstreamRequest(name, operation string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	upstreamRequestDuration.WithLabelValues(name, operation, result).Observe(time.Since(start).Seconds())
+}
@@ -6,16 +6,28 @@ package upstreamoidc
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
 	"golang.org/x/oauth2"
+	"gopkg.in/square/go-jose.v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"go.pinniped.dev/internal/clockskew"
+	"go.pinniped.dev/internal/constable"
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/oidc"
 	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/oidc/usernametemplate"
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/pkg/oidcclient/nonce"
 	"go.pinniped.dev/pkg/oidcclient/oidctypes"
@@ -29,10 +41,33 @@ func New(config *oauth2.Config, provider *coreosoidc.Provider, client *http.Clie
 // ProviderConfig holds the active configuration of an upstream OIDC provider.
 type ProviderConfig struct {
 	Name          string
+	Issuer        string
 	UsernameClaim string
 	GroupsClaim   string
-	Config        *oauth2.Config
-	Provider      interface {
+	UIDClaim      string
+
+	// UsernameTemplate computes the username from multiple claims. When it is set, UsernameClaim is ignored.
+	UsernameTemplate *usernametemplate.Template
+
+	// RequireAccessTokenHash, RequireAuthorizationCodeHash, and RequireIssuerParameter reject responses from
+	// providers which omit the at_hash claim, the c_hash claim, or the iss authorization response parameter.
+	RequireAccessTokenHash       bool
+	RequireAuthorizationCodeHash bool
+	RequireIssuerParameter       bool
+
+	// AllowPasswordGrant lets non-interactive clients log in with a username and password, which are sent to the
+	// provider using the resource owner password credentials grant.
+	AllowPasswordGrant bool
+
+	// ClockSkewTolerance is how far the clock of the provider may disagree with ours when checking the exp and nbf
+	// claims of ID tokens.
+	ClockSkewTolerance time.Duration
+
+	// DecryptionKeys are the private keys of the client, which decrypt ID tokens that the provider encrypted as JWEs.
+	DecryptionKeys []interface{}
+
+	Config   *oauth2.Config
+	Provider interface {
 		Verifier(*coreosoidc.Config) *coreosoidc.IDTokenVerifier
 		UserInfo(ctx context.Context, tokenSource oauth2.TokenSource) (*coreosoidc.UserInfo, error)
 	}
@@ -60,37 +95,97 @@ func (p *ProviderConfig) GetUsernameClaim() string {
 	return p.UsernameClaim
 }
 
+func (p *ProviderConfig) GetUsernameTemplate() *usernametemplate.Template {
+	return p.UsernameTemplate
+}
+
 func (p *ProviderConfig) GetGroupsClaim() string {
 	return p.GroupsClaim
 }
 
+func (p *ProviderConfig) GetUIDClaim() string {
+	return p.UIDClaim
+}
+
+// ValidateIssuerParameter checks the "iss" parameter of an authorization response, which identifies the provider
+// which issued the authorization code. This prevents mix-up attacks, in which an authorization code from one
+// provider is sent to the callback of another provider.
+func (p *ProviderConfig) ValidateIssuerParameter(iss string) error {
+	if iss == "" {
+		if p.RequireIssuerParameter {
+			return httperr.New(http.StatusBadRequest, "authorization response is missing the iss parameter")
+		}
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(iss), []byte(p.Issuer)) != 1 {
+		return httperr.Newf(http.StatusBadRequest, "authorization response has unexpected iss parameter (expected %q, got %q)", p.Issuer, iss)
+	}
+	return nil
+}
+
 func (p *ProviderConfig) ExchangeAuthcodeAndValidateTokens(ctx context.Context, authcode string, pkceCodeVerifier pkce.Code, expectedIDTokenNonce nonce.Nonce, redirectURI string) (*oidctypes.Token, error) {
+	start := time.Now()
 	tok, err := p.Config.Exchange(
 		coreosoidc.ClientContext(ctx, p.Client),
 		authcode,
 		pkceCodeVerifier.Verifier(),
 		oauth2.SetAuthURLParam("redirect_uri", redirectURI),
 	)
+	observeUpstreamRequest(p.Name, operationTokenExchange, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.validateToken(ctx, tok, expectedIDTokenNonce, authcode)
+}
+
+func (p *ProviderConfig) AllowsPasswordGrant() bool {
+	return p.AllowPasswordGrant
+}
+
+// PasswordCredentialsGrantAndValidateTokens logs in with the resource owner password credentials grant, see
+// https://datatracker.ietf.org/doc/html/rfc6749#section-4.3. The tokens are validated like those of an authcode
+// exchange, except that there is no nonce to check.
+func (p *ProviderConfig) PasswordCredentialsGrantAndValidateTokens(ctx context.Context, username, password string) (*oidctypes.Token, error) {
+	start := time.Now()
+	tok, err := p.Config.PasswordCredentialsToken(coreosoidc.ClientContext(ctx, p.Client), username, password)
+	observeUpstreamRequest(p.Name, operationPasswordGrant, start, err)
 	if err != nil {
 		return nil, err
 	}
 
-	return p.ValidateToken(ctx, tok, expectedIDTokenNonce)
+	return p.validateToken(ctx, tok, "", "")
 }
 
 func (p *ProviderConfig) ValidateToken(ctx context.Context, tok *oauth2.Token, expectedIDTokenNonce nonce.Nonce) (*oidctypes.Token, error) {
+	return p.validateToken(ctx, tok, expectedIDTokenNonce, "")
+}
+
+// validateToken validates the tokens in the token response. The authcode is only non-empty when the response is
+// from an authorization code exchange, in which case the c_hash claim of the ID token is also checked.
+func (p *ProviderConfig) validateToken(ctx context.Context, tok *oauth2.Token, expectedIDTokenNonce nonce.Nonce, authcode string) (*oidctypes.Token, error) {
 	idTok, hasIDTok := tok.Extra("id_token").(string)
 	if !hasIDTok {
 		return nil, httperr.New(http.StatusBadRequest, "received response missing ID token")
 	}
-	validated, err := p.Provider.Verifier(&coreosoidc.Config{ClientID: p.GetClientID()}).Verify(coreosoidc.ClientContext(ctx, p.Client), idTok)
+	idTok, err := p.decryptIDToken(idTok)
+	if err != nil {
+		return nil, httperr.Wrap(http.StatusBadRequest, "received invalid ID token", err)
+	}
+	verifierConfig := clockskew.Config(coreosoidc.Config{ClientID: p.GetClientID()}, p.ClockSkewTolerance)
+	validated, err := p.Provider.Verifier(verifierConfig).Verify(coreosoidc.ClientContext(ctx, p.Client), idTok)
 	if err != nil {
 		return nil, httperr.Wrap(http.StatusBadRequest, "received invalid ID token", err)
 	}
+	if err := clockskew.Check(validated, p.ClockSkewTolerance, time.Now()); err != nil {
+		return nil, httperr.Wrap(http.StatusBadRequest, "received invalid ID token", err)
+	}
 	if validated.AccessTokenHash != "" {
 		if err := validated.VerifyAccessToken(tok.AccessToken); err != nil {
 			return nil, httperr.Wrap(http.StatusBadRequest, "received invalid ID token", err)
 		}
+	} else if p.RequireAccessTokenHash {
+		return nil, httperr.New(http.StatusBadRequest, "received ID token without required at_hash claim")
 	}
 	if expectedIDTokenNonce != "" {
 		if err := expectedIDTokenNonce.Validate(validated); err != nil {
@@ -102,6 +197,11 @@ func (p *ProviderConfig) ValidateToken(ctx context.Context, tok *oauth2.Token, e
 	if err := validated.Claims(&validatedClaims); err != nil {
 		return nil, httperr.Wrap(http.StatusInternalServerError, "could not unmarshal id token claims", err)
 	}
+	if authcode != "" {
+		if err := p.verifyCodeHash(idTok, validatedClaims, authcode); err != nil {
+			return nil, httperr.Wrap(http.StatusBadRequest, "received invalid ID token", err)
+		}
+	}
 	plog.All("claims from ID token", "providerName", p.Name, "claims", validatedClaims)
 
 	if err := p.fetchUserInfo(ctx, tok, validatedClaims); err != nil {
@@ -126,20 +226,83 @@ func (p *ProviderConfig) ValidateToken(ctx context.Context, tok *oauth2.Token, e
 	}, nil
 }
 
+// decryptIDToken returns the signed JWT which is nested inside of an ID token that the provider encrypted as a JWE.
+// ID tokens which are not encrypted are returned unchanged.
+// See https://openid.net/specs/openid-connect-core-1_0.html#Encryption.
+func (p *ProviderConfig) decryptIDToken(idTok string) (string, error) {
+	// The compact serialization of a JWE has five parts, while the one of a JWS has three.
+	if strings.Count(idTok, ".") != 4 {
+		return idTok, nil
+	}
+	if len(p.DecryptionKeys) == 0 {
+		return "", constable.Error("ID token is encrypted, but no decryption keys are configured")
+	}
+	jwe, err := jose.ParseEncrypted(idTok)
+	if err != nil {
+		return "", fmt.Errorf("could not parse encrypted ID token: %w", err)
+	}
+	for _, key := range p.DecryptionKeys {
+		if plaintext, err := jwe.Decrypt(key); err == nil {
+			return string(plaintext), nil
+		}
+	}
+	return "", constable.Error("could not decrypt ID token with any of the configured decryption keys")
+}
+
+// verifyCodeHash checks the c_hash claim of an ID token against the authorization code. The c_hash claim is computed
+// like the at_hash claim, which the coreos library already verifies.
+// See https://openid.net/specs/openid-connect-core-1_0.html#HybridIDToken.
+func (p *ProviderConfig) verifyCodeHash(idTok string, claims map[string]interface{}, authcode string) error {
+	codeHash, hasCodeHash := claims[oidc.IDTokenCodeHashClaim]
+	if !hasCodeHash {
+		if p.RequireAuthorizationCodeHash {
+			return constable.Error("missing required c_hash claim")
+		}
+		return nil
+	}
+	codeHashString, ok := codeHash.(string)
+	if !ok {
+		return constable.Error("c_hash claim is not a string")
+	}
+
+	jws, err := jose.ParseSigned(idTok)
+	if err != nil {
+		return err
+	}
+	var h hash.Hash
+	switch alg := jws.Signatures[0].Header.Algorithm; alg {
+	case coreosoidc.RS256, coreosoidc.ES256, coreosoidc.PS256:
+		h = sha256.New()
+	case coreosoidc.RS384, coreosoidc.ES384, coreosoidc.PS384:
+		h = sha512.New384()
+	case coreosoidc.RS512, coreosoidc.ES512, coreosoidc.PS512:
+		h = sha512.New()
+	default:
+		return fmt.Errorf("cannot verify c_hash claim of ID token signed with unsupported algorithm %q", alg)
+	}
+	_, _ = h.Write([]byte(authcode))
+	sum := h.Sum(nil)
+	if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])), []byte(codeHashString)) != 1 {
+		return constable.Error("authorization code hash does not match value in ID token")
+	}
+	return nil
+}
+
 func (p *ProviderConfig) fetchUserInfo(ctx context.Context, tok *oauth2.Token, claims map[string]interface{}) error {
 	idTokenSubject, _ := claims[oidc.IDTokenSubjectClaim].(string)
 	if len(idTokenSubject) == 0 {
 		return nil // defer to existing ID token validation
 	}
 
+	start := time.Now()
 	userInfo, err := p.Provider.UserInfo(coreosoidc.ClientContext(ctx, p.Client), oauth2.StaticTokenSource(tok))
+	// the user info endpoint is not required but we do not have a good way to probe if it was provided
+	const userInfoUnsupported = "oidc: user info endpoint is not supported by this provider"
+	if err != nil && err.Error() == userInfoUnsupported {
+		return nil
+	}
+	observeUpstreamRequest(p.Name, operationUserInfo, start, err)
 	if err != nil {
-		// the user info endpoint is not required but we do not have a good way to probe if it was provided
-		const userInfoUnsupported = "oidc: user info endpoint is not supported by this provider"
-		if err.Error() == userInfoUnsupported {
-			return nil
-		}
-
 		return httperr.Wrap(http.StatusInternalServerError, "could not get user info", err)
 	}
 
@@ -1,10 +1,16 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package upstreamoidc
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -33,6 +39,7 @@ func TestProviderConfig(t *testing.T) {
 			Name:          "test-name",
 			UsernameClaim: "test-username-claim",
 			GroupsClaim:   "test-groups-claim",
+			UIDClaim:      "test-uid-claim",
 			Config: &oauth2.Config{
 				ClientID: "test-client-id",
 				Endpoint: oauth2.Endpoint{AuthURL: "https://example.com"},
@@ -45,6 +52,7 @@ func TestProviderConfig(t *testing.T) {
 		require.ElementsMatch(t, []string{"scope1", "scope2"}, p.GetScopes())
 		require.Equal(t, "test-username-claim", p.GetUsernameClaim())
 		require.Equal(t, "test-groups-claim", p.GetGroupsClaim())
+		require.Equal(t, "test-uid-claim", p.GetUIDClaim())
 	})
 
 	const (
@@ -360,3 +368,258 @@ func forceUserInfoWithClaims(subject string, claims string) *oidc.UserInfo {
 
 	return userInfo
 }
+
+func TestProviderConfigValidatesHashesAndNonce(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, nil)
+	require.NoError(t, err)
+	sign := func(claims map[string]interface{}) string {
+		t.Helper()
+		payload, err := json.Marshal(claims)
+		require.NoError(t, err)
+		jws, err := signer.Sign(payload)
+		require.NoError(t, err)
+		compact, err := jws.CompactSerialize()
+		require.NoError(t, err)
+		return compact
+	}
+	// The hashes are the base64url encoding of the left half of the SHA-256 hash of the value.
+	halfHash := func(value string) string {
+		sum := sha256.Sum256([]byte(value))
+		return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+	}
+	decryptionKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherDecryptionKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	encrypt := func(signed string) string {
+		t.Helper()
+		encrypter, err := jose.NewEncrypter(jose.A256GCM,
+			jose.Recipient{Algorithm: jose.RSA_OAEP_256, Key: &decryptionKey.PublicKey},
+			(&jose.EncrypterOptions{}).WithContentType("JWT"),
+		)
+		require.NoError(t, err)
+		jwe, err := encrypter.Encrypt([]byte(signed))
+		require.NoError(t, err)
+		compact, err := jwe.CompactSerialize()
+		require.NoError(t, err)
+		return compact
+	}
+	signedWithCodeHash := sign(map[string]interface{}{"sub": "test-user", "nonce": "test-nonce", "c_hash": halfHash("valid")})
+
+	tests := []struct {
+		name        string
+		config      ProviderConfig
+		idToken     interface{}
+		useRefresh  bool
+		wantErr     string
+		wantIDToken string
+	}{
+		{
+			name:    "ID token which is not a string",
+			idToken: 42,
+			wantErr: "received response missing ID token",
+		},
+		{
+			name:    "ID token which is not a JWT",
+			idToken: "not.a.jwt",
+			wantErr: "received invalid ID token: oidc: malformed jwt: illegal base64 data at input byte 0",
+		},
+		{
+			name:    "missing nonce",
+			idToken: sign(map[string]interface{}{"sub": "test-user"}),
+			wantErr: `received ID token with invalid nonce: invalid nonce (expected "test-nonce", got "")`,
+		},
+		{
+			name:    "no hashes",
+			idToken: sign(map[string]interface{}{"sub": "test-user", "nonce": "test-nonce"}),
+		},
+		{
+			name:    "at_hash required but missing",
+			config:  ProviderConfig{RequireAccessTokenHash: true},
+			idToken: sign(map[string]interface{}{"sub": "test-user", "nonce": "test-nonce"}),
+			wantErr: "received ID token without required at_hash claim",
+		},
+		{
+			name:    "at_hash required and valid",
+			config:  ProviderConfig{RequireAccessTokenHash: true},
+			idToken: sign(map[string]interface{}{"sub": "test-user", "nonce": "test-nonce", "at_hash": halfHash("test-access-token")}),
+		},
+		{
+			name:    "at_hash of another access token",
+			idToken: sign(map[string]interface{}{"sub": "test-user", "nonce": "test-nonce", "at_hash": halfHash("other-access-token")}),
+			wantErr: "received invalid ID token: access token hash does not match value in ID token",
+		},
+		{
+			name:    "c_hash required but missing",
+			config:  ProviderConfig{RequireAuthorizationCodeHash: true},
+			idToken: sign(map[string]interface{}{"sub": "test-user", "nonce": "test-nonce"}),
+			wantErr: "received invalid ID token: missing required c_hash claim",
+		},
+		{
+			name:       "c_hash required but missing from refreshed tokens",
+			config:     ProviderConfig{RequireAuthorizationCodeHash: true},
+			idToken:    sign(map[string]interface{}{"sub": "test-user"}),
+			useRefresh: true,
+		},
+		{
+			name:    "c_hash required and valid",
+			config:  ProviderConfig{RequireAuthorizationCodeHash: true},
+			idToken: sign(map[string]interface{}{"sub": "test-user", "nonce": "test-nonce", "c_hash": halfHash("valid")}),
+		},
+		{
+			name:    "c_hash of another authorization code",
+			idToken: sign(map[string]interface{}{"sub": "test-user", "nonce": "test-nonce", "c_hash": halfHash("other-code")}),
+			wantErr: "received invalid ID token: authorization code hash does not match value in ID token",
+		},
+		{
+			name:    "c_hash which is not a string",
+			idToken: sign(map[string]interface{}{"sub": "test-user", "nonce": "test-nonce", "c_hash": 42}),
+			wantErr: "received invalid ID token: c_hash claim is not a string",
+		},
+		{
+			name:        "encrypted ID token",
+			config:      ProviderConfig{RequireAuthorizationCodeHash: true, DecryptionKeys: []interface{}{otherDecryptionKey, decryptionKey}},
+			idToken:     encrypt(signedWithCodeHash),
+			wantIDToken: signedWithCodeHash,
+		},
+		{
+			name:    "encrypted ID token without decryption keys",
+			idToken: encrypt(signedWithCodeHash),
+			wantErr: "received invalid ID token: ID token is encrypted, but no decryption keys are configured",
+		},
+		{
+			name:    "encrypted ID token for another key",
+			config:  ProviderConfig{DecryptionKeys: []interface{}{otherDecryptionKey}},
+			idToken: encrypt(signedWithCodeHash),
+			wantErr: "received invalid ID token: could not decrypt ID token with any of the configured decryption keys",
+		},
+		{
+			name:    "invalid encrypted ID token",
+			config:  ProviderConfig{DecryptionKeys: []interface{}{decryptionKey}},
+			idToken: "not.a.valid.encrypted.token",
+			wantErr: "received invalid ID token: could not parse encrypted ID token: illegal base64 data at input byte 0",
+		},
+		{
+			name:    "expired within clock skew tolerance",
+			config:  ProviderConfig{ClockSkewTolerance: 5 * time.Minute},
+			idToken: sign(map[string]interface{}{"sub": "test-user", "nonce": "test-nonce", "exp": time.Now().Add(-time.Minute).Unix()}),
+		},
+		{
+			name:    "expired beyond clock skew tolerance",
+			config:  ProviderConfig{ClockSkewTolerance: 5 * time.Minute},
+			idToken: sign(map[string]interface{}{"sub": "test-user", "nonce": "test-nonce", "exp": 1}),
+			wantErr: fmt.Sprintf("received invalid ID token: token is expired (Token Expiry: %v, clock skew tolerance: 5m0s)", time.Unix(1, 0)),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("content-type", "application/json")
+				require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+					"access_token": "test-access-token",
+					"token_type":   "Bearer",
+					"id_token":     tt.idToken,
+				}))
+			}))
+			t.Cleanup(tokenServer.Close)
+
+			p := tt.config
+			p.Config = &oauth2.Config{
+				ClientID: "test-client-id",
+				Endpoint: oauth2.Endpoint{TokenURL: tokenServer.URL, AuthStyle: oauth2.AuthStyleInParams},
+			}
+			p.Provider = &mockProvider{userInfoErr: errors.New("oidc: user info endpoint is not supported by this provider")}
+
+			var tok *oidctypes.Token
+			var err error
+			if tt.useRefresh {
+				tok, err = p.ValidateToken(context.Background(), (&oauth2.Token{AccessToken: "test-access-token"}).WithExtra(map[string]interface{}{"id_token": tt.idToken}), "")
+			} else {
+				tok, err = p.ExchangeAuthcodeAndValidateTokens(context.Background(), "valid", "test-pkce", "test-nonce", "https://example.com/callback")
+			}
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, tok)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantIDToken != "" {
+				require.Equal(t, tt.wantIDToken, tok.IDToken.Token)
+				return
+			}
+			require.Equal(t, tt.idToken, tok.IDToken.Token)
+		})
+	}
+}
+
+func TestProviderConfigPasswordCredentialsGrant(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, nil)
+	require.NoError(t, err)
+	jws, err := signer.Sign([]byte(`{"sub":"test-user","email":"test-user@example.com"}`))
+	require.NoError(t, err)
+	idToken, err := jws.CompactSerialize()
+	require.NoError(t, err)
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "test-client-id", r.Form.Get("client_id"))
+		require.Equal(t, "password", r.Form.Get("grant_type"))
+		require.Equal(t, "scope1 scope2", r.Form.Get("scope"))
+		w.Header().Set("content-type", "application/json")
+		if r.Form.Get("username") != "test-user" || r.Form.Get("password") != "test-password" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "test-access-token",
+			"refresh_token": "test-refresh-token",
+			"token_type":    "Bearer",
+			"id_token":      idToken,
+		}))
+	}))
+	t.Cleanup(tokenServer.Close)
+
+	p := ProviderConfig{
+		Name:               "test-name",
+		AllowPasswordGrant: true,
+		Config: &oauth2.Config{
+			ClientID: "test-client-id",
+			Endpoint: oauth2.Endpoint{TokenURL: tokenServer.URL, AuthStyle: oauth2.AuthStyleInParams},
+			Scopes:   []string{"scope1", "scope2"},
+		},
+		Provider: &mockProvider{userInfoErr: errors.New("oidc: user info endpoint is not supported by this provider")},
+	}
+	require.True(t, p.AllowsPasswordGrant())
+
+	tok, err := p.PasswordCredentialsGrantAndValidateTokens(context.Background(), "test-user", "test-password")
+	require.NoError(t, err)
+	require.Equal(t, "test-access-token", tok.AccessToken.Token)
+	require.Equal(t, "test-refresh-token", tok.RefreshToken.Token)
+	require.Equal(t, idToken, tok.IDToken.Token)
+	require.Equal(t, map[string]interface{}{"sub": "test-user", "email": "test-user@example.com"}, tok.IDToken.Claims)
+
+	tok, err = p.PasswordCredentialsGrantAndValidateTokens(context.Background(), "test-user", "wrong-password")
+	var retrieveErr *oauth2.RetrieveError
+	require.True(t, errors.As(err, &retrieveErr))
+	require.Equal(t, `{"error":"invalid_grant"}`, string(retrieveErr.Body))
+	require.Nil(t, tok)
+}
+
+func TestProviderConfigValidateIssuerParameter(t *testing.T) {
+	p := ProviderConfig{Issuer: "https://issuer.example.com"}
+	require.NoError(t, p.ValidateIssuerParameter(""))
+	require.NoError(t, p.ValidateIssuerParameter("https://issuer.example.com"))
+	require.EqualError(t, p.ValidateIssuerParameter("https://other-issuer.example.com"),
+		`authorization response has unexpected iss parameter (expected "https://issuer.example.com", got "https://other-issuer.example.com")`)
+
+	p.RequireIssuerParameter = true
+	require.EqualError(t, p.ValidateIssuerParameter(""), "authorization response is missing the iss parameter")
+	require.NoError(t, p.ValidateIssuerParameter("https://issuer.example.com"))
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package versioninfo prints and serves the build version of the Pinniped server binaries in a machine-readable format.
+package versioninfo
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"k8s.io/component-base/version"
+	"sigs.k8s.io/yaml"
+
+	"go.pinniped.dev/internal/httputil/httperr"
+)
+
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Print writes the version information of the running binary to w in the given format, which must be
+// FormatJSON or FormatYAML.
+func Print(w io.Writer, format string) error {
+	info := version.Get()
+
+	var output []byte
+	var err error
+	switch format {
+	case FormatJSON:
+		output, err = json.MarshalIndent(info, "", "  ")
+		output = append(output, '\n')
+	case FormatYAML:
+		output, err = yaml.Marshal(info)
+	default:
+		return fmt.Errorf("invalid output format %q (must be %q or %q)", format, FormatJSON, FormatYAML)
+	}
+	if err != nil {
+		return fmt.Errorf("could not encode version info: %w", err)
+	}
+
+	_, err = w.Write(output)
+	return err
+}
+
+// NewHandler returns a handler which serves the version information of the running binary as JSON, so that clients
+// can detect when they are too old or too new for the server.
+func NewHandler() http.Handler {
+	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != http.MethodGet {
+			return httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET)", r.Method)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(version.Get())
+	})
+}
+
+// HandleArgs prints the version information to w and returns true when args (without the program name) ask for
+// the version, i.e. when they are "version" or "--version", optionally followed by "--output json|yaml".
+// Otherwise it does nothing and returns false, so the caller can continue with its usual argument handling.
+func HandleArgs(args []string, w io.Writer) (bool, error) {
+	if len(args) == 0 || (args[0] != "version" && args[0] != "--version") {
+		return false, nil
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard) // errors are returned instead
+	var format string
+	flags.StringVar(&format, "output", FormatJSON, "output format, either json or yaml")
+	flags.StringVar(&format, "o", FormatJSON, "output format, either json or yaml (shorthand)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return true, err
+	}
+	if flags.NArg() != 0 {
+		return true, fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+
+	return true, Print(w, format)
+}
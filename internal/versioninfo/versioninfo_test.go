@@ -0,0 +1,113 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package versioninfo
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/version"
+	"sigs.k8s.io/yaml"
+)
+
+func TestHandleArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantHandled bool
+		wantFormat  string
+		wantErr     string
+	}{
+		{
+			name: "no args",
+		},
+		{
+			name: "other args",
+			args: []string{"/etc/podinfo", "/etc/config/pinniped.yaml"},
+		},
+		{
+			name:        "version subcommand",
+			args:        []string{"version"},
+			wantHandled: true,
+			wantFormat:  FormatJSON,
+		},
+		{
+			name:        "version flag",
+			args:        []string{"--version"},
+			wantHandled: true,
+			wantFormat:  FormatJSON,
+		},
+		{
+			name:        "yaml output",
+			args:        []string{"version", "--output", "yaml"},
+			wantHandled: true,
+			wantFormat:  FormatYAML,
+		},
+		{
+			name:        "json output shorthand",
+			args:        []string{"--version", "-o", "json"},
+			wantHandled: true,
+			wantFormat:  FormatJSON,
+		},
+		{
+			name:        "invalid output",
+			args:        []string{"version", "-o", "xml"},
+			wantHandled: true,
+			wantErr:     `invalid output format "xml" (must be "json" or "yaml")`,
+		},
+		{
+			name:        "extra args",
+			args:        []string{"version", "extra"},
+			wantHandled: true,
+			wantErr:     "unexpected arguments: [extra]",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			handled, err := HandleArgs(tt.args, &out)
+			require.Equal(t, tt.wantHandled, handled)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if !tt.wantHandled {
+				require.Empty(t, out.String())
+				return
+			}
+
+			var info version.Info
+			switch tt.wantFormat {
+			case FormatJSON:
+				require.NoError(t, json.Unmarshal(out.Bytes(), &info))
+			case FormatYAML:
+				require.NoError(t, yaml.UnmarshalStrict(out.Bytes(), &info))
+			}
+			require.Equal(t, runtime.Version(), info.GoVersion)
+			require.Equal(t, runtime.GOOS+"/"+runtime.GOARCH, info.Platform)
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rsp := httptest.NewRecorder()
+	NewHandler().ServeHTTP(rsp, httptest.NewRequest(http.MethodGet, "/version", nil))
+	require.Equal(t, http.StatusOK, rsp.Code)
+	require.Equal(t, "application/json", rsp.Header().Get("Content-Type"))
+	var info version.Info
+	require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &info))
+	require.Equal(t, runtime.Version(), info.GoVersion)
+
+	rsp = httptest.NewRecorder()
+	NewHandler().ServeHTTP(rsp, httptest.NewRequest(http.MethodPost, "/version", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rsp.Code)
+	require.Equal(t, "Method Not Allowed: POST (try GET)\n", rsp.Body.String())
+}
@@ -8,11 +8,16 @@ import (
 	"context"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
 
+	"gopkg.in/square/go-jose.v2/jwt"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientauthenticationv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
 	"k8s.io/client-go/tools/clientcmd"
@@ -29,6 +34,11 @@ import (
 // ErrLoginFailed is returned by Client.ExchangeToken when the concierge server rejects the login request for any reason.
 const ErrLoginFailed = constable.Error("login failed")
 
+// ErrNoWorkingStrategy is returned by Client.ExchangeToken when the login request was authenticated, but the concierge
+// server cannot issue a cluster credential because none of its strategies are working. This is not caused by invalid
+// user credentials, so it can only be fixed by a cluster administrator.
+const ErrNoWorkingStrategy = constable.Error("the Pinniped Concierge on this cluster cannot issue cluster credentials")
+
 // Option is an optional configuration for New().
 type Option func(*Client) error
 
@@ -38,6 +48,9 @@ type Client struct {
 	caBundle       string
 	endpoint       *url.URL
 	apiGroupSuffix string
+	useProxy       bool
+	proxyURL       *url.URL
+	proxyCABundle  string
 }
 
 // WithAuthenticator configures the authenticator reference (spec.authenticator) of the TokenCredentialRequests.
@@ -54,8 +67,26 @@ func WithAuthenticator(authType, authName string) Option {
 		case "jwt":
 			authenticator.APIGroup = &auth1alpha1.SchemeGroupVersion.Group
 			authenticator.Kind = "JWTAuthenticator"
+		case "cloudidentity":
+			authenticator.APIGroup = &auth1alpha1.SchemeGroupVersion.Group
+			authenticator.Kind = "CloudIdentityAuthenticator"
+		case "statictoken":
+			authenticator.APIGroup = &auth1alpha1.SchemeGroupVersion.Group
+			authenticator.Kind = "StaticTokenAuthenticator"
+		case "clientcertificate":
+			authenticator.APIGroup = &auth1alpha1.SchemeGroupVersion.Group
+			authenticator.Kind = "ClientCertificateAuthenticator"
+		case "serviceaccount":
+			authenticator.APIGroup = &auth1alpha1.SchemeGroupVersion.Group
+			authenticator.Kind = "ServiceAccountAuthenticator"
+		case "bootstrap":
+			authenticator.APIGroup = &auth1alpha1.SchemeGroupVersion.Group
+			authenticator.Kind = "BootstrapCredential"
+		case "dev":
+			authenticator.APIGroup = &auth1alpha1.SchemeGroupVersion.Group
+			authenticator.Kind = "DevAuthenticator"
 		default:
-			return fmt.Errorf(`invalid authenticator type: %q, supported values are "webhook" and "jwt"`, authType)
+			return fmt.Errorf(`invalid authenticator type: %q, supported values are "webhook", "jwt", "cloudidentity", "statictoken", "clientcertificate", "serviceaccount", "bootstrap", and "dev"`, authType)
 		}
 		c.authenticator = &authenticator
 		return nil
@@ -116,6 +147,31 @@ func WithAPIGroupSuffix(apiGroupSuffix string) Option {
 	}
 }
 
+// WithImpersonationProxy configures the client to return a credential for the impersonation proxy of the concierge,
+// instead of performing a TokenCredentialRequest. The endpoint should be the impersonation proxy's endpoint.
+func WithImpersonationProxy() Option {
+	return func(c *Client) error {
+		c.useProxy = true
+		return nil
+	}
+}
+
+// WithProxy configures the HTTP(S) proxy for the requests to the concierge, instead of the proxy of the standard
+// environment variables. The PEM-formatted proxyCABundle is trusted in addition to the CA bundle of the concierge, for
+// proxies which serve TLS themselves. A nil proxyURL keeps using the standard environment variables.
+func WithProxy(proxyURL *url.URL, proxyCABundle string) Option {
+	return func(c *Client) error {
+		if proxyCABundle != "" {
+			if p := x509.NewCertPool(); !p.AppendCertsFromPEM([]byte(proxyCABundle)) {
+				return fmt.Errorf("invalid proxy CA bundle data: no certificates found")
+			}
+		}
+		c.proxyURL = proxyURL
+		c.proxyCABundle = proxyCABundle
+		return nil
+	}
+}
+
 // New validates the specified options and returns a newly initialized *Client.
 func New(opts ...Option) (*Client, error) {
 	c := Client{apiGroupSuffix: "pinniped.dev"}
@@ -139,7 +195,7 @@ func (c *Client) clientset() (conciergeclientset.Interface, error) {
 		Clusters: map[string]*clientcmdapi.Cluster{
 			"cluster": {
 				Server:                   c.endpoint.String(),
-				CertificateAuthorityData: []byte(c.caBundle),
+				CertificateAuthorityData: []byte(strings.TrimSpace(c.caBundle + "\n" + c.proxyCABundle)),
 			},
 		},
 		Contexts: map[string]*clientcmdapi.Context{
@@ -155,6 +211,9 @@ func (c *Client) clientset() (conciergeclientset.Interface, error) {
 	if err != nil {
 		return nil, err
 	}
+	if c.proxyURL != nil {
+		cfg.Proxy = http.ProxyURL(c.proxyURL)
+	}
 	client, err := kubeclient.New(
 		kubeclient.WithConfig(cfg),
 		kubeclient.WithMiddleware(groupsuffix.New(c.apiGroupSuffix)),
@@ -167,6 +226,9 @@ func (c *Client) clientset() (conciergeclientset.Interface, error) {
 
 // ExchangeToken performs a TokenCredentialRequest against the Pinniped concierge and returns the result as an ExecCredential.
 func (c *Client) ExchangeToken(ctx context.Context, token string) (*clientauthenticationv1beta1.ExecCredential, error) {
+	if c.useProxy {
+		return c.impersonationProxyCredential(token)
+	}
 	clientset, err := c.clientset()
 	if err != nil {
 		return nil, err
@@ -178,6 +240,9 @@ func (c *Client) ExchangeToken(ctx context.Context, token string) (*clientauthen
 		},
 	}, metav1.CreateOptions{})
 	if err != nil {
+		if message, ok := noWorkingStrategyMessage(err); ok {
+			return nil, fmt.Errorf("%w: %s", ErrNoWorkingStrategy, message)
+		}
 		return nil, fmt.Errorf("could not login: %w", err)
 	}
 	if resp.Status.Credential == nil || resp.Status.Message != nil {
@@ -200,3 +265,65 @@ func (c *Client) ExchangeToken(ctx context.Context, token string) (*clientauthen
 		},
 	}, nil
 }
+
+// impersonationProxyCredential returns an ExecCredential with a bearer token for the impersonation proxy. The proxy
+// performs the TokenCredentialRequest itself, so the token is just the request, encoded as base64 JSON. It is valid
+// for as long as the wrapped token, so it expires along with the token when the token is a JWT.
+func (c *Client) impersonationProxyCredential(token string) (*clientauthenticationv1beta1.ExecCredential, error) {
+	authenticator := c.authenticator.DeepCopy()
+	if authenticator.APIGroup != nil {
+		apiGroup, ok := groupsuffix.Replace(*authenticator.APIGroup, c.apiGroupSuffix)
+		if !ok {
+			return nil, fmt.Errorf("cannot replace api group suffix of %q", *authenticator.APIGroup)
+		}
+		authenticator.APIGroup = &apiGroup
+	}
+	reqJSON, err := json.Marshal(&loginv1alpha1.TokenCredentialRequest{
+		Spec: loginv1alpha1.TokenCredentialRequestSpec{
+			Token:         token,
+			Authenticator: *authenticator,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode impersonation proxy credential: %w", err)
+	}
+	return &clientauthenticationv1beta1.ExecCredential{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ExecCredential",
+			APIVersion: "client.authentication.k8s.io/v1beta1",
+		},
+		Status: &clientauthenticationv1beta1.ExecCredentialStatus{
+			ExpirationTimestamp: jwtExpiry(token),
+			Token:               base64.RawURLEncoding.EncodeToString(reqJSON),
+		},
+	}, nil
+}
+
+// jwtExpiry returns the "exp" claim of the token, or nil when the token is not a JWT or does not expire. The
+// signature is not verified, since the token is only inspected to tell kubectl when to ask for a new credential.
+func jwtExpiry(token string) *metav1.Time {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil
+	}
+	var claims jwt.Claims
+	if err := parsed.UnsafeClaimsWithoutVerification(&claims); err != nil || claims.Expiry == nil {
+		return nil
+	}
+	expiry := metav1.NewTime(claims.Expiry.Time())
+	return &expiry
+}
+
+// noWorkingStrategyMessage returns the message of the NoWorkingStrategy cause of the error, if it has one.
+func noWorkingStrategyMessage(err error) (string, bool) {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return "", false
+	}
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == loginv1alpha1.NoWorkingStrategyCause {
+			return cause.Message, true
+		}
+	}
+	return "", false
+}
@@ -5,17 +5,27 @@ package conciergeclient
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientauthenticationv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
 
@@ -46,7 +56,7 @@ func TestNew(t *testing.T) {
 			opts: []Option{
 				WithAuthenticator("invalid-type", "test-authenticator"),
 			},
-			wantErr: `invalid authenticator type: "invalid-type", supported values are "webhook" and "jwt"`,
+			wantErr: `invalid authenticator type: "invalid-type", supported values are "webhook", "jwt", "cloudidentity", "statictoken", "clientcertificate", "serviceaccount", "bootstrap", and "dev"`,
 		},
 		{
 			name: "with empty authenticator name",
@@ -69,6 +79,13 @@ func TestNew(t *testing.T) {
 			},
 			wantErr: "invalid CA bundle data: illegal base64 data at input byte 7",
 		},
+		{
+			name: "invalid proxy CA bundle",
+			opts: []Option{
+				WithProxy(&url.URL{Scheme: "http", Host: "proxy.example.com:3128"}, "not a certificate"),
+			},
+			wantErr: "invalid proxy CA bundle data: no certificates found",
+		},
 		{
 			name: "empty endpoint",
 			opts: []Option{
@@ -177,6 +194,38 @@ func TestExchangeToken(t *testing.T) {
 		require.Nil(t, got)
 	})
 
+	t.Run("no working strategy", func(t *testing.T) {
+		t.Parallel()
+		// Start a test server that returns the error for a cluster which has no working strategy.
+		caBundle, endpoint := testutil.TLSTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(&metav1.Status{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+				Status:   metav1.StatusFailure,
+				Message:  "some unavailable message",
+				Reason:   metav1.StatusReasonServiceUnavailable,
+				Code:     http.StatusServiceUnavailable,
+				Details: &metav1.StatusDetails{
+					Group: "login.concierge.pinniped.dev",
+					Kind:  "TokenCredentialRequest",
+					Causes: []metav1.StatusCause{{
+						Type:    loginv1alpha1.NoWorkingStrategyCause,
+						Message: "some cause message",
+					}},
+				},
+			})
+		})
+
+		client, err := New(WithEndpoint(endpoint), WithCABundle(caBundle), WithAuthenticator("jwt", "test-authenticator"))
+		require.NoError(t, err)
+
+		got, err := client.ExchangeToken(ctx, "test-token")
+		require.EqualError(t, err, `the Pinniped Concierge on this cluster cannot issue cluster credentials: some cause message`)
+		require.True(t, errors.Is(err, ErrNoWorkingStrategy))
+		require.Nil(t, got)
+	})
+
 	t.Run("login failure", func(t *testing.T) {
 		t.Parallel()
 		// Start a test server that returns success but with an error message
@@ -277,4 +326,108 @@ func TestExchangeToken(t *testing.T) {
 			},
 		}, got)
 	})
+
+	t.Run("through a proxy", func(t *testing.T) {
+		t.Parallel()
+		caBundle, endpoint := testutil.TLSTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("content-type", "application/json")
+			_ = json.NewEncoder(w).Encode(&loginv1alpha1.TokenCredentialRequest{
+				TypeMeta: metav1.TypeMeta{APIVersion: "login.concierge.pinniped.dev/v1alpha1", Kind: "TokenCredentialRequest"},
+				Status: loginv1alpha1.TokenCredentialRequestStatus{
+					Credential: &loginv1alpha1.ClusterCredential{Token: "test-token-from-proxied-request"},
+				},
+			})
+		})
+		endpointURL, err := url.Parse(endpoint)
+		require.NoError(t, err)
+
+		// Start an HTTP proxy which tunnels CONNECT requests to the concierge, and only to the concierge.
+		var tunnels int32
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodConnect || r.Host != endpointURL.Host || r.Header.Get("Proxy-Authorization") == "" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			atomic.AddInt32(&tunnels, 1)
+			upstream, err := net.Dial("tcp", r.Host)
+			require.NoError(t, err)
+			w.WriteHeader(http.StatusOK)
+			downstream, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			go func() { _, _ = io.Copy(upstream, downstream) }()
+			_, _ = io.Copy(downstream, upstream)
+			_ = downstream.Close()
+		}))
+		t.Cleanup(proxy.Close)
+		proxyURL, err := url.Parse(proxy.URL)
+		require.NoError(t, err)
+		proxyURL.User = url.UserPassword("proxy-user", "proxy-password")
+
+		client, err := New(WithEndpoint(endpoint), WithCABundle(caBundle), WithAuthenticator("webhook", "test-webhook"), WithProxy(proxyURL, ""))
+		require.NoError(t, err)
+		got, err := client.ExchangeToken(ctx, "test-token")
+		require.NoError(t, err)
+		require.Equal(t, "test-token-from-proxied-request", got.Status.Token)
+		require.Equal(t, int32(1), atomic.LoadInt32(&tunnels))
+	})
+
+	t.Run("impersonation proxy", func(t *testing.T) {
+		t.Parallel()
+		client, err := New(
+			WithEndpoint("https://proxy.example.com"),
+			WithAuthenticator("jwt", "test-jwt"),
+			WithAPIGroupSuffix("suffix.example.com"),
+			WithImpersonationProxy(),
+		)
+		require.NoError(t, err)
+
+		got, err := client.ExchangeToken(ctx, "test-token")
+		require.NoError(t, err)
+		require.Equal(t, "ExecCredential", got.Kind)
+		require.Nil(t, got.Status.ExpirationTimestamp)
+		require.Empty(t, got.Status.ClientCertificateData)
+
+		decoded, err := base64.RawURLEncoding.DecodeString(got.Status.Token)
+		require.NoError(t, err)
+		require.JSONEq(t,
+			`{
+			  "metadata": {
+				"creationTimestamp": null
+			  },
+			  "spec": {
+				"token": "test-token",
+				"authenticator": {
+					"apiGroup": "authentication.concierge.suffix.example.com",
+					"kind": "JWTAuthenticator",
+					"name": "test-jwt"
+				}
+			  },
+			  "status": {}
+			}`,
+			string(decoded),
+		)
+	})
+
+	t.Run("impersonation proxy with a JWT", func(t *testing.T) {
+		t.Parallel()
+		client, err := New(
+			WithEndpoint("https://proxy.example.com"),
+			WithAuthenticator("jwt", "test-jwt"),
+			WithImpersonationProxy(),
+		)
+		require.NoError(t, err)
+
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+		require.NoError(t, err)
+		expiry := time.Now().Add(5 * time.Minute).Truncate(time.Second)
+		token, err := jwt.Signed(signer).Claims(jwt.Claims{Subject: "some-user", Expiry: jwt.NewNumericDate(expiry)}).CompactSerialize()
+		require.NoError(t, err)
+
+		got, err := client.ExchangeToken(ctx, token)
+		require.NoError(t, err)
+		require.NotNil(t, got.Status.ExpirationTimestamp)
+		require.True(t, expiry.Equal(got.Status.ExpirationTimestamp.Time))
+	})
 }
@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package cachefile implements the file format for session caches.
@@ -16,6 +16,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 
+	"go.pinniped.dev/internal/atomicfile"
 	"go.pinniped.dev/pkg/oidcclient"
 	"go.pinniped.dev/pkg/oidcclient/oidctypes"
 )
@@ -50,6 +51,10 @@ type (
 		CreationTimestamp metav1.Time                `json:"creationTimestamp"`
 		LastUsedTimestamp metav1.Time                `json:"lastUsedTimestamp"`
 		Tokens            oidctypes.Token            `json:"tokens"`
+
+		// KeychainAccount is the account of the keychain item which holds the refresh token of the session, if any.
+		// The refresh token is then omitted from Tokens.
+		KeychainAccount string `json:"keychainAccount,omitempty"`
 	}
 )
 
@@ -65,7 +70,11 @@ func readSessionCache(path string) (*sessionCache, error) {
 		return nil, fmt.Errorf("could not read session file: %w", err)
 	}
 
-	// If we read the file successfully, unmarshal it from YAML.
+	return decodeSessionCache(cacheYAML)
+}
+
+// decodeSessionCache unmarshals a sessionCache from YAML.
+func decodeSessionCache(cacheYAML []byte) (*sessionCache, error) {
 	var cache sessionCache
 	if err := yaml.Unmarshal(cacheYAML, &cache); err != nil {
 		return nil, fmt.Errorf("invalid session file: %w", err)
@@ -91,7 +100,7 @@ func (c *sessionCache) writeTo(path string) error {
 	// Marshal the session back to YAML and save it to the file.
 	cacheYAML, err := yaml.Marshal(c)
 	if err == nil {
-		err = ioutil.WriteFile(path, cacheYAML, 0600)
+		err = atomicfile.WriteFile(path, cacheYAML)
 	}
 	return err
 }
@@ -152,6 +161,21 @@ func (c *sessionCache) lookup(key oidcclient.SessionCacheKey) *sessionEntry {
 	return nil
 }
 
+// remove the cache entries whose keys match, returning the removed entries.
+func (c *sessionCache) remove(matches func(oidcclient.SessionCacheKey) bool) []sessionEntry {
+	var removed []sessionEntry
+	kept := make([]sessionEntry, 0, len(c.Sessions))
+	for _, entry := range c.Sessions {
+		if matches(entry.Key) {
+			removed = append(removed, entry)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	c.Sessions = kept
+	return removed
+}
+
 // insert a cache entry.
 func (c *sessionCache) insert(entries ...sessionEntry) {
 	c.Sessions = append(c.Sessions, entries...)
@@ -4,6 +4,8 @@
 package filesession
 
 import (
+	"errors"
+	"io/ioutil"
 	"os"
 	"testing"
 	"time"
@@ -123,7 +125,7 @@ func TestWriteTo(t *testing.T) {
 		tmp := testutil.TempDir(t) + "/sessions.yaml"
 		require.NoError(t, os.Mkdir(tmp, 0700))
 		err := validSession.writeTo(tmp)
-		require.EqualError(t, err, "open "+tmp+": is a directory")
+		require.EqualError(t, err, "rename "+tmp+": "+renameOntoDirectoryError(t))
 	})
 
 	t.Run("success", func(t *testing.T) {
@@ -132,6 +134,17 @@ func TestWriteTo(t *testing.T) {
 	})
 }
 
+// renameOntoDirectoryError returns the error message of the platform for renaming a file onto an existing directory,
+// which differs between operating systems and file systems.
+func renameOntoDirectoryError(t *testing.T) string {
+	tmp := testutil.TempDir(t)
+	require.NoError(t, ioutil.WriteFile(tmp+"/file", nil, 0600))
+	require.NoError(t, os.Mkdir(tmp+"/dir", 0700))
+	err := os.Rename(tmp+"/file", tmp+"/dir")
+	require.Error(t, err)
+	return errors.Unwrap(err).Error()
+}
+
 func TestNormalized(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,80 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package filesession
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"go.pinniped.dev/internal/atomicfile"
+	"go.pinniped.dev/internal/fileencryption"
+)
+
+// encryptedAPIKind is the Kubernetes-style Kind of an encrypted session file object.
+const encryptedAPIKind = "EncryptedSessionCache"
+
+// encryptedSessionCache is the object which is YAML-serialized to form the contents of an encrypted cache file.
+// The ciphertext is the AES-GCM encryption of a serialized sessionCache, with a key derived from a secret with scrypt.
+type encryptedSessionCache struct {
+	metav1.TypeMeta
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// readEncryptedSessionCache loads a sessionCache from an encrypted file. Plaintext files are read as well, so that
+// they are encrypted when the cache is written back.
+func readEncryptedSessionCache(path string, e *fileencryption.Encryption) (*sessionCache, error) {
+	fileYAML, err := ioutil.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return emptySessionCache(), nil
+		}
+		return nil, fmt.Errorf("could not read session file: %w", err)
+	}
+
+	var encrypted encryptedSessionCache
+	if err := yaml.Unmarshal(fileYAML, &encrypted); err != nil {
+		return nil, fmt.Errorf("invalid session file: %w", err)
+	}
+	if encrypted.Kind != encryptedAPIKind {
+		return decodeSessionCache(fileYAML)
+	}
+	if encrypted.APIVersion != apiVersion {
+		return nil, fmt.Errorf("%w: %#v", errUnsupportedVersion, encrypted.TypeMeta)
+	}
+
+	cacheYAML, err := e.Open(encrypted.Salt, encrypted.Nonce, encrypted.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt session file (was it encrypted with a different secret?): %w", err)
+	}
+	return decodeSessionCache(cacheYAML)
+}
+
+// writeEncryptedTo writes the cache to the specified file path, encrypted with the secret.
+func (c *sessionCache) writeEncryptedTo(path string, e *fileencryption.Encryption) error {
+	cacheYAML, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	salt, nonce, ciphertext, err := e.Seal(cacheYAML)
+	if err != nil {
+		return fmt.Errorf("could not encrypt session file: %w", err)
+	}
+	fileYAML, err := yaml.Marshal(&encryptedSessionCache{
+		TypeMeta:   metav1.TypeMeta{APIVersion: apiVersion, Kind: encryptedAPIKind},
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	})
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, fileYAML)
+}
@@ -0,0 +1,88 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package filesession
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/pkg/oidcclient"
+	"go.pinniped.dev/pkg/oidcclient/oidctypes"
+)
+
+func TestEncryption(t *testing.T) {
+	t.Parallel()
+	key := oidcclient.SessionCacheKey{
+		Issuer:      "test-issuer",
+		ClientID:    "test-client-id",
+		Scopes:      []string{"email", "offline_access", "openid", "profile"},
+		RedirectURI: "http://localhost:0/callback",
+	}
+	token := &oidctypes.Token{
+		IDToken:      &oidctypes.IDToken{Token: "test-id-token", Expiry: metav1.NewTime(time.Now().Add(1 * time.Hour).Round(time.Second).Local())},
+		RefreshToken: &oidctypes.RefreshToken{Token: "test-refresh-token"},
+	}
+
+	requireEncrypted := func(t *testing.T, path string) {
+		fileYAML, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.Contains(t, string(fileYAML), "kind: EncryptedSessionCache")
+		require.NotContains(t, string(fileYAML), "test-refresh-token")
+	}
+
+	t.Run("sessions are encrypted", func(t *testing.T) {
+		t.Parallel()
+		tmp := testutil.TempDir(t) + "/sessions.yaml"
+		errors := errorCollector{t: t}
+		c := New(tmp, errors.collect(), WithEncryption([]byte("some-passphrase")))
+
+		c.PutToken(key, token)
+		requireEncrypted(t, tmp)
+		require.Equal(t, token, c.GetToken(key))
+		require.Equal(t, token, New(tmp, errors.collect(), WithEncryption([]byte("some-passphrase"))).GetToken(key))
+		errors.require(nil)
+	})
+
+	t.Run("plaintext sessions are migrated", func(t *testing.T) {
+		t.Parallel()
+		tmp := testutil.TempDir(t) + "/sessions.yaml"
+		errors := errorCollector{t: t}
+		New(tmp, errors.collect()).PutToken(key, token)
+
+		c := New(tmp, errors.collect(), WithEncryption([]byte("some-passphrase")))
+		require.Equal(t, token, c.GetToken(key))
+		requireEncrypted(t, tmp)
+		require.Equal(t, token, c.GetToken(key))
+		errors.require(nil)
+	})
+
+	t.Run("sessions cannot be decrypted with a different secret", func(t *testing.T) {
+		t.Parallel()
+		tmp := testutil.TempDir(t) + "/sessions.yaml"
+		errors := errorCollector{t: t}
+		New(tmp, WithEncryption([]byte("some-passphrase"))).PutToken(key, token)
+
+		require.Nil(t, New(tmp, errors.collect(), WithEncryption([]byte("other-passphrase"))).GetToken(key))
+		errors.require([]string{
+			"failed to read cache, resetting: could not decrypt session file (was it encrypted with a different secret?): cipher: message authentication failed",
+		})
+	})
+
+	t.Run("encrypted sessions cannot be read without the secret", func(t *testing.T) {
+		t.Parallel()
+		tmp := testutil.TempDir(t) + "/sessions.yaml"
+		errors := errorCollector{t: t}
+		New(tmp, WithEncryption([]byte("some-passphrase"))).PutToken(key, token)
+
+		require.Nil(t, New(tmp, errors.collect()).GetToken(key))
+		errors.require([]string{
+			`failed to read cache, resetting: unsupported session version: v1.TypeMeta{Kind:"EncryptedSessionCache", APIVersion:"config.supervisor.pinniped.dev/v1alpha1"}`,
+		})
+	})
+}
@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package filesession implements a simple YAML file-based login.sessionCache.
@@ -6,6 +6,9 @@ package filesession
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -15,6 +18,7 @@ import (
 	"github.com/gofrs/flock"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"go.pinniped.dev/internal/fileencryption"
 	"go.pinniped.dev/pkg/oidcclient"
 	"go.pinniped.dev/pkg/oidcclient/oidctypes"
 )
@@ -25,6 +29,10 @@ const (
 
 	// defaultFileLockRetryInterval is how often we will poll while waiting for the file lock to become available.
 	defaultFileLockRetryInterval = 10 * time.Millisecond
+
+	// defaultRefreshLockTimeout is how long we will wait for another process to finish refreshing a session. It is
+	// longer than defaultFileLockTimeout, because the other process talks to the OIDC provider while holding the lock.
+	defaultRefreshLockTimeout = 30 * time.Second
 )
 
 // Option configures a cache in New().
@@ -38,9 +46,34 @@ func WithErrorReporter(reporter func(error)) Option {
 	}
 }
 
+// Keychain stores the refresh tokens of sessions outside of the session cache file, e.g. in the credential store of
+// the operating system.
+type Keychain interface {
+	Get(account string) (string, error)
+	Set(account string, secret string) error
+	Delete(account string) error
+}
+
+// WithKeychain is an Option that stores refresh tokens in the keychain instead of the session cache file. Whenever
+// the keychain fails to store a refresh token, it is stored in the file instead.
+func WithKeychain(keychain Keychain) Option {
+	return func(c *Cache) {
+		c.keychain = keychain
+	}
+}
+
+// WithEncryption is an Option that encrypts the session cache file with a key derived from the secret, e.g. a
+// passphrase. An existing plaintext file is encrypted when it is next written.
+func WithEncryption(secret []byte) Option {
+	return func(c *Cache) {
+		c.encryption = fileencryption.New(secret)
+	}
+}
+
 // New returns a login.SessionCache implementation backed by the specified file path.
 func New(path string, options ...Option) *Cache {
 	lock := flock.New(path + ".lock")
+	refreshLock := flock.New(path + ".refresh.lock")
 	c := Cache{
 		path: path,
 		trylockFunc: func() error {
@@ -49,8 +82,15 @@ func New(path string, options ...Option) *Cache {
 			_, err := lock.TryLockContext(ctx, defaultFileLockRetryInterval)
 			return err
 		},
-		unlockFunc:  lock.Unlock,
-		errReporter: func(_ error) {},
+		unlockFunc: lock.Unlock,
+		refreshTrylockFunc: func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), defaultRefreshLockTimeout)
+			defer cancel()
+			_, err := refreshLock.TryLockContext(ctx, defaultFileLockRetryInterval)
+			return err
+		},
+		refreshUnlockFunc: refreshLock.Unlock,
+		errReporter:       func(_ error) {},
 	}
 	for _, opt := range options {
 		opt(&c)
@@ -61,8 +101,30 @@ func New(path string, options ...Option) *Cache {
 type Cache struct {
 	path        string
 	errReporter func(error)
+	keychain    Keychain
+	encryption  *fileencryption.Encryption
 	trylockFunc func() error
 	unlockFunc  func() error
+
+	// The refresh lock is separate from the lock of the file, which GetToken and PutToken take while the refresh lock
+	// is held.
+	refreshTrylockFunc func() error
+	refreshUnlockFunc  func() error
+}
+
+// LockSession implements oidcclient.SessionCacheLocker. It waits until no other process is refreshing a session of
+// this cache. For simplicity, there is one lock for all sessions, since refreshes are rare and fast.
+func (c *Cache) LockSession(_ oidcclient.SessionCacheKey) (func(), error) {
+	if err := c.refreshTrylockFunc(); err != nil {
+		err = fmt.Errorf("could not lock session file for refresh: %w", err)
+		c.errReporter(err)
+		return nil, err
+	}
+	return func() {
+		if err := c.refreshUnlockFunc(); err != nil {
+			c.errReporter(fmt.Errorf("could not unlock session file after refresh: %w", err))
+		}
+	}, nil
 }
 
 // GetToken looks up the cached data for the given parameters. It may return nil if no valid matching session is cached.
@@ -76,7 +138,7 @@ func (c *Cache) GetToken(key oidcclient.SessionCacheKey) *oidctypes.Token {
 	var result *oidctypes.Token
 	c.withCache(func(cache *sessionCache) {
 		if entry := cache.lookup(key); entry != nil {
-			result = &entry.Tokens
+			result = c.tokensWithRefreshTokenFromKeychain(entry)
 			entry.LastUsedTimestamp = metav1.Now()
 		}
 	})
@@ -97,20 +159,124 @@ func (c *Cache) PutToken(key oidcclient.SessionCacheKey, token *oidctypes.Token)
 		// Find the existing entry, if one exists
 		if match := cache.lookup(key); match != nil {
 			// Update the stored token.
-			match.Tokens = *token
+			c.setTokens(match, token)
 			match.LastUsedTimestamp = metav1.Now()
 			return
 		}
 
 		// If there's not an entry for this key, insert one.
 		now := metav1.Now()
-		cache.insert(sessionEntry{
+		entry := sessionEntry{
 			Key:               key,
 			CreationTimestamp: now,
 			LastUsedTimestamp: now,
-			Tokens:            *token,
-		})
+		}
+		c.setTokens(&entry, token)
+		cache.insert(entry)
+	})
+}
+
+// Session is a session which was listed by ListTokens or removed from the session cache by DeleteTokens.
+type Session struct {
+	Key    oidcclient.SessionCacheKey
+	Tokens oidctypes.Token
+}
+
+// ListTokens returns the sessions whose keys match the provided function, without removing them from the session
+// cache, so that their tokens can be revoked before they are deleted.
+func (c *Cache) ListTokens(matches func(oidcclient.SessionCacheKey) bool) []Session {
+	// If the cache file does not exist, exit immediately with no error log
+	if _, err := os.Stat(c.path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	var result []Session
+	c.withCache(func(cache *sessionCache) {
+		for i := range cache.Sessions {
+			if matches(cache.Sessions[i].Key) {
+				result = append(result, Session{Key: cache.Sessions[i].Key, Tokens: *c.tokensWithRefreshTokenFromKeychain(&cache.Sessions[i])})
+			}
+		}
 	})
+	return result
+}
+
+// DeleteTokens removes the sessions whose keys match the provided function from the session cache, and returns them
+// so that their tokens can be revoked. Like the other operations, it may silently fail to update the session cache.
+func (c *Cache) DeleteTokens(matches func(oidcclient.SessionCacheKey) bool) []Session {
+	// If the cache file does not exist, exit immediately with no error log
+	if _, err := os.Stat(c.path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	var result []Session
+	c.withCache(func(cache *sessionCache) {
+		for _, entry := range cache.remove(matches) {
+			entry := entry
+			result = append(result, Session{Key: entry.Key, Tokens: *c.tokensWithRefreshTokenFromKeychain(&entry)})
+			if entry.KeychainAccount != "" && c.keychain != nil {
+				if err := c.keychain.Delete(entry.KeychainAccount); err != nil {
+					c.errReporter(fmt.Errorf("could not delete refresh token from keychain: %w", err))
+				}
+			}
+		}
+	})
+	return result
+}
+
+// setTokens updates the tokens of an entry, storing the refresh token in the keychain when there is one.
+func (c *Cache) setTokens(entry *sessionEntry, token *oidctypes.Token) {
+	entry.Tokens = *token
+	if c.keychain == nil {
+		entry.KeychainAccount = ""
+		return
+	}
+
+	if token.RefreshToken == nil || token.RefreshToken.Token == "" {
+		if entry.KeychainAccount != "" {
+			if err := c.keychain.Delete(entry.KeychainAccount); err != nil {
+				c.errReporter(fmt.Errorf("could not delete refresh token from keychain: %w", err))
+			}
+			entry.KeychainAccount = ""
+		}
+		return
+	}
+
+	account := keychainAccount(entry.Key)
+	if err := c.keychain.Set(account, token.RefreshToken.Token); err != nil {
+		c.errReporter(fmt.Errorf("could not store refresh token in keychain, storing it in the session file instead: %w", err))
+		entry.KeychainAccount = ""
+		return
+	}
+	entry.Tokens.RefreshToken = nil
+	entry.KeychainAccount = account
+}
+
+// tokensWithRefreshTokenFromKeychain returns a copy of the tokens of an entry, including its refresh token when that
+// is stored in the keychain. When the keychain fails, the tokens are returned without a refresh token.
+func (c *Cache) tokensWithRefreshTokenFromKeychain(entry *sessionEntry) *oidctypes.Token {
+	tokens := entry.Tokens
+	if entry.KeychainAccount == "" {
+		return &tokens
+	}
+	if c.keychain == nil {
+		c.errReporter(fmt.Errorf("refresh token is stored in keychain, but no keychain is configured"))
+		return &tokens
+	}
+	refreshToken, err := c.keychain.Get(entry.KeychainAccount)
+	if err != nil {
+		c.errReporter(fmt.Errorf("could not read refresh token from keychain: %w", err))
+		return &tokens
+	}
+	tokens.RefreshToken = &oidctypes.RefreshToken{Token: refreshToken}
+	return &tokens
+}
+
+// keychainAccount returns the account of the keychain item for the refresh token of a session.
+func keychainAccount(key oidcclient.SessionCacheKey) string {
+	keyJSON, _ := json.Marshal(key)
+	hash := sha256.Sum256(keyJSON)
+	return "session-" + hex.EncodeToString(hash[:16])
 }
 
 // withCache is an internal helper which locks, reads the cache, processes/mutates it with the provided function, then
@@ -130,7 +296,11 @@ func (c *Cache) withCache(transact func(*sessionCache)) {
 	}()
 
 	// Try to read the existing cache.
-	cache, err := readSessionCache(c.path)
+	read := readSessionCache
+	if c.encryption != nil {
+		read = func(path string) (*sessionCache, error) { return readEncryptedSessionCache(path, c.encryption) }
+	}
+	cache, err := read(c.path)
 	if err != nil {
 		// If that fails, fall back to resetting to a blank slate.
 		c.errReporter(fmt.Errorf("failed to read cache, resetting: %w", err))
@@ -141,7 +311,11 @@ func (c *Cache) withCache(transact func(*sessionCache)) {
 	transact(cache)
 
 	// Marshal the session back to YAML and save it to the file.
-	if err := cache.writeTo(c.path); err != nil {
+	write := cache.writeTo
+	if c.encryption != nil {
+		write = func(path string) error { return cache.writeEncryptedTo(path, c.encryption) }
+	}
+	if err := write(c.path); err != nil {
 		c.errReporter(fmt.Errorf("could not write session cache: %w", err))
 	}
 }
@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package filesession
@@ -84,7 +84,7 @@ func TestGetToken(t *testing.T) {
 			key: oidcclient.SessionCacheKey{},
 			wantErrors: []string{
 				"failed to read cache, resetting: could not read session file: read TEMPFILE: is a directory",
-				"could not write session cache: open TEMPFILE: is a directory",
+				"could not write session cache: rename TEMPFILE: " + renameOntoDirectoryError(t),
 			},
 		},
 		{
@@ -406,7 +406,7 @@ func TestPutToken(t *testing.T) {
 			},
 			wantErrors: []string{
 				"failed to read cache, resetting: could not read session file: read TEMPFILE: is a directory",
-				"could not write session cache: open TEMPFILE: is a directory",
+				"could not write session cache: rename TEMPFILE: " + renameOntoDirectoryError(t),
 			},
 			wantTestFile: func(t *testing.T, tmp string) {
 				// cache, err := readSessionCache(tmp)
@@ -435,6 +435,310 @@ func TestPutToken(t *testing.T) {
 	}
 }
 
+func TestDeleteTokens(t *testing.T) {
+	t.Parallel()
+	now := time.Now().Round(1 * time.Second)
+	newEntry := func(issuer string, token string) sessionEntry {
+		return sessionEntry{
+			Key: oidcclient.SessionCacheKey{
+				Issuer:      issuer,
+				ClientID:    "test-client-id",
+				Scopes:      []string{"email", "offline_access", "openid", "profile"},
+				RedirectURI: "http://localhost:0/callback",
+			},
+			CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+			LastUsedTimestamp: metav1.NewTime(now.Add(-1 * time.Hour)),
+			Tokens:            oidctypes.Token{RefreshToken: &oidctypes.RefreshToken{Token: token}},
+		}
+	}
+	tests := []struct {
+		name         string
+		makeTestFile func(t *testing.T, tmp string)
+		matches      func(oidcclient.SessionCacheKey) bool
+		want         []Session
+		wantErrors   []string
+		wantTestFile func(t *testing.T, tmp string)
+	}{
+		{
+			name:    "file does not exist",
+			matches: func(oidcclient.SessionCacheKey) bool { return true },
+			wantTestFile: func(t *testing.T, tmp string) {
+				_, err := os.Stat(tmp)
+				require.True(t, os.IsNotExist(err))
+			},
+		},
+		{
+			name: "invalid file",
+			makeTestFile: func(t *testing.T, tmp string) {
+				require.NoError(t, ioutil.WriteFile(tmp, []byte("invalid yaml"), 0600))
+			},
+			matches: func(oidcclient.SessionCacheKey) bool { return true },
+			wantErrors: []string{
+				"failed to read cache, resetting: invalid session file: error unmarshaling JSON: while decoding JSON: json: cannot unmarshal string into Go value of type filesession.sessionCache",
+			},
+		},
+		{
+			name: "removes matching entries",
+			makeTestFile: func(t *testing.T, tmp string) {
+				validCache := emptySessionCache()
+				validCache.insert(newEntry("issuer-1", "refresh-token-1"), newEntry("issuer-2", "refresh-token-2"), newEntry("issuer-1", "refresh-token-3"))
+				require.NoError(t, validCache.writeTo(tmp))
+			},
+			matches: func(key oidcclient.SessionCacheKey) bool { return key.Issuer == "issuer-1" },
+			want: []Session{
+				{Key: newEntry("issuer-1", "").Key, Tokens: newEntry("", "refresh-token-1").Tokens},
+				{Key: newEntry("issuer-1", "").Key, Tokens: newEntry("", "refresh-token-3").Tokens},
+			},
+			wantTestFile: func(t *testing.T, tmp string) {
+				cache, err := readSessionCache(tmp)
+				require.NoError(t, err)
+				require.Len(t, cache.Sessions, 1)
+				require.Equal(t, "issuer-2", cache.Sessions[0].Key.Issuer)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmp := testutil.TempDir(t) + "/sessions.yaml"
+			if tt.makeTestFile != nil {
+				tt.makeTestFile(t, tmp)
+			}
+			// Initialize a cache with a reporter that collects errors
+			errors := errorCollector{t: t}
+			c := New(tmp, errors.collect())
+			got := c.DeleteTokens(tt.matches)
+			require.Equal(t, tt.want, got)
+			errors.require(tt.wantErrors, "TEMPFILE", tmp)
+			if tt.wantTestFile != nil {
+				tt.wantTestFile(t, tmp)
+			}
+		})
+	}
+}
+
+func TestListTokens(t *testing.T) {
+	t.Parallel()
+	now := time.Now().Round(1 * time.Second)
+	newEntry := func(issuer string, token string) sessionEntry {
+		return sessionEntry{
+			Key: oidcclient.SessionCacheKey{
+				Issuer:      issuer,
+				ClientID:    "test-client-id",
+				Scopes:      []string{"email", "offline_access", "openid", "profile"},
+				RedirectURI: "http://localhost:0/callback",
+			},
+			CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+			LastUsedTimestamp: metav1.NewTime(now.Add(-1 * time.Hour)),
+			Tokens:            oidctypes.Token{RefreshToken: &oidctypes.RefreshToken{Token: token}},
+		}
+	}
+
+	t.Run("file does not exist", func(t *testing.T) {
+		t.Parallel()
+		tmp := testutil.TempDir(t) + "/sessions.yaml"
+		require.Nil(t, New(tmp).ListTokens(func(oidcclient.SessionCacheKey) bool { return true }))
+		_, err := os.Stat(tmp)
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("lists matching entries without removing them", func(t *testing.T) {
+		t.Parallel()
+		tmp := testutil.TempDir(t) + "/sessions.yaml"
+		validCache := emptySessionCache()
+		validCache.insert(newEntry("issuer-1", "refresh-token-1"), newEntry("issuer-2", "refresh-token-2"))
+		require.NoError(t, validCache.writeTo(tmp))
+
+		errors := errorCollector{t: t}
+		got := New(tmp, errors.collect()).ListTokens(func(key oidcclient.SessionCacheKey) bool { return key.Issuer == "issuer-1" })
+		require.Equal(t, []Session{{Key: newEntry("issuer-1", "").Key, Tokens: newEntry("", "refresh-token-1").Tokens}}, got)
+		errors.require(nil)
+
+		cache, err := readSessionCache(tmp)
+		require.NoError(t, err)
+		require.Len(t, cache.Sessions, 2)
+	})
+}
+
+type fakeKeychain struct {
+	items     map[string]string
+	getErr    error
+	setErr    error
+	deleteErr error
+}
+
+func (k *fakeKeychain) Get(account string) (string, error) {
+	if k.getErr != nil {
+		return "", k.getErr
+	}
+	secret, ok := k.items[account]
+	if !ok {
+		return "", fmt.Errorf("not found")
+	}
+	return secret, nil
+}
+
+func (k *fakeKeychain) Set(account string, secret string) error {
+	if k.setErr != nil {
+		return k.setErr
+	}
+	k.items[account] = secret
+	return nil
+}
+
+func (k *fakeKeychain) Delete(account string) error {
+	if k.deleteErr != nil {
+		return k.deleteErr
+	}
+	delete(k.items, account)
+	return nil
+}
+
+func TestLockSession(t *testing.T) {
+	t.Parallel()
+
+	t.Run("waits for other processes", func(t *testing.T) {
+		t.Parallel()
+		tmp := testutil.TempDir(t) + "/sessions.yaml"
+		errors := &errorCollector{t: t}
+		c1 := New(tmp, errors.collect())
+		c2 := New(tmp, errors.collect()) // like another process, since each Cache has its own file handle
+
+		unlock1, err := c1.LockSession(oidcclient.SessionCacheKey{})
+		require.NoError(t, err)
+
+		// The lock is separate from the lock of the file, so the session can still be read and written.
+		c1.PutToken(oidcclient.SessionCacheKey{}, &oidctypes.Token{IDToken: &oidctypes.IDToken{Token: "test-id-token"}})
+		require.NotNil(t, c2.GetToken(oidcclient.SessionCacheKey{}))
+
+		locked2 := make(chan struct{})
+		go func() {
+			unlock2, err := c2.LockSession(oidcclient.SessionCacheKey{})
+			require.NoError(t, err)
+			close(locked2)
+			unlock2()
+		}()
+		select {
+		case <-locked2:
+			require.Fail(t, "expected the second lock to wait for the first one")
+		case <-time.After(100 * time.Millisecond):
+		}
+		unlock1()
+		select {
+		case <-locked2:
+		case <-time.After(10 * time.Second):
+			require.Fail(t, "expected the second lock to succeed after the first one was released")
+		}
+		errors.require(nil)
+	})
+
+	t.Run("lock and unlock errors", func(t *testing.T) {
+		t.Parallel()
+		errors := &errorCollector{t: t}
+		c := New(testutil.TempDir(t)+"/sessions.yaml", errors.collect())
+		c.refreshTrylockFunc = func() error { return fmt.Errorf("some lock error") }
+		unlock, err := c.LockSession(oidcclient.SessionCacheKey{})
+		require.EqualError(t, err, "could not lock session file for refresh: some lock error")
+		require.Nil(t, unlock)
+
+		c.refreshTrylockFunc = func() error { return nil }
+		c.refreshUnlockFunc = func() error { return fmt.Errorf("some unlock error") }
+		unlock, err = c.LockSession(oidcclient.SessionCacheKey{})
+		require.NoError(t, err)
+		unlock()
+		errors.require([]string{
+			"could not lock session file for refresh: some lock error",
+			"could not unlock session file after refresh: some unlock error",
+		})
+	})
+}
+
+func TestKeychain(t *testing.T) {
+	t.Parallel()
+	key := oidcclient.SessionCacheKey{
+		Issuer:      "test-issuer",
+		ClientID:    "test-client-id",
+		Scopes:      []string{"email", "offline_access", "openid", "profile"},
+		RedirectURI: "http://localhost:0/callback",
+	}
+	token := &oidctypes.Token{
+		IDToken:      &oidctypes.IDToken{Token: "test-id-token", Expiry: metav1.NewTime(time.Now().Add(1 * time.Hour).Round(time.Second).Local())},
+		RefreshToken: &oidctypes.RefreshToken{Token: "test-refresh-token"},
+	}
+	const account = "session-87e1a86d5c01c5f99e13c21fa622c2b8"
+
+	setup := func(t *testing.T, keychain *fakeKeychain) (*Cache, *errorCollector, string) {
+		tmp := testutil.TempDir(t) + "/sessions.yaml"
+		errors := &errorCollector{t: t}
+		return New(tmp, errors.collect(), WithKeychain(keychain)), errors, tmp
+	}
+
+	t.Run("refresh tokens are stored in the keychain", func(t *testing.T) {
+		t.Parallel()
+		keychain := &fakeKeychain{items: map[string]string{}}
+		c, errors, tmp := setup(t, keychain)
+
+		c.PutToken(key, token)
+		errors.require(nil)
+		require.Equal(t, map[string]string{account: "test-refresh-token"}, keychain.items)
+
+		cache, err := readSessionCache(tmp)
+		require.NoError(t, err)
+		require.Len(t, cache.Sessions, 1)
+		require.Equal(t, account, cache.Sessions[0].KeychainAccount)
+		require.Nil(t, cache.Sessions[0].Tokens.RefreshToken)
+		require.Equal(t, token.IDToken, cache.Sessions[0].Tokens.IDToken)
+
+		require.Equal(t, token, c.GetToken(key))
+		errors.require(nil)
+
+		// Storing a token without a refresh token removes the keychain item.
+		c.PutToken(key, &oidctypes.Token{IDToken: token.IDToken})
+		errors.require(nil)
+		require.Empty(t, keychain.items)
+		require.Equal(t, &oidctypes.Token{IDToken: token.IDToken}, c.GetToken(key))
+	})
+
+	t.Run("refresh tokens are stored in the file when the keychain fails", func(t *testing.T) {
+		t.Parallel()
+		keychain := &fakeKeychain{items: map[string]string{}, setErr: fmt.Errorf("some set error")}
+		c, errors, tmp := setup(t, keychain)
+
+		c.PutToken(key, token)
+		errors.require([]string{"could not store refresh token in keychain, storing it in the session file instead: some set error"})
+
+		cache, err := readSessionCache(tmp)
+		require.NoError(t, err)
+		require.Len(t, cache.Sessions, 1)
+		require.Empty(t, cache.Sessions[0].KeychainAccount)
+		require.Equal(t, *token, cache.Sessions[0].Tokens)
+	})
+
+	t.Run("tokens are returned without the refresh token when the keychain fails", func(t *testing.T) {
+		t.Parallel()
+		keychain := &fakeKeychain{items: map[string]string{}}
+		c, errors, _ := setup(t, keychain)
+
+		c.PutToken(key, token)
+		keychain.getErr = fmt.Errorf("some get error")
+		require.Equal(t, &oidctypes.Token{IDToken: token.IDToken}, c.GetToken(key))
+		errors.require([]string{"could not read refresh token from keychain: some get error"})
+	})
+
+	t.Run("deleted sessions include the refresh token and remove the keychain item", func(t *testing.T) {
+		t.Parallel()
+		keychain := &fakeKeychain{items: map[string]string{}}
+		c, errors, _ := setup(t, keychain)
+
+		c.PutToken(key, token)
+		sessions := c.DeleteTokens(func(oidcclient.SessionCacheKey) bool { return true })
+		errors.require(nil)
+		require.Equal(t, []Session{{Key: key, Tokens: *token}}, sessions)
+		require.Empty(t, keychain.items)
+	})
+}
+
 type errorCollector struct {
 	t   *testing.T
 	saw []error
@@ -1,29 +1,38 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package oidcclient implements a CLI OIDC login flow.
 package oidcclient
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"mime"
 	"net"
 	"net/http"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
-	"github.com/pkg/browser"
 	"golang.org/x/oauth2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"go.pinniped.dev/internal/browser"
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/httputil/securityheader"
+	supervisoroidc "go.pinniped.dev/internal/oidc"
 	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/oidc/staticadmin"
+	"go.pinniped.dev/internal/perror"
+	"go.pinniped.dev/internal/qrcode"
 	"go.pinniped.dev/internal/upstreamoidc"
 	"go.pinniped.dev/pkg/oidcclient/nonce"
 	"go.pinniped.dev/pkg/oidcclient/oidctypes"
@@ -41,8 +50,8 @@ const (
 	// Since these don't involve any user interaction, they should always be roughly as fast as network latency.
 	httpRequestTimeout = 60 * time.Second
 
-	// overallTimeout is the overall time that a login is allowed to take. This includes several user interactions, so
-	// we set this to be relatively long.
+	// overallTimeout is the default overall time that a login is allowed to take. This includes several user
+	// interactions, so we set this to be relatively long. It can be changed with WithLoginTimeout.
 	overallTimeout = 90 * time.Minute
 )
 
@@ -56,11 +65,33 @@ type handlerState struct {
 
 	requestedAudience string
 
+	upstreamIdentityProviderName string
+	upstreamIdentityProviderType string
+
+	username    string
+	password    string
+	staticAdmin bool
+
+	// The user is told where to log in on this writer when using the device authorization grant, see WithDeviceFlow.
+	deviceFlowOut io.Writer
+
+	// The user is told where to log in on the writer and pastes the authcode into the reader, see WithManualCodeEntry.
+	manualCodeIn  io.Reader
+	manualCodeOut io.Writer
+
+	// The URLs which the user is told to visit are also drawn as QR codes, see WithQRCode.
+	qrCode bool
+
 	httpClient *http.Client
 
-	// Parameters of the localhost listener.
-	listenAddr   string
-	callbackPath string
+	// Parameters of the localhost listener. The first free port in the range is used, and port 0 means an ephemeral
+	// port chosen by the operating system.
+	listenHost      string
+	listenPortFirst uint16
+	listenPortLast  uint16
+	callbackPath    string
+
+	loginTimeout time.Duration
 
 	// Generated parameters of a login flow.
 	provider     *oidc.Provider
@@ -76,6 +107,7 @@ type handlerState struct {
 	openURL         func(string) error
 	getProvider     func(*oauth2.Config, *oidc.Provider, *http.Client) provider.UpstreamOIDCIdentityProviderI
 	validateIDToken func(ctx context.Context, provider *oidc.Provider, audience string, token string) (*oidc.IDToken, error)
+	after           func(time.Duration) <-chan time.Time
 
 	callbacks chan callbackResult
 }
@@ -107,7 +139,49 @@ func WithContext(ctx context.Context) Option {
 // system at the time of the request.
 func WithListenPort(port uint16) Option {
 	return func(h *handlerState) error {
-		h.listenAddr = fmt.Sprintf("localhost:%d", port)
+		h.listenPortFirst = port
+		h.listenPortLast = port
+		return nil
+	}
+}
+
+// WithListenPortRange specifies a range of TCP listen ports on localhost. The first port of the range which is not
+// already in use will be used, which is useful when the authorization server only allows a few fixed redirect_uri
+// ports and some of them may be taken by other programs.
+func WithListenPortRange(first, last uint16) Option {
+	return func(h *handlerState) error {
+		if first == 0 || first > last {
+			return fmt.Errorf("invalid listen port range %d-%d", first, last)
+		}
+		h.listenPortFirst = first
+		h.listenPortLast = last
+		return nil
+	}
+}
+
+// WithListenAddress specifies the address which the localhost listener binds to, instead of "localhost". It must be
+// "localhost" or a loopback IP address such as "127.0.0.1" or "::1", since the authorization code callback must never
+// be reachable from other machines.
+func WithListenAddress(host string) Option {
+	return func(h *handlerState) error {
+		if host != "localhost" {
+			if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+				return fmt.Errorf("listen address %q is not localhost or a loopback IP address", host)
+			}
+		}
+		h.listenHost = host
+		return nil
+	}
+}
+
+// WithLoginTimeout specifies the overall time that a login is allowed to take, including the interactions with the
+// user. The default is 90 minutes.
+func WithLoginTimeout(timeout time.Duration) Option {
+	return func(h *handlerState) error {
+		if timeout <= 0 {
+			return fmt.Errorf("invalid login timeout %s (must be positive)", timeout)
+		}
+		h.loginTimeout = timeout
 		return nil
 	}
 }
@@ -122,7 +196,7 @@ func WithScopes(scopes []string) Option {
 }
 
 // WithBrowserOpen overrides the default "open browser" functionality with a custom callback. If not specified,
-// an implementation using https://github.com/pkg/browser will be used by default.
+// the default browser of the user is opened, which is the browser of Windows in the Windows Subsystem for Linux.
 func WithBrowserOpen(openURL func(url string) error) Option {
 	return func(h *handlerState) error {
 		h.openURL = openURL
@@ -136,6 +210,9 @@ type SessionCacheKey struct {
 	ClientID    string   `json:"clientID"`
 	Scopes      []string `json:"scopes"`
 	RedirectURI string   `json:"redirect_uri"`
+
+	UpstreamProviderName string `json:"upstream_provider_name,omitempty"`
+	UpstreamProviderType string `json:"upstream_provider_type,omitempty"`
 }
 
 type SessionCache interface {
@@ -143,6 +220,13 @@ type SessionCache interface {
 	PutToken(SessionCacheKey, *oidctypes.Token)
 }
 
+// SessionCacheLocker is implemented by a SessionCache which is shared by several processes, e.g. the session cache
+// file of the CLI, which every kubectl process reads and writes. Login holds the lock of a session while it refreshes
+// the session, so that concurrent processes do not both spend the same refresh token.
+type SessionCacheLocker interface {
+	LockSession(SessionCacheKey) (unlock func(), err error)
+}
+
 // WithSessionCache sets the session cache backend for storing and retrieving previously-issued ID tokens and refresh tokens.
 func WithSessionCache(cache SessionCache) Option {
 	return func(h *handlerState) error {
@@ -167,6 +251,72 @@ func WithRequestAudience(audience string) Option {
 	}
 }
 
+// WithUpstreamIdentityProvider causes the login flow to ask a Pinniped Supervisor to log in with the named upstream
+// identity provider of the given type (e.g. "oidc"). Either value may be empty, and the Supervisor only requires the
+// name when it has more than one upstream identity provider.
+func WithUpstreamIdentityProvider(name, idpType string) Option {
+	return func(h *handlerState) error {
+		h.upstreamIdentityProviderName = name
+		h.upstreamIdentityProviderType = idpType
+		return nil
+	}
+}
+
+// WithUsernamePassword causes the login flow to send the username and password directly to the authorization
+// endpoint of a Pinniped Supervisor instead of opening a browser, so that it can run without user interaction.
+// The Supervisor checks them with the upstream identity provider selected by WithUpstreamIdentityProvider, which
+// must allow the password grant.
+func WithUsernamePassword(username, password string) Option {
+	return func(h *handlerState) error {
+		h.username = username
+		h.password = password
+		return nil
+	}
+}
+
+// WithStaticAdminCredentials causes the login flow to authenticate as the static admin user of a Pinniped Supervisor.
+// It is like WithUsernamePassword, except that it selects the static admin identity provider instead of an upstream
+// identity provider.
+func WithStaticAdminCredentials(username, password string) Option {
+	return func(h *handlerState) error {
+		h.username = username
+		h.password = password
+		h.staticAdmin = true
+		return nil
+	}
+}
+
+// WithDeviceFlow causes the login flow to use the OAuth 2.0 device authorization grant (RFC 8628) instead of
+// opening a browser on this machine. The verification URL and user code are written to out, so the user can log in
+// with a browser on any other device while the CLI polls the token endpoint for the result.
+func WithDeviceFlow(out io.Writer) Option {
+	return func(h *handlerState) error {
+		h.deviceFlowOut = out
+		return nil
+	}
+}
+
+// WithManualCodeEntry causes the login flow to write the authorize URL to out instead of opening a browser and
+// listening on localhost for the callback. After the login, the Pinniped Supervisor shows the authorization code,
+// which the user pastes into in. This works in SSH sessions and containers, where a browser cannot reach the CLI.
+// Other OIDC providers usually do not support the out-of-band redirect_uri which this requires.
+func WithManualCodeEntry(in io.Reader, out io.Writer) Option {
+	return func(h *handlerState) error {
+		h.manualCodeIn = in
+		h.manualCodeOut = out
+		return nil
+	}
+}
+
+// WithQRCode causes the login flow to draw the URL which the user is told to visit with WithDeviceFlow or
+// WithManualCodeEntry as a QR code as well, so that the user can scan it with a phone instead of typing it.
+func WithQRCode() Option {
+	return func(h *handlerState) error {
+		h.qrCode = true
+		return nil
+	}
+}
+
 // nopCache is a SessionCache that doesn't actually do anything.
 type nopCache struct{}
 
@@ -174,14 +324,18 @@ func (*nopCache) GetToken(SessionCacheKey) *oidctypes.Token  { return nil }
 func (*nopCache) PutToken(SessionCacheKey, *oidctypes.Token) {}
 
 // Login performs an OAuth2/OIDC authorization code login using a localhost listener.
+//
+// The returned token has a RefreshToken when the session can be refreshed without user interaction. When an audience
+// was requested, this is the refresh token of the session which the exchanged token was issued for.
 func Login(issuer string, clientID string, opts ...Option) (*oidctypes.Token, error) {
 	h := handlerState{
 		issuer:       issuer,
 		clientID:     clientID,
-		listenAddr:   "localhost:0",
+		listenHost:   "localhost",
 		scopes:       []string{oidc.ScopeOfflineAccess, oidc.ScopeOpenID, "email", "profile"},
 		cache:        &nopCache{},
 		callbackPath: "/callback",
+		loginTimeout: overallTimeout,
 		ctx:          context.Background(),
 		callbacks:    make(chan callbackResult),
 		httpClient:   http.DefaultClient,
@@ -190,8 +344,9 @@ func Login(issuer string, clientID string, opts ...Option) (*oidctypes.Token, er
 		generateState: state.Generate,
 		generateNonce: nonce.Generate,
 		generatePKCE:  pkce.Generate,
-		openURL:       browser.OpenURL,
+		openURL:       browser.Open,
 		getProvider:   upstreamoidc.New,
+		after:         time.After,
 		validateIDToken: func(ctx context.Context, provider *oidc.Provider, audience string, token string) (*oidc.IDToken, error) {
 			return provider.Verifier(&oidc.Config{ClientID: audience}).Verify(ctx, token)
 		},
@@ -201,6 +356,10 @@ func Login(issuer string, clientID string, opts ...Option) (*oidctypes.Token, er
 			return nil, err
 		}
 	}
+	if h.staticAdmin {
+		h.upstreamIdentityProviderName = ""
+		h.upstreamIdentityProviderType = supervisoroidc.UpstreamIDPTypeStaticAdmin
+	}
 
 	// Copy the configured HTTP client to set a request timeout (the Go default client has no timeout configured).
 	httpClientWithTimeout := *h.httpClient
@@ -208,7 +367,7 @@ func Login(issuer string, clientID string, opts ...Option) (*oidctypes.Token, er
 	h.httpClient = &httpClientWithTimeout
 
 	// Always set a long, but non-infinite timeout for this operation.
-	ctx, cancel := context.WithTimeout(h.ctx, overallTimeout)
+	ctx, cancel := context.WithTimeout(h.ctx, h.loginTimeout)
 	defer cancel()
 	ctx = oidc.ClientContext(ctx, h.httpClient)
 	h.ctx = ctx
@@ -244,6 +403,7 @@ func Login(issuer string, clientID string, opts ...Option) (*oidctypes.Token, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange token: %w", err)
 	}
+	exchangedToken.RefreshToken = baseToken.RefreshToken
 	return exchangedToken, nil
 }
 
@@ -254,7 +414,10 @@ func (h *handlerState) baseLogin() (*oidctypes.Token, error) {
 		Issuer:      h.issuer,
 		ClientID:    h.clientID,
 		Scopes:      h.scopes,
-		RedirectURI: (&url.URL{Scheme: "http", Host: h.listenAddr, Path: h.callbackPath}).String(),
+		RedirectURI: (&url.URL{Scheme: "http", Host: h.listenHostPort(), Path: h.callbackPath}).String(),
+
+		UpstreamProviderName: h.upstreamIdentityProviderName,
+		UpstreamProviderType: h.upstreamIdentityProviderType,
 	}
 
 	// If the ID token is still valid for a bit, return it immediately and skip the rest of the flow.
@@ -270,19 +433,48 @@ func (h *handlerState) baseLogin() (*oidctypes.Token, error) {
 
 	// If there was a cached refresh token, attempt to use the refresh flow instead of a fresh login.
 	if cached != nil && cached.RefreshToken != nil && cached.RefreshToken.Token != "" {
-		freshToken, err := h.handleRefresh(h.ctx, cached.RefreshToken)
+		freshToken, err := h.refreshSession(cacheKey, cached)
 		if err != nil {
 			return nil, err
 		}
-		// If we got a fresh token, we can update the cache and return it. Otherwise we fall through to the full refresh flow.
+		// If we got a fresh token, we can return it. Otherwise we fall through to the full refresh flow.
 		if freshToken != nil {
-			h.cache.PutToken(cacheKey, freshToken)
 			return freshToken, nil
 		}
 	}
 
+	// Username/password logins do not need a browser or a callback listener.
+	if h.username != "" {
+		token, err := h.passwordLogin()
+		if err != nil {
+			return nil, err
+		}
+		h.cache.PutToken(cacheKey, token)
+		return token, nil
+	}
+
+	// Device logins do not need a callback listener either, since they poll the token endpoint for the result.
+	if h.deviceFlowOut != nil {
+		token, err := h.deviceLogin()
+		if err != nil {
+			return nil, err
+		}
+		h.cache.PutToken(cacheKey, token)
+		return token, nil
+	}
+
+	// Manual logins do not need a callback listener, since the user pastes the authcode.
+	if h.manualCodeIn != nil {
+		token, err := h.manualCodeLogin()
+		if err != nil {
+			return nil, err
+		}
+		h.cache.PutToken(cacheKey, token)
+		return token, nil
+	}
+
 	// Open a TCP listener and update the OAuth2 redirect_uri to match (in case we are using an ephemeral port number).
-	listener, err := net.Listen("tcp", h.listenAddr)
+	listener, err := h.listen()
 	if err != nil {
 		return nil, fmt.Errorf("could not open callback listener: %w", err)
 	}
@@ -297,13 +489,7 @@ func (h *handlerState) baseLogin() (*oidctypes.Token, error) {
 	defer shutdown()
 
 	// Open the authorize URL in the users browser.
-	authorizeURL := h.oauth2Config.AuthCodeURL(
-		h.state.String(),
-		oauth2.AccessTypeOffline,
-		h.nonce.Param(),
-		h.pkce.Challenge(),
-		h.pkce.Method(),
-	)
+	authorizeURL := h.oauth2Config.AuthCodeURL(h.state.String(), h.authorizeParams()...)
 	if err := h.openURL(authorizeURL); err != nil {
 		return nil, fmt.Errorf("could not open browser: %w", err)
 	}
@@ -321,6 +507,269 @@ func (h *handlerState) baseLogin() (*oidctypes.Token, error) {
 	}
 }
 
+// authorizeParams returns the parameters of the authorize request, besides the state.
+func (h *handlerState) authorizeParams() []oauth2.AuthCodeOption {
+	params := []oauth2.AuthCodeOption{
+		oauth2.AccessTypeOffline,
+		h.nonce.Param(),
+		h.pkce.Challenge(),
+		h.pkce.Method(),
+	}
+	if h.upstreamIdentityProviderName != "" {
+		params = append(params, oauth2.SetAuthURLParam(supervisoroidc.UpstreamIDPNameParamName, h.upstreamIdentityProviderName))
+	}
+	if h.upstreamIdentityProviderType != "" {
+		params = append(params, oauth2.SetAuthURLParam(supervisoroidc.UpstreamIDPTypeParamName, h.upstreamIdentityProviderType))
+	}
+	return params
+}
+
+// writeQRCode draws the URL as a QR code when WithQRCode was used. A URL which is too long for a QR code has already
+// been printed, so it is skipped.
+func (h *handlerState) writeQRCode(out io.Writer, url string) {
+	if !h.qrCode {
+		return
+	}
+	code, err := qrcode.Encode(url)
+	if err != nil {
+		return
+	}
+	_ = code.WriteTerminal(out)
+}
+
+func (h *handlerState) manualCodeLogin() (*oidctypes.Token, error) {
+	h.oauth2Config.RedirectURL = supervisoroidc.ManualCodeRedirectURI
+	authorizeURL := h.oauth2Config.AuthCodeURL(h.state.String(), h.authorizeParams()...)
+	_, _ = fmt.Fprintf(h.manualCodeOut, "To log in, visit %s\n", authorizeURL)
+	h.writeQRCode(h.manualCodeOut, authorizeURL)
+	_, _ = fmt.Fprint(h.manualCodeOut, "Then paste the code which is shown after the login: ")
+
+	// Read the code in the background, so that the login still times out when nobody enters it.
+	type readResult struct {
+		line string
+		err  error
+	}
+	lines := make(chan readResult, 1)
+	go func() {
+		line, err := bufio.NewReader(h.manualCodeIn).ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		lines <- readResult{line: line, err: err}
+	}()
+
+	var code string
+	select {
+	case <-h.ctx.Done():
+		return nil, fmt.Errorf("timed out waiting for the authorization code: %w", h.ctx.Err())
+	case result := <-lines:
+		if result.err != nil {
+			return nil, fmt.Errorf("could not read the authorization code: %w", result.err)
+		}
+		code = strings.TrimSpace(result.line)
+	}
+	if code == "" {
+		return nil, fmt.Errorf("no authorization code was entered")
+	}
+
+	// The state is not checked, since the user copied the code from the page of the issuer. A code which was issued
+	// to anyone else cannot be redeemed anyway, because it is bound to our PKCE code verifier.
+	token, err := h.getProvider(h.oauth2Config, h.provider, h.httpClient).
+		ExchangeAuthcodeAndValidateTokens(h.ctx, code, h.pkce, h.nonce, h.oauth2Config.RedirectURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not complete code exchange: %w", err)
+	}
+	return token, nil
+}
+
+func (h *handlerState) passwordLogin() (*oidctypes.Token, error) {
+	// Nothing listens on the redirect_uri, since the authorization code is read from the redirect response below.
+	h.oauth2Config.RedirectURL = (&url.URL{Scheme: "http", Host: "127.0.0.1", Path: h.callbackPath}).String()
+	authorizeURL := h.oauth2Config.AuthCodeURL(h.state.String(), h.authorizeParams()...)
+	req, err := http.NewRequestWithContext(h.ctx, http.MethodGet, authorizeURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not build authorization request: %w", err)
+	}
+	req.Header.Set(staticadmin.UsernameHeaderName, h.username)
+	req.Header.Set(staticadmin.PasswordHeaderName, h.password)
+
+	// Do not follow the redirect to the redirect_uri.
+	httpClient := *h.httpClient
+	httpClient.CheckRedirect = func(_ *http.Request, _ []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("authorization request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusFound {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		if pErr, ok := perror.FromHTTPResponse(resp, body); ok {
+			return nil, fmt.Errorf("authorization request failed: %w", pErr)
+		}
+		return nil, fmt.Errorf("authorization request returned unexpected HTTP response status %d", resp.StatusCode)
+	}
+
+	redirectLocation, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return nil, fmt.Errorf("authorization response had an invalid Location header: %w", err)
+	}
+	// Supervisors which do not know about username/password logins redirect to the login page of an upstream
+	// identity provider instead, which would need a browser.
+	if redirectURL, _ := url.Parse(h.oauth2Config.RedirectURL); redirectLocation.Host != redirectURL.Host || redirectLocation.Path != redirectURL.Path {
+		return nil, fmt.Errorf("issuer %q does not support username/password logins (it redirected to an interactive login page)", h.issuer)
+	}
+	params := redirectLocation.Query()
+	if err := h.state.Validate(params.Get("state")); err != nil {
+		return nil, fmt.Errorf("authorization response had a missing or invalid state parameter")
+	}
+	if errorParam := params.Get("error"); errorParam != "" {
+		return nil, fmt.Errorf("login failed with code %q: %s", errorParam, params.Get("error_description"))
+	}
+
+	token, err := h.getProvider(h.oauth2Config, h.provider, h.httpClient).
+		ExchangeAuthcodeAndValidateTokens(
+			h.ctx,
+			params.Get("code"),
+			h.pkce,
+			h.nonce,
+			h.oauth2Config.RedirectURL,
+		)
+	if err != nil {
+		return nil, fmt.Errorf("could not complete code exchange: %w", err)
+	}
+	return token, nil
+}
+
+func (h *handlerState) deviceLogin() (*oidctypes.Token, error) {
+	var discoveryClaims struct {
+		DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	}
+	if err := h.provider.Claims(&discoveryClaims); err != nil {
+		return nil, fmt.Errorf("could not decode discovery claims: %w", err)
+	}
+	if discoveryClaims.DeviceAuthorizationEndpoint == "" {
+		return nil, fmt.Errorf("issuer %q does not support the device authorization grant", h.issuer)
+	}
+
+	// Start the device authorization, see RFC 8628 section 3.1.
+	var authorization struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		Interval                int64  `json:"interval"`
+	}
+	deviceAuthorizationParams := url.Values{
+		"client_id": []string{h.clientID},
+		"scope":     []string{strings.Join(h.scopes, " ")},
+	}
+	if h.upstreamIdentityProviderName != "" {
+		deviceAuthorizationParams.Set(supervisoroidc.UpstreamIDPNameParamName, h.upstreamIdentityProviderName)
+	}
+	if h.upstreamIdentityProviderType != "" {
+		deviceAuthorizationParams.Set(supervisoroidc.UpstreamIDPTypeParamName, h.upstreamIdentityProviderType)
+	}
+	if err := h.postForm(discoveryClaims.DeviceAuthorizationEndpoint, deviceAuthorizationParams, &authorization); err != nil {
+		return nil, fmt.Errorf("device authorization request failed: %w", err)
+	}
+
+	if authorization.VerificationURIComplete != "" {
+		_, _ = fmt.Fprintf(h.deviceFlowOut, "To log in, visit %s (code %s)\n", authorization.VerificationURIComplete, authorization.UserCode)
+		h.writeQRCode(h.deviceFlowOut, authorization.VerificationURIComplete)
+	} else {
+		_, _ = fmt.Fprintf(h.deviceFlowOut, "To log in, visit %s and enter the code %s\n", authorization.VerificationURI, authorization.UserCode)
+		h.writeQRCode(h.deviceFlowOut, authorization.VerificationURI)
+	}
+
+	// Poll the token endpoint until the user has approved or denied the login, see RFC 8628 section 3.4.
+	interval := time.Duration(authorization.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	for {
+		select {
+		case <-h.ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for device authorization: %w", h.ctx.Err())
+		case <-h.after(interval):
+		}
+
+		var tokenResponse struct {
+			AccessToken  string `json:"access_token"`
+			TokenType    string `json:"token_type"`
+			RefreshToken string `json:"refresh_token"`
+			ExpiresIn    int64  `json:"expires_in"`
+			IDToken      string `json:"id_token"`
+		}
+		err := h.postForm(h.oauth2Config.Endpoint.TokenURL, url.Values{
+			"client_id":   []string{h.clientID},
+			"grant_type":  []string{"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": []string{authorization.DeviceCode},
+		}, &tokenResponse)
+
+		var oauthErr *oauthErrorResponse
+		switch {
+		case errors.As(err, &oauthErr) && oauthErr.Code == "authorization_pending":
+			continue
+		case errors.As(err, &oauthErr) && oauthErr.Code == "slow_down":
+			interval += 5 * time.Second
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("device login failed: %w", err)
+		}
+
+		tok := (&oauth2.Token{
+			AccessToken:  tokenResponse.AccessToken,
+			TokenType:    tokenResponse.TokenType,
+			RefreshToken: tokenResponse.RefreshToken,
+			Expiry:       time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second),
+		}).WithExtra(map[string]interface{}{"id_token": tokenResponse.IDToken})
+
+		// The device never saw the authorization request, so there is no nonce to validate.
+		return h.getProvider(h.oauth2Config, h.provider, h.httpClient).ValidateToken(h.ctx, tok, "")
+	}
+}
+
+// oauthErrorResponse is an OAuth 2.0 error response, see RFC 6749 section 5.2.
+type oauthErrorResponse struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oauthErrorResponse) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("login failed with code %q", e.Code)
+	}
+	return fmt.Sprintf("login failed with code %q: %s", e.Code, e.Description)
+}
+
+// postForm sends a form to an OAuth 2.0 endpoint and decodes its JSON response into result. OAuth 2.0 error responses
+// are returned as an *oauthErrorResponse.
+func (h *handlerState) postForm(endpoint string, form url.Values, result interface{}) error {
+	req, err := http.NewRequestWithContext(h.ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("could not build request: %w", err)
+	}
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr oauthErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&oauthErr); err != nil || oauthErr.Code == "" {
+			return fmt.Errorf("unexpected HTTP response status %d", resp.StatusCode)
+		}
+		return &oauthErr
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
 func (h *handlerState) initOIDCDiscovery() error {
 	// Make this method idempotent so it can be called in multiple cases with no extra network requests.
 	if h.provider != nil {
@@ -412,6 +861,32 @@ func (h *handlerState) tokenExchangeRFC8693(baseToken *oidctypes.Token) (*oidcty
 	}}, nil
 }
 
+// refreshSession refreshes a cached session and updates the cache. When the cache is shared with other processes, the
+// session is read again while holding its lock, since another process may have refreshed it in the meantime. Then the
+// refresh token has already been spent, but the new tokens can be used instead.
+func (h *handlerState) refreshSession(cacheKey SessionCacheKey, cached *oidctypes.Token) (*oidctypes.Token, error) {
+	if locker, ok := h.cache.(SessionCacheLocker); ok {
+		// When the lock fails, refresh anyway. That is no worse than a cache which cannot be locked at all.
+		if unlock, err := locker.LockSession(cacheKey); err == nil {
+			defer unlock()
+			cached = h.cache.GetToken(cacheKey)
+			if cached != nil && cached.IDToken != nil && time.Until(cached.IDToken.Expiry.Time) > minIDTokenValidity {
+				return cached, nil
+			}
+			if cached == nil || cached.RefreshToken == nil || cached.RefreshToken.Token == "" {
+				return nil, nil
+			}
+		}
+	}
+
+	freshToken, err := h.handleRefresh(h.ctx, cached.RefreshToken)
+	if err != nil || freshToken == nil {
+		return nil, err
+	}
+	h.cache.PutToken(cacheKey, freshToken)
+	return freshToken, nil
+}
+
 func (h *handlerState) handleRefresh(ctx context.Context, refreshToken *oidctypes.RefreshToken) (*oidctypes.Token, error) {
 	refreshSource := h.oauth2Config.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken.Token})
 
@@ -469,6 +944,29 @@ func (h *handlerState) handleAuthCodeCallback(w http.ResponseWriter, r *http.Req
 	return nil
 }
 
+// listenHostPort returns the configured address of the localhost listener, with the port range when there is one.
+// It is part of the session cache key, so it must not depend on which port is actually used.
+func (h *handlerState) listenHostPort() string {
+	port := strconv.Itoa(int(h.listenPortFirst))
+	if h.listenPortLast != h.listenPortFirst {
+		port += "-" + strconv.Itoa(int(h.listenPortLast))
+	}
+	return net.JoinHostPort(h.listenHost, port)
+}
+
+// listen opens the localhost listener on the first port of the configured range which is available.
+func (h *handlerState) listen() (net.Listener, error) {
+	var lastErr error
+	for port := int(h.listenPortFirst); port <= int(h.listenPortLast); port++ {
+		listener, err := net.Listen("tcp", net.JoinHostPort(h.listenHost, strconv.Itoa(port)))
+		if err == nil {
+			return listener, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
 func (h *handlerState) serve(listener net.Listener) func() {
 	mux := http.NewServeMux()
 	mux.Handle(h.callbackPath, httperr.HandlerFunc(h.handleAuthCodeCallback))
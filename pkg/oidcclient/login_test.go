@@ -1,15 +1,19 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidcclient
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,6 +27,8 @@ import (
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/mocks/mockupstreamoidcidentityprovider"
 	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/perror"
+	"go.pinniped.dev/internal/qrcode"
 	"go.pinniped.dev/internal/testutil"
 	"go.pinniped.dev/pkg/oidcclient/nonce"
 	"go.pinniped.dev/pkg/oidcclient/oidctypes"
@@ -51,6 +57,39 @@ func (m *mockSessionCache) PutToken(key SessionCacheKey, token *oidctypes.Token)
 	m.sawPutTokens = append(m.sawPutTokens, token)
 }
 
+// mockLockingSessionCache is a mockSessionCache which also implements SessionCacheLocker. While it is locked,
+// GetToken returns getReturnsTokenWhileLocked, as if another process had refreshed the session in the meantime.
+type mockLockingSessionCache struct {
+	mockSessionCache
+	getReturnsTokenWhileLocked *oidctypes.Token
+	lockErr                    error
+	locked                     bool
+	sawLockKeys                []SessionCacheKey
+}
+
+func (m *mockLockingSessionCache) GetToken(key SessionCacheKey) *oidctypes.Token {
+	token := m.mockSessionCache.GetToken(key)
+	if m.locked {
+		return m.getReturnsTokenWhileLocked
+	}
+	return token
+}
+
+func (m *mockLockingSessionCache) PutToken(key SessionCacheKey, token *oidctypes.Token) {
+	require.True(m.t, m.locked || m.lockErr != nil, "expected the session to be locked while it is refreshed")
+	m.mockSessionCache.PutToken(key, token)
+}
+
+func (m *mockLockingSessionCache) LockSession(key SessionCacheKey) (func(), error) {
+	m.sawLockKeys = append(m.sawLockKeys, key)
+	if m.lockErr != nil {
+		return nil, m.lockErr
+	}
+	require.False(m.t, m.locked, "expected the session not to be locked twice")
+	m.locked = true
+	return func() { m.locked = false }, nil
+}
+
 func TestLogin(t *testing.T) {
 	time1 := time.Date(2035, 10, 12, 13, 14, 15, 16, time.UTC)
 	time1Unix := int64(2075807775)
@@ -62,8 +101,15 @@ func TestLogin(t *testing.T) {
 		IDToken:      &oidctypes.IDToken{Token: "test-id-token", Expiry: metav1.NewTime(time1.Add(2 * time.Minute))},
 	}
 
+	// Occupy a port, so that the callback listener cannot use it.
+	usedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = usedListener.Close() })
+	usedPort := uint16(usedListener.Addr().(*net.TCPAddr).Port)
+
 	testExchangedToken := oidctypes.Token{
-		IDToken: &oidctypes.IDToken{Token: "test-id-token-with-requested-audience", Expiry: metav1.NewTime(time1.Add(3 * time.Minute))},
+		RefreshToken: testToken.RefreshToken,
+		IDToken:      &oidctypes.IDToken{Token: "test-id-token-with-requested-audience", Expiry: metav1.NewTime(time1.Add(3 * time.Minute))},
 	}
 
 	// Start a test server that returns 500 errors
@@ -103,18 +149,94 @@ func TestLogin(t *testing.T) {
 		}
 		w.Header().Set("content-type", "application/json")
 		type providerJSON struct {
-			Issuer   string `json:"issuer"`
-			AuthURL  string `json:"authorization_endpoint"`
-			TokenURL string `json:"token_endpoint"`
-			JWKSURL  string `json:"jwks_uri"`
+			Issuer        string `json:"issuer"`
+			AuthURL       string `json:"authorization_endpoint"`
+			TokenURL      string `json:"token_endpoint"`
+			JWKSURL       string `json:"jwks_uri"`
+			DeviceAuthURL string `json:"device_authorization_endpoint"`
 		}
 		_ = json.NewEncoder(w).Encode(&providerJSON{
-			Issuer:   successServer.URL,
-			AuthURL:  successServer.URL + "/authorize",
-			TokenURL: successServer.URL + "/token",
-			JWKSURL:  successServer.URL + "/keys",
+			Issuer:        successServer.URL,
+			AuthURL:       successServer.URL + "/authorize",
+			TokenURL:      successServer.URL + "/token",
+			JWKSURL:       successServer.URL + "/keys",
+			DeviceAuthURL: successServer.URL + "/device_authorization",
 		})
 	})
+	providerMux.HandleFunc("/device_authorization", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.FormValue("scope") != "test-scope" {
+			http.Error(w, "expected scope 'test-scope'", http.StatusBadRequest)
+			return
+		}
+		if name := r.FormValue("pinniped_idp_name"); name != "" && name != "some-upstream" {
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, `{"error":"invalid_request","error_description":"No upstream provider named \"%s\" is configured"}`, name)
+			return
+		}
+		deviceCode := "test-device-code"
+		switch r.FormValue("client_id") {
+		case "test-client-id":
+		case "test-client-id-denied":
+			deviceCode = "test-device-code-denied"
+		default:
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"unknown client"}`))
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"device_code":%q,"user_code":"BCDF-GHJK","verification_uri":%q,"verification_uri_complete":%q,"expires_in":900}`,
+			deviceCode, successServer.URL+"/device", successServer.URL+"/device?user_code=BCDF-GHJK")
+	})
+	devicePolls := 0
+	providerMux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		// This only handles username/password logins, since browser-based logins never reach the test server.
+		if r.Method != http.MethodGet {
+			http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Pinniped-Username") != "test-admin" {
+			http.Error(w, "expected Pinniped-Username to be 'test-admin'", http.StatusBadRequest)
+			return
+		}
+		redirectParams := url.Values{"state": []string{r.URL.Query().Get("state")}}
+		switch r.Header.Get("Pinniped-Password") {
+		case "test-password":
+			// Static admin logins select the static admin identity provider instead of any upstream.
+			switch idp := r.URL.Query().Get("pinniped_idp_name") + "/" + r.URL.Query().Get("pinniped_idp_type"); idp {
+			case "/static-admin":
+				redirectParams.Set("code", "test-authcode")
+			case "some-upstream/oidc":
+				redirectParams.Set("code", "test-upstream-authcode")
+			default:
+				http.Error(w, "unexpected identity provider "+idp, http.StatusBadRequest)
+				return
+			}
+		case "test-password-producing-invalid-state":
+			redirectParams.Set("state", "wrong-state")
+		case "test-password-producing-http-500":
+			http.Error(w, "some server error", http.StatusInternalServerError)
+			return
+		case "test-password-producing-coded-error":
+			perror.New(perror.CodeNotConfigured, "No upstream providers are configured").Respond(w)
+			return
+		case "test-password-producing-upstream-redirect":
+			http.Redirect(w, r, "https://upstream.example.com/authorize?state=upstream-state", http.StatusFound)
+			return
+		case "test-password-without-password-idp":
+			redirectParams.Set("error", "invalid_request")
+			redirectParams.Set("error_description", "The identity provider does not support username/password logins.")
+		default:
+			redirectParams.Set("error", "access_denied")
+			redirectParams.Set("error_description", "Username/password not accepted.")
+		}
+		http.Redirect(w, r, r.URL.Query().Get("redirect_uri")+"?"+redirectParams.Encode(), http.StatusFound)
+	})
 	providerMux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
@@ -151,6 +273,35 @@ func TestLogin(t *testing.T) {
 				return
 			}
 
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			w.Header().Set("content-type", "application/json")
+			if r.Form.Get("device_code") == "test-device-code-denied" {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"error":"access_denied","error_description":"The user denied the login."}`))
+				return
+			}
+			if r.Form.Get("device_code") != "test-device-code" {
+				http.Error(w, "expected device_code to be 'test-device-code'", http.StatusBadRequest)
+				return
+			}
+
+			// The first polls happen before the user has logged in.
+			devicePolls++
+			switch devicePolls {
+			case 1:
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"authorization_pending"}`))
+				return
+			case 2:
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"slow_down"}`))
+				return
+			}
+			response.AccessToken = testToken.AccessToken.Token
+			response.ExpiresIn = int64(time.Until(testToken.AccessToken.Expiry.Time).Seconds())
+			response.RefreshToken = testToken.RefreshToken.Token
+			response.IDToken = testToken.IDToken.Token
+
 		case "urn:ietf:params:oauth:grant-type:token-exchange":
 			if r.Form.Get("client_id") != "test-client-id" {
 				http.Error(w, "bad client_id", http.StatusBadRequest)
@@ -215,6 +366,27 @@ func TestLogin(t *testing.T) {
 			},
 			wantErr: "some option error",
 		},
+		{
+			name: "listen address which is not a loopback address",
+			opt: func(t *testing.T) Option {
+				return WithListenAddress("0.0.0.0")
+			},
+			wantErr: `listen address "0.0.0.0" is not localhost or a loopback IP address`,
+		},
+		{
+			name: "invalid listen port range",
+			opt: func(t *testing.T) Option {
+				return WithListenPortRange(49162, 49152)
+			},
+			wantErr: "invalid listen port range 49162-49152",
+		},
+		{
+			name: "invalid login timeout",
+			opt: func(t *testing.T) Option {
+				return WithLoginTimeout(-time.Minute)
+			},
+			wantErr: "invalid login timeout -1m0s (must be positive)",
+		},
 		{
 			name: "error generating state",
 			opt: func(t *testing.T) Option {
@@ -339,6 +511,112 @@ func TestLogin(t *testing.T) {
 			},
 			wantToken: &testToken,
 		},
+		{
+			name:     "session cache hit with refreshable token which another process refreshes while waiting for the lock",
+			issuer:   successServer.URL,
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					h.getProvider = func(_ *oauth2.Config, _ *oidc.Provider, _ *http.Client) provider.UpstreamOIDCIdentityProviderI {
+						t.Fatal("expected the session not to be refreshed again")
+						return nil
+					}
+
+					cache := &mockLockingSessionCache{
+						mockSessionCache: mockSessionCache{t: t, getReturnsToken: &oidctypes.Token{
+							IDToken: &oidctypes.IDToken{
+								Token:  "expired-test-id-token",
+								Expiry: metav1.Now(), // less than Now() + minIDTokenValidity
+							},
+							RefreshToken: &oidctypes.RefreshToken{Token: "test-refresh-token"},
+						}},
+						getReturnsTokenWhileLocked: &testToken,
+					}
+					t.Cleanup(func() {
+						require.Len(t, cache.sawGetKeys, 2)
+						require.Equal(t, cache.sawGetKeys[:1], cache.sawLockKeys)
+						require.Empty(t, cache.sawPutKeys)
+						require.False(t, cache.locked)
+					})
+					h.cache = cache
+					return nil
+				}
+			},
+			wantToken: &testToken,
+		},
+		{
+			name:     "session cache hit with refreshable token which is refreshed while holding the lock",
+			issuer:   successServer.URL,
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					h.getProvider = func(_ *oauth2.Config, _ *oidc.Provider, _ *http.Client) provider.UpstreamOIDCIdentityProviderI {
+						mock := mockUpstream(t)
+						mock.EXPECT().
+							ValidateToken(gomock.Any(), HasAccessToken(testToken.AccessToken.Token), nonce.Nonce("")).
+							Return(&testToken, nil)
+						return mock
+					}
+
+					expiredToken := &oidctypes.Token{
+						IDToken: &oidctypes.IDToken{
+							Token:  "expired-test-id-token",
+							Expiry: metav1.Now(), // less than Now() + minIDTokenValidity
+						},
+						RefreshToken: &oidctypes.RefreshToken{Token: "test-refresh-token"},
+					}
+					cache := &mockLockingSessionCache{
+						mockSessionCache:           mockSessionCache{t: t, getReturnsToken: expiredToken},
+						getReturnsTokenWhileLocked: expiredToken,
+					}
+					t.Cleanup(func() {
+						require.Len(t, cache.sawGetKeys, 2)
+						require.Equal(t, cache.sawGetKeys[:1], cache.sawLockKeys)
+						require.Equal(t, cache.sawGetKeys[:1], cache.sawPutKeys)
+						require.Equal(t, testToken.IDToken.Token, cache.sawPutTokens[0].IDToken.Token)
+						require.False(t, cache.locked)
+					})
+					h.cache = cache
+					return nil
+				}
+			},
+			wantToken: &testToken,
+		},
+		{
+			name:     "session cache hit with refreshable token, but the session cache cannot be locked",
+			issuer:   successServer.URL,
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					h.getProvider = func(_ *oauth2.Config, _ *oidc.Provider, _ *http.Client) provider.UpstreamOIDCIdentityProviderI {
+						mock := mockUpstream(t)
+						mock.EXPECT().
+							ValidateToken(gomock.Any(), HasAccessToken(testToken.AccessToken.Token), nonce.Nonce("")).
+							Return(&testToken, nil)
+						return mock
+					}
+
+					cache := &mockLockingSessionCache{
+						mockSessionCache: mockSessionCache{t: t, getReturnsToken: &oidctypes.Token{
+							IDToken: &oidctypes.IDToken{
+								Token:  "expired-test-id-token",
+								Expiry: metav1.Now(), // less than Now() + minIDTokenValidity
+							},
+							RefreshToken: &oidctypes.RefreshToken{Token: "test-refresh-token"},
+						}},
+						lockErr: fmt.Errorf("some lock error"),
+					}
+					t.Cleanup(func() {
+						require.Len(t, cache.sawGetKeys, 1)
+						require.Equal(t, cache.sawGetKeys, cache.sawLockKeys)
+						require.Equal(t, cache.sawGetKeys, cache.sawPutKeys)
+					})
+					h.cache = cache
+					return nil
+				}
+			},
+			wantToken: &testToken,
+		},
 		{
 			name:     "session cache hit but refresh returns invalid token",
 			issuer:   successServer.URL,
@@ -390,24 +668,35 @@ func TestLogin(t *testing.T) {
 					})
 					h.cache = cache
 
-					h.listenAddr = "invalid-listen-address"
+					h.listenHost = "[invalid-listen-address"
 
 					return nil
 				}
 			},
 			// Expect this to fall through to the authorization code flow, so it fails here.
-			wantErr: "could not open callback listener: listen tcp: address invalid-listen-address: missing port in address",
+			wantErr: "could not open callback listener: listen tcp: address [invalid-listen-address:0: missing ']' in address",
 		},
 		{
 			name: "listen failure",
 			opt: func(t *testing.T) Option {
 				return func(h *handlerState) error {
-					h.listenAddr = "invalid-listen-address"
+					h.listenHost = "[invalid-listen-address"
 					return nil
 				}
 			},
 			issuer:  successServer.URL,
-			wantErr: "could not open callback listener: listen tcp: address invalid-listen-address: missing port in address",
+			wantErr: "could not open callback listener: listen tcp: address [invalid-listen-address:0: missing ']' in address",
+		},
+		{
+			name: "listen failure because every port of the range is in use",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					require.NoError(t, WithListenAddress("127.0.0.1")(h))
+					return WithListenPortRange(usedPort, usedPort)(h)
+				}
+			},
+			issuer:  successServer.URL,
+			wantErr: fmt.Sprintf("could not open callback listener: listen tcp 127.0.0.1:%d: bind: address already in use", usedPort),
 		},
 		{
 			name: "browser open failure",
@@ -436,6 +725,20 @@ func TestLogin(t *testing.T) {
 			issuer:  successServer.URL,
 			wantErr: "timed out waiting for token callback: context canceled",
 		},
+		{
+			name: "login timeout expires while waiting for callback",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					h.openURL = func(_ string) error {
+						<-h.ctx.Done()
+						return nil
+					}
+					return WithLoginTimeout(time.Second)(h)
+				}
+			},
+			issuer:  successServer.URL,
+			wantErr: "timed out waiting for token callback: context deadline exceeded",
+		},
 		{
 			name: "callback returns error",
 			opt: func(t *testing.T) Option {
@@ -512,6 +815,358 @@ func TestLogin(t *testing.T) {
 			issuer:    successServer.URL,
 			wantToken: &testToken,
 		},
+		{
+			name:     "callback returns success with an upstream identity provider",
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					h.generateState = func() (state.State, error) { return "test-state", nil }
+					h.generatePKCE = func() (pkce.Code, error) { return "test-pkce", nil }
+					h.generateNonce = func() (nonce.Nonce, error) { return "test-nonce", nil }
+
+					cache := &mockSessionCache{t: t, getReturnsToken: nil}
+					cacheKey := SessionCacheKey{
+						Issuer:               successServer.URL,
+						ClientID:             "test-client-id",
+						Scopes:               []string{"test-scope"},
+						RedirectURI:          "http://localhost:0/callback",
+						UpstreamProviderName: "some-upstream",
+						UpstreamProviderType: "oidc",
+					}
+					t.Cleanup(func() {
+						require.Equal(t, []SessionCacheKey{cacheKey}, cache.sawGetKeys)
+						require.Equal(t, []SessionCacheKey{cacheKey}, cache.sawPutKeys)
+					})
+					require.NoError(t, WithSessionCache(cache)(h))
+					require.NoError(t, WithClient(&http.Client{Timeout: 10 * time.Second})(h))
+					require.NoError(t, WithUpstreamIdentityProvider("some-upstream", "oidc")(h))
+
+					h.openURL = func(actualURL string) error {
+						parsedActualURL, err := url.Parse(actualURL)
+						require.NoError(t, err)
+						actualParams := parsedActualURL.Query()
+						require.Equal(t, "some-upstream", actualParams.Get("pinniped_idp_name"))
+						require.Equal(t, "oidc", actualParams.Get("pinniped_idp_type"))
+
+						go func() {
+							h.callbacks <- callbackResult{token: &testToken}
+						}()
+						return nil
+					}
+					return nil
+				}
+			},
+			issuer:    successServer.URL,
+			wantToken: &testToken,
+		},
+		{
+			name:     "static admin login with the wrong password",
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return WithStaticAdminCredentials("test-admin", "wrong-password")
+			},
+			issuer:  successServer.URL,
+			wantErr: `login failed with code "access_denied": Username/password not accepted.`,
+		},
+		{
+			name:     "static admin login returns an unexpected HTTP status",
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return WithStaticAdminCredentials("test-admin", "test-password-producing-http-500")
+			},
+			issuer:  successServer.URL,
+			wantErr: "authorization request returned unexpected HTTP response status 500",
+		},
+		{
+			name:     "static admin login returns an error with a code",
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return WithStaticAdminCredentials("test-admin", "test-password-producing-coded-error")
+			},
+			issuer:  successServer.URL,
+			wantErr: "authorization request failed: No upstream providers are configured",
+		},
+		{
+			name:     "static admin login returns an invalid state",
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return WithStaticAdminCredentials("test-admin", "test-password-producing-invalid-state")
+			},
+			issuer:  successServer.URL,
+			wantErr: "authorization response had a missing or invalid state parameter",
+		},
+		{
+			name:     "username/password login when the issuer redirects to an interactive login page",
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return WithUsernamePassword("test-admin", "test-password-producing-upstream-redirect")
+			},
+			issuer:  successServer.URL,
+			wantErr: fmt.Sprintf("issuer %q does not support username/password logins (it redirected to an interactive login page)", successServer.URL),
+		},
+		{
+			name:     "username/password login when the issuer has no password-capable identity provider",
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return WithUsernamePassword("test-admin", "test-password-without-password-idp")
+			},
+			issuer:  successServer.URL,
+			wantErr: `login failed with code "invalid_request": The identity provider does not support username/password logins.`,
+		},
+		{
+			name:     "static admin login succeeds and ignores the upstream identity provider",
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					h.generateState = func() (state.State, error) { return "test-state", nil }
+					h.generatePKCE = func() (pkce.Code, error) { return "test-pkce", nil }
+					h.generateNonce = func() (nonce.Nonce, error) { return "test-nonce", nil }
+
+					cache := &mockSessionCache{t: t, getReturnsToken: nil}
+					cacheKey := SessionCacheKey{
+						Issuer:               successServer.URL,
+						ClientID:             "test-client-id",
+						Scopes:               []string{"test-scope"},
+						RedirectURI:          "http://localhost:0/callback",
+						UpstreamProviderType: "static-admin",
+					}
+					t.Cleanup(func() {
+						require.Equal(t, []SessionCacheKey{cacheKey}, cache.sawGetKeys)
+						require.Equal(t, []SessionCacheKey{cacheKey}, cache.sawPutKeys)
+						require.Equal(t, []*oidctypes.Token{&testToken}, cache.sawPutTokens)
+					})
+					require.NoError(t, WithSessionCache(cache)(h))
+					require.NoError(t, WithUpstreamIdentityProvider("some-upstream", "oidc")(h))
+					require.NoError(t, WithStaticAdminCredentials("test-admin", "test-password")(h))
+
+					h.openURL = func(_ string) error {
+						t.Fatal("expected the browser not to be opened")
+						return nil
+					}
+					h.getProvider = func(_ *oauth2.Config, _ *oidc.Provider, _ *http.Client) provider.UpstreamOIDCIdentityProviderI {
+						mock := mockUpstream(t)
+						mock.EXPECT().
+							ExchangeAuthcodeAndValidateTokens(gomock.Any(), "test-authcode", pkce.Code("test-pkce"), nonce.Nonce("test-nonce"), "http://127.0.0.1/callback").
+							Return(&testToken, nil)
+						return mock
+					}
+					return nil
+				}
+			},
+			issuer:    successServer.URL,
+			wantToken: &testToken,
+		},
+		{
+			name:     "username/password login with an upstream identity provider succeeds",
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					h.generateState = func() (state.State, error) { return "test-state", nil }
+					h.generatePKCE = func() (pkce.Code, error) { return "test-pkce", nil }
+					h.generateNonce = func() (nonce.Nonce, error) { return "test-nonce", nil }
+
+					cache := &mockSessionCache{t: t, getReturnsToken: nil}
+					cacheKey := SessionCacheKey{
+						Issuer:               successServer.URL,
+						ClientID:             "test-client-id",
+						Scopes:               []string{"test-scope"},
+						RedirectURI:          "http://localhost:0/callback",
+						UpstreamProviderName: "some-upstream",
+						UpstreamProviderType: "oidc",
+					}
+					t.Cleanup(func() {
+						require.Equal(t, []SessionCacheKey{cacheKey}, cache.sawGetKeys)
+						require.Equal(t, []SessionCacheKey{cacheKey}, cache.sawPutKeys)
+						require.Equal(t, []*oidctypes.Token{&testToken}, cache.sawPutTokens)
+					})
+					require.NoError(t, WithSessionCache(cache)(h))
+					require.NoError(t, WithUpstreamIdentityProvider("some-upstream", "oidc")(h))
+					require.NoError(t, WithUsernamePassword("test-admin", "test-password")(h))
+
+					h.openURL = func(_ string) error {
+						t.Fatal("expected the browser not to be opened")
+						return nil
+					}
+					h.getProvider = func(_ *oauth2.Config, _ *oidc.Provider, _ *http.Client) provider.UpstreamOIDCIdentityProviderI {
+						mock := mockUpstream(t)
+						mock.EXPECT().
+							ExchangeAuthcodeAndValidateTokens(gomock.Any(), "test-upstream-authcode", pkce.Code("test-pkce"), nonce.Nonce("test-nonce"), "http://127.0.0.1/callback").
+							Return(&testToken, nil)
+						return mock
+					}
+					return nil
+				}
+			},
+			issuer:    successServer.URL,
+			wantToken: &testToken,
+		},
+		{
+			name:     "device login with an issuer which does not support it",
+			issuer:   brokenTokenURLServer.URL,
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return WithDeviceFlow(&bytes.Buffer{})
+			},
+			wantErr: fmt.Sprintf("issuer %q does not support the device authorization grant", brokenTokenURLServer.URL),
+		},
+		{
+			name:     "device login with an unknown client",
+			issuer:   successServer.URL,
+			clientID: "test-unknown-client-id",
+			opt: func(t *testing.T) Option {
+				return WithDeviceFlow(&bytes.Buffer{})
+			},
+			wantErr: `device authorization request failed: login failed with code "invalid_client": unknown client`,
+		},
+		{
+			name:     "device login with an unknown upstream identity provider",
+			issuer:   successServer.URL,
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					require.NoError(t, WithUpstreamIdentityProvider("other-upstream", "oidc")(h))
+					return WithDeviceFlow(&bytes.Buffer{})(h)
+				}
+			},
+			wantErr: `device authorization request failed: login failed with code "invalid_request": No upstream provider named "other-upstream" is configured`,
+		},
+		{
+			name:     "device login which the user denies",
+			issuer:   successServer.URL,
+			clientID: "test-client-id-denied",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					h.after = func(time.Duration) <-chan time.Time { return time.After(0) }
+					return WithDeviceFlow(&bytes.Buffer{})(h)
+				}
+			},
+			wantErr: `device login failed: login failed with code "access_denied": The user denied the login.`,
+		},
+		{
+			name:     "device login succeeds after polling",
+			issuer:   successServer.URL,
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					cache := &mockSessionCache{t: t, getReturnsToken: nil}
+					t.Cleanup(func() {
+						require.Equal(t, []*oidctypes.Token{&testToken}, cache.sawPutTokens)
+					})
+					require.NoError(t, WithSessionCache(cache)(h))
+
+					var out bytes.Buffer
+					t.Cleanup(func() {
+						require.Equal(t, "To log in, visit "+successServer.URL+"/device?user_code=BCDF-GHJK (code BCDF-GHJK)\n", out.String())
+					})
+					require.NoError(t, WithDeviceFlow(&out)(h))
+
+					var sawIntervals []time.Duration
+					t.Cleanup(func() {
+						require.Equal(t, []time.Duration{5 * time.Second, 5 * time.Second, 10 * time.Second}, sawIntervals)
+					})
+					h.after = func(d time.Duration) <-chan time.Time {
+						sawIntervals = append(sawIntervals, d)
+						return time.After(0)
+					}
+
+					h.openURL = func(_ string) error {
+						t.Fatal("expected the browser not to be opened")
+						return nil
+					}
+					h.getProvider = func(_ *oauth2.Config, _ *oidc.Provider, _ *http.Client) provider.UpstreamOIDCIdentityProviderI {
+						mock := mockUpstream(t)
+						mock.EXPECT().
+							ValidateToken(gomock.Any(), HasAccessToken(testToken.AccessToken.Token), nonce.Nonce("")).
+							Return(&testToken, nil)
+						return mock
+					}
+					return nil
+				}
+			},
+			wantToken: &testToken,
+		},
+		{
+			name:     "manual login without a code",
+			issuer:   successServer.URL,
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return WithManualCodeEntry(strings.NewReader("  \n"), &bytes.Buffer{})
+			},
+			wantErr: "no authorization code was entered",
+		},
+		{
+			name:     "manual login times out while waiting for the code",
+			issuer:   successServer.URL,
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					in, _ := io.Pipe()
+					require.NoError(t, WithManualCodeEntry(in, &bytes.Buffer{})(h))
+					return WithLoginTimeout(time.Millisecond)(h)
+				}
+			},
+			wantErr: "timed out waiting for the authorization code: context deadline exceeded",
+		},
+		{
+			name:     "manual login with a code which cannot be exchanged",
+			issuer:   successServer.URL,
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					h.getProvider = func(_ *oauth2.Config, _ *oidc.Provider, _ *http.Client) provider.UpstreamOIDCIdentityProviderI {
+						mock := mockUpstream(t)
+						mock.EXPECT().
+							ExchangeAuthcodeAndValidateTokens(gomock.Any(), "invalid-authcode", gomock.Any(), gomock.Any(), "urn:ietf:wg:oauth:2.0:oob").
+							Return(nil, fmt.Errorf("some exchange error"))
+						return mock
+					}
+					return WithManualCodeEntry(strings.NewReader("invalid-authcode\n"), &bytes.Buffer{})(h)
+				}
+			},
+			wantErr: "could not complete code exchange: some exchange error",
+		},
+		{
+			name:     "manual login succeeds",
+			issuer:   successServer.URL,
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					h.generateState = func() (state.State, error) { return "test-state", nil }
+					h.generatePKCE = func() (pkce.Code, error) { return "test-pkce", nil }
+					h.generateNonce = func() (nonce.Nonce, error) { return "test-nonce", nil }
+
+					cache := &mockSessionCache{t: t, getReturnsToken: nil}
+					t.Cleanup(func() {
+						require.Equal(t, []*oidctypes.Token{&testToken}, cache.sawPutTokens)
+					})
+					require.NoError(t, WithSessionCache(cache)(h))
+
+					var out bytes.Buffer
+					t.Cleanup(func() {
+						require.Equal(t, "To log in, visit "+successServer.URL+"/authorize?"+
+							"access_type=offline&client_id=test-client-id&code_challenge=VVaezYqum7reIhoavCHD1n2d-piN3r_mywoYj7fCR7g&"+
+							"code_challenge_method=S256&nonce=test-nonce&redirect_uri=urn%3Aietf%3Awg%3Aoauth%3A2.0%3Aoob&"+
+							"response_type=code&scope=test-scope&state=test-state\n"+
+							"Then paste the code which is shown after the login: ", out.String())
+					})
+					require.NoError(t, WithManualCodeEntry(strings.NewReader(" test-authcode"), &out)(h))
+
+					h.openURL = func(_ string) error {
+						t.Fatal("expected the browser not to be opened")
+						return nil
+					}
+					h.getProvider = func(_ *oauth2.Config, _ *oidc.Provider, _ *http.Client) provider.UpstreamOIDCIdentityProviderI {
+						mock := mockUpstream(t)
+						mock.EXPECT().
+							ExchangeAuthcodeAndValidateTokens(gomock.Any(), "test-authcode", pkce.Code("test-pkce"), nonce.Nonce("test-nonce"), "urn:ietf:wg:oauth:2.0:oob").
+							Return(&testToken, nil)
+						return mock
+					}
+					return nil
+				}
+			},
+			wantToken: &testToken,
+		},
 		{
 			name:     "with requested audience, session cache hit with valid token, but discovery fails",
 			clientID: "test-client-id",
@@ -861,6 +1516,45 @@ func TestLogin(t *testing.T) {
 	}
 }
 
+func TestListenPortRange(t *testing.T) {
+	usedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = usedListener.Close() })
+	usedPort := uint16(usedListener.Addr().(*net.TCPAddr).Port)
+	if usedPort == 65535 {
+		t.Skip("no port left after the port which is in use")
+	}
+
+	h := handlerState{listenHost: "127.0.0.1"}
+	require.NoError(t, WithListenPortRange(usedPort, 65535)(&h))
+	require.Equal(t, fmt.Sprintf("127.0.0.1:%d-65535", usedPort), h.listenHostPort())
+
+	listener, err := h.listen()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	require.Greater(t, listener.Addr().(*net.TCPAddr).Port, int(usedPort))
+}
+
+func TestWriteQRCode(t *testing.T) {
+	const url = "https://issuer.example.com/device?user_code=BCDF-GHJK"
+	var out bytes.Buffer
+	(&handlerState{}).writeQRCode(&out, url)
+	require.Empty(t, out.String(), "without WithQRCode")
+
+	var h handlerState
+	require.NoError(t, WithQRCode()(&h))
+	h.writeQRCode(&out, url)
+	code, err := qrcode.Encode(url)
+	require.NoError(t, err)
+	var want bytes.Buffer
+	require.NoError(t, code.WriteTerminal(&want))
+	require.Equal(t, want.String(), out.String())
+
+	out.Reset()
+	h.writeQRCode(&out, "https://issuer.example.com/"+strings.Repeat("a", 3000))
+	require.Empty(t, out.String(), "URL is too long")
+}
+
 func TestHandleAuthCodeCallback(t *testing.T) {
 	const testRedirectURI = "http://127.0.0.1:12324/callback"
 
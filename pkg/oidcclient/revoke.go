@@ -0,0 +1,74 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidcclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/pkg/oidcclient/oidctypes"
+)
+
+// ErrRevocationNotSupported is returned by Revoke when the issuer does not advertise a revocation endpoint.
+const ErrRevocationNotSupported = constable.Error("issuer does not support token revocation")
+
+// Revoke revokes the session of a token at the RFC 7009 revocation endpoint of the issuer, so the session cannot be
+// used anymore, even by copies of the token. When the token has a refresh token it is revoked, which also revokes the
+// access tokens of the session, otherwise the access token is revoked. Tokens without either are ignored.
+func Revoke(ctx context.Context, issuer string, clientID string, token *oidctypes.Token, httpClient *http.Client) error {
+	var revokeToken, tokenTypeHint string
+	switch {
+	case token.RefreshToken != nil && token.RefreshToken.Token != "":
+		revokeToken, tokenTypeHint = token.RefreshToken.Token, "refresh_token"
+	case token.AccessToken != nil && token.AccessToken.Token != "":
+		revokeToken, tokenTypeHint = token.AccessToken.Token, "access_token"
+	default:
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpRequestTimeout)
+	defer cancel()
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, httpClient), issuer)
+	if err != nil {
+		return fmt.Errorf("could not perform OIDC discovery for %q: %w", issuer, err)
+	}
+	var metadata struct {
+		RevocationEndpoint string `json:"revocation_endpoint"`
+	}
+	if err := provider.Claims(&metadata); err != nil {
+		return fmt.Errorf("could not decode OIDC discovery metadata for %q: %w", issuer, err)
+	}
+	if metadata.RevocationEndpoint == "" {
+		return ErrRevocationNotSupported
+	}
+
+	reqBody := strings.NewReader(url.Values{
+		"client_id":       []string{clientID},
+		"token":           []string{revokeToken},
+		"token_type_hint": []string{tokenTypeHint},
+	}.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.RevocationEndpoint, reqBody)
+	if err != nil {
+		return fmt.Errorf("could not build revocation request: %w", err)
+	}
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not revoke token: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not revoke token: unexpected HTTP response status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidcclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/pkg/oidcclient/oidctypes"
+)
+
+func TestRevoke(t *testing.T) {
+	var gotForms []url.Values
+	newIssuer := func(t *testing.T, withRevocationEndpoint bool, revocationStatus int) string {
+		mux := http.NewServeMux()
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("content-type", "application/json")
+			metadata := map[string]string{
+				"issuer":                 server.URL,
+				"authorization_endpoint": server.URL + "/authorize",
+				"token_endpoint":         server.URL + "/token",
+				"jwks_uri":               server.URL + "/keys",
+			}
+			if withRevocationEndpoint {
+				metadata["revocation_endpoint"] = server.URL + "/revoke"
+			}
+			_ = json.NewEncoder(w).Encode(metadata)
+		})
+		mux.HandleFunc("/revoke", func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPost, r.Method)
+			require.NoError(t, r.ParseForm())
+			gotForms = append(gotForms, r.PostForm)
+			w.WriteHeader(revocationStatus)
+		})
+		return server.URL
+	}
+
+	tests := []struct {
+		name      string
+		issuer    func(t *testing.T) string
+		token     *oidctypes.Token
+		wantErr   string
+		wantForms []url.Values
+	}{
+		{
+			name:   "token without refresh or access token",
+			issuer: func(t *testing.T) string { return "https://does-not-exist.invalid" },
+			token:  &oidctypes.Token{IDToken: &oidctypes.IDToken{Token: "some-id-token"}},
+		},
+		{
+			name:   "refresh token is revoked",
+			issuer: func(t *testing.T) string { return newIssuer(t, true, http.StatusOK) },
+			token: &oidctypes.Token{
+				AccessToken:  &oidctypes.AccessToken{Token: "some-access-token"},
+				RefreshToken: &oidctypes.RefreshToken{Token: "some-refresh-token"},
+			},
+			wantForms: []url.Values{{
+				"client_id":       []string{"test-client-id"},
+				"token":           []string{"some-refresh-token"},
+				"token_type_hint": []string{"refresh_token"},
+			}},
+		},
+		{
+			name:   "access token is revoked when there is no refresh token",
+			issuer: func(t *testing.T) string { return newIssuer(t, true, http.StatusOK) },
+			token:  &oidctypes.Token{AccessToken: &oidctypes.AccessToken{Token: "some-access-token"}},
+			wantForms: []url.Values{{
+				"client_id":       []string{"test-client-id"},
+				"token":           []string{"some-access-token"},
+				"token_type_hint": []string{"access_token"},
+			}},
+		},
+		{
+			name:    "issuer does not support revocation",
+			issuer:  func(t *testing.T) string { return newIssuer(t, false, http.StatusOK) },
+			token:   &oidctypes.Token{RefreshToken: &oidctypes.RefreshToken{Token: "some-refresh-token"}},
+			wantErr: "issuer does not support token revocation",
+		},
+		{
+			name:    "revocation fails",
+			issuer:  func(t *testing.T) string { return newIssuer(t, true, http.StatusBadRequest) },
+			token:   &oidctypes.Token{RefreshToken: &oidctypes.RefreshToken{Token: "some-refresh-token"}},
+			wantErr: "could not revoke token: unexpected HTTP response status 400",
+			wantForms: []url.Values{{
+				"client_id":       []string{"test-client-id"},
+				"token":           []string{"some-refresh-token"},
+				"token_type_hint": []string{"refresh_token"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			gotForms = nil
+			err := Revoke(context.Background(), tt.issuer(t), "test-client-id", tt.token, http.DefaultClient)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantForms, gotForms)
+		})
+	}
+}
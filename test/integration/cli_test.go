@@ -197,9 +197,6 @@ func runPinnipedLoginOIDC(
 	// Make a temp directory to hold the session cache for this test.
 	sessionCachePath := testutil.TempDir(t) + "/sessions.yaml"
 
-	// Start the browser driver.
-	page := browsertest.Open(t)
-
 	// Start the CLI running the "login oidc [...]" command with stdout/stderr connected to pipes.
 	cmd := oidcLoginCommand(ctx, t, pinnipedExe, sessionCachePath)
 	stderr, err := cmd.StderrPipe()
@@ -269,23 +266,32 @@ func runPinnipedLoginOIDC(
 		require.Fail(t, "timed out waiting for login URL")
 	case loginURL = <-loginURLChan:
 	}
-	t.Logf("navigating to login page")
-	require.NoError(t, page.Navigate(loginURL))
-
-	// Expect to be redirected to the upstream provider and log in.
-	browsertest.LoginToUpstream(t, page, env.CLITestUpstream)
-
-	// Expect to be redirected to the localhost callback.
-	t.Logf("waiting for redirect to callback")
 	callbackURLPattern := regexp.MustCompile(`\A` + regexp.QuoteMeta(env.CLITestUpstream.CallbackURL) + `\?.+\z`)
-	browsertest.WaitForURL(t, page, callbackURLPattern)
+	var msg string
+	if env.Browserless {
+		// Log in to the upstream provider without a browser, and expect to be redirected to the localhost callback.
+		msg = browsertest.LoginWithoutBrowser(ctx, t, loginURL, env.CLITestUpstream, callbackURLPattern)
+	} else {
+		// Start the browser driver.
+		page := browsertest.Open(t)
+		t.Logf("navigating to login page")
+		require.NoError(t, page.Navigate(loginURL))
+
+		// Expect to be redirected to the upstream provider and log in.
+		browsertest.LoginToUpstream(t, page, env.CLITestUpstream)
+
+		// Expect to be redirected to the localhost callback.
+		t.Logf("waiting for redirect to callback")
+		browsertest.WaitForURL(t, page, callbackURLPattern)
+
+		// Wait for the "pre" element that gets rendered for a `text/plain` page.
+		browsertest.WaitForVisibleElements(t, page, "pre")
+		msg, err = page.First("pre").Text()
+		require.NoError(t, err)
+	}
 
-	// Wait for the "pre" element that gets rendered for a `text/plain` page, and
-	// assert that it contains the success message.
+	// Assert that the callback page contains the success message.
 	t.Logf("verifying success page")
-	browsertest.WaitForVisibleElements(t, page, "pre")
-	msg, err := page.First("pre").Text()
-	require.NoError(t, err)
 	require.Equal(t, "you have been logged in and may now close this tab", msg)
 
 	// Expect the CLI to output an ExecCredential in JSON format.
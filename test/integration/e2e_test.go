@@ -56,9 +56,6 @@ func TestE2EFullIntegration(t *testing.T) {
 	pinnipedExe := library.PinnipedCLIPath(t)
 	tempDir := testutil.TempDir(t)
 
-	// Start the browser driver.
-	page := browsertest.Open(t)
-
 	// Infer the downstream issuer URL from the callback associated with the upstream test client registration.
 	issuerURL, err := url.Parse(env.SupervisorTestUpstream.CallbackURL)
 	require.NoError(t, err)
@@ -127,7 +124,7 @@ func TestE2EFullIntegration(t *testing.T) {
 	authenticator := library.CreateTestJWTAuthenticator(ctx, t, authv1alpha.JWTAuthenticatorSpec{
 		Issuer:   downstream.Spec.Issuer,
 		Audience: clusterAudience,
-		TLS:      &authv1alpha.TLSSpec{CertificateAuthorityData: testCABundleBase64},
+		TLS:      &authv1alpha.JWTTLSSpec{CertificateAuthorityData: testCABundleBase64},
 	})
 
 	// Create a ClusterRoleBinding to give our test user from the upstream read-only access to the cluster.
@@ -241,22 +238,32 @@ func TestE2EFullIntegration(t *testing.T) {
 		require.Fail(t, "timed out waiting for login URL")
 	case loginURL = <-loginURLChan:
 	}
-	t.Logf("navigating to login page")
-	require.NoError(t, page.Navigate(loginURL))
-
-	// Expect to be redirected to the upstream provider and log in.
-	browsertest.LoginToUpstream(t, page, env.SupervisorTestUpstream)
-
-	// Expect to be redirected to the localhost callback.
-	t.Logf("waiting for redirect to callback")
-	browsertest.WaitForURL(t, page, regexp.MustCompile(`\Ahttp://127\.0\.0\.1:[0-9]+/callback\?.+\z`))
+	callbackURLPattern := regexp.MustCompile(`\Ahttp://127\.0\.0\.1:[0-9]+/callback\?.+\z`)
+	var msg string
+	if env.Browserless {
+		// Log in to the upstream provider without a browser, and expect to be redirected to the localhost callback.
+		msg = browsertest.LoginWithoutBrowser(ctx, t, loginURL, env.SupervisorTestUpstream, callbackURLPattern)
+	} else {
+		// Start the browser driver.
+		page := browsertest.Open(t)
+		t.Logf("navigating to login page")
+		require.NoError(t, page.Navigate(loginURL))
+
+		// Expect to be redirected to the upstream provider and log in.
+		browsertest.LoginToUpstream(t, page, env.SupervisorTestUpstream)
+
+		// Expect to be redirected to the localhost callback.
+		t.Logf("waiting for redirect to callback")
+		browsertest.WaitForURL(t, page, callbackURLPattern)
+
+		// Wait for the "pre" element that gets rendered for a `text/plain` page.
+		browsertest.WaitForVisibleElements(t, page, "pre")
+		msg, err = page.First("pre").Text()
+		require.NoError(t, err)
+	}
 
-	// Wait for the "pre" element that gets rendered for a `text/plain` page, and
-	// assert that it contains the success message.
+	// Assert that the callback page contains the success message.
 	t.Logf("verifying success page")
-	browsertest.WaitForVisibleElements(t, page, "pre")
-	msg, err := page.First("pre").Text()
-	require.NoError(t, err)
 	require.Equal(t, "you have been logged in and may now close this tab", msg)
 
 	// Expect the CLI to output a list of namespaces in JSON format.
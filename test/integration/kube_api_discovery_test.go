@@ -44,6 +44,7 @@ func TestGetAPIResourceList(t *testing.T) {
 		}
 	}
 	loginConciergeGV := makeGV("login", "concierge")
+	identityConciergeGV := makeGV("identity", "concierge")
 	authenticationConciergeGV := makeGV("authentication", "concierge")
 	configConciergeGV := makeGV("config", "concierge")
 	idpSupervisorGV := makeGV("idp", "supervisor")
@@ -79,6 +80,32 @@ func TestGetAPIResourceList(t *testing.T) {
 				},
 			},
 		},
+		{
+			group: metav1.APIGroup{
+				Name: identityConciergeGV.Group,
+				Versions: []metav1.GroupVersionForDiscovery{
+					{
+						GroupVersion: identityConciergeGV.String(),
+						Version:      identityConciergeGV.Version,
+					},
+				},
+				PreferredVersion: metav1.GroupVersionForDiscovery{
+					GroupVersion: identityConciergeGV.String(),
+					Version:      identityConciergeGV.Version,
+				},
+			},
+			resourceByVersion: map[string][]metav1.APIResource{
+				identityConciergeGV.String(): {
+					{
+						Name:       "whoamirequests",
+						Kind:       "WhoAmIRequest",
+						Verbs:      []string{"create", "list"},
+						Namespaced: false,
+						Categories: []string{"pinniped"},
+					},
+				},
+			},
+		},
 		{
 			group: metav1.APIGroup{
 				Name: configSupervisorGV.Group,
@@ -109,6 +136,28 @@ func TestGetAPIResourceList(t *testing.T) {
 						Kind:       "FederationDomain",
 						Verbs:      []string{"get", "patch", "update"},
 					},
+					{
+						Name:         "groupgrants",
+						SingularName: "groupgrant",
+						Namespaced:   true,
+						Kind:         "GroupGrant",
+						Verbs:        []string{"delete", "deletecollection", "get", "list", "patch", "create", "update", "watch"},
+						Categories:   []string{"pinniped"},
+					},
+					{
+						Name:       "groupgrants/status",
+						Namespaced: true,
+						Kind:       "GroupGrant",
+						Verbs:      []string{"get", "patch", "update"},
+					},
+					{
+						Name:         "userapprovals",
+						SingularName: "userapproval",
+						Namespaced:   true,
+						Kind:         "UserApproval",
+						Verbs:        []string{"delete", "deletecollection", "get", "list", "patch", "create", "update", "watch"},
+						Categories:   []string{"pinniped"},
+					},
 				},
 			},
 		},
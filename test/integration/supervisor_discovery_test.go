@@ -479,6 +479,8 @@ func requireWellKnownEndpointIsWorking(t *testing.T, supervisorScheme, superviso
       "issuer": "%s",
       "authorization_endpoint": "%s/oauth2/authorize",
       "token_endpoint": "%s/oauth2/token",
+      "revocation_endpoint": "%s/oauth2/revoke",
+      "device_authorization_endpoint": "%s/oauth2/device_authorization",
       "token_endpoint_auth_methods_supported": ["client_secret_basic"],
       "jwks_uri": "%s/jwks.json",
       "scopes_supported": ["openid", "offline"],
@@ -487,7 +489,7 @@ func requireWellKnownEndpointIsWorking(t *testing.T, supervisorScheme, superviso
       "subject_types_supported": ["public"],
       "id_token_signing_alg_values_supported": ["ES256"]
     }`)
-	expectedJSON := fmt.Sprintf(expectedResultTemplate, issuerName, issuerName, issuerName, issuerName)
+	expectedJSON := fmt.Sprintf(expectedResultTemplate, issuerName, issuerName, issuerName, issuerName, issuerName, issuerName)
 
 	require.Equal(t, "application/json", response.Header.Get("content-type"))
 	require.JSONEq(t, expectedJSON, responseBody)
@@ -53,3 +53,33 @@ func TestSupervisorHealthz(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "ok", string(responseBody))
 }
+
+// Each replica of the Supervisor reports its own readiness on the same public port as the health endpoint, so
+// that load balancers which route traffic within a zone can use it as well.
+func TestSupervisorReadyz(t *testing.T) {
+	env := library.IntegrationEnv(t)
+
+	if env.SupervisorHTTPAddress == "" {
+		t.Skip("PINNIPED_TEST_SUPERVISOR_HTTP_ADDRESS not defined")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	requestReadyEndpoint, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("http://%s/readyz", env.SupervisorHTTPAddress),
+		nil,
+	)
+	require.NoError(t, err)
+
+	response, err := http.DefaultClient.Do(requestReadyEndpoint)
+	require.NoError(t, err)
+	defer func() { _ = response.Body.Close() }()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(responseBody))
+}
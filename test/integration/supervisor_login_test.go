@@ -184,18 +184,24 @@ func TestSupervisorLogin(t *testing.T) {
 	require.NoError(t, authorizeResp.Body.Close())
 	expectSecurityHeaders(t, authorizeResp)
 
-	// Open the web browser and navigate to the downstream authorize URL.
-	page := browsertest.Open(t)
-	t.Logf("opening browser to downstream authorize URL %s", library.MaskTokens(downstreamAuthorizeURL))
-	require.NoError(t, page.Navigate(downstreamAuthorizeURL))
-
-	// Expect to be redirected to the upstream provider and log in.
-	browsertest.LoginToUpstream(t, page, env.SupervisorTestUpstream)
-
-	// Wait for the login to happen and us be redirected back to a localhost callback.
-	t.Logf("waiting for redirect to callback")
 	callbackURLPattern := regexp.MustCompile(`\A` + regexp.QuoteMeta(localCallbackServer.URL) + `\?.+\z`)
-	browsertest.WaitForURL(t, page, callbackURLPattern)
+	if env.Browserless {
+		// Log in to the upstream provider without a browser, and expect to be redirected back to a localhost callback.
+		t.Logf("requesting downstream authorize URL %s without a browser", library.MaskTokens(downstreamAuthorizeURL))
+		browsertest.LoginWithoutBrowser(ctx, t, downstreamAuthorizeURL, env.SupervisorTestUpstream, callbackURLPattern)
+	} else {
+		// Open the web browser and navigate to the downstream authorize URL.
+		page := browsertest.Open(t)
+		t.Logf("opening browser to downstream authorize URL %s", library.MaskTokens(downstreamAuthorizeURL))
+		require.NoError(t, page.Navigate(downstreamAuthorizeURL))
+
+		// Expect to be redirected to the upstream provider and log in.
+		browsertest.LoginToUpstream(t, page, env.SupervisorTestUpstream)
+
+		// Wait for the login to happen and us be redirected back to a localhost callback.
+		t.Logf("waiting for redirect to callback")
+		browsertest.WaitForURL(t, page, callbackURLPattern)
+	}
 
 	// Expect that our callback handler was invoked.
 	callback := localCallbackServer.waitForCallback(10 * time.Second)
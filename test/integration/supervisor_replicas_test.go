@@ -0,0 +1,125 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package integration
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	"go.pinniped.dev/pkg/oidcclient/nonce"
+	"go.pinniped.dev/pkg/oidcclient/pkce"
+	"go.pinniped.dev/pkg/oidcclient/state"
+	"go.pinniped.dev/test/library"
+)
+
+// The replicas of the Supervisor share their keys and sessions through Secrets, so a login which was started on one
+// replica can be finished on another, even when the FederationDomain was created just before the login started.
+func TestSupervisorAcrossReplicas(t *testing.T) {
+	env := library.IntegrationEnv(t)
+
+	if env.SupervisorHTTPAddress == "" {
+		t.Skip("PINNIPED_TEST_SUPERVISOR_HTTP_ADDRESS not defined")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	pods, err := library.NewKubernetesClientset(t).CoreV1().Pods(env.SupervisorNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=" + env.SupervisorAppName,
+	})
+	require.NoError(t, err)
+	if len(pods.Items) < 2 {
+		t.Skipf("the Supervisor has %d replicas, need at least 2", len(pods.Items))
+	}
+
+	// Talk to each replica directly. The first container port is the HTTP listener, which the Supervisor
+	// serves on TCP whenever PINNIPED_TEST_SUPERVISOR_HTTP_ADDRESS is defined.
+	var replicaAddresses []string
+	for i := range pods.Items[:2] {
+		pod := &pods.Items[i]
+		require.NotEmpty(t, pod.Spec.Containers[0].Ports)
+		replicaAddresses = append(replicaAddresses, library.PortForwardToPod(t, pod, int(pod.Spec.Containers[0].Ports[0].ContainerPort)))
+	}
+
+	// The requests are sent to the replicas with the Host header of the issuer, like the discovery test does.
+	issuerHost := fmt.Sprintf("replicas-%s.example.com", library.RandHex(t, 8))
+	issuer := fmt.Sprintf("https://%s/issuer", issuerHost)
+	library.CreateTestFederationDomain(ctx, t, issuer, "", configv1alpha1.SuccessFederationDomainStatusCondition)
+	library.CreateTestOIDCIdentityProvider(t, idpv1alpha1.OIDCIdentityProviderSpec{
+		Issuer: env.SupervisorTestUpstream.Issuer,
+		TLS: &idpv1alpha1.TLSSpec{
+			CertificateAuthorityData: base64.StdEncoding.EncodeToString([]byte(env.SupervisorTestUpstream.CABundle)),
+		},
+		Client: idpv1alpha1.OIDCClient{
+			SecretName: library.CreateClientCredsSecret(t, env.SupervisorTestUpstream.ClientID, env.SupervisorTestUpstream.ClientSecret).Name,
+		},
+	}, idpv1alpha1.PhaseReady)
+
+	httpClient := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	get := func(address string, path string, query url.Values, cookies ...*http.Cookie) *http.Response {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/issuer%s?%s", address, path, query.Encode()), nil)
+		require.NoError(t, err)
+		req.Host = issuerHost
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rsp, err := httpClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, rsp.Body.Close())
+		return rsp
+	}
+
+	for _, replicas := range [][2]string{{replicaAddresses[0], replicaAddresses[1]}, {replicaAddresses[1], replicaAddresses[0]}} {
+		startReplica, finishReplica := replicas[0], replicas[1]
+
+		stateParam, err := state.Generate()
+		require.NoError(t, err)
+		nonceParam, err := nonce.Generate()
+		require.NoError(t, err)
+		pkceParam, err := pkce.Generate()
+		require.NoError(t, err)
+
+		authorizeURL, err := url.Parse((&oauth2.Config{
+			ClientID:    "pinniped-cli",
+			Endpoint:    oauth2.Endpoint{AuthURL: issuer + "/oauth2/authorize"},
+			RedirectURL: "http://127.0.0.1/callback",
+			Scopes:      []string{"openid"},
+		}).AuthCodeURL(stateParam.String(), nonceParam.Param(), pkceParam.Challenge(), pkceParam.Method()))
+		require.NoError(t, err)
+
+		// Start the login on one replica, as soon as it knows about the new FederationDomain and its upstream.
+		var authorizeResponse *http.Response
+		assert.Eventually(t, func() bool {
+			authorizeResponse = get(startReplica, "/oauth2/authorize", authorizeURL.Query())
+			return authorizeResponse.StatusCode == http.StatusFound
+		}, time.Minute, 200*time.Millisecond)
+		require.Equal(t, http.StatusFound, authorizeResponse.StatusCode)
+		upstreamLocation, err := url.Parse(authorizeResponse.Header.Get("Location"))
+		require.NoError(t, err)
+		upstreamState := upstreamLocation.Query().Get("state")
+		require.NotEmpty(t, upstreamState)
+		require.NotEmpty(t, authorizeResponse.Cookies())
+
+		// Immediately finish it on the other replica. It must accept the state and the CSRF cookie which the first
+		// replica issued, so the request only fails when the made-up authcode is exchanged with the upstream.
+		callbackResponse := get(finishReplica, "/callback", url.Values{
+			"code":  []string{"some-made-up-authcode"},
+			"state": []string{upstreamState},
+		}, authorizeResponse.Cookies()...)
+		require.Equal(t, http.StatusBadGateway, callbackResponse.StatusCode)
+	}
+}
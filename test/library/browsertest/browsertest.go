@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package browsertest provides integration test helpers for our browser-based tests.
@@ -99,23 +99,26 @@ func WaitForURL(t *testing.T, page *agouti.Page, pat *regexp.Regexp) {
 	)
 }
 
-// LoginToUpstream expects the page to be redirected to one of several known upstream IDPs.
-// It knows how to enter the test username/password and submit the upstream login form.
-func LoginToUpstream(t *testing.T, page *agouti.Page, upstream library.TestOIDCUpstream) {
-	t.Helper()
+// upstreamLoginConfig describes how to log in to one of several known upstream IDPs.
+type upstreamLoginConfig struct {
+	Name                string
+	IssuerPattern       *regexp.Regexp
+	LoginPagePattern    *regexp.Regexp
+	UsernameSelector    string
+	PasswordSelector    string
+	LoginButtonSelector string
 
-	type config struct {
-		Name                string
-		IssuerPattern       *regexp.Regexp
-		LoginPagePattern    *regexp.Regexp
-		UsernameSelector    string
-		PasswordSelector    string
-		LoginButtonSelector string
-	}
+	// UsernameField and PasswordField are the names of the login form inputs, for logging in without a browser.
+	// They are empty when the login page cannot be used without running its JavaScript.
+	UsernameField string
+	PasswordField string
+}
+
+// lookupUpstreamLoginConfig finds the login config for the upstream by matching on the issuer URL.
+func lookupUpstreamLoginConfig(t *testing.T, upstream library.TestOIDCUpstream) *upstreamLoginConfig {
+	t.Helper()
 
-	// Lookup the provider by matching on the issuer URL.
-	var cfg *config
-	for _, p := range []*config{
+	for _, p := range []*upstreamLoginConfig{
 		{
 			Name:                "Okta",
 			IssuerPattern:       regexp.MustCompile(`\Ahttps://.+\.okta\.com/.+\z`),
@@ -131,17 +134,24 @@ func LoginToUpstream(t *testing.T, page *agouti.Page, upstream library.TestOIDCU
 			UsernameSelector:    "input#login",
 			PasswordSelector:    "input#password",
 			LoginButtonSelector: "button#submit-login",
+			UsernameField:       "login",
+			PasswordField:       "password",
 		},
 	} {
 		if p.IssuerPattern.MatchString(upstream.Issuer) {
-			cfg = p
-			break
+			return p
 		}
 	}
-	if cfg == nil {
-		require.Failf(t, "could not find login provider for issuer %q", upstream.Issuer)
-		return
-	}
+	require.Failf(t, "could not find login provider for issuer %q", upstream.Issuer)
+	return nil
+}
+
+// LoginToUpstream expects the page to be redirected to one of several known upstream IDPs.
+// It knows how to enter the test username/password and submit the upstream login form.
+func LoginToUpstream(t *testing.T, page *agouti.Page, upstream library.TestOIDCUpstream) {
+	t.Helper()
+
+	cfg := lookupUpstreamLoginConfig(t, upstream)
 
 	// Expect to be redirected to the login page.
 	t.Logf("waiting for redirect to %s login page", cfg.Name)
@@ -0,0 +1,123 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package browsertest
+
+import (
+	"context"
+	"crypto/tls"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/test/library"
+)
+
+// formActionPattern finds the action URL of the first HTML form on a page.
+var formActionPattern = regexp.MustCompile(`(?is)<form[^>]*\saction="([^"]*)"`)
+
+// LoginWithoutBrowser is a programmatic alternative to Open, LoginToUpstream, and WaitForURL, for environments
+// where Chrome is not available. Starting from loginURL, it follows redirects to the upstream login page, submits
+// the test username/password directly to the upstream login form, and then follows redirects until it reaches a
+// URL matching callbackURLPattern. It returns the body of the callback response.
+//
+// Only upstreams whose login page works without JavaScript are supported. The test is skipped for other upstreams.
+func LoginWithoutBrowser(
+	ctx context.Context,
+	t *testing.T,
+	loginURL string,
+	upstream library.TestOIDCUpstream,
+	callbackURLPattern *regexp.Regexp,
+) string {
+	t.Helper()
+
+	cfg := lookupUpstreamLoginConfig(t, upstream)
+	if cfg.UsernameField == "" || cfg.PasswordField == "" {
+		t.Skipf("logging into %s requires a real browser", cfg.Name)
+	}
+	client := newBrowserlessHTTPClient(t)
+
+	// Expect to be redirected to the login page.
+	t.Logf("following redirects to %s login page", cfg.Name)
+	loginPageURL, loginPage := doRequest(ctx, t, client, http.MethodGet, loginURL, nil)
+	require.Regexpf(t, cfg.LoginPagePattern, loginPageURL.String(), "expected to be redirected to the %s login page", cfg.Name)
+
+	// Find the login form on the page.
+	matches := formActionPattern.FindStringSubmatch(loginPage)
+	require.Lenf(t, matches, 2, "could not find a form on the %s login page:\n%s", cfg.Name, loginPage)
+	formActionURL, err := loginPageURL.Parse(html.UnescapeString(matches[1]))
+	require.NoError(t, err)
+
+	// Submit the username and password, then expect to eventually be redirected to the callback.
+	t.Logf("logging into %s", cfg.Name)
+	form := url.Values{
+		cfg.UsernameField: []string{upstream.Username},
+		cfg.PasswordField: []string{upstream.Password},
+	}
+	callbackURL, callbackPage := doRequest(ctx, t, client, http.MethodPost, formActionURL.String(), form)
+	require.Regexp(t, callbackURLPattern, callbackURL.String(), "expected to be redirected to the callback")
+	return strings.TrimSpace(callbackPage)
+}
+
+// newBrowserlessHTTPClient returns an *http.Client which behaves like the browser returned by Open: it keeps cookies,
+// uses the test proxy for everything except localhost callbacks, and ignores certificate errors.
+func newBrowserlessHTTPClient(t *testing.T) *http.Client {
+	env := library.IntegrationEnv(t)
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+
+	proxy := func(*http.Request) (*url.URL, error) { return nil, nil }
+	if env.Proxy != "" {
+		t.Logf("configuring HTTP client to use proxy %q", env.Proxy)
+		proxyURL, err := url.Parse(env.Proxy)
+		require.NoError(t, err)
+		proxy = func(req *http.Request) (*url.URL, error) {
+			if req.URL.Hostname() == "127.0.0.1" {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	}
+
+	return &http.Client{
+		Jar: jar,
+		Transport: &http.Transport{
+			Proxy:           proxy,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // Same as the browser, which ignores certificate errors.
+		},
+	}
+}
+
+// doRequest performs a request, following any redirects, and returns the final URL and the response body.
+func doRequest(ctx context.Context, t *testing.T, client *http.Client, method, requestURL string, form url.Values) (*url.URL, string) {
+	t.Helper()
+
+	var body *strings.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, body)
+	require.NoError(t, err)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, resp.Body.Close()) }()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	t.Logf("saw URL %s", library.MaskTokens(resp.Request.URL.String()))
+	require.Equalf(t, http.StatusOK, resp.StatusCode, "unexpected response from %s:\n%s", resp.Request.URL, string(respBody))
+	return resp.Request.URL, string(respBody)
+}
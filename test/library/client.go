@@ -207,7 +207,7 @@ func CreateTestJWTAuthenticatorForCLIUpstream(ctx context.Context, t *testing.T)
 	// JWTAuthenticator. Leaving TLSSpec set to nil will result in OIDC discovery using the OS's root
 	// CA store.
 	if testEnv.CLITestUpstream.CABundle != "" {
-		spec.TLS = &auth1alpha1.TLSSpec{
+		spec.TLS = &auth1alpha1.JWTTLSSpec{
 			CertificateAuthorityData: base64.StdEncoding.EncodeToString([]byte(testEnv.CLITestUpstream.CABundle)),
 		}
 	}
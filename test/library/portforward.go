@@ -0,0 +1,59 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package library
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// PortForwardToPod forwards a random local port to the given port of the pod until the test finishes, and returns the
+// local address. Unlike a request through a Service, every request to the address is served by this pod.
+func PortForwardToPod(t *testing.T, pod *corev1.Pod, podPort int) string {
+	t.Helper()
+
+	config := NewClientConfig(t)
+	transport, upgrader, err := spdy.RoundTripperFor(config)
+	require.NoError(t, err)
+
+	url := NewKubernetesClientset(t).CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	stopCh, readyCh := make(chan struct{}), make(chan struct{})
+	forwarder, err := portforward.New(dialer, []string{fmt.Sprintf("0:%d", podPort)}, stopCh, readyCh, ioutil.Discard, ioutil.Discard)
+	require.NoError(t, err)
+
+	var forwardErr error
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		forwardErr = forwarder.ForwardPorts()
+	}()
+	t.Cleanup(func() {
+		close(stopCh)
+		<-doneCh
+	})
+
+	select {
+	case <-readyCh:
+	case <-doneCh:
+		require.FailNowf(t, "port forward failed", "could not forward to pod %s/%s: %v", pod.Namespace, pod.Name, forwardErr)
+	}
+	ports, err := forwarder.GetPorts()
+	require.NoError(t, err)
+	require.Len(t, ports, 1)
+	return fmt.Sprintf("127.0.0.1:%d", ports[0].Local)
+}